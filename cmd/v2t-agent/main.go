@@ -0,0 +1,75 @@
+// Command v2t-agent runs on a GPU machine and transcribes the jobs of a v2t serve --agents started
+// elsewhere. It registers with the gRPC address of the server and pulls the tasks, so the server needs
+// no address or credentials of the machine:
+//
+//	v2t-agent -server 192.168.1.10:9090 -capacity 2
+//
+// The local whisper.cpp of config.yaml transcribes the tasks, -provider picks another provider.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	v2tv1 "tiktok-whisper/api/proto/v2t/v1"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/agent"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/server"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	hostname, _ := os.Hostname()
+	serverAddr := flag.String("server", "127.0.0.1:9090", "gRPC address of v2t serve --agents")
+	name := flag.String("name", hostname, "Name of the agent in the agent list of the server")
+	capacity := flag.Int("capacity", 1, "How many tasks to transcribe at the same time")
+	providerName := flag.String("provider", "", "Provider to transcribe with (default is the local whisper.cpp of config.yaml)")
+	tempDir := flag.String("temp-dir", "", "Directory keeping the audio of the running tasks (default is the system temp dir)")
+	logLevel := flag.String("log-level", "info", "Level of the log messages: debug, info, warn or error")
+	logFormat := flag.String("log-format", logging.FormatConsole, "Format of the log messages on stderr: console or json")
+	flag.Parse()
+	if err := logging.Setup(logging.Options{Level: *logLevel, Format: *logFormat}); err != nil {
+		log.Fatal(err)
+	}
+
+	var transcriber api.Transcriber
+	providers := []string{"whisper_cpp"}
+	if *providerName == "" {
+		transcriber = app.InitializeAgentTranscriber()
+	} else {
+		var err error
+		if transcriber, err = app.NewProvider(*providerName); err != nil {
+			log.Fatal(err)
+		}
+		providers = []string{*providerName}
+	}
+
+	conn, err := grpc.Dial(*serverAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(server.DefaultMaxUploadBytes)))
+	if err != nil {
+		log.Fatalf("connect to %s failed: %v", *serverAddr, err)
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	worker := agent.NewWorker(v2tv1.NewAgentServiceClient(conn), transcriber, agent.WorkerOptions{
+		Name:      *name,
+		Providers: providers,
+		Capacity:  *capacity,
+		TempDir:   *tempDir,
+	})
+	logging.L().Info("Pulling tasks", "server", *serverAddr)
+	if err = worker.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,77 @@
+package analyze
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/analytics"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user      string
+	threshold float64
+)
+
+func init() {
+	duplicatesCmd.Flags().StringVarP(&user, "user", "u", "default", "Whose transcriptions to compare")
+	duplicatesCmd.Flags().Float64Var(&threshold, "threshold", analytics.DefaultDuplicateThreshold, "Similarity from 0 to 1 from which a transcription is a duplicate of an earlier one")
+
+	Cmd.AddCommand(duplicatesCmd)
+}
+
+// Cmd represents the analyze command
+var Cmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Analyze the stored transcriptions of a user",
+}
+
+var duplicatesCmd = &cobra.Command{
+	Use:   "duplicates",
+	Short: "Find the transcriptions repeating an earlier one of the user",
+	Long: `Find the transcriptions repeating an earlier one of the user
+
+- Flags re-uploaded and nearly identical videos, the earliest upload is the original
+- Compares the embeddings of the vector index, see v2t embed, and the overlapping word sequences of the transcripts
+- A transcription as similar as --threshold by either measure is a duplicate
+- The duplicates found replace those of the previous run, serve shows them on /analytics/{user}`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if threshold <= 0 || threshold > 1 {
+			return errors.New(i18n.T("--threshold must be greater than 0 and at most 1"))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+
+		duplicates, err := analytics.DetectDuplicates(db, analytics.DuplicateOptions{User: user, Threshold: threshold})
+		if errors.Is(err, analytics.ErrDuplicatesNotSupported) {
+			return errors.New(i18n.T("the configured database does not keep duplicates"))
+		}
+		if err != nil {
+			return err
+		}
+		return output.Print(analytics.NewDuplicateEntries(duplicates), func(out io.Writer) error {
+			if len(duplicates) == 0 {
+				fmt.Fprint(out, i18n.T("No duplicates among the transcriptions of %s\n", user))
+				return nil
+			}
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("ID\tFILE\tDUPLICATE OF\tORIGINAL FILE\tSIMILARITY\tEMBEDDING\tTEXT"))
+			for _, d := range duplicates {
+				embedding := "-"
+				if d.EmbeddingSimilarity != 0 {
+					embedding = fmt.Sprintf("%.3f", d.EmbeddingSimilarity)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%.3f\t%s\t%.3f\n", d.TranscriptionID, d.FileName, d.DuplicateOf,
+					d.DuplicateFileName, d.Similarity, embedding, d.TextSimilarity)
+			}
+			return w.Flush()
+		})
+	},
+}
@@ -0,0 +1,117 @@
+package ask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/embedding"
+	"tiktok-whisper/internal/app/embedding/provider"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/llm"
+	"tiktok-whisper/internal/app/output"
+	"tiktok-whisper/internal/app/prompts"
+	"tiktok-whisper/internal/app/qa"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user string
+	topK int
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&user, "user", "u", "default", "Whose transcriptions to ask about")
+	Cmd.Flags().IntVarP(&topK, "top-k", "k", qa.DefaultTopK, "Number of relevant passages the answer is grounded in")
+}
+
+// Cmd represents the ask command
+var Cmd = &cobra.Command{
+	Use:   "ask <question>...",
+	Short: "Ask a question about all the transcriptions of a user",
+	Long: `Ask a question about all the transcriptions of a user
+
+- Finds the transcriptions nearest to the question in the vector index, run v2t embed first
+- Answers from their passages most similar to the question and cites them like [2], with the file and timestamp
+- Uses the language model of llm and the embedding model of embedding in config.yaml
+- Passages beyond the budget of ask in llm.budgets are left out, the least relevant first`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if topK <= 0 {
+			return errors.New(i18n.T("--top-k must be at least 1"))
+		}
+		if err := capability.Require(capability.LLM, capability.Embeddings); err != nil {
+			return err
+		}
+		e, err := provider.Default()
+		if err != nil {
+			return errors.New(i18n.T("Invalid embedding in config.yaml: %v", err))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		library := qa.NewLibrary(db, e, llm.Complete, config.Get().Embedding.BatchSize)
+		library.SetBudget(llm.Budget(config.Get().LLM, string(prompts.Ask)))
+		question := strings.Join(args, " ")
+		answer, err := library.Ask(ctx, user, question, topK)
+		switch {
+		case errors.Is(err, embedding.ErrNotSupported):
+			return errors.New(i18n.T("the configured database does not keep embeddings"))
+		case errors.Is(err, embedding.ErrNoIndex), errors.Is(err, qa.ErrNothingEmbedded):
+			return errors.New(i18n.T("none of the transcriptions of %s is embedded yet, run v2t embed run first", user))
+		case errors.Is(err, embedding.ErrOtherModel):
+			return errors.New(i18n.T("%v, set the model of the index as embedding in config.yaml", err))
+		case err != nil:
+			return err
+		}
+
+		result := reply{Question: question, Answer: answer.Text, Citations: make([]citation, 0, len(answer.Citations))}
+		for i, p := range answer.Citations {
+			result.Citations = append(result.Citations, citation{Index: i + 1, TranscriptionID: p.TranscriptionID,
+				File: p.File, Timestamp: p.Timestamp(), Start: p.Start, Text: p.Text, Similarity: p.Similarity})
+		}
+		return output.Print(result, func(out io.Writer) error {
+			fmt.Fprintln(out, answer.Text)
+			fmt.Fprintln(out)
+			for _, c := range result.Citations {
+				if c.Timestamp == "" {
+					fmt.Fprint(out, i18n.T("  [%d] %s (id %d)\n", c.Index, c.File, c.TranscriptionID))
+					continue
+				}
+				fmt.Fprint(out, i18n.T("  [%d] %s at %s (id %d)\n", c.Index, c.File, c.Timestamp, c.TranscriptionID))
+			}
+			return nil
+		})
+	},
+}
+
+// reply is the answer as printed by --json.
+type reply struct {
+	Question  string     `json:"question"`
+	Answer    string     `json:"answer"`
+	Citations []citation `json:"citations"`
+}
+
+// citation is a passage the answer cites as [Index].
+type citation struct {
+	Index           int    `json:"index"`
+	TranscriptionID int    `json:"transcription_id"`
+	File            string `json:"file"`
+	// Timestamp is empty for transcriptions stored without timestamps.
+	Timestamp  string  `json:"timestamp,omitempty"`
+	Start      float64 `json:"start_seconds"`
+	Text       string  `json:"text"`
+	Similarity float64 `json:"similarity"`
+}
@@ -0,0 +1,99 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/repository/bench"
+	"tiktok-whisper/internal/app/repository/pg"
+	"tiktok-whisper/internal/app/repository/sqlite"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rows        []int
+	queries     int
+	concurrency int
+	postgresDSN string
+	seed        int64
+)
+
+func init() {
+	dbCmd.Flags().IntSliceVar(&rows, "rows", []int{1000, 10000}, "Table sizes to measure GetAllByUser at")
+	dbCmd.Flags().IntVar(&queries, "queries", 20, "GetAllByUser calls per table size, and reads and writes per concurrent worker")
+	dbCmd.Flags().IntVar(&concurrency, "concurrency", 4, "Readers and writers running at the same time, 0 skips the concurrent measurement")
+	dbCmd.Flags().StringVar(&postgresDSN, "postgres", "",
+		"Also measure this PostgreSQL database, use a scratch database as the inserted rows stay")
+	dbCmd.Flags().Int64Var(&seed, "seed", 1, "Seed of the generated rows")
+
+	Cmd.AddCommand(dbCmd)
+}
+
+// Cmd represents the bench command
+var Cmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure the performance of v2t components",
+}
+
+// backend is a database to measure, open connects to it.
+type backend struct {
+	name string
+	open func() (repository.TranscriptionDAO, error)
+}
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Compare the database backends under load",
+	Long: `Compare the database backends under load
+
+- insert: rows inserted one by one, like convert records its results
+- get_all_by_user: latency of reading the history of the largest user as the table grows
+- concurrent_read, concurrent_insert: readers and writers running at the same time
+- SQLite is measured in a temp file, PostgreSQL only with --postgres`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.MkdirTemp("", "v2t-bench-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		backends := []backend{{name: "sqlite", open: func() (repository.TranscriptionDAO, error) {
+			return sqlite.NewSQLiteDB(filepath.Join(dir, "bench.db")), nil
+		}}}
+		if postgresDSN != "" {
+			backends = append(backends, backend{name: "postgres", open: func() (repository.TranscriptionDAO, error) {
+				return pg.NewPostgresDB(postgresDSN)
+			}})
+		}
+
+		opts := bench.Options{RowCounts: rows, Queries: queries, Concurrency: concurrency, Seed: seed}
+		runID := fmt.Sprintf("v2t-bench-%d", time.Now().Unix())
+		var results []bench.Result
+		for _, b := range backends {
+			fmt.Fprint(os.Stderr, i18n.T("Benchmarking %s...\n", b.name))
+			db, err := b.open()
+			if err != nil {
+				return err
+			}
+			r, err := bench.Run(b.name, db, runID, opts)
+			db.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %v", b.name, err)
+			}
+			results = append(results, r...)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("BACKEND\tMEASUREMENT\tROWS\tOPS\tOPS/S\tP50\tP95"))
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.0f\t%s\t%s\n", r.Backend, r.Measurement, r.Rows, r.Ops, r.OpsPerSecond(),
+				r.P50.Round(time.Microsecond), r.P95.Round(time.Microsecond))
+		}
+		return w.Flush()
+	},
+}
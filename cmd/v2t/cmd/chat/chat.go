@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/llm"
+	"tiktok-whisper/internal/app/prompts"
+	"tiktok-whisper/internal/app/qa"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	transcriptionID int
+	user            string
+)
+
+func init() {
+	Cmd.Flags().IntVarP(&transcriptionID, "id", "i", 0, "The id of the transcription to chat about")
+	Cmd.Flags().StringVarP(&user, "user", "u", "", "The user whose database holds the transcription (default database when empty)")
+	Cmd.MarkFlagRequired("id")
+}
+
+// Cmd represents the chat command
+var Cmd = &cobra.Command{
+	Use:   "chat [question]",
+	Short: "Ask questions about a single transcription",
+	Long: `Ask questions about a single transcription
+
+- Answers are grounded in the most relevant excerpts of that one transcription and cite them like [2]
+- With a question argument it answers once, otherwise it starts a conversation, type exit to quit
+- Uses the language model of llm in config.yaml, OpenAI unless set, e.g. a local Ollama model`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+
+		transcription, err := db.GetByID(transcriptionID)
+		if err != nil {
+			return errors.New(i18n.T("get transcription %d failed: %v", transcriptionID, err))
+		}
+
+		if err = capability.Require(capability.LLM); err != nil {
+			return err
+		}
+		conversation := qa.NewConversation(*transcription, llm.Complete)
+		// long conversations leave out their oldest turns
+		conversation.SetBudget(llm.Budget(config.Get().LLM, string(prompts.QA)))
+
+		if len(args) > 0 {
+			return ask(conversation, strings.Join(args, " "))
+		}
+
+		fmt.Print(i18n.T("Chatting about %s (%s), type exit to quit\n", transcription.Mp3FileName, transcription.User))
+		scanner := bufio.NewScanner(os.Stdin)
+		for {
+			fmt.Print("> ")
+			if !scanner.Scan() {
+				return scanner.Err()
+			}
+
+			question := strings.TrimSpace(scanner.Text())
+			if question == "exit" {
+				return nil
+			}
+			if question == "" {
+				continue
+			}
+
+			if err = ask(conversation, question); err != nil {
+				cmd.PrintErrln(err)
+			}
+		}
+	},
+}
+
+func ask(conversation *qa.Conversation, question string) error {
+	answer, err := conversation.Ask(question)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(answer.Text)
+	for _, c := range answer.Citations {
+		fmt.Print(i18n.T("  [%d] chars %d-%d\n", c.Index, c.Start, c.End))
+	}
+	return nil
+}
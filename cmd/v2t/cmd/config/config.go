@@ -1,21 +1,177 @@
 package config
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app/capability"
+	appconfig "tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/config/secrets"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+
 	"github.com/spf13/cobra"
 )
 
+func init() {
+	Cmd.AddCommand(setKeyCmd, deleteKeyCmd, keysCmd, capabilitiesCmd)
+}
+
 // Cmd represents the config command
 var Cmd = &cobra.Command{
 	Use:   "config",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("config called")
+	Short: "Manage the API keys of the providers",
+	Long: `Manage the API keys of the providers
+
+- Keys are stored in the OS keychain, or a file encrypted with the passphrase in V2T_SECRETS_PASSPHRASE
+- secrets.backend in config.yaml selects keychain, file or env
+- A provider without a stored key reads it from its environment variable, e.g. OPENAI_API_KEY`,
+}
+
+var setKeyCmd = &cobra.Command{
+	Use:   "set-key <provider>",
+	Short: "Store the API key of a provider, read from stdin",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider := args[0]
+		if _, ok := secrets.EnvVars[provider]; !ok {
+			return errors.New(i18n.T("unknown provider %s, supported: %s", provider, strings.Join(secrets.Providers(), ", ")))
+		}
+		store, err := openStore()
+		if err != nil {
+			return err
+		}
+
+		key, err := readSecret(i18n.T("API key for %s: ", provider))
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			return errors.New(i18n.T("no key given"))
+		}
+		if err = store.Set(provider, key); err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("stored the %s key in the %s store\n", provider, store.Name()))
+		return nil
 	},
 }
+
+var deleteKeyCmd = &cobra.Command{
+	Use:   "delete-key <provider>",
+	Short: "Remove the stored API key of a provider",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openStore()
+		if err != nil {
+			return err
+		}
+		err = store.Delete(args[0])
+		if errors.Is(err, secrets.ErrNotFound) {
+			return errors.New(i18n.T("no %s key in the %s store", args[0], store.Name()))
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("removed the %s key from the %s store\n", args[0], store.Name()))
+		return nil
+	},
+}
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "List where the API key of each provider comes from",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openStore()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("PROVIDER\tSOURCE\tENVIRONMENT VARIABLE"))
+		for _, provider := range secrets.Providers() {
+			source := "-"
+			if _, err := store.Get(provider); err == nil {
+				source = store.Name()
+			} else if _, ok := os.LookupEnv(secrets.EnvVars[provider]); ok {
+				source = "env"
+			} else if !errors.Is(err, secrets.ErrNotFound) {
+				source = i18n.T("error: %v", err)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", provider, source, secrets.EnvVars[provider])
+		}
+		return w.Flush()
+	},
+}
+
+var capabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "List the optional subsystems and how to enable the unavailable ones",
+	Long: `List the optional subsystems and how to enable the unavailable ones
+
+- ffmpeg converts audio, without it only the audio a provider accepts as it is can be transcribed
+- llm is the language model of chat, generate and the llm translation backend
+- embeddings need the OpenAI key or a local embedding model
+- A command needing an unavailable subsystem fails with exit status 7 and says how to enable it`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statuses := capability.Default().Statuses()
+		return output.Print(statuses, func(out io.Writer) error {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("CAPABILITY\tSTATUS\tGUIDANCE"))
+			for _, s := range statuses {
+				if s.Available {
+					fmt.Fprintf(w, "%s\t%s\t-\n", s.Name, i18n.T("ok"))
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, i18n.T("unavailable: %s", s.Error), s.Guidance)
+			}
+			return w.Flush()
+		})
+	},
+}
+
+// openStore opens the configured store, it asks for the passphrase of the encrypted file when
+// V2T_SECRETS_PASSPHRASE isn't set.
+func openStore() (secrets.Store, error) {
+	cfg := appconfig.Get().Secrets
+	if secrets.Backend(cfg) != "file" || os.Getenv(secrets.PassphraseEnv) != "" {
+		return secrets.New(cfg)
+	}
+	passphrase, err := readSecret(i18n.T("Passphrase of %s: ", secrets.File(cfg)))
+	if err != nil {
+		return nil, err
+	}
+	return secrets.NewFileStore(secrets.File(cfg), passphrase), nil
+}
+
+// readSecret reads a line from stdin, with the prompt and without echo when it is a terminal.
+func readSecret(prompt string) (string, error) {
+	stat, err := os.Stdin.Stat()
+	terminal := err == nil && stat.Mode()&os.ModeCharDevice != 0
+	if terminal {
+		fmt.Fprint(os.Stderr, prompt)
+		if err = stty("-echo"); err == nil {
+			defer func() {
+				stty("echo")
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read from stdin failed: %v", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
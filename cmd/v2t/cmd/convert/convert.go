@@ -1,10 +1,32 @@
 package convert
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"math"
+	"path/filepath"
 	"strings"
+	"sync"
 	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/middleware"
+	"tiktok-whisper/internal/app/audio/ffmpeg"
+	"tiktok-whisper/internal/app/audio/vad"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/cleanup"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter"
+	"tiktok-whisper/internal/app/diarization"
+	"tiktok-whisper/internal/app/exitcode"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/output"
+	"tiktok-whisper/internal/app/vocab"
+	"tiktok-whisper/internal/downloader"
+	"time"
 
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
@@ -16,8 +38,23 @@ var video bool
 var audio bool
 var convertCount int
 var parallel int
+var retranscribe bool
+var stream bool
+var diarize bool
+var resume string
+var chunkDuration time.Duration
+var vadFilter bool
+var draft bool
+var detectChanges bool
+var changeThreshold float64
+var verbatim bool
+var translate bool
+var temperature float32
+var wordThreshold float32
+var maxSegmentLength int
 
 var inputFile string
+var urls string
 
 func init() {
 	Cmd.Flags().StringVarP(&userNickname, "userNickname", "u", "",
@@ -34,6 +71,9 @@ func init() {
 	Cmd.Flags().StringVarP(&inputFile, "input", "i", "",
 		"Specifies the audio file to convert, example: . /test/data/test.mp3")
 
+	Cmd.Flags().StringVar(&urls, "url", "",
+		"Download the audio of these video pages with yt-dlp and convert it, separated by commas, example: https://www.youtube.com/watch?v=...")
+
 	Cmd.Flags().StringVarP(&fileExtension, "type", "t", "",
 		"When converting the specified directory, you can use this option to filter the files with the specified extension, example: mp3")
 
@@ -42,6 +82,44 @@ func init() {
 
 	Cmd.Flags().BoolVarP(&audio, "audio", "a", false,
 		"Convert audio to text")
+
+	Cmd.Flags().BoolVar(&retranscribe, "retranscribe", false,
+		"Also convert videos that were already transcribed, the new result is stored as a revision, see v2t revisions")
+
+	Cmd.Flags().BoolVar(&stream, "stream", false,
+		"Print the transcript segments while a file is being transcribed")
+
+	Cmd.Flags().BoolVar(&diarize, "diarize", false,
+		"Label the transcript with its speakers using the diarization service in config.yaml")
+
+	Cmd.Flags().StringVar(&resume, "resume", "",
+		"Resume the batch job with this id, converting the files it didn't finish")
+
+	Cmd.Flags().DurationVar(&chunkDuration, "chunk-duration", 0,
+		"Transcribe long audio in chunks of this length, example: 5m. Overrides chunking.seconds of config.yaml")
+
+	Cmd.Flags().BoolVar(&vadFilter, "vad", false,
+		"Cut silences of 2 seconds and more before transcribing, the cut seconds are stored as trimmed_seconds")
+
+	Cmd.Flags().BoolVar(&detectChanges, "detect-changes", false,
+		"Convert processed videos again when their file was replaced, the new transcript is only stored when it changed materially")
+	Cmd.Flags().Float64Var(&changeThreshold, "change-threshold", converter.DefaultChangeThreshold,
+		"Word error rate against the previous transcript from which a replaced video counts as changed")
+
+	Cmd.Flags().BoolVar(&draft, "draft", false,
+		"Transcribe a quick draft with refine.draft_model of config.yaml, v2t refine replaces it with the high-quality result later")
+
+	Cmd.Flags().BoolVar(&verbatim, "verbatim", false,
+		"Keep fillers, false starts and stutters and drop nothing as silence, for legal transcripts. Overrides verbatim.users of config.yaml")
+
+	Cmd.Flags().BoolVar(&translate, "translate", false,
+		"Transcribe into English whatever language is spoken")
+	Cmd.Flags().Float32Var(&temperature, "temperature", 0,
+		"Sampling temperature of the decoder from 0 to 1, higher values are more random (default the provider's)")
+	Cmd.Flags().Float32Var(&wordThreshold, "word-threshold", 0,
+		"Probability below which whisper.cpp drops a word timestamp (default the provider's)")
+	Cmd.Flags().IntVar(&maxSegmentLength, "max-segment-length", 0,
+		"Split the segments of whisper.cpp longer than this many characters, e.g. for subtitles")
 }
 
 // Cmd represents the convert command
@@ -53,41 +131,43 @@ var Cmd = &cobra.Command{
 - Iterate through the mp4 files in the specified directory
 - Convert to mp3 or wav and convert to text
 - Support openai whisper or native whisper.cpp as conversion engine`,
-	Run: func(cmd *cobra.Command, args []string) {
-		if !video && !audio {
-			cmd.PrintErrf("Please specify the conversion type, -v or -a\n")
-			cmd.Help()
-			return
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if resume != "" {
+			return resumeJob()
 		}
 
-		if video && audio {
-			cmd.PrintErrf("Please specify the conversion type, -v or -a\n")
-			cmd.Help()
-			return
+		if urls != "" {
+			return convertURLs()
 		}
 
-		if directory == "" && inputFile == "" {
-			cmd.PrintErrf("Please specify the directory or file to convert\n")
-			cmd.Help()
-			return
+		if video == audio {
+			return usageError("Please specify the conversion type, -v or -a")
 		}
 
-		if directory != "" && inputFile != "" {
-			cmd.PrintErrf("Please specify the directory or file to convert\n")
-			cmd.Help()
-			return
+		if (directory == "") == (inputFile == "") {
+			return usageError("Please specify the directory or file to convert")
 		}
 
-		converter := app.InitializeConverter()
-		defer converter.Close()
+		if video && directory != "" && userNickname == "" {
+			return usageError("UserNickName must be set when converting video in directory")
+		}
 
+		// the audio of every video is extracted with ffmpeg, audio the provider accepts is sent as it is
 		if video {
-			if directory != "" && userNickname == "" {
-				cmd.PrintErrf("UserNickName must be set when converting video in directory\n")
-				cmd.Help()
-				return
+			if err := capability.Require(capability.FFmpeg); err != nil {
+				return err
 			}
+		} else {
+			capability.Warn(capability.FFmpeg, "only the audio the provider accepts as it is is transcribed")
+		}
+
+		converter, err := newConverter()
+		if err != nil {
+			return err
+		}
+		defer converter.Close()
 
+		if video {
 			if fileExtension == "" {
 				fileExtension = "mp4"
 			}
@@ -100,53 +180,234 @@ var Cmd = &cobra.Command{
 					convertCount,
 					parallel,
 				)
-				if err != nil {
-					cmd.PrintErrf("ConvertAudioDir error: %v\n", err)
-					return
-				}
-			} else if inputFile != "" {
-				if userNickname == "" {
-					userNickname = "default"
-				}
-
-				// set convert count to int max
-				err := converter.ConvertVideos(strings.Split(inputFile, ","), userNickname, math.MaxInt, parallel)
-				if err != nil {
-					cmd.PrintErrf("ConvertVideos error: %v\n", err)
-					return
-				}
+				return finish(converter, "ConvertAudioDir error: %v", err)
+			}
+
+			if userNickname == "" {
+				userNickname = "default"
 			}
 
-			return
+			// set convert count to int max
+			err := converter.ConvertVideos(strings.Split(inputFile, ","), userNickname, math.MaxInt, parallel)
+			return finish(converter, "ConvertVideos error: %v", err)
+		}
+
+		if fileExtension == "" {
+			fileExtension = "mp3"
 		}
 
+		if directory != "" {
+			err := converter.ConvertAudioDir(
+				directory,
+				fileExtension,
+				outputDirectory,
+				convertCount,
+				parallel,
+			)
+			return finish(converter, "ConvertAudioDir error: %v", err)
+		}
+
+		err = converter.ConvertAudios(strings.Split(inputFile, ","), outputDirectory, parallel)
+		return finish(converter, "ConvertAudios error: %v", err)
+	},
+}
+
+// usageError is an invalid combination of flags, the exit status tells it apart from failed conversions.
+func usageError(message string) error {
+	return exitcode.WithCode(exitcode.Usage, errors.New(i18n.T(message)))
+}
+
+// newConverter sets up the converter as the flags ask for, it returns an error when the flags or
+// the configuration are invalid.
+func newConverter() (*converter.Converter, error) {
+	var diarizer diarization.Diarizer
+	if diarize {
+		var err error
+		if diarizer, err = diarization.New(config.Get().Diarization); err != nil {
+			return nil, errors.New(i18n.T("Invalid diarization in config.yaml: %v", err))
+		}
+	}
+
+	mws, err := middleware.FromConfig(middlewareConfigs())
+	if err != nil {
+		return nil, errors.New(i18n.T("Invalid middlewares in config.yaml: %v", err))
+	}
+	if vadFilter {
+		// outermost, so the configured middlewares like cost see the trimmed audio
+		mws = append([]middleware.Middleware{middleware.VAD(vad.DefaultOptions(), cleanup.Default())}, mws...)
+	}
+	if packs := vocab.Installed(); len(packs) > 0 {
+		// outermost, so the cache keeps the raw text and packs installed later correct it
+		mws = append([]middleware.Middleware{middleware.Vocabulary(packs)}, mws...)
+	}
+
+	if chunkDuration > 0 {
+		config.Get().Chunking.Seconds = int(chunkDuration.Seconds())
+	}
+
+	// the providers read the setting when the converter is initialized
+	config.Get().Verbatim.Default = verbatim || config.Get().Verbatim.For(userNickname)
+	if vadFilter && config.Get().Verbatim.Default {
+		return nil, usageError("--vad cuts quiet passages, it can't be used with verbatim conversions")
+	}
+
+	// audio conversions skip files by their text file, replacements are detected by the stored transcriptions
+	if detectChanges && audio {
+		return nil, usageError("--detect-changes only works with video conversions")
+	}
+
+	if draft {
+		// audio conversions write text files, only transcriptions stored in the database can be refined
 		if audio {
-			if fileExtension == "" {
-				fileExtension = "mp3"
-			}
+			return nil, usageError("--draft only works with video and URL conversions")
+		}
+		if config.Get().Refine.DraftModel == "" {
+			return nil, errors.New(i18n.T("Set refine.draft_model in config.yaml to use --draft"))
+		}
+	}
 
-			if directory != "" {
-				err := converter.ConvertAudioDir(
-					directory,
-					fileExtension,
-					outputDirectory,
-					convertCount,
-					parallel,
-				)
-				if err != nil {
-					cmd.PrintErrf("ConvertAudioDir error: %v\n", err)
-					return
-				}
-			} else if inputFile != "" {
-				err := converter.ConvertAudios(strings.Split(inputFile, ","), outputDirectory, parallel)
-				if err != nil {
-					cmd.PrintErrf("ConvertAudios error: %v\n", err)
-					return
-				}
+	opts := api.Options{Translate: translate, Temperature: temperature, WordThreshold: wordThreshold, MaxSegmentLength: maxSegmentLength}
+	if temperature < 0 || temperature > 1 {
+		return nil, usageError("--temperature must be between 0 and 1")
+	}
+	if stream && opts != (api.Options{}) {
+		return nil, usageError("--stream can't be used with --translate, --temperature, --word-threshold or --max-segment-length")
+	}
+
+	var c *converter.Converter
+	if draft {
+		c = app.InitializeDraftConverter(userNickname)
+		c.SetDraft(true)
+	} else {
+		c = app.InitializeConverter(userNickname)
+	}
+	c.SweepTempFiles()
+	c.SetRetranscribe(retranscribe)
+	c.SetDetectChanges(detectChanges, changeThreshold)
+	c.SetOptions(opts)
+	c.Use(mws...)
+	c.TrackCosts(config.Get().Cost)
+	if stream {
+		c.SetPartialHandler(printPartial)
+	}
+	if !output.Quiet() {
+		c.SetProgressHandler(newProgressPrinter().print)
+	}
+	if diarizer != nil {
+		c.SetDiarizer(diarizer)
+	}
+	return c, nil
+}
+
+// resumeJob continues an interrupted batch job, the files, user and output directory come from the job.
+func resumeJob() error {
+	c, err := newConverter()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return finish(c, "Resume error: %v", c.Resume(resume, parallel))
+}
+
+// convertURLs downloads and converts the video pages of --url, the user defaults like for --input.
+func convertURLs() error {
+	c, err := newConverter()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if userNickname == "" {
+		userNickname = "default"
+	}
+	err = c.ConvertURLs(strings.Split(urls, ","), userNickname, downloader.YtDlp{}, parallel)
+	return finish(c, "ConvertURLs error: %v", err)
+}
+
+// finish prints the summary of the run and returns its error: err of the run described by message,
+// otherwise the failure of the files. The error keeps the exit status of its cause.
+func finish(c *converter.Converter, message string, err error) error {
+	summary := c.Summary()
+	perr := output.Print(summary, func(w io.Writer) error {
+		if len(summary.Files) == 0 {
+			return nil
+		}
+		fmt.Fprint(w, i18n.T("Converted %d, failed %d, skipped %d\n", summary.Converted, summary.Failed, summary.Skipped))
+		for _, f := range summary.Files {
+			if f.Status == converter.FileFailed {
+				fmt.Fprintf(w, "  %s: %s\n", filepath.Base(f.Path), f.Error)
 			}
-			return
 		}
+		return nil
+	})
 
-		cmd.Help()
-	},
+	switch {
+	case err != nil:
+		return exitcode.WithCode(exitcode.Code(err), errors.New(i18n.T(message, err)))
+	case summary.Failed == 0:
+		return perr
+	case summary.Converted == 0:
+		// every file failed, likely for the same reason
+		return exitcode.WithCode(exitcode.Code(summary.Err()), errors.New(i18n.T("%d files failed: %v", summary.Failed, summary.Err())))
+	default:
+		return exitcode.WithCode(exitcode.Partial, errors.New(i18n.T("%d of %d files failed", summary.Failed, len(summary.Files))))
+	}
+}
+
+// printPartial prints a streamed segment prefixed with its file, as files may be converted in parallel.
+func printPartial(audioFilePath string, s model.Segment) {
+	if !s.Timed() {
+		fmt.Fprintf(output.Text(), "%s: %s\n", filepath.Base(audioFilePath), s.Text)
+		return
+	}
+	fmt.Fprintf(output.Text(), "%s: [%s --> %s] %s\n", filepath.Base(audioFilePath), formatSeconds(s.Start), formatSeconds(s.End), s.Text)
+}
+
+// progressPrinter prints the progress of extracting audio every 10 percent, prefixed with the file
+// as files may be converted in parallel.
+type progressPrinter struct {
+	mu sync.Mutex
+	// printed is the last tenth printed of every file.
+	printed map[string]int
+}
+
+func newProgressPrinter() *progressPrinter {
+	return &progressPrinter{printed: map[string]int{}}
+}
+
+func (pp *progressPrinter) print(filePath string, p ffmpeg.Progress) {
+	// the percent is unknown without the duration of the file
+	if p.Total == 0 {
+		return
+	}
+	tenth := int(p.Percent / 10)
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if last, ok := pp.printed[filePath]; ok && tenth <= last {
+		return
+	}
+	pp.printed[filePath] = tenth
+	if p.Done {
+		delete(pp.printed, filePath)
+	}
+	fmt.Fprint(output.Text(), i18n.T("%s: extracting audio %d%%\n", filepath.Base(filePath), tenth*10))
+}
+
+// formatSeconds formats like whisper.cpp, e.g. 00:01:11.020.
+func formatSeconds(seconds float64) string {
+	ms := int(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// middlewareConfigs returns the configured middlewares, a re-transcription skips the cache
+// as it asks for a fresh result.
+func middlewareConfigs() []config.MiddlewareConfig {
+	cfgs := config.Get().Middlewares
+	if !retranscribe {
+		return cfgs
+	}
+	return lo.Filter(cfgs, func(c config.MiddlewareConfig, i int) bool {
+		return c.Name != "cache"
+	})
 }
@@ -0,0 +1,92 @@
+package corpus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app/corpus"
+	"tiktok-whisper/internal/app/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	language string
+	noise    string
+)
+
+func init() {
+	Cmd.PersistentFlags().StringVarP(&language, "language", "l", "", "Only samples in this language, e.g. en")
+	Cmd.PersistentFlags().StringVar(&noise, "noise", "", "Only samples recorded in these conditions, e.g. clean or broadcast")
+
+	Cmd.AddCommand(listCmd, fetchCmd)
+}
+
+// Cmd represents the corpus command
+var Cmd = &cobra.Command{
+	Use:   "corpus",
+	Short: "Download and verify the audio samples used by integration tests",
+	Long: `Download and verify the audio samples used by integration tests
+
+- Samples are public-domain recordings in several languages and recording conditions
+- They are stored in test/data with their checksums, sources and licenses in corpus.lock.json
+- Tests needing a sample that wasn't fetched are skipped`,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the samples and whether they were fetched",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := corpus.Default()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("NAME\tLANGUAGE\tNOISE\tLICENSE\tSTATUS"))
+		for _, s := range m.Samples(language, noise) {
+			status := i18n.T("ok")
+			if _, err = m.Verify(s.Name); errors.Is(err, corpus.ErrNotFetched) {
+				status = i18n.T("not fetched")
+			} else if err != nil {
+				status = err.Error()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Language, s.Noise, s.License, status)
+		}
+		return w.Flush()
+	},
+}
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [sample...]",
+	Short: "Download the samples, all matching --language and --noise when none are named",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := corpus.Default()
+		if err != nil {
+			return err
+		}
+
+		names := args
+		if len(names) == 0 {
+			for _, s := range m.Samples(language, noise) {
+				names = append(names, s.Name)
+			}
+		}
+
+		var failed int
+		for _, name := range names {
+			record, err := m.Fetch(name)
+			if err != nil {
+				cmd.PrintErr(i18n.T("Error fetching %s: %v\n", name, err))
+				failed++
+				continue
+			}
+			fmt.Print(i18n.T("%s: %s (%s)\n", name, record.File, record.License))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d samples failed", failed, len(names))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,124 @@
+package cost
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/cost"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	since string
+	by    string
+	user  string
+)
+
+func init() {
+	reportCmd.Flags().StringVar(&since, "since", "", "Report the costs recorded since this day, YYYY-MM-DD (default is the start of the month)")
+	reportCmd.Flags().StringVar(&by, "by", string(cost.ByUserAndProvider), "Break the costs down by user, provider or user,provider")
+	reportCmd.Flags().StringVarP(&user, "user", "u", "", "The user whose database holds the costs (default database when empty)")
+
+	Cmd.AddCommand(reportCmd)
+}
+
+// Cmd represents the cost command
+var Cmd = &cobra.Command{
+	Use:   "cost",
+	Short: "Show the estimated costs of the transcriptions",
+	Long: `Show the estimated costs of the transcriptions
+
+- Every transcription of v2t convert is priced and recorded in the database
+- The price comes from the cost middleware or from the per-minute rates under cost.rates in config.yaml
+- With cost.monthly_budget set, conversions stop once the costs of the month reach it`,
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report the costs per user and provider",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from := cost.MonthStart(time.Now())
+		if since != "" {
+			var err error
+			if from, err = time.Parse("2006-01-02", since); err != nil {
+				return errors.New(i18n.T("invalid --since %q, expected YYYY-MM-DD", since))
+			}
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+		dao, ok := db.(repository.CostDAO)
+		if !ok {
+			return errors.New(i18n.T("the configured database does not keep costs"))
+		}
+
+		entries, err := dao.GetCosts(from)
+		if err != nil {
+			return err
+		}
+		lines, err := cost.Summarize(entries, cost.GroupBy(by))
+		if err != nil {
+			return err
+		}
+
+		report := costReport{Since: from.Format("2006-01-02"), Lines: lines}
+		for _, l := range lines {
+			report.Total.Files += l.Files
+			report.Total.AudioSeconds += l.AudioSeconds
+			report.Total.Cost += l.Cost
+		}
+		if cfg := config.Get().Cost; cfg.MonthlyBudget > 0 {
+			spent, err := cost.NewTracker(db, cfg).MonthToDate()
+			if err != nil {
+				return err
+			}
+			report.Budget = &budget{Monthly: cfg.MonthlyBudget, Spent: spent, Exhausted: spent >= cfg.MonthlyBudget}
+		}
+
+		return output.Print(report, func(out io.Writer) error {
+			fmt.Fprint(out, i18n.T("Costs since %s\n\n", report.Since))
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("USER\tPROVIDER\tFILES\tMINUTES\tCOST"))
+			for _, l := range lines {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.4f\n", l.User, l.Provider, l.Files, l.AudioSeconds/60, l.Cost)
+			}
+			fmt.Fprintf(w, "%s\t\t%d\t%.1f\t%.4f\n", i18n.T("TOTAL"), report.Total.Files, report.Total.AudioSeconds/60, report.Total.Cost)
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			if report.Budget != nil {
+				fmt.Fprint(out, i18n.T("\nMonthly budget: %.2f spent of %.2f\n", report.Budget.Spent, report.Budget.Monthly))
+				if report.Budget.Exhausted {
+					fmt.Fprint(out, i18n.T("The budget is exhausted, conversions are stopped until next month\n"))
+				}
+			}
+			return nil
+		})
+	},
+}
+
+// costReport is the report printed by --json.
+type costReport struct {
+	// Since is the first day of the report, YYYY-MM-DD.
+	Since string      `json:"since"`
+	Lines []cost.Line `json:"lines"`
+	Total cost.Line   `json:"total"`
+	// Budget is nil without cost.monthly_budget.
+	Budget *budget `json:"budget,omitempty"`
+}
+
+// budget shows how much of the monthly budget is spent.
+type budget struct {
+	Monthly   float64 `json:"monthly"`
+	Spent     float64 `json:"spent"`
+	Exhausted bool    `json:"exhausted"`
+}
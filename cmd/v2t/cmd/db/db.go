@@ -0,0 +1,274 @@
+package db
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/repository/dump"
+	"tiktok-whisper/internal/app/repository/migrations"
+	"tiktok-whisper/internal/app/repository/router"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user   string
+	driver string
+	dsn    string
+	target int
+	yes    bool
+	format string
+	output string
+	remap  bool
+	report string
+)
+
+func init() {
+	Cmd.PersistentFlags().StringVarP(&user, "user", "u", "", "The user whose database to work on (default database when empty)")
+	Cmd.PersistentFlags().StringVar(&driver, "driver", "sqlite", "Driver of the database given by --dsn: sqlite or postgres")
+	Cmd.PersistentFlags().StringVar(&dsn, "dsn", "", "Work on this database instead of the configured one, a file path for sqlite or a connection string for postgres")
+
+	migrateCmd.Flags().IntVar(&target, "to", 0, "Schema version to migrate to, lower than the current one reverts migrations (default is the latest)")
+	migrateCmd.Flags().BoolVar(&yes, "yes", false, "Revert migrations, which drops what they added")
+
+	exportCmd.Flags().StringVar(&format, "format", "jsonl", "Format of the dump, only jsonl is supported")
+	exportCmd.Flags().StringVarP(&output, "output", "o", "", "File to write the dump to (default stdout)")
+
+	importCmd.Flags().BoolVar(&remap, "remap", false, "Let the database assign new ids, for importing into a database that has data")
+	importCmd.Flags().StringVar(&report, "report", "", "CSV file to write the old and new id of every imported transcription to")
+
+	Cmd.AddCommand(exportCmd, importCmd, migrateCmd, versionCmd)
+}
+
+// Cmd represents the db command
+var Cmd = &cobra.Command{
+	Use:   "db",
+	Short: "Show and change the schema version of a database",
+	Long: `Show and change the schema version of a database
+
+- Pending migrations are applied whenever v2t opens a SQLite or PostgreSQL database
+- version lists the migrations with the time they were applied
+- migrate --to reverts migrations, for going back to an older v2t
+- export and import move all data between databases, also from SQLite to PostgreSQL and back`,
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "List the migrations and which of them are applied",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, closeDB, err := openMigrator()
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		applied, err := migrator.Applied()
+		if err != nil {
+			return err
+		}
+		appliedAt := make(map[int]string, len(applied))
+		for _, a := range applied {
+			appliedAt[a.Version] = a.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("VERSION\tNAME\tAPPLIED"))
+		for _, m := range migrator.Migrations() {
+			at, ok := appliedAt[m.Version]
+			if !ok {
+				at = i18n.T("pending")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, at)
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+
+		version, err := migrator.Version()
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("schema version %d, the latest is %d\n", version, migrator.Latest()))
+		return nil
+	},
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the schema to the latest or a given version",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, closeDB, err := openMigrator()
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		if !cmd.Flags().Changed("to") {
+			target = migrator.Latest()
+		}
+		current, err := migrator.Version()
+		if err != nil {
+			return err
+		}
+		if target < current && !yes {
+			for v := current; v > target; v-- {
+				fmt.Print(i18n.T("would revert %s\n", migrator.Migrations()[v-1]))
+			}
+			return errors.New(i18n.T("reverting migrations drops what they added, pass --yes to revert them"))
+		}
+
+		steps, err := migrator.Migrate(target)
+		for _, step := range steps {
+			fmt.Println(step)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("schema version %d, the latest is %d\n", target, migrator.Latest()))
+		return nil
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Dump all data of a database",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if format != "jsonl" {
+			return errors.New(i18n.T("unsupported dump format %s, only jsonl is supported", format))
+		}
+		migrator, closeDB, err := openMigrator()
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		var w io.Writer = os.Stdout
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		counts, err := dump.Export(migrator, w)
+		if err != nil {
+			return err
+		}
+
+		// The summary goes to stderr, stdout may be the dump.
+		tw := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, i18n.T("TABLE\tROWS"))
+		for _, table := range dump.Tables() {
+			fmt.Fprintf(tw, "%s\t%d\n", table, counts[table])
+		}
+		return tw.Flush()
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a dump written by export",
+	Long: `Import a dump written by export
+
+- The dump is imported in one transaction, nothing is imported when a row fails
+- The ids of the dump are kept, which needs the tables of the database to be empty
+- --remap imports into a database that has data, the transcriptions get new ids and --report lists them`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if report != "" && !remap {
+			return errors.New(i18n.T("--report lists remapped ids, pass --remap too"))
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		migrator, closeDB, err := openMigrator()
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		result, err := dump.Import(migrator, f, dump.ImportOptions{Remap: remap})
+		if errors.Is(err, dump.ErrNotEmpty) {
+			return errors.New(i18n.T("%v, pass --remap to import into a database that has data", err))
+		}
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("TABLE\tIMPORTED\tSKIPPED"))
+		for _, table := range dump.Tables() {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", table, result.Imported[table], result.Skipped[table])
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+		if report != "" {
+			if err = writeReport(report, result.TranscriptionIDs); err != nil {
+				return err
+			}
+			fmt.Print(i18n.T("wrote the ids of %d transcriptions to %s\n", len(result.TranscriptionIDs), report))
+		}
+		return nil
+	},
+}
+
+// writeReport writes the old and new id of every transcription to a CSV file, ordered by old id.
+func writeReport(path string, ids map[int64]int64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	old := make([]int64, 0, len(ids))
+	for id := range ids {
+		old = append(old, id)
+	}
+	sort.Slice(old, func(i, j int) bool { return old[i] < old[j] })
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"old_id", "new_id"})
+	for _, id := range old {
+		w.Write([]string{strconv.FormatInt(id, 10), strconv.FormatInt(ids[id], 10)})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func openMigrator() (*migrations.Migrator, func() error, error) {
+	db, err := openDatabase()
+	if err != nil {
+		return nil, nil, err
+	}
+	sm, ok := db.(repository.SchemaMigrator)
+	if !ok {
+		db.Close()
+		return nil, nil, errors.New(i18n.T("the database has no versioned schema"))
+	}
+	return sm.Migrator(), db.Close, nil
+}
+
+// openDatabase opens the database given by --dsn, or the one of the user.
+func openDatabase() (repository.TranscriptionDAO, error) {
+	if dsn == "" {
+		return app.InitializeTranscriptionDAOForUser(user), nil
+	}
+	return router.Open(config.DatabaseInstance{Driver: driver, DSN: dsn})
+}
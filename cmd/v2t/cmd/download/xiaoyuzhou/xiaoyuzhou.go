@@ -5,6 +5,7 @@ import (
 	"github.com/spf13/cobra"
 	"log"
 	"strings"
+	"tiktok-whisper/internal/app/i18n"
 	"tiktok-whisper/internal/app/util/files"
 	"tiktok-whisper/internal/downloader"
 )
@@ -26,7 +27,7 @@ var Cmd = &cobra.Command{
 	Long:  `Download podcasts from Small Universe, support downloading all shows from the home page and single downloads`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if podcast == "" && episode == "" {
-			return errors.New("please input a podcast or an episode")
+			return errors.New(i18n.T("please input a podcast or an episode"))
 		}
 
 		dir, err := files.GetAbsolutePath(downloadDir)
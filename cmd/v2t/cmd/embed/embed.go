@@ -0,0 +1,203 @@
+package embed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/embedding"
+	"tiktok-whisper/internal/app/embedding/provider"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user       string
+	limit      int
+	interval   time.Duration
+	providerID string
+	modelName  string
+	dimensions int
+	keep       bool
+)
+
+func init() {
+	for _, c := range []*cobra.Command{runCmd, daemonCmd, reindexCmd, statusCmd} {
+		c.Flags().StringVarP(&user, "user", "u", "", "The user whose database holds the transcriptions (default database when empty)")
+	}
+	runCmd.Flags().IntVarP(&limit, "limit", "n", 0, "Embed at most this many transcriptions, all of them when 0")
+	daemonCmd.Flags().DurationVar(&interval, "interval", 0, "Time between two backfills (default embedding.interval in config.yaml, else 10m)")
+	reindexCmd.Flags().StringVar(&providerID, "provider", "", "Provider of the new model: openai, ollama, http (default embedding.provider in config.yaml)")
+	reindexCmd.Flags().StringVar(&modelName, "model", "", "The new embedding model, e.g. text-embedding-3-large")
+	reindexCmd.Flags().IntVar(&dimensions, "dimensions", 0, "Dimensions of the vectors of the new model, checked against every vector (default any)")
+	reindexCmd.Flags().BoolVar(&keep, "keep", false, "Keep the vectors of the previous model instead of deleting them once the index switches")
+	reindexCmd.MarkFlagRequired("model")
+
+	Cmd.AddCommand(runCmd)
+	Cmd.AddCommand(daemonCmd)
+	Cmd.AddCommand(reindexCmd)
+	Cmd.AddCommand(statusCmd)
+}
+
+// Cmd represents the embed command
+var Cmd = &cobra.Command{
+	Use:   "embed",
+	Short: "Embed the transcriptions for the vector index",
+	Long: `Embed the transcriptions for the vector index
+
+- Uses the model of embedding in config.yaml, OpenAI unless set, e.g. a local Ollama model
+- Transcriptions without an embedding of the model, or revised since, are embedded in batches of embedding.batch_size
+- embedding.requests_per_minute limits the requests per provider
+- Every batch is saved as it is embedded, a stopped run resumes where it stopped
+- v2t embed daemon embeds the new transcriptions every embedding.interval until it is stopped
+- v2t embed reindex switches the index to another model once it embedded every transcription`,
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Embed the transcriptions missing an embedding once",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b, err := newBackfiller()
+		if err != nil {
+			return err
+		}
+		defer b.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		result, err := b.Run(ctx, limit)
+		if err = explain(err); err != nil && result == (embedding.Result{}) {
+			return err
+		}
+		fmt.Print(i18n.T("Embedded %d, failed %d, %d transcriptions remaining\n", result.Embedded, result.Failed, result.Remaining))
+		return err
+	},
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep embedding the new and revised transcriptions until stopped",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b, err := newBackfiller()
+		if err != nil {
+			return err
+		}
+		defer b.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if interval == 0 {
+			interval = config.Get().Embedding.Interval
+		}
+		return explain(b.Daemon(ctx, interval))
+	},
+}
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Embed every transcription with a new model, then switch the index to it",
+	Long: `Embed every transcription with a new model, then switch the index to it
+
+- The vectors of the new model are stored next to those of the current one, the index keeps the current model meanwhile
+- Stopped or with failed transcriptions, run it again to resume, the index switches once every transcription is embedded
+- The index switches in one transaction that also deletes the vectors of the previous model, unless --keep is set
+- Set the new model as embedding in config.yaml afterwards, v2t embed run and daemon only embed with the model of the index`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get().Embedding
+		configured := cfg.Provider
+		if configured == "" {
+			configured = "openai"
+		}
+		// the url and key of the configured provider are no use to another one
+		if providerID != "" && providerID != configured {
+			cfg = config.EmbeddingConfig{Provider: providerID, BatchSize: cfg.BatchSize, MaxChars: cfg.MaxChars,
+				RequestsPerMinute: cfg.RequestsPerMinute, Timeout: cfg.Timeout}
+		}
+		cfg.Model = modelName
+		cfg.Dimensions = dimensions
+
+		e, err := provider.FromConfig(cfg)
+		if err != nil {
+			return errors.New(i18n.T("Invalid embedding model: %v", err))
+		}
+		b := embedding.NewBackfiller(e, app.InitializeTranscriptionDAOForUser(user), cfg)
+		defer b.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		result, err := b.Reindex(ctx, keep)
+		if errors.Is(err, embedding.ErrNotSupported) {
+			return explain(err)
+		}
+		fmt.Print(i18n.T("Embedded %d, failed %d, %d transcriptions remaining\n", result.Embedded, result.Failed, result.Remaining))
+		if errors.Is(err, embedding.ErrIncomplete) {
+			return errors.New(i18n.T("the index keeps its model until every transcription is embedded with %s, run reindex again", b.Model()))
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("The vector index now uses %s, set it as embedding in config.yaml\n", b.Model()))
+		return nil
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show how many transcriptions the configured model embedded",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		e, err := provider.Default()
+		if err != nil {
+			return errors.New(i18n.T("Invalid embedding in config.yaml: %v", err))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+		embeddings, ok := db.(repository.EmbeddingDAO)
+		if !ok {
+			return errors.New(i18n.T("the configured database does not keep embeddings"))
+		}
+
+		index, err := embeddings.GetIndexModel()
+		if err != nil {
+			return err
+		}
+		progress, err := embeddings.GetEmbeddingProgress(e.Name())
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("%s embedded %d transcriptions, %d pending\n", progress.Model, progress.Embedded, progress.Pending))
+		if index != "" && index != e.Name() {
+			fmt.Print(i18n.T("The vector index uses %s, run v2t embed reindex to switch it to %s\n", index, e.Name()))
+		}
+		return nil
+	},
+}
+
+// explain translates the errors of the backfill the user can act on.
+func explain(err error) error {
+	switch {
+	case errors.Is(err, embedding.ErrNotSupported):
+		return errors.New(i18n.T("the configured database does not keep embeddings"))
+	case errors.Is(err, embedding.ErrOtherModel):
+		return errors.New(i18n.T("%v, run v2t embed reindex to switch models", err))
+	}
+	return err
+}
+
+func newBackfiller() (*embedding.Backfiller, error) {
+	e, err := provider.Default()
+	if err != nil {
+		return nil, errors.New(i18n.T("Invalid embedding in config.yaml: %v", err))
+	}
+	return embedding.NewBackfiller(e, app.InitializeTranscriptionDAOForUser(user), config.Get().Embedding), nil
+}
@@ -0,0 +1,146 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/analytics"
+	"tiktok-whisper/internal/app/audio/condense"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/moderation"
+	"tiktok-whisper/internal/app/util/files"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	condensedUser           string
+	condensedOutput         string
+	condensedAudio          string
+	condensedAggressiveness string
+)
+
+func init() {
+	condensedCmd.Flags().StringVarP(&condensedUser, "user", "u", "default", "Owner of the transcription")
+	condensedCmd.Flags().StringVarP(&condensedOutput, "output", "o", ".", "Directory the condensed audio and its transcript are written to")
+	condensedCmd.Flags().StringVar(&condensedAudio, "audio", "", "Audio of the transcription, data/mp3/<user>/<file> by default")
+	condensedCmd.Flags().StringVarP(&condensedAggressiveness, "aggressiveness", "a", "normal", "What is cut: gentle, normal or aggressive, or 1 to 3")
+
+	Cmd.AddCommand(condensedCmd)
+}
+
+var condensedCmd = &cobra.Command{
+	Use:   "condensed <id>",
+	Short: "Export a condensed audio of a transcription without its pauses and filler words",
+	Long: `Export a condensed audio of a transcription without its pauses and filler words
+
+- gentle cuts pauses of 1.5 seconds and more, normal of a second and filler words, aggressive every pause of half a second
+- Filler words are the built-in ones plus analytics.filler_words of config.yaml, they are cut where the provider reported word timings
+- <file>.condensed.mp3 is written with <file>.condensed.srt timed to it, or <file>.condensed.txt without timestamps`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return errors.New(i18n.T("invalid transcription id %q", args[0]))
+		}
+		level, err := condense.ParseLevel(condensedAggressiveness)
+		if err != nil {
+			return err
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(condensedUser)
+		defer db.Close()
+		t, err := db.GetByID(id)
+		if err != nil || t == nil {
+			return errors.New(i18n.T("transcription %d of %s not found", id, condensedUser))
+		}
+		blocker, err := moderation.ExportBlocker(config.Get().Moderation, db)
+		if err != nil {
+			return err
+		}
+		if blocker != nil {
+			blocked, err := moderation.Blocked(blocker, id)
+			if err != nil {
+				return err
+			}
+			if blocked {
+				return errors.New(i18n.T("transcription %d is flagged by moderation and can't be exported", id))
+			}
+		}
+		if err = export.LoadSegments(db, t); err != nil {
+			return err
+		}
+
+		input := condensedAudio
+		if input == "" {
+			input = filepath.Join(files.GetUserMp3Dir(t.User), filepath.Base(t.Mp3FileName))
+		}
+		if _, err = os.Stat(input); err != nil {
+			return errors.New(i18n.T("the audio of transcription %d is missing, pass it with --audio: %v", id, err))
+		}
+		if err = os.MkdirAll(condensedOutput, 0755); err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(filepath.Base(t.Mp3FileName), filepath.Ext(t.Mp3FileName))
+		if name == "" || name == "." {
+			name = fmt.Sprintf("transcription_%d", id)
+		}
+		name += ".condensed"
+		fillers := append(append([]string{}, analytics.DefaultFillerWords...), config.Get().Analytics.FillerWords...)
+		output := filepath.Join(condensedOutput, name+".mp3")
+		plan, err := condense.Condense(input, output, t.Segments, level.Options(fillers))
+		if err != nil {
+			return err
+		}
+
+		transcript, err := writeCondensedTranscript(*t, condense.Segments(t.Segments, plan), filepath.Join(condensedOutput, name))
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("condensed %s to %s, %.0f%% shorter: %s, %s\n", formatSeconds(plan.Duration), formatSeconds(plan.SpeechSeconds()),
+			plan.TrimmedSeconds()/plan.Duration*100, output, transcript))
+		return nil
+	},
+}
+
+// writeCondensedTranscript writes the transcript next to the condensed audio, as subtitles timed to
+// it when segments are, as text otherwise. It returns the path written.
+func writeCondensedTranscript(t model.Transcription, segments []model.Segment, base string) (string, error) {
+	format := "srt"
+	if len(segments) == 0 {
+		format = "txt"
+	}
+	w, err := export.GetWriter(format)
+	if err != nil {
+		return "", err
+	}
+	t.Segments = segments
+
+	path := base + "." + w.Extension()
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err = w.Write(f, t, export.DefaultOptions()); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// formatSeconds formats a duration as m:ss, or h:mm:ss from an hour.
+func formatSeconds(seconds float64) string {
+	s := int(seconds + 0.5)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -5,17 +5,25 @@ import (
 	"github.com/spf13/cobra"
 	"log"
 	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/config"
 	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/moderation"
+	"tiktok-whisper/internal/app/repository"
 	"tiktok-whisper/internal/app/repository/sqlite"
 	"tiktok-whisper/internal/app/util/files"
 )
 
 var userNickname string
 var outputFilePath string
+var format string
 
 func init() {
 	Cmd.Flags().StringVarP(&userNickname, "userNickname", "n", "", "set userNickname")
 	Cmd.Flags().StringVarP(&outputFilePath, "outputFilePath", "o", "", "set outputFilePath")
+	Cmd.Flags().StringVarP(&format, "format", "f", "xlsx", "Output format: xlsx, "+strings.Join(export.Formats(), ", "))
 
 	Cmd.MarkFlagRequired("userNickname")
 	Cmd.MarkFlagRequired("outputFilePath")
@@ -24,10 +32,11 @@ func init() {
 // Cmd represents the export command
 var Cmd = &cobra.Command{
 	Use:   "export",
-	Short: "Export the specified user's text to excel",
-	Long: `Export the specified user's text to excel
+	Short: "Export the specified user's text to excel or subtitles",
+	Long: `Export the specified user's text to excel or subtitles
 
-- Export all the user's text to excel, currently does not support a limited number`,
+- Export all the user's text to excel, currently does not support a limited number
+- Other formats write one file per transcription to the outputFilePath directory, srt, vtt and json use the stored timestamps`,
 	Run: func(cmd *cobra.Command, args []string) {
 		projectRoot, err := files.GetProjectRoot()
 		if err != nil {
@@ -41,8 +50,57 @@ var Cmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
+		blocker, err := moderation.ExportBlocker(config.Get().Moderation, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if format == "xlsx" {
+			if blocker != nil {
+				transcriptions = unflagged(blocker, transcriptions)
+			}
+			export.ToExcel(transcriptions, outputFilePath)
+			fmt.Print(i18n.T("export finished, exported file path: %v\n", outputFilePath))
+			return
+		}
 
-		export.ToExcel(transcriptions, outputFilePath)
-		fmt.Printf("export finished, exported file path: %v\n", outputFilePath)
+		opts := export.DefaultOptions()
+		cfg := config.Get().Verbatim
+		opts.Legal = export.LegalOptions{LinesPerPage: cfg.LinesPerPage, LineWidth: cfg.LineWidth, InaudibleProbability: cfg.InaudibleProbability}
+		result, err := export.ReExport(db, db, export.ReExportOptions{
+			User:         userNickname,
+			Format:       format,
+			OutputDir:    outputFilePath,
+			Force:        true,
+			Write:        opts,
+			BlockFlagged: blocker,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(i18n.T("export finished, %d files written to %s\n", result.Written, outputFilePath))
+		if result.Untimed > 0 {
+			fmt.Print(i18n.T("%d transcriptions have no timestamps and were skipped\n", result.Untimed))
+		}
+		if result.Blocked > 0 {
+			fmt.Print(i18n.T("%d transcriptions flagged by moderation were skipped\n", result.Blocked))
+		}
 	},
 }
+
+// unflagged drops the transcriptions moderation flagged.
+func unflagged(moderations repository.ModerationDAO, transcriptions []model.Transcription) []model.Transcription {
+	kept := make([]model.Transcription, 0, len(transcriptions))
+	for _, t := range transcriptions {
+		blocked, err := moderation.Blocked(moderations, t.ID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if blocked {
+			fmt.Print(i18n.T("transcription %d is flagged by moderation and was skipped\n", t.ID))
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept
+}
@@ -0,0 +1,69 @@
+package export
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/moderation"
+
+	"github.com/spf13/cobra"
+)
+
+var youtubeUser string
+
+func init() {
+	youtubeCmd.Flags().StringVarP(&youtubeUser, "user", "u", "default", "Owner of the transcription")
+
+	Cmd.AddCommand(youtubeCmd)
+}
+
+var youtubeCmd = &cobra.Command{
+	Use:   "youtube-desc <id>",
+	Short: "Print a YouTube description with chapters, summary and hashtags for a transcription",
+	Long: `Print a YouTube description with chapters, summary and hashtags for a transcription
+
+- The summary are the opening sentences of the transcription
+- Chapters are cut at the longest pauses, about one per three minutes and at least three, titled by their first sentence
+- Hashtags are the most frequent words of the transcription
+- Needs the stored timestamps, the same text is written by export --format youtube-desc`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return errors.New(i18n.T("invalid transcription id %q", args[0]))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(youtubeUser)
+		defer db.Close()
+		t, err := db.GetByID(id)
+		if err != nil || t == nil {
+			return errors.New(i18n.T("transcription %d of %s not found", id, youtubeUser))
+		}
+		blocker, err := moderation.ExportBlocker(config.Get().Moderation, db)
+		if err != nil {
+			return err
+		}
+		if blocker != nil {
+			blocked, err := moderation.Blocked(blocker, id)
+			if err != nil {
+				return err
+			}
+			if blocked {
+				return errors.New(i18n.T("transcription %d is flagged by moderation and can't be exported", id))
+			}
+		}
+		if err := export.LoadSegments(db, t); err != nil {
+			return err
+		}
+
+		w, err := export.GetWriter("youtube-desc")
+		if err != nil {
+			return err
+		}
+		return w.Write(os.Stdout, *t, export.DefaultOptions())
+	},
+}
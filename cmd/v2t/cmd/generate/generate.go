@@ -0,0 +1,182 @@
+package generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/exitcode"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/llm"
+	"tiktok-whisper/internal/app/output"
+	"tiktok-whisper/internal/app/prompts"
+
+	"github.com/samber/lo"
+	"github.com/sashabaranov/go-openai"
+	"github.com/spf13/cobra"
+)
+
+var (
+	transcriptionID int
+	user            string
+	estimate        bool
+)
+
+// features are the features generating text from a whole transcription.
+var features = []prompts.Feature{prompts.Summary, prompts.Title, prompts.ActionItems, prompts.Chapters}
+
+func init() {
+	Cmd.Flags().IntVarP(&transcriptionID, "id", "i", 0, "The id of the transcription")
+	Cmd.Flags().StringVarP(&user, "user", "u", "", "The user whose database holds the transcription (default database when empty)")
+	Cmd.Flags().BoolVar(&estimate, "estimate", false, "Print the tokens and the estimated cost of the requests without sending them")
+	Cmd.MarkFlagRequired("id")
+}
+
+// Cmd represents the generate command
+var Cmd = &cobra.Command{
+	Use:   "generate <summary|title|action_items|chapters>",
+	Short: "Generate a summary, title, action items or chapters of a transcription with a language model",
+	Long: `Generate a summary, title, action items or chapters of a transcription with a language model
+
+- The prompt is the template of the feature, see v2t prompts
+- Chapters use the timestamps of the transcription when it was stored with them
+- Uses the language model of llm in config.yaml, OpenAI unless set, e.g. a local Ollama model
+- A transcript over the token budget of the feature is condensed part by part first, see llm.budgets in config.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f := prompts.Feature(args[0])
+		if !lo.Contains(features, f) {
+			return exitcode.WithCode(exitcode.Usage, errors.New(i18n.T("unknown feature %q, use summary, title, action_items or chapters", args[0])))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+
+		t, err := db.GetByID(transcriptionID)
+		if err != nil {
+			return errors.New(i18n.T("get transcription %d failed: %v", transcriptionID, err))
+		}
+		if err = export.LoadSegments(db, t); err != nil {
+			return err
+		}
+
+		cfg := config.Get().LLM
+		budget := llm.Budget(cfg, string(f))
+		data := prompts.NewData(*t)
+		transcript := timedTranscript(data)
+		if estimate {
+			return printEstimate(t.ID, f, cfg, transcript, budget)
+		}
+
+		if err = capability.Require(capability.LLM); err != nil {
+			return err
+		}
+		model, err := llm.Default()
+		if err != nil {
+			return errors.New(i18n.T("Invalid llm in config.yaml: %v", err))
+		}
+		metered := llm.NewMetered(model)
+		ctx := context.Background()
+
+		if llm.EstimateTokens(transcript) > budget {
+			condensed, err := llm.Condense(ctx, metered, transcript, budget, func(part string) (string, error) {
+				return prompts.Render(prompts.Condense, prompts.Data{Transcript: part, User: data.User, Language: data.Language, File: data.File})
+			}, func(level int, parts int) {
+				fmt.Fprint(output.Text(), i18n.T("Transcript over the %s budget of %d tokens, condensing %d parts\n", f, budget, parts))
+			})
+			if err != nil {
+				return err
+			}
+			// the condensed lines keep the timestamps chapters need
+			data.Transcript, data.Segments = condensed, nil
+		}
+
+		prompt, err := prompts.Render(f, data)
+		if err != nil {
+			return err
+		}
+		text, err := metered.Complete(ctx, []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: prompt}})
+		if err != nil {
+			return err
+		}
+
+		usage := metered.Usage()
+		result := generated{ID: t.ID, Feature: f, Model: model.Name(), Text: text, Usage: usage, EstimatedCost: usage.Cost(cfg)}
+		return output.Print(result, func(w io.Writer) error {
+			if usage.Requests > 1 || result.EstimatedCost > 0 {
+				fmt.Fprint(output.Text(), i18n.T("%d requests, about %d tokens in and %d out, estimated cost %.4f\n",
+					usage.Requests, usage.InputTokens, usage.OutputTokens, result.EstimatedCost))
+			}
+			if usage.Cached > 0 {
+				fmt.Fprint(output.Text(), i18n.T("%d responses from the cache, see llm.cache in config.yaml\n", usage.Cached))
+			}
+			_, err := fmt.Fprintln(w, text)
+			return err
+		})
+	},
+}
+
+// timedTranscript is the transcript of d, one timestamped line per segment when it has segments so
+// condensing keeps the timestamps.
+func timedTranscript(d prompts.Data) string {
+	if len(d.Segments) == 0 {
+		return d.Transcript
+	}
+	var sb strings.Builder
+	for _, s := range d.Segments {
+		fmt.Fprintf(&sb, "%s %s\n", s.Start, s.Text)
+	}
+	return sb.String()
+}
+
+// printEstimate prints what generating f from transcript takes without sending a request: the tokens,
+// how many parts are condensed first and the cost of the input. Condensing may take more levels, and
+// the replies cost extra, so the requests and the cost are the least it takes.
+func printEstimate(id int, f prompts.Feature, cfg config.LLMConfig, transcript string, budget int) error {
+	tokens := llm.EstimateTokens(transcript)
+	result := estimated{ID: id, Feature: f, Tokens: tokens, Budget: budget, Requests: 1}
+	if tokens > budget {
+		result.Parts = len(llm.Split(transcript, budget))
+		result.Requests += result.Parts
+		// every part once, then the condensed transcript filling the budget at most
+		tokens += budget
+	}
+	result.EstimatedCost = llm.Usage{Requests: result.Requests, InputTokens: tokens}.Cost(cfg)
+
+	return output.Print(result, func(w io.Writer) error {
+		fmt.Fprint(w, i18n.T("Transcript of about %d tokens, budget of %s %d tokens\n", result.Tokens, f, budget))
+		if result.Parts > 0 {
+			fmt.Fprint(w, i18n.T("Condensed in %d parts first\n", result.Parts))
+		}
+		_, err := fmt.Fprint(w, i18n.T("At least %d requests, estimated input cost %.4f\n", result.Requests, result.EstimatedCost))
+		return err
+	})
+}
+
+// generated is the result printed by --json.
+type generated struct {
+	ID      int             `json:"id"`
+	Feature prompts.Feature `json:"feature"`
+	Model   string          `json:"model"`
+	Text    string          `json:"text"`
+	// Usage counts the requests condensing the transcript as well.
+	Usage         llm.Usage `json:"usage"`
+	EstimatedCost float64   `json:"estimated_cost"`
+}
+
+// estimated is the estimate printed by --estimate --json.
+type estimated struct {
+	ID      int             `json:"id"`
+	Feature prompts.Feature `json:"feature"`
+	Tokens  int             `json:"tokens"`
+	Budget  int             `json:"budget"`
+	// Parts are the parts condensed first, zero when the transcript fits the budget.
+	Parts         int     `json:"parts"`
+	Requests      int     `json:"requests"`
+	EstimatedCost float64 `json:"estimated_cost"`
+}
@@ -0,0 +1,63 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/schema"
+
+	"github.com/spf13/cobra"
+)
+
+var user string
+
+func init() {
+	Cmd.Flags().StringVarP(&user, "user", "u", "", "Store the transcriptions for this user instead of the user in the files")
+}
+
+// Cmd represents the import command
+var Cmd = &cobra.Command{
+	Use:   "import <file.json>...",
+	Short: "Load transcriptions exported with the json format back into the database",
+	Long: `Load transcriptions exported with the json format back into the database
+
+- Files of every schema version are accepted, invalid ones are reported and skipped
+- Transcriptions whose file is already stored are skipped
+- Each transcription goes to the database of its user in config.yaml`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var imported, skipped, invalid int
+		for _, path := range args {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			doc, err := schema.DecodeTranscription(data)
+			if err != nil {
+				cmd.PrintErr(i18n.T("Skipping %s: %v\n", path, err))
+				invalid++
+				continue
+			}
+			if user != "" {
+				doc.User = user
+			}
+
+			db := app.InitializeTranscriptionDAOForUser(doc.User)
+			stored, err := export.Import(db, doc, filepath.Dir(path))
+			if err != nil {
+				return fmt.Errorf("import %s failed: %w", path, err)
+			}
+			if stored {
+				imported++
+			} else {
+				skipped++
+			}
+		}
+
+		fmt.Print(i18n.T("%d imported, %d already stored, %d invalid\n", imported, skipped, invalid))
+		return nil
+	},
+}
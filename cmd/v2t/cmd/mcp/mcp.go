@@ -0,0 +1,36 @@
+package mcp
+
+import (
+	"log"
+	"os"
+	"tiktok-whisper/cmd/v2t/cmd/version"
+	"tiktok-whisper/internal/app"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	Cmd.AddCommand(serveCmd)
+}
+
+// Cmd represents the mcp command
+var Cmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Expose the transcript archive to LLM agents over the Model Context Protocol",
+	Long: `Expose the transcript archive to LLM agents over the Model Context Protocol
+
+- search finds the transcriptions of a user containing all words of a query
+- fetch-transcript returns a transcription with its segments and provider metadata
+- stats counts the transcribed files and their audio duration per user
+- Each user is read from the database it is routed to in config.yaml`,
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve MCP on stdin and stdout, for clients like Claude Desktop or IDE assistants",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// stdout carries the protocol, the logs must not end up in it
+		log.SetOutput(os.Stderr)
+		return app.InitializeMCPServer(version.Version()).Serve(os.Stdin, os.Stdout)
+	},
+}
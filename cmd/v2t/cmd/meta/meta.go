@@ -0,0 +1,175 @@
+package meta
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/meta"
+	"tiktok-whisper/internal/app/repository"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	filter   string
+	set      []string
+	dryRun   bool
+	undoFile string
+	user     string
+)
+
+func init() {
+	setCmd.Flags().StringVarP(&filter, "filter", "f", "", "Which transcriptions to edit, e.g. user=X,before=2023-01-01,collection=inbox")
+	setCmd.Flags().StringArrayVarP(&set, "set", "s", nil, "Metadata to set as key=value, key= removes the key, can be repeated")
+	setCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only list the changes, the database is not touched")
+	setCmd.Flags().StringVar(&undoFile, "undo-file", "", "Where to write the patch that undoes the edit (default is meta-undo-<time>.json)")
+	setCmd.MarkFlagRequired("set")
+
+	getCmd.Flags().StringVarP(&user, "user", "u", "", "The user whose database holds the transcription (default database when empty)")
+
+	Cmd.AddCommand(setCmd)
+	Cmd.AddCommand(undoCmd)
+	Cmd.AddCommand(getCmd)
+}
+
+// Cmd represents the meta command
+var Cmd = &cobra.Command{
+	Use:   "meta",
+	Short: "Edit the metadata of many transcriptions at once",
+	Long: `Edit the metadata of many transcriptions at once
+
+- Metadata are free-form keys such as language or collection
+- set edits every transcription its filter selects, --dry-run lists the changes first
+- Every edit writes a patch file, meta undo with that file restores the previous values`,
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Set metadata on the transcriptions a filter selects",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := meta.ParseFilter(filter)
+		if err != nil {
+			return errors.New(i18n.T("invalid --filter: %v", err))
+		}
+		values, err := meta.ParseAssignments(set)
+		if err != nil {
+			return errors.New(i18n.T("invalid --set: %v", err))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(f.User)
+		defer db.Close()
+		dao, ok := db.(repository.MetadataDAO)
+		if !ok {
+			return errors.New(i18n.T("the configured database does not keep metadata"))
+		}
+
+		patch, err := meta.Plan(db, dao, f, values)
+		if err != nil {
+			return err
+		}
+		if len(patch.Changes) == 0 {
+			fmt.Print(i18n.T("No transcription needs a change\n"))
+			return nil
+		}
+		if dryRun {
+			if err = printChanges(patch); err != nil {
+				return err
+			}
+			fmt.Print(i18n.T("%d transcriptions would be changed, run again without --dry-run to apply\n", len(patch.Changes)))
+			return nil
+		}
+
+		// The undo patch is written first, so an edit that stops halfway can still be undone
+		path := undoFile
+		if path == "" {
+			path = fmt.Sprintf("meta-undo-%s.json", patch.CreatedAt.Format("20060102-150405"))
+		}
+		if err = meta.WritePatch(path, patch.Inverse()); err != nil {
+			return errors.New(i18n.T("write undo file failed: %v", err))
+		}
+
+		n, err := meta.Apply(dao, patch)
+		fmt.Print(i18n.T("%d transcriptions changed, undo with: v2t meta undo %s\n", n, path))
+		return err
+	},
+}
+
+var undoCmd = &cobra.Command{
+	Use:   "undo <undo-file>",
+	Short: "Restore the metadata an edit changed",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		patch, err := meta.ReadPatch(args[0])
+		if err != nil {
+			return err
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(patch.User)
+		defer db.Close()
+		dao, ok := db.(repository.MetadataDAO)
+		if !ok {
+			return errors.New(i18n.T("the configured database does not keep metadata"))
+		}
+
+		n, err := meta.Apply(dao, patch)
+		fmt.Print(i18n.T("%d transcriptions restored\n", n))
+		return err
+	},
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <id>",
+	Short: "Print the metadata of a transcription",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return errors.New(i18n.T("invalid transcription id %q", args[0]))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+		dao, ok := db.(repository.MetadataDAO)
+		if !ok {
+			return errors.New(i18n.T("the configured database does not keep metadata"))
+		}
+
+		values, err := dao.GetMetadata(id)
+		if err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(values) {
+			fmt.Printf("%s=%s\n", key, values[key])
+		}
+		return nil
+	},
+}
+
+// printChanges lists the changes of a dry run, one transcription per line.
+func printChanges(patch meta.Patch) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, i18n.T("ID\tFILE\tCHANGES"))
+	for _, c := range patch.Changes {
+		var changes []string
+		for _, key := range sortedKeys(c.After) {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", key, c.Before[key], c.After[key]))
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", c.TranscriptionID, c.File, strings.Join(changes, ", "))
+	}
+	return w.Flush()
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+package moderate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/moderation"
+	"tiktok-whisper/internal/app/repository"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user    string
+	backend string
+	force   bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&user, "user", "u", "default", "Whose transcriptions to screen")
+	Cmd.Flags().StringVarP(&backend, "backend", "b", "", "Moderation backend: "+strings.Join(moderation.Backends, ", ")+" (default is moderation.backend in config.yaml, else openai)")
+	Cmd.Flags().BoolVar(&force, "force", false, "Screen transcriptions again that were screened since their last change")
+}
+
+// Cmd represents the moderate command
+var Cmd = &cobra.Command{
+	Use:   "moderate",
+	Short: "Screen the stored transcriptions of a user for harmful content",
+	Long: `Screen the stored transcriptions of a user for harmful content
+
+- Backends are openai, its key is read from OPENAI_API_KEY, and http for a local classifier
+- The latest result is stored per transcription, transcriptions screened since their last change are skipped
+- moderation.actions in config.yaml decide what happens to flagged transcriptions: flag, block_export and notify
+- The flagged transcriptions of the user are listed afterwards`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get().Moderation
+		if backend != "" {
+			cfg.Backend = backend
+		}
+		moderator, err := moderation.New(cfg)
+		if err != nil {
+			return err
+		}
+		policy, err := moderation.NewPolicy(cfg)
+		if err != nil {
+			return err
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+		dao, ok := db.(repository.ModerationDAO)
+		if !ok {
+			return errors.New(i18n.T("the configured database does not keep moderation results"))
+		}
+
+		var bus events.Bus
+		if policy.Has(moderation.ActionNotify) {
+			if bus, err = events.New(config.Get().Events); err != nil {
+				return err
+			}
+			defer bus.Close()
+		}
+
+		result, err := moderation.Transcriptions(db, dao, moderator, bus, moderation.Options{User: user, Force: force, Policy: policy})
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("%d screened, %d flagged, %d skipped, %d failed\n", result.Checked, result.Flagged, result.Skipped, result.Failed))
+		if err = printFlagged(db, dao); err != nil {
+			return err
+		}
+		if result.Failed > 0 {
+			return errors.New(i18n.T("%d transcriptions failed to screen", result.Failed))
+		}
+		return nil
+	},
+}
+
+// printFlagged lists the transcriptions of the user their latest result flags.
+func printFlagged(db repository.TranscriptionDAO, dao repository.ModerationDAO) error {
+	transcriptions, err := db.GetAllByUser(user)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	flagged := 0
+	for _, t := range transcriptions {
+		m, err := dao.GetModeration(t.ID)
+		if err != nil || !m.IsFlagged() {
+			continue
+		}
+		if flagged == 0 {
+			fmt.Fprintln(w, i18n.T("ID\tFILE\tCATEGORIES\tBACKEND\tCHECKED"))
+		}
+		flagged++
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", t.ID, t.Mp3FileName, strings.Join(m.Flagged, ", "), m.Backend,
+			m.CheckedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,129 @@
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/prompts"
+
+	"github.com/spf13/cobra"
+)
+
+var force bool
+
+func init() {
+	initCmd.Flags().BoolVar(&force, "force", false, "Overwrite the templates already in the prompts directory")
+
+	Cmd.AddCommand(listCmd, showCmd, initCmd, validateCmd)
+}
+
+// Cmd represents the prompts command
+var Cmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "Manage the prompt templates of the features using a language model",
+	Long: `Manage the prompt templates of the features using a language model
+
+- Every feature has a built-in template: summary, title, action_items, chapters, condense, qa, ask and translation
+- A file named after the feature in the prompts directory next to config.yaml replaces it, e.g. prompts/summary.tmpl
+- Templates are Go text/template files with variables like {{.Transcript}}, {{.User}}, {{.Language}}, {{.File}} and {{.Segments}}
+- v2t prompts init writes the built-in templates there to edit them, v2t prompts validate checks them`,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the features and the template each one uses",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set, err := prompts.Load(prompts.Dir())
+		if err != nil {
+			cmd.PrintErrln(i18n.T("Invalid templates use the built-in ones, see v2t prompts validate"))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("FEATURE\tTEMPLATE"))
+		for _, f := range prompts.Features {
+			source := set.Source(f)
+			if source == prompts.Builtin {
+				source = i18n.T(prompts.Builtin)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", f, source)
+		}
+		return w.Flush()
+	},
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show <feature>",
+	Short: "Print the template a feature uses",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f := prompts.Feature(args[0])
+		set, err := prompts.Load(prompts.Dir())
+		if err != nil {
+			cmd.PrintErrln(i18n.T("Invalid templates use the built-in ones, see v2t prompts validate"))
+		}
+
+		text, err := prompts.BuiltinText(f)
+		if err != nil {
+			return err
+		}
+		if source := set.Source(f); source != prompts.Builtin {
+			data, err := os.ReadFile(source)
+			if err != nil {
+				return err
+			}
+			text = string(data)
+		}
+		fmt.Print(text)
+		return nil
+	},
+}
+
+var initCmd = &cobra.Command{
+	Use:   "init [feature]...",
+	Short: "Write the built-in templates into the prompts directory to edit them, all when no feature is given",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		features := prompts.Features
+		if len(args) > 0 {
+			features = nil
+			for _, arg := range args {
+				features = append(features, prompts.Feature(arg))
+			}
+		}
+
+		dir := prompts.Dir()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for _, f := range features {
+			text, err := prompts.BuiltinText(f)
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(dir, string(f)+prompts.Extension)
+			if _, err := os.Stat(path); err == nil && !force {
+				fmt.Print(i18n.T("Kept %s, --force overwrites it\n", path))
+				continue
+			}
+			if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+				return err
+			}
+			fmt.Print(i18n.T("Wrote %s\n", path))
+		}
+		return nil
+	},
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check that the templates in the prompts directory parse and render",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := prompts.Validate(prompts.Dir()); err != nil {
+			return errors.New(i18n.T("invalid prompt templates:\n%v", err))
+		}
+		fmt.Print(i18n.T("The prompt templates in %s are valid\n", prompts.Dir()))
+		return nil
+	},
+}
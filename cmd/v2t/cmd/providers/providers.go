@@ -0,0 +1,241 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/api/benchmark"
+	"tiktok-whisper/internal/app/api/conformance"
+	"tiktok-whisper/internal/app/audio"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/corpus"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	sample    string
+	maxFileMB int64
+	timeout   time.Duration
+
+	benchSample string
+	benchFile   string
+	reference   string
+)
+
+func init() {
+	verifyCmd.Flags().StringVarP(&sample, "sample", "s", "",
+		"Short speech recording to transcribe (default is the jfk sample of v2t corpus)")
+	verifyCmd.Flags().Int64Var(&maxFileMB, "max-file-mb", 25,
+		"Largest file the provider accepts in MB, the large file check sends a bigger one, 0 skips it")
+	verifyCmd.Flags().DurationVar(&timeout, "timeout", conformance.DefaultTimeout,
+		"How long a single transcription may take")
+
+	benchmarkCmd.Flags().StringVarP(&benchSample, "sample", "s", "jfk",
+		"Corpus sample to transcribe, it must have a known transcript")
+	benchmarkCmd.Flags().StringVar(&benchFile, "file", "",
+		"Transcribe this recording instead of a corpus sample, --reference gives its transcript")
+	benchmarkCmd.Flags().StringVar(&reference, "reference", "",
+		"Text file with the correct transcript of --file")
+
+	Cmd.AddCommand(verifyCmd, benchmarkCmd)
+}
+
+// Cmd represents the providers command
+var Cmd = &cobra.Command{
+	Use:   "providers",
+	Short: "Check transcription providers",
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <name>",
+	Short: "Run the conformance checks against a provider",
+	Long: `Run the conformance checks against a provider
+
+- info: the metadata names the provider and its model
+- health: the provider's own health check passes
+- small_file, unicode_filename: short speech is transcribed, also under a unicode file name
+- large_file, error_mapping: oversized, corrupt and missing files fail with errors that aren't retryable
+- timeout: the sample is transcribed within --timeout`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		t, err := app.NewProvider(name)
+		if err != nil {
+			return err
+		}
+
+		samplePath := sample
+		if samplePath == "" {
+			if samplePath, err = defaultSample(); err != nil {
+				return err
+			}
+		}
+
+		results := conformance.Run(t, conformance.Options{
+			Provider:     name,
+			Sample:       samplePath,
+			MaxFileBytes: maxFileMB << 20,
+			Timeout:      timeout,
+		})
+
+		passed := conformance.Passed(results)
+		err = output.Print(verifyResult{Provider: name, Passed: passed, Checks: results}, func(out io.Writer) error {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("CHECK\tSTATUS\tTIME\tDETAIL"))
+			for _, r := range results {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Check, r.Status, r.Duration.Round(time.Millisecond), oneLine(r.Detail))
+			}
+			if err := w.Flush(); err != nil || !passed {
+				return err
+			}
+			fmt.Fprint(out, i18n.T("provider %s passed\n", name))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !passed {
+			return errors.New(i18n.T("provider %s failed conformance checks", name))
+		}
+		return nil
+	},
+}
+
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark [name...]",
+	Short: "Measure the providers on a reference recording and recommend one",
+	Long: `Measure the providers on a reference recording and recommend one
+
+- Every provider (all of them when none is named) transcribes the same recording
+- Latency, word error rate against the known transcript and cost (see cost.rates in config.yaml) are measured
+- The results are kept in data/provider_benchmarks.json, the provider "auto" uses the recommended one:
+  the most accurate, the cheapest and then the fastest among equally accurate ones`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := args
+		if len(names) == 0 {
+			names = app.ProviderNames
+		}
+		opts, err := benchmarkOptions()
+		if err != nil {
+			return err
+		}
+
+		var results []benchmark.Result
+		for _, name := range names {
+			t, err := app.NewProvider(name)
+			if err != nil {
+				results = append(results, benchmark.Result{Provider: name, Error: err.Error(), MeasuredAt: time.Now().UTC()})
+				continue
+			}
+			fmt.Fprint(os.Stderr, i18n.T("Benchmarking %s...\n", name))
+			results = append(results, benchmark.Run(name, t, opts))
+		}
+
+		path, err := benchmark.DefaultPath()
+		if err != nil {
+			return err
+		}
+		if err = benchmark.Save(path, results); err != nil {
+			return err
+		}
+		recommended, err := benchmark.Recommended(path)
+		if err != nil {
+			return err
+		}
+
+		return output.Print(benchmarkResult{Results: results, Recommended: recommended}, func(out io.Writer) error {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("PROVIDER\tMODEL\tLATENCY\tWER\tCOST\tERROR"))
+			for _, r := range results {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%.3f\t%.4f\t%s\n", r.Provider, r.Model, r.Latency.Round(time.Millisecond), r.WER, r.Cost, oneLine(r.Error))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprint(out, i18n.T("Recommended provider: %s\n", recommended))
+			return nil
+		})
+	},
+}
+
+// benchmarkOptions returns the recording of the benchmark with its transcript.
+func benchmarkOptions() (benchmark.Options, error) {
+	var opts benchmark.Options
+	if benchFile != "" {
+		if reference == "" {
+			return opts, errors.New(i18n.T("--file needs --reference with its transcript"))
+		}
+		text, err := os.ReadFile(reference)
+		if err != nil {
+			return opts, err
+		}
+		opts.Sample, opts.Reference = benchFile, string(text)
+	} else {
+		m, err := corpus.Default()
+		if err != nil {
+			return opts, err
+		}
+		s, err := m.Sample(benchSample)
+		if err != nil {
+			return opts, err
+		}
+		if s.Transcript == "" {
+			return opts, errors.New(i18n.T("sample %s has no known transcript, pass --file and --reference", benchSample))
+		}
+		path, err := m.Verify(benchSample)
+		if errors.Is(err, corpus.ErrNotFetched) {
+			return opts, errors.New(i18n.T("run v2t corpus fetch %s first", benchSample))
+		}
+		if err != nil {
+			return opts, err
+		}
+		opts.Sample, opts.Reference = path, s.Transcript
+	}
+
+	opts.Rates = config.Get().Cost.Rates
+	duration, err := audio.GetAudioDuration(opts.Sample)
+	if err != nil {
+		log.Printf("Error getting the duration of %s, its cost is unknown: %v\n", opts.Sample, err)
+	}
+	opts.AudioSeconds = float64(duration)
+	return opts, nil
+}
+
+func defaultSample() (string, error) {
+	m, err := corpus.Default()
+	if err != nil {
+		return "", err
+	}
+	path, err := m.Verify("jfk")
+	if errors.Is(err, corpus.ErrNotFetched) {
+		return "", errors.New(i18n.T("no sample given, run v2t corpus fetch jfk or pass --sample"))
+	}
+	return path, err
+}
+
+// verifyResult is the outcome of verify printed by --json.
+type verifyResult struct {
+	Provider string               `json:"provider"`
+	Passed   bool                 `json:"passed"`
+	Checks   []conformance.Result `json:"checks"`
+}
+
+// benchmarkResult is the outcome of benchmark printed by --json.
+type benchmarkResult struct {
+	Results     []benchmark.Result `json:"results"`
+	Recommended string             `json:"recommended"`
+}
+
+// oneLine keeps multi-line error details in their table row.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,168 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app/api/ssh_whisper"
+	"tiktok-whisper/internal/app/cleanup"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	useDownloaded bool
+	repository    string
+)
+
+func init() {
+	modelsDownloadCmd.Flags().BoolVar(&useDownloaded, "use", false,
+		"Make the downloaded model the default of ssh_whisper in providers.yaml")
+	modelsDownloadCmd.Flags().StringVar(&repository, "repository", ssh_whisper.ModelRepository,
+		"Repository the models and their checksums are taken from")
+
+	modelsCmd.AddCommand(modelsListCmd, modelsDownloadCmd, modelsUseCmd)
+	sshCmd.AddCommand(modelsCmd)
+	Cmd.AddCommand(sshCmd)
+}
+
+var sshCmd = &cobra.Command{
+	Use:   "ssh",
+	Short: "Manage the whisper.cpp host of ssh_whisper",
+}
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List, download and select the models on the whisper.cpp host",
+	Long: `List, download and select the models on the whisper.cpp host
+
+- The host is the ssh settings of ssh_whisper in providers.yaml, the models are in the directory of ssh.model
+- Models are downloaded by the host itself and verified with the sha256 the repository publishes
+- The default model is ssh.model in providers.yaml`,
+}
+
+var modelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the ggml models on the whisper.cpp host",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		st, err := ssh_whisper.New()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		models, err := st.ListModels()
+		if err != nil {
+			return err
+		}
+		return output.Print(models, func(out io.Writer) error {
+			if len(models) == 0 {
+				fmt.Fprint(out, i18n.T("No models in %s\n", st.ModelsDir()))
+				return nil
+			}
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("MODEL\tSIZE\tDEFAULT\tPATH"))
+			for _, m := range models {
+				def := ""
+				if m.Default {
+					def = "*"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Name, cleanup.FormatBytes(m.Size), def, m.Path)
+			}
+			return w.Flush()
+		})
+	},
+}
+
+var modelsDownloadCmd = &cobra.Command{
+	Use:   "download <model>...",
+	Short: "Download models on the whisper.cpp host and verify their checksums",
+	Long: `Download models on the whisper.cpp host and verify their checksums
+
+- Models are named like whisper.cpp names them, e.g. base.en, large-v3 or large-v3-turbo
+- The host downloads them with curl or wget, models already there are verified and kept
+- A model failing verification is removed, --use makes the last model the default`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		st, err := ssh_whisper.New()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+		st.SetProgressHandler(func(p ssh_whisper.Transfer) {
+			if p.Total > 0 {
+				fmt.Fprint(output.Text(), i18n.T("\r%s of %s", cleanup.FormatBytes(p.Done), cleanup.FormatBytes(p.Total)))
+			}
+		})
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		var downloaded []ssh_whisper.RemoteModel
+		for _, name := range args {
+			m, err := ssh_whisper.LookupModel(ctx, http.DefaultClient, repository, name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(output.Text(), i18n.T("Downloading %s (%s) on the host\n", m.Name, cleanup.FormatBytes(m.Size)))
+			model, err := st.DownloadModel(ctx, m)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(output.Text(), i18n.T("\r%s verified at %s\n", model.Name, model.Path))
+			downloaded = append(downloaded, model)
+		}
+
+		if useDownloaded {
+			last := downloaded[len(downloaded)-1]
+			if err = useModel(last.Path); err != nil {
+				return err
+			}
+			last.Default = true
+			downloaded[len(downloaded)-1] = last
+		}
+		return output.Print(downloaded, func(out io.Writer) error { return nil })
+	},
+}
+
+var modelsUseCmd = &cobra.Command{
+	Use:   "use <model>",
+	Short: "Make a model on the whisper.cpp host the default of ssh_whisper",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		st, err := ssh_whisper.New()
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+
+		models, err := st.ListModels()
+		if err != nil {
+			return err
+		}
+		file := ssh_whisper.ModelFileName(args[0])
+		for _, m := range models {
+			if ssh_whisper.ModelFileName(m.Name) == file {
+				return useModel(m.Path)
+			}
+		}
+		return errors.New(i18n.T("model %s is not on the host, download it with v2t providers ssh models download %s", args[0], args[0]))
+	},
+}
+
+// useModel makes the model at path on the host the default of ssh_whisper in providers.yaml.
+func useModel(path string) error {
+	if err := config.SetProvidersValue(config.ProvidersFile(), path, "providers", "ssh_whisper", "ssh", "model"); err != nil {
+		return err
+	}
+	fmt.Fprint(output.Text(), i18n.T("ssh_whisper transcribes with %s\n", path))
+	return nil
+}
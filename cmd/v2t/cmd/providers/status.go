@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var check bool
+
+// unavailable is the state of the providers that aren't registered, e.g. openai without a key.
+const unavailable = "unavailable"
+
+func init() {
+	statusCmd.Flags().BoolVar(&check, "check", false,
+		"Run the health checks of the providers first, failing checks count against their circuits")
+
+	Cmd.AddCommand(statusCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the circuit breakers of the providers",
+	Long: `Show the circuit breakers of the providers
+
+- A provider failing failures times in a row (circuit_breaker in providers.yaml, 5 by default) is taken out of the rotation
+- After the cooldown (1m by default) a single probe is let through, it closes the circuit when it succeeds
+- Failures caused by the audio don't count, the circuits are kept in data/provider_breakers.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registry := app.DefaultProviderRegistry()
+		statuses := registry.Status()
+		if check {
+			statuses = registry.Check()
+		}
+
+		for _, name := range app.ProviderNames {
+			if !lo.Contains(registry.Names(), name) {
+				statuses = append(statuses, provider.BreakerStatus{Provider: name, State: unavailable, LastError: "not configured"})
+			}
+		}
+
+		return output.Print(statuses, func(out io.Writer) error {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("PROVIDER\tSTATE\tFAILURES\tOPENED\tLAST ERROR"))
+			for _, s := range statuses {
+				if s.State == unavailable {
+					fmt.Fprintf(w, "%s\t%s\t-\t-\t%s\n", s.Provider, i18n.T(s.State), i18n.T(s.LastError))
+					continue
+				}
+				opened := "-"
+				if !s.OpenedAt.IsZero() {
+					opened = s.OpenedAt.Local().Format("2006-01-02 15:04:05")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", s.Provider, i18n.T(s.State), s.Failures, opened, oneLine(s.LastError))
+			}
+			return w.Flush()
+		})
+	},
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/output"
+	"tiktok-whisper/internal/app/queue"
+	"tiktok-whisper/internal/app/util/files"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user            string
+	audio           bool
+	fileExtension   string
+	outputDirectory string
+)
+
+func init() {
+	addCmd.Flags().StringVarP(&user, "user", "u", "default", "Which user owns the videos, their transcriptions are stored in this user's database")
+	addCmd.Flags().BoolVarP(&audio, "audio", "a", false, "Queue audio files, whose text is written to --outputDirectory, instead of videos")
+	addCmd.Flags().StringVarP(&fileExtension, "type", "t", "", "Extension of the files to queue, mp4 for videos and mp3 for audio by default")
+	addCmd.Flags().StringVarP(&outputDirectory, "outputDirectory", "o", "./data/transcription", "Where the text files of audio files are written")
+
+	Cmd.AddCommand(addCmd)
+}
+
+// Cmd represents the queue command
+var Cmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Convert files in the background with the local job queue",
+	Long: `Convert files in the background with the local job queue
+
+- v2t queue add queues the files of a directory in data/queue.db
+- v2t queue worker converts the queued files, several at a time with --concurrency
+- Failed files are retried, files of a worker that crashed are picked up by the next one
+- v2t queue status counts the jobs and lists those not done yet`,
+}
+
+var addCmd = &cobra.Command{
+	Use:   "add <dir>...",
+	Short: "Queue the files of directories for conversion",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kind := model.BatchVideo
+		if audio {
+			kind = model.BatchAudio
+		}
+		if fileExtension == "" {
+			fileExtension = "mp4"
+			if audio {
+				fileExtension = "mp3"
+			}
+		}
+		absOutput, err := filepath.Abs(outputDirectory)
+		if err != nil {
+			return err
+		}
+
+		var jobs []queue.Job
+		for _, dir := range args {
+			absDir, err := files.GetAbsolutePath(dir)
+			if err != nil {
+				return err
+			}
+			fileInfos, err := files.GetAllFiles(absDir, fileExtension)
+			if err != nil {
+				return errors.New(i18n.T("List the files of %s failed: %v", dir, err))
+			}
+			for _, f := range fileInfos {
+				job := queue.Job{Kind: kind, Path: f.FullPath, User: user}
+				if audio {
+					job.User, job.OutputDirectory = "", absOutput
+				}
+				jobs = append(jobs, job)
+			}
+		}
+
+		q, err := open()
+		if err != nil {
+			return err
+		}
+		defer q.Close()
+
+		added, err := q.Add(jobs...)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(output.Text(), i18n.T("Queued %d files, %d were already queued\n", added, len(jobs)-added))
+		return nil
+	},
+}
+
+// open opens the queue in the data directory.
+func open() (*queue.Queue, error) {
+	path, err := queue.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return queue.Open(path)
+}
@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+	"tiktok-whisper/internal/app/queue"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var status string
+
+func init() {
+	statusCmd.Flags().StringVarP(&status, "status", "s", "", "List the jobs with this status: pending, running, done or failed (default all but done)")
+
+	Cmd.AddCommand(statusCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Count the queued jobs and list those not done",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statuses := []queue.Status{queue.StatusPending, queue.StatusRunning, queue.StatusFailed}
+		if status != "" {
+			if !lo.Contains(queue.Statuses, queue.Status(status)) {
+				return errors.New(i18n.T("invalid --status %q, expected pending, running, done or failed", status))
+			}
+			statuses = []queue.Status{queue.Status(status)}
+		}
+
+		q, err := open()
+		if err != nil {
+			return err
+		}
+		defer q.Close()
+
+		counts, err := q.Counts()
+		if err != nil {
+			return err
+		}
+		jobs, err := q.Jobs(statuses...)
+		if err != nil {
+			return err
+		}
+
+		// an empty list rather than null for scripts
+		report := statusReport{Counts: counts, Jobs: lo.Ternary(jobs == nil, []queue.Job{}, jobs)}
+		return output.Print(report, func(out io.Writer) error {
+			fmt.Fprint(out, i18n.T("Pending %d, running %d, done %d, failed %d\n",
+				counts[queue.StatusPending], counts[queue.StatusRunning], counts[queue.StatusDone], counts[queue.StatusFailed]))
+			if len(jobs) == 0 {
+				return nil
+			}
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("ID\tKIND\tFILE\tUSER\tSTATUS\tATTEMPTS\tUPDATED\tERROR"))
+			for _, job := range jobs {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", job.ID, job.Kind, job.Path, lo.Ternary(job.User == "", "-", job.User),
+					i18n.T(string(job.Status)), job.Attempts, job.UpdatedAt.Format("2006-01-02 15:04"), job.Error)
+			}
+			return w.Flush()
+		})
+	},
+}
+
+// statusReport is the status printed by --json.
+type statusReport struct {
+	Counts map[queue.Status]int `json:"counts"`
+	Jobs   []queue.Job          `json:"jobs"`
+}
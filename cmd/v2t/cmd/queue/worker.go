@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/api/middleware"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/queue"
+	"tiktok-whisper/internal/app/vocab"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	concurrency int
+	drain       bool
+)
+
+func init() {
+	workerCmd.Flags().IntVarP(&concurrency, "concurrency", "c", 1, "How many files to convert at the same time")
+	workerCmd.Flags().BoolVar(&drain, "drain", false, "Exit once no queued file is due instead of waiting for new ones")
+
+	Cmd.AddCommand(workerCmd)
+}
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Convert the queued files",
+	Long: `Convert the queued files
+
+- Runs until interrupted, the files being converted still finish
+- Files failing are retried up to 3 times, waiting longer after every attempt
+- Several workers can share the queue, every file is converted by a single one`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mws, err := middleware.FromConfig(config.Get().Middlewares)
+		if err != nil {
+			return errors.New(i18n.T("Invalid middlewares in config.yaml: %v", err))
+		}
+		if packs := vocab.Installed(); len(packs) > 0 {
+			mws = append([]middleware.Middleware{middleware.Vocabulary(packs)}, mws...)
+		}
+
+		q, err := open()
+		if err != nil {
+			return err
+		}
+		defer q.Close()
+
+		converters := &converters{middlewares: mws, byUser: map[string]*converter.Converter{}}
+		defer converters.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		handle := func(job queue.Job) error {
+			return converters.For(job.User).ConvertFile(job.Kind, job.Path, job.User, job.OutputDirectory)
+		}
+		return queue.NewWorker(q, handle, queue.WorkerOptions{Concurrency: concurrency, Drain: drain}).Run(ctx)
+	},
+}
+
+// converters are the converters of the users of the jobs, each one writes to the database of its user.
+type converters struct {
+	middlewares []middleware.Middleware
+
+	mu     sync.Mutex
+	byUser map[string]*converter.Converter
+}
+
+// For returns the converter of user, set up on first use like v2t convert does.
+func (cs *converters) For(user string) *converter.Converter {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if c, ok := cs.byUser[user]; ok {
+		return c
+	}
+	c := app.InitializeConverter(user)
+	c.SweepTempFiles()
+	c.Use(cs.middlewares...)
+	c.TrackCosts(config.Get().Cost)
+	cs.byUser[user] = c
+	return c
+}
+
+func (cs *converters) Close() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, c := range cs.byUser {
+		c.Close()
+	}
+}
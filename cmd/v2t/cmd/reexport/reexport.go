@@ -0,0 +1,124 @@
+package reexport
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/locale"
+	"tiktok-whisper/internal/app/moderation"
+	"tiktok-whisper/internal/app/paragraph"
+	"tiktok-whisper/internal/app/redact"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/files"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user      string
+	format    string
+	since     string
+	outputDir string
+	force     bool
+	lang      string
+	broadcast bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&user, "user", "u", "", "The user whose transcriptions are re-exported")
+	Cmd.Flags().StringVarP(&format, "format", "f", "txt", "Output format: "+strings.Join(export.Formats(), ", "))
+	Cmd.Flags().StringVarP(&since, "since", "s", "", "Only re-export transcriptions converted on or after this date, e.g. 2024-01-01")
+	Cmd.Flags().StringVarP(&outputDir, "output", "o", "", "Output directory (default is data/export/<user>)")
+	Cmd.Flags().BoolVar(&force, "force", false, "Rewrite artifacts even when they are up to date")
+	Cmd.Flags().BoolVar(&broadcast, "broadcast", false, "Also write a broadcast variant with profanity redacted (default is broadcast.enabled in config.yaml)")
+	Cmd.Flags().StringVar(&lang, "locale", "", "Locale for dates and numbers, e.g. zh-CN (default is the user's locale in config.yaml, then LANG)")
+
+	Cmd.MarkFlagRequired("user")
+}
+
+// Cmd represents the re-export command
+var Cmd = &cobra.Command{
+	Use:   "re-export",
+	Short: "Regenerate output files from stored transcriptions",
+	Long: `Regenerate output files from stored transcriptions
+
+- Nothing is transcribed again, the stored text is rendered with the current writers
+- Artifacts that are still up to date are skipped, every rewrite is recorded as a new artifact version`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := export.ReExportOptions{
+			User:      user,
+			Format:    format,
+			OutputDir: outputDir,
+			Force:     force,
+			Write: export.Options{
+				Locale: locale.Resolve(lang, config.Get().Locale.For(user)),
+				Paragraphs: paragraph.Options{
+					MaxLength: config.Get().Paragraphs.MaxLength,
+					Pause:     config.Get().Paragraphs.Pause,
+				},
+				Legal: export.LegalOptions{
+					LinesPerPage:         config.Get().Verbatim.LinesPerPage,
+					LineWidth:            config.Get().Verbatim.LineWidth,
+					InaudibleProbability: config.Get().Verbatim.InaudibleProbability,
+				},
+			},
+		}
+
+		if since != "" {
+			t, err := time.ParseInLocation("2006-01-02", since, time.Local)
+			if err != nil {
+				return errors.New(i18n.T("invalid --since %q, expected YYYY-MM-DD: %v", since, err))
+			}
+			opts.Since = t
+		}
+
+		if cfg := config.Get().Broadcast; broadcast || cfg.Enabled {
+			filter, err := redact.NewFilter(redact.Mode(cfg.Mode), cfg.WordsFile, cfg.Words)
+			if err != nil {
+				return err
+			}
+			opts.Broadcast = filter
+		}
+
+		if opts.OutputDir == "" {
+			projectRoot, err := files.GetProjectRoot()
+			if err != nil {
+				return err
+			}
+			opts.OutputDir = filepath.Join(projectRoot, "data", "export", user)
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+
+		artifacts, ok := db.(repository.ArtifactDAO)
+		if !ok {
+			return errors.New(i18n.T("the configured database does not track artifacts"))
+		}
+		blocker, err := moderation.ExportBlocker(config.Get().Moderation, db)
+		if err != nil {
+			return err
+		}
+		opts.BlockFlagged = blocker
+
+		result, err := export.ReExport(db, artifacts, opts)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(i18n.T("re-export finished, %d written, %d up to date, output dir: %s\n", result.Written, result.Skipped, opts.OutputDir))
+		if result.Untimed > 0 {
+			fmt.Print(i18n.T("%d transcriptions have no timestamps and were skipped\n", result.Untimed))
+		}
+		if result.Blocked > 0 {
+			fmt.Print(i18n.T("%d transcriptions flagged by moderation were skipped\n", result.Blocked))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,128 @@
+package refine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/refine"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user   string
+	limit  int
+	wait   bool
+	now    bool
+	status string
+)
+
+func init() {
+	runCmd.Flags().StringVarP(&user, "user", "u", "", "The user whose database holds the drafts (default database when empty)")
+	runCmd.Flags().IntVarP(&limit, "limit", "n", 0, "Refine at most this many drafts, all of them when 0")
+	runCmd.Flags().BoolVar(&wait, "wait", false, "Outside refine.window, wait until it opens instead of exiting")
+	runCmd.Flags().BoolVar(&now, "now", false, "Refine right away, ignoring refine.window")
+
+	statusCmd.Flags().StringVarP(&user, "user", "u", "", "The user whose database holds the drafts (default database when empty)")
+	statusCmd.Flags().StringVarP(&status, "status", "s", "", "Only list the drafts with this status: pending, done or failed")
+
+	Cmd.AddCommand(runCmd)
+	Cmd.AddCommand(statusCmd)
+}
+
+// Cmd represents the refine command
+var Cmd = &cobra.Command{
+	Use:   "refine",
+	Short: "Replace draft transcriptions with high-quality ones",
+	Long: `Replace draft transcriptions with high-quality ones
+
+- v2t convert --draft transcribes with the fast refine.draft_model of config.yaml and queues the result
+- v2t refine run transcribes the queued drafts again with refine.model, within refine.window
+- The refined text becomes the current revision, the draft stays in v2t revisions`,
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Refine the queued drafts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		window, err := refine.ParseWindow(config.Get().Refine.Window)
+		if err != nil {
+			return errors.New(i18n.T("Invalid refine.window in config.yaml: %v", err))
+		}
+
+		r := app.InitializeRefiner(user)
+		defer r.Close()
+		if !now {
+			r.SetWindow(window)
+		}
+
+		if next := r.Window().Next(time.Now()); next.After(time.Now()) {
+			if !wait {
+				fmt.Print(i18n.T("Outside the refine window %s, it opens at %s\n", r.Window(), next.Format("2006-01-02 15:04")))
+				return nil
+			}
+			fmt.Print(i18n.T("Waiting for the refine window %s, it opens at %s\n", r.Window(), next.Format("2006-01-02 15:04")))
+			time.Sleep(time.Until(next))
+		}
+
+		result, err := r.Run(limit)
+		if errors.Is(err, refine.ErrNotSupported) {
+			return errors.New(i18n.T("the configured database does not keep refine jobs"))
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("Refined %d, failed %d, %d drafts remaining\n", result.Refined, result.Failed, result.Remaining))
+		return nil
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List the drafts and their refine status",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch model.RefineStatus(status) {
+		case "", model.RefinePending, model.RefineDone, model.RefineFailed:
+		default:
+			return errors.New(i18n.T("invalid --status %q, expected pending, done or failed", status))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+		queue, ok := db.(repository.RefineDAO)
+		if !ok {
+			return errors.New(i18n.T("the configured database does not keep refine jobs"))
+		}
+
+		jobs, err := queue.GetRefineJobs(model.RefineStatus(status))
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			fmt.Print(i18n.T("No drafts queued for refinement\n"))
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("ID\tFILE\tSTATUS\tUPDATED\tERROR"))
+		for _, job := range jobs {
+			name := job.AudioPath
+			if t, err := db.GetByID(job.TranscriptionID); err == nil && t != nil {
+				name = t.Mp3FileName
+			}
+			updated := job.UpdatedAt
+			if updated.IsZero() {
+				updated = job.QueuedAt
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", job.TranscriptionID, name, job.Status, updated.Format("2006-01-02 15:04"), job.Error)
+		}
+		return w.Flush()
+	},
+}
@@ -0,0 +1,153 @@
+package revisions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/textdiff"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	transcriptionID int
+	from            int
+	to              int
+	revision        int
+	user            string
+)
+
+func init() {
+	Cmd.PersistentFlags().IntVarP(&transcriptionID, "id", "i", 0, "The id of the transcription")
+	Cmd.MarkPersistentFlagRequired("id")
+	Cmd.PersistentFlags().StringVarP(&user, "user", "u", "", "The user whose database holds the transcription (default database when empty)")
+
+	diffCmd.Flags().IntVar(&from, "from", 0, "Old revision (default is the revision before --to)")
+	diffCmd.Flags().IntVar(&to, "to", 0, "New revision (default is the current revision)")
+
+	useCmd.Flags().IntVarP(&revision, "revision", "r", 0, "The revision to make current")
+	useCmd.MarkFlagRequired("revision")
+
+	Cmd.AddCommand(listCmd, diffCmd, useCmd)
+}
+
+// Cmd represents the revisions command
+var Cmd = &cobra.Command{
+	Use:   "revisions",
+	Short: "List, compare and pick the revisions of a re-transcribed file",
+	Long: `List, compare and pick the revisions of a re-transcribed file
+
+- Converting a file again (e.g. with convert --retranscribe) stores the result as a new revision
+- The newest revision becomes current, use "revisions use" to go back to an older one`,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the revisions of a transcription",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		revisions, err := getRevisions()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("REVISION\tCURRENT\tCREATED\tPROVIDER\tMODEL\tLENGTH"))
+		for _, r := range revisions {
+			current := ""
+			if r.Current {
+				current = "*"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\n", r.Revision, current, r.CreatedAt.Format("2006-01-02 15:04:05"),
+				r.ProviderMetadata.Provider, r.ProviderMetadata.Model, len([]rune(r.Transcription)))
+		}
+		return w.Flush()
+	},
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show what changed between two revisions, sentence by sentence",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		revisions, err := getRevisions()
+		if err != nil {
+			return err
+		}
+
+		newRev, err := findRevision(revisions, to, func(r model.Revision) bool { return r.Current })
+		if err != nil {
+			return err
+		}
+		oldRev, err := findRevision(revisions, from, func(r model.Revision) bool { return r.Revision < newRev.Revision })
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(i18n.T("--- revision %d\n+++ revision %d\n", oldRev.Revision, newRev.Revision))
+		fmt.Print(textdiff.Unified(textdiff.Diff(oldRev.Transcription, newRev.Transcription)))
+		return nil
+	},
+}
+
+var useCmd = &cobra.Command{
+	Use:   "use",
+	Short: "Make a revision the current text of the transcription",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, dao, err := openRevisionDAO()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		if err = dao.SetCurrentRevision(transcriptionID, revision); err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("revision %d is now current for transcription %d\n", revision, transcriptionID))
+		return nil
+	},
+}
+
+func openRevisionDAO() (repository.TranscriptionDAO, repository.RevisionDAO, error) {
+	db := app.InitializeTranscriptionDAOForUser(user)
+	dao, ok := db.(repository.RevisionDAO)
+	if !ok {
+		db.Close()
+		return nil, nil, errors.New(i18n.T("the configured database does not keep revisions"))
+	}
+	return db, dao, nil
+}
+
+func getRevisions() ([]model.Revision, error) {
+	db, dao, err := openRevisionDAO()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	revisions, err := dao.GetRevisions(transcriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, errors.New(i18n.T("transcription %d has a single result, there are no revisions", transcriptionID))
+	}
+	return revisions, nil
+}
+
+// findRevision returns the revision numbered n, or when n is 0 the last revision matching fallback.
+func findRevision(revisions []model.Revision, n int, fallback func(model.Revision) bool) (model.Revision, error) {
+	for i := len(revisions) - 1; i >= 0; i-- {
+		r := revisions[i]
+		if (n != 0 && r.Revision == n) || (n == 0 && fallback(r)) {
+			return r, nil
+		}
+	}
+	if n == 0 {
+		return model.Revision{}, errors.New(i18n.T("no earlier revision to compare with, use --from"))
+	}
+	return model.Revision{}, errors.New(i18n.T("transcription %d has no revision %d", transcriptionID, n))
+}
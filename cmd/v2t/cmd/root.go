@@ -1,16 +1,61 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"os"
+	"strings"
+	"sync"
+	"tiktok-whisper/cmd/v2t/cmd/analyze"
+	"tiktok-whisper/cmd/v2t/cmd/ask"
+	"tiktok-whisper/cmd/v2t/cmd/bench"
+	"tiktok-whisper/cmd/v2t/cmd/chat"
 	"tiktok-whisper/cmd/v2t/cmd/config"
 	"tiktok-whisper/cmd/v2t/cmd/convert"
+	"tiktok-whisper/cmd/v2t/cmd/corpus"
+	"tiktok-whisper/cmd/v2t/cmd/cost"
+	"tiktok-whisper/cmd/v2t/cmd/db"
 	"tiktok-whisper/cmd/v2t/cmd/download"
+	"tiktok-whisper/cmd/v2t/cmd/embed"
 	"tiktok-whisper/cmd/v2t/cmd/export"
+	"tiktok-whisper/cmd/v2t/cmd/generate"
+	"tiktok-whisper/cmd/v2t/cmd/importer"
+	"tiktok-whisper/cmd/v2t/cmd/mcp"
+	"tiktok-whisper/cmd/v2t/cmd/meta"
+	"tiktok-whisper/cmd/v2t/cmd/moderate"
+	"tiktok-whisper/cmd/v2t/cmd/prompts"
+	"tiktok-whisper/cmd/v2t/cmd/providers"
+	"tiktok-whisper/cmd/v2t/cmd/queue"
+	"tiktok-whisper/cmd/v2t/cmd/reexport"
+	"tiktok-whisper/cmd/v2t/cmd/refine"
+	"tiktok-whisper/cmd/v2t/cmd/revisions"
+	"tiktok-whisper/cmd/v2t/cmd/search"
+	"tiktok-whisper/cmd/v2t/cmd/serve"
+	"tiktok-whisper/cmd/v2t/cmd/soak"
+	"tiktok-whisper/cmd/v2t/cmd/stats"
+	"tiktok-whisper/cmd/v2t/cmd/transcriptions"
+	"tiktok-whisper/cmd/v2t/cmd/translate"
 	"tiktok-whisper/cmd/v2t/cmd/version"
+	"tiktok-whisper/cmd/v2t/cmd/vocab"
+	"tiktok-whisper/cmd/v2t/cmd/watch"
+	appconfig "tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/exitcode"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/output"
+	"tiktok-whisper/internal/app/tracing"
+	"time"
 )
 
 var Verbose bool
+var cfgFile string
+var logLevel string
+var logFormat string
+var jsonOutput bool
+var quiet bool
+var noEmoji bool
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -23,27 +68,186 @@ var rootCmd = &cobra.Command{
 	TraverseChildren: true,
 }
 
+// shutdownTimeout bounds how long the spans not yet exported may hold up the exit.
+const shutdownTimeout = 10 * time.Second
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	shutdown, err := tracing.Init()
+	if err != nil {
+		logging.L().Warn("Error configuring tracing, running without it", "error", err)
+	}
+
+	markUsageErrors(rootCmd)
+	err = rootCmd.Execute()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if serr := shutdown(ctx); serr != nil {
+		logging.L().Error("Error exporting spans", "error", serr)
+	}
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitcode.Code(err))
+	}
+}
+
+// markUsageErrors makes invalid flags and arguments of c and its sub commands exit with exitcode.Usage,
+// mistyped sub commands included.
+func markUsageErrors(c *cobra.Command) {
+	if c.HasSubCommands() && !c.Runnable() {
+		// cobra prints the help of a group called with an unknown sub command and succeeds
+		c.Args = cobra.NoArgs
+		c.RunE = func(c *cobra.Command, args []string) error {
+			return c.Help()
+		}
+	}
+	c.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+		return exitcode.WithCode(exitcode.Usage, err)
+	})
+	if args := c.Args; args != nil {
+		c.Args = func(c *cobra.Command, a []string) error {
+			return exitcode.WithCode(exitcode.Usage, args(c, a))
+		}
+	}
+	for _, sub := range c.Commands() {
+		markUsageErrors(sub)
 	}
 }
 
 func init() {
+	cobra.OnInitialize(initConfig)
+
+	rootCmd.AddCommand(analyze.Cmd)
+	rootCmd.AddCommand(ask.Cmd)
+	rootCmd.AddCommand(bench.Cmd)
+	rootCmd.AddCommand(chat.Cmd)
 	rootCmd.AddCommand(config.Cmd)
 	rootCmd.AddCommand(download.Cmd)
 	rootCmd.AddCommand(convert.Cmd)
+	rootCmd.AddCommand(corpus.Cmd)
+	rootCmd.AddCommand(cost.Cmd)
+	rootCmd.AddCommand(db.Cmd)
+	rootCmd.AddCommand(embed.Cmd)
 	rootCmd.AddCommand(export.Cmd)
+	rootCmd.AddCommand(generate.Cmd)
+	rootCmd.AddCommand(importer.Cmd)
+	rootCmd.AddCommand(mcp.Cmd)
+	rootCmd.AddCommand(meta.Cmd)
+	rootCmd.AddCommand(moderate.Cmd)
+	rootCmd.AddCommand(prompts.Cmd)
+	rootCmd.AddCommand(providers.Cmd)
+	rootCmd.AddCommand(queue.Cmd)
+	rootCmd.AddCommand(reexport.Cmd)
+	rootCmd.AddCommand(refine.Cmd)
+	rootCmd.AddCommand(revisions.Cmd)
+	rootCmd.AddCommand(search.Cmd)
+	rootCmd.AddCommand(serve.Cmd)
+	rootCmd.AddCommand(soak.Cmd)
+	rootCmd.AddCommand(stats.Cmd)
+	rootCmd.AddCommand(transcriptions.Cmd)
+	rootCmd.AddCommand(translate.Cmd)
 	rootCmd.AddCommand(version.Cmd)
+	rootCmd.AddCommand(vocab.Cmd)
+	rootCmd.AddCommand(watch.Cmd)
 
 	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "V", false, "verbose output")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/v2t/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Level of the log messages: debug, info, warn or error, --verbose is debug")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.FormatConsole, "Format of the log messages on stderr: console or json")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Print the results as JSON on stdout, the other messages go to stderr")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Print only errors and results, for cron jobs and CI, see the exit statuses in the README")
+	rootCmd.PersistentFlags().BoolVar(&noEmoji, "no-emoji", false, "Leave out emoji and other decorations like sparklines")
+
+	// --help doesn't run the initializers, the help function selects the language itself
+	defaultHelp := rootCmd.HelpFunc()
+	rootCmd.SetHelpFunc(func(c *cobra.Command, args []string) {
+		initConfig()
+		defaultHelp(c, args)
+	})
+}
+
+var localizeOnce sync.Once
+var loggingOnce sync.Once
 
-	// Here you will define your flags and configuration settings.
-	// Cobra supports persistent flags, which, if defined here,
-	// will be global for your application.
+// initConfig points the config loader at the file given by --config, if any, sets up the logger
+// as the log flags ask for, selects the output of --json, --quiet and --no-emoji and translates the
+// commands into the configured language.
+func initConfig() {
+	if cfgFile != "" {
+		appconfig.SetConfigFile(cfgFile)
+	}
+
+	output.SetJSON(jsonOutput)
+	output.SetQuiet(quiet)
+	output.SetNoEmoji(noEmoji)
+	// the error is printed without the usage, which isn't an error or result
+	rootCmd.SilenceUsage = quiet
+
+	loggingOnce.Do(func() {
+		level := logLevel
+		if !rootCmd.PersistentFlags().Changed("log-level") {
+			switch {
+			case Verbose:
+				level = "debug"
+			case quiet:
+				level = "error"
+			}
+		}
+		if err := logging.Setup(logging.Options{Level: level, Format: logFormat}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	})
+
+	localizeOnce.Do(func() {
+		i18n.SetLanguage(i18n.Detect(appconfig.Get().Language))
+		if i18n.Language() == i18n.English {
+			return
+		}
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cmd.yaml)")
+		rootCmd.InitDefaultHelpCmd()
+		rootCmd.InitDefaultCompletionCmd()
+		localizeCommand(rootCmd)
+		rootCmd.SetUsageTemplate(localizeUsageTemplate(rootCmd.UsageTemplate()))
+	})
+}
+
+// localizeCommand translates the help texts of c and its sub commands.
+func localizeCommand(c *cobra.Command) {
+	c.Short = i18n.T(c.Short)
+	c.Long = i18n.T(c.Long)
+
+	c.InitDefaultHelpFlag()
+	c.Flags().VisitAll(func(f *pflag.Flag) {
+		f.Usage = i18n.T(f.Usage)
+	})
+	if f := c.Flags().Lookup("help"); f != nil {
+		f.Usage = i18n.T("help for %s", c.Name())
+	}
+
+	for _, sub := range c.Commands() {
+		localizeCommand(sub)
+	}
+}
+
+// usageHeadings are the English texts of cobra's usage template, "Global Flags:"
+// must be replaced before "Flags:".
+var usageHeadings = []string{
+	`Use "{{.CommandPath}} [command] --help" for more information about a command.`,
+	"Additional help topics:",
+	"Additional Commands:",
+	"Available Commands:",
+	"Global Flags:",
+	"Flags:",
+	"Examples:",
+	"Aliases:",
+	"Usage:",
+}
+
+func localizeUsageTemplate(template string) string {
+	for _, heading := range usageHeadings {
+		template = strings.ReplaceAll(template, heading, i18n.T(heading))
+	}
+	return template
 }
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+	"tiktok-whisper/internal/app/search"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user    string
+	limit   int
+	format  string
+	baseURL string
+
+	includeLowConfidence bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&user, "user", "u", "default", "Whose transcriptions to search")
+	Cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Most results to list, 0 lists all")
+	Cmd.Flags().StringVarP(&format, "output", "o", "text", "Output format: text, or alfred for the script filter JSON of Alfred and Raycast")
+	Cmd.Flags().StringVar(&baseURL, "base-url", "http://127.0.0.1:8080", "Address of v2t serve the alfred items link to")
+	Cmd.Flags().BoolVar(&includeLowConfidence, "include-low-confidence", true, "List likely misheard transcriptions after the others, false leaves them out")
+}
+
+// Cmd represents the search command
+var Cmd = &cobra.Command{
+	Use:   "search <query>...",
+	Short: "Search the stored transcriptions for keywords",
+	Long: `Search the stored transcriptions for keywords
+
+- Lists the transcriptions whose file name and text contain all words of the query
+- Transcriptions whose words the provider was unsure of, or that failed the result checks, are listed last
+- With --output alfred, prints the JSON of an Alfred script filter, whose items open the transcription in v2t serve
+- v2t serve answers the same search at /api/quick-search?user=...&q=...`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if format != "text" && format != "alfred" {
+			return errors.New(i18n.T("unknown output %q, use text or alfred", format))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+		results, err := search.Transcriptions(db, user, strings.Join(args, " "), search.Options{Limit: limit, ExcludeLowConfidence: !includeLowConfidence})
+		if err != nil && !(format == "alfred" && errors.Is(err, search.ErrEmptyQuery)) {
+			return err
+		}
+
+		if format == "alfred" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(search.NewScriptFilter(results, baseURL))
+		}
+
+		found := make([]match, 0, len(results))
+		for _, r := range results {
+			found = append(found, match{ID: r.ID, File: r.Mp3FileName, User: r.User, Confidence: r.Confidence, Snippet: r.Snippet})
+		}
+		return output.Print(found, func(out io.Writer) error {
+			if len(results) == 0 {
+				fmt.Fprint(out, i18n.T("No transcription matches\n"))
+				return nil
+			}
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("ID\tFILE\tCONFIDENCE\tSNIPPET"))
+			for _, r := range results {
+				fmt.Fprintf(w, "%d\t%s\t%.2f\t%s\n", r.ID, r.Mp3FileName, r.Confidence, strings.Join(strings.Fields(r.Snippet), " "))
+			}
+			return w.Flush()
+		})
+	},
+}
+
+// match is a search result as printed by --json.
+type match struct {
+	ID         int     `json:"id"`
+	File       string  `json:"file"`
+	User       string  `json:"user"`
+	Confidence float64 `json:"confidence"`
+	Snippet    string  `json:"snippet"`
+}
@@ -0,0 +1,96 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/agent"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/embedding/provider"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/server"
+	"tiktok-whisper/internal/app/translation"
+	"tiktok-whisper/internal/app/util/files"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	addr      string
+	grpcAddr  string
+	uploadDir string
+	workers   int
+	maxUpload int64
+	agents    bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&addr, "addr", "a", "127.0.0.1:8080", "Address to listen on")
+	Cmd.Flags().StringVar(&grpcAddr, "grpc-addr", "", "Also serve the gRPC API of api/proto on this address, example: 127.0.0.1:9090")
+	Cmd.Flags().StringVar(&uploadDir, "upload-dir", "", "Directory keeping the submitted files (default is data/uploads)")
+	Cmd.Flags().IntVarP(&workers, "workers", "w", 1, "How many jobs to transcribe at the same time")
+	Cmd.Flags().Int64Var(&maxUpload, "max-upload", server.DefaultMaxUploadBytes, "Largest accepted upload in bytes")
+	Cmd.Flags().BoolVar(&agents, "agents", false, "Transcribe the jobs on the v2t-agent machines registered over gRPC instead of locally, needs --grpc-addr")
+}
+
+// Cmd represents the serve command
+var Cmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API to submit audio files and fetch their transcriptions",
+	Long: `Serve an HTTP API to submit audio files and fetch their transcriptions
+
+- POST /api/v1/jobs with a multipart form of "file" and "user" queues a job
+- GET /api/v1/jobs/{id} reports its status, GET /api/v1/jobs/{id}/result returns the transcription
+- GET /api/v1/users/{user}/transcriptions lists the history of a user, /transcriptions/{id} returns one
+- GET /api/quick-search?user=...&q=... lists the matching transcriptions for launchers like Alfred and Raycast
+- With --grpc-addr, the same is served over gRPC as defined in api/proto/v2t/v1/v2t.proto
+- With --agents, the jobs are pulled by v2t-agent on GPU machines, GET /api/v1/agents lists them
+- GET /status shows whether the providers meet their slos of config.yaml, breaches are published as provider.unhealthy events
+- GET /review/{user} is the language-learning review: segments next to their translations, marked for spaced repetition and exported to Anki
+- GET /analytics/{user} is a dashboard of the speech analytics of a user's episodes, /api/v1/users/{user}/speech-analytics returns them as JSON
+- GET /search finds the transcriptions of a user by words and by meaning, narrowed by date and duration, /api/v1/search returns them as JSON
+- GET /dashboard follows the batch jobs on the event bus live: the progress of their files, providers, errors and throughput
+- Results are stored in the user's database, like convert does`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if agents && grpcAddr == "" {
+			return errors.New(i18n.T("--agents needs --grpc-addr for the agents to connect to"))
+		}
+		projectRoot, err := files.GetProjectRoot()
+		if err != nil && uploadDir == "" {
+			return err
+		}
+		if uploadDir == "" {
+			uploadDir = filepath.Join(projectRoot, "data", "uploads")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		opts := server.Options{
+			Addr:           addr,
+			GRPCAddr:       grpcAddr,
+			UploadDir:      uploadDir,
+			Workers:        workers,
+			MaxUploadBytes: maxUpload,
+			FillerWords:    config.Get().Analytics.FillerWords,
+		}
+		if projectRoot != "" {
+			opts.MediaDir = filepath.Join(projectRoot, "data", "mp3")
+		}
+		if opts.Translator, err = translation.New(config.Get().Translation); err != nil {
+			log.Printf("The review page can't translate: %v\n", err)
+		}
+		if opts.Embedder, err = provider.Default(); err != nil {
+			log.Printf("The search page searches by words only: %v\n", err)
+		}
+		if agents {
+			opts.Agents = agent.NewPool(agent.PoolOptions{})
+		}
+		return app.InitializeServer().Run(ctx, opts)
+	},
+}
@@ -0,0 +1,170 @@
+package soak
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/api/middleware"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter"
+	"tiktok-whisper/internal/app/corpus"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/repository/sqlite"
+	"tiktok-whisper/internal/app/soak"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hours           float64
+	providerName    string
+	sample          string
+	interval        time.Duration
+	warmup          time.Duration
+	maxHeapGrowthMB uint64
+	maxGoroutines   int
+	maxFDs          int
+	output          string
+)
+
+func init() {
+	Cmd.Flags().Float64Var(&hours, "hours", 8, "How long to run")
+	Cmd.Flags().StringVar(&providerName, "provider", "", "The provider transcribing the jobs, see v2t providers verify")
+	Cmd.MarkFlagRequired("provider")
+	Cmd.Flags().StringVarP(&sample, "sample", "s", "",
+		"Recording each job converts (default is the jfk sample of v2t corpus)")
+	Cmd.Flags().DurationVar(&interval, "interval", time.Minute, "Time between two samples of memory, goroutines and open files")
+	Cmd.Flags().DurationVar(&warmup, "warmup", 5*time.Minute, "Time before the baseline is sampled")
+	Cmd.Flags().Uint64Var(&maxHeapGrowthMB, "max-heap-growth-mb", 100, "Fail when the heap grew more than this, 0 doesn't check it")
+	Cmd.Flags().IntVar(&maxGoroutines, "max-goroutine-growth", 20, "Fail when more goroutines than this were added, 0 doesn't check it")
+	Cmd.Flags().IntVar(&maxFDs, "max-fd-growth", 20, "Fail when more files than this were left open, 0 doesn't check it")
+	Cmd.Flags().StringVarP(&output, "output", "o", "", "Write the samples to this CSV file")
+}
+
+// Cmd represents the soak command
+var Cmd = &cobra.Command{
+	Use:   "soak",
+	Short: "Convert a sample over and over for hours to find leaks",
+	Long: `Convert a sample over and over for hours to find leaks
+
+- Each job converts a fresh copy of the sample through the converter, middlewares and a scratch database
+- Memory, goroutines and open files are sampled every --interval, the first sample after --warmup is the baseline
+- The run fails when one of them grew beyond its limit by the end, or when every job failed`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		t, err := app.NewProvider(providerName)
+		if err != nil {
+			return err
+		}
+		samplePath := sample
+		if samplePath == "" {
+			if samplePath, err = defaultSample(); err != nil {
+				return err
+			}
+		}
+		mws, err := middleware.FromConfig(lo.Filter(config.Get().Middlewares, func(c config.MiddlewareConfig, i int) bool {
+			// Cached results would skip the provider after the first job
+			return c.Name != "cache"
+		}))
+		if err != nil {
+			return err
+		}
+
+		workDir, err := os.MkdirTemp("", "v2t-soak-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(workDir)
+
+		c := converter.NewConverter(t, sqlite.NewSQLiteDB(filepath.Join(workDir, "soak.db")), events.NewInProcessBus())
+		defer c.Close()
+		c.Use(mws...)
+
+		onSample, closeOutput, err := sampleWriter()
+		if err != nil {
+			return err
+		}
+		defer closeOutput()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		report, err := soak.Run(ctx, soak.Options{
+			Duration: time.Duration(hours * float64(time.Hour)),
+			Interval: interval,
+			Warmup:   warmup,
+			Limits: soak.Limits{
+				HeapGrowthBytes: maxHeapGrowthMB << 20,
+				GoroutineGrowth: maxGoroutines,
+				FDGrowth:        maxFDs,
+			},
+			OnSample: onSample,
+		}, soak.ConvertJob(c, samplePath, workDir))
+
+		fmt.Print(i18n.T("%d jobs, %d failed\n", report.Jobs, report.Failed))
+		fmt.Print(i18n.T("heap %s -> %s, goroutines %d -> %d, open files %d -> %d\n",
+			formatMB(report.Baseline.HeapBytes), formatMB(report.Last.HeapBytes),
+			report.Baseline.Goroutines, report.Last.Goroutines, report.Baseline.FDs, report.Last.FDs))
+		return err
+	},
+}
+
+// sampleWriter logs every sample and writes it to the --output CSV file when there is one.
+func sampleWriter() (func(soak.Sample), func(), error) {
+	logSample := func(s soak.Sample) {
+		log.Printf("Soak %s: %d jobs, %d failed, heap %s, %d goroutines, %d open files\n",
+			s.Elapsed.Round(time.Second), s.Jobs, s.Failed, formatMB(s.HeapBytes), s.Goroutines, s.FDs)
+	}
+	if output == "" {
+		return logSample, func() {}, nil
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"elapsed_seconds", "jobs", "failed", "heap_bytes", "goroutines", "open_files"})
+	return func(s soak.Sample) {
+			logSample(s)
+			w.Write([]string{
+				strconv.FormatFloat(s.Elapsed.Seconds(), 'f', 1, 64),
+				strconv.Itoa(s.Jobs),
+				strconv.Itoa(s.Failed),
+				strconv.FormatUint(s.HeapBytes, 10),
+				strconv.Itoa(s.Goroutines),
+				strconv.Itoa(s.FDs),
+			})
+			// Flushed each time, so the samples survive a killed run
+			w.Flush()
+		}, func() {
+			w.Flush()
+			f.Close()
+		}, nil
+}
+
+func defaultSample() (string, error) {
+	m, err := corpus.Default()
+	if err != nil {
+		return "", err
+	}
+	path, err := m.Verify("jfk")
+	if errors.Is(err, corpus.ErrNotFetched) {
+		return "", errors.New(i18n.T("no sample given, run v2t corpus fetch jfk or pass --sample"))
+	}
+	return path, err
+}
+
+func formatMB(bytes uint64) string {
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+}
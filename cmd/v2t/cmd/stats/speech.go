@@ -0,0 +1,87 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/analytics"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	speechUser  string
+	speechForce bool
+	speechTrend bool
+)
+
+func init() {
+	speechCmd.Flags().StringVarP(&speechUser, "user", "u", "default", "Whose episodes to analyze")
+	speechCmd.Flags().BoolVar(&speechForce, "force", false, "Analyze episodes with current analytics again, e.g. after changing analytics.filler_words")
+	speechCmd.Flags().BoolVar(&speechTrend, "trend", false, "Print the weekly trend instead of the episodes")
+
+	Cmd.AddCommand(speechCmd)
+}
+
+var speechCmd = &cobra.Command{
+	Use:   "speech",
+	Short: "Show speech analytics of the episodes of a user",
+	Long: `Show speech analytics of the episodes of a user
+
+- Filler words per minute, the built-in ones such as um, uh and 嗯 plus analytics.filler_words of config.yaml
+- The longest monologue, a stretch one speaker talked without a pause of 3 seconds
+- Interruptions, turns starting while or right as the previous speaker talked, need diarized segments
+- New and re-transcribed episodes are analyzed and stored first, serve shows the same on /analytics/{user}
+- With --json, prints the episodes and the weekly trend like /api/v1/users/{user}/speech-analytics`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db := app.InitializeTranscriptionDAOForUser(speechUser)
+		defer db.Close()
+
+		stats, err := analytics.UpdateSpeech(db, analytics.SpeechOptions{
+			User:        speechUser,
+			FillerWords: config.Get().Analytics.FillerWords,
+			Force:       speechForce,
+		})
+		if errors.Is(err, analytics.ErrSpeechNotSupported) {
+			return errors.New(i18n.T("the configured database does not keep speech analytics"))
+		}
+		if err != nil {
+			return err
+		}
+		return output.Print(analytics.NewSpeechReport(stats), func(out io.Writer) error {
+			if len(stats) == 0 {
+				fmt.Fprint(out, i18n.T("%s has no transcriptions to analyze\n", speechUser))
+				return nil
+			}
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			if speechTrend {
+				trend := analytics.Trend(stats)
+				fillers := make([]float64, len(trend))
+				fmt.Fprintln(w, i18n.T("WEEK\tEPISODES\tFILLERS/MIN\tWORDS/MIN\tLONGEST MONOLOGUE(s)\tINTERRUPTIONS"))
+				for i, p := range trend {
+					fillers[i] = p.FillersPerMinute
+					fmt.Fprintf(w, "%s\t%d\t%.2f\t%.0f\t%.0f\t%.1f\n", p.Week.Format("2006-01-02"), p.Episodes,
+						p.FillersPerMinute, p.WordsPerMinute, p.LongestMonologue, p.Interruptions)
+				}
+				if err := w.Flush(); err != nil || !output.Decorate() {
+					return err
+				}
+				fmt.Fprint(out, i18n.T("Fillers per minute: %s\n", analytics.Sparkline(fillers)))
+				return nil
+			}
+
+			fmt.Fprintln(w, i18n.T("ID\tDATE\tFILE\tFILLERS/MIN\tWORDS/MIN\tLONGEST MONOLOGUE(s)\tSPEAKERS\tINTERRUPTIONS"))
+			for _, s := range stats {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%.2f\t%.0f\t%.0f\t%d\t%d\n", s.TranscriptionID, s.Date.Format("2006-01-02"),
+					s.FileName, s.FillersPerMinute(), s.WordsPerMinute(), s.LongestMonologue, s.Speakers, s.Interruptions)
+			}
+			return w.Flush()
+		})
+	},
+}
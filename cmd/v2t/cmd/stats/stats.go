@@ -0,0 +1,74 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/output"
+
+	"github.com/spf13/cobra"
+)
+
+var role string
+
+func init() {
+	Cmd.Flags().StringVarP(&role, "role", "r", "",
+		"Which analytics role to apply, roles and their privacy policies are defined in config.yaml")
+}
+
+// Cmd represents the stats command
+var Cmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show aggregated transcription statistics per user",
+	Long: `Show aggregated transcription statistics per user
+
+- Only aggregates are printed, never transcription text
+- Groups smaller than the role's min_group_size are folded into "(other)"
+- Roles with epsilon > 0 see counts with differential privacy noise`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		analyzer := app.InitializeAnalyzer()
+
+		report, err := analyzer.UserStats(role)
+		if err != nil {
+			return err
+		}
+
+		groups := make([]userStats, 0, len(report.Groups))
+		for _, s := range report.Groups {
+			groups = append(groups, userStats{User: s.User, Videos: s.VideoCount, AvgDuration: s.AvgAudioDuration(), TotalDuration: s.TotalAudioDuration})
+		}
+		result := statsReport{Role: report.Role, Users: groups, Suppressed: report.Suppressed}
+		return output.Print(result, func(out io.Writer) error {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, i18n.T("USER\tVIDEOS\tAVG DURATION(s)\tTOTAL DURATION(s)"))
+			for _, s := range report.Groups {
+				fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\n", s.User, s.VideoCount, s.AvgAudioDuration(), s.TotalAudioDuration)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			if report.Suppressed > 0 {
+				fmt.Fprint(out, i18n.T("%d small groups suppressed by role %q\n", report.Suppressed, report.Role))
+			}
+			return nil
+		})
+	},
+}
+
+// statsReport is the report printed by --json.
+type statsReport struct {
+	Role  string      `json:"role,omitempty"`
+	Users []userStats `json:"users"`
+	// Suppressed counts the small groups folded into "(other)" or dropped.
+	Suppressed int `json:"suppressed"`
+}
+
+type userStats struct {
+	User          string  `json:"user"`
+	Videos        int     `json:"videos"`
+	AvgDuration   float64 `json:"avg_duration_seconds"`
+	TotalDuration float64 `json:"total_duration_seconds"`
+}
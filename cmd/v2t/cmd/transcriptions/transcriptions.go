@@ -0,0 +1,158 @@
+package transcriptions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/repository"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user      string
+	limit     int
+	offset    int
+	sortBy    string
+	ascending bool
+
+	text      string
+	owner     string
+	fileName  string
+	sourceURL string
+)
+
+func init() {
+	Cmd.PersistentFlags().StringVarP(&user, "user", "u", "", "The user whose database holds the transcriptions (default database when empty)")
+
+	listCmd.Flags().IntVarP(&limit, "limit", "n", 20, "How many transcriptions to list, 0 lists all of them")
+	listCmd.Flags().IntVar(&offset, "offset", 0, "How many transcriptions to skip, for the next pages")
+	listCmd.Flags().StringVar(&sortBy, "sort", string(repository.SortByTime), "Sort by time, id, duration or file")
+	listCmd.Flags().BoolVar(&ascending, "asc", false, "Sort in ascending order, the default is descending")
+
+	updateCmd.Flags().StringVar(&text, "text", "", "The new text of the transcription")
+	updateCmd.Flags().StringVar(&owner, "set-user", "", "Assign the transcription to this user")
+	updateCmd.Flags().StringVar(&fileName, "file", "", "The new file name of the transcription")
+	updateCmd.Flags().StringVar(&sourceURL, "source-url", "", "The new source URL of the transcription")
+
+	Cmd.AddCommand(listCmd, updateCmd, deleteCmd)
+}
+
+// Cmd represents the transcriptions command
+var Cmd = &cobra.Command{
+	Use:   "transcriptions",
+	Short: "List, correct and delete stored transcriptions",
+	Long: `List, correct and delete stored transcriptions
+
+- list pages through the transcriptions of a user, sorted by time, id, duration or file
+- update overwrites the fields given as flags, use revisions to keep the previous text
+- delete removes transcriptions with their segments, revisions, translations and metadata`,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the transcriptions of a user page by page",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if user == "" {
+			return errors.New(i18n.T("--user is required to list transcriptions"))
+		}
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+
+		transcriptions, err := db.ListByUser(user, repository.ListOptions{
+			Limit:     limit,
+			Offset:    offset,
+			SortBy:    repository.SortField(sortBy),
+			Ascending: ascending,
+		})
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("ID\tCONVERTED\tDURATION\tFILE\tLENGTH"))
+		for _, t := range transcriptions {
+			fmt.Fprintf(w, "%d\t%s\t%.0f\t%s\t%d\n", t.ID, t.LastConversionTime.Format("2006-01-02 15:04:05"),
+				t.AudioDuration, t.Mp3FileName, len([]rune(t.Transcription)))
+		}
+		return w.Flush()
+	},
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update <id>",
+	Short: "Correct the fields of a transcription",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return errors.New(i18n.T("invalid transcription id %q", args[0]))
+		}
+		flags := cmd.Flags()
+		if !flags.Changed("text") && !flags.Changed("set-user") && !flags.Changed("file") && !flags.Changed("source-url") {
+			return errors.New(i18n.T("nothing to update, pass --text, --set-user, --file or --source-url"))
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+
+		t, err := db.GetByID(id)
+		if err != nil {
+			return errors.New(i18n.T("no transcription %d: %v", id, err))
+		}
+		if flags.Changed("text") {
+			t.Transcription = text
+		}
+		if flags.Changed("set-user") {
+			t.User = owner
+		}
+		if flags.Changed("file") {
+			t.Mp3FileName = fileName
+		}
+		if flags.Changed("source-url") {
+			t.SourceURL = sourceURL
+		}
+		if err = db.UpdateTranscription(*t); err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("transcription %d updated\n", id))
+		return nil
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <id>...",
+	Short: "Delete transcriptions with everything stored about them",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids := make([]int, 0, len(args))
+		for _, arg := range args {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				return errors.New(i18n.T("invalid transcription id %q", arg))
+			}
+			ids = append(ids, id)
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+
+		var failed []string
+		for _, id := range ids {
+			if err := db.DeleteTranscription(id); err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			fmt.Print(i18n.T("transcription %d deleted\n", id))
+		}
+		if len(failed) > 0 {
+			return errors.New(strings.Join(failed, "; "))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,109 @@
+package translate
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/review"
+	"tiktok-whisper/internal/app/translation"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user     string
+	to       string
+	backend  string
+	force    bool
+	segments bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&user, "user", "u", "default", "Whose transcriptions to translate")
+	Cmd.Flags().StringVarP(&to, "to", "t", "", "Target language code, e.g. en or zh")
+	Cmd.Flags().StringVarP(&backend, "backend", "b", "", "Translation backend: "+strings.Join(translation.Backends, ", ")+" (default is translation.backend in config.yaml, else openai)")
+	Cmd.Flags().BoolVar(&force, "force", false, "Translate transcriptions that already have a translation into the language again")
+	Cmd.Flags().BoolVar(&segments, "segments", false, "Translate segment by segment for the review page of serve, only segments without a current translation")
+
+	Cmd.MarkFlagRequired("to")
+}
+
+// Cmd represents the translate command
+var Cmd = &cobra.Command{
+	Use:   "translate",
+	Short: "Translate the stored transcriptions of a user into another language",
+	Long: `Translate the stored transcriptions of a user into another language
+
+- Backends are openai, gemini and deepl, their keys are read from OPENAI_API_KEY, GEMINI_API_KEY and DEEPL_AUTH_KEY, or llm for the language model of llm in config.yaml
+- Translations are stored in the database next to the transcriptions, one per language
+- Transcriptions already translated into the language are skipped unless --force is set
+- With --segments, each segment is translated on its own for the language-learning review of serve`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get().Translation
+		if backend != "" {
+			cfg.Backend = backend
+		}
+		translator, err := translation.New(cfg)
+		if err != nil {
+			return err
+		}
+
+		db := app.InitializeTranscriptionDAOForUser(user)
+		defer db.Close()
+		if segments {
+			return translateSegments(db, translator)
+		}
+		dao, ok := db.(repository.TranslationDAO)
+		if !ok {
+			return errors.New(i18n.T("the configured database does not keep translations"))
+		}
+
+		result, err := translation.Transcriptions(db, dao, translator, translation.Options{User: user, Language: to, Force: force})
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("%d translated, %d skipped, %d failed\n", result.Translated, result.Skipped, result.Failed))
+		if result.Failed > 0 {
+			return errors.New(i18n.T("%d transcriptions failed to translate", result.Failed))
+		}
+		return nil
+	},
+}
+
+// translateSegments translates the segments of every transcription of the user for the review.
+func translateSegments(db repository.TranscriptionDAO, translator translation.Translator) error {
+	transcriptions, err := db.GetAllByUser(user)
+	if err != nil {
+		return err
+	}
+
+	var translated, failed int
+	for _, t := range transcriptions {
+		if strings.TrimSpace(t.Transcription) == "" {
+			continue
+		}
+		if err = export.LoadSegments(db, &t); err != nil {
+			return err
+		}
+		n, err := review.Translate(db, translator, t, to)
+		translated += n
+		if errors.Is(err, review.ErrNotSupported) {
+			return errors.New(i18n.T("the configured database does not keep translations"))
+		}
+		if err != nil {
+			log.Printf("Translating the segments of %d (%s) failed: %v\n", t.ID, t.Mp3FileName, err)
+			failed++
+		}
+	}
+	fmt.Print(i18n.T("%d segments translated, %d transcriptions failed\n", translated, failed))
+	if failed > 0 {
+		return errors.New(i18n.T("%d transcriptions failed to translate", failed))
+	}
+	return nil
+}
@@ -18,6 +18,11 @@ var Cmd = &cobra.Command{
 	},
 }
 
+// Version returns the version of v2t.
+func Version() string {
+	return version
+}
+
 func printVersion() {
 	fmt.Println(version)
 }
@@ -0,0 +1,127 @@
+package vocab
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/vocab"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	Cmd.AddCommand(listCmd, installCmd, removeCmd, showCmd)
+}
+
+// Cmd represents the vocab command
+var Cmd = &cobra.Command{
+	Use:   "vocab",
+	Short: "Manage the vocabulary packs that bias and correct the transcriptions",
+	Long: `Manage the vocabulary packs that bias and correct the transcriptions
+
+- A pack lists domain terms with their preferred spelling, the variants to correct and optional expansions
+- Installed packs add their terms to the prompt of whisper.cpp and OpenAI and correct the variants after transcription
+- Packs are yaml files installed in the vocab directory next to config.yaml, v2t ships medical-zh, medical-en and tech-en`,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the built-in and installed packs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		installed, err := vocab.Load(vocab.Dir())
+		if err != nil {
+			return err
+		}
+
+		packs := map[string]vocab.Pack{}
+		var names []string
+		for _, p := range append(vocab.Builtin(), installed...) {
+			if _, ok := packs[p.Name]; !ok {
+				names = append(names, p.Name)
+			}
+			packs[p.Name] = p
+		}
+		isInstalled := map[string]bool{}
+		for _, p := range installed {
+			isInstalled[p.Name] = true
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("NAME\tLANGUAGE\tTERMS\tSTATUS\tDESCRIPTION"))
+		for _, name := range names {
+			p := packs[name]
+			status := i18n.T("available")
+			if isInstalled[name] {
+				status = i18n.T("installed")
+			}
+			language := p.Language
+			if language == "" {
+				language = i18n.T("all")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", p.Name, language, len(p.Terms), status, p.Description)
+		}
+		return w.Flush()
+	},
+}
+
+var installCmd = &cobra.Command{
+	Use:   "install <pack|file.yaml>",
+	Short: "Install a built-in pack by name or a pack file, replacing the installed pack of the same name",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := vocab.Install(vocab.Dir(), args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("Installed %s with %d terms\n", p.Name, len(p.Terms)))
+		return nil
+	},
+}
+
+var removeCmd = &cobra.Command{
+	Use:   "remove <pack>",
+	Short: "Remove an installed pack",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := vocab.Remove(vocab.Dir(), args[0]); errors.Is(err, vocab.ErrNotInstalled) {
+			return errors.New(i18n.T("pack %s is not installed", args[0]))
+		} else if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T("Removed %s\n", args[0]))
+		return nil
+	},
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show <pack>",
+	Short: "Print the terms of a pack, the installed one when it is installed",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		installed, err := vocab.Load(vocab.Dir())
+		if err != nil {
+			return err
+		}
+
+		var pack *vocab.Pack
+		for _, p := range append(vocab.Builtin(), installed...) {
+			if p.Name == args[0] {
+				p := p
+				pack = &p
+			}
+		}
+		if pack == nil {
+			return errors.New(i18n.T("no pack named %s", args[0]))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, i18n.T("TERM\tEXPANSION\tVARIANTS"))
+		for _, t := range pack.Terms {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Term, t.Expansion, strings.Join(t.Variants, ", "))
+		}
+		return w.Flush()
+	},
+}
@@ -0,0 +1,95 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/api/middleware"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/vocab"
+	"tiktok-whisper/internal/app/watch"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	user            string
+	audio           bool
+	fileExtensions  string
+	outputDirectory string
+	existing        bool
+	settle          time.Duration
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&user, "user", "u", "default", "Which user owns the videos, their transcriptions are stored in this user's database")
+	Cmd.Flags().BoolVarP(&audio, "audio", "a", false, "Convert audio files, whose text is written to --outputDirectory, instead of videos")
+	Cmd.Flags().StringVarP(&fileExtensions, "type", "t", "", "Extensions of the files to convert separated by commas, mp4 for videos and mp3 for audio by default")
+	Cmd.Flags().StringVarP(&outputDirectory, "outputDirectory", "o", "./data/transcription", "Where the text files of audio files are written")
+	Cmd.Flags().BoolVar(&existing, "existing", false, "Also convert the files already in the directory, those converted before are skipped")
+	Cmd.Flags().DurationVar(&settle, "settle", watch.DefaultSettle, "How long a new file has to stay unchanged before it is converted")
+}
+
+// Cmd represents the watch command
+var Cmd = &cobra.Command{
+	Use:   "watch <dir>",
+	Short: "Convert the files dropped into a directory",
+	Long: `Convert the files dropped into a directory
+
+- New files are converted once they stopped changing, files still being copied are left alone
+- Videos are stored in the database of --user, the text of audio files is written to --outputDirectory
+- Files converted before are skipped, a failed file is logged and the watch goes on
+- Runs until interrupted, the file being converted still finishes`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return errors.New(i18n.T("%s is not a directory", dir))
+		}
+
+		kind := model.BatchVideo
+		if audio {
+			kind = model.BatchAudio
+		}
+		if fileExtensions == "" {
+			fileExtensions = "mp4"
+			if audio {
+				fileExtensions = "mp3"
+			}
+		}
+		absOutput, err := filepath.Abs(outputDirectory)
+		if err != nil {
+			return err
+		}
+
+		mws, err := middleware.FromConfig(config.Get().Middlewares)
+		if err != nil {
+			return errors.New(i18n.T("Invalid middlewares in config.yaml: %v", err))
+		}
+		if packs := vocab.Installed(); len(packs) > 0 {
+			mws = append([]middleware.Middleware{middleware.Vocabulary(packs)}, mws...)
+		}
+
+		c := app.InitializeConverter(user)
+		defer c.Close()
+		c.SweepTempFiles()
+		c.Use(mws...)
+		c.TrackCosts(config.Get().Cost)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		opts := watch.Options{Extensions: strings.Split(fileExtensions, ","), Settle: settle, Existing: existing}
+		return watch.Watch(ctx, dir, opts, func(path string) error {
+			return c.ConvertFile(kind, path, user, absOutput)
+		})
+	},
+}
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	v2tv1 "tiktok-whisper/api/proto/v2t/v1"
+	"tiktok-whisper/internal/app/model"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeTranscriber struct{}
+
+func (fakeTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := fakeTranscriber{}.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (fakeTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	audio, err := os.ReadFile(inputFilePath)
+	if err != nil {
+		return "", model.ProviderMetadata{}, err
+	}
+	if strings.HasPrefix(filepath.Base(inputFilePath), "broken") {
+		return "", model.ProviderMetadata{Provider: "fake"}, errors.New("decoder failed")
+	}
+	return "heard " + string(audio), model.ProviderMetadata{
+		Provider: "fake",
+		Model:    "large",
+		Segments: []model.Segment{{Start: 0, End: 1.5, Text: "heard", Words: []model.Word{{Start: 0, End: 1.5, Text: "heard", Probability: 0.9}}}},
+	}, nil
+}
+
+func newTestClient(t *testing.T, pool *Pool) v2tv1.AgentServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	v2tv1.RegisterAgentServiceServer(srv, pool)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return v2tv1.NewAgentServiceClient(conn)
+}
+
+func writeAudio(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPool_Worker(t *testing.T) {
+	pool := NewPool(PoolOptions{PollTime: 50 * time.Millisecond, QueueTimeout: 5 * time.Second, TaskTimeout: 5 * time.Second})
+	worker := NewWorker(newTestClient(t, pool), fakeTranscriber{}, WorkerOptions{Name: "gpu-1", Providers: []string{"fake"}, Capacity: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- worker.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-stopped; err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	}()
+
+	tests := []struct {
+		name     string
+		fileName string
+		wantText string
+		wantErr  string
+	}{
+		{name: "done", fileName: "talk.mp3", wantText: "heard audio"},
+		{name: "failed", fileName: "broken.mp3", wantErr: "decoder failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, metadata, err := pool.TranscriptWithMetadata(writeAudio(t, tt.fileName, "audio"))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("TranscriptWithMetadata() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != tt.wantText || metadata.Provider != "fake" || metadata.Model != "large" {
+				t.Errorf("TranscriptWithMetadata() = %q, %+v", text, metadata)
+			}
+			if len(metadata.Segments) != 1 || len(metadata.Segments[0].Words) != 1 || metadata.Segments[0].Words[0].Probability != 0.9 {
+				t.Errorf("TranscriptWithMetadata() segments = %+v", metadata.Segments)
+			}
+		})
+	}
+
+	agents := pool.Agents()
+	if len(agents) != 1 || agents[0].Name != "gpu-1" || agents[0].Capacity != 2 || agents[0].Providers[0] != "fake" || agents[0].Running != 0 {
+		t.Errorf("Agents() = %+v, want gpu-1 with capacity 2 and nothing running", agents)
+	}
+}
+
+func TestPool_Timeouts(t *testing.T) {
+	pool := NewPool(PoolOptions{PollTime: 5 * time.Second, QueueTimeout: 50 * time.Millisecond, TaskTimeout: 50 * time.Millisecond})
+	client := newTestClient(t, pool)
+	ctx := context.Background()
+
+	if _, err := pool.Transcript(writeAudio(t, "talk.mp3", "audio")); err == nil || !strings.Contains(err.Error(), "no agent pulled") {
+		t.Errorf("Transcript() without agents error = %v, want no agent pulled", err)
+	}
+	if _, err := client.PullTask(ctx, &v2tv1.PullTaskRequest{AgentId: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("PullTask() of an unknown agent error = %v, want NotFound", err)
+	}
+
+	registered, err := client.Register(ctx, &v2tv1.RegisterRequest{Name: "gpu-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pulled := make(chan *v2tv1.Task, 1)
+	go func() {
+		resp, err := client.PullTask(ctx, &v2tv1.PullTaskRequest{AgentId: registered.AgentId})
+		if err != nil {
+			t.Error(err)
+		}
+		pulled <- resp.GetTask()
+	}()
+	// The agent pulls the task but never completes it
+	pool.opts.QueueTimeout = 5 * time.Second
+	if _, err = pool.Transcript(writeAudio(t, "talk.mp3", "audio")); err == nil || !strings.Contains(err.Error(), "didn't complete") {
+		t.Fatalf("Transcript() error = %v, want didn't complete", err)
+	}
+
+	task := <-pulled
+	if _, err = client.CompleteTask(ctx, &v2tv1.CompleteTaskRequest{AgentId: registered.AgentId, TaskId: task.Id, Text: "late"}); status.Code(err) != codes.NotFound {
+		t.Errorf("CompleteTask() of a timed out task error = %v, want NotFound", err)
+	}
+}
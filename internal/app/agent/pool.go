@@ -0,0 +1,329 @@
+// Package agent offloads transcription to GPU machines. A Pool on the server hands the audio of its
+// jobs to the agents registered over gRPC, a Worker on each machine pulls the tasks and transcribes
+// them with its own providers. Only the agents connect, so machines behind NAT or a firewall work
+// without any provider configuration on the server.
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	v2tv1 "tiktok-whisper/api/proto/v2t/v1"
+	"tiktok-whisper/internal/app/model"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultPollTime is how long PullTask waits for a task before answering without one.
+	DefaultPollTime = 30 * time.Second
+	// DefaultQueueTimeout is how long a task waits for an agent to pull it.
+	DefaultQueueTimeout = 10 * time.Minute
+	// DefaultTaskTimeout is how long an agent may take for a pulled task.
+	DefaultTaskTimeout = 2 * time.Hour
+)
+
+// PoolOptions tune a Pool, zero values take the defaults.
+type PoolOptions struct {
+	PollTime     time.Duration
+	QueueTimeout time.Duration
+	TaskTimeout  time.Duration
+}
+
+// Info describes a registered agent.
+type Info struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Providers []string `json:"providers"`
+	Capacity  int      `json:"capacity"`
+	// Running is the number of tasks the agent pulled and hasn't completed yet.
+	Running      int       `json:"running"`
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+type result struct {
+	text     string
+	metadata model.ProviderMetadata
+	err      error
+}
+
+type task struct {
+	id    string
+	path  string
+	agent string
+	// result receives the outcome exactly once, from whoever removed the task from the running ones.
+	result chan result
+}
+
+// Pool is the transcriber of a server whose jobs run on agents, it also serves the AgentService
+// of api/proto/v2t/v1 the agents talk to.
+type Pool struct {
+	v2tv1.UnimplementedAgentServiceServer
+
+	opts PoolOptions
+	now  func() time.Time
+	// pending hands a task to exactly one waiting PullTask, tasks never sit in a buffer nobody pulls from.
+	pending chan *task
+
+	mu      sync.Mutex
+	agents  map[string]*Info
+	running map[string]*task
+}
+
+// NewPool creates a Pool without agents.
+func NewPool(opts PoolOptions) *Pool {
+	if opts.PollTime <= 0 {
+		opts.PollTime = DefaultPollTime
+	}
+	if opts.QueueTimeout <= 0 {
+		opts.QueueTimeout = DefaultQueueTimeout
+	}
+	if opts.TaskTimeout <= 0 {
+		opts.TaskTimeout = DefaultTaskTimeout
+	}
+	return &Pool{
+		opts:    opts,
+		now:     time.Now,
+		pending: make(chan *task),
+		agents:  make(map[string]*Info),
+		running: make(map[string]*task),
+	}
+}
+
+// Transcript transcribes the file on the next agent asking for a task.
+func (p *Pool) Transcript(inputFilePath string) (string, error) {
+	text, _, err := p.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata transcribes the file on the next agent asking for a task, it fails when no
+// agent pulls it within the queue timeout or the agent doesn't complete it within the task timeout.
+func (p *Pool) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	t := &task{id: newID(), path: inputFilePath, result: make(chan result, 1)}
+
+	queued := time.NewTimer(p.opts.QueueTimeout)
+	defer queued.Stop()
+	select {
+	case p.pending <- t:
+	case <-queued.C:
+		return "", model.ProviderMetadata{}, fmt.Errorf("no agent pulled %s within %v", filepath.Base(inputFilePath), p.opts.QueueTimeout)
+	}
+
+	running := time.NewTimer(p.opts.TaskTimeout)
+	defer running.Stop()
+	select {
+	case r := <-t.result:
+		return r.text, r.metadata, r.err
+	case <-running.C:
+	}
+	if !p.abandon(t.id) {
+		// The agent completed the task just now
+		r := <-t.result
+		return r.text, r.metadata, r.err
+	}
+	return "", model.ProviderMetadata{}, fmt.Errorf("agent didn't complete %s within %v", filepath.Base(inputFilePath), p.opts.TaskTimeout)
+}
+
+// Agents returns the registered agents ordered by name, agents gone for a while are forgotten.
+func (p *Pool) Agents() []Info {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.forgetGone()
+
+	agents := make([]Info, 0, len(p.agents))
+	for _, a := range p.agents {
+		info := *a
+		info.Providers = append([]string(nil), a.Providers...)
+		agents = append(agents, info)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		if agents[i].Name != agents[j].Name {
+			return agents[i].Name < agents[j].Name
+		}
+		return agents[i].ID < agents[j].ID
+	})
+	return agents
+}
+
+// forgetGone removes the idle agents that haven't asked for a task for a few poll times, it must be
+// called under the lock. An agent that was only slow is told to register again by its next pull.
+func (p *Pool) forgetGone() {
+	for id, a := range p.agents {
+		if a.Running == 0 && p.now().Sub(a.LastSeen) > 3*p.opts.PollTime {
+			delete(p.agents, id)
+		}
+	}
+}
+
+func (p *Pool) Register(ctx context.Context, req *v2tv1.RegisterRequest) (*v2tv1.RegisterResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	capacity := int(req.Capacity)
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.forgetGone()
+
+	now := p.now()
+	info := &Info{
+		ID:           newID(),
+		Name:         req.Name,
+		Providers:    append([]string(nil), req.Providers...),
+		Capacity:     capacity,
+		RegisteredAt: now,
+		LastSeen:     now,
+	}
+	p.agents[info.ID] = info
+	return &v2tv1.RegisterResponse{AgentId: info.ID}, nil
+}
+
+func (p *Pool) PullTask(ctx context.Context, req *v2tv1.PullTaskRequest) (*v2tv1.PullTaskResponse, error) {
+	if !p.seen(req.AgentId) {
+		return nil, status.Error(codes.NotFound, "unknown agent, register again")
+	}
+
+	timer := time.NewTimer(p.opts.PollTime)
+	defer timer.Stop()
+	select {
+	case t := <-p.pending:
+		audio, err := os.ReadFile(t.path)
+		if err != nil {
+			t.result <- result{err: fmt.Errorf("read audio failed: %v", err)}
+			return &v2tv1.PullTaskResponse{}, nil
+		}
+		if !p.start(req.AgentId, t) {
+			t.result <- result{err: errors.New("agent was forgotten while pulling the task")}
+			return nil, status.Error(codes.NotFound, "unknown agent, register again")
+		}
+		return &v2tv1.PullTaskResponse{Task: &v2tv1.Task{Id: t.id, FileName: filepath.Base(t.path), Audio: audio}}, nil
+	case <-timer.C:
+		p.seen(req.AgentId)
+		return &v2tv1.PullTaskResponse{}, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (p *Pool) CompleteTask(ctx context.Context, req *v2tv1.CompleteTaskRequest) (*v2tv1.CompleteTaskResponse, error) {
+	var metadata model.ProviderMetadata
+	if req.MetadataJson != "" {
+		if err := json.Unmarshal([]byte(req.MetadataJson), &metadata); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "parse metadata_json failed: %v", err)
+		}
+	}
+	metadata.Segments = segmentsFromProto(req.Segments)
+
+	t := p.finish(req.AgentId, req.TaskId)
+	if t == nil {
+		return nil, status.Error(codes.NotFound, "unknown task, it may have timed out")
+	}
+	r := result{text: req.Text, metadata: metadata}
+	if req.Error != "" {
+		r.err = errors.New(req.Error)
+	}
+	t.result <- r
+	return &v2tv1.CompleteTaskResponse{}, nil
+}
+
+// seen records that the agent asked for a task, it reports false for unknown agents.
+func (p *Pool) seen(agentID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	a, ok := p.agents[agentID]
+	if ok {
+		a.LastSeen = p.now()
+	}
+	return ok
+}
+
+// start assigns t to the agent, it reports false when the agent was forgotten meanwhile.
+func (p *Pool) start(agentID string, t *task) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	a, ok := p.agents[agentID]
+	if !ok {
+		return false
+	}
+	a.Running++
+	a.LastSeen = p.now()
+	t.agent = agentID
+	p.running[t.id] = t
+	return true
+}
+
+// finish removes the running task of the agent and returns it, nil when there is no such task.
+func (p *Pool) finish(agentID string, taskID string) *task {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t, ok := p.running[taskID]
+	if !ok || t.agent != agentID {
+		return nil
+	}
+	delete(p.running, taskID)
+	if a, ok := p.agents[agentID]; ok {
+		a.Running--
+		a.LastSeen = p.now()
+	}
+	return t
+}
+
+// abandon removes a running task that took too long, it reports false when it wasn't running anymore.
+func (p *Pool) abandon(taskID string) bool {
+	p.mu.Lock()
+	t, ok := p.running[taskID]
+	p.mu.Unlock()
+	return ok && p.finish(t.agent, taskID) != nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms, the time keeps ids unique regardless
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func segmentsToProto(segments []model.Segment) []*v2tv1.Segment {
+	pb := make([]*v2tv1.Segment, 0, len(segments))
+	for _, s := range segments {
+		segment := &v2tv1.Segment{Start: s.Start, End: s.End, Speaker: s.Speaker, Text: s.Text}
+		for _, w := range s.Words {
+			segment.Words = append(segment.Words, &v2tv1.Word{Start: w.Start, End: w.End, Text: w.Text, Probability: w.Probability})
+		}
+		pb = append(pb, segment)
+	}
+	return pb
+}
+
+func segmentsFromProto(pb []*v2tv1.Segment) []model.Segment {
+	if len(pb) == 0 {
+		return nil
+	}
+	segments := make([]model.Segment, 0, len(pb))
+	for _, s := range pb {
+		segment := model.Segment{Start: s.Start, End: s.End, Speaker: s.Speaker, Text: s.Text}
+		for _, w := range s.Words {
+			segment.Words = append(segment.Words, model.Word{Start: w.Start, End: w.End, Text: w.Text, Probability: w.Probability})
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	v2tv1 "tiktok-whisper/api/proto/v2t/v1"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/model"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRetryDelay is how long a Worker waits before asking a server again that didn't answer.
+const DefaultRetryDelay = 5 * time.Second
+
+// WorkerOptions configure a Worker.
+type WorkerOptions struct {
+	// Name of the agent in the agent list of the server.
+	Name string
+	// Providers are advertised to the server, they are informational only.
+	Providers []string
+	// Capacity is the number of tasks transcribed at the same time.
+	Capacity int
+	// TempDir keeps the audio of the running tasks, the system temp dir when empty.
+	TempDir    string
+	RetryDelay time.Duration
+}
+
+// Worker registers with a server and transcribes the tasks it pulls from it.
+type Worker struct {
+	client      v2tv1.AgentServiceClient
+	transcriber api.Transcriber
+	opts        WorkerOptions
+
+	mu      sync.Mutex
+	agentID string
+}
+
+// NewWorker creates a Worker pulling from the server of client.
+func NewWorker(client v2tv1.AgentServiceClient, transcriber api.Transcriber, opts WorkerOptions) *Worker {
+	if opts.Capacity < 1 {
+		opts.Capacity = 1
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	return &Worker{client: client, transcriber: transcriber, opts: opts}
+}
+
+// Run pulls and transcribes tasks until ctx is done, the running tasks still finish and are reported.
+// A server that is down or restarted is retried and registered with again.
+func (w *Worker) Run(ctx context.Context) error {
+	if err := w.register(ctx, ""); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Capacity; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.pull(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// register registers the agent unless another pull loop already replaced the stale id, it retries
+// until the server answers or ctx is done.
+func (w *Worker) register(ctx context.Context, stale string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.agentID != stale {
+		return nil
+	}
+	for {
+		resp, err := w.client.Register(ctx, &v2tv1.RegisterRequest{
+			Name:      w.opts.Name,
+			Providers: w.opts.Providers,
+			Capacity:  int32(w.opts.Capacity),
+		})
+		if err == nil {
+			w.agentID = resp.AgentId
+			log.Printf("Registered as agent %s with capacity %d\n", w.agentID, w.opts.Capacity)
+			return nil
+		}
+		if status.Code(err) == codes.InvalidArgument {
+			return fmt.Errorf("register failed: %v", err)
+		}
+		log.Printf("Register failed, retrying in %v: %v\n", w.opts.RetryDelay, err)
+		if !sleep(ctx, w.opts.RetryDelay) {
+			return ctx.Err()
+		}
+	}
+}
+
+func (w *Worker) id() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.agentID
+}
+
+func (w *Worker) pull(ctx context.Context) {
+	for ctx.Err() == nil {
+		id := w.id()
+		resp, err := w.client.PullTask(ctx, &v2tv1.PullTaskRequest{AgentId: id})
+		switch {
+		case status.Code(err) == codes.NotFound:
+			log.Println("The server forgot the agent, registering again")
+			if w.register(ctx, id) != nil {
+				return
+			}
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Pull task failed, retrying in %v: %v\n", w.opts.RetryDelay, err)
+			sleep(ctx, w.opts.RetryDelay)
+		case resp.Task != nil:
+			w.run(id, resp.Task)
+		}
+	}
+}
+
+// run transcribes a task and reports the result, even when the worker is stopping meanwhile.
+func (w *Worker) run(agentID string, task *v2tv1.Task) {
+	log.Printf("Transcribing task %s: %s\n", task.Id, task.FileName)
+	text, metadata, err := w.transcribe(task)
+
+	req := &v2tv1.CompleteTaskRequest{AgentId: agentID, TaskId: task.Id, Text: text, Segments: segmentsToProto(metadata.Segments)}
+	if err != nil {
+		req.Error = err.Error()
+		log.Printf("Task %s failed: %v\n", task.Id, err)
+	}
+	if b, err := json.Marshal(metadata); err == nil {
+		req.MetadataJson = string(b)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err = w.client.CompleteTask(ctx, req); err != nil {
+		log.Printf("Report task %s failed: %v\n", task.Id, err)
+	}
+}
+
+func (w *Worker) transcribe(task *v2tv1.Task) (string, model.ProviderMetadata, error) {
+	dir, err := os.MkdirTemp(w.opts.TempDir, "v2t-agent-")
+	if err != nil {
+		return "", model.ProviderMetadata{}, fmt.Errorf("create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, filepath.Base(task.FileName))
+	if err = os.WriteFile(path, task.Audio, 0644); err != nil {
+		return "", model.ProviderMetadata{}, fmt.Errorf("write audio failed: %v", err)
+	}
+	if mt, ok := w.transcriber.(api.MetadataTranscriber); ok {
+		return mt.TranscriptWithMetadata(path)
+	}
+	text, err := w.transcriber.Transcript(path)
+	return text, model.ProviderMetadata{}, err
+}
+
+// sleep waits for d, it reports false when ctx was done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,127 @@
+package analytics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+// OtherGroup labels the bucket collecting every group below the minimum group size.
+const OtherGroup = "(other)"
+
+// Report is the privacy filtered result of an analytics query, it never contains transcription text.
+type Report struct {
+	Role   string
+	Groups []model.UserStats
+	// Suppressed counts the groups that were folded into OtherGroup or dropped.
+	Suppressed int
+}
+
+// Analyzer serves aggregated statistics according to the policy of the caller's role.
+type Analyzer struct {
+	db  repository.TranscriptionDAO
+	cfg config.AnalyticsConfig
+	rnd *rand.Rand
+}
+
+// NewAnalyzer creates a new Analyzer instance.
+func NewAnalyzer(db repository.TranscriptionDAO, cfg config.AnalyticsConfig) *Analyzer {
+	return &Analyzer{
+		db:  db,
+		cfg: cfg,
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Policy returns the policy of role, an empty role means the configured default role.
+func (a *Analyzer) Policy(role string) (string, config.AnalyticsPolicy, error) {
+	if role == "" {
+		role = a.cfg.DefaultRole
+	}
+
+	policy, ok := a.cfg.Roles[role]
+	if !ok {
+		return role, config.AnalyticsPolicy{}, fmt.Errorf("unknown analytics role: %s", role)
+	}
+	return role, policy, nil
+}
+
+// UserStats returns per user statistics filtered by the policy of role.
+func (a *Analyzer) UserStats(role string) (*Report, error) {
+	role, policy, err := a.Policy(role)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := a.db.GetUserStats()
+	if err != nil {
+		return nil, fmt.Errorf("get user stats failed: %v", err)
+	}
+
+	report := Apply(stats, policy, a.rnd)
+	report.Role = role
+	return &report, nil
+}
+
+// Apply enforces policy on stats: groups below MinGroupSize are folded into OtherGroup
+// (or dropped when even the folded group is too small), counts get Laplace noise when
+// Epsilon is set and user names are replaced by stable labels when HideUserNames is set.
+func Apply(stats []model.UserStats, policy config.AnalyticsPolicy, rnd *rand.Rand) Report {
+	report := Report{Groups: make([]model.UserStats, 0, len(stats))}
+	other := model.UserStats{User: OtherGroup}
+
+	for _, s := range stats {
+		if s.VideoCount < policy.MinGroupSize {
+			other.VideoCount += s.VideoCount
+			other.TotalAudioDuration += s.TotalAudioDuration
+			report.Suppressed++
+			continue
+		}
+
+		if policy.HideUserNames {
+			s.User = anonymize(s.User)
+		}
+		report.Groups = append(report.Groups, s)
+	}
+
+	if other.VideoCount > 0 && other.VideoCount >= policy.MinGroupSize {
+		report.Groups = append(report.Groups, other)
+	}
+
+	if policy.Epsilon > 0 {
+		for i := range report.Groups {
+			report.Groups[i] = addNoise(report.Groups[i], policy.Epsilon, rnd)
+		}
+	}
+
+	return report
+}
+
+// addNoise perturbs the count with Laplace noise of scale 1/epsilon, the total duration
+// is rescaled so the average stays meaningful.
+func addNoise(s model.UserStats, epsilon float64, rnd *rand.Rand) model.UserStats {
+	avg := s.AvgAudioDuration()
+
+	u := rnd.Float64() - 0.5
+	noise := -(1 / epsilon) * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+
+	count := int(math.Round(float64(s.VideoCount) + noise))
+	if count < 0 {
+		count = 0
+	}
+
+	s.VideoCount = count
+	s.TotalAudioDuration = avg * float64(count)
+	return s
+}
+
+func anonymize(user string) string {
+	sum := sha256.Sum256([]byte(user))
+	return "user-" + hex.EncodeToString(sum[:4])
+}
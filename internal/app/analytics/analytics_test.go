@@ -0,0 +1,78 @@
+package analytics
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestApply(t *testing.T) {
+	stats := []model.UserStats{
+		{User: "alice", VideoCount: 12, TotalAudioDuration: 1200},
+		{User: "bob", VideoCount: 3, TotalAudioDuration: 30},
+		{User: "carol", VideoCount: 4, TotalAudioDuration: 80},
+	}
+
+	tests := []struct {
+		name           string
+		policy         config.AnalyticsPolicy
+		want           []model.UserStats
+		wantSuppressed int
+	}{
+		{
+			name:   "admin_sees_everything",
+			policy: config.AnalyticsPolicy{MinGroupSize: 1},
+			want:   stats,
+		},
+		{
+			name:   "small_groups_are_folded",
+			policy: config.AnalyticsPolicy{MinGroupSize: 5},
+			want: []model.UserStats{
+				{User: "alice", VideoCount: 12, TotalAudioDuration: 1200},
+				{User: OtherGroup, VideoCount: 7, TotalAudioDuration: 110},
+			},
+			wantSuppressed: 2,
+		},
+		{
+			name:   "folded_group_too_small_is_dropped",
+			policy: config.AnalyticsPolicy{MinGroupSize: 10},
+			want: []model.UserStats{
+				{User: "alice", VideoCount: 12, TotalAudioDuration: 1200},
+			},
+			wantSuppressed: 2,
+		},
+		{
+			name:   "user_names_are_hidden",
+			policy: config.AnalyticsPolicy{MinGroupSize: 10, HideUserNames: true},
+			want: []model.UserStats{
+				{User: anonymize("alice"), VideoCount: 12, TotalAudioDuration: 1200},
+			},
+			wantSuppressed: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Apply(stats, tt.policy, rand.New(rand.NewSource(1)))
+			if !reflect.DeepEqual(got.Groups, tt.want) {
+				t.Errorf("Apply() groups = %v, want %v", got.Groups, tt.want)
+			}
+			if got.Suppressed != tt.wantSuppressed {
+				t.Errorf("Apply() suppressed = %v, want %v", got.Suppressed, tt.wantSuppressed)
+			}
+		})
+	}
+}
+
+func TestApplyNoiseKeepsAverage(t *testing.T) {
+	stats := []model.UserStats{{User: "alice", VideoCount: 100, TotalAudioDuration: 5000}}
+	got := Apply(stats, config.AnalyticsPolicy{MinGroupSize: 1, Epsilon: 0.5}, rand.New(rand.NewSource(42)))
+
+	if len(got.Groups) != 1 {
+		t.Fatalf("Apply() returned %d groups, want 1", len(got.Groups))
+	}
+	if got.Groups[0].VideoCount > 0 && got.Groups[0].AvgAudioDuration() != 50 {
+		t.Errorf("Apply() avg = %v, want 50", got.Groups[0].AvgAudioDuration())
+	}
+}
@@ -0,0 +1,261 @@
+package analytics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/textdiff"
+	"time"
+)
+
+// DefaultDuplicateThreshold is the similarity from which a transcription is a duplicate unless
+// another threshold is given.
+const DefaultDuplicateThreshold = 0.95
+
+// shingleSize is the number of words of a shingle, the overlapping word sequences texts are
+// compared by.
+const shingleSize = 3
+
+// ErrDuplicatesNotSupported is returned when the database doesn't keep duplicates.
+var ErrDuplicatesNotSupported = errors.New("the database does not keep duplicates")
+
+// DuplicateOptions select the transcriptions FindDuplicates compares.
+type DuplicateOptions struct {
+	User string
+	// Threshold from 0 to 1, DefaultDuplicateThreshold when zero.
+	Threshold float64
+}
+
+// candidate is a transcription prepared for the comparisons.
+type candidate struct {
+	t model.Transcription
+	// vector is the normalized embedding of the index, nil when there is none or it is stale
+	vector []float64
+	// shingles are the sorted hashes of the word shingles
+	shingles []uint64
+}
+
+// FindDuplicates compares every transcription of the user with the earlier ones, by the cosine
+// similarity of their embeddings in the vector index when the database keeps them, and by the
+// Jaccard similarity of the word shingles of their transcripts. A transcription at least as similar
+// as the threshold to an earlier one by either measure is a duplicate of it. Earlier is a lower ID,
+// so the first upload is the original. The duplicates are returned the most similar first.
+func FindDuplicates(db repository.TranscriptionDAO, opts DuplicateOptions, now time.Time) ([]model.Duplicate, error) {
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = DefaultDuplicateThreshold
+	}
+	transcriptions, err := db.GetAllByUser(opts.User)
+	if err != nil {
+		return nil, fmt.Errorf("get transcriptions of %s failed: %v", opts.User, err)
+	}
+	sort.Slice(transcriptions, func(i, j int) bool { return transcriptions[i].ID < transcriptions[j].ID })
+
+	vectors, err := indexVectors(db, transcriptions)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]candidate, 0, len(transcriptions))
+	for _, t := range transcriptions {
+		c := candidate{t: t, vector: vectors[t.ID], shingles: shingles(t.Transcription)}
+		if c.vector != nil || len(c.shingles) > 0 {
+			candidates = append(candidates, c)
+		}
+	}
+
+	duplicates := make([]model.Duplicate, 0)
+	for i, later := range candidates {
+		for _, earlier := range candidates[:i] {
+			d := model.Duplicate{TranscriptionID: later.t.ID, DuplicateOf: earlier.t.ID, DetectedAt: now}
+			if later.vector != nil && earlier.vector != nil {
+				d.EmbeddingSimilarity = dot(later.vector, earlier.vector)
+			}
+			// the Jaccard similarity can't exceed the ratio of the sizes, skip those too different
+			if d.EmbeddingSimilarity >= threshold || sizeRatio(later.shingles, earlier.shingles) >= threshold {
+				d.TextSimilarity = jaccard(later.shingles, earlier.shingles)
+			}
+			d.Similarity = math.Max(d.EmbeddingSimilarity, d.TextSimilarity)
+			if d.Similarity >= threshold {
+				duplicates = append(duplicates, d)
+			}
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool { return duplicates[i].Similarity > duplicates[j].Similarity })
+	return duplicates, nil
+}
+
+// DetectDuplicates finds the duplicates of the user and stores them, replacing those found before.
+func DetectDuplicates(db repository.TranscriptionDAO, opts DuplicateOptions) ([]model.Duplicate, error) {
+	dao, ok := db.(repository.DuplicateDAO)
+	if !ok {
+		return nil, ErrDuplicatesNotSupported
+	}
+	duplicates, err := FindDuplicates(db, opts, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err = dao.ReplaceDuplicates(opts.User, duplicates); err != nil {
+		return nil, fmt.Errorf("save duplicates of %s failed: %v", opts.User, err)
+	}
+	return dao.GetDuplicates(opts.User)
+}
+
+// indexVectors returns the normalized embeddings of the transcriptions in the vector index by ID,
+// leaving out the stale ones. It returns none when the database keeps no embeddings.
+func indexVectors(db repository.TranscriptionDAO, transcriptions []model.Transcription) (map[int][]float64, error) {
+	vectors := make(map[int][]float64)
+	embeddings, ok := db.(repository.EmbeddingDAO)
+	if !ok {
+		return vectors, nil
+	}
+	index, err := embeddings.GetIndexModel()
+	if err != nil || index == "" {
+		return vectors, err
+	}
+
+	for _, t := range transcriptions {
+		e, err := embeddings.GetEmbedding(t.ID, index)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get embedding of transcription %d failed: %v", t.ID, err)
+		}
+		if e.EmbeddedAt.Before(t.LastConversionTime) {
+			continue
+		}
+		if v := normalize(e.Vector); v != nil {
+			vectors[t.ID] = v
+		}
+	}
+	return vectors, nil
+}
+
+// normalize scales v to a unit vector so the dot product of two is their cosine similarity, it
+// returns nil for a zero vector.
+func normalize(v []float32) []float64 {
+	var norm float64
+	for _, f := range v {
+		norm += float64(f) * float64(f)
+	}
+	if norm == 0 {
+		return nil
+	}
+	norm = math.Sqrt(norm)
+	unit := make([]float64, len(v))
+	for i, f := range v {
+		unit[i] = float64(f) / norm
+	}
+	return unit
+}
+
+// dot returns the dot product of a and b, zero when their dimensions differ.
+func dot(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// shingles returns the sorted, distinct hashes of the word shingles of text. A text shorter than a
+// shingle is a single one.
+func shingles(text string) []uint64 {
+	words := textdiff.Words(text)
+	if len(words) == 0 {
+		return nil
+	}
+	n := len(words) - shingleSize + 1
+	if n < 1 {
+		n = 1
+	}
+	seen := make(map[uint64]bool, n)
+	hashes := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		end := i + shingleSize
+		if end > len(words) {
+			end = len(words)
+		}
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:end], " ")))
+		if sum := h.Sum64(); !seen[sum] {
+			seen[sum] = true
+			hashes = append(hashes, sum)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	return hashes
+}
+
+// jaccard returns the size of the intersection of the sorted sets a and b over that of their union.
+func jaccard(a, b []uint64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			common++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// sizeRatio is the size of the smaller of a and b over that of the larger, the highest Jaccard
+// similarity they can have.
+func sizeRatio(a, b []uint64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return float64(len(a)) / float64(len(b))
+}
+
+// DuplicateEntry is the JSON of a duplicate, served at /api/v1/users/{user}/duplicates and printed
+// by v2t analyze duplicates --json.
+type DuplicateEntry struct {
+	TranscriptionID     int       `json:"transcription_id"`
+	FileName            string    `json:"file_name"`
+	DuplicateOf         int       `json:"duplicate_of"`
+	DuplicateFileName   string    `json:"duplicate_file_name"`
+	Similarity          float64   `json:"similarity"`
+	EmbeddingSimilarity float64   `json:"embedding_similarity"`
+	TextSimilarity      float64   `json:"text_similarity"`
+	DetectedAt          time.Time `json:"detected_at"`
+}
+
+// NewDuplicateEntries returns the JSON of duplicates.
+func NewDuplicateEntries(duplicates []model.Duplicate) []DuplicateEntry {
+	entries := make([]DuplicateEntry, 0, len(duplicates))
+	for _, d := range duplicates {
+		entries = append(entries, DuplicateEntry{
+			TranscriptionID:     d.TranscriptionID,
+			FileName:            d.FileName,
+			DuplicateOf:         d.DuplicateOf,
+			DuplicateFileName:   d.DuplicateFileName,
+			Similarity:          d.Similarity,
+			EmbeddingSimilarity: d.EmbeddingSimilarity,
+			TextSimilarity:      d.TextSimilarity,
+			DetectedAt:          d.DetectedAt,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	story := "once upon a time there was a small town by the sea where every morning the fishermen went out"
+	tests := []struct {
+		name      string
+		texts     []string
+		vectors   map[int][]float32
+		stale     bool
+		threshold float64
+		// want lists the duplicates as transcription, duplicate of, rounded similarity
+		want [][3]float64
+	}{
+		{
+			name:  "re-upload",
+			texts: []string{story, "something else entirely", story},
+			want:  [][3]float64{{3, 1, 1}},
+		},
+		{
+			name:  "punctuation and case ignored",
+			texts: []string{story, strings.ToUpper(story) + "!"},
+			want:  [][3]float64{{2, 1, 1}},
+		},
+		{
+			name:      "one word changed",
+			texts:     []string{story, strings.Replace(story, "small", "little", 1)},
+			threshold: 0.7,
+			// 3 of the 17 shingles changed: 14 shared of 20
+			want: [][3]float64{{2, 1, 0.7}},
+		},
+		{
+			name:  "one word changed below the default threshold",
+			texts: []string{story, strings.Replace(story, "small", "little", 1)},
+		},
+		{
+			name:    "same embedding",
+			texts:   []string{story, "the same story told in other words", "unrelated"},
+			vectors: map[int][]float32{1: {1, 0}, 2: {2, 0.01}, 3: {0, 1}},
+			want:    [][3]float64{{2, 1, 1}},
+		},
+		{
+			name:    "stale embedding",
+			texts:   []string{story, "the same story told in other words"},
+			vectors: map[int][]float32{1: {1, 0}, 2: {1, 0}},
+			stale:   true,
+		},
+		{
+			name:      "several earlier ones, most similar first",
+			texts:     []string{story, story + " again", story},
+			threshold: 0.9,
+			want:      [][3]float64{{3, 1, 1}, {2, 1, 0.94}, {3, 2, 0.94}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemoryDB()
+			for i, text := range tt.texts {
+				db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, text, now.Add(time.Duration(i)*time.Minute), 0, "", model.ProviderMetadata{})
+			}
+			db.RecordToDB("bob", "/in", "b.mp4", "b.mp3", 60, story, now, 0, "", model.ProviderMetadata{})
+			embeddedAt := now.Add(time.Hour)
+			if tt.stale {
+				embeddedAt = now
+			}
+			for id, v := range tt.vectors {
+				db.SaveEmbedding(model.Embedding{TranscriptionID: id, Model: "m", Vector: v, EmbeddedAt: embeddedAt})
+			}
+			db.SetIndexModel("m", false, now)
+
+			got, err := FindDuplicates(db, DuplicateOptions{User: "alice", Threshold: tt.threshold}, now)
+			if err != nil {
+				t.Fatalf("FindDuplicates() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("FindDuplicates() = %+v, want %v", got, tt.want)
+			}
+			for i, d := range got {
+				w := tt.want[i]
+				if d.TranscriptionID != int(w[0]) || d.DuplicateOf != int(w[1]) || math.Round(d.Similarity*100)/100 != w[2] {
+					t.Errorf("FindDuplicates()[%d] = %+v, want %v", i, d, w)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectDuplicates(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	db := memory.NewMemoryDB()
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "hello there", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "a2.mp4", "a2.mp3", 60, "Hello there!", now, 0, "", model.ProviderMetadata{})
+
+	got, err := DetectDuplicates(db, DuplicateOptions{User: "alice"})
+	if err != nil || len(got) != 1 || got[0].FileName != "a2.mp3" || got[0].DuplicateFileName != "a.mp3" || got[0].TextSimilarity != 1 {
+		t.Fatalf("DetectDuplicates() = %+v, %v, want a2.mp3 a duplicate of a.mp3", got, err)
+	}
+	if stored, _ := db.GetDuplicates("alice"); len(stored) != 1 {
+		t.Errorf("GetDuplicates() = %+v, want the detected duplicate stored", stored)
+	}
+}
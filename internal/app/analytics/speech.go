@@ -0,0 +1,376 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/paragraph"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/textdiff"
+	"time"
+)
+
+// SpeechVersion is the version of the speech analysis, stats of an older version are computed again.
+const SpeechVersion = 1
+
+const (
+	// monologuePause in seconds ends a monologue even when the same speaker goes on.
+	monologuePause = 3.0
+	// interruptionGap in seconds is how soon after the previous speaker a turn counts as an interruption.
+	interruptionGap = 0.2
+)
+
+// DefaultFillerWords are counted as filler words, config.yaml adds more under analytics.filler_words.
+var DefaultFillerWords = []string{
+	"um", "umm", "uh", "uhh", "erm", "er", "hmm", "you know", "i mean",
+	"嗯", "呃", "额", "那个", "就是说",
+}
+
+// ErrSpeechNotSupported is returned when the database doesn't keep speech analytics.
+var ErrSpeechNotSupported = errors.New("the database does not keep speech analytics")
+
+// Speech computes the speech analytics of t with fillers as the filler words, the segments of t have
+// to be loaded. Monologues, interruptions and the speech time need timed segments, the speech time
+// of a transcription without them is its audio duration.
+func Speech(t model.Transcription, fillers []string) model.SpeechStats {
+	stats := model.SpeechStats{TranscriptionID: t.ID, Version: SpeechVersion, FileName: t.Mp3FileName, Date: t.LastConversionTime}
+
+	var text []string
+	var timed []model.Segment
+	for _, s := range paragraph.Segments(t) {
+		text = append(text, s.Text)
+		if s.Timed() {
+			timed = append(timed, s)
+		}
+	}
+	words := textdiff.Words(strings.Join(text, " "))
+	stats.Words = len(words)
+	stats.FillerWords = countFillers(words, fillers)
+
+	if len(timed) == 0 {
+		stats.SpeechSeconds = t.AudioDuration
+		return stats
+	}
+
+	speakers := make(map[string]bool)
+	start := timed[0].Start
+	stats.LongestMonologue = timed[0].End - timed[0].Start
+	for i, s := range timed {
+		stats.SpeechSeconds += s.End - s.Start
+		if s.Speaker != "" {
+			speakers[s.Speaker] = true
+		}
+		if i == 0 {
+			continue
+		}
+
+		prev := timed[i-1]
+		if s.Speaker != prev.Speaker || s.Start-prev.End >= monologuePause {
+			start = s.Start
+		}
+		if s.Speaker != prev.Speaker && s.Speaker != "" && prev.Speaker != "" && s.Start < prev.End+interruptionGap {
+			stats.Interruptions++
+		}
+		if s.End-start > stats.LongestMonologue {
+			stats.LongestMonologue = s.End - start
+		}
+	}
+	stats.Speakers = len(speakers)
+	return stats
+}
+
+// countFillers counts the filler words and phrases in words, the longest filler matching first.
+func countFillers(words []string, fillers []string) int {
+	phrases := fillerPhrases(fillers)
+	count := 0
+	for i := 0; i < len(words); {
+		matched := matchFiller(words[i:], phrases)
+		if matched == 0 {
+			i++
+			continue
+		}
+		count++
+		i += matched
+	}
+	return count
+}
+
+// TimedFillers returns the filler words and phrases among the timed words of segments, each as one
+// word spanning it. A filler has to cover whole words, 那个 doesn't match the start of 那个人.
+// Segments without word timings have none.
+func TimedFillers(segments []model.Segment, fillers []string) []model.Word {
+	phrases := fillerPhrases(fillers)
+	var found []model.Word
+	for _, s := range segments {
+		// tokens are the words of the segment as Words splits them, owner the word each came from
+		var tokens []string
+		var owner []int
+		for i, w := range s.Words {
+			for _, token := range textdiff.Words(w.Text) {
+				tokens = append(tokens, token)
+				owner = append(owner, i)
+			}
+		}
+
+		for i := 0; i < len(tokens); {
+			matched := 0
+			if i == 0 || owner[i-1] != owner[i] {
+				matched = matchFiller(tokens[i:], phrases)
+			}
+			end := i + matched
+			if matched == 0 || (end < len(tokens) && owner[end] == owner[end-1]) {
+				i++
+				continue
+			}
+			var text strings.Builder
+			for _, w := range s.Words[owner[i] : owner[end-1]+1] {
+				text.WriteString(w.Text)
+			}
+			first, last := s.Words[owner[i]], s.Words[owner[end-1]]
+			found = append(found, model.Word{Start: first.Start, End: last.End, Text: strings.TrimSpace(text.String())})
+			i = end
+		}
+	}
+	return found
+}
+
+// fillerPhrases splits the fillers into words, the longest first so it wins over its prefixes.
+func fillerPhrases(fillers []string) [][]string {
+	phrases := make([][]string, 0, len(fillers))
+	for _, f := range fillers {
+		if phrase := textdiff.Words(f); len(phrase) > 0 {
+			phrases = append(phrases, phrase)
+		}
+	}
+	sort.SliceStable(phrases, func(i, j int) bool { return len(phrases[i]) > len(phrases[j]) })
+	return phrases
+}
+
+// matchFiller returns the number of words of the filler words starts with, zero when none.
+func matchFiller(words []string, phrases [][]string) int {
+	for _, phrase := range phrases {
+		if hasPrefix(words, phrase) {
+			return len(phrase)
+		}
+	}
+	return 0
+}
+
+func hasPrefix(words []string, prefix []string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i, w := range prefix {
+		if words[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// SpeechOptions select the transcriptions UpdateSpeech analyzes.
+type SpeechOptions struct {
+	User string
+	// FillerWords are counted in addition to DefaultFillerWords.
+	FillerWords []string
+	// Force analyzes transcriptions with current stats again, e.g. after the filler words changed.
+	Force bool
+}
+
+// UpdateSpeech computes and stores the speech analytics of the transcriptions of the user that have
+// none, or stats older than the transcription or the analysis, and returns the stats of all of them.
+func UpdateSpeech(db repository.TranscriptionDAO, opts SpeechOptions) ([]model.SpeechStats, error) {
+	dao, ok := db.(repository.SpeechStatsDAO)
+	if !ok {
+		return nil, ErrSpeechNotSupported
+	}
+	stored, err := dao.GetSpeechStats(opts.User)
+	if err != nil {
+		return nil, fmt.Errorf("get speech stats of %s failed: %v", opts.User, err)
+	}
+	current := make(map[int]model.SpeechStats, len(stored))
+	for _, s := range stored {
+		current[s.TranscriptionID] = s
+	}
+
+	transcriptions, err := db.GetAllByUser(opts.User)
+	if err != nil {
+		return nil, fmt.Errorf("get transcriptions of %s failed: %v", opts.User, err)
+	}
+	fillers := append(append([]string{}, DefaultFillerWords...), opts.FillerWords...)
+	updated := false
+	for _, t := range transcriptions {
+		if strings.TrimSpace(t.Transcription) == "" {
+			continue
+		}
+		s, ok := current[t.ID]
+		if ok && !opts.Force && s.Version == SpeechVersion && !s.ComputedAt.Before(t.LastConversionTime) {
+			continue
+		}
+
+		if err = export.LoadSegments(db, &t); err != nil {
+			return nil, err
+		}
+		s = Speech(t, fillers)
+		s.ComputedAt = time.Now()
+		if err = dao.SaveSpeechStats(s); err != nil {
+			return nil, fmt.Errorf("save speech stats of %d failed: %v", t.ID, err)
+		}
+		updated = true
+	}
+
+	if !updated {
+		return stored, nil
+	}
+	return dao.GetSpeechStats(opts.User)
+}
+
+// TrendPoint sums up the episodes of a week.
+type TrendPoint struct {
+	// Week is the Monday the week starts on.
+	Week     time.Time
+	Episodes int
+	// FillersPerMinute and WordsPerMinute are over the speech of all the episodes.
+	FillersPerMinute float64
+	WordsPerMinute   float64
+	// LongestMonologue is the longest of the week in seconds, Interruptions the average per episode.
+	LongestMonologue float64
+	Interruptions    float64
+}
+
+// Trend groups stats by the week of their transcription, the earliest week first.
+func Trend(stats []model.SpeechStats) []TrendPoint {
+	byWeek := make(map[time.Time]*model.SpeechStats)
+	episodes := make(map[time.Time]int)
+	for _, s := range stats {
+		week := weekOf(s.Date)
+		sum, ok := byWeek[week]
+		if !ok {
+			sum = &model.SpeechStats{}
+			byWeek[week] = sum
+		}
+		episodes[week]++
+		sum.SpeechSeconds += s.SpeechSeconds
+		sum.Words += s.Words
+		sum.FillerWords += s.FillerWords
+		sum.Interruptions += s.Interruptions
+		if s.LongestMonologue > sum.LongestMonologue {
+			sum.LongestMonologue = s.LongestMonologue
+		}
+	}
+
+	points := make([]TrendPoint, 0, len(byWeek))
+	for week, sum := range byWeek {
+		points = append(points, TrendPoint{
+			Week:             week,
+			Episodes:         episodes[week],
+			FillersPerMinute: sum.FillersPerMinute(),
+			WordsPerMinute:   sum.WordsPerMinute(),
+			LongestMonologue: sum.LongestMonologue,
+			Interruptions:    float64(sum.Interruptions) / float64(episodes[week]),
+		})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Week.Before(points[j].Week) })
+	return points
+}
+
+// weekOf returns the start of the Monday of the week of t, in the location of t.
+func weekOf(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// sparkBars draw a value between the lowest and the highest of a sparkline.
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a line of bars for a terminal, the lowest value as the lowest bar.
+func Sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, v := range values {
+		if v < low {
+			low = v
+		}
+		if v > high {
+			high = v
+		}
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		i := 0
+		if high > low {
+			i = int((v - low) / (high - low) * float64(len(sparkBars)-1))
+		}
+		sb.WriteRune(sparkBars[i])
+	}
+	return sb.String()
+}
+
+// SpeechEpisode is an episode of a SpeechReport.
+type SpeechEpisode struct {
+	TranscriptionID  int       `json:"transcription_id"`
+	FileName         string    `json:"file_name"`
+	Date             time.Time `json:"date"`
+	SpeechSeconds    float64   `json:"speech_seconds"`
+	Words            int       `json:"words"`
+	FillerWords      int       `json:"filler_words"`
+	FillersPerMinute float64   `json:"fillers_per_minute"`
+	WordsPerMinute   float64   `json:"words_per_minute"`
+	LongestMonologue float64   `json:"longest_monologue_seconds"`
+	Speakers         int       `json:"speakers"`
+	Interruptions    int       `json:"interruptions"`
+}
+
+// SpeechWeek is a point of the trend of a SpeechReport.
+type SpeechWeek struct {
+	Week             string  `json:"week"`
+	Episodes         int     `json:"episodes"`
+	FillersPerMinute float64 `json:"fillers_per_minute"`
+	WordsPerMinute   float64 `json:"words_per_minute"`
+	LongestMonologue float64 `json:"longest_monologue_seconds"`
+	Interruptions    float64 `json:"interruptions"`
+}
+
+// SpeechReport is the JSON of the speech analytics of a user, served at
+// /api/v1/users/{user}/speech-analytics and printed by v2t stats speech --json.
+type SpeechReport struct {
+	Episodes []SpeechEpisode `json:"episodes"`
+	Trend    []SpeechWeek    `json:"trend"`
+}
+
+// NewSpeechReport returns the report of the stats of the episodes of a user and their trend.
+func NewSpeechReport(stats []model.SpeechStats) SpeechReport {
+	report := SpeechReport{Episodes: make([]SpeechEpisode, 0, len(stats)), Trend: make([]SpeechWeek, 0)}
+	for _, e := range stats {
+		report.Episodes = append(report.Episodes, SpeechEpisode{
+			TranscriptionID:  e.TranscriptionID,
+			FileName:         e.FileName,
+			Date:             e.Date,
+			SpeechSeconds:    e.SpeechSeconds,
+			Words:            e.Words,
+			FillerWords:      e.FillerWords,
+			FillersPerMinute: e.FillersPerMinute(),
+			WordsPerMinute:   e.WordsPerMinute(),
+			LongestMonologue: e.LongestMonologue,
+			Speakers:         e.Speakers,
+			Interruptions:    e.Interruptions,
+		})
+	}
+	for _, p := range Trend(stats) {
+		report.Trend = append(report.Trend, SpeechWeek{
+			Week:             p.Week.Format("2006-01-02"),
+			Episodes:         p.Episodes,
+			FillersPerMinute: p.FillersPerMinute,
+			WordsPerMinute:   p.WordsPerMinute,
+			LongestMonologue: p.LongestMonologue,
+			Interruptions:    p.Interruptions,
+		})
+	}
+	return report
+}
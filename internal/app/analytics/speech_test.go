@@ -0,0 +1,142 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+func TestSpeech(t *testing.T) {
+	tests := []struct {
+		name              string
+		t                 model.Transcription
+		wantWords         int
+		wantFillers       int
+		wantSpeech        float64
+		wantMonologue     float64
+		wantSpeakers      int
+		wantInterruptions int
+	}{
+		{
+			name: "untimed",
+			t:    model.Transcription{Transcription: "Um, so, you know, I mean it. Uh yes.", AudioDuration: 30},
+			// um, you know, i mean and uh, "so" is no filler
+			wantWords: 9, wantFillers: 4, wantSpeech: 30,
+		},
+		{
+			name: "chinese",
+			t:    model.Transcription{Transcription: "嗯，那个，我们开始吧。", AudioDuration: 10},
+			// every Han character is a word, 那个 is one filler
+			wantWords: 8, wantFillers: 2, wantSpeech: 10,
+		},
+		{
+			name: "one speaker with a long pause",
+			t: model.Transcription{Segments: []model.Segment{
+				{Start: 0, End: 10, Text: "First part."},
+				{Start: 10.5, End: 25, Text: "Still talking."},
+				{Start: 30, End: 35, Text: "After a pause."},
+			}},
+			wantWords: 7, wantSpeech: 29.5, wantMonologue: 25,
+		},
+		{
+			name: "interview",
+			t: model.Transcription{Segments: []model.Segment{
+				{Start: 0, End: 5, Speaker: "SPEAKER_00", Text: "Question?"},
+				{Start: 4.5, End: 20, Speaker: "SPEAKER_01", Text: "Um, cutting in."},
+				{Start: 21, End: 40, Speaker: "SPEAKER_01", Text: "And going on."},
+				{Start: 41, End: 45, Speaker: "SPEAKER_00", Text: "Thanks."},
+				{Start: 45.1, End: 50, Speaker: "SPEAKER_01", Text: "Right away."},
+			}},
+			wantWords: 10, wantFillers: 1, wantSpeech: 48.4, wantMonologue: 35.5, wantSpeakers: 2, wantInterruptions: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Speech(tt.t, DefaultFillerWords)
+			if got.Words != tt.wantWords || got.FillerWords != tt.wantFillers || got.Speakers != tt.wantSpeakers ||
+				got.Interruptions != tt.wantInterruptions {
+				t.Errorf("Speech() = %d words, %d fillers, %d speakers, %d interruptions, want %d, %d, %d, %d",
+					got.Words, got.FillerWords, got.Speakers, got.Interruptions,
+					tt.wantWords, tt.wantFillers, tt.wantSpeakers, tt.wantInterruptions)
+			}
+			if !near(got.SpeechSeconds, tt.wantSpeech) || !near(got.LongestMonologue, tt.wantMonologue) {
+				t.Errorf("Speech() = %.2fs speech, %.2fs monologue, want %.2f, %.2f",
+					got.SpeechSeconds, got.LongestMonologue, tt.wantSpeech, tt.wantMonologue)
+			}
+		})
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
+
+func TestTimedFillers(t *testing.T) {
+	segments := []model.Segment{
+		{Words: []model.Word{
+			{Start: 0, End: 1, Text: " Um,"}, {Start: 1, End: 2, Text: " you"}, {Start: 2, End: 3, Text: " know"},
+			{Start: 3, End: 4, Text: " umbrella"}, {Start: 4, End: 5, Text: " uh"},
+		}},
+		{Words: []model.Word{{Start: 6, End: 7, Text: "那个人"}, {Start: 7, End: 8, Text: "嗯"}, {Start: 8, End: 9, Text: "那个"}}},
+		{Start: 10, End: 12, Text: "um untimed words"},
+	}
+	want := []model.Word{
+		{Start: 0, End: 1, Text: "Um,"}, {Start: 1, End: 3, Text: "you know"}, {Start: 4, End: 5, Text: "uh"},
+		{Start: 7, End: 8, Text: "嗯"}, {Start: 8, End: 9, Text: "那个"},
+	}
+	if got := TimedFillers(segments, DefaultFillerWords); !reflect.DeepEqual(got, want) {
+		t.Errorf("TimedFillers() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUpdateSpeech(t *testing.T) {
+	db := memory.NewMemoryDB()
+	monday := time.Date(2024, 4, 29, 10, 0, 0, 0, time.UTC)
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "Um, hello.", monday, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 60, "Hello again.", monday.AddDate(0, 0, 3), 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 120, "Like, like, hello.", monday.AddDate(0, 0, 7), 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "d.mp4", "d.mp3", 60, "Not alice.", monday, 0, "", model.ProviderMetadata{})
+
+	stats, err := UpdateSpeech(db, SpeechOptions{User: "alice", FillerWords: []string{"like"}})
+	if err != nil || len(stats) != 3 {
+		t.Fatalf("UpdateSpeech() = %+v, %v, want the three transcriptions of alice", stats, err)
+	}
+	if stats[0].FileName != "a.mp3" || stats[0].FillerWords != 1 || stats[2].FillerWords != 2 {
+		t.Errorf("UpdateSpeech() = %+v", stats)
+	}
+
+	computed := stats[0].ComputedAt
+	if stats, _ = UpdateSpeech(db, SpeechOptions{User: "alice"}); !stats[0].ComputedAt.Equal(computed) || stats[2].FillerWords != 2 {
+		t.Errorf("UpdateSpeech() again = %+v, want the stored stats", stats)
+	}
+	if stats, _ = UpdateSpeech(db, SpeechOptions{User: "alice", Force: true}); stats[2].FillerWords != 0 {
+		t.Errorf("UpdateSpeech(Force) = %+v, want the stats computed without the extra filler", stats)
+	}
+
+	trend := Trend(stats)
+	if len(trend) != 2 || !trend[0].Week.Equal(time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC)) || trend[0].Episodes != 2 {
+		t.Fatalf("Trend() = %+v, want two weeks, two episodes in the first", trend)
+	}
+	// 1 filler in the 2 minutes of the first week
+	if !near(trend[0].FillersPerMinute, 0.5) || trend[1].Episodes != 1 {
+		t.Errorf("Trend() = %+v", trend)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   string
+	}{
+		{values: []float64{1, 2, 3, 8}, want: "▁▂▃█"},
+		{values: []float64{2, 2}, want: "▁▁"},
+		{values: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := Sparkline(tt.values); got != tt.want {
+			t.Errorf("Sparkline(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,121 @@
 package api
 
+import (
+	"context"
+	"tiktok-whisper/internal/app/model"
+)
+
 // Transcriber defines a transcription interface for converting audio files to text.
 type Transcriber interface {
 	Transcript(inputFilePath string) (string, error)
 }
+
+// MetadataTranscriber is implemented by transcribers that can also describe how the text was produced.
+type MetadataTranscriber interface {
+	Transcriber
+	TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error)
+}
+
+// PromptTranscriber is implemented by transcribers whose backend accepts an initial prompt,
+// used to carry the context of the previous chunk over to the next one.
+type PromptTranscriber interface {
+	MetadataTranscriber
+	TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error)
+}
+
+// Options tune how a backend decodes the audio, the zero value keeps its defaults.
+type Options struct {
+	// Prompt is the text the backend continues from, e.g. the end of the previous chunk.
+	Prompt string
+	// Temperature of the sampling, a higher one helps the decoder out of repetition loops.
+	Temperature float32
+	// NoContext stops the backend from conditioning on text it decoded before, Prompt is ignored.
+	NoContext bool
+	// NoSpeechThreshold is the no-speech probability above which a segment is dropped as silence.
+	NoSpeechThreshold float32
+	// VAD skips the parts without speech before decoding.
+	VAD bool
+	// Translate transcribes into English whatever language is spoken.
+	Translate bool
+	// WordThreshold is the probability below which a word timestamp is dropped.
+	WordThreshold float32
+	// MaxSegmentLength in characters splits longer segments, e.g. for subtitles. Zero doesn't split.
+	MaxSegmentLength int
+}
+
+// StrictOptions trade some recall for fewer hallucinations, results that look hallucinated are re-run
+// with them: no context, some temperature to leave loops, silence dropped more eagerly and VAD.
+// Backends ignore the settings they don't support.
+var StrictOptions = Options{NoContext: true, Temperature: 0.4, NoSpeechThreshold: 0.4, VAD: true}
+
+// Merge returns o with the unset settings taken from defaults, Prompt is never taken from them.
+func (o Options) Merge(defaults Options) Options {
+	o.NoContext = o.NoContext || defaults.NoContext
+	o.VAD = o.VAD || defaults.VAD
+	o.Translate = o.Translate || defaults.Translate
+	if o.Temperature == 0 {
+		o.Temperature = defaults.Temperature
+	}
+	if o.NoSpeechThreshold == 0 {
+		o.NoSpeechThreshold = defaults.NoSpeechThreshold
+	}
+	if o.WordThreshold == 0 {
+		o.WordThreshold = defaults.WordThreshold
+	}
+	if o.MaxSegmentLength == 0 {
+		o.MaxSegmentLength = defaults.MaxSegmentLength
+	}
+	return o
+}
+
+type optionsKey struct{}
+
+// WithOptions returns ctx carrying the options of one file, transcribers taking a context merge them
+// into their defaults. Callers tune a single file this way through a whole chain of wrappers.
+func WithOptions(ctx context.Context, opts Options) context.Context {
+	return context.WithValue(ctx, optionsKey{}, opts)
+}
+
+// OptionsFrom returns the options ctx carries, the zero value when it carries none.
+func OptionsFrom(ctx context.Context) Options {
+	opts, _ := ctx.Value(optionsKey{}).(Options)
+	return opts
+}
+
+// verbatimPrompts prime the decoder with disfluent text per language, whisper writes like its prompt
+// and keeps the fillers, false starts and stutters it would otherwise clean up.
+var verbatimPrompts = map[string]string{
+	"en": "Umm, let me think like, hmm... Okay, I- I mean, here's what I'm, like, thinking. ",
+	"zh": "嗯，那个，我我觉得，呃……就是说，这个嘛，",
+}
+
+// VerbatimPrompt returns the prompt priming a verbatim transcription of language, the English one
+// when language has none.
+func VerbatimPrompt(language string) string {
+	if prompt, ok := verbatimPrompts[language]; ok {
+		return prompt
+	}
+	return verbatimPrompts["en"]
+}
+
+// Verbatim returns o for a verbatim transcription: nothing is dropped as silence, so the no-speech
+// threshold and VAD are off whatever the defaults or a retry ask for.
+func (o Options) Verbatim() Options {
+	o.NoSpeechThreshold = 0
+	o.VAD = false
+	return o
+}
+
+// OptionsTranscriber is implemented by transcribers whose decoding can be tuned per call,
+// used to re-run suspicious results with different parameters.
+type OptionsTranscriber interface {
+	PromptTranscriber
+	TranscriptWithOptions(inputFilePath string, opts Options) (string, model.ProviderMetadata, error)
+}
+
+// ContextTranscriber is implemented by transcribers that carry ctx into their backend call,
+// so the call joins the trace of the conversion and stops when ctx is canceled.
+type ContextTranscriber interface {
+	MetadataTranscriber
+	TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error)
+}
@@ -0,0 +1,385 @@
+// Package assemblyai transcribes with the AssemblyAI API: the audio is uploaded, a transcript of it
+// is requested and polled until it is done. Besides the segments it reports the chapters, entities
+// and sentiments AssemblyAI found as the insights of the transcription.
+package assemblyai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/requestmeta"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/config/secrets"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+const providerName = "assemblyai"
+
+const (
+	defaultURL          = "https://api.assemblyai.com"
+	defaultPollInterval = 3 * time.Second
+)
+
+const healthCheckTimeout = 10 * time.Second
+
+// AssemblyAITranscriber transcribes with one AssemblyAI model.
+type AssemblyAITranscriber struct {
+	cfg      config.AssemblyAIConfig
+	key      string
+	baseURL  string
+	client   *http.Client
+	language string
+	request  *model.RequestMetadata
+}
+
+// NewAssemblyAITranscriber creates the transcriber of cfg authenticated with key, requests are sent with client.
+func NewAssemblyAITranscriber(cfg config.AssemblyAIConfig, key string, client *http.Client) *AssemblyAITranscriber {
+	baseURL := strings.TrimSuffix(cfg.URL, "/")
+	if baseURL == "" {
+		baseURL = defaultURL
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	return &AssemblyAITranscriber{
+		cfg:     cfg,
+		key:     key,
+		baseURL: baseURL,
+		client:  client,
+		request: config.GetProviders().For(providerName).Record(),
+	}
+}
+
+// New creates the transcriber of assemblyai in providers.yaml, the key is resolved by secrets.Key.
+func New() (*AssemblyAITranscriber, error) {
+	key, err := secrets.Key(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return NewAssemblyAITranscriber(config.GetProviders().For(providerName).AssemblyAI, key, requestmeta.NewClient(providerName)), nil
+}
+
+// SetLanguage sets the language of the audio when providers.yaml sets none, it is detected when language is empty.
+func (at *AssemblyAITranscriber) SetLanguage(language string) {
+	at.language = language
+}
+
+// SupportedFormats are the containers AssemblyAI decodes itself, other audio is converted to mp3.
+func (at *AssemblyAITranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"mp3", "m4a", "mp4", "wav", "webm", "ogg", "flac"}}
+}
+
+func (at *AssemblyAITranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := at.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and also reports the language, speakers, segments
+// with the timings of their words and the insights the configured analyses found.
+func (at *AssemblyAITranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return at.transcribe(context.Background(), inputFilePath)
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata, AssemblyAI takes no prompt so it is ignored.
+func (at *AssemblyAITranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return at.transcribe(context.Background(), inputFilePath)
+}
+
+// TranscriptWithOptions works like TranscriptWithMetadata, the decoding options have no equivalent
+// in the API and are ignored.
+func (at *AssemblyAITranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return at.transcribe(context.Background(), inputFilePath)
+}
+
+// TranscriptContext works like TranscriptWithMetadata, ctx cancels the upload and the polling.
+func (at *AssemblyAITranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return at.transcribe(ctx, inputFilePath)
+}
+
+// transcriptRequest are the options of a transcript, times in its results are in milliseconds.
+type transcriptRequest struct {
+	AudioURL          string `json:"audio_url"`
+	SpeechModel       string `json:"speech_model,omitempty"`
+	LanguageCode      string `json:"language_code,omitempty"`
+	LanguageDetection bool   `json:"language_detection,omitempty"`
+	SpeakerLabels     bool   `json:"speaker_labels,omitempty"`
+	AutoChapters      bool   `json:"auto_chapters,omitempty"`
+	EntityDetection   bool   `json:"entity_detection,omitempty"`
+	SentimentAnalysis bool   `json:"sentiment_analysis,omitempty"`
+}
+
+// transcript is a transcript as /v2/transcript reports it, its results are set once Status is completed.
+type transcript struct {
+	ID            string  `json:"id"`
+	Status        string  `json:"status"`
+	Error         string  `json:"error"`
+	Text          string  `json:"text"`
+	LanguageCode  string  `json:"language_code"`
+	AudioDuration float64 `json:"audio_duration"`
+	SpeechModel   string  `json:"speech_model"`
+	Chapters      []struct {
+		Start    float64 `json:"start"`
+		End      float64 `json:"end"`
+		Headline string  `json:"headline"`
+		Gist     string  `json:"gist"`
+		Summary  string  `json:"summary"`
+	} `json:"chapters"`
+	Entities []struct {
+		EntityType string  `json:"entity_type"`
+		Text       string  `json:"text"`
+		Start      float64 `json:"start"`
+		End        float64 `json:"end"`
+	} `json:"entities"`
+	SentimentAnalysisResults []struct {
+		Start      float64 `json:"start"`
+		End        float64 `json:"end"`
+		Text       string  `json:"text"`
+		Sentiment  string  `json:"sentiment"`
+		Confidence float64 `json:"confidence"`
+		Speaker    *string `json:"speaker"`
+	} `json:"sentiment_analysis_results"`
+}
+
+// sentences are the sentences of a completed transcript, which are its segments.
+type sentences struct {
+	Sentences []struct {
+		Start   float64 `json:"start"`
+		End     float64 `json:"end"`
+		Text    string  `json:"text"`
+		Speaker *string `json:"speaker"`
+		Words   []struct {
+			Start      float64 `json:"start"`
+			End        float64 `json:"end"`
+			Text       string  `json:"text"`
+			Confidence float64 `json:"confidence"`
+		} `json:"words"`
+	} `json:"sentences"`
+}
+
+func (at *AssemblyAITranscriber) transcribe(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	metadata := at.metadata()
+
+	f, err := os.Open(inputFilePath)
+	if err != nil {
+		return "", metadata, fmt.Errorf("read audio failed: %v", err)
+	}
+	defer f.Close()
+
+	logging.L().Info("Starting transcription", "provider", providerName, "model", at.cfg.SpeechModel, "file", inputFilePath)
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+	}
+	if err = at.do(ctx, http.MethodPost, "/v2/upload", f, &upload); err != nil {
+		return "", metadata, err
+	}
+
+	body, err := json.Marshal(at.transcriptRequest(upload.UploadURL))
+	if err != nil {
+		return "", metadata, err
+	}
+	var t transcript
+	if err = at.do(ctx, http.MethodPost, "/v2/transcript", bytes.NewReader(body), &t); err != nil {
+		return "", metadata, err
+	}
+	if t, err = at.wait(ctx, t); err != nil {
+		return "", metadata, err
+	}
+	var s sentences
+	if err = at.do(ctx, http.MethodGet, "/v2/transcript/"+url.PathEscape(t.ID)+"/sentences", nil, &s); err != nil {
+		return "", metadata, err
+	}
+
+	if t.LanguageCode != "" {
+		metadata.Language = t.LanguageCode
+	}
+	if t.SpeechModel != "" {
+		metadata.Model = t.SpeechModel
+	}
+	metadata.DurationSeconds = t.AudioDuration
+	speakers := map[string]bool{}
+	metadata.Segments = make([]model.Segment, 0, len(s.Sentences))
+	for _, sentence := range s.Sentences {
+		segment := model.Segment{Start: seconds(sentence.Start), End: seconds(sentence.End), Text: strings.TrimSpace(sentence.Text)}
+		for _, w := range sentence.Words {
+			segment.Words = append(segment.Words, model.Word{Start: seconds(w.Start), End: seconds(w.End), Text: w.Text, Probability: w.Confidence})
+		}
+		if sentence.Speaker != nil && *sentence.Speaker != "" {
+			segment.Speaker = *sentence.Speaker
+			speakers[segment.Speaker] = true
+		}
+		metadata.Segments = append(metadata.Segments, segment)
+	}
+	metadata.SegmentCount = len(metadata.Segments)
+	metadata.Speakers = len(speakers)
+	if insights := insightsOf(t); !insights.Empty() {
+		metadata.Insights = &insights
+	}
+	return strings.TrimSpace(t.Text), metadata, nil
+}
+
+// transcriptRequest returns the options of the transcript of the audio uploaded to audioURL.
+func (at *AssemblyAITranscriber) transcriptRequest(audioURL string) transcriptRequest {
+	r := transcriptRequest{
+		AudioURL:          audioURL,
+		SpeechModel:       at.cfg.SpeechModel,
+		LanguageCode:      at.lang(),
+		SpeakerLabels:     at.cfg.SpeakerLabels,
+		AutoChapters:      at.cfg.AutoChapters,
+		EntityDetection:   at.cfg.EntityDetection,
+		SentimentAnalysis: at.cfg.SentimentAnalysis,
+	}
+	r.LanguageDetection = r.LanguageCode == ""
+	return r
+}
+
+// wait polls t until AssemblyAI completed it, it fails when AssemblyAI gave up on the audio.
+func (at *AssemblyAITranscriber) wait(ctx context.Context, t transcript) (transcript, error) {
+	timer := time.NewTimer(at.cfg.PollInterval)
+	defer timer.Stop()
+	for {
+		switch t.Status {
+		case "completed":
+			return t, nil
+		case "error":
+			return t, provider.NewTranscriptionError(providerName, false, fmt.Errorf("%s failed transcript %s: %s", at.baseURL, t.ID, t.Error))
+		}
+		select {
+		case <-ctx.Done():
+			return t, provider.NewTranscriptionError(providerName, false, fmt.Errorf("wait for transcript %s failed: %w", t.ID, ctx.Err()))
+		case <-timer.C:
+		}
+		if err := at.do(ctx, http.MethodGet, "/v2/transcript/"+url.PathEscape(t.ID), nil, &t); err != nil {
+			return t, err
+		}
+		timer.Reset(at.cfg.PollInterval)
+	}
+}
+
+// do sends body to path and decodes the JSON response into v, failures are TranscriptionErrors.
+func (at *AssemblyAITranscriber) do(ctx context.Context, method, path string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, at.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", at.key)
+	if method == http.MethodPost && path != "/v2/upload" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := at.client.Do(req)
+	if err != nil {
+		return provider.NewTranscriptionError(providerName, ctx.Err() == nil, fmt.Errorf("request to %s%s failed: %w", at.baseURL, path, err))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return provider.NewTranscriptionError(providerName, true, fmt.Errorf("read response of %s%s failed: %w", at.baseURL, path, err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return provider.NewTranscriptionError(providerName, retryable(resp.StatusCode),
+			fmt.Errorf("%s%s answered %s: %s", at.baseURL, path, resp.Status, errorMessage(data)))
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return provider.NewTranscriptionError(providerName, true, fmt.Errorf("parse response of %s%s failed: %v", at.baseURL, path, err))
+	}
+	return nil
+}
+
+// insightsOf returns the chapters, entities and sentiments of t in seconds.
+func insightsOf(t transcript) model.Insights {
+	var insights model.Insights
+	for _, c := range t.Chapters {
+		insights.Chapters = append(insights.Chapters, model.Chapter{
+			Start: seconds(c.Start), End: seconds(c.End), Headline: c.Headline, Gist: c.Gist, Summary: c.Summary,
+		})
+	}
+	for _, e := range t.Entities {
+		insights.Entities = append(insights.Entities, model.Entity{Type: e.EntityType, Text: e.Text, Start: seconds(e.Start), End: seconds(e.End)})
+	}
+	for _, s := range t.SentimentAnalysisResults {
+		sentiment := model.Sentiment{
+			Start: seconds(s.Start), End: seconds(s.End), Text: s.Text,
+			Sentiment: strings.ToLower(s.Sentiment), Confidence: s.Confidence,
+		}
+		if s.Speaker != nil {
+			sentiment.Speaker = *s.Speaker
+		}
+		insights.Sentiments = append(insights.Sentiments, sentiment)
+	}
+	return insights
+}
+
+// seconds converts the milliseconds of AssemblyAI.
+func seconds(ms float64) float64 {
+	return ms / 1000
+}
+
+// metadata returns the metadata every transcription starts with.
+func (at *AssemblyAITranscriber) metadata() model.ProviderMetadata {
+	return model.ProviderMetadata{
+		Provider: providerName,
+		Model:    at.cfg.SpeechModel,
+		Language: at.lang(),
+		Request:  at.request,
+		Server:   &model.ServerInfo{Host: host(at.baseURL)},
+	}
+}
+
+// lang is the language of the audio, empty when it is detected.
+func (at *AssemblyAITranscriber) lang() string {
+	if at.cfg.LanguageCode != "" {
+		return at.cfg.LanguageCode
+	}
+	if at.language == "auto" {
+		return ""
+	}
+	return at.language
+}
+
+// HealthCheck lists the latest transcript of the key, which needs the API up and the key valid.
+func (at *AssemblyAITranscriber) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	var list struct{}
+	if err := at.do(ctx, http.MethodGet, "/v2/transcript?limit=1", nil, &list); err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	return nil
+}
+
+// errorMessage returns the error of an error response of AssemblyAI, the body when it isn't one.
+func errorMessage(body []byte) string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &e); err == nil && e.Error != "" {
+		return e.Error
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// retryable reports whether another provider may succeed: rate limits, rejected keys, exhausted
+// credits and server errors are, requests rejected for their audio aren't.
+func retryable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests ||
+		status == http.StatusRequestTimeout || status == http.StatusUnauthorized ||
+		status == http.StatusPaymentRequired || status == http.StatusForbidden
+}
+
+// host returns the host of baseURL, baseURL itself when it doesn't parse.
+func host(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
@@ -0,0 +1,159 @@
+package assemblyai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+func writeAudio(t *testing.T, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "a.mp3")
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const (
+	completed = `{"id":"t1","status":"completed","text":"Hi Bob. I love Paris.","language_code":"en","audio_duration":4,"speech_model":"best",
+		"chapters":[{"start":0,"end":4000,"headline":"Alice greets Bob","gist":"Greetings","summary":"Alice greets Bob and talks about Paris."}],
+		"entities":[{"entity_type":"person_name","text":"Bob","start":300,"end":700},{"entity_type":"location","text":"Paris","start":2500,"end":3000}],
+		"sentiment_analysis_results":[{"text":"Hi Bob.","start":0,"end":1000,"sentiment":"NEUTRAL","confidence":0.7,"speaker":"A"},
+			{"text":"I love Paris.","start":2000,"end":3000,"sentiment":"POSITIVE","confidence":0.9,"speaker":"B"}]}`
+	sentencesBody = `{"sentences":[
+		{"text":"Hi Bob.","start":0,"end":1000,"speaker":"A","words":[{"text":"Hi","start":0,"end":300,"confidence":0.9},{"text":"Bob.","start":300,"end":700,"confidence":0.8}]},
+		{"text":"I love Paris.","start":2000,"end":3000,"speaker":"B","words":[{"text":"I","start":2000,"end":2100,"confidence":1}]}]}`
+)
+
+func TestAssemblyAITranscriber_TranscriptWithMetadata(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           config.AssemblyAIConfig
+		language      string
+		routes        map[string]string
+		statuses      map[string]int
+		wantRequest   transcriptRequest
+		wantText      string
+		wantMetadata  model.ProviderMetadata
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{
+			name: "with insights",
+			cfg:  config.AssemblyAIConfig{SpeakerLabels: true, AutoChapters: true, EntityDetection: true, SentimentAnalysis: true},
+			routes: map[string]string{
+				"POST /v2/transcript":             `{"id":"t1","status":"queued"}`,
+				"GET /v2/transcript/t1":           completed,
+				"GET /v2/transcript/t1/sentences": sentencesBody,
+			},
+			wantRequest: transcriptRequest{AudioURL: "https://cdn/upload/1", LanguageDetection: true, SpeakerLabels: true,
+				AutoChapters: true, EntityDetection: true, SentimentAnalysis: true},
+			wantText: "Hi Bob. I love Paris.",
+			wantMetadata: model.ProviderMetadata{
+				Provider: providerName, Model: "best", Language: "en", DurationSeconds: 4, SegmentCount: 2, Speakers: 2,
+				Segments: []model.Segment{
+					{Start: 0, End: 1, Speaker: "A", Text: "Hi Bob.", Words: []model.Word{{Start: 0, End: 0.3, Text: "Hi", Probability: 0.9}, {Start: 0.3, End: 0.7, Text: "Bob.", Probability: 0.8}}},
+					{Start: 2, End: 3, Speaker: "B", Text: "I love Paris.", Words: []model.Word{{Start: 2, End: 2.1, Text: "I", Probability: 1}}},
+				},
+				Insights: &model.Insights{
+					Chapters: []model.Chapter{{Start: 0, End: 4, Headline: "Alice greets Bob", Gist: "Greetings", Summary: "Alice greets Bob and talks about Paris."}},
+					Entities: []model.Entity{{Type: "person_name", Text: "Bob", Start: 0.3, End: 0.7}, {Type: "location", Text: "Paris", Start: 2.5, End: 3}},
+					Sentiments: []model.Sentiment{
+						{Start: 0, End: 1, Speaker: "A", Text: "Hi Bob.", Sentiment: model.SentimentNeutral, Confidence: 0.7},
+						{Start: 2, End: 3, Speaker: "B", Text: "I love Paris.", Sentiment: model.SentimentPositive, Confidence: 0.9},
+					},
+				},
+			},
+		},
+		{
+			name:     "configured language without analyses",
+			cfg:      config.AssemblyAIConfig{SpeechModel: "nano"},
+			language: "zh",
+			routes: map[string]string{
+				"POST /v2/transcript":             `{"id":"t1","status":"processing"}`,
+				"GET /v2/transcript/t1":           `{"id":"t1","status":"completed","text":"你好","language_code":"zh","audio_duration":1,"speech_model":"nano"}`,
+				"GET /v2/transcript/t1/sentences": `{"sentences":[{"text":"你好","start":0,"end":900,"speaker":null,"words":[]}]}`,
+			},
+			wantRequest: transcriptRequest{AudioURL: "https://cdn/upload/1", SpeechModel: "nano", LanguageCode: "zh"},
+			wantText:    "你好",
+			wantMetadata: model.ProviderMetadata{
+				Provider: providerName, Model: "nano", Language: "zh", DurationSeconds: 1, SegmentCount: 1,
+				Segments: []model.Segment{{Start: 0, End: 0.9, Text: "你好"}},
+			},
+		},
+		{
+			name: "audio rejected",
+			routes: map[string]string{
+				"POST /v2/transcript":   `{"id":"t1","status":"queued"}`,
+				"GET /v2/transcript/t1": `{"id":"t1","status":"error","error":"File does not appear to contain audio."}`,
+			},
+			wantErr: true,
+		},
+		{name: "invalid key", statuses: map[string]int{"POST /v2/upload": http.StatusUnauthorized}, routes: map[string]string{"POST /v2/upload": `{"error":"Invalid API key"}`}, wantErr: true, wantRetryable: true},
+		{name: "bad request", statuses: map[string]int{"POST /v2/transcript": http.StatusBadRequest}, routes: map[string]string{"POST /v2/transcript": `{"error":"Invalid language_code"}`}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request transcriptRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "secret" {
+					t.Errorf("%s sent with %q", r.URL.Path, r.Header.Get("Authorization"))
+				}
+				route := r.Method + " " + r.URL.Path
+				body, _ := io.ReadAll(r.Body)
+				switch route {
+				case "POST /v2/upload":
+					if len(body) != 100 {
+						t.Errorf("uploaded %d bytes of audio, want 100", len(body))
+					}
+				case "POST /v2/transcript":
+					json.Unmarshal(body, &request)
+				}
+				if status := tt.statuses[route]; status != 0 {
+					w.WriteHeader(status)
+				}
+				if response, ok := tt.routes[route]; ok {
+					w.Write([]byte(response))
+				} else if route == "POST /v2/upload" {
+					w.Write([]byte(`{"upload_url":"https://cdn/upload/1"}`))
+				} else {
+					t.Errorf("unexpected %s", route)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			tt.cfg.URL = srv.URL
+			tt.cfg.PollInterval = time.Millisecond
+			at := NewAssemblyAITranscriber(tt.cfg, "secret", srv.Client())
+			at.SetLanguage(tt.language)
+			text, metadata, err := at.TranscriptWithMetadata(writeAudio(t, 100))
+			if tt.wantErr {
+				if err == nil || provider.IsRetryable(err) != tt.wantRetryable {
+					t.Errorf("TranscriptWithMetadata() error = %v, want retryable %v", err, tt.wantRetryable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TranscriptWithMetadata() error = %v", err)
+			}
+			metadata.Request, metadata.Server = nil, nil
+			if text != tt.wantText || !reflect.DeepEqual(metadata, tt.wantMetadata) {
+				t.Errorf("TranscriptWithMetadata() = %q, %+v, want %q, %+v", text, metadata, tt.wantText, tt.wantMetadata)
+			}
+			if !reflect.DeepEqual(request, tt.wantRequest) {
+				t.Errorf("transcript request = %+v, want %+v", request, tt.wantRequest)
+			}
+		})
+	}
+}
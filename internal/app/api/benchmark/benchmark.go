@@ -0,0 +1,172 @@
+// Package benchmark measures the providers on a reference recording, latency, accuracy and cost,
+// and recommends one from the measurements.
+package benchmark
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/util/files"
+	"tiktok-whisper/internal/app/util/textdiff"
+	"time"
+)
+
+// ResultsFile is where the measurements are kept in the data directory.
+const ResultsFile = "provider_benchmarks.json"
+
+// SameAccuracy is the WER difference below which providers count as equally accurate,
+// the cheaper one is recommended then.
+const SameAccuracy = 0.05
+
+// ErrNoResults is returned when no provider was measured successfully.
+var ErrNoResults = errors.New("no provider benchmarks, run v2t providers benchmark")
+
+// Options describes the reference recording.
+type Options struct {
+	Sample string
+	// Reference is the known transcript of the sample.
+	Reference string
+	// AudioSeconds is the duration of the sample, used to price it.
+	AudioSeconds float64
+	// Rates are the prices per audio minute by provider, see config.CostConfig.
+	Rates map[string]float64
+}
+
+// Result is the measurement of a provider.
+type Result struct {
+	Provider string        `json:"provider"`
+	Model    string        `json:"model,omitempty"`
+	Latency  time.Duration `json:"latency"`
+	WER      float64       `json:"wer"`
+	Cost     float64       `json:"cost"`
+	// Error is why the provider couldn't transcribe the sample, the measurements are empty then.
+	Error      string    `json:"error,omitempty"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// Run transcribes the sample with t and measures it.
+func Run(name string, t api.Transcriber, opts Options) Result {
+	result := Result{Provider: name, MeasuredAt: time.Now().UTC()}
+
+	start := time.Now()
+	var text string
+	var metadata model.ProviderMetadata
+	var err error
+	if mt, ok := t.(api.MetadataTranscriber); ok {
+		text, metadata, err = mt.TranscriptWithMetadata(opts.Sample)
+	} else {
+		text, err = t.Transcript(opts.Sample)
+	}
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Model = metadata.Model
+	result.WER = textdiff.WER(opts.Reference, text)
+	result.Cost = metadata.Cost
+	if result.Cost == 0 {
+		result.Cost = opts.Rates[name] * opts.AudioSeconds / 60
+	}
+	return result
+}
+
+// Recommend picks the most accurate provider, among equally accurate ones the cheapest and then the fastest.
+func Recommend(results []Result) (Result, error) {
+	var measured []Result
+	for _, r := range results {
+		if r.Error == "" {
+			measured = append(measured, r)
+		}
+	}
+	if len(measured) == 0 {
+		return Result{}, ErrNoResults
+	}
+
+	best := measured[0].WER
+	for _, r := range measured {
+		if r.WER < best {
+			best = r.WER
+		}
+	}
+	var accurate []Result
+	for _, r := range measured {
+		if r.WER <= best+SameAccuracy {
+			accurate = append(accurate, r)
+		}
+	}
+	sort.SliceStable(accurate, func(i, j int) bool {
+		if accurate[i].Cost != accurate[j].Cost {
+			return accurate[i].Cost < accurate[j].Cost
+		}
+		return accurate[i].Latency < accurate[j].Latency
+	})
+	return accurate[0], nil
+}
+
+// DefaultPath is the results file in the data directory of the project.
+func DefaultPath() (string, error) {
+	projectRoot, err := files.GetProjectRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(projectRoot, "data", ResultsFile), nil
+}
+
+// Load reads the results kept at path, it is empty when nothing was measured yet.
+func Load(path string) ([]Result, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %v", path, err)
+	}
+	return results, nil
+}
+
+// Save keeps results at path, replacing the earlier results of the same providers.
+func Save(path string, results []Result) error {
+	kept, err := Load(path)
+	if err != nil {
+		return err
+	}
+	byProvider := make(map[string]Result)
+	for _, r := range append(kept, results...) {
+		byProvider[r.Provider] = r
+	}
+	merged := make([]Result, 0, len(byProvider))
+	for _, r := range byProvider {
+		merged = append(merged, r)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Provider < merged[j].Provider })
+
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	return files.WriteFileAtomic(path, data, 0644)
+}
+
+// Recommended returns the recommended provider of the results kept at path.
+func Recommended(path string) (string, error) {
+	results, err := Load(path)
+	if err != nil {
+		return "", err
+	}
+	r, err := Recommend(results)
+	if err != nil {
+		return "", err
+	}
+	return r.Provider, nil
+}
@@ -0,0 +1,131 @@
+package benchmark
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeTranscriber struct {
+	text string
+	err  error
+}
+
+func (f fakeTranscriber) Transcript(inputFilePath string) (string, error) {
+	return f.text, f.err
+}
+
+func TestRun(t *testing.T) {
+	opts := Options{
+		Sample:       "jfk.wav",
+		Reference:    "so ask not what your country can do for you",
+		AudioSeconds: 120,
+		Rates:        map[string]float64{"openai": 0.006},
+	}
+
+	tests := []struct {
+		name     string
+		provider string
+		t        fakeTranscriber
+		wantWER  float64
+		wantCost float64
+		wantErr  bool
+	}{
+		{name: "exact", provider: "openai", t: fakeTranscriber{text: "So, ask not what your country can do for you."}, wantCost: 0.012},
+		{name: "one word wrong", provider: "whisper_cpp", t: fakeTranscriber{text: "so ask not what your county can do for you"}, wantWER: 0.1},
+		{name: "failed", provider: "openai", t: fakeTranscriber{err: errors.New("unauthorized")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Run(tt.provider, tt.t, opts)
+			if (r.Error != "") != tt.wantErr {
+				t.Fatalf("Run() error = %q, wantErr %v", r.Error, tt.wantErr)
+			}
+			if r.Provider != tt.provider || r.WER != tt.wantWER || r.Cost < tt.wantCost-1e-9 || r.Cost > tt.wantCost+1e-9 {
+				t.Errorf("Run() = %+v, want WER %v and cost %v", r, tt.wantWER, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Result
+		want    string
+		wantErr error
+	}{
+		{
+			name: "most accurate",
+			results: []Result{
+				{Provider: "openai", WER: 0.3, Cost: 0},
+				{Provider: "whisper_cpp", WER: 0.1, Cost: 0.01},
+			},
+			want: "whisper_cpp",
+		},
+		{
+			name: "cheapest among equally accurate",
+			results: []Result{
+				{Provider: "openai", WER: 0.1, Cost: 0.01},
+				{Provider: "whisper_cpp", WER: 0.12, Cost: 0},
+			},
+			want: "whisper_cpp",
+		},
+		{
+			name: "fastest among equally priced",
+			results: []Result{
+				{Provider: "openai", WER: 0.1, Latency: 2 * time.Second},
+				{Provider: "whisper_cpp", WER: 0.1, Latency: time.Second},
+			},
+			want: "whisper_cpp",
+		},
+		{
+			name: "failed providers are skipped",
+			results: []Result{
+				{Provider: "openai", Error: "unauthorized"},
+				{Provider: "whisper_cpp", WER: 0.5},
+			},
+			want: "whisper_cpp",
+		},
+		{
+			name:    "nothing measured",
+			results: []Result{{Provider: "openai", Error: "unauthorized"}},
+			wantErr: ErrNoResults,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Recommend(tt.results)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Recommend() error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Provider != tt.want {
+				t.Errorf("Recommend() = %s, want %s", got.Provider, tt.want)
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ResultsFile)
+	if _, err := Recommended(path); !errors.Is(err, ErrNoResults) {
+		t.Fatalf("Recommended() without results error = %v, want %v", err, ErrNoResults)
+	}
+
+	if err := Save(path, []Result{{Provider: "openai", WER: 0.2}, {Provider: "whisper_cpp", WER: 0.4}}); err != nil {
+		t.Fatal(err)
+	}
+	// A later run of one provider replaces only its own result
+	if err := Save(path, []Result{{Provider: "whisper_cpp", WER: 0.05}}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Load(path)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("Load() = %+v, %v, want both providers", results, err)
+	}
+	if got, err := Recommended(path); err != nil || got != "whisper_cpp" {
+		t.Errorf("Recommended() = %s, %v, want whisper_cpp", got, err)
+	}
+}
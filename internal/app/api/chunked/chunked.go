@@ -0,0 +1,399 @@
+package chunked
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/validation"
+	"tiktok-whisper/internal/app/audio"
+	"tiktok-whisper/internal/app/cleanup"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/paragraph"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultChunkSeconds keeps 128kbps mp3 chunks well below the 25MB upload limit of the OpenAI API.
+	DefaultChunkSeconds = 600
+	// promptLength is the number of characters of the previous chunk sent as prompt,
+	// whisper only considers the last 224 tokens of a prompt.
+	promptLength = 200
+	// minOverlap is the shortest repeated prompt tail removed from the start of the next chunk,
+	// shorter matches are more likely to be genuine repetitions.
+	minOverlap = 4
+)
+
+// errSkipped is the result of the chunks not transcribed because an earlier one failed.
+var errSkipped = errors.New("skipped after another chunk failed")
+
+// Transcriber splits long audio into chunks and transcribes them in order, passing the tail of
+// the text so far as the prompt of the next chunk, so context and spellings carry over the boundaries.
+type Transcriber struct {
+	inner        api.PromptTranscriber
+	chunkSeconds int
+	overlap      int
+	parallel     int
+
+	duration func(filePath string) (int, error)
+	split    func(inputFilePath string, outputPrefix string, chunkSeconds int, overlapSeconds int, durationSeconds int) ([]string, error)
+	tracker  *cleanup.Tracker
+}
+
+// NewTranscriber creates a new Transcriber instance, audio up to chunkSeconds long is passed through.
+func NewTranscriber(inner api.PromptTranscriber, chunkSeconds int) *Transcriber {
+	return &Transcriber{
+		inner:        inner,
+		chunkSeconds: chunkSeconds,
+		duration:     audio.GetAudioDuration,
+		split:        splitAudio,
+		tracker:      cleanup.Default(),
+	}
+}
+
+// SetOverlap lets every chunk reach seconds into the next one, so a word cut at a boundary is
+// heard whole once. Timed segments are cut at the middle of the overlap, text without timings
+// drops the start of a chunk repeating the end of the previous one.
+func (t *Transcriber) SetOverlap(seconds int) {
+	t.overlap = seconds
+}
+
+// SetParallel transcribes up to n chunks at the same time. The chunks don't get the text of the
+// previous one as their prompt then, as it isn't known yet.
+func (t *Transcriber) SetParallel(n int) {
+	t.parallel = n
+}
+
+func splitAudio(inputFilePath string, outputPrefix string, chunkSeconds int, overlapSeconds int, durationSeconds int) ([]string, error) {
+	if overlapSeconds == 0 {
+		return audio.SplitAudio(inputFilePath, outputPrefix, chunkSeconds)
+	}
+	return audio.SplitAudioOverlap(inputFilePath, outputPrefix, chunkSeconds, overlapSeconds, durationSeconds)
+}
+
+func (t *Transcriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := t.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata transcribes the chunks and merges their metadata.
+func (t *Transcriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return t.transcribe(inputFilePath, api.Options{}, nil)
+}
+
+// TranscriptWithOptions works like TranscriptWithMetadata and passes opts on to every chunk,
+// with NoContext no chunk gets the text of the previous one as its prompt.
+func (t *Transcriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return t.transcribe(inputFilePath, opts, nil)
+}
+
+// TranscriptContext works like TranscriptWithOptions with the options ctx carries.
+func (t *Transcriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return t.transcribe(inputFilePath, api.OptionsFrom(ctx), nil)
+}
+
+// TranscriptStream works like TranscriptWithMetadata and sends the text of each chunk as a segment
+// spanning the chunk once it is transcribed, so long audio shows progress every chunkSeconds.
+func (t *Transcriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	return t.transcribe(inputFilePath, api.Options{}, partials)
+}
+
+// transcribe sends each chunk's text to partials when it is not nil.
+func (t *Transcriber) transcribe(inputFilePath string, opts api.Options, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	duration, err := t.duration(inputFilePath)
+	if err != nil {
+		return "", model.ProviderMetadata{}, fmt.Errorf("get audio duration failed: %v", err)
+	}
+	if duration <= t.chunkSeconds+t.overlap {
+		text, metadata, _, err := t.transcribeChecked(inputFilePath, opts)
+		if err == nil && partials != nil && text != "" {
+			partials <- model.Segment{End: float64(duration), Text: text}
+		}
+		return text, metadata, err
+	}
+
+	job := inputFilePath + "#chunks"
+	defer func() {
+		if _, err := t.tracker.Release(job); err != nil {
+			logging.L().Error("Error removing chunks", "file", inputFilePath, "error", err)
+		}
+	}()
+
+	prefix := filepath.Join(t.tracker.Dir(), strings.TrimSuffix(filepath.Base(inputFilePath), filepath.Ext(inputFilePath))+"_chunk")
+	chunks, err := t.split(inputFilePath, prefix, t.chunkSeconds, t.overlap, duration)
+	if err != nil {
+		return "", model.ProviderMetadata{}, fmt.Errorf("split audio failed: %v", err)
+	}
+	// ffmpeg names the chunks itself, so they can only be tracked once they exist
+	for _, c := range chunks {
+		if err = t.tracker.Track(job, c); err != nil {
+			logging.L().Error("Error tracking chunk", "chunk", c, "error", err)
+		}
+	}
+
+	logging.L().Info("Transcribing in chunks", "file", inputFilePath, "chunks", len(chunks), "chunk_seconds", t.chunkSeconds, "overlap_seconds", t.overlap)
+
+	var ahead []chan chunkResult
+	if t.parallel > 1 {
+		var wait func()
+		ahead, wait = t.transcribeAhead(chunks, opts)
+		// the chunks are only released once no transcription reads them anymore
+		defer wait()
+	}
+
+	var text string
+	var metadata model.ProviderMetadata
+	for i, chunk := range chunks {
+		var r chunkResult
+		if ahead != nil {
+			r = <-ahead[i]
+		} else {
+			chunkOpts := opts
+			if !opts.NoContext {
+				chunkOpts.Prompt = promptTail(text, promptLength)
+			}
+			r.text, r.metadata, r.prompt, r.err = t.transcribeChecked(chunk, chunkOpts)
+		}
+		if r.err != nil {
+			return "", metadata, fmt.Errorf("transcribe chunk %d/%d failed: %w", i+1, len(chunks), r.err)
+		}
+		chunkText, chunkMetadata, prompt := r.text, r.metadata, r.prompt
+
+		offset := float64(i * t.chunkSeconds)
+		from, to := t.bounds(i, len(chunks))
+		segments := metadata.Segments
+		var kept int
+		for _, s := range chunkMetadata.Segments {
+			if s = shift(s, offset); s.Start >= from && s.Start < to {
+				segments = append(segments, s)
+				kept++
+			}
+		}
+		if t.overlap > 0 && len(chunkMetadata.Segments) > 0 {
+			// the segments heard twice are already dropped by their timings
+			chunkText = joinSegments(segments[len(segments)-kept:])
+			chunkMetadata.SegmentCount = kept
+			prompt = ""
+		} else if t.overlap > 0 {
+			prompt = promptTail(text, promptLength)
+		}
+		if i == 0 {
+			metadata = chunkMetadata
+		} else {
+			metadata.SegmentCount += chunkMetadata.SegmentCount
+			metadata.RerunChunks += chunkMetadata.RerunChunks
+		}
+		metadata.Segments = segments
+		stitched := stitch(text, chunkText, prompt)
+		if piece := strings.TrimSpace(strings.TrimPrefix(stitched, text)); partials != nil && piece != "" {
+			partials <- model.Segment{
+				Start: float64(i * t.chunkSeconds),
+				End:   float64(min((i+1)*t.chunkSeconds, duration)),
+				Text:  piece,
+			}
+		}
+		text = stitched
+	}
+
+	metadata.DurationSeconds = float64(duration)
+	metadata.Chunks = len(chunks)
+	return text, metadata, nil
+}
+
+// chunkResult is a transcribed chunk, prompt is the prompt its text was transcribed with.
+type chunkResult struct {
+	text     string
+	metadata model.ProviderMetadata
+	prompt   string
+	err      error
+}
+
+// transcribeAhead transcribes the chunks in order with t.parallel workers, each chunk's result is sent
+// to its channel. Once a chunk failed the remaining ones are skipped, wait blocks until all are done.
+func (t *Transcriber) transcribeAhead(chunks []string, opts api.Options) (results []chan chunkResult, wait func()) {
+	opts.Prompt = ""
+	next := make(chan int, len(chunks))
+	results = make([]chan chunkResult, len(chunks))
+	for i := range chunks {
+		next <- i
+		results[i] = make(chan chunkResult, 1)
+	}
+	close(next)
+
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < min(t.parallel, len(chunks)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if failed.Load() {
+					results[i] <- chunkResult{err: errSkipped}
+					continue
+				}
+				var r chunkResult
+				r.text, r.metadata, r.prompt, r.err = t.transcribeChecked(chunks[i], opts)
+				if r.err != nil {
+					failed.Store(true)
+				}
+				results[i] <- r
+			}
+		}()
+	}
+	return results, wg.Wait
+}
+
+// bounds returns the part of the whole audio the segments of chunk i are kept from, a segment
+// starting in the overlap of two chunks belongs to the one whose middle it is closer to.
+func (t *Transcriber) bounds(i int, chunks int) (from float64, to float64) {
+	from, to = math.Inf(-1), math.Inf(1)
+	if t.overlap == 0 {
+		return from, to
+	}
+	half := float64(t.overlap) / 2
+	if i > 0 {
+		from = float64(i*t.chunkSeconds) + half
+	}
+	if i < chunks-1 {
+		to = float64((i+1)*t.chunkSeconds) + half
+	}
+	return from, to
+}
+
+// joinSegments returns the text of segments, separated by spaces where the language needs them.
+func joinSegments(segments []model.Segment) string {
+	var text string
+	for _, s := range segments {
+		text = stitch(text, s.Text, "")
+	}
+	return text
+}
+
+// transcribeChecked transcribes a chunk and re-runs it with api.StrictOptions when its text is stuck
+// in a repetition loop and the inner transcriber takes options. The re-run is kept unless it loops
+// as well, it returns the prompt the kept text was transcribed with.
+func (t *Transcriber) transcribeChecked(chunk string, opts api.Options) (string, model.ProviderMetadata, string, error) {
+	text, metadata, err := t.transcribeChunk(chunk, opts)
+	if err != nil {
+		return text, metadata, opts.Prompt, err
+	}
+	loop := validation.Repetition(validation.Result{Text: text})
+	ot, ok := t.inner.(api.OptionsTranscriber)
+	if loop == "" || !ok {
+		return text, metadata, opts.Prompt, nil
+	}
+
+	logging.L().Warn("Chunk looks hallucinated, re-running it with strict settings", "chunk", chunk, "issue", loop)
+	strictText, strictMetadata, err := ot.TranscriptWithOptions(chunk, api.StrictOptions)
+	if err != nil {
+		logging.L().Warn("Re-run of chunk failed, keeping the first result", "chunk", chunk, "error", err)
+		return text, metadata, opts.Prompt, nil
+	}
+	if validation.Repetition(validation.Result{Text: strictText}) != "" {
+		logging.L().Warn("Re-run of chunk loops as well, keeping the first result", "chunk", chunk)
+		return text, metadata, opts.Prompt, nil
+	}
+	strictMetadata.RerunChunks = 1
+	return strictText, strictMetadata, "", nil
+}
+
+// transcribeChunk passes opts on when the inner transcriber takes them, otherwise only the prompt.
+func (t *Transcriber) transcribeChunk(chunk string, opts api.Options) (string, model.ProviderMetadata, error) {
+	if opts == (api.Options{}) {
+		return t.inner.TranscriptWithMetadata(chunk)
+	}
+	if ot, ok := t.inner.(api.OptionsTranscriber); ok {
+		return ot.TranscriptWithOptions(chunk, opts)
+	}
+	return t.inner.TranscriptWithPrompt(chunk, opts.Prompt)
+}
+
+// shift moves the timings of a chunk's segment to its position in the whole audio.
+func shift(s model.Segment, offset float64) model.Segment {
+	s.Start += offset
+	s.End += offset
+	if len(s.Words) > 0 {
+		words := make([]model.Word, len(s.Words))
+		for i, w := range s.Words {
+			w.Start += offset
+			w.End += offset
+			words[i] = w
+		}
+		s.Words = words
+	}
+	return s
+}
+
+// promptTail returns at most maxLength characters from the end of text, starting
+// after a sentence or word boundary when the cut falls inside the text.
+func promptTail(text string, maxLength int) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+
+	runes := []rune(text)
+	tail := runes[len(runes)-maxLength:]
+	for i, r := range tail {
+		if i > len(tail)/2 {
+			break
+		}
+		if strings.ContainsRune("。！？!?.，,；;", r) || unicode.IsSpace(r) {
+			return strings.TrimSpace(string(tail[i+1:]))
+		}
+	}
+	return string(tail)
+}
+
+// stitch appends next to text. Whisper sometimes repeats the end of its prompt at the start
+// of the next chunk, such a repetition of at least minOverlap characters is dropped.
+func stitch(text string, next string, prompt string) string {
+	next = strings.TrimSpace(next)
+	if text == "" {
+		return next
+	}
+	if next == "" {
+		return text
+	}
+
+	promptRunes := []rune(prompt)
+	nextRunes := []rune(next)
+	for k := min(len(promptRunes), len(nextRunes)); k >= minOverlap; k-- {
+		if string(promptRunes[len(promptRunes)-k:]) == string(nextRunes[:k]) {
+			next = strings.TrimSpace(string(nextRunes[k:]))
+			break
+		}
+	}
+	if next == "" {
+		return text
+	}
+
+	if paragraph.NeedsSpace(text, next) {
+		return text + " " + next
+	}
+	return text + next
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// HealthCheck runs the health check of the inner transcriber, it passes when that has none.
+func (t *Transcriber) HealthCheck() error {
+	if hc, ok := t.inner.(provider.HealthChecker); ok {
+		return hc.HealthCheck()
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package chunked
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/cleanup"
+	"tiktok-whisper/internal/app/model"
+)
+
+func Test_promptTail(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		want      string
+	}{
+		{name: "short text", text: " whole text ", maxLength: 20, want: "whole text"},
+		{name: "cut at word boundary", text: "alpha beta gamma delta", maxLength: 13, want: "gamma delta"},
+		{name: "cut at sentence boundary", text: "第一句话。第二句话。第三句", maxLength: 8, want: "第三句"},
+		{name: "no boundary in the first half", text: "abcdefghij klm", maxLength: 12, want: "cdefghij klm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := promptTail(tt.text, tt.maxLength); got != tt.want {
+				t.Errorf("promptTail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_stitch(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		next   string
+		prompt string
+		want   string
+	}{
+		{name: "first chunk", text: "", next: " hello ", prompt: "", want: "hello"},
+		{name: "latin words get a space", text: "the end of one", next: "and the start", prompt: "end of one", want: "the end of one and the start"},
+		{name: "cjk joins directly", text: "今天天气", next: "很好。", prompt: "今天天气", want: "今天天气很好。"},
+		{name: "echoed prompt tail is dropped", text: "we talk about whisper models", next: "whisper models are great", prompt: "about whisper models", want: "we talk about whisper models are great"},
+		{name: "echoed cjk tail is dropped", text: "我们讨论语音识别模型", next: "语音识别模型的效果", prompt: "我们讨论语音识别模型", want: "我们讨论语音识别模型的效果"},
+		{name: "short overlap is kept", text: "it is", next: "is it", prompt: "it is", want: "it is is it"},
+		{name: "only the echo", text: "same words here", next: "words here", prompt: "same words here", want: "same words here"},
+		{name: "empty chunk", text: "text", next: "  ", prompt: "text", want: "text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stitch(tt.text, tt.next, tt.prompt); got != tt.want {
+				t.Errorf("stitch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeTranscriber struct {
+	texts    map[string]string
+	segments map[string][]model.Segment
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (f *fakeTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := f.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (f *fakeTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return f.TranscriptWithPrompt(inputFilePath, "")
+}
+
+func (f *fakeTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	f.mu.Lock()
+	f.prompts = append(f.prompts, prompt)
+	f.mu.Unlock()
+	text, ok := f.texts[inputFilePath]
+	if !ok {
+		return "", model.ProviderMetadata{}, fmt.Errorf("no such chunk %s", inputFilePath)
+	}
+	return text, model.ProviderMetadata{Provider: "fake", SegmentCount: 2, Segments: f.segments[inputFilePath]}, nil
+}
+
+func TestTranscriber_TranscriptWithMetadata(t *testing.T) {
+	inner := &fakeTranscriber{texts: map[string]string{
+		"short.mp3": "short audio",
+		"c0":        "first chunk ends here.",
+		"c1":        "ends here. second chunk",
+		"c2":        "third chunk",
+	}}
+
+	tr := NewTranscriber(inner, 600)
+	tr.tracker = cleanup.NewTracker(t.TempDir())
+	tr.duration = func(filePath string) (int, error) {
+		if filePath == "short.mp3" {
+			return 600, nil
+		}
+		return 1500, nil
+	}
+	tr.split = func(inputFilePath string, outputPrefix string, chunkSeconds int, overlapSeconds int, durationSeconds int) ([]string, error) {
+		return []string{"c0", "c1", "c2"}, nil
+	}
+
+	text, metadata, err := tr.TranscriptWithMetadata("short.mp3")
+	if err != nil || text != "short audio" || metadata.Chunks != 0 {
+		t.Fatalf("TranscriptWithMetadata(short) = %q, %+v, %v", text, metadata, err)
+	}
+
+	inner.prompts = nil
+	text, metadata, err = tr.TranscriptWithMetadata("long.mp3")
+	if err != nil {
+		t.Fatalf("TranscriptWithMetadata() error = %v", err)
+	}
+	if want := "first chunk ends here. second chunk third chunk"; text != want {
+		t.Errorf("TranscriptWithMetadata() text = %q, want %q", text, want)
+	}
+	if want := []string{"", "first chunk ends here.", "first chunk ends here. second chunk"}; !reflect.DeepEqual(inner.prompts, want) {
+		t.Errorf("prompts = %q, want %q", inner.prompts, want)
+	}
+	if metadata.Chunks != 3 || metadata.SegmentCount != 6 || metadata.DurationSeconds != 1500 || metadata.Provider != "fake" {
+		t.Errorf("TranscriptWithMetadata() metadata = %+v", metadata)
+	}
+}
+
+func TestTranscriber_TranscriptStream(t *testing.T) {
+	inner := &fakeTranscriber{texts: map[string]string{
+		"c0": "first chunk ends here.",
+		"c1": "ends here. second chunk",
+		"c2": "third chunk",
+	}}
+
+	tr := NewTranscriber(inner, 600)
+	tr.tracker = cleanup.NewTracker(t.TempDir())
+	tr.duration = func(filePath string) (int, error) { return 1500, nil }
+	tr.split = func(inputFilePath string, outputPrefix string, chunkSeconds int, overlapSeconds int, durationSeconds int) ([]string, error) {
+		return []string{"c0", "c1", "c2"}, nil
+	}
+
+	var got []model.Segment
+	text, _, err := provider.Stream(tr, "long.mp3", func(s model.Segment) {
+		got = append(got, s)
+	})
+	if err != nil || text != "first chunk ends here. second chunk third chunk" {
+		t.Fatalf("Stream() = %q, %v", text, err)
+	}
+
+	want := []model.Segment{
+		{Start: 0, End: 600, Text: "first chunk ends here."},
+		{Start: 600, End: 1200, Text: "second chunk"},
+		{Start: 1200, End: 1500, Text: "third chunk"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stream() partials = %+v, want %+v", got, want)
+	}
+}
+
+func TestTranscriber_Overlap(t *testing.T) {
+	tests := []struct {
+		name         string
+		texts        map[string]string
+		segments     map[string][]model.Segment
+		want         string
+		wantSegments []model.Segment
+	}{
+		{
+			name:  "timed segments are cut in the middle of the overlap",
+			texts: map[string]string{"c0": "one two three", "c1": "thr three four"},
+			segments: map[string][]model.Segment{
+				"c0": {{Start: 0, End: 300, Text: "one"}, {Start: 300, End: 590, Text: "two"}, {Start: 598, End: 606, Text: "three"}},
+				"c1": {{Start: 0, End: 2, Text: "thr"}, {Start: 3, End: 6, Text: "three"}, {Start: 6, End: 100, Text: "four"}},
+			},
+			want: "one two three four",
+			wantSegments: []model.Segment{
+				{Start: 0, End: 300, Text: "one"}, {Start: 300, End: 590, Text: "two"},
+				{Start: 598, End: 606, Text: "three"}, {Start: 606, End: 700, Text: "four"},
+			},
+		},
+		{
+			name:  "text without timings drops the repeated start",
+			texts: map[string]string{"c0": "we talk about whisper models", "c1": "whisper models are great"},
+			want:  "we talk about whisper models are great",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTranscriber(&fakeTranscriber{texts: tt.texts, segments: tt.segments}, 600)
+			tr.SetOverlap(10)
+			tr.tracker = cleanup.NewTracker(t.TempDir())
+			tr.duration = func(filePath string) (int, error) { return 700, nil }
+			tr.split = func(inputFilePath string, outputPrefix string, chunkSeconds int, overlapSeconds int, durationSeconds int) ([]string, error) {
+				if overlapSeconds != 10 {
+					t.Errorf("split() overlap = %d, want 10", overlapSeconds)
+				}
+				return []string{"c0", "c1"}, nil
+			}
+
+			text, metadata, err := tr.TranscriptWithMetadata("long.mp3")
+			if err != nil || text != tt.want {
+				t.Fatalf("TranscriptWithMetadata() = %q, %v, want %q", text, err, tt.want)
+			}
+			if !reflect.DeepEqual(metadata.Segments, tt.wantSegments) {
+				t.Errorf("TranscriptWithMetadata() segments = %+v, want %+v", metadata.Segments, tt.wantSegments)
+			}
+		})
+	}
+}
+
+func TestTranscriber_Parallel(t *testing.T) {
+	texts := map[string]string{"c0": "first", "c1": "second", "c2": "third", "c3": "fourth"}
+	inner := &fakeTranscriber{texts: texts}
+
+	tr := NewTranscriber(inner, 600)
+	tr.SetParallel(3)
+	tr.tracker = cleanup.NewTracker(t.TempDir())
+	tr.duration = func(filePath string) (int, error) { return 2000, nil }
+	tr.split = func(inputFilePath string, outputPrefix string, chunkSeconds int, overlapSeconds int, durationSeconds int) ([]string, error) {
+		return []string{"c0", "c1", "c2", "c3"}, nil
+	}
+
+	text, metadata, err := tr.TranscriptWithMetadata("long.mp3")
+	if err != nil || text != "first second third fourth" || metadata.Chunks != 4 {
+		t.Fatalf("TranscriptWithMetadata() = %q, %+v, %v", text, metadata, err)
+	}
+	if want := []string{"", "", "", ""}; !reflect.DeepEqual(inner.prompts, want) {
+		t.Errorf("prompts = %q, want none", inner.prompts)
+	}
+
+	delete(texts, "c1")
+	if _, _, err = tr.TranscriptWithMetadata("long.mp3"); err == nil || !strings.Contains(err.Error(), "chunk 2/4") {
+		t.Errorf("TranscriptWithMetadata() error = %v, want chunk 2/4 failed", err)
+	}
+}
+
+// optionsTranscriber returns strictTexts when called with options.
+type optionsTranscriber struct {
+	fakeTranscriber
+	strictTexts map[string]string
+	strict      []api.Options
+}
+
+func (o *optionsTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	if opts != api.StrictOptions {
+		return o.TranscriptWithPrompt(inputFilePath, opts.Prompt)
+	}
+	o.strict = append(o.strict, opts)
+	return o.strictTexts[inputFilePath], model.ProviderMetadata{Provider: "fake"}, nil
+}
+
+func TestTranscriber_RerunsLoopingChunks(t *testing.T) {
+	loop := strings.Repeat("谢谢观看。", 6)
+	inner := &optionsTranscriber{
+		fakeTranscriber: fakeTranscriber{texts: map[string]string{
+			"c0": "第一段。",
+			"c1": loop,
+			"c2": loop,
+		}},
+		strictTexts: map[string]string{
+			"c1": "第二段。",
+			"c2": loop,
+		},
+	}
+
+	tr := NewTranscriber(inner, 600)
+	tr.tracker = cleanup.NewTracker(t.TempDir())
+	tr.duration = func(filePath string) (int, error) { return 1500, nil }
+	tr.split = func(inputFilePath string, outputPrefix string, chunkSeconds int, overlapSeconds int, durationSeconds int) ([]string, error) {
+		return []string{"c0", "c1", "c2"}, nil
+	}
+
+	text, metadata, err := tr.TranscriptWithMetadata("long.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// c1 is fixed by the re-run, c2 loops either way and keeps its first result
+	if want := "第一段。第二段。" + loop; text != want {
+		t.Errorf("TranscriptWithMetadata() text = %q, want %q", text, want)
+	}
+	if len(inner.strict) != 2 || metadata.RerunChunks != 1 {
+		t.Errorf("re-ran %d chunks, metadata.RerunChunks = %d, want 2 and 1", len(inner.strict), metadata.RerunChunks)
+	}
+}
@@ -0,0 +1,279 @@
+// Package conformance checks that a provider behaves the way the converter relies on: it reports who
+// transcribed, transcribes short speech, copes with unicode file names, rejects what it can't handle
+// with errors classified correctly and answers in time.
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	Pass Status = "PASS"
+	Fail Status = "FAIL"
+	Skip Status = "SKIP"
+)
+
+// Result is the outcome of a check with what was observed.
+type Result struct {
+	Check    string        `json:"check"`
+	Status   Status        `json:"status"`
+	Detail   string        `json:"detail,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Options configures a run of the suite.
+type Options struct {
+	// Provider is the name the transcriber must report in its metadata.
+	Provider string
+	// Sample is a short speech recording the provider must transcribe.
+	Sample string
+	// MaxFileBytes is the largest file the provider accepts, the large file check exceeds it.
+	// The check is skipped when it is zero.
+	MaxFileBytes int64
+	// Timeout bounds every transcription of the suite.
+	Timeout time.Duration
+}
+
+// DefaultTimeout bounds a transcription when Options.Timeout is zero.
+const DefaultTimeout = 2 * time.Minute
+
+// Passed reports whether no check failed.
+func Passed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return false
+		}
+	}
+	return true
+}
+
+// Run runs the checks against t in order: info, health, small_file, unicode_filename,
+// large_file, error_mapping and timeout.
+func Run(t api.Transcriber, opts Options) []Result {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	s := &suite{t: t, opts: opts}
+
+	sample := s.call(opts.Sample)
+	return []Result{
+		s.info(sample),
+		s.health(),
+		s.smallFile(sample),
+		s.unicodeFilename(sample),
+		s.largeFile(),
+		s.errorMapping(),
+		s.timeout(sample),
+	}
+}
+
+type suite struct {
+	t    api.Transcriber
+	opts Options
+}
+
+// outcome is what a transcription returned, timedOut is set when it didn't return within the timeout.
+type outcome struct {
+	text     string
+	metadata model.ProviderMetadata
+	err      error
+	elapsed  time.Duration
+	timedOut bool
+}
+
+func (s *suite) call(path string) outcome {
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		var o outcome
+		if mt, ok := s.t.(api.MetadataTranscriber); ok {
+			o.text, o.metadata, o.err = mt.TranscriptWithMetadata(path)
+		} else {
+			o.text, o.err = s.t.Transcript(path)
+		}
+		done <- o
+	}()
+
+	select {
+	case o := <-done:
+		o.elapsed = time.Since(start)
+		return o
+	case <-time.After(s.opts.Timeout):
+		// The transcription is abandoned, transcribers have no way to be cancelled
+		return outcome{err: fmt.Errorf("no result after %v", s.opts.Timeout), elapsed: s.opts.Timeout, timedOut: true}
+	}
+}
+
+func (s *suite) info(sample outcome) Result {
+	r := Result{Check: "info", Status: Pass, Duration: sample.elapsed}
+	switch {
+	case sample.err != nil:
+		r.Status, r.Detail = Skip, "the sample wasn't transcribed"
+	case sample.metadata.Provider != s.opts.Provider:
+		r.Status, r.Detail = Fail, fmt.Sprintf("metadata reports provider %q, want %q", sample.metadata.Provider, s.opts.Provider)
+	case sample.metadata.Model == "":
+		r.Status, r.Detail = Fail, "metadata reports no model"
+	default:
+		r.Detail = fmt.Sprintf("provider %s, model %s", sample.metadata.Provider, sample.metadata.Model)
+	}
+	return r
+}
+
+func (s *suite) health() Result {
+	hc, ok := s.t.(provider.HealthChecker)
+	if !ok {
+		return Result{Check: "health", Status: Skip, Detail: "the provider has no health check"}
+	}
+
+	start := time.Now()
+	if err := hc.HealthCheck(); err != nil {
+		return Result{Check: "health", Status: Fail, Detail: err.Error(), Duration: time.Since(start)}
+	}
+	return Result{Check: "health", Status: Pass, Duration: time.Since(start)}
+}
+
+func (s *suite) smallFile(sample outcome) Result {
+	return transcribed("small_file", sample)
+}
+
+func (s *suite) unicodeFilename(sample outcome) Result {
+	if sample.err != nil {
+		return Result{Check: "unicode_filename", Status: Skip, Detail: "the sample wasn't transcribed"}
+	}
+
+	dir, err := os.MkdirTemp("", "v2t-conformance-")
+	if err != nil {
+		return Result{Check: "unicode_filename", Status: Fail, Detail: err.Error()}
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "样本 ünïcødé 🎙"+filepath.Ext(s.opts.Sample))
+	if err = copyFile(s.opts.Sample, path); err != nil {
+		return Result{Check: "unicode_filename", Status: Fail, Detail: err.Error()}
+	}
+	return transcribed("unicode_filename", s.call(path))
+}
+
+func (s *suite) largeFile() Result {
+	if s.opts.MaxFileBytes <= 0 {
+		return Result{Check: "large_file", Status: Skip, Detail: "no file size limit given"}
+	}
+
+	dir, err := os.MkdirTemp("", "v2t-conformance-")
+	if err != nil {
+		return Result{Check: "large_file", Status: Fail, Detail: err.Error()}
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "large"+filepath.Ext(s.opts.Sample))
+	f, err := os.Create(path)
+	if err == nil {
+		err = f.Truncate(s.opts.MaxFileBytes + 1)
+		f.Close()
+	}
+	if err != nil {
+		return Result{Check: "large_file", Status: Fail, Detail: err.Error()}
+	}
+	return rejected("large_file", s.call(path))
+}
+
+func (s *suite) errorMapping() Result {
+	dir, err := os.MkdirTemp("", "v2t-conformance-")
+	if err != nil {
+		return Result{Check: "error_mapping", Status: Fail, Detail: err.Error()}
+	}
+	defer os.RemoveAll(dir)
+
+	corrupt := filepath.Join(dir, "corrupt"+filepath.Ext(s.opts.Sample))
+	if err = os.WriteFile(corrupt, []byte("this is not audio"), 0644); err != nil {
+		return Result{Check: "error_mapping", Status: Fail, Detail: err.Error()}
+	}
+
+	r := Result{Check: "error_mapping", Status: Pass}
+	var details []string
+	for _, path := range []string{corrupt, filepath.Join(dir, "missing.mp3")} {
+		o := s.call(path)
+		r.Duration += o.elapsed
+		one := rejected("error_mapping", o)
+		if one.Status == Fail {
+			r.Status = Fail
+		}
+		details = append(details, filepath.Base(path)+": "+one.Detail)
+	}
+	r.Detail = strings.Join(details, "; ")
+	return r
+}
+
+func (s *suite) timeout(sample outcome) Result {
+	r := Result{Check: "timeout", Status: Pass, Duration: sample.elapsed}
+	if sample.timedOut {
+		r.Status, r.Detail = Fail, sample.err.Error()
+		return r
+	}
+	r.Detail = fmt.Sprintf("the sample took %v of %v", sample.elapsed.Round(time.Millisecond), s.opts.Timeout)
+	return r
+}
+
+// transcribed passes when the call returned text.
+func transcribed(check string, o outcome) Result {
+	r := Result{Check: check, Status: Pass, Duration: o.elapsed}
+	switch {
+	case o.err != nil:
+		r.Status, r.Detail = Fail, o.err.Error()
+	case strings.TrimSpace(o.text) == "":
+		r.Status, r.Detail = Fail, "no text"
+	default:
+		r.Detail = fmt.Sprintf("%d characters", len([]rune(o.text)))
+	}
+	return r
+}
+
+// rejected passes when the call failed with an error that isn't retryable, as another attempt
+// or provider would fail on the same input.
+func rejected(check string, o outcome) Result {
+	r := Result{Check: check, Status: Pass, Duration: o.elapsed}
+	var te *provider.TranscriptionError
+	switch {
+	case o.timedOut:
+		r.Status, r.Detail = Fail, o.err.Error()
+	case o.err == nil:
+		r.Status, r.Detail = Fail, "accepted, want an error"
+	case provider.IsRetryable(o.err):
+		r.Status, r.Detail = Fail, "rejected with a retryable error: "+o.err.Error()
+	case errors.As(o.err, &te):
+		r.Detail = "rejected as permanent"
+	default:
+		r.Detail = "rejected, the error is unclassified and treated as permanent"
+	}
+	return r
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,92 @@
+package conformance
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/testutil"
+	"time"
+)
+
+// fakeProvider transcribes files containing "audio", retryable makes it misclassify other files.
+type fakeProvider struct {
+	name      string
+	retryable bool
+	delay     time.Duration
+	healthErr error
+}
+
+func (f *fakeProvider) Transcript(inputFilePath string) (string, error) {
+	text, _, err := f.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (f *fakeProvider) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	time.Sleep(f.delay)
+	metadata := model.ProviderMetadata{Provider: f.name, Model: "tiny"}
+	data, err := os.ReadFile(inputFilePath)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(f.name, f.retryable, err)
+	}
+	if string(data) != "audio" {
+		return "", metadata, provider.NewTranscriptionError(f.name, f.retryable, errors.New("invalid audio"))
+	}
+	return "ask not what your country can do for you", metadata, nil
+}
+
+func (f *fakeProvider) HealthCheck() error {
+	return f.healthErr
+}
+
+func TestRun(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	sample := filepath.Join(t.TempDir(), "jfk.wav")
+	os.WriteFile(sample, []byte("audio"), 0644)
+
+	tests := []struct {
+		name     string
+		provider *fakeProvider
+		timeout  time.Duration
+		want     map[string]Status
+	}{
+		{
+			name:     "conforming",
+			provider: &fakeProvider{name: "fake"},
+			want: map[string]Status{"info": Pass, "health": Pass, "small_file": Pass, "unicode_filename": Pass,
+				"large_file": Pass, "error_mapping": Pass, "timeout": Pass},
+		},
+		{
+			name:     "misclassified errors",
+			provider: &fakeProvider{name: "fake", retryable: true, healthErr: errors.New("model missing")},
+			want: map[string]Status{"info": Pass, "health": Fail, "small_file": Pass, "unicode_filename": Pass,
+				"large_file": Fail, "error_mapping": Fail, "timeout": Pass},
+		},
+		{
+			name:     "wrong name",
+			provider: &fakeProvider{name: "other"},
+			want:     map[string]Status{"info": Fail, "small_file": Pass},
+		},
+		{
+			name:     "too slow",
+			provider: &fakeProvider{name: "fake", delay: 50 * time.Millisecond},
+			timeout:  10 * time.Millisecond,
+			want:     map[string]Status{"info": Skip, "small_file": Fail, "unicode_filename": Skip, "timeout": Fail},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Run(tt.provider, Options{Provider: "fake", Sample: sample, MaxFileBytes: 1 << 10, Timeout: tt.timeout})
+			if len(results) != 7 {
+				t.Fatalf("Run() returned %d results, want 7", len(results))
+			}
+			for _, r := range results {
+				if want, ok := tt.want[r.Check]; ok && r.Status != want {
+					t.Errorf("%s = %s (%s), want %s", r.Check, r.Status, r.Detail, want)
+				}
+			}
+		})
+	}
+}
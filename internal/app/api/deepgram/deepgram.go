@@ -0,0 +1,307 @@
+// Package deepgram transcribes with the Deepgram API: files are sent to the /v1/listen REST
+// endpoint, streaming sends them over its websocket to report the segments as they are transcribed.
+package deepgram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/requestmeta"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/config/secrets"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+const providerName = "deepgram"
+
+const (
+	defaultURL   = "https://api.deepgram.com"
+	defaultModel = "nova-2"
+)
+
+const healthCheckTimeout = 10 * time.Second
+
+// DeepgramTranscriber transcribes with one Deepgram model.
+type DeepgramTranscriber struct {
+	cfg      config.DeepgramConfig
+	key      string
+	baseURL  string
+	client   *http.Client
+	language string
+	request  *model.RequestMetadata
+}
+
+// NewDeepgramTranscriber creates the transcriber of cfg authenticated with key, requests are sent with client.
+func NewDeepgramTranscriber(cfg config.DeepgramConfig, key string, client *http.Client) *DeepgramTranscriber {
+	baseURL := strings.TrimSuffix(cfg.URL, "/")
+	if baseURL == "" {
+		baseURL = defaultURL
+	}
+	if cfg.Model == "" {
+		cfg.Model = defaultModel
+	}
+	return &DeepgramTranscriber{
+		cfg:     cfg,
+		key:     key,
+		baseURL: baseURL,
+		client:  client,
+		request: config.GetProviders().For(providerName).Record(),
+	}
+}
+
+// New creates the transcriber of deepgram in providers.yaml, the key is resolved by secrets.Key.
+func New() (*DeepgramTranscriber, error) {
+	key, err := secrets.Key(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return NewDeepgramTranscriber(config.GetProviders().For(providerName).Deepgram, key, requestmeta.NewClient(providerName)), nil
+}
+
+// SetLanguage sets the language of the audio when providers.yaml sets none, it is detected when language is empty.
+func (dt *DeepgramTranscriber) SetLanguage(language string) {
+	dt.language = language
+}
+
+// SupportedFormats are the containers Deepgram decodes itself, other audio is converted to mp3.
+func (dt *DeepgramTranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"mp3", "m4a", "mp4", "wav", "webm", "ogg", "flac"}}
+}
+
+func (dt *DeepgramTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := dt.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and also reports the model, language, speakers and
+// segments with the timings of their words.
+func (dt *DeepgramTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return dt.transcribe(context.Background(), inputFilePath)
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata, Deepgram takes no prompt so it is ignored.
+func (dt *DeepgramTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return dt.transcribe(context.Background(), inputFilePath)
+}
+
+// TranscriptWithOptions works like TranscriptWithMetadata, the decoding options have no equivalent
+// in the API and are ignored.
+func (dt *DeepgramTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return dt.transcribe(context.Background(), inputFilePath)
+}
+
+// TranscriptContext works like TranscriptWithMetadata and sends the request with ctx.
+func (dt *DeepgramTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return dt.transcribe(ctx, inputFilePath)
+}
+
+// word is a word of a response, PunctuatedWord is the word as smart_format wrote it.
+type word struct {
+	Word           string  `json:"word"`
+	PunctuatedWord string  `json:"punctuated_word"`
+	Start          float64 `json:"start"`
+	End            float64 `json:"end"`
+	Confidence     float64 `json:"confidence"`
+	Speaker        *int    `json:"speaker"`
+}
+
+// alternative is a transcript of a channel or of a streamed piece of audio.
+type alternative struct {
+	Transcript string  `json:"transcript"`
+	Confidence float64 `json:"confidence"`
+	Words      []word  `json:"words"`
+}
+
+// response is the response of /v1/listen with utterances, which are its segments.
+type response struct {
+	Metadata struct {
+		Duration float64 `json:"duration"`
+	} `json:"metadata"`
+	Results struct {
+		Channels []struct {
+			Alternatives     []alternative `json:"alternatives"`
+			DetectedLanguage string        `json:"detected_language"`
+		} `json:"channels"`
+		Utterances []struct {
+			Start      float64 `json:"start"`
+			End        float64 `json:"end"`
+			Transcript string  `json:"transcript"`
+			Speaker    *int    `json:"speaker"`
+			Words      []word  `json:"words"`
+		} `json:"utterances"`
+	} `json:"results"`
+}
+
+func (dt *DeepgramTranscriber) transcribe(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	metadata := dt.metadata()
+	query := dt.query()
+	query.Set("utterances", "true")
+	if metadata.Language == "" {
+		query.Set("detect_language", "true")
+	}
+
+	f, err := os.Open(inputFilePath)
+	if err != nil {
+		return "", metadata, fmt.Errorf("read audio failed: %v", err)
+	}
+	defer f.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dt.baseURL+"/v1/listen?"+query.Encode(), f)
+	if err != nil {
+		return "", metadata, err
+	}
+	req.Header.Set("Authorization", "Token "+dt.key)
+	req.Header.Set("Content-Type", "audio/*")
+
+	logging.L().Info("Starting transcription", "provider", providerName, "model", dt.cfg.Model, "file", inputFilePath)
+	resp, err := dt.client.Do(req)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, ctx.Err() == nil, fmt.Errorf("transcription request to %s failed: %w", dt.baseURL, err))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("read response of %s failed: %w", dt.baseURL, err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", metadata, provider.NewTranscriptionError(providerName, retryable(resp.StatusCode),
+			fmt.Errorf("%s answered %s: %s", dt.baseURL, resp.Status, errorMessage(data)))
+	}
+
+	var r response
+	if err = json.Unmarshal(data, &r); err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("parse response of %s failed: %v", dt.baseURL, err))
+	}
+	text := ""
+	if len(r.Results.Channels) > 0 {
+		channel := r.Results.Channels[0]
+		if channel.DetectedLanguage != "" {
+			metadata.Language = channel.DetectedLanguage
+		}
+		if len(channel.Alternatives) > 0 {
+			text = channel.Alternatives[0].Transcript
+		}
+	}
+	speakers := map[int]bool{}
+	metadata.Segments = make([]model.Segment, 0, len(r.Results.Utterances))
+	for _, u := range r.Results.Utterances {
+		segment := model.Segment{Start: u.Start, End: u.End, Text: strings.TrimSpace(u.Transcript), Words: dt.words(u.Words)}
+		if dt.cfg.Diarize && u.Speaker != nil {
+			segment.Speaker = strconv.Itoa(*u.Speaker)
+			speakers[*u.Speaker] = true
+		}
+		metadata.Segments = append(metadata.Segments, segment)
+	}
+	metadata.SegmentCount = len(metadata.Segments)
+	metadata.Speakers = len(speakers)
+	metadata.DurationSeconds = r.Metadata.Duration
+	return strings.TrimSpace(text), metadata, nil
+}
+
+// metadata returns the metadata every transcription starts with.
+func (dt *DeepgramTranscriber) metadata() model.ProviderMetadata {
+	return model.ProviderMetadata{
+		Provider: providerName,
+		Model:    dt.cfg.Model,
+		Language: dt.lang(),
+		Request:  dt.request,
+		Server:   &model.ServerInfo{Host: host(dt.baseURL)},
+	}
+}
+
+// lang is the language of the audio, empty when it is detected.
+func (dt *DeepgramTranscriber) lang() string {
+	if dt.cfg.Language != "" {
+		return dt.cfg.Language
+	}
+	if dt.language == "auto" {
+		return ""
+	}
+	return dt.language
+}
+
+// query returns the options shared by the REST endpoint and the websocket.
+func (dt *DeepgramTranscriber) query() url.Values {
+	query := url.Values{"model": {dt.cfg.Model}}
+	if dt.cfg.SmartFormatting() {
+		query.Set("smart_format", "true")
+	}
+	if dt.cfg.Diarize {
+		query.Set("diarize", "true")
+	}
+	if language := dt.lang(); language != "" {
+		query.Set("language", language)
+	}
+	return query
+}
+
+// words returns ws as the words of a segment, punctuated when smart_format is on.
+func (dt *DeepgramTranscriber) words(ws []word) []model.Word {
+	var words []model.Word
+	for _, w := range ws {
+		text := w.Word
+		if w.PunctuatedWord != "" {
+			text = w.PunctuatedWord
+		}
+		words = append(words, model.Word{Start: w.Start, End: w.End, Text: text, Probability: w.Confidence})
+	}
+	return words
+}
+
+// HealthCheck lists the projects of the key, which needs the API up and the key valid.
+func (dt *DeepgramTranscriber) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dt.baseURL+"/v1/projects", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+dt.key)
+	resp, err := dt.client.Do(req)
+	if err != nil {
+		return provider.NewTranscriptionError(providerName, true, fmt.Errorf("health check of %s failed: %w", dt.baseURL, err))
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return provider.NewTranscriptionError(providerName, retryable(resp.StatusCode), fmt.Errorf("%s is unhealthy: %s %s", dt.baseURL, resp.Status, errorMessage(data)))
+	}
+	return nil
+}
+
+// errorMessage returns the err_msg of an error response of Deepgram, the body when it isn't one.
+func errorMessage(body []byte) string {
+	var e struct {
+		ErrMsg string `json:"err_msg"`
+	}
+	if err := json.Unmarshal(body, &e); err == nil && e.ErrMsg != "" {
+		return e.ErrMsg
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// retryable reports whether another provider may succeed: rate limits, rejected keys, exhausted
+// credits and server errors are, requests rejected for their audio aren't.
+func retryable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests ||
+		status == http.StatusRequestTimeout || status == http.StatusUnauthorized ||
+		status == http.StatusPaymentRequired || status == http.StatusForbidden
+}
+
+// host returns the host of baseURL, baseURL itself when it doesn't parse.
+func host(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
@@ -0,0 +1,189 @@
+package deepgram
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+
+	"golang.org/x/net/websocket"
+)
+
+func writeAudio(t *testing.T, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "a.mp3")
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDeepgramTranscriber_TranscriptWithMetadata(t *testing.T) {
+	noFormatting := false
+	tests := []struct {
+		name          string
+		cfg           config.DeepgramConfig
+		language      string
+		status        int
+		body          string
+		wantQuery     url.Values
+		wantText      string
+		wantLanguage  string
+		wantSegments  []model.Segment
+		wantSpeakers  int
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{
+			name:   "detected language",
+			status: http.StatusOK,
+			body: `{"metadata":{"duration":3.5},"results":{"channels":[{"detected_language":"zh","alternatives":[{"transcript":"你好 世界"}]}],
+				"utterances":[{"start":0.1,"end":3.2,"transcript":"你好 世界","speaker":0,"words":[{"word":"你好","start":0.1,"end":0.9,"confidence":0.9,"speaker":0},{"word":"世界","start":1,"end":3.2,"confidence":0.8,"speaker":0}]}]}}`,
+			wantQuery:    url.Values{"model": {"nova-2"}, "smart_format": {"true"}, "utterances": {"true"}, "detect_language": {"true"}},
+			wantText:     "你好 世界",
+			wantLanguage: "zh",
+			wantSegments: []model.Segment{{Start: 0.1, End: 3.2, Text: "你好 世界", Words: []model.Word{
+				{Start: 0.1, End: 0.9, Text: "你好", Probability: 0.9}, {Start: 1, End: 3.2, Text: "世界", Probability: 0.8},
+			}}},
+		},
+		{
+			name:     "diarized with smart formatting",
+			cfg:      config.DeepgramConfig{Model: "nova-2-meeting", Diarize: true},
+			language: "en",
+			status:   http.StatusOK,
+			body: `{"metadata":{"duration":4},"results":{"channels":[{"alternatives":[{"transcript":"Hi, Bob. Hello!"}]}],"utterances":[
+				{"start":0,"end":1,"transcript":"Hi, Bob.","speaker":0,"words":[{"word":"hi","punctuated_word":"Hi,","start":0,"end":0.4,"speaker":0},{"word":"bob","punctuated_word":"Bob.","start":0.5,"end":1,"speaker":0}]},
+				{"start":2,"end":4,"transcript":"Hello!","speaker":1,"words":[{"word":"hello","punctuated_word":"Hello!","start":2,"end":4,"speaker":1}]}]}}`,
+			wantQuery:    url.Values{"model": {"nova-2-meeting"}, "smart_format": {"true"}, "diarize": {"true"}, "utterances": {"true"}, "language": {"en"}},
+			wantText:     "Hi, Bob. Hello!",
+			wantLanguage: "en",
+			wantSegments: []model.Segment{
+				{Start: 0, End: 1, Speaker: "0", Text: "Hi, Bob.", Words: []model.Word{{Start: 0, End: 0.4, Text: "Hi,"}, {Start: 0.5, End: 1, Text: "Bob."}}},
+				{Start: 2, End: 4, Speaker: "1", Text: "Hello!", Words: []model.Word{{Start: 2, End: 4, Text: "Hello!"}}},
+			},
+			wantSpeakers: 2,
+		},
+		{
+			name:         "configured language",
+			cfg:          config.DeepgramConfig{Language: "multi", SmartFormat: &noFormatting},
+			language:     "en",
+			status:       http.StatusOK,
+			body:         `{"results":{"channels":[{"alternatives":[{"transcript":""}]}],"utterances":[]}}`,
+			wantQuery:    url.Values{"model": {"nova-2"}, "utterances": {"true"}, "language": {"multi"}},
+			wantLanguage: "multi",
+			wantSegments: []model.Segment{},
+		},
+		{name: "out of credits", status: http.StatusPaymentRequired, body: `{"err_code":"ASR_PAYMENT_REQUIRED","err_msg":"Project does not have enough credits"}`, wantErr: true, wantRetryable: true},
+		{name: "corrupt audio", status: http.StatusBadRequest, body: `{"err_code":"Bad Request","err_msg":"corrupt or unsupported data"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/listen" || r.Header.Get("Authorization") != "Token secret" {
+					t.Errorf("sent to %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+				}
+				if body, _ := io.ReadAll(r.Body); len(body) != 100 {
+					t.Errorf("sent %d bytes of audio, want 100", len(body))
+				}
+				query = r.URL.Query()
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			tt.cfg.URL = srv.URL
+			dt := NewDeepgramTranscriber(tt.cfg, "secret", srv.Client())
+			dt.SetLanguage(tt.language)
+			text, metadata, err := dt.TranscriptWithMetadata(writeAudio(t, 100))
+			if tt.wantErr {
+				if err == nil || provider.IsRetryable(err) != tt.wantRetryable {
+					t.Errorf("TranscriptWithMetadata() error = %v, want retryable %v", err, tt.wantRetryable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TranscriptWithMetadata() error = %v", err)
+			}
+			if text != tt.wantText || metadata.Language != tt.wantLanguage || metadata.Speakers != tt.wantSpeakers ||
+				!reflect.DeepEqual(metadata.Segments, tt.wantSegments) {
+				t.Errorf("TranscriptWithMetadata() = %q in %q by %d speakers, %+v", text, metadata.Language, metadata.Speakers, metadata.Segments)
+			}
+			if !reflect.DeepEqual(query, tt.wantQuery) {
+				t.Errorf("query = %v, want %v", query, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestDeepgramTranscriber_TranscriptStream(t *testing.T) {
+	results := []string{
+		`{"type":"Results","is_final":false,"start":0,"duration":1,"channel":{"alternatives":[{"transcript":"hel"}]}}`,
+		`{"type":"Results","is_final":true,"start":0,"duration":1.5,"channel":{"alternatives":[{"transcript":"Hello.","words":[{"word":"hello","punctuated_word":"Hello.","start":0.2,"end":1,"confidence":0.9,"speaker":1}]}]}}`,
+		`{"type":"Results","is_final":true,"start":1.5,"duration":0.5,"channel":{"alternatives":[{"transcript":""}]}}`,
+		`{"type":"Results","is_final":true,"start":2,"duration":2,"channel":{"alternatives":[{"transcript":"Bye.","words":[{"word":"bye","punctuated_word":"Bye.","start":2.5,"end":3,"confidence":0.8,"speaker":0}]}]}}`,
+		`{"type":"Metadata","duration":4}`,
+	}
+	var received int
+	var query url.Values
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		query = ws.Request().URL.Query()
+		if ws.Request().Header.Get("Authorization") != "Token secret" {
+			t.Errorf("Authorization = %q", ws.Request().Header.Get("Authorization"))
+		}
+		for {
+			var data []byte
+			if err := websocket.Message.Receive(ws, &data); err != nil {
+				t.Errorf("receive: %v", err)
+				return
+			}
+			var control struct{ Type string }
+			if json.Unmarshal(data, &control) == nil && control.Type == "CloseStream" {
+				break
+			}
+			received += len(data)
+		}
+		for _, r := range results {
+			websocket.Message.Send(ws, r)
+		}
+	}))
+	defer srv.Close()
+
+	dt := NewDeepgramTranscriber(config.DeepgramConfig{URL: srv.URL, Diarize: true}, "secret", srv.Client())
+	dt.SetLanguage("en")
+	partials := make(chan model.Segment, 4)
+	text, metadata, err := dt.TranscriptStream(writeAudio(t, 3*chunkSize+1), partials)
+	if err != nil {
+		t.Fatalf("TranscriptStream() error = %v", err)
+	}
+	var streamed []model.Segment
+	for s := range partials {
+		streamed = append(streamed, s)
+	}
+
+	want := []model.Segment{
+		{Start: 0, End: 1.5, Speaker: "1", Text: "Hello.", Words: []model.Word{{Start: 0.2, End: 1, Text: "Hello.", Probability: 0.9}}},
+		{Start: 2, End: 4, Speaker: "0", Text: "Bye.", Words: []model.Word{{Start: 2.5, End: 3, Text: "Bye.", Probability: 0.8}}},
+	}
+	if text != "Hello.\nBye." || !reflect.DeepEqual(streamed, want) || !reflect.DeepEqual(metadata.Segments, want) {
+		t.Errorf("TranscriptStream() = %q, streamed %+v", text, streamed)
+	}
+	if metadata.Speakers != 2 || metadata.DurationSeconds != 4 || metadata.Language != "en" {
+		t.Errorf("metadata = %+v, want 2 speakers in 4 seconds of en", metadata)
+	}
+	if received != 3*chunkSize+1 {
+		t.Errorf("server received %d bytes, want %d", received, 3*chunkSize+1)
+	}
+	wantQuery := url.Values{"model": {"nova-2"}, "smart_format": {"true"}, "diarize": {"true"}, "language": {"en"}}
+	if !reflect.DeepEqual(query, wantQuery) {
+		t.Errorf("query = %v, want %v", query, wantQuery)
+	}
+}
@@ -0,0 +1,148 @@
+package deepgram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// chunkSize of the audio sent in each websocket message.
+const chunkSize = 32 * 1024
+
+// streamIdle is how long the websocket may stay silent before the transcription is given up.
+const streamIdle = time.Minute
+
+// message is a message of the websocket, Results carry a piece of the transcript and Metadata ends
+// the stream once the audio is transcribed.
+type message struct {
+	Type     string  `json:"type"`
+	IsFinal  bool    `json:"is_final"`
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+	Channel  struct {
+		Alternatives []alternative `json:"alternatives"`
+	} `json:"channel"`
+	// Description is the reason of an Error message.
+	Description string `json:"description"`
+}
+
+// TranscriptStream works like TranscriptWithMetadata but sends the audio over the websocket of
+// Deepgram and sends each final piece of the transcript to partials as soon as it arrives. The
+// websocket doesn't detect the language, audio without one is transcribed as English.
+func (dt *DeepgramTranscriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	metadata := dt.metadata()
+	if metadata.Language == "" {
+		metadata.Language = "en"
+	}
+
+	f, err := os.Open(inputFilePath)
+	if err != nil {
+		return "", metadata, fmt.Errorf("read audio failed: %v", err)
+	}
+	defer f.Close()
+
+	ws, err := dt.dial()
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("connect to %s failed: %w", dt.baseURL, err))
+	}
+	defer ws.Close()
+	logging.L().Info("Starting streaming transcription", "provider", providerName, "model", dt.cfg.Model, "file", inputFilePath)
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- send(ws, f)
+	}()
+
+	speakers := map[string]bool{}
+	var texts []string
+	for {
+		ws.SetReadDeadline(time.Now().Add(streamIdle))
+		var data []byte
+		if err = websocket.Message.Receive(ws, &data); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("stream of %s failed: %w", dt.baseURL, err))
+		}
+		var m message
+		if err = json.Unmarshal(data, &m); err != nil {
+			return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("parse message of %s failed: %v", dt.baseURL, err))
+		}
+		if m.Type == "Error" {
+			return "", metadata, provider.NewTranscriptionError(providerName, false, fmt.Errorf("%s failed the stream: %s", dt.baseURL, m.Description))
+		}
+		if m.Type == "Metadata" {
+			break
+		}
+		if m.Type != "Results" || !m.IsFinal || len(m.Channel.Alternatives) == 0 {
+			continue
+		}
+
+		a := m.Channel.Alternatives[0]
+		segment := model.Segment{Start: m.Start, End: m.Start + m.Duration, Text: strings.TrimSpace(a.Transcript), Words: dt.words(a.Words)}
+		if segment.Text == "" {
+			continue
+		}
+		if dt.cfg.Diarize && len(a.Words) > 0 && a.Words[0].Speaker != nil {
+			segment.Speaker = strconv.Itoa(*a.Words[0].Speaker)
+			speakers[segment.Speaker] = true
+		}
+		metadata.Segments = append(metadata.Segments, segment)
+		texts = append(texts, segment.Text)
+		partials <- segment
+	}
+	if err = <-sent; err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("send audio to %s failed: %w", dt.baseURL, err))
+	}
+
+	metadata.SegmentCount = len(metadata.Segments)
+	metadata.Speakers = len(speakers)
+	if len(metadata.Segments) > 0 {
+		metadata.DurationSeconds = metadata.Segments[len(metadata.Segments)-1].End
+	}
+	return strings.Join(texts, "\n"), metadata, nil
+}
+
+// dial opens the websocket of /v1/listen with the options of the transcriber.
+func (dt *DeepgramTranscriber) dial() (*websocket.Conn, error) {
+	location := "ws" + strings.TrimPrefix(dt.baseURL, "http") + "/v1/listen?" + dt.query().Encode()
+	cfg, err := websocket.NewConfig(location, dt.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Header.Set("Authorization", "Token "+dt.key)
+	cfg.Dialer = &net.Dialer{Timeout: healthCheckTimeout}
+	return websocket.DialConfig(cfg)
+}
+
+// send sends the audio of r and then asks Deepgram to finish the transcription and close the stream.
+func send(ws *websocket.Conn, r io.Reader) error {
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := websocket.Message.Send(ws, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return websocket.Message.Send(ws, `{"type":"CloseStream"}`)
+}
@@ -0,0 +1,344 @@
+// Package faster_whisper transcribes with faster-whisper servers running on CTranslate2, e.g.
+// speaches, through the OpenAI-compatible /v1/audio/transcriptions endpoint they expose. Unlike the
+// OpenAI API they report the timestamps and probabilities of the words and filter the audio with VAD.
+package faster_whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/requestmeta"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/vocab"
+	"time"
+)
+
+const providerName = "faster_whisper"
+
+const healthCheckTimeout = 10 * time.Second
+
+// FasterWhisperTranscriber transcribes with one faster-whisper server.
+type FasterWhisperTranscriber struct {
+	cfg      config.FasterWhisperConfig
+	baseURL  string
+	client   *http.Client
+	language string
+	request  *model.RequestMetadata
+	decoding config.DecodingConfig
+	// verbatim keeps fillers and stutters, see config.VerbatimConfig
+	verbatim bool
+	// vocabulary are the installed vocabulary packs, their terms bias the prompt
+	vocabulary vocab.Packs
+}
+
+// NewFasterWhisperTranscriber creates the transcriber of the server of cfg, requests are sent with client.
+func NewFasterWhisperTranscriber(cfg config.FasterWhisperConfig, client *http.Client) *FasterWhisperTranscriber {
+	return &FasterWhisperTranscriber{
+		cfg:        cfg,
+		baseURL:    strings.TrimSuffix(cfg.URL, "/"),
+		client:     client,
+		request:    config.GetProviders().For(providerName).Record(),
+		decoding:   config.GetProviders().For(providerName).Decoding,
+		verbatim:   config.Get().Verbatim.Default,
+		vocabulary: vocab.Installed(),
+	}
+}
+
+// New creates the transcriber of the faster_whisper server in providers.yaml.
+func New() (*FasterWhisperTranscriber, error) {
+	cfg := config.GetProviders().For(providerName).FasterWhisper
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("no server for %s, set server.url in providers.yaml", providerName)
+	}
+	return NewFasterWhisperTranscriber(cfg, requestmeta.NewClient(providerName)), nil
+}
+
+// SetLanguage sends language with the requests, the server detects the language when it is empty.
+func (ft *FasterWhisperTranscriber) SetLanguage(language string) {
+	ft.language = language
+}
+
+// SupportedFormats are the containers faster-whisper decodes itself, other audio is converted to mp3.
+func (ft *FasterWhisperTranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"mp3", "m4a", "mp4", "wav", "webm", "ogg", "flac"}}
+}
+
+func (ft *FasterWhisperTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := ft.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and also reports the server, model, language and
+// segments with the timings of their words.
+func (ft *FasterWhisperTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return ft.TranscriptWithPrompt(inputFilePath, "")
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata and sends prompt as the initial prompt.
+func (ft *FasterWhisperTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return ft.transcribe(context.Background(), inputFilePath, api.Options{Prompt: prompt})
+}
+
+// TranscriptWithOptions works like TranscriptWithPrompt with opts.Prompt, which NoContext drops, and
+// sends the temperature. VAD filters the audio with the VAD parameters of providers.yaml, Translate
+// sends it to the translations endpoint. The API has no no-speech or word threshold nor segment
+// length, those are ignored.
+func (ft *FasterWhisperTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return ft.transcribe(context.Background(), inputFilePath, opts)
+}
+
+// TranscriptContext works like TranscriptWithOptions with the options ctx carries and sends the request with ctx.
+func (ft *FasterWhisperTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return ft.transcribe(ctx, inputFilePath, api.OptionsFrom(ctx))
+}
+
+// word is a word of the verbose_json response.
+type word struct {
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Word        string  `json:"word"`
+	Probability float64 `json:"probability"`
+}
+
+// response is the verbose_json response. faster-whisper servers report the words of each segment,
+// the OpenAI API reports them for the whole audio.
+type response struct {
+	Language string  `json:"language"`
+	Duration float64 `json:"duration"`
+	Text     string  `json:"text"`
+	Segments []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+		Words []word  `json:"words"`
+	} `json:"segments"`
+	Words []word `json:"words"`
+}
+
+func (ft *FasterWhisperTranscriber) transcribe(ctx context.Context, inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	opts = opts.Merge(api.Options{
+		NoContext: ft.decoding.NoContext(),
+		VAD:       ft.decoding.VAD,
+	})
+	if ft.verbatim {
+		opts = opts.Verbatim()
+	}
+	prompt := ft.vocabulary.Prompt(ft.language)
+	if ft.verbatim {
+		prompt += api.VerbatimPrompt(ft.language)
+	}
+	if !opts.NoContext {
+		prompt += opts.Prompt
+	}
+	metadata := model.ProviderMetadata{
+		Provider: providerName,
+		Model:    ft.cfg.Model,
+		Language: ft.language,
+		Request:  ft.request,
+		Verbatim: ft.verbatim,
+		Server:   &model.ServerInfo{Host: host(ft.baseURL)},
+	}
+
+	fields := url.Values{"response_format": {"verbose_json"}, "timestamp_granularities[]": {"segment"}}
+	if ft.cfg.Words() {
+		fields.Add("timestamp_granularities[]", "word")
+	}
+	if ft.cfg.Model != "" {
+		fields.Set("model", ft.cfg.Model)
+	}
+	if prompt != "" {
+		fields.Set("prompt", prompt)
+	}
+	if opts.Temperature > 0 {
+		fields.Set("temperature", strconv.FormatFloat(float64(opts.Temperature), 'f', 2, 32))
+	}
+	if opts.VAD {
+		fields.Set("vad_filter", "true")
+		if parameters := vadParameters(ft.cfg.VAD); parameters != "" {
+			fields.Set("vad_parameters", parameters)
+		}
+	}
+	endpoint := "/v1/audio/transcriptions"
+	if opts.Translate {
+		endpoint = "/v1/audio/translations"
+		metadata.Language = "en"
+	} else if ft.language != "" {
+		fields.Set("language", ft.language)
+	}
+
+	body, contentType, err := multipartBody(inputFilePath, fields)
+	if err != nil {
+		return "", metadata, fmt.Errorf("read audio failed: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ft.baseURL+endpoint, body)
+	if err != nil {
+		return "", metadata, err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	logging.L().Info("Starting transcription", "provider", providerName, "server", ft.baseURL, "file", inputFilePath)
+	resp, err := ft.client.Do(req)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, ctx.Err() == nil, fmt.Errorf("transcription request to %s failed: %w", ft.baseURL, err))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("read response of %s failed: %w", ft.baseURL, err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", metadata, provider.NewTranscriptionError(providerName, retryable(resp.StatusCode),
+			fmt.Errorf("%s answered %s: %s", ft.baseURL, resp.Status, strings.TrimSpace(string(data))))
+	}
+
+	var r response
+	if err = json.Unmarshal(data, &r); err != nil {
+		// a server that answers garbage is broken, not the audio
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("parse response of %s failed: %v", ft.baseURL, err))
+	}
+	if r.Language != "" && !opts.Translate {
+		metadata.Language = r.Language
+	}
+	metadata.Segments = segments(r)
+	metadata.SegmentCount = len(metadata.Segments)
+	metadata.DurationSeconds = r.Duration
+	if metadata.DurationSeconds == 0 && len(metadata.Segments) > 0 {
+		metadata.DurationSeconds = metadata.Segments[len(metadata.Segments)-1].End
+	}
+	return strings.TrimSpace(r.Text), metadata, nil
+}
+
+// segments returns the segments of r with their words, the words reported for the whole audio are
+// given to the segment they start in.
+func segments(r response) []model.Segment {
+	segments := make([]model.Segment, 0, len(r.Segments))
+	for _, s := range r.Segments {
+		segment := model.Segment{Start: s.Start, End: s.End, Text: strings.TrimSpace(s.Text)}
+		for _, w := range s.Words {
+			segment.Words = append(segment.Words, toWord(w))
+		}
+		segments = append(segments, segment)
+	}
+
+	for _, w := range r.Words {
+		i := sort.Search(len(segments), func(i int) bool { return segments[i].End > w.Start })
+		if i == len(segments) {
+			i--
+		}
+		if i < 0 || len(r.Segments[i].Words) > 0 {
+			continue
+		}
+		segments[i].Words = append(segments[i].Words, toWord(w))
+	}
+	return segments
+}
+
+func toWord(w word) model.Word {
+	return model.Word{Start: w.Start, End: w.End, Text: strings.TrimSpace(w.Word), Probability: w.Probability}
+}
+
+// vadParameters returns the vad_parameters field, the JSON of faster-whisper's VadOptions, empty
+// when every parameter keeps the server's default.
+func vadParameters(v config.VADParameters) string {
+	parameters := map[string]any{}
+	if v.Threshold > 0 {
+		parameters["threshold"] = v.Threshold
+	}
+	if v.MinSpeechDuration > 0 {
+		parameters["min_speech_duration_ms"] = v.MinSpeechDuration.Milliseconds()
+	}
+	if v.MaxSpeechDuration > 0 {
+		parameters["max_speech_duration_s"] = v.MaxSpeechDuration.Seconds()
+	}
+	if v.MinSilenceDuration > 0 {
+		parameters["min_silence_duration_ms"] = v.MinSilenceDuration.Milliseconds()
+	}
+	if v.SpeechPad > 0 {
+		parameters["speech_pad_ms"] = v.SpeechPad.Milliseconds()
+	}
+	if len(parameters) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(parameters)
+	return string(data)
+}
+
+// HealthCheck lists the models of the server, which needs it up and the credentials of the headers valid.
+func (ft *FasterWhisperTranscriber) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ft.baseURL+"/v1/models", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ft.client.Do(req)
+	if err != nil {
+		return provider.NewTranscriptionError(providerName, true, fmt.Errorf("health check of %s failed: %w", ft.baseURL, err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return provider.NewTranscriptionError(providerName, retryable(resp.StatusCode), fmt.Errorf("%s is unhealthy: %s", ft.baseURL, resp.Status))
+	}
+	return nil
+}
+
+// retryable reports whether another provider may succeed: rate limits, rejected credentials and server
+// errors, e.g. a server still loading its model, are, requests rejected for their audio aren't.
+func retryable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests ||
+		status == http.StatusRequestTimeout || status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+// multipartBody returns the form sending the file at path with fields.
+func multipartBody(path string, fields url.Values) (io.Reader, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = io.Copy(part, f); err != nil {
+		return nil, "", err
+	}
+	for name, values := range fields {
+		for _, value := range values {
+			if err = w.WriteField(name, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
+
+// host returns the host of baseURL, baseURL itself when it doesn't parse.
+func host(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
@@ -0,0 +1,167 @@
+package faster_whisper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+func TestFasterWhisperTranscriber_TranscriptWithOptions(t *testing.T) {
+	audio := filepath.Join(t.TempDir(), "a.mp3")
+	if err := os.WriteFile(audio, []byte("ID3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	noWords := false
+
+	tests := []struct {
+		name          string
+		cfg           config.FasterWhisperConfig
+		status        int
+		body          string
+		opts          api.Options
+		wantPath      string
+		wantFields    url.Values
+		wantText      string
+		wantLanguage  string
+		wantSegments  []model.Segment
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{
+			name:     "words of the segments",
+			cfg:      config.FasterWhisperConfig{Model: "Systran/faster-whisper-small"},
+			status:   http.StatusOK,
+			body:     `{"language":"en","duration":2,"text":" hello world","segments":[{"start":0,"end":2,"text":" hello world","words":[{"start":0,"end":0.8,"word":" hello","probability":0.9},{"start":1,"end":2,"word":" world","probability":0.7}]}]}`,
+			opts:     api.Options{Prompt: "before", Temperature: 0.2},
+			wantPath: "/v1/audio/transcriptions",
+			wantFields: url.Values{
+				"response_format": {"verbose_json"}, "timestamp_granularities[]": {"segment", "word"},
+				"model": {"Systran/faster-whisper-small"}, "language": {"en"}, "prompt": {"before"}, "temperature": {"0.20"},
+			},
+			wantText:     "hello world",
+			wantLanguage: "en",
+			wantSegments: []model.Segment{{Start: 0, End: 2, Text: "hello world", Words: []model.Word{
+				{Start: 0, End: 0.8, Text: "hello", Probability: 0.9}, {Start: 1, End: 2, Text: "world", Probability: 0.7},
+			}}},
+		},
+		{
+			name:     "words of the whole audio",
+			status:   http.StatusOK,
+			body:     `{"language":"en","text":"hi there","segments":[{"start":0,"end":1,"text":"hi"},{"start":1,"end":3,"text":"there"}],"words":[{"start":0.1,"end":0.5,"word":"hi"},{"start":1.2,"end":3.4,"word":"there"}]}`,
+			wantPath: "/v1/audio/transcriptions",
+			wantFields: url.Values{
+				"response_format": {"verbose_json"}, "timestamp_granularities[]": {"segment", "word"}, "language": {"en"},
+			},
+			wantText:     "hi there",
+			wantLanguage: "en",
+			wantSegments: []model.Segment{
+				{Start: 0, End: 1, Text: "hi", Words: []model.Word{{Start: 0.1, End: 0.5, Text: "hi"}}},
+				{Start: 1, End: 3, Text: "there", Words: []model.Word{{Start: 1.2, End: 3.4, Text: "there"}}},
+			},
+		},
+		{
+			name: "vad with parameters",
+			cfg: config.FasterWhisperConfig{WordTimestamps: &noWords, VAD: config.VADParameters{
+				Threshold: 0.6, MinSilenceDuration: 500 * time.Millisecond, MaxSpeechDuration: 30 * time.Second,
+			}},
+			status:   http.StatusOK,
+			body:     `{"text":"hi","segments":[]}`,
+			opts:     api.Options{Prompt: "before", NoContext: true, VAD: true},
+			wantPath: "/v1/audio/transcriptions",
+			wantFields: url.Values{
+				"response_format": {"verbose_json"}, "timestamp_granularities[]": {"segment"}, "language": {"en"},
+				"vad_filter":     {"true"},
+				"vad_parameters": {`{"max_speech_duration_s":30,"min_silence_duration_ms":500,"threshold":0.6}`},
+			},
+			wantText:     "hi",
+			wantLanguage: "en",
+			wantSegments: []model.Segment{},
+		},
+		{
+			name:         "translation",
+			status:       http.StatusOK,
+			body:         `{"language":"zh","text":"hello","segments":[]}`,
+			opts:         api.Options{Translate: true},
+			wantPath:     "/v1/audio/translations",
+			wantFields:   url.Values{"response_format": {"verbose_json"}, "timestamp_granularities[]": {"segment", "word"}},
+			wantText:     "hello",
+			wantLanguage: "en",
+			wantSegments: []model.Segment{},
+		},
+		{name: "model loading", status: http.StatusServiceUnavailable, body: `{"detail":"loading"}`, wantErr: true, wantRetryable: true},
+		{name: "rejected audio", status: http.StatusUnprocessableEntity, body: `{"detail":"invalid audio"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			var fields url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Fatal(err)
+				}
+				if _, _, err := r.FormFile("file"); err != nil {
+					t.Errorf("no file sent: %v", err)
+				}
+				fields = url.Values(r.MultipartForm.Value)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			ft := &FasterWhisperTranscriber{cfg: tt.cfg, baseURL: srv.URL, client: srv.Client(), language: "en"}
+			text, metadata, err := ft.TranscriptWithOptions(audio, tt.opts)
+			if tt.wantErr {
+				if err == nil || provider.IsRetryable(err) != tt.wantRetryable {
+					t.Errorf("TranscriptWithOptions() error = %v, want retryable %v", err, tt.wantRetryable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TranscriptWithOptions() error = %v", err)
+			}
+			if text != tt.wantText || metadata.Language != tt.wantLanguage || !reflect.DeepEqual(metadata.Segments, tt.wantSegments) {
+				t.Errorf("TranscriptWithOptions() = %q in %q, %+v", text, metadata.Language, metadata.Segments)
+			}
+			if path != tt.wantPath || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("sent %v to %s, want %v to %s", fields, path, tt.wantFields, tt.wantPath)
+			}
+			if metadata.Provider != providerName || metadata.Server == nil || !strings.HasPrefix(srv.URL, "http://"+metadata.Server.Host) {
+				t.Errorf("metadata = %+v, want the provider and server", metadata)
+			}
+		})
+	}
+}
+
+func TestFasterWhisperTranscriber_HealthCheck(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("path = %s, want /v1/models", r.URL.Path)
+		}
+		w.WriteHeader(status)
+	}))
+	ft := &FasterWhisperTranscriber{baseURL: srv.URL, client: srv.Client()}
+
+	if err := ft.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck() = %v, want nil", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := ft.HealthCheck(); err == nil {
+		t.Error("HealthCheck() = nil while the model loads")
+	}
+	srv.Close()
+	if err := ft.HealthCheck(); !provider.IsRetryable(err) {
+		t.Errorf("HealthCheck() = %v, want a retryable error for a server that is down", err)
+	}
+}
@@ -0,0 +1,152 @@
+package google_stt
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scope of the access tokens, it covers Speech-to-Text and the staging bucket.
+const scope = "https://www.googleapis.com/auth/cloud-platform"
+
+// tokenLifetime is how long the requested access tokens are valid, the most Google grants.
+const tokenLifetime = time.Hour
+
+// credentials is the JSON key of a service account.
+type credentials struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// readCredentials reads the service account key at path, $GOOGLE_APPLICATION_CREDENTIALS when path is empty.
+func readCredentials(path string) (credentials, error) {
+	if path == "" {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if path == "" {
+		return credentials{}, errors.New("no service account key, set google.credentials_file in providers.yaml or GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return credentials{}, err
+	}
+	var c credentials
+	if err = json.Unmarshal(data, &c); err != nil {
+		return credentials{}, fmt.Errorf("parse %s failed: %v", path, err)
+	}
+	if c.Type != "service_account" || c.ClientEmail == "" || c.PrivateKey == "" {
+		return credentials{}, fmt.Errorf("%s is not the key of a service account", path)
+	}
+	if c.TokenURI == "" {
+		c.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return c, nil
+}
+
+// serviceAccountTokens exchanges a JWT signed with the key of a service account for access tokens and keeps
+// each token until shortly before it expires.
+type serviceAccountTokens struct {
+	creds  credentials
+	key    *rsa.PrivateKey
+	client *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newServiceAccountTokens(creds credentials, client *http.Client) (*serviceAccountTokens, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("the private key of %s is not PEM", creds.ClientEmail)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		// keys of older tools are PKCS #1
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("parse the private key of %s failed: %v", creds.ClientEmail, err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key of %s is not an RSA key", creds.ClientEmail)
+	}
+	return &serviceAccountTokens{creds: creds, key: key, client: client}, nil
+}
+
+// Token returns a valid access token.
+func (ts *serviceAccountTokens) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != "" && time.Until(ts.expiry) > time.Minute {
+		return ts.token, nil
+	}
+
+	assertion, err := ts.assertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request for %s failed: %w", ts.creds.ClientEmail, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request for %s failed: %s %s", ts.creds.ClientEmail, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("token request for %s answered no token: %s", ts.creds.ClientEmail, data)
+	}
+	ts.token, ts.expiry = token.AccessToken, time.Now().Add(time.Duration(token.ExpiresIn)*time.Second)
+	return ts.token, nil
+}
+
+// assertion is the JWT asking for a token of the service account, signed with its key.
+func (ts *serviceAccountTokens) assertion(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": ts.creds.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   ts.creds.ClientEmail,
+		"scope": scope,
+		"aud":   ts.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(tokenLifetime).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, ts.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("sign the token request of %s failed: %v", ts.creds.ClientEmail, err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
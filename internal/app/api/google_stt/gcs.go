@@ -0,0 +1,56 @@
+package google_stt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// stage uploads the audio at path to the bucket and returns the name of the object.
+func (gt *GoogleTranscriber) stage(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// the name is unique for every upload, two transcriptions of the same file don't share an object
+	name := "v2t/" + strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + filepath.Base(path)
+	endpoint := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", gt.storageURL, url.PathEscape(gt.cfg.Bucket),
+		url.Values{"uploadType": {"media"}, "name": {name}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, f)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "audio/wav")
+	if _, err = gt.do(req); err != nil {
+		return "", fmt.Errorf("stage %s in gs://%s failed: %w", path, gt.cfg.Bucket, err)
+	}
+	return name, nil
+}
+
+// unstage removes the object staged by stage. It runs after the transcription whatever its outcome,
+// so it doesn't use the context of the transcription, which may be canceled.
+func (gt *GoogleTranscriber) unstage(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", gt.storageURL, url.PathEscape(gt.cfg.Bucket), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = gt.do(req); err != nil {
+		return fmt.Errorf("remove gs://%s/%s failed: %w", gt.cfg.Bucket, name, err)
+	}
+	return nil
+}
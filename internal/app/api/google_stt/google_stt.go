@@ -0,0 +1,545 @@
+// Package google_stt transcribes with Google Cloud Speech-to-Text v2, authenticated as a service
+// account. Audio up to a minute is sent with the request, longer audio is staged in Cloud Storage
+// for a batch recognition whose operation is polled until it completes.
+package google_stt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/requestmeta"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+const providerName = "google_stt"
+
+const healthCheckTimeout = 10 * time.Second
+
+// inlineLimit is the longest audio the recognize method accepts, longer audio goes through batchRecognize.
+const inlineLimit = time.Minute
+
+// bytesPerSecond of the 16kHz mono 16-bit PCM the audio is converted to.
+const bytesPerSecond = 16000 * 2
+
+// defaultModel is the model of the recognizer when neither it nor providers.yaml sets one.
+const defaultModel = "long"
+
+const defaultPollInterval = 5 * time.Second
+
+// tokenSource returns the access tokens the requests are authorized with.
+type tokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// GoogleTranscriber transcribes with one Speech-to-Text recognizer.
+type GoogleTranscriber struct {
+	cfg      config.GoogleConfig
+	project  string
+	location string
+	tokens   tokenSource
+	client   *http.Client
+	// speechURL and storageURL are the endpoints of Speech-to-Text and Cloud Storage
+	speechURL  string
+	storageURL string
+	language   string
+	request    *model.RequestMetadata
+}
+
+// New creates the transcriber of the google_stt recognizer in providers.yaml, authenticated with the
+// key of its service account.
+func New() (*GoogleTranscriber, error) {
+	cfg := config.GetProviders().For(providerName).Google
+	creds, err := readCredentials(cfg.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", providerName, err)
+	}
+	project := cfg.Project
+	if project == "" {
+		project = creds.ProjectID
+	}
+	if project == "" {
+		return nil, fmt.Errorf("no project for %s, set google.project in providers.yaml", providerName)
+	}
+	client := requestmeta.NewClient(providerName)
+	tokens, err := newServiceAccountTokens(creds, client)
+	if err != nil {
+		return nil, err
+	}
+	return newGoogleTranscriber(cfg, project, tokens, client), nil
+}
+
+func newGoogleTranscriber(cfg config.GoogleConfig, project string, tokens tokenSource, client *http.Client) *GoogleTranscriber {
+	location := cfg.Location
+	if location == "" {
+		location = "global"
+	}
+	speechURL := "https://speech.googleapis.com"
+	if location != "global" {
+		speechURL = "https://" + location + "-speech.googleapis.com"
+	}
+	return &GoogleTranscriber{
+		cfg:        cfg,
+		project:    project,
+		location:   location,
+		tokens:     tokens,
+		client:     client,
+		speechURL:  speechURL,
+		storageURL: "https://storage.googleapis.com",
+		request:    config.GetProviders().For(providerName).Record(),
+	}
+}
+
+// SetLanguage sets the language of the audio when providers.yaml sets no language codes, it is
+// detected when language is empty or auto.
+func (gt *GoogleTranscriber) SetLanguage(language string) {
+	gt.language = language
+}
+
+// SupportedFormats is 16kHz 16-bit PCM WAV, which gives the duration deciding whether the audio is
+// staged without probing it.
+func (gt *GoogleTranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"wav"}, Codecs: []string{"pcm_s16le"}, SampleRate: 16000}
+}
+
+func (gt *GoogleTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := gt.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and also reports the model, language, speakers and
+// segments with the timings of their words.
+func (gt *GoogleTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return gt.transcribe(context.Background(), inputFilePath, api.Options{})
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata, Speech-to-Text takes no prompt so it is ignored.
+func (gt *GoogleTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return gt.transcribe(context.Background(), inputFilePath, api.Options{Prompt: prompt})
+}
+
+// TranscriptWithOptions works like TranscriptWithMetadata. Translate asks the recognizer for an
+// English translation, which only chirp_2 supports, the other options have no equivalent and are ignored.
+func (gt *GoogleTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return gt.transcribe(context.Background(), inputFilePath, opts)
+}
+
+// TranscriptContext works like TranscriptWithOptions with the options ctx carries and sends the requests with ctx.
+func (gt *GoogleTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return gt.transcribe(ctx, inputFilePath, api.OptionsFrom(ctx))
+}
+
+// result is a SpeechRecognitionResult, the offsets are durations like "1.500s".
+type result struct {
+	Alternatives []struct {
+		Transcript string  `json:"transcript"`
+		Confidence float64 `json:"confidence"`
+		Words      []struct {
+			StartOffset  string  `json:"startOffset"`
+			EndOffset    string  `json:"endOffset"`
+			Word         string  `json:"word"`
+			Confidence   float64 `json:"confidence"`
+			SpeakerLabel string  `json:"speakerLabel"`
+		} `json:"words"`
+	} `json:"alternatives"`
+	ResultEndOffset string `json:"resultEndOffset"`
+	LanguageCode    string `json:"languageCode"`
+}
+
+// status is the error of an operation or of one file of a batch recognition.
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// operation is the long-running operation of a batch recognition.
+type operation struct {
+	Name     string  `json:"name"`
+	Done     bool    `json:"done"`
+	Error    *status `json:"error"`
+	Response struct {
+		Results map[string]struct {
+			Error        *status `json:"error"`
+			InlineResult struct {
+				Transcript struct {
+					Results []result `json:"results"`
+				} `json:"transcript"`
+			} `json:"inlineResult"`
+			// Transcript is where the results were before inlineResult
+			Transcript struct {
+				Results []result `json:"results"`
+			} `json:"transcript"`
+		} `json:"results"`
+	} `json:"response"`
+}
+
+func (gt *GoogleTranscriber) transcribe(ctx context.Context, inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	recognitionConfig := gt.recognitionConfig(opts)
+	metadata := model.ProviderMetadata{
+		Provider: providerName,
+		Model:    gt.cfg.Model,
+		Language: gt.language,
+		Request:  gt.request,
+		Server:   &model.ServerInfo{Host: strings.TrimPrefix(gt.speechURL, "https://")},
+	}
+	if metadata.Model == "" && gt.cfg.Recognizer == "" {
+		metadata.Model = defaultModel
+	}
+	if opts.Translate {
+		metadata.Language = "en"
+	}
+
+	info, err := os.Stat(inputFilePath)
+	if err != nil {
+		return "", metadata, fmt.Errorf("read audio failed: %v", err)
+	}
+	duration := time.Duration(float64(info.Size()-44) / bytesPerSecond * float64(time.Second))
+
+	logging.L().Info("Starting transcription", "provider", providerName, "recognizer", gt.recognizer(), "file", inputFilePath)
+	var results []result
+	if duration <= inlineLimit {
+		results, err = gt.recognize(ctx, inputFilePath, recognitionConfig)
+	} else if gt.cfg.Bucket == "" {
+		return "", metadata, provider.NewTranscriptionError(providerName, false,
+			fmt.Errorf("%s lasts %s, audio over %s needs google.bucket in providers.yaml to be staged in", inputFilePath, duration.Round(time.Second), inlineLimit))
+	} else {
+		results, err = gt.batchRecognize(ctx, inputFilePath, recognitionConfig)
+	}
+	if err != nil {
+		return "", metadata, gt.transcriptionError(ctx, err)
+	}
+
+	metadata.Segments, metadata.Speakers = segments(results)
+	metadata.SegmentCount = len(metadata.Segments)
+	if len(metadata.Segments) > 0 {
+		metadata.DurationSeconds = metadata.Segments[len(metadata.Segments)-1].End
+	}
+	for _, r := range results {
+		if r.LanguageCode != "" && !opts.Translate {
+			metadata.Language = r.LanguageCode
+			break
+		}
+	}
+	texts := make([]string, 0, len(metadata.Segments))
+	for _, s := range metadata.Segments {
+		texts = append(texts, s.Text)
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n")), metadata, nil
+}
+
+// recognitionConfig returns the RecognitionConfig of the requests.
+func (gt *GoogleTranscriber) recognitionConfig(opts api.Options) map[string]any {
+	languages := gt.cfg.LanguageCodes
+	if len(languages) == 0 {
+		languages = []string{"auto"}
+		if gt.language != "" && gt.language != "auto" {
+			languages = []string{gt.language}
+		}
+	}
+	features := map[string]any{}
+	if gt.cfg.Words() {
+		features["enableWordTimeOffsets"] = true
+		features["enableWordConfidence"] = true
+	}
+	if d := gt.cfg.Diarization; d != nil {
+		diarization := map[string]any{}
+		if d.MinSpeakers > 0 {
+			diarization["minSpeakerCount"] = d.MinSpeakers
+		}
+		if d.MaxSpeakers > 0 {
+			diarization["maxSpeakerCount"] = d.MaxSpeakers
+		}
+		features["diarizationConfig"] = diarization
+	}
+	for name, value := range gt.cfg.Features {
+		features[lowerCamel(name)] = value
+	}
+
+	recognitionConfig := map[string]any{
+		"autoDecodingConfig": map[string]any{},
+		"languageCodes":      languages,
+		"features":           features,
+	}
+	if gt.cfg.Model != "" {
+		recognitionConfig["model"] = gt.cfg.Model
+	} else if gt.cfg.Recognizer == "" {
+		recognitionConfig["model"] = defaultModel
+	}
+	if opts.Translate {
+		recognitionConfig["translationConfig"] = map[string]any{"targetLanguage": "en"}
+	}
+	return recognitionConfig
+}
+
+// recognize sends the audio at path with the request.
+func (gt *GoogleTranscriber) recognize(ctx context.Context, path string, recognitionConfig map[string]any) ([]result, error) {
+	audio, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Results []result `json:"results"`
+	}
+	err = gt.call(ctx, http.MethodPost, gt.recognizer()+":recognize",
+		map[string]any{"config": recognitionConfig, "content": base64.StdEncoding.EncodeToString(audio)}, &response)
+	return response.Results, err
+}
+
+// batchRecognize stages the audio at path in the bucket, starts its batch recognition and waits for
+// it. The staged object is removed whatever the outcome.
+func (gt *GoogleTranscriber) batchRecognize(ctx context.Context, path string, recognitionConfig map[string]any) ([]result, error) {
+	name, err := gt.stage(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := gt.unstage(name); err != nil {
+			logging.L().Warn("Staged audio left in the bucket", "provider", providerName, "error", err)
+		}
+	}()
+
+	uri := "gs://" + gt.cfg.Bucket + "/" + name
+	var op operation
+	err = gt.call(ctx, http.MethodPost, gt.recognizer()+":batchRecognize", map[string]any{
+		"config":                  recognitionConfig,
+		"files":                   []map[string]string{{"uri": uri}},
+		"recognitionOutputConfig": map[string]any{"inlineResponseConfig": map[string]any{}},
+	}, &op)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := gt.cfg.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		logging.L().Debug("Polling batch recognition", "provider", providerName, "operation", op.Name)
+		if err = gt.call(ctx, http.MethodGet, op.Name, nil, &op); err != nil {
+			return nil, err
+		}
+	}
+	if op.Error != nil {
+		return nil, provider.NewTranscriptionError(providerName, false, fmt.Errorf("batch recognition of %s failed: %s", uri, op.Error.Message))
+	}
+	file, ok := op.Response.Results[uri]
+	if !ok {
+		return nil, provider.NewTranscriptionError(providerName, true, fmt.Errorf("batch recognition of %s answered no result", uri))
+	}
+	if file.Error != nil && file.Error.Code != 0 {
+		return nil, provider.NewTranscriptionError(providerName, false, fmt.Errorf("batch recognition of %s failed: %s", uri, file.Error.Message))
+	}
+	if results := file.InlineResult.Transcript.Results; len(results) > 0 {
+		return results, nil
+	}
+	return file.Transcript.Results, nil
+}
+
+// recognizer is the resource name of the recognizer.
+func (gt *GoogleTranscriber) recognizer() string {
+	id := gt.cfg.Recognizer
+	if id == "" {
+		id = "_"
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/recognizers/%s", gt.project, gt.location, id)
+}
+
+// call sends body as JSON to the Speech-to-Text resource and method and decodes the response into v.
+func (gt *GoogleTranscriber) call(ctx context.Context, method string, resource string, body any, v any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, gt.speechURL+"/v2/"+resource, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	data, err := gt.do(req)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return &statusError{status: http.StatusBadGateway, message: fmt.Sprintf("parse response of %s failed: %v", resource, err)}
+	}
+	return nil
+}
+
+// do sends req with an access token and returns the body of the response, responses other than 2xx
+// are a *statusError.
+func (gt *GoogleTranscriber) do(req *http.Request) ([]byte, error) {
+	token, err := gt.tokens.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := gt.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &statusError{status: resp.StatusCode, message: errorMessage(resp.Status, data)}
+	}
+	return data, nil
+}
+
+// statusError is a response of a Google API other than 2xx.
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// errorMessage returns the message of the error Google APIs answer, {"error": {"message": ...}}, the
+// status and body when the body isn't one.
+func errorMessage(status string, body []byte) string {
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &e); err == nil && e.Error.Message != "" {
+		return status + " " + e.Error.Message
+	}
+	return status + " " + strings.TrimSpace(string(body))
+}
+
+// transcriptionError wraps err of a transcription, errors of the service are retryable per their
+// status, failures to reach it are unless ctx ended, unreadable audio isn't.
+func (gt *GoogleTranscriber) transcriptionError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return provider.NewTranscriptionError(providerName, retryable(se.status), err)
+	}
+	var te *provider.TranscriptionError
+	if errors.As(err, &te) {
+		return err
+	}
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return provider.NewTranscriptionError(providerName, false, err)
+	}
+	return provider.NewTranscriptionError(providerName, true, err)
+}
+
+// segments returns a segment of each result, split where the speaker changes, and the number of
+// speakers the words are labeled with.
+func segments(results []result) ([]model.Segment, int) {
+	var segments []model.Segment
+	speakers := map[string]bool{}
+	end := 0.0
+	for _, r := range results {
+		if len(r.Alternatives) == 0 || strings.TrimSpace(r.Alternatives[0].Transcript) == "" {
+			continue
+		}
+		a := r.Alternatives[0]
+		start := end
+		end = offset(r.ResultEndOffset)
+		segment := model.Segment{Start: start, End: end, Text: strings.TrimSpace(a.Transcript)}
+		if len(a.Words) > 0 {
+			segment.Start = offset(a.Words[0].StartOffset)
+			segment.Speaker = a.Words[0].SpeakerLabel
+		}
+		// the words of languages written without spaces, e.g. Chinese, are joined without them
+		separator := " "
+		if !strings.Contains(segment.Text, " ") {
+			separator = ""
+		}
+
+		var texts []string
+		split := false
+		for i, w := range a.Words {
+			if w.SpeakerLabel != "" {
+				speakers[w.SpeakerLabel] = true
+			}
+			if i > 0 && w.SpeakerLabel != segment.Speaker {
+				segment.Text, segment.End = strings.Join(texts, separator), segment.Words[len(segment.Words)-1].End
+				segments = append(segments, segment)
+				segment, texts, split = model.Segment{Start: offset(w.StartOffset), End: end, Speaker: w.SpeakerLabel}, nil, true
+			}
+			segment.Words = append(segment.Words, model.Word{
+				Start: offset(w.StartOffset), End: offset(w.EndOffset), Text: w.Word, Probability: w.Confidence,
+			})
+			texts = append(texts, w.Word)
+		}
+		// the transcript is punctuated, the words aren't, so it is kept unless the speakers split it
+		if split {
+			segment.Text = strings.Join(texts, separator)
+		}
+		segments = append(segments, segment)
+	}
+	return segments, len(speakers)
+}
+
+// offset returns the seconds of a protobuf duration in JSON, e.g. "1.500s", zero when it doesn't parse.
+func offset(d string) float64 {
+	parsed, err := time.ParseDuration(d)
+	if err != nil {
+		return 0
+	}
+	return parsed.Seconds()
+}
+
+// lowerCamel returns the JSON name of the protobuf field name, profanity_filter is profanityFilter.
+func lowerCamel(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// HealthCheck lists the recognizers of the project and location, which needs the service account
+// authorized and the API enabled.
+func (gt *GoogleTranscriber) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	var recognizers struct{}
+	resource := fmt.Sprintf("projects/%s/locations/%s/recognizers?pageSize=1", gt.project, gt.location)
+	if err := gt.call(ctx, http.MethodGet, resource, nil, &recognizers); err != nil {
+		return gt.transcriptionError(ctx, fmt.Errorf("health check of %s failed: %w", gt.speechURL, err))
+	}
+	return nil
+}
+
+// retryable reports whether another provider may succeed: rate limits, exhausted quotas, rejected
+// credentials and server errors are, requests rejected for their audio or config aren't.
+func retryable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests ||
+		status == http.StatusRequestTimeout || status == http.StatusUnauthorized || status == http.StatusForbidden
+}
@@ -0,0 +1,304 @@
+package google_stt
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// staticTokens is a token source always returning the same token.
+type staticTokens string
+
+func (s staticTokens) Token(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// route is the answer of the fake APIs to a method and path.
+type route struct {
+	status int
+	body   string
+}
+
+// writeAudio writes a WAV file lasting seconds.
+func writeAudio(t *testing.T, seconds int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audio.wav")
+	if err := os.WriteFile(path, make([]byte, 44+seconds*bytesPerSecond), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGoogleTranscriber_TranscriptWithOptions(t *testing.T) {
+	const recognizer = "/v2/projects/p/locations/global/recognizers/_"
+	const operation = `{"name":"projects/p/locations/global/operations/7"}`
+	noWords := false
+
+	tests := []struct {
+		name          string
+		cfg           config.GoogleConfig
+		seconds       int
+		opts          api.Options
+		routes        map[string]route
+		wantCalls     []string
+		wantConfig    string
+		wantText      string
+		wantLanguage  string
+		wantSegments  []model.Segment
+		wantSpeakers  int
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{
+			name:    "short audio with words",
+			seconds: 3,
+			routes: map[string]route{"POST " + recognizer + ":recognize": {body: `{"results":[
+				{"alternatives":[{"transcript":"Hello world.","words":[{"startOffset":"0.200s","endOffset":"0.600s","word":"Hello","confidence":0.9},{"startOffset":"0.700s","endOffset":"1.100s","word":"world","confidence":0.8}]}],"resultEndOffset":"1.200s","languageCode":"en-us"},
+				{"alternatives":[{"transcript":" Bye."}],"resultEndOffset":"2.500s","languageCode":"en-us"}]}`}},
+			wantCalls:    []string{"POST " + recognizer + ":recognize"},
+			wantConfig:   `{"autoDecodingConfig":{},"languageCodes":["en-US"],"model":"long","features":{"enableWordTimeOffsets":true,"enableWordConfidence":true}}`,
+			wantText:     "Hello world.\nBye.",
+			wantLanguage: "en-us",
+			wantSegments: []model.Segment{
+				{Start: 0.2, End: 1.2, Text: "Hello world.", Words: []model.Word{
+					{Start: 0.2, End: 0.6, Text: "Hello", Probability: 0.9}, {Start: 0.7, End: 1.1, Text: "world", Probability: 0.8},
+				}},
+				{Start: 1.2, End: 2.5, Text: "Bye."},
+			},
+		},
+		{
+			name: "diarization and features",
+			cfg: config.GoogleConfig{
+				Model: "chirp_2", Recognizer: "meetings", LanguageCodes: []string{"cmn-Hans-CN"},
+				Diarization: &config.SpeakerCount{MinSpeakers: 2, MaxSpeakers: 3},
+				Features:    map[string]any{"profanity_filter": true},
+			},
+			seconds: 3,
+			routes: map[string]route{"POST /v2/projects/p/locations/global/recognizers/meetings:recognize": {body: `{"results":[
+				{"alternatives":[{"transcript":"你好再见","words":[{"startOffset":"0s","endOffset":"0.500s","word":"你好","speakerLabel":"1"},{"startOffset":"1s","endOffset":"1.500s","word":"再见","speakerLabel":"2"}]}],"resultEndOffset":"2s","languageCode":"cmn-hans-cn"}]}`}},
+			wantCalls:    []string{"POST /v2/projects/p/locations/global/recognizers/meetings:recognize"},
+			wantConfig:   `{"autoDecodingConfig":{},"languageCodes":["cmn-Hans-CN"],"model":"chirp_2","features":{"enableWordTimeOffsets":true,"enableWordConfidence":true,"diarizationConfig":{"minSpeakerCount":2,"maxSpeakerCount":3},"profanityFilter":true}}`,
+			wantText:     "你好\n再见",
+			wantLanguage: "cmn-hans-cn",
+			wantSegments: []model.Segment{
+				{Start: 0, End: 0.5, Speaker: "1", Text: "你好", Words: []model.Word{{Start: 0, End: 0.5, Text: "你好"}}},
+				{Start: 1, End: 2, Speaker: "2", Text: "再见", Words: []model.Word{{Start: 1, End: 1.5, Text: "再见"}}},
+			},
+			wantSpeakers: 2,
+		},
+		{
+			name:    "long audio staged",
+			cfg:     config.GoogleConfig{Bucket: "staging", WordOffsets: &noWords, PollInterval: time.Millisecond},
+			seconds: 61,
+			opts:    api.Options{Translate: true},
+			routes: map[string]route{
+				"POST /upload/storage/v1/b/staging/o":              {body: `{}`},
+				"POST " + recognizer + ":batchRecognize":           {body: operation},
+				"GET /v2/projects/p/locations/global/operations/7": {body: `{"name":"projects/p/locations/global/operations/7","done":true,"response":{"results":{"{uri}":{"inlineResult":{"transcript":{"results":[{"alternatives":[{"transcript":"long talk"}],"resultEndOffset":"61s"}]}}}}}}`},
+				"DELETE /storage/v1/b/staging/o/{name}":            {body: ``},
+			},
+			wantCalls: []string{
+				"POST /upload/storage/v1/b/staging/o", "POST " + recognizer + ":batchRecognize",
+				"GET /v2/projects/p/locations/global/operations/7", "DELETE /storage/v1/b/staging/o/{name}",
+			},
+			wantConfig:   `{"autoDecodingConfig":{},"languageCodes":["en-US"],"model":"long","features":{},"translationConfig":{"targetLanguage":"en"}}`,
+			wantText:     "long talk",
+			wantLanguage: "en",
+			wantSegments: []model.Segment{{Start: 0, End: 61, Text: "long talk"}},
+		},
+		{
+			name:    "batch recognition failing",
+			cfg:     config.GoogleConfig{Bucket: "staging", PollInterval: time.Millisecond},
+			seconds: 61,
+			routes: map[string]route{
+				"POST /upload/storage/v1/b/staging/o":              {body: `{}`},
+				"POST " + recognizer + ":batchRecognize":           {body: operation},
+				"GET /v2/projects/p/locations/global/operations/7": {body: `{"done":true,"response":{"results":{"{uri}":{"error":{"code":3,"message":"Audio can't be decoded"}}}}}`},
+				"DELETE /storage/v1/b/staging/o/{name}":            {body: ``},
+			},
+			wantCalls: []string{
+				"POST /upload/storage/v1/b/staging/o", "POST " + recognizer + ":batchRecognize",
+				"GET /v2/projects/p/locations/global/operations/7", "DELETE /storage/v1/b/staging/o/{name}",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "long audio without a bucket",
+			seconds: 61,
+			wantErr: true,
+		},
+		{
+			name:    "quota exceeded",
+			seconds: 1,
+			routes: map[string]route{"POST " + recognizer + ":recognize": {
+				status: http.StatusTooManyRequests, body: `{"error":{"code":429,"message":"Quota exceeded"}}`,
+			}},
+			wantCalls:     []string{"POST " + recognizer + ":recognize"},
+			wantErr:       true,
+			wantRetryable: true,
+		},
+		{
+			name:    "invalid config",
+			cfg:     config.GoogleConfig{Diarization: &config.SpeakerCount{MaxSpeakers: 2}},
+			seconds: 1,
+			routes: map[string]route{"POST " + recognizer + ":recognize": {
+				status: http.StatusBadRequest, body: `{"error":{"code":400,"message":"Recognizer does not support diarization"}}`,
+			}},
+			wantCalls: []string{"POST " + recognizer + ":recognize"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls []string
+			var sent, staged string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					t.Errorf("%s %s sent without the token", r.Method, r.URL.Path)
+				}
+				body, _ := io.ReadAll(r.Body)
+				if r.URL.Path == "/upload/storage/v1/b/staging/o" {
+					staged = r.URL.Query().Get("name")
+				}
+				if strings.Contains(r.URL.Path, "/recognizers/") {
+					var request struct {
+						Config json.RawMessage `json:"config"`
+					}
+					json.Unmarshal(body, &request)
+					sent = string(request.Config)
+				}
+				call := r.Method + " " + r.URL.Path
+				if staged != "" {
+					call = strings.Replace(call, staged, "{name}", 1)
+				}
+				calls = append(calls, call)
+
+				rt, ok := tt.routes[call]
+				if !ok {
+					t.Errorf("unexpected %s", call)
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if rt.status != 0 {
+					w.WriteHeader(rt.status)
+				}
+				w.Write([]byte(strings.ReplaceAll(rt.body, "{uri}", "gs://staging/"+staged)))
+			}))
+			defer srv.Close()
+
+			gt := newGoogleTranscriber(tt.cfg, "p", staticTokens("secret"), srv.Client())
+			gt.speechURL, gt.storageURL = srv.URL, srv.URL
+			gt.SetLanguage("en-US")
+			text, metadata, err := gt.TranscriptWithOptions(writeAudio(t, tt.seconds), tt.opts)
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if err == nil || provider.IsRetryable(err) != tt.wantRetryable {
+					t.Errorf("TranscriptWithOptions() error = %v, want retryable %v", err, tt.wantRetryable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TranscriptWithOptions() error = %v", err)
+			}
+			if text != tt.wantText || metadata.Language != tt.wantLanguage || metadata.Speakers != tt.wantSpeakers ||
+				!reflect.DeepEqual(metadata.Segments, tt.wantSegments) {
+				t.Errorf("TranscriptWithOptions() = %q in %q by %d speakers, %+v", text, metadata.Language, metadata.Speakers, metadata.Segments)
+			}
+			var got, want any
+			json.Unmarshal([]byte(sent), &got)
+			json.Unmarshal([]byte(tt.wantConfig), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("sent config %s, want %s", sent, tt.wantConfig)
+			}
+		})
+	}
+}
+
+func TestServiceAccountTokens_Token(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant_type = %q", r.FormValue("grant_type"))
+		}
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if len(parts) != 3 {
+			t.Fatalf("assertion %q is not a JWT", r.FormValue("assertion"))
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], signature); err != nil {
+			t.Errorf("signature of the assertion: %v", err)
+		}
+		var claims struct {
+			Iss   string `json:"iss"`
+			Scope string `json:"scope"`
+			Aud   string `json:"aud"`
+		}
+		data, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		json.Unmarshal(data, &claims)
+		if claims.Iss != "v2t@p.iam.gserviceaccount.com" || claims.Scope != scope || claims.Aud != "http://"+r.Host+"/token" {
+			t.Errorf("claims = %+v", claims)
+		}
+		w.Write([]byte(`{"access_token":"ya29.token","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer srv.Close()
+
+	// the key is PKCS #1, the fallback of the PKCS #8 keys the console creates
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	path := filepath.Join(t.TempDir(), "sa.json")
+	data, _ := json.Marshal(map[string]string{
+		"type": "service_account", "project_id": "p", "client_email": "v2t@p.iam.gserviceaccount.com",
+		"private_key": string(keyPEM), "token_uri": srv.URL + "/token",
+	})
+	if err = os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := readCredentials(path)
+	if err != nil {
+		t.Fatalf("readCredentials() error = %v", err)
+	}
+	tokens, err := newServiceAccountTokens(creds, srv.Client())
+	if err != nil {
+		t.Fatalf("newServiceAccountTokens() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		token, err := tokens.Token(context.Background())
+		if err != nil || token != "ya29.token" {
+			t.Errorf("Token() = %q, %v, want ya29.token", token, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requested %d tokens, want the first one kept", requests)
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the transcriber while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open, too many consecutive transcription failures")
+
+// CircuitBreaker stops calling a failing transcriber, so a dead backend fails the remaining files
+// quickly instead of each of them waiting for its own timeouts.
+type CircuitBreaker struct {
+	failures int
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	consecutive int
+	openUntil   time.Time
+}
+
+// NewCircuitBreaker opens after failures consecutive failures and lets a trial call through after cooldown.
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	if failures < 1 {
+		failures = 5
+	}
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	return &CircuitBreaker{failures: failures, cooldown: cooldown, now: time.Now}
+}
+
+func (b *CircuitBreaker) Middleware() Middleware {
+	return func(next Func) Func {
+		return func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+			if !b.allow() {
+				return "", model.ProviderMetadata{}, ErrCircuitOpen
+			}
+			text, metadata, err := next(ctx, inputFilePath, onPartial)
+			b.record(err)
+			return text, metadata, err
+		}
+	}
+}
+
+// allow reports whether a call may go through, once the cooldown passed a single trial call is let through.
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.consecutive < b.failures {
+		return true
+	}
+	now := b.now()
+	if now.Before(b.openUntil) {
+		return false
+	}
+	// Half open, further calls wait for the outcome of this one
+	b.openUntil = now.Add(b.cooldown)
+	return true
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.consecutive = 0
+		return
+	}
+	b.consecutive++
+	if b.consecutive >= b.failures {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/util/files"
+)
+
+type cacheEntry struct {
+	Text     string                 `json:"text"`
+	Metadata model.ProviderMetadata `json:"metadata"`
+	// Segments are kept apart, the metadata doesn't serialize them.
+	Segments []model.Segment `json:"segments,omitempty"`
+}
+
+// Cache stores successful results in dir keyed by the audio content, so the same audio
+// isn't transcribed twice even under another name. dir defaults to the user cache directory.
+// Results of another engine are returned as well, clear dir after switching engines.
+func Cache(dir string) Middleware {
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			userDir = os.TempDir()
+		}
+		dir = filepath.Join(userDir, "v2t", "transcriptions")
+	}
+
+	return func(next Func) Func {
+		return func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+			key, err := fileHash(inputFilePath)
+			if err != nil {
+				logging.L().Error("Error hashing, not caching it", "file", inputFilePath, "error", err)
+				return next(ctx, inputFilePath, onPartial)
+			}
+			path := filepath.Join(dir, key+".json")
+
+			if entry, ok := readCacheEntry(path); ok {
+				logging.L().Info("Using cached transcription", "file", inputFilePath)
+				if onPartial != nil && entry.Text != "" {
+					onPartial(model.Segment{Text: entry.Text})
+				}
+				entry.Metadata.Segments = entry.Segments
+				return entry.Text, entry.Metadata, nil
+			}
+
+			text, metadata, err := next(ctx, inputFilePath, onPartial)
+			if err != nil {
+				return text, metadata, err
+			}
+			if data, merr := json.Marshal(cacheEntry{Text: text, Metadata: metadata, Segments: metadata.Segments}); merr == nil {
+				if werr := files.WriteFileAtomic(path, data, 0644); werr != nil {
+					logging.L().Error("Error caching transcription", "file", inputFilePath, "error", werr)
+				}
+			}
+			return text, metadata, nil
+		}
+	}
+}
+
+func readCacheEntry(path string) (cacheEntry, bool) {
+	if !files.IsComplete(path) {
+		return cacheEntry{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cacheEntry{}, false
+	}
+
+	var entry cacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		logging.L().Warn("Ignoring corrupt cache entry", "path", path, "error", err)
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("read %s failed: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
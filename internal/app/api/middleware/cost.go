@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"tiktok-whisper/internal/app/audio"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+)
+
+// audioDuration is replaced in tests.
+var audioDuration = audio.GetAudioDuration
+
+// Cost records the price of each transcription in its provider metadata, the audio duration
+// at costPerMinute, and logs the running total.
+func Cost(costPerMinute float64) Middleware {
+	var mu sync.Mutex
+	var total float64
+
+	return func(next Func) Func {
+		return func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+			text, metadata, err := next(ctx, inputFilePath, onPartial)
+			if err != nil {
+				return text, metadata, err
+			}
+
+			seconds := metadata.DurationSeconds
+			if seconds == 0 {
+				d, derr := audioDuration(inputFilePath)
+				if derr != nil {
+					logging.L().Error("Error getting duration for cost tracking", "file", inputFilePath, "error", derr)
+					return text, metadata, nil
+				}
+				seconds = float64(d)
+			}
+			metadata.Cost = seconds / 60 * costPerMinute
+
+			mu.Lock()
+			total += metadata.Cost
+			sum := total
+			mu.Unlock()
+			logging.L().Info("Transcription cost", "file", inputFilePath, "cost", metadata.Cost, "total", sum)
+			return text, metadata, nil
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// Metrics counts the transcriptions passing through it and the time they took.
+type Metrics struct {
+	mu       sync.Mutex
+	calls    int
+	failures int
+	total    time.Duration
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+func (m *Metrics) Middleware() Middleware {
+	return func(next Func) Func {
+		return func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+			start := time.Now()
+			text, metadata, err := next(ctx, inputFilePath, onPartial)
+			elapsed := time.Since(start)
+
+			calls, failures, total := m.record(elapsed, err)
+			logging.L().Info("Transcribed", "file", inputFilePath, "elapsed", elapsed.Round(time.Millisecond), "error", err,
+				"calls", calls, "failed", failures, "total", total.Round(time.Millisecond))
+			return text, metadata, err
+		}
+	}
+}
+
+func (m *Metrics) record(elapsed time.Duration, err error) (calls, failures int, total time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	if err != nil {
+		m.failures++
+	}
+	m.total += elapsed
+	return m.calls, m.failures, m.total
+}
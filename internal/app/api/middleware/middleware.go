@@ -0,0 +1,86 @@
+// Package middleware wraps a transcriber with cross-cutting behavior such as retries, rate limiting,
+// caching, metrics, cost tracking and circuit breaking, composed from the list in config.yaml.
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+)
+
+// Func transcribes one file, onPartial is nil unless the caller streams. ctx is the one the
+// conversion passed to TranscriptContext, the background otherwise.
+type Func func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error)
+
+// Middleware adds behavior around the next Func of the chain.
+type Middleware func(next Func) Func
+
+// Transcriber runs a transcriber through a chain of middlewares. It streams when the wrapped transcriber does.
+type Transcriber struct {
+	fn Func
+}
+
+// New wraps inner with mws, the first middleware is the outermost.
+func New(inner api.Transcriber, mws ...Middleware) *Transcriber {
+	fn := func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+		if onPartial != nil {
+			return provider.Stream(inner, inputFilePath, onPartial)
+		}
+		return provider.Transcribe(ctx, inner, inputFilePath)
+	}
+
+	for i := len(mws) - 1; i >= 0; i-- {
+		fn = mws[i](fn)
+	}
+	return &Transcriber{fn: fn}
+}
+
+func (t *Transcriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := t.fn(context.Background(), inputFilePath, nil)
+	return text, err
+}
+
+func (t *Transcriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return t.fn(context.Background(), inputFilePath, nil)
+}
+
+// TranscriptContext works like TranscriptWithMetadata and hands ctx down the chain to the wrapped transcriber.
+func (t *Transcriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return t.fn(ctx, inputFilePath, nil)
+}
+
+// TranscriptStream streams the segments of the wrapped transcriber through the chain,
+// one that can't stream reports its whole text once it is done.
+func (t *Transcriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	return t.fn(context.Background(), inputFilePath, func(s model.Segment) { partials <- s })
+}
+
+// FromConfig builds the middlewares listed in config.yaml, in their configured order.
+func FromConfig(cfgs []config.MiddlewareConfig) ([]Middleware, error) {
+	mws := make([]Middleware, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		var mw Middleware
+		switch cfg.Name {
+		case "retry":
+			mw = Retry(cfg.Attempts, cfg.Backoff)
+		case "rate_limit":
+			mw = RateLimit(cfg.RequestsPerMinute)
+		case "cache":
+			mw = Cache(cfg.Dir)
+		case "metrics":
+			mw = NewMetrics().Middleware()
+		case "cost":
+			mw = Cost(cfg.CostPerMinute)
+		case "circuit_breaker":
+			mw = NewCircuitBreaker(cfg.Failures, cfg.Cooldown).Middleware()
+		default:
+			return nil, fmt.Errorf("unknown middleware %q", cfg.Name)
+		}
+		mws = append(mws, mw)
+	}
+	return mws, nil
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/testutil"
+	"time"
+)
+
+// fakeTranscriber fails the first failures calls, with err when it is set.
+type fakeTranscriber struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *fakeTranscriber) Transcript(inputFilePath string) (string, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		if f.err != nil {
+			return "", f.err
+		}
+		return "", errors.New("backend unavailable")
+	}
+	return "text of " + filepath.Base(inputFilePath), nil
+}
+
+func noSleep(t *testing.T) *[]time.Duration {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	t.Cleanup(func() { sleep = time.Sleep })
+	return &slept
+}
+
+func TestNew_Order(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Func) Func {
+			return func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+				order = append(order, name)
+				return next(ctx, inputFilePath, onPartial)
+			}
+		}
+	}
+
+	tr := New(&fakeTranscriber{}, trace("outer"), trace("inner"))
+	if _, err := tr.Transcript("a.mp3"); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("middlewares ran in order %v, want [outer inner]", order)
+	}
+}
+
+// contextTranscriber reports the value of ctxKey its ctx carries as the text.
+type contextTranscriber struct{ fakeTranscriber }
+
+type ctxKey struct{}
+
+func (c *contextTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return c.TranscriptContext(context.Background(), inputFilePath)
+}
+
+func (c *contextTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	value, _ := ctx.Value(ctxKey{}).(string)
+	return value, model.ProviderMetadata{}, nil
+}
+
+func TestTranscriber_TranscriptContext(t *testing.T) {
+	tr := New(&contextTranscriber{}, Retry(2, time.Second), NewMetrics().Middleware())
+	ctx := context.WithValue(context.Background(), ctxKey{}, "span of the conversion")
+	if text, _, err := tr.TranscriptContext(ctx, "a.mp3"); err != nil || text != "span of the conversion" {
+		t.Errorf("TranscriptContext() = %q, %v, want ctx to reach the transcriber", text, err)
+	}
+	if text, _, err := tr.TranscriptWithMetadata("a.mp3"); err != nil || text != "" {
+		t.Errorf("TranscriptWithMetadata() = %q, %v, want the background", text, err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		attempts  int
+		wantErr   bool
+		wantCalls int
+		wantSleep []time.Duration
+	}{
+		{name: "recovers", failures: 2, attempts: 3, wantCalls: 3, wantSleep: []time.Duration{time.Second, 2 * time.Second}},
+		{name: "gives up", failures: 5, attempts: 2, wantErr: true, wantCalls: 2, wantSleep: []time.Duration{time.Second}},
+		{name: "first try", failures: 0, attempts: 3, wantCalls: 1},
+		{name: "permanent", failures: 5, err: provider.NewTranscriptionError("openai", false, errors.New("invalid file format")), attempts: 3, wantErr: true, wantCalls: 1},
+		{name: "retryable", failures: 1, err: provider.NewTranscriptionError("openai", true, errors.New("rate limited")), attempts: 3, wantCalls: 2, wantSleep: []time.Duration{time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slept := noSleep(t)
+			logger := testutil.UseMockLogger(t)
+			inner := &fakeTranscriber{failures: tt.failures, err: tt.err}
+
+			_, err := New(inner, Retry(tt.attempts, time.Second)).Transcript("a.mp3")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Transcript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if inner.calls != tt.wantCalls || len(*slept) != len(tt.wantSleep) {
+				t.Fatalf("calls = %d, slept %v, want %d calls, slept %v", inner.calls, *slept, tt.wantCalls, tt.wantSleep)
+			}
+			for i := range tt.wantSleep {
+				if (*slept)[i] != tt.wantSleep[i] {
+					t.Errorf("slept %v, want %v", *slept, tt.wantSleep)
+				}
+			}
+			entry, logged := logger.Find("warn", "retrying")
+			if logged != (len(tt.wantSleep) > 0) || logged && (entry.Fields["file"] != "a.mp3" || entry.Fields["attempt"] != 1) {
+				t.Errorf("logged %+v, want a warning per retry", logger.Entries())
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	slept := noSleep(t)
+	tr := New(&fakeTranscriber{}, RateLimit(60))
+
+	for i := 0; i < 3; i++ {
+		tr.Transcript("a.mp3")
+	}
+	// The first call goes right away, the others wait for their slot a second apart
+	if len(*slept) != 2 || (*slept)[0] < 900*time.Millisecond || (*slept)[1] < 1900*time.Millisecond {
+		t.Errorf("slept %v, want about [1s 2s]", *slept)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	inner := &fakeTranscriber{failures: 3}
+	tr := New(inner, b.Middleware())
+
+	steps := []struct {
+		advance   time.Duration
+		wantErr   error
+		wantCalls int
+	}{
+		{wantCalls: 1},
+		{wantCalls: 2},
+		{wantErr: ErrCircuitOpen, wantCalls: 2},
+		{advance: time.Minute, wantCalls: 3},
+		{wantErr: ErrCircuitOpen, wantCalls: 3},
+		{advance: time.Minute, wantCalls: 4},
+		{wantCalls: 5},
+	}
+	for i, s := range steps {
+		now = now.Add(s.advance)
+		_, err := tr.Transcript("a.mp3")
+		if s.wantErr != nil && !errors.Is(err, s.wantErr) {
+			t.Errorf("step %d: error = %v, want %v", i, err, s.wantErr)
+		}
+		if inner.calls != s.wantCalls {
+			t.Errorf("step %d: calls = %d, want %d", i, inner.calls, s.wantCalls)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	audio := filepath.Join(dir, "a.mp3")
+	copied := filepath.Join(dir, "b.mp3")
+	os.WriteFile(audio, []byte("audio"), 0644)
+	os.WriteFile(copied, []byte("audio"), 0644)
+
+	inner := &fakeTranscriber{}
+	tr := New(inner, Cache(filepath.Join(dir, "cache")))
+
+	first, err := tr.Transcript(audio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := tr.Transcript(copied)
+	if err != nil || second != first || inner.calls != 1 {
+		t.Errorf("Transcript() of the same audio = %q, %v after %d calls, want the cached %q", second, err, inner.calls, first)
+	}
+}
+
+func TestCost(t *testing.T) {
+	original := audioDuration
+	audioDuration = func(filePath string) (int, error) { return 90, nil }
+	defer func() { audioDuration = original }()
+
+	_, metadata, err := New(&fakeTranscriber{}, Cost(0.006)).TranscriptWithMetadata("a.mp3")
+	if err != nil || metadata.Cost < 0.0089 || metadata.Cost > 0.0091 {
+		t.Errorf("TranscriptWithMetadata() cost = %v, %v, want 0.009", metadata.Cost, err)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if _, err := FromConfig([]config.MiddlewareConfig{{Name: "retry"}, {Name: "metrics"}}); err != nil {
+		t.Errorf("FromConfig() error = %v", err)
+	}
+	if _, err := FromConfig([]config.MiddlewareConfig{{Name: "telepathy"}}); err == nil {
+		t.Errorf("FromConfig() of an unknown middleware succeeded")
+	}
+}
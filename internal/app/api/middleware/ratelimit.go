@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// RateLimit spaces the calls to next evenly so at most requestsPerMinute start per minute,
+// it is shared by parallel conversions. Zero disables it.
+func RateLimit(requestsPerMinute float64) Middleware {
+	if requestsPerMinute <= 0 {
+		return func(next Func) Func { return next }
+	}
+
+	interval := time.Duration(float64(time.Minute) / requestsPerMinute)
+	var mu sync.Mutex
+	var next time.Time
+
+	// reserve returns how long the caller has to wait for its slot
+	reserve := func() time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now()
+		if next.Before(now) {
+			next = now
+		}
+		wait := next.Sub(now)
+		next = next.Add(interval)
+		return wait
+	}
+
+	return func(nextFn Func) Func {
+		return func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+			if wait := reserve(); wait > 0 {
+				sleep(wait)
+			}
+			return nextFn(ctx, inputFilePath, onPartial)
+		}
+	}
+}
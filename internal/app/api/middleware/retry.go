@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// Retry calls next up to attempts times, waiting backoff after the first failure and twice as long after each further one.
+// Failures a provider reports as not retryable, such as audio it can't decode, are returned right away.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	return func(next Func) Func {
+		return func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+			wait := backoff
+			for attempt := 1; ; attempt++ {
+				text, metadata, err := next(ctx, inputFilePath, onPartial)
+				if err == nil || attempt >= attempts || permanent(err) {
+					return text, metadata, err
+				}
+
+				logging.L().Warn("Transcription failed, retrying", "file", inputFilePath, "attempt", attempt, "attempts", attempts, "wait", wait, "error", err)
+				sleep(wait)
+				wait *= 2
+			}
+		}
+	}
+}
+
+// permanent reports whether err is a TranscriptionError the provider marked as not retryable.
+func permanent(err error) bool {
+	var te *provider.TranscriptionError
+	return errors.As(err, &te) && !te.Retryable
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/audio/vad"
+	"tiktok-whisper/internal/app/cleanup"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+)
+
+// minTrimSeconds of silence make the re-encoding of the audio worth it.
+const minTrimSeconds = 1
+
+// vadDetect and vadTrim are replaced in tests.
+var (
+	vadDetect = vad.Detect
+	vadTrim   = vad.Trim
+)
+
+// VAD cuts the long silences out of the audio before the rest of the chain transcribes it. Segment
+// timings are mapped back to the original audio and the cut seconds are stored as TrimmedSeconds.
+// Audio whose detection fails or finds no speech is transcribed whole. The trimmed audio is a temp
+// file of tracker.
+func VAD(opts vad.Options, tracker *cleanup.Tracker) Middleware {
+	return func(next Func) Func {
+		return func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+			r, err := vadDetect(inputFilePath, opts)
+			if err != nil {
+				logging.L().Error("Error detecting speech, transcribing it whole", "file", inputFilePath, "error", err)
+				return next(ctx, inputFilePath, onPartial)
+			}
+			if len(r.Speech) == 0 {
+				logging.L().Warn("No speech found, transcribing it whole", "file", inputFilePath)
+				return next(ctx, inputFilePath, onPartial)
+			}
+			if r.TrimmedSeconds() < minTrimSeconds {
+				return next(ctx, inputFilePath, onPartial)
+			}
+
+			job := inputFilePath + "#vad"
+			defer func() {
+				if _, err := tracker.Release(job); err != nil {
+					logging.L().Error("Error removing trimmed audio", "file", inputFilePath, "error", err)
+				}
+			}()
+			trimmed := filepath.Join(tracker.Dir(), strings.TrimSuffix(filepath.Base(inputFilePath), filepath.Ext(inputFilePath))+"_vad.wav")
+			if err = tracker.Track(job, trimmed); err != nil {
+				return "", model.ProviderMetadata{}, err
+			}
+			if err = vadTrim(inputFilePath, trimmed, r); err != nil {
+				return "", model.ProviderMetadata{}, fmt.Errorf("trim silences failed: %v", err)
+			}
+			logging.L().Info("Cut silence", "file", inputFilePath, "trimmed_seconds", r.TrimmedSeconds(), "speech_seconds", r.SpeechSeconds())
+
+			if onPartial != nil {
+				partial := onPartial
+				onPartial = func(s model.Segment) { partial(toOriginal(s, r)) }
+			}
+			text, metadata, err := next(ctx, trimmed, onPartial)
+			if err != nil {
+				return text, metadata, err
+			}
+			for i, s := range metadata.Segments {
+				metadata.Segments[i] = toOriginal(s, r)
+			}
+			metadata.TrimmedSeconds = r.TrimmedSeconds()
+			return text, metadata, nil
+		}
+	}
+}
+
+// toOriginal moves the timings of a segment of the trimmed audio to the original audio,
+// segments without timings are left alone.
+func toOriginal(s model.Segment, r vad.Result) model.Segment {
+	if !s.Timed() {
+		return s
+	}
+	s.Start, s.End = r.Original(s.Start), r.Original(s.End)
+	if len(s.Words) > 0 {
+		words := make([]model.Word, len(s.Words))
+		for i, w := range s.Words {
+			w.Start, w.End = r.Original(w.Start), r.Original(w.End)
+			words[i] = w
+		}
+		s.Words = words
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/audio/vad"
+	"tiktok-whisper/internal/app/cleanup"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestVAD(t *testing.T) {
+	tests := []struct {
+		name         string
+		result       vad.Result
+		detectErr    error
+		wantFile     string
+		wantSegments []model.Segment
+		wantTrimmed  float64
+	}{
+		{
+			name:         "silences cut",
+			result:       vad.Result{Duration: 60, Speech: []vad.Span{{Start: 2, End: 20}, {Start: 25, End: 58}}},
+			wantFile:     "talk_vad.wav",
+			wantSegments: []model.Segment{{Start: 12, End: 19, Text: "a"}, {Start: 29, End: 37, Text: "b", Words: []model.Word{{Start: 35, End: 37, Text: "b"}}}},
+			wantTrimmed:  9,
+		},
+		{
+			name:         "too little silence",
+			result:       vad.Result{Duration: 60, Speech: []vad.Span{{Start: 0.5, End: 60}}},
+			wantFile:     "talk.mp3",
+			wantSegments: []model.Segment{{Start: 10, End: 17, Text: "a"}, {Start: 22, End: 30, Text: "b", Words: []model.Word{{Start: 28, End: 30, Text: "b"}}}},
+		},
+		{
+			name:         "detection failed",
+			detectErr:    errors.New("ffmpeg not found"),
+			wantFile:     "talk.mp3",
+			wantSegments: []model.Segment{{Start: 10, End: 17, Text: "a"}, {Start: 22, End: 30, Text: "b", Words: []model.Word{{Start: 28, End: 30, Text: "b"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vadDetect = func(inputFilePath string, opts vad.Options) (vad.Result, error) { return tt.result, tt.detectErr }
+			vadTrim = func(inputFilePath string, outputFilePath string, r vad.Result) error {
+				return os.WriteFile(outputFilePath, []byte("wav"), 0644)
+			}
+			t.Cleanup(func() { vadDetect, vadTrim = vad.Detect, vad.Trim })
+			tracker := cleanup.NewTracker(t.TempDir())
+
+			var transcribed string
+			next := func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+				transcribed = inputFilePath
+				if _, err := os.Stat(inputFilePath); tt.wantFile != "talk.mp3" && err != nil {
+					t.Errorf("trimmed audio missing while transcribing: %v", err)
+				}
+				return "a b", model.ProviderMetadata{Provider: "fake", Segments: []model.Segment{
+					{Start: 10, End: 17, Text: "a"},
+					{Start: 22, End: 30, Text: "b", Words: []model.Word{{Start: 28, End: 30, Text: "b"}}},
+				}}, nil
+			}
+
+			text, metadata, err := VAD(vad.DefaultOptions(), tracker)(next)(context.Background(), "/in/talk.mp3", nil)
+			if err != nil || text != "a b" {
+				t.Fatalf("VAD() = %q, %v", text, err)
+			}
+			if filepath.Base(transcribed) != tt.wantFile {
+				t.Errorf("transcribed %s, want %s", transcribed, tt.wantFile)
+			}
+			if !reflect.DeepEqual(metadata.Segments, tt.wantSegments) || metadata.TrimmedSeconds != tt.wantTrimmed {
+				t.Errorf("VAD() metadata = %+v, want segments %+v and %vs trimmed", metadata, tt.wantSegments, tt.wantTrimmed)
+			}
+			if _, err = os.Stat(transcribed); tt.wantFile != "talk.mp3" && !os.IsNotExist(err) {
+				t.Errorf("trimmed audio left behind: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"context"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/vocab"
+)
+
+// Vocabulary corrects the variants of the terms of packs in the transcription to their preferred
+// spelling, for the language the provider reported. Abbreviations of expanding packs are expanded
+// once in the text, the segments are only corrected. The number of corrections is stored as Corrections.
+func Vocabulary(packs vocab.Packs) Middleware {
+	segmentPacks := packs.Unexpanded()
+	return func(next Func) Func {
+		return func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+			text, metadata, err := next(ctx, inputFilePath, onPartial)
+			if err != nil {
+				return text, metadata, err
+			}
+
+			text, count := packs.Correct(text, metadata.Language)
+			for i, s := range metadata.Segments {
+				metadata.Segments[i].Text, _ = segmentPacks.Correct(s.Text, metadata.Language)
+			}
+			if count > 0 {
+				logging.L().Info("Corrected vocabulary", "file", inputFilePath, "corrections", count)
+			}
+			metadata.Corrections = count
+			return text, metadata, nil
+		}
+	}
+}
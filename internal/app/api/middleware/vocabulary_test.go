@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/vocab"
+)
+
+func TestVocabulary(t *testing.T) {
+	packs := vocab.Packs{
+		{Name: "medical-en", Language: "en", Expand: true, Terms: []vocab.Term{
+			{Term: "ECG", Variants: []string{"EKG"}, Expansion: "electrocardiogram"},
+		}},
+	}
+	next := func(ctx context.Context, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+		return "The EKG. Another EKG.", model.ProviderMetadata{Provider: "fake", Language: "en", Segments: []model.Segment{
+			{Start: 0, End: 2, Text: " The EKG."},
+			{Start: 2, End: 4, Text: " Another EKG."},
+		}}, nil
+	}
+
+	text, metadata, err := Vocabulary(packs)(next)(context.Background(), "a.mp3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "The ECG (electrocardiogram). Another ECG."; text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	if metadata.Segments[0].Text != " The ECG." || metadata.Segments[1].Text != " Another ECG." {
+		t.Errorf("segments = %v, want corrected without expansions", metadata.Segments)
+	}
+	if metadata.Corrections != 2 {
+		t.Errorf("Corrections = %d, want 2", metadata.Corrections)
+	}
+}
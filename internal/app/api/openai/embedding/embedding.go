@@ -4,18 +4,39 @@ import (
 	"context"
 	"github.com/sashabaranov/go-openai"
 	openai2 "tiktok-whisper/internal/app/api/openai"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/config/secrets"
+	"tiktok-whisper/internal/app/llm/cache"
+	"tiktok-whisper/internal/app/tracing"
 )
 
-func Embedding(text string) (openai.EmbeddingResponse, error) {
+// Embedding requests the embedding of text, the request joins the trace of ctx. Embeddings of the
+// same text are answered from the cache of llm.cache unless that is disabled. It always asks the
+// OpenAI API and fails without its key, whatever embedding in config.yaml sets, see
+// embedding/provider for the configured model.
+func Embedding(ctx context.Context, text string) (openai.EmbeddingResponse, error) {
+	store := cache.FromConfig(config.Get().LLM.Cache)
+	key := cache.Key("embedding", openai.DavinciSimilarity.String(), text)
+	var resp openai.EmbeddingResponse
+	if store != nil && store.Get(key, &resp) {
+		return resp, nil
+	}
+
+	if _, err := secrets.Key("openai"); err != nil {
+		return resp, err
+	}
 	client := openai2.GetClient()
-	ctx := context.Background()
+	ctx, span := tracing.Start(ctx, "embedding", tracing.String("v2t.model", openai.DavinciSimilarity.String()))
+	defer span.End()
 
 	request := openai.EmbeddingRequest{
 		Model: openai.DavinciSimilarity,
-		Input: []string{
-			"text",
-		},
+		Input: []string{text},
 	}
 	resp, err := client.CreateEmbeddings(ctx, request)
+	span.RecordError(err)
+	if err == nil && store != nil {
+		store.Put(key, resp)
+	}
 	return resp, err
 }
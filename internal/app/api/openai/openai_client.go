@@ -2,8 +2,9 @@ package openai
 
 import (
 	"github.com/sashabaranov/go-openai"
-	"os"
 	"sync"
+	"tiktok-whisper/internal/app/api/requestmeta"
+	"tiktok-whisper/internal/app/config/secrets"
 )
 
 var (
@@ -13,11 +14,13 @@ var (
 
 func GetClient() *openai.Client {
 	once.Do(func() {
-		token, ok := os.LookupEnv("OPENAI_API_KEY")
-		if !ok {
-			panic("OPENAI_API_KEY environment variable not set")
+		token, err := secrets.Key("openai")
+		if err != nil {
+			panic(err)
 		}
-		singleton = openai.NewClient(token)
+		cfg := openai.DefaultConfig(token)
+		cfg.HTTPClient = requestmeta.NewClient("openai")
+		singleton = openai.NewClientWithConfig(cfg)
 	})
 
 	return singleton
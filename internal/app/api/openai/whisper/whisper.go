@@ -2,32 +2,153 @@ package whisper
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/sashabaranov/go-openai"
+	"net/http"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/vocab"
+	"time"
 )
 
+const providerName = "openai"
+
+const healthCheckTimeout = 30 * time.Second
+
 // RemoteTranscriber implements remote transcription using the OpenAI API.
 type RemoteTranscriber struct {
-	client *openai.Client
+	client   *openai.Client
+	language string
+	request  *model.RequestMetadata
+	decoding config.DecodingConfig
+	// verbatim keeps fillers and stutters, see config.VerbatimConfig
+	verbatim bool
+	// vocabulary are the installed vocabulary packs, their terms bias the prompt
+	vocabulary vocab.Packs
 }
 
 // NewRemoteTranscriber creates a new RemoteTranscriber instance.
 func NewRemoteTranscriber(client *openai.Client) *RemoteTranscriber {
-	return &RemoteTranscriber{client: client}
+	return &RemoteTranscriber{
+		client:     client,
+		request:    config.GetProviders().For(providerName).Record(),
+		decoding:   config.GetProviders().For(providerName).Decoding,
+		verbatim:   config.Get().Verbatim.Default,
+		vocabulary: vocab.Installed(),
+	}
+}
+
+// SetLanguage sends language with the requests, the API detects the language when it is empty.
+func (rt *RemoteTranscriber) SetLanguage(language string) {
+	rt.language = language
+}
+
+// SupportedFormats are the file types the transcription API accepts, other audio is converted to mp3.
+func (rt *RemoteTranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"mp3", "m4a", "mp4", "mpeg", "mpga", "wav", "webm", "ogg", "flac"}}
 }
 
 // Transcript uses the OpenAI API for remote transcription.
 func (rt *RemoteTranscriber) Transcript(inputFilePath string) (string, error) {
-	ctx := context.Background()
+	text, _, err := rt.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
 
-	req := openai.AudioRequest{
+// TranscriptWithMetadata works like Transcript and also reports the model used.
+func (rt *RemoteTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return rt.TranscriptWithPrompt(inputFilePath, "")
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata and sends prompt as the initial prompt.
+func (rt *RemoteTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return rt.TranscriptWithOptions(inputFilePath, api.Options{Prompt: prompt})
+}
+
+// TranscriptWithOptions works like TranscriptWithPrompt with opts.Prompt, which NoContext drops, and sends the temperature.
+// Translate sends the audio to the translations endpoint.
+// The API has no no-speech threshold or VAD, those are ignored.
+func (rt *RemoteTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return rt.transcribe(context.Background(), inputFilePath, opts)
+}
+
+// TranscriptContext works like TranscriptWithOptions with the options ctx carries and sends the request with ctx.
+func (rt *RemoteTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return rt.transcribe(ctx, inputFilePath, api.OptionsFrom(ctx))
+}
+
+func (rt *RemoteTranscriber) transcribe(ctx context.Context, inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	prompt := opts.Prompt
+	if opts.NoContext || rt.decoding.NoContext() {
+		prompt = ""
+	}
+	if rt.verbatim {
+		prompt = api.VerbatimPrompt(rt.language) + prompt
+	}
+	prompt = rt.vocabulary.Prompt(rt.language) + prompt
+	metadata := model.ProviderMetadata{
+		Provider: providerName,
 		Model:    openai.Whisper1,
-		FilePath: inputFilePath,
+		Language: rt.language,
+		Request:  rt.request,
+		Verbatim: rt.verbatim,
+		OpenAI:   &model.OpenAIMetadata{Endpoint: "transcriptions"},
+	}
+
+	req := openai.AudioRequest{
+		Model:       openai.Whisper1,
+		FilePath:    inputFilePath,
+		Prompt:      prompt,
+		Temperature: opts.Temperature,
+		Language:    rt.language,
 	}
-	resp, err := rt.client.CreateTranscription(ctx, req)
+	// the API has no word threshold nor segment length, only the translations endpoint translates
+	create, call := rt.client.CreateTranscription, "createTranscription"
+	if opts.Translate {
+		create, call = rt.client.CreateTranslation, "createTranslation"
+		req.Language = ""
+		metadata.Language = "en"
+		metadata.OpenAI.Endpoint = "translations"
+	}
+	resp, err := create(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("createTranscription failed: %s", err)
+		return "", metadata, provider.NewTranscriptionError(providerName, retryable(err), fmt.Errorf("%s failed: %w", call, err))
 	}
 
-	return resp.Text, nil
+	return resp.Text, metadata, nil
+}
+
+// HealthCheck lists the models, which needs valid credentials and a reachable API.
+func (rt *RemoteTranscriber) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	if _, err := rt.client.ListModels(ctx); err != nil {
+		return provider.NewTranscriptionError(providerName, retryable(err), fmt.Errorf("listModels failed: %w", err))
+	}
+	return nil
+}
+
+// retryable reports whether another provider may succeed where the API failed: network errors,
+// rejected credentials, rate limits and server errors are, requests rejected for their audio aren't.
+func retryable(err error) bool {
+	status := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+
+	switch {
+	case status == 0, status >= 500:
+		return true
+	case status == http.StatusUnauthorized, status == http.StatusForbidden,
+		status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	}
+	return false
 }
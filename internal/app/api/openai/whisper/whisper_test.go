@@ -0,0 +1,53 @@
+package whisper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestRemoteTranscriber_TranscriptContext(t *testing.T) {
+	var path, language, temperature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, language, temperature = r.URL.Path, r.FormValue("language"), r.FormValue("temperature")
+		w.Write([]byte(`{"text": "hello"}`))
+	}))
+	defer server.Close()
+	cfg := openai.DefaultConfig("key")
+	cfg.BaseURL = server.URL
+	input := filepath.Join(t.TempDir(), "talk.mp3")
+	os.WriteFile(input, []byte("mp3"), 0644)
+
+	tests := []struct {
+		name         string
+		opts         api.Options
+		wantPath     string
+		wantLanguage string
+		wantEndpoint string
+	}{
+		{name: "transcribed", wantPath: "/audio/transcriptions", wantLanguage: "zh", wantEndpoint: "transcriptions"},
+		{name: "translated", opts: api.Options{Translate: true, Temperature: 0.2}, wantPath: "/audio/translations", wantEndpoint: "translations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := NewRemoteTranscriber(openai.NewClientWithConfig(cfg))
+			rt.SetLanguage("zh")
+			text, metadata, err := rt.TranscriptContext(api.WithOptions(context.Background(), tt.opts), input)
+			if err != nil || text != "hello" {
+				t.Fatalf("TranscriptContext() = %q, %v", text, err)
+			}
+			if path != tt.wantPath || language != tt.wantLanguage || metadata.OpenAI.Endpoint != tt.wantEndpoint {
+				t.Errorf("sent to %s in %q, endpoint %q, want %s in %q", path, language, metadata.OpenAI.Endpoint, tt.wantPath, tt.wantLanguage)
+			}
+			if tt.opts.Temperature > 0 && temperature != "0.20" {
+				t.Errorf("temperature = %q, want 0.2", temperature)
+			}
+		})
+	}
+}
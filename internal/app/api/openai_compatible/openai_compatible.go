@@ -0,0 +1,357 @@
+// Package openai_compatible transcribes with any service exposing the OpenAI /audio/transcriptions
+// API, e.g. Groq, LocalAI or vLLM, at the base URL, model and key of providers.yaml.
+package openai_compatible
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/requestmeta"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/config/secrets"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/vocab"
+	"time"
+)
+
+const providerName = "openai_compatible"
+
+const (
+	defaultModel          = "whisper-1"
+	defaultResponseFormat = "verbose_json"
+)
+
+const healthCheckTimeout = 10 * time.Second
+
+// CompatibleTranscriber transcribes with one OpenAI-compatible service.
+type CompatibleTranscriber struct {
+	cfg      config.EndpointConfig
+	key      string
+	baseURL  string
+	client   *http.Client
+	language string
+	request  *model.RequestMetadata
+	decoding config.DecodingConfig
+	// verbatim keeps fillers and stutters, see config.VerbatimConfig
+	verbatim bool
+	// vocabulary are the installed vocabulary packs, their terms bias the prompt
+	vocabulary vocab.Packs
+}
+
+// NewCompatibleTranscriber creates the transcriber of the service of cfg authenticated with key, no
+// token is sent when key is empty. Requests are sent with client.
+func NewCompatibleTranscriber(cfg config.EndpointConfig, key string, client *http.Client) *CompatibleTranscriber {
+	if cfg.Model == "" {
+		cfg.Model = defaultModel
+	}
+	if cfg.ResponseFormat == "" {
+		cfg.ResponseFormat = defaultResponseFormat
+	}
+	return &CompatibleTranscriber{
+		cfg:        cfg,
+		key:        key,
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		client:     client,
+		request:    config.GetProviders().For(providerName).Record(),
+		decoding:   config.GetProviders().For(providerName).Decoding,
+		verbatim:   config.Get().Verbatim.Default,
+		vocabulary: vocab.Installed(),
+	}
+}
+
+// New creates the transcriber of the openai_compatible service in providers.yaml. Its key is the
+// api_key there, the one resolved by secrets.Key otherwise, and services without a key are sent none.
+func New() (*CompatibleTranscriber, error) {
+	cfg := config.GetProviders().For(providerName).Endpoint
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("no service for %s, set endpoint.base_url in providers.yaml", providerName)
+	}
+	switch cfg.ResponseFormat {
+	case "", "verbose_json", "json", "text":
+	default:
+		return nil, fmt.Errorf("unknown response_format %q of %s, use verbose_json, json or text", cfg.ResponseFormat, providerName)
+	}
+	key := cfg.APIKey
+	if key == "" {
+		key, _ = secrets.Key(providerName)
+	}
+	return NewCompatibleTranscriber(cfg, key, requestmeta.NewClient(providerName)), nil
+}
+
+// SetLanguage sends language with the requests, the service detects the language when it is empty.
+func (ct *CompatibleTranscriber) SetLanguage(language string) {
+	ct.language = language
+}
+
+// SupportedFormats are the file types the OpenAI API accepts, other audio is converted to mp3.
+func (ct *CompatibleTranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"mp3", "m4a", "mp4", "mpeg", "mpga", "wav", "webm", "ogg", "flac"}}
+}
+
+func (ct *CompatibleTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := ct.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and also reports the service, model, language and
+// the segments when the response format has them.
+func (ct *CompatibleTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return ct.TranscriptWithPrompt(inputFilePath, "")
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata and sends prompt as the initial prompt.
+func (ct *CompatibleTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return ct.transcribe(context.Background(), inputFilePath, api.Options{Prompt: prompt})
+}
+
+// TranscriptWithOptions works like TranscriptWithPrompt with opts.Prompt, which NoContext drops, and
+// sends the temperature. Translate sends the audio to the translations endpoint. The API has no
+// no-speech threshold, VAD, word threshold nor segment length, those are ignored.
+func (ct *CompatibleTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return ct.transcribe(context.Background(), inputFilePath, opts)
+}
+
+// TranscriptContext works like TranscriptWithOptions with the options ctx carries and sends the request with ctx.
+func (ct *CompatibleTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return ct.transcribe(ctx, inputFilePath, api.OptionsFrom(ctx))
+}
+
+// word is a word of the verbose_json response.
+type word struct {
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Word        string  `json:"word"`
+	Probability float64 `json:"probability"`
+}
+
+// response is the json or verbose_json response, only verbose_json has more than the text. Some
+// services report the words of each segment, the OpenAI API reports them for the whole audio.
+type response struct {
+	Language string  `json:"language"`
+	Duration float64 `json:"duration"`
+	Text     string  `json:"text"`
+	Segments []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+		Words []word  `json:"words"`
+	} `json:"segments"`
+	Words []word `json:"words"`
+}
+
+func (ct *CompatibleTranscriber) transcribe(ctx context.Context, inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	prompt := opts.Prompt
+	if opts.NoContext || ct.decoding.NoContext() {
+		prompt = ""
+	}
+	if ct.verbatim {
+		prompt = api.VerbatimPrompt(ct.language) + prompt
+	}
+	prompt = ct.vocabulary.Prompt(ct.language) + prompt
+	metadata := model.ProviderMetadata{
+		Provider: providerName,
+		Model:    ct.cfg.Model,
+		Language: ct.language,
+		Request:  ct.request,
+		Verbatim: ct.verbatim,
+		Server:   &model.ServerInfo{Host: host(ct.baseURL)},
+	}
+
+	fields := url.Values{"model": {ct.cfg.Model}, "response_format": {ct.cfg.ResponseFormat}}
+	if ct.cfg.ResponseFormat == "verbose_json" {
+		fields.Add("timestamp_granularities[]", "segment")
+		if ct.cfg.WordTimestamps {
+			fields.Add("timestamp_granularities[]", "word")
+		}
+	}
+	if prompt != "" {
+		fields.Set("prompt", prompt)
+	}
+	if opts.Temperature > 0 {
+		fields.Set("temperature", strconv.FormatFloat(float64(opts.Temperature), 'f', 2, 32))
+	}
+	endpoint := "/audio/transcriptions"
+	if opts.Translate {
+		endpoint = "/audio/translations"
+		metadata.Language = "en"
+	} else if ct.language != "" {
+		fields.Set("language", ct.language)
+	}
+
+	body, contentType, err := multipartBody(inputFilePath, fields)
+	if err != nil {
+		return "", metadata, fmt.Errorf("read audio failed: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ct.baseURL+endpoint, body)
+	if err != nil {
+		return "", metadata, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	ct.authorize(req)
+
+	logging.L().Info("Starting transcription", "provider", providerName, "server", ct.baseURL, "model", ct.cfg.Model, "file", inputFilePath)
+	resp, err := ct.client.Do(req)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, ctx.Err() == nil, fmt.Errorf("transcription request to %s failed: %w", ct.baseURL, err))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("read response of %s failed: %w", ct.baseURL, err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", metadata, provider.NewTranscriptionError(providerName, retryable(resp.StatusCode),
+			fmt.Errorf("%s answered %s: %s", ct.baseURL, resp.Status, errorMessage(data)))
+	}
+
+	if ct.cfg.ResponseFormat == "text" {
+		return strings.TrimSpace(string(data)), metadata, nil
+	}
+	var r response
+	if err = json.Unmarshal(data, &r); err != nil {
+		// a service that answers garbage is broken, not the audio
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("parse response of %s failed: %v", ct.baseURL, err))
+	}
+	if r.Language != "" && !opts.Translate {
+		metadata.Language = r.Language
+	}
+	metadata.Segments = segments(r)
+	metadata.SegmentCount = len(metadata.Segments)
+	metadata.DurationSeconds = r.Duration
+	if metadata.DurationSeconds == 0 && len(metadata.Segments) > 0 {
+		metadata.DurationSeconds = metadata.Segments[len(metadata.Segments)-1].End
+	}
+	return strings.TrimSpace(r.Text), metadata, nil
+}
+
+// authorize sends the key as the bearer token, services without a key are sent none.
+func (ct *CompatibleTranscriber) authorize(req *http.Request) {
+	if ct.key != "" {
+		req.Header.Set("Authorization", "Bearer "+ct.key)
+	}
+}
+
+// segments returns the segments of r with their words, the words reported for the whole audio are
+// given to the segment they start in.
+func segments(r response) []model.Segment {
+	if len(r.Segments) == 0 {
+		return nil
+	}
+	segments := make([]model.Segment, 0, len(r.Segments))
+	for _, s := range r.Segments {
+		segment := model.Segment{Start: s.Start, End: s.End, Text: strings.TrimSpace(s.Text)}
+		for _, w := range s.Words {
+			segment.Words = append(segment.Words, toWord(w))
+		}
+		segments = append(segments, segment)
+	}
+
+	for _, w := range r.Words {
+		i := sort.Search(len(segments), func(i int) bool { return segments[i].End > w.Start })
+		if i == len(segments) {
+			i--
+		}
+		if len(r.Segments[i].Words) > 0 {
+			continue
+		}
+		segments[i].Words = append(segments[i].Words, toWord(w))
+	}
+	return segments
+}
+
+func toWord(w word) model.Word {
+	return model.Word{Start: w.Start, End: w.End, Text: strings.TrimSpace(w.Word), Probability: w.Probability}
+}
+
+// HealthCheck lists the models of the service, which needs it up and the key valid.
+func (ct *CompatibleTranscriber) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ct.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	ct.authorize(req)
+	resp, err := ct.client.Do(req)
+	if err != nil {
+		return provider.NewTranscriptionError(providerName, true, fmt.Errorf("health check of %s failed: %w", ct.baseURL, err))
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return provider.NewTranscriptionError(providerName, retryable(resp.StatusCode), fmt.Errorf("%s is unhealthy: %s %s", ct.baseURL, resp.Status, errorMessage(data)))
+	}
+	return nil
+}
+
+// errorMessage returns the message of an OpenAI error response, the body when it isn't one.
+func errorMessage(body []byte) string {
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &e); err == nil && e.Error.Message != "" {
+		return e.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// retryable reports whether another provider may succeed: rate limits, rejected keys and server
+// errors, e.g. a service still loading its model, are, requests rejected for their audio aren't.
+func retryable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests ||
+		status == http.StatusRequestTimeout || status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+// multipartBody returns the form sending the file at path with fields.
+func multipartBody(path string, fields url.Values) (io.Reader, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = io.Copy(part, f); err != nil {
+		return nil, "", err
+	}
+	for name, values := range fields {
+		for _, value := range values {
+			if err = w.WriteField(name, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
+
+// host returns the host of baseURL, baseURL itself when it doesn't parse.
+func host(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
@@ -0,0 +1,119 @@
+package openai_compatible
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestCompatibleTranscriber_TranscriptWithOptions(t *testing.T) {
+	audio := filepath.Join(t.TempDir(), "a.mp3")
+	if err := os.WriteFile(audio, []byte("ID3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		cfg           config.EndpointConfig
+		key           string
+		status        int
+		body          string
+		opts          api.Options
+		wantPath      string
+		wantAuth      string
+		wantFields    url.Values
+		wantText      string
+		wantLanguage  string
+		wantSegments  []model.Segment
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{
+			name:     "verbose json with words",
+			cfg:      config.EndpointConfig{Model: "whisper-large-v3", WordTimestamps: true},
+			key:      "gsk_secret",
+			status:   http.StatusOK,
+			body:     `{"language":"english","duration":3,"text":" hi there","segments":[{"start":0,"end":1,"text":" hi"},{"start":1,"end":3,"text":" there"}],"words":[{"start":0.1,"end":0.5,"word":"hi"},{"start":1.2,"end":2.9,"word":"there"}]}`,
+			opts:     api.Options{Prompt: "before", Temperature: 0.2},
+			wantPath: "/openai/v1/audio/transcriptions",
+			wantAuth: "Bearer gsk_secret",
+			wantFields: url.Values{
+				"model": {"whisper-large-v3"}, "response_format": {"verbose_json"}, "timestamp_granularities[]": {"segment", "word"},
+				"language": {"en"}, "prompt": {"before"}, "temperature": {"0.20"},
+			},
+			wantText:     "hi there",
+			wantLanguage: "english",
+			wantSegments: []model.Segment{
+				{Start: 0, End: 1, Text: "hi", Words: []model.Word{{Start: 0.1, End: 0.5, Text: "hi"}}},
+				{Start: 1, End: 3, Text: "there", Words: []model.Word{{Start: 1.2, End: 2.9, Text: "there"}}},
+			},
+		},
+		{
+			name:         "json without a key",
+			cfg:          config.EndpointConfig{ResponseFormat: "json"},
+			status:       http.StatusOK,
+			body:         `{"text":"hello"}`,
+			wantPath:     "/openai/v1/audio/transcriptions",
+			wantFields:   url.Values{"model": {"whisper-1"}, "response_format": {"json"}, "language": {"en"}},
+			wantText:     "hello",
+			wantLanguage: "en",
+		},
+		{
+			name:         "text translated",
+			cfg:          config.EndpointConfig{ResponseFormat: "text"},
+			status:       http.StatusOK,
+			body:         "hello\n",
+			opts:         api.Options{Prompt: "dropped", NoContext: true, Translate: true},
+			wantPath:     "/openai/v1/audio/translations",
+			wantFields:   url.Values{"model": {"whisper-1"}, "response_format": {"text"}},
+			wantText:     "hello",
+			wantLanguage: "en",
+		},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{"error":{"message":"Rate limit reached"}}`, wantErr: true, wantRetryable: true},
+		{name: "audio rejected", status: http.StatusBadRequest, body: `{"error":{"message":"could not process file"}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path, auth string
+			var fields url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, auth = r.URL.Path, r.Header.Get("Authorization")
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("ParseMultipartForm() error = %v", err)
+				}
+				fields = url.Values(r.MultipartForm.Value)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			tt.cfg.BaseURL = srv.URL + "/openai/v1/"
+			ct := NewCompatibleTranscriber(tt.cfg, tt.key, srv.Client())
+			ct.SetLanguage("en")
+			text, metadata, err := ct.TranscriptWithOptions(audio, tt.opts)
+			if tt.wantErr {
+				if err == nil || provider.IsRetryable(err) != tt.wantRetryable {
+					t.Errorf("TranscriptWithOptions() error = %v, want retryable %v", err, tt.wantRetryable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TranscriptWithOptions() error = %v", err)
+			}
+			if text != tt.wantText || metadata.Language != tt.wantLanguage || !reflect.DeepEqual(metadata.Segments, tt.wantSegments) {
+				t.Errorf("TranscriptWithOptions() = %q in %q, %+v", text, metadata.Language, metadata.Segments)
+			}
+			if path != tt.wantPath || auth != tt.wantAuth || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("sent to %s with %q: %v, want %s with %q: %v", path, auth, fields, tt.wantPath, tt.wantAuth, tt.wantFields)
+			}
+		})
+	}
+}
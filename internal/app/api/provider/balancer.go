@@ -0,0 +1,232 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+)
+
+// Strategies of a Balancer.
+const (
+	// LeastBusy sends a file to the instance with the fewest files in flight, the first one on a tie.
+	LeastBusy = "least_busy"
+	// RoundRobin sends the files to the instances in turn.
+	RoundRobin = "round_robin"
+)
+
+// Balancer spreads the files of one provider over several instances of it, e.g. a whisper.cpp
+// server per GPU. Every instance has a circuit of its own: an instance failing with a retryable
+// TranscriptionError is left for the next one, and it is skipped while its circuit is open. The
+// probe of a half-open circuit checks the health of the instance first when it can tell.
+type Balancer struct {
+	name     string
+	strategy string
+	breaker  config.BreakerConfig
+
+	mu        sync.Mutex
+	instances []*instance
+	// next is the instance RoundRobin starts from.
+	next int
+}
+
+// instance is a transcriber of a Balancer.
+type instance struct {
+	t       api.OptionsTranscriber
+	breaker *Breaker
+	// busy is the number of files in flight.
+	busy int
+}
+
+// NewBalancer creates the Balancer of provider name, its instances get circuits with cfg. strategy
+// is LeastBusy or RoundRobin, LeastBusy when empty.
+func NewBalancer(name string, strategy string, cfg config.BreakerConfig) (*Balancer, error) {
+	switch strategy {
+	case "":
+		strategy = LeastBusy
+	case LeastBusy, RoundRobin:
+	default:
+		return nil, fmt.Errorf("unknown balance %q, available: %s, %s", strategy, LeastBusy, RoundRobin)
+	}
+	return &Balancer{name: name, strategy: strategy, breaker: cfg}, nil
+}
+
+// Add adds the instance t, name identifies it in the logs and the circuit statuses, e.g. its URL.
+func (b *Balancer) Add(name string, t api.OptionsTranscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.instances = append(b.instances, &instance{t: t, breaker: NewBreaker(name, b.breaker)})
+}
+
+// Statuses returns the circuits of the instances in the order they were added.
+func (b *Balancer) Statuses() []BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	statuses := make([]BreakerStatus, len(b.instances))
+	for i, inst := range b.instances {
+		statuses[i] = inst.breaker.Status()
+	}
+	return statuses
+}
+
+func (b *Balancer) Transcript(inputFilePath string) (string, error) {
+	text, _, err := b.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (b *Balancer) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return b.TranscriptContext(context.Background(), inputFilePath)
+}
+
+// TranscriptContext transcribes with the instance the strategy picks and passes ctx on to it.
+func (b *Balancer) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return b.balance(inputFilePath, func(t api.OptionsTranscriber) (string, model.ProviderMetadata, error) {
+		return Transcribe(ctx, t, inputFilePath)
+	})
+}
+
+func (b *Balancer) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return b.balance(inputFilePath, func(t api.OptionsTranscriber) (string, model.ProviderMetadata, error) {
+		return t.TranscriptWithPrompt(inputFilePath, prompt)
+	})
+}
+
+func (b *Balancer) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return b.balance(inputFilePath, func(t api.OptionsTranscriber) (string, model.ProviderMetadata, error) {
+		return t.TranscriptWithOptions(inputFilePath, opts)
+	})
+}
+
+// SupportedFormats are the formats of the first instance, the instances of a provider accept the same audio.
+func (b *Balancer) SupportedFormats() Formats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.instances) > 0 {
+		if ft, ok := b.instances[0].t.(FormatTranscriber); ok {
+			return ft.SupportedFormats()
+		}
+	}
+	return Formats{}
+}
+
+// HealthCheck succeeds when one instance is healthy, it returns the errors of all of them otherwise.
+// Instances that can't check their health count as healthy.
+func (b *Balancer) HealthCheck() error {
+	b.mu.Lock()
+	instances := append([]*instance(nil), b.instances...)
+	b.mu.Unlock()
+	if len(instances) == 0 {
+		return fmt.Errorf("%s has no instances", b.name)
+	}
+
+	var errs []error
+	for _, inst := range instances {
+		err := healthCheck(inst.t)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", inst.breaker.Status().Provider, err))
+	}
+	return errors.Join(errs...)
+}
+
+func healthCheck(t api.Transcriber) error {
+	if hc, ok := t.(HealthChecker); ok {
+		return hc.HealthCheck()
+	}
+	return nil
+}
+
+func (b *Balancer) balance(inputFilePath string, run func(t api.OptionsTranscriber) (string, model.ProviderMetadata, error)) (string, model.ProviderMetadata, error) {
+	b.mu.Lock()
+	empty := len(b.instances) == 0
+	b.mu.Unlock()
+	if empty {
+		return "", model.ProviderMetadata{}, fmt.Errorf("%s has no instances", b.name)
+	}
+
+	var text string
+	var metadata model.ProviderMetadata
+	var err error
+	tried := false
+	seen := make(map[*instance]bool)
+	for inst := b.pick(seen); inst != nil; inst = b.pick(seen) {
+		name := inst.breaker.Status().Provider
+		// the probe of a half-open circuit is cheaper as a health check than as a failed file
+		if inst.breaker.Status().State == BreakerHalfOpen {
+			if hcErr := healthCheck(inst.t); hcErr != nil {
+				inst.breaker.Record(hcErr)
+				b.release(inst)
+				logging.L().Warn("Instance still unhealthy, skipping it", "provider", b.name, "instance", name, "error", hcErr)
+				continue
+			}
+		}
+
+		tried = true
+		text, metadata, err = run(inst.t)
+		b.release(inst)
+		retryable := IsRetryable(err)
+		// audio the instance rejected doesn't tell anything about the instance
+		if retryable {
+			inst.breaker.Record(err)
+		} else {
+			inst.breaker.Record(nil)
+		}
+		if !retryable {
+			return text, metadata, err
+		}
+		logging.L().Warn("Instance failed, failing over to the next one", "provider", b.name, "instance", name, "file", inputFilePath, "error", err)
+	}
+	if !tried {
+		return "", model.ProviderMetadata{}, NewTranscriptionError(b.name, true, errors.New("every instance is unavailable"))
+	}
+	return text, metadata, err
+}
+
+// pick returns the next instance of a file that seen doesn't have yet and whose circuit allows a
+// call, nil when there is none. It adds the instances it considered to seen and counts the picked
+// one as busy, under the same lock, so parallel files don't all pick the same idle instance.
+func (b *Balancer) pick(seen map[*instance]bool) *instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.instances)
+	candidates := make([]*instance, 0, n)
+	if b.strategy == RoundRobin {
+		for i := 0; i < n; i++ {
+			candidates = append(candidates, b.instances[(b.next+i)%n])
+		}
+		// the next file starts from the next instance, failovers of this one don't move it
+		if len(seen) == 0 {
+			b.next = (b.next + 1) % n
+		}
+	} else {
+		candidates = append(candidates, b.instances...)
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].busy < candidates[j].busy })
+	}
+
+	for _, inst := range candidates {
+		if seen[inst] {
+			continue
+		}
+		seen[inst] = true
+		if !inst.breaker.Allow() {
+			logging.L().Debug("Instance circuit open, skipping it", "provider", b.name, "instance", inst.breaker.Status().Provider)
+			continue
+		}
+		inst.busy++
+		return inst
+	}
+	return nil
+}
+
+func (b *Balancer) release(inst *instance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	inst.busy--
+}
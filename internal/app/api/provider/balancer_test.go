@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"time"
+)
+
+// instanceTranscriber is a flakyTranscriber with a health check failing with checkErr.
+type instanceTranscriber struct {
+	flakyTranscriber
+	checkErr error
+	checks   int
+}
+
+func (i *instanceTranscriber) HealthCheck() error {
+	i.checks++
+	return i.checkErr
+}
+
+func newTestBalancer(t *testing.T, strategy string, instances ...*instanceTranscriber) *Balancer {
+	b, err := NewBalancer("whisper_server", strategy, config.BreakerConfig{Failures: 1, Cooldown: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, inst := range instances {
+		b.Add(string(rune('a'+i)), inst)
+	}
+	return b
+}
+
+func TestNewBalancer_UnknownStrategy(t *testing.T) {
+	if _, err := NewBalancer("whisper_server", "random", config.BreakerConfig{}); err == nil {
+		t.Error("NewBalancer() succeeded, want an error for an unknown strategy")
+	}
+}
+
+func TestBalancer_Spread(t *testing.T) {
+	tests := []struct {
+		strategy  string
+		wantCalls []int
+	}{
+		{strategy: RoundRobin, wantCalls: []int{2, 2, 2}},
+		// nothing is in flight in between, the first instance is the least busy every time
+		{strategy: LeastBusy, wantCalls: []int{6, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			instances := []*instanceTranscriber{{}, {}, {}}
+			b := newTestBalancer(t, tt.strategy, instances...)
+			for i := 0; i < 6; i++ {
+				if _, err := b.Transcript("a.wav"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, inst := range instances {
+				if inst.calls != tt.wantCalls[i] {
+					t.Errorf("instance %d called %d times, want %d", i, inst.calls, tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBalancer_LeastBusy(t *testing.T) {
+	b := newTestBalancer(t, LeastBusy, &instanceTranscriber{}, &instanceTranscriber{})
+	first := b.pick(map[*instance]bool{})
+	second := b.pick(map[*instance]bool{})
+	if first == second {
+		t.Fatal("pick() returned the busy instance while another one is idle")
+	}
+	b.release(first)
+	if got := b.pick(map[*instance]bool{}); got != first {
+		t.Error("pick() didn't return the instance that became idle")
+	}
+}
+
+func TestBalancer_Failover(t *testing.T) {
+	outage := NewTranscriptionError("whisper_server", true, errors.New("503 service unavailable"))
+	badAudio := NewTranscriptionError("whisper_server", false, errors.New("400 invalid file"))
+
+	tests := []struct {
+		name      string
+		errs      [][]error
+		wantErr   error
+		wantCalls []int
+	}{
+		{name: "fails over on retryable error", errs: [][]error{{outage}, nil}, wantCalls: []int{1, 1}},
+		{name: "stops on permanent error", errs: [][]error{{badAudio}, nil}, wantErr: badAudio, wantCalls: []int{1, 0}},
+		{name: "all fail", errs: [][]error{{outage}, {outage}}, wantErr: outage, wantCalls: []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var instances []*instanceTranscriber
+			for _, errs := range tt.errs {
+				instances = append(instances, &instanceTranscriber{flakyTranscriber: flakyTranscriber{errs: errs}})
+			}
+			b := newTestBalancer(t, LeastBusy, instances...)
+
+			_, _, err := b.TranscriptWithMetadata("a.wav")
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("TranscriptWithMetadata() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("TranscriptWithMetadata() error = %v, want %v", err, tt.wantErr)
+			}
+			for i, inst := range instances {
+				if inst.calls != tt.wantCalls[i] {
+					t.Errorf("instance %d called %d times, want %d", i, inst.calls, tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBalancer_Circuits(t *testing.T) {
+	outage := NewTranscriptionError("whisper_server", true, errors.New("connection refused"))
+	down := &instanceTranscriber{flakyTranscriber: flakyTranscriber{errs: []error{outage}}, checkErr: errors.New("connection refused")}
+	up := &instanceTranscriber{}
+	b := newTestBalancer(t, RoundRobin, down, up)
+
+	// the failure opens the circuit of the first instance, the next files skip it
+	for i := 0; i < 3; i++ {
+		if _, err := b.Transcript("a.wav"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if down.calls != 1 || up.calls != 3 {
+		t.Fatalf("calls = %d, %d, want 1, 3", down.calls, up.calls)
+	}
+	if got := b.Statuses()[0].State; got != BreakerOpen {
+		t.Fatalf("state = %s, want %s", got, BreakerOpen)
+	}
+
+	// once the cooldown passed, the probe checks the health before sending a file
+	b.instances[0].breaker.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	for i := 0; i < 2; i++ {
+		if _, err := b.Transcript("a.wav"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if down.checks != 1 || down.calls != 1 {
+		t.Errorf("checks, calls = %d, %d, want the probe to stop at the health check", down.checks, down.calls)
+	}
+
+	// with every circuit open nothing is tried and the failure is retryable
+	up.errs = []error{outage, outage, outage, outage, outage}
+	up.calls = 0
+	b.Transcript("a.wav")
+	if _, err := b.Transcript("a.wav"); !IsRetryable(err) || !strings.Contains(err.Error(), "every instance is unavailable") {
+		t.Errorf("Transcript() error = %v, want every instance unavailable", err)
+	}
+}
+
+func TestBalancer_HealthCheck(t *testing.T) {
+	failing := errors.New("connection refused")
+	b := newTestBalancer(t, LeastBusy, &instanceTranscriber{checkErr: failing}, &instanceTranscriber{})
+	if err := b.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck() = %v, want nil with a healthy instance", err)
+	}
+
+	b = newTestBalancer(t, LeastBusy, &instanceTranscriber{checkErr: failing}, &instanceTranscriber{checkErr: failing})
+	err := b.HealthCheck()
+	if err == nil || !strings.Contains(err.Error(), "a: connection refused") || !strings.Contains(err.Error(), "b: connection refused") {
+		t.Errorf("HealthCheck() = %v, want the errors of both instances", err)
+	}
+}
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"time"
+)
+
+// States of a Breaker.
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed = "closed"
+	// BreakerOpen skips the provider until the cooldown passed.
+	BreakerOpen = "open"
+	// BreakerHalfOpen lets a single probe through, its outcome closes or opens the circuit again.
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerStatus is the state of the circuit of a provider, as shown by v2t providers status.
+type BreakerStatus struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	// Failures is the number of consecutive failures.
+	Failures int `json:"failures"`
+	// OpenedAt is when the circuit opened last, zero while it is closed.
+	OpenedAt  time.Time `json:"opened_at,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Breaker is the circuit breaker of a provider: it opens after consecutive failures, so the
+// provider is taken out of the rotation instead of every file waiting for it to fail, and lets
+// a single probe through once the cooldown passed.
+type Breaker struct {
+	provider string
+	cfg      config.BreakerConfig
+	now      func() time.Time
+
+	mu      sync.Mutex
+	status  BreakerStatus
+	probing bool
+}
+
+// NewBreaker creates the closed circuit of provider.
+func NewBreaker(provider string, cfg config.BreakerConfig) *Breaker {
+	if cfg.Failures < 1 {
+		cfg.Failures = config.DefaultBreaker.Failures
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = config.DefaultBreaker.Cooldown
+	}
+	return &Breaker{
+		provider: provider,
+		cfg:      cfg,
+		now:      time.Now,
+		status:   BreakerStatus{Provider: provider, State: BreakerClosed},
+	}
+}
+
+// Allow reports whether the provider may be called. An open circuit whose cooldown passed turns
+// half-open and allows the call as its probe, the calls after it are skipped until it is recorded.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.status.State {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if b.now().Before(b.status.OpenedAt.Add(b.cfg.Cooldown)) {
+			return false
+		}
+		b.status.State = BreakerHalfOpen
+		b.probing = true
+		return true
+	default:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+}
+
+// Record records the outcome of a call Allow let through, err is nil when the provider worked.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil {
+		b.status = BreakerStatus{Provider: b.provider, State: BreakerClosed}
+		return
+	}
+
+	b.status.Failures++
+	b.status.LastError = err.Error()
+	if b.status.State == BreakerHalfOpen || b.status.Failures >= b.cfg.Failures {
+		b.status.State = BreakerOpen
+		b.status.OpenedAt = b.now()
+	}
+}
+
+// Status returns the state of the circuit.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+// restore sets the state of the circuit saved by an earlier process. A probe that was running
+// when it was saved is let through again.
+func (b *Breaker) restore(s BreakerStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s.Provider = b.provider
+	switch s.State {
+	case BreakerOpen, BreakerHalfOpen:
+	default:
+		s.State = BreakerClosed
+	}
+	b.status = s
+}
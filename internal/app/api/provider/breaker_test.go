@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBreaker("openai", config.BreakerConfig{Failures: 2, Cooldown: time.Minute})
+	b.now = func() time.Time { return now }
+	outage := errors.New("503 service unavailable")
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		outcome   error
+		wantAllow bool
+		wantState string
+	}{
+		{name: "first failure stays closed", outcome: outage, wantAllow: true, wantState: BreakerClosed},
+		{name: "second failure opens", outcome: outage, wantAllow: true, wantState: BreakerOpen},
+		{name: "skipped during cooldown", advance: 30 * time.Second, wantAllow: false, wantState: BreakerOpen},
+		{name: "failed probe opens again", advance: 31 * time.Second, outcome: outage, wantAllow: true, wantState: BreakerOpen},
+		{name: "probe after the next cooldown closes", advance: time.Minute, wantAllow: true, wantState: BreakerClosed},
+	}
+	for _, s := range steps {
+		now = now.Add(s.advance)
+		allowed := b.Allow()
+		if allowed != s.wantAllow {
+			t.Fatalf("%s: Allow() = %v, want %v", s.name, allowed, s.wantAllow)
+		}
+		if allowed {
+			b.Record(s.outcome)
+		}
+		if got := b.Status(); got.State != s.wantState {
+			t.Fatalf("%s: state = %s, want %s (%+v)", s.name, got.State, s.wantState, got)
+		}
+	}
+	if got := b.Status(); got.Failures != 0 || got.LastError != "" || !got.OpenedAt.IsZero() {
+		t.Errorf("closed circuit = %+v, want it reset", got)
+	}
+}
+
+func TestBreaker_HalfOpenSingleProbe(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBreaker("openai", config.BreakerConfig{Failures: 1, Cooldown: time.Minute})
+	b.now = func() time.Time { return now }
+	b.Allow()
+	b.Record(errors.New("timeout"))
+
+	now = now.Add(2 * time.Minute)
+	if !b.Allow() {
+		t.Fatal("Allow() after the cooldown = false, want the probe")
+	}
+	if b.Status().State != BreakerHalfOpen {
+		t.Errorf("state = %s, want %s", b.Status().State, BreakerHalfOpen)
+	}
+	if b.Allow() {
+		t.Error("Allow() while the probe runs = true, want false")
+	}
+}
@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TranscriptionError is returned by providers to tell whether a failure is theirs, so another
+// provider may succeed, or comes from the audio itself and would fail everywhere.
+type TranscriptionError struct {
+	Provider string
+	// Retryable is true for failures of the provider such as network errors, rate limits or server errors.
+	Retryable bool
+	Err       error
+}
+
+// NewTranscriptionError wraps err as a failure of provider.
+func NewTranscriptionError(provider string, retryable bool, err error) *TranscriptionError {
+	return &TranscriptionError{Provider: provider, Retryable: retryable, Err: err}
+}
+
+func (e *TranscriptionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+}
+
+func (e *TranscriptionError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err wraps a retryable TranscriptionError. Errors of transcribers
+// that don't classify their failures are not retryable.
+func IsRetryable(err error) bool {
+	var te *TranscriptionError
+	return errors.As(err, &te) && te.Retryable
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+)
+
+// FallbackTranscriber tries its providers in order and moves on to the next one when a provider
+// fails with a retryable TranscriptionError. Other errors are returned right away, the audio
+// would fail with every provider. The FallbackTranscriber of a Registry skips the providers whose
+// circuit is open.
+type FallbackTranscriber struct {
+	providers []api.Transcriber
+	// breakers are the circuits of the providers, nil when they have none.
+	breakers []*Breaker
+	// onRecord is called after an outcome was recorded in a circuit, to keep it.
+	onRecord func()
+}
+
+// NewFallbackTranscriber creates a FallbackTranscriber, the first provider is the preferred one.
+func NewFallbackTranscriber(providers ...api.Transcriber) *FallbackTranscriber {
+	return &FallbackTranscriber{providers: providers}
+}
+
+// Transcript transcribes with the first provider that succeeds.
+func (f *FallbackTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := f.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and reports the metadata of the provider that
+// produced the text, with the providers that failed before it in FailedOver.
+func (f *FallbackTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return f.TranscriptContext(context.Background(), inputFilePath)
+}
+
+// TranscriptContext works like TranscriptWithMetadata and passes ctx on to the providers.
+func (f *FallbackTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return f.fallback(inputFilePath, func(t api.Transcriber) (string, model.ProviderMetadata, error) {
+		return Transcribe(ctx, t, inputFilePath)
+	})
+}
+
+// TranscriptStream works like TranscriptWithMetadata and forwards the segments of every provider
+// it tries, a provider failing midway may have sent some before the next one starts over.
+func (f *FallbackTranscriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	return f.fallback(inputFilePath, func(t api.Transcriber) (string, model.ProviderMetadata, error) {
+		return Stream(t, inputFilePath, func(s model.Segment) { partials <- s })
+	})
+}
+
+func (f *FallbackTranscriber) fallback(inputFilePath string, run func(t api.Transcriber) (string, model.ProviderMetadata, error)) (string, model.ProviderMetadata, error) {
+	if len(f.providers) == 0 {
+		return "", model.ProviderMetadata{}, errors.New("no transcription provider configured")
+	}
+
+	var failedOver []string
+	var text string
+	var metadata model.ProviderMetadata
+	var err error
+	tried := false
+	for i, t := range f.providers {
+		b := f.breaker(i)
+		if b != nil && !b.Allow() {
+			logging.L().Info("Provider circuit open, skipping it", "provider", b.Status().Provider, "file", inputFilePath)
+			continue
+		}
+
+		tried = true
+		text, metadata, err = run(t)
+		var te *TranscriptionError
+		retryable := errors.As(err, &te) && te.Retryable
+		if b != nil {
+			// audio the provider rejected doesn't tell anything about the provider
+			if retryable {
+				b.Record(err)
+			} else {
+				b.Record(nil)
+			}
+			if f.onRecord != nil {
+				f.onRecord()
+			}
+		}
+		if err == nil {
+			metadata.FailedOver = failedOver
+			return text, metadata, nil
+		}
+		if !retryable {
+			return text, metadata, err
+		}
+
+		failedOver = append(failedOver, te.Provider)
+		if i < len(f.providers)-1 {
+			logging.L().Warn("Provider failed, falling back to the next provider", "provider", te.Provider, "file", inputFilePath, "error", err)
+		}
+	}
+	if !tried {
+		return "", model.ProviderMetadata{}, ErrNoProviderAvailable
+	}
+	return text, metadata, err
+}
+
+// breaker returns the circuit of the provider at i, nil when it has none.
+func (f *FallbackTranscriber) breaker(i int) *Breaker {
+	if i < len(f.breakers) {
+		return f.breakers[i]
+	}
+	return nil
+}
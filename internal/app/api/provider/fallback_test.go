@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/testutil"
+)
+
+// namedTranscriber fails with err when it is set.
+type namedTranscriber struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (n *namedTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := n.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (n *namedTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	n.calls++
+	if n.err != nil {
+		return "", model.ProviderMetadata{}, n.err
+	}
+	return "text by " + n.name, model.ProviderMetadata{Provider: n.name}, nil
+}
+
+func TestFallbackTranscriber(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	outage := NewTranscriptionError("remote", true, errors.New("503 service unavailable"))
+	badAudio := NewTranscriptionError("remote", false, errors.New("400 invalid file format"))
+
+	tests := []struct {
+		name           string
+		errs           []error
+		wantText       string
+		wantProvider   string
+		wantFailedOver []string
+		wantErr        error
+		wantCalls      []int
+	}{
+		{name: "first succeeds", errs: []error{nil, nil}, wantText: "text by p0", wantProvider: "p0", wantCalls: []int{1, 0}},
+		{name: "falls back on retryable error", errs: []error{outage, nil}, wantText: "text by p1", wantProvider: "p1", wantFailedOver: []string{"remote"}, wantCalls: []int{1, 1}},
+		{name: "stops on permanent error", errs: []error{badAudio, nil}, wantErr: badAudio, wantCalls: []int{1, 0}},
+		{name: "stops on unclassified error", errs: []error{errors.New("boom"), nil}, wantCalls: []int{1, 0}},
+		{name: "all fail", errs: []error{outage, outage}, wantErr: outage, wantCalls: []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var providers []*namedTranscriber
+			f := NewFallbackTranscriber()
+			for i, err := range tt.errs {
+				p := &namedTranscriber{name: fmt.Sprintf("p%d", i), err: err}
+				providers = append(providers, p)
+				f.providers = append(f.providers, p)
+			}
+
+			text, metadata, err := f.TranscriptWithMetadata("a.mp3")
+			if tt.wantText != "" && err != nil {
+				t.Fatalf("TranscriptWithMetadata() error = %v", err)
+			}
+			if tt.wantText == "" && err == nil {
+				t.Fatalf("TranscriptWithMetadata() succeeded, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("TranscriptWithMetadata() error = %v, want %v", err, tt.wantErr)
+			}
+			if text != tt.wantText || metadata.Provider != tt.wantProvider || !reflect.DeepEqual(metadata.FailedOver, tt.wantFailedOver) {
+				t.Errorf("TranscriptWithMetadata() = %q, %+v", text, metadata)
+			}
+			for i, p := range providers {
+				if p.calls != tt.wantCalls[i] {
+					t.Errorf("provider %d called %d times, want %d", i, p.calls, tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "retryable", err: NewTranscriptionError("openai", true, errors.New("timeout")), want: true},
+		{name: "wrapped", err: fmt.Errorf("transcribe chunk 1/2 failed: %w", NewTranscriptionError("openai", true, errors.New("timeout"))), want: true},
+		{name: "permanent", err: NewTranscriptionError("openai", false, errors.New("bad audio"))},
+		{name: "plain", err: errors.New("boom")},
+		{name: "nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
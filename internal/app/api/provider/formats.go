@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/audio/ffmpeg"
+
+	"github.com/samber/lo"
+)
+
+// Formats are the audio a provider accepts.
+type Formats struct {
+	// Containers are the accepted containers as ffprobe names them, which are also their file
+	// extensions, e.g. mp3 or wav. The first one is converted to.
+	Containers []string
+	// Codecs are the accepted audio codecs as ffprobe names them, e.g. pcm_s16le, any when empty. The
+	// first one is converted to.
+	Codecs []string
+	// SampleRate is the only accepted sample rate in Hz, any when zero.
+	SampleRate int
+}
+
+// FormatTranscriber is implemented by transcribers that only accept some audio formats.
+type FormatTranscriber interface {
+	api.Transcriber
+	SupportedFormats() Formats
+}
+
+// Accepts reports whether the file at path described by info can be sent as it is: both its
+// extension and its container must be accepted, providers like OpenAI check both.
+func (f Formats) Accepts(path string, info ffmpeg.Info) bool {
+	if !f.AcceptsExtension(path) || !lo.Some(f.Containers, info.Formats) {
+		return false
+	}
+	if len(f.Codecs) > 0 && !lo.Contains(f.Codecs, info.Codec) {
+		return false
+	}
+	return f.SampleRate == 0 || info.SampleRate == f.SampleRate
+}
+
+// AcceptsExtension reports whether the extension of path is one of the containers, all that is
+// known of a file that can't be probed.
+func (f Formats) AcceptsExtension(path string) bool {
+	return lo.Contains(f.Containers, strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")))
+}
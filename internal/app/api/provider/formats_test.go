@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"testing"
+	"tiktok-whisper/internal/app/audio/ffmpeg"
+)
+
+func TestFormats_Accepts(t *testing.T) {
+	openai := Formats{Containers: []string{"mp3", "m4a", "mp4", "wav", "webm"}}
+	whisperCpp := Formats{Containers: []string{"wav"}, Codecs: []string{"pcm_s16le"}, SampleRate: 16000}
+	m4a := ffmpeg.Info{Formats: []string{"mov", "mp4", "m4a", "3gp", "3g2", "mj2"}, Codec: "aac", SampleRate: 44100}
+	wav16k := ffmpeg.Info{Formats: []string{"wav"}, Codec: "pcm_s16le", SampleRate: 16000}
+	tests := []struct {
+		name    string
+		formats Formats
+		path    string
+		info    ffmpeg.Info
+		want    bool
+	}{
+		{name: "m4a by any of its container names", formats: openai, path: "talk.M4A", info: m4a, want: true},
+		{name: "extension not accepted", formats: openai, path: "talk.mkv", info: ffmpeg.Info{Formats: []string{"matroska", "webm"}}},
+		{name: "container not accepted", formats: openai, path: "talk.mp3", info: ffmpeg.Info{Formats: []string{"amr"}}},
+		{name: "16kHz wav", formats: whisperCpp, path: "talk.wav", info: wav16k, want: true},
+		{name: "wav of another sample rate", formats: whisperCpp, path: "talk.wav", info: ffmpeg.Info{Formats: []string{"wav"}, Codec: "pcm_s16le", SampleRate: 44100}},
+		{name: "wav of another codec", formats: whisperCpp, path: "talk.wav", info: ffmpeg.Info{Formats: []string{"wav"}, Codec: "pcm_f32le", SampleRate: 16000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.formats.Accepts(tt.path, tt.info); got != tt.want {
+				t.Errorf("Accepts(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
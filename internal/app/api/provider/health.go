@@ -0,0 +1,7 @@
+package provider
+
+// HealthChecker is implemented by transcribers that can check they are usable without transcribing,
+// e.g. that their binary and model exist or that the service accepts their credentials.
+type HealthChecker interface {
+	HealthCheck() error
+}
@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"context"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+)
+
+// LanguageDetector is implemented by providers that can tell the spoken language of audio
+// without transcribing all of it.
+type LanguageDetector interface {
+	DetectLanguage(inputFilePath string) (string, error)
+}
+
+// LanguageRouter detects the language of each file and transcribes it with the provider routed
+// to that language, so mixed-language batches get a model fit for each language. Languages
+// without a route, and files whose detection fails, go to the default provider.
+type LanguageRouter struct {
+	detector LanguageDetector
+	routes   map[string]api.Transcriber
+	fallback api.Transcriber
+}
+
+// NewLanguageRouter creates a LanguageRouter, routes map language codes such as zh or en to providers.
+func NewLanguageRouter(detector LanguageDetector, routes map[string]api.Transcriber, fallback api.Transcriber) *LanguageRouter {
+	return &LanguageRouter{detector: detector, routes: routes, fallback: fallback}
+}
+
+// Transcript transcribes with the provider of the detected language.
+func (r *LanguageRouter) Transcript(inputFilePath string) (string, error) {
+	text, _, err := r.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and reports the detected language in DetectedLanguage.
+func (r *LanguageRouter) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return r.TranscriptContext(context.Background(), inputFilePath)
+}
+
+// TranscriptContext works like TranscriptWithMetadata and passes ctx on to the routed provider.
+func (r *LanguageRouter) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	t, language := r.route(inputFilePath)
+	text, metadata, err := Transcribe(ctx, t, inputFilePath)
+	metadata.DetectedLanguage = language
+	return text, metadata, err
+}
+
+// TranscriptStream works like TranscriptWithMetadata and forwards the segments of the routed provider.
+func (r *LanguageRouter) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	t, language := r.route(inputFilePath)
+	text, metadata, err := Stream(t, inputFilePath, func(s model.Segment) { partials <- s })
+	metadata.DetectedLanguage = language
+	return text, metadata, err
+}
+
+// route returns the provider of the language detected in inputFilePath, and the language.
+func (r *LanguageRouter) route(inputFilePath string) (api.Transcriber, string) {
+	language, err := r.detector.DetectLanguage(inputFilePath)
+	if err != nil {
+		logging.L().Warn("Detecting the language failed, using the default provider", "file", inputFilePath, "error", err)
+		return r.fallback, ""
+	}
+
+	t, ok := r.routes[language]
+	if !ok {
+		logging.L().Info("Detected language has no route, using the default provider", "file", inputFilePath, "language", language)
+		return r.fallback, language
+	}
+	logging.L().Info("Detected language", "file", inputFilePath, "language", language)
+	return t, language
+}
+
+// HealthCheck runs the health checks of the default provider and the routed ones, it fails with
+// the first failing check.
+func (r *LanguageRouter) HealthCheck() error {
+	providers := []api.Transcriber{r.fallback}
+	for _, t := range r.routes {
+		providers = append(providers, t)
+	}
+	for _, t := range providers {
+		if hc, ok := t.(HealthChecker); ok {
+			if err := hc.HealthCheck(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+)
+
+type fakeDetector struct {
+	language string
+	err      error
+}
+
+func (d fakeDetector) DetectLanguage(inputFilePath string) (string, error) {
+	return d.language, d.err
+}
+
+func TestLanguageRouter(t *testing.T) {
+	tests := []struct {
+		name         string
+		detector     fakeDetector
+		wantProvider string
+		wantLanguage string
+	}{
+		{name: "routed", detector: fakeDetector{language: "en"}, wantProvider: "base.en", wantLanguage: "en"},
+		{name: "other route", detector: fakeDetector{language: "zh"}, wantProvider: "large-v3", wantLanguage: "zh"},
+		{name: "no route", detector: fakeDetector{language: "ja"}, wantProvider: "default", wantLanguage: "ja"},
+		{name: "detection fails", detector: fakeDetector{err: errors.New("no language")}, wantProvider: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewLanguageRouter(tt.detector, map[string]api.Transcriber{
+				"en": &namedTranscriber{name: "base.en"},
+				"zh": &namedTranscriber{name: "large-v3"},
+			}, &namedTranscriber{name: "default"})
+
+			text, metadata, err := r.TranscriptWithMetadata("a.mp3")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != "text by "+tt.wantProvider || metadata.Provider != tt.wantProvider || metadata.DetectedLanguage != tt.wantLanguage {
+				t.Errorf("TranscriptWithMetadata() = %q, %+v, want %s in %q", text, metadata, tt.wantProvider, tt.wantLanguage)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/util/files"
+)
+
+// BreakersFile is where the registry keeps the states of the circuits in the data directory.
+const BreakersFile = "provider_breakers.json"
+
+// ErrNoProviderAvailable is returned when the circuits of all providers are open.
+var ErrNoProviderAvailable = errors.New("no transcription provider available, the circuits of all providers are open")
+
+// Registry holds the named providers in their order of preference, each behind its own circuit
+// breaker. The states of the circuits are kept in a file, so a provider that failed in one run
+// stays out of the rotation of the next ones until its cooldown passed.
+type Registry struct {
+	path string
+
+	mu      sync.Mutex
+	names   []string
+	entries map[string]registryEntry
+	saved   map[string]BreakerStatus
+}
+
+type registryEntry struct {
+	transcriber api.Transcriber
+	breaker     *Breaker
+}
+
+// NewRegistry creates an empty Registry keeping the circuits at path, they aren't kept when it is empty.
+func NewRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path, entries: map[string]registryEntry{}, saved: map[string]BreakerStatus{}}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var statuses []BreakerStatus
+	if err = json.Unmarshal(data, &statuses); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %v", path, err)
+	}
+	for _, s := range statuses {
+		r.saved[s.Provider] = s
+	}
+	return r, nil
+}
+
+// Register adds t as the provider name, after the providers registered before it. Its circuit
+// starts in the state kept by earlier runs.
+func (r *Registry) Register(name string, t api.Transcriber, cfg config.BreakerConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := NewBreaker(name, cfg)
+	if s, ok := r.saved[name]; ok {
+		b.restore(s)
+	}
+	if _, ok := r.entries[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.entries[name] = registryEntry{transcriber: t, breaker: b}
+}
+
+// Names returns the registered providers in their order of preference.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.names...)
+}
+
+// Get returns the provider name, false when it isn't registered.
+func (r *Registry) Get(name string) (api.Transcriber, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[name]
+	return e.transcriber, ok
+}
+
+// Status returns the circuits of the providers in their order of preference.
+func (r *Registry) Status() []BreakerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(r.names))
+	for _, name := range r.names {
+		statuses = append(statuses, r.entries[name].breaker.Status())
+	}
+	return statuses
+}
+
+// Check runs the health checks of the providers whose circuit lets a call through, their results
+// count like transcriptions: failing checks open the circuit, a passing one closes it. Providers
+// without a health check are left alone.
+func (r *Registry) Check() []BreakerStatus {
+	for _, name := range r.Names() {
+		r.mu.Lock()
+		e := r.entries[name]
+		r.mu.Unlock()
+
+		hc, ok := e.transcriber.(HealthChecker)
+		if !ok || !e.breaker.Allow() {
+			continue
+		}
+		err := hc.HealthCheck()
+		if err != nil {
+			logging.L().Warn("Provider health check failed", "provider", name, "error", err)
+		}
+		e.breaker.Record(err)
+	}
+	r.save()
+	return r.Status()
+}
+
+// Transcriber returns a FallbackTranscriber over the registered providers that skips those whose
+// circuit is open. Failures of a provider count against its circuit, failures caused by the audio don't.
+func (r *Registry) Transcriber() *FallbackTranscriber {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := &FallbackTranscriber{onRecord: r.save}
+	for _, name := range r.names {
+		f.providers = append(f.providers, r.entries[name].transcriber)
+		f.breakers = append(f.breakers, r.entries[name].breaker)
+	}
+	return f
+}
+
+// save keeps the circuits in the file of the registry, failures are logged.
+func (r *Registry) save() {
+	if r.path == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]BreakerStatus, 0, len(r.names))
+	for _, name := range r.names {
+		statuses = append(statuses, r.entries[name].breaker.Status())
+	}
+	data, err := json.MarshalIndent(statuses, "", "  ")
+	if err == nil {
+		err = files.WriteFileAtomic(r.path, data, 0644)
+	}
+	if err != nil {
+		logging.L().Error("Failed to save the provider circuits", "path", r.path, "error", err)
+	}
+}
@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"time"
+)
+
+// checkedTranscriber is a namedTranscriber with a health check failing with checkErr.
+type checkedTranscriber struct {
+	namedTranscriber
+	checkErr error
+	checks   int
+}
+
+func (c *checkedTranscriber) HealthCheck() error {
+	c.checks++
+	return c.checkErr
+}
+
+func TestRegistry_Transcriber(t *testing.T) {
+	path := filepath.Join(t.TempDir(), BreakersFile)
+	registry, err := NewRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outage := NewTranscriptionError("openai", true, errors.New("503 service unavailable"))
+	remote := &namedTranscriber{name: "openai", err: outage}
+	local := &namedTranscriber{name: "whisper_cpp"}
+	cfg := config.BreakerConfig{Failures: 2, Cooldown: time.Hour}
+	registry.Register("openai", remote, cfg)
+	registry.Register("whisper_cpp", local, cfg)
+
+	f := registry.Transcriber()
+	for i := 0; i < 3; i++ {
+		if _, metadata, err := f.TranscriptWithMetadata("a.mp3"); err != nil || metadata.Provider != "whisper_cpp" {
+			t.Fatalf("TranscriptWithMetadata() = %v, %v, want the local provider", metadata.Provider, err)
+		}
+	}
+	if remote.calls != 2 || local.calls != 3 {
+		t.Errorf("calls = %d remote, %d local, want the remote skipped once its circuit opened", remote.calls, local.calls)
+	}
+
+	// a new process starts with the kept circuits
+	reloaded, err := NewRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.Register("openai", remote, cfg)
+	reloaded.Register("whisper_cpp", local, cfg)
+	statuses := reloaded.Status()
+	states := []string{statuses[0].State, statuses[1].State}
+	if !reflect.DeepEqual(states, []string{BreakerOpen, BreakerClosed}) || statuses[0].Failures != 2 {
+		t.Errorf("reloaded circuits = %+v", statuses)
+	}
+	if !reflect.DeepEqual(reloaded.Names(), []string{"openai", "whisper_cpp"}) {
+		t.Errorf("Names() = %v", reloaded.Names())
+	}
+}
+
+func TestRegistry_AllOpen(t *testing.T) {
+	registry, _ := NewRegistry("")
+	outage := NewTranscriptionError("openai", true, errors.New("connection refused"))
+	registry.Register("openai", &namedTranscriber{name: "openai", err: outage}, config.BreakerConfig{Failures: 1, Cooldown: time.Hour})
+
+	f := registry.Transcriber()
+	if _, _, err := f.TranscriptWithMetadata("a.mp3"); !errors.Is(err, outage) {
+		t.Fatalf("first call error = %v, want the outage", err)
+	}
+	if _, _, err := f.TranscriptWithMetadata("a.mp3"); !errors.Is(err, ErrNoProviderAvailable) {
+		t.Errorf("call with all circuits open error = %v, want ErrNoProviderAvailable", err)
+	}
+}
+
+func TestRegistry_Check(t *testing.T) {
+	registry, _ := NewRegistry("")
+	broken := &checkedTranscriber{namedTranscriber: namedTranscriber{name: "whisper_cpp"}, checkErr: errors.New("whisper.cpp binary: no such file")}
+	healthy := &checkedTranscriber{namedTranscriber: namedTranscriber{name: "openai"}}
+	registry.Register("whisper_cpp", broken, config.BreakerConfig{Failures: 1, Cooldown: time.Hour})
+	registry.Register("openai", healthy, config.BreakerConfig{Failures: 1, Cooldown: time.Hour})
+
+	statuses := registry.Check()
+	if statuses[0].State != BreakerOpen || statuses[0].LastError == "" || statuses[1].State != BreakerClosed {
+		t.Errorf("Check() = %+v, want whisper_cpp open", statuses)
+	}
+	registry.Check()
+	if broken.checks != 1 || healthy.checks != 2 {
+		t.Errorf("checks = %d broken, %d healthy, want the open circuit not checked again", broken.checks, healthy.checks)
+	}
+}
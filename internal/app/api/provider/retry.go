@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"math/rand"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// sleepContext waits d or until ctx is done, it is replaced in tests.
+var sleepContext = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryTranscriber calls its provider again when it fails with a retryable TranscriptionError,
+// waiting exponentially longer with jitter between the attempts. Other errors are returned right
+// away, so are failures whose next wait would pass the deadline of the context.
+type RetryTranscriber struct {
+	inner  api.OptionsTranscriber
+	policy config.RetryConfig
+}
+
+// NewRetryTranscriber wraps inner with policy, usually the Retry of the provider in providers.yaml.
+func NewRetryTranscriber(inner api.OptionsTranscriber, policy config.RetryConfig) *RetryTranscriber {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &RetryTranscriber{inner: inner, policy: policy}
+}
+
+// Transcript transcribes with the provider, retrying its retryable failures.
+func (r *RetryTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := r.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and reports the metadata of the successful attempt.
+func (r *RetryTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return r.TranscriptContext(context.Background(), inputFilePath)
+}
+
+// TranscriptContext works like TranscriptWithMetadata, ctx bounds the waits between the attempts.
+func (r *RetryTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return r.retry(ctx, inputFilePath, func() (string, model.ProviderMetadata, error) {
+		return Transcribe(ctx, r.inner, inputFilePath)
+	})
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata and passes prompt on to every attempt.
+func (r *RetryTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return r.retry(context.Background(), inputFilePath, func() (string, model.ProviderMetadata, error) {
+		return r.inner.TranscriptWithPrompt(inputFilePath, prompt)
+	})
+}
+
+// TranscriptWithOptions works like TranscriptWithMetadata and passes opts on to every attempt.
+func (r *RetryTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return r.retry(context.Background(), inputFilePath, func() (string, model.ProviderMetadata, error) {
+		return r.inner.TranscriptWithOptions(inputFilePath, opts)
+	})
+}
+
+// TranscriptStream works like TranscriptWithMetadata and forwards the segments of every attempt,
+// an attempt failing midway may have sent some before the next one starts over.
+func (r *RetryTranscriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	return r.retry(context.Background(), inputFilePath, func() (string, model.ProviderMetadata, error) {
+		return Stream(r.inner, inputFilePath, func(s model.Segment) { partials <- s })
+	})
+}
+
+// HealthCheck checks the provider, once.
+func (r *RetryTranscriber) HealthCheck() error {
+	if hc, ok := r.inner.(HealthChecker); ok {
+		return hc.HealthCheck()
+	}
+	return nil
+}
+
+func (r *RetryTranscriber) retry(ctx context.Context, inputFilePath string, call func() (string, model.ProviderMetadata, error)) (string, model.ProviderMetadata, error) {
+	for attempt := 1; ; attempt++ {
+		text, metadata, err := call()
+		if err == nil || attempt >= r.policy.MaxAttempts || !IsRetryable(err) {
+			return text, metadata, err
+		}
+
+		wait := r.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			logging.L().Warn("Transcription failed, no time left to retry", "file", inputFilePath, "attempt", attempt, "error", err)
+			return text, metadata, err
+		}
+		logging.L().Warn("Transcription failed, retrying", "file", inputFilePath, "attempt", attempt, "attempts", r.policy.MaxAttempts, "wait", wait, "error", err)
+		if sleepContext(ctx, wait) != nil {
+			return text, metadata, err
+		}
+	}
+}
+
+// backoff returns the wait after the failed attempt: InitialBackoff doubled for every earlier
+// failure, capped at MaxBackoff, of which a random half is taken off.
+func (r *RetryTranscriber) backoff(attempt int) time.Duration {
+	wait := r.policy.InitialBackoff
+	for i := 1; i < attempt && (r.policy.MaxBackoff <= 0 || wait < r.policy.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if r.policy.MaxBackoff > 0 && wait > r.policy.MaxBackoff {
+		wait = r.policy.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	half := wait / 2
+	return wait - half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// flakyTranscriber fails with errs in turn before it succeeds.
+type flakyTranscriber struct {
+	errs    []error
+	calls   int
+	prompts []string
+}
+
+func (f *flakyTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := f.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (f *flakyTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return f.TranscriptWithOptions(inputFilePath, api.Options{})
+}
+
+func (f *flakyTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return f.TranscriptWithOptions(inputFilePath, api.Options{Prompt: prompt})
+}
+
+func (f *flakyTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	f.calls++
+	f.prompts = append(f.prompts, opts.Prompt)
+	if f.calls <= len(f.errs) {
+		return "", model.ProviderMetadata{}, f.errs[f.calls-1]
+	}
+	return "text", model.ProviderMetadata{Provider: "flaky"}, nil
+}
+
+func noRetrySleep(t *testing.T) *[]time.Duration {
+	var slept []time.Duration
+	original := sleepContext
+	sleepContext = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleepContext = original })
+	return &slept
+}
+
+func TestRetryTranscriber(t *testing.T) {
+	outage := NewTranscriptionError("remote", true, errors.New("503 service unavailable"))
+	badAudio := NewTranscriptionError("remote", false, errors.New("400 invalid file format"))
+	policy := config.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		policy    config.RetryConfig
+		ctx       func() (context.Context, context.CancelFunc)
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "succeeds after retryable failures", errs: []error{outage, outage}, policy: policy, wantCalls: 3},
+		{name: "gives up after max attempts", errs: []error{outage, outage, outage}, policy: policy, wantErr: outage, wantCalls: 3},
+		{name: "permanent error not retried", errs: []error{badAudio}, policy: policy, wantErr: badAudio, wantCalls: 1},
+		{name: "unclassified error not retried", errs: []error{errors.New("boom")}, policy: policy, wantCalls: 1},
+		{name: "retries disabled", errs: []error{outage}, policy: config.RetryConfig{MaxAttempts: 1}, wantErr: outage, wantCalls: 1},
+		{
+			name:   "deadline before the next attempt",
+			errs:   []error{outage},
+			policy: policy,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 100*time.Millisecond)
+			},
+			wantErr:   outage,
+			wantCalls: 1,
+		},
+		{
+			name:   "canceled while waiting",
+			errs:   []error{outage},
+			policy: policy,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, func() {}
+			},
+			wantErr:   outage,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noRetrySleep(t)
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+
+			inner := &flakyTranscriber{errs: tt.errs}
+			text, _, err := NewRetryTranscriber(inner, tt.policy).TranscriptContext(ctx, "a.mp3")
+			if inner.calls != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", inner.calls, tt.wantCalls)
+			}
+			if tt.wantCalls > len(tt.errs) {
+				if err != nil || text != "text" {
+					t.Errorf("TranscriptContext() = %q, %v, want the text", text, err)
+				}
+			} else if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("TranscriptContext() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryTranscriber_Backoff(t *testing.T) {
+	slept := noRetrySleep(t)
+	outage := NewTranscriptionError("remote", true, errors.New("429 too many requests"))
+	inner := &flakyTranscriber{errs: []error{outage, outage, outage, outage}}
+	r := NewRetryTranscriber(inner, config.RetryConfig{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second})
+
+	if _, _, err := r.TranscriptWithPrompt("a.mp3", "previous"); err != nil {
+		t.Fatal(err)
+	}
+	limits := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	if len(*slept) != len(limits) {
+		t.Fatalf("waited %v, want %d waits", *slept, len(limits))
+	}
+	for i, d := range *slept {
+		if d < limits[i]/2 || d > limits[i] {
+			t.Errorf("wait %d = %v, want between %v and %v", i+1, d, limits[i]/2, limits[i])
+		}
+	}
+	for _, p := range inner.prompts {
+		if p != "previous" {
+			t.Errorf("attempt prompted with %q, want the prompt of the call", p)
+		}
+	}
+}
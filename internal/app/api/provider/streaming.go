@@ -0,0 +1,56 @@
+// Package provider describes capabilities a transcriber may offer on top of api.Transcriber.
+package provider
+
+import (
+	"context"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/model"
+)
+
+// StreamingTranscriber is implemented by transcribers that report segments while the audio is still being processed.
+type StreamingTranscriber interface {
+	api.MetadataTranscriber
+	// TranscriptStream works like TranscriptWithMetadata and sends every segment to partials as soon
+	// as it is transcribed. partials is closed before TranscriptStream returns.
+	TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error)
+}
+
+// Stream transcribes inputFilePath with t and calls onPartial with each segment as it arrives.
+// Transcribers that can't stream report their whole text as a single segment once they are done.
+func Stream(t api.Transcriber, inputFilePath string, onPartial func(model.Segment)) (string, model.ProviderMetadata, error) {
+	st, ok := t.(StreamingTranscriber)
+	if !ok {
+		text, metadata, err := Transcribe(context.Background(), t, inputFilePath)
+		if err == nil && text != "" {
+			onPartial(model.Segment{Text: text})
+		}
+		return text, metadata, err
+	}
+
+	partials := make(chan model.Segment, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for s := range partials {
+			onPartial(s)
+		}
+	}()
+
+	text, metadata, err := st.TranscriptStream(inputFilePath, partials)
+	<-done
+	return text, metadata, err
+}
+
+// Transcribe transcribes inputFilePath with t, passing ctx on when t takes one and reporting the
+// metadata when t describes how it transcribed.
+func Transcribe(ctx context.Context, t api.Transcriber, inputFilePath string) (string, model.ProviderMetadata, error) {
+	if ct, ok := t.(api.ContextTranscriber); ok {
+		return ct.TranscriptContext(ctx, inputFilePath)
+	}
+	if mt, ok := t.(api.MetadataTranscriber); ok {
+		return mt.TranscriptWithMetadata(inputFilePath)
+	}
+
+	text, err := t.Transcript(inputFilePath)
+	return text, model.ProviderMetadata{}, err
+}
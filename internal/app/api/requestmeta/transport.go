@@ -0,0 +1,140 @@
+// Package requestmeta builds provider HTTP clients with the headers, form fields and timeouts configured in providers.yaml.
+package requestmeta
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/tracing"
+	"time"
+)
+
+// Transport is an http.RoundTripper adding Headers to every request and FormFields to multipart bodies.
+type Transport struct {
+	Base       http.RoundTripper
+	Headers    map[string]string
+	FormFields map[string]string
+}
+
+// NewClient returns an http client with the timeouts configured for provider,
+// injecting its request metadata when there is any. Its requests are traced when tracing is enabled.
+func NewClient(provider string) *http.Client {
+	pc := config.GetProviders().For(provider)
+
+	var transport http.RoundTripper = newHTTPTransport(pc.Timeouts)
+	if len(pc.Headers) > 0 || len(pc.FormFields) > 0 {
+		transport = &Transport{Base: transport, Headers: pc.Headers, FormFields: pc.FormFields}
+	}
+	return &http.Client{
+		Transport: &tracing.Transport{Base: transport},
+		Timeout:   enabled(pc.Timeouts.Total),
+	}
+}
+
+// newHTTPTransport applies the phase timeouts to a transport otherwise like http.DefaultTransport.
+func newHTTPTransport(t config.TimeoutConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   enabled(t.Dial),
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		TLSHandshakeTimeout:   enabled(t.TLSHandshake),
+		ResponseHeaderTimeout: enabled(t.ResponseHeader),
+		IdleConnTimeout:       enabled(t.Idle),
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// enabled maps a disabled, i.e. negative, timeout to zero which means no timeout for net/http.
+func enabled(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	for name, value := range t.Headers {
+		req.Header.Set(name, value)
+	}
+
+	if len(t.FormFields) > 0 && req.Body != nil {
+		if err := t.addFormFields(req); err != nil {
+			return nil, err
+		}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// addFormFields appends the fields to a multipart/form-data body, other bodies are left alone.
+func (t *Transport) addFormFields(req *http.Request) error {
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil
+	}
+	boundary := params["boundary"]
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("read request body failed: %v", err)
+	}
+
+	// Drop the closing delimiter, the writer below continues the same form and closes it again.
+	// The CRLF in front of the delimiter is kept, it starts the first added part.
+	end := bytes.LastIndex(body, []byte("--"+boundary+"--"))
+	if end < 0 {
+		return fmt.Errorf("multipart body has no closing boundary")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(body[:end])
+	w := multipart.NewWriter(&buf)
+	if err = w.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(t.FormFields) {
+		if err = w.WriteField(name, t.FormFields[name]); err != nil {
+			return err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	data := buf.Bytes()
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
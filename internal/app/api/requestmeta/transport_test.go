@@ -0,0 +1,98 @@
+package requestmeta
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"time"
+)
+
+func TestTransport_RoundTrip(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm() error = %v", err)
+		}
+		got = r
+	}))
+	defer server.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("model", "whisper-1")
+	fw, _ := mw.CreateFormFile("file", "a.mp3")
+	fw.Write([]byte("audio"))
+	mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	client := &http.Client{Transport: &Transport{
+		Headers:    map[string]string{"X-Trace-Id": "trace-1"},
+		FormFields: map[string]string{"cost_center": "team-a", "temperature": "0"},
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "header", got: got.Header.Get("X-Trace-Id"), want: "trace-1"},
+		{name: "original field", got: got.FormValue("model"), want: "whisper-1"},
+		{name: "added field", got: got.FormValue("cost_center"), want: "team-a"},
+		{name: "second added field", got: got.FormValue("temperature"), want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+
+	f, _, err := got.FormFile("file")
+	if err != nil {
+		t.Fatalf("FormFile() error = %v", err)
+	}
+	if data, _ := io.ReadAll(f); string(data) != "audio" {
+		t.Errorf("file content = %q, want audio", data)
+	}
+}
+
+func TestNewHTTPTransport_ResponseHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		responseHeader time.Duration
+		wantErr        bool
+	}{
+		{name: "exceeded", responseHeader: 20 * time.Millisecond, wantErr: true},
+		{name: "within", responseHeader: time.Second, wantErr: false},
+		{name: "disabled", responseHeader: -1, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: newHTTPTransport(config.TimeoutConfig{ResponseHeader: tt.responseHeader})}
+			resp, err := client.Get(server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if resp != nil {
+				resp.Body.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package ssh_whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/logging"
+	"time"
+)
+
+// ModelRepository is where whisper.cpp publishes its ggml models, with the sha256 of every file.
+const ModelRepository = "https://huggingface.co/ggerganov/whisper.cpp"
+
+// modelProgressInterval is how often the size of a model being downloaded on the host is reported.
+const modelProgressInterval = time.Second
+
+// RemoteModel is a ggml model on the host.
+type RemoteModel struct {
+	// Name is the model without the ggml- prefix and the .bin extension, e.g. large-v3.
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Default is set for the model of ssh.model in providers.yaml.
+	Default bool `json:"default"`
+}
+
+// ModelFile is a model of the ModelRepository.
+type ModelFile struct {
+	Name   string
+	Size   int64
+	SHA256 string
+	URL    string
+}
+
+// ModelFileName returns the file of the model name, e.g. ggml-base.en.bin for base.en. Names
+// that are file names already are returned as they are.
+func ModelFileName(name string) string {
+	name = path.Base(name)
+	if strings.HasPrefix(name, "ggml-") && strings.HasSuffix(name, ".bin") {
+		return name
+	}
+	return "ggml-" + name + ".bin"
+}
+
+// modelName is the inverse of ModelFileName.
+func modelName(file string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(path.Base(file), "ggml-"), ".bin")
+}
+
+// ModelsDir is the directory of the configured model on the host, models are listed and downloaded there.
+func (st *SSHTranscriber) ModelsDir() string {
+	return path.Dir(st.cfg.Model)
+}
+
+// ListModels lists the ggml models in ModelsDir on the host.
+func (st *SSHTranscriber) ListModels() ([]RemoteModel, error) {
+	conn, err := st.pool.Get(st.addr, st.client)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := conn.SFTP.ReadDir(st.ModelsDir())
+	if err != nil {
+		return nil, fmt.Errorf("list %s on %s failed: %w", st.ModelsDir(), st.addr, err)
+	}
+
+	var models []RemoteModel
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), "ggml-") || !strings.HasSuffix(e.Name(), ".bin") {
+			continue
+		}
+		p := path.Join(st.ModelsDir(), e.Name())
+		models = append(models, RemoteModel{Name: modelName(e.Name()), Path: p, Size: e.Size(), Default: p == path.Clean(st.cfg.Model)})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return models, nil
+}
+
+// LookupModel returns the file of the model name in the repository at baseURL, usually
+// ModelRepository, with its size and sha256.
+func LookupModel(ctx context.Context, client *http.Client, baseURL string, name string) (ModelFile, error) {
+	u, err := treeURL(baseURL)
+	if err != nil {
+		return ModelFile{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return ModelFile{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ModelFile{}, fmt.Errorf("list the models of %s failed: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return ModelFile{}, fmt.Errorf("list the models of %s failed: %s %s", baseURL, resp.Status, bytes.TrimSpace(data))
+	}
+
+	// the files of the repository, models are stored with git lfs which records their sha256
+	var files []struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+		LFS  *struct {
+			OID  string `json:"oid"`
+			Size int64  `json:"size"`
+		} `json:"lfs"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&files); err != nil {
+		return ModelFile{}, fmt.Errorf("parse the models of %s failed: %v", baseURL, err)
+	}
+
+	file := ModelFileName(name)
+	var available []string
+	for _, f := range files {
+		if f.Type != "file" || !strings.HasPrefix(f.Path, "ggml-") || !strings.HasSuffix(f.Path, ".bin") {
+			continue
+		}
+		if f.Path != file {
+			available = append(available, modelName(f.Path))
+			continue
+		}
+		if f.LFS == nil || f.LFS.OID == "" {
+			return ModelFile{}, fmt.Errorf("%s has no checksum for %s", baseURL, file)
+		}
+		return ModelFile{
+			Name:   modelName(file),
+			Size:   f.LFS.Size,
+			SHA256: f.LFS.OID,
+			URL:    strings.TrimSuffix(baseURL, "/") + "/resolve/main/" + file,
+		}, nil
+	}
+	return ModelFile{}, fmt.Errorf("unknown model %s, available: %s", name, strings.Join(available, ", "))
+}
+
+// treeURL is the API listing the files of the repository at baseURL, e.g.
+// https://huggingface.co/api/models/ggerganov/whisper.cpp/tree/main.
+func treeURL(baseURL string) (string, error) {
+	i := strings.Index(baseURL, "://")
+	if i < 0 {
+		return "", fmt.Errorf("invalid model repository %s", baseURL)
+	}
+	host, repo, ok := strings.Cut(baseURL[i+3:], "/")
+	if !ok || repo == "" {
+		return "", fmt.Errorf("invalid model repository %s", baseURL)
+	}
+	return baseURL[:i+3] + host + "/api/models/" + strings.Trim(repo, "/") + "/tree/main", nil
+}
+
+// DownloadModel downloads m on the host into ModelsDir, so the model doesn't pass through this
+// machine, and verifies its sha256 there. A model already present is verified and kept. The size
+// downloaded so far is reported to st's progress handler, a model failing verification is removed.
+func (st *SSHTranscriber) DownloadModel(ctx context.Context, m ModelFile) (RemoteModel, error) {
+	conn, err := st.pool.Get(st.addr, st.client)
+	if err != nil {
+		return RemoteModel{}, err
+	}
+	target := path.Join(st.ModelsDir(), ModelFileName(m.Name))
+	model := RemoteModel{Name: m.Name, Path: target, Size: m.Size, Default: target == path.Clean(st.cfg.Model)}
+	logger := logging.L().With("provider", providerName, "host", st.addr, "model", m.Name)
+
+	if _, err = conn.SFTP.Stat(target); err == nil {
+		if err = st.verifyModel(ctx, conn, target, m.SHA256); err != nil {
+			return model, fmt.Errorf("%s is already on %s but doesn't match the checksum, remove it to download it again: %w", target, st.addr, err)
+		}
+		logger.Info("Model already on the host")
+		return model, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return model, fmt.Errorf("stat %s on %s failed: %w", target, st.addr, err)
+	}
+
+	if err = conn.SFTP.MkdirAll(st.ModelsDir()); err != nil {
+		return model, fmt.Errorf("create %s on %s failed: %w", st.ModelsDir(), st.addr, err)
+	}
+	// downloaded next to the target and renamed once verified, a failed download never passes for the model
+	partial := target + ".partial"
+	defer st.removeRemote(conn, partial)
+
+	logger.Info("Downloading model on the host", "url", m.URL, "size", m.Size)
+	q, u := shellQuote(partial), shellQuote(m.URL)
+	download := fmt.Sprintf("if command -v curl >/dev/null 2>&1; then curl -fsSL -o %s %s; else wget -q -O %s %s; fi", q, u, q, u)
+	done := make(chan struct{})
+	go st.reportModelProgress(conn, partial, m.Size, done)
+	err = st.run(ctx, conn, download, nil)
+	close(done)
+	if err != nil {
+		return model, fmt.Errorf("download %s on %s failed: %w", m.URL, st.addr, err)
+	}
+
+	if err = st.verifyModel(ctx, conn, partial, m.SHA256); err != nil {
+		return model, err
+	}
+	if err = conn.SFTP.PosixRename(partial, target); err != nil {
+		return model, fmt.Errorf("rename %s on %s failed: %w", partial, st.addr, err)
+	}
+	logger.Info("Downloaded and verified model")
+	return model, nil
+}
+
+// reportModelProgress reports the size of the model being downloaded to file until done is closed.
+func (st *SSHTranscriber) reportModelProgress(c *Conn, file string, total int64, done <-chan struct{}) {
+	if st.onProgress == nil {
+		return
+	}
+	ticker := time.NewTicker(modelProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if info, err := c.SFTP.Stat(file); err == nil {
+				st.onProgress(Transfer{File: file, Done: info.Size(), Total: total})
+			}
+		}
+	}
+}
+
+// verifyModel compares the sha256 of the file on the host with want.
+func (st *SSHTranscriber) verifyModel(ctx context.Context, c *Conn, file string, want string) error {
+	var stdout bytes.Buffer
+	if err := st.run(ctx, c, "sha256sum "+shellQuote(file), &stdout); err != nil {
+		return fmt.Errorf("checksum of %s on %s failed: %w", file, st.addr, err)
+	}
+	got, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), " ")
+	if !strings.EqualFold(got, want) {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", file, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package ssh_whisper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestModelFileName(t *testing.T) {
+	for name, want := range map[string]string{
+		"base.en":               "ggml-base.en.bin",
+		"large-v3":              "ggml-large-v3.bin",
+		"ggml-tiny.bin":         "ggml-tiny.bin",
+		"/models/ggml-tiny.bin": "ggml-tiny.bin",
+	} {
+		if got := ModelFileName(name); got != want {
+			t.Errorf("ModelFileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSSHTranscriber_ListModels(t *testing.T) {
+	s := startSSHServer(t)
+	st, _ := newTestTranscriber(t, s)
+	dir := filepath.Dir(st.cfg.Model)
+	os.WriteFile(filepath.Join(dir, "ggml-tiny.bin"), []byte("tiny"), 0644)
+	os.WriteFile(filepath.Join(dir, "ggml-tiny.bin.partial"), []byte("ti"), 0644)
+
+	models, err := st.ListModels()
+	if err != nil {
+		t.Fatalf("ListModels() error = %v", err)
+	}
+	want := []RemoteModel{
+		{Name: "base", Path: st.cfg.Model, Size: 0, Default: true},
+		{Name: "tiny", Path: filepath.Join(dir, "ggml-tiny.bin"), Size: 4},
+	}
+	if !reflect.DeepEqual(models, want) {
+		t.Errorf("ListModels() = %+v, want %+v", models, want)
+	}
+}
+
+// modelRepository serves the tree API and the files of a model repository holding models, the
+// checksums it lists are those of checksums.
+func modelRepository(t *testing.T, models map[string]string, checksums map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/models/ggerganov/whisper.cpp/tree/main":
+			var entries []string
+			for file, content := range models {
+				entries = append(entries, fmt.Sprintf(`{"type":"file","path":%q,"size":134,"lfs":{"oid":%q,"size":%d}}`,
+					file, checksums[file], len(content)))
+			}
+			entries = append(entries, `{"type":"file","path":"README.md","size":12}`, `{"type":"directory","path":"models"}`)
+			fmt.Fprintf(w, "[%s]", strings.Join(entries, ","))
+		case strings.HasPrefix(r.URL.Path, "/ggerganov/whisper.cpp/resolve/main/"):
+			content, ok := models[strings.TrimPrefix(r.URL.Path, "/ggerganov/whisper.cpp/resolve/main/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLookupModel(t *testing.T) {
+	srv := modelRepository(t, map[string]string{"ggml-tiny.bin": "tiny model"}, map[string]string{"ggml-tiny.bin": sha256Hex("tiny model")})
+	base := srv.URL + "/ggerganov/whisper.cpp"
+
+	m, err := LookupModel(context.Background(), srv.Client(), base, "tiny")
+	if err != nil {
+		t.Fatalf("LookupModel() error = %v", err)
+	}
+	want := ModelFile{Name: "tiny", Size: 10, SHA256: sha256Hex("tiny model"), URL: base + "/resolve/main/ggml-tiny.bin"}
+	if m != want {
+		t.Errorf("LookupModel() = %+v, want %+v", m, want)
+	}
+
+	if _, err = LookupModel(context.Background(), srv.Client(), base, "huge"); err == nil || !strings.Contains(err.Error(), "available: tiny") {
+		t.Errorf("LookupModel() of an unknown model error = %v, want the available models", err)
+	}
+}
+
+func TestSSHTranscriber_DownloadModel(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("the host downloads with curl")
+	}
+	const content = "tiny model"
+	tests := []struct {
+		name     string
+		checksum string
+		present  string
+		wantErr  bool
+	}{
+		{name: "downloaded and verified", checksum: sha256Hex(content)},
+		{name: "checksum mismatch", checksum: sha256Hex("another model"), wantErr: true},
+		{name: "already present", checksum: sha256Hex(content), present: content},
+		{name: "present but corrupt", checksum: sha256Hex(content), present: "tiny mod", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := modelRepository(t, map[string]string{"ggml-tiny.bin": content}, map[string]string{"ggml-tiny.bin": tt.checksum})
+			st, _ := newTestTranscriber(t, startSSHServer(t))
+			target := filepath.Join(filepath.Dir(st.cfg.Model), "ggml-tiny.bin")
+			if tt.present != "" {
+				os.WriteFile(target, []byte(tt.present), 0644)
+			}
+
+			m, err := LookupModel(context.Background(), srv.Client(), srv.URL+"/ggerganov/whisper.cpp", "tiny")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := st.DownloadModel(context.Background(), m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DownloadModel() error = %v, want an error %v", err, tt.wantErr)
+			}
+			if _, err := os.Stat(target + ".partial"); !os.IsNotExist(err) {
+				t.Errorf("the partial download is left on the host")
+			}
+			data, _ := os.ReadFile(target)
+			switch {
+			case tt.present != "":
+				if string(data) != tt.present {
+					t.Errorf("the model on the host = %q, want it kept", data)
+				}
+			case tt.wantErr:
+				if data != nil {
+					t.Errorf("the model failing verification is on the host")
+				}
+			default:
+				if string(data) != content || got.Path != target || got.Name != "tiny" {
+					t.Errorf("DownloadModel() = %+v with %q on the host, want the model", got, data)
+				}
+			}
+		})
+	}
+}
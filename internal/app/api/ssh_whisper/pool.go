@@ -0,0 +1,124 @@
+package ssh_whisper
+
+import (
+	"fmt"
+	"sync"
+	"tiktok-whisper/internal/app/logging"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultKeepAlive = 30 * time.Second
+
+// Pool keeps one SSH connection per host open between files, with an SFTP client on it, so a batch
+// pays for the handshake once rather than per file. The sessions of concurrent transcriptions share
+// the connection. Keepalives hold idle connections open and find the dead ones, which are dialed
+// again by the next Get.
+type Pool struct {
+	keepAlive time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*Conn
+}
+
+// Conn is a pooled connection and the SFTP client on it.
+type Conn struct {
+	*ssh.Client
+	SFTP *sftp.Client
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPool creates a pool sending keepalives every keepAlive, 30s when it is zero.
+func NewPool(keepAlive time.Duration) *Pool {
+	if keepAlive <= 0 {
+		keepAlive = defaultKeepAlive
+	}
+	return &Pool{keepAlive: keepAlive, conns: map[string]*Conn{}}
+}
+
+// Get returns the open connection to addr, or dials it with cfg. Dials are serialized, so the
+// workers of a batch starting together share one connection rather than racing to open several.
+func (p *Pool) Get(addr string, cfg *ssh.ClientConfig) (*Conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.conns[addr]; ok {
+		return c, nil
+	}
+
+	client, err := ssh.Dial("tcp", addr, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s failed: %w", addr, err)
+	}
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("start sftp on %s failed: %w", addr, err)
+	}
+	c := &Conn{Client: client, SFTP: sftpClient, closed: make(chan struct{})}
+	p.conns[addr] = c
+	logging.L().Debug("Opened SSH connection", "provider", providerName, "host", addr)
+
+	go func() {
+		client.Wait()
+		p.Discard(addr, c)
+	}()
+	go p.keepAliveLoop(addr, c)
+	return c, nil
+}
+
+// Check sends a keepalive on c after a failed transfer or command and discards c when it is dead,
+// so the retry dials again instead of failing on the same connection.
+func (p *Pool) Check(addr string, c *Conn) {
+	if _, _, err := c.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+		logging.L().Warn("SSH connection is dead", "provider", providerName, "host", addr, "error", err)
+		p.Discard(addr, c)
+	}
+}
+
+// Discard closes c and removes it from the pool if it is still the connection to addr.
+func (p *Pool) Discard(addr string, c *Conn) {
+	p.mu.Lock()
+	if p.conns[addr] == c {
+		delete(p.conns, addr)
+	}
+	p.mu.Unlock()
+	c.close()
+}
+
+// Close closes every connection of the pool.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = map[string]*Conn{}
+	p.mu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+	return nil
+}
+
+// keepAliveLoop sends keepalives on c until it is closed, a connection not answering is discarded.
+func (p *Pool) keepAliveLoop(addr string, c *Conn) {
+	ticker := time.NewTicker(p.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			p.Check(addr, c)
+		}
+	}
+}
+
+func (c *Conn) close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.SFTP.Close()
+		c.Client.Close()
+	})
+}
@@ -0,0 +1,362 @@
+// Package ssh_whisper transcribes with the whisper.cpp executable of a remote host, e.g. a GPU box
+// reachable over SSH only. The audio is uploaded over SFTP to a temp file of the host, whisper.cpp
+// runs on it in an SSH session and the transcript is read back, the temp files are removed
+// whatever the outcome. The connection is kept open between files, see Pool.
+package ssh_whisper
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/whisper_cpp"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/vocab"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	providerName     = "ssh_whisper"
+	defaultLanguage  = "zh"
+	defaultRemoteDir = "/tmp"
+)
+
+// SSHTranscriber transcribes with whisper.cpp on a remote host.
+type SSHTranscriber struct {
+	cfg        config.SSHConfig
+	addr       string
+	client     *ssh.ClientConfig
+	pool       *Pool
+	onProgress func(Transfer)
+
+	language string
+	request  *model.RequestMetadata
+	decoding config.DecodingConfig
+	// verbatim keeps fillers and stutters, see config.VerbatimConfig
+	verbatim bool
+	// vocabulary are the installed vocabulary packs, their terms bias the prompt
+	vocabulary vocab.Packs
+}
+
+// NewSSHTranscriber creates the transcriber of the host of cfg, connecting with client.
+func NewSSHTranscriber(cfg config.SSHConfig, client *ssh.ClientConfig) *SSHTranscriber {
+	addr := cfg.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	return &SSHTranscriber{
+		cfg:        cfg,
+		addr:       addr,
+		client:     client,
+		pool:       NewPool(cfg.KeepAlive),
+		language:   defaultLanguage,
+		request:    config.GetProviders().For(providerName).Record(),
+		decoding:   config.GetProviders().For(providerName).Decoding,
+		verbatim:   config.Get().Verbatim.Default,
+		vocabulary: vocab.Installed(),
+	}
+}
+
+// New returns the transcriber of the ssh settings of ssh_whisper in providers.yaml, authenticating
+// with their key file or the ssh-agent and verifying the host against their known hosts.
+func New() (*SSHTranscriber, error) {
+	providerCfg := config.GetProviders().For(providerName)
+	cfg := providerCfg.SSH
+	if cfg.Host == "" || cfg.Binary == "" || cfg.Model == "" {
+		return nil, fmt.Errorf("%s needs ssh.host, ssh.binary and ssh.model in providers.yaml", providerName)
+	}
+	auth, err := authMethod(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	knownHosts := cfg.KnownHosts
+	if knownHosts == "" {
+		knownHosts = "~/.ssh/known_hosts"
+	}
+	hostKeys, err := knownhosts.New(expandHome(knownHosts))
+	if err != nil {
+		return nil, fmt.Errorf("read known hosts failed: %v", err)
+	}
+	return NewSSHTranscriber(cfg, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeys,
+		Timeout:         providerCfg.Timeouts.Dial,
+	}), nil
+}
+
+// authMethod authenticates with the private key at keyFile, or with the keys of the ssh-agent when it is empty.
+func authMethod(keyFile string) (ssh.AuthMethod, error) {
+	if keyFile == "" {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, fmt.Errorf("%s needs ssh.key_file in providers.yaml or a running ssh-agent", providerName)
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("connect to ssh-agent failed: %v", err)
+		}
+		return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), nil
+	}
+	key, err := os.ReadFile(expandHome(keyFile))
+	if err != nil {
+		return nil, fmt.Errorf("read ssh key failed: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("parse ssh key %s failed: %v", keyFile, err)
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+// expandHome replaces a leading ~ of p by the home directory.
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[1:])
+}
+
+// SetLanguage sets the language whisper.cpp transcribes, zh unless set.
+func (st *SSHTranscriber) SetLanguage(language string) {
+	st.language = language
+}
+
+// SetProgressHandler reports the progress of the uploads and downloads to onProgress.
+func (st *SSHTranscriber) SetProgressHandler(onProgress func(Transfer)) {
+	st.onProgress = onProgress
+}
+
+// Close closes the connection to the host.
+func (st *SSHTranscriber) Close() error {
+	return st.pool.Close()
+}
+
+func (st *SSHTranscriber) lang() string {
+	if st.language == "" {
+		return defaultLanguage
+	}
+	return st.language
+}
+
+// SupportedFormats is the 16kHz WAV whisper.cpp reads, other audio is converted before it is uploaded.
+func (st *SSHTranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"wav"}, Codecs: []string{"pcm_s16le"}, SampleRate: 16000}
+}
+
+func (st *SSHTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := st.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and also reports the host, model, language and segments.
+func (st *SSHTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return st.TranscriptWithPrompt(inputFilePath, "")
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata, previousText is appended to the language prompt.
+func (st *SSHTranscriber) TranscriptWithPrompt(inputFilePath string, previousText string) (string, model.ProviderMetadata, error) {
+	return st.transcribe(context.Background(), inputFilePath, api.Options{Prompt: previousText})
+}
+
+// TranscriptWithOptions works like TranscriptWithPrompt with opts.Prompt as previousText and passes
+// the decoding settings on to whisper.cpp.
+func (st *SSHTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return st.transcribe(context.Background(), inputFilePath, opts)
+}
+
+// TranscriptContext works like TranscriptWithOptions with the options ctx carries, the transfers
+// stop and whisper.cpp is killed once ctx is canceled.
+func (st *SSHTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return st.transcribe(ctx, inputFilePath, api.OptionsFrom(ctx))
+}
+
+func (st *SSHTranscriber) transcribe(ctx context.Context, inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	logger := logging.L().With("provider", providerName, "host", st.addr)
+	opts = opts.Merge(api.Options{
+		NoContext:         st.decoding.NoContext(),
+		NoSpeechThreshold: st.decoding.NoSpeechThreshold,
+		VAD:               st.decoding.VAD,
+	})
+	if st.verbatim {
+		opts = opts.Verbatim()
+	}
+	if opts.VAD && st.decoding.VADModel == "" {
+		logger.Warn("VAD needs decoding.vad_model in providers.yaml, transcribing without it")
+		opts.VAD = false
+	}
+
+	language := st.lang()
+	prompt := whisper_cpp.LanguagePrompt(language) + st.vocabulary.Prompt(language)
+	if st.verbatim {
+		prompt += api.VerbatimPrompt(language)
+	}
+	if !opts.NoContext {
+		prompt += opts.Prompt
+	}
+	metadata := model.ProviderMetadata{
+		Provider: providerName,
+		Model:    modelName(st.cfg.Model),
+		Language: language,
+		Request:  st.request,
+		Verbatim: st.verbatim,
+		Server:   &model.ServerInfo{Host: st.addr},
+		WhisperCpp: &model.WhisperCppMetadata{
+			BinaryPath: st.cfg.Binary,
+			ModelPath:  st.cfg.Model,
+			Prompt:     prompt,
+
+			NoContext:         opts.NoContext,
+			Temperature:       opts.Temperature,
+			NoSpeechThreshold: opts.NoSpeechThreshold,
+			VAD:               opts.VAD,
+			Translate:         opts.Translate,
+			WordThreshold:     opts.WordThreshold,
+			MaxSegmentLength:  opts.MaxSegmentLength,
+		},
+	}
+
+	conn, err := st.pool.Get(st.addr, st.client)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, ctx.Err() == nil, err)
+	}
+	// a failure of the connection shows on the next keepalive at the latest, checking right away
+	// lets a retry dial again rather than fail on the dead connection
+	defer func() {
+		if err != nil && ctx.Err() == nil {
+			st.pool.Check(st.addr, conn)
+		}
+	}()
+
+	remoteDir := st.cfg.RemoteDir
+	if remoteDir == "" {
+		remoteDir = defaultRemoteDir
+	}
+	prefix, err := remotePrefix(remoteDir)
+	if err != nil {
+		return "", metadata, err
+	}
+	wav, txt := prefix+".wav", prefix+".txt"
+	defer st.removeRemote(conn, wav, txt)
+
+	logger.Info("Starting transcription", "file", inputFilePath)
+	if err = st.upload(ctx, conn, inputFilePath, wav); err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, ctx.Err() == nil, err)
+	}
+
+	command := shellQuote(st.cfg.Binary)
+	for _, arg := range whisper_cpp.Args(st.cfg.Model, language, prompt, wav, prefix, opts, st.decoding.VADModel) {
+		command += " " + shellQuote(arg)
+	}
+	var stdout bytes.Buffer
+	if err = st.run(ctx, conn, command, &stdout); err != nil {
+		// the command failing is not about the audio, which converted fine, another provider may succeed
+		return "", metadata, provider.NewTranscriptionError(providerName, ctx.Err() == nil, err)
+	}
+	metadata.Segments = whisper_cpp.Segments(stdout.String())
+	metadata.SegmentCount = len(metadata.Segments)
+	if metadata.SegmentCount > 0 {
+		metadata.DurationSeconds = metadata.Segments[metadata.SegmentCount-1].End
+	}
+
+	output, err := st.download(ctx, conn, txt)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, ctx.Err() == nil, err)
+	}
+	return strings.TrimSpace(string(output)), metadata, nil
+}
+
+// run runs the shell command in a session of c, its stdout is written to stdout unless that is nil.
+// The remote process is killed and the session closed once ctx is canceled.
+func (st *SSHTranscriber) run(ctx context.Context, c *Conn, command string, stdout io.Writer) error {
+	session, err := c.NewSession()
+	if err != nil {
+		return fmt.Errorf("open session on %s failed: %w", st.addr, err)
+	}
+	defer session.Close()
+	var stderr bytes.Buffer
+	session.Stdout = stdout
+	session.Stderr = &stderr
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Signal(ssh.SIGKILL)
+			session.Close()
+		case <-done:
+		}
+	}()
+
+	logging.L().Debug("Running command", "provider", providerName, "host", st.addr, "command", command)
+	if err = session.Run(command); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("command execution error on %s: %v, stderr: %s", st.addr, err, stderr.String())
+	}
+	return nil
+}
+
+// removeRemote removes the temp files of a transcription from the host, the ones never written are skipped.
+func (st *SSHTranscriber) removeRemote(c *Conn, files ...string) {
+	for _, f := range files {
+		if err := c.SFTP.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logging.L().Warn("Error removing remote temp file", "provider", providerName, "host", st.addr, "file", f, "error", err)
+		}
+	}
+}
+
+// HealthCheck connects to the host and checks that the binary is executable and the model exists there.
+func (st *SSHTranscriber) HealthCheck() error {
+	conn, err := st.pool.Get(st.addr, st.client)
+	if err != nil {
+		return provider.NewTranscriptionError(providerName, true, err)
+	}
+	session, err := conn.NewSession()
+	if err != nil {
+		st.pool.Check(st.addr, conn)
+		return provider.NewTranscriptionError(providerName, true, fmt.Errorf("open session on %s failed: %w", st.addr, err))
+	}
+	defer session.Close()
+	check := fmt.Sprintf("test -x %s && test -f %s", shellQuote(st.cfg.Binary), shellQuote(st.cfg.Model))
+	if err = session.Run(check); err != nil {
+		return fmt.Errorf("whisper.cpp binary %s or model %s is missing on %s", st.cfg.Binary, st.cfg.Model, st.addr)
+	}
+	return nil
+}
+
+// remotePrefix returns a unique path in dir for the temp files of a transcription, parallel
+// transcriptions and other clients of the host don't share them.
+func remotePrefix(dir string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return path.Join(dir, "v2t-"+hex.EncodeToString(b)), nil
+}
+
+// shellQuote quotes s for the POSIX shell the command runs in.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,308 @@
+package ssh_whisper
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeWhisper is a whisper.cpp executable writing the transcript of the input of -f to the .txt
+// of -of and printing its segments. It records its arguments next to itself.
+const fakeWhisper = `#!/bin/sh
+dir=$(dirname "$0")
+printf '%s\n' "$@" > "$dir/args"
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-f) in="$2"; shift ;;
+	-of) out="$2"; shift ;;
+	esac
+	shift
+done
+test -f "$in" || { echo "no input $in" >&2; exit 1; }
+printf 'hello world\n' > "$out.txt"
+echo '[00:00:00.000 --> 00:00:01.500]  hello'
+echo '[00:00:01.500 --> 00:00:03.000]  world'
+`
+
+// sshServer is an SSH server running commands with sh and serving SFTP on the local file system.
+type sshServer struct {
+	addr string
+	// conns counts the connections accepted
+	conns atomic.Int32
+}
+
+func startSSHServer(t *testing.T) *sshServer {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() != "whisper" || string(password) != "secret" {
+				return nil, ssh.ErrNoAuth
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &sshServer{addr: l.Addr().String()}
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.conns.Add(1)
+			go serveConn(nc, cfg)
+		}
+	}()
+	return s
+}
+
+func serveConn(nc net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "sessions only")
+			continue
+		}
+		ch, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSession(ch, requests)
+	}
+}
+
+func serveSession(ch ssh.Channel, requests <-chan *ssh.Request) {
+	defer ch.Close()
+	for req := range requests {
+		switch req.Type {
+		case "subsystem":
+			req.Reply(true, nil)
+			server, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+			server.Serve()
+			return
+		case "exec":
+			req.Reply(true, nil)
+			// the payload is the command as an SSH string, a length and the bytes
+			command := string(req.Payload[4:])
+			cmd := exec.Command("sh", "-c", command)
+			cmd.Stdout, cmd.Stderr = ch, ch.Stderr()
+			status := 0
+			if err := cmd.Run(); err != nil {
+				status = 1
+			}
+			ch.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, uint32(status)))
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// newTestTranscriber returns the transcriber of s running the fake whisper.cpp, with the remote dir it uses.
+func newTestTranscriber(t *testing.T, s *sshServer) (*SSHTranscriber, string) {
+	t.Helper()
+	host := t.TempDir()
+	binaryPath := filepath.Join(host, "whisper")
+	if err := os.WriteFile(binaryPath, []byte(fakeWhisper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	modelPath := filepath.Join(host, "ggml-base.bin")
+	if err := os.WriteFile(modelPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	remoteDir := t.TempDir()
+
+	st := NewSSHTranscriber(config.SSHConfig{Host: s.addr, Binary: binaryPath, Model: modelPath, RemoteDir: remoteDir},
+		&ssh.ClientConfig{
+			User:            "whisper",
+			Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			Timeout:         5 * time.Second,
+		})
+	st.vocabulary = nil
+	st.verbatim = false
+	t.Cleanup(func() { st.Close() })
+	return st, remoteDir
+}
+
+func writeAudio(t *testing.T) string {
+	t.Helper()
+	audio := filepath.Join(t.TempDir(), "a.wav")
+	if err := os.WriteFile(audio, []byte(strings.Repeat("RIFF", 1024)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return audio
+}
+
+func TestSSHTranscriber_TranscriptWithOptions(t *testing.T) {
+	s := startSSHServer(t)
+	st, remoteDir := newTestTranscriber(t, s)
+	st.SetLanguage("en")
+	var transfers []Transfer
+	st.SetProgressHandler(func(tr Transfer) { transfers = append(transfers, tr) })
+	audio := writeAudio(t)
+
+	// the files of a batch share the connection
+	for i := 0; i < 3; i++ {
+		text, metadata, err := st.TranscriptWithOptions(audio, api.Options{Prompt: "it's", Temperature: 0.2})
+		if err != nil {
+			t.Fatalf("TranscriptWithOptions() error = %v", err)
+		}
+		wantSegments := []model.Segment{{Start: 0, End: 1.5, Text: "hello"}, {Start: 1.5, End: 3, Text: "world"}}
+		if text != "hello world" || !reflect.DeepEqual(metadata.Segments, wantSegments) {
+			t.Errorf("TranscriptWithOptions() = %q, %+v", text, metadata.Segments)
+		}
+		if metadata.Provider != providerName || metadata.Model != "base" || metadata.Server.Host != s.addr || metadata.DurationSeconds != 3 {
+			t.Errorf("metadata = %+v", metadata)
+		}
+	}
+	if n := s.conns.Load(); n != 1 {
+		t.Errorf("connections = %d, want 1 reused for every file", n)
+	}
+
+	args, err := os.ReadFile(filepath.Join(filepath.Dir(st.cfg.Binary), "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"--prompt\nit's\n", "-l\nen\n", "--temperature\n0.20\n"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("whisper.cpp args miss %q:\n%s", want, args)
+		}
+	}
+
+	// the temp files are removed from the host
+	if left, _ := os.ReadDir(remoteDir); len(left) != 0 {
+		t.Errorf("remote dir keeps %d files, want none", len(left))
+	}
+
+	var uploaded, downloaded Transfer
+	for _, tr := range transfers {
+		if tr.Upload {
+			uploaded = tr
+		} else {
+			downloaded = tr
+		}
+	}
+	if uploaded.Done != 4096 || uploaded.Total != 4096 || !strings.HasPrefix(uploaded.File, remoteDir) {
+		t.Errorf("last upload progress = %+v, want 4096 of 4096 bytes", uploaded)
+	}
+	if downloaded.Done != int64(len("hello world\n")) || downloaded.Done != downloaded.Total {
+		t.Errorf("last download progress = %+v, want the whole transcript", downloaded)
+	}
+}
+
+func TestSSHTranscriber_Failures(t *testing.T) {
+	s := startSSHServer(t)
+	audio := writeAudio(t)
+
+	tests := []struct {
+		name  string
+		setup func(st *SSHTranscriber)
+	}{
+		{
+			name:  "command fails",
+			setup: func(st *SSHTranscriber) { st.cfg.Binary = "false" },
+		},
+		{
+			name:  "wrong password",
+			setup: func(st *SSHTranscriber) { st.client.Auth = []ssh.AuthMethod{ssh.Password("wrong")} },
+		},
+		{
+			name:  "host down",
+			setup: func(st *SSHTranscriber) { st.addr = "127.0.0.1:1" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, remoteDir := newTestTranscriber(t, s)
+			tt.setup(st)
+			_, _, err := st.TranscriptWithMetadata(audio)
+			if err == nil || !provider.IsRetryable(err) {
+				t.Errorf("TranscriptWithMetadata() error = %v, want a retryable error", err)
+			}
+			if left, _ := os.ReadDir(remoteDir); len(left) != 0 {
+				t.Errorf("remote dir keeps %d files after the failure, want none", len(left))
+			}
+		})
+	}
+}
+
+func TestPool_RedialsDeadConnection(t *testing.T) {
+	s := startSSHServer(t)
+	st, _ := newTestTranscriber(t, s)
+
+	first, err := st.pool.Get(st.addr, st.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Client.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st.pool.mu.Lock()
+		_, pooled := st.pool.conns[st.addr]
+		st.pool.mu.Unlock()
+		if !pooled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the closed connection is still pooled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, _, err = st.TranscriptWithMetadata(writeAudio(t)); err != nil {
+		t.Fatalf("TranscriptWithMetadata() error = %v", err)
+	}
+	if n := s.conns.Load(); n != 2 {
+		t.Errorf("connections = %d, want the dead one dialed again", n)
+	}
+}
+
+func TestSSHTranscriber_HealthCheck(t *testing.T) {
+	s := startSSHServer(t)
+	st, _ := newTestTranscriber(t, s)
+	if err := st.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck() = %v, want nil", err)
+	}
+	st.cfg.Model = filepath.Join(t.TempDir(), "missing.bin")
+	if err := st.HealthCheck(); err == nil {
+		t.Error("HealthCheck() = nil for a missing model")
+	}
+}
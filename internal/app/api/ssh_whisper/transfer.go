@@ -0,0 +1,93 @@
+package ssh_whisper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"tiktok-whisper/internal/app/cleanup"
+	"tiktok-whisper/internal/app/logging"
+	"time"
+)
+
+// Transfer is the progress of a file copied to or from the host.
+type Transfer struct {
+	// File is the remote path.
+	File string
+	// Upload is set for the audio sent to the host, unset for the transcript read back.
+	Upload bool
+	Done   int64
+	// Total is the size of the file, zero when it isn't known.
+	Total int64
+}
+
+// upload copies the local file to remote over SFTP, reporting the progress to st's handler.
+func (st *SSHTranscriber) upload(ctx context.Context, c *Conn, local string, remote string) error {
+	src, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := c.SFTP.Create(remote)
+	if err != nil {
+		return fmt.Errorf("create %s failed: %w", remote, err)
+	}
+	started := time.Now()
+	_, err = io.Copy(dst, st.progress(ctx, src, Transfer{File: remote, Upload: true, Total: info.Size()}))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("upload to %s failed: %w", remote, err)
+	}
+	logging.L().Debug("Uploaded audio", "provider", providerName, "file", remote,
+		"size", cleanup.FormatBytes(info.Size()), "elapsed", time.Since(started))
+	return nil
+}
+
+// download reads the remote file over SFTP, reporting the progress to st's handler.
+func (st *SSHTranscriber) download(ctx context.Context, c *Conn, remote string) ([]byte, error) {
+	src, err := c.SFTP.Open(remote)
+	if err != nil {
+		return nil, fmt.Errorf("open %s failed: %w", remote, err)
+	}
+	defer src.Close()
+	var total int64
+	if info, err := src.Stat(); err == nil {
+		total = info.Size()
+	}
+	data, err := io.ReadAll(st.progress(ctx, src, Transfer{File: remote, Total: total}))
+	if err != nil {
+		return nil, fmt.Errorf("download %s failed: %w", remote, err)
+	}
+	return data, nil
+}
+
+// progress wraps r so the bytes read are reported to st's handler and the copy stops once ctx is canceled.
+func (st *SSHTranscriber) progress(ctx context.Context, r io.Reader, t Transfer) io.Reader {
+	return &progressReader{ctx: ctx, r: r, transfer: t, onProgress: st.onProgress}
+}
+
+type progressReader struct {
+	ctx        context.Context
+	r          io.Reader
+	transfer   Transfer
+	onProgress func(Transfer)
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	if err := pr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := pr.r.Read(p)
+	pr.transfer.Done += int64(n)
+	if n > 0 && pr.onProgress != nil {
+		pr.onProgress(pr.transfer)
+	}
+	return n, err
+}
@@ -0,0 +1,170 @@
+// Package transcode converts the audio a provider doesn't accept into one of its formats before it
+// is sent. Inputs are probed with ffprobe, converted files are cached by the path, size and
+// modification time of their input, so retries and later runs don't convert them again.
+package transcode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/audio/ffmpeg"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+)
+
+// ffmpegTool probes and converts the audio, the ffmpeg binaries implement both.
+type ffmpegTool interface {
+	ffmpeg.Runner
+	ffmpeg.Prober
+}
+
+// Transcriber sends the inputs its inner transcriber accepts as they are and converts the others.
+// Inputs that can't be probed are sent as they are, the inner transcriber reports what's wrong with them.
+// Without ffmpeg only the inputs with an accepted extension are sent, the others fail.
+type Transcriber struct {
+	inner        api.Transcriber
+	formats      provider.Formats
+	dir          string
+	ffmpeg       ffmpegTool
+	capabilities *capability.Registry
+}
+
+// NewTranscriber converts the inputs of inner to formats, the converted files are cached in dir.
+func NewTranscriber(inner api.Transcriber, formats provider.Formats, dir string) *Transcriber {
+	return &Transcriber{inner: inner, formats: formats, dir: dir, ffmpeg: ffmpeg.New(), capabilities: capability.Default()}
+}
+
+// Wrap converts the inputs of inner to the formats p supports, p is the bare provider inner wraps.
+// The converted files are cached in DefaultDir.
+func Wrap(inner api.Transcriber, p provider.FormatTranscriber) api.Transcriber {
+	return NewTranscriber(inner, p.SupportedFormats(), DefaultDir())
+}
+
+// DefaultDir is the cache of the converted audio in the user cache directory.
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "v2t", "audio")
+}
+
+func (t *Transcriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := t.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (t *Transcriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return t.TranscriptContext(context.Background(), inputFilePath)
+}
+
+// TranscriptContext converts inputFilePath when needed and transcribes it with ctx.
+func (t *Transcriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	path, err := t.convert(ctx, inputFilePath)
+	if err != nil {
+		return "", model.ProviderMetadata{}, err
+	}
+	return provider.Transcribe(ctx, t.inner, path)
+}
+
+// TranscriptStream converts inputFilePath when needed and streams its segments.
+func (t *Transcriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	path, err := t.convert(context.Background(), inputFilePath)
+	if err != nil {
+		return "", model.ProviderMetadata{}, err
+	}
+	return provider.Stream(t.inner, path, func(s model.Segment) { partials <- s })
+}
+
+// HealthCheck runs the health check of the inner transcriber, it passes when that has none.
+func (t *Transcriber) HealthCheck() error {
+	if hc, ok := t.inner.(provider.HealthChecker); ok {
+		return hc.HealthCheck()
+	}
+	return nil
+}
+
+// convert returns inputFilePath when the inner transcriber accepts it, otherwise its converted copy
+// in the cache.
+func (t *Transcriber) convert(ctx context.Context, inputFilePath string) (string, error) {
+	info, err := t.ffmpeg.Probe(ctx, inputFilePath)
+	if err != nil {
+		if cerr := t.capabilities.Check(capability.FFmpeg); cerr != nil {
+			if !t.formats.AcceptsExtension(inputFilePath) {
+				return "", fmt.Errorf("%s needs converting to %s: %w", inputFilePath, t.formats.Containers[0], cerr)
+			}
+			t.capabilities.Warn(capability.FFmpeg, "sending the audio without converting it")
+			return inputFilePath, nil
+		}
+		logging.L().Warn("Error probing the audio, sending it as it is", "file", inputFilePath, "error", err)
+		return inputFilePath, nil
+	}
+	if t.formats.Accepts(inputFilePath, info) {
+		return inputFilePath, nil
+	}
+
+	cached, err := t.cachePath(inputFilePath)
+	if err != nil {
+		return "", err
+	}
+	if _, err = os.Stat(cached); err == nil {
+		logging.L().Debug("Using the converted audio of an earlier run", "file", inputFilePath, "path", cached)
+		return cached, nil
+	}
+
+	if err = os.MkdirAll(t.dir, 0755); err != nil {
+		return "", err
+	}
+	// converted next to the cached file and renamed, a failed run never leaves a partial file in the cache
+	partial := strings.TrimSuffix(cached, filepath.Ext(cached)) + ".partial" + filepath.Ext(cached)
+	logging.L().Info("Converting the audio to a format the provider accepts", "file", inputFilePath,
+		"codec", info.Codec, "sample_rate", info.SampleRate, "to", filepath.Ext(cached))
+	if err = t.ffmpeg.Run(ctx, t.args(inputFilePath, partial), info.Duration, nil); err != nil {
+		os.Remove(partial)
+		return "", fmt.Errorf("FFmpeg error: %v", err)
+	}
+	if err = os.Rename(partial, cached); err != nil {
+		os.Remove(partial)
+		return "", err
+	}
+	return cached, nil
+}
+
+// args are the arguments of ffmpeg converting input to the first container and codec of the formats.
+func (t *Transcriber) args(input string, output string) []string {
+	args := []string{"-i", input, "-vn"}
+	if len(t.formats.Codecs) > 0 {
+		args = append(args, "-acodec", t.formats.Codecs[0])
+	}
+	if t.formats.SampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(t.formats.SampleRate))
+	}
+	return append(args, "-y", output)
+}
+
+// cachePath returns where the conversion of inputFilePath is cached. The name changes with the input
+// and the target format, so a replaced input or a changed provider is converted again.
+func (t *Transcriber) cachePath(inputFilePath string) (string, error) {
+	abs, err := filepath.Abs(inputFilePath)
+	if err != nil {
+		return "", err
+	}
+	stat, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s\x00%d\x00%d\x00%s\x00%v\x00%d", abs, stat.Size(), stat.ModTime().UnixNano(),
+		t.formats.Containers[0], t.formats.Codecs, t.formats.SampleRate)
+	sum := sha256.Sum256([]byte(key))
+	name := strings.TrimSuffix(filepath.Base(abs), filepath.Ext(abs))
+	return filepath.Join(t.dir, name+"_"+hex.EncodeToString(sum[:])[:16]+"."+t.formats.Containers[0]), nil
+}
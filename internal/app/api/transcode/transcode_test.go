@@ -0,0 +1,141 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/audio/ffmpeg"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/testutil"
+	"time"
+)
+
+// fakeFFmpeg probes every file as info and writes the output of every run.
+type fakeFFmpeg struct {
+	info     ffmpeg.Info
+	probeErr error
+	runErr   error
+	runs     [][]string
+}
+
+func (f *fakeFFmpeg) Probe(ctx context.Context, path string) (ffmpeg.Info, error) {
+	return f.info, f.probeErr
+}
+
+func (f *fakeFFmpeg) Run(ctx context.Context, args []string, total time.Duration, onProgress ffmpeg.ProgressFunc) error {
+	f.runs = append(f.runs, args)
+	os.WriteFile(args[len(args)-1], []byte("converted"), 0644)
+	return f.runErr
+}
+
+func (f *fakeFFmpeg) Duration(ctx context.Context, path string) (time.Duration, error) {
+	return f.info.Duration, nil
+}
+
+// pathTranscriber returns the path it was given as the text.
+type pathTranscriber struct{}
+
+func (pathTranscriber) Transcript(inputFilePath string) (string, error) {
+	return inputFilePath, nil
+}
+
+// ffmpegCapability is a registry in which ffmpeg is available or not.
+func ffmpegCapability(available bool) *capability.Registry {
+	r := capability.NewRegistry()
+	r.Register(capability.FFmpeg, "install ffmpeg", func() error {
+		if !available {
+			return errors.New(`exec: "ffprobe": executable file not found in $PATH`)
+		}
+		return nil
+	})
+	return r
+}
+
+func TestTranscriber(t *testing.T) {
+	testutil.UseMockLogger(t)
+	wav := provider.Formats{Containers: []string{"wav"}, Codecs: []string{"pcm_s16le"}, SampleRate: 16000}
+	ogg := ffmpeg.Info{Formats: []string{"ogg"}, Codec: "opus", SampleRate: 48000}
+	tests := []struct {
+		name          string
+		file          string
+		ffmpeg        *fakeFFmpeg
+		noFFmpeg      bool
+		wantConverted bool
+		wantErr       bool
+	}{
+		{name: "accepted as it is", file: "talk.wav", ffmpeg: &fakeFFmpeg{info: ffmpeg.Info{Formats: []string{"wav"}, Codec: "pcm_s16le", SampleRate: 16000}}},
+		{name: "converted", file: "talk.ogg", ffmpeg: &fakeFFmpeg{info: ogg}, wantConverted: true},
+		{name: "sent as it is when probing fails", file: "talk.ogg", ffmpeg: &fakeFFmpeg{probeErr: errors.New("exit status 1")}},
+		{name: "accepted extension sent as it is without ffmpeg", file: "talk.wav", ffmpeg: &fakeFFmpeg{probeErr: errors.New("not found")}, noFFmpeg: true},
+		{name: "fails without ffmpeg when it needs converting", file: "talk.ogg", ffmpeg: &fakeFFmpeg{probeErr: errors.New("not found")}, noFFmpeg: true, wantErr: true},
+		{name: "conversion fails", file: "talk.ogg", ffmpeg: &fakeFFmpeg{info: ogg, runErr: errors.New("exit status 1")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, tt.file)
+			os.WriteFile(input, []byte("audio"), 0644)
+			cache := filepath.Join(dir, "cache")
+			tr := NewTranscriber(pathTranscriber{}, wav, cache)
+			tr.ffmpeg = tt.ffmpeg
+			tr.capabilities = ffmpegCapability(!tt.noFFmpeg)
+
+			got, err := tr.Transcript(input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transcript() error = %v, want an error %v", err, tt.wantErr)
+			}
+			converted := strings.HasPrefix(got, cache) && strings.HasSuffix(got, ".wav")
+			if converted != tt.wantConverted || (!tt.wantConverted && !tt.wantErr && got != input) {
+				t.Errorf("transcribed %q, want it converted %v", got, tt.wantConverted)
+			}
+			if tt.noFFmpeg && tt.wantErr && !errors.Is(err, capability.ErrUnavailable) {
+				t.Errorf("Transcript() error = %v, want the unavailable capability", err)
+			}
+			if tt.wantErr {
+				if entries, _ := os.ReadDir(cache); len(entries) != 0 {
+					t.Errorf("cache holds %d files after a failed conversion, want none", len(entries))
+				}
+			}
+		})
+	}
+}
+
+func TestTranscriber_CachesConversions(t *testing.T) {
+	testutil.UseMockLogger(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "talk.ogg")
+	os.WriteFile(input, []byte("audio"), 0644)
+	f := &fakeFFmpeg{info: ffmpeg.Info{Formats: []string{"ogg"}, Codec: "opus"}}
+	tr := NewTranscriber(pathTranscriber{}, provider.Formats{Containers: []string{"mp3"}}, filepath.Join(dir, "cache"))
+	tr.ffmpeg = f
+
+	first, _, err := tr.TranscriptWithMetadata(input)
+	if err != nil {
+		t.Fatalf("TranscriptWithMetadata() error = %v", err)
+	}
+	second, _, _ := tr.TranscriptWithMetadata(input)
+	if first != second || len(f.runs) != 1 {
+		t.Errorf("transcribed %q then %q after %d conversions, want the cached conversion", first, second, len(f.runs))
+	}
+
+	// a replaced input is converted again
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(input, later, later)
+	if third, _, _ := tr.TranscriptWithMetadata(input); third == first || len(f.runs) != 2 {
+		t.Errorf("transcribed %q after %d conversions, want a new conversion", third, len(f.runs))
+	}
+
+	partials := make(chan model.Segment)
+	go func() {
+		for range partials {
+		}
+	}()
+	if _, _, err = tr.TranscriptStream(input, partials); err != nil || len(f.runs) != 2 {
+		t.Errorf("TranscriptStream() error = %v after %d conversions, want the cached conversion", err, len(f.runs))
+	}
+}
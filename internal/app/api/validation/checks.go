@@ -0,0 +1,177 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Result is a transcription as seen by the checks.
+type Result struct {
+	Text string
+	// Language the provider was asked to transcribe, checks depending on it are skipped when it is empty.
+	Language string
+	// DurationSeconds of the audio, zero when it is unknown.
+	DurationSeconds float64
+}
+
+// Check returns a description of what is wrong with r, or "" when it looks fine.
+type Check func(r Result) string
+
+// DefaultChecks are the checks used when none are given.
+var DefaultChecks = []Check{EmptyText, LanguageMismatch, Repetition}
+
+const (
+	// minCheckedSeconds is the shortest audio expected to hold speech, shorter clips may be silent.
+	minCheckedSeconds = 30
+	// secondsPerCharacter is the longest stretch of audio a single character of text may stand for.
+	secondsPerCharacter = 10
+	// minLetters is the least text the language of a result is judged on.
+	minLetters = 20
+	// minScriptShare of the letters must be in the script of the requested language.
+	minScriptShare = 0.5
+	// maxPeriod is the longest phrase, in tokens, looked for in repetition loops.
+	maxPeriod = 50
+	// minRepeats of a phrase in a row make a loop when they span at least minLoopTokens.
+	minRepeats    = 4
+	minLoopTokens = 12
+)
+
+// EmptyText flags long audio that produced no or almost no text, typically a decoder that gave up.
+func EmptyText(r Result) string {
+	if r.DurationSeconds < minCheckedSeconds {
+		return ""
+	}
+
+	chars := 0
+	for _, c := range r.Text {
+		if !unicode.IsSpace(c) {
+			chars++
+		}
+	}
+	if float64(chars) >= r.DurationSeconds/secondsPerCharacter {
+		return ""
+	}
+	return fmt.Sprintf("empty_text: %d characters for %.0fs of audio", chars, r.DurationSeconds)
+}
+
+// scripts maps a language to the scripts its text is written in.
+var scripts = map[string][]*unicode.RangeTable{
+	"zh": {unicode.Han},
+	"ja": {unicode.Han, unicode.Hiragana, unicode.Katakana},
+	"ko": {unicode.Hangul, unicode.Han},
+	"ru": {unicode.Cyrillic},
+	"uk": {unicode.Cyrillic},
+	"en": {unicode.Latin},
+	"fr": {unicode.Latin},
+	"de": {unicode.Latin},
+	"es": {unicode.Latin},
+	"it": {unicode.Latin},
+	"pt": {unicode.Latin},
+	"nl": {unicode.Latin},
+}
+
+// LanguageMismatch flags text mostly written in another script than the requested language,
+// e.g. whisper translating Chinese audio to English. Languages it doesn't know are not checked.
+func LanguageMismatch(r Result) string {
+	lang := strings.ToLower(r.Language)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	tables, ok := scripts[lang]
+	if !ok {
+		return ""
+	}
+
+	letters, matching := 0, 0
+	for _, c := range r.Text {
+		if !unicode.IsLetter(c) {
+			continue
+		}
+		letters++
+		if unicode.In(c, tables...) {
+			matching++
+		}
+	}
+	if letters < minLetters {
+		return ""
+	}
+
+	share := float64(matching) / float64(letters)
+	if share >= minScriptShare {
+		return ""
+	}
+	return fmt.Sprintf("language_mismatch: requested %s, %.0f%% of the letters are in its script", r.Language, share*100)
+}
+
+// Repetition flags a phrase repeated over and over, the hallucination loop whisper falls
+// into on silence or music. Short repetitions such as "no, no, no" are normal speech.
+func Repetition(r Result) string {
+	tokens := tokenize(r.Text)
+	for period := 1; period <= maxPeriod && 2*period <= len(tokens); period++ {
+		run := 0
+		for i := 0; i+period < len(tokens); i++ {
+			if tokens[i] != tokens[i+period] {
+				run = 0
+				continue
+			}
+			run++
+			repeats := run/period + 1
+			if repeats >= minRepeats && repeats*period >= minLoopTokens {
+				start := i - run + 1
+				return fmt.Sprintf("repetition: %q repeated %d times", join(tokens[start:start+period]), repeats)
+			}
+		}
+	}
+	return ""
+}
+
+// tokenize splits text into words, with every character of scripts written without spaces
+// as a token of its own. Punctuation and spaces are dropped so they don't hide a loop.
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+
+	for _, c := range text {
+		switch {
+		case unspaced(c):
+			flush()
+			tokens = append(tokens, string(c))
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '\'':
+			word = append(word, c)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// unspaced reports whether c belongs to a script written without spaces between words.
+func unspaced(c rune) bool {
+	return unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// join puts tokens back together, with spaces between words.
+func join(tokens []string) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		if i > 0 && !(unspacedToken(t) && unspacedToken(tokens[i-1])) {
+			b.WriteString(" ")
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+func unspacedToken(t string) bool {
+	r, size := utf8.DecodeRuneInString(t)
+	return size == len(t) && unspaced(r)
+}
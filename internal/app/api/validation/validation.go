@@ -0,0 +1,128 @@
+// Package validation checks transcriptions for the typical failures of whisper models, such as empty
+// text on long audio, text in another language than requested and hallucinated repetition loops.
+package validation
+
+import (
+	"context"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/audio"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+)
+
+// RetryOptions are the stricter decoding parameters suspicious results are re-run with.
+var RetryOptions = api.StrictOptions
+
+// Transcriber runs the checks on every result of its inner transcriber and records the verdict as
+// Validation in the provider metadata. With retry, results that fail a check are transcribed again
+// with RetryOptions when the inner transcriber takes options, the result with fewer issues is kept.
+type Transcriber struct {
+	inner  api.Transcriber
+	retry  bool
+	checks []Check
+
+	duration func(filePath string) (int, error)
+}
+
+// NewTranscriber creates a new Transcriber instance, DefaultChecks are used when no checks are given.
+func NewTranscriber(inner api.Transcriber, retry bool, checks ...Check) *Transcriber {
+	if len(checks) == 0 {
+		checks = DefaultChecks
+	}
+	return &Transcriber{
+		inner:    inner,
+		retry:    retry,
+		checks:   checks,
+		duration: audio.GetAudioDuration,
+	}
+}
+
+// Wrap validates the results of inner as configured, inner is returned as is when validation is disabled.
+func Wrap(inner api.Transcriber, cfg config.ValidationConfig) api.Transcriber {
+	if !cfg.Enabled {
+		return inner
+	}
+	return NewTranscriber(inner, cfg.Retry)
+}
+
+func (t *Transcriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := t.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata transcribes and validates inputFilePath.
+func (t *Transcriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return t.TranscriptContext(context.Background(), inputFilePath)
+}
+
+// TranscriptContext works like TranscriptWithMetadata and hands ctx to the inner transcriber, a retry
+// keeps the options ctx carries.
+func (t *Transcriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	text, metadata, err := provider.Transcribe(ctx, t.inner, inputFilePath)
+	if err != nil {
+		return text, metadata, err
+	}
+	return t.validate(ctx, inputFilePath, text, metadata)
+}
+
+// TranscriptStream streams the first attempt, a retry is only reported through the returned text.
+func (t *Transcriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	text, metadata, err := provider.Stream(t.inner, inputFilePath, func(s model.Segment) { partials <- s })
+	if err != nil {
+		return text, metadata, err
+	}
+	return t.validate(context.Background(), inputFilePath, text, metadata)
+}
+
+func (t *Transcriber) validate(ctx context.Context, inputFilePath string, text string, metadata model.ProviderMetadata) (string, model.ProviderMetadata, error) {
+	duration := metadata.DurationSeconds
+	if duration == 0 {
+		// whisper.cpp derives the duration from its segments, there are none for empty text
+		if seconds, err := t.duration(inputFilePath); err == nil {
+			duration = float64(seconds)
+		}
+	}
+
+	issues := t.check(text, metadata, duration)
+	verdict := &model.ValidationMetadata{Passed: len(issues) == 0, Issues: issues}
+
+	ot, ok := t.inner.(api.OptionsTranscriber)
+	if len(issues) > 0 && t.retry && ok {
+		logging.L().Warn("Transcription looks wrong, retrying with stricter settings", "file", inputFilePath, "issues", issues)
+		retryText, retryMetadata, err := ot.TranscriptWithOptions(inputFilePath, RetryOptions.Merge(api.OptionsFrom(ctx)))
+		if err != nil {
+			logging.L().Warn("Retry failed, keeping the first result", "file", inputFilePath, "error", err)
+		} else if retryIssues := t.check(retryText, retryMetadata, duration); len(retryIssues) < len(issues) {
+			text, metadata, issues = retryText, retryMetadata, retryIssues
+		}
+		verdict = &model.ValidationMetadata{Passed: len(issues) == 0, Issues: issues, Retried: true}
+	}
+
+	if !verdict.Passed {
+		logging.L().Warn("Transcription failed validation", "file", inputFilePath, "issues", issues)
+	}
+	metadata.Validation = verdict
+	return text, metadata, nil
+}
+
+func (t *Transcriber) check(text string, metadata model.ProviderMetadata, duration float64) []string {
+	r := Result{Text: text, Language: metadata.Language, DurationSeconds: duration}
+	var issues []string
+	for _, c := range t.checks {
+		if issue := c(r); issue != "" {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// HealthCheck runs the health check of the inner transcriber, it passes when that has none.
+func (t *Transcriber) HealthCheck() error {
+	if hc, ok := t.inner.(provider.HealthChecker); ok {
+		return hc.HealthCheck()
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name      string
+		check     Check
+		result    Result
+		wantIssue string
+	}{
+		{name: "empty long audio", check: EmptyText, result: Result{Text: " ", DurationSeconds: 120}, wantIssue: "empty_text"},
+		{name: "sparse long audio", check: EmptyText, result: Result{Text: "嗯", DurationSeconds: 120}, wantIssue: "empty_text"},
+		{name: "empty short clip", check: EmptyText, result: Result{DurationSeconds: 5}},
+		{name: "speech", check: EmptyText, result: Result{Text: "今天我们聊一聊播客", DurationSeconds: 60}},
+		{name: "english for zh", check: LanguageMismatch, result: Result{Text: "Today we are going to talk about podcasts", Language: "zh"}, wantIssue: "language_mismatch"},
+		{name: "zh with english terms", check: LanguageMismatch, result: Result{Text: "今天我们聊一聊怎么用 ChatGPT 做播客的后期剪辑和字幕", Language: "zh-CN"}},
+		{name: "unknown language", check: LanguageMismatch, result: Result{Text: "Today we are going to talk about podcasts", Language: "sw"}},
+		{name: "too little text", check: LanguageMismatch, result: Result{Text: "OK", Language: "zh"}},
+		{name: "zh loop", check: Repetition, result: Result{Text: "谢谢观看。谢谢观看。谢谢观看。谢谢观看。"}, wantIssue: `"谢谢观看" repeated 4 times`},
+		{name: "en loop", check: Repetition, result: Result{Text: "Thank you for watching. Thank you for watching! Thank you for watching. Thank you for watching."}, wantIssue: `"thank you for watching"`},
+		{name: "single char loop", check: Repetition, result: Result{Text: strings.Repeat("啊", 20)}, wantIssue: `"啊"`},
+		{name: "natural repetition", check: Repetition, result: Result{Text: "No, no, no, that's not what I meant. 哈哈哈哈"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.check(tt.result)
+			if tt.wantIssue == "" && got != "" || !strings.Contains(got, tt.wantIssue) {
+				t.Errorf("check = %q, want %q", got, tt.wantIssue)
+			}
+		})
+	}
+}
+
+// fakeTranscriber returns text, or retryText when called with options.
+type fakeTranscriber struct {
+	text      string
+	retryText string
+	retries   []api.Options
+}
+
+func (f *fakeTranscriber) Transcript(inputFilePath string) (string, error) {
+	return f.text, nil
+}
+
+func (f *fakeTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return f.text, model.ProviderMetadata{Provider: "fake", Language: "zh", DurationSeconds: 60}, nil
+}
+
+func (f *fakeTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return f.TranscriptWithMetadata(inputFilePath)
+}
+
+func (f *fakeTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	f.retries = append(f.retries, opts)
+	return f.retryText, model.ProviderMetadata{Provider: "fake", Language: "zh", DurationSeconds: 60}, nil
+}
+
+func TestTranscriber_TranscriptWithMetadata(t *testing.T) {
+	loop := strings.Repeat("谢谢观看。", 10)
+	good := "今天我们聊一聊播客的后期制作"
+
+	tests := []struct {
+		name        string
+		retry       bool
+		text        string
+		retryText   string
+		wantText    string
+		wantVerdict model.ValidationMetadata
+		wantRetries int
+	}{
+		{name: "passes", retry: true, text: good, wantText: good, wantVerdict: model.ValidationMetadata{Passed: true}},
+		{name: "flagged only", text: loop, wantText: loop, wantVerdict: model.ValidationMetadata{Issues: []string{"x"}}},
+		{name: "fixed by retry", retry: true, text: loop, retryText: good, wantText: good, wantVerdict: model.ValidationMetadata{Passed: true, Retried: true}, wantRetries: 1},
+		{name: "retry no better", retry: true, text: loop, retryText: loop, wantText: loop, wantVerdict: model.ValidationMetadata{Issues: []string{"x"}, Retried: true}, wantRetries: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &fakeTranscriber{text: tt.text, retryText: tt.retryText}
+			tr := NewTranscriber(inner, tt.retry)
+
+			text, metadata, err := tr.TranscriptWithMetadata("a.mp3")
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := metadata.Validation
+			if text != tt.wantText || v == nil || v.Passed != tt.wantVerdict.Passed || v.Retried != tt.wantVerdict.Retried || len(v.Issues) != len(tt.wantVerdict.Issues) {
+				t.Errorf("TranscriptWithMetadata() = %q, %+v", text, v)
+			}
+			if len(inner.retries) != tt.wantRetries || tt.wantRetries > 0 && inner.retries[0] != RetryOptions {
+				t.Errorf("retried with %+v, want %d retries with %+v", inner.retries, tt.wantRetries, RetryOptions)
+			}
+		})
+	}
+}
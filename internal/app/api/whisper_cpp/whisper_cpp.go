@@ -2,18 +2,62 @@ package whisper_cpp
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
 	"tiktok-whisper/internal/app/audio"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/cleanup"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/tracing"
 	"tiktok-whisper/internal/app/util/files"
+	"tiktok-whisper/internal/app/vocab"
+	"time"
 )
 
+const (
+	providerName    = "whisper_cpp"
+	defaultLanguage = "zh"
+)
+
+// prompts are the initial prompts per language, they steer whisper.cpp towards the script, e.g. simplified Chinese.
+var prompts = map[string]string{
+	"zh": "以下是简体中文普通话:",
+}
+
+// detectedLanguageRegexp matches the language whisper.cpp reports with --detect-language,
+// e.g. "whisper_full_with_state: auto-detected language: en (p = 0.976563)"
+var detectedLanguageRegexp = regexp.MustCompile(`auto-detected language: ([a-z]+)`)
+
+// segmentLineRegexp matches the segments whisper.cpp prints to stdout, e.g. "[00:00:00.000 --> 00:00:11.000]  text"
+var segmentLineRegexp = regexp.MustCompile(`^\[(\d{2}):(\d{2}):(\d{2})\.(\d{3}) --> (\d{2}):(\d{2}):(\d{2})\.(\d{3})\]`)
+
+// colorRegexp matches the ANSI color escapes of --print-colors.
+var colorRegexp = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
 // LocalTranscriber implements local transcription, using local binary commands.
 type LocalTranscriber struct {
 	binaryPath string
 	modelPath  string
+	language   string
+	request    *model.RequestMetadata
+	decoding   config.DecodingConfig
+	// verbatim keeps fillers and stutters, see config.VerbatimConfig
+	verbatim bool
+	// vocabulary are the installed vocabulary packs, their terms bias the prompt
+	vocabulary vocab.Packs
 }
 
 // NewLocalTranscriber creates a new instance of LocalTranscriber.
@@ -21,65 +65,388 @@ func NewLocalTranscriber(binaryPath, modelPath string) *LocalTranscriber {
 	return &LocalTranscriber{
 		binaryPath: binaryPath,
 		modelPath:  modelPath,
+		language:   defaultLanguage,
+		request:    config.GetProviders().For(providerName).Record(),
+		decoding:   config.GetProviders().For(providerName).Decoding,
+		verbatim:   config.Get().Verbatim.Default,
+		vocabulary: vocab.Installed(),
 	}
 }
 
+// SetLanguage sets the language whisper.cpp transcribes, zh unless set.
+func (lt *LocalTranscriber) SetLanguage(language string) {
+	lt.language = language
+}
+
+func (lt *LocalTranscriber) lang() string {
+	if lt.language == "" {
+		return defaultLanguage
+	}
+	return lt.language
+}
+
 // Transcript encapsulates native binary commands, takes the MP3 file path as input and returns the transcribed text and errors (if any).
 func (lt *LocalTranscriber) Transcript(inputFilePath string) (string, error) {
-	log.Printf("Starting transcription of file %s\n", inputFilePath)
+	text, _, err := lt.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
 
-	// Check if the input file is a 16kHz WAV file
-	is16kHzWav, err := audio.Is16kHzWavFile(inputFilePath)
-	if err != nil {
-		log.Printf("Error checking if input file is a 16kHz WAV file: %v\n", err)
-		return "", fmt.Errorf("error checking input file: %v", err)
+// TranscriptWithMetadata works like Transcript and also reports the model, language and segments whisper.cpp produced.
+func (lt *LocalTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return lt.TranscriptWithPrompt(inputFilePath, "")
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata, previousText is appended to the
+// language prompt so whisper.cpp continues in the same context.
+func (lt *LocalTranscriber) TranscriptWithPrompt(inputFilePath string, previousText string) (string, model.ProviderMetadata, error) {
+	return lt.transcribe(context.Background(), inputFilePath, api.Options{Prompt: previousText}, nil)
+}
+
+// TranscriptWithOptions works like TranscriptWithPrompt with opts.Prompt as previousText, NoContext
+// limits whisper.cpp's text context to zero and a Temperature above zero is passed on.
+func (lt *LocalTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return lt.transcribe(context.Background(), inputFilePath, opts, nil)
+}
+
+// TranscriptContext works like TranscriptWithOptions with the options ctx carries, whisper.cpp is
+// killed once ctx is canceled.
+func (lt *LocalTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return lt.transcribe(ctx, inputFilePath, api.OptionsFrom(ctx), nil)
+}
+
+// TranscriptStream works like TranscriptWithMetadata and sends each segment as soon as whisper.cpp prints it.
+func (lt *LocalTranscriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	return lt.transcribe(context.Background(), inputFilePath, api.Options{}, partials)
+}
+
+// transcribe runs whisper.cpp, the segments it prints are sent to partials when it is not nil.
+func (lt *LocalTranscriber) transcribe(ctx context.Context, inputFilePath string, opts api.Options, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	logger := logging.L().With("provider", providerName)
+	opts = opts.Merge(api.Options{
+		NoContext:         lt.decoding.NoContext(),
+		NoSpeechThreshold: lt.decoding.NoSpeechThreshold,
+		VAD:               lt.decoding.VAD,
+	})
+	if lt.verbatim {
+		opts = opts.Verbatim()
+	}
+	if opts.VAD && lt.decoding.VADModel == "" {
+		logger.Warn("VAD needs decoding.vad_model in providers.yaml, transcribing without it")
+		opts.VAD = false
+	}
+
+	language := lt.lang()
+	initialPrompt := LanguagePrompt(language) + lt.vocabulary.Prompt(language)
+	if lt.verbatim {
+		initialPrompt += api.VerbatimPrompt(language)
+	}
+	if !opts.NoContext {
+		initialPrompt += opts.Prompt
 	}
+	metadata := model.ProviderMetadata{
+		Provider: providerName,
+		Model:    modelName(lt.modelPath),
+		Language: language,
+		Request:  lt.request,
+		Verbatim: lt.verbatim,
+		WhisperCpp: &model.WhisperCppMetadata{
+			BinaryPath: lt.binaryPath,
+			ModelPath:  lt.modelPath,
+			Prompt:     initialPrompt,
 
-	// Convert the input file to a 16kHz WAV file if necessary
-	if !is16kHzWav {
-		log.Printf("Input file is not a 16kHz WAV file, converting...\n")
-		inputFilePath, err = audio.ConvertTo16kHzWav(inputFilePath)
+			NoContext:         opts.NoContext,
+			Temperature:       opts.Temperature,
+			NoSpeechThreshold: opts.NoSpeechThreshold,
+			VAD:               opts.VAD,
+			Translate:         opts.Translate,
+			WordThreshold:     opts.WordThreshold,
+			MaxSegmentLength:  opts.MaxSegmentLength,
+		},
+	}
+
+	logger.Info("Starting transcription", "file", inputFilePath)
+
+	// Every intermediate file of this transcription is tracked, so it is removed
+	// whatever the outcome and swept on the next run if the process dies.
+	job := inputFilePath
+	tracker := cleanup.Default()
+	defer func() {
+		reclaimed, err := tracker.Release(job)
 		if err != nil {
-			log.Printf("Error converting input file to a 16kHz WAV file: %v\n", err)
-			return "", fmt.Errorf("error converting input file: %v", err)
+			logger.Error("Error removing temp files", "job", job, "error", err)
+			return
 		}
-		log.Printf("Successfully converted input file to a 16kHz WAV file\n")
-	}
+		logger.Debug("Removed temp files", "job", job, "reclaimed", cleanup.FormatBytes(reclaimed))
+	}()
+	tempPrefix := tempFilePrefix(tracker.Dir(), inputFilePath)
 
-	outputFile := "./1"
+	inputFilePath, err := toWav(tracker, job, tempPrefix, inputFilePath)
+	if err != nil {
+		return "", metadata, err
+	}
 
-	args := []string{
-		"-m", lt.modelPath,
-		"--print-colors",
-		"-l", "zh",
-		"--prompt", "以下是简体中文普通话:",
-		"-otxt",
-		"-f", inputFilePath,
-		"-of", outputFile,
+	outputFile := tempPrefix
+	if err = tracker.Track(job, outputFile+".txt"); err != nil {
+		return "", metadata, fmt.Errorf("error tracking temp file: %v", err)
 	}
 
-	command := exec.Command(lt.binaryPath, args...)
+	args := Args(lt.modelPath, language, initialPrompt, inputFilePath, outputFile, opts, lt.decoding.VADModel)
+
+	ctx, span := tracing.Start(ctx, "whisper_cpp",
+		tracing.String("v2t.model", metadata.Model), tracing.String("v2t.language", language))
+	defer span.End()
+	command := exec.CommandContext(ctx, lt.binaryPath, args...)
 	var stdout, stderr bytes.Buffer
 	command.Stdout = &stdout
 	command.Stderr = &stderr
+	var segments *segmentWriter
+	if partials != nil {
+		segments = &segmentWriter{partials: partials}
+		command.Stdout = io.MultiWriter(&stdout, segments)
+	}
 
-	log.Printf("Running transcription command...\n command: %s %s", lt.binaryPath, strings.Join(args, " "))
+	logger.Debug("Running transcription command", "command", lt.binaryPath+" "+strings.Join(args, " "))
 
 	err = command.Run()
+	if segments != nil {
+		segments.flush()
+	}
 	if err != nil {
-		log.Printf("Error running transcription command: %v\n", err)
-		return "", fmt.Errorf("command execution error: %v, stderr: %s", err, stderr.String())
+		span.RecordError(err)
+		logger.Error("Error running transcription command", "error", err, "stderr", stderr.String())
+		// The binary failing is not about the audio, which converted fine, another provider may succeed
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("command execution error: %v, stderr: %s", err, stderr.String()))
 	}
 
-	log.Printf("Successfully ran transcription command\n")
+	logger.Debug("Successfully ran transcription command")
+
+	metadata.Segments = Segments(stdout.String())
+	metadata.SegmentCount, metadata.DurationSeconds = parseSegments(stdout.String())
+	span.SetAttributes(tracing.Int("v2t.segments", metadata.SegmentCount))
 
 	output, err := files.ReadOutputFile(outputFile + ".txt")
 	if err != nil {
-		log.Printf("Error reading output file: %v\n", err)
-		return "", fmt.Errorf("failed to read output file: %v", err)
+		logger.Error("Error reading output file", "error", err)
+		return "", metadata, fmt.Errorf("failed to read output file: %v", err)
+	}
+
+	return output, metadata, nil
+}
+
+// LanguagePrompt is the initial prompt steering whisper.cpp towards the script of language, empty for most languages.
+func LanguagePrompt(language string) string {
+	return prompts[language]
+}
+
+// Args are the arguments of a whisper.cpp run transcribing the 16kHz WAV input with opts to
+// outputPrefix.txt, vadModel is used when opts.VAD is set.
+func Args(modelPath, language, prompt, input, outputPrefix string, opts api.Options, vadModel string) []string {
+	args := []string{
+		"-m", modelPath,
+		"--print-colors",
+		"-l", language,
+		"--prompt", prompt,
+		"-otxt",
+		"-f", input,
+		"-of", outputPrefix,
+	}
+	if opts.NoContext {
+		args = append(args, "--max-context", "0")
+	}
+	if opts.Temperature > 0 {
+		args = append(args, "--temperature", strconv.FormatFloat(float64(opts.Temperature), 'f', 2, 32))
+	}
+	if opts.NoSpeechThreshold > 0 {
+		args = append(args, "--no-speech-thold", strconv.FormatFloat(float64(opts.NoSpeechThreshold), 'f', 2, 32))
+	}
+	if opts.VAD {
+		args = append(args, "--vad", "--vad-model", vadModel)
+	}
+	if opts.Translate {
+		args = append(args, "--translate")
+	}
+	if opts.WordThreshold > 0 {
+		args = append(args, "--word-thold", strconv.FormatFloat(float64(opts.WordThreshold), 'f', 2, 32))
+	}
+	if opts.MaxSegmentLength > 0 {
+		// split at words rather than in the middle of one
+		args = append(args, "--max-len", strconv.Itoa(opts.MaxSegmentLength), "--split-on-word")
+	}
+	return args
+}
+
+// toWav returns inputFilePath when it is a 16kHz WAV file as whisper.cpp needs, or converts it
+// to a temp file next to tempPrefix tracked for job.
+func toWav(tracker *cleanup.Tracker, job string, tempPrefix string, inputFilePath string) (string, error) {
+	is16kHzWav, err := audio.Is16kHzWavFile(inputFilePath)
+	if err != nil && !capability.Available(capability.FFmpeg) {
+		// without ffprobe a WAV file is sent as it is, whisper.cpp rejects it when it isn't 16kHz
+		if strings.EqualFold(filepath.Ext(inputFilePath), ".wav") {
+			capability.Warn(capability.FFmpeg, "sending WAV files to whisper.cpp without checking their sample rate")
+			return inputFilePath, nil
+		}
+		return "", fmt.Errorf("%s needs converting to 16kHz WAV: %w", inputFilePath, capability.Require(capability.FFmpeg))
+	}
+	if err != nil {
+		logging.L().Error("Error checking if input file is a 16kHz WAV file", "file", inputFilePath, "error", err)
+		return "", fmt.Errorf("error checking input file: %v", err)
+	}
+	if is16kHzWav {
+		return inputFilePath, nil
+	}
+
+	logging.L().Debug("Input file is not a 16kHz WAV file, converting", "file", inputFilePath)
+	wavFilePath := tempPrefix + "_16khz.wav"
+	if err = tracker.Track(job, wavFilePath); err != nil {
+		return "", fmt.Errorf("error tracking temp file: %v", err)
+	}
+	if err = audio.ConvertTo16kHzWavAt(inputFilePath, wavFilePath); err != nil {
+		logging.L().Error("Error converting input file to a 16kHz WAV file", "file", inputFilePath, "error", err)
+		return "", fmt.Errorf("error converting input file: %v", err)
+	}
+	return wavFilePath, nil
+}
+
+// DetectLanguage returns the language whisper.cpp detects in the first 30 seconds of the audio,
+// a tiny model is enough for it and takes a fraction of a transcription.
+func (lt *LocalTranscriber) DetectLanguage(inputFilePath string) (string, error) {
+	job := inputFilePath + "#language"
+	tracker := cleanup.Default()
+	defer func() {
+		if _, err := tracker.Release(job); err != nil {
+			logging.L().Error("Error removing temp files", "provider", providerName, "job", job, "error", err)
+		}
+	}()
+
+	wavFilePath, err := toWav(tracker, job, tempFilePrefix(tracker.Dir(), job), inputFilePath)
+	if err != nil {
+		return "", err
+	}
+
+	command := exec.Command(lt.binaryPath, "-m", lt.modelPath, "-l", "auto", "--detect-language", "-f", wavFilePath)
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return "", provider.NewTranscriptionError(providerName, true, fmt.Errorf("command execution error: %v, output: %s", err, output))
 	}
+	return parseDetectedLanguage(string(output))
+}
 
-	log.Printf("Successfully read output file\n")
+// parseDetectedLanguage reads the language whisper.cpp printed with --detect-language.
+func parseDetectedLanguage(output string) (string, error) {
+	m := detectedLanguageRegexp.FindStringSubmatch(output)
+	if m == nil {
+		return "", fmt.Errorf("whisper.cpp reported no language")
+	}
+	return m[1], nil
+}
+
+// segmentWriter parses the segment lines whisper.cpp prints while it runs and sends them to partials.
+type segmentWriter struct {
+	partials chan<- model.Segment
+	line     []byte
+}
+
+func (w *segmentWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		w.send(string(w.line[:i]))
+		w.line = w.line[i+1:]
+	}
+	return len(p), nil
+}
 
-	return output, nil
+// flush sends the last line when the output doesn't end with a newline.
+func (w *segmentWriter) flush() {
+	w.send(string(w.line))
+	w.line = nil
+}
+
+func (w *segmentWriter) send(line string) {
+	if s, ok := parseSegmentLine(line); ok {
+		w.partials <- s
+	}
+}
+
+// parseSegmentLine reads a segment line of whisper.cpp's stdout, the colors of --print-colors are dropped.
+func parseSegmentLine(line string) (model.Segment, bool) {
+	line = strings.TrimSpace(colorRegexp.ReplaceAllString(line, ""))
+	m := segmentLineRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return model.Segment{}, false
+	}
+
+	return model.Segment{
+		Start: parseTimestamp(m[1:5]).Seconds(),
+		End:   parseTimestamp(m[5:9]).Seconds(),
+		Text:  strings.TrimSpace(line[len(m[0]):]),
+	}, true
+}
+
+// tempFilePrefix derives a per input file prefix in dir, so parallel transcriptions don't share temp files.
+func tempFilePrefix(dir string, inputFilePath string) string {
+	sum := sha1.Sum([]byte(inputFilePath))
+	name := strings.TrimSuffix(filepath.Base(inputFilePath), filepath.Ext(inputFilePath))
+	return filepath.Join(dir, name+"_"+hex.EncodeToString(sum[:4]))
+}
+
+// SupportedFormats is the 16kHz WAV whisper.cpp reads, other audio is converted before the call.
+func (lt *LocalTranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"wav"}, Codecs: []string{"pcm_s16le"}, SampleRate: 16000}
+}
+
+// HealthCheck checks that the binary is executable and the model exists.
+func (lt *LocalTranscriber) HealthCheck() error {
+	info, err := os.Stat(lt.binaryPath)
+	if err != nil {
+		return fmt.Errorf("whisper.cpp binary: %v", err)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("whisper.cpp binary %s is not executable", lt.binaryPath)
+	}
+	if _, err = os.Stat(lt.modelPath); err != nil {
+		return fmt.Errorf("whisper.cpp model: %v", err)
+	}
+	return nil
+}
+
+// modelName turns a model path like models/ggml-large-v2.bin into large-v2.
+func modelName(modelPath string) string {
+	name := strings.TrimSuffix(filepath.Base(modelPath), filepath.Ext(modelPath))
+	return strings.TrimPrefix(name, "ggml-")
+}
+
+// parseSegments counts the segments in whisper.cpp's stdout and returns the end of the last one in seconds.
+func parseSegments(stdout string) (count int, durationSeconds float64) {
+	segments := Segments(stdout)
+	if len(segments) == 0 {
+		return 0, 0
+	}
+	return len(segments), segments[len(segments)-1].End
+}
+
+// Segments returns the segments whisper.cpp printed to stdout.
+func Segments(stdout string) []model.Segment {
+	var segments []model.Segment
+	for _, line := range strings.Split(stdout, "\n") {
+		if s, ok := parseSegmentLine(line); ok {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+func parseTimestamp(parts []string) time.Duration {
+	var values [4]int
+	for i, p := range parts {
+		fmt.Sscanf(p, "%d", &values[i])
+	}
+	return time.Duration(values[0])*time.Hour +
+		time.Duration(values[1])*time.Minute +
+		time.Duration(values[2])*time.Second +
+		time.Duration(values[3])*time.Millisecond
 }
@@ -1,69 +1,149 @@
 package whisper_cpp
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"tiktok-whisper/internal/app/corpus/corpustest"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/util/files"
 )
 
-func TestLocalTranscriber_Transcript(t *testing.T) {
-	type fields struct {
-		binaryPath string
-		modelPath  string
+func Test_parseSegments(t *testing.T) {
+	tests := []struct {
+		name         string
+		stdout       string
+		wantCount    int
+		wantDuration float64
+	}{
+		{
+			name:         "two_segments",
+			stdout:       "\n[00:00:00.000 --> 00:00:07.600]   And so my fellow Americans\n[00:00:07.600 --> 00:01:11.020]   ask not\n\nwhisper_print_timings: total time = 1.00 ms\n",
+			wantCount:    2,
+			wantDuration: 71.02,
+		},
+		{
+			name:   "no_segments",
+			stdout: "whisper_init_from_file: loading model\n",
+		},
 	}
-	type args struct {
-		inputFilePath string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCount, gotDuration := parseSegments(tt.stdout)
+			if gotCount != tt.wantCount || gotDuration != tt.wantDuration {
+				t.Errorf("parseSegments() = %v, %v, want %v, %v", gotCount, gotDuration, tt.wantCount, tt.wantDuration)
+			}
+		})
 	}
+}
+
+func Test_parseDetectedLanguage(t *testing.T) {
 	tests := []struct {
 		name    string
-		fields  fields
-		args    args
+		output  string
 		want    string
 		wantErr bool
 	}{
 		{
-			name: "large",
-			fields: fields{
-				binaryPath: "/Users/tiansheng/workspace/cpp/whisper.cpp/main",
-				modelPath:  "/Users/tiansheng/workspace/cpp/whisper.cpp/models/ggml-large-v2.bin",
-			},
-			args: args{
-				inputFilePath: "/Users/tiansheng/workspace/go/tiktok-whisper/test/data/jfk.wav",
-			},
-			want:    "And so my fellow Americans, ask not what your country can do for you, ask what you can do for your country!",
-			wantErr: false,
+			name:   "detected",
+			output: "whisper_init_from_file: loading model\nwhisper_full_with_state: auto-detected language: en (p = 0.976563)\n",
+			want:   "en",
+		},
+		{name: "no language", output: "whisper_init_from_file: loading model\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDetectedLanguage(tt.output)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseDetectedLanguage() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_segmentWriter(t *testing.T) {
+	partials := make(chan model.Segment, 10)
+	w := &segmentWriter{partials: partials}
+
+	// Writes don't line up with lines, the last line has no newline
+	for _, chunk := range []string{
+		"whisper_init: loading model\n[00:00:00.000 --> 00:00:0",
+		"7.600]   \x1b[38;5;160mAnd\x1b[0m so my fellow Americans\n",
+		"[00:00:07.600 --> 00:01:11.020]   ask not",
+	} {
+		w.Write([]byte(chunk))
+	}
+	w.flush()
+	close(partials)
+
+	var got []model.Segment
+	for s := range partials {
+		got = append(got, s)
+	}
+	want := []model.Segment{
+		{Start: 0, End: 7.6, Text: "And so my fellow Americans"},
+		{Start: 7.6, End: 71.02, Text: "ask not"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("segmentWriter sent %+v, want %+v", got, want)
+	}
+}
+
+// localFile returns a recording kept in test/data that isn't part of the corpus, it skips the test when it is missing.
+func localFile(t *testing.T, name string) string {
+	t.Helper()
+	root, err := files.GetProjectRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, "test", "data", name)
+	if _, err = os.Stat(path); err != nil {
+		t.Skipf("%s is not in test/data", name)
+	}
+	return path
+}
+
+func TestLocalTranscriber_Transcript(t *testing.T) {
+	// The whisper.cpp build and model differ per machine, set them to run the test
+	binaryPath, modelPath := os.Getenv("WHISPER_CPP_BINARY"), os.Getenv("WHISPER_CPP_MODEL")
+	if binaryPath == "" || modelPath == "" {
+		t.Skip("WHISPER_CPP_BINARY and WHISPER_CPP_MODEL are not set")
+	}
+
+	tests := []struct {
+		name      string
+		inputFile func(t *testing.T) string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "large",
+			inputFile: func(t *testing.T) string { return corpustest.Require(t, "jfk") },
+			want:      "And so my fellow Americans, ask not what your country can do for you, ask what you can do for your country!",
+			wantErr:   false,
 		},
 		{
-			name: "large-mp3",
-			fields: fields{
-				binaryPath: "/Users/tiansheng/workspace/cpp/whisper.cpp/main",
-				modelPath:  "/Users/tiansheng/workspace/cpp/whisper.cpp/models/ggml-large-v2.bin",
-			},
-			args: args{
-				inputFilePath: "/Users/tiansheng/workspace/go/tiktok-whisper/test/data/test.mp3",
-			},
-			want:    "星巴克",
-			wantErr: false,
+			name:      "large-mp3",
+			inputFile: func(t *testing.T) string { return localFile(t, "test.mp3") },
+			want:      "星巴克",
+			wantErr:   false,
 		},
 		{
-			name: "large-m4a",
-			fields: fields{
-				binaryPath: "/Users/tiansheng/workspace/cpp/whisper.cpp/main",
-				modelPath:  "/Users/tiansheng/workspace/cpp/whisper.cpp/models/ggml-large-v2.bin",
-			},
-			args: args{
-				inputFilePath: "/Users/tiansheng/workspace/go/tiktok-whisper/test/data/output.m4a",
-			},
-			want:    "大家好",
-			wantErr: false,
+			name:      "large-m4a",
+			inputFile: func(t *testing.T) string { return localFile(t, "output.m4a") },
+			want:      "大家好",
+			wantErr:   false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			lt := &LocalTranscriber{
-				binaryPath: tt.fields.binaryPath,
-				modelPath:  tt.fields.modelPath,
+				binaryPath: binaryPath,
+				modelPath:  modelPath,
 			}
-			got, err := lt.Transcript(tt.args.inputFilePath)
+			got, err := lt.Transcript(tt.inputFile(t))
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Transcript() error = %v, wantErr %v", err, tt.wantErr)
 				return
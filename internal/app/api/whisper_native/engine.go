@@ -0,0 +1,128 @@
+//go:build whisper_native
+
+package whisper_native
+
+/*
+#include <stdlib.h>
+#include <whisper.h>
+
+// v2t_whisper_init loads the model with the GPU settings, the bindings only load it with the defaults.
+static struct whisper_context* v2t_whisper_init(const char* path, bool use_gpu, bool flash_attn, int gpu_device) {
+	struct whisper_context_params params = whisper_context_default_params();
+	params.use_gpu = use_gpu;
+	params.flash_attn = flash_attn;
+	params.gpu_device = gpu_device;
+	return whisper_init_from_file_with_params(path, params);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"unsafe"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go"
+)
+
+const compiled = true
+
+// running serializes the transcriptions of every model: a whisper.cpp context decodes one audio at a
+// time and the bindings keep the callbacks of all contexts in a map of their own without a lock.
+var running sync.Mutex
+
+// cgoEngine is a model loaded by libwhisper.
+type cgoEngine struct {
+	ctx *whisper.Context
+}
+
+func loadModel(cfg config.NativeConfig) (engine, error) {
+	if _, err := os.Stat(cfg.Model); err != nil {
+		return nil, err
+	}
+	if cfg.MetalResources != "" {
+		// read by ggml when it compiles its Metal kernels
+		os.Setenv("GGML_METAL_PATH_RESOURCES", cfg.MetalResources)
+	}
+
+	path := C.CString(cfg.Model)
+	defer C.free(unsafe.Pointer(path))
+	ctx := C.v2t_whisper_init(path, C.bool(cfg.UseGPU()), C.bool(cfg.FlashAttention), C.int(cfg.GPUDevice))
+	if ctx == nil {
+		return nil, fmt.Errorf("whisper.cpp could not load %s", cfg.Model)
+	}
+	return &cgoEngine{ctx: (*whisper.Context)(unsafe.Pointer(ctx))}, nil
+}
+
+func (e *cgoEngine) Transcribe(samples []float32, p params, onSegment func(model.Segment), abort func() bool) ([]model.Segment, string, error) {
+	running.Lock()
+	defer running.Unlock()
+
+	wp := e.ctx.Whisper_full_default_params(whisper.SAMPLING_GREEDY)
+	wp.SetPrintProgress(false)
+	wp.SetPrintRealtime(false)
+	wp.SetPrintTimestamps(false)
+	if p.Threads > 0 {
+		wp.SetThreads(p.Threads)
+	}
+	if p.Language == "auto" {
+		wp.SetLanguage(-1)
+	} else if err := wp.SetLanguage(e.ctx.Whisper_lang_id(p.Language)); err != nil {
+		return nil, "", fmt.Errorf("%w: %s", err, p.Language)
+	}
+	wp.SetTranslate(p.Translate)
+	wp.SetNoContext(p.NoContext)
+	if p.WordThreshold > 0 {
+		wp.SetTokenThreshold(p.WordThreshold)
+	}
+	if p.MaxSegmentLength > 0 {
+		// like --max-len of whisper.cpp, which needs token timestamps and splits at words
+		wp.SetTokenTimestamps(true)
+		wp.SetMaxSegmentLength(p.MaxSegmentLength)
+		wp.SetSplitOnWord(true)
+	}
+
+	// the fields the bindings have no setter for
+	cp := (*C.struct_whisper_full_params)(unsafe.Pointer(&wp))
+	if p.Temperature > 0 {
+		cp.temperature = C.float(p.Temperature)
+	}
+	if p.NoSpeechThreshold > 0 {
+		cp.no_speech_thold = C.float(p.NoSpeechThreshold)
+	}
+	if p.Prompt != "" {
+		prompt := C.CString(p.Prompt)
+		defer C.free(unsafe.Pointer(prompt))
+		cp.initial_prompt = prompt
+	}
+
+	var segments []model.Segment
+	newSegments := func(n int) {
+		total := e.ctx.Whisper_full_n_segments()
+		for i := total - n; i < total; i++ {
+			s := e.segment(i)
+			segments = append(segments, s)
+			if onSegment != nil {
+				onSegment(s)
+			}
+		}
+	}
+	encoderBegin := func() bool { return !abort() }
+	if err := e.ctx.Whisper_full(wp, samples, encoderBegin, newSegments, nil); err != nil {
+		return nil, "", err
+	}
+	return segments, whisper.Whisper_lang_str(e.ctx.Whisper_full_lang_id()), nil
+}
+
+// segment returns segment i of the last transcription, whisper.cpp times segments in 10ms.
+func (e *cgoEngine) segment(i int) model.Segment {
+	return model.Segment{
+		Start: float64(e.ctx.Whisper_full_get_segment_t0(i)) / 100,
+		End:   float64(e.ctx.Whisper_full_get_segment_t1(i)) / 100,
+		Text:  strings.TrimSpace(e.ctx.Whisper_full_get_segment_text(i)),
+	}
+}
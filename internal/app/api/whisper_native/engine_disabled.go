@@ -0,0 +1,12 @@
+//go:build !whisper_native
+
+package whisper_native
+
+import "tiktok-whisper/internal/app/config"
+
+// compiled reports whether the whisper.cpp library is linked in.
+const compiled = false
+
+func loadModel(cfg config.NativeConfig) (engine, error) {
+	return nil, ErrNotCompiled
+}
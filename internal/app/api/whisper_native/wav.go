@@ -0,0 +1,73 @@
+package whisper_native
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// sampleRate is the rate whisper.cpp decodes at.
+const sampleRate = 16000
+
+// readSamples reads the 16kHz mono 16-bit PCM WAV file path as samples between -1 and 1.
+func readSamples(path string) ([]float32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var riff [12]byte
+	if _, err = io.ReadFull(f, riff[:]); err != nil || string(riff[:4]) != "RIFF" || string(riff[8:]) != "WAVE" {
+		return nil, fmt.Errorf("%s is not a WAV file", path)
+	}
+
+	formatRead := false
+	for {
+		var header [8]byte
+		if _, err = io.ReadFull(f, header[:]); err != nil {
+			return nil, fmt.Errorf("%s has no audio data", path)
+		}
+		id, size := string(header[:4]), int64(binary.LittleEndian.Uint32(header[4:]))
+
+		switch id {
+		case "fmt ":
+			format := make([]byte, size)
+			if _, err = io.ReadFull(f, format); err != nil || size < 16 {
+				return nil, fmt.Errorf("%s has a truncated format", path)
+			}
+			encoding := binary.LittleEndian.Uint16(format[0:])
+			channels := binary.LittleEndian.Uint16(format[2:])
+			rate := binary.LittleEndian.Uint32(format[4:])
+			bits := binary.LittleEndian.Uint16(format[14:])
+			// 0xfffe is WAVE_FORMAT_EXTENSIBLE, ffmpeg writes it for some layouts
+			if (encoding != 1 && encoding != 0xfffe) || channels != 1 || rate != sampleRate || bits != 16 {
+				return nil, fmt.Errorf("%s is %d channels of %d-bit audio at %dHz, want 16kHz mono 16-bit PCM", path, channels, bits, rate)
+			}
+			formatRead = true
+		case "data":
+			if !formatRead {
+				return nil, fmt.Errorf("%s has audio data before its format", path)
+			}
+			data, err := io.ReadAll(io.LimitReader(f, size))
+			if err != nil {
+				return nil, err
+			}
+			if len(data) < 2 {
+				return nil, errors.New("no audio in " + path)
+			}
+			samples := make([]float32, len(data)/2)
+			for i := range samples {
+				samples[i] = float32(int16(binary.LittleEndian.Uint16(data[2*i:]))) / 32768
+			}
+			return samples, nil
+		default:
+			// chunks are padded to an even size
+			if _, err = f.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package whisper_native
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/whisper_cpp"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/tracing"
+	"tiktok-whisper/internal/app/vocab"
+)
+
+const (
+	providerName    = "whisper_native"
+	defaultLanguage = "zh"
+)
+
+// ErrNotCompiled is returned when v2t was built without the whisper.cpp library.
+var ErrNotCompiled = errors.New("the whisper_native provider is not compiled in, rebuild with -tags whisper_native against libwhisper")
+
+// engine is a whisper.cpp model loaded into the process.
+type engine interface {
+	// Transcribe decodes the 16kHz mono samples with p, onSegment is called with each segment as
+	// soon as it is decoded. Decoding stops before the next 30 seconds window once abort returns true.
+	// It returns the segments and the language they are in, the detected one when p.Language is auto.
+	Transcribe(samples []float32, p params, onSegment func(model.Segment), abort func() bool) ([]model.Segment, string, error)
+}
+
+// params are the decoding settings of one transcription.
+type params struct {
+	Language string
+	Prompt   string
+	Threads  int
+	api.Options
+}
+
+// loaded are the models loaded so far, each model is loaded once per process and shared by the
+// transcribers using it.
+var loaded = struct {
+	sync.Mutex
+	engines map[config.NativeConfig]engine
+}{engines: map[config.NativeConfig]engine{}}
+
+// load loads the model of cfg, replaced in tests.
+var load = loadModel
+
+// NativeTranscriber transcribes in-process with the whisper.cpp library, without spawning whisper.cpp
+// or sending the audio to a server.
+type NativeTranscriber struct {
+	cfg      config.NativeConfig
+	language string
+	request  *model.RequestMetadata
+	decoding config.DecodingConfig
+	// verbatim keeps fillers and stutters, see config.VerbatimConfig
+	verbatim bool
+	// vocabulary are the installed vocabulary packs, their terms bias the prompt
+	vocabulary vocab.Packs
+}
+
+// NewNativeTranscriber creates a transcriber with the model of cfg, it is loaded on the first transcription.
+func NewNativeTranscriber(cfg config.NativeConfig) *NativeTranscriber {
+	return &NativeTranscriber{
+		cfg:        cfg,
+		language:   defaultLanguage,
+		request:    config.GetProviders().For(providerName).Record(),
+		decoding:   config.GetProviders().For(providerName).Decoding,
+		verbatim:   config.Get().Verbatim.Default,
+		vocabulary: vocab.Installed(),
+	}
+}
+
+// New creates the transcriber of whisper_native in providers.yaml.
+func New() (*NativeTranscriber, error) {
+	if !compiled {
+		return nil, ErrNotCompiled
+	}
+	cfg := config.GetProviders().For(providerName).Native
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("no model for %s, set native.model in providers.yaml", providerName)
+	}
+	return NewNativeTranscriber(cfg), nil
+}
+
+// SetLanguage sets the language whisper.cpp transcribes, zh unless set, auto detects it.
+func (nt *NativeTranscriber) SetLanguage(language string) {
+	nt.language = language
+}
+
+func (nt *NativeTranscriber) lang() string {
+	if nt.language == "" {
+		return defaultLanguage
+	}
+	return nt.language
+}
+
+// Transcript transcribes the 16kHz WAV file inputFilePath.
+func (nt *NativeTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := nt.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and also reports the model, language and segments.
+func (nt *NativeTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return nt.TranscriptWithPrompt(inputFilePath, "")
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata, previousText is appended to the
+// language prompt so whisper.cpp continues in the same context.
+func (nt *NativeTranscriber) TranscriptWithPrompt(inputFilePath string, previousText string) (string, model.ProviderMetadata, error) {
+	return nt.transcribe(context.Background(), inputFilePath, api.Options{Prompt: previousText}, nil)
+}
+
+// TranscriptWithOptions works like TranscriptWithPrompt with opts.Prompt as previousText.
+// VAD is not supported by the library and ignored.
+func (nt *NativeTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return nt.transcribe(context.Background(), inputFilePath, opts, nil)
+}
+
+// TranscriptContext works like TranscriptWithOptions with the options ctx carries, decoding stops
+// at the next 30 seconds window once ctx is canceled.
+func (nt *NativeTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return nt.transcribe(ctx, inputFilePath, api.OptionsFrom(ctx), nil)
+}
+
+// TranscriptStream works like TranscriptWithMetadata and sends each segment as soon as it is decoded.
+func (nt *NativeTranscriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	return nt.transcribe(context.Background(), inputFilePath, api.Options{}, partials)
+}
+
+// transcribe decodes inputFilePath with the model, the segments are sent to partials when it is not nil.
+func (nt *NativeTranscriber) transcribe(ctx context.Context, inputFilePath string, opts api.Options, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	logger := logging.L().With("provider", providerName)
+	opts = opts.Merge(api.Options{
+		NoContext:         nt.decoding.NoContext(),
+		NoSpeechThreshold: nt.decoding.NoSpeechThreshold,
+	})
+	if nt.verbatim {
+		opts = opts.Verbatim()
+	}
+	opts.VAD = false
+
+	language := nt.lang()
+	prompt := whisper_cpp.LanguagePrompt(language) + nt.vocabulary.Prompt(language)
+	if nt.verbatim {
+		prompt += api.VerbatimPrompt(language)
+	}
+	if !opts.NoContext {
+		prompt += opts.Prompt
+	}
+	metadata := model.ProviderMetadata{
+		Provider: providerName,
+		Model:    modelName(nt.cfg.Model),
+		Language: language,
+		Request:  nt.request,
+		Verbatim: nt.verbatim,
+		WhisperCpp: &model.WhisperCppMetadata{
+			ModelPath: nt.cfg.Model,
+			Prompt:    prompt,
+
+			NoContext:         opts.NoContext,
+			Temperature:       opts.Temperature,
+			NoSpeechThreshold: opts.NoSpeechThreshold,
+			Translate:         opts.Translate,
+			WordThreshold:     opts.WordThreshold,
+			MaxSegmentLength:  opts.MaxSegmentLength,
+		},
+	}
+
+	samples, err := readSamples(inputFilePath)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, false, err)
+	}
+	e, err := nt.engine()
+	if err != nil {
+		// another provider may well transcribe the file
+		return "", metadata, provider.NewTranscriptionError(providerName, true, err)
+	}
+
+	logger.Info("Starting transcription", "file", inputFilePath, "seconds", float64(len(samples))/sampleRate)
+	_, span := tracing.Start(ctx, providerName,
+		tracing.String("v2t.model", metadata.Model), tracing.String("v2t.language", language))
+	defer span.End()
+
+	var onSegment func(model.Segment)
+	if partials != nil {
+		onSegment = func(s model.Segment) { partials <- s }
+	}
+	p := params{Language: language, Prompt: prompt, Threads: nt.cfg.Threads, Options: opts}
+	segments, detected, err := e.Transcribe(samples, p, onSegment, func() bool { return ctx.Err() != nil })
+	if ctx.Err() != nil {
+		return "", metadata, ctx.Err()
+	}
+	if err != nil {
+		span.RecordError(err)
+		logger.Error("Error transcribing", "file", inputFilePath, "error", err)
+		return "", metadata, provider.NewTranscriptionError(providerName, true, err)
+	}
+
+	if detected != "" {
+		metadata.Language = detected
+	}
+	metadata.Segments = segments
+	metadata.SegmentCount = len(segments)
+	if len(segments) > 0 {
+		metadata.DurationSeconds = segments[len(segments)-1].End
+	}
+	span.SetAttributes(tracing.Int("v2t.segments", metadata.SegmentCount))
+	return text(segments), metadata, nil
+}
+
+// engine returns the model of nt, loading it when no transcriber loaded it before.
+func (nt *NativeTranscriber) engine() (engine, error) {
+	loaded.Lock()
+	defer loaded.Unlock()
+	if e, ok := loaded.engines[nt.cfg]; ok {
+		return e, nil
+	}
+
+	logging.L().Info("Loading model", "provider", providerName, "model", nt.cfg.Model, "gpu", nt.cfg.UseGPU())
+	e, err := load(nt.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("load %s failed: %w", nt.cfg.Model, err)
+	}
+	loaded.engines[nt.cfg] = e
+	return e, nil
+}
+
+// text joins the segments one per line, like the .txt whisper.cpp writes.
+func text(segments []model.Segment) string {
+	lines := make([]string, len(segments))
+	for i, s := range segments {
+		lines[i] = strings.TrimSpace(s.Text)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// SupportedFormats is the 16kHz WAV the model is fed, other audio is converted before the call.
+func (nt *NativeTranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"wav"}, Codecs: []string{"pcm_s16le"}, SampleRate: 16000}
+}
+
+// HealthCheck checks that the library is compiled in and the model exists.
+func (nt *NativeTranscriber) HealthCheck() error {
+	if !compiled {
+		return ErrNotCompiled
+	}
+	if _, err := os.Stat(nt.cfg.Model); err != nil {
+		return fmt.Errorf("whisper.cpp model: %v", err)
+	}
+	return nil
+}
+
+// modelName turns a model path like models/ggml-large-v2.bin into large-v2.
+func modelName(modelPath string) string {
+	name := strings.TrimSuffix(filepath.Base(modelPath), filepath.Ext(modelPath))
+	return strings.TrimPrefix(name, "ggml-")
+}
@@ -0,0 +1,255 @@
+package whisper_native
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+)
+
+// writeWav writes samples as a PCM WAV file, extra chunks come before the data.
+func writeWav(t *testing.T, rate uint32, channels uint16, samples []int16, extra ...string) string {
+	t.Helper()
+	format := make([]byte, 16)
+	binary.LittleEndian.PutUint16(format[0:], 1)
+	binary.LittleEndian.PutUint16(format[2:], channels)
+	binary.LittleEndian.PutUint32(format[4:], rate)
+	binary.LittleEndian.PutUint32(format[8:], rate*uint32(channels)*2)
+	binary.LittleEndian.PutUint16(format[12:], channels*2)
+	binary.LittleEndian.PutUint16(format[14:], 16)
+	data := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(s))
+	}
+
+	chunk := func(id string, body []byte) []byte {
+		header := make([]byte, 8, 8+len(body)+1)
+		copy(header, id)
+		binary.LittleEndian.PutUint32(header[4:], uint32(len(body)))
+		body = append(header, body...)
+		if len(body)%2 == 1 {
+			body = append(body, 0)
+		}
+		return body
+	}
+	body := []byte("WAVE")
+	body = append(body, chunk("fmt ", format)...)
+	for _, e := range extra {
+		body = append(body, chunk("LIST", []byte(e))...)
+	}
+	body = append(body, chunk("data", data)...)
+
+	path := filepath.Join(t.TempDir(), "audio.wav")
+	wav := append([]byte("RIFF\x00\x00\x00\x00"), body...)
+	binary.LittleEndian.PutUint32(wav[4:], uint32(len(body)))
+	if err := os.WriteFile(path, wav, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_readSamples(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    func(t *testing.T) string
+		want    []float32
+		wantErr bool
+	}{
+		{
+			name: "16kHz mono",
+			path: func(t *testing.T) string { return writeWav(t, 16000, 1, []int16{0, 16384, -32768}) },
+			want: []float32{0, 0.5, -1},
+		},
+		{
+			name: "odd sized chunk before the data",
+			path: func(t *testing.T) string { return writeWav(t, 16000, 1, []int16{-16384}, "INFOISFT") },
+			want: []float32{-0.5},
+		},
+		{
+			name:    "44.1kHz",
+			path:    func(t *testing.T) string { return writeWav(t, 44100, 1, []int16{0}) },
+			wantErr: true,
+		},
+		{
+			name:    "stereo",
+			path:    func(t *testing.T) string { return writeWav(t, 16000, 2, []int16{0, 0}) },
+			wantErr: true,
+		},
+		{
+			name: "not a WAV file",
+			path: func(t *testing.T) string {
+				path := filepath.Join(t.TempDir(), "audio.mp3")
+				os.WriteFile(path, []byte("ID3\x04\x00"), 0644)
+				return path
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSamples(tt.path(t))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readSamples() error = %v, want an error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readSamples() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeEngine returns its segments and records the params of the last transcription.
+type fakeEngine struct {
+	segments []model.Segment
+	language string
+	err      error
+	params   params
+	samples  int
+}
+
+func (e *fakeEngine) Transcribe(samples []float32, p params, onSegment func(model.Segment), abort func() bool) ([]model.Segment, string, error) {
+	e.params, e.samples = p, len(samples)
+	if abort() {
+		return nil, "", errors.New("aborted")
+	}
+	for _, s := range e.segments {
+		if onSegment != nil {
+			onSegment(s)
+		}
+	}
+	return e.segments, e.language, e.err
+}
+
+// useEngine makes e the model every transcriber loads and counts the loads.
+func useEngine(t *testing.T, e engine, err error) *int {
+	t.Helper()
+	loads := 0
+	load = func(config.NativeConfig) (engine, error) {
+		loads++
+		return e, err
+	}
+	t.Cleanup(func() {
+		load = loadModel
+		loaded.Lock()
+		loaded.engines = map[config.NativeConfig]engine{}
+		loaded.Unlock()
+	})
+	return &loads
+}
+
+func TestNativeTranscriber_TranscriptWithOptions(t *testing.T) {
+	e := &fakeEngine{
+		segments: []model.Segment{{Start: 0, End: 2.5, Text: "hello"}, {Start: 2.5, End: 4, Text: " world "}},
+		language: "en",
+	}
+	loads := useEngine(t, e, nil)
+	audio := writeWav(t, 16000, 1, make([]int16, 64000))
+	cfg := config.NativeConfig{Model: "/models/ggml-base.en.bin", Threads: 4}
+
+	nt := NewNativeTranscriber(cfg)
+	nt.SetLanguage("auto")
+	text, metadata, err := nt.TranscriptWithOptions(audio, api.Options{Prompt: "previously", Temperature: 0.4, VAD: true})
+	if err != nil {
+		t.Fatalf("TranscriptWithOptions() error = %v", err)
+	}
+	if text != "hello\nworld" {
+		t.Errorf("TranscriptWithOptions() = %q, want the segments one per line", text)
+	}
+	if metadata.Provider != providerName || metadata.Model != "base.en" || metadata.Language != "en" ||
+		metadata.SegmentCount != 2 || metadata.DurationSeconds != 4 {
+		t.Errorf("metadata = %+v, want base.en detecting en with 2 segments of 4 seconds", metadata)
+	}
+	want := params{Language: "auto", Prompt: "previously", Threads: 4, Options: api.Options{Prompt: "previously", Temperature: 0.4}}
+	if e.params != want || e.samples != 64000 {
+		t.Errorf("decoded %d samples with %+v, want 64000 with %+v", e.samples, e.params, want)
+	}
+
+	// another transcriber of the same model shares it
+	partials := make(chan model.Segment, 2)
+	if _, _, err = NewNativeTranscriber(cfg).TranscriptStream(audio, partials); err != nil {
+		t.Fatalf("TranscriptStream() error = %v", err)
+	}
+	var streamed []model.Segment
+	for s := range partials {
+		streamed = append(streamed, s)
+	}
+	if !reflect.DeepEqual(streamed, e.segments) {
+		t.Errorf("streamed %+v, want %+v", streamed, e.segments)
+	}
+	if *loads != 1 {
+		t.Errorf("loaded the model %d times, want once", *loads)
+	}
+}
+
+func TestNativeTranscriber_Failures(t *testing.T) {
+	audio := func(t *testing.T) string { return writeWav(t, 16000, 1, []int16{0}) }
+	tests := []struct {
+		name          string
+		engine        *fakeEngine
+		loadErr       error
+		path          func(t *testing.T) string
+		ctx           func() context.Context
+		wantRetryable bool
+		wantCanceled  bool
+	}{
+		{
+			name:   "unreadable audio",
+			engine: &fakeEngine{},
+			path:   func(t *testing.T) string { return writeWav(t, 8000, 1, []int16{0}) },
+		},
+		{
+			name:          "model failing to load",
+			loadErr:       errors.New("out of memory"),
+			path:          audio,
+			wantRetryable: true,
+		},
+		{
+			name:          "decoding failing",
+			engine:        &fakeEngine{err: errors.New("whisper_full failed")},
+			path:          audio,
+			wantRetryable: true,
+		},
+		{
+			name:   "canceled",
+			engine: &fakeEngine{},
+			path:   audio,
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			wantCanceled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useEngine(t, tt.engine, tt.loadErr)
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx()
+			}
+
+			_, _, err := NewNativeTranscriber(config.NativeConfig{Model: "/models/ggml-base.bin"}).TranscriptContext(ctx, tt.path(t))
+			if err == nil {
+				t.Fatal("TranscriptContext() error = nil, want an error")
+			}
+			if tt.wantCanceled {
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("TranscriptContext() error = %v, want it canceled", err)
+				}
+				return
+			}
+			var te *provider.TranscriptionError
+			if !errors.As(err, &te) || te.Retryable != tt.wantRetryable {
+				t.Errorf("TranscriptContext() error = %v, want a transcription error retryable %v", err, tt.wantRetryable)
+			}
+		})
+	}
+}
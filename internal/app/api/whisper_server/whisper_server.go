@@ -0,0 +1,286 @@
+// Package whisper_server transcribes with whisper.cpp servers, e.g. one per GPU of a home lab, sending
+// the audio to their /inference endpoint. The servers of providers.yaml are balanced by a provider.Balancer.
+package whisper_server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/requestmeta"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/vocab"
+	"time"
+)
+
+const providerName = "whisper_server"
+
+const healthCheckTimeout = 10 * time.Second
+
+// ServerTranscriber transcribes with one whisper.cpp server.
+type ServerTranscriber struct {
+	baseURL  string
+	client   *http.Client
+	language string
+	request  *model.RequestMetadata
+	decoding config.DecodingConfig
+	// verbatim keeps fillers and stutters, see config.VerbatimConfig
+	verbatim bool
+	// vocabulary are the installed vocabulary packs, their terms bias the prompt
+	vocabulary vocab.Packs
+}
+
+// NewServerTranscriber creates the transcriber of the server at baseURL, e.g. http://gpu1:8080. The
+// transcribers of several servers share client, so they share its pool of connections.
+func NewServerTranscriber(baseURL string, client *http.Client) *ServerTranscriber {
+	return &ServerTranscriber{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		client:     client,
+		request:    config.GetProviders().For(providerName).Record(),
+		decoding:   config.GetProviders().For(providerName).Decoding,
+		verbatim:   config.Get().Verbatim.Default,
+		vocabulary: vocab.Installed(),
+	}
+}
+
+// New returns the servers of providers.yaml behind a provider.Balancer, with the balance and circuit
+// breaker configured there.
+func New() (*provider.Balancer, error) {
+	cfg := config.GetProviders().For(providerName)
+	if len(cfg.Servers) == 0 {
+		return nil, fmt.Errorf("no servers configured for %s in providers.yaml", providerName)
+	}
+	b, err := provider.NewBalancer(providerName, cfg.Balance, cfg.CircuitBreaker)
+	if err != nil {
+		return nil, err
+	}
+	client := requestmeta.NewClient(providerName)
+	for _, server := range cfg.Servers {
+		b.Add(server, NewServerTranscriber(server, client))
+	}
+	return b, nil
+}
+
+// SetLanguage sends language with the requests, the server uses the language it was started with when it is empty.
+func (st *ServerTranscriber) SetLanguage(language string) {
+	st.language = language
+}
+
+// SupportedFormats is the 16kHz WAV whisper.cpp reads, a server started without --convert doesn't convert.
+func (st *ServerTranscriber) SupportedFormats() provider.Formats {
+	return provider.Formats{Containers: []string{"wav"}, Codecs: []string{"pcm_s16le"}, SampleRate: 16000}
+}
+
+func (st *ServerTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := st.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+// TranscriptWithMetadata works like Transcript and also reports the server, language and segments.
+func (st *ServerTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return st.TranscriptWithPrompt(inputFilePath, "")
+}
+
+// TranscriptWithPrompt works like TranscriptWithMetadata and sends prompt as the initial prompt.
+func (st *ServerTranscriber) TranscriptWithPrompt(inputFilePath string, prompt string) (string, model.ProviderMetadata, error) {
+	return st.transcribe(context.Background(), inputFilePath, api.Options{Prompt: prompt})
+}
+
+// TranscriptWithOptions works like TranscriptWithPrompt with opts.Prompt and sends the decoding
+// settings as form fields. The server runs VAD only when it was started with a VAD model, VAD is ignored.
+func (st *ServerTranscriber) TranscriptWithOptions(inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	return st.transcribe(context.Background(), inputFilePath, opts)
+}
+
+// TranscriptContext works like TranscriptWithOptions with the options ctx carries and sends the request with ctx.
+func (st *ServerTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	return st.transcribe(ctx, inputFilePath, api.OptionsFrom(ctx))
+}
+
+// response is the verbose_json response of /inference.
+type response struct {
+	Language string  `json:"language"`
+	Duration float64 `json:"duration"`
+	Text     string  `json:"text"`
+	Segments []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+	} `json:"segments"`
+}
+
+func (st *ServerTranscriber) transcribe(ctx context.Context, inputFilePath string, opts api.Options) (string, model.ProviderMetadata, error) {
+	opts = opts.Merge(api.Options{
+		NoContext:         st.decoding.NoContext(),
+		NoSpeechThreshold: st.decoding.NoSpeechThreshold,
+	})
+	if st.verbatim {
+		opts = opts.Verbatim()
+	}
+	prompt := st.vocabulary.Prompt(st.language)
+	if st.verbatim {
+		prompt += api.VerbatimPrompt(st.language)
+	}
+	if !opts.NoContext {
+		prompt += opts.Prompt
+	}
+	metadata := model.ProviderMetadata{
+		Provider: providerName,
+		Language: st.language,
+		Request:  st.request,
+		Verbatim: st.verbatim,
+		Server:   &model.ServerInfo{Host: host(st.baseURL)},
+	}
+
+	fields := map[string]string{"response_format": "verbose_json"}
+	if st.language != "" {
+		fields["language"] = st.language
+	}
+	if prompt != "" {
+		fields["prompt"] = prompt
+	}
+	if opts.NoContext {
+		fields["max_context"] = "0"
+	}
+	if opts.Temperature > 0 {
+		fields["temperature"] = formatFloat(opts.Temperature)
+	}
+	if opts.NoSpeechThreshold > 0 {
+		fields["no_speech_thold"] = formatFloat(opts.NoSpeechThreshold)
+	}
+	if opts.Translate {
+		fields["translate"] = "true"
+		metadata.Language = "en"
+	}
+	if opts.WordThreshold > 0 {
+		fields["word_thold"] = formatFloat(opts.WordThreshold)
+	}
+	if opts.MaxSegmentLength > 0 {
+		// split at words rather than in the middle of one
+		fields["max_len"] = strconv.Itoa(opts.MaxSegmentLength)
+		fields["split_on_word"] = "true"
+	}
+
+	body, contentType, err := multipartBody(inputFilePath, fields)
+	if err != nil {
+		return "", metadata, fmt.Errorf("read audio failed: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, st.baseURL+"/inference", body)
+	if err != nil {
+		return "", metadata, err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	logging.L().Info("Starting transcription", "provider", providerName, "server", st.baseURL, "file", inputFilePath)
+	resp, err := st.client.Do(req)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, ctx.Err() == nil, fmt.Errorf("inference request to %s failed: %w", st.baseURL, err))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("read response of %s failed: %w", st.baseURL, err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", metadata, provider.NewTranscriptionError(providerName, retryable(resp.StatusCode),
+			fmt.Errorf("%s answered %s: %s", st.baseURL, resp.Status, strings.TrimSpace(string(data))))
+	}
+
+	var r response
+	if err = json.Unmarshal(data, &r); err != nil {
+		// a server that answers garbage is broken, not the audio
+		return "", metadata, provider.NewTranscriptionError(providerName, true, fmt.Errorf("parse response of %s failed: %v", st.baseURL, err))
+	}
+	if r.Language != "" {
+		metadata.Language = r.Language
+	}
+	for _, s := range r.Segments {
+		metadata.Segments = append(metadata.Segments, model.Segment{Start: s.Start, End: s.End, Text: strings.TrimSpace(s.Text)})
+	}
+	metadata.SegmentCount = len(metadata.Segments)
+	metadata.DurationSeconds = r.Duration
+	if metadata.DurationSeconds == 0 && len(metadata.Segments) > 0 {
+		metadata.DurationSeconds = metadata.Segments[len(metadata.Segments)-1].End
+	}
+	return strings.TrimSpace(r.Text), metadata, nil
+}
+
+// HealthCheck asks the server whether it is up and its model loaded.
+func (st *ServerTranscriber) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, st.baseURL+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := st.client.Do(req)
+	if err != nil {
+		return provider.NewTranscriptionError(providerName, true, fmt.Errorf("health check of %s failed: %w", st.baseURL, err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return provider.NewTranscriptionError(providerName, true, fmt.Errorf("%s is unhealthy: %s", st.baseURL, resp.Status))
+	}
+	return nil
+}
+
+// retryable reports whether another server may succeed: rate limits and server errors, e.g. a server
+// still loading its model, are, requests rejected for their audio aren't.
+func retryable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests ||
+		status == http.StatusRequestTimeout
+}
+
+// multipartBody returns the form sending the file at path with fields.
+func multipartBody(path string, fields map[string]string) (io.Reader, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = io.Copy(part, f); err != nil {
+		return nil, "", err
+	}
+	for name, value := range fields {
+		if err = w.WriteField(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
+
+// host returns the host of baseURL, baseURL itself when it doesn't parse.
+func host(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
+
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', 2, 32)
+}
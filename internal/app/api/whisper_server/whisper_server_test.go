@@ -0,0 +1,123 @@
+package whisper_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestServerTranscriber_TranscriptWithOptions(t *testing.T) {
+	audio := filepath.Join(t.TempDir(), "a.wav")
+	if err := os.WriteFile(audio, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		opts          api.Options
+		wantFields    map[string]string
+		wantText      string
+		wantSegments  []model.Segment
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{
+			name:   "verbose json",
+			status: http.StatusOK,
+			body:   `{"language":"en","duration":3.5,"text":" hello world","segments":[{"start":0,"end":1.5,"text":" hello"},{"start":1.5,"end":3.5,"text":" world"}]}`,
+			opts:   api.Options{Prompt: "before", Temperature: 0.2, MaxSegmentLength: 42},
+			wantFields: map[string]string{
+				"response_format": "verbose_json", "language": "en", "prompt": "before",
+				"temperature": "0.20", "max_len": "42", "split_on_word": "true",
+			},
+			wantText:     "hello world",
+			wantSegments: []model.Segment{{Start: 0, End: 1.5, Text: "hello"}, {Start: 1.5, End: 3.5, Text: "world"}},
+		},
+		{
+			name:       "no context drops the prompt",
+			status:     http.StatusOK,
+			body:       `{"text":"hi"}`,
+			opts:       api.Options{Prompt: "before", NoContext: true, Translate: true},
+			wantFields: map[string]string{"response_format": "verbose_json", "language": "en", "max_context": "0", "translate": "true"},
+			wantText:   "hi",
+		},
+		{name: "model loading", status: http.StatusServiceUnavailable, body: `{"error":"loading"}`, wantErr: true, wantRetryable: true},
+		{name: "rejected audio", status: http.StatusBadRequest, body: `{"error":"invalid audio"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/inference" {
+					t.Errorf("path = %s, want /inference", r.URL.Path)
+				}
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Fatal(err)
+				}
+				if _, _, err := r.FormFile("file"); err != nil {
+					t.Errorf("no file sent: %v", err)
+				}
+				fields = map[string]string{}
+				for name, values := range r.MultipartForm.Value {
+					fields[name] = values[0]
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			st := &ServerTranscriber{baseURL: srv.URL, client: srv.Client(), language: "en"}
+			text, metadata, err := st.TranscriptWithOptions(audio, tt.opts)
+			if tt.wantErr {
+				if err == nil || provider.IsRetryable(err) != tt.wantRetryable {
+					t.Errorf("TranscriptWithOptions() error = %v, want retryable %v", err, tt.wantRetryable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TranscriptWithOptions() error = %v", err)
+			}
+			if text != tt.wantText || !reflect.DeepEqual(metadata.Segments, tt.wantSegments) {
+				t.Errorf("TranscriptWithOptions() = %q, %+v", text, metadata.Segments)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+			if metadata.Provider != providerName || metadata.Server == nil || !strings.HasPrefix(srv.URL, "http://"+metadata.Server.Host) {
+				t.Errorf("metadata = %+v, want the provider and server", metadata)
+			}
+		})
+	}
+}
+
+func TestServerTranscriber_HealthCheck(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("path = %s, want /health", r.URL.Path)
+		}
+		w.WriteHeader(status)
+	}))
+	st := &ServerTranscriber{baseURL: srv.URL, client: srv.Client()}
+
+	if err := st.HealthCheck(); err != nil {
+		t.Errorf("HealthCheck() = %v, want nil", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := st.HealthCheck(); err == nil {
+		t.Error("HealthCheck() = nil while the model loads")
+	}
+	srv.Close()
+	if err := st.HealthCheck(); !provider.IsRetryable(err) {
+		t.Errorf("HealthCheck() = %v, want a retryable error for a server that is down", err)
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
 	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"tiktok-whisper/internal/app/logging"
 	model2 "tiktok-whisper/internal/app/model"
 )
 
@@ -28,28 +29,68 @@ func GetAudioDuration(filePath string) (int, error) {
 	return duration, nil
 }
 
-func ConvertToMp3(fileName string, fileFullPath string, mp3FilePath string) error {
-	if _, err := os.Stat(mp3FilePath); os.IsNotExist(err) {
-		log.Printf("converting to mp3: %s\n", fileName)
+// SplitAudio cuts the input audio into chunks of chunkSeconds without re-encoding, named
+// <outputPrefix>_000<ext>, <outputPrefix>_001<ext>... It returns the chunk paths in order.
+func SplitAudio(inputFilePath string, outputPrefix string, chunkSeconds int) ([]string, error) {
+	ext := filepath.Ext(inputFilePath)
+	chunkGlob := outputPrefix + "_[0-9][0-9][0-9]" + ext
 
-		// Convert MP4 to MP3
-		cmd := exec.Command("ffmpeg", "-i", fileFullPath, "-vn", "-acodec", "libmp3lame", mp3FilePath)
+	// Stale chunks of an interrupted run would be taken for chunks of this one
+	removeFiles(chunkGlob)
 
-		// 创建一个 buffer 来捕获标准错误输出
+	cmd := exec.Command("ffmpeg", "-i", inputFilePath, "-vn", "-f", "segment",
+		"-segment_time", strconv.Itoa(chunkSeconds), "-reset_timestamps", "1", "-c", "copy", outputPrefix+"_%03d"+ext)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		removeFiles(chunkGlob)
+		return nil, fmt.Errorf("FFmpeg error: %v, stderr: %s", err, stderr.String())
+	}
+
+	chunks, err := filepath.Glob(chunkGlob)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(chunks)
+	return chunks, nil
+}
+
+// SplitAudioOverlap works like SplitAudio, but chunk i starts at i*chunkSeconds and reaches
+// overlapSeconds into the next chunk. No chunk is cut that the previous one already covers
+// completely, durationSeconds is the length of the input.
+func SplitAudioOverlap(inputFilePath string, outputPrefix string, chunkSeconds int, overlapSeconds int, durationSeconds int) ([]string, error) {
+	ext := filepath.Ext(inputFilePath)
+	chunkGlob := outputPrefix + "_[0-9][0-9][0-9]" + ext
+	removeFiles(chunkGlob)
+
+	count := 1
+	if durationSeconds > chunkSeconds+overlapSeconds {
+		count = (durationSeconds - overlapSeconds + chunkSeconds - 1) / chunkSeconds
+	}
+
+	chunks := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		chunk := fmt.Sprintf("%s_%03d%s", outputPrefix, i, ext)
+		cmd := exec.Command("ffmpeg", "-ss", strconv.Itoa(i*chunkSeconds), "-t", strconv.Itoa(chunkSeconds+overlapSeconds),
+			"-i", inputFilePath, "-vn", "-c", "copy", "-y", chunk)
 		var stderr bytes.Buffer
 		cmd.Stderr = &stderr
 
-		err := cmd.Run()
-		if err != nil {
-			// 输出标准错误输出的内容，以便了解详细的错误原因
-			return fmt.Errorf("FFmpeg error: %v, stderr: %s", err, stderr.String())
+		if err := cmd.Run(); err != nil {
+			removeFiles(chunkGlob)
+			return nil, fmt.Errorf("FFmpeg error: %v, stderr: %s", err, stderr.String())
 		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
 
-		log.Printf("MP4 to MP3 conversion completed: '%s'\n", mp3FilePath)
-	} else {
-		log.Printf("MP3 file already exists for '%s', skipping conversion.\n", fileName)
+func removeFiles(pattern string) {
+	matches, _ := filepath.Glob(pattern)
+	for _, m := range matches {
+		os.Remove(m)
 	}
-	return nil
 }
 
 func Is16kHzWavFile(filePath string) (bool, error) {
@@ -84,9 +125,14 @@ func ConvertTo16kHzWav(inputFilePath string) (string, error) {
 	return outputFilePath, nil
 }
 
+// ConvertTo16kHzWavAt converts the input audio to a 16kHz WAV file at outputWavPath.
+func ConvertTo16kHzWavAt(inputAudioFilePath, outputWavPath string) error {
+	return convertTo16kHzWav(inputAudioFilePath, outputWavPath)
+}
+
 func convertTo16kHzWav(inputAudioFilePath, outputWavPath string) error {
 	if _, err := os.Stat(outputWavPath); !os.IsNotExist(err) {
-		log.Printf("16kHz WAV file already exists for '%s', skipping conversion.\n", inputAudioFilePath)
+		logging.L().Info("16kHz WAV file already exists, skipping conversion", "file", inputAudioFilePath)
 		return nil
 	}
 
@@ -95,14 +141,15 @@ func convertTo16kHzWav(inputAudioFilePath, outputWavPath string) error {
 		return fmt.Errorf("unsupported audio format not in [mp3,m4a,wav]: %s", ext)
 	}
 
-	log.Printf("convert to 16kHz wav: %s\n", inputAudioFilePath)
+	logging.L().Info("Converting to 16kHz wav", "file", inputAudioFilePath)
 
 	// Convert audio to 16kHz WAV
 	cmd := exec.Command("ffmpeg", "-i", inputAudioFilePath, "-vn", "-acodec", "pcm_s16le", "-ar", "16000", "-ac", "2", outputWavPath)
 	if err := cmd.Run(); err != nil {
+		os.Remove(outputWavPath)
 		return fmt.Errorf("FFmpeg error: %v", err)
 	}
 
-	log.Printf("Audio to 16kHz WAV conversion completed: '%s'\n", outputWavPath)
+	logging.L().Info("Audio to 16kHz WAV conversion completed", "path", outputWavPath)
 	return nil
 }
@@ -0,0 +1,147 @@
+// Package condense cuts the silences and filler words out of an episode, so it can be listened
+// through faster next to its transcript.
+package condense
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/analytics"
+	"tiktok-whisper/internal/app/audio/vad"
+	"tiktok-whisper/internal/app/model"
+)
+
+// Level is how aggressively the audio is condensed.
+type Level int
+
+const (
+	// Gentle only cuts the long pauses.
+	Gentle Level = iota + 1
+	// Normal cuts pauses of a second and the filler words.
+	Normal
+	// Aggressive cuts every noticeable pause and the filler words, with little room around the speech.
+	Aggressive
+)
+
+var levelNames = map[Level]string{Gentle: "gentle", Normal: "normal", Aggressive: "aggressive"}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return strconv.Itoa(int(l))
+}
+
+// ParseLevel parses a level by its name or its number, 1 to 3.
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for l, name := range levelNames {
+		if s == name || s == strconv.Itoa(int(l)) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown aggressiveness %q, use gentle, normal, aggressive or 1 to 3", s)
+}
+
+// Options tune what is cut.
+type Options struct {
+	// Silence finds the pauses to cut.
+	Silence vad.Options
+	// Fillers are the filler words cut where the segments have word timings, none when empty.
+	Fillers []string
+	// MinCut in seconds, shorter filler words are kept as cutting them only makes the audio choppy.
+	MinCut float64
+}
+
+// Options returns the options of the level, cutting fillers at the levels that cut filler words.
+func (l Level) Options(fillers []string) Options {
+	switch l {
+	case Gentle:
+		return Options{Silence: vad.Options{MinSilence: 1.5, NoiseDB: -35, Padding: 0.3}}
+	case Aggressive:
+		return Options{Silence: vad.Options{MinSilence: 0.5, NoiseDB: -30, Padding: 0.1}, Fillers: fillers, MinCut: 0.1}
+	default:
+		return Options{Silence: vad.Options{MinSilence: 1, NoiseDB: -35, Padding: 0.2}, Fillers: fillers, MinCut: 0.2}
+	}
+}
+
+// Plan returns the audio to keep: the speech found in silence minus the filler words of segments.
+func Plan(silence vad.Result, segments []model.Segment, opts Options) vad.Result {
+	plan := vad.Result{Duration: silence.Duration}
+	cuts := analytics.TimedFillers(segments, opts.Fillers)
+	for _, s := range silence.Speech {
+		for _, c := range cuts {
+			if c.End-c.Start < opts.MinCut || c.End <= s.Start || c.Start >= s.End {
+				continue
+			}
+			if c.Start > s.Start {
+				plan.Speech = append(plan.Speech, vad.Span{Start: s.Start, End: c.Start})
+			}
+			s.Start = c.End
+			if s.Start >= s.End {
+				break
+			}
+		}
+		if s.End > s.Start {
+			plan.Speech = append(plan.Speech, s)
+		}
+	}
+	return plan
+}
+
+// Segments moves segments to the condensed audio of plan, segments and word timings cut completely
+// are dropped. The text is kept as it is, cut filler words included.
+func Segments(segments []model.Segment, plan vad.Result) []model.Segment {
+	condensed := make([]model.Segment, 0, len(segments))
+	for _, s := range segments {
+		if !s.Timed() {
+			continue
+		}
+		s.Start, s.End = plan.Trimmed(s.Start), plan.Trimmed(s.End)
+		if s.End <= s.Start {
+			continue
+		}
+		if len(s.Words) > 0 {
+			words := make([]model.Word, 0, len(s.Words))
+			for _, w := range s.Words {
+				w.Start, w.End = plan.Trimmed(w.Start), plan.Trimmed(w.End)
+				if w.End > w.Start {
+					words = append(words, w)
+				}
+			}
+			s.Words = words
+		}
+		condensed = append(condensed, s)
+	}
+	return condensed
+}
+
+// Write writes the audio of plan to outputFilePath, the format follows its extension, e.g. mp3.
+func Write(inputFilePath string, outputFilePath string, plan vad.Result) error {
+	if len(plan.Speech) == 0 {
+		return fmt.Errorf("nothing of %s is left to write", inputFilePath)
+	}
+	cmd := exec.Command("ffmpeg", "-y", "-i", inputFilePath, "-vn", "-af", vad.Filter(plan), outputFilePath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("FFmpeg error: %v, stderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// Condense finds the pauses of the audio, cuts them and the filler words of segments out and writes
+// the rest to outputFilePath. It returns the plan, which maps between both audios.
+func Condense(inputFilePath string, outputFilePath string, segments []model.Segment, opts Options) (vad.Result, error) {
+	silence, err := vad.Detect(inputFilePath, opts.Silence)
+	if err != nil {
+		return vad.Result{}, err
+	}
+	plan := Plan(silence, segments, opts)
+	if err = Write(inputFilePath, outputFilePath, plan); err != nil {
+		return vad.Result{}, err
+	}
+	return plan, nil
+}
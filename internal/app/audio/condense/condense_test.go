@@ -0,0 +1,89 @@
+package condense
+
+import (
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/audio/vad"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Level
+		wantErr bool
+	}{
+		{in: "gentle", want: Gentle},
+		{in: " Aggressive", want: Aggressive},
+		{in: "2", want: Normal},
+		{in: "4", wantErr: true},
+		{in: "max", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	silence := vad.Result{Duration: 30, Speech: []vad.Span{{Start: 0, End: 10}, {Start: 15, End: 30}}}
+	segments := []model.Segment{
+		{Start: 0, End: 10, Text: "Um so we start", Words: []model.Word{
+			{Start: 0.5, End: 1, Text: "Um"}, {Start: 1, End: 2, Text: "so"}, {Start: 2, End: 4, Text: "we"}, {Start: 4, End: 9.8, Text: "start"},
+		}},
+		{Start: 15, End: 25, Text: "you know it uh works", Words: []model.Word{
+			{Start: 15, End: 15.4, Text: " you"}, {Start: 15.4, End: 16, Text: " know"}, {Start: 16, End: 18, Text: " it"},
+			{Start: 18, End: 18.05, Text: " uh"}, {Start: 18.1, End: 20, Text: " works"},
+		}},
+		{Start: 25, End: 30, Text: "umbrella"},
+	}
+
+	tests := []struct {
+		name  string
+		level Level
+		want  []vad.Span
+	}{
+		{name: "gentle keeps fillers", level: Gentle, want: silence.Speech},
+		{
+			name:  "normal cuts fillers longer than the minimum",
+			level: Normal,
+			want:  []vad.Span{{Start: 0, End: 0.5}, {Start: 1, End: 10}, {Start: 16, End: 30}},
+		},
+		{
+			name:  "aggressive cuts short fillers too",
+			level: Aggressive,
+			want:  []vad.Span{{Start: 0, End: 0.5}, {Start: 1, End: 10}, {Start: 16, End: 18}, {Start: 18.05, End: 30}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.level.Options([]string{"um", "uh", "you know"})
+			opts.MinCut = map[Level]float64{Normal: 0.2, Aggressive: 0.01}[tt.level]
+			got := Plan(silence, segments, opts)
+			if got.Duration != 30 || !reflect.DeepEqual(got.Speech, tt.want) {
+				t.Errorf("Plan() = %+v, want %+v", got.Speech, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegments(t *testing.T) {
+	plan := vad.Result{Duration: 30, Speech: []vad.Span{{Start: 1, End: 10}, {Start: 16, End: 30}}}
+	segments := []model.Segment{
+		{Start: 0, End: 1, Text: "Um", Words: []model.Word{{Start: 0, End: 1, Text: "Um"}}},
+		{Start: 1, End: 10, Text: "we start"},
+		{Start: 15, End: 20, Text: "you know it works", Words: []model.Word{
+			{Start: 15, End: 16, Text: "you know"}, {Start: 16, End: 20, Text: "it works"},
+		}},
+		{Text: "untimed"},
+	}
+	want := []model.Segment{
+		{Start: 0, End: 9, Text: "we start"},
+		{Start: 9, End: 13, Text: "you know it works", Words: []model.Word{{Start: 9, End: 13, Text: "it works"}}},
+	}
+	if got := Segments(segments, plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("Segments() = %+v, want %+v", got, want)
+	}
+}
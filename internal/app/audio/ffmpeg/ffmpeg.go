@@ -0,0 +1,158 @@
+// Package ffmpeg runs ffmpeg and ffprobe. Runs report their progress, parsed from the output of
+// ffmpeg -progress, and stop when their context is done. The converter uses a Runner, so its tests
+// replace ffmpeg with a fake.
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress is the progress of a run.
+type Progress struct {
+	// Processed is the duration of the output written so far.
+	Processed time.Duration
+	// Total is the duration of the input, zero when it is unknown.
+	Total time.Duration
+	// Percent is Processed of Total from 0 to 100, zero while Total is unknown.
+	Percent float64
+	// Speed is how many times faster than real time ffmpeg runs, zero when it didn't report it.
+	Speed float64
+	// Done is set on the last report of a successful run.
+	Done bool
+}
+
+// ProgressFunc receives the progress of a run, about twice a second.
+type ProgressFunc func(p Progress)
+
+// Runner runs ffmpeg and ffprobe.
+type Runner interface {
+	// Run runs ffmpeg with args and reports its progress to onProgress, which may be nil. total is
+	// the duration of the input, the percent complete is unknown when it is zero.
+	Run(ctx context.Context, args []string, total time.Duration, onProgress ProgressFunc) error
+	// Duration returns the duration of the media at path.
+	Duration(ctx context.Context, path string) (time.Duration, error)
+}
+
+// Command runs the ffmpeg and ffprobe binaries.
+type Command struct {
+	FFmpegPath  string
+	FFprobePath string
+}
+
+// New returns a Command running ffmpeg and ffprobe from the PATH.
+func New() *Command {
+	return &Command{FFmpegPath: "ffmpeg", FFprobePath: "ffprobe"}
+}
+
+// stderrTail is how much of the end of stderr a failed run returns.
+const stderrTail = 2000
+
+func (c *Command) Run(ctx context.Context, args []string, total time.Duration, onProgress ProgressFunc) error {
+	if onProgress != nil {
+		args = append([]string{"-progress", "pipe:1", "-nostats"}, args...)
+	}
+	cmd := exec.CommandContext(ctx, c.FFmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	var stdout io.ReadCloser
+	if onProgress != nil {
+		var err error
+		if stdout, err = cmd.StdoutPipe(); err != nil {
+			return err
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if stdout != nil {
+		parseProgress(stdout, total, onProgress)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		tail := stderr.String()
+		if len(tail) > stderrTail {
+			tail = tail[len(tail)-stderrTail:]
+		}
+		return fmt.Errorf("%v, stderr: %s", err, tail)
+	}
+	return nil
+}
+
+func (c *Command) Duration(ctx context.Context, path string) (time.Duration, error) {
+	cmd := exec.CommandContext(ctx, c.FFprobePath, "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse duration of %s failed: %v", path, err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// parseProgress reads the key=value lines of ffmpeg -progress until r ends, every block ends with
+// progress=continue or progress=end and is reported to onProgress.
+func parseProgress(r io.Reader, total time.Duration, onProgress ProgressFunc) {
+	p := Progress{Total: total}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		// out_time_ms holds microseconds as well, older versions only write it
+		case "out_time_us", "out_time_ms":
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+				p.Processed = time.Duration(us) * time.Microsecond
+			}
+		case "speed":
+			if speed, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64); err == nil {
+				p.Speed = speed
+			}
+		case "progress":
+			p.Done = value == "end"
+			p.Percent = 0
+			if total > 0 {
+				p.Percent = math.Min(100, float64(p.Processed)/float64(total)*100)
+			}
+			if p.Done {
+				p.Percent = 100
+			}
+			onProgress(p)
+		}
+	}
+	// a reader stopping early would block ffmpeg on a full pipe
+	io.Copy(io.Discard, r)
+}
+
+// ExtractAudio extracts the audio of input, e.g. a video, into output as mp3, reporting the progress
+// to onProgress. A partial output is removed when the run fails or is canceled.
+func ExtractAudio(ctx context.Context, r Runner, input string, output string, onProgress ProgressFunc) error {
+	var total time.Duration
+	if onProgress != nil {
+		// without a duration the progress is still reported, only the percent is unknown
+		total, _ = r.Duration(ctx, input)
+	}
+	err := r.Run(ctx, []string{"-i", input, "-vn", "-acodec", "libmp3lame", output}, total, onProgress)
+	if err != nil {
+		os.Remove(output)
+	}
+	return err
+}
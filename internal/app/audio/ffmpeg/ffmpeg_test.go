@@ -0,0 +1,161 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		total  time.Duration
+		want   []Progress
+	}{
+		{
+			name:   "percent of the total",
+			output: "frame=0\nout_time_us=5000000\nspeed=2.5x\nprogress=continue\nout_time_us=10000000\nspeed=3x\nprogress=end\n",
+			total:  10 * time.Second,
+			want: []Progress{
+				{Processed: 5 * time.Second, Total: 10 * time.Second, Percent: 50, Speed: 2.5},
+				{Processed: 10 * time.Second, Total: 10 * time.Second, Percent: 100, Speed: 3, Done: true},
+			},
+		},
+		{
+			name:   "unknown total",
+			output: "out_time_ms=1500000\nspeed=N/A\nprogress=continue\n",
+			want:   []Progress{{Processed: 1500 * time.Millisecond}},
+		},
+		{
+			name:   "output longer than the probed total",
+			output: "out_time_us=12000000\nprogress=continue\n",
+			total:  10 * time.Second,
+			want:   []Progress{{Processed: 12 * time.Second, Total: 10 * time.Second, Percent: 100}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Progress
+			parseProgress(strings.NewReader(tt.output), tt.total, func(p Progress) { got = append(got, p) })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProgress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeFFmpeg writes a shell script standing in for ffmpeg.
+func fakeFFmpeg(t *testing.T, script string) *Command {
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Command{FFmpegPath: path, FFprobePath: path}
+}
+
+func TestCommand_Run(t *testing.T) {
+	c := fakeFFmpeg(t, `echo "out_time_us=1000000"; echo "progress=end"`)
+	var got []Progress
+	if err := c.Run(context.Background(), []string{"-i", "in.mp4"}, 2*time.Second, func(p Progress) { got = append(got, p) }); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(got) != 1 || !got[0].Done || got[0].Processed != time.Second {
+		t.Errorf("progress = %+v, want the end", got)
+	}
+
+	c = fakeFFmpeg(t, `echo "in.mp4: Invalid data found" >&2; exit 1`)
+	if err := c.Run(context.Background(), nil, 0, nil); err == nil || !strings.Contains(err.Error(), "Invalid data found") {
+		t.Errorf("Run() = %v, want the error with stderr", err)
+	}
+
+	c = fakeFFmpeg(t, `exec sleep 10`)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := c.Run(ctx, nil, 0, func(Progress) {}); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Errorf("Run() = %v after %v, want it canceled", err, time.Since(start))
+	}
+}
+
+// fakeRunner writes the output and reports half of the input done.
+type fakeRunner struct {
+	err  error
+	args []string
+}
+
+func (f *fakeRunner) Run(ctx context.Context, args []string, total time.Duration, onProgress ProgressFunc) error {
+	f.args = args
+	os.WriteFile(args[len(args)-1], []byte("partial"), 0644)
+	if onProgress != nil {
+		onProgress(Progress{Processed: total / 2, Total: total, Percent: 50})
+	}
+	return f.err
+}
+
+func (f *fakeRunner) Duration(ctx context.Context, path string) (time.Duration, error) {
+	return 4 * time.Second, nil
+}
+
+func TestExtractAudio(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantOutput bool
+	}{
+		{name: "extracted", wantOutput: true},
+		{name: "failed run removes the partial output", err: errors.New("exit status 1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "a.mp3")
+			r := &fakeRunner{err: tt.err}
+			var percent float64
+			err := ExtractAudio(context.Background(), r, "a.mp4", output, func(p Progress) { percent = p.Percent })
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ExtractAudio() error = %v, want %v", err, tt.err)
+			}
+			if _, statErr := os.Stat(output); (statErr == nil) != tt.wantOutput {
+				t.Errorf("output exists = %v, want %v", statErr == nil, tt.wantOutput)
+			}
+			if percent != 50 || r.args[0] != "-i" || r.args[1] != "a.mp4" {
+				t.Errorf("ran %v reporting %v%%, want the input and its progress", r.args, percent)
+			}
+		})
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    Info
+		wantErr bool
+	}{
+		{
+			name: "first audio stream",
+			output: `{"streams": [{"codec_type": "video", "codec_name": "h264"}, {"codec_type": "audio", "codec_name": "aac", "sample_rate": "44100", "channels": 2}],
+				"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "12.500000"}}`,
+			want: Info{Formats: []string{"mov", "mp4", "m4a", "3gp", "3g2", "mj2"}, Codec: "aac", SampleRate: 44100, Channels: 2, Duration: 12500 * time.Millisecond},
+		},
+		{
+			name:    "no audio",
+			output:  `{"streams": [{"codec_type": "video", "codec_name": "h264"}], "format": {"format_name": "mp4"}}`,
+			wantErr: true,
+		},
+		{name: "not json", output: "Invalid data found", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProbe("talk.mp4", []byte(tt.output))
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProbe() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info describes the audio of a media file as ffprobe reports it.
+type Info struct {
+	// Formats are the names ffprobe matches the container with, e.g. mov, mp4 and m4a for an m4a file.
+	Formats []string
+	// Codec is the codec of the first audio stream, e.g. aac or pcm_s16le.
+	Codec string
+	// SampleRate of the first audio stream in Hz.
+	SampleRate int
+	// Channels of the first audio stream.
+	Channels int
+	Duration time.Duration
+}
+
+// Prober describes media files.
+type Prober interface {
+	// Probe returns the container and first audio stream of the media at path, an error when it has no audio.
+	Probe(ctx context.Context, path string) (Info, error)
+}
+
+type probeOutput struct {
+	Streams []struct {
+		CodecType  string `json:"codec_type"`
+		CodecName  string `json:"codec_name"`
+		SampleRate string `json:"sample_rate"`
+		Channels   int    `json:"channels"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+	} `json:"format"`
+}
+
+func (c *Command) Probe(ctx context.Context, path string) (Info, error) {
+	cmd := exec.CommandContext(ctx, c.FFprobePath, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return Info{}, fmt.Errorf("probe %s failed: %v", path, err)
+	}
+	return parseProbe(path, output)
+}
+
+func parseProbe(path string, output []byte) (Info, error) {
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return Info{}, fmt.Errorf("parse probe of %s failed: %v", path, err)
+	}
+	info := Info{Formats: strings.Split(probe.Format.FormatName, ",")}
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	for _, s := range probe.Streams {
+		if s.CodecType != "audio" {
+			continue
+		}
+		info.Codec, info.Channels = s.CodecName, s.Channels
+		info.SampleRate, _ = strconv.Atoi(s.SampleRate)
+		return info, nil
+	}
+	return Info{}, fmt.Errorf("%s has no audio stream", path)
+}
@@ -0,0 +1,192 @@
+// Package vad finds the speech in audio with ffmpeg's silencedetect filter and cuts the long
+// silences out, so providers transcribe and bill less audio.
+package vad
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Options tune which silences are cut.
+type Options struct {
+	// MinSilence in seconds, shorter pauses are kept.
+	MinSilence float64
+	// NoiseDB is the level in dB below which audio counts as silence.
+	NoiseDB float64
+	// Padding in seconds kept around the speech, so the first and last sounds of a word aren't cut.
+	Padding float64
+}
+
+// DefaultOptions cut silences of two seconds and more.
+func DefaultOptions() Options {
+	return Options{MinSilence: 2, NoiseDB: -35, Padding: 0.25}
+}
+
+// Span is a part of the audio, in seconds.
+type Span struct {
+	Start float64
+	End   float64
+}
+
+// Result is the speech found in an audio file.
+type Result struct {
+	// Duration of the whole audio in seconds.
+	Duration float64
+	// Speech are the spans kept, in order and not overlapping.
+	Speech []Span
+}
+
+// SpeechSeconds returns the length of the kept audio.
+func (r Result) SpeechSeconds() float64 {
+	var seconds float64
+	for _, s := range r.Speech {
+		seconds += s.End - s.Start
+	}
+	return seconds
+}
+
+// TrimmedSeconds returns the length of the silence cut out.
+func (r Result) TrimmedSeconds() float64 {
+	return r.Duration - r.SpeechSeconds()
+}
+
+// Original maps a time of the trimmed audio back to the time in the whole audio.
+func (r Result) Original(t float64) float64 {
+	var offset float64
+	for _, s := range r.Speech {
+		length := s.End - s.Start
+		if t < offset+length {
+			return s.Start + t - offset
+		}
+		offset += length
+	}
+	if len(r.Speech) == 0 {
+		return t
+	}
+	last := r.Speech[len(r.Speech)-1]
+	return last.End + t - offset
+}
+
+// Trimmed maps a time of the whole audio to the time in the trimmed audio, a time within a cut
+// maps to where the speech after it starts.
+func (r Result) Trimmed(t float64) float64 {
+	var trimmed float64
+	for _, s := range r.Speech {
+		if t <= s.Start {
+			break
+		}
+		trimmed += min(t, s.End) - s.Start
+	}
+	return trimmed
+}
+
+// Detect runs silencedetect over the audio and returns the speech around the silences.
+func Detect(inputFilePath string, opts Options) (Result, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-i", inputFilePath,
+		"-af", fmt.Sprintf("silencedetect=noise=%gdB:d=%g", opts.NoiseDB, opts.MinSilence), "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Result{}, fmt.Errorf("FFmpeg error: %v, stderr: %s", err, stderr.String())
+	}
+
+	duration, silences, err := parseSilenceDetect(stderr.String())
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Duration: duration, Speech: speech(silences, duration, opts.Padding)}, nil
+}
+
+// Trim writes the speech of the audio to outputFilePath as 16kHz mono wav, which every provider takes.
+func Trim(inputFilePath string, outputFilePath string, r Result) error {
+	cmd := exec.Command("ffmpeg", "-y", "-i", inputFilePath, "-vn", "-af", Filter(r),
+		"-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", outputFilePath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("FFmpeg error: %v, stderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// Filter is the ffmpeg audio filter keeping only the speech of r, back to back.
+func Filter(r Result) string {
+	selects := make([]string, 0, len(r.Speech))
+	for _, s := range r.Speech {
+		selects = append(selects, fmt.Sprintf("between(t,%.3f,%.3f)", s.Start, s.End))
+	}
+	return fmt.Sprintf("aselect='%s',asetpts=N/SR/TB", strings.Join(selects, "+"))
+}
+
+var (
+	durationPattern     = regexp.MustCompile(`Duration: (\d+):(\d+):(\d+(?:\.\d+)?)`)
+	silenceStartPattern = regexp.MustCompile(`silence_start: (-?\d+(?:\.\d+)?)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end: (\d+(?:\.\d+)?)`)
+)
+
+// parseSilenceDetect reads the duration of the input and the silences from the log of ffmpeg.
+// A silence lasting until the end of the audio has no silence_end.
+func parseSilenceDetect(log string) (float64, []Span, error) {
+	m := durationPattern.FindStringSubmatch(log)
+	if m == nil {
+		return 0, nil, fmt.Errorf("no duration in the ffmpeg output")
+	}
+	hours, _ := strconv.ParseFloat(m[1], 64)
+	minutes, _ := strconv.ParseFloat(m[2], 64)
+	seconds, _ := strconv.ParseFloat(m[3], 64)
+	duration := hours*3600 + minutes*60 + seconds
+
+	var silences []Span
+	open := false
+	for _, line := range strings.Split(log, "\n") {
+		if m := silenceStartPattern.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.ParseFloat(m[1], 64)
+			silences = append(silences, Span{Start: max(start, 0), End: duration})
+			open = true
+		} else if m := silenceEndPattern.FindStringSubmatch(line); m != nil && open {
+			silences[len(silences)-1].End, _ = strconv.ParseFloat(m[1], 64)
+			open = false
+		}
+	}
+	return duration, silences, nil
+}
+
+// speech returns the audio between the silences, widened by padding and merged where they touch.
+func speech(silences []Span, duration float64, padding float64) []Span {
+	var spans []Span
+	start := 0.0
+	for _, s := range append(silences, Span{Start: duration, End: duration}) {
+		if s.Start > start {
+			spans = append(spans, Span{Start: max(start-padding, 0), End: min(s.Start+padding, duration)})
+		}
+		start = s.End
+	}
+
+	merged := spans[:0]
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, s.End)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+func min(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,112 @@
+package vad
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseSilenceDetect(t *testing.T) {
+	log := `Input #0, mp3, from 'talk.mp3':
+  Duration: 00:01:02.50, start: 0.025057, bitrate: 128 kb/s
+[silencedetect @ 0x7f] silence_start: -0.01
+[silencedetect @ 0x7f] silence_end: 3.2 | silence_duration: 3.21
+[silencedetect @ 0x7f] silence_start: 20.5
+[silencedetect @ 0x7f] silence_end: 25 | silence_duration: 4.5
+[silencedetect @ 0x7f] silence_start: 58.75
+size=N/A time=00:01:02.50 bitrate=N/A speed= 600x`
+
+	duration, silences, err := parseSilenceDetect(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Span{{Start: 0, End: 3.2}, {Start: 20.5, End: 25}, {Start: 58.75, End: 62.5}}
+	if duration != 62.5 || !reflect.DeepEqual(silences, want) {
+		t.Errorf("parseSilenceDetect() = %v, %v, want 62.5, %v", duration, silences, want)
+	}
+
+	if _, _, err = parseSilenceDetect("Invalid data found when processing input"); err == nil {
+		t.Error("parseSilenceDetect() without a duration error = nil")
+	}
+}
+
+func Test_speech(t *testing.T) {
+	tests := []struct {
+		name     string
+		silences []Span
+		padding  float64
+		want     []Span
+	}{
+		{name: "no silence", want: []Span{{Start: 0, End: 60}}},
+		{
+			name:     "padded",
+			silences: []Span{{Start: 0, End: 3}, {Start: 20, End: 25}, {Start: 58, End: 60}},
+			padding:  0.5,
+			want:     []Span{{Start: 2.5, End: 20.5}, {Start: 24.5, End: 58.5}},
+		},
+		{
+			name:     "padding bridges a short gap",
+			silences: []Span{{Start: 10, End: 10.8}},
+			padding:  0.5,
+			want:     []Span{{Start: 0, End: 60}},
+		},
+		{name: "only silence", silences: []Span{{Start: 0, End: 60}}, want: []Span{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := speech(tt.silences, 60, tt.padding)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("speech() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResult_Original(t *testing.T) {
+	r := Result{Duration: 60, Speech: []Span{{Start: 2, End: 20}, {Start: 25, End: 58}}}
+	if r.TrimmedSeconds() != 9 {
+		t.Errorf("TrimmedSeconds() = %v, want 9", r.TrimmedSeconds())
+	}
+
+	tests := []struct {
+		t    float64
+		want float64
+	}{
+		{t: 0, want: 2},
+		{t: 10, want: 12},
+		{t: 18, want: 25},
+		{t: 30, want: 37},
+		{t: 52, want: 59},
+	}
+	for _, tt := range tests {
+		if got := r.Original(tt.t); got != tt.want {
+			t.Errorf("Original(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestResult_Trimmed(t *testing.T) {
+	r := Result{Duration: 60, Speech: []Span{{Start: 2, End: 20}, {Start: 25, End: 58}}}
+	tests := []struct {
+		t    float64
+		want float64
+	}{
+		{t: 0, want: 0},
+		{t: 12, want: 10},
+		{t: 22, want: 18},
+		{t: 37, want: 30},
+		{t: 60, want: 51},
+	}
+	for _, tt := range tests {
+		if got := r.Trimmed(tt.t); got != tt.want {
+			t.Errorf("Trimmed(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+		if tt.t > 2 && tt.t < 58 && tt.t != 22 {
+			if got := r.Original(r.Trimmed(tt.t)); got != tt.t {
+				t.Errorf("Original(Trimmed(%v)) = %v", tt.t, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Package capability tells whether the optional subsystems v2t builds on are available: ffmpeg
+// converting audio, the language model of chat, generate and the llm translation backend, and the
+// embedding model of the vector index. Commands require the capabilities they need up front and fail with
+// an Error saying how to enable the missing one, rather than with a fatal error deep inside, and
+// the work that doesn't need it still runs.
+package capability
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/embedding/provider"
+	"tiktok-whisper/internal/app/llm"
+	"tiktok-whisper/internal/app/logging"
+)
+
+// The capabilities of the default registry.
+const (
+	// FFmpeg converts the audio providers don't accept as it is and probes its format and duration.
+	FFmpeg = "ffmpeg"
+	// LLM is the language model of llm in config.yaml.
+	LLM = "llm"
+	// Embeddings is the embedding model of embedding in config.yaml.
+	Embeddings = "embeddings"
+)
+
+// ErrUnavailable is wrapped by the errors of the capabilities that are unavailable.
+var ErrUnavailable = errors.New("capability unavailable")
+
+// Error is a capability that is unavailable, with the reason and how to enable it.
+type Error struct {
+	Name     string
+	Err      error
+	Guidance string
+}
+
+func (e *Error) Error() string {
+	if e.Guidance == "" {
+		return fmt.Sprintf("%s is unavailable: %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s is unavailable: %v. %s", e.Name, e.Err, e.Guidance)
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{ErrUnavailable, e.Err}
+}
+
+// Status is whether a capability is available, and why not and how to enable it when it isn't.
+type Status struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Error     string `json:"error,omitempty"`
+	Guidance  string `json:"guidance,omitempty"`
+}
+
+type capability struct {
+	probe    func() error
+	guidance string
+}
+
+// Registry probes the capabilities registered with it, once each.
+type Registry struct {
+	mu     sync.Mutex
+	names  []string
+	caps   map[string]capability
+	probed map[string]error
+	warned map[string]bool
+}
+
+// NewRegistry creates a registry without capabilities.
+func NewRegistry() *Registry {
+	return &Registry{caps: map[string]capability{}, probed: map[string]error{}, warned: map[string]bool{}}
+}
+
+// Register adds the capability name, available when probe succeeds. guidance tells how to enable it.
+func (r *Registry) Register(name string, guidance string, probe func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.caps[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.caps[name] = capability{probe: probe, guidance: guidance}
+	delete(r.probed, name)
+}
+
+// Check returns nil when the capability name is available, otherwise an *Error. The capability
+// is probed on the first check only.
+func (r *Registry) Check(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.caps[name]
+	if !ok {
+		return &Error{Name: name, Err: errors.New("unknown capability")}
+	}
+	err, probed := r.probed[name]
+	if !probed {
+		err = c.probe()
+		r.probed[name] = err
+	}
+	if err != nil {
+		return &Error{Name: name, Err: err, Guidance: c.guidance}
+	}
+	return nil
+}
+
+// Available reports whether the capability name is available.
+func (r *Registry) Available(name string) bool {
+	return r.Check(name) == nil
+}
+
+// Require returns the error of the first of names that is unavailable, nil when all are available.
+func (r *Registry) Require(names ...string) error {
+	for _, name := range names {
+		if err := r.Check(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Warn logs once per capability that name is unavailable and what degrades, e.g. which files
+// are skipped. It reports whether name is available.
+func (r *Registry) Warn(name string, degraded string) bool {
+	err := r.Check(name)
+	if err == nil {
+		return true
+	}
+	r.mu.Lock()
+	warned := r.warned[name]
+	r.warned[name] = true
+	r.mu.Unlock()
+	if !warned {
+		logging.L().Warn("Capability unavailable, "+degraded, "capability", name, "error", err)
+	}
+	return false
+}
+
+// Statuses returns the status of every capability in the order they were registered.
+func (r *Registry) Statuses() []Status {
+	r.mu.Lock()
+	names := append([]string(nil), r.names...)
+	r.mu.Unlock()
+	statuses := make([]Status, 0, len(names))
+	for _, name := range names {
+		s := Status{Name: name, Available: true}
+		var ce *Error
+		if errors.As(r.Check(name), &ce) {
+			s.Available, s.Error, s.Guidance = false, ce.Err.Error(), ce.Guidance
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+var defaultRegistry = newDefault()
+
+// newDefault registers the capabilities v2t checks.
+func newDefault() *Registry {
+	r := NewRegistry()
+	r.Register(FFmpeg, "Install ffmpeg, e.g. brew install ffmpeg or apt install ffmpeg, or supply audio the provider accepts as it is, like 16kHz WAV for whisper.cpp",
+		func() error {
+			for _, tool := range []string{"ffmpeg", "ffprobe"} {
+				if _, err := exec.LookPath(tool); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	r.Register(LLM, "Set the OpenAI key with v2t config set-key openai or OPENAI_API_KEY, or use a local model with llm.backend: ollama in config.yaml",
+		func() error {
+			_, err := llm.Default()
+			return err
+		})
+	r.Register(Embeddings, "Set the OpenAI key with v2t config set-key openai or OPENAI_API_KEY, or use a local model with embedding.provider: ollama in config.yaml",
+		func() error {
+			_, err := provider.New(config.Get().Embedding)
+			return err
+		})
+	return r
+}
+
+// Default returns the registry of the capabilities v2t checks.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Require returns the error of the first of names the default registry finds unavailable.
+func Require(names ...string) error {
+	return defaultRegistry.Require(names...)
+}
+
+// Available reports whether the default registry finds the capability name available.
+func Available(name string) bool {
+	return defaultRegistry.Available(name)
+}
+
+// Warn logs once that the capability name of the default registry is unavailable and what degrades.
+func Warn(name string, degraded string) bool {
+	return defaultRegistry.Warn(name, degraded)
+}
@@ -0,0 +1,82 @@
+package capability
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/testutil"
+)
+
+func TestRegistry_Require(t *testing.T) {
+	missing := errors.New("not found")
+	tests := []struct {
+		name      string
+		require   []string
+		wantName  string
+		wantError bool
+	}{
+		{name: "available", require: []string{"ffmpeg"}},
+		{name: "unavailable", require: []string{"ffmpeg", "llm"}, wantName: "llm", wantError: true},
+		{name: "first unavailable", require: []string{"llm", "embeddings"}, wantName: "llm", wantError: true},
+		{name: "unknown", require: []string{"pgvector"}, wantName: "pgvector", wantError: true},
+		{name: "nothing", require: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.Register("ffmpeg", "install ffmpeg", func() error { return nil })
+			r.Register("llm", "set a key", func() error { return missing })
+			r.Register("embeddings", "set a key", func() error { return missing })
+
+			err := r.Require(tt.require...)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("Require() error = %v, want an error %v", err, tt.wantError)
+			}
+			if err == nil {
+				return
+			}
+			var ce *Error
+			if !errors.As(err, &ce) || ce.Name != tt.wantName || !errors.Is(err, ErrUnavailable) {
+				t.Errorf("Require() error = %v, want %s unavailable", err, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestRegistry_ProbesOnce(t *testing.T) {
+	testutil.UseMockLogger(t)
+	probes := 0
+	r := NewRegistry()
+	r.Register("ffmpeg", "install ffmpeg", func() error {
+		probes++
+		return errors.New("not found")
+	})
+
+	for i := 0; i < 3; i++ {
+		if r.Available("ffmpeg") || r.Warn("ffmpeg", "converting nothing") {
+			t.Fatal("ffmpeg is available, want unavailable")
+		}
+	}
+	if probes != 1 {
+		t.Errorf("probed %d times, want once", probes)
+	}
+
+	err := r.Check("ffmpeg")
+	if want := "ffmpeg is unavailable: not found. install ffmpeg"; err == nil || err.Error() != want {
+		t.Errorf("Check() = %v, want %q", err, want)
+	}
+}
+
+func TestRegistry_Statuses(t *testing.T) {
+	r := NewRegistry()
+	r.Register("ffmpeg", "install ffmpeg", func() error { return nil })
+	r.Register("llm", "set a key", func() error { return errors.New("no key") })
+
+	want := []Status{
+		{Name: "ffmpeg", Available: true},
+		{Name: "llm", Error: "no key", Guidance: "set a key"},
+	}
+	if got := r.Statuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statuses() = %+v, want %+v", got, want)
+	}
+}
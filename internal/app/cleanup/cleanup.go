@@ -0,0 +1,218 @@
+package cleanup
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/util/files"
+)
+
+const manifestPrefix = "manifest-"
+
+// Tracker records the temp files created for each job in a per-process manifest on disk,
+// so they are removed when the job finishes and can still be swept after a crash.
+type Tracker struct {
+	mu      sync.Mutex
+	dir     string
+	pid     int
+	entries map[string][]string
+}
+
+var (
+	once      sync.Once
+	singleton *Tracker
+)
+
+// Default returns the tracker storing its manifests and temp files under data/tmp.
+func Default() *Tracker {
+	once.Do(func() {
+		root, err := files.GetProjectRoot()
+		if err != nil {
+			log.Fatalf("Failed to get project root: %v\n", err)
+		}
+		singleton = NewTracker(filepath.Join(root, "data", "tmp"))
+	})
+
+	return singleton
+}
+
+// NewTracker creates a tracker keeping its manifest in dir.
+func NewTracker(dir string) *Tracker {
+	return &Tracker{
+		dir:     dir,
+		pid:     os.Getpid(),
+		entries: make(map[string][]string),
+	}
+}
+
+// Dir is where callers should create their temp files.
+func (t *Tracker) Dir() string {
+	return t.dir
+}
+
+// Track registers path as a temp file of job, it must be called before the file is created.
+func (t *Tracker) Track(job string, path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, p := range t.entries[job] {
+		if p == path {
+			return nil
+		}
+	}
+	t.entries[job] = append(t.entries[job], path)
+	return t.save()
+}
+
+// Keep stops tracking path without removing it, used once an intermediate file
+// is complete and meant to outlive the job.
+func (t *Tracker) Keep(job string, path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	paths := t.entries[job]
+	for i, p := range paths {
+		if p == path {
+			paths = append(paths[:i], paths[i+1:]...)
+			break
+		}
+	}
+
+	if len(paths) == 0 {
+		delete(t.entries, job)
+	} else {
+		t.entries[job] = paths
+	}
+	return t.save()
+}
+
+// Release removes every temp file of job and returns the number of bytes reclaimed.
+// It is idempotent, files that are already gone are ignored.
+func (t *Tracker) Release(job string) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reclaimed, err := removeAll(t.entries[job])
+	delete(t.entries, job)
+
+	if saveErr := t.save(); err == nil {
+		err = saveErr
+	}
+	return reclaimed, err
+}
+
+// Sweep removes the temp files listed in manifests of processes that are no longer running,
+// i.e. files left behind by a crashed or killed run.
+func (t *Tracker) Sweep() (int64, error) {
+	manifests, err := filepath.Glob(filepath.Join(t.dir, manifestPrefix+"*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	var reclaimed int64
+	for _, manifest := range manifests {
+		pid, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(manifest), manifestPrefix), ".json"))
+		if err != nil || pid == t.pid || processAlive(pid) {
+			continue
+		}
+
+		entries, err := readManifest(manifest)
+		if err != nil {
+			log.Printf("Skip unreadable temp manifest %s: %v\n", manifest, err)
+			continue
+		}
+
+		for job, paths := range entries {
+			n, err := removeAll(paths)
+			if err != nil {
+				log.Printf("Failed to sweep temp files of %s: %v\n", job, err)
+			}
+			reclaimed += n
+		}
+
+		if err = os.Remove(manifest); err != nil && !os.IsNotExist(err) {
+			return reclaimed, err
+		}
+	}
+	return reclaimed, nil
+}
+
+func (t *Tracker) manifestPath() string {
+	return filepath.Join(t.dir, fmt.Sprintf("%s%d.json", manifestPrefix, t.pid))
+}
+
+// save persists the entries, the manifest is removed once nothing is tracked anymore.
+func (t *Tracker) save() error {
+	path := t.manifestPath()
+	if len(t.entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return fmt.Errorf("create temp dir err: %v", err)
+	}
+
+	data, err := json.Marshal(t.entries)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readManifest(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string][]string)
+	err = json.Unmarshal(data, &entries)
+	return entries, err
+}
+
+func removeAll(paths []string) (int64, error) {
+	var reclaimed int64
+	var firstErr error
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			reclaimed += info.Size()
+		}
+
+		if err = os.Remove(p); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return reclaimed, firstErr
+}
+
+// FormatBytes renders n for log messages about reclaimed space.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,90 @@
+package cleanup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrackerRelease(t *testing.T) {
+	dir := t.TempDir()
+	tracker := NewTracker(dir)
+
+	wav := filepath.Join(dir, "a_16khz.wav")
+	if err := tracker.Track("a.mp3", wav); err != nil {
+		t.Fatalf("Track() error = %v", err)
+	}
+	if err := os.WriteFile(wav, make([]byte, 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(tracker.manifestPath()); err != nil {
+		t.Errorf("manifest not written: %v", err)
+	}
+
+	reclaimed, err := tracker.Release("a.mp3")
+	if err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if reclaimed != 2048 {
+		t.Errorf("Release() reclaimed = %v, want 2048", reclaimed)
+	}
+	if _, err = os.Stat(wav); !os.IsNotExist(err) {
+		t.Errorf("temp file still exists")
+	}
+	if _, err = os.Stat(tracker.manifestPath()); !os.IsNotExist(err) {
+		t.Errorf("manifest still exists after releasing every job")
+	}
+
+	// releasing twice is a no-op
+	if reclaimed, err = tracker.Release("a.mp3"); err != nil || reclaimed != 0 {
+		t.Errorf("second Release() = %v, %v", reclaimed, err)
+	}
+}
+
+func TestTrackerSweep(t *testing.T) {
+	dir := t.TempDir()
+
+	// simulate a crashed run whose pid can't be alive
+	crashed := NewTracker(dir)
+	crashed.pid = 1 << 30
+	leftover := filepath.Join(dir, "partial.wav")
+	if err := crashed.Track("b.mp3", leftover); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(leftover, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reclaimed, err := NewTracker(dir).Sweep()
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if reclaimed != 100 {
+		t.Errorf("Sweep() reclaimed = %v, want 100", reclaimed)
+	}
+	if _, err = os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("leftover file still exists")
+	}
+	if _, err = os.Stat(crashed.manifestPath()); !os.IsNotExist(err) {
+		t.Errorf("crashed manifest still exists")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{n: 512, want: "512 B"},
+		{n: 1536, want: "1.5 KiB"},
+		{n: 3 << 20, want: "3.0 MiB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatBytes(tt.n); got != tt.want {
+				t.Errorf("FormatBytes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
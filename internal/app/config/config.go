@@ -0,0 +1,452 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the user editable settings of v2t, loaded from config.yaml.
+type Config struct {
+	// Language of the CLI messages, e.g. zh or en. The environment's LANG is used when empty.
+	Language   string          `yaml:"language"`
+	Analytics  AnalyticsConfig `yaml:"analytics"`
+	Events     EventsConfig    `yaml:"events"`
+	Locale     LocaleConfig    `yaml:"locale"`
+	Paragraphs ParagraphConfig `yaml:"paragraphs"`
+	Broadcast  BroadcastConfig `yaml:"broadcast"`
+	Databases  DatabaseConfig  `yaml:"databases"`
+	// Middlewares wrap the transcriber of convert, the first one is the outermost.
+	Middlewares []MiddlewareConfig `yaml:"middlewares"`
+	Validation  ValidationConfig   `yaml:"validation"`
+	Diarization DiarizationConfig  `yaml:"diarization"`
+	Cost        CostConfig         `yaml:"cost"`
+	Chunking    ChunkingConfig     `yaml:"chunking"`
+	Verbatim    VerbatimConfig     `yaml:"verbatim"`
+	Translation TranslationConfig  `yaml:"translation"`
+	LLM         LLMConfig          `yaml:"llm"`
+	Embedding   EmbeddingConfig    `yaml:"embedding"`
+	Refine      RefineConfig       `yaml:"refine"`
+	Moderation  ModerationConfig   `yaml:"moderation"`
+	Secrets     SecretsConfig      `yaml:"secrets"`
+	// SLOs maps a provider name such as openai or whisper_cpp to its objectives, checked by v2t serve.
+	SLOs map[string]SLOConfig `yaml:"slos"`
+}
+
+// AnalyticsConfig configures what the analytics commands may expose.
+type AnalyticsConfig struct {
+	// DefaultRole is used when the caller does not specify a role.
+	DefaultRole string `yaml:"default_role"`
+	// Roles maps a role name to the privacy policy applied to it.
+	Roles map[string]AnalyticsPolicy `yaml:"roles"`
+	// FillerWords are counted by the speech analytics in addition to the built-in ones, e.g. "like".
+	FillerWords []string `yaml:"filler_words"`
+}
+
+// AnalyticsPolicy limits the aggregated statistics a role can see.
+type AnalyticsPolicy struct {
+	// MinGroupSize suppresses every group with fewer rows than this.
+	MinGroupSize int `yaml:"min_group_size"`
+	// Epsilon enables Laplace noise on the aggregates when greater than zero,
+	// smaller values mean more noise.
+	Epsilon float64 `yaml:"epsilon"`
+	// HideUserNames replaces user names with stable anonymous labels.
+	HideUserNames bool `yaml:"hide_user_names"`
+}
+
+// EventsConfig selects the event bus carrying pipeline events.
+type EventsConfig struct {
+	// Backend is "inprocess" (default) or "nats", the latter needs a build with -tags nats.
+	Backend string     `yaml:"backend"`
+	NATS    NATSConfig `yaml:"nats"`
+}
+
+// NATSConfig configures the nats event bus.
+type NATSConfig struct {
+	// URL of an external nats server, an embedded server is started when empty.
+	URL      string `yaml:"url"`
+	Embedded bool   `yaml:"embedded"`
+	// Port of the embedded server, a random port is used when zero.
+	Port int `yaml:"port"`
+}
+
+// LocaleConfig selects the locale generated artifacts are formatted for.
+type LocaleConfig struct {
+	// Default applies to users without their own setting, the environment's LANG is used when empty.
+	Default string `yaml:"default"`
+	// Users maps a user nickname to a locale tag such as zh-CN.
+	Users map[string]string `yaml:"users"`
+}
+
+// For returns the locale tag configured for user, it is empty when nothing is configured.
+func (c LocaleConfig) For(user string) string {
+	if tag, ok := c.Users[user]; ok {
+		return tag
+	}
+	return c.Default
+}
+
+// ParagraphConfig controls how text, Markdown and HTML outputs group segments into paragraphs.
+type ParagraphConfig struct {
+	// MaxLength in characters, a paragraph ends at the first sentence boundary past it, 0 disables it.
+	MaxLength int `yaml:"max_length"`
+	// Pause in seconds between two segments that starts a new paragraph, 0 disables it.
+	Pause float64 `yaml:"pause"`
+}
+
+// BroadcastConfig configures the profanity-safe variant written next to the regular outputs.
+type BroadcastConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Mode is "star" (f***, default) or "bleep" ([bleep]).
+	Mode string `yaml:"mode"`
+	// WordsFile extends the built-in word list, one word or phrase per line.
+	WordsFile string   `yaml:"words_file"`
+	Words     []string `yaml:"words"`
+}
+
+// MiddlewareConfig enables one transcriber middleware, only the fields of its kind are used.
+type MiddlewareConfig struct {
+	// Name is retry, rate_limit, cache, metrics, cost or circuit_breaker.
+	Name string `yaml:"name"`
+	// Attempts and Backoff configure retry, the backoff doubles after every failed attempt.
+	Attempts int           `yaml:"attempts"`
+	Backoff  time.Duration `yaml:"backoff"`
+	// RequestsPerMinute configures rate_limit.
+	RequestsPerMinute float64 `yaml:"requests_per_minute"`
+	// Dir configures cache, the user cache directory is used when empty.
+	Dir string `yaml:"dir"`
+	// CostPerMinute of audio configures cost, e.g. 0.006 for the OpenAI whisper API.
+	CostPerMinute float64 `yaml:"cost_per_minute"`
+	// Failures in a row open the circuit_breaker, it lets a trial call through after Cooldown.
+	Failures int           `yaml:"failures"`
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// ValidationConfig enables the sanity checks of transcription results.
+type ValidationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Retry transcribes results failing a check again with stricter decoding settings.
+	Retry bool `yaml:"retry"`
+}
+
+// DiarizationConfig selects the service labeling transcript segments with their speakers.
+type DiarizationConfig struct {
+	// Backend is "http" for a pyannote-compatible service or "binary" for a local program.
+	Backend string `yaml:"backend"`
+	// URL the http backend posts the audio to, Headers are sent along, e.g. Authorization.
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// Binary is run with Args followed by the audio path and prints RTTM or JSON turns.
+	Binary  string        `yaml:"binary"`
+	Args    []string      `yaml:"args"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// CostConfig prices transcriptions and caps the monthly spending.
+type CostConfig struct {
+	// Rates maps a provider to its price per audio minute, transcriptions priced by the cost
+	// middleware keep that price.
+	Rates map[string]float64 `yaml:"rates"`
+	// MonthlyBudget stops conversions once the costs of the calendar month reach it, zero disables it.
+	MonthlyBudget float64 `yaml:"monthly_budget"`
+}
+
+// ChunkingConfig controls how long audio is cut into chunks transcribed one by one.
+type ChunkingConfig struct {
+	// Seconds is the chunk length, zero keeps the provider's default: 600 for openai,
+	// whisper.cpp transcribes the whole file.
+	Seconds int `yaml:"seconds"`
+	// Overlap in seconds each chunk reaches into the next one, so words cut at a boundary are heard
+	// whole once. The text heard twice is kept once.
+	Overlap int `yaml:"overlap"`
+	// Parallel chunks are transcribed at the same time, more than one means no chunk gets the
+	// text of the previous one as its prompt.
+	Parallel int `yaml:"parallel"`
+}
+
+// VerbatimConfig sets up the verbatim mode for legal transcripts: fillers, false starts and stutters
+// are kept as spoken, nothing is dropped as silence, and the legal export numbers the lines.
+type VerbatimConfig struct {
+	// Default applies to users without their own setting. convert resolves the setting of its user, or
+	// --verbatim, into Default before the providers are created.
+	Default bool `yaml:"default"`
+	// Users maps a user nickname to whether their conversions are verbatim.
+	Users map[string]bool `yaml:"users"`
+	// InaudibleProbability marks the words the provider is less confident in as inaudible in the
+	// legal export, e.g. 0.3. 0 marks only the passages the provider reported as inaudible.
+	InaudibleProbability float64 `yaml:"inaudible_probability"`
+	// LinesPerPage and LineWidth, in characters, of the legal export, 25 and 60 when zero.
+	LinesPerPage int `yaml:"lines_per_page"`
+	LineWidth    int `yaml:"line_width"`
+}
+
+// For reports whether the conversions of user are verbatim.
+func (c VerbatimConfig) For(user string) bool {
+	if verbatim, ok := c.Users[user]; ok {
+		return verbatim
+	}
+	return c.Default
+}
+
+// TranslationConfig selects the service translating stored transcriptions. The API keys are read
+// from the environment: OPENAI_API_KEY, GEMINI_API_KEY or DEEPL_AUTH_KEY.
+type TranslationConfig struct {
+	// Backend is "openai" (default), "gemini", "deepl" or "llm" for the model of LLMConfig.
+	Backend string `yaml:"backend"`
+	// Model of openai or gemini, each has a default. DeepL has none.
+	Model string `yaml:"model"`
+	// URL replaces the endpoint of the backend, e.g. a proxy or the DeepL Pro API.
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// LLMConfig selects the language model of chat, generate and the llm translation backend.
+type LLMConfig struct {
+	// Backend is "openai" (default), "ollama" for a local Ollama server or "openai_compatible" for any
+	// server with the OpenAI chat API, e.g. llama.cpp or LM Studio.
+	Backend string `yaml:"backend"`
+	// Model defaults to gpt-3.5-turbo on openai, the other backends need one, e.g. llama3.1.
+	Model string `yaml:"model"`
+	// URL of the API, http://localhost:11434/v1 for ollama unless set. openai_compatible needs one.
+	URL string `yaml:"url"`
+	// KeyEnv is the environment variable holding the key of an openai_compatible server that needs one.
+	KeyEnv  string        `yaml:"key_env"`
+	Timeout time.Duration `yaml:"timeout"`
+	// ContextTokens is the context window of the model, 4096 unless set. A transcript longer than the
+	// budget of its feature is condensed part by part before the request instead of being cut.
+	ContextTokens int `yaml:"context_tokens"`
+	// Budgets maps a feature such as summary or chapters to the tokens of transcript it sends in one
+	// request, half of ContextTokens unless set.
+	Budgets map[string]int `yaml:"budgets"`
+	// InputPrice and OutputPrice per million tokens estimate the cost of the requests, local models
+	// leave them zero.
+	InputPrice  float64        `yaml:"input_price"`
+	OutputPrice float64        `yaml:"output_price"`
+	Cache       LLMCacheConfig `yaml:"cache"`
+}
+
+// EmbeddingConfig is the model embedding the transcripts for the vector index, e.g.
+//
+//	embedding:
+//	  provider: ollama
+//	  model: nomic-embed-text
+type EmbeddingConfig struct {
+	// Provider is "openai" (default), "ollama" for a local Ollama server or "http" for any server
+	// answering a JSON list of texts with their vectors, e.g. a sentence-transformers server.
+	Provider string `yaml:"provider"`
+	// Model defaults to text-embedding-3-small on openai, ollama needs one, e.g. nomic-embed-text.
+	Model string `yaml:"model"`
+	// URL is the base URL of the API, http://localhost:11434 for ollama unless set, and the endpoint
+	// the texts are posted to for http, which needs one.
+	URL string `yaml:"url"`
+	// KeyEnv is the environment variable holding the key of an http server that needs one.
+	KeyEnv string `yaml:"key_env"`
+	// InputField is the field of the request body http sends the texts in, input unless set, e.g.
+	// inputs for text-embeddings-inference.
+	InputField string `yaml:"input_field"`
+	// Dimensions of the vectors, the vectors answered are checked against it. openai shortens its
+	// vectors to it, the other providers report theirs as the model makes them when it is zero.
+	Dimensions int           `yaml:"dimensions"`
+	Timeout    time.Duration `yaml:"timeout"`
+	// BatchSize is the number of transcripts v2t embed sends per request, 16 unless set.
+	BatchSize int `yaml:"batch_size"`
+	// MaxChars truncates the transcripts v2t embed sends, 8000 characters unless set, as the models
+	// only read a few thousand tokens.
+	MaxChars int `yaml:"max_chars"`
+	// Interval of v2t embed daemon between two backfills, 10m unless set.
+	Interval time.Duration `yaml:"interval"`
+	// RequestsPerMinute maps a provider to the requests v2t embed sends it per minute, e.g.
+	// openai: 500, unlimited for the providers left out.
+	RequestsPerMinute map[string]float64 `yaml:"requests_per_minute"`
+}
+
+// LLMCacheConfig keeps the replies of the language model and the embeddings on disk, so running a
+// feature again over unchanged data after a config tweak costs nothing.
+type LLMCacheConfig struct {
+	// Disabled sends every request.
+	Disabled bool `yaml:"disabled"`
+	// Dir defaults to v2t/llm in the user cache directory.
+	Dir string `yaml:"dir"`
+	// TTL is how long a response is used, 30 days unless set.
+	TTL time.Duration `yaml:"ttl"`
+	// MaxMB bounds the size of the cache, the oldest responses are removed beyond it. 100 unless set.
+	MaxMB int `yaml:"max_mb"`
+}
+
+// RefineConfig sets up the two-pass mode: convert --draft transcribes with a fast model, so the text is
+// searchable right away, and v2t refine transcribes the drafts again with a bigger model off-peak.
+type RefineConfig struct {
+	// DraftModel is the whisper.cpp model of the draft pass, e.g. ggml-base.bin. It is required for --draft.
+	DraftModel string `yaml:"draft_model"`
+	// Model is the whisper.cpp model of the refine pass, the default local model when empty.
+	Model string `yaml:"model"`
+	// Window is the daily time range refine runs in, e.g. 01:00-06:00, any time when empty.
+	Window string `yaml:"window"`
+}
+
+// ModerationConfig sets up the screening of transcripts for harmful content run by v2t moderate,
+// and what happens to the transcriptions it flags.
+type ModerationConfig struct {
+	// Backend is "openai" (default) for the moderation API, its key is read from OPENAI_API_KEY,
+	// or "http" for a local classifier.
+	Backend string `yaml:"backend"`
+	// Model of openai, e.g. omni-moderation-latest, the API picks one when empty.
+	Model string `yaml:"model"`
+	// URL replaces the endpoint of openai, the http backend posts the text to it. Headers are sent along.
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Timeout time.Duration     `yaml:"timeout"`
+	// Threshold flags the categories scoring at least this much, e.g. 0.5. The backend decides when it is zero.
+	Threshold float64 `yaml:"threshold"`
+	// Actions taken on flagged transcriptions: flag, block_export and notify. flag when empty.
+	Actions []string `yaml:"actions"`
+}
+
+// SecretsConfig selects where the provider API keys set by v2t config set-key are stored.
+type SecretsConfig struct {
+	// Backend is "keychain" for the keychain of the OS, "file" for a file encrypted with the passphrase
+	// in V2T_SECRETS_PASSPHRASE or "env" for environment variables only. When empty the keychain is
+	// used where one is available, the file otherwise.
+	Backend string `yaml:"backend"`
+	// File is the encrypted file, secrets.enc next to config.yaml when empty.
+	File string `yaml:"file"`
+}
+
+// SLOConfig sets the service level objectives of a provider, they are evaluated over a rolling window
+// and a breach is published as a provider.unhealthy event.
+type SLOConfig struct {
+	// P95RealtimeFactor limits the 95th percentile of transcription time divided by audio duration,
+	// e.g. 2 for under 2x realtime, 0 disables it.
+	P95RealtimeFactor float64 `yaml:"p95_realtime_factor"`
+	// MaxErrorRate is the largest share of failed transcriptions, e.g. 0.02, 0 disables it.
+	MaxErrorRate float64 `yaml:"max_error_rate"`
+	// Window the objectives are evaluated over, one hour when zero.
+	Window time.Duration `yaml:"window"`
+	// MinSamples is how many transcriptions the window needs before it can breach, 10 when zero.
+	MinSamples int `yaml:"min_samples"`
+}
+
+// DefaultDatabase is the instance users without a route are stored in.
+const DefaultDatabase = "default"
+
+// DatabaseConfig routes users to separate database instances, e.g. one Postgres database per client.
+type DatabaseConfig struct {
+	// Instances maps an instance name to its connection, the "default" instance
+	// is data/transcription.db unless it is configured here.
+	Instances map[string]DatabaseInstance `yaml:"instances"`
+	// Users maps a user nickname to an instance name.
+	Users map[string]string `yaml:"users"`
+}
+
+// DatabaseInstance is the connection of one database.
+type DatabaseInstance struct {
+	// Driver is "sqlite" (default), "postgres" or "memory".
+	Driver string `yaml:"driver"`
+	// DSN is the sqlite file path or the postgres connection string.
+	DSN string `yaml:"dsn"`
+}
+
+// InstanceFor returns the name and connection of the database user is routed to.
+func (c DatabaseConfig) InstanceFor(user string) (string, DatabaseInstance, error) {
+	name, ok := c.Users[user]
+	if !ok {
+		name = DefaultDatabase
+	}
+
+	instance, ok := c.Instances[name]
+	if !ok && name != DefaultDatabase {
+		return "", DatabaseInstance{}, fmt.Errorf("user %s is routed to unknown database %s", user, name)
+	}
+	return name, instance, nil
+}
+
+var (
+	once    sync.Once
+	current *Config
+	cfgFile string
+)
+
+// SetConfigFile overrides the default config file location, it must be called before Get.
+func SetConfigFile(path string) {
+	cfgFile = path
+}
+
+// Dir returns the directory holding config.yaml and the other user editable files.
+func Dir() string {
+	if cfgFile != "" {
+		return filepath.Dir(cfgFile)
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "v2t")
+}
+
+// Get returns the loaded config, falling back to the defaults when no config file exists.
+func Get() *Config {
+	once.Do(func() {
+		path := cfgFile
+		if path == "" {
+			path = filepath.Join(Dir(), "config.yaml")
+		}
+
+		cfg, err := Load(path)
+		if err != nil {
+			log.Fatalf("Failed to load config %s: %v\n", path, err)
+		}
+		current = cfg
+	})
+
+	return current
+}
+
+// Load reads the config file at path on top of the defaults, a missing file is not an error.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config failed: %v", err)
+	}
+	return cfg, nil
+}
+
+// Default returns the settings used when nothing is configured.
+func Default() *Config {
+	return &Config{
+		Events: EventsConfig{
+			Backend: "inprocess",
+		},
+		Paragraphs: ParagraphConfig{
+			MaxLength: 300,
+			Pause:     2,
+		},
+		Analytics: AnalyticsConfig{
+			DefaultRole: "admin",
+			Roles: map[string]AnalyticsPolicy{
+				"admin": {
+					MinGroupSize: 1,
+				},
+				"viewer": {
+					MinGroupSize:  10,
+					Epsilon:       1.0,
+					HideUserNames: true,
+				},
+			},
+		},
+	}
+}
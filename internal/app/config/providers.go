@@ -0,0 +1,595 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/model"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProvidersConfig holds the per provider request settings, loaded from providers.yaml.
+type ProvidersConfig struct {
+	// Providers maps a provider name such as openai or whisper_cpp to its settings.
+	Providers map[string]ProviderConfig `yaml:"providers"`
+	// Languages routes audio to a provider by its detected language, off when it has no routes.
+	Languages LanguagesConfig `yaml:"languages"`
+}
+
+// LanguagesConfig routes mixed-language batches: the language of each file is detected first and
+// the file is transcribed by the provider and model routed to that language, e.g.
+//
+//	languages:
+//	  detect_model: models/ggml-tiny.bin
+//	  routes:
+//	    zh: {provider: whisper_cpp, model: models/ggml-large-v3.bin}
+//	    en: {provider: whisper_cpp, model: models/ggml-base.en.bin}
+//
+// Languages without a route, and files whose detection fails, go to the default provider.
+type LanguagesConfig struct {
+	// DetectModel is the whisper.cpp model detecting the language, a small one like tiny is enough.
+	// The model of the local provider is used when it is empty.
+	DetectModel string `yaml:"detect_model"`
+	// Routes map a language code such as zh or en to the provider transcribing it.
+	Routes map[string]LanguageRoute `yaml:"routes"`
+}
+
+// LanguageRoute is the provider transcribing a language.
+type LanguageRoute struct {
+	// Provider is openai or whisper_cpp.
+	Provider string `yaml:"provider"`
+	// Model is the model file of whisper_cpp, the local provider's model when empty. openai has a single model.
+	Model string `yaml:"model"`
+}
+
+// ProviderConfig holds the request settings of one provider, e.g. tracing IDs, cost-center tags or timeouts.
+type ProviderConfig struct {
+	// Headers are added to every HTTP request sent to the provider.
+	Headers map[string]string `yaml:"headers"`
+	// FormFields are added to every multipart upload sent to the provider.
+	FormFields map[string]string `yaml:"form_fields"`
+	// Metadata is not sent, it is only recorded with the transcriptions for attribution.
+	Metadata map[string]string `yaml:"metadata"`
+	// Timeouts of the HTTP requests sent to the provider.
+	Timeouts TimeoutConfig `yaml:"timeouts"`
+	// Decoding tunes the provider against hallucinations, settings it doesn't support are ignored.
+	Decoding DecodingConfig `yaml:"decoding"`
+	// Retry retries the failures the provider reports as retryable.
+	Retry RetryConfig `yaml:"retry"`
+	// CircuitBreaker takes the provider out of the rotation of the provider registry while it keeps failing.
+	// The instances of whisper_server have a circuit of their own with these settings.
+	CircuitBreaker BreakerConfig `yaml:"circuit_breaker"`
+	// Servers are the base URLs of the whisper.cpp server instances of whisper_server, e.g.
+	// http://gpu1:8080. The files are spread over them.
+	Servers []string `yaml:"servers"`
+	// Balance is how whisper_server spreads the files over its Servers: least_busy, the default,
+	// or round_robin.
+	Balance string `yaml:"balance"`
+	// SSH is the host ssh_whisper runs whisper.cpp on.
+	SSH SSHConfig `yaml:"ssh"`
+	// Native is the model whisper_native loads into the process.
+	Native NativeConfig `yaml:"native"`
+	// FasterWhisper is the server faster_whisper transcribes with.
+	FasterWhisper FasterWhisperConfig `yaml:"server"`
+	// Google is the project and recognizer google_stt transcribes with.
+	Google GoogleConfig `yaml:"google"`
+	// Deepgram is the model and options deepgram transcribes with.
+	Deepgram DeepgramConfig `yaml:"deepgram"`
+	// AssemblyAI is the model and analyses assemblyai transcribes with.
+	AssemblyAI AssemblyAIConfig `yaml:"assemblyai"`
+	// Endpoint is the OpenAI-compatible service openai_compatible transcribes with.
+	Endpoint EndpointConfig `yaml:"endpoint"`
+}
+
+// EndpointConfig is a service exposing the OpenAI /audio/transcriptions API, e.g. Groq, LocalAI or
+// vLLM, that openai_compatible transcribes with, e.g.
+//
+//	providers:
+//	  openai_compatible:
+//	    endpoint:
+//	      base_url: https://api.groq.com/openai/v1
+//	      model: whisper-large-v3
+type EndpointConfig struct {
+	// BaseURL is the URL the OpenAI API paths are appended to, including its version, e.g.
+	// http://localhost:8080/v1.
+	BaseURL string `yaml:"base_url"`
+	// Model is the model the service transcribes with, whisper-1 when empty.
+	Model string `yaml:"model"`
+	// APIKey is sent as the bearer token. When empty the key of openai_compatible is read by
+	// secrets.Key, and no token is sent when there is none either.
+	APIKey string `yaml:"api_key"`
+	// ResponseFormat is verbose_json, the default, which reports the segments, or json or text for
+	// services that only report the text.
+	ResponseFormat string `yaml:"response_format"`
+	// WordTimestamps asks for the timestamps of the words too, which not every service reports.
+	WordTimestamps bool `yaml:"word_timestamps"`
+}
+
+// AssemblyAIConfig is the AssemblyAI model assemblyai transcribes with and the analyses it runs on
+// the audio, its key is read by secrets.Key, e.g.
+//
+//	providers:
+//	  assemblyai:
+//	    assemblyai:
+//	      speaker_labels: true
+//	      auto_chapters: true
+//	      sentiment_analysis: true
+type AssemblyAIConfig struct {
+	// URL of the API, https://api.assemblyai.com when empty.
+	URL string `yaml:"url"`
+	// SpeechModel is the model transcribing, e.g. best or nano, AssemblyAI's default when empty.
+	SpeechModel string `yaml:"speech_model"`
+	// LanguageCode of the audio, e.g. zh, the language the transcriber is set to when empty and
+	// detected when that is empty too.
+	LanguageCode string `yaml:"language_code"`
+	// SpeakerLabels labels the segments with their speakers.
+	SpeakerLabels bool `yaml:"speaker_labels"`
+	// AutoChapters splits the transcript into chapters with a headline and a summary each.
+	AutoChapters bool `yaml:"auto_chapters"`
+	// EntityDetection reports the names, places and other entities mentioned.
+	EntityDetection bool `yaml:"entity_detection"`
+	// SentimentAnalysis reports the sentiment of each sentence.
+	SentimentAnalysis bool `yaml:"sentiment_analysis"`
+	// PollInterval is how often the transcript is checked until it is done, 3s when zero.
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// DeepgramConfig is the Deepgram model deepgram transcribes with, its key is read by secrets.Key, e.g.
+//
+//	providers:
+//	  deepgram:
+//	    deepgram:
+//	      model: nova-2
+//	      diarize: true
+type DeepgramConfig struct {
+	// URL of the API, https://api.deepgram.com when empty, e.g. the URL of a self-hosted Deepgram.
+	URL string `yaml:"url"`
+	// Model is the model transcribing, nova-2 when empty.
+	Model string `yaml:"model"`
+	// SmartFormat set to false leaves numbers, dates and punctuation as spoken. It is on when unset.
+	SmartFormat *bool `yaml:"smart_format"`
+	// Diarize labels the segments with their speakers.
+	Diarize bool `yaml:"diarize"`
+	// Language of the audio, e.g. zh-CN or multi, the language the transcriber is set to when empty
+	// and detected when that is empty too.
+	Language string `yaml:"language"`
+}
+
+// SmartFormatting reports whether Deepgram formats the transcript.
+func (d DeepgramConfig) SmartFormatting() bool {
+	return d.SmartFormat == nil || *d.SmartFormat
+}
+
+// GoogleConfig is the Speech-to-Text v2 recognizer google_stt transcribes with, authenticated as a
+// service account, e.g.
+//
+//	providers:
+//	  google_stt:
+//	    google:
+//	      credentials_file: /etc/v2t/speech-sa.json
+//	      location: us-central1
+//	      model: chirp_2
+//	      bucket: my-v2t-staging
+//	      diarization: {min_speakers: 2, max_speakers: 4}
+//
+// Audio up to a minute is sent with the request, longer audio is staged in Bucket for a batch
+// recognition and removed from it afterwards.
+type GoogleConfig struct {
+	// CredentialsFile is the JSON key of the service account, $GOOGLE_APPLICATION_CREDENTIALS when empty.
+	CredentialsFile string `yaml:"credentials_file"`
+	// Project is the project of the recognizer, the project of the service account when empty.
+	Project string `yaml:"project"`
+	// Location of the recognizer, global when empty. Other locations use their regional endpoint.
+	Location string `yaml:"location"`
+	// Recognizer is the recognizer ID, _ for the default recognizer when empty.
+	Recognizer string `yaml:"recognizer"`
+	// Model overrides the model of the recognizer, long when neither sets one.
+	Model string `yaml:"model"`
+	// LanguageCodes are the BCP-47 languages of the audio, e.g. cmn-Hans-CN, the language the
+	// transcriber is set to when empty and auto detected when that is empty too.
+	LanguageCodes []string `yaml:"language_codes"`
+	// Bucket is the Cloud Storage bucket long audio is staged in, audio over a minute fails without it.
+	Bucket string `yaml:"bucket"`
+	// WordOffsets set to false doesn't ask for the timings and confidence of the words. It is on when unset.
+	WordOffsets *bool `yaml:"word_offsets"`
+	// Diarization labels the speakers of the words when set, for the models supporting it.
+	Diarization *SpeakerCount `yaml:"diarization"`
+	// Features are passed on as they are in the features of the recognition config, e.g.
+	// profanity_filter: true, they override the features set by the other settings.
+	Features map[string]any `yaml:"features"`
+	// PollInterval is how often a batch recognition is checked, 5s when unset.
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// Words reports whether the timings of the words are asked for.
+func (g GoogleConfig) Words() bool {
+	return g.WordOffsets == nil || *g.WordOffsets
+}
+
+// SpeakerCount bounds the number of speakers diarization looks for, unset bounds keep the service's defaults.
+type SpeakerCount struct {
+	MinSpeakers int `yaml:"min_speakers"`
+	MaxSpeakers int `yaml:"max_speakers"`
+}
+
+// FasterWhisperConfig is a faster-whisper server, e.g. speaches, transcribing on CTranslate2 behind
+// an OpenAI-compatible /v1/audio/transcriptions endpoint, e.g.
+//
+//	providers:
+//	  faster_whisper:
+//	    server:
+//	      url: http://gpu-box:8000
+//	      model: Systran/faster-whisper-large-v3
+//	      vad:
+//	        min_silence_duration: 500ms
+type FasterWhisperConfig struct {
+	// URL is the base URL of the server, the endpoint paths are appended to it.
+	URL string `yaml:"url"`
+	// Model is the model the server loads, its default when empty.
+	Model string `yaml:"model"`
+	// WordTimestamps set to false only asks for segment timestamps. It is on when unset.
+	WordTimestamps *bool `yaml:"word_timestamps"`
+	// VAD tunes the Silero VAD of faster-whisper, it runs when decoding.vad is on.
+	VAD VADParameters `yaml:"vad"`
+}
+
+// Words reports whether the server is asked for the timestamps of the words.
+func (f FasterWhisperConfig) Words() bool {
+	return f.WordTimestamps == nil || *f.WordTimestamps
+}
+
+// VADParameters are the VAD options of faster-whisper, unset fields keep the server's defaults.
+type VADParameters struct {
+	// Threshold is the speech probability above which audio is speech.
+	Threshold float32 `yaml:"threshold"`
+	// MinSpeechDuration drops shorter speech.
+	MinSpeechDuration time.Duration `yaml:"min_speech_duration"`
+	// MaxSpeechDuration splits longer speech.
+	MaxSpeechDuration time.Duration `yaml:"max_speech_duration"`
+	// MinSilenceDuration is the silence separating two parts of speech.
+	MinSilenceDuration time.Duration `yaml:"min_silence_duration"`
+	// SpeechPad is added on both sides of the speech.
+	SpeechPad time.Duration `yaml:"speech_pad"`
+}
+
+// NativeConfig is the model whisper_native transcribes with in-process through the whisper.cpp
+// library, v2t has to be built with -tags whisper_native against libwhisper, e.g.
+//
+//	providers:
+//	  whisper_native:
+//	    native:
+//	      model: /opt/whisper.cpp/models/ggml-large-v3.bin
+//	      threads: 8
+//	      gpu_device: 1
+//
+// The model is loaded once per process, on the first transcription.
+type NativeConfig struct {
+	Model string `yaml:"model"`
+	// Threads decoding on the CPU, the library's default when zero.
+	Threads int `yaml:"threads"`
+	// GPU set to false runs on the CPU even when the library was built with CUDA or Metal. It is on when unset.
+	GPU *bool `yaml:"gpu"`
+	// GPUDevice selects the GPU when there are several.
+	GPUDevice int `yaml:"gpu_device"`
+	// FlashAttention speeds up decoding on GPUs supporting it.
+	FlashAttention bool `yaml:"flash_attention"`
+	// MetalResources is the directory of ggml-metal.metal, for libraries built with Metal without embedding it.
+	MetalResources string `yaml:"metal_resources"`
+}
+
+// UseGPU reports whether the model is loaded on the GPU.
+func (n NativeConfig) UseGPU() bool {
+	return n.GPU == nil || *n.GPU
+}
+
+// SSHConfig is the host ssh_whisper transcribes on: the audio is uploaded over SFTP and the
+// whisper.cpp executable of the host runs on it, e.g.
+//
+//	providers:
+//	  ssh_whisper:
+//	    ssh:
+//	      host: gpu-box
+//	      user: whisper
+//	      key_file: ~/.ssh/id_ed25519
+//	      binary: /opt/whisper.cpp/main
+//	      model: /opt/whisper.cpp/models/ggml-large-v3.bin
+//
+// The connection stays open between files, Timeouts.Dial limits connecting.
+type SSHConfig struct {
+	// Host is host or host:port, port 22 when it has none.
+	Host string `yaml:"host"`
+	User string `yaml:"user"`
+	// KeyFile is the private key authenticating User, the keys of the ssh-agent are used when it is empty.
+	KeyFile string `yaml:"key_file"`
+	// KnownHosts verifies the key of the host, ~/.ssh/known_hosts when empty.
+	KnownHosts string `yaml:"known_hosts"`
+	// Binary and Model are the whisper.cpp executable and model on the host.
+	Binary string `yaml:"binary"`
+	Model  string `yaml:"model"`
+	// RemoteDir holds the uploaded audio while it is transcribed, /tmp when empty.
+	RemoteDir string `yaml:"remote_dir"`
+	// KeepAlive is the interval of the keepalives holding the connection open between files, 30s when unset.
+	KeepAlive time.Duration `yaml:"keep_alive"`
+}
+
+// BreakerConfig opens the circuit of a provider after consecutive failures of its own, the
+// provider is skipped until Cooldown passed and a single probe succeeds. Unset fields take DefaultBreaker.
+type BreakerConfig struct {
+	Failures int           `yaml:"failures"`
+	Cooldown time.Duration `yaml:"cooldown"`
+}
+
+// DefaultBreaker applies to the fields of BreakerConfig left unset.
+var DefaultBreaker = BreakerConfig{Failures: 5, Cooldown: time.Minute}
+
+// orDefault fills the unset fields of b from DefaultBreaker.
+func (b BreakerConfig) orDefault() BreakerConfig {
+	if b.Failures == 0 {
+		b.Failures = DefaultBreaker.Failures
+	}
+	if b.Cooldown == 0 {
+		b.Cooldown = DefaultBreaker.Cooldown
+	}
+	return b
+}
+
+// RetryConfig retries the failures a provider reports as retryable, e.g. network errors, rate limits
+// or server errors, waiting longer after each failure. Unset fields take DefaultRetry.
+type RetryConfig struct {
+	// MaxAttempts is the number of calls including the first one, 1 disables retries.
+	MaxAttempts int `yaml:"max_attempts"`
+	// InitialBackoff is the wait before the first retry, doubled after each further failure up to
+	// MaxBackoff. The waits are randomized by up to half so parallel conversions don't retry together.
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// DefaultRetry applies to the fields of RetryConfig left unset.
+var DefaultRetry = RetryConfig{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// orDefault fills the unset fields of r from DefaultRetry.
+func (r RetryConfig) orDefault() RetryConfig {
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = DefaultRetry.MaxAttempts
+	}
+	if r.InitialBackoff == 0 {
+		r.InitialBackoff = DefaultRetry.InitialBackoff
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = DefaultRetry.MaxBackoff
+	}
+	return r
+}
+
+// DecodingConfig holds the anti-hallucination settings of a whisper backend.
+type DecodingConfig struct {
+	// NoSpeechThreshold is the no-speech probability above which a segment is dropped as silence,
+	// lower values drop more. Zero keeps the backend default.
+	NoSpeechThreshold float32 `yaml:"no_speech_threshold"`
+	// ConditionOnPreviousText set to false stops the decoder from continuing the text it decoded
+	// before, which breaks repetition loops on noisy audio. It is on when unset.
+	ConditionOnPreviousText *bool `yaml:"condition_on_previous_text"`
+	// VAD skips the parts without speech before decoding, whisper.cpp needs VADModel for it.
+	VAD      bool   `yaml:"vad"`
+	VADModel string `yaml:"vad_model"`
+}
+
+// NoContext reports whether conditioning on previous text is turned off.
+func (d DecodingConfig) NoContext() bool {
+	return d.ConditionOnPreviousText != nil && !*d.ConditionOnPreviousText
+}
+
+// TimeoutConfig bounds each phase of an HTTP request separately, so a long transcription
+// doesn't need a total timeout that would also hide a dead connection. Unset fields take the
+// provider's defaults, a negative value such as -1s disables the timeout.
+type TimeoutConfig struct {
+	Dial         time.Duration `yaml:"dial"`
+	TLSHandshake time.Duration `yaml:"tls_handshake"`
+	// ResponseHeader is the wait for the first byte of the response after the request was sent,
+	// transcription APIs answer only when they are done, so it covers the processing time.
+	ResponseHeader time.Duration `yaml:"response_header"`
+	// Idle is how long a kept-alive connection may stay unused.
+	Idle  time.Duration `yaml:"idle"`
+	Total time.Duration `yaml:"total"`
+}
+
+// defaultTimeouts apply to providers without an entry in providerTimeouts.
+var defaultTimeouts = TimeoutConfig{
+	Dial:           10 * time.Second,
+	TLSHandshake:   10 * time.Second,
+	ResponseHeader: 5 * time.Minute,
+	Idle:           90 * time.Second,
+	Total:          10 * time.Minute,
+}
+
+// providerTimeouts are the defaults per provider type.
+var providerTimeouts = map[string]TimeoutConfig{
+	// Uploads are at most 25MB, but a long file takes minutes before the first byte
+	"openai": {
+		Dial:           10 * time.Second,
+		TLSHandshake:   10 * time.Second,
+		ResponseHeader: 10 * time.Minute,
+		Idle:           90 * time.Second,
+		Total:          15 * time.Minute,
+	},
+}
+
+// DefaultTimeouts returns the timeouts used for provider when nothing is configured.
+func DefaultTimeouts(provider string) TimeoutConfig {
+	if t, ok := providerTimeouts[provider]; ok {
+		return t
+	}
+	return defaultTimeouts
+}
+
+// orDefault fills the unset fields of t from d.
+func (t TimeoutConfig) orDefault(d TimeoutConfig) TimeoutConfig {
+	fields := []struct{ value, fallback *time.Duration }{
+		{&t.Dial, &d.Dial},
+		{&t.TLSHandshake, &d.TLSHandshake},
+		{&t.ResponseHeader, &d.ResponseHeader},
+		{&t.Idle, &d.Idle},
+		{&t.Total, &d.Total},
+	}
+	for _, f := range fields {
+		if *f.value == 0 {
+			*f.value = *f.fallback
+		}
+	}
+	return t
+}
+
+// For returns the settings of provider, empty apart from the default timeouts, retries and circuit
+// breaker when it has none.
+func (c ProvidersConfig) For(provider string) ProviderConfig {
+	pc := c.Providers[provider]
+	pc.Timeouts = pc.Timeouts.orDefault(DefaultTimeouts(provider))
+	pc.Retry = pc.Retry.orDefault()
+	pc.CircuitBreaker = pc.CircuitBreaker.orDefault()
+	return pc
+}
+
+// sensitiveHeaderWords mark headers whose values are not copied into the stored metadata.
+var sensitiveHeaderWords = []string{"authorization", "key", "token", "secret", "cookie"}
+
+// Record returns what is kept with a transcription, values of credential-like headers are masked.
+// It is nil when nothing is configured.
+func (c ProviderConfig) Record() *model.RequestMetadata {
+	if len(c.Headers) == 0 && len(c.FormFields) == 0 && len(c.Metadata) == 0 {
+		return nil
+	}
+
+	var headers map[string]string
+	if len(c.Headers) > 0 {
+		headers = make(map[string]string, len(c.Headers))
+		for name, value := range c.Headers {
+			if isSensitiveHeader(name) {
+				value = "***"
+			}
+			headers[name] = value
+		}
+	}
+	return &model.RequestMetadata{
+		Headers:    headers,
+		FormFields: c.FormFields,
+		Metadata:   c.Metadata,
+	}
+}
+
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	providersOnce sync.Once
+	providers     *ProvidersConfig
+)
+
+// GetProviders returns the loaded providers.yaml next to config.yaml, empty when it doesn't exist.
+func GetProviders() *ProvidersConfig {
+	providersOnce.Do(func() {
+		path := ProvidersFile()
+
+		cfg, err := LoadProviders(path)
+		if err != nil {
+			log.Fatalf("Failed to load provider config %s: %v\n", path, err)
+		}
+		providers = cfg
+	})
+
+	return providers
+}
+
+// ProvidersFile is the path of providers.yaml, next to config.yaml.
+func ProvidersFile() string {
+	return filepath.Join(Dir(), "providers.yaml")
+}
+
+// SetProvidersValue sets the setting at keys of the providers file at path to value, e.g. the
+// keys providers, ssh_whisper, ssh and model. The file and the missing keys are created, the
+// other settings and the comments are kept. GetProviders doesn't see the change in this process.
+func SetProvidersValue(path string, value string, keys ...string) error {
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse provider config failed: %v", err)
+	}
+	if doc.Kind == 0 {
+		// an empty file, its comments are kept above the new settings
+		root := &yaml.Node{Kind: yaml.MappingNode, HeadComment: strings.TrimSpace(string(data))}
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+	}
+
+	node := doc.Content[0]
+	for i, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s in %s is not a mapping", strings.Join(keys[:i], "."), path)
+		}
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				next = node.Content[j+1]
+				break
+			}
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, next)
+		}
+		node = next
+	}
+	*node = yaml.Node{Kind: yaml.ScalarNode, Value: value, LineComment: node.LineComment}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// written next to the file and renamed, a failed write never leaves a truncated config
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, out.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// LoadProviders reads the providers file at path, a missing file is not an error.
+func LoadProviders(path string) (*ProvidersConfig, error) {
+	cfg := &ProvidersConfig{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse provider config failed: %v", err)
+	}
+	return cfg, nil
+}
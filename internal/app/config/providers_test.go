@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProvidersConfig_For(t *testing.T) {
+	cfg := ProvidersConfig{Providers: map[string]ProviderConfig{
+		"openai": {Timeouts: TimeoutConfig{Total: -1, Dial: 3 * time.Second}},
+	}}
+
+	tests := []struct {
+		name     string
+		provider string
+		want     TimeoutConfig
+	}{
+		{
+			name:     "configured fields override the defaults",
+			provider: "openai",
+			want: TimeoutConfig{
+				Dial:           3 * time.Second,
+				TLSHandshake:   10 * time.Second,
+				ResponseHeader: 10 * time.Minute,
+				Idle:           90 * time.Second,
+				Total:          -1,
+			},
+		},
+		{
+			name:     "unknown provider",
+			provider: "custom",
+			want:     defaultTimeouts,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.For(tt.provider).Timeouts; got != tt.want {
+				t.Errorf("For().Timeouts = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvidersConfig_For_Retry(t *testing.T) {
+	cfg := ProvidersConfig{Providers: map[string]ProviderConfig{
+		"openai":      {Retry: RetryConfig{MaxAttempts: 5}},
+		"whisper_cpp": {Retry: RetryConfig{MaxAttempts: 1, MaxBackoff: time.Minute}},
+	}}
+
+	tests := []struct {
+		provider string
+		want     RetryConfig
+	}{
+		{provider: "openai", want: RetryConfig{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 30 * time.Second}},
+		{provider: "whisper_cpp", want: RetryConfig{MaxAttempts: 1, InitialBackoff: time.Second, MaxBackoff: time.Minute}},
+		{provider: "custom", want: DefaultRetry},
+	}
+	for _, tt := range tests {
+		if got := cfg.For(tt.provider).Retry; got != tt.want {
+			t.Errorf("For(%s).Retry = %+v, want %+v", tt.provider, got, tt.want)
+		}
+	}
+}
+
+func TestProvidersConfig_For_CircuitBreaker(t *testing.T) {
+	cfg := ProvidersConfig{Providers: map[string]ProviderConfig{
+		"openai": {CircuitBreaker: BreakerConfig{Failures: 2}},
+	}}
+	if got, want := cfg.For("openai").CircuitBreaker, (BreakerConfig{Failures: 2, Cooldown: time.Minute}); got != want {
+		t.Errorf("For(openai).CircuitBreaker = %+v, want %+v", got, want)
+	}
+	if got := cfg.For("custom").CircuitBreaker; got != DefaultBreaker {
+		t.Errorf("For(custom).CircuitBreaker = %+v, want %+v", got, DefaultBreaker)
+	}
+}
+
+func TestSetProvidersValue(t *testing.T) {
+	keys := []string{"providers", "ssh_whisper", "ssh", "model"}
+	tests := []struct {
+		name    string
+		file    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no file",
+			want: "providers:\n  ssh_whisper:\n    ssh:\n      model: /models/ggml-base.bin\n",
+		},
+		{
+			name: "replaces the value and keeps the rest",
+			file: "# GPU box\nproviders:\n  ssh_whisper:\n    ssh:\n      host: gpu # over the VPN\n      model: /models/ggml-tiny.bin # fast\n  openai:\n    retry: {attempts: 3}\n",
+			want: "# GPU box\nproviders:\n  ssh_whisper:\n    ssh:\n      host: gpu # over the VPN\n      model: /models/ggml-base.bin # fast\n  openai:\n    retry: {attempts: 3}\n",
+		},
+		{
+			name: "only comments",
+			file: "# nothing yet\n",
+			want: "# nothing yet\nproviders:\n  ssh_whisper:\n    ssh:\n      model: /models/ggml-base.bin\n",
+		},
+		{
+			name:    "not a mapping",
+			file:    "providers:\n  ssh_whisper: [a, b]\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "providers.yaml")
+			if tt.file != "" {
+				if err := os.WriteFile(path, []byte(tt.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := SetProvidersValue(path, "/models/ggml-base.bin", keys...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetProvidersValue() error = %v, want an error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tt.want {
+				t.Errorf("providers.yaml =\n%s\nwant\n%s", got, tt.want)
+			}
+			cfg, err := LoadProviders(path)
+			if err != nil || cfg.For("ssh_whisper").SSH.Model != "/models/ggml-base.bin" {
+				t.Errorf("LoadProviders() = %+v, %v, want the new model", cfg.For("ssh_whisper").SSH, err)
+			}
+		})
+	}
+}
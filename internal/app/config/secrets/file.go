@@ -0,0 +1,199 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrWrongPassphrase is returned when the encrypted file can't be decrypted with the passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase for the secrets file")
+
+// ErrNoPassphrase is returned when the encrypted file is read or written without a passphrase.
+var ErrNoPassphrase = fmt.Errorf("the secrets file needs a passphrase, set %s", PassphraseEnv)
+
+// The scrypt parameters recommended for interactive logins.
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	keyLength    = 32
+	saltLength   = 16
+	sealedFormat = 1
+)
+
+// sealed is the content of the encrypted file, the keys are a JSON object encrypted with AES-256-GCM
+// under a key derived from the passphrase with scrypt.
+type sealed struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// FileStore stores the keys in a file encrypted with a passphrase.
+type FileStore struct {
+	path       string
+	passphrase string
+
+	mu sync.Mutex
+	// keys are cached after the first read, deriving the key takes a while on purpose.
+	keys map[string]string
+}
+
+// NewFileStore creates a new FileStore instance, the file is created by the first Set.
+func NewFileStore(path string, passphrase string) *FileStore {
+	return &FileStore{path: path, passphrase: passphrase}
+}
+
+func (f *FileStore) Name() string { return "file" }
+
+func (f *FileStore) Get(provider string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	key, ok := keys[provider]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return key, nil
+}
+
+func (f *FileStore) Set(provider string, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.passphrase == "" {
+		return ErrNoPassphrase
+	}
+	keys, err := f.load()
+	if err != nil {
+		return err
+	}
+	updated := make(map[string]string, len(keys)+1)
+	for p, k := range keys {
+		updated[p] = k
+	}
+	updated[provider] = key
+	return f.save(updated)
+}
+
+func (f *FileStore) Delete(provider string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys, err := f.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := keys[provider]; !ok {
+		return ErrNotFound
+	}
+	updated := make(map[string]string, len(keys))
+	for p, k := range keys {
+		if p != provider {
+			updated[p] = k
+		}
+	}
+	return f.save(updated)
+}
+
+// load returns the keys of the file, none when it doesn't exist.
+func (f *FileStore) load() (map[string]string, error) {
+	if f.keys != nil {
+		return f.keys, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		f.keys = make(map[string]string)
+		return f.keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.passphrase == "" {
+		return nil, ErrNoPassphrase
+	}
+
+	var s sealed
+	if err = json.Unmarshal(data, &s); err != nil || s.Version != sealedFormat {
+		return nil, fmt.Errorf("%s is not a v2t secrets file", f.path)
+	}
+	gcm, err := newGCM(f.passphrase, s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, s.Nonce, s.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	keys := make(map[string]string)
+	if err = json.Unmarshal(plain, &keys); err != nil {
+		return nil, err
+	}
+	f.keys = keys
+	return keys, nil
+}
+
+// save encrypts keys with a new salt and nonce and replaces the file with them.
+func (f *FileStore) save(keys map[string]string) error {
+	plain, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	s := sealed{Version: sealedFormat, Salt: make([]byte, saltLength)}
+	if _, err = rand.Read(s.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(f.passphrase, s.Salt)
+	if err != nil {
+		return err
+	}
+	s.Nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(s.Nonce); err != nil {
+		return err
+	}
+	s.Data = gcm.Seal(nil, s.Nonce, plain, nil)
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+	f.keys = keys
+	return nil
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,97 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// service names the v2t entries of the keychain.
+const service = "v2t"
+
+// KeychainAvailable reports whether the keychain of the OS can be reached, through security on
+// macOS and secret-tool of libsecret on Linux.
+func KeychainAvailable() bool {
+	_, err := exec.LookPath(keychainTool())
+	return keychainTool() != "" && err == nil
+}
+
+func keychainTool() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "security"
+	case "linux":
+		return "secret-tool"
+	default:
+		return ""
+	}
+}
+
+// keychain stores the keys in the keychain of the OS.
+type keychain struct{}
+
+func (keychain) Name() string { return "keychain" }
+
+func (keychain) Get(provider string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", provider, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "provider", provider)
+	}
+	out, err := run(cmd, "")
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// Both tools exit with an error and print nothing when there is no entry.
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	key := strings.TrimRight(out, "\r\n")
+	if key == "" {
+		return "", ErrNotFound
+	}
+	return key, nil
+}
+
+func (keychain) Set(provider string, key string) error {
+	if runtime.GOOS == "darwin" {
+		// security only takes the password as an argument, -U updates an existing entry.
+		_, err := run(exec.Command("security", "add-generic-password", "-U", "-s", service, "-a", provider, "-w", key), "")
+		return err
+	}
+	label := fmt.Sprintf("v2t %s API key", provider)
+	_, err := run(exec.Command("secret-tool", "store", "--label", label, "service", service, "provider", provider), key)
+	return err
+}
+
+func (k keychain) Delete(provider string) error {
+	if _, err := k.Get(provider); err != nil {
+		return err
+	}
+	if runtime.GOOS == "darwin" {
+		_, err := run(exec.Command("security", "delete-generic-password", "-s", service, "-a", provider), "")
+		return err
+	}
+	_, err := run(exec.Command("secret-tool", "clear", "service", service, "provider", provider), "")
+	return err
+}
+
+// run runs cmd with stdin and returns its output, the error carries what it printed to stderr.
+func run(cmd *exec.Cmd, stdin string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, msg)
+		}
+		return "", fmt.Errorf("%s failed: %w", cmd.Args[0], err)
+	}
+	return stdout.String(), nil
+}
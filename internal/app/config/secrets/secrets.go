@@ -0,0 +1,160 @@
+// Package secrets keeps the API keys of the providers out of plain .env files. A key is stored in
+// the keychain of the OS or a file encrypted with a passphrase, and resolved from the environment
+// variable of the provider when the store doesn't have it.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+)
+
+// ErrNotFound is returned by a store that has no key for the provider.
+var ErrNotFound = errors.New("secret not found")
+
+// Store keeps the API keys of the providers.
+type Store interface {
+	// Name of the backend, e.g. keychain.
+	Name() string
+	// Get returns the key of provider, ErrNotFound when there is none.
+	Get(provider string) (string, error)
+	Set(provider string, key string) error
+	// Delete removes the key of provider, it fails with ErrNotFound when there is none.
+	Delete(provider string) error
+}
+
+// EnvVars maps the providers to the environment variables their key is read from
+// when the store doesn't have it.
+var EnvVars = map[string]string{
+	"assemblyai":        "ASSEMBLYAI_API_KEY",
+	"deepgram":          "DEEPGRAM_API_KEY",
+	"deepl":             "DEEPL_AUTH_KEY",
+	"gemini":            "GEMINI_API_KEY",
+	"openai":            "OPENAI_API_KEY",
+	"openai_compatible": "OPENAI_COMPATIBLE_API_KEY",
+}
+
+// Providers returns the sorted names of the providers with a key.
+func Providers() []string {
+	names := make([]string, 0, len(EnvVars))
+	for name := range EnvVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PassphraseEnv is the environment variable holding the passphrase of the encrypted file.
+const PassphraseEnv = "V2T_SECRETS_PASSPHRASE"
+
+// Backends are the backends New creates.
+var Backends = []string{"keychain", "file", "env"}
+
+// Backend returns the backend cfg selects, the keychain when it is available and none is configured,
+// the file otherwise.
+func Backend(cfg config.SecretsConfig) string {
+	if cfg.Backend != "" {
+		return cfg.Backend
+	}
+	if KeychainAvailable() {
+		return "keychain"
+	}
+	return "file"
+}
+
+// File returns the path of the encrypted file of cfg.
+func File(cfg config.SecretsConfig) string {
+	if cfg.File != "" {
+		return cfg.File
+	}
+	return filepath.Join(config.Dir(), "secrets.enc")
+}
+
+// New creates the store configured in config.yaml, the file is decrypted with the passphrase in
+// PassphraseEnv.
+func New(cfg config.SecretsConfig) (Store, error) {
+	switch Backend(cfg) {
+	case "keychain":
+		if !KeychainAvailable() {
+			return nil, errors.New("no keychain available, it needs security on macOS or secret-tool on Linux")
+		}
+		return keychain{}, nil
+	case "file":
+		return NewFileStore(File(cfg), os.Getenv(PassphraseEnv)), nil
+	case "env":
+		return envStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets backend %q, supported: %s", cfg.Backend, strings.Join(Backends, ", "))
+	}
+}
+
+// Resolve returns the key of provider from store, or from its environment variable when store has none.
+// A failing store is logged and the environment variable is used.
+func Resolve(store Store, provider string) (string, error) {
+	env, known := EnvVars[provider]
+	if !known {
+		return "", fmt.Errorf("unknown provider %q, supported: %s", provider, strings.Join(Providers(), ", "))
+	}
+
+	key, err := store.Get(provider)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		log.Printf("Reading the %s key from the %s store failed: %v\n", provider, store.Name(), err)
+	}
+	if key, ok := os.LookupEnv(env); ok {
+		return key, nil
+	}
+	return "", fmt.Errorf("no %s API key, set it with v2t config set-key %s or in %s", provider, provider, env)
+}
+
+var (
+	once  sync.Once
+	store Store
+)
+
+// Default returns the store configured in config.yaml, it only reads environment variables
+// when the configured one can't be created.
+func Default() Store {
+	once.Do(func() {
+		var err error
+		store, err = New(config.Get().Secrets)
+		if err != nil {
+			log.Printf("Using environment variables for API keys: %v\n", err)
+			store = envStore{}
+		}
+	})
+	return store
+}
+
+// Key returns the API key of provider from the configured store or its environment variable.
+func Key(provider string) (string, error) {
+	return Resolve(Default(), provider)
+}
+
+// envStore reads the keys from the environment only.
+type envStore struct{}
+
+func (envStore) Name() string { return "env" }
+
+func (envStore) Get(provider string) (string, error) {
+	if key, ok := os.LookupEnv(EnvVars[provider]); ok {
+		return key, nil
+	}
+	return "", ErrNotFound
+}
+
+func (envStore) Set(provider string, key string) error {
+	return errors.New("the env backend can't store keys, use the keychain or file backend")
+}
+
+func (envStore) Delete(provider string) error {
+	return errors.New("the env backend can't delete keys, unset the environment variable")
+}
@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store := NewFileStore(path, "correct horse")
+	if _, err := store.Get("openai"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() without a file error = %v, want ErrNotFound", err)
+	}
+	if err := store.Set("openai", "sk-test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("deepl", "key:fx"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "sk-test") {
+		t.Errorf("the file holds the key in plain text: %s", data)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		provider   string
+		want       string
+		wantErr    error
+	}{
+		{name: "stored", passphrase: "correct horse", provider: "openai", want: "sk-test"},
+		{name: "not stored", passphrase: "correct horse", provider: "gemini", wantErr: ErrNotFound},
+		{name: "wrong passphrase", passphrase: "battery staple", provider: "openai", wantErr: ErrWrongPassphrase},
+		{name: "no passphrase", provider: "openai", wantErr: ErrNoPassphrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewFileStore(path, tt.passphrase).Get(tt.provider)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Get() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := store.Delete("openai"); err != nil {
+		t.Fatal(err)
+	}
+	reopened := NewFileStore(path, "correct horse")
+	if _, err := reopened.Get("openai"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+	if key, err := reopened.Get("deepl"); err != nil || key != "key:fx" {
+		t.Errorf("Get() of the other key = %q, %v", key, err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "secrets.enc"), "passphrase")
+	if err := store.Set("openai", "stored-key"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENAI_API_KEY", "env-key")
+	t.Setenv("GEMINI_API_KEY", "gemini-env-key")
+	os.Unsetenv("DEEPL_AUTH_KEY")
+
+	tests := []struct {
+		provider string
+		want     string
+		wantErr  string
+	}{
+		{provider: "openai", want: "stored-key"},
+		{provider: "gemini", want: "gemini-env-key"},
+		{provider: "deepl", wantErr: "config set-key deepl"},
+		{provider: "unknown", wantErr: "unknown provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			got, err := Resolve(store, tt.provider)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		cfg      config.SecretsConfig
+		wantName string
+		wantErr  bool
+	}{
+		{cfg: config.SecretsConfig{Backend: "file", File: filepath.Join(t.TempDir(), "secrets.enc")}, wantName: "file"},
+		{cfg: config.SecretsConfig{Backend: "env"}, wantName: "env"},
+		{cfg: config.SecretsConfig{Backend: "vault"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := New(tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("New(%q) error = %v, wantErr %v", tt.cfg.Backend, err, tt.wantErr)
+		}
+		if err == nil && got.Name() != tt.wantName {
+			t.Errorf("New(%q) = %s, want %s", tt.cfg.Backend, got.Name(), tt.wantName)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package batch checkpoints batch conversions in the database: which files a run converts and
+// which of them are done, failed or in flight, so an interrupted run can be resumed.
+package batch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+// Job tracks the progress of a batch conversion, its methods are safe for concurrent use by the
+// conversion workers. Every change is written to the database right away.
+type Job struct {
+	dao repository.BatchDAO
+	now func() time.Time
+
+	mu    sync.Mutex
+	state model.BatchJob
+}
+
+// New stores a new job converting files, dao must implement repository.BatchDAO.
+func New(dao repository.TranscriptionDAO, kind model.BatchKind, user string, outputDirectory string, files []string) (*Job, error) {
+	batchDAO, ok := dao.(repository.BatchDAO)
+	if !ok {
+		return nil, fmt.Errorf("the database doesn't support batch jobs")
+	}
+
+	now := time.Now()
+	state := model.BatchJob{
+		ID:              newID(),
+		Kind:            kind,
+		User:            user,
+		OutputDirectory: outputDirectory,
+		CreatedAt:       now,
+	}
+	seen := make(map[string]bool)
+	for _, f := range files {
+		// A path given twice is converted once, it is tracked by its path
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		state.Files = append(state.Files, model.BatchFile{Path: f, Status: model.BatchFilePending, UpdatedAt: now})
+	}
+
+	if err := batchDAO.CreateBatchJob(state); err != nil {
+		return nil, fmt.Errorf("create batch job failed: %v", err)
+	}
+	return &Job{dao: batchDAO, now: time.Now, state: state}, nil
+}
+
+// Load reads the job with id, to resume it.
+func Load(dao repository.TranscriptionDAO, id string) (*Job, error) {
+	batchDAO, ok := dao.(repository.BatchDAO)
+	if !ok {
+		return nil, fmt.Errorf("the database doesn't support batch jobs")
+	}
+
+	state, err := batchDAO.GetBatchJob(id)
+	if err != nil {
+		return nil, fmt.Errorf("get batch job %s failed: %w", id, err)
+	}
+	return &Job{dao: batchDAO, now: time.Now, state: *state}, nil
+}
+
+func (j *Job) ID() string {
+	return j.state.ID
+}
+
+func (j *Job) Kind() model.BatchKind {
+	return j.state.Kind
+}
+
+func (j *Job) User() string {
+	return j.state.User
+}
+
+func (j *Job) OutputDirectory() string {
+	return j.state.OutputDirectory
+}
+
+// Remaining returns the files a resumed run converts: the pending ones, the ones in flight when
+// the previous run stopped and the failed ones, in their original order.
+func (j *Job) Remaining() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var remaining []string
+	for _, f := range j.state.Files {
+		if f.Status != model.BatchFileDone {
+			remaining = append(remaining, f.Path)
+		}
+	}
+	return remaining
+}
+
+// Counts returns how many files are done, failed and not finished.
+func (j *Job) Counts() (done int, failed int, unfinished int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, f := range j.state.Files {
+		switch f.Status {
+		case model.BatchFileDone:
+			done++
+		case model.BatchFileFailed:
+			failed++
+		default:
+			unfinished++
+		}
+	}
+	return done, failed, unfinished
+}
+
+// Start marks path in flight.
+func (j *Job) Start(path string) error {
+	return j.set(path, model.BatchFileInFlight, "")
+}
+
+// Finish marks path done, or failed with err.
+func (j *Job) Finish(path string, err error) error {
+	if err != nil {
+		return j.set(path, model.BatchFileFailed, err.Error())
+	}
+	return j.set(path, model.BatchFileDone, "")
+}
+
+func (j *Job) set(path string, status model.BatchFileStatus, errorMessage string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.now()
+	if err := j.dao.SetBatchFileStatus(j.state.ID, path, status, errorMessage, now); err != nil {
+		return fmt.Errorf("checkpoint %s failed: %v", path, err)
+	}
+	for i := range j.state.Files {
+		if j.state.Files[i].Path == path {
+			j.state.Files[i].Status = status
+			j.state.Files[i].Error = errorMessage
+			j.state.Files[i].UpdatedAt = now
+		}
+	}
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms, the time keeps ids unique regardless
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
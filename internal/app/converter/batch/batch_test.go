@@ -0,0 +1,66 @@
+package batch
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/sqlite"
+)
+
+func TestJob_Resume(t *testing.T) {
+	db := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "transcription.db"))
+	defer db.Close()
+
+	job, err := New(db, model.BatchAudio, "", "/data/transcription", []string{"a.mp3", "b.mp3", "c.mp3", "d.mp3", "a.mp3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The run stops while c.mp3 is in flight, d.mp3 was never started
+	job.Start("a.mp3")
+	job.Finish("a.mp3", nil)
+	job.Start("b.mp3")
+	job.Finish("b.mp3", errors.New("decode failed"))
+	job.Start("c.mp3")
+
+	resumed, err := Load(db, job.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Kind() != model.BatchAudio || resumed.OutputDirectory() != "/data/transcription" {
+		t.Errorf("Load() = %+v", resumed.state)
+	}
+	if got, want := resumed.Remaining(), []string{"b.mp3", "c.mp3", "d.mp3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Remaining() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		err            error
+		wantDone       int
+		wantFailed     int
+		wantUnfinished int
+	}{
+		{name: "retried failure", path: "b.mp3", wantDone: 2, wantFailed: 0, wantUnfinished: 2},
+		{name: "in flight", path: "c.mp3", wantDone: 3, wantFailed: 0, wantUnfinished: 1},
+		{name: "pending fails", path: "d.mp3", err: errors.New("timeout"), wantDone: 3, wantFailed: 1, wantUnfinished: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := resumed.Finish(tt.path, tt.err); err != nil {
+				t.Fatal(err)
+			}
+			done, failed, unfinished := resumed.Counts()
+			if done != tt.wantDone || failed != tt.wantFailed || unfinished != tt.wantUnfinished {
+				t.Errorf("Counts() = %d, %d, %d, want %d, %d, %d", done, failed, unfinished, tt.wantDone, tt.wantFailed, tt.wantUnfinished)
+			}
+		})
+	}
+
+	if _, err = Load(db, "missing"); err == nil {
+		t.Errorf("Load() of an unknown job succeeded")
+	}
+}
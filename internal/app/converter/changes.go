@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/textdiff"
+)
+
+// SourceHashKey is the transcription metadata key holding the sha256 of the file it was transcribed from.
+const SourceHashKey = "source_sha256"
+
+// DefaultChangeThreshold is the word error rate between the previous and the new transcript from
+// which a replaced file counts as changed.
+const DefaultChangeThreshold = 0.05
+
+// SetDetectChanges makes directory conversions hash the files they convert and transcribe a processed
+// file again once it was replaced. The new transcript is only stored, as a revision announced with
+// TopicTranscriptionChanged, when its word error rate against the previous one reaches threshold,
+// so re-exports and subscribers ignore re-uploads that only differ in encoding.
+// It does nothing when the database can't keep metadata.
+func (c *Converter) SetDetectChanges(detect bool, threshold float64) {
+	if _, ok := c.db.(repository.MetadataDAO); !ok && detect {
+		logging.L().Warn("The database does not keep metadata, replaced files can't be detected")
+		detect = false
+	}
+	c.detectChanges = detect
+	c.changeThreshold = threshold
+	c.replaced = make(map[string]bool)
+}
+
+// sourceReplaced reports whether the file of the transcription with id differs from the one it was
+// transcribed from. A file converted before its hash was recorded is taken as unchanged and its
+// hash is recorded now.
+func (c *Converter) sourceReplaced(id int, filePath string) bool {
+	dao := c.db.(repository.MetadataDAO)
+	metadata, err := dao.GetMetadata(id)
+	if err != nil {
+		logging.L().Error("Error reading the source hash", "id", id, "error", err)
+		return false
+	}
+	hash, err := fileHash(filePath)
+	if err != nil {
+		logging.L().Error("Error hashing", "file", filePath, "error", err)
+		return false
+	}
+
+	stored := metadata[SourceHashKey]
+	if stored == "" {
+		c.setSourceHash(id, hash)
+		return false
+	}
+	return stored != hash
+}
+
+// recordSourceHash stores the hash of the file a transcription was made from.
+func (c *Converter) recordSourceHash(id int, filePath string) {
+	hash, err := fileHash(filePath)
+	if err != nil {
+		logging.L().Error("Error hashing", "file", filePath, "error", err)
+		return
+	}
+	c.setSourceHash(id, hash)
+}
+
+func (c *Converter) setSourceHash(id int, hash string) {
+	dao := c.db.(repository.MetadataDAO)
+	if err := dao.SetMetadata(id, map[string]string{SourceHashKey: hash}); err != nil {
+		logging.L().Error("Error recording the source hash", "id", id, "error", err)
+	}
+}
+
+// materialChange reports whether the transcript of a replaced file differs enough from the
+// current one to be stored.
+func (c *Converter) materialChange(id int, previous string, transcription string) bool {
+	wer := textdiff.WER(previous, transcription)
+	if wer < c.changeThreshold {
+		logging.L().Info("Transcript of replaced file barely differs, keeping the current one", "id", id, "wer", wer)
+		return false
+	}
+	logging.L().Info("Transcript of replaced file differs", "id", id, "wer", wer)
+	return true
+}
+
+// publishChanged announces that the stored transcript of a replaced file changed.
+func (c *Converter) publishChanged(userNickname string, filePath string, id int) {
+	c.bus.Publish(events.Event{
+		Topic:           events.TopicTranscriptionChanged,
+		User:            userNickname,
+		FilePath:        filePath,
+		TranscriptionID: id,
+	})
+}
+
+// fileHash returns the hex sha256 of the content of path.
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -1,15 +1,30 @@
 package converter
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"math"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/middleware"
+	"tiktok-whisper/internal/app/api/provider"
 	"tiktok-whisper/internal/app/audio"
+	"tiktok-whisper/internal/app/audio/ffmpeg"
+	"tiktok-whisper/internal/app/cleanup"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter/batch"
+	"tiktok-whisper/internal/app/cost"
+	"tiktok-whisper/internal/app/diarization"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/logging"
 	"tiktok-whisper/internal/app/model"
 	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/tracing"
 	"tiktok-whisper/internal/app/util/files"
 	"time"
 
@@ -19,19 +34,231 @@ import (
 type Converter struct {
 	transcriber api.Transcriber
 	db          repository.TranscriptionDAO
+	bus         events.Bus
+	ffmpeg      ffmpeg.Runner
+
+	retranscribe bool
+	onPartial    func(audioFilePath string, s model.Segment)
+	onProgress   func(filePath string, p ffmpeg.Progress)
+	diarizer     diarization.Diarizer
+	costs        *cost.Tracker
+	draft        bool
+	options      api.Options
+
+	detectChanges   bool
+	changeThreshold float64
+	// replaced are the file names of processed files whose source changed, they are set before
+	// the conversions start.
+	replaced map[string]bool
+
+	// resumed is the batch job a resumed run continues, new runs start their own.
+	resumed *batch.Job
+
+	results results
 }
 
-func NewConverter(transcriber api.Transcriber, transcriptionDAO repository.TranscriptionDAO) *Converter {
+func NewConverter(transcriber api.Transcriber, transcriptionDAO repository.TranscriptionDAO, bus events.Bus) *Converter {
 	return &Converter{
 		transcriber: transcriber,
 		db:          transcriptionDAO,
+		bus:         bus,
+		ffmpeg:      ffmpeg.New(),
+	}
+}
+
+// Use wraps the transcriber with mws, the first middleware is the outermost.
+func (c *Converter) Use(mws ...middleware.Middleware) {
+	if len(mws) == 0 {
+		return
+	}
+	c.transcriber = middleware.New(c.transcriber, mws...)
+}
+
+// SetRetranscribe makes directory conversions include files that were already transcribed,
+// their new results are stored as revisions.
+func (c *Converter) SetRetranscribe(retranscribe bool) {
+	c.retranscribe = retranscribe
+}
+
+// SetPartialHandler makes conversions stream, onPartial is called with every segment as soon as the
+// transcriber reports it. Transcribers that can't stream report the whole text once they are done.
+func (c *Converter) SetPartialHandler(onPartial func(audioFilePath string, s model.Segment)) {
+	c.onPartial = onPartial
+}
+
+// SetFFmpeg replaces how ffmpeg runs, tests set a fake.
+func (c *Converter) SetFFmpeg(r ffmpeg.Runner) {
+	c.ffmpeg = r
+}
+
+// SetProgressHandler reports the progress of extracting the audio of every file to onProgress.
+func (c *Converter) SetProgressHandler(onProgress func(filePath string, p ffmpeg.Progress)) {
+	c.onProgress = onProgress
+}
+
+// SetDiarizer labels the segments of every transcription with their speakers, the text becomes
+// speaker-attributed. Transcriptions without timed segments are stored as they are.
+func (c *Converter) SetDiarizer(diarizer diarization.Diarizer) {
+	c.diarizer = diarizer
+}
+
+// TrackCosts records the estimated cost of every transcription and stops converting once the monthly
+// budget of cfg is spent. It does nothing when the database can't keep a cost ledger.
+func (c *Converter) TrackCosts(cfg config.CostConfig) {
+	c.costs = cost.NewTracker(c.db, cfg)
+}
+
+// SetDraft marks stored transcriptions as drafts, each one is queued for the refine pass that
+// transcribes it again with the high-quality model and replaces the draft text.
+func (c *Converter) SetDraft(draft bool) {
+	c.draft = draft
+}
+
+// SetOptions tunes how every file is decoded, e.g. translated into English. Streaming can't carry
+// them, so a converter with options transcribes every file in one piece.
+func (c *Converter) SetOptions(opts api.Options) {
+	c.options = opts
+}
+
+// SweepTempFiles removes temp files left behind by crashed runs.
+func (c *Converter) SweepTempFiles() {
+	reclaimed, err := cleanup.Default().Sweep()
+	if err != nil {
+		logging.L().Error("Error sweeping temp files", "error", err)
+	}
+	if reclaimed > 0 {
+		logging.L().Info("Removed temp files of previous runs", "reclaimed", cleanup.FormatBytes(reclaimed))
 	}
 }
 
 func (c *Converter) Close() error {
+	if err := c.bus.Close(); err != nil {
+		logging.L().Error("Error closing event bus", "error", err)
+	}
 	return c.db.Close()
 }
 
+// lockRun keeps a second conversion run from writing to the same database concurrently.
+func (c *Converter) lockRun() error {
+	if locker, ok := c.db.(repository.RunLocker); ok {
+		return locker.LockRun()
+	}
+	return nil
+}
+
+// publishResult reports the outcome of a single file on the event bus, with its job and provider
+// when ctx reports for it.
+func (c *Converter) publishResult(ctx context.Context, userNickname string, filePath string, err error) {
+	e := events.Event{
+		Topic:    events.TopicFileDone,
+		User:     userNickname,
+		FilePath: filePath,
+	}
+	if r := reportOf(ctx); r != nil {
+		e.JobID = r.jobID
+		e.Provider = r.provider
+	}
+	if err != nil {
+		e.Topic = events.TopicJobFailed
+		e.Error = err.Error()
+	}
+	c.bus.Publish(e)
+}
+
+// recordResult keeps the outcome of filePath for the summary.
+func (c *Converter) recordResult(filePath string, err error) {
+	if err != nil {
+		c.results.record(filePath, FileFailed, err)
+		return
+	}
+	c.results.record(filePath, FileConverted, nil)
+}
+
+// Resume converts the files a batch job didn't finish, the ones that failed included.
+func (c *Converter) Resume(jobID string, parallel int) error {
+	job, err := batch.Load(c.db, jobID)
+	if err != nil {
+		return err
+	}
+
+	remaining := job.Remaining()
+	if len(remaining) == 0 {
+		logging.L().Info("Batch job has no unfinished files", "job", jobID)
+		return nil
+	}
+	if err = c.lockRun(); err != nil {
+		return err
+	}
+	logging.L().Info("Resuming batch job", "job", jobID, "unfinished", len(remaining))
+
+	c.resumed = job
+	defer func() { c.resumed = nil }()
+
+	switch job.Kind() {
+	case model.BatchAudio:
+		return c.ConvertAudios(remaining, job.OutputDirectory(), parallel)
+	case model.BatchVideo:
+		return c.ConvertVideos(remaining, job.User(), len(remaining), parallel)
+	default:
+		return fmt.Errorf("batch job %s has unknown kind %q", jobID, job.Kind())
+	}
+}
+
+// beginBatch returns the job checkpointing a run over files: the resumed one, or a new one.
+// It is nil when the database can't store batch jobs, the run goes on without checkpoints.
+func (c *Converter) beginBatch(kind model.BatchKind, user string, outputDirectory string, files []string) *batch.Job {
+	if c.resumed != nil {
+		c.publishStarted(c.resumed, files)
+		return c.resumed
+	}
+	if _, ok := c.db.(repository.BatchDAO); !ok || len(files) == 0 {
+		return nil
+	}
+
+	job, err := batch.New(c.db, kind, user, outputDirectory, files)
+	if err != nil {
+		logging.L().Error("Error creating batch job", "error", err)
+		return nil
+	}
+	// The job is stored in the user's database, the resume must open the same one
+	resume := "v2t convert --resume " + job.ID()
+	if user != "" {
+		resume += " -u " + user
+	}
+	logging.L().Info("Started batch job", "job", job.ID(), "files", len(files), "resume", resume)
+	c.publishStarted(job, files)
+	return job
+}
+
+// startFile checkpoints that the conversion of filePath started.
+func startFile(job *batch.Job, filePath string) {
+	if job == nil {
+		return
+	}
+	if err := job.Start(filePath); err != nil {
+		logging.L().Error("Error checkpointing batch job", "job", job.ID(), "error", err)
+	}
+}
+
+// finishFile checkpoints the outcome of filePath.
+func finishFile(job *batch.Job, filePath string, err error) {
+	if job == nil {
+		return
+	}
+	if err := job.Finish(filePath, err); err != nil {
+		logging.L().Error("Error checkpointing batch job", "job", job.ID(), "error", err)
+	}
+}
+
+// endBatch logs the progress of the job once a run over it returns.
+func endBatch(job *batch.Job) {
+	if job == nil {
+		return
+	}
+	done, failed, unfinished := job.Counts()
+	logging.L().Info("Batch job finished", "job", job.ID(), "done", done, "failed", failed, "unfinished", unfinished)
+}
+
 // ConvertAudioDir converts audio files in a directory to text in parallel.
 // It takes the directory, the file extension of the audios, the output directory,
 // and the number of parallel conversions as parameters.
@@ -42,34 +269,38 @@ func (c *Converter) ConvertAudioDir(directory string,
 	parallel int) error {
 	absDir, err := files.GetAbsolutePath(directory)
 	if err != nil {
-		log.Printf("Error getting absolute path of directory %s: %v\n", directory, err)
+		logging.L().Error("Error getting absolute path of directory", "dir", directory, "error", err)
 		return err
 	}
 
-	log.Printf("Starting to convert audio files in directory %s\n", absDir)
+	if err = c.lockRun(); err != nil {
+		return err
+	}
+
+	logging.L().Info("Starting to convert audio files", "dir", absDir)
 
 	// Get all files with specified extension in directory and sort them by old and new
 	fileInfos, err := files.GetAllFiles(absDir, extension)
 	if err != nil {
-		log.Printf("Error getting all files in directory %s: %v\n", absDir, err)
+		logging.L().Error("Error getting all files in directory", "dir", absDir, "error", err)
 		return err
 	}
 
-	filesToProcess := c.filterUnProcessedFiles(fileInfos, convertCount)
+	filesToProcess := c.filterUnProcessedFiles(c.filterTranscribedAudios(fileInfos, outputDirectory), convertCount)
 
 	files := lo.Map(filesToProcess, func(f model.FileInfo, i int) string {
 		return f.FullPath
 	})
 
-	log.Printf("Found %d files to convert\n", len(files))
+	logging.L().Info("Found files to convert", "files", len(files))
 
 	err = c.ConvertAudios(files, outputDirectory, parallel)
 	if err != nil {
-		log.Printf("Error converting audio files: %v\n", err)
+		logging.L().Error("Error converting audio files", "error", err)
 		return err
 	}
 
-	log.Printf("Successfully converted all audio files\n")
+	logging.L().Info("Successfully converted all audio files")
 
 	return nil
 }
@@ -80,52 +311,106 @@ func (c *Converter) ConvertAudios(audioFiles []string, outputDirectory string, p
 		return err
 	}
 
+	job := c.beginBatch(model.BatchAudio, "", transcriptionDirectory, audioFiles)
+	defer endBatch(job)
+
 	var wg sync.WaitGroup
 	sem := make(chan bool, parallel)
+	var budget budgetStop
 
 	for _, file := range audioFiles {
 		wg.Add(1)
 		go func(file string) {
 			defer wg.Done()
 			sem <- true
-			c.processFile(file, transcriptionDirectory)
+			if err := c.overBudget(); err != nil {
+				<-sem
+				budget.skip(file, err)
+				c.results.record(file, FileSkipped, err)
+				return
+			}
+			startFile(job, file)
+			ctx, span := tracing.Start(withReport(context.Background(), job, "", file), "convert", tracing.String("v2t.file", file))
+			err := c.processFile(ctx, file, transcriptionDirectory)
+			span.RecordError(err)
+			span.End()
+			finishFile(job, file, err)
+			c.recordResult(file, err)
 			<-sem
 		}(file)
 	}
 	wg.Wait()
-	return nil
+	return budget.err()
 }
 
-func (c *Converter) processFile(audioAbsPath string, transcriptionDirectory string) {
-	log.Printf("Start to process %s\n", audioAbsPath)
+func (c *Converter) processFile(ctx context.Context, audioAbsPath string, transcriptionDirectory string) error {
+	logging.L().Info("Start to process", "file", audioAbsPath)
 
-	transcription, err := c.transcriber.Transcript(audioAbsPath)
+	transcriptionFilepath := transcriptionFilePath(audioAbsPath, transcriptionDirectory)
+	transcription, metadata, err := c.transcribeTo(ctx, audioAbsPath, transcriptionFilepath)
 	if err != nil {
-		log.Printf("Transcription error: %v\n", err)
-		return
+		logging.L().Error("Transcription error", "file", audioAbsPath, "error", err)
+		c.publishResult(ctx, "", audioAbsPath, err)
+		return err
 	}
 
-	fileName := filepath.Base(audioAbsPath)
-	fileNameWithoutExt := strings.TrimSuffix(fileName, filepath.Ext(fileName))
-	transcriptionFileName := fileNameWithoutExt + ".txt"
-	transcriptionFilepath := filepath.Join(transcriptionDirectory, transcriptionFileName)
-
 	err = files.WriteToFile(transcription, transcriptionFilepath)
 	if err != nil {
-		log.Printf("Error writing to audioAbsPath: %v\n", err)
-		return
+		logging.L().Error("Error writing transcription", "path", transcriptionFilepath, "error", err)
+		c.publishResult(ctx, "", audioAbsPath, err)
+		return err
+	}
+	logging.L().Info("Transcription saved", "path", transcriptionFilepath)
+	if c.costs != nil {
+		c.recordCost(0, "", audioAbsPath, metadata, audioSeconds(audioAbsPath, metadata))
+	}
+	c.publishResult(ctx, "", audioAbsPath, nil)
+	return nil
+}
+
+// transcriptionFilePath is the text file the transcription of an audio file is written to.
+func transcriptionFilePath(audioPath string, transcriptionDirectory string) string {
+	fileName := filepath.Base(audioPath)
+	return filepath.Join(transcriptionDirectory, strings.TrimSuffix(fileName, filepath.Ext(fileName))+".txt")
+}
+
+// filterTranscribedAudios drops audio files whose text file was completely written by a previous run,
+// a text file still marked partial was interrupted and is converted again.
+func (c *Converter) filterTranscribedAudios(fileInfos []model.FileInfo, outputDirectory string) []model.FileInfo {
+	if c.retranscribe {
+		return fileInfos
+	}
+
+	transcriptionDirectory, err := filepath.Abs(outputDirectory)
+	if err != nil {
+		return fileInfos
 	}
-	log.Printf("Transcription saved to: %s\n", transcriptionFilepath)
+
+	return lo.Filter(fileInfos, func(f model.FileInfo, i int) bool {
+		path := transcriptionFilePath(f.FullPath, transcriptionDirectory)
+		if files.IsComplete(path) {
+			logging.L().Info("File has already been transcribed, skipping", "file", f.Name, "path", path)
+			return false
+		}
+		if _, err := os.Stat(files.PartialMarker(path)); err == nil {
+			logging.L().Warn("Transcription is incomplete, converting again", "file", f.Name, "path", path)
+		}
+		return true
+	})
 }
 
 // ConvertVideoDir converts videos in a directory to text in parallel.
 // It takes the user's nickname, the input directory, the file extension of the videos,
 // the maximum number of videos to convert, and the number of parallel conversions as parameters.
 func (c *Converter) ConvertVideoDir(userNickname string, inputDir string, fileExtension string, convertCount int, parallel int) error {
+	if err := c.lockRun(); err != nil {
+		return err
+	}
+
 	// Get all MP4 files in the input directory and sort them by old and new
 	fileInfos, err := files.GetAllFiles(inputDir, fileExtension)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
 	filesToProcess := c.filterUnProcessedFiles(fileInfos, convertCount)
@@ -139,22 +424,71 @@ func (c *Converter) ConvertVideoDir(userNickname string, inputDir string, fileEx
 
 	err = c.ConvertVideos(fileFullpaths, userNickname, convertCount, parallel)
 	if err != nil {
-		log.Printf("Error converting video files: %v\n", err)
+		logging.L().Error("Error converting video files", "error", err)
 		return err
 	}
 
-	log.Printf("Successfully converted all video files\n")
+	logging.L().Info("Successfully converted all video files")
 
 	return nil
 }
 
 func (c *Converter) ConvertVideos(fileFullpaths []string, userNickname string, convertCount int, parallel int) error {
+	return c.convertVideos(context.Background(), fileFullpaths, userNickname, convertCount, parallel)
+}
+
+// ConvertFile converts a single file of kind, a video of userNickname or an audio whose text is written
+// to outputDirectory, and returns its error instead of stopping the run, for callers keeping their own
+// progress like the job queue. Files converted before are skipped like in a directory conversion.
+func (c *Converter) ConvertFile(kind model.BatchKind, filePath string, userNickname string, outputDirectory string) error {
+	if err := c.overBudget(); err != nil {
+		return err
+	}
+
+	ctx, span := tracing.Start(withReport(context.Background(), nil, userNickname, filePath), "convert", tracing.String("v2t.user", userNickname), tracing.String("v2t.file", filePath))
+	defer span.End()
+
+	switch kind {
+	case model.BatchAudio:
+		transcriptionDirectory, err := filepath.Abs(outputDirectory)
+		if err != nil {
+			return err
+		}
+		if path := transcriptionFilePath(filePath, transcriptionDirectory); !c.retranscribe && files.IsComplete(path) {
+			logging.L().Info("File has already been transcribed, skipping", "file", filePath, "path", path)
+			return nil
+		}
+		err = c.processFile(ctx, filePath, transcriptionDirectory)
+		span.RecordError(err)
+		return err
+	case model.BatchVideo:
+		fileName := filepath.Base(filePath)
+		if id, err := c.db.CheckIfFileProcessed(fileName); err == nil && !c.retranscribe {
+			logging.L().Info("File has already been processed, skipping", "file", fileName, "id", id)
+			return nil
+		}
+		files.CheckAndCreateMP3Directory(files.GetUserMp3Dir(userNickname))
+		err := c.convertToText(ctx, userNickname, fileName, filePath)
+		span.RecordError(err)
+		c.publishResult(ctx, userNickname, filePath, err)
+		return err
+	default:
+		return fmt.Errorf("unknown kind %q of %s", kind, filePath)
+	}
+}
+
+// convertVideos works like ConvertVideos, the span of each file is a child of the span of ctx.
+func (c *Converter) convertVideos(ctx context.Context, fileFullpaths []string, userNickname string, convertCount int, parallel int) error {
 	// Check and create the data/mp3/userNickname subdirectory
 	convertedMp3Dir := files.GetUserMp3Dir(userNickname)
 	files.CheckAndCreateMP3Directory(convertedMp3Dir)
 
+	job := c.beginBatch(model.BatchVideo, userNickname, "", fileFullpaths)
+	defer endBatch(job)
+
 	var wg sync.WaitGroup
 	sem := make(chan bool, parallel)
+	var budget budgetStop
 
 	for _, fileAbsPath := range fileFullpaths {
 		wg.Add(1)
@@ -164,29 +498,266 @@ func (c *Converter) ConvertVideos(fileFullpaths []string, userNickname string, c
 			fileName := filepath.Base(fileAbsPath)
 
 			sem <- true
-			err := c.convertToText(userNickname, fileName, fileAbsPath)
+			if err := c.overBudget(); err != nil {
+				<-sem
+				budget.skip(fileAbsPath, err)
+				c.results.record(fileAbsPath, FileSkipped, err)
+				return
+			}
+			startFile(job, fileAbsPath)
+			ctx, span := tracing.Start(withReport(ctx, job, userNickname, fileAbsPath), "convert", tracing.String("v2t.user", userNickname), tracing.String("v2t.file", fileAbsPath))
+			err := c.convertToText(ctx, userNickname, fileName, fileAbsPath)
+			span.RecordError(err)
+			span.End()
+			finishFile(job, fileAbsPath, err)
+			c.recordResult(fileAbsPath, err)
 			<-sem
 
+			c.publishResult(ctx, userNickname, fileAbsPath, err)
+
 			if err != nil {
-				log.Fatalf("Error converting file %s: %v\n", fileName, err)
+				logging.L().Error("Error converting file", "file", fileName, "error", err)
 			} else {
-				log.Printf("Successfully converted file %s\n", fileName)
+				logging.L().Info("Successfully converted file", "file", fileName)
 			}
 		}(fileAbsPath)
 	}
 	wg.Wait()
+	return budget.err()
+}
+
+// Downloader fetches the media of a page, see downloader.YtDlp.
+type Downloader interface {
+	// DownloadAudio downloads the audio of url into dir and returns the path of the file.
+	DownloadAudio(url string, dir string) (string, error)
+}
+
+// ConvertURLs downloads the media of the urls into data/download/<user> and converts them like
+// ConvertVideos, the transcriptions record the url they came from. A url that can't be downloaded
+// is skipped, the others are still converted. The downloads and conversions are traced as one run.
+func (c *Converter) ConvertURLs(urls []string, userNickname string, d Downloader, parallel int) error {
+	ctx, span := tracing.Start(context.Background(), "convert urls",
+		tracing.String("v2t.user", userNickname), tracing.Int("v2t.urls", len(urls)))
+	defer span.End()
+
+	dir := files.GetUserDownloadDir(userNickname)
+	sources := make(map[string]string)
+	var paths []string
+	var failed int
+	var firstErr error
+	for _, url := range urls {
+		logging.L().Info("Downloading", "url", url)
+		_, download := tracing.Start(ctx, "download", tracing.String("url.full", url))
+		path, err := d.DownloadAudio(url, dir)
+		download.RecordError(err)
+		download.End()
+		if err != nil {
+			logging.L().Error("Error downloading", "url", url, "error", err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sources[filepath.Base(path)] = url
+		paths = append(paths, path)
+	}
+
+	var err error
+	if len(paths) > 0 {
+		err = c.convertVideos(ctx, paths, userNickname, math.MaxInt, parallel)
+		c.recordSources(sources)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%d urls not downloaded: %w", failed, firstErr)
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// recordSources stores the url each converted file was downloaded from, keyed by file name,
+// when the database keeps them.
+func (c *Converter) recordSources(sources map[string]string) {
+	dao, ok := c.db.(repository.SourceDAO)
+	if !ok {
+		return
+	}
+	for fileName, url := range sources {
+		id, err := c.db.CheckIfFileProcessed(fileName)
+		if err != nil {
+			continue
+		}
+		if err = dao.SetSourceURL(id, url); err != nil {
+			logging.L().Error("Error recording the source", "file", fileName, "error", err)
+		}
+	}
+}
+
+// transcribe calls the transcriber and collects its provider metadata when it can report it.
+func (c *Converter) transcribe(ctx context.Context, audioFilePath string) (string, model.ProviderMetadata, error) {
+	return c.transcribeTo(ctx, audioFilePath, "")
+}
+
+// transcribeTo works like transcribe, when the transcriber streams and outputPath is set the segments
+// are appended to the partial output of outputPath as they arrive, so a long transcription can be
+// followed while it runs and what was transcribed survives a crash.
+func (c *Converter) transcribeTo(ctx context.Context, audioFilePath string, outputPath string) (string, model.ProviderMetadata, error) {
+	c.publishStage(ctx, events.StageTranscribing, 0)
+	ctx, span := tracing.Start(ctx, "transcribe", tracing.String("v2t.audio", audioFilePath))
+	transcription, metadata, err := c.transcribeAudio(ctx, audioFilePath, outputPath)
+	if r := reportOf(ctx); r != nil {
+		r.provider = metadata.Provider
+	}
+	metadata.WordConfidence = model.WordConfidence(metadata.Segments)
+	span.SetAttributes(tracing.String("v2t.provider", metadata.Provider), tracing.String("v2t.model", metadata.Model))
+	span.RecordError(err)
+	span.End()
+	if err != nil || c.diarizer == nil {
+		return transcription, metadata, err
+	}
+
+	_, span = tracing.Start(ctx, "diarize", tracing.String("v2t.audio", audioFilePath))
+	defer span.End()
+	return c.diarize(audioFilePath, transcription, metadata)
+}
+
+// diarize labels the segments of a transcription with their speakers, a failing diarization is
+// logged and leaves the transcription as it is.
+func (c *Converter) diarize(audioFilePath string, transcription string, metadata model.ProviderMetadata) (string, model.ProviderMetadata, error) {
+	if len(metadata.Segments) == 0 {
+		logging.L().Warn("Skipping diarization, the transcriber reported no timed segments", "file", audioFilePath)
+		return transcription, metadata, nil
+	}
+
+	turns, err := c.diarizer.Diarize(audioFilePath)
+	if err != nil {
+		logging.L().Error("Error diarizing", "file", audioFilePath, "error", err)
+		return transcription, metadata, nil
+	}
+
+	metadata.Segments = diarization.Assign(metadata.Segments, turns)
+	metadata.Speakers = diarization.Speakers(metadata.Segments)
+	if metadata.Speakers == 0 {
+		return transcription, metadata, nil
+	}
+	return diarization.Text(metadata.Segments), metadata, nil
+}
+
+// transcribeAudio runs the transcriber, streaming to the partial output of outputPath when it can.
+func (c *Converter) transcribeAudio(ctx context.Context, audioFilePath string, outputPath string) (string, model.ProviderMetadata, error) {
+	if c.options != (api.Options{}) {
+		return provider.Transcribe(api.WithOptions(ctx, c.options), c.transcriber, audioFilePath)
+	}
+
+	var partial *files.PartialWriter
+	if _, ok := c.transcriber.(provider.StreamingTranscriber); ok && outputPath != "" {
+		var err error
+		if partial, err = files.CreatePartial(outputPath); err != nil {
+			logging.L().Error("Error creating partial output", "path", outputPath, "error", err)
+		} else {
+			defer partial.Close()
+		}
+	}
+
+	if partial != nil || c.onPartial != nil {
+		return provider.Stream(c.transcriber, audioFilePath, func(s model.Segment) {
+			if partial != nil {
+				if err := partial.WriteLine(s.Text); err != nil {
+					logging.L().Error("Error writing partial output", "path", outputPath, "error", err)
+				}
+			}
+			if c.onPartial != nil {
+				c.onPartial(audioFilePath, s)
+			}
+		})
+	}
+
+	return provider.Transcribe(ctx, c.transcriber, audioFilePath)
+}
+
+// saveTranscription records the result of a conversion, when the file was transcribed before
+// the result is added as a new revision instead of a second row.
+func (c *Converter) saveTranscription(userNickname, fileFullPath, fileName, mp3FileName string, duration int,
+	transcription string, metadata model.ProviderMetadata) error {
+	revisions, ok := c.db.(repository.RevisionDAO)
+	if ok {
+		if id, err := c.db.CheckIfFileProcessed(fileName); err == nil {
+			replaced := c.replaced[fileName]
+			if replaced {
+				previous, err := c.db.GetByID(id)
+				if err != nil {
+					return fmt.Errorf("get transcription failed: %v", err)
+				}
+				if !c.materialChange(id, previous.Transcription, transcription) {
+					return nil
+				}
+			}
+			revision, err := revisions.AddRevision(id, transcription, metadata, time.Now())
+			if err != nil {
+				return fmt.Errorf("add revision failed: %v", err)
+			}
+			logging.L().Info("File was transcribed before, stored as a revision", "file", fileName, "revision", revision, "id", id)
+			c.saveSegments(id, metadata.Segments)
+			c.saveInsights(id, metadata.Insights)
+			if replaced {
+				c.publishChanged(userNickname, fileFullPath, id)
+			}
+			return nil
+		}
+	}
+
+	c.db.RecordToDB(userNickname, fileFullPath, fileName, mp3FileName, duration, transcription, time.Now(), 0, "", metadata)
+	if id, err := c.db.CheckIfFileProcessed(fileName); err == nil {
+		c.saveSegments(id, metadata.Segments)
+		c.saveInsights(id, metadata.Insights)
+	}
 	return nil
 }
 
+// saveSegments stores the timed segments of the transcription when the database keeps them,
+// subtitles are regenerated from them. A failure only loses the timings, the text is stored.
+func (c *Converter) saveSegments(transcriptionID int, segments []model.Segment) {
+	dao, ok := c.db.(repository.SegmentDAO)
+	if !ok || len(segments) == 0 {
+		return
+	}
+
+	if err := dao.SaveSegments(transcriptionID, segments); err != nil {
+		logging.L().Error("Error saving segments", "id", transcriptionID, "error", err)
+	}
+}
+
+// saveInsights stores the chapters, entities and sentiments of the transcription when the provider
+// reported some and the database keeps them. They stay in the metadata when this fails.
+func (c *Converter) saveInsights(transcriptionID int, insights *model.Insights) {
+	dao, ok := c.db.(repository.InsightDAO)
+	if !ok || insights == nil || insights.Empty() {
+		return
+	}
+
+	if err := dao.SaveInsights(transcriptionID, *insights); err != nil {
+		logging.L().Error("Error saving insights", "id", transcriptionID, "error", err)
+	}
+}
+
 func (c *Converter) filterUnProcessedFiles(fileInfos []model.FileInfo, convertCount int) []model.FileInfo {
 	filesToProcess := make([]model.FileInfo, 0, convertCount)
 
 	for _, fileInfo := range fileInfos {
 		// Check if the file has been processed
 		id, err := c.db.CheckIfFileProcessed(fileInfo.Name)
-		if err == nil {
-			log.Printf("File '%s' with '%d' has already been processed, skipping...\n", fileInfo.Name, id)
-			continue
+		if err == nil && !c.retranscribe {
+			if !c.detectChanges || !c.sourceReplaced(id, fileInfo.FullPath) {
+				logging.L().Info("File has already been processed, skipping", "file", fileInfo.Name, "id", id)
+				continue
+			}
+			logging.L().Info("File was replaced, converting it again", "file", fileInfo.Name, "id", id)
+			c.replaced[fileInfo.Name] = true
 		}
 
 		filesToProcess = append(filesToProcess, fileInfo)
@@ -197,44 +768,199 @@ func (c *Converter) filterUnProcessedFiles(fileInfos []model.FileInfo, convertCo
 	return filesToProcess
 }
 
-func (c *Converter) convertToText(userNickname string, fileName string, fileFullPath string) error {
-	log.Printf("Processing file '%s'\n", fileName)
+func (c *Converter) convertToText(ctx context.Context, userNickname string, fileName string, fileFullPath string) error {
+	logging.L().Info("Processing file", "file", fileName)
 
 	// Convert MP4 to MP3 using FFmpeg
 	mp3FileName := strings.TrimSuffix(fileName, ".mp4") + ".mp3"
 	mp3FilePath := filepath.Join(files.GetUserMp3Dir(userNickname), mp3FileName)
 
+	// A fresh extraction is tracked as a temp file until it completes,
+	// so a crash mid-way doesn't leave a partial mp3 that looks finished
+	tracker := cleanup.Default()
+	if c.replaced[fileName] {
+		// The mp3 was extracted from the file it replaced
+		if err := os.Remove(mp3FilePath); err != nil && !os.IsNotExist(err) {
+			logging.L().Error("Error removing the outdated audio", "path", mp3FilePath, "error", err)
+		}
+	}
+	_, statErr := os.Stat(mp3FilePath)
+	extracting := os.IsNotExist(statErr)
+	if extracting {
+		if err := tracker.Track(fileFullPath, mp3FilePath); err != nil {
+			logging.L().Error("Error tracking temp file", "path", mp3FilePath, "error", err)
+		}
+	}
+
 	// Check if the MP3 file already exists
-	err := audio.ConvertToMp3(fileName, fileFullPath, mp3FilePath)
+	spanCtx, span := tracing.Start(ctx, "ffmpeg", tracing.String("v2t.mp3", mp3FilePath), tracing.Bool("v2t.extracted", extracting))
+	err := c.extractAudio(spanCtx, fileName, fileFullPath, mp3FilePath)
+	span.RecordError(err)
+	span.End()
+	if extracting {
+		if err != nil {
+			tracker.Release(fileFullPath)
+		} else {
+			tracker.Keep(fileFullPath, mp3FilePath)
+		}
+	}
 	if err != nil {
 		c.db.RecordToDB(userNickname, fileFullPath, fileName, mp3FileName, 0, "",
-			time.Now(), 1, fmt.Sprintf("FFmpeg error: %v", err))
+			time.Now(), 1, fmt.Sprintf("FFmpeg error: %v", err), model.ProviderMetadata{})
 		return fmt.Errorf("FFmpeg error: %v", err)
 	}
 
 	// Get audio duration
-	duration, err := audio.GetAudioDuration(mp3FilePath)
+	probed, err := c.ffmpeg.Duration(ctx, mp3FilePath)
+	duration := int(math.Round(probed.Seconds()))
 	if err != nil {
 		c.db.RecordToDB(userNickname, fileFullPath, fileName, mp3FileName, 0, "",
-			time.Now(), 1, fmt.Sprintf("Failed to get audio duration: %v", err))
+			time.Now(), 1, fmt.Sprintf("Failed to get audio duration: %v", err), model.ProviderMetadata{})
 		return fmt.Errorf("failed to get audio duration: %v", err)
 	}
 
 	// Call Whisper with a new MP3 file path
-	transcription, err := c.transcriber.Transcript(mp3FilePath)
+	transcription, metadata, err := c.transcribe(ctx, mp3FilePath)
 	if err != nil {
-		log.Printf("transcripting failed for %v, err: %v", fileName, err)
+		logging.L().Error("Transcription failed", "file", fileName, "error", err)
 
 		c.db.RecordToDB(userNickname, fileFullPath, fileName, mp3FileName, duration, "",
-			time.Now(), 1, fmt.Sprintf("Transcription error: %v", err))
+			time.Now(), 1, fmt.Sprintf("Transcription error: %v", err), metadata)
 
 		return fmt.Errorf("transcription error: %v", err)
 	}
 
 	// Save conversion results to database
-	c.db.RecordToDB(userNickname, fileFullPath, fileName, mp3FileName, duration, transcription, time.Now(), 0, "")
+	_, span = tracing.Start(ctx, "db write", tracing.String("v2t.file", fileName))
+	err = c.saveTranscription(userNickname, fileFullPath, fileName, mp3FileName, duration, transcription, metadata)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return err
+	}
+	if c.costs != nil || c.detectChanges {
+		id, err := c.db.CheckIfFileProcessed(fileName)
+		if c.costs != nil {
+			c.recordCost(id, userNickname, fileFullPath, metadata, float64(duration))
+		}
+		if c.detectChanges && err == nil {
+			c.recordSourceHash(id, fileFullPath)
+		}
+	}
+	if c.draft {
+		c.queueRefine(fileName, mp3FilePath)
+	}
+
+	logging.L().Info("Transcription completed", "file", fileName)
+	logging.L().Debug("Transcription text", "file", fileName, "text", transcription)
+	return nil
+}
+
+// queueRefine queues the draft transcription of fileName for the refine pass, a failure leaves it a draft.
+func (c *Converter) queueRefine(fileName string, audioPath string) {
+	queue, ok := c.db.(repository.RefineDAO)
+	if !ok {
+		logging.L().Warn("The database does not keep refine jobs, the file stays a draft", "file", fileName)
+		return
+	}
+	id, err := c.db.CheckIfFileProcessed(fileName)
+	if err != nil {
+		logging.L().Error("Error queueing for refinement", "file", fileName, "error", err)
+		return
+	}
+	if abs, err := filepath.Abs(audioPath); err == nil {
+		audioPath = abs
+	}
+
+	now := time.Now()
+	err = queue.QueueRefine(model.RefineJob{
+		TranscriptionID: id,
+		AudioPath:       audioPath,
+		Status:          model.RefinePending,
+		QueuedAt:        now,
+		UpdatedAt:       now,
+	})
+	if err != nil {
+		logging.L().Error("Error queueing for refinement", "file", fileName, "error", err)
+	}
+}
+
+// overBudget returns the budget error once the budget of the month is spent,
+// a ledger that can't be read doesn't stop the conversions.
+func (c *Converter) overBudget() error {
+	if c.costs == nil {
+		return nil
+	}
+	err := c.costs.CheckBudget()
+	if err != nil && !errors.Is(err, cost.ErrBudgetExceeded) {
+		logging.L().Error("Error checking the budget", "error", err)
+		return nil
+	}
+	return err
+}
 
-	log.Println("transcription completed for file: ", fileName)
-	fmt.Println(transcription)
+// extractAudio extracts the audio of the file at fileFullPath into mp3FilePath unless it exists.
+func (c *Converter) extractAudio(ctx context.Context, fileName string, fileFullPath string, mp3FilePath string) error {
+	if _, err := os.Stat(mp3FilePath); !os.IsNotExist(err) {
+		logging.L().Info("MP3 file already exists, skipping conversion", "file", fileName)
+		return nil
+	}
+	logging.L().Info("Converting to mp3", "file", fileName)
+	var onProgress ffmpeg.ProgressFunc
+	if c.onProgress != nil || reportOf(ctx) != nil {
+		onProgress = func(p ffmpeg.Progress) {
+			c.publishExtraction(ctx, p.Percent)
+			if c.onProgress != nil {
+				c.onProgress(fileFullPath, p)
+			}
+		}
+	}
+	if err := ffmpeg.ExtractAudio(ctx, c.ffmpeg, fileFullPath, mp3FilePath, onProgress); err != nil {
+		return err
+	}
+	logging.L().Info("MP4 to MP3 conversion completed", "path", mp3FilePath)
 	return nil
 }
+
+// recordCost adds a transcription to the cost ledger, a failure only loses its cost.
+func (c *Converter) recordCost(transcriptionID int, user string, filePath string, metadata model.ProviderMetadata, audioSeconds float64) {
+	if err := c.costs.Record(transcriptionID, user, filePath, metadata, audioSeconds); err != nil {
+		logging.L().Error("Error recording the cost", "file", filePath, "error", err)
+	}
+}
+
+// audioSeconds returns the duration of an audio file for pricing, the transcriber's when it reported one.
+func audioSeconds(audioPath string, metadata model.ProviderMetadata) float64 {
+	if metadata.DurationSeconds > 0 {
+		return metadata.DurationSeconds
+	}
+	duration, err := audio.GetAudioDuration(audioPath)
+	if err != nil {
+		logging.L().Error("Error getting the duration for the cost", "file", audioPath, "error", err)
+		return 0
+	}
+	return float64(duration)
+}
+
+// budgetStop collects the files a conversion skipped because the budget was spent,
+// they stay unfinished in the batch job and can be resumed once there is budget again.
+type budgetStop struct {
+	skipped int32
+	once    sync.Once
+	cause   error
+}
+
+func (b *budgetStop) skip(filePath string, err error) {
+	logging.L().Warn("Skipping file", "file", filePath, "error", err)
+	atomic.AddInt32(&b.skipped, 1)
+	b.once.Do(func() { b.cause = err })
+}
+
+// err returns the budget error with the number of skipped files, nil when none was skipped.
+// It is called once the conversions are done.
+func (b *budgetStop) err() error {
+	if b.skipped == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d files not converted: %w", b.skipped, b.cause)
+}
@@ -1,12 +1,24 @@
 package converter
 
 import (
+	"context"
+	"errors"
 	"log"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"tiktok-whisper/internal/app/api"
 	"tiktok-whisper/internal/app/api/whisper_cpp"
+	"tiktok-whisper/internal/app/audio/ffmpeg"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
 	"tiktok-whisper/internal/app/repository/sqlite"
+	"tiktok-whisper/internal/app/testutil"
 	"tiktok-whisper/internal/app/util/files"
+	"time"
 )
 
 func TestDo(t *testing.T) {
@@ -29,6 +41,12 @@ func TestDo(t *testing.T) {
 		},
 	}
 
+	// The whisper.cpp build and model differ per machine, set them to run the test
+	binaryPath, modelPath := os.Getenv("WHISPER_CPP_BINARY"), os.Getenv("WHISPER_CPP_MODEL")
+	if binaryPath == "" || modelPath == "" {
+		t.Skip("WHISPER_CPP_BINARY and WHISPER_CPP_MODEL are not set")
+	}
+
 	projectRoot, err := files.GetProjectRoot()
 	if err != nil {
 		log.Fatalf("Failed to get project root: %v\n", err)
@@ -36,10 +54,7 @@ func TestDo(t *testing.T) {
 
 	dbPath := filepath.Join(projectRoot, "data/transcription.db")
 
-	binaryPath := "/Users/tiansheng/workspace/cpp/whisper.cpp/main"
-	modelPath := "/Users/tiansheng/workspace/cpp/whisper.cpp/models/ggml-large-v2.bin"
-
-	converter := NewConverter(whisper_cpp.NewLocalTranscriber(binaryPath, modelPath), sqlite.NewSQLiteDB(dbPath))
+	converter := NewConverter(whisper_cpp.NewLocalTranscriber(binaryPath, modelPath), sqlite.NewSQLiteDB(dbPath), events.NewInProcessBus())
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -47,3 +62,350 @@ func TestDo(t *testing.T) {
 		})
 	}
 }
+
+// streamingTranscriber streams its segments one by one.
+type streamingTranscriber struct {
+	segments []string
+}
+
+func (st *streamingTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := st.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (st *streamingTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	return strings.Join(st.segments, " "), model.ProviderMetadata{Provider: "fake"}, nil
+}
+
+func (st *streamingTranscriber) TranscriptStream(inputFilePath string, partials chan<- model.Segment) (string, model.ProviderMetadata, error) {
+	defer close(partials)
+	for _, s := range st.segments {
+		partials <- model.Segment{Text: s}
+	}
+	return st.TranscriptWithMetadata(inputFilePath)
+}
+
+func TestConverter_processFile_StreamsPartialOutput(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	dir := t.TempDir()
+	output := filepath.Join(dir, "talk.txt")
+	c := NewConverter(&streamingTranscriber{segments: []string{"first", "second"}}, nil, events.NewInProcessBus())
+
+	// The handler runs after the segment was appended to the partial output
+	var seen []string
+	c.SetPartialHandler(func(audioFilePath string, s model.Segment) {
+		data, _ := os.ReadFile(files.PartialMarker(output))
+		seen = append(seen, string(data))
+	})
+
+	c.processFile(context.Background(), filepath.Join(dir, "talk.mp3"), dir)
+
+	data, err := os.ReadFile(output)
+	if err != nil || string(data) != "first second" {
+		t.Errorf("output = %q, %v, want the complete transcription", data, err)
+	}
+	if !files.IsComplete(output) {
+		t.Errorf("output is not complete, the partial output was left behind")
+	}
+	if want := []string{"first\n", "first\nsecond\n"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("partial output while streaming = %q, want %q", seen, want)
+	}
+}
+
+// optionsTranscriber records the options of the context it is called with.
+type optionsTranscriber struct {
+	streamingTranscriber
+	got []api.Options
+}
+
+func (ot *optionsTranscriber) TranscriptContext(ctx context.Context, inputFilePath string) (string, model.ProviderMetadata, error) {
+	ot.got = append(ot.got, api.OptionsFrom(ctx))
+	return ot.TranscriptWithMetadata(inputFilePath)
+}
+
+func TestConverter_SetOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options api.Options
+		want    []api.Options
+	}{
+		{name: "no options stream", want: nil},
+		{name: "options transcribe in one piece", options: api.Options{Translate: true, MaxSegmentLength: 42},
+			want: []api.Options{{Translate: true, MaxSegmentLength: 42}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			ot := &optionsTranscriber{streamingTranscriber: streamingTranscriber{segments: []string{"first"}}}
+			c := NewConverter(ot, nil, events.NewInProcessBus())
+			c.SetOptions(tt.options)
+
+			if err := c.processFile(context.Background(), filepath.Join(dir, "talk.mp3"), dir); err != nil {
+				t.Fatalf("processFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(ot.got, tt.want) {
+				t.Errorf("options = %+v, want %+v", ot.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_ConvertFile_Audio(t *testing.T) {
+	dir := t.TempDir()
+	st := &streamingTranscriber{segments: []string{"first"}}
+	c := NewConverter(st, nil, events.NewInProcessBus())
+	audioPath := filepath.Join(dir, "talk.mp3")
+
+	if err := c.ConvertFile(model.BatchAudio, audioPath, "", dir); err != nil {
+		t.Fatalf("ConvertFile() error = %v", err)
+	}
+	// converted before, the text file is kept
+	st.segments = []string{"second"}
+	if err := c.ConvertFile(model.BatchAudio, audioPath, "", dir); err != nil {
+		t.Fatalf("ConvertFile() again error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "talk.txt")); err != nil || string(data) != "first" {
+		t.Errorf("output = %q, %v, want the first transcription", data, err)
+	}
+
+	if err := c.ConvertFile("image", audioPath, "", dir); err == nil {
+		t.Errorf("ConvertFile() of an unknown kind succeeded, want an error")
+	}
+}
+
+func TestConverter_ConvertAudios_PublishesProgress(t *testing.T) {
+	dir := t.TempDir()
+	bus := events.NewInProcessBus()
+	var published []events.Event
+	bus.Subscribe(events.TopicAll, func(e events.Event) { published = append(published, e) })
+	c := NewConverter(&streamingTranscriber{segments: []string{"first"}}, memory.NewMemoryDB(), bus)
+	audioPath := filepath.Join(dir, "talk.mp3")
+
+	if err := c.ConvertAudios([]string{audioPath}, dir, 1); err != nil {
+		t.Fatalf("ConvertAudios() error = %v", err)
+	}
+	// closing drains the subscription
+	bus.Close()
+
+	if len(published) != 3 {
+		t.Fatalf("published %+v, want the start of the job, the transcription and the result", published)
+	}
+	jobID := published[0].JobID
+	if e := published[0]; e.Topic != events.TopicJobStarted || jobID == "" || e.Files != 1 {
+		t.Errorf("first event = %+v, want the job started with 1 file", e)
+	}
+	if e := published[1]; e.Topic != events.TopicFileProgress || e.Stage != events.StageTranscribing || e.JobID != jobID || e.FilePath != audioPath {
+		t.Errorf("second event = %+v, want %s transcribing", e, audioPath)
+	}
+	if e := published[2]; e.Topic != events.TopicFileDone || e.Provider != "fake" || e.JobID != jobID {
+		t.Errorf("last event = %+v, want %s done by the fake provider", e, audioPath)
+	}
+}
+
+func TestConverter_publishExtraction(t *testing.T) {
+	tests := []struct {
+		name     string
+		percents []float64
+		want     []float64
+	}{
+		{name: "once per whole percent", percents: []float64{0, 0.5, 1.2, 1.9, 100}, want: []float64{0, 1.2, 100}},
+		{name: "unknown duration", percents: []float64{0, 0, 100}, want: []float64{0, 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := events.NewInProcessBus()
+			var got []float64
+			bus.Subscribe(events.TopicFileProgress, func(e events.Event) { got = append(got, e.Progress) })
+			c := NewConverter(&streamingTranscriber{}, nil, bus)
+
+			ctx := withReport(context.Background(), nil, "alice", "talk.mp4")
+			for _, p := range tt.percents {
+				c.publishExtraction(ctx, p)
+			}
+			// without a file to report for nothing is published
+			c.publishExtraction(context.Background(), 50)
+			bus.Close()
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("published %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingDownloader struct{ calls int }
+
+func (d *failingDownloader) DownloadAudio(url string, dir string) (string, error) {
+	d.calls++
+	return "", errors.New("unsupported url")
+}
+
+func TestConverter_ConvertURLs_DownloadFails(t *testing.T) {
+	db := memory.NewMemoryDB()
+	c := NewConverter(&streamingTranscriber{}, db, events.NewInProcessBus())
+	d := &failingDownloader{}
+
+	err := c.ConvertURLs([]string{"https://example.com/a", "https://example.com/b"}, "alice", d, 1)
+	if err == nil || d.calls != 2 {
+		t.Fatalf("ConvertURLs() = %v after %d downloads, want an error after trying both", err, d.calls)
+	}
+	if got, _ := db.GetAllByUser("alice"); len(got) != 0 {
+		t.Errorf("stored %+v, want nothing", got)
+	}
+}
+
+func TestConverter_recordSources(t *testing.T) {
+	db := memory.NewMemoryDB()
+	db.RecordToDB("alice", "/download", "youtube_abc.m4a", "youtube_abc.m4a.mp3", 30, "hello", time.Now(), 0, "", model.ProviderMetadata{})
+	c := NewConverter(&streamingTranscriber{}, db, events.NewInProcessBus())
+
+	c.recordSources(map[string]string{
+		"youtube_abc.m4a": "https://www.youtube.com/watch?v=abc",
+		"missing.m4a":     "https://www.youtube.com/watch?v=missing",
+	})
+	if got, err := db.GetByID(1); err != nil || got.SourceURL != "https://www.youtube.com/watch?v=abc" {
+		t.Errorf("GetByID() = %+v, %v, want the source url", got, err)
+	}
+}
+
+func TestConverter_DetectChanges(t *testing.T) {
+	db := memory.NewMemoryDB()
+	bus := events.NewInProcessBus()
+	changed := make(chan events.Event, 1)
+	bus.Subscribe(events.TopicTranscriptionChanged, func(e events.Event) { changed <- e })
+	c := NewConverter(&streamingTranscriber{}, db, bus)
+	c.SetDetectChanges(true, DefaultChangeThreshold)
+
+	video := filepath.Join(t.TempDir(), "episode.mp4")
+	if err := os.WriteFile(video, []byte("first upload"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db.RecordToDB("alice", video, "episode.mp4", "episode.mp3", 30, "welcome to the show today we talk about go", time.Now(), 0, "", model.ProviderMetadata{})
+	fileInfos := []model.FileInfo{{FullPath: video, Name: "episode.mp4"}}
+
+	// The hash of a file converted before is recorded on its first check
+	if got := c.filterUnProcessedFiles(fileInfos, 10); len(got) != 0 {
+		t.Fatalf("filterUnProcessedFiles() of an unchanged file = %+v, want nothing", got)
+	}
+	if got := c.filterUnProcessedFiles(fileInfos, 10); len(got) != 0 {
+		t.Fatalf("filterUnProcessedFiles() after recording the hash = %+v, want nothing", got)
+	}
+	if err := os.WriteFile(video, []byte("second upload"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.filterUnProcessedFiles(fileInfos, 10); len(got) != 1 {
+		t.Fatalf("filterUnProcessedFiles() of a replaced file = %+v, want it", got)
+	}
+
+	tests := []struct {
+		name          string
+		transcription string
+		wantRevisions int
+		wantChanged   bool
+	}{
+		{name: "same words", transcription: "Welcome to the show, today we talk about Go.", wantRevisions: 0},
+		{name: "new segment", transcription: "welcome to the show today we talk about go and rust", wantRevisions: 2, wantChanged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.saveTranscription("alice", video, "episode.mp4", "episode.mp3", 30, tt.transcription, model.ProviderMetadata{}); err != nil {
+				t.Fatal(err)
+			}
+			if revisions, _ := db.GetRevisions(1); len(revisions) != tt.wantRevisions {
+				t.Errorf("GetRevisions() = %+v, want %d revisions", revisions, tt.wantRevisions)
+			}
+			select {
+			case e := <-changed:
+				if !tt.wantChanged || e.TranscriptionID != 1 || e.FilePath != video {
+					t.Errorf("published %+v, want changed = %v", e, tt.wantChanged)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.wantChanged {
+					t.Errorf("no %s event published", events.TopicTranscriptionChanged)
+				}
+			}
+		})
+	}
+}
+
+func TestConverter_Summary(t *testing.T) {
+	dir := t.TempDir()
+	c := NewConverter(&streamingTranscriber{segments: []string{"hello"}}, nil, events.NewInProcessBus())
+
+	files := []string{filepath.Join(dir, "a.mp3"), filepath.Join(dir, "b.mp3")}
+	if err := c.ConvertAudios(files, dir, 2); err != nil {
+		t.Fatalf("ConvertAudios() error = %v", err)
+	}
+	c.recordResult(filepath.Join(dir, "c.mp3"), errors.New("provider down"))
+	c.results.record(filepath.Join(dir, "d.mp3"), FileSkipped, errors.New("budget spent"))
+
+	got := c.Summary()
+	if got.Converted != 2 || got.Failed != 1 || got.Skipped != 1 || len(got.Files) != 4 {
+		t.Fatalf("Summary() = %+v, want 2 converted, 1 failed and 1 skipped", got)
+	}
+	if f := got.Files[2]; f.Status != FileFailed || f.Error != "provider down" {
+		t.Errorf("failed file = %+v, want its error", f)
+	}
+	if err := got.Err(); err == nil || err.Error() != "provider down" {
+		t.Errorf("Err() = %v, want the error of the failed file", err)
+	}
+}
+
+// fakeFFmpeg writes the output of every run and reports it done.
+type fakeFFmpeg struct {
+	err  error
+	runs int
+}
+
+func (f *fakeFFmpeg) Run(ctx context.Context, args []string, total time.Duration, onProgress ffmpeg.ProgressFunc) error {
+	f.runs++
+	if f.err != nil {
+		return f.err
+	}
+	if onProgress != nil {
+		onProgress(ffmpeg.Progress{Processed: total, Total: total, Percent: 100, Done: true})
+	}
+	return os.WriteFile(args[len(args)-1], []byte("mp3"), 0644)
+}
+
+func (f *fakeFFmpeg) Duration(ctx context.Context, path string) (time.Duration, error) {
+	return time.Minute, nil
+}
+
+func TestConverter_extractAudio(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		existing bool
+		wantRuns int
+		wantDone bool
+	}{
+		{name: "extracted with progress", wantRuns: 1, wantDone: true},
+		{name: "existing mp3 is kept", existing: true},
+		{name: "ffmpeg fails", err: errors.New("exit status 1"), wantRuns: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input, mp3 := filepath.Join(dir, "talk.mp4"), filepath.Join(dir, "talk.mp3")
+			if tt.existing {
+				os.WriteFile(mp3, []byte("mp3"), 0644)
+			}
+			f := &fakeFFmpeg{err: tt.err}
+			c := NewConverter(&streamingTranscriber{}, nil, events.NewInProcessBus())
+			c.SetFFmpeg(f)
+			var done bool
+			c.SetProgressHandler(func(filePath string, p ffmpeg.Progress) {
+				done = filePath == input && p.Done && p.Total == time.Minute
+			})
+
+			err := c.extractAudio(context.Background(), "talk.mp4", input, mp3)
+			if !errors.Is(err, tt.err) || f.runs != tt.wantRuns || done != tt.wantDone {
+				t.Errorf("extractAudio() = %v after %d runs, done %v, want %v after %d, done %v", err, f.runs, done, tt.err, tt.wantRuns, tt.wantDone)
+			}
+			if _, statErr := os.Stat(mp3); (statErr == nil) != (tt.err == nil) {
+				t.Errorf("mp3 exists = %v, want it only without an error", statErr == nil)
+			}
+		})
+	}
+}
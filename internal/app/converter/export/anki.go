@@ -0,0 +1,69 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// AnkiNote is a note of an Anki deck, Front and Back are plain text.
+type AnkiNote struct {
+	Front string
+	Back  string
+	Tags  []string
+}
+
+// WriteAnki writes notes as an Anki text import into deck: one note per line with the front, the
+// back and the tags separated by tabs, and the header lines that let Anki import it without asking.
+func WriteAnki(w io.Writer, deck string, notes []AnkiNote) error {
+	var sb strings.Builder
+	sb.WriteString("#separator:tab\n#html:true\n")
+	if deck != "" {
+		fmt.Fprintf(&sb, "#deck:%s\n", ankiLine(deck))
+	}
+	sb.WriteString("#tags column:3\n")
+	for _, n := range notes {
+		tags := make([]string, 0, len(n.Tags))
+		for _, tag := range n.Tags {
+			if tag = AnkiTag(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		fmt.Fprintf(&sb, "%s\t%s\t%s\n", ankiField(n.Front), ankiField(n.Back), strings.Join(tags, " "))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ankiField escapes text for a field of an HTML import, line breaks become <br>.
+func ankiField(text string) string {
+	text = html.EscapeString(strings.TrimSpace(text))
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\n", "<br>")
+	return strings.ReplaceAll(text, "\t", " ")
+}
+
+func ankiLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// AnkiTag turns text into an Anki tag: tags are separated by spaces, so every run of spaces and
+// punctuation other than the :: of nested tags, - and _ becomes an underscore.
+func AnkiTag(text string) string {
+	var sb strings.Builder
+	pending := false
+	for _, r := range strings.TrimSpace(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == ':' || r == '-' || r == '_' {
+			if pending && sb.Len() > 0 {
+				sb.WriteRune('_')
+			}
+			pending = false
+			sb.WriteRune(r)
+			continue
+		}
+		pending = true
+	}
+	return sb.String()
+}
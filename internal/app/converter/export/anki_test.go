@@ -0,0 +1,41 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteAnki(t *testing.T) {
+	notes := []AnkiNote{
+		{Front: "Hello <world>", Back: "你好\n世界", Tags: []string{"alice", "talk 1.mp3"}},
+		{Front: "Tabs\tinside", Back: "", Tags: []string{"  ", "v2t::review"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteAnki(&buf, "v2t  review", notes); err != nil {
+		t.Fatal(err)
+	}
+	want := "#separator:tab\n#html:true\n#deck:v2t review\n#tags column:3\n" +
+		"Hello &lt;world&gt;\t你好<br>世界\talice talk_1_mp3\n" +
+		"Tabs inside\t\tv2t::review\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteAnki() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAnkiTag(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "alice", want: "alice"},
+		{text: " my talk (2).mp3 ", want: "my_talk_2_mp3"},
+		{text: "v2t::en", want: "v2t::en"},
+		{text: "访谈 第一集", want: "访谈_第一集"},
+		{text: "!!", want: ""},
+	}
+	for _, tt := range tests {
+		if got := AnkiTag(tt.text); got != tt.want {
+			t.Errorf("AnkiTag(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/paragraph"
+	"tiktok-whisper/internal/app/redact"
+	"tiktok-whisper/internal/app/util/files"
+)
+
+// broadcastSuffix marks the artifact format of the redacted variant, e.g. txt+broadcast.
+const broadcastSuffix = "+broadcast"
+
+// Broadcast returns the copy of t with the filter's words redacted from its segments and text,
+// and where they were removed. The stored transcription is not modified.
+func Broadcast(t model.Transcription, f *redact.Filter) (model.Transcription, []redact.Redaction) {
+	segments, redactions := f.Segments(paragraph.Segments(t))
+	t.Segments = segments
+	t.Transcription = f.Text(t.Transcription)
+	return t, redactions
+}
+
+// BroadcastFileName is the artifact file name of the redacted variant, e.g. talk.broadcast.txt.
+func BroadcastFileName(t model.Transcription, w Writer) string {
+	name := ArtifactFileName(t, w)
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".broadcast." + w.Extension()
+}
+
+type redactionReport struct {
+	Count      int                `json:"count"`
+	Redactions []redact.Redaction `json:"redactions"`
+}
+
+func writeRedactions(path string, redactions []redact.Redaction) error {
+	data, err := json.MarshalIndent(redactionReport{Count: len(redactions), Redactions: redactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = files.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("write %s failed: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/i18n"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/paragraph"
+	"time"
+)
+
+//go:embed templates/transcript.html
+var transcriptHTML string
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{"t": i18n.T}).Parse(transcriptHTML))
+
+// htmlWriter renders an accessible (WCAG 2.1 AA) transcript page: landmarks and headings,
+// the page language and direction from the locale, a skip link and segments that can be
+// focused and walked with the arrow keys.
+type htmlWriter struct{}
+
+func (htmlWriter) Extension() string { return "html" }
+
+func (htmlWriter) Version() int { return 2 }
+
+type htmlSegment struct {
+	ID   string
+	Text string
+	// Space is set when a space separates the segment from the previous one.
+	Space bool
+}
+
+type htmlParagraph struct {
+	Speaker  string
+	Segments []htmlSegment
+}
+
+type htmlPage struct {
+	Lang       string
+	Dir        string
+	Title      string
+	User       string
+	Converted  string
+	DateTime   string
+	Duration   string
+	Media      string
+	Paragraphs []htmlParagraph
+}
+
+func (htmlWriter) Write(w io.Writer, t model.Transcription, opts Options) error {
+	page := htmlPage{
+		Lang:      opts.Locale.Tag,
+		Dir:       opts.Locale.Direction(),
+		Title:     strings.TrimSuffix(t.Mp3FileName, filepath.Ext(t.Mp3FileName)),
+		User:      t.User,
+		Converted: opts.Locale.FormatDateTime(t.LastConversionTime),
+		DateTime:  t.LastConversionTime.Format(time.RFC3339),
+		Duration:  opts.Locale.FormatNumber(t.AudioDuration, 0),
+		Media:     t.Mp3FileName,
+	}
+
+	n := 0
+	for _, p := range paragraphs(t, opts) {
+		hp := htmlParagraph{Speaker: p.Speaker}
+		previous := ""
+		for _, s := range p.Segments {
+			text := strings.TrimSpace(s.Text)
+			if text == "" {
+				continue
+			}
+			n++
+			hp.Segments = append(hp.Segments, htmlSegment{
+				ID:    fmt.Sprintf("segment-%d", n),
+				Text:  text,
+				Space: previous != "" && paragraph.NeedsSpace(previous, text),
+			})
+			previous = text
+		}
+		page.Paragraphs = append(page.Paragraphs, hp)
+	}
+
+	// The labels follow the locale of the reader, not the language of the CLI
+	tmpl, err := htmlTemplate.Clone()
+	if err != nil {
+		return err
+	}
+	lang := opts.Locale.Language()
+	tmpl.Funcs(template.FuncMap{"t": func(message string, args ...any) string {
+		return i18n.Translate(lang, message, args...)
+	}})
+	return tmpl.Execute(w, page)
+}
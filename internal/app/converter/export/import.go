@@ -0,0 +1,44 @@
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/schema"
+	"time"
+)
+
+// Import stores a transcription document, as written by the json format, in db. It reports false
+// when a transcription of the same file is already stored, the document is skipped then.
+func Import(db repository.TranscriptionDAO, doc schema.Transcription, inputDir string) (bool, error) {
+	if err := doc.Validate(); err != nil {
+		return false, err
+	}
+	_, err := db.CheckIfFileProcessed(doc.FileName)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("check stored transcriptions failed: %v", err)
+	}
+
+	convertedAt := doc.LastConversionTime
+	if convertedAt.IsZero() {
+		convertedAt = time.Now()
+	}
+	db.RecordToDB(doc.User, inputDir, doc.FileName, doc.FileName, int(math.Round(doc.AudioDuration)), doc.Transcription,
+		convertedAt, 0, "", doc.ProviderMetadata)
+
+	id, err := db.CheckIfFileProcessed(doc.FileName)
+	if err != nil {
+		return false, fmt.Errorf("look up imported transcription failed: %v", err)
+	}
+	if segments, ok := db.(repository.SegmentDAO); ok && len(doc.Segments) > 0 {
+		if err = segments.SaveSegments(id, doc.Segments); err != nil {
+			return true, fmt.Errorf("save segments of transcription %d failed: %v", id, err)
+		}
+	}
+	return true, nil
+}
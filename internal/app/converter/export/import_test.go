@@ -0,0 +1,52 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"tiktok-whisper/internal/app/schema"
+)
+
+func TestImport(t *testing.T) {
+	var buf bytes.Buffer
+	exported := model.Transcription{
+		ID:               7,
+		User:             "alice",
+		Mp3FileName:      "talk.mp3",
+		AudioDuration:    61.6,
+		Transcription:    "hello world",
+		ProviderMetadata: model.ProviderMetadata{Provider: "whisper_cpp"},
+		Segments:         []model.Segment{{Start: 0, End: 1, Text: "hello"}, {Start: 1, End: 2, Text: "world"}},
+	}
+	if err := (jsonWriter{}).Write(&buf, exported, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := schema.DecodeTranscription(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := memory.NewMemoryDB()
+	for i, want := range []bool{true, false} {
+		stored, err := Import(db, doc, "/exports")
+		if err != nil || stored != want {
+			t.Fatalf("Import() #%d = %v, %v, want %v", i+1, stored, err, want)
+		}
+	}
+
+	transcriptions, _ := db.GetAllByUser("alice")
+	if len(transcriptions) != 1 || transcriptions[0].Transcription != "hello world" || transcriptions[0].AudioDuration != 62 {
+		t.Fatalf("stored %+v, want the imported transcription", transcriptions)
+	}
+	segments, _ := db.GetSegments(transcriptions[0].ID)
+	if len(segments) != 2 {
+		t.Errorf("stored segments %+v, want 2", segments)
+	}
+
+	doc.User = ""
+	if _, err = Import(db, doc, "/exports"); !errors.Is(err, schema.ErrInvalid) {
+		t.Errorf("Import() of an invalid document error = %v, want %v", err, schema.ErrInvalid)
+	}
+}
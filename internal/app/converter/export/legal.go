@@ -0,0 +1,151 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"unicode"
+)
+
+// LegalOptions lay out the numbered-line transcript of the legal export.
+type LegalOptions struct {
+	// LinesPerPage are numbered from 1 on every page, the last page is padded with numbered blank lines.
+	LinesPerPage int
+	// LineWidth in characters, longer text is wrapped at spaces or cut where it has none, e.g. Chinese.
+	LineWidth int
+	// InaudibleProbability marks the words the provider is less confident in as inaudible, 0 disables it.
+	InaudibleProbability float64
+}
+
+// DefaultLegalOptions follow the common court transcript layout of 25 lines per page.
+var DefaultLegalOptions = LegalOptions{LinesPerPage: 25, LineWidth: 60}
+
+// inaudibleRegexp matches the markers providers write for speech they couldn't make out, e.g. "(inaudible)".
+var inaudibleRegexp = regexp.MustCompile(`(?i)[\[(]\s*(inaudible|unintelligible|indistinct)( speech)?\s*[\])]`)
+
+// legalWriter writes a transcript for legal use: every line numbered per page, pages separated by a
+// form feed, each segment starting with its timestamp and speaker, inaudible passages marked with
+// the time they start at.
+type legalWriter struct{}
+
+func (legalWriter) Extension() string { return "legal.txt" }
+
+func (legalWriter) Version() int { return 1 }
+
+func (legalWriter) Write(w io.Writer, t model.Transcription, opts Options) error {
+	layout := opts.Legal
+	if layout.LinesPerPage <= 0 {
+		layout.LinesPerPage = DefaultLegalOptions.LinesPerPage
+	}
+	if layout.LineWidth <= 0 {
+		layout.LineWidth = DefaultLegalOptions.LineWidth
+	}
+
+	var lines []string
+	for _, entry := range legalEntries(t, layout) {
+		lines = append(lines, wrapLine(entry, layout.LineWidth)...)
+	}
+	if last := len(lines) % layout.LinesPerPage; last > 0 || len(lines) == 0 {
+		lines = append(lines, make([]string, layout.LinesPerPage-last)...)
+	}
+
+	var sb strings.Builder
+	numberWidth := len(strconv.Itoa(layout.LinesPerPage))
+	for i, line := range lines {
+		n := i%layout.LinesPerPage + 1
+		if n == 1 {
+			if i > 0 {
+				sb.WriteString("\f")
+			}
+			fmt.Fprintf(&sb, "Page %d\n\n", i/layout.LinesPerPage+1)
+		}
+		fmt.Fprintf(&sb, "%*d", numberWidth, n)
+		if line != "" {
+			sb.WriteString("  " + line)
+		}
+		sb.WriteString("\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// legalEntries returns the text of t as it starts new lines: a segment each, prefixed with its
+// timestamp and speaker, or a paragraph each when t has no timed segments.
+func legalEntries(t model.Transcription, opts LegalOptions) []string {
+	var entries []string
+	for _, s := range t.Segments {
+		if !s.Timed() {
+			continue
+		}
+		entry := "[" + legalTimestamp(s.Start) + "] "
+		if s.Speaker != "" {
+			entry += s.Speaker + ": "
+		}
+		entries = append(entries, entry+MarkInaudible(s, opts.InaudibleProbability))
+	}
+	if len(entries) > 0 {
+		return entries
+	}
+
+	for _, p := range strings.Split(t.Transcription, "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			entries = append(entries, inaudibleRegexp.ReplaceAllString(p, "[inaudible]"))
+		}
+	}
+	return entries
+}
+
+// MarkInaudible returns the text of s with its inaudible passages replaced by "[inaudible HH:MM:SS]":
+// the markers the provider wrote, timed at the start of s, and runs of words it is less confident
+// in than minProbability, timed at their first word. Words without a probability are kept.
+func MarkInaudible(s model.Segment, minProbability float64) string {
+	text := s.Text
+	if minProbability > 0 && len(s.Words) > 0 {
+		var sb strings.Builder
+		inaudible := false
+		for _, w := range s.Words {
+			if w.Probability <= 0 || w.Probability >= minProbability {
+				inaudible = false
+				sb.WriteString(w.Text)
+				continue
+			}
+			if !inaudible {
+				if strings.HasPrefix(w.Text, " ") {
+					sb.WriteString(" ")
+				}
+				sb.WriteString("[inaudible " + legalTimestamp(w.Start) + "]")
+			}
+			inaudible = true
+		}
+		text = sb.String()
+	}
+	return strings.TrimSpace(inaudibleRegexp.ReplaceAllString(text, "[inaudible "+legalTimestamp(s.Start)+"]"))
+}
+
+// legalTimestamp formats seconds as HH:MM:SS.
+func legalTimestamp(seconds float64) string {
+	s := int(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// wrapLine wraps text into lines of at most width characters, breaking after the last space that
+// fits or, in text without spaces, at width.
+func wrapLine(text string, width int) []string {
+	var lines []string
+	runes := []rune(strings.TrimSpace(text))
+	for len(runes) > width {
+		cut := width
+		for i := width; i > 0; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, strings.TrimSpace(string(runes[:cut])))
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	return append(lines, string(runes))
+}
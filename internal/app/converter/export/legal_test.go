@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestLegalWriter(t *testing.T) {
+	tests := []struct {
+		name          string
+		transcription model.Transcription
+		opts          LegalOptions
+		want          string
+	}{
+		{
+			name: "segments paginated and padded",
+			transcription: model.Transcription{Segments: []model.Segment{
+				{Start: 1.5, End: 4, Speaker: "A", Text: " Umm, I- I went to the, uh, store."},
+				{Start: 65, End: 70, Speaker: "B", Text: " Okay."},
+			}},
+			opts: LegalOptions{LinesPerPage: 2, LineWidth: 24},
+			want: "Page 1\n\n" +
+				"1  [00:00:01] A: Umm, I- I\n" +
+				"2  went to the, uh, store.\n" +
+				"\fPage 2\n\n" +
+				"1  [00:01:05] B: Okay.\n" +
+				"2\n",
+		},
+		{
+			name:          "untimed text by paragraph",
+			transcription: model.Transcription{Transcription: "第一段 (inaudible) 话。\n\n第二段。"},
+			opts:          LegalOptions{LinesPerPage: 10, LineWidth: 12},
+			want: "Page 1\n\n" +
+				" 1  第一段\n" +
+				" 2  [inaudible]\n" +
+				" 3  话。\n" +
+				" 4  第二段。\n" +
+				" 5\n 6\n 7\n 8\n 9\n10\n",
+		},
+		{
+			name:          "empty transcription is one blank page",
+			transcription: model.Transcription{},
+			opts:          LegalOptions{LinesPerPage: 2},
+			want:          "Page 1\n\n1\n2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (legalWriter{}).Write(&buf, tt.transcription, Options{Legal: tt.opts}); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Write() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkInaudible(t *testing.T) {
+	words := []model.Word{
+		{Start: 10, End: 10.5, Text: " I", Probability: 0.9},
+		{Start: 10.5, End: 11, Text: " said", Probability: 0.2},
+		{Start: 11, End: 11.4, Text: " the", Probability: 0.1},
+		{Start: 11.4, End: 12, Text: " truth", Probability: 0.8},
+		{Start: 12, End: 13, Text: " yesterday"},
+	}
+	tests := []struct {
+		name           string
+		segment        model.Segment
+		minProbability float64
+		want           string
+	}{
+		{
+			name:           "low probability run",
+			segment:        model.Segment{Start: 10, End: 13, Text: " I said the truth yesterday", Words: words},
+			minProbability: 0.3,
+			want:           "I [inaudible 00:00:10] truth yesterday",
+		},
+		{
+			name:    "disabled keeps the text",
+			segment: model.Segment{Start: 10, End: 13, Text: " I said the truth yesterday", Words: words},
+			want:    "I said the truth yesterday",
+		},
+		{
+			name:    "provider markers",
+			segment: model.Segment{Start: 3725, End: 3730, Text: " So [INAUDIBLE] and (unintelligible speech) then."},
+			want:    "So [inaudible 01:02:05] and [inaudible 01:02:05] then.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MarkInaudible(tt.segment, tt.minProbability); got != tt.want {
+				t.Errorf("MarkInaudible() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_wrapLine(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{text: "short", width: 10, want: []string{"short"}},
+		{text: "one two three four", width: 9, want: []string{"one two", "three", "four"}},
+		{text: "没有空格的一整句话", width: 4, want: []string{"没有空格", "的一整句", "话"}},
+	}
+	for _, tt := range tests {
+		if got := wrapLine(tt.text, tt.width); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("wrapLine(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package export
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/locale"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/moderation"
+	"tiktok-whisper/internal/app/redact"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/files"
+	"time"
+)
+
+// ReExportOptions selects the transcriptions to re-export and where the artifacts go.
+type ReExportOptions struct {
+	User      string
+	Format    string
+	OutputDir string
+	// Since skips transcriptions converted before it, the zero value selects everything.
+	Since time.Time
+	// Force rewrites artifacts even when they are up to date.
+	Force bool
+	// Write are the user settings the artifacts are rendered with.
+	Write Options
+	// Broadcast, when set, also writes a variant with the filter's words redacted.
+	Broadcast *redact.Filter
+	// BlockFlagged, when set, skips the transcriptions its moderation results flag.
+	BlockFlagged repository.ModerationDAO
+}
+
+// ReExportResult counts what happened to the selected transcriptions.
+type ReExportResult struct {
+	Written int
+	Skipped int
+	// Untimed counts the transcriptions a subtitle format skipped for lack of timestamps.
+	Untimed int
+	// Blocked counts the transcriptions skipped because moderation flagged them.
+	Blocked int
+}
+
+// ReExport regenerates the artifacts of stored transcriptions with the current writers, without
+// transcribing again. An artifact is up to date when the file still exists and was produced by
+// the same writer version with the same content, every rewrite is recorded as a new artifact version.
+func ReExport(db repository.TranscriptionDAO, artifacts repository.ArtifactDAO, opts ReExportOptions) (ReExportResult, error) {
+	var result ReExportResult
+
+	w, err := GetWriter(opts.Format)
+	if err != nil {
+		return result, err
+	}
+
+	transcriptions, err := db.GetAllByUser(opts.User)
+	if err != nil {
+		return result, fmt.Errorf("get transcriptions failed: %v", err)
+	}
+
+	if err = os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return result, fmt.Errorf("create output dir failed: %v", err)
+	}
+
+	writeOpts := opts.Write
+	if writeOpts.Locale.Tag == "" {
+		writeOpts.Locale = locale.Default
+	}
+
+	for _, t := range transcriptions {
+		if t.LastConversionTime.Before(opts.Since) {
+			continue
+		}
+		if opts.BlockFlagged != nil {
+			blocked, err := moderation.Blocked(opts.BlockFlagged, t.ID)
+			if err != nil {
+				return result, fmt.Errorf("get moderation of transcription %d failed: %v", t.ID, err)
+			}
+			if blocked {
+				logging.L().Info("Skip transcription flagged by moderation", "id", t.ID)
+				result.Blocked++
+				continue
+			}
+		}
+		if err = LoadSegments(db, &t); err != nil {
+			return result, err
+		}
+
+		path := filepath.Join(opts.OutputDir, ArtifactFileName(t, w))
+		written, err := exportArtifact(artifacts, w, t, opts.Format, path, writeOpts, opts.Force)
+		if errors.Is(err, ErrNoTimedSegments) {
+			logging.L().Info("Skip transcription", "id", t.ID, "reason", err)
+			result.Untimed++
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result.count(written)
+
+		if opts.Broadcast == nil {
+			continue
+		}
+
+		clean, redactions := Broadcast(t, opts.Broadcast)
+		path = filepath.Join(opts.OutputDir, BroadcastFileName(t, w))
+		written, err = exportArtifact(artifacts, w, clean, opts.Format+broadcastSuffix, path, writeOpts, opts.Force)
+		if err != nil {
+			return result, err
+		}
+		result.count(written)
+
+		if written {
+			if err = writeRedactions(strings.TrimSuffix(path, filepath.Ext(path))+".redactions.json", redactions); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *ReExportResult) count(written bool) {
+	if written {
+		r.Written++
+	} else {
+		r.Skipped++
+	}
+}
+
+// exportArtifact renders t to path unless the recorded artifact is up to date, it reports whether the file was written.
+func exportArtifact(artifacts repository.ArtifactDAO, w Writer, t model.Transcription, format string, path string,
+	opts Options, force bool) (bool, error) {
+	var buf bytes.Buffer
+	if err := w.Write(&buf, t, opts); err != nil {
+		return false, fmt.Errorf("render transcription %d failed: %w", t.ID, err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	hash := hex.EncodeToString(sum[:])
+
+	previous, err := artifacts.GetArtifact(t.ID, format)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("get artifact of transcription %d failed: %v", t.ID, err)
+	}
+	if !force && upToDate(previous, path, w.Version(), hash) {
+		return false, nil
+	}
+
+	if err = files.WriteFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return false, fmt.Errorf("write %s failed: %v", path, err)
+	}
+
+	err = artifacts.SaveArtifact(model.Artifact{
+		TranscriptionID: t.ID,
+		Format:          format,
+		Path:            path,
+		WriterVersion:   w.Version(),
+		ContentHash:     hash,
+		ExportedAt:      time.Now(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("save artifact of transcription %d failed: %v", t.ID, err)
+	}
+	return true, nil
+}
+
+func upToDate(previous *model.Artifact, path string, version int, hash string) bool {
+	if previous == nil || previous.Path != path || previous.WriterVersion != version || previous.ContentHash != hash {
+		return false
+	}
+	return files.IsComplete(path)
+}
@@ -0,0 +1,150 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/redact"
+	"tiktok-whisper/internal/app/repository/sqlite"
+	"time"
+)
+
+func TestReExport(t *testing.T) {
+	db := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "transcription.db"))
+	defer db.Close()
+
+	old := time.Date(2023, 6, 1, 0, 0, 0, 0, time.Local)
+	recent := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 30, "old text", old, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 30, "new text", recent, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "c.mp4", "c.mp3", 30, "other user", recent, 0, "", model.ProviderMetadata{})
+
+	outputDir := t.TempDir()
+	opts := ReExportOptions{
+		User:      "alice",
+		Format:    "txt",
+		OutputDir: outputDir,
+		Since:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+	}
+
+	tests := []struct {
+		name    string
+		prepare func()
+		force   bool
+		want    ReExportResult
+	}{
+		{name: "first export", want: ReExportResult{Written: 1}},
+		{name: "up to date", want: ReExportResult{Skipped: 1}},
+		{name: "forced", force: true, want: ReExportResult{Written: 1}},
+		{
+			name:    "file removed",
+			prepare: func() { os.Remove(filepath.Join(outputDir, "b.txt")) },
+			want:    ReExportResult{Written: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare()
+			}
+			opts.Force = tt.force
+
+			got, err := ReExport(db, db, opts)
+			if err != nil {
+				t.Fatalf("ReExport() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReExport() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "b.txt"))
+	if err != nil || string(data) != "new text" {
+		t.Errorf("b.txt = %q, %v", data, err)
+	}
+	if _, err = os.Stat(filepath.Join(outputDir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("a.txt was exported although it is older than since")
+	}
+
+	artifact, err := db.GetArtifact(2, "txt")
+	if err != nil {
+		t.Fatalf("GetArtifact() error = %v", err)
+	}
+	if artifact.WriterVersion != (textWriter{}).Version() || artifact.Path != filepath.Join(outputDir, "b.txt") {
+		t.Errorf("GetArtifact() = %+v", artifact)
+	}
+}
+
+func TestGetWriter(t *testing.T) {
+	if _, err := GetWriter("TXT"); err != nil {
+		t.Errorf("GetWriter(TXT) error = %v", err)
+	}
+	if _, err := GetWriter("docx"); err == nil {
+		t.Errorf("GetWriter(docx) error = nil, want unsupported format")
+	}
+}
+
+func TestReExport_Broadcast(t *testing.T) {
+	db := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "transcription.db"))
+	defer db.Close()
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 30, "Well, shit happens.", time.Now(), 0, "", model.ProviderMetadata{})
+
+	filter, err := redact.NewFilter(redact.Star, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := t.TempDir()
+	result, err := ReExport(db, db, ReExportOptions{User: "alice", Format: "txt", OutputDir: outputDir, Broadcast: filter})
+	if err != nil {
+		t.Fatalf("ReExport() error = %v", err)
+	}
+	if result.Written != 2 {
+		t.Errorf("ReExport() = %+v, want the original and the broadcast variant", result)
+	}
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: "a.txt", want: "Well, shit happens."},
+		{file: "a.broadcast.txt", want: "Well, s*** happens."},
+		{file: "a.broadcast.redactions.json", want: `"count": 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(outputDir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("%s = %q, want %q", tt.file, data, tt.want)
+			}
+		})
+	}
+}
+
+func TestReExport_BlockFlagged(t *testing.T) {
+	db := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "transcription.db"))
+	defer db.Close()
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 30, "flagged text", time.Now(), 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 30, "clean text", time.Now(), 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 30, "unscreened text", time.Now(), 0, "", model.ProviderMetadata{})
+	db.SaveModeration(model.Moderation{TranscriptionID: 1, Backend: "http", Flagged: []string{"violence"}, CheckedAt: time.Now()})
+	db.SaveModeration(model.Moderation{TranscriptionID: 2, Backend: "http", CheckedAt: time.Now()})
+
+	outputDir := t.TempDir()
+	result, err := ReExport(db, db, ReExportOptions{User: "alice", Format: "txt", OutputDir: outputDir, BlockFlagged: db})
+	if err != nil {
+		t.Fatalf("ReExport() error = %v", err)
+	}
+	if want := (ReExportResult{Written: 2, Blocked: 1}); result != want {
+		t.Errorf("ReExport() = %+v, want %+v", result, want)
+	}
+	if _, err = os.Stat(filepath.Join(outputDir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("a.txt was exported although moderation flagged it")
+	}
+}
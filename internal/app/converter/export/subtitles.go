@@ -0,0 +1,125 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/schema"
+)
+
+// LoadSegments reads the stored segments of t when db keeps them, subtitle formats need their timings.
+func LoadSegments(db repository.TranscriptionDAO, t *model.Transcription) error {
+	dao, ok := db.(repository.SegmentDAO)
+	if !ok {
+		return nil
+	}
+
+	segments, err := dao.GetSegments(t.ID)
+	if err != nil {
+		return fmt.Errorf("get segments of transcription %d failed: %v", t.ID, err)
+	}
+	if len(segments) > 0 {
+		t.Segments = segments
+	}
+	return nil
+}
+
+// ErrNoTimedSegments is returned by the subtitle writers for transcriptions stored without timestamps.
+var ErrNoTimedSegments = errors.New("no timed segments")
+
+// timedSegments returns the timed segments of t, subtitles can't be written without them.
+func timedSegments(t model.Transcription) ([]model.Segment, error) {
+	segments := make([]model.Segment, 0, len(t.Segments))
+	for _, s := range t.Segments {
+		if s.Timed() {
+			segments = append(segments, s)
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("transcription %d: %w, transcribe it again with a provider reporting timestamps", t.ID, ErrNoTimedSegments)
+	}
+	return segments, nil
+}
+
+// subtitleTimestamp formats seconds as HH:MM:SS followed by sep and milliseconds.
+func subtitleTimestamp(seconds float64, sep string) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+type srtWriter struct{}
+
+func (srtWriter) Extension() string { return "srt" }
+
+func (srtWriter) Version() int { return 1 }
+
+func (srtWriter) Write(w io.Writer, t model.Transcription, opts Options) error {
+	segments, err := timedSegments(t)
+	if err != nil {
+		return err
+	}
+
+	for i, s := range segments {
+		text := s.Text
+		if s.Speaker != "" {
+			text = s.Speaker + ": " + text
+		}
+		_, err = fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1,
+			subtitleTimestamp(s.Start, ","), subtitleTimestamp(s.End, ","), text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type vttWriter struct{}
+
+func (vttWriter) Extension() string { return "vtt" }
+
+func (vttWriter) Version() int { return 1 }
+
+func (vttWriter) Write(w io.Writer, t model.Transcription, opts Options) error {
+	segments, err := timedSegments(t)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+	for _, s := range segments {
+		text := s.Text
+		if s.Speaker != "" {
+			text = "<v " + s.Speaker + ">" + text
+		}
+		_, err = fmt.Fprintf(w, "%s --> %s\n%s\n\n", subtitleTimestamp(s.Start, "."), subtitleTimestamp(s.End, "."), text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonWriter struct{}
+
+func (jsonWriter) Extension() string { return "json" }
+
+// Version 2 writes the versioned transcription document of the schema package.
+func (jsonWriter) Version() int { return 2 }
+
+func (jsonWriter) Write(w io.Writer, t model.Transcription, opts Options) error {
+	segments, err := timedSegments(t)
+	if err != nil {
+		return err
+	}
+	t.Segments = segments
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(schema.NewTranscription(t))
+}
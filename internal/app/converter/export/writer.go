@@ -0,0 +1,130 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/locale"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/paragraph"
+)
+
+// Writer renders a stored transcription into an output artifact.
+type Writer interface {
+	// Extension is the file extension of the artifact, without the dot.
+	Extension() string
+	// Version must be bumped whenever the rendered output changes, so re-export can tell stale artifacts apart.
+	Version() int
+	Write(w io.Writer, t model.Transcription, opts Options) error
+}
+
+// Options are the user settings applied while rendering an artifact.
+type Options struct {
+	Locale     locale.Locale
+	Paragraphs paragraph.Options
+	Legal      LegalOptions
+}
+
+// DefaultOptions are used when the caller has no user settings.
+func DefaultOptions() Options {
+	return Options{Locale: locale.Default, Paragraphs: paragraph.DefaultOptions, Legal: DefaultLegalOptions}
+}
+
+// paragraphs groups the segments of t as configured in opts.
+func paragraphs(t model.Transcription, opts Options) []paragraph.Paragraph {
+	return paragraph.Split(paragraph.Segments(t), opts.Paragraphs)
+}
+
+var writers = map[string]Writer{
+	"txt":          textWriter{},
+	"md":           markdownWriter{},
+	"html":         htmlWriter{},
+	"srt":          srtWriter{},
+	"vtt":          vttWriter{},
+	"json":         jsonWriter{},
+	"youtube-desc": youtubeWriter{},
+	"legal":        legalWriter{},
+}
+
+// GetWriter returns the writer registered for format.
+func GetWriter(format string) (Writer, error) {
+	w, ok := writers[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported export format %q, supported: %s", format, strings.Join(Formats(), ", "))
+	}
+	return w, nil
+}
+
+// Formats lists the registered formats.
+func Formats() []string {
+	formats := make([]string, 0, len(writers))
+	for f := range writers {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ArtifactFileName derives the artifact file name from the transcription's audio file name.
+func ArtifactFileName(t model.Transcription, w Writer) string {
+	name := strings.TrimSuffix(t.Mp3FileName, filepath.Ext(t.Mp3FileName))
+	if name == "" {
+		name = fmt.Sprintf("transcription_%d", t.ID)
+	}
+	return name + "." + w.Extension()
+}
+
+type textWriter struct{}
+
+func (textWriter) Extension() string { return "txt" }
+
+func (textWriter) Version() int { return 2 }
+
+func (textWriter) Write(w io.Writer, t model.Transcription, opts Options) error {
+	for i, p := range paragraphs(t, opts) {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, speakerPrefix(p)+p.Text()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func speakerPrefix(p paragraph.Paragraph) string {
+	if p.Speaker == "" {
+		return ""
+	}
+	return p.Speaker + ": "
+}
+
+type markdownWriter struct{}
+
+func (markdownWriter) Extension() string { return "md" }
+
+func (markdownWriter) Version() int { return 3 }
+
+func (markdownWriter) Write(w io.Writer, t model.Transcription, opts Options) error {
+	title := strings.TrimSuffix(t.Mp3FileName, filepath.Ext(t.Mp3FileName))
+	_, err := fmt.Fprintf(w, "# %s\n\n- User: %s\n- Converted: %s\n- Duration: %ss\n",
+		title, t.User, opts.Locale.FormatDateTime(t.LastConversionTime), opts.Locale.FormatNumber(t.AudioDuration, 0))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paragraphs(t, opts) {
+		prefix := ""
+		if p.Speaker != "" {
+			prefix = "**" + p.Speaker + ":** "
+		}
+		if _, err = fmt.Fprintf(w, "\n%s%s\n", prefix, p.Text()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/locale"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/schema"
+	"time"
+)
+
+func TestMarkdownWriter_Locale(t *testing.T) {
+	transcription := model.Transcription{
+		User:               "alice",
+		Mp3FileName:        "talk.mp3",
+		AudioDuration:      3725,
+		LastConversionTime: time.Date(2024, 3, 9, 14, 5, 0, 0, time.UTC),
+		Transcription:      "hello",
+	}
+
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{tag: "en-US", want: []string{"- Converted: 03/09/2024 2:05 PM", "- Duration: 3,725s"}},
+		{tag: "de-DE", want: []string{"- Converted: 09.03.2024 14:05", "- Duration: 3.725s"}},
+		{tag: "zh-CN", want: []string{"- Converted: 2024年3月9日 14:05"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			l, _ := locale.Get(tt.tag)
+
+			var buf bytes.Buffer
+			if err := (markdownWriter{}).Write(&buf, transcription, Options{Locale: l}); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Write() = %q, missing %q", buf.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTMLWriter(t *testing.T) {
+	transcription := model.Transcription{
+		User:               "alice",
+		Mp3FileName:        "talk.mp3",
+		AudioDuration:      61,
+		LastConversionTime: time.Date(2024, 3, 9, 14, 5, 0, 0, time.UTC),
+		Transcription:      "第一句。<b>第二句</b>！",
+	}
+
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{
+			tag: "zh-CN",
+			want: []string{`<html lang="zh-CN" dir="ltr">`, `<h2 id="transcript-heading">转录文字</h2>`,
+				`<span class="segment" id="segment-1" tabindex="0">第一句。</span><span class="segment" id="segment-2" tabindex="0">&lt;b&gt;第二句&lt;/b&gt;！</span>`,
+				`<time datetime="2024-03-09T14:05:00Z">2024年3月9日 14:05</time>`, `<audio controls preload="metadata" src="talk.mp3">`},
+		},
+		{
+			tag:  "ar-SA",
+			want: []string{`<html lang="ar-SA" dir="rtl">`, `<h2 id="transcript-heading">Transcript</h2>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			l, _ := locale.Get(tt.tag)
+
+			var buf bytes.Buffer
+			if err := (htmlWriter{}).Write(&buf, transcription, Options{Locale: l}); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Write() missing %q in\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestTextWriter_Paragraphs(t *testing.T) {
+	transcription := model.Transcription{
+		Segments: []model.Segment{
+			{Start: 0, End: 1, Speaker: "A", Text: "Hello."},
+			{Start: 1, End: 2, Speaker: "A", Text: "Welcome."},
+			{Start: 2, End: 3, Speaker: "B", Text: "Thanks."},
+			{Start: 8, End: 9, Speaker: "B", Text: "So, the topic."},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := (textWriter{}).Write(&buf, transcription, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	want := "A: Hello. Welcome.\n\nB: Thanks.\n\nB: So, the topic."
+	if buf.String() != want {
+		t.Errorf("Write() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSubtitleWriters(t *testing.T) {
+	transcription := model.Transcription{
+		ID:            7,
+		User:          "alice",
+		Mp3FileName:   "talk.mp3",
+		Transcription: "Hello. Thanks.",
+		Segments: []model.Segment{
+			{Start: 0, End: 1.5, Speaker: "A", Text: "Hello.", Words: []model.Word{{Start: 0, End: 1.2, Text: "Hello."}}},
+			{Start: 3661.25, End: 3663, Text: "Thanks."},
+		},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "srt", want: "1\n00:00:00,000 --> 00:00:01,500\nA: Hello.\n\n2\n01:01:01,250 --> 01:01:03,000\nThanks.\n\n"},
+		{format: "vtt", want: "WEBVTT\n\n00:00:00.000 --> 00:00:01.500\n<v A>Hello.\n\n01:01:01.250 --> 01:01:03.000\nThanks.\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			w, _ := GetWriter(tt.format)
+
+			var buf bytes.Buffer
+			if err := w.Write(&buf, transcription, DefaultOptions()); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Write() = %q, want %q", buf.String(), tt.want)
+			}
+
+			if err := w.Write(&buf, model.Transcription{Transcription: "untimed"}, DefaultOptions()); !errors.Is(err, ErrNoTimedSegments) {
+				t.Errorf("Write() of an untimed transcription error = %v, want %v", err, ErrNoTimedSegments)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := (jsonWriter{}).Write(&buf, transcription, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := schema.DecodeTranscription(buf.Bytes())
+	if err != nil || doc.SchemaVersion != schema.Version || len(doc.Segments) != 2 || len(doc.Segments[0].Words) != 1 || doc.FileName != "talk.mp3" {
+		t.Errorf("json Write() = %s, %v", buf.String(), err)
+	}
+}
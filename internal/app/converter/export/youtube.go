@@ -0,0 +1,225 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/paragraph"
+	"tiktok-whisper/internal/app/util/textdiff"
+	"unicode/utf8"
+)
+
+const (
+	// YouTube only shows chapters when there are at least minChapters, each minChapterSeconds long.
+	minChapters       = 3
+	minChapterSeconds = 10
+	// maxChapters keeps the list readable, chapterSeconds is the aimed length of a chapter.
+	maxChapters    = 12
+	chapterSeconds = 180
+	// titleLength and summaryLength are in characters.
+	titleLength   = 50
+	summaryLength = 300
+	hashtagCount  = 5
+)
+
+// Chapter is a part of a transcription, starting at Start seconds.
+type Chapter struct {
+	Start float64
+	Title string
+}
+
+// youtubeWriter writes a description block to paste into YouTube: a summary of the opening
+// sentences, chapters cut at the longest pauses and hashtags of the most frequent words.
+type youtubeWriter struct{}
+
+func (youtubeWriter) Extension() string { return "youtube.txt" }
+
+func (youtubeWriter) Version() int { return 1 }
+
+func (youtubeWriter) Write(w io.Writer, t model.Transcription, opts Options) error {
+	segments, err := timedSegments(t)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(Summary(t.Transcription, summaryLength) + "\n")
+	if chapters := Chapters(segments); len(chapters) > 0 {
+		sb.WriteString("\nChapters:\n")
+		for _, c := range chapters {
+			fmt.Fprintf(&sb, "%s %s\n", youtubeTimestamp(c.Start), c.Title)
+		}
+	}
+	if tags := Hashtags(t.Transcription, hashtagCount); len(tags) > 0 {
+		sb.WriteString("\n" + strings.Join(tags, " ") + "\n")
+	}
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// Chapters splits timed segments at the longest pauses into about one chapter per three minutes.
+// The first chapter starts at 0:00, none is shorter than the others allow, and audio too short
+// for the chapters YouTube requires gets none.
+func Chapters(segments []model.Segment) []Chapter {
+	if len(segments) == 0 {
+		return nil
+	}
+	duration := segments[len(segments)-1].End
+	count := int(duration / chapterSeconds)
+	if count < minChapters {
+		count = minChapters
+	}
+	if count > maxChapters {
+		count = maxChapters
+	}
+	minLength := duration / float64(count) / 2
+	if minLength < minChapterSeconds {
+		minLength = minChapterSeconds
+	}
+
+	// the segments after the longest pauses start chapters, unless that leaves a chapter too short
+	order := make([]int, 0, len(segments)-1)
+	for i := 1; i < len(segments); i++ {
+		order = append(order, i)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return pause(segments, order[a]) > pause(segments, order[b])
+	})
+	starts := []float64{0}
+	first := []int{0}
+	for _, i := range order {
+		if len(starts) == count {
+			break
+		}
+		start := segments[i].Start
+		if start < minLength || duration-start < minLength {
+			continue
+		}
+		tooClose := false
+		for _, s := range starts {
+			if start-s < minLength && s-start < minLength {
+				tooClose = true
+				break
+			}
+		}
+		if !tooClose {
+			starts = append(starts, start)
+			first = append(first, i)
+		}
+	}
+	if len(starts) < minChapters {
+		return nil
+	}
+	sort.Ints(first)
+
+	chapters := make([]Chapter, 0, len(first))
+	for n, i := range first {
+		end := len(segments)
+		if n+1 < len(first) {
+			end = first[n+1]
+		}
+		text := paragraph.Paragraph{Segments: segments[i:end]}.Text()
+		start := segments[i].Start
+		if n == 0 {
+			start = 0
+		}
+		chapters = append(chapters, Chapter{Start: start, Title: title(text, titleLength)})
+	}
+	return chapters
+}
+
+// pause returns the silence before segment i.
+func pause(segments []model.Segment, i int) float64 {
+	return segments[i].Start - segments[i-1].End
+}
+
+// title returns the first sentence of text, cut at a word boundary before maxLength characters.
+func title(text string, maxLength int) string {
+	sentences := textdiff.Sentences(text)
+	if len(sentences) == 0 {
+		return ""
+	}
+	s := strings.TrimRight(sentences[0], "。！？!?.，,；;")
+	if utf8.RuneCountInString(s) <= maxLength {
+		return s
+	}
+
+	runes := []rune(s)[:maxLength]
+	if i := strings.LastIndex(string(runes), " "); i > 0 {
+		return string(runes)[:i] + "…"
+	}
+	return string(runes) + "…"
+}
+
+// Summary returns the opening sentences of text, as many as fit in maxLength characters
+// but at least the first one.
+func Summary(text string, maxLength int) string {
+	var summary string
+	for _, s := range textdiff.Sentences(text) {
+		next := s
+		if summary != "" && paragraph.NeedsSpace(summary, s) {
+			next = " " + s
+		}
+		if summary != "" && utf8.RuneCountInString(summary+next) > maxLength {
+			break
+		}
+		summary += next
+	}
+	return summary
+}
+
+// stopwords are frequent English words that make no hashtag.
+var stopwords = map[string]bool{
+	"about": true, "after": true, "again": true, "also": true, "because": true, "been": true, "before": true,
+	"being": true, "could": true, "does": true, "doing": true, "from": true, "have": true, "here": true,
+	"into": true, "just": true, "know": true, "like": true, "little": true, "make": true, "more": true,
+	"much": true, "only": true, "other": true, "really": true, "right": true, "should": true, "some": true,
+	"that": true, "that's": true, "their": true, "them": true, "then": true, "there": true, "these": true,
+	"they": true, "thing": true, "things": true, "think": true, "this": true, "those": true, "very": true,
+	"want": true, "well": true, "were": true, "what": true, "when": true, "where": true, "which": true,
+	"while": true, "will": true, "with": true, "would": true, "your": true, "yeah": true, "going": true,
+	"it's": true, "don't": true, "i'm": true, "you're": true, "we're": true, "people": true, "actually": true,
+}
+
+// Hashtags returns hashtags of the count most frequent words of text with at least four letters,
+// words of scripts without spaces are skipped as they are split into single characters.
+func Hashtags(text string, count int) []string {
+	freq := make(map[string]int)
+	for _, w := range textdiff.Words(text) {
+		if utf8.RuneCountInString(w) < 4 || stopwords[w] || strings.Contains(w, "'") {
+			continue
+		}
+		freq[w]++
+	}
+
+	words := make([]string, 0, len(freq))
+	for w := range freq {
+		words = append(words, w)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if freq[words[i]] != freq[words[j]] {
+			return freq[words[i]] > freq[words[j]]
+		}
+		return words[i] < words[j]
+	})
+
+	tags := make([]string, 0, count)
+	for _, w := range words {
+		if len(tags) == count {
+			break
+		}
+		tags = append(tags, "#"+w)
+	}
+	return tags
+}
+
+// youtubeTimestamp formats seconds as M:SS, or H:MM:SS for an hour and longer, as YouTube links them.
+func youtubeTimestamp(seconds float64) string {
+	s := int(seconds)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+)
+
+// talk returns a segment every 10 seconds for minutes, with a long pause before the segments in breaks.
+func talk(minutes int, breaks ...int) []model.Segment {
+	long := make(map[int]bool)
+	for _, b := range breaks {
+		long[b] = true
+	}
+	var segments []model.Segment
+	for i := 0; i < minutes*6; i++ {
+		end := float64(i*10 + 9)
+		if long[i+1] {
+			end = float64(i*10 + 5)
+		}
+		segments = append(segments, model.Segment{Start: float64(i * 10), End: end, Text: fmt.Sprintf("Part %d starts here. More words.", i)})
+	}
+	return segments
+}
+
+func TestChapters(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []model.Segment
+		want     []float64
+	}{
+		{name: "cut at the longest pauses", segments: talk(9, 20, 31), want: []float64{0, 200, 310}},
+		{name: "pauses too close to each other", segments: talk(9, 20, 22, 40), want: []float64{0, 200, 400}},
+		{name: "too short for chapters", segments: talk(0)},
+		{name: "too short for three chapters", segments: []model.Segment{{Start: 0, End: 9, Text: "a."}, {Start: 15, End: 25, Text: "b."}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []float64
+			for _, c := range Chapters(tt.segments) {
+				got = append(got, c.Start)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Chapters() start at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		want      string
+	}{
+		{name: "opening sentences", text: "First one. Second one. Third one.", maxLength: 25, want: "First one. Second one."},
+		{name: "long first sentence", text: "A very long first sentence. Next.", maxLength: 5, want: "A very long first sentence."},
+		{name: "cjk", text: "第一句。第二句。第三句。", maxLength: 8, want: "第一句。第二句。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summary(tt.text, tt.maxLength); got != tt.want {
+				t.Errorf("Summary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	text := "Whisper models are great. Whisper models run locally, and whisper is open. We think that models matter. 语音识别模型"
+	want := []string{"#models", "#whisper", "#great"}
+	if got := Hashtags(text, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("Hashtags() = %v, want %v", got, want)
+	}
+}
+
+func TestYoutubeWriter(t *testing.T) {
+	transcription := model.Transcription{ID: 3, Transcription: "Welcome to the show. Today we talk about whisper.", Segments: talk(65, 20, 300)}
+
+	var buf bytes.Buffer
+	if err := (youtubeWriter{}).Write(&buf, transcription, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Welcome to the show. Today we talk about whisper.\n\nChapters:\n0:00 Part 0 starts here\n", "\n3:20 Part 20 starts here\n", "\n50:00 Part 300 starts here\n", "\n#show #talk #today #welcome #whisper\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Write() = %s, want it to contain %q", buf.String(), want)
+		}
+	}
+
+	transcription.Segments = []model.Segment{{Text: "untimed"}}
+	if err := (youtubeWriter{}).Write(&buf, transcription, DefaultOptions()); !errors.Is(err, ErrNoTimedSegments) {
+		t.Errorf("Write() without timestamps error = %v, want %v", err, ErrNoTimedSegments)
+	}
+}
+
+func TestYoutubeTimestamp(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		want    string
+	}{
+		{0, "0:00"},
+		{65.9, "1:05"},
+		{3599, "59:59"},
+		{3723, "1:02:03"},
+	}
+	for _, tt := range tests {
+		if got := youtubeTimestamp(tt.seconds); got != tt.want {
+			t.Errorf("youtubeTimestamp(%v) = %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+}
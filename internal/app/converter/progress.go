@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"context"
+	"tiktok-whisper/internal/app/converter/batch"
+	"tiktok-whisper/internal/app/events"
+)
+
+// fileReport is a file whose conversion reports its progress on the bus, it travels in the context
+// of the conversion so the stages deep in it report for the file the run was given.
+type fileReport struct {
+	jobID string
+	user  string
+	path  string
+	// provider transcribed the file, it is set once the transcription returned.
+	provider string
+	// percent is the last extraction progress published.
+	percent int
+}
+
+type fileReportKey struct{}
+
+// withReport returns ctx reporting the progress of path, a file of job that may be nil.
+func withReport(ctx context.Context, job *batch.Job, user string, path string) context.Context {
+	r := &fileReport{user: user, path: path, percent: -1}
+	if job != nil {
+		r.jobID = job.ID()
+	}
+	return context.WithValue(ctx, fileReportKey{}, r)
+}
+
+// reportOf returns the file ctx reports for, nil when it reports for none.
+func reportOf(ctx context.Context) *fileReport {
+	r, _ := ctx.Value(fileReportKey{}).(*fileReport)
+	return r
+}
+
+// publishStarted reports that a run over files of job started, nothing is published without a job.
+func (c *Converter) publishStarted(job *batch.Job, files []string) {
+	if job == nil {
+		return
+	}
+	c.bus.Publish(events.Event{Topic: events.TopicJobStarted, JobID: job.ID(), User: job.User(), Files: len(files)})
+}
+
+// publishStage reports that the file of ctx entered stage, percent done of it.
+func (c *Converter) publishStage(ctx context.Context, stage string, percent float64) {
+	r := reportOf(ctx)
+	if r == nil {
+		return
+	}
+	c.bus.Publish(events.Event{
+		Topic:    events.TopicFileProgress,
+		JobID:    r.jobID,
+		User:     r.user,
+		FilePath: r.path,
+		Stage:    stage,
+		Progress: percent,
+	})
+}
+
+// publishExtraction reports the extraction progress of the file of ctx, once per whole percent,
+// ffmpeg reports it twice a second.
+func (c *Converter) publishExtraction(ctx context.Context, percent float64) {
+	r := reportOf(ctx)
+	if r == nil || int(percent) <= r.percent {
+		return
+	}
+	r.percent = int(percent)
+	c.publishStage(ctx, events.StageExtracting, percent)
+}
@@ -0,0 +1,75 @@
+package converter
+
+import "sync"
+
+// FileStatus is the outcome of a file in a conversion run.
+type FileStatus string
+
+const (
+	FileConverted FileStatus = "converted"
+	FileFailed    FileStatus = "failed"
+	// FileSkipped files weren't converted because the budget was spent.
+	FileSkipped FileStatus = "skipped"
+)
+
+// FileResult is the outcome of a single file.
+type FileResult struct {
+	Path   string     `json:"path"`
+	Status FileStatus `json:"status"`
+	Error  string     `json:"error,omitempty"`
+
+	err error
+}
+
+// Summary counts the outcomes of the files the converter was given, v2t convert prints it once done.
+type Summary struct {
+	Converted int          `json:"converted"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+	Files     []FileResult `json:"files"`
+}
+
+// results collects the outcomes of the files converted in parallel.
+type results struct {
+	mu    sync.Mutex
+	files []FileResult
+}
+
+func (r *results) record(filePath string, status FileStatus, err error) {
+	result := FileResult{Path: filePath, Status: status, err: err}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files = append(r.files, result)
+}
+
+// Summary returns the outcomes of the files converted so far, in the order they finished.
+func (c *Converter) Summary() Summary {
+	c.results.mu.Lock()
+	defer c.results.mu.Unlock()
+
+	summary := Summary{Files: append([]FileResult{}, c.results.files...)}
+	for _, f := range summary.Files {
+		switch f.Status {
+		case FileConverted:
+			summary.Converted++
+		case FileFailed:
+			summary.Failed++
+		case FileSkipped:
+			summary.Skipped++
+		}
+	}
+	return summary
+}
+
+// Err returns the error of the first failed file, nil when none failed.
+func (s Summary) Err() error {
+	for _, f := range s.Files {
+		if f.Status == FileFailed {
+			return f.err
+		}
+	}
+	return nil
+}
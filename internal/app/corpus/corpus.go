@@ -0,0 +1,223 @@
+// Package corpus manages the audio samples used by integration tests: it downloads public-domain
+// recordings into test/data, verifies them by checksum and records where they came from and their license.
+package corpus
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"tiktok-whisper/internal/app/util/files"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed samples.yaml
+var defaultSamples []byte
+
+// LockFile records the downloaded samples in the corpus directory.
+const LockFile = "corpus.lock.json"
+
+// Sample is a downloadable recording.
+type Sample struct {
+	Name string `yaml:"name"`
+	// File is the name the sample is stored as in the corpus directory.
+	File string `yaml:"file"`
+	URL  string `yaml:"url"`
+	// SHA256 pins the content, the first download pins it in the lock file when it is empty.
+	SHA256   string `yaml:"sha256"`
+	Language string `yaml:"language"`
+	// Noise describes the recording conditions, e.g. clean, broadcast or noisy.
+	Noise   string `yaml:"noise"`
+	License string `yaml:"license"`
+	Source  string `yaml:"source"`
+	// Transcript is the known text of the recording, used to measure accuracy. It is empty when unknown.
+	Transcript string `yaml:"transcript"`
+}
+
+// Record is a downloaded sample as recorded in the lock file.
+type Record struct {
+	File      string    `json:"file"`
+	SHA256    string    `json:"sha256"`
+	URL       string    `json:"url"`
+	License   string    `json:"license"`
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// ErrNotFetched is returned for samples that weren't downloaded yet.
+var ErrNotFetched = errors.New("sample not fetched")
+
+// Manager downloads samples into a directory and verifies them.
+type Manager struct {
+	dir     string
+	samples []Sample
+	client  *http.Client
+}
+
+// NewManager creates a new Manager instance for the samples in samples.yaml.
+func NewManager(dir string) (*Manager, error) {
+	var doc struct {
+		Samples []Sample `yaml:"samples"`
+	}
+	if err := yaml.Unmarshal(defaultSamples, &doc); err != nil {
+		return nil, fmt.Errorf("parse samples failed: %v", err)
+	}
+	return &Manager{dir: dir, samples: doc.Samples, client: &http.Client{Timeout: 5 * time.Minute}}, nil
+}
+
+// Default returns the manager of test/data in the project root.
+func Default() (*Manager, error) {
+	root, err := files.GetProjectRoot()
+	if err != nil {
+		return nil, err
+	}
+	return NewManager(filepath.Join(root, "test", "data"))
+}
+
+// Dir is the corpus directory.
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Samples returns the samples in language and with noise, empty filters match every sample.
+func (m *Manager) Samples(language string, noise string) []Sample {
+	var matched []Sample
+	for _, s := range m.samples {
+		if (language == "" || s.Language == language) && (noise == "" || s.Noise == noise) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// Sample returns the sample called name.
+func (m *Manager) Sample(name string) (Sample, error) {
+	for _, s := range m.samples {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Sample{}, fmt.Errorf("unknown sample %q", name)
+}
+
+// Fetch downloads the sample unless a verified copy is present, and records it in the lock file.
+func (m *Manager) Fetch(name string) (Record, error) {
+	s, err := m.Sample(name)
+	if err != nil {
+		return Record{}, err
+	}
+	if _, err = m.Verify(name); err == nil {
+		lock, err := m.readLock()
+		return lock[name], err
+	}
+
+	data, err := m.download(s.URL)
+	if err != nil {
+		return Record{}, fmt.Errorf("download %s failed: %v", name, err)
+	}
+	sum := checksum(data)
+
+	lock, err := m.readLock()
+	if err != nil {
+		return Record{}, err
+	}
+	want := s.SHA256
+	if want == "" {
+		want = lock[name].SHA256
+	}
+	if want != "" && sum != want {
+		return Record{}, fmt.Errorf("checksum mismatch for %s: got %s, want %s", name, sum, want)
+	}
+
+	if err = files.WriteFileAtomic(filepath.Join(m.dir, s.File), data, 0644); err != nil {
+		return Record{}, err
+	}
+	record := Record{File: s.File, SHA256: sum, URL: s.URL, License: s.License, Source: s.Source, FetchedAt: time.Now()}
+	lock[name] = record
+	return record, m.writeLock(lock)
+}
+
+// Verify returns the path of a downloaded sample whose content matches its checksum,
+// ErrNotFetched when it wasn't downloaded.
+func (m *Manager) Verify(name string) (string, error) {
+	s, err := m.Sample(name)
+	if err != nil {
+		return "", err
+	}
+	lock, err := m.readLock()
+	if err != nil {
+		return "", err
+	}
+	record, ok := lock[name]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", name, ErrNotFetched)
+	}
+
+	path := filepath.Join(m.dir, s.File)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%s: %w", name, ErrNotFetched)
+	}
+	if err != nil {
+		return "", err
+	}
+	sum := checksum(data)
+	if sum != record.SHA256 || s.SHA256 != "" && sum != s.SHA256 {
+		return "", fmt.Errorf("checksum mismatch for %s, fetch it again", path)
+	}
+	return path, nil
+}
+
+// Records returns the downloaded samples by name.
+func (m *Manager) Records() (map[string]Record, error) {
+	return m.readLock()
+}
+
+func (m *Manager) download(url string) ([]byte, error) {
+	resp, err := m.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (m *Manager) readLock() (map[string]Record, error) {
+	lock := make(map[string]Record)
+	data, err := os.ReadFile(filepath.Join(m.dir, LockFile))
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %v", LockFile, err)
+	}
+	return lock, nil
+}
+
+func (m *Manager) writeLock(lock map[string]Record) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return files.WriteFileAtomic(filepath.Join(m.dir, LockFile), append(data, '\n'), 0644)
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,99 @@
+package corpus
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManager_Fetch(t *testing.T) {
+	content := "RIFF audio"
+	var downloads int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write([]byte(content))
+	}))
+	defer ts.Close()
+
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.samples = []Sample{
+		{Name: "speech", File: "speech.wav", URL: ts.URL, Language: "en", Noise: "clean", License: "Public Domain"},
+		{Name: "pinned", File: "pinned.wav", URL: ts.URL, SHA256: checksum([]byte("other audio"))},
+	}
+
+	if _, err = m.Verify("speech"); !errors.Is(err, ErrNotFetched) {
+		t.Fatalf("Verify() before fetching error = %v, want ErrNotFetched", err)
+	}
+
+	record, err := m.Fetch("speech")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.SHA256 != checksum([]byte(content)) || record.License != "Public Domain" {
+		t.Errorf("Fetch() = %+v", record)
+	}
+	if _, err = m.Fetch("speech"); err != nil || downloads != 1 {
+		t.Errorf("Fetch() of a verified sample = %v after %d downloads, want no second download", err, downloads)
+	}
+
+	path, err := m.Verify("speech")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("corrupt"), 0644)
+	if _, err = m.Verify("speech"); err == nil || errors.Is(err, ErrNotFetched) {
+		t.Errorf("Verify() of a corrupt sample error = %v, want a checksum mismatch", err)
+	}
+
+	// The first download pinned the checksum, changed content upstream is rejected
+	content = "changed audio"
+	if _, err = m.Fetch("speech"); err == nil {
+		t.Errorf("Fetch() of changed content succeeded")
+	}
+	if _, err = m.Fetch("pinned"); err == nil {
+		t.Errorf("Fetch() not matching the manifest checksum succeeded")
+	}
+	if _, err = os.Stat(filepath.Join(m.Dir(), "pinned.wav")); !os.IsNotExist(err) {
+		t.Errorf("a sample failing its checksum was written")
+	}
+}
+
+func TestManager_Samples(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := m.Samples("", "")
+	if len(all) == 0 {
+		t.Fatal("samples.yaml has no samples")
+	}
+	for _, s := range all {
+		if s.Name == "" || s.File == "" || s.URL == "" || s.License == "" || s.Source == "" {
+			t.Errorf("sample %+v lacks its file, url, license or source", s)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		language string
+		noise    string
+		want     int
+	}{
+		{name: "english", language: "en", want: 3},
+		{name: "clean english", language: "en", noise: "clean", want: 2},
+		{name: "klingon", language: "tlh", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Samples(tt.language, tt.noise); len(got) != tt.want {
+				t.Errorf("Samples() = %v, want %d samples", got, tt.want)
+			}
+		})
+	}
+}
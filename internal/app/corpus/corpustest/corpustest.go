@@ -0,0 +1,27 @@
+// Package corpustest gives integration tests the audio samples of the corpus.
+package corpustest
+
+import (
+	"errors"
+	"testing"
+	"tiktok-whisper/internal/app/corpus"
+)
+
+// Require returns the path of the sample called name, it skips the test when the sample
+// wasn't fetched and fails it when the sample is corrupt.
+func Require(tb testing.TB, name string) string {
+	tb.Helper()
+	m, err := corpus.Default()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	path, err := m.Verify(name)
+	if errors.Is(err, corpus.ErrNotFetched) {
+		tb.Skipf("%v, run v2t corpus fetch %s", err, name)
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
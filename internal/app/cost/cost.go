@@ -0,0 +1,156 @@
+// Package cost estimates the price of transcriptions, keeps them in a ledger in the database
+// and stops conversions once the monthly budget is spent.
+package cost
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+// ErrBudgetExceeded is returned once the costs of the month reached the budget.
+var ErrBudgetExceeded = errors.New("monthly budget exceeded")
+
+// Tracker records the cost of transcriptions and checks the budget.
+type Tracker struct {
+	dao repository.CostDAO
+	cfg config.CostConfig
+	now func() time.Time
+}
+
+// NewTracker creates a new Tracker instance, it is nil when dao can't keep a cost ledger.
+func NewTracker(dao repository.TranscriptionDAO, cfg config.CostConfig) *Tracker {
+	costDAO, ok := dao.(repository.CostDAO)
+	if !ok {
+		return nil
+	}
+	return &Tracker{dao: costDAO, cfg: cfg, now: time.Now}
+}
+
+// Estimate prices a transcription: the cost the cost middleware recorded, otherwise the configured
+// rate of its provider for the audio duration. audioSeconds is used when the metadata has no duration.
+func (t *Tracker) Estimate(metadata model.ProviderMetadata, audioSeconds float64) float64 {
+	if metadata.Cost > 0 {
+		return metadata.Cost
+	}
+	if metadata.DurationSeconds > 0 {
+		audioSeconds = metadata.DurationSeconds
+	}
+	return t.cfg.Rates[metadata.Provider] * audioSeconds / 60
+}
+
+// Record adds a transcription to the ledger, transcriptionID is zero when the result isn't stored.
+func (t *Tracker) Record(transcriptionID int, user string, filePath string, metadata model.ProviderMetadata, audioSeconds float64) error {
+	if metadata.DurationSeconds > 0 {
+		audioSeconds = metadata.DurationSeconds
+	}
+	return t.dao.RecordCost(model.CostEntry{
+		TranscriptionID: transcriptionID,
+		User:            user,
+		FilePath:        filePath,
+		Provider:        metadata.Provider,
+		Model:           metadata.Model,
+		AudioSeconds:    audioSeconds,
+		Cost:            t.Estimate(metadata, audioSeconds),
+		RecordedAt:      t.now().UTC(),
+	})
+}
+
+// MonthToDate returns the costs recorded since the start of the current calendar month.
+func (t *Tracker) MonthToDate() (float64, error) {
+	entries, err := t.dao.GetCosts(MonthStart(t.now()))
+	if err != nil {
+		return 0, err
+	}
+	var sum float64
+	for _, e := range entries {
+		sum += e.Cost
+	}
+	return sum, nil
+}
+
+// CheckBudget returns ErrBudgetExceeded when the costs of the month reached the budget,
+// there is no limit without a budget.
+func (t *Tracker) CheckBudget() error {
+	if t.cfg.MonthlyBudget <= 0 {
+		return nil
+	}
+	spent, err := t.MonthToDate()
+	if err != nil {
+		return fmt.Errorf("get costs failed: %v", err)
+	}
+	if spent >= t.cfg.MonthlyBudget {
+		return fmt.Errorf("%w: spent %.2f of %.2f", ErrBudgetExceeded, spent, t.cfg.MonthlyBudget)
+	}
+	return nil
+}
+
+// MonthStart returns the start of the calendar month of now, in UTC as the ledger is.
+func MonthStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// GroupBy selects the breakdown of a report.
+type GroupBy string
+
+const (
+	ByUser            GroupBy = "user"
+	ByProvider        GroupBy = "provider"
+	ByUserAndProvider GroupBy = "user,provider"
+)
+
+// Line is a group of the report, User or Provider is empty when the report isn't grouped by it.
+type Line struct {
+	User         string  `json:"user,omitempty"`
+	Provider     string  `json:"provider,omitempty"`
+	Files        int     `json:"files"`
+	AudioSeconds float64 `json:"audio_seconds"`
+	Cost         float64 `json:"cost"`
+}
+
+// Summarize groups the entries by, ordered by cost with the most expensive group first.
+func Summarize(entries []model.CostEntry, by GroupBy) ([]Line, error) {
+	if by != ByUser && by != ByProvider && by != ByUserAndProvider {
+		return nil, fmt.Errorf("unknown grouping %q, supported: user, provider, user,provider", by)
+	}
+
+	type key struct{ user, provider string }
+	groups := make(map[key]*Line)
+	for _, e := range entries {
+		var k key
+		if by != ByProvider {
+			k.user = e.User
+		}
+		if by != ByUser {
+			k.provider = e.Provider
+		}
+		l, ok := groups[k]
+		if !ok {
+			l = &Line{User: k.user, Provider: k.provider}
+			groups[k] = l
+		}
+		l.Files++
+		l.AudioSeconds += e.AudioSeconds
+		l.Cost += e.Cost
+	}
+
+	lines := make([]Line, 0, len(groups))
+	for _, l := range groups {
+		lines = append(lines, *l)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Cost != lines[j].Cost {
+			return lines[i].Cost > lines[j].Cost
+		}
+		if lines[i].User != lines[j].User {
+			return lines[i].User < lines[j].User
+		}
+		return lines[i].Provider < lines[j].Provider
+	})
+	return lines, nil
+}
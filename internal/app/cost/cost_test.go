@@ -0,0 +1,96 @@
+package cost
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/sqlite"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	entries := []model.CostEntry{
+		{User: "alice", Provider: "openai", AudioSeconds: 60, Cost: 0.006},
+		{User: "alice", Provider: "whisper_cpp", AudioSeconds: 600},
+		{User: "bob", Provider: "openai", AudioSeconds: 300, Cost: 0.03},
+		{User: "alice", Provider: "openai", AudioSeconds: 120, Cost: 0.012},
+	}
+
+	tests := []struct {
+		name    string
+		by      GroupBy
+		want    []Line
+		wantErr bool
+	}{
+		{name: "user", by: ByUser, want: []Line{
+			{User: "bob", Files: 1, AudioSeconds: 300, Cost: 0.03},
+			{User: "alice", Files: 3, AudioSeconds: 780, Cost: 0.018},
+		}},
+		{name: "provider", by: ByProvider, want: []Line{
+			{Provider: "openai", Files: 3, AudioSeconds: 480, Cost: 0.048},
+			{Provider: "whisper_cpp", Files: 1, AudioSeconds: 600},
+		}},
+		{name: "user and provider", by: ByUserAndProvider, want: []Line{
+			{User: "bob", Provider: "openai", Files: 1, AudioSeconds: 300, Cost: 0.03},
+			{User: "alice", Provider: "openai", Files: 2, AudioSeconds: 180, Cost: 0.018},
+			{User: "alice", Provider: "whisper_cpp", Files: 1, AudioSeconds: 600},
+		}},
+		{name: "unknown", by: "model", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Summarize(entries, tt.by)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Summarize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for i := range got {
+				// The sums are compared rounded, floating point addition isn't exact
+				got[i].Cost = float64(int(got[i].Cost*1e6+0.5)) / 1e6
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Summarize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	db := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "transcription.db"))
+	defer db.Close()
+
+	now := time.Date(2023, 9, 15, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(db, config.CostConfig{Rates: map[string]float64{"openai": 0.006}, MonthlyBudget: 0.02})
+	tracker.now = func() time.Time { return now }
+
+	steps := []struct {
+		name         string
+		metadata     model.ProviderMetadata
+		audioSeconds float64
+		wantCost     float64
+		wantExceeded bool
+	}{
+		{name: "rate", metadata: model.ProviderMetadata{Provider: "openai"}, audioSeconds: 90, wantCost: 0.009},
+		{name: "unpriced provider", metadata: model.ProviderMetadata{Provider: "whisper_cpp"}, audioSeconds: 600},
+		{name: "middleware cost", metadata: model.ProviderMetadata{Provider: "openai", Cost: 0.011}, audioSeconds: 60, wantCost: 0.011, wantExceeded: true},
+	}
+	for _, s := range steps {
+		if got := tracker.Estimate(s.metadata, s.audioSeconds); got < s.wantCost-1e-9 || got > s.wantCost+1e-9 {
+			t.Errorf("%s: Estimate() = %v, want %v", s.name, got, s.wantCost)
+		}
+		if err := tracker.Record(0, "alice", s.name+".mp3", s.metadata, s.audioSeconds); err != nil {
+			t.Fatalf("%s: Record() error = %v", s.name, err)
+		}
+		if err := tracker.CheckBudget(); errors.Is(err, ErrBudgetExceeded) != s.wantExceeded {
+			t.Errorf("%s: CheckBudget() = %v, want exceeded %v", s.name, err, s.wantExceeded)
+		}
+	}
+
+	// A new month starts with the whole budget
+	now = now.AddDate(0, 1, 0)
+	if err := tracker.CheckBudget(); err != nil {
+		t.Errorf("CheckBudget() in the next month = %v", err)
+	}
+}
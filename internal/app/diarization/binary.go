@@ -0,0 +1,38 @@
+package diarization
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// BinaryDiarizer runs a local program with its args followed by the audio path, the program
+// prints the turns to stdout as RTTM or JSON.
+type BinaryDiarizer struct {
+	binaryPath string
+	args       []string
+	timeout    time.Duration
+}
+
+// NewBinaryDiarizer creates a new BinaryDiarizer instance.
+func NewBinaryDiarizer(binaryPath string, args []string, timeout time.Duration) *BinaryDiarizer {
+	return &BinaryDiarizer{binaryPath: binaryPath, args: args, timeout: timeout}
+}
+
+func (d *BinaryDiarizer) Diarize(audioFilePath string) ([]Turn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	args := append(append([]string{}, d.args...), audioFilePath)
+	command := exec.CommandContext(ctx, d.binaryPath, args...)
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+
+	if err := command.Run(); err != nil {
+		return nil, fmt.Errorf("diarization command error: %v, stderr: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return parseTurns(stdout.Bytes())
+}
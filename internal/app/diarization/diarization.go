@@ -0,0 +1,189 @@
+// Package diarization finds who speaks when in an audio file and labels the transcript segments
+// with their speakers, using a pyannote-compatible HTTP service or a local program.
+package diarization
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// Turn is a stretch of audio in which one speaker talks.
+type Turn struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Speaker string  `json:"speaker"`
+}
+
+// Diarizer returns the speaker turns of an audio file.
+type Diarizer interface {
+	Diarize(audioFilePath string) ([]Turn, error)
+}
+
+// DefaultTimeout bounds a diarization when none is configured.
+const DefaultTimeout = 10 * time.Minute
+
+// New creates the diarizer configured in config.yaml.
+func New(cfg config.DiarizationConfig) (Diarizer, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	switch cfg.Backend {
+	case "http":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("diarization.url is required for the http backend")
+		}
+		return NewHTTPDiarizer(cfg.URL, cfg.Headers, timeout), nil
+	case "binary":
+		if cfg.Binary == "" {
+			return nil, fmt.Errorf("diarization.binary is required for the binary backend")
+		}
+		return NewBinaryDiarizer(cfg.Binary, cfg.Args, timeout), nil
+	case "":
+		return nil, fmt.Errorf("no diarization backend configured, set diarization.backend in config.yaml")
+	default:
+		return nil, fmt.Errorf("unknown diarization backend %q, supported: http, binary", cfg.Backend)
+	}
+}
+
+// Assign labels every segment with the speaker whose turns overlap it the most, segments
+// no turn overlaps keep their speaker. The segments are copied, not modified.
+func Assign(segments []model.Segment, turns []Turn) []model.Segment {
+	labeled := make([]model.Segment, len(segments))
+	for i, s := range segments {
+		overlaps := make(map[string]float64)
+		for _, t := range turns {
+			if o := overlap(s.Start, s.End, t.Start, t.End); o > 0 {
+				overlaps[t.Speaker] += o
+			}
+		}
+
+		best, bestOverlap := s.Speaker, 0.0
+		for speaker, o := range overlaps {
+			// Ties go to the alphabetically first speaker, so the result doesn't depend on map order
+			if o > bestOverlap || o == bestOverlap && speaker < best {
+				best, bestOverlap = speaker, o
+			}
+		}
+		s.Speaker = best
+		labeled[i] = s
+	}
+	return labeled
+}
+
+func overlap(start1, end1, start2, end2 float64) float64 {
+	start, end := start1, end1
+	if start2 > start {
+		start = start2
+	}
+	if end2 < end {
+		end = end2
+	}
+	return end - start
+}
+
+// Speakers counts the distinct speakers of the segments.
+func Speakers(segments []model.Segment) int {
+	seen := make(map[string]bool)
+	for _, s := range segments {
+		if s.Speaker != "" {
+			seen[s.Speaker] = true
+		}
+	}
+	return len(seen)
+}
+
+// Text renders the segments as speaker-attributed text, one line per change of speaker.
+func Text(segments []model.Segment) string {
+	var lines []string
+	var speaker string
+	var texts []string
+	flush := func() {
+		if len(texts) == 0 {
+			return
+		}
+		line := strings.Join(texts, " ")
+		if speaker != "" {
+			line = speaker + ": " + line
+		}
+		lines = append(lines, line)
+		texts = nil
+	}
+
+	for _, s := range segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		if s.Speaker != speaker {
+			flush()
+			speaker = s.Speaker
+		}
+		texts = append(texts, text)
+	}
+	flush()
+	return strings.Join(lines, "\n")
+}
+
+// parseTurns reads the turns a backend returned: RTTM, which pyannote writes, or JSON as a list
+// of turns or an object holding it in "segments", "diarization" or "output.diarization".
+func parseTurns(data []byte) ([]Turn, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "{") {
+		return parseRTTM(trimmed)
+	}
+
+	var turns []Turn
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &turns); err != nil {
+			return nil, fmt.Errorf("parse turns failed: %v", err)
+		}
+	} else {
+		var doc struct {
+			Segments    []Turn `json:"segments"`
+			Diarization []Turn `json:"diarization"`
+			Output      struct {
+				Diarization []Turn `json:"diarization"`
+			} `json:"output"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse turns failed: %v", err)
+		}
+		turns = append(append(doc.Segments, doc.Diarization...), doc.Output.Diarization...)
+	}
+
+	sort.SliceStable(turns, func(i, j int) bool { return turns[i].Start < turns[j].Start })
+	return turns, nil
+}
+
+// parseRTTM reads SPEAKER lines of the RTTM format: type, file, channel, onset, duration, ortho, stype, name.
+func parseRTTM(text string) ([]Turn, error) {
+	var turns []Turn
+	for i, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "SPEAKER" {
+			continue
+		}
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("invalid RTTM line %d: %q", i+1, line)
+		}
+
+		var onset, duration float64
+		if _, err := fmt.Sscanf(fields[3]+" "+fields[4], "%g %g", &onset, &duration); err != nil {
+			return nil, fmt.Errorf("invalid RTTM line %d: %q", i+1, line)
+		}
+		turns = append(turns, Turn{Start: onset, End: onset + duration, Speaker: fields[7]})
+	}
+
+	sort.SliceStable(turns, func(i, j int) bool { return turns[i].Start < turns[j].Start })
+	return turns, nil
+}
@@ -0,0 +1,133 @@
+package diarization
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestParseTurns(t *testing.T) {
+	want := []Turn{{Start: 0, End: 2.5, Speaker: "SPEAKER_00"}, {Start: 2.5, End: 4, Speaker: "SPEAKER_01"}}
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "list", data: `[{"start":0,"end":2.5,"speaker":"SPEAKER_00"},{"start":2.5,"end":4,"speaker":"SPEAKER_01"}]`},
+		{name: "segments", data: `{"segments":[{"start":2.5,"end":4,"speaker":"SPEAKER_01"},{"start":0,"end":2.5,"speaker":"SPEAKER_00"}]}`},
+		{name: "pyannote output", data: `{"output":{"diarization":[{"start":0,"end":2.5,"speaker":"SPEAKER_00"},{"start":2.5,"end":4,"speaker":"SPEAKER_01"}]}}`},
+		{name: "rttm", data: "SPEAKER talk 1 0.000 2.500 <NA> <NA> SPEAKER_00 <NA> <NA>\nSPEAKER talk 1 2.500 1.500 <NA> <NA> SPEAKER_01 <NA> <NA>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTurns([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("parseTurns() = %v, want %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("parseTurns() = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := parseTurns([]byte("SPEAKER talk 1 zero")); err == nil {
+		t.Errorf("parseTurns() of an invalid RTTM line succeeded")
+	}
+}
+
+func TestAssign(t *testing.T) {
+	turns := []Turn{{Start: 0, End: 3, Speaker: "A"}, {Start: 3, End: 10, Speaker: "B"}}
+	segments := []model.Segment{
+		{Start: 0, End: 2, Text: "hello"},
+		{Start: 2, End: 5, Text: "mostly B"},
+		{Start: 5, End: 6, Text: "still B"},
+		{Start: 12, End: 13, Text: "silence"},
+	}
+
+	labeled := Assign(segments, turns)
+	wantSpeakers := []string{"A", "B", "B", ""}
+	for i, s := range labeled {
+		if s.Speaker != wantSpeakers[i] {
+			t.Errorf("segment %d speaker = %q, want %q", i, s.Speaker, wantSpeakers[i])
+		}
+	}
+	if segments[0].Speaker != "" {
+		t.Errorf("Assign() modified its input")
+	}
+	if n := Speakers(labeled); n != 2 {
+		t.Errorf("Speakers() = %d, want 2", n)
+	}
+
+	want := "A: hello\nB: mostly B still B\nsilence"
+	if got := Text(labeled); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestHTTPDiarizer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(f)
+		if string(data) != "audio" {
+			http.Error(w, "unexpected file", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"diarization":[{"start":0,"end":1,"speaker":"SPEAKER_00"}]}`))
+	}))
+	defer ts.Close()
+
+	audio := filepath.Join(t.TempDir(), "a.wav")
+	os.WriteFile(audio, []byte("audio"), 0644)
+
+	d, err := New(config.DiarizationConfig{Backend: "http", URL: ts.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	turns, err := d.Diarize(audio)
+	if err != nil || len(turns) != 1 || turns[0].Speaker != "SPEAKER_00" {
+		t.Errorf("Diarize() = %v, %v", turns, err)
+	}
+
+	d = NewHTTPDiarizer(ts.URL, nil, DefaultTimeout)
+	if _, err = d.Diarize(audio); err == nil {
+		t.Errorf("Diarize() without credentials succeeded")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.DiarizationConfig
+		wantErr bool
+	}{
+		{name: "http", cfg: config.DiarizationConfig{Backend: "http", URL: "http://localhost:8000/diarize"}},
+		{name: "binary", cfg: config.DiarizationConfig{Backend: "binary", Binary: "pyannote-rttm"}},
+		{name: "http without url", cfg: config.DiarizationConfig{Backend: "http"}, wantErr: true},
+		{name: "unconfigured", cfg: config.DiarizationConfig{}, wantErr: true},
+		{name: "unknown", cfg: config.DiarizationConfig{Backend: "telepathy"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package diarization
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HTTPDiarizer posts the audio to a pyannote-compatible service as the multipart field "file".
+type HTTPDiarizer struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewHTTPDiarizer creates a new HTTPDiarizer instance, headers such as Authorization are sent with every request.
+func NewHTTPDiarizer(url string, headers map[string]string, timeout time.Duration) *HTTPDiarizer {
+	return &HTTPDiarizer{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (d *HTTPDiarizer) Diarize(audioFilePath string) ([]Turn, error) {
+	f, err := os.Open(audioFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("open audio failed: %v", err)
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", filepath.Base(audioFilePath))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(fw, f); err != nil {
+		return nil, fmt.Errorf("read audio failed: %v", err)
+	}
+	if err = mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	for k, v := range d.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("diarization request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read diarization response failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("diarization service returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return parseTurns(data)
+}
@@ -0,0 +1,316 @@
+// Package embedding keeps the embeddings of the transcriptions up to date for the vector index. The
+// backfill embeds the transcriptions without an embedding of the configured model, or with one made
+// before their last revision, in batches, saving each batch as it goes so it resumes where it stopped.
+// A reindex embeds everything with a new model next to the vectors of the current one, and switches
+// the index to it once all transcriptions are embedded. Nearest searches the index.
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/embedding/provider"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+var (
+	// ErrNotSupported is returned when the database can't keep embeddings.
+	ErrNotSupported = errors.New("the database does not keep embeddings")
+	// ErrOtherModel is returned by Run when the index uses another model than the backfiller,
+	// switching models takes a Reindex.
+	ErrOtherModel = errors.New("the vector index uses another embedding model")
+	// ErrIncomplete is returned by Reindex when transcriptions failed to embed with the new model,
+	// the index keeps its model until a Reindex embeds them all.
+	ErrIncomplete = errors.New("not every transcription is embedded with the new model")
+)
+
+const (
+	// DefaultBatchSize is the number of transcripts per request unless one is configured.
+	DefaultBatchSize = 16
+	// DefaultMaxChars truncates the transcripts unless a length is configured.
+	DefaultMaxChars = 8000
+	// DefaultInterval separates two backfills of the daemon unless one is configured.
+	DefaultInterval = 10 * time.Minute
+)
+
+// Backfiller embeds the transcriptions missing an embedding of its embedder.
+type Backfiller struct {
+	embedder  provider.Embedder
+	db        repository.TranscriptionDAO
+	batchSize int
+	maxChars  int
+	limiter   *limiter
+	now       func() time.Time
+}
+
+// NewBackfiller creates a new Backfiller sending the batches, requests spaced by the rate limit of
+// the provider of embedder in cfg.
+func NewBackfiller(embedder provider.Embedder, transcriptionDAO repository.TranscriptionDAO, cfg config.EmbeddingConfig) *Backfiller {
+	b := &Backfiller{
+		embedder:  embedder,
+		db:        transcriptionDAO,
+		batchSize: cfg.BatchSize,
+		maxChars:  cfg.MaxChars,
+		limiter:   newLimiter(cfg.RequestsPerMinute[providerOf(embedder)]),
+		now:       time.Now,
+	}
+	if b.batchSize <= 0 {
+		b.batchSize = DefaultBatchSize
+	}
+	if b.maxChars <= 0 {
+		b.maxChars = DefaultMaxChars
+	}
+	return b
+}
+
+func (b *Backfiller) Close() error {
+	return b.db.Close()
+}
+
+// Model is the name the embeddings are stored under, that of the embedder.
+func (b *Backfiller) Model() string {
+	return b.embedder.Name()
+}
+
+// Result counts what Run did.
+type Result struct {
+	Embedded int
+	Failed   int
+	// Remaining transcriptions still miss an embedding, because of the limit or because they failed.
+	Remaining int
+}
+
+// Run embeds up to limit transcriptions, all of them when limit is zero or less, lowest ID first.
+// A batch the provider rejects is sent again one transcript at a time so a single bad transcript
+// only fails itself, the failed ones are tried again by the next Run. It returns the context error
+// once ctx is done, keeping what was saved so far. The first model to run becomes the model of the
+// index, Run fails with ErrOtherModel for any other.
+func (b *Backfiller) Run(ctx context.Context, limit int) (Result, error) {
+	embeddings, ok := b.db.(repository.EmbeddingDAO)
+	if !ok {
+		return Result{}, ErrNotSupported
+	}
+
+	index, err := embeddings.GetIndexModel()
+	if err != nil {
+		return Result{}, fmt.Errorf("get index model failed: %v", err)
+	}
+	switch index {
+	case b.Model():
+	case "":
+		if err = embeddings.SetIndexModel(b.Model(), false, b.now()); err != nil {
+			return Result{}, fmt.Errorf("set index model failed: %v", err)
+		}
+	default:
+		return Result{}, fmt.Errorf("%w: %s, not %s", ErrOtherModel, index, b.Model())
+	}
+	return b.backfill(ctx, embeddings, limit)
+}
+
+// Reindex embeds every transcription with the model of the backfiller, then switches the index to
+// it. Until then the index keeps its model and vectors, so it stays usable while the reindex runs, and a
+// stopped Reindex resumes where it stopped. Unless keep is set the vectors of the other models are
+// deleted in the transaction switching the index.
+func (b *Backfiller) Reindex(ctx context.Context, keep bool) (Result, error) {
+	embeddings, ok := b.db.(repository.EmbeddingDAO)
+	if !ok {
+		return Result{}, ErrNotSupported
+	}
+
+	result, err := b.backfill(ctx, embeddings, 0)
+	if err != nil {
+		return result, err
+	}
+	if result.Remaining > 0 {
+		return result, fmt.Errorf("%w: %d remaining", ErrIncomplete, result.Remaining)
+	}
+	if err = embeddings.SetIndexModel(b.Model(), !keep, b.now()); err != nil {
+		return result, fmt.Errorf("set index model failed: %v", err)
+	}
+	log.Printf("The vector index now uses %s\n", b.Model())
+	return result, nil
+}
+
+// backfill embeds up to limit transcriptions missing an embedding of the model.
+func (b *Backfiller) backfill(ctx context.Context, embeddings repository.EmbeddingDAO, limit int) (Result, error) {
+	var result Result
+	for afterID := 0; limit <= 0 || result.Embedded+result.Failed < limit; {
+		size := b.batchSize
+		if left := limit - result.Embedded - result.Failed; limit > 0 && left < size {
+			size = left
+		}
+		batch, err := embeddings.GetTranscriptionsWithoutEmbeddings(b.Model(), afterID, size)
+		if err != nil {
+			return result, fmt.Errorf("get transcriptions without embeddings failed: %v", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		afterID = batch[len(batch)-1].ID
+
+		embedded, failed, err := b.embed(ctx, embeddings, batch)
+		result.Embedded += embedded
+		result.Failed += failed
+		if err != nil {
+			return result, err
+		}
+		log.Printf("Embedded %d transcriptions with %s, %d failed, up to ID %d\n", result.Embedded, b.Model(), result.Failed, afterID)
+	}
+
+	progress, err := embeddings.GetEmbeddingProgress(b.Model())
+	if err != nil {
+		return result, fmt.Errorf("get embedding progress failed: %v", err)
+	}
+	result.Remaining = progress.Pending
+	return result, nil
+}
+
+// Daemon runs a backfill every interval until ctx is done, picking up the new and revised
+// transcriptions. A failed backfill is logged and tried again at the next interval.
+func (b *Backfiller) Daemon(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	for {
+		result, err := b.Run(ctx, 0)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case errors.Is(err, ErrNotSupported), errors.Is(err, ErrOtherModel):
+			return err
+		case err != nil:
+			log.Printf("Embedding backfill failed: %v\n", err)
+		case result.Embedded+result.Failed > 0:
+			log.Printf("Embedding backfill done: embedded %d, failed %d, %d remaining\n", result.Embedded, result.Failed, result.Remaining)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// embed embeds batch and saves the embeddings, one transcript at a time when the provider rejects
+// the batch. Failing to save is an error, failing to embed a transcript only counts it as failed.
+func (b *Backfiller) embed(ctx context.Context, embeddings repository.EmbeddingDAO, batch []model.Transcription) (embedded, failed int, err error) {
+	// taken before the request so a revision made meanwhile leaves the embedding stale
+	embeddedAt := b.now()
+	vectors, err := b.request(ctx, batch)
+	switch {
+	case err == nil:
+		if err = b.save(embeddings, batch, vectors, embeddedAt); err != nil {
+			return 0, 0, err
+		}
+		return len(batch), 0, nil
+	case ctx.Err() != nil:
+		return 0, 0, ctx.Err()
+	case len(batch) == 1:
+		log.Printf("Embedding transcription %d failed: %v\n", batch[0].ID, err)
+		return 0, 1, nil
+	}
+	log.Printf("Embedding a batch of %d transcriptions failed, embedding them one by one: %v\n", len(batch), err)
+
+	for i := range batch {
+		one := batch[i : i+1]
+		embeddedAt = b.now()
+		if vectors, err = b.request(ctx, one); ctx.Err() != nil {
+			return embedded, failed, ctx.Err()
+		}
+		if err != nil {
+			log.Printf("Embedding transcription %d failed: %v\n", batch[i].ID, err)
+			failed++
+			continue
+		}
+		if err = b.save(embeddings, one, vectors, embeddedAt); err != nil {
+			return embedded, failed, err
+		}
+		embedded++
+	}
+	return embedded, failed, nil
+}
+
+// request embeds the transcripts of batch, truncated, in a single request.
+func (b *Backfiller) request(ctx context.Context, batch []model.Transcription) ([][]float32, error) {
+	if err := b.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(batch))
+	for i, t := range batch {
+		texts[i] = truncate(t.Transcription, b.maxChars)
+	}
+	return b.embedder.Embed(ctx, texts)
+}
+
+func (b *Backfiller) save(embeddings repository.EmbeddingDAO, batch []model.Transcription, vectors [][]float32, embeddedAt time.Time) error {
+	for i, t := range batch {
+		e := model.Embedding{TranscriptionID: t.ID, Model: b.Model(), Vector: vectors[i], EmbeddedAt: embeddedAt}
+		if err := embeddings.SaveEmbedding(e); err != nil {
+			return fmt.Errorf("save embedding of transcription %d failed: %v", t.ID, err)
+		}
+	}
+	return nil
+}
+
+// truncate cuts text to its first maxChars characters.
+func truncate(text string, maxChars int) string {
+	if len(text) <= maxChars {
+		return text
+	}
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars])
+}
+
+// providerOf returns the provider part of the name of e, e.g. ollama for ollama/nomic-embed-text.
+func providerOf(e provider.Embedder) string {
+	name, _, _ := strings.Cut(e.Name(), "/")
+	return name
+}
+
+// limiter spaces the requests evenly so at most requestsPerMinute start per minute.
+type limiter struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// newLimiter returns a limiter, nil when requestsPerMinute is zero, which never waits.
+func newLimiter(requestsPerMinute float64) *limiter {
+	if requestsPerMinute <= 0 {
+		return nil
+	}
+	return &limiter{interval: time.Duration(float64(time.Minute) / requestsPerMinute)}
+}
+
+// wait blocks until the next request may start or ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	if delay == 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,184 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+// fakeEmbedder embeds a text as its length and rejects the texts containing "bad".
+type fakeEmbedder struct {
+	name     string
+	requests [][]string
+}
+
+func (e *fakeEmbedder) Name() string {
+	if e.name == "" {
+		return "fake/model"
+	}
+	return e.name
+}
+
+func (e *fakeEmbedder) Dimensions() int { return 1 }
+
+func (e *fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.requests = append(e.requests, texts)
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "bad") {
+			return nil, errors.New("rejected")
+		}
+		vectors[i] = []float32{float32(len(text))}
+	}
+	return vectors, nil
+}
+
+func TestBackfiller_Run(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		texts        []string
+		cfg          config.EmbeddingConfig
+		limit        int
+		want         Result
+		wantRequests [][]string
+	}{
+		{
+			name:         "batches",
+			texts:        []string{"a", "bb", "", "ccc"},
+			cfg:          config.EmbeddingConfig{BatchSize: 2},
+			want:         Result{Embedded: 3},
+			wantRequests: [][]string{{"a", "bb"}, {"ccc"}},
+		},
+		{
+			name:         "limited",
+			texts:        []string{"a", "bb", "ccc"},
+			cfg:          config.EmbeddingConfig{BatchSize: 2},
+			limit:        1,
+			want:         Result{Embedded: 1, Remaining: 2},
+			wantRequests: [][]string{{"a"}},
+		},
+		{
+			name:         "rejected batch sent one by one",
+			texts:        []string{"a", "bad", "ccc"},
+			want:         Result{Embedded: 2, Failed: 1, Remaining: 1},
+			wantRequests: [][]string{{"a", "bad", "ccc"}, {"a"}, {"bad"}, {"ccc"}},
+		},
+		{
+			name:         "truncated",
+			texts:        []string{"héllo"},
+			cfg:          config.EmbeddingConfig{MaxChars: 2},
+			want:         Result{Embedded: 1},
+			wantRequests: [][]string{{"hé"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemoryDB()
+			for _, text := range tt.texts {
+				db.RecordToDB("alice", "/in", text+".mp4", text+".mp3", 60, text, now, 0, "", model.ProviderMetadata{})
+			}
+			e := &fakeEmbedder{}
+			b := NewBackfiller(e, db, tt.cfg)
+
+			got, err := b.Run(context.Background(), tt.limit)
+			if err != nil || got != tt.want {
+				t.Errorf("Run() = %+v, %v, want %+v", got, err, tt.want)
+			}
+			if !reflect.DeepEqual(e.requests, tt.wantRequests) {
+				t.Errorf("sent %q, want %q", e.requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestBackfiller_RunResumes(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	db := memory.NewMemoryDB()
+	for _, text := range []string{"a", "bb"} {
+		db.RecordToDB("alice", "/in", text+".mp4", text+".mp3", 60, text, now, 0, "", model.ProviderMetadata{})
+	}
+	e := &fakeEmbedder{}
+	b := NewBackfiller(e, db, config.EmbeddingConfig{BatchSize: 1})
+	b.now = func() time.Time { return now.Add(time.Minute) }
+
+	if _, err := b.Run(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AddRevision(1, "aaaa", model.ProviderMetadata{}, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	b.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if got, err := b.Run(context.Background(), 0); err != nil || got != (Result{Embedded: 2}) {
+		t.Errorf("Run() = %+v, %v, want the revised and the remaining transcriptions embedded", got, err)
+	}
+	if want := [][]string{{"a"}, {"aaaa"}, {"bb"}}; !reflect.DeepEqual(e.requests, want) {
+		t.Errorf("sent %q, want %q", e.requests, want)
+	}
+	if got, err := db.GetEmbedding(1, "fake/model"); err != nil || !reflect.DeepEqual(got.Vector, []float32{4}) {
+		t.Errorf("GetEmbedding() = %+v, %v, want the embedding of the revision", got, err)
+	}
+}
+
+func TestBackfiller_Reindex(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	db := memory.NewMemoryDB()
+	for _, text := range []string{"a", "bad"} {
+		db.RecordToDB("alice", "/in", text+".mp4", text+".mp3", 60, text, now, 0, "", model.ProviderMetadata{})
+	}
+	if _, err := NewBackfiller(&fakeEmbedder{}, db, config.EmbeddingConfig{}).Run(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	large := NewBackfiller(&fakeEmbedder{name: "fake/large"}, db, config.EmbeddingConfig{})
+	if _, err := large.Run(context.Background(), 0); !errors.Is(err, ErrOtherModel) {
+		t.Errorf("Run() of another model error = %v, want ErrOtherModel", err)
+	}
+	if got, err := large.Reindex(context.Background(), false); !errors.Is(err, ErrIncomplete) || got.Embedded != 1 {
+		t.Errorf("Reindex() = %+v, %v, want ErrIncomplete", got, err)
+	}
+	if got, _ := db.GetIndexModel(); got != "fake/model" {
+		t.Errorf("GetIndexModel() after an incomplete Reindex() = %q, want the old model", got)
+	}
+
+	db.AddRevision(2, "bb", model.ProviderMetadata{}, now.Add(time.Hour))
+	if got, err := large.Reindex(context.Background(), false); err != nil || got != (Result{Embedded: 1}) {
+		t.Errorf("Reindex() = %+v, %v, want the remaining transcription embedded", got, err)
+	}
+	if got, _ := db.GetIndexModel(); got != "fake/large" {
+		t.Errorf("GetIndexModel() = %q, want the new model", got)
+	}
+	if _, err := db.GetEmbedding(1, "fake/model"); err == nil {
+		t.Error("GetEmbedding() of the old model succeeded, want its vectors dropped")
+	}
+	if _, err := large.Run(context.Background(), 0); err != nil {
+		t.Errorf("Run() of the new model error = %v", err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(60 * 1000)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Millisecond {
+		t.Errorf("3 requests at 1000 a second took %v, want at least 2ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = newLimiter(1)
+	l.wait(ctx)
+	if err := l.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() error = %v, want context.Canceled", err)
+	}
+}
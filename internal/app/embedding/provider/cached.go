@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"context"
+	"tiktok-whisper/internal/app/llm/cache"
+)
+
+// Cached is an embedder answering the texts it embedded before from a cache, keyed by the name of
+// the embedder and the text, so only the new texts of a batch are sent.
+type Cached struct {
+	Embedder
+	store *cache.Store
+}
+
+// NewCached returns e answering from store.
+func NewCached(e Embedder, store *cache.Store) *Cached {
+	return &Cached{Embedder: e, store: store}
+}
+
+func (c *Cached) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	var missing []string
+	var positions []int
+	for i, text := range texts {
+		if !c.store.Get(c.key(text), &vectors[i]) {
+			missing = append(missing, text)
+			positions = append(positions, i)
+		}
+	}
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := c.Embedder.Embed(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range embedded {
+		vectors[positions[i]] = v
+		c.store.Put(c.key(missing[i]), v)
+	}
+	return vectors, nil
+}
+
+func (c *Cached) key(text string) string {
+	return cache.Key("embedding", c.Name(), text)
+}
@@ -0,0 +1,255 @@
+// Package provider embeds texts for the vector index with the model of embedding in config.yaml: the
+// OpenAI API, a local Ollama server or any HTTP server answering texts with their vectors, such as a
+// sentence-transformers server. With a local model the index is built without paying for embeddings.
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/config/secrets"
+	"tiktok-whisper/internal/app/llm/cache"
+	"time"
+)
+
+// Embedder turns texts into vectors.
+type Embedder interface {
+	// Name of the provider and model, e.g. ollama/nomic-embed-text.
+	Name() string
+	// Dimensions of the vectors, zero until the first ones when the configuration sets none.
+	Dimensions() int
+	// Embed returns the vectors of texts in their order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Providers are the providers New creates.
+var Providers = []string{"openai", "ollama", "http"}
+
+const (
+	// OpenAIURL is the base URL of the OpenAI API.
+	OpenAIURL = "https://api.openai.com/v1"
+	// OpenAIModel is the model of openai unless one is configured.
+	OpenAIModel = "text-embedding-3-small"
+	// OllamaURL is the API of a local Ollama server.
+	OllamaURL = "http://localhost:11434"
+)
+
+// DefaultTimeout bounds a request when none is configured.
+const DefaultTimeout = time.Minute
+
+// HTTPEmbedder is a model behind an HTTP API taking a JSON list of texts. It reads the vectors of
+// the responses of the OpenAI API, of Ollama and of servers answering a bare list of vectors.
+type HTTPEmbedder struct {
+	client   *http.Client
+	provider string
+	model    string
+	endpoint string
+	key      string
+	// inputField is the field of the request holding the texts
+	inputField string
+	// sendDimensions asks the API for vectors of the configured dimensions
+	sendDimensions bool
+
+	mu         sync.Mutex
+	dimensions int
+}
+
+// New creates the embedder configured in config.yaml.
+func New(cfg config.EmbeddingConfig) (*HTTPEmbedder, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	e := &HTTPEmbedder{
+		client:     &http.Client{Timeout: timeout},
+		provider:   cfg.Provider,
+		model:      cfg.Model,
+		inputField: "input",
+		dimensions: cfg.Dimensions,
+	}
+
+	switch cfg.Provider {
+	case "openai", "":
+		e.provider = "openai"
+		if e.model == "" {
+			e.model = OpenAIModel
+		}
+		url := cfg.URL
+		if url == "" {
+			url = OpenAIURL
+		}
+		e.endpoint = strings.TrimSuffix(url, "/") + "/embeddings"
+		var err error
+		if e.key, err = secrets.Key("openai"); err != nil {
+			return nil, err
+		}
+		e.sendDimensions = cfg.Dimensions > 0
+	case "ollama":
+		if e.model == "" {
+			return nil, errors.New("embedding.model is required by the ollama provider, e.g. nomic-embed-text")
+		}
+		url := cfg.URL
+		if url == "" {
+			url = OllamaURL
+		}
+		e.endpoint = strings.TrimSuffix(url, "/") + "/api/embed"
+	case "http":
+		if cfg.URL == "" {
+			return nil, errors.New("embedding.url is required by the http provider")
+		}
+		e.endpoint = cfg.URL
+		if cfg.KeyEnv != "" {
+			e.key = os.Getenv(cfg.KeyEnv)
+		}
+		if cfg.InputField != "" {
+			e.inputField = cfg.InputField
+		}
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q, supported: %s", cfg.Provider, strings.Join(Providers, ", "))
+	}
+	return e, nil
+}
+
+func (e *HTTPEmbedder) Name() string {
+	if e.model == "" {
+		return e.provider
+	}
+	return e.provider + "/" + e.model
+}
+
+func (e *HTTPEmbedder) Dimensions() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dimensions
+}
+
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	request := map[string]interface{}{e.inputField: texts}
+	if e.model != "" {
+		request["model"] = e.model
+	}
+	if e.sendDimensions {
+		request["dimensions"] = e.Dimensions()
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.key != "" {
+		req.Header.Set("Authorization", "Bearer "+e.key)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding with %s failed: %v", e.Name(), err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read embeddings of %s failed: %v", e.Name(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding with %s failed: %s %s", e.Name(), resp.Status, strings.TrimSpace(string(data)))
+	}
+	vectors, err := parseVectors(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse embeddings of %s failed: %v", e.Name(), err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("%s answered %d embeddings for %d texts", e.Name(), len(vectors), len(texts))
+	}
+	if err = e.check(vectors); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// check fails when vectors don't have the dimensions of the embedder, which are those of the first
+// vectors when none are configured.
+func (e *HTTPEmbedder) check(vectors [][]float32) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, v := range vectors {
+		if e.dimensions == 0 {
+			e.dimensions = len(v)
+		}
+		if len(v) != e.dimensions {
+			return fmt.Errorf("%s answered a vector of %d dimensions, want %d", e.Name(), len(v), e.dimensions)
+		}
+	}
+	return nil
+}
+
+// parseVectors reads the vectors of the OpenAI response, {"data":[{"embedding":[...],"index":0}]},
+// of the Ollama one, {"embeddings":[[...]]}, or of a bare list of vectors.
+func parseVectors(data []byte) ([][]float32, error) {
+	var vectors [][]float32
+	if err := json.Unmarshal(data, &vectors); err == nil {
+		return vectors, nil
+	}
+	var r struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		} `json:"data"`
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if r.Data == nil {
+		if r.Embeddings == nil {
+			return nil, errors.New("no data nor embeddings in the response")
+		}
+		return r.Embeddings, nil
+	}
+	sort.SliceStable(r.Data, func(i, j int) bool { return r.Data[i].Index < r.Data[j].Index })
+	for _, d := range r.Data {
+		vectors = append(vectors, d.Embedding)
+	}
+	return vectors, nil
+}
+
+var (
+	defaultEmbedder Embedder
+	defaultErr      error
+	defaultOnce     sync.Once
+)
+
+// Default returns the embedder of config.yaml, created once.
+func Default() (Embedder, error) {
+	defaultOnce.Do(func() {
+		defaultEmbedder, defaultErr = FromConfig(config.Get().Embedding)
+	})
+	return defaultEmbedder, defaultErr
+}
+
+// FromConfig creates the embedder of cfg, answering from the cache of llm.cache unless that is
+// disabled.
+func FromConfig(cfg config.EmbeddingConfig) (Embedder, error) {
+	e, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if store := cache.FromConfig(config.Get().LLM.Cache); store != nil {
+		return NewCached(e, store), nil
+	}
+	return e, nil
+}
@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/llm/cache"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "key")
+	tests := []struct {
+		name     string
+		cfg      config.EmbeddingConfig
+		wantName string
+		wantErr  string
+	}{
+		{name: "openai by default", cfg: config.EmbeddingConfig{}, wantName: "openai/" + OpenAIModel},
+		{name: "ollama", cfg: config.EmbeddingConfig{Provider: "ollama", Model: "nomic-embed-text"}, wantName: "ollama/nomic-embed-text"},
+		{name: "ollama without a model", cfg: config.EmbeddingConfig{Provider: "ollama"}, wantErr: "embedding.model"},
+		{name: "http without a model", cfg: config.EmbeddingConfig{Provider: "http", URL: "http://localhost:8080/embed"}, wantName: "http"},
+		{name: "http without a url", cfg: config.EmbeddingConfig{Provider: "http"}, wantErr: "embedding.url"},
+		{name: "unknown provider", cfg: config.EmbeddingConfig{Provider: "gemini"}, wantErr: "unknown embedding provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || e.Name() != tt.wantName {
+				t.Errorf("New() = %v, %v, want %s", e, err, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestHTTPEmbedder_Embed(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "openai-key")
+	t.Setenv("EMBEDDING_KEY", "local-key")
+	tests := []struct {
+		name           string
+		cfg            config.EmbeddingConfig
+		response       string
+		wantPath       string
+		wantAuth       string
+		wantBody       map[string]interface{}
+		want           [][]float32
+		wantDimensions int
+		wantErr        string
+	}{
+		{
+			name:           "openai shortened",
+			cfg:            config.EmbeddingConfig{Provider: "openai", Dimensions: 2},
+			response:       `{"data":[{"embedding":[0.3,0.4],"index":1},{"embedding":[0.1,0.2],"index":0}]}`,
+			wantPath:       "/v1/embeddings",
+			wantAuth:       "Bearer openai-key",
+			wantBody:       map[string]interface{}{"model": OpenAIModel, "input": []interface{}{"a", "b"}, "dimensions": float64(2)},
+			want:           [][]float32{{0.1, 0.2}, {0.3, 0.4}},
+			wantDimensions: 2,
+		},
+		{
+			name:           "ollama",
+			cfg:            config.EmbeddingConfig{Provider: "ollama", Model: "nomic-embed-text"},
+			response:       `{"model":"nomic-embed-text","embeddings":[[1,2,3],[4,5,6]]}`,
+			wantPath:       "/api/embed",
+			wantBody:       map[string]interface{}{"model": "nomic-embed-text", "input": []interface{}{"a", "b"}},
+			want:           [][]float32{{1, 2, 3}, {4, 5, 6}},
+			wantDimensions: 3,
+		},
+		{
+			name:           "http answering a list",
+			cfg:            config.EmbeddingConfig{Provider: "http", InputField: "inputs", KeyEnv: "EMBEDDING_KEY"},
+			response:       `[[1,0],[0,1]]`,
+			wantPath:       "/embed",
+			wantAuth:       "Bearer local-key",
+			wantBody:       map[string]interface{}{"inputs": []interface{}{"a", "b"}},
+			want:           [][]float32{{1, 0}, {0, 1}},
+			wantDimensions: 2,
+		},
+		{
+			name:     "http with other dimensions",
+			cfg:      config.EmbeddingConfig{Provider: "http", Model: "all-MiniLM-L6-v2", Dimensions: 384},
+			response: `{"embeddings":[[1,0],[0,1]]}`,
+			wantPath: "/embed",
+			wantBody: map[string]interface{}{"model": "all-MiniLM-L6-v2", "input": []interface{}{"a", "b"}},
+			wantErr:  "2 dimensions, want 384",
+		},
+		{
+			name:     "missing vectors",
+			cfg:      config.EmbeddingConfig{Provider: "http"},
+			response: `{"embeddings":[[1,0]]}`,
+			wantPath: "/embed",
+			wantBody: map[string]interface{}{"input": []interface{}{"a", "b"}},
+			wantErr:  "1 embeddings for 2 texts",
+		},
+		{
+			name:     "server error",
+			cfg:      config.EmbeddingConfig{Provider: "ollama", Model: "missing"},
+			response: `{"error":"model \"missing\" not found"}`,
+			wantPath: "/api/embed",
+			wantBody: map[string]interface{}{"model": "missing", "input": []interface{}{"a", "b"}},
+			wantErr:  "not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&body)
+				if r.URL.Path != tt.wantPath || r.Header.Get("Authorization") != tt.wantAuth || !reflect.DeepEqual(body, tt.wantBody) {
+					t.Errorf("sent %v to %s with %q, want %v to %s with %q", body, r.URL.Path, r.Header.Get("Authorization"), tt.wantBody, tt.wantPath, tt.wantAuth)
+				}
+				if strings.Contains(tt.response, "error") {
+					w.WriteHeader(http.StatusNotFound)
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			switch tt.cfg.Provider {
+			case "openai":
+				tt.cfg.URL = srv.URL + "/v1/"
+			case "ollama":
+				tt.cfg.URL = srv.URL
+			default:
+				tt.cfg.URL = srv.URL + "/embed"
+			}
+			e, err := New(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.Embed(context.Background(), []string{"a", "b"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Embed() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) || e.Dimensions() != tt.wantDimensions {
+				t.Errorf("Embed() = %v of %d dimensions, %v, want %v of %d", got, e.Dimensions(), err, tt.want, tt.wantDimensions)
+			}
+		})
+	}
+}
+
+func TestCached(t *testing.T) {
+	var sent [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Input []string }
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body.Input)
+		vectors := make([][]float32, len(body.Input))
+		for i, text := range body.Input {
+			vectors[i] = []float32{float32(len(text))}
+		}
+		json.NewEncoder(w).Encode(vectors)
+	}))
+	defer srv.Close()
+
+	e, err := New(config.EmbeddingConfig{Provider: "http", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCached(e, cache.New(t.TempDir(), time.Hour, 1<<20))
+	if got, err := c.Embed(context.Background(), []string{"a", "bb"}); err != nil || !reflect.DeepEqual(got, [][]float32{{1}, {2}}) {
+		t.Fatalf("Embed() = %v, %v", got, err)
+	}
+	if got, err := c.Embed(context.Background(), []string{"ccc", "a"}); err != nil || !reflect.DeepEqual(got, [][]float32{{3}, {1}}) {
+		t.Fatalf("Embed() = %v, %v", got, err)
+	}
+	if want := [][]string{{"a", "bb"}, {"ccc"}}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %v, want only the texts not cached %v", sent, want)
+	}
+}
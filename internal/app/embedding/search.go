@@ -0,0 +1,82 @@
+package embedding
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+)
+
+// ErrNoIndex is returned by Nearest before anything was embedded.
+var ErrNoIndex = errors.New("the vector index is empty")
+
+// Match is a transcription Nearest found, with the cosine similarity of its embedding to the query.
+type Match struct {
+	Transcription model.Transcription
+	Similarity    float64
+}
+
+// Nearest returns the k transcriptions of user whose embeddings in the vector index are the most
+// similar to vector, the most similar first, all of them when k is zero or less. vector must be an
+// embedding of modelName, the model of the index, Nearest fails with ErrOtherModel otherwise.
+// Transcriptions revised since they were embedded are matched by their previous embedding until the
+// backfill embeds them again, those without one aren't matched.
+func Nearest(db repository.TranscriptionDAO, modelName string, user string, vector []float32, k int) ([]Match, error) {
+	embeddings, ok := db.(repository.EmbeddingDAO)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	index, err := embeddings.GetIndexModel()
+	if err != nil {
+		return nil, fmt.Errorf("get index model failed: %v", err)
+	}
+	switch index {
+	case modelName:
+	case "":
+		return nil, ErrNoIndex
+	default:
+		return nil, fmt.Errorf("%w: %s, not %s", ErrOtherModel, index, modelName)
+	}
+
+	transcriptions, err := db.GetAllByUser(user)
+	if err != nil {
+		return nil, fmt.Errorf("get transcriptions of %s failed: %v", user, err)
+	}
+	matches := make([]Match, 0, len(transcriptions))
+	for _, t := range transcriptions {
+		e, err := embeddings.GetEmbedding(t.ID, index)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get embedding of transcription %d failed: %v", t.ID, err)
+		}
+		matches = append(matches, Match{Transcription: t, Similarity: Cosine(vector, e.Vector)})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// Cosine returns the cosine similarity of a and b, zero when their dimensions differ or either is a
+// zero vector.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
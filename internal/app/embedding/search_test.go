@@ -0,0 +1,58 @@
+package embedding
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+func TestNearest(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		index   string
+		vector  []float32
+		k       int
+		want    []int
+		wantErr error
+	}{
+		{name: "most similar first", index: "m", vector: []float32{1, 0}, want: []int{1, 3, 2}},
+		{name: "k nearest", index: "m", vector: []float32{0, 1}, k: 2, want: []int{2, 3}},
+		{name: "other model", index: "other", vector: []float32{1, 0}, wantErr: ErrOtherModel},
+		{name: "empty index", vector: []float32{1, 0}, wantErr: ErrNoIndex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemoryDB()
+			vectors := [][]float32{{2, 0}, {0, 3}, {1, 1}, nil, {1, 0}}
+			for i, v := range vectors {
+				user := "alice"
+				if i == len(vectors)-1 {
+					user = "bob"
+				}
+				db.RecordToDB(user, "/in", "a.mp4", "a.mp3", 60, "text", now, 0, "", model.ProviderMetadata{})
+				if v != nil {
+					db.SaveEmbedding(model.Embedding{TranscriptionID: i + 1, Model: "m", Vector: v, EmbeddedAt: now})
+				}
+			}
+			if tt.index != "" {
+				db.SetIndexModel(tt.index, false, now)
+			}
+
+			matches, err := Nearest(db, "m", "alice", tt.vector, tt.k)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Nearest() error = %v, want %v", err, tt.wantErr)
+			}
+			var got []int
+			for _, m := range matches {
+				got = append(got, m.Transcription.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Nearest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package events
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"time"
+)
+
+// Topic identifies a kind of pipeline event.
+type Topic string
+
+const (
+	// TopicAll subscribes to every topic.
+	TopicAll Topic = "*"
+
+	TopicFileDone          Topic = "file.done"
+	TopicJobFailed         Topic = "job.failed"
+	TopicProviderUnhealthy Topic = "provider.unhealthy"
+	// TopicProviderRecovered is published when a provider that was unhealthy meets its objectives again.
+	TopicProviderRecovered Topic = "provider.recovered"
+	// TopicTranscriptionRefined is published when a draft was replaced by its high-quality pass,
+	// indexes of the text like embeddings should be rebuilt for it.
+	TopicTranscriptionRefined Topic = "transcription.refined"
+	// TopicTranscriptionChanged is published when a replaced source file was transcribed again and
+	// its transcript changed materially, exports and published copies of it are outdated.
+	TopicTranscriptionChanged Topic = "transcription.changed"
+	// TopicTranscriptionFlagged is published when moderation flagged a transcription and its policy notifies.
+	TopicTranscriptionFlagged Topic = "transcription.flagged"
+	// TopicJobStarted is published when a run over the Files of a batch job starts.
+	TopicJobStarted Topic = "job.started"
+	// TopicFileProgress is published when a file of a job enters a Stage, and while its audio is
+	// extracted as its Progress advances.
+	TopicFileProgress Topic = "file.progress"
+)
+
+// Stages of a file reported by TopicFileProgress.
+const (
+	StageExtracting   = "extracting"
+	StageTranscribing = "transcribing"
+)
+
+// Event is a pipeline notification, it is JSON serializable so it can cross process boundaries.
+type Event struct {
+	Topic    Topic     `json:"topic"`
+	Time     time.Time `json:"time"`
+	User     string    `json:"user,omitempty"`
+	FilePath string    `json:"file_path,omitempty"`
+	Provider string    `json:"provider,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// TranscriptionID is the stored transcription the event is about, zero when there is none.
+	TranscriptionID int `json:"transcription_id,omitempty"`
+	// JobID is the batch job the file belongs to, empty for a file converted on its own.
+	JobID string `json:"job_id,omitempty"`
+	// Files is the number of files of a started job.
+	Files int    `json:"files,omitempty"`
+	Stage string `json:"stage,omitempty"`
+	// Progress is the percent of the stage done, zero while it is unknown.
+	Progress float64 `json:"progress,omitempty"`
+}
+
+// Handler consumes events, handlers of one subscription are called sequentially.
+type Handler func(Event)
+
+// Bus distributes pipeline events to the modules interested in them,
+// so producers like the converter don't need references to their consumers.
+type Bus interface {
+	Publish(e Event)
+	// Subscribe registers h for topic and returns a function removing the subscription.
+	Subscribe(topic Topic, h Handler) (unsubscribe func())
+	Close() error
+}
+
+// New creates the bus selected by cfg.Backend, the in-process bus is the default.
+func New(cfg config.EventsConfig) (Bus, error) {
+	switch cfg.Backend {
+	case "", "inprocess":
+		return NewInProcessBus(), nil
+	case "nats":
+		return newNATSBus(cfg.NATS)
+	default:
+		return nil, fmt.Errorf("unknown event bus backend: %s", cfg.Backend)
+	}
+}
+
+const subscriptionBufferSize = 256
+
+type subscription struct {
+	topic   Topic
+	handler Handler
+	ch      chan Event
+	done    chan struct{}
+}
+
+// InProcessBus delivers events to subscribers of the same process through buffered channels.
+type InProcessBus struct {
+	mu     sync.RWMutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+// NewInProcessBus creates a new InProcessBus instance.
+func NewInProcessBus() *InProcessBus {
+	return &InProcessBus{subs: make(map[*subscription]struct{})}
+}
+
+// Publish hands e to every matching subscriber, it blocks only when a subscriber's buffer is full.
+func (b *InProcessBus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		log.Printf("Event bus closed, dropping event %s\n", e.Topic)
+		return
+	}
+
+	for s := range b.subs {
+		if s.topic == TopicAll || s.topic == e.Topic {
+			s.ch <- e
+		}
+	}
+}
+
+func (b *InProcessBus) Subscribe(topic Topic, h Handler) func() {
+	s := &subscription{
+		topic:   topic,
+		handler: h,
+		ch:      make(chan Event, subscriptionBufferSize),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		for e := range s.ch {
+			s.handler(e)
+		}
+	}()
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.remove(s) })
+	}
+}
+
+func (b *InProcessBus) remove(s *subscription) {
+	b.mu.Lock()
+	_, ok := b.subs[s]
+	delete(b.subs, s)
+	b.mu.Unlock()
+
+	if ok {
+		close(s.ch)
+		<-s.done
+	}
+}
+
+// Close stops accepting events and waits until every subscriber drained its buffer.
+func (b *InProcessBus) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	subs := make([]*subscription, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		b.remove(s)
+	}
+	return nil
+}
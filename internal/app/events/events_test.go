@@ -0,0 +1,66 @@
+package events
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestInProcessBus(t *testing.T) {
+	tests := []struct {
+		name      string
+		topic     Topic
+		published []Topic
+		want      []Topic
+	}{
+		{
+			name:      "single_topic",
+			topic:     TopicFileDone,
+			published: []Topic{TopicFileDone, TopicJobFailed, TopicFileDone},
+			want:      []Topic{TopicFileDone, TopicFileDone},
+		},
+		{
+			name:      "all_topics",
+			topic:     TopicAll,
+			published: []Topic{TopicFileDone, TopicJobFailed, TopicProviderUnhealthy},
+			want:      []Topic{TopicFileDone, TopicJobFailed, TopicProviderUnhealthy},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewInProcessBus()
+
+			var mu sync.Mutex
+			var got []Topic
+			bus.Subscribe(tt.topic, func(e Event) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, e.Topic)
+			})
+
+			for _, topic := range tt.published {
+				bus.Publish(Event{Topic: topic})
+			}
+			bus.Close()
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("received %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInProcessBusUnsubscribe(t *testing.T) {
+	bus := NewInProcessBus()
+	defer bus.Close()
+
+	count := 0
+	unsubscribe := bus.Subscribe(TopicAll, func(e Event) { count++ })
+	bus.Publish(Event{Topic: TopicFileDone})
+	unsubscribe()
+	bus.Publish(Event{Topic: TopicFileDone})
+
+	if count != 1 {
+		t.Errorf("received %d events, want 1", count)
+	}
+}
@@ -0,0 +1,108 @@
+//go:build nats
+
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"tiktok-whisper/internal/app/config"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+const subjectPrefix = "v2t."
+
+// NATSBus distributes events through NATS, either an embedded server or an external one,
+// which lets several v2t processes share the same event stream.
+type NATSBus struct {
+	server *natsserver.Server
+	conn   *nats.Conn
+}
+
+func newNATSBus(cfg config.NATSConfig) (Bus, error) {
+	url := cfg.URL
+
+	var srv *natsserver.Server
+	if cfg.Embedded || url == "" {
+		port := cfg.Port
+		if port == 0 {
+			port = natsserver.RANDOM_PORT
+		}
+
+		var err error
+		srv, err = natsserver.NewServer(&natsserver.Options{Host: "127.0.0.1", Port: port, NoSigs: true})
+		if err != nil {
+			return nil, fmt.Errorf("create embedded nats server failed: %v", err)
+		}
+
+		go srv.Start()
+		if !srv.ReadyForConnections(5 * time.Second) {
+			srv.Shutdown()
+			return nil, fmt.Errorf("embedded nats server not ready")
+		}
+		url = srv.ClientURL()
+	}
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		if srv != nil {
+			srv.Shutdown()
+		}
+		return nil, fmt.Errorf("connect nats %s failed: %v", url, err)
+	}
+
+	return &NATSBus{server: srv, conn: conn}, nil
+}
+
+func (b *NATSBus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Marshal event %s failed: %v\n", e.Topic, err)
+		return
+	}
+
+	if err = b.conn.Publish(subject(e.Topic), data); err != nil {
+		log.Printf("Publish event %s failed: %v\n", e.Topic, err)
+	}
+}
+
+func (b *NATSBus) Subscribe(topic Topic, h Handler) func() {
+	sub, err := b.conn.Subscribe(subject(topic), func(m *nats.Msg) {
+		var e Event
+		if err := json.Unmarshal(m.Data, &e); err != nil {
+			log.Printf("Unmarshal event from %s failed: %v\n", m.Subject, err)
+			return
+		}
+		h(e)
+	})
+	if err != nil {
+		log.Printf("Subscribe to %s failed: %v\n", topic, err)
+		return func() {}
+	}
+
+	return func() {
+		sub.Unsubscribe()
+	}
+}
+
+func (b *NATSBus) Close() error {
+	err := b.conn.Drain()
+	if b.server != nil {
+		b.server.Shutdown()
+	}
+	return err
+}
+
+func subject(topic Topic) string {
+	if topic == TopicAll {
+		return subjectPrefix + ">"
+	}
+	return subjectPrefix + string(topic)
+}
@@ -0,0 +1,12 @@
+//go:build !nats
+
+package events
+
+import (
+	"errors"
+	"tiktok-whisper/internal/app/config"
+)
+
+func newNATSBus(cfg config.NATSConfig) (Bus, error) {
+	return nil, errors.New("the nats event bus is not compiled in, rebuild with -tags nats")
+}
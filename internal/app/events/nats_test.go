@@ -0,0 +1,33 @@
+//go:build nats
+
+package events
+
+import (
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"time"
+)
+
+func TestNATSBus(t *testing.T) {
+	bus, err := New(config.EventsConfig{Backend: "nats", NATS: config.NATSConfig{Embedded: true}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer bus.Close()
+
+	received := make(chan Event, 1)
+	bus.Subscribe(TopicJobFailed, func(e Event) { received <- e })
+
+	// nats subscriptions are registered asynchronously on the server
+	time.Sleep(100 * time.Millisecond)
+	bus.Publish(Event{Topic: TopicJobFailed, FilePath: "a.mp3", Error: "boom"})
+
+	select {
+	case e := <-received:
+		if e.FilePath != "a.mp3" || e.Error != "boom" {
+			t.Errorf("received %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
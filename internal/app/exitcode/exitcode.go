@@ -0,0 +1,77 @@
+// Package exitcode maps the errors of v2t to stable exit statuses, so cron jobs and CI can tell
+// what went wrong without parsing the messages.
+package exitcode
+
+import (
+	"errors"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/cost"
+)
+
+// The exit statuses, they don't change between releases.
+const (
+	OK = 0
+	// Failure is any error not covered by the statuses below.
+	Failure = 1
+	// Usage is an unknown command, an invalid flag or argument.
+	Usage = 2
+	// Unavailable is a failure of the providers such as a network error, a rate limit or open
+	// circuits, running again later may succeed.
+	Unavailable = 3
+	// Transcription is a failure of the audio itself, it fails with every provider.
+	Transcription = 4
+	// Budget is the monthly budget of cost.monthly_budget being spent.
+	Budget = 5
+	// Partial is a batch in which some files failed and the others were converted.
+	Partial = 6
+	// Capability is an optional subsystem the command needs being unavailable, e.g. ffmpeg or the
+	// key of the language model, see v2t config capabilities.
+	Capability = 7
+)
+
+// codedError is an error with the exit status it asks for.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// WithCode returns err asking for the exit status code, it is nil when err is nil.
+func WithCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// Code returns the exit status of err: the one asked for by WithCode, otherwise the one of its kind.
+func Code(err error) int {
+	if err == nil {
+		return OK
+	}
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	var te *provider.TranscriptionError
+	switch {
+	case errors.Is(err, capability.ErrUnavailable):
+		return Capability
+	case errors.Is(err, cost.ErrBudgetExceeded):
+		return Budget
+	case errors.Is(err, provider.ErrNoProviderAvailable), provider.IsRetryable(err):
+		return Unavailable
+	case errors.As(err, &te):
+		return Transcription
+	default:
+		return Failure
+	}
+}
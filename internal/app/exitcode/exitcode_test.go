@@ -0,0 +1,38 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/cost"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", want: OK},
+		{name: "other error", err: errors.New("disk full"), want: Failure},
+		{name: "budget", err: fmt.Errorf("3 files not converted: %w", cost.ErrBudgetExceeded), want: Budget},
+		{name: "open circuits", err: provider.ErrNoProviderAvailable, want: Unavailable},
+		{name: "rate limit", err: provider.NewTranscriptionError("openai", true, errors.New("429")), want: Unavailable},
+		{name: "bad audio", err: provider.NewTranscriptionError("openai", false, errors.New("invalid file")), want: Transcription},
+		{name: "missing capability", err: fmt.Errorf("talk.ogg: %w", &capability.Error{Name: capability.FFmpeg, Err: errors.New("not found")}), want: Capability},
+		{name: "asked for", err: WithCode(Usage, errors.New("unknown flag")), want: Usage},
+		{name: "asked for wraps a kind", err: WithCode(Partial, cost.ErrBudgetExceeded), want: Partial},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Code(tt.err); got != tt.want {
+				t.Errorf("Code(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+	if WithCode(Usage, nil) != nil {
+		t.Errorf("WithCode(nil) != nil")
+	}
+}
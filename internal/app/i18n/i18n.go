@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/locale"
+)
+
+// English is the source language, messages are written in English in the code
+// and used as the keys of the other catalogs.
+const English = "en"
+
+// catalogs maps a language subtag to its translations keyed by the English message.
+var catalogs = map[string]map[string]string{
+	"zh": zh,
+}
+
+var (
+	mu       sync.RWMutex
+	language = English
+)
+
+// Detect returns the language to use: the configured one, otherwise the environment's.
+func Detect(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	return locale.EnvTag()
+}
+
+// SetLanguage selects the catalog used by T, tags like zh_CN.UTF-8 select their language,
+// languages without a catalog fall back to English.
+func SetLanguage(tag string) {
+	lang := strings.SplitN(locale.Normalize(tag), "-", 2)[0]
+	if _, ok := catalogs[lang]; !ok {
+		lang = English
+	}
+
+	mu.Lock()
+	language = lang
+	mu.Unlock()
+}
+
+// Language returns the language subtag selected by SetLanguage.
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return language
+}
+
+// Languages lists the languages with a catalog, English included.
+func Languages() []string {
+	langs := []string{English}
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// T translates the English message into the selected language and formats it
+// like fmt.Sprintf when args are given. Untranslated messages are returned in English.
+func T(message string, args ...any) string {
+	return Translate(Language(), message, args...)
+}
+
+// Translate is T for an explicit language, used for artifacts written in the reader's language.
+func Translate(lang string, message string, args ...any) string {
+	translated, ok := catalogs[lang][message]
+	if !ok {
+		translated = message
+	}
+
+	if len(args) == 0 {
+		return translated
+	}
+	return fmt.Sprintf(translated, args...)
+}
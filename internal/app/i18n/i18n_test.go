@@ -0,0 +1,66 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	defer SetLanguage(English)
+
+	tests := []struct {
+		name    string
+		lang    string
+		message string
+		args    []any
+		want    string
+	}{
+		{name: "english", lang: "en", message: "Flags:", want: "Flags:"},
+		{name: "posix tag", lang: "zh_CN.UTF-8", message: "Flags:", want: "参数："},
+		{name: "formatted", lang: "zh", message: "transcription %d has no revision %d", args: []any{3, 2}, want: "转录 3 没有版本 2"},
+		{name: "reordered arguments", lang: "zh", message: "%d small groups suppressed by role %q\n", args: []any{2, "viewer"}, want: "角色 \"viewer\" 隐藏了 2 个较小的分组\n"},
+		{name: "untranslated", lang: "zh", message: "no such message %d", args: []any{1}, want: "no such message 1"},
+		{name: "unknown language", lang: "xx", message: "Flags:", want: "Flags:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetLanguage(tt.lang)
+			if got := T(tt.message, tt.args...); got != tt.want {
+				t.Errorf("T() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+var verbRegexp = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+var indexRegexp = regexp.MustCompile(`\[\d+\]`)
+
+// TestCatalogs_Verbs makes sure translations keep the formatting verbs of their message.
+func TestCatalogs_Verbs(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for message, translated := range catalog {
+			want := verbs(message)
+			got := verbs(translated)
+			if len(got) != len(want) {
+				t.Errorf("%s translation of %q has verbs %v, want %v", lang, message, got, want)
+				continue
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("%s translation of %q has verbs %v, want %v", lang, message, got, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func verbs(s string) []string {
+	found := verbRegexp.FindAllString(s, -1)
+	for i, v := range found {
+		found[i] = indexRegexp.ReplaceAllString(v, "")
+	}
+	sort.Strings(found)
+	return found
+}
@@ -0,0 +1,506 @@
+package i18n
+
+// zh holds the Simplified Chinese translations, keyed by the English message.
+var zh = map[string]string{
+	"An application for batch converting video to text, supports tiktok and other video sites": "批量将视频转换为文字的工具，支持 TikTok 及其他视频网站",
+	"An application for batch converting video to text, supports tiktok and other video sites or local video.\n- First download all videos to local machine\n- Call v2t to batch process the videos with local folder path\n- The processed records will be saved to sqlite.": "批量将视频转换为文字的工具，支持 TikTok 及其他视频网站或本地视频。\n- 先把所有视频下载到本地\n- 用本地文件夹路径调用 v2t 批量处理\n- 处理记录会保存到 sqlite",
+	"config file (default is $XDG_CONFIG_HOME/v2t/config.yaml)": "配置文件（默认为 $XDG_CONFIG_HOME/v2t/config.yaml）",
+	"verbose output":         "输出详细信息",
+	"Help about any command": "查看任意命令的帮助",
+	"Generate the autocompletion script for the specified shell": "为指定的 shell 生成自动补全脚本",
+	"Ask questions about a single transcription":                 "针对单条转录内容提问",
+	"Ask questions about a single transcription\n\n- Answers are grounded in the most relevant excerpts of that one transcription and cite them like [2]\n- With a question argument it answers once, otherwise it starts a conversation, type exit to quit\n- Uses the language model of llm in config.yaml, OpenAI unless set, e.g. a local Ollama model": "针对单条转录内容提问\n\n- 回答只依据该转录中最相关的片段，并以 [2] 的形式标注引用\n- 带问题参数时只回答一次，否则进入对话，输入 exit 退出\n- 使用 config.yaml 中 llm 配置的语言模型，未设置时为 OpenAI，也可以是本地的 Ollama 模型",
+	"The id of the transcription to chat about":                           "要对话的转录 id",
+	"get transcription %d failed: %v":                                     "获取转录 %d 失败：%v",
+	"Chatting about %s (%s), type exit to quit\n":                         "正在讨论 %s（%s），输入 exit 退出\n",
+	"  [%d] chars %d-%d\n":                                                "  [%d] 第 %d-%d 个字符\n",
+	"Start converting the video files in the specified directory to text": "开始将指定目录中的视频文件转换为文字",
+	"Start converting the video files in the specified directory to text\n\n- Iterate through the mp4 files in the specified directory\n- Convert to mp3 or wav and convert to text\n- Support openai whisper or native whisper.cpp as conversion engine": "开始将指定目录中的视频文件转换为文字\n\n- 遍历指定目录中的 mp4 文件\n- 转换为 mp3 或 wav 后再转为文字\n- 支持 openai whisper 或本地 whisper.cpp 作为转换引擎",
+	"Convert audio to text":                                                                                                           "将音频转换为文字",
+	"Convert video to text":                                                                                                           "将视频转换为文字",
+	"How many files to convert from the directory this time":                                                                          "本次从目录中转换多少个文件",
+	"Specifies the mp4 file directory, example: ./test/data/mp4":                                                                      "指定 mp4 文件目录，例如：./test/data/mp4",
+	"Specifies the audio file to convert, example: . /test/data/test.mp3":                                                             "指定要转换的音频文件，例如：./test/data/test.mp3",
+	"Specifies the transcriptions directory, example: ./test/data/transcription":                                                      "指定转录文本的输出目录，例如：./test/data/transcription",
+	"How many files to convert at the same time":                                                                                      "同时转换多少个文件",
+	"Also convert videos that were already transcribed, the new result is stored as a revision, see v2t revisions":                    "同时转换已经转录过的视频，新结果保存为一个修订版本，参见 v2t revisions",
+	"Print the transcript segments while a file is being transcribed":                                                                 "转录过程中实时输出已识别的片段",
+	"Label the transcript with its speakers using the diarization service in config.yaml":                                             "使用 config.yaml 中配置的说话人分离服务为转录文本标注说话人",
+	"Resume the batch job with this id, converting the files it didn't finish":                                                        "恢复指定 id 的批量任务，继续转换其中未完成的文件",
+	"When converting the specified directory, you can use this option to filter the files with the specified extension, example: mp3": "转换指定目录时，可用此选项按扩展名过滤文件，例如：mp3",
+	"Which user owns the videos, this parameter affects the 'user' field when they are saved to the database":                         "视频所属的用户，会写入数据库中的 'user' 字段",
+	"Please specify the conversion type, -v or -a":                                                                                    "请指定转换类型，-v 或 -a",
+	"Please specify the directory or file to convert":                                                                                 "请指定要转换的目录或文件",
+	"UserNickName must be set when converting video in directory":                                                                     "转换目录中的视频时必须设置 UserNickName",
+	"Invalid diarization in config.yaml: %v":                                                                                          "config.yaml 中的 diarization 配置无效：%v",
+	"Resume error: %v":                                                                                                                "恢复批量任务出错：%v",
+	"ConvertAudioDir error: %v":                                                                                                       "转换音频目录出错：%v",
+	"ConvertVideos error: %v":                                                                                                         "转换视频出错：%v",
+	"ConvertAudios error: %v":                                                                                                         "转换音频出错：%v",
+	"Download podcasts from Small Universe or tiktok(unsupported now)":                                                                "从小宇宙下载播客，或从 TikTok 下载（暂不支持）",
+	"Download podcasts from Small Universe or tiktok(unsupported now), support downloading all shows from the home page and single downloads": "从小宇宙下载播客，或从 TikTok 下载（暂不支持），支持下载主页上的全部节目或单集",
+	"Download podcasts from Small Universe": "从小宇宙下载播客",
+	"Download podcasts from Small Universe, support downloading all shows from the home page and single downloads": "从小宇宙下载播客，支持下载主页上的全部节目或单集",
+	"set directory to save downloaded files ": "设置下载文件的保存目录",
+	"set episode, If it is more than one episode can be separated by a comma, e.g. https://www.xiaoyuzhoufm.com/episode/64411602a79cc81470055c96": "设置单集地址，多个单集用逗号分隔，例如：https://www.xiaoyuzhoufm.com/episode/64411602a79cc81470055c96",
+	"set podcast url, e.g. https://www.xiaoyuzhoufm.com/podcast/61a9f093ca6141933d1a1c63":                                                         "设置播客地址，例如：https://www.xiaoyuzhoufm.com/podcast/61a9f093ca6141933d1a1c63",
+	"please input a podcast or an episode":                   "请输入播客或单集地址",
+	"Export the specified user's text to excel or subtitles": "将指定用户的文字导出到 Excel 或字幕",
+	"Export the specified user's text to excel or subtitles\n\n- Export all the user's text to excel, currently does not support a limited number\n- Other formats write one file per transcription to the outputFilePath directory, srt, vtt and json use the stored timestamps": "将指定用户的文字导出到 Excel 或字幕\n\n- 导出该用户的全部文字到 Excel，暂不支持限制数量\n- 其他格式在 outputFilePath 目录中为每条转录写一个文件，srt、vtt 和 json 使用已存储的时间戳",
+	"export finished, %d files written to %s\n":               "导出完成，已写入 %d 个文件到 %s\n",
+	"%d transcriptions have no timestamps and were skipped\n": "%d 条转录没有时间戳，已跳过\n",
+	"set outputFilePath":                                 "设置输出文件路径",
+	"set userNickname":                                   "设置用户昵称",
+	"export finished, exported file path: %v\n":          "导出完成，文件路径：%v\n",
+	"Regenerate output files from stored transcriptions": "根据已保存的转录重新生成输出文件",
+	"Regenerate output files from stored transcriptions\n\n- Nothing is transcribed again, the stored text is rendered with the current writers\n- Artifacts that are still up to date are skipped, every rewrite is recorded as a new artifact version": "根据已保存的转录重新生成输出文件\n\n- 不会重新转录，使用当前的输出格式渲染已保存的文字\n- 跳过仍是最新的文件，每次重写都记录为一个新的文件版本",
+	"Rewrite artifacts even when they are up to date":                                                   "即使文件已是最新也重新生成",
+	"Locale for dates and numbers, e.g. zh-CN (default is the user's locale in config.yaml, then LANG)": "日期和数字的区域设置，例如 zh-CN（默认使用 config.yaml 中该用户的设置，其次是 LANG）",
+	"Output directory (default is data/export/<user>)":                                                  "输出目录（默认为 data/export/<user>）",
+	"Only re-export transcriptions converted on or after this date, e.g. 2024-01-01":                    "只重新导出在该日期及之后转换的转录，例如 2024-01-01",
+	"The user whose transcriptions are re-exported":                                                     "要重新导出其转录的用户",
+	"invalid --since %q, expected YYYY-MM-DD: %v":                                                       "--since %q 无效，应为 YYYY-MM-DD：%v",
+	"the configured database does not track artifacts":                                                  "当前配置的数据库不记录输出文件",
+	"re-export finished, %d written, %d up to date, output dir: %s\n":                                   "重新导出完成，写入 %d 个，%d 个已是最新，输出目录：%s\n",
+	"List, compare and pick the revisions of a re-transcribed file":                                     "列出、比较并选择重新转录文件的修订版本",
+	"List, compare and pick the revisions of a re-transcribed file\n\n- Converting a file again (e.g. with convert --retranscribe) stores the result as a new revision\n- The newest revision becomes current, use \"revisions use\" to go back to an older one": "列出、比较并选择重新转录文件的修订版本\n\n- 再次转换同一文件（例如 convert --retranscribe）会把结果保存为新的修订版本\n- 最新的修订版本成为当前版本，可用 \"revisions use\" 切换回旧版本",
+	"The user whose database holds the transcription (default database when empty)": "转录所在数据库对应的用户（为空时使用默认数据库）",
+	"Serve an HTTP API to submit audio files and fetch their transcriptions":        "提供 HTTP API，用于提交音频文件并获取转录结果",
+	"Serve an HTTP API to submit audio files and fetch their transcriptions\n\n- POST /api/v1/jobs with a multipart form of \"file\" and \"user\" queues a job\n- GET /api/v1/jobs/{id} reports its status, GET /api/v1/jobs/{id}/result returns the transcription\n- GET /api/v1/users/{user}/transcriptions lists the history of a user, /transcriptions/{id} returns one\n- GET /api/quick-search?user=...&q=... lists the matching transcriptions for launchers like Alfred and Raycast\n- With --grpc-addr, the same is served over gRPC as defined in api/proto/v2t/v1/v2t.proto\n- With --agents, the jobs are pulled by v2t-agent on GPU machines, GET /api/v1/agents lists them\n- GET /status shows whether the providers meet their slos of config.yaml, breaches are published as provider.unhealthy events\n- GET /review/{user} is the language-learning review: segments next to their translations, marked for spaced repetition and exported to Anki\n- GET /analytics/{user} is a dashboard of the speech analytics of a user's episodes, /api/v1/users/{user}/speech-analytics returns them as JSON\n- GET /search finds the transcriptions of a user by words and by meaning, narrowed by date and duration, /api/v1/search returns them as JSON\n- GET /dashboard follows the batch jobs on the event bus live: the progress of their files, providers, errors and throughput\n- Results are stored in the user's database, like convert does": "提供 HTTP API，用于提交音频文件并获取转录结果\n\n- POST /api/v1/jobs 提交包含 \"file\" 和 \"user\" 的 multipart 表单，创建一个任务\n- GET /api/v1/jobs/{id} 查询任务状态，GET /api/v1/jobs/{id}/result 返回转录结果\n- GET /api/v1/users/{user}/transcriptions 列出用户的转录历史，/transcriptions/{id} 返回单条转录\n- GET /api/quick-search?user=...&q=... 为 Alfred、Raycast 等启动器列出匹配的转录\n- 使用 --grpc-addr 时，还会按 api/proto/v2t/v1/v2t.proto 的定义通过 gRPC 提供相同的功能\n- 使用 --agents 时，任务由 GPU 机器上的 v2t-agent 拉取执行，GET /api/v1/agents 列出这些 agent\n- GET /status 显示各提供方是否达到 config.yaml 中 slos 的目标，违反时发布 provider.unhealthy 事件\n- GET /review/{user} 是语言学习复习页：分段与译文并排显示，可标记进行间隔重复并导出到 Anki\n- GET /analytics/{user} 是用户各期节目语音分析的仪表盘，/api/v1/users/{user}/speech-analytics 以 JSON 返回\n- GET /search 按词语和语义查找用户的转录，可按日期和时长筛选，/api/v1/search 以 JSON 返回\n- GET /dashboard 实时跟踪事件总线上的批量任务：各文件的进度、提供方、错误和吞吐量\n- 结果与 convert 一样保存到该用户的数据库",
+	"Address to listen on": "监听地址",
+	"Directory keeping the submitted files (default is data/uploads)": "保存已提交文件的目录（默认为 data/uploads）",
+	"How many jobs to transcribe at the same time":                    "同时转录的任务数",
+	"Largest accepted upload in bytes":                                "允许上传的最大字节数",
+	"The id of the transcription":                                     "转录 id",
+	"Show what changed between two revisions, sentence by sentence":   "逐句显示两个修订版本之间的差异",
+	"Old revision (default is the revision before --to)":              "旧修订版本（默认为 --to 之前的版本）",
+	"New revision (default is the current revision)":                  "新修订版本（默认为当前版本）",
+	"List the revisions of a transcription":                           "列出转录的修订版本",
+	"Make a revision the current text of the transcription":           "将某个修订版本设为转录的当前文字",
+	"The revision to make current":                                    "要设为当前的修订版本",
+	"REVISION\tCURRENT\tCREATED\tPROVIDER\tMODEL\tLENGTH":             "版本\t当前\t创建时间\t服务\t模型\t长度",
+	"--- revision %d\n+++ revision %d\n":                              "--- 版本 %d\n+++ 版本 %d\n",
+	"revision %d is now current for transcription %d\n":               "版本 %d 已成为转录 %d 的当前版本\n",
+	"the configured database does not keep revisions":                 "当前配置的数据库不保存修订版本",
+	"transcription %d has a single result, there are no revisions":    "转录 %d 只有一个结果，没有修订版本",
+	"no earlier revision to compare with, use --from":                 "没有更早的版本可比较，请使用 --from",
+	"transcription %d has no revision %d":                             "转录 %d 没有版本 %d",
+	"Download and verify the audio samples used by integration tests": "下载并校验集成测试使用的音频样本",
+	"Download and verify the audio samples used by integration tests\n\n- Samples are public-domain recordings in several languages and recording conditions\n- They are stored in test/data with their checksums, sources and licenses in corpus.lock.json\n- Tests needing a sample that wasn't fetched are skipped": "下载并校验集成测试使用的音频样本\n\n- 样本是多种语言、多种录音条件下的公有领域录音\n- 样本保存在 test/data 中，校验和、来源与许可证记录在 corpus.lock.json\n- 需要未下载样本的测试会被跳过",
+	"Only samples in this language, e.g. en":                                        "只包含该语言的样本，例如 en",
+	"Only samples recorded in these conditions, e.g. clean or broadcast":            "只包含该录音条件的样本，例如 clean 或 broadcast",
+	"List the samples and whether they were fetched":                                "列出样本及其是否已下载",
+	"Download the samples, all matching --language and --noise when none are named": "下载样本，未指定名称时下载所有符合 --language 和 --noise 的样本",
+	"NAME\tLANGUAGE\tNOISE\tLICENSE\tSTATUS":                                        "名称\t语言\t噪声\t许可证\t状态",
+	"not fetched":                                                                   "未下载",
+	"ok":                                                                            "正常",
+	"Error fetching %s: %v\n":                                                       "下载 %s 出错：%v\n",
+	"%s: %s (%s)\n":                                                                 "%s：%s（%s）\n",
+	"Check transcription providers":                                                 "检查转录服务",
+	"Run the conformance checks against a provider":                                 "对转录服务运行一致性检查",
+	"Run the conformance checks against a provider\n\n- info: the metadata names the provider and its model\n- health: the provider's own health check passes\n- small_file, unicode_filename: short speech is transcribed, also under a unicode file name\n- large_file, error_mapping: oversized, corrupt and missing files fail with errors that aren't retryable\n- timeout: the sample is transcribed within --timeout": "对转录服务运行一致性检查\n\n- info：元数据包含服务名称及其模型\n- health：服务自身的健康检查通过\n- small_file、unicode_filename：能转录短语音，文件名含 unicode 字符时也可以\n- large_file、error_mapping：过大、损坏和不存在的文件以不可重试的错误失败\n- timeout：样本在 --timeout 内转录完成",
+	"Short speech recording to transcribe (default is the jfk sample of v2t corpus)":               "用于转录的短语音录音（默认为 v2t corpus 的 jfk 样本）",
+	"Largest file the provider accepts in MB, the large file check sends a bigger one, 0 skips it": "服务接受的最大文件大小（MB），大文件检查会发送更大的文件，为 0 时跳过",
+	"How long a single transcription may take":                                                     "单次转录允许的最长时间",
+	"CHECK\tSTATUS\tTIME\tDETAIL":                                                                  "检查项\t结果\t耗时\t详情",
+	"provider %s failed conformance checks":                                                        "转录服务 %s 未通过一致性检查",
+	"provider %s passed\n":                                                                         "转录服务 %s 通过了所有检查\n",
+	"no sample given, run v2t corpus fetch jfk or pass --sample":                                   "未指定样本，请运行 v2t corpus fetch jfk 或使用 --sample",
+	"Show the estimated costs of the transcriptions":                                               "显示转录的预估费用",
+	"Show the estimated costs of the transcriptions\n\n- Every transcription of v2t convert is priced and recorded in the database\n- The price comes from the cost middleware or from the per-minute rates under cost.rates in config.yaml\n- With cost.monthly_budget set, conversions stop once the costs of the month reach it": "显示转录的预估费用\n\n- v2t convert 的每次转录都会计价并记录到数据库\n- 价格来自 cost 中间件，或 config.yaml 中 cost.rates 下的每分钟费率\n- 设置 cost.monthly_budget 后，当月费用达到预算时停止转换",
+	"Report the costs per user and provider":                                                   "按用户和服务报告费用",
+	"Report the costs recorded since this day, YYYY-MM-DD (default is the start of the month)": "报告自该日起记录的费用，格式 YYYY-MM-DD（默认为本月初）",
+	"Break the costs down by user, provider or user,provider":                                  "按 user、provider 或 user,provider 细分费用",
+	"The user whose database holds the costs (default database when empty)":                    "保存费用的数据库所属用户（为空时使用默认数据库）",
+	"invalid --since %q, expected YYYY-MM-DD":                                                  "无效的 --since %q，应为 YYYY-MM-DD",
+	"the configured database does not keep costs":                                              "当前配置的数据库不保存费用",
+	"Costs since %s\n\n":                     "自 %s 起的费用\n\n",
+	"USER\tPROVIDER\tFILES\tMINUTES\tCOST":   "用户\t服务\t文件数\t分钟\t费用",
+	"TOTAL":                                  "合计",
+	"\nMonthly budget: %.2f spent of %.2f\n": "\n月度预算：已花费 %.2f，共 %.2f\n",
+	"The budget is exhausted, conversions are stopped until next month\n": "预算已用完，转换将暂停至下月\n",
+	"How long to run": "运行时长",
+	"The provider transcribing the jobs, see v2t providers verify":          "转录任务使用的服务，参见 v2t providers verify",
+	"Recording each job converts (default is the jfk sample of v2t corpus)": "每个任务转换的录音（默认为 v2t corpus 的 jfk 样本）",
+	"Time between two samples of memory, goroutines and open files":         "两次采样内存、goroutine 和打开文件之间的间隔",
+	"Time before the baseline is sampled":                                   "采样基线之前的预热时间",
+	"Fail when the heap grew more than this, 0 doesn't check it":            "堆增长超过该值（MB）时失败，0 表示不检查",
+	"Fail when more goroutines than this were added, 0 doesn't check it":    "新增 goroutine 超过该数量时失败，0 表示不检查",
+	"Fail when more files than this were left open, 0 doesn't check it":     "未关闭的文件超过该数量时失败，0 表示不检查",
+	"Write the samples to this CSV file":                                    "将采样写入该 CSV 文件",
+	"Convert a sample over and over for hours to find leaks":                "长时间反复转换样本以发现资源泄漏",
+	"Convert a sample over and over for hours to find leaks\n\n- Each job converts a fresh copy of the sample through the converter, middlewares and a scratch database\n- Memory, goroutines and open files are sampled every --interval, the first sample after --warmup is the baseline\n- The run fails when one of them grew beyond its limit by the end, or when every job failed": "长时间反复转换样本以发现资源泄漏\n\n- 每个任务都通过转换器、中间件和临时数据库转换样本的新副本\n- 每隔 --interval 采样内存、goroutine 和打开的文件，--warmup 之后的第一次采样为基线\n- 结束时任一项增长超过限制，或所有任务都失败时，运行失败",
+	"%d jobs, %d failed\n": "%d 个任务，%d 个失败\n",
+	"heap %s -> %s, goroutines %d -> %d, open files %d -> %d\n": "堆 %s -> %s，goroutine %d -> %d，打开的文件 %d -> %d\n",
+	"Measure the performance of v2t components":                 "测量 v2t 各组件的性能",
+	"Compare the database backends under load":                  "比较数据库后端在负载下的表现",
+	"Compare the database backends under load\n\n- insert: rows inserted one by one, like convert records its results\n- get_all_by_user: latency of reading the history of the largest user as the table grows\n- concurrent_read, concurrent_insert: readers and writers running at the same time\n- SQLite is measured in a temp file, PostgreSQL only with --postgres": "比较数据库后端在负载下的表现\n\n- insert：逐行插入，与 convert 记录结果的方式相同\n- get_all_by_user：随着表增大，读取最大用户历史记录的延迟\n- concurrent_read、concurrent_insert：读写同时进行\n- SQLite 在临时文件中测量，PostgreSQL 仅在指定 --postgres 时测量",
+	"Table sizes to measure GetAllByUser at":                                                  "测量 GetAllByUser 时的表行数",
+	"GetAllByUser calls per table size, and reads and writes per concurrent worker":           "每个表大小的 GetAllByUser 调用次数，以及每个并发工作者的读写次数",
+	"Readers and writers running at the same time, 0 skips the concurrent measurement":        "同时运行的读者和写者数量，0 表示跳过并发测量",
+	"Also measure this PostgreSQL database, use a scratch database as the inserted rows stay": "同时测量该 PostgreSQL 数据库，插入的行会保留，请使用临时数据库",
+	"Seed of the generated rows":                                                              "生成数据行的随机种子",
+	"Benchmarking %s...\n":                                                                    "正在测量 %s...\n",
+	"BACKEND\tMEASUREMENT\tROWS\tOPS\tOPS/S\tP50\tP95":                                        "后端\t测量项\t行数\t操作数\t每秒操作\tP50\tP95",
+	"Measure the providers on a reference recording and recommend one":                        "在参考录音上测评各提供方并推荐一个",
+	"Measure the providers on a reference recording and recommend one\n\n- Every provider (all of them when none is named) transcribes the same recording\n- Latency, word error rate against the known transcript and cost (see cost.rates in config.yaml) are measured\n- The results are kept in data/provider_benchmarks.json, the provider \"auto\" uses the recommended one:\n  the most accurate, the cheapest and then the fastest among equally accurate ones": "在参考录音上测评各提供方并推荐一个\n\n- 每个提供方（未指定时为全部）转录同一段录音\n- 测量延迟、相对已知文本的词错误率以及费用（见 config.yaml 中的 cost.rates）\n- 结果保存在 data/provider_benchmarks.json，提供方 \"auto\" 使用推荐的那个：\n  最准确的，准确率相当时选最便宜、再选最快的",
+	"Corpus sample to transcribe, it must have a known transcript":                           "要转录的语料样本，必须有已知文本",
+	"Transcribe this recording instead of a corpus sample, --reference gives its transcript": "转录该录音而不是语料样本，--reference 提供其文本",
+	"Text file with the correct transcript of --file":                                        "包含 --file 正确文本的文本文件",
+	"PROVIDER\tMODEL\tLATENCY\tWER\tCOST\tERROR":                                             "提供方\t模型\t延迟\t词错误率\t费用\t错误",
+	"Recommended provider: %s\n":                                                             "推荐的提供方：%s\n",
+	"--file needs --reference with its transcript":                                           "--file 需要用 --reference 提供其文本",
+	"sample %s has no known transcript, pass --file and --reference":                         "样本 %s 没有已知文本，请使用 --file 和 --reference",
+	"run v2t corpus fetch %s first":                                                          "请先运行 v2t corpus fetch %s",
+	"Store the transcriptions for this user instead of the user in the files":                "将转录保存到该用户名下，而不是文件中的用户",
+	"Load transcriptions exported with the json format back into the database":               "将以 json 格式导出的转录重新载入数据库",
+	"Load transcriptions exported with the json format back into the database\n\n- Files of every schema version are accepted, invalid ones are reported and skipped\n- Transcriptions whose file is already stored are skipped\n- Each transcription goes to the database of its user in config.yaml": "将以 json 格式导出的转录重新载入数据库\n\n- 接受所有 schema 版本的文件，无效的文件会被报告并跳过\n- 文件已保存过的转录会被跳过\n- 每条转录保存到 config.yaml 中其用户对应的数据库",
+	"Skipping %s: %v\n":                            "跳过 %s：%v\n",
+	"%d imported, %d already stored, %d invalid\n": "已导入 %d 条，%d 条已存在，%d 条无效\n",
+	"Download the audio of these video pages with yt-dlp and convert it, separated by commas, example: https://www.youtube.com/watch?v=...": "用 yt-dlp 下载这些视频页面的音频并转换，以逗号分隔，例如：https://www.youtube.com/watch?v=...",
+	"ConvertURLs error: %v": "ConvertURLs 错误：%v",
+	"Transcribe long audio in chunks of this length, example: 5m. Overrides chunking.seconds of config.yaml": "将长音频按此长度分块转录，例如：5m。覆盖 config.yaml 中的 chunking.seconds",
+	"Expose the transcript archive to LLM agents over the Model Context Protocol":                            "通过 Model Context Protocol 向 LLM 智能体开放转录档案",
+	"Expose the transcript archive to LLM agents over the Model Context Protocol\n\n- search finds the transcriptions of a user containing all words of a query\n- fetch-transcript returns a transcription with its segments and provider metadata\n- stats counts the transcribed files and their audio duration per user\n- Each user is read from the database it is routed to in config.yaml": "通过 Model Context Protocol 向 LLM 智能体开放转录档案\n\n- search 查找用户包含查询中所有词的转录\n- fetch-transcript 返回一条转录及其分段和服务商元数据\n- stats 统计每个用户已转录的文件数和音频时长\n- 每个用户从 config.yaml 中为其路由的数据库读取",
+	"Serve MCP on stdin and stdout, for clients like Claude Desktop or IDE assistants": "在标准输入和标准输出上提供 MCP 服务，供 Claude Desktop 或 IDE 助手等客户端使用",
+	"Search the stored transcriptions for keywords":                                    "按关键词搜索已保存的转录",
+	"Search the stored transcriptions for keywords\n\n- Lists the transcriptions whose file name and text contain all words of the query\n- Transcriptions whose words the provider was unsure of, or that failed the result checks, are listed last\n- With --output alfred, prints the JSON of an Alfred script filter, whose items open the transcription in v2t serve\n- v2t serve answers the same search at /api/quick-search?user=...&q=...": "按关键词搜索已保存的转录\n\n- 列出文件名和文本包含查询中所有词的转录\n- 服务商对其中词语把握不大或未通过结果检查的转录排在最后\n- 使用 --output alfred 时输出 Alfred script filter 的 JSON，条目会在 v2t serve 中打开转录\n- v2t serve 在 /api/quick-search?user=...&q=... 提供相同的搜索",
+	"Whose transcriptions to search":                                                  "搜索哪个用户的转录",
+	"Most results to list, 0 lists all":                                               "最多列出的结果数，0 表示全部列出",
+	"Output format: text, or alfred for the script filter JSON of Alfred and Raycast": "输出格式：text，或 alfred 输出 Alfred 和 Raycast 使用的 script filter JSON",
+	"Address of v2t serve the alfred items link to":                                   "alfred 条目链接到的 v2t serve 地址",
+	"unknown output %q, use text or alfred":                                           "未知的输出格式 %q，请使用 text 或 alfred",
+	"No transcription matches\n":                                                      "没有匹配的转录\n",
+	"ID\tFILE\tCONFIDENCE\tSNIPPET":                                                   "ID\t文件\t置信度\t片段",
+	"Cut silences of 2 seconds and more before transcribing, the cut seconds are stored as trimmed_seconds": "转录前剪掉 2 秒及以上的静音，剪掉的秒数保存为 trimmed_seconds",
+	"Owner of the transcription":       "转录的所有者",
+	"invalid transcription id %q":      "无效的转录 ID %q",
+	"transcription %d of %s not found": "未找到 %[2]s 的转录 %[1]d",
+	"Print a YouTube description with chapters, summary and hashtags for a transcription": "为转录输出带章节、摘要和话题标签的 YouTube 简介",
+	"Print a YouTube description with chapters, summary and hashtags for a transcription\n\n- The summary are the opening sentences of the transcription\n- Chapters are cut at the longest pauses, about one per three minutes and at least three, titled by their first sentence\n- Hashtags are the most frequent words of the transcription\n- Needs the stored timestamps, the same text is written by export --format youtube-desc": "为转录输出带章节、摘要和话题标签的 YouTube 简介\n\n- 摘要是转录开头的几句话\n- 章节在最长的停顿处切分，大约每三分钟一章，至少三章，标题为章节的第一句话\n- 话题标签是转录中出现最多的词\n- 需要已存储的时间戳，export --format youtube-desc 会写出相同的文字",
+	"Whose transcriptions to translate":   "要翻译谁的转录",
+	"Target language code, e.g. en or zh": "目标语言代码，例如 en 或 zh",
+	"Translation backend: openai, gemini, deepl (default is translation.backend in config.yaml, else openai)": "翻译后端：openai、gemini、deepl（默认为 config.yaml 中的 translation.backend，否则为 openai）",
+	"Translate transcriptions that already have a translation into the language again":                        "重新翻译已有该语言译文的转录",
+	"Translate the stored transcriptions of a user into another language":                                     "将用户已存储的转录翻译成另一种语言",
+	"Translate the stored transcriptions of a user into another language\n\n- Backends are openai, gemini and deepl, their keys are read from OPENAI_API_KEY, GEMINI_API_KEY and DEEPL_AUTH_KEY, or llm for the language model of llm in config.yaml\n- Translations are stored in the database next to the transcriptions, one per language\n- Transcriptions already translated into the language are skipped unless --force is set\n- With --segments, each segment is translated on its own for the language-learning review of serve": "将用户已存储的转录翻译成另一种语言\n\n- 后端有 openai、gemini 和 deepl，密钥从 OPENAI_API_KEY、GEMINI_API_KEY 和 DEEPL_AUTH_KEY 读取；llm 使用 config.yaml 中 llm 配置的语言模型\n- 译文与转录一起存储在数据库中，每种语言一份\n- 已翻译成该语言的转录会被跳过，除非设置了 --force\n- 使用 --segments 时，逐段单独翻译，供 serve 的语言学习复习页使用",
+	"the configured database does not keep translations": "当前配置的数据库不保存译文",
+	"%d translated, %d skipped, %d failed\n":             "已翻译 %d 条，跳过 %d 条，失败 %d 条\n",
+	"%d transcriptions failed to translate":              "%d 条转录翻译失败",
+	"Transcribe a quick draft with refine.draft_model of config.yaml, v2t refine replaces it with the high-quality result later": "用 config.yaml 中的 refine.draft_model 快速转录草稿，之后由 v2t refine 替换为高质量结果",
+	"--draft only works with video and URL conversions":                                                                          "--draft 只适用于视频和 URL 转换",
+	"Set refine.draft_model in config.yaml to use --draft":                                                                       "使用 --draft 需要在 config.yaml 中设置 refine.draft_model",
+	"Replace draft transcriptions with high-quality ones":                                                                        "用高质量转录替换草稿转录",
+	"Replace draft transcriptions with high-quality ones\n\n- v2t convert --draft transcribes with the fast refine.draft_model of config.yaml and queues the result\n- v2t refine run transcribes the queued drafts again with refine.model, within refine.window\n- The refined text becomes the current revision, the draft stays in v2t revisions": "用高质量转录替换草稿转录\n\n- v2t convert --draft 使用 config.yaml 中快速的 refine.draft_model 转录，并将结果加入队列\n- v2t refine run 在 refine.window 时段内用 refine.model 重新转录队列中的草稿\n- 精修后的文字成为当前修订版本，草稿保留在 v2t revisions 中",
+	"Refine the queued drafts":                                                         "精修队列中的草稿",
+	"List the drafts and their refine status":                                          "列出草稿及其精修状态",
+	"The user whose database holds the drafts (default database when empty)":           "草稿所在数据库对应的用户（为空时使用默认数据库）",
+	"Refine at most this many drafts, all of them when 0":                              "最多精修的草稿数，0 表示全部",
+	"Outside refine.window, wait until it opens instead of exiting":                    "在 refine.window 时段之外时，等待时段开始而不是退出",
+	"Refine right away, ignoring refine.window":                                        "立即精修，忽略 refine.window",
+	"Only list the drafts with this status: pending, done or failed":                   "只列出该状态的草稿：pending、done 或 failed",
+	"Invalid refine.window in config.yaml: %v":                                         "config.yaml 中的 refine.window 无效：%v",
+	"Outside the refine window %s, it opens at %s\n":                                   "当前不在精修时段 %s 内，时段将于 %s 开始\n",
+	"Waiting for the refine window %s, it opens at %s\n":                               "等待精修时段 %s，时段将于 %s 开始\n",
+	"the configured database does not keep refine jobs":                                "当前配置的数据库不保存精修任务",
+	"Refined %d, failed %d, %d drafts remaining\n":                                     "已精修 %d 条，失败 %d 条，剩余 %d 条草稿\n",
+	"invalid --status %q, expected pending, done or failed":                            "无效的 --status %q，应为 pending、done 或 failed",
+	"No drafts queued for refinement\n":                                                "没有等待精修的草稿\n",
+	"ID\tFILE\tSTATUS\tUPDATED\tERROR":                                                 "ID\t文件\t状态\t更新时间\t错误",
+	"Which transcriptions to edit, e.g. user=X,before=2023-01-01,collection=inbox":     "要编辑哪些转录，例如 user=X,before=2023-01-01,collection=inbox",
+	"Metadata to set as key=value, key= removes the key, can be repeated":              "要设置的元数据，格式为 key=value，key= 表示删除该键，可重复使用",
+	"Only list the changes, the database is not touched":                               "只列出改动，不修改数据库",
+	"Where to write the patch that undoes the edit (default is meta-undo-<time>.json)": "撤销补丁的写入位置（默认为 meta-undo-<时间>.json）",
+	"Edit the metadata of many transcriptions at once":                                 "批量编辑转录的元数据",
+	"Edit the metadata of many transcriptions at once\n\n- Metadata are free-form keys such as language or collection\n- set edits every transcription its filter selects, --dry-run lists the changes first\n- Every edit writes a patch file, meta undo with that file restores the previous values": "批量编辑转录的元数据\n\n- 元数据是自由定义的键，例如 language 或 collection\n- set 编辑过滤条件选中的所有转录，--dry-run 先列出改动\n- 每次编辑都会写入补丁文件，用该文件运行 meta undo 可恢复原来的值",
+	"Set metadata on the transcriptions a filter selects":                            "为过滤条件选中的转录设置元数据",
+	"Restore the metadata an edit changed":                                           "恢复一次编辑改动的元数据",
+	"Print the metadata of a transcription":                                          "输出一条转录的元数据",
+	"invalid --filter: %v":                                                           "无效的 --filter：%v",
+	"invalid --set: %v":                                                              "无效的 --set：%v",
+	"the configured database does not keep metadata":                                 "当前配置的数据库不保存元数据",
+	"No transcription needs a change\n":                                              "没有需要修改的转录\n",
+	"%d transcriptions would be changed, run again without --dry-run to apply\n":     "将修改 %d 条转录，去掉 --dry-run 再次运行以应用\n",
+	"write undo file failed: %v":                                                     "写入撤销文件失败：%v",
+	"%d transcriptions changed, undo with: v2t meta undo %s\n":                       "已修改 %d 条转录，撤销命令：v2t meta undo %s\n",
+	"%d transcriptions restored\n":                                                   "已恢复 %d 条转录\n",
+	"ID\tFILE\tCHANGES":                                                              "ID\t文件\t改动",
+	"Also serve the gRPC API of api/proto on this address, example: 127.0.0.1:9090":  "同时在此地址上提供 api/proto 中定义的 gRPC API，例如：127.0.0.1:9090",
+	"The user whose database holds the transcriptions (default database when empty)": "转录所在数据库对应的用户（为空时使用默认数据库）",
+	"How many transcriptions to list, 0 lists all of them":                           "列出的转录数量，0 表示全部列出",
+	"How many transcriptions to skip, for the next pages":                            "跳过的转录数量，用于翻到后面的页",
+	"Sort by time, id, duration or file":                                             "按 time、id、duration 或 file 排序",
+	"Sort in ascending order, the default is descending":                             "升序排列，默认为降序",
+	"The new text of the transcription":                                              "转录的新文本",
+	"Assign the transcription to this user":                                          "将转录归到此用户名下",
+	"The new file name of the transcription":                                         "转录的新文件名",
+	"The new source URL of the transcription":                                        "转录的新来源 URL",
+	"List, correct and delete stored transcriptions":                                 "列出、修正和删除已保存的转录",
+	"List, correct and delete stored transcriptions\n\n- list pages through the transcriptions of a user, sorted by time, id, duration or file\n- update overwrites the fields given as flags, use revisions to keep the previous text\n- delete removes transcriptions with their segments, revisions, translations and metadata": "列出、修正和删除已保存的转录\n\n- list 按页列出用户的转录，可按 time、id、duration 或 file 排序\n- update 覆盖以参数给出的字段，需要保留之前的文本时请使用 revisions\n- delete 删除转录及其分段、版本、翻译和元数据",
+	"List the transcriptions of a user page by page":                     "按页列出用户的转录",
+	"--user is required to list transcriptions":                          "列出转录需要 --user",
+	"ID\tCONVERTED\tDURATION\tFILE\tLENGTH":                              "ID\t转录时间\t时长\t文件\t长度",
+	"Correct the fields of a transcription":                              "修正转录的字段",
+	"nothing to update, pass --text, --set-user, --file or --source-url": "没有要更新的内容，请传入 --text、--set-user、--file 或 --source-url",
+	"no transcription %d: %v":                                            "没有转录 %d：%v",
+	"transcription %d updated\n":                                         "转录 %d 已更新\n",
+	"Delete transcriptions with everything stored about them":            "删除转录及其所有相关数据",
+	"transcription %d deleted\n":                                         "转录 %d 已删除\n",
+	"Transcribe the jobs on the v2t-agent machines registered over gRPC instead of locally, needs --grpc-addr": "在通过 gRPC 注册的 v2t-agent 机器上转录任务，而不是在本机转录，需要 --grpc-addr",
+	"--agents needs --grpc-addr for the agents to connect to":                                                  "--agents 需要 --grpc-addr 供 agent 连接",
+	"The user whose database to work on (default database when empty)":                                         "要操作其数据库的用户（为空时使用默认数据库）",
+	"Schema version to migrate to, lower than the current one reverts migrations (default is the latest)":      "要迁移到的 schema 版本，低于当前版本时回滚迁移（默认为最新版本）",
+	"Revert migrations, which drops what they added":                                                           "回滚迁移，会删除这些迁移添加的内容",
+	"Show and change the schema version of a database":                                                         "查看和更改数据库的 schema 版本",
+	"List the migrations and which of them are applied":                                                        "列出迁移及其是否已应用",
+	"VERSION\tNAME\tAPPLIED":                "版本\t名称\t应用时间",
+	"pending":                               "待执行",
+	"schema version %d, the latest is %d\n": "schema 版本 %d，最新版本为 %d\n",
+	"Migrate the schema to the latest or a given version": "将 schema 迁移到最新版本或指定版本",
+	"would revert %s\n": "将回滚 %s\n",
+	"reverting migrations drops what they added, pass --yes to revert them":                                                     "回滚迁移会删除它们添加的内容，传入 --yes 以确认回滚",
+	"the database has no versioned schema":                                                                                      "该数据库没有版本化的 schema",
+	"Convert processed videos again when their file was replaced, the new transcript is only stored when it changed materially": "已处理的视频文件被替换后重新转换，新的转录文本仅在有实质变化时才保存",
+	"Word error rate against the previous transcript from which a replaced video counts as changed":                             "被替换的视频与之前的转录文本的词错误率达到该值时视为有变化",
+	"--detect-changes only works with video conversions":                                                                        "--detect-changes 仅适用于视频转换",
+	"Whose transcriptions to screen":                                                                                            "要审核谁的转录",
+	"Moderation backend: openai, http (default is moderation.backend in config.yaml, else openai)":                              "审核后端：openai、http（默认为 config.yaml 中的 moderation.backend，否则为 openai）",
+	"Screen transcriptions again that were screened since their last change":                                                    "重新审核自上次修改以来已审核过的转录",
+	"Screen the stored transcriptions of a user for harmful content":                                                            "审核用户已存储的转录中是否有有害内容",
+	"Screen the stored transcriptions of a user for harmful content\n\n- Backends are openai, its key is read from OPENAI_API_KEY, and http for a local classifier\n- The latest result is stored per transcription, transcriptions screened since their last change are skipped\n- moderation.actions in config.yaml decide what happens to flagged transcriptions: flag, block_export and notify\n- The flagged transcriptions of the user are listed afterwards": "审核用户已存储的转录中是否有有害内容\n\n- 后端有 openai（密钥从 OPENAI_API_KEY 读取）和用于本地分类器的 http\n- 每条转录存储最新的审核结果，自上次修改以来已审核过的转录会被跳过\n- config.yaml 中的 moderation.actions 决定如何处理被标记的转录：flag、block_export 和 notify\n- 最后列出该用户被标记的转录",
+	"the configured database does not keep moderation results":                                                        "配置的数据库不保存审核结果",
+	"%d screened, %d flagged, %d skipped, %d failed\n":                                                                "已审核 %d 条，标记 %d 条，跳过 %d 条，失败 %d 条\n",
+	"%d transcriptions failed to screen":                                                                              "%d 条转录审核失败",
+	"ID\tFILE\tCATEGORIES\tBACKEND\tCHECKED":                                                                          "ID\t文件\t类别\t后端\t审核时间",
+	"%d transcriptions flagged by moderation were skipped\n":                                                          "已跳过 %d 条被审核标记的转录\n",
+	"transcription %d is flagged by moderation and was skipped\n":                                                     "转录 %d 已被审核标记，已跳过\n",
+	"transcription %d is flagged by moderation and can't be exported":                                                 "转录 %d 已被审核标记，无法导出",
+	"Driver of the database given by --dsn: sqlite or postgres":                                                       "--dsn 指定的数据库驱动：sqlite 或 postgres",
+	"Work on this database instead of the configured one, a file path for sqlite or a connection string for postgres": "操作此数据库而不是配置的数据库，sqlite 为文件路径，postgres 为连接字符串",
+	"Format of the dump, only jsonl is supported":                                                                     "导出格式，仅支持 jsonl",
+	"File to write the dump to (default stdout)":                                                                      "导出写入的文件（默认标准输出）",
+	"Let the database assign new ids, for importing into a database that has data":                                    "由数据库分配新的 ID，用于导入已有数据的数据库",
+	"CSV file to write the old and new id of every imported transcription to":                                         "写入每条导入转录旧 ID 与新 ID 的 CSV 文件",
+	"Dump all data of a database":                                                                                     "导出数据库的全部数据",
+	"Import a dump written by export":                                                                                 "导入 export 写出的数据",
+	"Import a dump written by export\n\n- The dump is imported in one transaction, nothing is imported when a row fails\n- The ids of the dump are kept, which needs the tables of the database to be empty\n- --remap imports into a database that has data, the transcriptions get new ids and --report lists them":                                                          "导入 export 写出的数据\n\n- 在一个事务中导入，任一行失败则全部不导入\n- 保留导出数据中的 ID，这要求数据库的表为空\n- --remap 可导入已有数据的数据库，转录获得新的 ID，--report 列出它们",
+	"Show and change the schema version of a database\n\n- Pending migrations are applied whenever v2t opens a SQLite or PostgreSQL database\n- version lists the migrations with the time they were applied\n- migrate --to reverts migrations, for going back to an older v2t\n- export and import move all data between databases, also from SQLite to PostgreSQL and back": "查看和更改数据库的 schema 版本\n\n- v2t 每次打开 SQLite 或 PostgreSQL 数据库时都会应用待执行的迁移\n- version 列出各个迁移及其应用时间\n- migrate --to 回滚迁移，用于退回旧版本的 v2t\n- export 和 import 在数据库之间迁移全部数据，包括从 SQLite 到 PostgreSQL 以及反向",
+	"unsupported dump format %s, only jsonl is supported": "不支持的导出格式 %s，仅支持 jsonl",
+	"TABLE\tROWS": "表\t行数",
+	"--report lists remapped ids, pass --remap too":            "--report 列出重新分配的 ID，请同时指定 --remap",
+	"%v, pass --remap to import into a database that has data": "%v，导入已有数据的数据库请指定 --remap",
+	"TABLE\tIMPORTED\tSKIPPED":                                 "表\t已导入\t已跳过",
+	"wrote the ids of %d transcriptions to %s\n":               "已将 %d 条转录的 ID 写入 %s\n",
+	"Manage the API keys of the providers":                     "管理服务的 API 密钥",
+	"Manage the API keys of the providers\n\n- Keys are stored in the OS keychain, or a file encrypted with the passphrase in V2T_SECRETS_PASSPHRASE\n- secrets.backend in config.yaml selects keychain, file or env\n- A provider without a stored key reads it from its environment variable, e.g. OPENAI_API_KEY": "管理服务的 API 密钥\n\n- 密钥保存在操作系统钥匙串中，或用 V2T_SECRETS_PASSPHRASE 中的口令加密的文件中\n- config.yaml 中的 secrets.backend 选择 keychain、file 或 env\n- 没有已保存密钥的服务从其环境变量读取密钥，例如 OPENAI_API_KEY",
+	"Store the API key of a provider, read from stdin":   "保存服务的 API 密钥，从标准输入读取",
+	"Remove the stored API key of a provider":            "删除已保存的服务 API 密钥",
+	"List where the API key of each provider comes from": "列出每个服务 API 密钥的来源",
+	"unknown provider %s, supported: %s":                 "未知的服务 %s，支持：%s",
+	"API key for %s: ":                                   "%s 的 API 密钥：",
+	"no key given":                                       "未提供密钥",
+	"stored the %s key in the %s store\n":                "已将 %s 密钥保存到 %s 存储\n",
+	"no %s key in the %s store":                          "%[2]s 存储中没有 %[1]s 密钥",
+	"removed the %s key from the %s store\n":             "已从 %[2]s 存储删除 %[1]s 密钥\n",
+	"PROVIDER\tSOURCE\tENVIRONMENT VARIABLE":             "服务\t来源\t环境变量",
+	"error: %v":                                          "错误：%v",
+	"Passphrase of %s: ":                                 "%s 的口令：",
+
+	"List the optional subsystems and how to enable the unavailable ones": "列出可选组件，以及如何启用不可用的组件",
+	"CAPABILITY\tSTATUS\tGUIDANCE":                                        "组件\t状态\t启用方法",
+	"unavailable: %s":                                                     "不可用：%s",
+
+	"Manage the whisper.cpp host of ssh_whisper":                         "管理 ssh_whisper 的 whisper.cpp 主机",
+	"List, download and select the models on the whisper.cpp host":       "列出、下载和选择 whisper.cpp 主机上的模型",
+	"List the ggml models on the whisper.cpp host":                       "列出 whisper.cpp 主机上的 ggml 模型",
+	"Download models on the whisper.cpp host and verify their checksums": "在 whisper.cpp 主机上下载模型并校验",
+	"Make a model on the whisper.cpp host the default of ssh_whisper":    "将 whisper.cpp 主机上的模型设为 ssh_whisper 的默认模型",
+	"No models in %s\n":                 "%s 中没有模型\n",
+	"MODEL\tSIZE\tDEFAULT\tPATH":        "模型\t大小\t默认\t路径",
+	"\r%s of %s":                        "\r%s / %s",
+	"Downloading %s (%s) on the host\n": "正在主机上下载 %s（%s）\n",
+	"\r%s verified at %s\n":             "\r%s 已校验，位于 %s\n",
+	"ssh_whisper transcribes with %s\n": "ssh_whisper 改用 %s 转录\n",
+	"model %s is not on the host, download it with v2t providers ssh models download %s":                     "主机上没有模型 %s，请用 v2t providers ssh models download %s 下载",
+	"Translate segment by segment for the review page of serve, only segments without a current translation": "逐段翻译，供 serve 的复习页使用，只翻译没有最新译文的分段",
+	"%d segments translated, %d transcriptions failed\n":                                                     "已翻译 %d 个分段，%d 个转录失败\n",
+	"Whose episodes to analyze": "要分析哪个用户的节目",
+	"Analyze episodes with current analytics again, e.g. after changing analytics.filler_words": "重新分析已有最新分析结果的节目，例如修改 analytics.filler_words 之后",
+	"Print the weekly trend instead of the episodes":                                            "输出每周趋势而不是各期节目",
+	"Show speech analytics of the episodes of a user":                                           "显示用户各期节目的语音分析",
+	"Show speech analytics of the episodes of a user\n\n- Filler words per minute, the built-in ones such as um, uh and 嗯 plus analytics.filler_words of config.yaml\n- The longest monologue, a stretch one speaker talked without a pause of 3 seconds\n- Interruptions, turns starting while or right as the previous speaker talked, need diarized segments\n- New and re-transcribed episodes are analyzed and stored first, serve shows the same on /analytics/{user}\n- With --json, prints the episodes and the weekly trend like /api/v1/users/{user}/speech-analytics": "显示用户各期节目的语音分析\n\n- 每分钟填充词数，内置 um、uh、嗯 等，另加 config.yaml 中的 analytics.filler_words\n- 最长独白，即同一说话人中间没有 3 秒停顿的最长一段\n- 打断次数，即在上一位说话人说话时或刚说完时开始的发言，需要说话人分离后的分段\n- 新的和重新转录的节目会先分析并保存，serve 在 /analytics/{user} 显示相同内容\n- 使用 --json 时，像 /api/v1/users/{user}/speech-analytics 一样输出各期节目和每周趋势",
+	"the configured database does not keep speech analytics":                                "当前配置的数据库不保存语音分析",
+	"%s has no transcriptions to analyze\n":                                                 "%s 没有可分析的转录\n",
+	"WEEK\tEPISODES\tFILLERS/MIN\tWORDS/MIN\tLONGEST MONOLOGUE(s)\tINTERRUPTIONS":           "周\t节目数\t填充词/分钟\t词/分钟\t最长独白(秒)\t打断次数",
+	"Fillers per minute: %s\n":                                                              "每分钟填充词：%s\n",
+	"ID\tDATE\tFILE\tFILLERS/MIN\tWORDS/MIN\tLONGEST MONOLOGUE(s)\tSPEAKERS\tINTERRUPTIONS": "ID\t日期\t文件\t填充词/分钟\t词/分钟\t最长独白(秒)\t说话人\t打断次数",
+	"Export a condensed audio of a transcription without its pauses and filler words":       "导出去掉停顿和口头禅的转录精简音频",
+	"Export a condensed audio of a transcription without its pauses and filler words\n\n- gentle cuts pauses of 1.5 seconds and more, normal of a second and filler words, aggressive every pause of half a second\n- Filler words are the built-in ones plus analytics.filler_words of config.yaml, they are cut where the provider reported word timings\n- <file>.condensed.mp3 is written with <file>.condensed.srt timed to it, or <file>.condensed.txt without timestamps": "导出去掉停顿和口头禅的转录精简音频\n\n- gentle 剪掉 1.5 秒及以上的停顿，normal 剪掉一秒的停顿和口头禅，aggressive 剪掉每个半秒的停顿\n- 口头禅为内置词加上 config.yaml 的 analytics.filler_words，在服务报告了词级时间的地方剪掉\n- 写出 <文件>.condensed.mp3 以及与之对齐的 <文件>.condensed.srt，没有时间戳时写出 <文件>.condensed.txt",
+	"Directory the condensed audio and its transcript are written to":           "精简音频及其转录的输出目录",
+	"Audio of the transcription, data/mp3/<user>/<file> by default":             "转录的音频，默认为 data/mp3/<用户>/<文件>",
+	"What is cut: gentle, normal or aggressive, or 1 to 3":                      "剪切力度：gentle、normal 或 aggressive，或 1 到 3",
+	"the audio of transcription %d is missing, pass it with --audio: %v":        "转录 %d 的音频不存在，请用 --audio 指定：%v",
+	"condensed %s to %s, %.0f%% shorter: %s, %s\n":                              "已从 %s 精简到 %s，缩短 %.0f%%：%s，%s\n",
+	"--vad cuts quiet passages, it can't be used with verbatim conversions":     "--vad 会剪掉小声的段落，不能用于逐字转录",
+	"Level of the log messages: debug, info, warn or error, --verbose is debug": "日志级别：debug、info、warn 或 error，--verbose 即 debug",
+	"Format of the log messages on stderr: console or json":                     "输出到 stderr 的日志格式：console 或 json",
+	"Manage the vocabulary packs that bias and correct the transcriptions":      "管理用于引导和纠正转录的词汇包",
+	"Manage the vocabulary packs that bias and correct the transcriptions\n\n- A pack lists domain terms with their preferred spelling, the variants to correct and optional expansions\n- Installed packs add their terms to the prompt of whisper.cpp and OpenAI and correct the variants after transcription\n- Packs are yaml files installed in the vocab directory next to config.yaml, v2t ships medical-zh, medical-en and tech-en": "管理用于引导和纠正转录的词汇包\n\n- 词汇包列出领域术语的规范写法、需要纠正的变体以及可选的全称\n- 已安装的词汇包会把术语加入 whisper.cpp 和 OpenAI 的提示词，并在转录后纠正变体\n- 词汇包是安装在 config.yaml 同级 vocab 目录中的 yaml 文件，v2t 内置 medical-zh、medical-en 和 tech-en",
+	"List the built-in and installed packs":      "列出内置和已安装的词汇包",
+	"NAME\tLANGUAGE\tTERMS\tSTATUS\tDESCRIPTION": "名称\t语言\t术语数\t状态\t描述",
+	"available": "可安装",
+	"installed": "已安装",
+	"all":       "全部",
+	"Install a built-in pack by name or a pack file, replacing the installed pack of the same name": "按名称安装内置词汇包或安装词汇包文件，替换已安装的同名词汇包",
+	"Installed %s with %d terms\n": "已安装 %s，共 %d 个术语\n",
+	"Remove an installed pack":     "移除已安装的词汇包",
+	"pack %s is not installed":     "词汇包 %s 未安装",
+	"Removed %s\n":                 "已移除 %s\n",
+	"Print the terms of a pack, the installed one when it is installed": "打印词汇包的术语，已安装时打印已安装的版本",
+	"no pack named %s":          "没有名为 %s 的词汇包",
+	"TERM\tEXPANSION\tVARIANTS": "术语\t全称\t变体",
+	"Run the health checks of the providers first, failing checks count against their circuits": "先运行各提供方的健康检查，检查失败计入其熔断器",
+	"Show the circuit breakers of the providers":                                                "显示各提供方的熔断器状态",
+	"Show the circuit breakers of the providers\n\n- A provider failing failures times in a row (circuit_breaker in providers.yaml, 5 by default) is taken out of the rotation\n- After the cooldown (1m by default) a single probe is let through, it closes the circuit when it succeeds\n- Failures caused by the audio don't count, the circuits are kept in data/provider_breakers.json": "显示各提供方的熔断器状态\n\n- 提供方连续失败 failures 次（providers.yaml 中的 circuit_breaker，默认 5 次）后会被移出轮换\n- 冷却时间（默认 1m）过后放行一次探测请求，成功则关闭熔断器\n- 由音频本身导致的失败不计入，熔断器状态保存在 data/provider_breakers.json",
+	"PROVIDER\tSTATE\tFAILURES\tOPENED\tLAST ERROR": "提供方\t状态\t失败次数\t熔断时间\t最近错误",
+	"closed":         "正常",
+	"open":           "熔断",
+	"half-open":      "半开",
+	"unavailable":    "不可用",
+	"not configured": "未配置",
+	"List likely misheard transcriptions after the others, false leaves them out": "将可能听错的转录列在其他转录之后，设为 false 则不列出",
+	"Convert files in the background with the local job queue":                    "使用本地任务队列在后台转换文件",
+	"Convert files in the background with the local job queue\n\n- v2t queue add queues the files of a directory in data/queue.db\n- v2t queue worker converts the queued files, several at a time with --concurrency\n- Failed files are retried, files of a worker that crashed are picked up by the next one\n- v2t queue status counts the jobs and lists those not done yet": "使用本地任务队列在后台转换文件\n\n- v2t queue add 将目录中的文件加入 data/queue.db 中的队列\n- v2t queue worker 转换队列中的文件，可用 --concurrency 同时转换多个\n- 失败的文件会被重试，崩溃的 worker 的文件由下一个 worker 接手\n- v2t queue status 统计任务数量并列出尚未完成的任务",
+	"Queue the files of directories for conversion":                                       "将目录中的文件加入转换队列",
+	"Which user owns the videos, their transcriptions are stored in this user's database": "视频所属的用户，转录保存在该用户的数据库中",
+	"Queue audio files, whose text is written to --outputDirectory, instead of videos":    "加入音频文件而不是视频，其文本写入 --outputDirectory",
+	"Extension of the files to queue, mp4 for videos and mp3 for audio by default":        "要加入队列的文件扩展名，视频默认为 mp4，音频默认为 mp3",
+	"Where the text files of audio files are written":                                     "音频文件的文本写入的目录",
+	"List the files of %s failed: %v":                                                     "列出 %s 中的文件失败：%v",
+	"Queued %d files, %d were already queued\n":                                           "已加入 %d 个文件，%d 个已在队列中\n",
+	"Convert the queued files":                                                            "转换队列中的文件",
+	"Convert the queued files\n\n- Runs until interrupted, the files being converted still finish\n- Files failing are retried up to 3 times, waiting longer after every attempt\n- Several workers can share the queue, every file is converted by a single one": "转换队列中的文件\n\n- 一直运行到被中断，正在转换的文件仍会完成\n- 失败的文件最多尝试 3 次，每次尝试后等待更久\n- 多个 worker 可以共用队列，每个文件只由一个 worker 转换",
+	"Exit once no queued file is due instead of waiting for new ones":                         "没有待转换的文件时退出，而不是等待新文件",
+	"Invalid middlewares in config.yaml: %v":                                                  "config.yaml 中的中间件无效：%v",
+	"List the jobs with this status: pending, running, done or failed (default all but done)": "列出该状态的任务：pending、running、done 或 failed（默认列出除 done 外的全部）",
+	"Count the queued jobs and list those not done":                                           "统计队列中的任务并列出未完成的任务",
+	"invalid --status %q, expected pending, running, done or failed":                          "无效的 --status %q，应为 pending、running、done 或 failed",
+	"Pending %d, running %d, done %d, failed %d\n":                                            "待执行 %d，运行中 %d，已完成 %d，失败 %d\n",
+	"ID\tKIND\tFILE\tUSER\tSTATUS\tATTEMPTS\tUPDATED\tERROR":                                  "ID\t类型\t文件\t用户\t状态\t尝试次数\t更新时间\t错误",
+	"running": "运行中",
+	"done":    "已完成",
+	"failed":  "失败",
+	"Print the results as JSON on stdout, the other messages go to stderr": "在 stdout 上以 JSON 输出结果，其他信息输出到 stderr",
+	"Converted %d, failed %d, skipped %d\n":                                "已转换 %d 个，失败 %d 个，跳过 %d 个\n",
+	"%d files failed: %v":                                                  "%d 个文件失败：%v",
+	"%d of %d files failed":                                                "%[2]d 个文件中有 %[1]d 个失败",
+	"Print only errors and results, for cron jobs and CI, see the exit statuses in the README": "只输出错误和结果，适用于 cron 任务和 CI，退出状态见 README",
+	"Leave out emoji and other decorations like sparklines":                                    "不输出表情符号和迷你图等装饰",
+	"%s is not a directory": "%s 不是目录",
+	"Convert audio files, whose text is written to --outputDirectory, instead of videos":                  "转换音频文件而不是视频，文本写入 --outputDirectory",
+	"Extensions of the files to convert separated by commas, mp4 for videos and mp3 for audio by default": "要转换的文件扩展名，以逗号分隔，视频默认为 mp4，音频默认为 mp3",
+	"Also convert the files already in the directory, those converted before are skipped":                 "同时转换目录中已有的文件，之前转换过的会跳过",
+	"How long a new file has to stay unchanged before it is converted":                                    "新文件需要保持不变多久才开始转换",
+	"Convert the files dropped into a directory":                                                          "转换放入目录的文件",
+	"Convert the files dropped into a directory\n\n- New files are converted once they stopped changing, files still being copied are left alone\n- Videos are stored in the database of --user, the text of audio files is written to --outputDirectory\n- Files converted before are skipped, a failed file is logged and the watch goes on\n- Runs until interrupted, the file being converted still finishes": "转换放入目录的文件\n\n- 新文件在不再变化后转换，仍在复制中的文件不会处理\n- 视频保存到 --user 的数据库，音频文件的文本写入 --outputDirectory\n- 之前转换过的文件会跳过，失败的文件会记录日志，监视继续进行\n- 一直运行直到被中断，正在转换的文件仍会完成",
+	"Overwrite the templates already in the prompts directory":           "覆盖 prompts 目录中已有的模板",
+	"Manage the prompt templates of the features using a language model": "管理使用语言模型的功能的提示词模板",
+	"Manage the prompt templates of the features using a language model\n\n- Every feature has a built-in template: summary, title, action_items, chapters, condense, qa, ask and translation\n- A file named after the feature in the prompts directory next to config.yaml replaces it, e.g. prompts/summary.tmpl\n- Templates are Go text/template files with variables like {{.Transcript}}, {{.User}}, {{.Language}}, {{.File}} and {{.Segments}}\n- v2t prompts init writes the built-in templates there to edit them, v2t prompts validate checks them": "管理使用语言模型的功能的提示词模板\n\n- 每个功能都有内置模板：summary、title、action_items、chapters、condense、qa、ask 和 translation\n- config.yaml 旁边 prompts 目录中以功能命名的文件会替换内置模板，例如 prompts/summary.tmpl\n- 模板是 Go text/template 文件，可以使用 {{.Transcript}}、{{.User}}、{{.Language}}、{{.File}} 和 {{.Segments}} 等变量\n- v2t prompts init 把内置模板写到该目录以便编辑，v2t prompts validate 检查模板",
+	"List the features and the template each one uses":                  "列出各功能及其使用的模板",
+	"Invalid templates use the built-in ones, see v2t prompts validate": "无效的模板会使用内置模板代替，见 v2t prompts validate",
+	"FEATURE\tTEMPLATE":                 "功能\t模板",
+	"built-in":                          "内置",
+	"Print the template a feature uses": "输出某个功能使用的模板",
+	"Write the built-in templates into the prompts directory to edit them, all when no feature is given": "把内置模板写入 prompts 目录以便编辑，未指定功能时写入全部",
+	"Kept %s, --force overwrites it\n": "保留了 %s，--force 会覆盖它\n",
+	"Wrote %s\n":                       "已写入 %s\n",
+	"Check that the templates in the prompts directory parse and render":                           "检查 prompts 目录中的模板能否解析和渲染",
+	"invalid prompt templates:\n%v":                                                                "无效的提示词模板：\n%v",
+	"The prompt templates in %s are valid\n":                                                       "%s 中的提示词模板有效\n",
+	"Generate a summary, title, action items or chapters of a transcription with a language model": "使用语言模型生成转录的摘要、标题、待办事项或章节",
+	"Generate a summary, title, action items or chapters of a transcription with a language model\n\n- The prompt is the template of the feature, see v2t prompts\n- Chapters use the timestamps of the transcription when it was stored with them\n- Uses the language model of llm in config.yaml, OpenAI unless set, e.g. a local Ollama model\n- A transcript over the token budget of the feature is condensed part by part first, see llm.budgets in config.yaml": "使用语言模型生成转录的摘要、标题、待办事项或章节\n\n- 提示词是该功能的模板，见 v2t prompts\n- 转录保存了时间戳时，章节会使用这些时间戳\n- 使用 config.yaml 中 llm 配置的语言模型，未设置时为 OpenAI，也可以是本地的 Ollama 模型\n- 超出该功能 token 预算的转录会先分段压缩，见 config.yaml 中的 llm.budgets",
+	"unknown feature %q, use summary, title, action_items or chapters":                                        "未知的功能 %q，请使用 summary、title、action_items 或 chapters",
+	"%s: extracting audio %d%%\n":                                                                             "%s：正在提取音频 %d%%\n",
+	"Invalid llm in config.yaml: %v":                                                                          "config.yaml 中的 llm 配置无效：%v",
+	"Transcribe into English whatever language is spoken":                                                     "无论说的是哪种语言，都转录为英文",
+	"Sampling temperature of the decoder from 0 to 1, higher values are more random (default the provider's)": "解码器的采样温度，取值 0 到 1，越高越随机（默认使用服务商的设置）",
+	"Probability below which whisper.cpp drops a word timestamp (default the provider's)":                     "whisper.cpp 丢弃单词时间戳的概率阈值（默认使用服务商的设置）",
+	"Split the segments of whisper.cpp longer than this many characters, e.g. for subtitles":                  "将 whisper.cpp 超过该字符数的片段拆分，例如用于字幕",
+	"--temperature must be between 0 and 1":                                                                   "--temperature 必须在 0 到 1 之间",
+	"--stream can't be used with --translate, --temperature, --word-threshold or --max-segment-length":        "--stream 不能与 --translate、--temperature、--word-threshold 或 --max-segment-length 同时使用",
+	"Print the tokens and the estimated cost of the requests without sending them":                            "只输出请求的 token 数和预估费用，不发送请求",
+	"Transcript over the %s budget of %d tokens, condensing %d parts\n":                                       "转录超出 %s 的 %d token 预算，正在压缩 %d 段\n",
+	"%d requests, about %d tokens in and %d out, estimated cost %.4f\n":                                       "%d 次请求，约输入 %d 个 token、输出 %d 个 token，预估费用 %.4f\n",
+	"Transcript of about %d tokens, budget of %s %d tokens\n":                                                 "转录约 %d 个 token，%s 的预算为 %d 个 token\n",
+	"Condensed in %d parts first\n":                                                                           "先分 %d 段压缩\n",
+	"At least %d requests, estimated input cost %.4f\n":                                                       "至少 %d 次请求，预估输入费用 %.4f\n",
+	"%d responses from the cache, see llm.cache in config.yaml\n":                                             "%d 个响应来自缓存，见 config.yaml 中的 llm.cache\n",
+	"Embed at most this many transcriptions, all of them when 0":                                              "最多嵌入多少条转录，为 0 时全部嵌入",
+	"Time between two backfills (default embedding.interval in config.yaml, else 10m)":                        "两次补全之间的间隔（默认为 config.yaml 中的 embedding.interval，否则为 10m）",
+	"Embed the transcriptions for the vector index":                                                           "为向量索引嵌入转录",
+	"Embed the transcriptions for the vector index\n\n- Uses the model of embedding in config.yaml, OpenAI unless set, e.g. a local Ollama model\n- Transcriptions without an embedding of the model, or revised since, are embedded in batches of embedding.batch_size\n- embedding.requests_per_minute limits the requests per provider\n- Every batch is saved as it is embedded, a stopped run resumes where it stopped\n- v2t embed daemon embeds the new transcriptions every embedding.interval until it is stopped\n- v2t embed reindex switches the index to another model once it embedded every transcription": "为向量索引嵌入转录\n\n- 使用 config.yaml 中 embedding 配置的模型，未设置时为 OpenAI，也可以是本地的 Ollama 模型\n- 没有该模型嵌入或之后被修订的转录按 embedding.batch_size 分批嵌入\n- embedding.requests_per_minute 限制每个服务的请求数\n- 每批嵌入后立即保存，中断的运行会从中断处继续\n- v2t embed daemon 每隔 embedding.interval 嵌入新的转录，直到被停止\n- v2t embed reindex 在所有转录都嵌入后将索引切换到另一个模型",
+	"Provider of the new model: openai, ollama, http (default embedding.provider in config.yaml)": "新模型的服务：openai、ollama、http（默认为 config.yaml 中的 embedding.provider）",
+	"The new embedding model, e.g. text-embedding-3-large":                                        "新的嵌入模型，例如 text-embedding-3-large",
+	"Dimensions of the vectors of the new model, checked against every vector (default any)":      "新模型的向量维度，每个向量都会检查（默认不限）",
+	"Keep the vectors of the previous model instead of deleting them once the index switches":     "索引切换后保留之前模型的向量，而不是删除",
+	"Embed every transcription with a new model, then switch the index to it":                     "用新模型嵌入所有转录，然后将索引切换到该模型",
+	"Embed every transcription with a new model, then switch the index to it\n\n- The vectors of the new model are stored next to those of the current one, the index keeps the current model meanwhile\n- Stopped or with failed transcriptions, run it again to resume, the index switches once every transcription is embedded\n- The index switches in one transaction that also deletes the vectors of the previous model, unless --keep is set\n- Set the new model as embedding in config.yaml afterwards, v2t embed run and daemon only embed with the model of the index": "用新模型嵌入所有转录，然后将索引切换到该模型\n\n- 新模型的向量与当前模型的向量并存，期间索引继续使用当前模型\n- 中断或有转录失败时，再次运行即可继续，所有转录都嵌入后索引才会切换\n- 索引在一个事务中切换，并同时删除之前模型的向量，除非设置了 --keep\n- 之后请在 config.yaml 的 embedding 中设置新模型，v2t embed run 和 daemon 只使用索引的模型嵌入",
+	"Invalid embedding model: %v": "嵌入模型无效：%v",
+	"the index keeps its model until every transcription is embedded with %s, run reindex again": "在所有转录都用 %s 嵌入之前，索引保持原模型，请再次运行 reindex",
+	"The vector index now uses %s, set it as embedding in config.yaml\n":                         "向量索引现在使用 %s，请在 config.yaml 的 embedding 中设置它\n",
+	"The vector index uses %s, run v2t embed reindex to switch it to %s\n":                       "向量索引使用 %s，运行 v2t embed reindex 将其切换到 %s\n",
+	"%v, run v2t embed reindex to switch models":                                                 "%v，运行 v2t embed reindex 切换模型",
+	"Embed the transcriptions missing an embedding once":                                         "对缺少嵌入的转录执行一次嵌入",
+	"Keep embedding the new and revised transcriptions until stopped":                            "持续嵌入新增和修订的转录，直到被停止",
+	"Show how many transcriptions the configured model embedded":                                 "显示配置的模型已嵌入多少条转录",
+	"the configured database does not keep embeddings":                                           "配置的数据库不保存嵌入",
+	"Whose transcriptions to compare":                                                            "比较哪个用户的转录",
+	"Similarity from 0 to 1 from which a transcription is a duplicate of an earlier one":         "相似度阈值（0 到 1），达到该值的转录视为较早转录的重复",
+	"Analyze the stored transcriptions of a user":                                                "分析用户已保存的转录",
+	"Find the transcriptions repeating an earlier one of the user":                               "查找重复用户较早转录的转录",
+	"Find the transcriptions repeating an earlier one of the user\n\n- Flags re-uploaded and nearly identical videos, the earliest upload is the original\n- Compares the embeddings of the vector index, see v2t embed, and the overlapping word sequences of the transcripts\n- A transcription as similar as --threshold by either measure is a duplicate\n- The duplicates found replace those of the previous run, serve shows them on /analytics/{user}": "查找重复用户较早转录的转录\n\n- 标记重新上传和几乎相同的视频，最早的上传为原始版本\n- 比较向量索引中的嵌入（参见 v2t embed）以及转录文本中重叠的词序列\n- 任一度量的相似度达到 --threshold 的转录即为重复\n- 找到的重复会替换上次运行的结果，serve 在 /analytics/{user} 显示它们",
+	"--threshold must be greater than 0 and at most 1":                   "--threshold 必须大于 0 且不超过 1",
+	"the configured database does not keep duplicates":                   "配置的数据库不保存重复项",
+	"No duplicates among the transcriptions of %s\n":                     "%s 的转录中没有重复\n",
+	"ID\tFILE\tDUPLICATE OF\tORIGINAL FILE\tSIMILARITY\tEMBEDDING\tTEXT": "ID\t文件\t重复自\t原始文件\t相似度\t嵌入\t文本",
+	"Whose transcriptions to ask about":                                  "询问哪个用户的转录",
+	"Number of relevant passages the answer is grounded in":              "回答所依据的相关段落数",
+	"Ask a question about all the transcriptions of a user":              "就用户的所有转录提问",
+	"Ask a question about all the transcriptions of a user\n\n- Finds the transcriptions nearest to the question in the vector index, run v2t embed first\n- Answers from their passages most similar to the question and cites them like [2], with the file and timestamp\n- Uses the language model of llm and the embedding model of embedding in config.yaml\n- Passages beyond the budget of ask in llm.budgets are left out, the least relevant first": "就用户的所有转录提问\n\n- 在向量索引中查找与问题最接近的转录，请先运行 v2t embed\n- 根据其中与问题最相似的段落回答，并以 [2] 的形式引用，附带文件和时间戳\n- 使用 config.yaml 中 llm 的语言模型和 embedding 的嵌入模型\n- 超出 llm.budgets 中 ask 预算的段落会被省略，先省略相关性最低的",
+	"--top-k must be at least 1": "--top-k 至少为 1",
+	"none of the transcriptions of %s is embedded yet, run v2t embed run first": "%s 的转录还没有任何嵌入，请先运行 v2t embed run",
+	"%v, set the model of the index as embedding in config.yaml":                "%v，请在 config.yaml 的 embedding 中设置索引使用的模型",
+	"  [%d] %s (id %d)\n":                                   "  [%d] %s（id %d）\n",
+	"  [%d] %s at %s (id %d)\n":                             "  [%d] %s，%s 处（id %d）\n",
+	"Embedded %d, failed %d, %d transcriptions remaining\n": "已嵌入 %d 条，失败 %d 条，剩余 %d 条转录\n",
+	"Invalid embedding in config.yaml: %v":                  "config.yaml 中的 embedding 无效：%v",
+	"%s embedded %d transcriptions, %d pending\n":           "%s 已嵌入 %d 条转录，%d 条待处理\n",
+	"Show aggregated transcription statistics per user":     "按用户显示转录的汇总统计",
+	"Show aggregated transcription statistics per user\n\n- Only aggregates are printed, never transcription text\n- Groups smaller than the role's min_group_size are folded into \"(other)\"\n- Roles with epsilon > 0 see counts with differential privacy noise": "按用户显示转录的汇总统计\n\n- 只输出汇总数据，不会输出转录文字\n- 小于角色 min_group_size 的分组会合并到 \"(other)\"\n- epsilon > 0 的角色看到的计数带有差分隐私噪声",
+	"Which analytics role to apply, roles and their privacy policies are defined in config.yaml": "使用哪个统计角色，角色及其隐私策略在 config.yaml 中定义",
+	"USER\tVIDEOS\tAVG DURATION(s)\tTOTAL DURATION(s)":                                           "用户\t视频数\t平均时长(秒)\t总时长(秒)",
+	"%d small groups suppressed by role %q\n":                                                    "角色 %[2]q 隐藏了 %[1]d 个较小的分组\n",
+	"Print the version number of video-to-text":                                                  "显示 video-to-text 的版本号",
+	"All software has versions. This is video-to-text's.":                                        "所有软件都有版本号，这是 video-to-text 的版本号。",
+	"Use \"{{.CommandPath}} [command] --help\" for more information about a command.":            "使用 \"{{.CommandPath}} [command] --help\" 查看命令的详细信息。",
+	"Additional help topics:":                                                                    "其他帮助主题：",
+	"Additional Commands:":                                                                       "其他命令：",
+	"Available Commands:":                                                                        "可用命令：",
+	"Global Flags:":                                                                              "全局参数：",
+	"Flags:":                                                                                     "参数：",
+	"Examples:":                                                                                  "示例：",
+	"Aliases:":                                                                                   "别名：",
+	"Skip to transcript":                                                                         "跳到转录文字",
+	"User":                                                                                       "用户",
+	"Converted":                                                                                  "转换时间",
+	"Duration":                                                                                   "时长",
+	"%s seconds":                                                                                 "%s 秒",
+	"Audio":                                                                                      "音频",
+	"Download the audio":                                                                         "下载音频",
+	"Transcript":                                                                                 "转录文字",
+	"Use the up and down arrow keys to move between segments.": "使用上下方向键在段落之间移动。",
+	"%s:": "%s：",
+	"Also write a broadcast variant with profanity redacted (default is broadcast.enabled in config.yaml)": "同时生成屏蔽不雅词语的播出版本（默认取 config.yaml 中的 broadcast.enabled）",
+	"help for %s": "%s 的帮助",
+	"Usage:":      "用法：",
+}
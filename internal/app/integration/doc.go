@@ -0,0 +1,9 @@
+// Package integration runs v2t end to end against real services: Postgres in a container started
+// with testcontainers-go and fake whisper.cpp servers, wired like v2t serve. Files are uploaded to
+// the HTTP API, transcribed, stored, searched and exported, so a release is checked across the
+// modules rather than against mocks alone.
+//
+// The scenarios need Docker and ffprobe and only build with the integration tag:
+//
+//	go test -tags integration ./internal/app/integration/
+package integration
@@ -0,0 +1,202 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/schema"
+	"tiktok-whisper/internal/app/search"
+	"tiktok-whisper/internal/app/server"
+	"time"
+)
+
+// TestPipeline uploads files to v2t serve, which transcribes them on the whisper.cpp servers, one
+// failing over to the other, and stores them in Postgres. The results are then read back, searched
+// and exported as subtitles.
+func TestPipeline(t *testing.T) {
+	const user = "pipeline"
+	healthyBefore, brokenBefore := env.healthy.Load(), env.broken.Load()
+
+	// upload → transcribe → store
+	var ids []int
+	for _, name := range []string{"review.wav", "plans.wav"} {
+		job := submit(t, user, writeWAV(t, name, 3*time.Second))
+		job = waitDone(t, job.ID)
+		if job.Status != server.JobDone {
+			t.Fatalf("job of %s = %s: %s, want done", name, job.Status, job.Error)
+		}
+		ids = append(ids, job.TranscriptionID)
+
+		var result schema.Transcription
+		getJSON(t, "/api/v1/jobs/"+job.ID+"/result", &result)
+		if want := "Welcome to the pricing review. The new plans start next month."; result.Transcription != want {
+			t.Errorf("transcription of %s = %q, want %q", name, result.Transcription, want)
+		}
+		if result.ProviderMetadata.Provider != "whisper_server" || result.ProviderMetadata.Server == nil {
+			t.Errorf("provider metadata of %s = %+v, want whisper_server and its host", name, result.ProviderMetadata)
+		}
+	}
+	// round robin sends one of the files to the broken server first
+	if healthy, broken := env.healthy.Load()-healthyBefore, env.broken.Load()-brokenBefore; healthy != 2 || broken < 1 {
+		t.Errorf("files sent to the healthy and the broken server = %d, %d, want 2 and at least 1", healthy, broken)
+	}
+
+	// search
+	var found search.ScriptFilter
+	getJSON(t, "/api/quick-search?"+url.Values{"user": {user}, "q": {"pricing plans"}}.Encode(), &found)
+	if len(found.Items) != len(ids) {
+		t.Errorf("quick search found %d transcriptions, want %d", len(found.Items), len(ids))
+	}
+
+	// export
+	db := app.InitializeTranscriptionDAOForUser(user)
+	transcription, err := db.GetByID(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = export.LoadSegments(db, transcription); err != nil {
+		t.Fatal(err)
+	}
+	w, err := export.GetWriter("srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srt bytes.Buffer
+	if err = w.Write(&srt, *transcription, export.DefaultOptions()); err != nil {
+		t.Fatalf("export srt failed: %v", err)
+	}
+	for _, want := range []string{"00:00:00,000 --> 00:00:01,500", "00:00:01,500 --> 00:00:03,000", "The new plans start next month."} {
+		if !strings.Contains(srt.String(), want) {
+			t.Errorf("srt misses %q:\n%s", want, srt.String())
+		}
+	}
+}
+
+// TestPipeline_RejectsInvalidOptions checks that the API refuses an upload before anything is transcribed.
+func TestPipeline_RejectsInvalidOptions(t *testing.T) {
+	before := env.healthy.Load() + env.broken.Load()
+	resp := post(t, "pipeline", writeWAV(t, "hot.wav", time.Second), map[string]string{"temperature": "2"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if after := env.healthy.Load() + env.broken.Load(); after != before {
+		t.Errorf("%d files were sent to the whisper.cpp servers, want none", after-before)
+	}
+}
+
+// writeWAV writes d of silence as the 16kHz mono WAV whisper.cpp reads, so nothing is converted.
+func writeWAV(t *testing.T, name string, d time.Duration) string {
+	t.Helper()
+	const sampleRate = 16000
+	samples := int(d.Seconds() * sampleRate)
+
+	var buf bytes.Buffer
+	header := []interface{}{
+		[]byte("RIFF"), uint32(36 + samples*2), []byte("WAVE"),
+		[]byte("fmt "), uint32(16), uint16(1), uint16(1), uint32(sampleRate), uint32(sampleRate * 2), uint16(2), uint16(16),
+		[]byte("data"), uint32(samples * 2),
+	}
+	for _, field := range header {
+		binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.Write(make([]byte, samples*2))
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// post uploads the file at path for user with the extra form fields.
+func post(t *testing.T, user string, path string, fields map[string]string) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("user", user)
+	for name, value := range fields {
+		w.WriteField(name, value)
+	}
+	part, err := w.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	w.Close()
+
+	resp, err := http.Post(env.api+"/api/v1/jobs", w.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// submit uploads the file at path for user and returns its queued job.
+func submit(t *testing.T, user string, path string) server.Job {
+	t.Helper()
+	resp := post(t, user, path, nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		data, _ := io.ReadAll(resp.Body)
+		t.Fatalf("submit %s: %s %s", path, resp.Status, data)
+	}
+	var job server.Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// waitDone polls the job with id until it finished.
+func waitDone(t *testing.T, id string) server.Job {
+	t.Helper()
+	deadline := time.Now().Add(time.Minute)
+	for {
+		var job server.Job
+		getJSON(t, "/api/v1/jobs/"+id, &job)
+		if job.Status == server.JobDone || job.Status == server.JobFailed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is still %s after a minute", id, job.Status)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// getJSON reads the JSON the API answers at path into v.
+func getJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(env.api + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s %s", path, resp.Status, data)
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+}
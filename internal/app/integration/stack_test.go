@@ -0,0 +1,231 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/server"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const postgresImage = "postgres:16-alpine"
+
+// spoken is what the fake whisper.cpp servers hear in every file, one segment per sentence.
+var spoken = []struct {
+	start, end float64
+	text       string
+}{
+	{start: 0, end: 1.5, text: "Welcome to the pricing review."},
+	{start: 1.5, end: 3, text: "The new plans start next month."},
+}
+
+// env is the stack every scenario runs against, the config is loaded once per process so the
+// scenarios share it.
+var env *stack
+
+func TestMain(m *testing.M) {
+	var err error
+	if env, err = startStack(); err != nil {
+		log.Fatalf("Failed to start the integration stack: %v\n", err)
+	}
+	code := m.Run()
+	env.stop()
+	os.Exit(code)
+}
+
+// stack is Postgres, a healthy and a broken whisper.cpp server and v2t serve using them.
+type stack struct {
+	// api is the base URL of the HTTP API of v2t serve.
+	api string
+	// healthy and broken count the files sent to each whisper.cpp server.
+	healthy, broken *atomic.Int32
+
+	stops []func()
+}
+
+func startStack() (s *stack, err error) {
+	if _, err = exec.LookPath("ffprobe"); err != nil {
+		return nil, fmt.Errorf("the scenarios need ffprobe: %v", err)
+	}
+	s = &stack{healthy: new(atomic.Int32), broken: new(atomic.Int32)}
+	defer func() {
+		if err != nil {
+			s.stop()
+		}
+	}()
+
+	ctx := context.Background()
+	pg, err := postgres.RunContainer(ctx,
+		testcontainers.WithImage(postgresImage),
+		postgres.WithDatabase("v2t"),
+		postgres.WithUsername("v2t"),
+		postgres.WithPassword("v2t"),
+		// the server restarts once after the init scripts ran
+		testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).WithStartupTimeout(2*time.Minute)),
+	)
+	if err != nil {
+		return s, fmt.Errorf("start postgres failed: %v", err)
+	}
+	s.stops = append(s.stops, func() { pg.Terminate(context.Background()) })
+	dsn, err := pg.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return s, err
+	}
+
+	healthy := httptest.NewServer(fakeWhisperServer(s.healthy, http.StatusOK))
+	broken := httptest.NewServer(fakeWhisperServer(s.broken, http.StatusServiceUnavailable))
+	s.stops = append(s.stops, healthy.Close, broken.Close)
+
+	dir, err := os.MkdirTemp("", "v2t-integration")
+	if err != nil {
+		return s, err
+	}
+	s.stops = append(s.stops, func() { os.RemoveAll(dir) })
+	if err = writeConfig(dir, dsn, broken.URL, healthy.URL); err != nil {
+		return s, err
+	}
+	config.SetConfigFile(filepath.Join(dir, "config.yaml"))
+
+	addr, err := freeAddr()
+	if err != nil {
+		return s, err
+	}
+	serveCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var serveErr error
+	go func() {
+		defer close(done)
+		serveErr = app.InitializeServer().Run(serveCtx, server.Options{
+			Addr:      addr,
+			UploadDir: filepath.Join(dir, "uploads"),
+			Workers:   2,
+		})
+	}()
+	s.stops = append(s.stops, func() {
+		cancel()
+		<-done
+	})
+	s.api = "http://" + addr
+	return s, waitHealthy(s.api, done, &serveErr)
+}
+
+// stop stops the services in the reverse order they started.
+func (s *stack) stop() {
+	for i := len(s.stops) - 1; i >= 0; i-- {
+		s.stops[i]()
+	}
+}
+
+// fakeWhisperServer answers /inference like a whisper.cpp server with status, and with the spoken
+// segments when status is OK. It counts the files it received in files.
+func fakeWhisperServer(files *atomic.Int32, status int) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	mux.HandleFunc("/inference", func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := r.FormFile("file"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		files.Add(1)
+		if status != http.StatusOK {
+			http.Error(w, `{"error":"model loading"}`, status)
+			return
+		}
+
+		type segment struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+		}
+		resp := struct {
+			Language string    `json:"language"`
+			Duration float64   `json:"duration"`
+			Text     string    `json:"text"`
+			Segments []segment `json:"segments"`
+		}{Language: "en", Duration: spoken[len(spoken)-1].end}
+		for _, s := range spoken {
+			resp.Text += " " + s.text
+			resp.Segments = append(resp.Segments, segment{Start: s.start, End: s.end, Text: " " + s.text})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
+
+// writeConfig writes the config.yaml and providers.yaml of v2t serve to dir: the default database
+// is Postgres at dsn, the files are spread over the whisper.cpp servers in turn.
+func writeConfig(dir string, dsn string, servers ...string) error {
+	cfg := fmt.Sprintf(`databases:
+  instances:
+    default: {driver: postgres, dsn: %q}
+llm:
+  cache: {disabled: true}
+`, dsn)
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(cfg), 0644); err != nil {
+		return err
+	}
+
+	serverList, err := json.Marshal(servers)
+	if err != nil {
+		return err
+	}
+	providers := fmt.Sprintf(`providers:
+  whisper_server:
+    servers: %s
+    balance: round_robin
+`, serverList)
+	return os.WriteFile(filepath.Join(dir, "providers.yaml"), []byte(providers), 0644)
+}
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+// waitHealthy waits until the API at base answers, or fails with serveErr when done is closed
+// because the server stopped.
+func waitHealthy(base string, done <-chan struct{}, serveErr *error) error {
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-done:
+			return fmt.Errorf("v2t serve stopped: %v", *serveErr)
+		default:
+		}
+		if resp, err := http.Get(base + "/healthz"); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("v2t serve didn't become healthy within 30s")
+}
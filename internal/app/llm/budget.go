@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultContextTokens is the context window assumed when llm.context_tokens isn't set, small local
+// models take at least that much.
+const DefaultContextTokens = 4096
+
+// EstimateTokens estimates the tokens of text without the tokenizer of the model: a Chinese, Japanese
+// or Korean character is about one token, other text about four bytes per token.
+func EstimateTokens(text string) int {
+	var c tokenCount
+	c.add(text)
+	return c.tokens()
+}
+
+// tokenCount adds up the text EstimateTokens estimates.
+type tokenCount struct {
+	cjk   int
+	bytes int
+}
+
+func (c *tokenCount) add(text string) {
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			c.cjk++
+		} else {
+			c.bytes += utf8.RuneLen(r)
+		}
+	}
+}
+
+func (c *tokenCount) tokens() int {
+	return c.cjk + (c.bytes+3)/4
+}
+
+// Budget returns the tokens of transcript feature sends in one request: llm.budgets of feature,
+// otherwise half the context window, the rest is left to the prompt and the reply, which is as long
+// as the text for translations.
+func Budget(cfg config.LLMConfig, feature string) int {
+	if budget := cfg.Budgets[feature]; budget > 0 {
+		return budget
+	}
+	contextTokens := cfg.ContextTokens
+	if contextTokens <= 0 {
+		contextTokens = DefaultContextTokens
+	}
+	return contextTokens / 2
+}
+
+// Split cuts text into parts of at most budget tokens, at line ends where it can, then at spaces,
+// and within words only when a word alone is over budget.
+func Split(text string, budget int) []string {
+	var parts []string
+	var part strings.Builder
+	var count tokenCount
+	flush := func() {
+		if p := strings.TrimSpace(part.String()); p != "" {
+			parts = append(parts, p)
+		}
+		part.Reset()
+		count = tokenCount{}
+	}
+	add := func(piece string) {
+		next := count
+		next.add(piece)
+		if part.Len() > 0 && next.tokens() > budget {
+			flush()
+			next = tokenCount{}
+			next.add(piece)
+		}
+		part.WriteString(piece)
+		count = next
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if EstimateTokens(line) <= budget {
+			add(line)
+			continue
+		}
+		for _, word := range strings.SplitAfter(line, " ") {
+			if EstimateTokens(word) <= budget {
+				add(word)
+				continue
+			}
+			for _, r := range word {
+				add(string(r))
+			}
+		}
+	}
+	flush()
+	return parts
+}
+
+// Condense shortens text to at most budget tokens with m, map-reduce style: the parts of text that
+// fit a request are condensed one by one, then their joined results again until they fit, so long
+// transcripts are summarized hierarchically instead of cut. prompt renders the request condensing
+// one part, onLevel, which may be nil, learns how many parts each level has. Text within budget is
+// returned as it is.
+func Condense(ctx context.Context, m Model, text string, budget int, prompt func(part string) (string, error), onLevel func(level int, parts int)) (string, error) {
+	for level := 1; EstimateTokens(text) > budget; level++ {
+		parts := Split(text, budget)
+		if onLevel != nil {
+			onLevel(level, len(parts))
+		}
+		condensed := make([]string, len(parts))
+		for i, part := range parts {
+			content, err := prompt(part)
+			if err != nil {
+				return "", err
+			}
+			reply, err := m.Complete(ctx, []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: content}})
+			if err != nil {
+				return "", fmt.Errorf("condense part %d of %d failed: %v", i+1, len(parts), err)
+			}
+			condensed[i] = strings.TrimSpace(reply)
+		}
+
+		shorter := strings.Join(condensed, "\n")
+		if EstimateTokens(shorter) >= EstimateTokens(text) {
+			return "", fmt.Errorf("%s didn't shorten the transcript, raise the budget or use a model with a larger context window", m.Name())
+		}
+		text = shorter
+	}
+	return text, nil
+}
+
+// Usage counts the requests sent to a model and their estimated tokens.
+type Usage struct {
+	Requests     int `json:"requests"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// Cached requests were answered by the cache, they cost nothing and aren't in the other counts.
+	Cached int `json:"cached"`
+}
+
+// Cost estimates the price of u with the prices per million tokens of cfg, zero without prices.
+func (u Usage) Cost(cfg config.LLMConfig) float64 {
+	return (float64(u.InputTokens)*cfg.InputPrice + float64(u.OutputTokens)*cfg.OutputPrice) / 1e6
+}
+
+// Metered is a model counting the usage of its requests.
+type Metered struct {
+	Model
+	mu    sync.Mutex
+	usage Usage
+}
+
+// NewMetered returns m counting its usage.
+func NewMetered(m Model) *Metered {
+	return &Metered{Model: m}
+}
+
+func (m *Metered) Complete(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	if c, ok := m.Model.(*Cached); ok && c.Has(messages) {
+		reply, err := c.Complete(ctx, messages)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.usage.Cached++
+		return reply, err
+	}
+
+	var input int
+	for _, message := range messages {
+		input += EstimateTokens(message.Content)
+	}
+	reply, err := m.Model.Complete(ctx, messages)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage.Requests++
+	m.usage.InputTokens += input
+	m.usage.OutputTokens += EstimateTokens(reply)
+	return reply, err
+}
+
+// Usage returns the usage of the requests so far.
+func (m *Metered) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
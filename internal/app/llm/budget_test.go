@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "hello world!", want: 3},
+		{text: "你好世界", want: 4},
+		{text: "hi 你好", want: 3},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	cfg := config.LLMConfig{ContextTokens: 16000, Budgets: map[string]int{"chapters": 12000}}
+	if got := Budget(cfg, "chapters"); got != 12000 {
+		t.Errorf("Budget(chapters) = %d, want the configured 12000", got)
+	}
+	if got := Budget(cfg, "summary"); got != 8000 {
+		t.Errorf("Budget(summary) = %d, want half the context window", got)
+	}
+	if got := Budget(config.LLMConfig{}, "summary"); got != DefaultContextTokens/2 {
+		t.Errorf("Budget() without a context window = %d, want half the default", got)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		budget int
+		want   []string
+	}{
+		{name: "fits", text: "0:00 one\n0:05 two\n", budget: 10, want: []string{"0:00 one\n0:05 two"}},
+		{name: "at line ends", text: "0:00 one two\n0:05 three four\n0:10 five\n", budget: 8, want: []string{"0:00 one two\n0:05 three four", "0:10 five"}},
+		{name: "long line at spaces", text: "aaaa bbbb cccc dddd", budget: 3, want: []string{"aaaa bbbb", "cccc dddd"}},
+		{name: "cjk within words", text: "一二三四五", budget: 2, want: []string{"一二", "三四", "五"}},
+		{name: "empty", text: "\n", budget: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.text, tt.budget)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() = %q, want %q", got, tt.want)
+			}
+			for _, part := range got {
+				if EstimateTokens(part) > tt.budget {
+					t.Errorf("part %q is over the budget of %d", part, tt.budget)
+				}
+			}
+		})
+	}
+}
+
+// firstWordModel condenses every part to its first word, or replies with the prompt as it is.
+type firstWordModel struct {
+	shorten bool
+	err     error
+}
+
+func (m *firstWordModel) Name() string { return "fake/first-word" }
+
+func (m *firstWordModel) Complete(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	content := messages[len(messages)-1].Content
+	if m.err != nil {
+		return "", m.err
+	}
+	if !m.shorten {
+		return content, nil
+	}
+	return strings.Fields(content)[0], nil
+}
+
+func TestCondense(t *testing.T) {
+	long := strings.Repeat("word ", 80)
+	tests := []struct {
+		name       string
+		model      *firstWordModel
+		text       string
+		want       string
+		wantLevels []int
+		wantErr    string
+	}{
+		{name: "within budget", model: &firstWordModel{shorten: true}, text: "short text", want: "short text"},
+		{name: "map reduce", model: &firstWordModel{shorten: true}, text: long, want: "word\nword", wantLevels: []int{10, 2}},
+		{name: "not shortened", model: &firstWordModel{}, text: long, wantErr: "didn't shorten", wantLevels: []int{10}},
+		{name: "model fails", model: &firstWordModel{err: errors.New("down")}, text: long, wantErr: "condense part 1 of 10 failed: down", wantLevels: []int{10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var levels []int
+			got, err := Condense(context.Background(), tt.model, tt.text, 10, func(part string) (string, error) {
+				return part, nil
+			}, func(level int, parts int) {
+				levels = append(levels, parts)
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Condense() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("Condense() = %q, %v, want %q", got, err, tt.want)
+			}
+			if !reflect.DeepEqual(levels, tt.wantLevels) {
+				t.Errorf("parts per level = %v, want %v", levels, tt.wantLevels)
+			}
+		})
+	}
+}
+
+func TestMetered(t *testing.T) {
+	m := NewMetered(&firstWordModel{shorten: true})
+	for i := 0; i < 2; i++ {
+		if _, err := m.Complete(context.Background(), []openai.ChatCompletionMessage{{Content: "abcdefgh abcdefgh"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	usage := m.Usage()
+	if want := (Usage{Requests: 2, InputTokens: 10, OutputTokens: 4}); usage != want {
+		t.Errorf("Usage() = %+v, want %+v", usage, want)
+	}
+	if cost := usage.Cost(config.LLMConfig{InputPrice: 1000, OutputPrice: 2000}); cost != 0.018 {
+		t.Errorf("Cost() = %v, want 0.018", cost)
+	}
+}
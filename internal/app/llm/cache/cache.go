@@ -0,0 +1,165 @@
+// Package cache keeps the responses of language models and embedding requests on disk, keyed by the
+// model, the normalized input and the parameters of the request. Re-running a feature over data that
+// didn't change costs nothing, until the response expires or is evicted to stay within the size limit.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/util/files"
+	"time"
+)
+
+const (
+	// DefaultTTL is how long a response is used when llm.cache.ttl isn't set.
+	DefaultTTL = 30 * 24 * time.Hour
+	// DefaultMaxMB bounds the cache when llm.cache.max_mb isn't set.
+	DefaultMaxMB = 100
+)
+
+// Store keeps responses as JSON files in a directory, one per key.
+type Store struct {
+	dir      string
+	ttl      time.Duration
+	maxBytes int64
+	// now is replaced in tests.
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+// New returns a store in dir using responses for ttl and removing the oldest ones once the store
+// is larger than maxBytes.
+func New(dir string, ttl time.Duration, maxBytes int64) *Store {
+	return &Store{dir: dir, ttl: ttl, maxBytes: maxBytes, now: time.Now}
+}
+
+// FromConfig returns the store cfg sets up, nil when the cache is disabled.
+func FromConfig(cfg config.LLMCacheConfig) *Store {
+	if cfg.Disabled {
+		return nil
+	}
+	dir, ttl, maxMB := cfg.Dir, cfg.TTL, cfg.MaxMB
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxMB <= 0 {
+		maxMB = DefaultMaxMB
+	}
+	return New(dir, ttl, int64(maxMB)<<20)
+}
+
+// DefaultDir is the cache of the responses in the user cache directory.
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "v2t", "llm")
+}
+
+// Key identifies a request: its kind such as completion or embedding, the model, the input and the
+// parameters. Whitespace of the input is normalized, so reformatting it alone doesn't miss the cache.
+func Key(kind string, model string, input string, params ...string) string {
+	h := sha256.New()
+	for _, field := range append([]string{kind, model, strings.Join(strings.Fields(input), " ")}, params...) {
+		// the length keeps the fields apart
+		fmt.Fprintf(h, "%d:%s\n", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get reads the response of key into v, it reports false when there is none or it expired.
+func (s *Store) Get(key string, v interface{}) bool {
+	path := s.path(key)
+	info, err := os.Stat(path)
+	if err != nil || !files.IsComplete(path) {
+		return false
+	}
+	if s.now().Sub(info.ModTime()) > s.ttl {
+		os.Remove(path)
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		logging.L().Warn("Ignoring corrupt cached response", "path", path, "error", err)
+		return false
+	}
+	return true
+}
+
+// Put stores v as the response of key, then evicts what is over the limits. Errors are logged, a
+// response that isn't cached is only requested again.
+func (s *Store) Put(key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = files.WriteFileAtomic(s.path(key), data, 0644)
+	}
+	if err != nil {
+		logging.L().Error("Error caching response", "dir", s.dir, "error", err)
+		return
+	}
+	if err = s.Prune(); err != nil {
+		logging.L().Error("Error pruning response cache", "dir", s.dir, "error", err)
+	}
+}
+
+// Prune removes the expired responses, then the oldest ones until the store is within its size.
+func (s *Store) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	var total int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if s.now().Sub(info.ModTime()) > s.ttl {
+			os.Remove(path)
+			continue
+		}
+		entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	for _, e := range entries {
+		if total <= s.maxBytes {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= e.size
+	}
+	return nil
+}
+
+func (s *Store) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
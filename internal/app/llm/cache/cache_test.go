@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	base := Key("completion", "ollama/llama3.1", "Summarize this.\n\nWe talked.")
+	tests := []struct {
+		name string
+		key  string
+		same bool
+	}{
+		{name: "whitespace normalized", key: Key("completion", "ollama/llama3.1", "  Summarize this. We   talked.\n"), same: true},
+		{name: "other model", key: Key("completion", "openai/gpt-4o", "Summarize this.\n\nWe talked.")},
+		{name: "other kind", key: Key("embedding", "ollama/llama3.1", "Summarize this.\n\nWe talked.")},
+		{name: "other params", key: Key("completion", "ollama/llama3.1", "Summarize this.\n\nWe talked.", "temperature=0.2")},
+		{name: "fields kept apart", key: Key("completion", "ollama/llama3.1Summarize", "this.\n\nWe talked.")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.key == base) != tt.same {
+				t.Errorf("Key() == base is %v, want %v", tt.key == base, tt.same)
+			}
+		})
+	}
+}
+
+func TestStore_GetPut(t *testing.T) {
+	now := time.Now()
+	s := New(t.TempDir(), time.Hour, 1<<20)
+	s.now = func() time.Time { return now }
+
+	var got string
+	if s.Get("a", &got) {
+		t.Fatalf("Get() of an empty store = %q, want nothing", got)
+	}
+	s.Put("a", "reply")
+	if !s.Get("a", &got) || got != "reply" {
+		t.Errorf("Get() = %q, want the stored reply", got)
+	}
+
+	s.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if s.Get("a", &got) {
+		t.Errorf("Get() of an expired response succeeded")
+	}
+	if _, err := os.Stat(s.path("a")); !os.IsNotExist(err) {
+		t.Errorf("expired response was kept, stat error = %v", err)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	dir := t.TempDir()
+	reply := strings.Repeat("x", 100)
+	s := New(dir, time.Hour, 250)
+
+	old := time.Now().Add(-time.Minute)
+	for i, key := range []string{"oldest", "older", "newest"} {
+		s.Put(key, reply)
+		modTime := old.Add(time.Duration(i) * time.Second)
+		os.Chtimes(s.path(key), modTime, modTime)
+	}
+	if err := s.Prune(); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	var got string
+	for key, want := range map[string]bool{"oldest": false, "older": true, "newest": true} {
+		if s.Get(key, &got) != want {
+			t.Errorf("Get(%s) = %v after pruning, want %v", key, !want, want)
+		}
+	}
+	if paths, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(paths) != 2 {
+		t.Errorf("%d responses kept, want 2", len(paths))
+	}
+}
@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"tiktok-whisper/internal/app/llm/cache"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Cached is a model answering the conversations it completed before from a cache, keyed by the name
+// of the model and the messages.
+type Cached struct {
+	Model
+	store *cache.Store
+}
+
+// NewCached returns m answering from store.
+func NewCached(m Model, store *cache.Store) *Cached {
+	return &Cached{Model: m, store: store}
+}
+
+func (c *Cached) Complete(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	key := c.key(messages)
+	var reply string
+	if c.store.Get(key, &reply) {
+		return reply, nil
+	}
+	reply, err := c.Model.Complete(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	c.store.Put(key, reply)
+	return reply, nil
+}
+
+// Has reports whether the reply to messages is cached.
+func (c *Cached) Has(messages []openai.ChatCompletionMessage) bool {
+	var reply string
+	return c.store.Get(c.key(messages), &reply)
+}
+
+func (c *Cached) key(messages []openai.ChatCompletionMessage) string {
+	var input strings.Builder
+	for _, m := range messages {
+		input.WriteString(m.Role + ": " + m.Content + "\n")
+	}
+	return cache.Key("completion", c.Name(), input.String())
+}
@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"tiktok-whisper/internal/app/llm/cache"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// countingModel replies with the number of requests it got, or fails.
+type countingModel struct {
+	calls int
+	err   error
+}
+
+func (m *countingModel) Name() string { return "fake/counting" }
+
+func (m *countingModel) Complete(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	m.calls++
+	if m.err != nil {
+		return "", m.err
+	}
+	return "reply", nil
+}
+
+func TestCached(t *testing.T) {
+	inner := &countingModel{}
+	metered := NewMetered(NewCached(inner, cache.New(t.TempDir(), time.Hour, 1<<20)))
+	summarize := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Summarize: we talked."}}
+	title := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Title: we talked."}}
+
+	for _, messages := range [][]openai.ChatCompletionMessage{summarize, summarize, title} {
+		if reply, err := metered.Complete(context.Background(), messages); err != nil || reply != "reply" {
+			t.Fatalf("Complete() = %q, %v, want the reply", reply, err)
+		}
+	}
+	if inner.calls != 2 {
+		t.Errorf("model called %d times, want 2, the repeated request is cached", inner.calls)
+	}
+	if usage := metered.Usage(); usage.Requests != 2 || usage.Cached != 1 {
+		t.Errorf("Usage() = %+v, want 2 requests and 1 cached", usage)
+	}
+
+	failing := NewCached(&countingModel{err: errors.New("down")}, cache.New(t.TempDir(), time.Hour, 1<<20))
+	if _, err := failing.Complete(context.Background(), title); err == nil {
+		t.Fatalf("Complete() of a failing model succeeded")
+	}
+	if failing.Has(title) {
+		t.Errorf("a failed request was cached")
+	}
+}
@@ -0,0 +1,135 @@
+// Package llm sends conversations to the language model of chat, generate and the llm translation
+// backend: the OpenAI API, a local Ollama server or any server with the OpenAI chat API, such as
+// llama.cpp or LM Studio. With a local model no transcript leaves the machine.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/config/secrets"
+	"tiktok-whisper/internal/app/llm/cache"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Model completes conversations with a language model.
+type Model interface {
+	// Name of the backend and model, e.g. ollama/llama3.1.
+	Name() string
+	// Complete sends the conversation and returns the content of the reply.
+	Complete(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error)
+}
+
+// Backends are the backends New creates.
+var Backends = []string{"openai", "ollama", "openai_compatible"}
+
+// OllamaURL is the OpenAI compatible API of a local Ollama server.
+const OllamaURL = "http://localhost:11434/v1"
+
+// DefaultTimeout bounds a completion when none is configured, local models on a laptop are slow.
+const DefaultTimeout = 5 * time.Minute
+
+// Chat is a model behind the OpenAI chat completion API, which Ollama and the other local servers
+// offer as well.
+type Chat struct {
+	client  *openai.Client
+	backend string
+	model   string
+}
+
+// New creates the model configured in config.yaml.
+func New(cfg config.LLMConfig) (*Chat, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	var token string
+	switch cfg.Backend {
+	case "openai", "":
+		cfg.Backend = "openai"
+		if cfg.Model == "" {
+			cfg.Model = openai.GPT3Dot5Turbo
+		}
+		var err error
+		if token, err = secrets.Key("openai"); err != nil {
+			return nil, err
+		}
+	case "ollama":
+		if cfg.URL == "" {
+			cfg.URL = OllamaURL
+		}
+	case "openai_compatible":
+		if cfg.URL == "" {
+			return nil, errors.New("llm.url is required by the openai_compatible backend")
+		}
+		if cfg.KeyEnv != "" {
+			token = os.Getenv(cfg.KeyEnv)
+		}
+	default:
+		return nil, fmt.Errorf("unknown llm backend %q, supported: %s", cfg.Backend, strings.Join(Backends, ", "))
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("llm.model is required by the %s backend", cfg.Backend)
+	}
+
+	clientConfig := openai.DefaultConfig(token)
+	if cfg.URL != "" {
+		clientConfig.BaseURL = strings.TrimSuffix(cfg.URL, "/")
+	}
+	clientConfig.HTTPClient = &http.Client{Timeout: timeout}
+	return &Chat{client: openai.NewClientWithConfig(clientConfig), backend: cfg.Backend, model: cfg.Model}, nil
+}
+
+func (c *Chat) Name() string {
+	return c.backend + "/" + c.model
+}
+
+func (c *Chat) Complete(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	resp, err := c.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{Model: c.model, Messages: messages})
+	if err != nil {
+		return "", fmt.Errorf("chat completion with %s failed: %v", c.Name(), err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("empty chat completion response")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+var (
+	defaultModel Model
+	defaultErr   error
+	defaultOnce  sync.Once
+)
+
+// Default returns the model of config.yaml, created once. It answers from the cache of llm.cache
+// unless that is disabled.
+func Default() (Model, error) {
+	defaultOnce.Do(func() {
+		var c *Chat
+		if c, defaultErr = New(config.Get().LLM); defaultErr != nil {
+			return
+		}
+		defaultModel = c
+		if store := cache.FromConfig(config.Get().LLM.Cache); store != nil {
+			defaultModel = NewCached(c, store)
+		}
+	})
+	return defaultModel, defaultErr
+}
+
+// Complete sends messages to the Default model.
+func Complete(messages []openai.ChatCompletionMessage) (string, error) {
+	m, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return m.Complete(context.Background(), messages)
+}
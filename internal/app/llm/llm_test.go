@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestNew(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "key")
+	tests := []struct {
+		name     string
+		cfg      config.LLMConfig
+		wantName string
+		wantErr  string
+	}{
+		{name: "openai by default", cfg: config.LLMConfig{}, wantName: "openai/" + openai.GPT3Dot5Turbo},
+		{name: "ollama", cfg: config.LLMConfig{Backend: "ollama", Model: "llama3.1"}, wantName: "ollama/llama3.1"},
+		{name: "ollama without a model", cfg: config.LLMConfig{Backend: "ollama"}, wantErr: "llm.model"},
+		{name: "openai_compatible without a url", cfg: config.LLMConfig{Backend: "openai_compatible", Model: "qwen2"}, wantErr: "llm.url"},
+		{name: "unknown backend", cfg: config.LLMConfig{Backend: "claude"}, wantErr: "unknown llm backend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || c.Name() != tt.wantName {
+				t.Errorf("New() = %v, %v, want %s", c, err, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestChat_Complete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer local" || body.Model != "llama3.1" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if body.Messages[len(body.Messages)-1].Content == "empty" {
+			w.Write([]byte(`{"choices": []}`))
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Hello"}}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("LOCAL_LLM_KEY", "local")
+
+	c, err := New(config.LLMConfig{Backend: "openai_compatible", Model: "llama3.1", URL: server.URL + "/v1/", KeyEnv: "LOCAL_LLM_KEY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Hi"}}
+	if got, err := c.Complete(context.Background(), messages); err != nil || got != "Hello" {
+		t.Errorf("Complete() = %q, %v, want Hello", got, err)
+	}
+	messages[0].Content = "empty"
+	if _, err = c.Complete(context.Background(), messages); err == nil {
+		t.Errorf("Complete() without choices succeeded, want an error")
+	}
+}
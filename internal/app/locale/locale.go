@@ -0,0 +1,154 @@
+package locale
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale describes how generated artifacts format dates and numbers for a reader.
+type Locale struct {
+	// Tag is the BCP 47 language tag, e.g. zh-CN.
+	Tag string
+	// DateLayout and TimeLayout are Go time layouts.
+	DateLayout string
+	TimeLayout string
+	Decimal    string
+	Group      string
+	// RTL is set for right-to-left scripts.
+	RTL bool
+}
+
+// Default is used when neither the config nor the environment name a known locale.
+var Default = locales["en-US"]
+
+var locales = map[string]Locale{
+	"en-US": {Tag: "en-US", DateLayout: "01/02/2006", TimeLayout: "3:04 PM", Decimal: ".", Group: ","},
+	"en-GB": {Tag: "en-GB", DateLayout: "02/01/2006", TimeLayout: "15:04", Decimal: ".", Group: ","},
+	"zh-CN": {Tag: "zh-CN", DateLayout: "2006年1月2日", TimeLayout: "15:04", Decimal: ".", Group: ","},
+	"zh-TW": {Tag: "zh-TW", DateLayout: "2006/1/2", TimeLayout: "15:04", Decimal: ".", Group: ","},
+	"ja-JP": {Tag: "ja-JP", DateLayout: "2006/01/02", TimeLayout: "15:04", Decimal: ".", Group: ","},
+	"de-DE": {Tag: "de-DE", DateLayout: "02.01.2006", TimeLayout: "15:04", Decimal: ",", Group: "."},
+	"fr-FR": {Tag: "fr-FR", DateLayout: "02/01/2006", TimeLayout: "15:04", Decimal: ",", Group: " "},
+	"es-ES": {Tag: "es-ES", DateLayout: "02/01/2006", TimeLayout: "15:04", Decimal: ",", Group: "."},
+	"ru-RU": {Tag: "ru-RU", DateLayout: "02.01.2006", TimeLayout: "15:04", Decimal: ",", Group: " "},
+	"ar-SA": {Tag: "ar-SA", DateLayout: "02/01/2006", TimeLayout: "15:04", Decimal: "٫", Group: "٬", RTL: true},
+	"he-IL": {Tag: "he-IL", DateLayout: "02.01.2006", TimeLayout: "15:04", Decimal: ".", Group: ",", RTL: true},
+	"fa-IR": {Tag: "fa-IR", DateLayout: "2006/01/02", TimeLayout: "15:04", Decimal: "٫", Group: "٬", RTL: true},
+}
+
+// Get returns the locale of tag, matching first the full tag and then only its language,
+// so "de" and "de_AT.UTF-8" both resolve to de-DE. ok is false when nothing matches.
+func Get(tag string) (Locale, bool) {
+	tag = Normalize(tag)
+	if l, ok := locales[tag]; ok {
+		return l, true
+	}
+
+	lang := strings.SplitN(tag, "-", 2)[0]
+	if lang == "" {
+		return Default, false
+	}
+	var match *Locale
+	for key, l := range locales {
+		if strings.SplitN(key, "-", 2)[0] == lang && (match == nil || key < match.Tag) {
+			l := l
+			match = &l
+		}
+	}
+	if match == nil {
+		return Default, false
+	}
+	return *match, true
+}
+
+// Normalize turns POSIX locale names like zh_CN.UTF-8 into language tags like zh-CN.
+func Normalize(tag string) string {
+	tag = strings.SplitN(tag, ".", 2)[0]
+	tag = strings.SplitN(tag, "@", 2)[0]
+	parts := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+	parts[0] = strings.ToLower(parts[0])
+	if len(parts) > 1 {
+		parts[1] = strings.ToUpper(parts[1])
+	}
+	return strings.Join(parts, "-")
+}
+
+// EnvTag returns the language tag named by LC_ALL, LC_MESSAGES or LANG, in that order,
+// it is empty when none is set.
+func EnvTag() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(key); v != "" && v != "C" && v != "POSIX" {
+			return Normalize(v)
+		}
+	}
+	return ""
+}
+
+// FromEnv returns the locale named by the environment, see EnvTag.
+func FromEnv() (Locale, bool) {
+	tag := EnvTag()
+	if tag == "" {
+		return Default, false
+	}
+	return Get(tag)
+}
+
+// Resolve returns the first known locale of tags, then the environment's locale, then Default.
+func Resolve(tags ...string) Locale {
+	for _, tag := range tags {
+		if tag == "" {
+			continue
+		}
+		if l, ok := Get(tag); ok {
+			return l
+		}
+	}
+	l, _ := FromEnv()
+	return l
+}
+
+// Language returns the language subtag, e.g. zh for zh-CN.
+func (l Locale) Language() string {
+	return strings.SplitN(l.Tag, "-", 2)[0]
+}
+
+// Direction returns the value of the HTML dir attribute.
+func (l Locale) Direction() string {
+	if l.RTL {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+func (l Locale) FormatDate(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
+
+func (l Locale) FormatDateTime(t time.Time) string {
+	return t.Format(l.DateLayout + " " + l.TimeLayout)
+}
+
+// FormatNumber renders f with the given number of decimals and the locale's separators.
+func (l Locale) FormatNumber(f float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	var sb strings.Builder
+	if f < 0 && strings.Trim(s, "0.") != "" {
+		sb.WriteString("-")
+	}
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			sb.WriteString(l.Group)
+		}
+		sb.WriteRune(r)
+	}
+	if fracPart != "" {
+		sb.WriteString(l.Decimal)
+		sb.WriteString(fracPart)
+	}
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name   string
+		tag    string
+		want   string
+		wantOk bool
+	}{
+		{name: "exact", tag: "zh-CN", want: "zh-CN", wantOk: true},
+		{name: "posix", tag: "de_DE.UTF-8", want: "de-DE", wantOk: true},
+		{name: "language only", tag: "fr", want: "fr-FR", wantOk: true},
+		{name: "other region", tag: "en_AU", want: "en-GB", wantOk: true},
+		{name: "unknown", tag: "xx-YY", want: "en-US", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Get(tt.tag)
+			if got.Tag != tt.want || ok != tt.wantOk {
+				t.Errorf("Get(%q) = %s, %v, want %s, %v", tt.tag, got.Tag, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestLocale_FormatNumber(t *testing.T) {
+	tests := []struct {
+		tag      string
+		f        float64
+		decimals int
+		want     string
+	}{
+		{tag: "en-US", f: 1234567.891, decimals: 2, want: "1,234,567.89"},
+		{tag: "de-DE", f: 1234.5, decimals: 1, want: "1.234,5"},
+		{tag: "zh-CN", f: 42, decimals: 0, want: "42"},
+		{tag: "en-US", f: -1000, decimals: 0, want: "-1,000"},
+		{tag: "en-US", f: -0.001, decimals: 1, want: "0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag+"/"+tt.want, func(t *testing.T) {
+			l, _ := Get(tt.tag)
+			if got := l.FormatNumber(tt.f, tt.decimals); got != tt.want {
+				t.Errorf("FormatNumber() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocale_FormatDate(t *testing.T) {
+	d := time.Date(2024, 3, 9, 14, 5, 0, 0, time.UTC)
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{tag: "en-US", want: "03/09/2024 2:05 PM"},
+		{tag: "zh-CN", want: "2024年3月9日 14:05"},
+		{tag: "de-DE", want: "09.03.2024 14:05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			l, _ := Get(tt.tag)
+			if got := l.FormatDateTime(d); got != tt.want {
+				t.Errorf("FormatDateTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Package logging is the structured logger of v2t. The converters, providers and storage log
+// through L, which is zap writing human-readable lines to stderr unless Setup configured it
+// otherwise, e.g. JSON at debug level from the CLI flags.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger logs a message with context as alternating keys and values,
+// e.g. Info("Transcription saved", "file", path, "segments", 12).
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+	// With returns a logger adding keysAndValues to every message.
+	With(keysAndValues ...interface{}) Logger
+}
+
+// Formats of the log lines.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Options configure the zap logger of New.
+type Options struct {
+	// Level is debug, info (default), warn or error.
+	Level string
+	// Format is FormatConsole (default) or FormatJSON, one object per line.
+	Format string
+	// Output receives the lines, stderr when nil.
+	Output io.Writer
+}
+
+// New returns a zap logger configured by opts.
+func New(opts Options) (Logger, error) {
+	level := zapcore.InfoLevel
+	if opts.Level != "" {
+		var err error
+		if level, err = zapcore.ParseLevel(opts.Level); err != nil {
+			return nil, fmt.Errorf("unknown log level %q, use debug, info, warn or error", opts.Level)
+		}
+	}
+
+	encoding := zap.NewProductionEncoderConfig()
+	encoding.EncodeTime = zapcore.ISO8601TimeEncoder
+	var encoder zapcore.Encoder
+	switch strings.ToLower(opts.Format) {
+	case "", FormatConsole:
+		encoding.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoding)
+	case FormatJSON:
+		encoder = zapcore.NewJSONEncoder(encoding)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, use %s or %s", opts.Format, FormatConsole, FormatJSON)
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	core := zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(output)), level)
+	return &zapLogger{sugar: zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1)).Sugar()}, nil
+}
+
+// zapLogger is the Logger of New.
+type zapLogger struct {
+	sugar *zap.SugaredLogger
+}
+
+func (l *zapLogger) Debug(msg string, kv ...interface{}) { l.sugar.Debugw(msg, kv...) }
+func (l *zapLogger) Info(msg string, kv ...interface{})  { l.sugar.Infow(msg, kv...) }
+func (l *zapLogger) Warn(msg string, kv ...interface{})  { l.sugar.Warnw(msg, kv...) }
+func (l *zapLogger) Error(msg string, kv ...interface{}) { l.sugar.Errorw(msg, kv...) }
+
+func (l *zapLogger) With(kv ...interface{}) Logger {
+	return &zapLogger{sugar: l.sugar.With(kv...)}
+}
+
+// Nop returns a logger discarding every message.
+func Nop() Logger {
+	return &zapLogger{sugar: zap.NewNop().Sugar()}
+}
+
+var (
+	global      atomic.Value
+	defaultOnce sync.Once
+	restoreStd  func()
+	stdMu       sync.Mutex
+)
+
+// holder lets loggers of different types share the atomic.Value.
+type holder struct{ Logger }
+
+// L returns the logger of the process.
+func L() Logger {
+	defaultOnce.Do(func() {
+		if global.Load() == nil {
+			l, _ := New(Options{})
+			global.Store(holder{l})
+		}
+	})
+	return global.Load().(holder).Logger
+}
+
+// SetDefault makes l the logger of L and returns a function restoring the previous one, for tests.
+func SetDefault(l Logger) (restore func()) {
+	previous := L()
+	global.Store(holder{l})
+	return func() { global.Store(holder{previous}) }
+}
+
+// Setup makes a logger configured by opts the logger of L. The standard log package writes
+// through it as well, at info level, so the modules not logging through L keep the same format.
+func Setup(opts Options) error {
+	l, err := New(opts)
+	if err != nil {
+		return err
+	}
+	SetDefault(l)
+
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	if restoreStd != nil {
+		restoreStd()
+	}
+	// the bridge logs with its own caller skip, the one of Logger would point into the log package
+	restoreStd = zap.RedirectStdLog(l.(*zapLogger).sugar.Desugar().WithOptions(zap.AddCallerSkip(-1)))
+	return nil
+}
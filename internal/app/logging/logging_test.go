@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []string
+		wantErr bool
+	}{
+		{name: "console at info", opts: Options{}, want: []string{"\tINFO\tlogging/logging_test.go:", "\tsaved\t{\"file\": \"a.mp3\"}"}},
+		{name: "debug level", opts: Options{Level: "debug"}, want: []string{"\tDEBUG\t", "\tchecking\n", "\tINFO\t"}},
+		{name: "warn level drops info", opts: Options{Level: "WARN"}},
+		{name: "json", opts: Options{Format: "json"}, want: []string{`"level":"info"`, `"msg":"saved"`, `"file":"a.mp3"`}},
+		{name: "unknown level", opts: Options{Level: "loud"}, wantErr: true},
+		{name: "unknown format", opts: Options{Format: "xml"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.opts.Output = &buf
+			l, err := New(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			l.Debug("checking")
+			l.Info("saved", "file", "a.mp3")
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("logged %q, missing %q", buf.String(), want)
+				}
+			}
+			if len(tt.want) == 0 && buf.Len() > 0 {
+				t.Errorf("logged %q, want nothing", buf.String())
+			}
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	var buf bytes.Buffer
+	previous := L()
+	if err := Setup(Options{Format: FormatJSON, Output: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		restoreStd()
+		restoreStd = nil
+		SetDefault(previous)
+	}()
+
+	L().With("provider", "openai").Warn("retrying", "attempt", 2)
+	log.Printf("from the standard logger")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2: %q", len(lines), buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "warn" || entry["provider"] != "openai" || entry["attempt"] != float64(2) {
+		t.Errorf("first line = %v", entry)
+	}
+	if !strings.Contains(entry["caller"].(string), "logging_test.go") {
+		t.Errorf("caller = %v, want the test", entry["caller"])
+	}
+	if !strings.Contains(lines[1], `"msg":"from the standard logger"`) || !strings.Contains(lines[1], "logging_test.go") {
+		t.Errorf("standard log line = %s", lines[1])
+	}
+}
@@ -0,0 +1,150 @@
+// Package mcp serves the transcript archive to LLM agents and IDE assistants over the Model Context
+// Protocol, see https://modelcontextprotocol.io. Requests and responses are JSON-RPC 2.0 messages,
+// one per line, as in the stdio transport.
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"tiktok-whisper/internal/app/repository"
+)
+
+// ProtocolVersion is the MCP revision the server implements, it is offered to clients asking for another one.
+const ProtocolVersion = "2024-11-05"
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// maxMessageBytes limits a single request line.
+const maxMessageBytes = 10 << 20
+
+// Databases returns the database the transcriptions of a user are stored in, *router.Router implements it.
+type Databases interface {
+	ForUser(user string) (repository.TranscriptionDAO, error)
+}
+
+// Server answers MCP requests with the tools of tools.go.
+type Server struct {
+	databases Databases
+	version   string
+}
+
+// NewServer creates a new Server instance, version is reported to clients along with the name v2t.
+func NewServer(databases Databases, version string) *Server {
+	return &Server{databases: databases, version: version}
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve answers the requests read from r on w until r ends. Notifications get no response.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageBytes)
+	encoder := json.NewEncoder(w)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		resp, ok := s.handle(line)
+		if !ok {
+			continue
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return fmt.Errorf("write response failed: %v", err)
+		}
+	}
+	return scanner.Err()
+}
+
+// handle answers one message, it returns false for notifications.
+func (s *Server) handle(message []byte) (response, bool) {
+	resp := response{JSONRPC: "2.0", ID: json.RawMessage("null")}
+
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		resp.Error = &rpcError{Code: codeParseError, Message: err.Error()}
+		return resp, true
+	}
+	if len(req.ID) == 0 {
+		if req.Method != "notifications/initialized" && req.Method != "notifications/cancelled" {
+			log.Printf("Ignoring notification %s\n", req.Method)
+		}
+		return resp, false
+	}
+	resp.ID = req.ID
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{Code: codeInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+		return resp, true
+	}
+
+	result, err := s.call(req.Method, req.Params)
+	if err != nil {
+		resp.Error = err
+		return resp, true
+	}
+	resp.Result = result
+	return resp, true
+}
+
+func (s *Server) call(method string, params json.RawMessage) (interface{}, *rpcError) {
+	switch method {
+	case "initialize":
+		return s.initialize(), nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": tools}, nil
+	case "tools/call":
+		return s.callTool(params)
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("unknown method %s", method)}
+	}
+}
+
+func (s *Server) initialize() interface{} {
+	return map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+		"serverInfo":      map[string]string{"name": "v2t", "version": s.version},
+		"instructions":    "Search, read and count the transcriptions of videos and podcasts stored by v2t.",
+	}
+}
+
+func decodeParams(params json.RawMessage, v interface{}) *rpcError {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+// oneDatabase stores every user in the same database.
+type oneDatabase struct {
+	db repository.TranscriptionDAO
+}
+
+func (o oneDatabase) ForUser(user string) (repository.TranscriptionDAO, error) {
+	return o.db, nil
+}
+
+type testResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result struct {
+		ProtocolVersion string            `json:"protocolVersion"`
+		ServerInfo      map[string]string `json:"serverInfo"`
+		Tools           []tool            `json:"tools"`
+		Content         []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	} `json:"result"`
+	Error *rpcError `json:"error"`
+}
+
+// serve runs a session of requests and returns the responses.
+func serve(t *testing.T, s *Server, requests ...string) []testResponse {
+	t.Helper()
+	var out bytes.Buffer
+	if err := s.Serve(strings.NewReader(strings.Join(requests, "\n")), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var responses []testResponse
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var r testResponse
+		if err := decoder.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, r)
+	}
+	return responses
+}
+
+func TestServer_Serve(t *testing.T) {
+	responses := serve(t, NewServer(oneDatabase{memory.NewMemoryDB()}, "v1.2.3"),
+		`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2025-03-26", "capabilities": {}}}`,
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}`,
+		``,
+		`{"jsonrpc": "2.0", "id": "two", "method": "tools/list"}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "resources/list"}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "ping"}`,
+		`not json`,
+	)
+	if len(responses) != 5 {
+		t.Fatalf("got %d responses, want one per request", len(responses))
+	}
+
+	if r := responses[0]; r.Result.ProtocolVersion != ProtocolVersion || r.Result.ServerInfo["version"] != "v1.2.3" {
+		t.Errorf("initialize = %+v", r.Result)
+	}
+	var names []string
+	for _, tool := range responses[1].Result.Tools {
+		names = append(names, tool.Name)
+		if !json.Valid(tool.InputSchema) {
+			t.Errorf("input schema of %s is not valid JSON", tool.Name)
+		}
+	}
+	if string(responses[1].ID) != `"two"` || strings.Join(names, ",") != "search,fetch-transcript,stats" {
+		t.Errorf("tools/list = %s %v", responses[1].ID, names)
+	}
+	if r := responses[2]; r.Error == nil || r.Error.Code != codeMethodNotFound {
+		t.Errorf("unknown method error = %+v, want %d", r.Error, codeMethodNotFound)
+	}
+	if r := responses[3]; r.Error != nil || string(r.ID) != "4" {
+		t.Errorf("ping = %+v", r)
+	}
+	if r := responses[4]; r.Error == nil || r.Error.Code != codeParseError || string(r.ID) != "null" {
+		t.Errorf("parse error = %+v", r)
+	}
+}
+
+func TestServer_callTool(t *testing.T) {
+	db := memory.NewMemoryDB()
+	now := time.Now()
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "We compare whisper models on long podcasts.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 120, "A talk about cooking.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "c.mp4", "c.mp3", 30, "Whisper models for bob.", now, 0, "", model.ProviderMetadata{})
+	if err := db.SaveSegments(1, []model.Segment{{Start: 0, End: 2, Text: "We compare whisper models on long podcasts."}}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(oneDatabase{db}, "v1.2.3")
+
+	tests := []struct {
+		name      string
+		params    string
+		want      []string
+		wantError bool
+		wantCode  int
+	}{
+		{
+			name:   "search",
+			params: `{"name": "search", "arguments": {"query": "Models whisper", "user": "alice"}}`,
+			want:   []string{`"file_name": "a.mp3"`, `"snippet": "We compare whisper models on long podcasts."`},
+		},
+		{
+			name:   "search without results",
+			params: `{"name": "search", "arguments": {"query": "cooking whisper", "user": "alice"}}`,
+			want:   []string{"[]"},
+		},
+		{
+			name:   "fetch-transcript",
+			params: `{"name": "fetch-transcript", "arguments": {"id": 1, "user": "alice"}}`,
+			want:   []string{`"schema_version": 1`, `"transcription": "We compare whisper models on long podcasts."`, `"segments": [`},
+		},
+		{
+			name:      "fetch-transcript of a missing id",
+			params:    `{"name": "fetch-transcript", "arguments": {"id": 42}}`,
+			want:      []string{"transcription 42 of default not found"},
+			wantError: true,
+		},
+		{
+			name:   "stats",
+			params: `{"name": "stats", "arguments": {"user": "bob"}}`,
+			want:   []string{`"user": "bob"`, `"video_count": 1`, `"total_audio_duration": 30`},
+		},
+		{
+			name:      "empty query",
+			params:    `{"name": "search", "arguments": {"query": " ? "}}`,
+			want:      []string{"empty search query"},
+			wantError: true,
+		},
+		{
+			name:      "invalid arguments",
+			params:    `{"name": "stats", "arguments": {"user": 7}}`,
+			want:      []string{"invalid arguments"},
+			wantError: true,
+		},
+		{name: "unknown tool", params: `{"name": "delete"}`, wantCode: codeInvalidParams},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := serve(t, s, `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": `+tt.params+`}`)
+			if len(responses) != 1 {
+				t.Fatalf("got %d responses, want 1", len(responses))
+			}
+			r := responses[0]
+			if tt.wantCode != 0 {
+				if r.Error == nil || r.Error.Code != tt.wantCode {
+					t.Errorf("error = %+v, want code %d", r.Error, tt.wantCode)
+				}
+				return
+			}
+			if r.Error != nil || len(r.Result.Content) != 1 || r.Result.IsError != tt.wantError {
+				t.Fatalf("tools/call = %+v, want isError %v", r, tt.wantError)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(r.Result.Content[0].Text, want) {
+					t.Errorf("tools/call text = %s, want it to contain %s", r.Result.Content[0].Text, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/schema"
+	"tiktok-whisper/internal/app/search"
+)
+
+// DefaultUser is searched when a tool call names no user, like convert stores files without a user.
+const DefaultUser = "default"
+
+// defaultLimit is the number of search results returned when the call sets no limit.
+const defaultLimit = 10
+
+type tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+
+	run func(s *Server, args json.RawMessage) (interface{}, error)
+}
+
+var tools = []tool{
+	{
+		Name:        "search",
+		Description: "Search the file names and transcriptions of a user for all words of a query, in any order. Returns the id, file name, a snippet and the confidence in the text of each match, likely misheard transcriptions last.",
+		InputSchema: json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"query": {"type": "string", "description": "Words the transcription must contain"},
+		"user": {"type": "string", "description": "Owner of the transcriptions, default is \"default\""},
+		"limit": {"type": "integer", "description": "Most results to return, default is 10"},
+		"include_low_confidence": {"type": "boolean", "description": "Also return likely misheard transcriptions, ranked last, default is true"}
+	},
+	"required": ["query"]
+}`),
+		run: (*Server).search,
+	},
+	{
+		Name:        "fetch-transcript",
+		Description: "Fetch the full text, timed segments and provider metadata of a transcription by the id search returned.",
+		InputSchema: json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"id": {"type": "integer", "description": "Id of the transcription"},
+		"user": {"type": "string", "description": "Owner of the transcription, default is \"default\""}
+	},
+	"required": ["id"]
+}`),
+		run: (*Server).fetchTranscript,
+	},
+	{
+		Name:        "stats",
+		Description: "Count the transcribed files and their audio duration in seconds per user.",
+		InputSchema: json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"user": {"type": "string", "description": "Only count this user, default is every user of the default database"}
+	}
+}`),
+		run: (*Server).stats,
+	},
+}
+
+// callTool runs a tool, its failures are results flagged with isError so the model can read them.
+func (s *Server) callTool(params json.RawMessage) (interface{}, *rpcError) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	for _, t := range tools {
+		if t.Name != p.Name {
+			continue
+		}
+
+		result, err := t.run(s, p.Arguments)
+		if err != nil {
+			return toolResult(err.Error(), true), nil
+		}
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		return toolResult(string(data), false), nil
+	}
+	return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", p.Name)}
+}
+
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+func decodeArguments(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// SearchResult is a transcription matching a search.
+type SearchResult struct {
+	ID            int     `json:"id"`
+	User          string  `json:"user"`
+	FileName      string  `json:"file_name"`
+	AudioDuration float64 `json:"audio_duration"`
+	Snippet       string  `json:"snippet"`
+	// Confidence in the text from 0 to 1, see search.Confidence.
+	Confidence float64 `json:"confidence"`
+}
+
+func (s *Server) search(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Query string `json:"query"`
+		User  string `json:"user"`
+		Limit int    `json:"limit"`
+		// IncludeLowConfidence is nil when the argument is missing, which includes them
+		IncludeLowConfidence *bool `json:"include_low_confidence"`
+	}
+	if err := decodeArguments(args, &a); err != nil {
+		return nil, err
+	}
+	if a.User == "" {
+		a.User = DefaultUser
+	}
+	if a.Limit <= 0 {
+		a.Limit = defaultLimit
+	}
+
+	db, err := s.databases.ForUser(a.User)
+	if err != nil {
+		return nil, err
+	}
+	opts := search.Options{Limit: a.Limit}
+	if a.IncludeLowConfidence != nil {
+		opts.ExcludeLowConfidence = !*a.IncludeLowConfidence
+	}
+	found, err := search.Transcriptions(db, a.User, a.Query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(found))
+	for _, r := range found {
+		results = append(results, SearchResult{
+			ID:            r.ID,
+			User:          r.User,
+			FileName:      r.Mp3FileName,
+			AudioDuration: r.AudioDuration,
+			Snippet:       r.Snippet,
+			Confidence:    r.Confidence,
+		})
+	}
+	return results, nil
+}
+
+func (s *Server) fetchTranscript(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		ID   int    `json:"id"`
+		User string `json:"user"`
+	}
+	if err := decodeArguments(args, &a); err != nil {
+		return nil, err
+	}
+	if a.User == "" {
+		a.User = DefaultUser
+	}
+
+	db, err := s.databases.ForUser(a.User)
+	if err != nil {
+		return nil, err
+	}
+	t, err := db.GetByID(a.ID)
+	if err != nil || t == nil {
+		return nil, fmt.Errorf("transcription %d of %s not found", a.ID, a.User)
+	}
+	if segmentDAO, ok := db.(repository.SegmentDAO); ok && len(t.Segments) == 0 {
+		if t.Segments, err = segmentDAO.GetSegments(t.ID); err != nil {
+			return nil, fmt.Errorf("get segments failed: %v", err)
+		}
+	}
+	return schema.NewTranscription(*t), nil
+}
+
+// UserStats are the stats of a user.
+type UserStats struct {
+	User               string  `json:"user"`
+	VideoCount         int     `json:"video_count"`
+	TotalAudioDuration float64 `json:"total_audio_duration"`
+	AvgAudioDuration   float64 `json:"avg_audio_duration"`
+}
+
+func (s *Server) stats(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		User string `json:"user"`
+	}
+	if err := decodeArguments(args, &a); err != nil {
+		return nil, err
+	}
+
+	db, err := s.databases.ForUser(a.User)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := db.GetUserStats()
+	if err != nil {
+		return nil, fmt.Errorf("get stats failed: %v", err)
+	}
+
+	result := make([]UserStats, 0, len(stats))
+	for _, st := range stats {
+		if a.User != "" && st.User != a.User {
+			continue
+		}
+		result = append(result, newUserStats(st))
+	}
+	return result, nil
+}
+
+func newUserStats(s model.UserStats) UserStats {
+	return UserStats{
+		User:               s.User,
+		VideoCount:         s.VideoCount,
+		TotalAudioDuration: s.TotalAudioDuration,
+		AvgAudioDuration:   s.AvgAudioDuration(),
+	}
+}
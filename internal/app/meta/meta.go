@@ -0,0 +1,234 @@
+// Package meta edits the metadata of many transcriptions at once, e.g. to fix the language of a
+// user's old transcriptions. An edit is planned as a Patch, whose inverse undoes it.
+package meta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+// dateLayout is the layout of the before and after filters.
+const dateLayout = "2006-01-02"
+
+// reservedKeys are filter keys that are not metadata, they can't be set.
+var reservedKeys = []string{"user", "before", "after"}
+
+// Filter selects the transcriptions an edit applies to.
+type Filter struct {
+	// User owns the transcriptions, every user of the database when empty.
+	User string
+	// Before and After bound the last conversion time, Before exclusive and After inclusive.
+	// The zero time leaves the bound open.
+	Before time.Time
+	After  time.Time
+	// Metadata the transcriptions must have, an empty value matches transcriptions without the key.
+	Metadata map[string]string
+}
+
+// ParseFilter reads a filter written as comma separated key=value pairs, such as
+// user=X,before=2023-01-01,collection=inbox. Keys other than user, before and after match metadata.
+func ParseFilter(s string) (Filter, error) {
+	f := Filter{Metadata: make(map[string]string)}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, err := parsePair(pair)
+		if err != nil {
+			return f, err
+		}
+
+		switch key {
+		case "user":
+			f.User = value
+		case "before", "after":
+			t, err := time.ParseInLocation(dateLayout, value, time.Local)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s %q, expected YYYY-MM-DD", key, value)
+			}
+			if key == "before" {
+				f.Before = t
+			} else {
+				f.After = t
+			}
+		default:
+			f.Metadata[key] = value
+		}
+	}
+	return f, nil
+}
+
+// ParseAssignments reads key=value assignments, key= removes the key.
+func ParseAssignments(assignments []string) (map[string]string, error) {
+	values := make(map[string]string, len(assignments))
+	for _, a := range assignments {
+		key, value, err := parsePair(a)
+		if err != nil {
+			return nil, err
+		}
+		for _, reserved := range reservedKeys {
+			if key == reserved {
+				return nil, fmt.Errorf("%s is not metadata, it can't be set", key)
+			}
+		}
+		values[key] = value
+	}
+	if len(values) == 0 {
+		return nil, errors.New("nothing to set")
+	}
+	return values, nil
+}
+
+func parsePair(pair string) (string, string, error) {
+	key, value, ok := strings.Cut(pair, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("invalid %q, expected key=value", pair)
+	}
+	return key, strings.TrimSpace(value), nil
+}
+
+// Matches reports whether the filter selects t, whose metadata is values.
+func (f Filter) Matches(t model.Transcription, values map[string]string) bool {
+	if f.User != "" && t.User != f.User {
+		return false
+	}
+	if !f.Before.IsZero() && !t.LastConversionTime.Before(f.Before) {
+		return false
+	}
+	if !f.After.IsZero() && t.LastConversionTime.Before(f.After) {
+		return false
+	}
+	for key, value := range f.Metadata {
+		if values[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Change is the edit of one transcription.
+type Change struct {
+	TranscriptionID int    `json:"transcription_id"`
+	File            string `json:"file,omitempty"`
+	// Before are the values the changed keys had, empty for keys that were not set.
+	Before map[string]string `json:"before"`
+	After  map[string]string `json:"after"`
+}
+
+// Patch is an edit of many transcriptions.
+type Patch struct {
+	// User routes the patch to the database it was planned on, the default database when empty.
+	User      string    `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+	Changes   []Change  `json:"changes"`
+}
+
+// Inverse returns the patch that undoes p.
+func (p Patch) Inverse() Patch {
+	inverse := Patch{User: p.User, CreatedAt: p.CreatedAt, Changes: make([]Change, len(p.Changes))}
+	for i, c := range p.Changes {
+		inverse.Changes[i] = Change{TranscriptionID: c.TranscriptionID, File: c.File, Before: c.After, After: c.Before}
+	}
+	return inverse
+}
+
+// Plan returns the patch that sets values on the transcriptions the filter selects.
+// Transcriptions that already have the values are left out.
+func Plan(db repository.TranscriptionDAO, metadata repository.MetadataDAO, f Filter, values map[string]string) (Patch, error) {
+	patch := Patch{User: f.User, CreatedAt: time.Now()}
+	transcriptions, err := transcriptionsOf(db, f.User)
+	if err != nil {
+		return patch, err
+	}
+
+	for _, t := range transcriptions {
+		current, err := metadata.GetMetadata(t.ID)
+		if err != nil {
+			return patch, fmt.Errorf("get metadata of %d failed: %v", t.ID, err)
+		}
+		if !f.Matches(t, current) {
+			continue
+		}
+
+		change := Change{TranscriptionID: t.ID, File: t.Mp3FileName, Before: map[string]string{}, After: map[string]string{}}
+		for key, value := range values {
+			if current[key] != value {
+				change.Before[key] = current[key]
+				change.After[key] = value
+			}
+		}
+		if len(change.After) > 0 {
+			patch.Changes = append(patch.Changes, change)
+		}
+	}
+	sort.Slice(patch.Changes, func(i, j int) bool {
+		return patch.Changes[i].TranscriptionID < patch.Changes[j].TranscriptionID
+	})
+	return patch, nil
+}
+
+// transcriptionsOf returns the transcriptions of user, of every user when it is empty.
+func transcriptionsOf(db repository.TranscriptionDAO, user string) ([]model.Transcription, error) {
+	if user != "" {
+		transcriptions, err := db.GetAllByUser(user)
+		if err != nil {
+			return nil, fmt.Errorf("get transcriptions of %s failed: %v", user, err)
+		}
+		return transcriptions, nil
+	}
+
+	stats, err := db.GetUserStats()
+	if err != nil {
+		return nil, fmt.Errorf("get users failed: %v", err)
+	}
+	var transcriptions []model.Transcription
+	for _, s := range stats {
+		ts, err := db.GetAllByUser(s.User)
+		if err != nil {
+			return nil, fmt.Errorf("get transcriptions of %s failed: %v", s.User, err)
+		}
+		transcriptions = append(transcriptions, ts...)
+	}
+	return transcriptions, nil
+}
+
+// Apply sets the After values of every change, it returns how many transcriptions were changed
+// before an error stopped it.
+func Apply(metadata repository.MetadataDAO, p Patch) (int, error) {
+	for i, c := range p.Changes {
+		if err := metadata.SetMetadata(c.TranscriptionID, c.After); err != nil {
+			return i, fmt.Errorf("set metadata of %d failed: %v", c.TranscriptionID, err)
+		}
+	}
+	return len(p.Changes), nil
+}
+
+// WritePatch saves p as JSON to path.
+func WritePatch(path string, p Patch) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadPatch loads a patch saved by WritePatch.
+func ReadPatch(path string) (Patch, error) {
+	var p Patch
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err = json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("invalid patch %s: %v", path, err)
+	}
+	return p, nil
+}
@@ -0,0 +1,105 @@
+package meta
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    Filter
+		wantErr bool
+	}{
+		{name: "empty", filter: "", want: Filter{Metadata: map[string]string{}}},
+		{
+			name:   "user and dates",
+			filter: "user=alice, before=2023-01-01,after=2022-06-01",
+			want: Filter{User: "alice", Before: time.Date(2023, 1, 1, 0, 0, 0, 0, time.Local),
+				After: time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local), Metadata: map[string]string{}},
+		},
+		{name: "metadata", filter: "collection=inbox,language=", want: Filter{Metadata: map[string]string{"collection": "inbox", "language": ""}}},
+		{name: "invalid date", filter: "before=yesterday", wantErr: true},
+		{name: "no value", filter: "user", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFilter(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFilter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAssignments(t *testing.T) {
+	got, err := ParseAssignments([]string{"language=zh", "collection=archive", "topic="})
+	if want := map[string]string{"language": "zh", "collection": "archive", "topic": ""}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAssignments() = %v, %v, want %v", got, err, want)
+	}
+	for _, invalid := range [][]string{nil, {"user=bob"}, {"=zh"}} {
+		if _, err = ParseAssignments(invalid); err == nil {
+			t.Errorf("ParseAssignments(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestPlanApplyUndo(t *testing.T) {
+	db := memory.NewMemoryDB()
+	old := time.Date(2022, 5, 1, 0, 0, 0, 0, time.Local)
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "a", old, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 60, "b", old, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 60, "c", old.AddDate(1, 0, 0), 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "d.mp4", "d.mp3", 60, "d", old, 0, "", model.ProviderMetadata{})
+	db.SetMetadata(1, map[string]string{"language": "en"})
+	db.SetMetadata(2, map[string]string{"language": "zh", "collection": "archive"})
+
+	f, _ := ParseFilter("user=alice,before=2023-01-01")
+	patch, err := Plan(db, db, f, map[string]string{"language": "zh", "collection": "archive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{{TranscriptionID: 1, File: "a.mp3",
+		Before: map[string]string{"language": "en", "collection": ""},
+		After:  map[string]string{"language": "zh", "collection": "archive"}}}
+	if !reflect.DeepEqual(patch.Changes, want) {
+		t.Fatalf("Plan() = %+v, want %+v", patch.Changes, want)
+	}
+
+	if n, err := Apply(db, patch); err != nil || n != 1 {
+		t.Fatalf("Apply() = %d, %v", n, err)
+	}
+	if got, _ := db.GetMetadata(1); !reflect.DeepEqual(got, map[string]string{"language": "zh", "collection": "archive"}) {
+		t.Errorf("metadata of 1 = %v after Apply()", got)
+	}
+
+	// The undo patch survives a round trip through its file
+	path := filepath.Join(t.TempDir(), "undo.json")
+	if err = WritePatch(path, patch.Inverse()); err != nil {
+		t.Fatal(err)
+	}
+	undo, err := ReadPatch(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Apply(db, undo); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetMetadata(1); !reflect.DeepEqual(got, map[string]string{"language": "en"}) {
+		t.Errorf("metadata of 1 = %v after undo, want the original", got)
+	}
+
+	// Without a user every user of the database is selected
+	f, _ = ParseFilter("language=")
+	if patch, _ = Plan(db, db, f, map[string]string{"language": "zh"}); len(patch.Changes) != 2 {
+		t.Errorf("Plan() of every user = %+v, want transcriptions 3 and 4", patch.Changes)
+	}
+}
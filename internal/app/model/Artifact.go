@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Artifact records an output file generated from a stored transcription.
+type Artifact struct {
+	ID              int
+	TranscriptionID int
+	Format          string
+	Path            string
+	// WriterVersion is the version of the writer that produced the file.
+	WriterVersion int
+	// ContentHash is the sha256 of the file content, hex encoded.
+	ContentHash string
+	ExportedAt  time.Time
+}
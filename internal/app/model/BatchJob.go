@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// BatchKind is what a batch job converts.
+type BatchKind string
+
+const (
+	BatchAudio BatchKind = "audio"
+	BatchVideo BatchKind = "video"
+)
+
+// BatchFileStatus is the progress of a single file of a batch job.
+type BatchFileStatus string
+
+const (
+	BatchFilePending  BatchFileStatus = "pending"
+	BatchFileInFlight BatchFileStatus = "in_flight"
+	BatchFileDone     BatchFileStatus = "done"
+	BatchFileFailed   BatchFileStatus = "failed"
+)
+
+// BatchJob is a conversion of a list of files whose progress is checkpointed, so an interrupted
+// run can be resumed with the files it didn't finish.
+type BatchJob struct {
+	ID   string
+	Kind BatchKind
+	// User owns the converted videos, it is empty for audio jobs.
+	User string
+	// OutputDirectory receives the text files of audio jobs.
+	OutputDirectory string
+	CreatedAt       time.Time
+	Files           []BatchFile
+}
+
+// BatchFile is a file of a batch job.
+type BatchFile struct {
+	Path      string
+	Status    BatchFileStatus
+	Error     string
+	UpdatedAt time.Time
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// CostEntry is the estimated price of a transcription as recorded in the cost ledger.
+type CostEntry struct {
+	ID int
+	// TranscriptionID is zero for audio conversions, their results aren't stored in the database.
+	TranscriptionID int
+	User            string
+	FilePath        string
+	Provider        string
+	Model           string
+	AudioSeconds    float64
+	Cost            float64
+	RecordedAt      time.Time
+}
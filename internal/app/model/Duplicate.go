@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Duplicate is a transcription repeating an earlier one of the same user, e.g. a video uploaded
+// again or a re-cut of the same recording.
+type Duplicate struct {
+	TranscriptionID int
+	// DuplicateOf is the earlier transcription it repeats.
+	DuplicateOf int
+	// Similarity from 0 to 1 is the higher of the two measures below.
+	Similarity float64
+	// EmbeddingSimilarity is the cosine similarity of the embeddings in the vector index, zero when
+	// either transcription has none.
+	EmbeddingSimilarity float64
+	// TextSimilarity is the Jaccard similarity of the word shingles of the transcripts.
+	TextSimilarity float64
+	DetectedAt     time.Time
+
+	// FileName and DuplicateFileName are those of the transcriptions, they are not stored with the
+	// duplicate.
+	FileName          string
+	DuplicateFileName string
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Embedding is the vector of the text of a transcription made by an embedding model.
+type Embedding struct {
+	TranscriptionID int
+	// Model is the embedder that made the vector, e.g. ollama/nomic-embed-text. Vectors of
+	// different models can't be compared, a transcription has one per model.
+	Model      string
+	Vector     []float32
+	EmbeddedAt time.Time
+}
+
+// EmbeddingProgress counts the transcriptions an embedding model has embedded so far.
+type EmbeddingProgress struct {
+	Model string
+	// Embedded transcriptions have a vector of their current text.
+	Embedded int
+	// Pending transcriptions have no vector yet or one of a text that changed since.
+	Pending int
+}
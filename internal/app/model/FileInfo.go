@@ -3,7 +3,7 @@ package model
 import "time"
 
 type FileInfo struct {
-    FullPath string
-    ModTime  time.Time
-    Name     string
+	FullPath string
+	ModTime  time.Time
+	Name     string
 }
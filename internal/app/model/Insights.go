@@ -0,0 +1,51 @@
+package model
+
+// Insights are what a provider understood of a transcription beyond its text, e.g. the chapters,
+// entities and sentiments AssemblyAI reports. Times are in seconds like those of the segments.
+type Insights struct {
+	Chapters   []Chapter   `json:"chapters,omitempty"`
+	Entities   []Entity    `json:"entities,omitempty"`
+	Sentiments []Sentiment `json:"sentiments,omitempty"`
+}
+
+// Chapter is a part of the audio about one topic.
+type Chapter struct {
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+	Headline string  `json:"headline"`
+	// Gist is a few words naming the topic.
+	Gist    string `json:"gist,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// Entity is a named entity mentioned in the audio.
+type Entity struct {
+	// Type is the kind of entity as the provider names it, e.g. person_name or location.
+	Type  string  `json:"type"`
+	Text  string  `json:"text"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Sentiment values of a sentence.
+const (
+	SentimentPositive = "positive"
+	SentimentNeutral  = "neutral"
+	SentimentNegative = "negative"
+)
+
+// Sentiment is the sentiment of a sentence.
+type Sentiment struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Speaker string  `json:"speaker,omitempty"`
+	Text    string  `json:"text"`
+	// Sentiment is SentimentPositive, SentimentNeutral or SentimentNegative.
+	Sentiment  string  `json:"sentiment"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// Empty reports whether the provider reported no insight.
+func (i Insights) Empty() bool {
+	return len(i.Chapters) == 0 && len(i.Entities) == 0 && len(i.Sentiments) == 0
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Moderation is the result of screening a transcription for harmful content.
+type Moderation struct {
+	TranscriptionID int
+	// Backend is the classifier that screened the text, e.g. openai.
+	Backend string
+	// Scores maps a category such as harassment or violence to the confidence of the classifier, 0 to 1.
+	Scores map[string]float64
+	// Flagged are the categories the policy flagged, sorted. The transcription is flagged when there is one.
+	Flagged   []string
+	CheckedAt time.Time
+}
+
+// IsFlagged reports whether a category of the transcription was flagged.
+func (m Moderation) IsFlagged() bool {
+	return len(m.Flagged) > 0
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProviderMetadata describes how a transcription was produced. It is stored as JSON in the
+// provider_metadata column, the common fields are filled by every provider and the
+// provider specific part lives in the matching typed field.
+type ProviderMetadata struct {
+	Provider        string  `json:"provider"`
+	Model           string  `json:"model,omitempty"`
+	Language        string  `json:"language,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	SegmentCount    int     `json:"segment_count,omitempty"`
+	// DetectedLanguage is the language detected to route the audio, empty when routing is off.
+	DetectedLanguage string `json:"detected_language,omitempty"`
+	// Chunks is the number of pieces long audio was split into, zero when it was sent whole.
+	Chunks int `json:"chunks,omitempty"`
+	// RerunChunks is the number of chunks transcribed again with strict settings after a repetition loop.
+	RerunChunks int         `json:"rerun_chunks,omitempty"`
+	Server      *ServerInfo `json:"server,omitempty"`
+	// Cost of the transcription as computed by the cost middleware, zero when it isn't tracked.
+	Cost float64 `json:"cost,omitempty"`
+	// Request is the static request metadata configured for the provider, nil when there was none.
+	Request *RequestMetadata `json:"request,omitempty"`
+	// FailedOver lists the providers that failed before this one produced the transcription.
+	FailedOver []string `json:"failed_over,omitempty"`
+	// Validation is the verdict of the result checks, nil when validation is disabled.
+	Validation *ValidationMetadata `json:"validation,omitempty"`
+	// Speakers is the number of speakers diarization found, zero when it didn't run.
+	Speakers int `json:"speakers,omitempty"`
+	// TrimmedSeconds of silence the VAD pre-filter cut before transcription, zero when it didn't run.
+	TrimmedSeconds float64 `json:"trimmed_seconds,omitempty"`
+	// Verbatim is true when the provider kept fillers, false starts and stutters as spoken.
+	Verbatim bool `json:"verbatim,omitempty"`
+	// WordConfidence is the mean probability of the words the provider reported, zero when it reported none.
+	WordConfidence float64 `json:"word_confidence,omitempty"`
+	// Corrections is the number of term variants the vocabulary packs corrected, zero when none are installed.
+	Corrections int `json:"corrections,omitempty"`
+	// Segments are the timed segments the provider reported, they are stored in their own table
+	// rather than in the provider_metadata column.
+	Segments []Segment `json:"-"`
+	// Insights are the chapters, entities and sentiments the provider reported, nil when it reports
+	// none. Databases keeping insights also store them in their own tables to query them.
+	Insights *Insights `json:"insights,omitempty"`
+
+	WhisperCpp *WhisperCppMetadata `json:"whisper_cpp,omitempty"`
+	OpenAI     *OpenAIMetadata     `json:"openai,omitempty"`
+}
+
+// ServerInfo identifies the remote service that handled the request.
+type ServerInfo struct {
+	Host    string `json:"host,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// RequestMetadata records the configured headers, form fields and attribution tags a request carried.
+type RequestMetadata struct {
+	Headers    map[string]string `json:"headers,omitempty"`
+	FormFields map[string]string `json:"form_fields,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// ValidationMetadata records what the result checks found in a transcription.
+type ValidationMetadata struct {
+	Passed bool     `json:"passed"`
+	Issues []string `json:"issues,omitempty"`
+	// Retried is true when the result was transcribed again with stricter settings, Issues are those of the kept result.
+	Retried bool `json:"retried,omitempty"`
+}
+
+// WhisperCppMetadata is specific to the local whisper.cpp binary.
+type WhisperCppMetadata struct {
+	BinaryPath string `json:"binary_path"`
+	ModelPath  string `json:"model_path"`
+	Prompt     string `json:"prompt,omitempty"`
+
+	// The anti-hallucination settings the transcription ran with, unset ones are whisper.cpp's defaults.
+	NoContext         bool    `json:"no_context,omitempty"`
+	Temperature       float32 `json:"temperature,omitempty"`
+	NoSpeechThreshold float32 `json:"no_speech_threshold,omitempty"`
+	VAD               bool    `json:"vad,omitempty"`
+
+	// The options of the file, see api.Options.
+	Translate        bool    `json:"translate,omitempty"`
+	WordThreshold    float32 `json:"word_threshold,omitempty"`
+	MaxSegmentLength int     `json:"max_segment_length,omitempty"`
+}
+
+// OpenAIMetadata is specific to the OpenAI whisper API.
+type OpenAIMetadata struct {
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Duration returns the transcribed audio duration reported by the provider.
+func (m ProviderMetadata) Duration() time.Duration {
+	return time.Duration(m.DurationSeconds * float64(time.Second))
+}
+
+// WhisperCppInfo returns the whisper.cpp specific metadata, ok is false for other providers.
+func (m ProviderMetadata) WhisperCppInfo() (info WhisperCppMetadata, ok bool) {
+	if m.WhisperCpp == nil {
+		return WhisperCppMetadata{}, false
+	}
+	return *m.WhisperCpp, true
+}
+
+// OpenAIInfo returns the OpenAI specific metadata, ok is false for other providers.
+func (m ProviderMetadata) OpenAIInfo() (info OpenAIMetadata, ok bool) {
+	if m.OpenAI == nil {
+		return OpenAIMetadata{}, false
+	}
+	return *m.OpenAI, true
+}
+
+// JSON serializes the metadata for the provider_metadata column, empty metadata is stored as "".
+func (m ProviderMetadata) JSON() (string, error) {
+	if m.Provider == "" {
+		return "", nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ParseProviderMetadata reads the provider_metadata column, unknown fields are ignored
+// so rows written by newer versions stay readable.
+func ParseProviderMetadata(s string) (ProviderMetadata, error) {
+	var m ProviderMetadata
+	if s == "" {
+		return m, nil
+	}
+
+	err := json.Unmarshal([]byte(s), &m)
+	return m, err
+}
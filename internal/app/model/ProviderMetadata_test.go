@@ -0,0 +1,62 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProviderMetadataRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		m    ProviderMetadata
+	}{
+		{
+			name: "empty",
+			m:    ProviderMetadata{},
+		},
+		{
+			name: "whisper_cpp",
+			m: ProviderMetadata{
+				Provider:        "whisper_cpp",
+				Model:           "ggml-large-v2",
+				Language:        "zh",
+				DurationSeconds: 12.5,
+				SegmentCount:    3,
+				WhisperCpp:      &WhisperCppMetadata{BinaryPath: "/bin/main", ModelPath: "/models/ggml-large-v2.bin"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := tt.m.JSON()
+			if err != nil {
+				t.Fatalf("JSON() error = %v", err)
+			}
+			got, err := ParseProviderMetadata(s)
+			if err != nil {
+				t.Fatalf("ParseProviderMetadata() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.m) {
+				t.Errorf("round trip got %+v, want %+v", got, tt.m)
+			}
+		})
+	}
+}
+
+func TestProviderMetadataGetters(t *testing.T) {
+	m, err := ParseProviderMetadata(`{"provider":"openai","duration_seconds":1.5,"openai":{"endpoint":"transcriptions"},"future_field":1}`)
+	if err != nil {
+		t.Fatalf("ParseProviderMetadata() error = %v", err)
+	}
+
+	if m.Duration() != 1500*time.Millisecond {
+		t.Errorf("Duration() = %v", m.Duration())
+	}
+	if _, ok := m.WhisperCppInfo(); ok {
+		t.Errorf("WhisperCppInfo() ok for openai metadata")
+	}
+	if info, ok := m.OpenAIInfo(); !ok || info.Endpoint != "transcriptions" {
+		t.Errorf("OpenAIInfo() = %+v, %v", info, ok)
+	}
+}
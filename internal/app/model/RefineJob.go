@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// RefineStatus is the progress of the high-quality pass of a draft transcription.
+type RefineStatus string
+
+const (
+	// RefinePending drafts wait for their high-quality pass.
+	RefinePending RefineStatus = "pending"
+	RefineDone    RefineStatus = "done"
+	RefineFailed  RefineStatus = "failed"
+)
+
+// RefineJob queues a draft transcription, made quickly with a fast model, to be transcribed
+// again with a bigger model. The refined text replaces the draft as a new revision.
+type RefineJob struct {
+	TranscriptionID int
+	// AudioPath is the audio the draft was transcribed from.
+	AudioPath string
+	Status    RefineStatus
+	Error     string
+	QueuedAt  time.Time
+	UpdatedAt time.Time
+}
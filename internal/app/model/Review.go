@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// SegmentTranslation is a segment of a transcription translated on its own, for reading the
+// original and the translation side by side.
+type SegmentTranslation struct {
+	TranscriptionID int
+	// Position of the segment within the transcription.
+	Position int
+	Language string
+	Backend  string
+	// Source is the text of the segment that was translated, the translation is outdated once
+	// the segment reads differently.
+	Source    string
+	Text      string
+	CreatedAt time.Time
+}
+
+// ReviewCard is a segment marked for spaced repetition in the language-learning review.
+type ReviewCard struct {
+	TranscriptionID int
+	Position        int
+	// Language of Translation.
+	Language string
+	// Text and Translation are the segment when it was marked, the card outlives a new transcript.
+	Text        string
+	Translation string
+	// IntervalDays until the next review, Ease multiplies it after a good answer and Repetitions
+	// counts the answers in a row that weren't wrong.
+	IntervalDays int
+	Ease         float64
+	Repetitions  int
+	DueAt        time.Time
+	MarkedAt     time.Time
+}
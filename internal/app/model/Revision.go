@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Revision is one transcription result of a file, re-transcribing a file adds a revision
+// instead of overwriting the previous text.
+type Revision struct {
+	TranscriptionID  int
+	Revision         int
+	Transcription    string
+	ProviderMetadata ProviderMetadata
+	CreatedAt        time.Time
+	// Current is set on the revision whose text the transcription currently shows.
+	Current bool
+}
@@ -0,0 +1,44 @@
+package model
+
+// Segment is a timed piece of a transcript as returned by the provider.
+// Start and End are zero when the provider reports no timings.
+type Segment struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Speaker string  `json:"speaker,omitempty"`
+	Text    string  `json:"text"`
+	// Words are the word timings within the segment, empty when the provider reports none.
+	Words []Word `json:"words,omitempty"`
+}
+
+// Word is a timed word of a segment.
+type Word struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+	// Probability is the provider's confidence in the word, zero when it reports none.
+	Probability float64 `json:"probability,omitempty"`
+}
+
+// Timed reports whether the segment carries timestamps.
+func (s Segment) Timed() bool {
+	return s.End > 0
+}
+
+// WordConfidence returns the mean probability of the words of segments that have one, zero when none has.
+func WordConfidence(segments []Segment) float64 {
+	var sum float64
+	var n int
+	for _, s := range segments {
+		for _, w := range s.Words {
+			if w.Probability > 0 {
+				sum += w.Probability
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
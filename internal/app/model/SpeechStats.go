@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// SpeechStats are the speech analytics of one transcription, an episode of a creator.
+type SpeechStats struct {
+	TranscriptionID int
+	// Version of the analysis that computed the stats.
+	Version int
+	// SpeechSeconds is the time covered by the segments, the pauses between them excluded.
+	SpeechSeconds float64
+	Words         int
+	FillerWords   int
+	// LongestMonologue in seconds is the longest stretch one speaker talked without a long pause.
+	LongestMonologue float64
+	// Speakers is zero when the transcription wasn't diarized.
+	Speakers int
+	// Interruptions count the turns that started while, or right as, the previous speaker talked.
+	Interruptions int
+	ComputedAt    time.Time
+
+	// FileName and Date are those of the transcription, they are not stored with the stats.
+	FileName string
+	Date     time.Time
+}
+
+// FillersPerMinute returns the filler words per minute of speech.
+func (s SpeechStats) FillersPerMinute() float64 {
+	if s.SpeechSeconds <= 0 {
+		return 0
+	}
+	return float64(s.FillerWords) / (s.SpeechSeconds / 60)
+}
+
+// WordsPerMinute returns the speaking rate.
+func (s SpeechStats) WordsPerMinute() float64 {
+	if s.SpeechSeconds <= 0 {
+		return 0
+	}
+	return float64(s.Words) / (s.SpeechSeconds / 60)
+}
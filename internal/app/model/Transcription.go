@@ -10,4 +10,9 @@ type Transcription struct {
 	AudioDuration      float64
 	Transcription      string
 	ErrorMessage       string
+	ProviderMetadata   ProviderMetadata
+	// Segments are the raw provider segments, empty when the provider reported none.
+	Segments []Segment
+	// SourceURL is the page the media was downloaded from, empty for local files.
+	SourceURL string
 }
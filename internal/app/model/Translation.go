@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Translation is the text of a stored transcription translated into another language.
+type Translation struct {
+	ID              int
+	TranscriptionID int
+	// Language is the target language code, e.g. en or zh.
+	Language string
+	// Backend is the service that translated the text, e.g. openai, gemini or deepl.
+	Backend   string
+	Text      string
+	CreatedAt time.Time
+}
@@ -0,0 +1,16 @@
+package model
+
+// UserStats is the aggregated, text free view of a user's transcriptions.
+type UserStats struct {
+	User               string
+	VideoCount         int
+	TotalAudioDuration float64
+}
+
+// AvgAudioDuration returns the average audio duration in seconds.
+func (s UserStats) AvgAudioDuration() float64 {
+	if s.VideoCount == 0 {
+		return 0
+	}
+	return s.TotalAudioDuration / float64(s.VideoCount)
+}
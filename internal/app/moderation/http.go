@@ -0,0 +1,74 @@
+package moderation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPModerator classifies with a local classifier service. It posts {"text": "..."} and expects
+// {"scores": {"category": 0.9}, "flagged": ["category"]}, flagged may be left out.
+type HTTPModerator struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewHTTPModerator creates a new HTTPModerator instance posting to url with headers.
+func NewHTTPModerator(url string, headers map[string]string, timeout time.Duration) (*HTTPModerator, error) {
+	if url == "" {
+		return nil, errors.New("the http moderation backend needs moderation.url")
+	}
+	return &HTTPModerator{url: url, headers: headers, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (h *HTTPModerator) Name() string { return "http" }
+
+func (h *HTTPModerator) Moderate(text string) (Verdict, error) {
+	var response struct {
+		Scores  map[string]float64 `json:"scores"`
+		Flagged []string           `json:"flagged"`
+	}
+	if err := postJSON(h.client, h.url, h.headers, map[string]string{"text": text}, &response); err != nil {
+		return Verdict{}, err
+	}
+	return Verdict{Scores: response.Scores, Flagged: response.Flagged}, nil
+}
+
+// postJSON posts request as JSON with headers and decodes the response into response.
+func postJSON(client *http.Client, url string, headers map[string]string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("moderation request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read moderation response failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("moderation service returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	if err = json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("parse moderation response failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+// Package moderation screens stored transcriptions for harmful content with the OpenAI moderation API
+// or a local classifier. The latest result is stored per transcription, and the configured policy
+// decides what happens to the flagged ones: they are marked in their metadata, kept out of exports
+// or announced on the event bus.
+package moderation
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/textdiff"
+	"time"
+	"unicode/utf8"
+)
+
+// Verdict is what a backend reports about a text.
+type Verdict struct {
+	// Scores maps each category to the confidence of the backend, 0 to 1.
+	Scores map[string]float64
+	// Flagged are the categories the backend flags by its own thresholds.
+	Flagged []string
+}
+
+// Moderator classifies text with one backend.
+type Moderator interface {
+	// Name of the backend, stored with the results.
+	Name() string
+	Moderate(text string) (Verdict, error)
+}
+
+// DefaultTimeout bounds a moderation request when none is configured.
+const DefaultTimeout = time.Minute
+
+// maxChunkLength in characters is the most text sent per request, longer transcriptions are
+// split at sentence ends and their verdicts merged.
+const maxChunkLength = 4000
+
+// Backends are the backends New creates.
+var Backends = []string{"openai", "http"}
+
+// New creates the moderator configured in config.yaml.
+func New(cfg config.ModerationConfig) (Moderator, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	switch cfg.Backend {
+	case "openai", "":
+		return NewOpenAIModerator(cfg.Model, cfg.URL, timeout)
+	case "http":
+		return NewHTTPModerator(cfg.URL, cfg.Headers, timeout)
+	default:
+		return nil, fmt.Errorf("unknown moderation backend %q, supported: %s", cfg.Backend, strings.Join(Backends, ", "))
+	}
+}
+
+// Action is what the policy does with a flagged transcription.
+type Action string
+
+const (
+	// ActionFlag sets FlagKey in the metadata of the transcription to its flagged categories.
+	ActionFlag Action = "flag"
+	// ActionBlockExport keeps the transcription out of export and re-export.
+	ActionBlockExport Action = "block_export"
+	// ActionNotify publishes a transcription.flagged event.
+	ActionNotify Action = "notify"
+)
+
+// Actions are the actions a policy can take.
+var Actions = []Action{ActionFlag, ActionBlockExport, ActionNotify}
+
+// FlagKey is the metadata key ActionFlag sets to the flagged categories, it is removed once a new
+// result is clean.
+const FlagKey = "moderation"
+
+// Policy decides which categories are flagged and what happens to flagged transcriptions.
+type Policy struct {
+	// Threshold flags the categories scoring at least this much, the backend decides when it is zero.
+	Threshold float64
+	Actions   []Action
+}
+
+// NewPolicy returns the policy of cfg, it takes ActionFlag when no action is configured.
+func NewPolicy(cfg config.ModerationConfig) (Policy, error) {
+	if cfg.Threshold < 0 || cfg.Threshold > 1 {
+		return Policy{}, fmt.Errorf("moderation threshold %v is not between 0 and 1", cfg.Threshold)
+	}
+	p := Policy{Threshold: cfg.Threshold}
+	for _, name := range cfg.Actions {
+		a := Action(name)
+		if !known(a) {
+			return Policy{}, fmt.Errorf("unknown moderation action %q, supported: flag, block_export, notify", name)
+		}
+		p.Actions = append(p.Actions, a)
+	}
+	if len(p.Actions) == 0 {
+		p.Actions = []Action{ActionFlag}
+	}
+	return p, nil
+}
+
+func known(a Action) bool {
+	for _, action := range Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Has reports whether the policy takes action a.
+func (p Policy) Has(a Action) bool {
+	for _, action := range p.Actions {
+		if action == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Flagged returns the sorted categories of v the policy flags.
+func (p Policy) Flagged(v Verdict) []string {
+	flagged := make([]string, 0)
+	if p.Threshold == 0 {
+		flagged = append(flagged, v.Flagged...)
+	} else {
+		for category, score := range v.Scores {
+			if score >= p.Threshold {
+				flagged = append(flagged, category)
+			}
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
+
+// Moderate classifies text with m, chunk by chunk when it is longer than one request takes. A category
+// scores its highest score of the chunks and is flagged when a chunk flagged it.
+func Moderate(m Moderator, text string) (Verdict, error) {
+	merged := Verdict{Scores: make(map[string]float64)}
+	seen := make(map[string]bool)
+	for i, chunk := range chunks(text, maxChunkLength) {
+		v, err := m.Moderate(chunk)
+		if err != nil {
+			return Verdict{}, fmt.Errorf("moderate chunk %d failed: %w", i+1, err)
+		}
+		for category, score := range v.Scores {
+			if score > merged.Scores[category] {
+				merged.Scores[category] = score
+			}
+		}
+		for _, category := range v.Flagged {
+			if !seen[category] {
+				seen[category] = true
+				merged.Flagged = append(merged.Flagged, category)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// chunks splits text at sentence ends into pieces of at most maxLength characters,
+// a single sentence longer than that is a piece of its own.
+func chunks(text string, maxLength int) []string {
+	var pieces []string
+	var current string
+	for _, s := range textdiff.Sentences(text) {
+		if current != "" && utf8.RuneCountInString(current)+1+utf8.RuneCountInString(s) > maxLength {
+			pieces = append(pieces, current)
+			current = ""
+		}
+		if current != "" {
+			current += " "
+		}
+		current += s
+	}
+	if current != "" {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+// Options select the transcriptions Transcriptions screens.
+type Options struct {
+	User string
+	// Force screens transcriptions again whose result is newer than their text.
+	Force  bool
+	Policy Policy
+}
+
+// Result counts what Transcriptions did.
+type Result struct {
+	Checked int
+	Flagged int
+	// Skipped transcriptions have no text, or a result newer than their text.
+	Skipped int
+	Failed  int
+}
+
+// Transcriptions screens every transcription of the user, stores the results in moderations and applies
+// the policy to the flagged ones. bus may be nil when the policy doesn't notify. A failed transcription
+// is logged and counted, the others go on.
+func Transcriptions(db repository.TranscriptionDAO, moderations repository.ModerationDAO, m Moderator, bus events.Bus, opts Options) (Result, error) {
+	var result Result
+	transcriptions, err := db.GetAllByUser(opts.User)
+	if err != nil {
+		return result, fmt.Errorf("get transcriptions of %s failed: %v", opts.User, err)
+	}
+
+	for _, tr := range transcriptions {
+		if strings.TrimSpace(tr.Transcription) == "" {
+			result.Skipped++
+			continue
+		}
+		if !opts.Force {
+			previous, err := moderations.GetModeration(tr.ID)
+			if err == nil && !previous.CheckedAt.Before(tr.LastConversionTime) {
+				result.Skipped++
+				continue
+			}
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return result, fmt.Errorf("get moderation of %d failed: %v", tr.ID, err)
+			}
+		}
+
+		v, err := Moderate(m, tr.Transcription)
+		if err != nil {
+			log.Printf("Moderating transcription %d (%s) failed: %v\n", tr.ID, tr.Mp3FileName, err)
+			result.Failed++
+			continue
+		}
+		moderation := model.Moderation{
+			TranscriptionID: tr.ID,
+			Backend:         m.Name(),
+			Scores:          v.Scores,
+			Flagged:         opts.Policy.Flagged(v),
+			CheckedAt:       time.Now(),
+		}
+		if err = moderations.SaveModeration(moderation); err != nil {
+			return result, fmt.Errorf("save moderation of %d failed: %v", tr.ID, err)
+		}
+		result.Checked++
+		if moderation.IsFlagged() {
+			result.Flagged++
+		}
+		apply(db, bus, opts.Policy, tr, moderation)
+	}
+	return result, nil
+}
+
+// apply takes the actions of the policy on a screened transcription, a failing action is logged.
+func apply(db repository.TranscriptionDAO, bus events.Bus, p Policy, tr model.Transcription, m model.Moderation) {
+	if p.Has(ActionFlag) {
+		if dao, ok := db.(repository.MetadataDAO); ok {
+			err := dao.SetMetadata(tr.ID, map[string]string{FlagKey: strings.Join(m.Flagged, ",")})
+			if err != nil {
+				log.Printf("Error flagging transcription %d: %v\n", tr.ID, err)
+			}
+		} else if m.IsFlagged() {
+			log.Printf("The database does not keep metadata, transcription %d is not flagged\n", tr.ID)
+		}
+	}
+	if p.Has(ActionNotify) && m.IsFlagged() && bus != nil {
+		bus.Publish(events.Event{
+			Topic:           events.TopicTranscriptionFlagged,
+			User:            tr.User,
+			FilePath:        tr.Mp3FileName,
+			TranscriptionID: tr.ID,
+		})
+	}
+}
+
+// Blocked reports whether moderations flagged the transcription, so a policy with ActionBlockExport
+// keeps it from being exported. A transcription that was never screened isn't blocked.
+func Blocked(moderations repository.ModerationDAO, transcriptionID int) (bool, error) {
+	m, err := moderations.GetModeration(transcriptionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return m.IsFlagged(), nil
+}
+
+// ExportBlocker returns the results to block exports with when the policy of cfg has ActionBlockExport,
+// nil otherwise. It fails when the policy is invalid or db can't keep moderation results.
+func ExportBlocker(cfg config.ModerationConfig, db repository.TranscriptionDAO) (repository.ModerationDAO, error) {
+	p, err := NewPolicy(cfg)
+	if err != nil || !p.Has(ActionBlockExport) {
+		return nil, err
+	}
+	moderations, ok := db.(repository.ModerationDAO)
+	if !ok {
+		return nil, errors.New("moderation blocks exports, but the database does not keep moderation results")
+	}
+	return moderations, nil
+}
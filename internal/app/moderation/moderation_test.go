@@ -0,0 +1,206 @@
+package moderation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+// keywordModerator scores violence 0.9 for text containing fight, it fails on text containing fail.
+type keywordModerator struct {
+	calls int
+}
+
+func (k *keywordModerator) Name() string { return "keywords" }
+
+func (k *keywordModerator) Moderate(text string) (Verdict, error) {
+	k.calls++
+	if strings.Contains(text, "fail") {
+		return Verdict{}, errors.New("classifier down")
+	}
+	v := Verdict{Scores: map[string]float64{"violence": 0.1, "hate": 0.3}}
+	if strings.Contains(text, "fight") {
+		v.Scores["violence"] = 0.9
+		v.Flagged = []string{"violence"}
+	}
+	return v, nil
+}
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.ModerationConfig
+		want    Policy
+		wantErr bool
+	}{
+		{name: "default", want: Policy{Actions: []Action{ActionFlag}}},
+		{name: "actions", cfg: config.ModerationConfig{Threshold: 0.5, Actions: []string{"block_export", "notify"}},
+			want: Policy{Threshold: 0.5, Actions: []Action{ActionBlockExport, ActionNotify}}},
+		{name: "unknown action", cfg: config.ModerationConfig{Actions: []string{"delete"}}, wantErr: true},
+		{name: "threshold out of range", cfg: config.ModerationConfig{Threshold: 2}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPolicy(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_Flagged(t *testing.T) {
+	v := Verdict{Scores: map[string]float64{"violence": 0.6, "hate": 0.3, "sexual": 0.01}, Flagged: []string{"violence"}}
+	tests := []struct {
+		threshold float64
+		want      []string
+	}{
+		{threshold: 0, want: []string{"violence"}},
+		{threshold: 0.2, want: []string{"hate", "violence"}},
+		{threshold: 0.9, want: []string{}},
+	}
+	for _, tt := range tests {
+		if got := (Policy{Threshold: tt.threshold}).Flagged(v); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Flagged() with threshold %v = %v, want %v", tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestModerate_Chunks(t *testing.T) {
+	k := &keywordModerator{}
+	text := strings.Repeat("A calm sentence. ", 300) + "Then a fight starts."
+	v, err := Moderate(k, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.calls < 2 || v.Scores["violence"] != 0.9 || !reflect.DeepEqual(v.Flagged, []string{"violence"}) {
+		t.Errorf("Moderate() = %+v after %d calls, want the highest score of the chunks", v, k.calls)
+	}
+}
+
+func TestOpenAIModerator(t *testing.T) {
+	var request map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/moderations" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"results": [{"flagged": true, "categories": {"harassment": true, "violence": false},
+			"category_scores": {"harassment": 0.8, "violence": 0.2}}]}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	m, err := NewOpenAIModerator("omni-moderation-latest", srv.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := m.Moderate("some text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request["input"] != "some text" || request["model"] != "omni-moderation-latest" {
+		t.Errorf("request = %v", request)
+	}
+	if v.Scores["harassment"] != 0.8 || !reflect.DeepEqual(v.Flagged, []string{"harassment"}) {
+		t.Errorf("Moderate() = %+v", v)
+	}
+}
+
+func TestHTTPModerator(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"scores": {"spam": 0.7}}`))
+	}))
+	defer srv.Close()
+
+	m, err := NewHTTPModerator(srv.URL, map[string]string{"X-Token": "secret"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.Moderate("buy now"); err != nil || v.Scores["spam"] != 0.7 || len(v.Flagged) != 0 {
+		t.Errorf("Moderate() = %+v, %v", v, err)
+	}
+
+	m, _ = NewHTTPModerator(srv.URL, nil, time.Second)
+	if _, err = m.Moderate("buy now"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Moderate() without the header error = %v, want the status", err)
+	}
+	if _, err = NewHTTPModerator("", nil, time.Second); err == nil {
+		t.Error("NewHTTPModerator() without url succeeded, want an error")
+	}
+}
+
+func TestTranscriptions(t *testing.T) {
+	db := memory.NewMemoryDB()
+	now := time.Now()
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "A fight broke out.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 60, "A quiet afternoon.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 60, "This will fail.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "d.mp4", "d.mp3", 60, "Another fight.", now, 0, "", model.ProviderMetadata{})
+	db.SetMetadata(2, map[string]string{FlagKey: "violence"})
+
+	bus := events.NewInProcessBus()
+	flagged := make(chan events.Event, 4)
+	bus.Subscribe(events.TopicTranscriptionFlagged, func(e events.Event) { flagged <- e })
+
+	k := &keywordModerator{}
+	opts := Options{User: "alice", Policy: Policy{Actions: []Action{ActionFlag, ActionNotify}}}
+	result, err := Transcriptions(db, db, k, bus, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Result{Checked: 2, Flagged: 1, Failed: 1}); result != want {
+		t.Errorf("Transcriptions() = %+v, want %+v", result, want)
+	}
+
+	tests := []struct {
+		id       int
+		wantFlag string
+	}{
+		{id: 1, wantFlag: "violence"},
+		{id: 2, wantFlag: ""},
+	}
+	for _, tt := range tests {
+		m, err := db.GetModeration(tt.id)
+		if err != nil || m.Backend != "keywords" || m.IsFlagged() != (tt.wantFlag != "") {
+			t.Errorf("GetModeration(%d) = %+v, %v", tt.id, m, err)
+		}
+		if metadata, _ := db.GetMetadata(tt.id); metadata[FlagKey] != tt.wantFlag {
+			t.Errorf("metadata of %d = %v, want %s = %q", tt.id, metadata, FlagKey, tt.wantFlag)
+		}
+	}
+	select {
+	case e := <-flagged:
+		if e.TranscriptionID != 1 || e.User != "alice" {
+			t.Errorf("published %+v, want transcription 1", e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no %s event published", events.TopicTranscriptionFlagged)
+	}
+
+	k.calls = 0
+	result, err = Transcriptions(db, db, k, bus, opts)
+	if err != nil || result.Skipped != 2 || k.calls != 1 {
+		t.Errorf("Transcriptions() again = %+v, %v after %d calls, want the screened ones skipped", result, err, k.calls)
+	}
+	if result, err = Transcriptions(db, db, k, nil, Options{User: "alice", Force: true}); err != nil || result.Checked != 2 {
+		t.Errorf("Transcriptions() with force = %+v, %v, want 2 checked", result, err)
+	}
+}
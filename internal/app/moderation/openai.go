@@ -0,0 +1,63 @@
+package moderation
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"tiktok-whisper/internal/app/config/secrets"
+	"time"
+)
+
+const defaultOpenAIURL = "https://api.openai.com/v1"
+
+// OpenAIModerator classifies with the moderation endpoint of the OpenAI API. Its categories are
+// decoded as they come, so categories added to the API are stored without a change here.
+type OpenAIModerator struct {
+	url    string
+	model  string
+	key    string
+	client *http.Client
+}
+
+// NewOpenAIModerator creates a new OpenAIModerator instance, the key is resolved by secrets.Key.
+// The API picks the model when model is empty, url replaces the API endpoint when it is set.
+func NewOpenAIModerator(model string, url string, timeout time.Duration) (*OpenAIModerator, error) {
+	key, err := secrets.Key("openai")
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		url = defaultOpenAIURL
+	}
+	return &OpenAIModerator{url: strings.TrimSuffix(url, "/"), model: model, key: key, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (o *OpenAIModerator) Name() string { return "openai" }
+
+func (o *OpenAIModerator) Moderate(text string) (Verdict, error) {
+	request := map[string]string{"input": text}
+	if o.model != "" {
+		request["model"] = o.model
+	}
+	var response struct {
+		Results []struct {
+			Categories     map[string]bool    `json:"categories"`
+			CategoryScores map[string]float64 `json:"category_scores"`
+		} `json:"results"`
+	}
+	if err := postJSON(o.client, o.url+"/moderations", map[string]string{"Authorization": "Bearer " + o.key}, request, &response); err != nil {
+		return Verdict{}, err
+	}
+	if len(response.Results) == 0 {
+		return Verdict{}, errors.New("empty moderation response")
+	}
+
+	result := response.Results[0]
+	v := Verdict{Scores: result.CategoryScores}
+	for category, flagged := range result.Categories {
+		if flagged {
+			v.Flagged = append(v.Flagged, category)
+		}
+	}
+	return v, nil
+}
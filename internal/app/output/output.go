@@ -0,0 +1,73 @@
+// Package output selects how the commands of v2t print their results: as text for humans on stdout, or
+// with --json as a JSON document on stdout for scripts, the text for humans going to stderr then.
+// --quiet drops that text and --no-emoji the decorations, for cron jobs and CI.
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+var (
+	jsonMode bool
+	quiet    bool
+	noEmoji  bool
+	// stdout and stderr are replaced in tests.
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// SetJSON turns the JSON mode on or off, root sets it from --json.
+func SetJSON(enabled bool) {
+	jsonMode = enabled
+}
+
+// JSON reports whether the results are printed as JSON.
+func JSON() bool {
+	return jsonMode
+}
+
+// SetQuiet turns the quiet mode on or off, root sets it from --quiet.
+func SetQuiet(enabled bool) {
+	quiet = enabled
+}
+
+// Quiet reports whether only errors and results are printed.
+func Quiet() bool {
+	return quiet
+}
+
+// SetNoEmoji turns the decorations off or on, root sets it from --no-emoji.
+func SetNoEmoji(enabled bool) {
+	noEmoji = enabled
+}
+
+// Decorate reports whether decorations like emoji and sparklines may be printed, they are left out
+// with --no-emoji and --quiet.
+func Decorate() bool {
+	return !noEmoji && !quiet
+}
+
+// Text returns where text for humans goes that isn't the result: notes, progress and hints.
+// It is stdout, stderr in JSON mode so stdout only holds the JSON, and nowhere in quiet mode.
+func Text() io.Writer {
+	if quiet {
+		return io.Discard
+	}
+	if jsonMode {
+		return stderr
+	}
+	return stdout
+}
+
+// Print prints the result of a command: v as indented JSON on stdout in JSON mode, otherwise the text
+// that text writes to w, which is stdout.
+func Print(v interface{}, text func(w io.Writer) error) error {
+	if !jsonMode {
+		return text(stdout)
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
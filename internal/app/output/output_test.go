@@ -0,0 +1,51 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestPrint(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       bool
+		quiet      bool
+		wantStdout string
+		wantStderr string
+	}{
+		{name: "text", wantStdout: "note\n2 files\n"},
+		{name: "json", json: true, wantStdout: "{\n  \"files\": 2\n}\n", wantStderr: "note\n"},
+		{name: "quiet", quiet: true, wantStdout: "2 files\n"},
+		{name: "quiet json", json: true, quiet: true, wantStdout: "{\n  \"files\": 2\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			originalOut, originalErr := stdout, stderr
+			stdout, stderr = &out, &errOut
+			SetJSON(tt.json)
+			SetQuiet(tt.quiet)
+			t.Cleanup(func() {
+				stdout, stderr = originalOut, originalErr
+				SetJSON(false)
+				SetQuiet(false)
+			})
+
+			fmt.Fprintln(Text(), "note")
+			err := Print(struct {
+				Files int `json:"files"`
+			}{Files: 2}, func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, "2 files")
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Print() error = %v", err)
+			}
+			if out.String() != tt.wantStdout || errOut.String() != tt.wantStderr {
+				t.Errorf("stdout = %q, stderr = %q, want %q and %q", out.String(), errOut.String(), tt.wantStdout, tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package paragraph
+
+import (
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/util/textdiff"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Options tune how segments are grouped, see config.ParagraphConfig.
+type Options struct {
+	// MaxLength is the number of characters after which a paragraph ends at the next
+	// sentence boundary, zero disables the limit.
+	MaxLength int
+	// Pause in seconds between two timed segments that starts a new paragraph, zero disables it.
+	Pause float64
+}
+
+// DefaultOptions are used when nothing is configured.
+var DefaultOptions = Options{MaxLength: 300, Pause: 2}
+
+// Paragraph is a run of consecutive segments of the same speaker.
+type Paragraph struct {
+	Speaker  string
+	Segments []model.Segment
+}
+
+// Start returns the start of the first segment in seconds.
+func (p Paragraph) Start() float64 {
+	return p.Segments[0].Start
+}
+
+// End returns the end of the last segment in seconds.
+func (p Paragraph) End() float64 {
+	return p.Segments[len(p.Segments)-1].End
+}
+
+// Text joins the segment texts, with a space only between words of space separated scripts.
+func (p Paragraph) Text() string {
+	var sb strings.Builder
+	for _, s := range p.Segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		if sb.Len() > 0 && NeedsSpace(sb.String(), text) {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// Segments returns the segments of t, or its sentences as untimed segments when
+// the provider reported no segments.
+func Segments(t model.Transcription) []model.Segment {
+	if len(t.Segments) > 0 {
+		return t.Segments
+	}
+
+	sentences := textdiff.Sentences(t.Transcription)
+	segments := make([]model.Segment, len(sentences))
+	for i, s := range sentences {
+		segments[i] = model.Segment{Text: s}
+	}
+	return segments
+}
+
+// Split groups segments into paragraphs. A paragraph ends when the speaker changes,
+// when the pause before the next segment reaches opts.Pause, or at the first sentence
+// boundary past opts.MaxLength; a paragraph without any boundary is cut at twice the limit.
+func Split(segments []model.Segment, opts Options) []Paragraph {
+	paragraphs := make([]Paragraph, 0)
+	var current *Paragraph
+	length := 0
+
+	for _, s := range segments {
+		if strings.TrimSpace(s.Text) == "" {
+			continue
+		}
+
+		if current != nil && breakBefore(current, length, s, opts) {
+			paragraphs = append(paragraphs, *current)
+			current = nil
+		}
+		if current == nil {
+			current = &Paragraph{Speaker: s.Speaker}
+			length = 0
+		}
+
+		current.Segments = append(current.Segments, s)
+		length += utf8.RuneCountInString(strings.TrimSpace(s.Text))
+	}
+
+	if current != nil {
+		paragraphs = append(paragraphs, *current)
+	}
+	return paragraphs
+}
+
+func breakBefore(p *Paragraph, length int, next model.Segment, opts Options) bool {
+	last := p.Segments[len(p.Segments)-1]
+
+	if next.Speaker != "" && next.Speaker != p.Speaker {
+		return true
+	}
+	if opts.Pause > 0 && last.Timed() && next.Timed() && next.Start-last.End >= opts.Pause {
+		return true
+	}
+	if opts.MaxLength > 0 {
+		if length >= opts.MaxLength && endsSentence(last.Text) {
+			return true
+		}
+		if length >= 2*opts.MaxLength {
+			return true
+		}
+	}
+	return false
+}
+
+func endsSentence(text string) bool {
+	r, _ := utf8.DecodeLastRuneInString(strings.TrimSpace(text))
+	return strings.ContainsRune("。！？!?.…", r)
+}
+
+// NeedsSpace reports whether a space separates before and after, CJK text is written without one.
+func NeedsSpace(before, after string) bool {
+	last, _ := utf8.DecodeLastRuneInString(before)
+	first, _ := utf8.DecodeRuneInString(after)
+	return !isCJK(last) && !isCJK(first)
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
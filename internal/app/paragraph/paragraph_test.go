@@ -0,0 +1,88 @@
+package paragraph
+
+import (
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+)
+
+func texts(paragraphs []Paragraph) []string {
+	got := make([]string, len(paragraphs))
+	for i, p := range paragraphs {
+		got[i] = p.Text()
+	}
+	return got
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []model.Segment
+		opts     Options
+		want     []string
+	}{
+		{
+			name:     "empty",
+			segments: nil,
+			opts:     DefaultOptions,
+			want:     []string{},
+		},
+		{
+			name: "speaker turns",
+			segments: []model.Segment{
+				{Speaker: "A", Text: "Hello."}, {Speaker: "A", Text: "How are you?"},
+				{Speaker: "B", Text: "Fine."}, {Text: "Thanks."},
+			},
+			opts: DefaultOptions,
+			want: []string{"Hello. How are you?", "Fine. Thanks."},
+		},
+		{
+			name: "pause",
+			segments: []model.Segment{
+				{Start: 0, End: 2, Text: "第一句。"}, {Start: 2.5, End: 4, Text: "第二句。"},
+				{Start: 7, End: 9, Text: "第三句。"},
+			},
+			opts: Options{Pause: 2},
+			want: []string{"第一句。第二句。", "第三句。"},
+		},
+		{
+			name: "max length waits for a sentence boundary",
+			segments: []model.Segment{
+				{Text: "one two"}, {Text: "three four."}, {Text: "five."}, {Text: "six."},
+			},
+			opts: Options{MaxLength: 10},
+			want: []string{"one two three four.", "five. six."},
+		},
+		{
+			name:     "hard limit without boundary",
+			segments: []model.Segment{{Text: "aaaa"}, {Text: "bbbb"}, {Text: "cccc"}},
+			opts:     Options{MaxLength: 4},
+			want:     []string{"aaaa bbbb", "cccc"},
+		},
+		{
+			name:     "untimed segments ignore pauses",
+			segments: []model.Segment{{Text: "a."}, {Text: "b."}},
+			opts:     Options{Pause: 1},
+			want:     []string{"a. b."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := texts(Split(tt.segments, tt.opts)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegments(t *testing.T) {
+	stored := []model.Segment{{Start: 0, End: 1, Text: "stored"}}
+	if got := Segments(model.Transcription{Transcription: "a. b.", Segments: stored}); !reflect.DeepEqual(got, stored) {
+		t.Errorf("Segments() = %v, want the stored segments", got)
+	}
+
+	want := []model.Segment{{Text: "a."}, {Text: "b."}}
+	if got := Segments(model.Transcription{Transcription: "a. b."}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Segments() = %v, want %v", got, want)
+	}
+}
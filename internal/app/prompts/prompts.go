@@ -0,0 +1,243 @@
+// Package prompts keeps the prompts v2t sends to language models as Go text/template files. Every
+// feature has a built-in template, a file named after the feature in the prompts directory of the
+// config directory replaces it, e.g. prompts/summary.tmpl, so prompts are edited without a rebuild.
+package prompts
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+
+	"github.com/samber/lo"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// Extension is the extension of the template files.
+const Extension = ".tmpl"
+
+// Feature is a feature prompting a language model, it names its template.
+type Feature string
+
+const (
+	Summary     Feature = "summary"
+	Title       Feature = "title"
+	ActionItems Feature = "action_items"
+	Chapters    Feature = "chapters"
+	// Condense shortens a part of a transcript longer than the budget of a feature, see llm.Condense.
+	Condense Feature = "condense"
+	// QA is the system prompt of v2t chat, with the excerpts relevant to the question.
+	QA Feature = "qa"
+	// Ask is the system prompt of v2t ask, with the excerpts of the transcripts of a user relevant to
+	// the question.
+	Ask Feature = "ask"
+	// Translation is the system prompt of v2t translate, the transcript is sent as the user message.
+	Translation Feature = "translation"
+)
+
+// Features are all features, sorted by name.
+var Features = []Feature{ActionItems, Ask, Chapters, Condense, QA, Summary, Title, Translation}
+
+// Builtin marks the templates v2t ships with in Source.
+const Builtin = "built-in"
+
+// Data are the variables of the templates, e.g. {{.Transcript}}. Excerpts are only set for qa and
+// ask, and Target only for translation.
+type Data struct {
+	Transcript string
+	User       string
+	// Language is the language of the transcript, e.g. zh, empty when the provider didn't report it.
+	Language string
+	// File is the name of the transcribed file.
+	File string
+	// Segments are the timed segments of the transcript, empty when it was stored without timestamps.
+	Segments []Segment
+	Excerpts []Excerpt
+	// Target is the language to translate into, e.g. en.
+	Target string
+}
+
+// Segment is a timed part of the transcript.
+type Segment struct {
+	// Start is formatted like 3:15 or 1:02:03.
+	Start string
+	Text  string
+}
+
+// Excerpt is a part of the transcript relevant to a question, cited by its Index.
+type Excerpt struct {
+	Index int
+	Text  string
+	// File is the name of the transcribed file of the excerpt, only set for ask.
+	File string
+	// Start is formatted like Segment.Start, empty when the transcript has no timestamps or for qa.
+	Start string
+}
+
+// NewData returns the variables describing t, its timed segments included.
+func NewData(t model.Transcription) Data {
+	d := Data{
+		Transcript: t.Transcription,
+		User:       t.User,
+		Language:   lo.Ternary(t.ProviderMetadata.Language != "", t.ProviderMetadata.Language, t.ProviderMetadata.DetectedLanguage),
+		File:       t.Mp3FileName,
+	}
+	for _, s := range t.Segments {
+		if s.Timed() {
+			d.Segments = append(d.Segments, Segment{Start: Timestamp(s.Start), Text: s.Text})
+		}
+	}
+	return d
+}
+
+// Timestamp formats seconds like the chapters of YouTube descriptions, e.g. 3:15 or 1:02:03.
+func Timestamp(seconds float64) string {
+	s := int(seconds)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// sample fills every variable, Validate renders the templates with it.
+var sample = Data{
+	Transcript: "Welcome back. Today we talk about pricing.",
+	User:       "alice",
+	Language:   "en",
+	File:       "episode.mp3",
+	Segments:   []Segment{{Start: "0:00", Text: "Welcome back."}, {Start: "0:02", Text: "Today we talk about pricing."}},
+	Excerpts:   []Excerpt{{Index: 1, Text: "Today we talk about pricing.", File: "episode.mp3", Start: "0:02"}},
+	Target:     "zh",
+}
+
+// Set holds the template of every feature.
+type Set struct {
+	templates map[Feature]*template.Template
+	sources   map[Feature]string
+}
+
+// Dir returns the directory of the templates replacing the built-in ones, prompts in the config directory.
+func Dir() string {
+	return filepath.Join(config.Dir(), "prompts")
+}
+
+// BuiltinText returns the built-in template of f.
+func BuiltinText(f Feature) (string, error) {
+	data, err := builtinTemplates.ReadFile("templates/" + string(f) + Extension)
+	if err != nil {
+		return "", fmt.Errorf("unknown prompt feature %q", f)
+	}
+	return string(data), nil
+}
+
+// Load returns the built-in templates replaced by those in dir, none are replaced when dir doesn't
+// exist. A template that fails to parse or render keeps the built-in one and its error is returned
+// with the set.
+func Load(dir string) (*Set, error) {
+	s := &Set{templates: map[Feature]*template.Template{}, sources: map[Feature]string{}}
+	var errs []error
+	for _, f := range Features {
+		text, err := BuiltinText(f)
+		if err != nil {
+			return nil, err
+		}
+		builtin, err := parse(f, text)
+		if err != nil {
+			panic(fmt.Sprintf("built-in prompt %s: %v", f, err))
+		}
+		s.templates[f], s.sources[f] = builtin, Builtin
+
+		path := filepath.Join(dir, string(f)+Extension)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		t, err := parse(f, string(data))
+		if err == nil {
+			// fields that don't exist only fail when the template runs
+			err = t.Execute(&strings.Builder{}, sample)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", path, err))
+			continue
+		}
+		s.templates[f], s.sources[f] = t, path
+	}
+	return s, errors.Join(errs...)
+}
+
+func parse(f Feature, text string) (*template.Template, error) {
+	return template.New(string(f)).Option("missingkey=error").Parse(text)
+}
+
+// Validate checks the templates in dir: they must parse and render, and be named after a feature.
+func Validate(dir string) error {
+	_, err := Load(dir)
+	errs := []error{err}
+
+	paths, globErr := filepath.Glob(filepath.Join(dir, "*"+Extension))
+	if globErr != nil {
+		return globErr
+	}
+	for _, path := range paths {
+		f := Feature(strings.TrimSuffix(filepath.Base(path), Extension))
+		if !lo.Contains(Features, f) {
+			names := lo.Map(Features, func(f Feature, i int) string { return string(f) })
+			errs = append(errs, fmt.Errorf("%s: unknown prompt feature %q, expected one of %s", path, f, strings.Join(names, ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Render renders the template of f with data, without leading and trailing blank space.
+func (s *Set) Render(f Feature, data Data) (string, error) {
+	t, ok := s.templates[f]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt feature %q", f)
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("render %s prompt failed: %v", f, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// Source returns the path of the template used for f, or Builtin.
+func (s *Set) Source(f Feature) string {
+	return s.sources[f]
+}
+
+var (
+	loaded     *Set
+	loadedOnce sync.Once
+)
+
+// Get returns the templates of Dir, loaded once. Templates that fail are logged and the built-in
+// ones used instead.
+func Get() *Set {
+	loadedOnce.Do(func() {
+		var err error
+		if loaded, err = Load(Dir()); err != nil {
+			logging.L().Error("Invalid prompt templates, using the built-in ones instead", "dir", Dir(), "error", err)
+		}
+	})
+	return loaded
+}
+
+// Render renders the template of f with data, see Get.
+func Render(f Feature, data Data) (string, error) {
+	return Get().Render(f, data)
+}
@@ -0,0 +1,103 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestBuiltin(t *testing.T) {
+	s, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	for _, f := range Features {
+		got, err := s.Render(f, sample)
+		if err != nil || got == "" || s.Source(f) != Builtin {
+			t.Errorf("Render(%s) = %q, %v from %s, want the built-in prompt", f, got, err, s.Source(f))
+		}
+	}
+
+	got, _ := s.Render(QA, Data{File: "a.mp3", User: "bob", Excerpts: []Excerpt{{Index: 2, Text: "prices rise"}}})
+	if !strings.HasPrefix(got, `You answer questions about the transcript of "a.mp3" by bob.`) || !strings.HasSuffix(got, "[2] prices rise") {
+		t.Errorf("Render(qa) = %q", got)
+	}
+	got, _ = s.Render(Ask, Data{User: "bob", Excerpts: []Excerpt{{Index: 1, File: "a.mp3", Start: "3:15", Text: "prices rise"}, {Index: 2, File: "b.mp3", Text: "untimed"}}})
+	if !strings.Contains(got, "[1] \"a.mp3\" at 3:15\nprices rise") || !strings.HasSuffix(got, "[2] \"b.mp3\"\nuntimed") {
+		t.Errorf("Render(ask) = %q", got)
+	}
+	got, _ = s.Render(Chapters, Data{Transcript: "untimed"})
+	if !strings.HasSuffix(got, "untimed") {
+		t.Errorf("Render(chapters) without segments = %q, want the transcript", got)
+	}
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "override", template: "Sum up {{.User}}: {{.Transcript}}\n", want: "Sum up alice: hi"},
+		{name: "syntax error keeps the built-in", template: "{{.Transcript", wantErr: true},
+		{name: "unknown variable keeps the built-in", template: "{{.Speaker}}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "summary.tmpl"), []byte(tt.template), 0644); err != nil {
+				t.Fatal(err)
+			}
+			s, err := Load(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := s.Render(Summary, Data{User: "alice", Transcript: "hi"})
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if tt.wantErr {
+				if s.Source(Summary) != Builtin {
+					t.Errorf("Source() = %s, want %s", s.Source(Summary), Builtin)
+				}
+				return
+			}
+			if got != tt.want || s.Source(Summary) != filepath.Join(dir, "summary.tmpl") {
+				t.Errorf("Render() = %q from %s, want %q", got, s.Source(Summary), tt.want)
+			}
+			// the other features keep their built-in template
+			if s.Source(Title) != Builtin {
+				t.Errorf("Source(title) = %s, want %s", s.Source(Title), Builtin)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "title.tmpl"), []byte("Title {{.File}}"), 0644)
+	if err := Validate(dir); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "summery.tmpl"), []byte("{{.Transcript}}"), 0644)
+	if err := Validate(dir); err == nil || !strings.Contains(err.Error(), `unknown prompt feature "summery"`) {
+		t.Errorf("Validate() = %v, want the unknown feature", err)
+	}
+}
+
+func TestNewData(t *testing.T) {
+	d := NewData(model.Transcription{
+		User:             "alice",
+		Mp3FileName:      "a.mp3",
+		Transcription:    "hi there",
+		ProviderMetadata: model.ProviderMetadata{DetectedLanguage: "en"},
+		Segments:         []model.Segment{{Start: 0, End: 1, Text: "hi"}, {Start: 3725, End: 3726, Text: "there"}},
+	})
+	if d.Language != "en" || len(d.Segments) != 2 || d.Segments[1].Start != "1:02:05" {
+		t.Errorf("NewData() = %+v", d)
+	}
+}
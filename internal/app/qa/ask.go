@@ -0,0 +1,215 @@
+package qa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/embedding"
+	"tiktok-whisper/internal/app/embedding/provider"
+	"tiktok-whisper/internal/app/llm"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/prompts"
+	"tiktok-whisper/internal/app/repository"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultTopK is the number of passages a Library answers from unless another is given.
+	DefaultTopK = 5
+	// searchCandidates is the number of transcriptions, the nearest to the question in the vector
+	// index, whose passages are ranked.
+	searchCandidates = 8
+)
+
+// ErrNothingEmbedded is returned when the vector index has no transcription of the user.
+var ErrNothingEmbedded = errors.New("none of the transcriptions of the user is embedded")
+
+// Passage is a part of a transcription of the user relevant to a question.
+type Passage struct {
+	TranscriptionID int
+	File            string
+	// Start is the offset of the passage in seconds, zero when the transcription isn't Timed.
+	Start float64
+	Timed bool
+	Text  string
+	// Similarity is the cosine similarity of the embeddings of the passage and of the question.
+	Similarity float64
+}
+
+// Timestamp formats Start like the chapters of YouTube descriptions, empty when the passage isn't timed.
+func (p Passage) Timestamp() string {
+	if !p.Timed {
+		return ""
+	}
+	return prompts.Timestamp(p.Start)
+}
+
+// Library answers questions over all the transcriptions of a user. It finds the transcriptions
+// nearest to the question in the vector index, cuts them into passages along their segments and
+// answers from the passages whose embeddings are the most similar to the question.
+type Library struct {
+	db        repository.TranscriptionDAO
+	embedder  provider.Embedder
+	complete  Completer
+	batchSize int
+	budget    int
+}
+
+// NewLibrary creates a new Library over db. embedder must embed with the model of the vector index,
+// it embeds the passages in batches of batchSize, embedding.DefaultBatchSize when zero.
+func NewLibrary(db repository.TranscriptionDAO, embedder provider.Embedder, complete Completer, batchSize int) *Library {
+	if batchSize <= 0 {
+		batchSize = embedding.DefaultBatchSize
+	}
+	return &Library{db: db, embedder: embedder, complete: complete, batchSize: batchSize}
+}
+
+// SetBudget bounds the tokens of the passages of a request, the least similar ones are left out of
+// those that would exceed it, the most similar one is always sent. Zero sends them all.
+func (l *Library) SetBudget(tokens int) {
+	l.budget = tokens
+}
+
+// LibraryAnswer is the LLM reply and the passages it was given, cited from [1] in their order.
+type LibraryAnswer struct {
+	Text      string
+	Citations []Passage
+}
+
+// Ask answers question from the k passages of the transcriptions of user most relevant to it,
+// DefaultTopK when k is zero or less.
+func (l *Library) Ask(ctx context.Context, user string, question string, k int) (*LibraryAnswer, error) {
+	if k <= 0 {
+		k = DefaultTopK
+	}
+	passages, err := l.Retrieve(ctx, user, question, k)
+	if err != nil {
+		return nil, err
+	}
+	passages = l.fit(passages)
+
+	data := prompts.Data{User: user}
+	for i, p := range passages {
+		data.Excerpts = append(data.Excerpts, prompts.Excerpt{Index: i + 1, Text: p.Text, File: p.File, Start: p.Timestamp()})
+	}
+	system, err := prompts.Render(prompts.Ask, data)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := l.complete([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: system},
+		{Role: openai.ChatMessageRoleUser, Content: question},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ask llm failed: %v", err)
+	}
+	return &LibraryAnswer{Text: reply, Citations: passages}, nil
+}
+
+// Retrieve returns the k passages of the transcriptions of user most similar to question, the most
+// similar first. It fails with ErrNothingEmbedded when the index has none of the transcriptions of
+// user, and with the errors of embedding.Nearest.
+func (l *Library) Retrieve(ctx context.Context, user string, question string, k int) ([]Passage, error) {
+	vectors, err := l.embedder.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, fmt.Errorf("embed question failed: %v", err)
+	}
+	matches, err := embedding.Nearest(l.db, l.embedder.Name(), user, vectors[0], searchCandidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, ErrNothingEmbedded
+	}
+
+	var passages []Passage
+	for _, m := range matches {
+		t := m.Transcription
+		if segmentDAO, ok := l.db.(repository.SegmentDAO); ok && len(t.Segments) == 0 {
+			if t.Segments, err = segmentDAO.GetSegments(t.ID); err != nil {
+				return nil, fmt.Errorf("get segments of transcription %d failed: %v", t.ID, err)
+			}
+		}
+		passages = append(passages, cut(t)...)
+	}
+
+	for start := 0; start < len(passages); start += l.batchSize {
+		batch := passages[start:]
+		if len(batch) > l.batchSize {
+			batch = batch[:l.batchSize]
+		}
+		texts := make([]string, len(batch))
+		for i, p := range batch {
+			texts[i] = p.Text
+		}
+		embedded, err := l.embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("embed passages failed: %v", err)
+		}
+		for i := range batch {
+			batch[i].Similarity = embedding.Cosine(vectors[0], embedded[i])
+		}
+	}
+
+	sort.SliceStable(passages, func(i, j int) bool { return passages[i].Similarity > passages[j].Similarity })
+	if len(passages) > k {
+		passages = passages[:k]
+	}
+	return passages, nil
+}
+
+// fit returns the most similar passages within the budget.
+func (l *Library) fit(passages []Passage) []Passage {
+	if l.budget <= 0 {
+		return passages
+	}
+	used := 0
+	for i, p := range passages {
+		used += llm.EstimateTokens(p.Text)
+		if i > 0 && used > l.budget {
+			return passages[:i]
+		}
+	}
+	return passages
+}
+
+// cut splits t into passages of about chunkSize runes. A timed transcription is cut between its
+// segments so every passage starts at a timestamp, others like Split.
+func cut(t model.Transcription) []Passage {
+	var segments []model.Segment
+	for _, s := range t.Segments {
+		if s.Timed() && strings.TrimSpace(s.Text) != "" {
+			segments = append(segments, s)
+		}
+	}
+
+	var passages []Passage
+	if len(segments) == 0 {
+		for _, c := range Split(t.Transcription, chunkSize, chunkOverlap) {
+			passages = append(passages, Passage{TranscriptionID: t.ID, File: t.Mp3FileName, Text: c.Text})
+		}
+		return passages
+	}
+
+	var texts []string
+	size := 0
+	for i, s := range segments {
+		text := strings.TrimSpace(s.Text)
+		if len(texts) > 0 && size+len([]rune(text)) > chunkSize {
+			passages[len(passages)-1].Text = strings.Join(texts, " ")
+			texts, size = nil, 0
+		}
+		if len(texts) == 0 {
+			passages = append(passages, Passage{TranscriptionID: t.ID, File: t.Mp3FileName, Start: s.Start, Timed: true})
+		}
+		texts = append(texts, text)
+		size += len([]rune(text))
+		if i == len(segments)-1 {
+			passages[len(passages)-1].Text = strings.Join(texts, " ")
+		}
+	}
+	return passages
+}
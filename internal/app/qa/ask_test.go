@@ -0,0 +1,118 @@
+package qa
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/embedding"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// topicEmbedder embeds a text as the number of times it mentions each topic.
+type topicEmbedder struct{}
+
+var topics = []string{"pricing", "weather", "music"}
+
+func (topicEmbedder) Name() string    { return "fake/topics" }
+func (topicEmbedder) Dimensions() int { return len(topics) }
+
+func (topicEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, len(topics))
+		for j, topic := range topics {
+			vectors[i][j] = float32(strings.Count(strings.ToLower(text), topic))
+		}
+	}
+	return vectors, nil
+}
+
+func TestLibrary_Ask(t *testing.T) {
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	intro := strings.TrimSpace(strings.Repeat("welcome back ", 70))
+	tests := []struct {
+		name     string
+		user     string
+		question string
+		index    string
+		// want are the file and timestamp of the cited passage
+		want    [2]string
+		wantErr error
+	}{
+		{name: "timed passage", user: "alice", question: "What about pricing?", want: [2]string{"talk.mp3", "1:05"}},
+		{name: "untimed passage", user: "alice", question: "How was the weather?", want: [2]string{"notes.mp3", ""}},
+		{name: "index of another model", user: "alice", question: "pricing", index: "other/model", wantErr: embedding.ErrOtherModel},
+		{name: "nothing embedded", user: "carol", question: "pricing", wantErr: ErrNothingEmbedded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemoryDB()
+			talk := intro + " Our pricing changes, pricing is monthly now."
+			db.RecordToDB("alice", "/in", "talk.mp4", "talk.mp3", 90, talk, now, 0, "", model.ProviderMetadata{})
+			db.SaveSegments(1, []model.Segment{{Start: 0, End: 65, Text: intro}, {Start: 65, End: 70, Text: "Our pricing changes, pricing is monthly now."}})
+			db.RecordToDB("alice", "/in", "notes.mp4", "notes.mp3", 60, "The weather was nice, weather permitting we film outside.", now, 0, "", model.ProviderMetadata{})
+			db.RecordToDB("bob", "/in", "bob.mp4", "bob.mp3", 60, "pricing pricing pricing", now, 0, "", model.ProviderMetadata{})
+			db.RecordToDB("carol", "/in", "carol.mp4", "carol.mp3", 60, "pricing", now, 0, "", model.ProviderMetadata{})
+			for id := 1; id <= 3; id++ {
+				tr, _ := db.GetByID(id)
+				vectors, _ := topicEmbedder{}.Embed(context.Background(), []string{tr.Transcription})
+				db.SaveEmbedding(model.Embedding{TranscriptionID: id, Model: "fake/topics", Vector: vectors[0], EmbeddedAt: now})
+			}
+			index := tt.index
+			if index == "" {
+				index = "fake/topics"
+			}
+			db.SetIndexModel(index, false, now)
+
+			var system string
+			library := NewLibrary(db, topicEmbedder{}, func(messages []openai.ChatCompletionMessage) (string, error) {
+				system = messages[0].Content
+				return "See [1].", nil
+			}, 2)
+			answer, err := library.Ask(context.Background(), tt.user, tt.question, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Ask() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if len(answer.Citations) != 1 || answer.Citations[0].File != tt.want[0] || answer.Citations[0].Timestamp() != tt.want[1] {
+				t.Fatalf("Ask() citations = %+v, want %v", answer.Citations, tt.want)
+			}
+			cited := "[1] \"" + tt.want[0] + "\""
+			if tt.want[1] != "" {
+				cited += " at " + tt.want[1]
+			}
+			if !strings.Contains(system, cited+"\n"+answer.Citations[0].Text) || answer.Text != "See [1]." {
+				t.Errorf("system prompt = %q, want the passage cited as %s", system, cited)
+			}
+		})
+	}
+}
+
+func TestLibrary_SetBudget(t *testing.T) {
+	passages := []Passage{{Text: strings.Repeat("a", 400)}, {Text: strings.Repeat("b", 400)}, {Text: strings.Repeat("c", 400)}}
+	tests := []struct {
+		name   string
+		budget int
+		want   int
+	}{
+		{name: "unbounded", budget: 0, want: 3},
+		{name: "two fit", budget: 200, want: 2},
+		{name: "the most similar is always sent", budget: 1, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLibrary(nil, topicEmbedder{}, nil, 0)
+			l.SetBudget(tt.budget)
+			if got := l.fit(passages); len(got) != tt.want {
+				t.Errorf("fit() = %d passages, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
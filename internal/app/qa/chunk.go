@@ -0,0 +1,119 @@
+package qa
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Chunk is a window of a transcription used as LLM context.
+type Chunk struct {
+	Index int
+	// Start and End are rune offsets into the transcription text.
+	Start int
+	End   int
+	Text  string
+}
+
+// Split cuts text into chunks of at most size runes, consecutive chunks share overlap runes
+// so a sentence on a boundary is fully contained in at least one of them.
+func Split(text string, size int, overlap int) []Chunk {
+	runes := []rune(text)
+	if size <= 0 || len(runes) == 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []Chunk
+	for start := 0; start < len(runes); start += size - overlap {
+		end := start + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+
+		chunks = append(chunks, Chunk{
+			Index: len(chunks),
+			Start: start,
+			End:   end,
+			Text:  string(runes[start:end]),
+		})
+
+		if end == len(runes) {
+			break
+		}
+	}
+	return chunks
+}
+
+// Rank returns the k chunks sharing the most terms with question, in transcript order.
+func Rank(chunks []Chunk, question string, k int) []Chunk {
+	if k <= 0 || len(chunks) <= k {
+		return chunks
+	}
+
+	queryTerms := terms(question)
+	type scored struct {
+		chunk Chunk
+		score int
+	}
+	candidates := make([]scored, 0, len(chunks))
+	for _, c := range chunks {
+		chunkTerms := terms(c.Text)
+		score := 0
+		for t := range queryTerms {
+			if chunkTerms[t] {
+				score++
+			}
+		}
+		candidates = append(candidates, scored{chunk: c, score: score})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	top := make([]Chunk, 0, k)
+	for _, c := range candidates[:k] {
+		top = append(top, c.chunk)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		return top[i].Index < top[j].Index
+	})
+	return top
+}
+
+// terms splits latin text into lower cased words and CJK text into character bigrams,
+// which is good enough to match questions against mixed Chinese and English transcripts.
+func terms(text string) map[string]bool {
+	result := make(map[string]bool)
+
+	var word []rune
+	var prevHan rune
+	flushWord := func() {
+		if len(word) > 1 {
+			result[strings.ToLower(string(word))] = true
+		}
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			if prevHan != 0 {
+				result[string([]rune{prevHan, r})] = true
+			}
+			prevHan = r
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			prevHan = 0
+			word = append(word, r)
+		default:
+			prevHan = 0
+			flushWord()
+		}
+	}
+	flushWord()
+	return result
+}
@@ -0,0 +1,84 @@
+package qa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		size    int
+		overlap int
+		want    []string
+	}{
+		{
+			name: "empty",
+			text: "",
+			size: 4,
+		},
+		{
+			name: "single_chunk",
+			text: "abc",
+			size: 4,
+			want: []string{"abc"},
+		},
+		{
+			name:    "overlapping",
+			text:    "abcdefghij",
+			size:    4,
+			overlap: 1,
+			want:    []string{"abcd", "defg", "ghij"},
+		},
+		{
+			name: "runes_not_bytes",
+			text: "价格是多少钱",
+			size: 3,
+			want: []string{"价格是", "多少钱"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range Split(tt.text, tt.size, tt.overlap) {
+				got = append(got, c.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	chunks := []Chunk{
+		{Index: 0, Text: "大家好，欢迎收听本期节目"},
+		{Index: 1, Text: "我们的定价策略是按月订阅"},
+		{Index: 2, Text: "今天天气不错"},
+		{Index: 3, Text: "the pricing page was redesigned"},
+	}
+
+	tests := []struct {
+		name     string
+		question string
+		k        int
+		want     []int
+	}{
+		{name: "chinese", question: "他们怎么说定价的？", k: 1, want: []int{1}},
+		{name: "english", question: "What about PRICING?", k: 1, want: []int{3}},
+		{name: "keeps_transcript_order", question: "定价 pricing", k: 2, want: []int{1, 3}},
+		{name: "k_larger_than_chunks", question: "x", k: 10, want: []int{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, c := range Rank(chunks, tt.question, tt.k) {
+				got = append(got, c.Index)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rank() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
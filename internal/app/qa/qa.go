@@ -0,0 +1,103 @@
+package qa
+
+import (
+	"fmt"
+	"tiktok-whisper/internal/app/llm"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/prompts"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	chunkSize    = 800
+	chunkOverlap = 100
+	contextSize  = 4
+)
+
+// Completer sends a conversation to an LLM and returns its reply.
+type Completer func(messages []openai.ChatCompletionMessage) (string, error)
+
+// Conversation is a question answering session scoped to a single transcription,
+// every answer is grounded in the chunks most relevant to the question.
+type Conversation struct {
+	transcription model.Transcription
+	chunks        []Chunk
+	complete      Completer
+	history       []openai.ChatCompletionMessage
+	budget        int
+}
+
+// NewConversation creates a new Conversation instance over transcription.
+func NewConversation(transcription model.Transcription, complete Completer) *Conversation {
+	return &Conversation{
+		transcription: transcription,
+		chunks:        Split(transcription.Transcription, chunkSize, chunkOverlap),
+		complete:      complete,
+	}
+}
+
+// SetBudget bounds the tokens of a request, the oldest turns are left out of the requests that would
+// exceed it. Zero sends the whole conversation.
+func (c *Conversation) SetBudget(tokens int) {
+	c.budget = tokens
+}
+
+// Answer is the LLM reply and the chunks it was given as context.
+type Answer struct {
+	Text      string
+	Citations []Chunk
+}
+
+// Ask answers question using the relevant chunks and the previous turns of the conversation.
+func (c *Conversation) Ask(question string) (*Answer, error) {
+	context := Rank(c.chunks, question, contextSize)
+
+	system, err := systemPrompt(c.transcription, context)
+	if err != nil {
+		return nil, err
+	}
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: system},
+	}
+	messages = append(messages, c.recentHistory(llm.EstimateTokens(system)+llm.EstimateTokens(question))...)
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: question})
+
+	reply, err := c.complete(messages)
+	if err != nil {
+		return nil, fmt.Errorf("ask llm failed: %v", err)
+	}
+
+	c.history = append(c.history,
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: question},
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply},
+	)
+	return &Answer{Text: reply, Citations: context}, nil
+}
+
+// recentHistory returns the latest turns that fit the budget next to used tokens, whole turns only.
+func (c *Conversation) recentHistory(used int) []openai.ChatCompletionMessage {
+	if c.budget <= 0 {
+		return c.history
+	}
+	start := len(c.history)
+	// a turn is a question and its answer
+	for start >= 2 {
+		turn := llm.EstimateTokens(c.history[start-2].Content) + llm.EstimateTokens(c.history[start-1].Content)
+		if used+turn > c.budget {
+			break
+		}
+		used += turn
+		start -= 2
+	}
+	return c.history[start:]
+}
+
+// systemPrompt renders the qa prompt template with the excerpts of t relevant to the question.
+func systemPrompt(t model.Transcription, chunks []Chunk) (string, error) {
+	data := prompts.Data{File: t.Mp3FileName, User: t.User}
+	for _, c := range chunks {
+		data.Excerpts = append(data.Excerpts, prompts.Excerpt{Index: c.Index, Text: c.Text})
+	}
+	return prompts.Render(prompts.QA, data)
+}
@@ -0,0 +1,44 @@
+package qa
+
+import (
+	"testing"
+	"tiktok-whisper/internal/app/model"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestConversation_Ask_Budget(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget int
+		// wantMessages of the third request: the system prompt, the turns kept and the question
+		wantMessages int
+	}{
+		{name: "unbounded", budget: 0, wantMessages: 6},
+		{name: "oldest turn left out", budget: 80, wantMessages: 4},
+		{name: "no history fits", budget: 1, wantMessages: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var last []openai.ChatCompletionMessage
+			c := NewConversation(model.Transcription{Transcription: "We talked about pricing."},
+				func(messages []openai.ChatCompletionMessage) (string, error) {
+					last = messages
+					return "An answer of a few words.", nil
+				})
+			c.SetBudget(tt.budget)
+
+			for _, question := range []string{"What about pricing?", "And the plans?", "Anything else?"} {
+				if _, err := c.Ask(question); err != nil {
+					t.Fatalf("Ask() error = %v", err)
+				}
+			}
+			if len(last) != tt.wantMessages {
+				t.Errorf("messages = %d, want %d", len(last), tt.wantMessages)
+			}
+			if last[len(last)-1].Content != "Anything else?" {
+				t.Errorf("last message = %q, want the question", last[len(last)-1].Content)
+			}
+		})
+	}
+}
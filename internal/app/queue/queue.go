@@ -0,0 +1,281 @@
+// Package queue is an embedded job queue kept in a SQLite file. v2t queue add queues the files of a
+// directory and v2t queue worker converts them in the background, so batches survive restarts and
+// crashes without an external workflow engine.
+package queue
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/util/files"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// File is the queue database in the data directory.
+const File = "queue.db"
+
+const (
+	// DefaultMaxAttempts is how often a job is tried before it fails for good.
+	DefaultMaxAttempts = 3
+	// DefaultRetryDelay is the wait before the second attempt of a failed job, the wait grows with every attempt.
+	DefaultRetryDelay = time.Minute
+	// DefaultLease is how long a running job stays claimed without a heartbeat of its worker, a job whose
+	// worker crashed is handed out again once its lease passed.
+	DefaultLease = 5 * time.Minute
+)
+
+// Status is the progress of a job.
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Statuses are the statuses in the order a job goes through them.
+var Statuses = []Status{StatusPending, StatusRunning, StatusDone, StatusFailed}
+
+// Job is the conversion of a single file.
+type Job struct {
+	ID   int64           `json:"id"`
+	Kind model.BatchKind `json:"kind"`
+	Path string          `json:"path"`
+	// User owns the converted video, it is empty for audio jobs.
+	User string `json:"user,omitempty"`
+	// OutputDirectory receives the text file of audio jobs.
+	OutputDirectory string `json:"output_directory,omitempty"`
+	Status          Status `json:"status"`
+	// Attempts counts the times a worker claimed the job.
+	Attempts int `json:"attempts"`
+	// Error is the error of the last failed attempt.
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const schema = `CREATE TABLE IF NOT EXISTS jobs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	path TEXT NOT NULL,
+	user TEXT NOT NULL DEFAULT '',
+	output_directory TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	available_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS jobs_status ON jobs (status, available_at);`
+
+const jobColumns = `id, kind, path, user, output_directory, status, attempts, error, created_at, updated_at`
+
+// Queue is the job queue, it is safe to use from several goroutines and processes at once.
+type Queue struct {
+	db          *sql.DB
+	now         func() time.Time
+	maxAttempts int
+	retryDelay  time.Duration
+	lease       time.Duration
+}
+
+// DefaultPath is the queue database in the data directory of the project.
+func DefaultPath() (string, error) {
+	projectRoot, err := files.GetProjectRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(projectRoot, "data", File), nil
+}
+
+// Open opens the queue kept at path, creating it when it doesn't exist.
+func Open(path string) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	// WAL lets the status be read while a worker writes, the busy timeout makes workers wait for each other
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create queue %s failed: %v", path, err)
+	}
+	return &Queue{
+		db:          db,
+		now:         time.Now,
+		maxAttempts: DefaultMaxAttempts,
+		retryDelay:  DefaultRetryDelay,
+		lease:       DefaultLease,
+	}, nil
+}
+
+// Close closes the queue database.
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+// Add queues the jobs as pending and returns how many were added. Files already pending or running
+// are skipped, so adding a directory again only queues its new files and those that finished.
+func (q *Queue) Add(jobs ...Job) (int, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := q.now().UnixMilli()
+	added := 0
+	for _, job := range jobs {
+		var active int
+		err = tx.QueryRow(`SELECT COUNT(*) FROM jobs WHERE path = ? AND user = ? AND status IN (?, ?);`,
+			job.Path, job.User, StatusPending, StatusRunning).Scan(&active)
+		if err != nil {
+			return 0, err
+		}
+		if active > 0 {
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO jobs (kind, path, user, output_directory, status, created_at, updated_at, available_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+			job.Kind, job.Path, job.User, job.OutputDirectory, StatusPending, now, now, now)
+		if err != nil {
+			return 0, fmt.Errorf("add job %s failed: %v", job.Path, err)
+		}
+		added++
+	}
+	return added, tx.Commit()
+}
+
+// Claim hands out the oldest job that is due, pending or running with an expired lease, and marks it
+// running for the lease. It returns nil when no job is due. Expired jobs without attempts left fail.
+func (q *Queue) Claim() (*Job, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := q.now()
+	_, err = tx.Exec(`UPDATE jobs SET status = ?, error = ?, updated_at = ? WHERE status = ? AND available_at <= ? AND attempts >= ?;`,
+		StatusFailed, "the worker stopped during the last attempt", now.UnixMilli(), StatusRunning, now.UnixMilli(), q.maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	row := tx.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE status IN (?, ?) AND available_at <= ? ORDER BY id LIMIT 1;`,
+		StatusPending, StatusRunning, now.UnixMilli())
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, tx.Commit()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job.Status = StatusRunning
+	job.Attempts++
+	job.UpdatedAt = time.UnixMilli(now.UnixMilli())
+	_, err = tx.Exec(`UPDATE jobs SET status = ?, attempts = ?, updated_at = ?, available_at = ? WHERE id = ?;`,
+		job.Status, job.Attempts, now.UnixMilli(), now.Add(q.lease).UnixMilli(), job.ID)
+	if err != nil {
+		return nil, err
+	}
+	return job, tx.Commit()
+}
+
+// Extend renews the lease of the running job, its worker calls it while it works on the job.
+func (q *Queue) Extend(id int64) error {
+	_, err := q.db.Exec(`UPDATE jobs SET available_at = ? WHERE id = ? AND status = ?;`,
+		q.now().Add(q.lease).UnixMilli(), id, StatusRunning)
+	return err
+}
+
+// Finish records the outcome of the attempt at job, err is nil when it succeeded. A failed job with
+// attempts left is pending again after a delay growing with its attempts.
+func (q *Queue) Finish(job Job, err error) error {
+	now := q.now()
+	status, message, available := StatusDone, "", now
+	if err != nil {
+		status, message = StatusFailed, err.Error()
+		if job.Attempts < q.maxAttempts {
+			status = StatusPending
+			available = now.Add(time.Duration(job.Attempts) * q.retryDelay)
+		}
+	}
+	_, err = q.db.Exec(`UPDATE jobs SET status = ?, error = ?, updated_at = ?, available_at = ? WHERE id = ?;`,
+		status, message, now.UnixMilli(), available.UnixMilli(), job.ID)
+	return err
+}
+
+// Jobs returns the jobs with one of statuses in the order they were added, every job when none is given.
+func (q *Queue) Jobs(statuses ...Status) ([]Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	args := make([]interface{}, len(statuses))
+	if len(statuses) > 0 {
+		query += ` WHERE status IN (?` + strings.Repeat(`, ?`, len(statuses)-1) + `)`
+		for i, s := range statuses {
+			args[i] = s
+		}
+	}
+
+	rows, err := q.db.Query(query+` ORDER BY id;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// Counts returns the number of jobs by status.
+func (q *Queue) Counts() (map[Status]int, error) {
+	rows, err := q.db.Query(`SELECT status, COUNT(*) FROM jobs GROUP BY status;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[Status]int, len(Statuses))
+	for rows.Next() {
+		var status Status
+		var n int
+		if err = rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row scanner) (*Job, error) {
+	var job Job
+	var createdAt, updatedAt int64
+	err := row.Scan(&job.ID, &job.Kind, &job.Path, &job.User, &job.OutputDirectory, &job.Status, &job.Attempts, &job.Error, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.CreatedAt = time.UnixMilli(createdAt)
+	job.UpdatedAt = time.UnixMilli(updatedAt)
+	return &job, nil
+}
@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/testutil"
+	"time"
+)
+
+func openQueue(t *testing.T) (*Queue, *time.Time) {
+	t.Helper()
+	q, err := Open(filepath.Join(t.TempDir(), File))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { q.Close() })
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	return q, &now
+}
+
+func TestQueue_Add(t *testing.T) {
+	q, _ := openQueue(t)
+
+	added, err := q.Add(
+		Job{Kind: model.BatchVideo, Path: "/in/a.mp4", User: "alice"},
+		Job{Kind: model.BatchVideo, Path: "/in/b.mp4", User: "alice"},
+	)
+	if err != nil || added != 2 {
+		t.Fatalf("Add() = %d, %v, want 2", added, err)
+	}
+	// pending files aren't queued twice, the same file of another user is
+	added, err = q.Add(
+		Job{Kind: model.BatchVideo, Path: "/in/a.mp4", User: "alice"},
+		Job{Kind: model.BatchVideo, Path: "/in/a.mp4", User: "bob"},
+	)
+	if err != nil || added != 1 {
+		t.Fatalf("Add() again = %d, %v, want 1", added, err)
+	}
+
+	jobs, err := q.Jobs(StatusPending)
+	if err != nil || len(jobs) != 3 {
+		t.Fatalf("Jobs() = %+v, %v, want 3 pending jobs", jobs, err)
+	}
+	if jobs[0].Path != "/in/a.mp4" || jobs[0].User != "alice" || jobs[0].Kind != model.BatchVideo {
+		t.Errorf("Jobs()[0] = %+v, want the first added job", jobs[0])
+	}
+}
+
+func TestQueue_ClaimAndFinish(t *testing.T) {
+	tests := []struct {
+		name       string
+		errs       []error
+		wantStatus Status
+		wantError  string
+	}{
+		{name: "done", errs: []error{nil}, wantStatus: StatusDone},
+		{name: "retried", errs: []error{errors.New("timeout"), nil}, wantStatus: StatusDone},
+		{name: "out of attempts", errs: []error{errors.New("a"), errors.New("b"), errors.New("c")}, wantStatus: StatusFailed, wantError: "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, now := openQueue(t)
+			q.Add(Job{Kind: model.BatchAudio, Path: "/in/a.mp3", OutputDirectory: "/out"})
+
+			for i, err := range tt.errs {
+				job, claimErr := q.Claim()
+				if claimErr != nil || job == nil {
+					t.Fatalf("Claim() %d = %v, %v, want the job", i+1, job, claimErr)
+				}
+				if job.Attempts != i+1 || job.Status != StatusRunning {
+					t.Fatalf("Claim() %d = %+v, want attempt %d running", i+1, job, i+1)
+				}
+				if again, _ := q.Claim(); again != nil {
+					t.Fatalf("Claim() of a running job = %+v, want none", again)
+				}
+				if err = q.Finish(*job, err); err != nil {
+					t.Fatalf("Finish() error = %v", err)
+				}
+				// failed jobs wait for their retry
+				*now = now.Add(time.Duration(i+1) * DefaultRetryDelay)
+			}
+
+			jobs, _ := q.Jobs()
+			if len(jobs) != 1 || jobs[0].Status != tt.wantStatus || jobs[0].Error != tt.wantError {
+				t.Errorf("Jobs() = %+v, want status %s with error %q", jobs, tt.wantStatus, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestQueue_ExpiredLease(t *testing.T) {
+	q, now := openQueue(t)
+	q.Add(Job{Kind: model.BatchVideo, Path: "/in/a.mp4", User: "alice"})
+
+	for attempt := 1; attempt <= DefaultMaxAttempts; attempt++ {
+		job, err := q.Claim()
+		if err != nil || job == nil || job.Attempts != attempt {
+			t.Fatalf("Claim() = %+v, %v, want attempt %d", job, err, attempt)
+		}
+		// the worker renews the lease while it runs
+		*now = now.Add(DefaultLease - time.Second)
+		q.Extend(job.ID)
+		*now = now.Add(DefaultLease - time.Second)
+		if again, _ := q.Claim(); again != nil {
+			t.Fatalf("Claim() with a renewed lease = %+v, want none", again)
+		}
+		// then it crashed
+		*now = now.Add(time.Second)
+	}
+
+	if job, err := q.Claim(); err != nil || job != nil {
+		t.Fatalf("Claim() without attempts left = %+v, %v, want none", job, err)
+	}
+	counts, err := q.Counts()
+	if err != nil || counts[StatusFailed] != 1 || counts[StatusRunning] != 0 {
+		t.Errorf("Counts() = %v, %v, want the job failed", counts, err)
+	}
+}
+
+func TestWorker_Run(t *testing.T) {
+	testutil.UseMockLogger(t)
+	q, _ := openQueue(t)
+	paths := []string{"/in/a.mp4", "/in/b.mp4", "/in/c.mp4", "/in/d.mp4", "/in/e.mp4"}
+	for _, p := range paths {
+		q.Add(Job{Kind: model.BatchVideo, Path: p, User: "alice"})
+	}
+
+	var mu sync.Mutex
+	var handled []string
+	handle := func(job Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, job.Path)
+		if job.Path == "/in/c.mp4" {
+			return errors.New("ffmpeg failed")
+		}
+		return nil
+	}
+	if err := NewWorker(q, handle, WorkerOptions{Concurrency: 3, Drain: true}).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	sort.Strings(handled)
+	if len(handled) != len(paths) {
+		t.Errorf("handled %v, want every job once", handled)
+	}
+	counts, _ := q.Counts()
+	// the failed job waits for its retry
+	if counts[StatusDone] != 4 || counts[StatusPending] != 1 {
+		t.Errorf("Counts() = %v, want 4 done and 1 pending", counts)
+	}
+}
@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"tiktok-whisper/internal/app/logging"
+	"time"
+)
+
+// DefaultPollInterval is how long an idle Worker waits before looking for due jobs again.
+const DefaultPollInterval = 5 * time.Second
+
+// Handler converts the file of a job, the job fails when it returns an error.
+type Handler func(job Job) error
+
+// WorkerOptions configure a Worker.
+type WorkerOptions struct {
+	// Concurrency is the number of jobs run at the same time.
+	Concurrency  int
+	PollInterval time.Duration
+	// Drain makes Run return once no job is due instead of waiting for new ones.
+	Drain bool
+}
+
+// Worker runs the jobs of a queue with a handler. Several workers, in the same process or not, can
+// share a queue: every job is claimed by a single one.
+type Worker struct {
+	queue  *Queue
+	handle Handler
+	opts   WorkerOptions
+}
+
+// NewWorker creates a Worker running the jobs of queue with handle.
+func NewWorker(queue *Queue, handle Handler, opts WorkerOptions) *Worker {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	return &Worker{queue: queue, handle: handle, opts: opts}
+}
+
+// Run claims and runs jobs until ctx is done, or no job is due with Drain. The running jobs still
+// finish and are recorded.
+func (w *Worker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := w.queue.Claim()
+		if err != nil {
+			logging.L().Error("Failed to claim a queued job", "error", err)
+		}
+		if job != nil {
+			w.run(*job)
+			continue
+		}
+		if err == nil && w.opts.Drain {
+			return
+		}
+		sleep(ctx, w.opts.PollInterval)
+	}
+}
+
+// run runs the job while renewing its lease, and records its outcome.
+func (w *Worker) run(job Job) {
+	logging.L().Info("Running queued job", "job", job.ID, "file", job.Path, "attempt", job.Attempts)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(w.queue.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.queue.Extend(job.ID); err != nil {
+					logging.L().Warn("Failed to renew the lease of a queued job", "job", job.ID, "error", err)
+				}
+			}
+		}
+	}()
+	err := w.handle(job)
+	close(done)
+
+	if err != nil {
+		logging.L().Error("Queued job failed", "job", job.ID, "file", job.Path, "attempt", job.Attempts, "error", err)
+	} else {
+		logging.L().Info("Queued job done", "job", job.ID, "file", job.Path)
+	}
+	if err = w.queue.Finish(job, err); err != nil {
+		logging.L().Error("Failed to record a queued job", "job", job.ID, "error", err)
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
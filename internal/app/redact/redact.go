@@ -0,0 +1,183 @@
+package redact
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed words.txt
+var defaultWords string
+
+// Mode selects how a redacted word is rendered.
+type Mode string
+
+const (
+	// Star keeps the first letter and replaces the rest with asterisks, e.g. f***.
+	Star Mode = "star"
+	// Bleep replaces the word with a [bleep] marker.
+	Bleep Mode = "bleep"
+)
+
+const bleepMarker = "[bleep]"
+
+// Redaction records where a word was removed from the broadcast variant, without the word itself.
+type Redaction struct {
+	// Segment is the index of the segment, Offset and Length are in characters of its original text.
+	Segment int `json:"segment"`
+	Offset  int `json:"offset"`
+	Length  int `json:"length"`
+	// Start and End are in seconds, interpolated within the segment by character position.
+	// Both are zero when the segment has no timestamps.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Filter finds words of its list in transcripts.
+type Filter struct {
+	mode    Mode
+	pattern *regexp.Regexp
+}
+
+// NewFilter builds a filter from the default word list, the words in wordsFile (if not empty) and extra words.
+func NewFilter(mode Mode, wordsFile string, extra []string) (*Filter, error) {
+	switch mode {
+	case "":
+		mode = Star
+	case Star, Bleep:
+	default:
+		return nil, fmt.Errorf("unknown redaction mode: %s", mode)
+	}
+
+	words := parseWords(defaultWords)
+	if wordsFile != "" {
+		data, err := os.ReadFile(wordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read words file failed: %v", err)
+		}
+		words = append(words, parseWords(string(data))...)
+	}
+	for _, w := range extra {
+		if w = strings.TrimSpace(w); w != "" {
+			words = append(words, w)
+		}
+	}
+
+	return &Filter{mode: mode, pattern: compile(words)}, nil
+}
+
+func parseWords(list string) []string {
+	words := make([]string, 0)
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words
+}
+
+// compile builds one case-insensitive alternation, longest words first so phrases win over their parts.
+func compile(words []string) *regexp.Regexp {
+	sort.Slice(words, func(i, j int) bool {
+		return utf8.RuneCountInString(words[i]) > utf8.RuneCountInString(words[j])
+	})
+
+	alternatives := make([]string, 0, len(words))
+	for _, w := range words {
+		quoted := regexp.QuoteMeta(w)
+		if isLatin(w) {
+			quoted = `\b` + quoted + `\b`
+		}
+		alternatives = append(alternatives, quoted)
+	}
+	if len(alternatives) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|"))
+}
+
+func isLatin(word string) bool {
+	for _, r := range word {
+		if r > unicode.MaxLatin1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Segments returns the redacted copy of segments and where words were removed.
+func (f *Filter) Segments(segments []model.Segment) ([]model.Segment, []Redaction) {
+	redacted := make([]model.Segment, len(segments))
+	redactions := make([]Redaction, 0)
+
+	for i, s := range segments {
+		text, found := f.text(s.Text)
+		s.Text = text
+		redacted[i] = s
+
+		length := utf8.RuneCountInString(segments[i].Text)
+		for _, r := range found {
+			r.Segment = i
+			if segments[i].Timed() && length > 0 {
+				perRune := (s.End - s.Start) / float64(length)
+				r.Start = s.Start + float64(r.Offset)*perRune
+				r.End = s.Start + float64(r.Offset+r.Length)*perRune
+			}
+			redactions = append(redactions, r)
+		}
+	}
+	return redacted, redactions
+}
+
+// Text redacts a plain text.
+func (f *Filter) Text(text string) string {
+	redacted, _ := f.text(text)
+	return redacted
+}
+
+func (f *Filter) text(text string) (string, []Redaction) {
+	if f.pattern == nil {
+		return text, nil
+	}
+
+	matches := f.pattern.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return text, nil
+	}
+
+	var sb strings.Builder
+	redactions := make([]Redaction, 0, len(matches))
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(text[last:m[0]])
+		sb.WriteString(f.replacement(text[m[0]:m[1]]))
+		redactions = append(redactions, Redaction{
+			Offset: utf8.RuneCountInString(text[:m[0]]),
+			Length: utf8.RuneCountInString(text[m[0]:m[1]]),
+		})
+		last = m[1]
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), redactions
+}
+
+func (f *Filter) replacement(word string) string {
+	if f.mode == Bleep {
+		return bleepMarker
+	}
+
+	first, size := utf8.DecodeRuneInString(word)
+	if !isLatin(word) {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	}
+	return string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+}
@@ -0,0 +1,67 @@
+package redact
+
+import (
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+)
+
+func TestFilter_Text(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  Mode
+		extra []string
+		text  string
+		want  string
+	}{
+		{name: "star", mode: Star, text: "What the Fuck is this shit?", want: "What the F*** is this s***?"},
+		{name: "whole words only", mode: Star, text: "Dickens wrote about Scunthorpe", want: "Dickens wrote about Scunthorpe"},
+		{name: "bleep", mode: Bleep, text: "oh shit", want: "oh [bleep]"},
+		{name: "cjk", mode: Star, text: "你他妈的在干什么", want: "你***在干什么"},
+		{name: "extra words", mode: Bleep, extra: []string{"darn it"}, text: "Darn it!", want: "[bleep]!"},
+		{name: "clean", mode: Star, text: "早上好", want: "早上好"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.mode, "", tt.extra)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Text(tt.text); got != tt.want {
+				t.Errorf("Text() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Segments(t *testing.T) {
+	f, err := NewFilter(Bleep, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments := []model.Segment{
+		{Start: 10, End: 20, Text: "clean text"},
+		{Start: 20, End: 30, Text: "01234 shit"},
+		{Text: "untimed shit"},
+	}
+	redacted, redactions := f.Segments(segments)
+
+	if redacted[1].Text != "01234 [bleep]" || segments[1].Text != "01234 shit" {
+		t.Errorf("Segments() text = %q, original %q", redacted[1].Text, segments[1].Text)
+	}
+
+	want := []Redaction{
+		{Segment: 1, Offset: 6, Length: 4, Start: 26, End: 30},
+		{Segment: 2, Offset: 8, Length: 4},
+	}
+	if !reflect.DeepEqual(redactions, want) {
+		t.Errorf("Segments() redactions = %+v, want %+v", redactions, want)
+	}
+}
+
+func TestNewFilter_UnknownMode(t *testing.T) {
+	if _, err := NewFilter("mute", "", nil); err == nil {
+		t.Errorf("NewFilter() error = nil, want unknown mode")
+	}
+}
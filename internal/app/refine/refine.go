@@ -0,0 +1,141 @@
+// Package refine runs the second pass of the two-pass mode: drafts transcribed quickly with a fast
+// model are transcribed again with a bigger one, off-peak, and the refined text replaces the draft.
+package refine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+// ErrNotSupported is returned when the database can't queue drafts or keep revisions.
+var ErrNotSupported = errors.New("the database does not keep refine jobs and revisions")
+
+// Refiner transcribes the queued drafts with the high-quality transcriber.
+type Refiner struct {
+	transcriber api.Transcriber
+	db          repository.TranscriptionDAO
+	bus         events.Bus
+	window      Window
+	now         func() time.Time
+}
+
+// NewRefiner creates a new Refiner instance, it refines at any time until SetWindow limits it.
+func NewRefiner(transcriber api.Transcriber, transcriptionDAO repository.TranscriptionDAO, bus events.Bus) *Refiner {
+	return &Refiner{
+		transcriber: transcriber,
+		db:          transcriptionDAO,
+		bus:         bus,
+		now:         time.Now,
+	}
+}
+
+// SetWindow limits refining to the off-peak hours of w.
+func (r *Refiner) SetWindow(w Window) {
+	r.window = w
+}
+
+// Window returns the hours the refiner works in.
+func (r *Refiner) Window() Window {
+	return r.window
+}
+
+func (r *Refiner) Close() error {
+	if err := r.bus.Close(); err != nil {
+		log.Printf("Error closing event bus: %v\n", err)
+	}
+	return r.db.Close()
+}
+
+// Result counts what Run did.
+type Result struct {
+	Refined int
+	Failed  int
+	// Remaining drafts are still pending, because of the limit or because the window closed.
+	Remaining int
+}
+
+// Run refines up to limit pending drafts, all of them when limit is zero or less, oldest first.
+// It stops once the window closes, the remaining drafts wait for the next run. Every refined draft
+// becomes a new revision of its transcription and is announced as TopicTranscriptionRefined.
+func (r *Refiner) Run(limit int) (Result, error) {
+	var result Result
+	queue, ok := r.db.(repository.RefineDAO)
+	revisions, ok2 := r.db.(repository.RevisionDAO)
+	if !ok || !ok2 {
+		return result, ErrNotSupported
+	}
+
+	jobs, err := queue.GetRefineJobs(model.RefinePending)
+	if err != nil {
+		return result, fmt.Errorf("get refine jobs failed: %v", err)
+	}
+	for i, job := range jobs {
+		if (limit > 0 && i >= limit) || !r.window.Contains(r.now()) {
+			result.Remaining = len(jobs) - i
+			break
+		}
+
+		if err = r.refine(job, revisions); err != nil {
+			log.Printf("Refining transcription %d failed: %v\n", job.TranscriptionID, err)
+			result.Failed++
+			if err = queue.SetRefineStatus(job.TranscriptionID, model.RefineFailed, err.Error(), r.now()); err != nil {
+				return result, fmt.Errorf("set refine status failed: %v", err)
+			}
+			continue
+		}
+		result.Refined++
+		if err = queue.SetRefineStatus(job.TranscriptionID, model.RefineDone, "", r.now()); err != nil {
+			return result, fmt.Errorf("set refine status failed: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// refine transcribes the audio of job and stores the text as the current revision.
+func (r *Refiner) refine(job model.RefineJob, revisions repository.RevisionDAO) error {
+	t, err := r.db.GetByID(job.TranscriptionID)
+	if err != nil || t == nil {
+		return fmt.Errorf("transcription %d not found", job.TranscriptionID)
+	}
+
+	log.Printf("Refining transcription %d from %s\n", job.TranscriptionID, job.AudioPath)
+	text, metadata, err := transcribe(r.transcriber, job.AudioPath)
+	if err != nil {
+		return err
+	}
+	metadata.WordConfidence = model.WordConfidence(metadata.Segments)
+
+	revision, err := revisions.AddRevision(job.TranscriptionID, text, metadata, r.now())
+	if err != nil {
+		return fmt.Errorf("add revision failed: %v", err)
+	}
+	if segments, ok := r.db.(repository.SegmentDAO); ok && len(metadata.Segments) > 0 {
+		if err = segments.SaveSegments(job.TranscriptionID, metadata.Segments); err != nil {
+			log.Printf("Error saving segments of transcription %d: %v\n", job.TranscriptionID, err)
+		}
+	}
+	log.Printf("Transcription %d refined, stored as revision %d\n", job.TranscriptionID, revision)
+
+	r.bus.Publish(events.Event{
+		Topic:           events.TopicTranscriptionRefined,
+		User:            t.User,
+		FilePath:        job.AudioPath,
+		Provider:        metadata.Provider,
+		TranscriptionID: job.TranscriptionID,
+	})
+	return nil
+}
+
+func transcribe(t api.Transcriber, audioPath string) (string, model.ProviderMetadata, error) {
+	if mt, ok := t.(api.MetadataTranscriber); ok {
+		return mt.TranscriptWithMetadata(audioPath)
+	}
+	text, err := t.Transcript(audioPath)
+	return text, model.ProviderMetadata{}, err
+}
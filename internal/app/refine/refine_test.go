@@ -0,0 +1,121 @@
+package refine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	day := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		window   string
+		at       time.Duration
+		contains bool
+		next     time.Time
+	}{
+		{window: "", at: 12 * time.Hour, contains: true, next: day.Add(12 * time.Hour)},
+		{window: "01:00-06:00", at: 3 * time.Hour, contains: true, next: day.Add(3 * time.Hour)},
+		{window: "01:00-06:00", at: 6 * time.Hour, next: day.AddDate(0, 0, 1).Add(time.Hour)},
+		{window: "01:00-06:00", at: 30 * time.Minute, next: day.Add(time.Hour)},
+		{window: "22:30-05:00", at: 23 * time.Hour, contains: true, next: day.Add(23 * time.Hour)},
+		{window: "22:30-05:00", at: 4 * time.Hour, contains: true, next: day.Add(4 * time.Hour)},
+		{window: "22:30-05:00", at: 12 * time.Hour, next: day.Add(22*time.Hour + 30*time.Minute)},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := day.Add(tt.at)
+		if got := w.Contains(at); got != tt.contains {
+			t.Errorf("%s Contains(%s) = %v, want %v", w, at.Format("15:04"), got, tt.contains)
+		}
+		if got := w.Next(at); !got.Equal(tt.next) {
+			t.Errorf("%s Next(%s) = %v, want %v", w, at.Format("15:04"), got, tt.next)
+		}
+	}
+
+	for _, invalid := range []string{"1-6", "01:00", "25:00-06:00"} {
+		if _, err := ParseWindow(invalid); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+// qualityTranscriber transcribes every file as "refined <path>", it fails on paths containing broken.
+type qualityTranscriber struct{}
+
+func (qualityTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := qualityTranscriber{}.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (qualityTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	if strings.Contains(inputFilePath, "broken") {
+		return "", model.ProviderMetadata{}, errors.New("decoding failed")
+	}
+	return "refined " + inputFilePath, model.ProviderMetadata{Provider: "whisper_cpp", Model: "large-v3",
+		Segments: []model.Segment{{Start: 0, End: 1, Text: "refined " + inputFilePath}}}, nil
+}
+
+func TestRefiner_Run(t *testing.T) {
+	db := memory.NewMemoryDB()
+	queued := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range []string{"a.mp3", "broken.mp3", "c.mp3"} {
+		db.RecordToDB("alice", "/in", name, name, 60, "draft of "+name, queued, 0, "", model.ProviderMetadata{Model: "base"})
+		db.QueueRefine(model.RefineJob{TranscriptionID: i + 1, AudioPath: "/mp3/" + name, Status: model.RefinePending, QueuedAt: queued.Add(time.Duration(i) * time.Minute)})
+	}
+
+	bus := events.NewInProcessBus()
+	refined := make(chan events.Event, 3)
+	bus.Subscribe(events.TopicTranscriptionRefined, func(e events.Event) { refined <- e })
+	r := NewRefiner(qualityTranscriber{}, db, bus)
+	window, _ := ParseWindow("01:00-06:00")
+	r.SetWindow(window)
+
+	// Outside the window nothing is refined
+	r.now = func() time.Time { return queued }
+	if result, err := r.Run(0); err != nil || result != (Result{Remaining: 3}) {
+		t.Fatalf("Run() outside the window = %+v, %v", result, err)
+	}
+
+	r.now = func() time.Time { return queued.Add(15 * time.Hour) }
+	result, err := r.Run(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Result{Refined: 1, Failed: 1, Remaining: 1}); result != want {
+		t.Errorf("Run() = %+v, want %+v", result, want)
+	}
+
+	if got, _ := db.GetByID(1); got.Transcription != "refined /mp3/a.mp3" || got.ProviderMetadata.Model != "large-v3" {
+		t.Errorf("transcription 1 = %+v, want the refined revision", got)
+	}
+	if segments, _ := db.GetSegments(1); len(segments) != 1 {
+		t.Errorf("segments of 1 = %+v, want the refined segments", segments)
+	}
+	if got, _ := db.GetByID(2); got.Transcription != "draft of broken.mp3" {
+		t.Errorf("transcription 2 = %q, want the draft kept", got.Transcription)
+	}
+
+	jobs, _ := db.GetRefineJobs("")
+	wantStatus := []model.RefineStatus{model.RefineDone, model.RefineFailed, model.RefinePending}
+	for i, job := range jobs {
+		if job.Status != wantStatus[i] {
+			t.Errorf("job %d status = %s, want %s", job.TranscriptionID, job.Status, wantStatus[i])
+		}
+	}
+	if jobs[1].Error != "decoding failed" {
+		t.Errorf("job 2 error = %q", jobs[1].Error)
+	}
+
+	r.Close()
+	if e := <-refined; e.TranscriptionID != 1 || e.User != "alice" {
+		t.Errorf("refined event = %+v", e)
+	}
+}
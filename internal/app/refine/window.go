@@ -0,0 +1,86 @@
+package refine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily time range in local time such as 01:00-06:00, it may wrap past midnight.
+// The zero Window is always open.
+type Window struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// ParseWindow reads a window written as HH:MM-HH:MM, the empty string is the zero Window.
+func ParseWindow(s string) (Window, error) {
+	if strings.TrimSpace(s) == "" {
+		return Window{}, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", s)
+	}
+
+	start, err := parseClock(from)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %v", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %v", s, err)
+	}
+	return Window{start: start, end: end}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", strings.TrimSpace(s))
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Always reports whether the window is open at any time.
+func (w Window) Always() bool {
+	return w.start == w.end
+}
+
+// Contains reports whether t is inside the window.
+func (w Window) Contains(t time.Time) bool {
+	if w.Always() {
+		return true
+	}
+	d := t.Sub(midnight(t))
+	if w.start < w.end {
+		return d >= w.start && d < w.end
+	}
+	return d >= w.start || d < w.end
+}
+
+// Next returns t when the window is open at t, otherwise the time it opens next.
+func (w Window) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	next := midnight(t).Add(w.start)
+	if next.Before(t) {
+		next = midnight(t.AddDate(0, 0, 1)).Add(w.start)
+	}
+	return next
+}
+
+func (w Window) String() string {
+	if w.Always() {
+		return "any time"
+	}
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.start) + "-" + clock(w.end)
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
 	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/migrations"
 	"time"
 )
 
@@ -10,8 +13,268 @@ type TranscriptionDAO interface {
 
 	GetAllByUser(userNickname string) ([]model.Transcription, error)
 
+	// ListByUser returns a page of the transcriptions of user that didn't fail, sorted as opts says.
+	ListByUser(userNickname string, opts ListOptions) ([]model.Transcription, error)
+
+	GetByID(id int) (*model.Transcription, error)
+
+	// UpdateTranscription overwrites the stored fields of the transcription with t.ID, it doesn't
+	// add a revision, RevisionDAO.AddRevision keeps the text it replaces.
+	UpdateTranscription(t model.Transcription) error
+
+	// DeleteTranscription removes the transcription with everything stored about it, such as its
+	// segments, revisions and translations. Recorded costs stay, they are spent regardless.
+	DeleteTranscription(id int) error
+
 	CheckIfFileProcessed(fileName string) (int, error)
 
+	GetUserStats() ([]model.UserStats, error)
+
 	RecordToDB(user, inputDir, fileName, mp3FileName string, audioDuration int, transcription string,
-		lastConversionTime time.Time, hasError int, errorMessage string)
+		lastConversionTime time.Time, hasError int, errorMessage string, providerMetadata model.ProviderMetadata)
+}
+
+// SortField is what ListByUser sorts the transcriptions by.
+type SortField string
+
+const (
+	SortByTime     SortField = "time"
+	SortByID       SortField = "id"
+	SortByDuration SortField = "duration"
+	SortByFile     SortField = "file"
+)
+
+// SortFields are the fields ListByUser can sort by.
+var SortFields = []SortField{SortByTime, SortByID, SortByDuration, SortByFile}
+
+// Column returns the column of the transcriptions table holding f, the databases name them alike.
+func (f SortField) Column() (string, error) {
+	switch f {
+	case SortByTime, "":
+		return "last_conversion_time", nil
+	case SortByID:
+		return "id", nil
+	case SortByDuration:
+		return "audio_duration", nil
+	case SortByFile:
+		return "mp3_file_name", nil
+	default:
+		return "", fmt.Errorf("unknown sort field %q", f)
+	}
+}
+
+// ListOptions select a page of transcriptions, the zero value lists all of them newest first.
+type ListOptions struct {
+	// Limit is the most transcriptions to return, all of them when zero.
+	Limit  int
+	Offset int
+	// SortBy is SortByTime when empty, ties are sorted by id.
+	SortBy    SortField
+	Ascending bool
+}
+
+// OrderBy returns the ORDER BY clause of the SQL databases for o.
+func (o ListOptions) OrderBy() (string, error) {
+	column, err := o.SortBy.Column()
+	if err != nil {
+		return "", err
+	}
+	direction := "DESC"
+	if o.Ascending {
+		direction = "ASC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, id %s", column, direction, direction), nil
+}
+
+// ArtifactDAO keeps track of the output files generated from stored transcriptions.
+type ArtifactDAO interface {
+	// GetArtifact returns the latest artifact of format for the transcription, sql.ErrNoRows if there is none.
+	GetArtifact(transcriptionID int, format string) (*model.Artifact, error)
+
+	SaveArtifact(artifact model.Artifact) error
+}
+
+// RevisionDAO keeps every transcription result of a file, the transcription row shows the current revision.
+type RevisionDAO interface {
+	// AddRevision stores a new result for the transcription and makes it current, the text it
+	// replaces is kept as the first revision. It returns the new revision number.
+	AddRevision(transcriptionID int, transcription string, providerMetadata model.ProviderMetadata, createdAt time.Time) (int, error)
+
+	// GetRevisions returns the revisions of the transcription ordered by revision number,
+	// it is empty while the file was transcribed only once.
+	GetRevisions(transcriptionID int) ([]model.Revision, error)
+
+	SetCurrentRevision(transcriptionID int, revision int) error
+}
+
+// RunLocker is implemented by DAOs whose storage must not be written by two conversion runs at once.
+type RunLocker interface {
+	// LockRun takes the run lock without waiting, it fails while another process holds it.
+	// The lock is released on Close.
+	LockRun() error
+}
+
+// SegmentDAO stores the timed segments and word timings of transcriptions, used to regenerate subtitles.
+type SegmentDAO interface {
+	// SaveSegments replaces the segments of the transcription.
+	SaveSegments(transcriptionID int, segments []model.Segment) error
+
+	// GetSegments returns the segments of the transcription in order, it is empty when none were stored.
+	GetSegments(transcriptionID int) ([]model.Segment, error)
+}
+
+// BatchDAO checkpoints batch conversions, so an interrupted one can be resumed.
+type BatchDAO interface {
+	// CreateBatchJob stores the job with its files.
+	CreateBatchJob(job model.BatchJob) error
+
+	// GetBatchJob returns the job with its files in their original order, sql.ErrNoRows if there is none.
+	GetBatchJob(id string) (*model.BatchJob, error)
+
+	// SetBatchFileStatus records the progress of a file of the job.
+	SetBatchFileStatus(jobID string, path string, status model.BatchFileStatus, errorMessage string, updatedAt time.Time) error
+}
+
+// SourceDAO records where the media of downloaded transcriptions came from.
+type SourceDAO interface {
+	SetSourceURL(transcriptionID int, sourceURL string) error
+}
+
+// CostDAO keeps the ledger of estimated transcription costs.
+type CostDAO interface {
+	RecordCost(entry model.CostEntry) error
+
+	// GetCosts returns the entries recorded at or after since, oldest first.
+	GetCosts(since time.Time) ([]model.CostEntry, error)
+}
+
+// TranslationDAO stores the translations of transcriptions, one per target language.
+type TranslationDAO interface {
+	// SaveTranslation stores the translation, replacing the one of the same transcription and language.
+	SaveTranslation(translation model.Translation) error
+
+	// GetTranslation returns the translation of the transcription into language, sql.ErrNoRows if there is none.
+	GetTranslation(transcriptionID int, language string) (*model.Translation, error)
+}
+
+// ModerationDAO stores the latest moderation result of each transcription.
+type ModerationDAO interface {
+	// SaveModeration stores the result, replacing the previous one of the transcription.
+	SaveModeration(moderation model.Moderation) error
+
+	// GetModeration returns the result of the transcription, sql.ErrNoRows if it was never screened.
+	GetModeration(transcriptionID int) (*model.Moderation, error)
+}
+
+// ReviewDAO keeps the segment translations and the marked segments of the language-learning review.
+type ReviewDAO interface {
+	// SaveSegmentTranslations stores the translations, replacing those of the same segment and language.
+	SaveSegmentTranslations(translations []model.SegmentTranslation) error
+
+	// GetSegmentTranslations returns the translations of the segments of the transcription into language
+	// by position, it is empty when none were stored.
+	GetSegmentTranslations(transcriptionID int, language string) (map[int]model.SegmentTranslation, error)
+
+	// SaveReviewCard stores the card, replacing the one of the same segment.
+	SaveReviewCard(card model.ReviewCard) error
+
+	// DeleteReviewCard unmarks the segment, deleting a card that doesn't exist is not an error.
+	DeleteReviewCard(transcriptionID int, position int) error
+
+	// GetReviewCards returns the cards of the transcriptions of user, the earliest due first.
+	GetReviewCards(userNickname string) ([]model.ReviewCard, error)
+}
+
+// SpeechStatsDAO keeps the speech analytics of each transcription.
+type SpeechStatsDAO interface {
+	// SaveSpeechStats stores the stats, replacing the previous ones of the transcription.
+	SaveSpeechStats(stats model.SpeechStats) error
+
+	// GetSpeechStats returns the stats of the transcriptions of user with their file names and
+	// dates, the oldest transcription first.
+	GetSpeechStats(userNickname string) ([]model.SpeechStats, error)
+}
+
+// EmbeddingDAO stores the embeddings of the transcriptions for the vector index. An embedding made
+// before the last change of its transcription is stale and embedded again.
+type EmbeddingDAO interface {
+	// GetTranscriptionsWithoutEmbeddings returns up to limit transcriptions with an ID above afterID,
+	// lowest ID first, whose text has no embedding of embeddingModel or only a stale one. Failed and
+	// empty transcriptions have no text to embed and are left out.
+	GetTranscriptionsWithoutEmbeddings(embeddingModel string, afterID int, limit int) ([]model.Transcription, error)
+
+	// SaveEmbedding stores the embedding, replacing the previous one of its transcription and model.
+	SaveEmbedding(embedding model.Embedding) error
+
+	// GetEmbedding returns the embedding of the transcription made by embeddingModel, sql.ErrNoRows
+	// if there is none.
+	GetEmbedding(transcriptionID int, embeddingModel string) (*model.Embedding, error)
+
+	// GetEmbeddingProgress counts the transcriptions embeddingModel embedded and those still pending.
+	GetEmbeddingProgress(embeddingModel string) (model.EmbeddingProgress, error)
+
+	// GetIndexModel returns the embedding model of the vector index, empty until one is set.
+	GetIndexModel() (string, error)
+
+	// SetIndexModel makes embeddingModel the model of the vector index. With dropOthers the
+	// embeddings of the other models are deleted in the same transaction, so the index never mixes
+	// the vectors of two models.
+	SetIndexModel(embeddingModel string, dropOthers bool, activatedAt time.Time) error
+}
+
+// DuplicateDAO stores the duplicates found among the transcriptions of each user.
+type DuplicateDAO interface {
+	// ReplaceDuplicates replaces the duplicates of the transcriptions of user with duplicates.
+	ReplaceDuplicates(userNickname string, duplicates []model.Duplicate) error
+
+	// GetDuplicates returns the duplicates of the transcriptions of user with their file names, the
+	// most similar first.
+	GetDuplicates(userNickname string) ([]model.Duplicate, error)
+}
+
+// InsightDAO stores the chapters, entities and sentiments providers report about transcriptions.
+type InsightDAO interface {
+	// SaveInsights replaces the insights of the transcription.
+	SaveInsights(transcriptionID int, insights model.Insights) error
+
+	// GetInsights returns the insights of the transcription in the order they were reported, they
+	// are empty when none were stored.
+	GetInsights(transcriptionID int) (model.Insights, error)
+}
+
+// RefineDAO queues draft transcriptions for their high-quality pass.
+type RefineDAO interface {
+	// QueueRefine queues the job, a transcription queued before is queued again as pending.
+	QueueRefine(job model.RefineJob) error
+
+	// GetRefineJobs returns the jobs with status oldest first, every job when status is empty.
+	GetRefineJobs(status model.RefineStatus) ([]model.RefineJob, error)
+
+	// SetRefineStatus records the outcome of the high-quality pass of the transcription.
+	SetRefineStatus(transcriptionID int, status model.RefineStatus, errorMessage string, updatedAt time.Time) error
+}
+
+// MetadataDAO keeps free-form key value metadata of transcriptions, such as language or collection.
+type MetadataDAO interface {
+	// GetMetadata returns the metadata of the transcription, it is empty when none was set.
+	GetMetadata(transcriptionID int) (map[string]string, error)
+
+	// SetMetadata sets the values of the transcription's keys at once, an empty value removes its key.
+	SetMetadata(transcriptionID int, values map[string]string) error
+}
+
+// ErrNoFullTextIndex is returned by FullTextSearcher when its database has no full-text index.
+var ErrNoFullTextIndex = errors.New("no full-text index")
+
+// FullTextSearcher is implemented by DAOs that keep a full-text index of the transcriptions.
+type FullTextSearcher interface {
+	// SearchTranscriptions returns the transcriptions of user whose file name and text together contain
+	// every word of query, best matches first. Zero limit returns all matches.
+	SearchTranscriptions(query string, user string, limit int) ([]model.Transcription, error)
+}
+
+// SchemaMigrator is implemented by DAOs whose schema is versioned by the embedded migrations, the
+// pending ones are applied when the database is opened.
+type SchemaMigrator interface {
+	Migrator() *migrations.Migrator
 }
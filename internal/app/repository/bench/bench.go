@@ -0,0 +1,187 @@
+// Package bench measures how a database backend copes with the load of large batch runs,
+// to help choosing between SQLite and PostgreSQL.
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/testutil"
+	"time"
+)
+
+// Measurement names what a Result measured.
+type Measurement string
+
+const (
+	Insert           Measurement = "insert"
+	GetAllByUser     Measurement = "get_all_by_user"
+	ConcurrentRead   Measurement = "concurrent_read"
+	ConcurrentInsert Measurement = "concurrent_insert"
+)
+
+// Options configures a benchmark run.
+type Options struct {
+	// RowCounts are the table sizes GetAllByUser is measured at, rows are inserted up to each in turn.
+	RowCounts []int
+	// Queries is the number of GetAllByUser calls per row count.
+	Queries int
+	// Concurrency is the number of readers and of writers in the concurrent measurement,
+	// zero skips it.
+	Concurrency int
+	// Seed generates the rows, see testutil.GenerateTestCorpus.
+	Seed int64
+}
+
+// Result is one measurement of a backend.
+type Result struct {
+	Backend     string
+	Measurement Measurement
+	// Rows is the table size the measurement started at.
+	Rows     int
+	Ops      int
+	Duration time.Duration
+	// P50 and P95 are the latencies of single operations.
+	P50 time.Duration
+	P95 time.Duration
+}
+
+// OpsPerSecond is the throughput of the measurement.
+func (r Result) OpsPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Ops) / r.Duration.Seconds()
+}
+
+// Run measures dao, it inserts its rows under users that start with runID so runs on a shared
+// database don't mix with each other or with real users.
+func Run(backend string, dao repository.TranscriptionDAO, runID string, opts Options) ([]Result, error) {
+	counts := append([]int(nil), opts.RowCounts...)
+	sort.Ints(counts)
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("no row counts")
+	}
+	corpus := testutil.GenerateTestCorpus(opts.Seed, counts[len(counts)-1])
+	for i := range corpus.Files {
+		corpus.Files[i].User = runID + "-" + corpus.Files[i].User
+		corpus.Files[i].FileName = runID + "-" + corpus.Files[i].FileName
+	}
+	// The first user owns the largest share of the rows, the one a slow query hurts most
+	user := runID + "-" + corpus.Users[0]
+
+	var results []Result
+	inserted := 0
+	for _, count := range counts {
+		var latencies []time.Duration
+		start := time.Now()
+		for _, f := range corpus.Files[inserted:count] {
+			latencies = append(latencies, timed(func() {
+				dao.RecordToDB(f.User, f.InputDir, f.FileName, f.Mp3FileName, f.AudioDuration, f.Transcription,
+					f.LastConversionTime, f.HasError, f.ErrorMessage, f.ProviderMetadata)
+			}))
+		}
+		results = append(results, result(backend, Insert, inserted, time.Since(start), latencies))
+		inserted = count
+
+		latencies = nil
+		start = time.Now()
+		for i := 0; i < opts.Queries; i++ {
+			var err error
+			latencies = append(latencies, timed(func() {
+				_, err = dao.GetAllByUser(user)
+			}))
+			if err != nil {
+				return results, fmt.Errorf("GetAllByUser failed: %v", err)
+			}
+		}
+		results = append(results, result(backend, GetAllByUser, count, time.Since(start), latencies))
+	}
+
+	if opts.Concurrency > 0 {
+		concurrent, err := runConcurrent(backend, dao, user, corpus.Files[0], inserted, opts)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, concurrent...)
+	}
+	return results, nil
+}
+
+// runConcurrent runs readers of user and writers inserting copies of f at the same time.
+func runConcurrent(backend string, dao repository.TranscriptionDAO, user string, f testutil.FixtureFile, rows int, opts Options) ([]Result, error) {
+	var mu sync.Mutex
+	var reads, writes []time.Duration
+	var readErr error
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < opts.Queries; i++ {
+				var err error
+				d := timed(func() {
+					_, err = dao.GetAllByUser(user)
+				})
+				mu.Lock()
+				reads = append(reads, d)
+				if err != nil && readErr == nil {
+					readErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < opts.Queries; i++ {
+				fileName := fmt.Sprintf("concurrent-%d-%d-%s", w, i, f.FileName)
+				d := timed(func() {
+					dao.RecordToDB(f.User, f.InputDir, fileName, f.Mp3FileName, f.AudioDuration, f.Transcription,
+						time.Now(), f.HasError, f.ErrorMessage, f.ProviderMetadata)
+				})
+				mu.Lock()
+				writes = append(writes, d)
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+	duration := time.Since(start)
+	if readErr != nil {
+		return nil, fmt.Errorf("GetAllByUser failed: %v", readErr)
+	}
+
+	return []Result{
+		result(backend, ConcurrentRead, rows, duration, reads),
+		result(backend, ConcurrentInsert, rows, duration, writes),
+	}, nil
+}
+
+func timed(op func()) time.Duration {
+	start := time.Now()
+	op()
+	return time.Since(start)
+}
+
+func result(backend string, m Measurement, rows int, duration time.Duration, latencies []time.Duration) Result {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return Result{
+		Backend:     backend,
+		Measurement: m,
+		Rows:        rows,
+		Ops:         len(latencies),
+		Duration:    duration,
+		P50:         percentile(latencies, 0.5),
+		P95:         percentile(latencies, 0.95),
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
@@ -0,0 +1,119 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/repository/pg"
+	"tiktok-whisper/internal/app/repository/sqlite"
+	"tiktok-whisper/internal/app/testutil"
+)
+
+func TestRun(t *testing.T) {
+	db := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "transcription.db"))
+	defer db.Close()
+
+	results, err := Run("sqlite", db, "bench", Options{RowCounts: []int{50, 20}, Queries: 3, Concurrency: 2, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		measurement Measurement
+		rows        int
+		ops         int
+	}{
+		{Insert, 0, 20},
+		{GetAllByUser, 20, 3},
+		{Insert, 20, 30},
+		{GetAllByUser, 50, 3},
+		{ConcurrentRead, 50, 6},
+		{ConcurrentInsert, 50, 6},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("Run() = %d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Backend != "sqlite" || r.Measurement != w.measurement || r.Rows != w.rows || r.Ops != w.ops || r.P50 > r.P95 {
+			t.Errorf("result %d = %+v, want %s of %d ops at %d rows", i, r, w.measurement, w.ops, w.rows)
+		}
+	}
+
+	// Failed conversions aren't counted, every concurrent insert is
+	wantTotal := 2 * 3
+	for _, f := range testutil.GenerateTestCorpus(1, 50).Files {
+		wantTotal += 1 - f.HasError
+	}
+	stats, _ := db.GetUserStats()
+	total := 0
+	for _, s := range stats {
+		total += s.VideoCount
+	}
+	if total != wantTotal {
+		t.Errorf("%d transcriptions were stored, want %d", total, wantTotal)
+	}
+}
+
+// benchmarkBackends returns the backends to benchmark, Postgres when V2T_BENCH_POSTGRES_DSN points
+// at a scratch database.
+func benchmarkBackends(b *testing.B) map[string]func() repository.TranscriptionDAO {
+	backends := map[string]func() repository.TranscriptionDAO{
+		"sqlite": func() repository.TranscriptionDAO {
+			return sqlite.NewSQLiteDB(filepath.Join(b.TempDir(), "transcription.db"))
+		},
+	}
+	if dsn := os.Getenv("V2T_BENCH_POSTGRES_DSN"); dsn != "" {
+		backends["postgres"] = func() repository.TranscriptionDAO {
+			db, err := pg.NewPostgresDB(dsn)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return db
+		}
+	}
+	return backends
+}
+
+func BenchmarkRecordToDB(b *testing.B) {
+	for name, open := range benchmarkBackends(b) {
+		b.Run(name, func(b *testing.B) {
+			db := open()
+			defer db.Close()
+			corpus := testutil.GenerateTestCorpus(1, b.N)
+			runID := fmt.Sprintf("bench%d-", b.N)
+
+			b.ResetTimer()
+			for _, f := range corpus.Files {
+				db.RecordToDB(runID+f.User, f.InputDir, runID+f.FileName, f.Mp3FileName, f.AudioDuration, f.Transcription,
+					f.LastConversionTime, f.HasError, f.ErrorMessage, f.ProviderMetadata)
+			}
+		})
+	}
+}
+
+func BenchmarkGetAllByUser(b *testing.B) {
+	for name, open := range benchmarkBackends(b) {
+		for _, rows := range []int{100, 1000, 10000} {
+			b.Run(fmt.Sprintf("%s/rows=%d", name, rows), func(b *testing.B) {
+				db := open()
+				defer db.Close()
+				corpus := testutil.GenerateTestCorpus(1, rows)
+				runID := fmt.Sprintf("bench%d-", rows)
+				for _, f := range corpus.Files {
+					db.RecordToDB(runID+f.User, f.InputDir, runID+f.FileName, f.Mp3FileName, f.AudioDuration, f.Transcription,
+						f.LastConversionTime, f.HasError, f.ErrorMessage, f.ProviderMetadata)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := db.GetAllByUser(runID + corpus.Users[0]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,424 @@
+// Package dump moves the data of a SQLite or PostgreSQL database to another one of either kind
+// through a JSONL file. The first line is a header with the schema version of the source, every
+// other line is a row of a table. Column names are the SQLite ones, so a dump reads the same
+// whichever database it came from. The full-text index of SQLite isn't dumped, it is rebuilt
+// whenever v2t opens the database, nor are the embeddings, v2t embed makes them again.
+package dump
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"tiktok-whisper/internal/app/repository/migrations"
+	"time"
+)
+
+// FormatVersion is the version of the dump format Export writes.
+const FormatVersion = 1
+
+// Header is the first line of a dump.
+type Header struct {
+	FormatVersion int                `json:"v2t_dump"`
+	SchemaVersion int                `json:"schema_version"`
+	Dialect       migrations.Dialect `json:"dialect"`
+	ExportedAt    time.Time          `json:"exported_at"`
+}
+
+// line is a row of a dump.
+type line struct {
+	Table string                 `json:"table"`
+	Row   map[string]interface{} `json:"row"`
+}
+
+// table describes how the rows of a table are imported.
+type table struct {
+	name string
+	// serial tables have an id the database assigns.
+	serial bool
+	// references maps columns to the serial table whose id they hold.
+	references map[string]string
+	// optional references are kept as zero instead of skipping the row when their id is unknown.
+	optional bool
+}
+
+// tables are dumped and imported in this order, a table comes after the ones it references.
+var tables = []table{
+	{name: "transcriptions", serial: true},
+	{name: "transcription_revisions", serial: true, references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "transcription_segments", serial: true, references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "transcription_words", serial: true, references: map[string]string{"segment_id": "transcription_segments"}},
+	{name: "artifacts", serial: true, references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "batch_jobs"},
+	{name: "batch_job_files"},
+	{name: "transcription_costs", serial: true, references: map[string]string{"transcription_id": "transcriptions"}, optional: true},
+	{name: "transcription_translations", serial: true, references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "refine_jobs", references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "transcription_metadata", references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "transcription_moderations", references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "segment_translations", references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "review_cards", references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "speech_analytics", references: map[string]string{"transcription_id": "transcriptions"}},
+	{name: "transcription_duplicates", references: map[string]string{"transcription_id": "transcriptions", "duplicate_of": "transcriptions"}},
+}
+
+// Tables returns the names of the dumped tables in the order they are dumped.
+func Tables() []string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.name
+	}
+	return names
+}
+
+func lookup(name string) (table, bool) {
+	for _, t := range tables {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return table{}, false
+}
+
+// postgresColumns maps the SQLite column names to the PostgreSQL ones where they differ,
+// user is a reserved word in PostgreSQL.
+var postgresColumns = map[string]string{"user": "user_nickname"}
+
+func column(dialect migrations.Dialect, name string) string {
+	if dialect == migrations.Postgres {
+		if renamed, ok := postgresColumns[name]; ok {
+			return renamed
+		}
+	}
+	return name
+}
+
+func canonicalColumn(dialect migrations.Dialect, name string) string {
+	if dialect == migrations.Postgres {
+		for sqliteName, postgresName := range postgresColumns {
+			if name == postgresName {
+				return sqliteName
+			}
+		}
+	}
+	return name
+}
+
+// Export writes the header and every row of the database of m to w, it returns the number of rows
+// of each table. The rows are read in one transaction, so they are consistent with each other.
+func Export(m *migrations.Migrator, w io.Writer) (map[string]int, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := m.DB().Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	header := Header{FormatVersion: FormatVersion, SchemaVersion: version, Dialect: m.Dialect(), ExportedAt: time.Now().UTC()}
+	if err = enc.Encode(header); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(tables))
+	for _, t := range tables {
+		n, err := exportTable(tx, m.Dialect(), t.name, enc)
+		if err != nil {
+			return counts, fmt.Errorf("export %s failed: %v", t.name, err)
+		}
+		counts[t.name] = n
+	}
+	return counts, bw.Flush()
+}
+
+func exportTable(tx *sql.Tx, dialect migrations.Dialect, name string, enc *json.Encoder) (int, error) {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT * FROM %s;`, name))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	n := 0
+	for rows.Next() {
+		if err = rows.Scan(pointers...); err != nil {
+			return n, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, c := range columns {
+			v := values[i]
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			row[canonicalColumn(dialect, c)] = v
+		}
+		if err = enc.Encode(line{Table: name, Row: row}); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+// ImportOptions change how Import writes the rows.
+type ImportOptions struct {
+	// Remap lets the database assign new ids, so a dump can be imported into a database that has data.
+	// References between the rows are rewritten to the new ids, rows referencing an id that isn't in
+	// the dump are skipped. Without it the ids are kept and every table of the dump must be empty.
+	Remap bool
+}
+
+// ImportResult counts what Import did.
+type ImportResult struct {
+	Header Header
+	// Imported and Skipped count the rows of each table.
+	Imported map[string]int
+	Skipped  map[string]int
+	// TranscriptionIDs maps the ids of the transcriptions in the dump to their ids in the database.
+	TranscriptionIDs map[int64]int64
+}
+
+// ErrNotEmpty is returned when ids are kept but a table of the database has rows already.
+var ErrNotEmpty = errors.New("table is not empty")
+
+// Import writes the rows of the dump read from r to the database of m in one transaction, nothing is
+// imported when it fails. The dump must not be from a newer schema than the one of the database.
+func Import(m *migrations.Migrator, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	result := ImportResult{
+		Imported:         make(map[string]int),
+		Skipped:          make(map[string]int),
+		TranscriptionIDs: make(map[int64]int64),
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+	if err := dec.Decode(&result.Header); err != nil {
+		return result, fmt.Errorf("read dump header failed: %v", err)
+	}
+	if result.Header.FormatVersion != FormatVersion {
+		return result, fmt.Errorf("not a v2t dump of format version %d", FormatVersion)
+	}
+	version, err := m.Version()
+	if err != nil {
+		return result, err
+	}
+	if result.Header.SchemaVersion > version {
+		return result, fmt.Errorf("the dump has schema version %d, the database %d, migrate the database first",
+			result.Header.SchemaVersion, version)
+	}
+
+	tx, err := m.DB().Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	im := &importer{
+		tx:      tx,
+		dialect: m.Dialect(),
+		remap:   opts.Remap,
+		ids:     map[string]map[int64]int64{"transcriptions": result.TranscriptionIDs, "transcription_segments": {}},
+		types:   make(map[string]map[string]string),
+		checked: make(map[string]bool),
+	}
+	for n := 2; ; n++ {
+		var l line
+		err = dec.Decode(&l)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("read dump line %d failed: %v", n, err)
+		}
+		imported, err := im.insert(l)
+		if err != nil {
+			return result, fmt.Errorf("import line %d into %s failed: %w", n, l.Table, err)
+		}
+		if imported {
+			result.Imported[l.Table]++
+		} else {
+			result.Skipped[l.Table]++
+		}
+	}
+
+	if m.Dialect() == migrations.Postgres && !opts.Remap {
+		if err = resetSequences(tx); err != nil {
+			return result, err
+		}
+	}
+	return result, tx.Commit()
+}
+
+type importer struct {
+	tx      *sql.Tx
+	dialect migrations.Dialect
+	remap   bool
+	// ids maps the old to the new ids of the referenced serial tables.
+	ids map[string]map[int64]int64
+	// types maps the columns of each table to their database type names.
+	types   map[string]map[string]string
+	checked map[string]bool
+}
+
+// insert writes a row of the dump, it reports false when the row was skipped.
+func (im *importer) insert(l line) (bool, error) {
+	t, ok := lookup(l.Table)
+	if !ok {
+		return false, errors.New("unknown table")
+	}
+	if !im.remap && !im.checked[t.name] {
+		var count int
+		if err := im.tx.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s;`, t.name)).Scan(&count); err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return false, ErrNotEmpty
+		}
+		im.checked[t.name] = true
+	}
+	types, err := im.columnTypes(t.name)
+	if err != nil {
+		return false, err
+	}
+
+	var oldID int64
+	columns := make([]string, 0, len(l.Row))
+	for c := range l.Row {
+		if _, ok := types[column(im.dialect, c)]; !ok {
+			return false, fmt.Errorf("unknown column %s", c)
+		}
+		if c == "id" && t.serial && im.remap {
+			oldID, _ = toInt(l.Row[c])
+			continue
+		}
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	values := make([]interface{}, len(columns))
+	for i, c := range columns {
+		v := convert(l.Row[c], types[column(im.dialect, c)])
+		if referenced, ok := t.references[c]; ok && im.remap {
+			id, _ := toInt(v)
+			newID, found := im.ids[referenced][id]
+			switch {
+			case found:
+				v = newID
+			case t.optional:
+				v = 0
+			default:
+				return false, nil
+			}
+		}
+		values[i] = v
+	}
+
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = column(im.dialect, c)
+		placeholders[i] = "?"
+		if im.dialect == migrations.Postgres {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, t.name, strings.Join(names, ", "), strings.Join(placeholders, ", "))
+
+	ids, tracked := im.ids[t.name]
+	if !im.remap || !tracked {
+		_, err = im.tx.Exec(query+";", values...)
+		return err == nil, err
+	}
+	var newID int64
+	if err = im.tx.QueryRow(query+" RETURNING id;", values...).Scan(&newID); err != nil {
+		return false, err
+	}
+	ids[oldID] = newID
+	return true, nil
+}
+
+// columnTypes returns the database type names of the columns of a table.
+func (im *importer) columnTypes(name string) (map[string]string, error) {
+	if types, ok := im.types[name]; ok {
+		return types, nil
+	}
+	rows, err := im.tx.Query(fmt.Sprintf(`SELECT * FROM %s WHERE 1 = 0;`, name))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]string, len(columnTypes))
+	for _, ct := range columnTypes {
+		types[ct.Name()] = strings.ToUpper(ct.DatabaseTypeName())
+	}
+	im.types[name] = types
+	return types, nil
+}
+
+// convert turns a JSON value into the value written to a column of typeName.
+func convert(v interface{}, typeName string) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case string:
+		if strings.HasPrefix(typeName, "TIMESTAMP") || typeName == "DATETIME" || typeName == "DATE" {
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return t
+			}
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch value := v.(type) {
+	case int64:
+		return value, true
+	case json.Number:
+		i, err := value.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// resetSequences moves the id sequences of PostgreSQL past the imported ids.
+func resetSequences(tx *sql.Tx) error {
+	for _, t := range tables {
+		if !t.serial {
+			continue
+		}
+		_, err := tx.Exec(fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %[1]s;`, t.name))
+		if err != nil {
+			return fmt.Errorf("reset the id sequence of %s failed: %v", t.name, err)
+		}
+	}
+	return nil
+}
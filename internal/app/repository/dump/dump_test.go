@@ -0,0 +1,150 @@
+package dump
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/repository/migrations"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestMigrator(t *testing.T, name string) *migrations.Migrator {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.New(db, migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seed stores a transcription with id with a segment, a word, metadata and a cost.
+func seed(t *testing.T, db *sql.DB, id int, fileName string) {
+	t.Helper()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mustExec(t, db, `INSERT INTO transcriptions (id, user, input_dir, file_name, mp3_file_name, audio_duration, transcription,
+		last_conversion_time, has_error) VALUES (?, 'alice', '/in', ?, ?, 60, 'hello world', ?, 0);`, id, fileName, fileName+".mp3", now)
+	mustExec(t, db, `INSERT INTO transcription_segments (id, transcription_id, position, start_seconds, end_seconds, text)
+		VALUES (?, ?, 0, 0, 1.5, 'hello world');`, id*10, id)
+	mustExec(t, db, `INSERT INTO transcription_words (segment_id, position, start_seconds, end_seconds, text, probability)
+		VALUES (?, 0, 0, 0.5, 'hello', 0.9);`, id*10)
+	mustExec(t, db, `INSERT INTO transcription_metadata (transcription_id, key, value) VALUES (?, 'topic', 'greetings');`, id)
+	mustExec(t, db, `INSERT INTO transcription_costs (transcription_id, user, file_path, cost, recorded_at)
+		VALUES (?, 'alice', ?, 0.006, ?);`, id, fileName, now)
+}
+
+func TestExportImport(t *testing.T) {
+	source := newTestMigrator(t, "source.db")
+	seed(t, source.DB(), 7, "a.mp4")
+	seed(t, source.DB(), 9, "b.mp4")
+	// A cost recorded for a file that was never stored.
+	mustExec(t, source.DB(), `INSERT INTO transcription_costs (transcription_id, user, file_path, cost, recorded_at)
+		VALUES (0, 'alice', 'c.mp4', 0.01, ?);`, time.Now())
+
+	var dump bytes.Buffer
+	counts, err := Export(source, &dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["transcriptions"] != 2 || counts["transcription_words"] != 2 || counts["transcription_costs"] != 3 {
+		t.Errorf("Export() counts = %v", counts)
+	}
+
+	tests := []struct {
+		name         string
+		existing     bool
+		opts         ImportOptions
+		wantErr      error
+		wantIDs      map[int64]int64
+		wantWordSegs []int64
+	}{
+		{name: "keep ids", wantIDs: map[int64]int64{}, wantWordSegs: []int64{70, 90}},
+		{name: "keep ids into a database with data", existing: true, wantErr: ErrNotEmpty},
+		{name: "remap into a database with data", existing: true, opts: ImportOptions{Remap: true},
+			wantIDs: map[int64]int64{7: 2, 9: 3}, wantWordSegs: []int64{10, 11, 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newTestMigrator(t, "target.db")
+			if tt.existing {
+				seed(t, target.DB(), 1, "existing.mp4")
+			}
+
+			result, err := Import(target, bytes.NewReader(dump.Bytes()), tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Import() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.TranscriptionIDs, tt.wantIDs) {
+				t.Errorf("Import() transcription ids = %v, want %v", result.TranscriptionIDs, tt.wantIDs)
+			}
+			for table, n := range counts {
+				if result.Imported[table] != n {
+					t.Errorf("Import() imported %d rows of %s, want %d", result.Imported[table], table, n)
+				}
+			}
+
+			var segments []int64
+			rows, err := target.DB().Query(`SELECT segment_id FROM transcription_words ORDER BY segment_id;`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for rows.Next() {
+				var id int64
+				rows.Scan(&id)
+				segments = append(segments, id)
+			}
+			rows.Close()
+			if !reflect.DeepEqual(segments, tt.wantWordSegs) {
+				t.Errorf("words reference segments %v, want %v", segments, tt.wantWordSegs)
+			}
+
+			newID := int64(9)
+			if tt.opts.Remap {
+				newID = tt.wantIDs[9]
+			}
+			var text, value string
+			var recorded time.Time
+			err = target.DB().QueryRow(`SELECT t.transcription, m.value, c.recorded_at FROM transcriptions t
+				JOIN transcription_metadata m ON m.transcription_id = t.id
+				JOIN transcription_costs c ON c.transcription_id = t.id WHERE t.id = ?;`, newID).Scan(&text, &value, &recorded)
+			if err != nil || text != "hello world" || value != "greetings" || !recorded.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+				t.Errorf("transcription %d = %q, %q, %v, %v", newID, text, value, recorded, err)
+			}
+		})
+	}
+}
+
+func TestImport_NewerSchema(t *testing.T) {
+	target := newTestMigrator(t, "target.db")
+	dump := `{"v2t_dump": 1, "schema_version": 99, "dialect": "postgres"}` + "\n"
+	if _, err := Import(target, strings.NewReader(dump), ImportOptions{}); err == nil || !strings.Contains(err.Error(), "99") {
+		t.Errorf("Import() error = %v, want the dump rejected", err)
+	}
+}
@@ -0,0 +1,890 @@
+// Package memory keeps transcriptions in memory, for tests, demos and runs whose results
+// shouldn't be persisted. Everything is lost when the process exits.
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+// row is a stored transcription, provider metadata is kept serialized like the databases do,
+// so callers never share it with the store.
+type row struct {
+	transcription   model.Transcription
+	inputDir        string
+	fileName        string
+	hasError        int
+	metadata        string
+	currentRevision int
+}
+
+type revision struct {
+	revision      int
+	transcription string
+	metadata      string
+	createdAt     time.Time
+}
+
+// MemoryDB implements the DAO interfaces on maps guarded by a lock, it is safe for concurrent use.
+type MemoryDB struct {
+	mu         sync.RWMutex
+	rows       []*row
+	revisions  map[int][]revision
+	segments   map[int][]model.Segment
+	artifacts  []model.Artifact
+	artifactID int
+	batchJobs  map[string]*model.BatchJob
+	costs      []model.CostEntry
+	// translations are keyed by transcription id, then language.
+	translations  map[int]map[string]model.Translation
+	translationID int
+	refineJobs    map[int]model.RefineJob
+	metadata      map[int]map[string]string
+	moderations   map[int]model.Moderation
+	// segmentTranslations are keyed by transcription id, then language and position.
+	segmentTranslations map[int]map[string]map[int]model.SegmentTranslation
+	// reviewCards are keyed by transcription id, then position.
+	reviewCards map[int]map[int]model.ReviewCard
+	speechStats map[int]model.SpeechStats
+	insights    map[int]model.Insights
+	// embeddings are keyed by transcription id, then model.
+	embeddings map[int]map[string]model.Embedding
+	indexModel string
+	duplicates []model.Duplicate
+}
+
+// NewMemoryDB creates a new, empty MemoryDB instance.
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		revisions:    make(map[int][]revision),
+		segments:     make(map[int][]model.Segment),
+		batchJobs:    make(map[string]*model.BatchJob),
+		translations: make(map[int]map[string]model.Translation),
+		refineJobs:   make(map[int]model.RefineJob),
+		metadata:     make(map[int]map[string]string),
+		moderations:  make(map[int]model.Moderation),
+
+		segmentTranslations: make(map[int]map[string]map[int]model.SegmentTranslation),
+		reviewCards:         make(map[int]map[int]model.ReviewCard),
+		speechStats:         make(map[int]model.SpeechStats),
+		insights:            make(map[int]model.Insights),
+		embeddings:          make(map[int]map[string]model.Embedding),
+	}
+}
+
+// Close does nothing, the data stays readable until the process exits.
+func (mdb *MemoryDB) Close() error {
+	return nil
+}
+
+func (mdb *MemoryDB) CheckIfFileProcessed(fileName string) (int, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	for _, r := range mdb.rows {
+		if r != nil && r.fileName == fileName && r.hasError == 0 {
+			return r.transcription.ID, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (mdb *MemoryDB) RecordToDB(user, inputDir, fileName, mp3FileName string, audioDuration int, transcription string,
+	lastConversionTime time.Time, hasError int, errorMessage string, providerMetadata model.ProviderMetadata) {
+	metadata, err := providerMetadata.JSON()
+	if err != nil {
+		logging.L().Error("Failed to serialize provider metadata", "error", err)
+	}
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	mdb.rows = append(mdb.rows, &row{
+		transcription: model.Transcription{
+			ID:                 len(mdb.rows) + 1,
+			User:               user,
+			LastConversionTime: lastConversionTime,
+			Mp3FileName:        mp3FileName,
+			AudioDuration:      float64(audioDuration),
+			Transcription:      transcription,
+			ErrorMessage:       errorMessage,
+		},
+		inputDir: inputDir,
+		fileName: fileName,
+		hasError: hasError,
+		metadata: metadata,
+	})
+}
+
+func (mdb *MemoryDB) GetAllByUser(userNickname string) ([]model.Transcription, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	transcriptions := make([]model.Transcription, 0)
+	for _, r := range mdb.rows {
+		if r != nil && r.hasError == 0 && r.transcription.User == userNickname {
+			transcriptions = append(transcriptions, r.read())
+		}
+	}
+	sort.SliceStable(transcriptions, func(i, j int) bool {
+		return transcriptions[i].LastConversionTime.After(transcriptions[j].LastConversionTime)
+	})
+	return transcriptions, nil
+}
+
+func (mdb *MemoryDB) ListByUser(userNickname string, opts repository.ListOptions) ([]model.Transcription, error) {
+	var less func(a, b model.Transcription) bool
+	switch opts.SortBy {
+	case repository.SortByTime, "":
+		less = func(a, b model.Transcription) bool { return a.LastConversionTime.Before(b.LastConversionTime) }
+	case repository.SortByID:
+		less = func(a, b model.Transcription) bool { return false }
+	case repository.SortByDuration:
+		less = func(a, b model.Transcription) bool { return a.AudioDuration < b.AudioDuration }
+	case repository.SortByFile:
+		less = func(a, b model.Transcription) bool { return a.Mp3FileName < b.Mp3FileName }
+	default:
+		return nil, fmt.Errorf("unknown sort field %q", opts.SortBy)
+	}
+
+	transcriptions, _ := mdb.GetAllByUser(userNickname)
+	sort.Slice(transcriptions, func(i, j int) bool {
+		a, b := transcriptions[i], transcriptions[j]
+		if !opts.Ascending {
+			a, b = b, a
+		}
+		if less(a, b) || less(b, a) {
+			return less(a, b)
+		}
+		return a.ID < b.ID
+	})
+
+	if opts.Offset >= len(transcriptions) {
+		return make([]model.Transcription, 0), nil
+	}
+	transcriptions = transcriptions[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(transcriptions) {
+		transcriptions = transcriptions[:opts.Limit]
+	}
+	return transcriptions, nil
+}
+
+func (mdb *MemoryDB) GetByID(id int) (*model.Transcription, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	r, err := mdb.row(id)
+	if err != nil {
+		return nil, err
+	}
+	t := r.read()
+	return &t, nil
+}
+
+func (mdb *MemoryDB) UpdateTranscription(t model.Transcription) error {
+	metadata, err := t.ProviderMetadata.JSON()
+	if err != nil {
+		return fmt.Errorf("serialize provider metadata failed: %v", err)
+	}
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	r, err := mdb.row(t.ID)
+	if err != nil {
+		return fmt.Errorf("no transcription %d", t.ID)
+	}
+	t.ProviderMetadata = model.ProviderMetadata{}
+	t.Segments = nil
+	r.transcription = t
+	r.metadata = metadata
+	return nil
+}
+
+func (mdb *MemoryDB) DeleteTranscription(id int) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if _, err := mdb.row(id); err != nil {
+		return fmt.Errorf("no transcription %d", id)
+	}
+	mdb.rows[id-1] = nil
+	delete(mdb.revisions, id)
+	delete(mdb.segments, id)
+	delete(mdb.translations, id)
+	delete(mdb.refineJobs, id)
+	delete(mdb.metadata, id)
+	delete(mdb.moderations, id)
+	delete(mdb.segmentTranslations, id)
+	delete(mdb.reviewCards, id)
+	delete(mdb.speechStats, id)
+	delete(mdb.insights, id)
+	delete(mdb.embeddings, id)
+	duplicates := mdb.duplicates[:0]
+	for _, d := range mdb.duplicates {
+		if d.TranscriptionID != id && d.DuplicateOf != id {
+			duplicates = append(duplicates, d)
+		}
+	}
+	mdb.duplicates = duplicates
+
+	artifacts := mdb.artifacts[:0]
+	for _, a := range mdb.artifacts {
+		if a.TranscriptionID != id {
+			artifacts = append(artifacts, a)
+		}
+	}
+	mdb.artifacts = artifacts
+	return nil
+}
+
+func (mdb *MemoryDB) GetUserStats() ([]model.UserStats, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	byUser := make(map[string]*model.UserStats)
+	for _, r := range mdb.rows {
+		if r == nil || r.hasError != 0 {
+			continue
+		}
+		s, ok := byUser[r.transcription.User]
+		if !ok {
+			s = &model.UserStats{User: r.transcription.User}
+			byUser[r.transcription.User] = s
+		}
+		s.VideoCount++
+		s.TotalAudioDuration += r.transcription.AudioDuration
+	}
+
+	stats := make([]model.UserStats, 0, len(byUser))
+	for _, s := range byUser {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].User < stats[j].User })
+	return stats, nil
+}
+
+func (mdb *MemoryDB) GetArtifact(transcriptionID int, format string) (*model.Artifact, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	for i := len(mdb.artifacts) - 1; i >= 0; i-- {
+		if a := mdb.artifacts[i]; a.TranscriptionID == transcriptionID && a.Format == format {
+			return &a, nil
+		}
+	}
+	return nil, fmt.Errorf("db scan failed: %w", sql.ErrNoRows)
+}
+
+func (mdb *MemoryDB) SaveArtifact(a model.Artifact) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	mdb.artifactID++
+	a.ID = mdb.artifactID
+	mdb.artifacts = append(mdb.artifacts, a)
+	return nil
+}
+
+func (mdb *MemoryDB) AddRevision(transcriptionID int, transcription string, providerMetadata model.ProviderMetadata, createdAt time.Time) (int, error) {
+	metadata, err := providerMetadata.JSON()
+	if err != nil {
+		return 0, fmt.Errorf("serialize provider metadata failed: %v", err)
+	}
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	r, err := mdb.row(transcriptionID)
+	if err != nil {
+		return 0, err
+	}
+
+	// The first result was stored before revisions existed, keep it as revision 1
+	revisions := mdb.revisions[transcriptionID]
+	if r.currentRevision == 0 {
+		revisions = append(revisions, revision{revision: 1, transcription: r.transcription.Transcription,
+			metadata: r.metadata, createdAt: r.transcription.LastConversionTime})
+	}
+	number := revisions[len(revisions)-1].revision + 1
+	mdb.revisions[transcriptionID] = append(revisions, revision{revision: number, transcription: transcription,
+		metadata: metadata, createdAt: createdAt})
+
+	r.transcription.Transcription = transcription
+	r.transcription.LastConversionTime = createdAt
+	r.metadata = metadata
+	r.currentRevision = number
+	return number, nil
+}
+
+func (mdb *MemoryDB) GetRevisions(transcriptionID int) ([]model.Revision, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	revisions := make([]model.Revision, 0)
+	r, err := mdb.row(transcriptionID)
+	if err != nil {
+		return revisions, nil
+	}
+	for _, rev := range mdb.revisions[transcriptionID] {
+		metadata, err := model.ParseProviderMetadata(rev.metadata)
+		if err != nil {
+			logging.L().Warn("Ignore invalid provider metadata", "id", transcriptionID, "revision", rev.revision, "error", err)
+		}
+		revisions = append(revisions, model.Revision{
+			TranscriptionID:  transcriptionID,
+			Revision:         rev.revision,
+			Transcription:    rev.transcription,
+			ProviderMetadata: metadata,
+			CreatedAt:        rev.createdAt,
+			Current:          rev.revision == r.currentRevision,
+		})
+	}
+	return revisions, nil
+}
+
+func (mdb *MemoryDB) SetCurrentRevision(transcriptionID int, number int) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	r, err := mdb.row(transcriptionID)
+	if err == nil {
+		for _, rev := range mdb.revisions[transcriptionID] {
+			if rev.revision == number {
+				r.transcription.Transcription = rev.transcription
+				r.metadata = rev.metadata
+				r.currentRevision = number
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("transcription %d has no revision %d", transcriptionID, number)
+}
+
+func (mdb *MemoryDB) SetSourceURL(transcriptionID int, sourceURL string) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	r, err := mdb.row(transcriptionID)
+	if err != nil {
+		return err
+	}
+	r.transcription.SourceURL = sourceURL
+	return nil
+}
+
+func (mdb *MemoryDB) SaveSegments(transcriptionID int, segments []model.Segment) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	mdb.segments[transcriptionID] = copySegments(segments)
+	return nil
+}
+
+func (mdb *MemoryDB) GetSegments(transcriptionID int) ([]model.Segment, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	return copySegments(mdb.segments[transcriptionID]), nil
+}
+
+func (mdb *MemoryDB) CreateBatchJob(job model.BatchJob) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if _, ok := mdb.batchJobs[job.ID]; ok {
+		return fmt.Errorf("batch job %s already exists", job.ID)
+	}
+	job.Files = append([]model.BatchFile(nil), job.Files...)
+	mdb.batchJobs[job.ID] = &job
+	return nil
+}
+
+func (mdb *MemoryDB) GetBatchJob(id string) (*model.BatchJob, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	job, ok := mdb.batchJobs[id]
+	if !ok {
+		return nil, fmt.Errorf("db scan failed: %w", sql.ErrNoRows)
+	}
+	copied := *job
+	copied.Files = append([]model.BatchFile(nil), job.Files...)
+	return &copied, nil
+}
+
+func (mdb *MemoryDB) SetBatchFileStatus(jobID string, path string, status model.BatchFileStatus, errorMessage string, updatedAt time.Time) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if job, ok := mdb.batchJobs[jobID]; ok {
+		for i := range job.Files {
+			if f := &job.Files[i]; f.Path == path {
+				f.Status, f.Error, f.UpdatedAt = status, errorMessage, updatedAt
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("batch job %s has no file %s", jobID, path)
+}
+
+func (mdb *MemoryDB) RecordCost(e model.CostEntry) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	e.ID = len(mdb.costs) + 1
+	mdb.costs = append(mdb.costs, e)
+	return nil
+}
+
+func (mdb *MemoryDB) GetCosts(since time.Time) ([]model.CostEntry, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	entries := make([]model.CostEntry, 0)
+	for _, e := range mdb.costs {
+		if !e.RecordedAt.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].RecordedAt.Before(entries[j].RecordedAt) })
+	return entries, nil
+}
+
+func (mdb *MemoryDB) SaveTranslation(t model.Translation) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	byLanguage := mdb.translations[t.TranscriptionID]
+	if byLanguage == nil {
+		byLanguage = make(map[string]model.Translation)
+		mdb.translations[t.TranscriptionID] = byLanguage
+	}
+	if previous, ok := byLanguage[t.Language]; ok {
+		t.ID = previous.ID
+	} else {
+		mdb.translationID++
+		t.ID = mdb.translationID
+	}
+	byLanguage[t.Language] = t
+	return nil
+}
+
+func (mdb *MemoryDB) GetTranslation(transcriptionID int, language string) (*model.Translation, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	t, ok := mdb.translations[transcriptionID][language]
+	if !ok {
+		return nil, fmt.Errorf("db scan failed: %w", sql.ErrNoRows)
+	}
+	return &t, nil
+}
+
+func (mdb *MemoryDB) SaveModeration(m model.Moderation) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	m.Scores = copyScores(m.Scores)
+	m.Flagged = append([]string(nil), m.Flagged...)
+	mdb.moderations[m.TranscriptionID] = m
+	return nil
+}
+
+func (mdb *MemoryDB) GetModeration(transcriptionID int) (*model.Moderation, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	m, ok := mdb.moderations[transcriptionID]
+	if !ok {
+		return nil, fmt.Errorf("db scan failed: %w", sql.ErrNoRows)
+	}
+	m.Scores = copyScores(m.Scores)
+	m.Flagged = append([]string(nil), m.Flagged...)
+	return &m, nil
+}
+
+func (mdb *MemoryDB) SaveSegmentTranslations(translations []model.SegmentTranslation) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	for _, t := range translations {
+		byLanguage := mdb.segmentTranslations[t.TranscriptionID]
+		if byLanguage == nil {
+			byLanguage = make(map[string]map[int]model.SegmentTranslation)
+			mdb.segmentTranslations[t.TranscriptionID] = byLanguage
+		}
+		if byLanguage[t.Language] == nil {
+			byLanguage[t.Language] = make(map[int]model.SegmentTranslation)
+		}
+		byLanguage[t.Language][t.Position] = t
+	}
+	return nil
+}
+
+func (mdb *MemoryDB) GetSegmentTranslations(transcriptionID int, language string) (map[int]model.SegmentTranslation, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	translations := make(map[int]model.SegmentTranslation)
+	for position, t := range mdb.segmentTranslations[transcriptionID][language] {
+		translations[position] = t
+	}
+	return translations, nil
+}
+
+func (mdb *MemoryDB) SaveReviewCard(c model.ReviewCard) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	byPosition := mdb.reviewCards[c.TranscriptionID]
+	if byPosition == nil {
+		byPosition = make(map[int]model.ReviewCard)
+		mdb.reviewCards[c.TranscriptionID] = byPosition
+	}
+	byPosition[c.Position] = c
+	return nil
+}
+
+func (mdb *MemoryDB) DeleteReviewCard(transcriptionID int, position int) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	delete(mdb.reviewCards[transcriptionID], position)
+	return nil
+}
+
+func (mdb *MemoryDB) GetReviewCards(userNickname string) ([]model.ReviewCard, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	cards := make([]model.ReviewCard, 0)
+	for id, byPosition := range mdb.reviewCards {
+		if r, err := mdb.row(id); err != nil || r.transcription.User != userNickname {
+			continue
+		}
+		for _, c := range byPosition {
+			cards = append(cards, c)
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		if !cards[i].DueAt.Equal(cards[j].DueAt) {
+			return cards[i].DueAt.Before(cards[j].DueAt)
+		}
+		if cards[i].TranscriptionID != cards[j].TranscriptionID {
+			return cards[i].TranscriptionID < cards[j].TranscriptionID
+		}
+		return cards[i].Position < cards[j].Position
+	})
+	return cards, nil
+}
+
+func (mdb *MemoryDB) SaveSpeechStats(s model.SpeechStats) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	s.FileName = ""
+	s.Date = time.Time{}
+	mdb.speechStats[s.TranscriptionID] = s
+	return nil
+}
+
+func (mdb *MemoryDB) GetSpeechStats(userNickname string) ([]model.SpeechStats, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	stats := make([]model.SpeechStats, 0)
+	for id, s := range mdb.speechStats {
+		r, err := mdb.row(id)
+		if err != nil || r.transcription.User != userNickname {
+			continue
+		}
+		s.FileName = r.transcription.Mp3FileName
+		s.Date = r.transcription.LastConversionTime
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if !stats[i].Date.Equal(stats[j].Date) {
+			return stats[i].Date.Before(stats[j].Date)
+		}
+		return stats[i].TranscriptionID < stats[j].TranscriptionID
+	})
+	return stats, nil
+}
+
+func (mdb *MemoryDB) ReplaceDuplicates(userNickname string, duplicates []model.Duplicate) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	kept := mdb.duplicates[:0]
+	for _, d := range mdb.duplicates {
+		if r, err := mdb.row(d.TranscriptionID); err == nil && r.transcription.User != userNickname {
+			kept = append(kept, d)
+		}
+	}
+	for _, d := range duplicates {
+		d.FileName, d.DuplicateFileName = "", ""
+		kept = append(kept, d)
+	}
+	mdb.duplicates = kept
+	return nil
+}
+
+func (mdb *MemoryDB) GetDuplicates(userNickname string) ([]model.Duplicate, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	duplicates := make([]model.Duplicate, 0)
+	for _, d := range mdb.duplicates {
+		r, err := mdb.row(d.TranscriptionID)
+		if err != nil || r.transcription.User != userNickname {
+			continue
+		}
+		original, err := mdb.row(d.DuplicateOf)
+		if err != nil {
+			continue
+		}
+		d.FileName = r.transcription.Mp3FileName
+		d.DuplicateFileName = original.transcription.Mp3FileName
+		duplicates = append(duplicates, d)
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].Similarity != duplicates[j].Similarity {
+			return duplicates[i].Similarity > duplicates[j].Similarity
+		}
+		if duplicates[i].TranscriptionID != duplicates[j].TranscriptionID {
+			return duplicates[i].TranscriptionID < duplicates[j].TranscriptionID
+		}
+		return duplicates[i].DuplicateOf < duplicates[j].DuplicateOf
+	})
+	return duplicates, nil
+}
+
+func (mdb *MemoryDB) SaveInsights(transcriptionID int, insights model.Insights) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	mdb.insights[transcriptionID] = copyInsights(insights)
+	return nil
+}
+
+func (mdb *MemoryDB) GetInsights(transcriptionID int) (model.Insights, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	return copyInsights(mdb.insights[transcriptionID]), nil
+}
+
+func (mdb *MemoryDB) GetTranscriptionsWithoutEmbeddings(embeddingModel string, afterID int, limit int) ([]model.Transcription, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	transcriptions := make([]model.Transcription, 0)
+	for _, r := range mdb.rows {
+		if limit > 0 && len(transcriptions) == limit {
+			break
+		}
+		if mdb.embeddable(r) && r.transcription.ID > afterID && !mdb.embedded(r, embeddingModel) {
+			transcriptions = append(transcriptions, r.transcription)
+		}
+	}
+	return transcriptions, nil
+}
+
+func (mdb *MemoryDB) SaveEmbedding(e model.Embedding) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if mdb.embeddings[e.TranscriptionID] == nil {
+		mdb.embeddings[e.TranscriptionID] = make(map[string]model.Embedding)
+	}
+	e.Vector = append([]float32(nil), e.Vector...)
+	mdb.embeddings[e.TranscriptionID][e.Model] = e
+	return nil
+}
+
+func (mdb *MemoryDB) GetEmbedding(transcriptionID int, embeddingModel string) (*model.Embedding, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	e, ok := mdb.embeddings[transcriptionID][embeddingModel]
+	if !ok {
+		return nil, fmt.Errorf("db scan failed: %w", sql.ErrNoRows)
+	}
+	e.Vector = append([]float32(nil), e.Vector...)
+	return &e, nil
+}
+
+func (mdb *MemoryDB) GetEmbeddingProgress(embeddingModel string) (model.EmbeddingProgress, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	progress := model.EmbeddingProgress{Model: embeddingModel}
+	for _, r := range mdb.rows {
+		if !mdb.embeddable(r) {
+			continue
+		}
+		if mdb.embedded(r, embeddingModel) {
+			progress.Embedded++
+		} else {
+			progress.Pending++
+		}
+	}
+	return progress, nil
+}
+
+func (mdb *MemoryDB) GetIndexModel() (string, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	return mdb.indexModel, nil
+}
+
+func (mdb *MemoryDB) SetIndexModel(embeddingModel string, dropOthers bool, activatedAt time.Time) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	mdb.indexModel = embeddingModel
+	if dropOthers {
+		for _, byModel := range mdb.embeddings {
+			for m := range byModel {
+				if m != embeddingModel {
+					delete(byModel, m)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// embeddable tells whether r has text to embed.
+func (mdb *MemoryDB) embeddable(r *row) bool {
+	return r != nil && r.hasError == 0 && r.transcription.Transcription != ""
+}
+
+// embedded tells whether r has an embedding of embeddingModel made since its last change.
+func (mdb *MemoryDB) embedded(r *row, embeddingModel string) bool {
+	e, ok := mdb.embeddings[r.transcription.ID][embeddingModel]
+	return ok && !e.EmbeddedAt.Before(r.transcription.LastConversionTime)
+}
+
+func copyInsights(insights model.Insights) model.Insights {
+	return model.Insights{
+		Chapters:   append([]model.Chapter(nil), insights.Chapters...),
+		Entities:   append([]model.Entity(nil), insights.Entities...),
+		Sentiments: append([]model.Sentiment(nil), insights.Sentiments...),
+	}
+}
+
+func copyScores(scores map[string]float64) map[string]float64 {
+	copied := make(map[string]float64, len(scores))
+	for category, score := range scores {
+		copied[category] = score
+	}
+	return copied
+}
+
+func (mdb *MemoryDB) QueueRefine(job model.RefineJob) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	mdb.refineJobs[job.TranscriptionID] = job
+	return nil
+}
+
+func (mdb *MemoryDB) GetRefineJobs(status model.RefineStatus) ([]model.RefineJob, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	jobs := make([]model.RefineJob, 0)
+	for _, job := range mdb.refineJobs {
+		if status == "" || job.Status == status {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].QueuedAt.Equal(jobs[j].QueuedAt) {
+			return jobs[i].QueuedAt.Before(jobs[j].QueuedAt)
+		}
+		return jobs[i].TranscriptionID < jobs[j].TranscriptionID
+	})
+	return jobs, nil
+}
+
+func (mdb *MemoryDB) SetRefineStatus(transcriptionID int, status model.RefineStatus, errorMessage string, updatedAt time.Time) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	job, ok := mdb.refineJobs[transcriptionID]
+	if !ok {
+		return fmt.Errorf("transcription %d is not queued for refinement", transcriptionID)
+	}
+	job.Status, job.Error, job.UpdatedAt = status, errorMessage, updatedAt
+	mdb.refineJobs[transcriptionID] = job
+	return nil
+}
+
+func (mdb *MemoryDB) GetMetadata(transcriptionID int) (map[string]string, error) {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	values := make(map[string]string, len(mdb.metadata[transcriptionID]))
+	for key, value := range mdb.metadata[transcriptionID] {
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (mdb *MemoryDB) SetMetadata(transcriptionID int, values map[string]string) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	stored := mdb.metadata[transcriptionID]
+	if stored == nil {
+		stored = make(map[string]string)
+		mdb.metadata[transcriptionID] = stored
+	}
+	for key, value := range values {
+		if value == "" {
+			delete(stored, key)
+		} else {
+			stored[key] = value
+		}
+	}
+	return nil
+}
+
+// row returns the stored transcription with id, the caller holds the lock. Rows are indexed by
+// id - 1, a deleted transcription leaves nil so ids are never reused.
+func (mdb *MemoryDB) row(id int) (*row, error) {
+	if id < 1 || id > len(mdb.rows) || mdb.rows[id-1] == nil {
+		return nil, fmt.Errorf("db scan failed: %w", sql.ErrNoRows)
+	}
+	return mdb.rows[id-1], nil
+}
+
+// read returns a copy of the transcription with its metadata parsed.
+func (r *row) read() model.Transcription {
+	t := r.transcription
+	var err error
+	t.ProviderMetadata, err = model.ParseProviderMetadata(r.metadata)
+	if err != nil {
+		logging.L().Warn("Ignore invalid provider metadata", "id", t.ID, "error", err)
+	}
+	return t
+}
+
+func copySegments(segments []model.Segment) []model.Segment {
+	copied := make([]model.Segment, len(segments))
+	for i, s := range segments {
+		s.Words = append([]model.Word(nil), s.Words...)
+		copied[i] = s
+	}
+	return copied
+}
@@ -0,0 +1,292 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+// MemoryDB is used wherever a database is, it keeps everything the databases keep.
+var (
+	_ repository.TranscriptionDAO = (*MemoryDB)(nil)
+	_ repository.ArtifactDAO      = (*MemoryDB)(nil)
+	_ repository.RevisionDAO      = (*MemoryDB)(nil)
+	_ repository.SegmentDAO       = (*MemoryDB)(nil)
+	_ repository.BatchDAO         = (*MemoryDB)(nil)
+	_ repository.CostDAO          = (*MemoryDB)(nil)
+	_ repository.SourceDAO        = (*MemoryDB)(nil)
+	_ repository.TranslationDAO   = (*MemoryDB)(nil)
+	_ repository.RefineDAO        = (*MemoryDB)(nil)
+	_ repository.MetadataDAO      = (*MemoryDB)(nil)
+	_ repository.ModerationDAO    = (*MemoryDB)(nil)
+	_ repository.ReviewDAO        = (*MemoryDB)(nil)
+	_ repository.SpeechStatsDAO   = (*MemoryDB)(nil)
+)
+
+func TestMemoryDB_Transcriptions(t *testing.T) {
+	mdb := NewMemoryDB()
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	mdb.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 30, "older", now, 0, "", model.ProviderMetadata{Provider: "openai"})
+	mdb.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 60, "newer", now.Add(time.Hour), 0, "", model.ProviderMetadata{})
+	mdb.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 0, "", now, 1, "FFmpeg error", model.ProviderMetadata{})
+	mdb.RecordToDB("bob", "/in", "d.mp4", "d.mp3", 10, "bob's", now, 0, "", model.ProviderMetadata{})
+
+	tests := []struct {
+		name     string
+		fileName string
+		wantID   int
+		wantErr  bool
+	}{
+		{name: "processed", fileName: "b.mp4", wantID: 2},
+		{name: "failed", fileName: "c.mp4", wantErr: true},
+		{name: "unknown", fileName: "e.mp4", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := mdb.CheckIfFileProcessed(tt.fileName)
+			if (err != nil) != tt.wantErr || id != tt.wantID {
+				t.Errorf("CheckIfFileProcessed() = %d, %v, want %d, wantErr %v", id, err, tt.wantID, tt.wantErr)
+			}
+		})
+	}
+
+	all, err := mdb.GetAllByUser("alice")
+	if err != nil || len(all) != 2 || all[0].Transcription != "newer" || all[1].ProviderMetadata.Provider != "openai" {
+		t.Errorf("GetAllByUser() = %+v, %v, want the two converted files, newest first", all, err)
+	}
+	all[1].ProviderMetadata.Provider = "changed"
+	if got, _ := mdb.GetByID(1); got.ProviderMetadata.Provider != "openai" {
+		t.Errorf("changing a result changed the stored transcription")
+	}
+	if _, err = mdb.GetByID(9); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID() of an unknown id error = %v, want sql.ErrNoRows", err)
+	}
+
+	stats, _ := mdb.GetUserStats()
+	want := []model.UserStats{{User: "alice", VideoCount: 2, TotalAudioDuration: 90}, {User: "bob", VideoCount: 1, TotalAudioDuration: 10}}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("GetUserStats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestMemoryDB_ListUpdateDelete(t *testing.T) {
+	mdb := NewMemoryDB()
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	mdb.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 30, "a", now, 0, "", model.ProviderMetadata{})
+	mdb.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 10, "b", now.Add(time.Hour), 0, "", model.ProviderMetadata{})
+	mdb.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 20, "c", now.Add(-time.Hour), 0, "", model.ProviderMetadata{})
+
+	tests := []struct {
+		name    string
+		opts    repository.ListOptions
+		wantIDs []int
+	}{
+		{name: "all newest first", wantIDs: []int{2, 1, 3}},
+		{name: "page", opts: repository.ListOptions{Limit: 2, Offset: 1}, wantIDs: []int{1, 3}},
+		{name: "past the end", opts: repository.ListOptions{Offset: 5}, wantIDs: []int{}},
+		{name: "shortest first", opts: repository.ListOptions{SortBy: repository.SortByDuration, Ascending: true}, wantIDs: []int{2, 3, 1}},
+		{name: "by id", opts: repository.ListOptions{SortBy: repository.SortByID}, wantIDs: []int{3, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mdb.ListByUser("alice", tt.opts)
+			ids := make([]int, 0, len(got))
+			for _, tr := range got {
+				ids = append(ids, tr.ID)
+			}
+			if err != nil || !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ListByUser() ids = %v, %v, want %v", ids, err, tt.wantIDs)
+			}
+		})
+	}
+
+	tr, _ := mdb.GetByID(1)
+	tr.Transcription = "changed"
+	tr.ProviderMetadata.Provider = "openai"
+	if err := mdb.UpdateTranscription(*tr); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := mdb.GetByID(1); got.Transcription != "changed" || got.ProviderMetadata.Provider != "openai" {
+		t.Errorf("GetByID() after update = %+v", got)
+	}
+
+	mdb.SaveArtifact(model.Artifact{TranscriptionID: 1, Format: "srt"})
+	if err := mdb.DeleteTranscription(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mdb.GetByID(1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID() after delete error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := mdb.GetArtifact(1, "srt"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetArtifact() after delete error = %v, want sql.ErrNoRows", err)
+	}
+	mdb.RecordToDB("alice", "/in", "d.mp4", "d.mp3", 20, "d", now, 0, "", model.ProviderMetadata{})
+	if all, _ := mdb.GetAllByUser("alice"); len(all) != 3 || mdb.DeleteTranscription(1) == nil {
+		t.Errorf("GetAllByUser() = %+v, want the deleted id not reused", all)
+	}
+	if id, _ := mdb.CheckIfFileProcessed("d.mp4"); id != 4 {
+		t.Errorf("CheckIfFileProcessed() = %d, want 4", id)
+	}
+}
+
+func TestMemoryDB_Revisions(t *testing.T) {
+	mdb := NewMemoryDB()
+	mdb.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 30, "first", time.Now(), 0, "", model.ProviderMetadata{Provider: "openai"})
+
+	for i, text := range []string{"second", "third"} {
+		if revision, err := mdb.AddRevision(1, text, model.ProviderMetadata{Provider: "whisper_cpp"}, time.Now()); err != nil || revision != i+2 {
+			t.Fatalf("AddRevision() = %d, %v, want %d", revision, err, i+2)
+		}
+	}
+	if err := mdb.SetCurrentRevision(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mdb.SetCurrentRevision(1, 9); err == nil {
+		t.Errorf("SetCurrentRevision() of an unknown revision succeeded")
+	}
+
+	revisions, _ := mdb.GetRevisions(1)
+	if len(revisions) != 3 || !revisions[0].Current || revisions[2].Current || revisions[2].ProviderMetadata.Provider != "whisper_cpp" {
+		t.Errorf("GetRevisions() = %+v, want three with the first current", revisions)
+	}
+	if got, _ := mdb.GetByID(1); got.Transcription != "first" || got.ProviderMetadata.Provider != "openai" {
+		t.Errorf("GetByID() = %+v, want the first revision", got)
+	}
+}
+
+func TestMemoryDB_Ledgers(t *testing.T) {
+	mdb := NewMemoryDB()
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	segments := []model.Segment{{Start: 0, End: 1, Text: "hello", Words: []model.Word{{Text: "hello"}}}}
+	mdb.SaveSegments(1, segments)
+	segments[0].Words[0].Text = "changed"
+	if got, _ := mdb.GetSegments(1); len(got) != 1 || got[0].Words[0].Text != "hello" {
+		t.Errorf("GetSegments() = %+v, want the saved segment", got)
+	}
+
+	insights := model.Insights{Chapters: []model.Chapter{{Headline: "Greetings"}}, Entities: []model.Entity{{Type: "person_name", Text: "Bob"}}}
+	mdb.SaveInsights(1, insights)
+	insights.Chapters[0].Headline = "changed"
+	if got, _ := mdb.GetInsights(1); len(got.Chapters) != 1 || got.Chapters[0].Headline != "Greetings" || len(got.Entities) != 1 {
+		t.Errorf("GetInsights() = %+v, want the saved insights", got)
+	}
+
+	vector := []float32{1, 2}
+	mdb.SaveEmbedding(model.Embedding{TranscriptionID: 1, Model: "m", Vector: vector, EmbeddedAt: now})
+	vector[0] = 9
+	if got, err := mdb.GetEmbedding(1, "m"); err != nil || got.Vector[0] != 1 {
+		t.Errorf("GetEmbedding() = %+v, %v, want the saved vector", got, err)
+	}
+	if _, err := mdb.GetEmbedding(1, "other"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetEmbedding() of another model error = %v, want sql.ErrNoRows", err)
+	}
+	mdb.SaveEmbedding(model.Embedding{TranscriptionID: 2, Model: "old", Vector: vector, EmbeddedAt: now})
+	mdb.SetIndexModel("m", true, now)
+	if got, _ := mdb.GetIndexModel(); got != "m" {
+		t.Errorf("GetIndexModel() = %q, want m", got)
+	}
+	if _, err := mdb.GetEmbedding(2, "old"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetEmbedding() of a dropped model error = %v, want sql.ErrNoRows", err)
+	}
+
+	mdb.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "hello", now, 0, "", model.ProviderMetadata{})
+	mdb.RecordToDB("alice", "/in", "a2.mp4", "a2.mp3", 60, "hello", now, 0, "", model.ProviderMetadata{})
+	mdb.ReplaceDuplicates("alice", []model.Duplicate{{TranscriptionID: 2, DuplicateOf: 1, Similarity: 1}})
+	if got, _ := mdb.GetDuplicates("alice"); len(got) != 1 || got[0].FileName != "a2.mp3" || got[0].DuplicateFileName != "a.mp3" {
+		t.Errorf("GetDuplicates() = %+v, want the duplicate with its file names", got)
+	}
+	mdb.DeleteTranscription(1)
+	if got, _ := mdb.GetDuplicates("alice"); len(got) != 0 {
+		t.Errorf("GetDuplicates() after deleting the original = %+v, want none", got)
+	}
+
+	mdb.SaveArtifact(model.Artifact{TranscriptionID: 1, Format: "srt", ContentHash: "old"})
+	mdb.SaveArtifact(model.Artifact{TranscriptionID: 1, Format: "srt", ContentHash: "new"})
+	if a, err := mdb.GetArtifact(1, "srt"); err != nil || a.ContentHash != "new" || a.ID != 2 {
+		t.Errorf("GetArtifact() = %+v, %v, want the latest", a, err)
+	}
+
+	job := model.BatchJob{ID: "job", Kind: model.BatchAudio, Files: []model.BatchFile{{Path: "a.mp3", Status: model.BatchFilePending}}}
+	mdb.CreateBatchJob(job)
+	if err := mdb.SetBatchFileStatus("job", "a.mp3", model.BatchFileDone, "", now); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := mdb.GetBatchJob("job"); got.Files[0].Status != model.BatchFileDone || job.Files[0].Status != model.BatchFilePending {
+		t.Errorf("GetBatchJob() = %+v, want the file done", got)
+	}
+	if _, err := mdb.GetBatchJob("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetBatchJob() of an unknown job error = %v, want sql.ErrNoRows", err)
+	}
+
+	mdb.RecordCost(model.CostEntry{Cost: 1, RecordedAt: now.Add(time.Hour)})
+	mdb.RecordCost(model.CostEntry{Cost: 2, RecordedAt: now.Add(-time.Hour)})
+	mdb.RecordCost(model.CostEntry{Cost: 3, RecordedAt: now})
+	if costs, _ := mdb.GetCosts(now); len(costs) != 2 || costs[0].Cost != 3 || costs[1].ID != 1 {
+		t.Errorf("GetCosts() = %+v, want the two entries since now, oldest first", costs)
+	}
+
+	mdb.SaveTranslation(model.Translation{TranscriptionID: 1, Language: "en", Backend: "openai", Text: "old"})
+	mdb.SaveTranslation(model.Translation{TranscriptionID: 1, Language: "ja", Backend: "openai", Text: "日本語"})
+	mdb.SaveTranslation(model.Translation{TranscriptionID: 1, Language: "en", Backend: "deepl", Text: "new"})
+	if tr, err := mdb.GetTranslation(1, "en"); err != nil || tr.Text != "new" || tr.Backend != "deepl" || tr.ID != 1 {
+		t.Errorf("GetTranslation() = %+v, %v, want the replaced translation", tr, err)
+	}
+	if _, err := mdb.GetTranslation(1, "fr"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetTranslation() of an untranslated language error = %v, want sql.ErrNoRows", err)
+	}
+
+	mdb.QueueRefine(model.RefineJob{TranscriptionID: 2, Status: model.RefinePending, QueuedAt: now})
+	mdb.QueueRefine(model.RefineJob{TranscriptionID: 1, Status: model.RefinePending, QueuedAt: now.Add(time.Minute)})
+	if err := mdb.SetRefineStatus(2, model.RefineDone, "", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := mdb.SetRefineStatus(3, model.RefineDone, "", now); err == nil {
+		t.Error("SetRefineStatus() of an unqueued transcription succeeded, want an error")
+	}
+	if jobs, _ := mdb.GetRefineJobs(""); len(jobs) != 2 || jobs[0].TranscriptionID != 2 || jobs[0].Status != model.RefineDone {
+		t.Errorf("GetRefineJobs() = %+v, want both jobs oldest first", jobs)
+	}
+	if jobs, _ := mdb.GetRefineJobs(model.RefinePending); len(jobs) != 1 || jobs[0].TranscriptionID != 1 {
+		t.Errorf("GetRefineJobs(pending) = %+v, want job 1", jobs)
+	}
+
+	mdb.SetMetadata(1, map[string]string{"language": "en", "collection": "inbox"})
+	mdb.SetMetadata(1, map[string]string{"language": "zh", "collection": ""})
+	values, _ := mdb.GetMetadata(1)
+	values["changed"] = "outside"
+	if got, _ := mdb.GetMetadata(1); len(got) != 1 || got["language"] != "zh" {
+		t.Errorf("GetMetadata() = %v, want only language=zh", got)
+	}
+}
+
+func TestMemoryDB_Concurrent(t *testing.T) {
+	mdb := NewMemoryDB()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				name := fmt.Sprintf("%d-%d.mp4", w, i)
+				mdb.RecordToDB("alice", "/in", name, name, 1, "text", time.Now(), 0, "", model.ProviderMetadata{})
+				mdb.GetAllByUser("alice")
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if stats, _ := mdb.GetUserStats(); len(stats) != 1 || stats[0].VideoCount != 400 {
+		t.Errorf("GetUserStats() = %+v, want 400 transcriptions", stats)
+	}
+	if t400, err := mdb.GetByID(400); err != nil || t400.ID != 400 {
+		t.Errorf("GetByID(400) = %+v, %v", t400, err)
+	}
+}
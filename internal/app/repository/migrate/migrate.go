@@ -1,13 +1,13 @@
 package migrate
 
 import (
-	"fmt"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"io/ioutil"
 	"log"
 	"strconv"
 	"strings"
+	"tiktok-whisper/internal/app/logging"
 	"tiktok-whisper/internal/app/repository/pg"
 	"tiktok-whisper/internal/app/repository/sqlite"
 )
@@ -65,19 +65,19 @@ func MigrateToPostgres() {
 
 		err = rows.Scan(&id, &inputDir, &fileName, &mp3FileName, &audioDuration, &transcription, &lastConversionTime, &hasError, &errorMessage, &user)
 		if err != nil {
-			log.Printf("Failed to read row with ID %d: %v", id, err)
+			logging.L().Error("Failed to read row", "id", id, "error", err)
 			continue
 		}
 
 		// Data validation
 		if strings.TrimSpace(inputDir) == "" || strings.TrimSpace(fileName) == "" {
-			log.Printf("Validation failed for row with ID %d: input_dir or file_name is empty", id)
+			logging.L().Warn("Validation failed for row, input_dir or file_name is empty", "id", id)
 			continue
 		}
 
 		_, err = stmt.Exec(id, inputDir, fileName, mp3FileName, audioDuration, transcription, lastConversionTime, hasError, errorMessage, user)
 		if err != nil {
-			log.Printf("Failed to insert row with ID %d: %v", id, err)
+			logging.L().Error("Failed to insert row", "id", id, "error", err)
 			continue
 		}
 		lastID = id
@@ -93,5 +93,5 @@ func MigrateToPostgres() {
 		log.Fatalf("Failed to save lastID: %v", err)
 	}
 
-	fmt.Println("Data migration completed.")
+	logging.L().Info("Data migration completed", "last_id", lastID)
 }
@@ -0,0 +1,309 @@
+// Package migrations versions the schema of the SQLite and PostgreSQL databases. The migrations are
+// SQL files embedded from the directory of each dialect, named <version>_<name>.up.sql and
+// <version>_<name>.down.sql like golang-migrate expects them. The applied versions are recorded in
+// the schema_migrations table, each migration runs in a transaction together with its record.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect selects the migrations of a database.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+const createVersionTableSQL = `
+	CREATE TABLE IF NOT EXISTS schema_migrations
+	(
+		version    INTEGER PRIMARY KEY,
+		name       VARCHAR   NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	);`
+
+// lockID keys the advisory lock that keeps concurrent PostgreSQL clients from migrating at once,
+// SQLite transactions take the write lock right away instead.
+const lockID = 4773
+
+// ErrNewerSchema is returned when the database was migrated by a newer v2t than this one.
+var ErrNewerSchema = errors.New("database schema is newer than this v2t")
+
+// Migration is a version of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// String returns the file name prefix of the migration, like 0002_transcriptions_user_index.
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Applied is a migration recorded in the database.
+type Applied struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// Step is a migration the Migrator applied or reverted.
+type Step struct {
+	Migration Migration
+	Down      bool
+}
+
+func (s Step) String() string {
+	if s.Down {
+		return "reverted " + s.Migration.String()
+	}
+	return "applied " + s.Migration.String()
+}
+
+// Load returns the migrations of dialect ordered by version, it fails when a version is missing
+// its up file or skipped.
+func Load(dialect Dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, string(dialect))
+	if err != nil {
+		return nil, fmt.Errorf("unknown dialect %q", dialect)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		base, direction, ok := cutDirection(e.Name())
+		if !ok {
+			return nil, fmt.Errorf("migration %s isn't named <version>_<name>.up.sql or .down.sql", e.Name())
+		}
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s has no valid version", e.Name())
+		}
+		content, err := files.ReadFile(path.Join(string(dialect), e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has no up file", m)
+		}
+	}
+	return migrations, nil
+}
+
+func cutDirection(fileName string) (base string, direction string, ok bool) {
+	if base, ok = strings.CutSuffix(fileName, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok = strings.CutSuffix(fileName, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
+
+// Migrator applies and reverts the migrations of a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+	now        func() time.Time
+}
+
+// New creates a Migrator of db with the embedded migrations of dialect.
+func New(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations, now: time.Now}, nil
+}
+
+// DB returns the database the migrator works on.
+func (m *Migrator) DB() *sql.DB {
+	return m.db
+}
+
+// Dialect returns the dialect of the database.
+func (m *Migrator) Dialect() Dialect {
+	return m.dialect
+}
+
+// Latest returns the version the migrations lead to.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Migrations returns the known migrations ordered by version.
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// Applied returns the migrations recorded in the database ordered by version.
+func (m *Migrator) Applied() ([]Applied, error) {
+	if _, err := m.db.Exec(createVersionTableSQL); err != nil {
+		return nil, fmt.Errorf("create schema_migrations failed: %v", err)
+	}
+	rows, err := m.db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version;`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations failed: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make([]Applied, 0)
+	for rows.Next() {
+		var a Applied
+		if err = rows.Scan(&a.Version, &a.Name, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	return applied, rows.Err()
+}
+
+// Version returns the version of the schema, zero when no migration was applied yet.
+func (m *Migrator) Version() (int, error) {
+	applied, err := m.Applied()
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// Up applies the pending migrations, it fails with ErrNewerSchema when the database is ahead.
+func (m *Migrator) Up() ([]Step, error) {
+	return m.Migrate(m.Latest())
+}
+
+// Migrate applies or reverts migrations until the schema is at version, reverting a migration
+// without a down file fails before anything is changed.
+func (m *Migrator) Migrate(version int) ([]Step, error) {
+	if version < 0 || version > m.Latest() {
+		return nil, fmt.Errorf("unknown schema version %d, the latest is %d", version, m.Latest())
+	}
+	current, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if current > m.Latest() {
+		return nil, fmt.Errorf("%w: it is at version %d, this v2t knows up to %d", ErrNewerSchema, current, m.Latest())
+	}
+
+	var steps []Step
+	for v := current + 1; v <= version; v++ {
+		steps = append(steps, Step{Migration: m.migrations[v-1]})
+	}
+	for v := current; v > version; v-- {
+		if m.migrations[v-1].Down == "" {
+			return nil, fmt.Errorf("migration %s can't be reverted, it has no down file", m.migrations[v-1])
+		}
+		steps = append(steps, Step{Migration: m.migrations[v-1], Down: true})
+	}
+
+	done := make([]Step, 0, len(steps))
+	for _, step := range steps {
+		ran, err := m.run(step)
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, step)
+		}
+	}
+	return done, nil
+}
+
+// run applies or reverts one migration with its record, it reports false when a concurrent client
+// did it already.
+func (m *Migrator) run(step Step) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if m.dialect == Postgres {
+		if _, err = tx.Exec(`SELECT pg_advisory_xact_lock($1);`, lockID); err != nil {
+			return false, fmt.Errorf("lock schema_migrations failed: %v", err)
+		}
+	}
+	var recorded int
+	err = tx.QueryRow(m.bind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?;`), step.Migration.Version).Scan(&recorded)
+	if err != nil {
+		return false, fmt.Errorf("query schema_migrations failed: %v", err)
+	}
+	if (recorded > 0) != step.Down {
+		return false, nil
+	}
+
+	if step.Down {
+		if _, err = tx.Exec(step.Migration.Down); err != nil {
+			return false, fmt.Errorf("revert migration %s failed: %v", step.Migration, err)
+		}
+		_, err = tx.Exec(m.bind(`DELETE FROM schema_migrations WHERE version = ?;`), step.Migration.Version)
+	} else {
+		if _, err = tx.Exec(step.Migration.Up); err != nil {
+			return false, fmt.Errorf("apply migration %s failed: %v", step.Migration, err)
+		}
+		_, err = tx.Exec(m.bind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?);`),
+			step.Migration.Version, step.Migration.Name, m.now().UTC())
+	}
+	if err != nil {
+		return false, fmt.Errorf("record migration %s failed: %v", step.Migration, err)
+	}
+	return true, tx.Commit()
+}
+
+// bind numbers the placeholders of query for PostgreSQL.
+func (m *Migrator) bind(query string) string {
+	if m.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
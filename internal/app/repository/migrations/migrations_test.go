@@ -0,0 +1,156 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestMigrator(t *testing.T) (*Migrator, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "transcription.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, db
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		wantErr bool
+	}{
+		{dialect: SQLite},
+		{dialect: Postgres},
+		{dialect: "mysql", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.dialect), func(t *testing.T) {
+			got, err := Load(tt.dialect)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for i, m := range got {
+				if m.Version != i+1 || m.Name == "" || m.Up == "" || m.Down == "" {
+					t.Errorf("Load() migration %d = %s, want a name and both directions", i+1, m)
+				}
+			}
+		})
+	}
+
+	sqliteMigrations, _ := Load(SQLite)
+	postgresMigrations, _ := Load(Postgres)
+	if len(sqliteMigrations) != len(postgresMigrations) {
+		t.Fatalf("SQLite has %d migrations, PostgreSQL %d", len(sqliteMigrations), len(postgresMigrations))
+	}
+	for i := range sqliteMigrations {
+		if sqliteMigrations[i].String() != postgresMigrations[i].String() {
+			t.Errorf("migration %d is %s for SQLite and %s for PostgreSQL", i+1, sqliteMigrations[i], postgresMigrations[i])
+		}
+	}
+}
+
+func TestMigrator_Migrate(t *testing.T) {
+	m, db := newTestMigrator(t)
+
+	tests := []struct {
+		name        string
+		version     int
+		wantSteps   []string
+		wantErr     bool
+		wantIndex   bool
+		wantRecords bool
+	}{
+		{name: "up", version: m.Latest(), wantSteps: []string{"applied 0001_initial", "applied 0002_transcriptions_user_index", "applied 0003_moderations", "applied 0004_review", "applied 0005_speech_analytics", "applied 0006_insights", "applied 0007_embeddings", "applied 0008_embedding_index", "applied 0009_duplicates"}, wantIndex: true, wantRecords: true},
+		{name: "again", version: m.Latest(), wantIndex: true, wantRecords: true},
+		{name: "down eight", version: 1, wantSteps: []string{"reverted 0009_duplicates", "reverted 0008_embedding_index", "reverted 0007_embeddings", "reverted 0006_insights", "reverted 0005_speech_analytics", "reverted 0004_review", "reverted 0003_moderations", "reverted 0002_transcriptions_user_index"}, wantRecords: true},
+		{name: "unknown version", version: m.Latest() + 1, wantErr: true, wantRecords: true},
+		{name: "down to nothing", version: 0, wantSteps: []string{"reverted 0001_initial"}},
+		{name: "up from nothing", version: 2, wantSteps: []string{"applied 0001_initial", "applied 0002_transcriptions_user_index"}, wantIndex: true, wantRecords: true},
+		{name: "up to latest", version: m.Latest(), wantSteps: []string{"applied 0003_moderations", "applied 0004_review", "applied 0005_speech_analytics", "applied 0006_insights", "applied 0007_embeddings", "applied 0008_embedding_index", "applied 0009_duplicates"}, wantIndex: true, wantRecords: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := m.Migrate(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Migrate(%d) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+			}
+			got := make([]string, 0, len(steps))
+			for _, s := range steps {
+				got = append(got, s.String())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantSteps, ",") {
+				t.Errorf("Migrate(%d) steps = %v, want %v", tt.version, got, tt.wantSteps)
+			}
+
+			var indexes, tables int
+			db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_transcriptions_user_time';`).Scan(&indexes)
+			db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'transcriptions';`).Scan(&tables)
+			if (indexes == 1) != tt.wantIndex || (tables == 1) != tt.wantRecords {
+				t.Errorf("after Migrate(%d) index = %d, transcriptions table = %d", tt.version, indexes, tables)
+			}
+		})
+	}
+
+	if version, err := m.Version(); err != nil || version != m.Latest() {
+		t.Errorf("Version() = %d, %v, want %d", version, err, m.Latest())
+	}
+	applied, err := m.Applied()
+	if err != nil || len(applied) != m.Latest() || applied[0].Name != "initial" || applied[0].AppliedAt.IsZero() {
+		t.Errorf("Applied() = %+v, %v", applied, err)
+	}
+}
+
+func TestMigrator_KeepsData(t *testing.T) {
+	m, db := newTestMigrator(t)
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.Exec(`INSERT INTO transcriptions (user, input_dir, file_name, mp3_file_name, audio_duration, transcription, last_conversion_time, has_error)
+		VALUES ('alice', '/in', 'a.mp4', 'a.mp3', 30, 'hello', '2024-01-01 00:00:00', 0);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Migrate(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	if err = db.QueryRow(`SELECT transcription FROM transcriptions WHERE user = 'alice';`).Scan(&text); err != nil || text != "hello" {
+		t.Errorf("transcription after down and up = %q, %v, want hello", text, err)
+	}
+}
+
+func TestMigrator_NewerSchema(t *testing.T) {
+	m, db := newTestMigrator(t)
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (99, 'future', '2030-01-01 00:00:00');`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("Up() of a newer schema error = %v, want ErrNewerSchema", err)
+	}
+}
+
+func TestMigrator_Bind(t *testing.T) {
+	m := &Migrator{dialect: Postgres}
+	if got := m.bind(`SELECT a FROM t WHERE b = ? AND c = ?;`); got != `SELECT a FROM t WHERE b = $1 AND c = $2;` {
+		t.Errorf("bind() = %s", got)
+	}
+}
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS transcription_metadata;
+DROP TABLE IF EXISTS refine_jobs;
+DROP TABLE IF EXISTS transcription_translations;
+DROP TABLE IF EXISTS transcription_costs;
+DROP TABLE IF EXISTS batch_job_files;
+DROP TABLE IF EXISTS batch_jobs;
+DROP TABLE IF EXISTS transcription_words;
+DROP TABLE IF EXISTS transcription_segments;
+DROP TABLE IF EXISTS transcription_revisions;
+DROP TABLE IF EXISTS artifacts;
+DROP TABLE IF EXISTS transcriptions;
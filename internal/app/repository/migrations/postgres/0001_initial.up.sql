@@ -0,0 +1,134 @@
+-- The schema of the databases created before migrations were versioned. Databases from that time are
+-- adopted by this migration, the statements only create what they lack.
+CREATE TABLE IF NOT EXISTS transcriptions
+(
+    id                   SERIAL PRIMARY KEY,
+    input_dir            VARCHAR   NOT NULL,
+    file_name            VARCHAR   NOT NULL,
+    mp3_file_name        VARCHAR   NOT NULL,
+    audio_duration       INTEGER   NOT NULL,
+    transcription        VARCHAR   NOT NULL,
+    last_conversion_time TIMESTAMP NOT NULL,
+    has_error            INTEGER   NOT NULL,
+    error_message        VARCHAR,
+    user_nickname        VARCHAR
+);
+
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS provider_metadata TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS artifacts
+(
+    id               SERIAL PRIMARY KEY,
+    transcription_id INTEGER   NOT NULL,
+    format           VARCHAR   NOT NULL,
+    path             VARCHAR   NOT NULL,
+    writer_version   INTEGER   NOT NULL,
+    content_hash     VARCHAR   NOT NULL,
+    exported_at      TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_artifacts_transcription ON artifacts (transcription_id, format);
+
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS current_revision INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS source_url VARCHAR NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS transcription_revisions
+(
+    id                SERIAL PRIMARY KEY,
+    transcription_id  INTEGER   NOT NULL,
+    revision          INTEGER   NOT NULL,
+    transcription     VARCHAR   NOT NULL,
+    provider_metadata TEXT      NOT NULL DEFAULT '',
+    created_at        TIMESTAMP NOT NULL,
+    UNIQUE (transcription_id, revision)
+);
+
+CREATE TABLE IF NOT EXISTS transcription_segments
+(
+    id               SERIAL PRIMARY KEY,
+    transcription_id INTEGER          NOT NULL,
+    position         INTEGER          NOT NULL,
+    start_seconds    DOUBLE PRECISION NOT NULL,
+    end_seconds      DOUBLE PRECISION NOT NULL,
+    speaker          VARCHAR          NOT NULL DEFAULT '',
+    text             VARCHAR          NOT NULL,
+    UNIQUE (transcription_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS transcription_words
+(
+    id            SERIAL PRIMARY KEY,
+    segment_id    INTEGER          NOT NULL REFERENCES transcription_segments (id) ON DELETE CASCADE,
+    position      INTEGER          NOT NULL,
+    start_seconds DOUBLE PRECISION NOT NULL,
+    end_seconds   DOUBLE PRECISION NOT NULL,
+    text          VARCHAR          NOT NULL,
+    probability   DOUBLE PRECISION NOT NULL DEFAULT 0,
+    UNIQUE (segment_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS batch_jobs
+(
+    id               VARCHAR PRIMARY KEY,
+    kind             VARCHAR   NOT NULL,
+    user_nickname    VARCHAR   NOT NULL DEFAULT '',
+    output_directory VARCHAR   NOT NULL DEFAULT '',
+    created_at       TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS batch_job_files
+(
+    job_id        VARCHAR   NOT NULL REFERENCES batch_jobs (id) ON DELETE CASCADE,
+    position      INTEGER   NOT NULL,
+    path          VARCHAR   NOT NULL,
+    status        VARCHAR   NOT NULL,
+    error_message VARCHAR   NOT NULL DEFAULT '',
+    updated_at    TIMESTAMP NOT NULL,
+    PRIMARY KEY (job_id, position),
+    UNIQUE (job_id, path)
+);
+
+CREATE TABLE IF NOT EXISTS transcription_costs
+(
+    id               SERIAL PRIMARY KEY,
+    transcription_id INTEGER          NOT NULL DEFAULT 0,
+    user_nickname    VARCHAR          NOT NULL DEFAULT '',
+    file_path        VARCHAR          NOT NULL,
+    provider         VARCHAR          NOT NULL DEFAULT '',
+    model            VARCHAR          NOT NULL DEFAULT '',
+    audio_seconds    DOUBLE PRECISION NOT NULL DEFAULT 0,
+    cost             DOUBLE PRECISION NOT NULL,
+    recorded_at      TIMESTAMP        NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_transcription_costs_recorded_at ON transcription_costs (recorded_at);
+
+CREATE TABLE IF NOT EXISTS transcription_translations
+(
+    id               SERIAL PRIMARY KEY,
+    transcription_id INTEGER   NOT NULL,
+    language         VARCHAR   NOT NULL,
+    backend          VARCHAR   NOT NULL,
+    text             VARCHAR   NOT NULL,
+    created_at       TIMESTAMP NOT NULL,
+    UNIQUE (transcription_id, language)
+);
+
+CREATE TABLE IF NOT EXISTS refine_jobs
+(
+    transcription_id INTEGER PRIMARY KEY,
+    audio_path       VARCHAR   NOT NULL,
+    status           VARCHAR   NOT NULL,
+    error_message    VARCHAR   NOT NULL DEFAULT '',
+    queued_at        TIMESTAMP NOT NULL,
+    updated_at       TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS transcription_metadata
+(
+    transcription_id INTEGER NOT NULL,
+    key              VARCHAR NOT NULL,
+    value            VARCHAR NOT NULL,
+    PRIMARY KEY (transcription_id, key)
+);
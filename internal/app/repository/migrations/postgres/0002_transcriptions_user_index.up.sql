@@ -0,0 +1,4 @@
+-- Listing the history of a user reads its transcriptions by conversion time, checking whether a file
+-- was processed looks it up by name.
+CREATE INDEX idx_transcriptions_user_time ON transcriptions (user_nickname, last_conversion_time);
+CREATE INDEX idx_transcriptions_file_name ON transcriptions (file_name);
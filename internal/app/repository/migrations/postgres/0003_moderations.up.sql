@@ -0,0 +1,9 @@
+-- The latest moderation result of each transcription, scores and flagged categories are JSON.
+CREATE TABLE transcription_moderations
+(
+    transcription_id INTEGER PRIMARY KEY,
+    backend          VARCHAR   NOT NULL,
+    scores           VARCHAR   NOT NULL,
+    flagged          VARCHAR   NOT NULL,
+    checked_at       TIMESTAMP NOT NULL
+);
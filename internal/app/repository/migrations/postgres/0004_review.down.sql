@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS review_cards;
+DROP TABLE IF EXISTS segment_translations;
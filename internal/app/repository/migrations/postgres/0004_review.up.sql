@@ -0,0 +1,29 @@
+-- The language-learning review: translations of single segments, and the segments marked for spaced
+-- repetition. Both are keyed by the position of the segment, segment ids change when they are saved again.
+CREATE TABLE segment_translations
+(
+    transcription_id INTEGER   NOT NULL,
+    position         INTEGER   NOT NULL,
+    language         VARCHAR   NOT NULL,
+    backend          VARCHAR   NOT NULL,
+    source           VARCHAR   NOT NULL,
+    text             VARCHAR   NOT NULL,
+    created_at       TIMESTAMP NOT NULL,
+    PRIMARY KEY (transcription_id, position, language)
+);
+
+CREATE TABLE review_cards
+(
+    transcription_id INTEGER          NOT NULL,
+    position         INTEGER          NOT NULL,
+    language         VARCHAR          NOT NULL,
+    text             VARCHAR          NOT NULL,
+    translation      VARCHAR          NOT NULL,
+    interval_days    INTEGER          NOT NULL,
+    ease             DOUBLE PRECISION NOT NULL,
+    repetitions      INTEGER          NOT NULL,
+    due_at           TIMESTAMP        NOT NULL,
+    marked_at        TIMESTAMP        NOT NULL,
+    PRIMARY KEY (transcription_id, position)
+);
+CREATE INDEX idx_review_cards_due_at ON review_cards (due_at);
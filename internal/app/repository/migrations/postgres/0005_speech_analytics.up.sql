@@ -0,0 +1,14 @@
+-- Speech analytics of each transcription, computed from its segments. version is the version of the
+-- analysis, results of an older analysis are computed again.
+CREATE TABLE speech_analytics
+(
+    transcription_id  INTEGER PRIMARY KEY,
+    version           INTEGER          NOT NULL,
+    speech_seconds    DOUBLE PRECISION NOT NULL,
+    words             INTEGER          NOT NULL,
+    filler_words      INTEGER          NOT NULL,
+    longest_monologue DOUBLE PRECISION NOT NULL,
+    speakers          INTEGER          NOT NULL,
+    interruptions     INTEGER          NOT NULL,
+    computed_at       TIMESTAMP        NOT NULL
+);
@@ -0,0 +1,11 @@
+-- The embeddings of the transcriptions for the vector index, one per transcription and model. The
+-- vector is stored as little-endian float32s.
+CREATE TABLE transcription_embeddings
+(
+    transcription_id INTEGER   NOT NULL,
+    model            TEXT      NOT NULL,
+    dimensions       INTEGER   NOT NULL,
+    vector           BYTEA     NOT NULL,
+    embedded_at      TIMESTAMP NOT NULL,
+    PRIMARY KEY (transcription_id, model)
+);
@@ -0,0 +1,127 @@
+-- The schema of the databases created before migrations were versioned. Databases from that time are
+-- adopted by this migration, the statements only create what they lack.
+CREATE TABLE IF NOT EXISTS transcriptions
+(
+    id                   INTEGER PRIMARY KEY AUTOINCREMENT,
+    user                 TEXT     NOT NULL,
+    input_dir            TEXT     NOT NULL,
+    file_name            TEXT     NOT NULL,
+    mp3_file_name        TEXT     NOT NULL,
+    audio_duration       INTEGER  NOT NULL,
+    transcription        TEXT     NOT NULL,
+    last_conversion_time DATETIME NOT NULL,
+    has_error            INTEGER  NOT NULL,
+    error_message        TEXT,
+    provider_metadata    TEXT     NOT NULL DEFAULT '',
+    current_revision     INTEGER  NOT NULL DEFAULT 0,
+    source_url           TEXT     NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS artifacts
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    transcription_id INTEGER  NOT NULL,
+    format           TEXT     NOT NULL,
+    path             TEXT     NOT NULL,
+    writer_version   INTEGER  NOT NULL,
+    content_hash     TEXT     NOT NULL,
+    exported_at      DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_artifacts_transcription ON artifacts (transcription_id, format);
+
+CREATE TABLE IF NOT EXISTS transcription_revisions
+(
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    transcription_id  INTEGER  NOT NULL,
+    revision          INTEGER  NOT NULL,
+    transcription     TEXT     NOT NULL,
+    provider_metadata TEXT     NOT NULL DEFAULT '',
+    created_at        DATETIME NOT NULL,
+    UNIQUE (transcription_id, revision)
+);
+
+CREATE TABLE IF NOT EXISTS transcription_segments
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    transcription_id INTEGER NOT NULL,
+    position         INTEGER NOT NULL,
+    start_seconds    REAL    NOT NULL,
+    end_seconds      REAL    NOT NULL,
+    speaker          TEXT    NOT NULL DEFAULT '',
+    text             TEXT    NOT NULL,
+    UNIQUE (transcription_id, position)
+);
+CREATE TABLE IF NOT EXISTS transcription_words
+(
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    segment_id    INTEGER NOT NULL,
+    position      INTEGER NOT NULL,
+    start_seconds REAL    NOT NULL,
+    end_seconds   REAL    NOT NULL,
+    text          TEXT    NOT NULL,
+    probability   REAL    NOT NULL DEFAULT 0,
+    UNIQUE (segment_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS batch_jobs
+(
+    id               TEXT PRIMARY KEY,
+    kind             TEXT     NOT NULL,
+    user             TEXT     NOT NULL DEFAULT '',
+    output_directory TEXT     NOT NULL DEFAULT '',
+    created_at       DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS batch_job_files
+(
+    job_id        TEXT     NOT NULL,
+    position      INTEGER  NOT NULL,
+    path          TEXT     NOT NULL,
+    status        TEXT     NOT NULL,
+    error_message TEXT     NOT NULL DEFAULT '',
+    updated_at    DATETIME NOT NULL,
+    PRIMARY KEY (job_id, position),
+    UNIQUE (job_id, path)
+);
+
+CREATE TABLE IF NOT EXISTS transcription_costs
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    transcription_id INTEGER  NOT NULL DEFAULT 0,
+    user             TEXT     NOT NULL DEFAULT '',
+    file_path        TEXT     NOT NULL,
+    provider         TEXT     NOT NULL DEFAULT '',
+    model            TEXT     NOT NULL DEFAULT '',
+    audio_seconds    REAL     NOT NULL DEFAULT 0,
+    cost             REAL     NOT NULL,
+    recorded_at      DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_transcription_costs_recorded_at ON transcription_costs (recorded_at);
+
+CREATE TABLE IF NOT EXISTS transcription_translations
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    transcription_id INTEGER  NOT NULL,
+    language         TEXT     NOT NULL,
+    backend          TEXT     NOT NULL,
+    text             TEXT     NOT NULL,
+    created_at       DATETIME NOT NULL,
+    UNIQUE (transcription_id, language)
+);
+
+CREATE TABLE IF NOT EXISTS refine_jobs
+(
+    transcription_id INTEGER PRIMARY KEY,
+    audio_path       TEXT     NOT NULL,
+    status           TEXT     NOT NULL,
+    error_message    TEXT     NOT NULL DEFAULT '',
+    queued_at        DATETIME NOT NULL,
+    updated_at       DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS transcription_metadata
+(
+    transcription_id INTEGER NOT NULL,
+    key              TEXT    NOT NULL,
+    value            TEXT    NOT NULL,
+    PRIMARY KEY (transcription_id, key)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transcriptions_file_name;
+DROP INDEX IF EXISTS idx_transcriptions_user_time;
@@ -0,0 +1,9 @@
+-- The latest moderation result of each transcription, scores and flagged categories are JSON.
+CREATE TABLE transcription_moderations
+(
+    transcription_id INTEGER PRIMARY KEY,
+    backend          TEXT     NOT NULL,
+    scores           TEXT     NOT NULL,
+    flagged          TEXT     NOT NULL,
+    checked_at       DATETIME NOT NULL
+);
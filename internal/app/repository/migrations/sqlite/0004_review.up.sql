@@ -0,0 +1,29 @@
+-- The language-learning review: translations of single segments, and the segments marked for spaced
+-- repetition. Both are keyed by the position of the segment, segment ids change when they are saved again.
+CREATE TABLE segment_translations
+(
+    transcription_id INTEGER  NOT NULL,
+    position         INTEGER  NOT NULL,
+    language         TEXT     NOT NULL,
+    backend          TEXT     NOT NULL,
+    source           TEXT     NOT NULL,
+    text             TEXT     NOT NULL,
+    created_at       DATETIME NOT NULL,
+    PRIMARY KEY (transcription_id, position, language)
+);
+
+CREATE TABLE review_cards
+(
+    transcription_id INTEGER  NOT NULL,
+    position         INTEGER  NOT NULL,
+    language         TEXT     NOT NULL,
+    text             TEXT     NOT NULL,
+    translation      TEXT     NOT NULL,
+    interval_days    INTEGER  NOT NULL,
+    ease             REAL     NOT NULL,
+    repetitions      INTEGER  NOT NULL,
+    due_at           DATETIME NOT NULL,
+    marked_at        DATETIME NOT NULL,
+    PRIMARY KEY (transcription_id, position)
+);
+CREATE INDEX idx_review_cards_due_at ON review_cards (due_at);
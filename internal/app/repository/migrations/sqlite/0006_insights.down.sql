@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS transcription_sentiments;
+DROP TABLE IF EXISTS transcription_entities;
+DROP TABLE IF EXISTS transcription_chapters;
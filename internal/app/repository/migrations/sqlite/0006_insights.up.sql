@@ -0,0 +1,38 @@
+-- The insights providers report beyond the text, e.g. the chapters, entities and sentiments of
+-- AssemblyAI, one row per item in the order the provider reported them.
+CREATE TABLE transcription_chapters
+(
+    transcription_id INTEGER NOT NULL,
+    position         INTEGER NOT NULL,
+    start_seconds    REAL    NOT NULL,
+    end_seconds      REAL    NOT NULL,
+    headline         TEXT    NOT NULL,
+    gist             TEXT    NOT NULL,
+    summary          TEXT    NOT NULL,
+    PRIMARY KEY (transcription_id, position)
+);
+
+CREATE TABLE transcription_entities
+(
+    transcription_id INTEGER NOT NULL,
+    position         INTEGER NOT NULL,
+    entity_type      TEXT    NOT NULL,
+    text             TEXT    NOT NULL,
+    start_seconds    REAL    NOT NULL,
+    end_seconds      REAL    NOT NULL,
+    PRIMARY KEY (transcription_id, position)
+);
+CREATE INDEX idx_transcription_entities_type ON transcription_entities (entity_type, text);
+
+CREATE TABLE transcription_sentiments
+(
+    transcription_id INTEGER NOT NULL,
+    position         INTEGER NOT NULL,
+    start_seconds    REAL    NOT NULL,
+    end_seconds      REAL    NOT NULL,
+    speaker          TEXT    NOT NULL DEFAULT '',
+    text             TEXT    NOT NULL,
+    sentiment        TEXT    NOT NULL,
+    confidence       REAL    NOT NULL,
+    PRIMARY KEY (transcription_id, position)
+);
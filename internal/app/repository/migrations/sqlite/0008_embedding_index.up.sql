@@ -0,0 +1,8 @@
+-- The embedding model of the vector index, a single row. Embeddings of other models are being
+-- built for a reindex and only searched once the index switches to their model.
+CREATE TABLE embedding_index
+(
+    id           INTEGER PRIMARY KEY CHECK (id = 1),
+    model        TEXT     NOT NULL,
+    activated_at DATETIME NOT NULL
+);
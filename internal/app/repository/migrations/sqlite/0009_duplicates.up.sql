@@ -0,0 +1,12 @@
+-- Transcriptions repeating an earlier one of the same user, found by v2t analyze duplicates from the
+-- embeddings and the word shingles of the transcripts.
+CREATE TABLE transcription_duplicates
+(
+    transcription_id     INTEGER  NOT NULL,
+    duplicate_of         INTEGER  NOT NULL,
+    similarity           REAL     NOT NULL,
+    embedding_similarity REAL     NOT NULL,
+    text_similarity      REAL     NOT NULL,
+    detected_at          DATETIME NOT NULL,
+    PRIMARY KEY (transcription_id, duplicate_of)
+);
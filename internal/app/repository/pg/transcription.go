@@ -2,16 +2,24 @@ package pg
 
 import (
 	"database/sql"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"math"
+	"tiktok-whisper/internal/app/logging"
 	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/repository/migrations"
 	"time"
 
 	_ "github.com/lib/pq"
 )
 
 type PostgresDB struct {
-	db *sql.DB
+	db       *sql.DB
+	migrator *migrations.Migrator
 }
 
 func NewPostgresDB(connectionString string) (*PostgresDB, error) {
@@ -19,7 +27,25 @@ func NewPostgresDB(connectionString string) (*PostgresDB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &PostgresDB{db: db}, nil
+
+	migrator, err := migrations.New(db, migrations.Postgres)
+	if err == nil {
+		var steps []migrations.Step
+		steps, err = migrator.Up()
+		for _, step := range steps {
+			logging.L().Info("Schema migration "+step.String(), "migration", step.Migration.String())
+		}
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("prepare schema failed: %v", err)
+	}
+	return &PostgresDB{db: db, migrator: migrator}, nil
+}
+
+// Migrator returns the migrator of the database schema.
+func (pdb *PostgresDB) Migrator() *migrations.Migrator {
+	return pdb.migrator
 }
 
 func (pdb *PostgresDB) Close() error {
@@ -35,14 +61,1059 @@ func (pdb *PostgresDB) CheckIfFileProcessed(fileName string) (int, error) {
 }
 
 func (pdb *PostgresDB) RecordToDB(user, inputDir, fileName, mp3FileName string, audioDuration int, transcription string,
-	lastConversionTime time.Time, hasError int, errorMessage string) {
-	insertSQL := `INSERT INTO transcriptions (user, input_dir, file_name, mp3_file_name, audio_duration, transcription, last_conversion_time, has_error, error_message) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
-	_, err := pdb.db.Exec(insertSQL, user, inputDir, fileName, mp3FileName, audioDuration, transcription, lastConversionTime, hasError, errorMessage)
+	lastConversionTime time.Time, hasError int, errorMessage string, providerMetadata model.ProviderMetadata) {
+	metadata, err := providerMetadata.JSON()
+	if err != nil {
+		logging.L().Error("Failed to serialize provider metadata", "error", err)
+	}
+
+	insertSQL := `INSERT INTO transcriptions (user_nickname, input_dir, file_name, mp3_file_name, audio_duration, transcription, last_conversion_time, has_error, error_message, provider_metadata) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
+	_, err = pdb.db.Exec(insertSQL, user, inputDir, fileName, mp3FileName, audioDuration, transcription, lastConversionTime, hasError, errorMessage, metadata)
 	if err != nil {
 		log.Fatalf("Failed to insert data into database: %v\n", err)
 	}
 }
 
 func (pdb *PostgresDB) GetAllByUser(userNickname string) ([]model.Transcription, error) {
-	return nil, errors.New("not implemented")
+	sqlStr := `
+		SELECT ` + transcriptionColumns + `
+		FROM transcriptions
+		WHERE has_error = 0
+		  AND user_nickname = $1
+		ORDER BY last_conversion_time DESC;`
+	rows, err := pdb.db.Query(sqlStr, userNickname)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	transcriptions := make([]model.Transcription, 0)
+	for rows.Next() {
+		t, err := scanTranscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		transcriptions = append(transcriptions, *t)
+	}
+	return transcriptions, rows.Err()
+}
+
+func (pdb *PostgresDB) ListByUser(userNickname string, opts repository.ListOptions) ([]model.Transcription, error) {
+	orderBy, err := opts.OrderBy()
+	if err != nil {
+		return nil, err
+	}
+	// LIMIT NULL returns every row
+	var limit any
+	if opts.Limit > 0 {
+		limit = opts.Limit
+	}
+	sqlStr := `
+		SELECT ` + transcriptionColumns + `
+		FROM transcriptions
+		WHERE has_error = 0
+		  AND user_nickname = $1
+		` + orderBy + `
+		LIMIT $2 OFFSET $3;`
+	rows, err := pdb.db.Query(sqlStr, userNickname, limit, opts.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	transcriptions := make([]model.Transcription, 0)
+	for rows.Next() {
+		t, err := scanTranscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		transcriptions = append(transcriptions, *t)
+	}
+	return transcriptions, rows.Err()
+}
+
+func (pdb *PostgresDB) GetByID(id int) (*model.Transcription, error) {
+	sqlStr := `
+		SELECT ` + transcriptionColumns + `
+		FROM transcriptions
+		WHERE id = $1;`
+	return scanTranscription(pdb.db.QueryRow(sqlStr, id))
+}
+
+// transcriptionColumns are the columns read by scanTranscription, in order.
+const transcriptionColumns = `id, coalesce(user_nickname, ''), last_conversion_time, mp3_file_name, audio_duration, transcription,
+		       coalesce(error_message, ''), provider_metadata, source_url`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTranscription(row scanner) (*model.Transcription, error) {
+	var t model.Transcription
+	var metadata string
+	err := row.Scan(&t.ID, &t.User, &t.LastConversionTime, &t.Mp3FileName, &t.AudioDuration,
+		&t.Transcription, &t.ErrorMessage, &metadata, &t.SourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+
+	t.ProviderMetadata, err = model.ParseProviderMetadata(metadata)
+	if err != nil {
+		logging.L().Warn("Ignore invalid provider metadata", "id", t.ID, "error", err)
+	}
+	return &t, nil
+}
+
+func (pdb *PostgresDB) UpdateTranscription(t model.Transcription) error {
+	metadata, err := t.ProviderMetadata.JSON()
+	if err != nil {
+		return fmt.Errorf("serialize provider metadata failed: %v", err)
+	}
+
+	updateSQL := `
+		UPDATE transcriptions
+		SET user_nickname = $1, mp3_file_name = $2, audio_duration = $3, transcription = $4, last_conversion_time = $5,
+		    error_message = $6, provider_metadata = $7, source_url = $8
+		WHERE id = $9;`
+	result, err := pdb.db.Exec(updateSQL, t.User, t.Mp3FileName, t.AudioDuration, t.Transcription, t.LastConversionTime,
+		t.ErrorMessage, metadata, t.SourceURL, t.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("no transcription %d", t.ID)
+	}
+	return nil
+}
+
+// deleteSQL removes the rows of the other tables about a transcription, words go with their segments.
+var deleteSQL = []string{
+	`DELETE FROM transcription_segments WHERE transcription_id = $1;`,
+	`DELETE FROM transcription_revisions WHERE transcription_id = $1;`,
+	`DELETE FROM artifacts WHERE transcription_id = $1;`,
+	`DELETE FROM transcription_translations WHERE transcription_id = $1;`,
+	`DELETE FROM refine_jobs WHERE transcription_id = $1;`,
+	`DELETE FROM transcription_metadata WHERE transcription_id = $1;`,
+	`DELETE FROM transcription_moderations WHERE transcription_id = $1;`,
+	`DELETE FROM segment_translations WHERE transcription_id = $1;`,
+	`DELETE FROM review_cards WHERE transcription_id = $1;`,
+	`DELETE FROM speech_analytics WHERE transcription_id = $1;`,
+	`DELETE FROM transcription_chapters WHERE transcription_id = $1;`,
+	`DELETE FROM transcription_entities WHERE transcription_id = $1;`,
+	`DELETE FROM transcription_sentiments WHERE transcription_id = $1;`,
+	`DELETE FROM transcription_embeddings WHERE transcription_id = $1;`,
+	`DELETE FROM transcription_duplicates WHERE transcription_id = $1 OR duplicate_of = $1;`,
+}
+
+func (pdb *PostgresDB) DeleteTranscription(id int) error {
+	tx, err := pdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM transcriptions WHERE id = $1;`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("no transcription %d", id)
+	}
+	for _, query := range deleteSQL {
+		if _, err = tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (pdb *PostgresDB) GetUserStats() ([]model.UserStats, error) {
+	sqlStr := `
+		SELECT coalesce(user_nickname, ''), count(*), coalesce(sum(audio_duration), 0)
+		FROM transcriptions
+		WHERE has_error = 0
+		GROUP BY user_nickname
+		ORDER BY user_nickname;`
+	rows, err := pdb.db.Query(sqlStr)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	stats := make([]model.UserStats, 0)
+	for rows.Next() {
+		var s model.UserStats
+		err = rows.Scan(&s.User, &s.VideoCount, &s.TotalAudioDuration)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (pdb *PostgresDB) GetArtifact(transcriptionID int, format string) (*model.Artifact, error) {
+	sqlStr := `
+		SELECT id, transcription_id, format, path, writer_version, content_hash, exported_at
+		FROM artifacts
+		WHERE transcription_id = $1 AND format = $2
+		ORDER BY id DESC
+		LIMIT 1;`
+
+	var a model.Artifact
+	err := pdb.db.QueryRow(sqlStr, transcriptionID, format).Scan(&a.ID, &a.TranscriptionID, &a.Format, &a.Path,
+		&a.WriterVersion, &a.ContentHash, &a.ExportedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+	return &a, nil
+}
+
+func (pdb *PostgresDB) SaveArtifact(a model.Artifact) error {
+	insertSQL := `INSERT INTO artifacts (transcription_id, format, path, writer_version, content_hash, exported_at) VALUES ($1, $2, $3, $4, $5, $6);`
+	_, err := pdb.db.Exec(insertSQL, a.TranscriptionID, a.Format, a.Path, a.WriterVersion, a.ContentHash, a.ExportedAt)
+	return err
+}
+
+func (pdb *PostgresDB) AddRevision(transcriptionID int, transcription string, providerMetadata model.ProviderMetadata, createdAt time.Time) (int, error) {
+	metadata, err := providerMetadata.JSON()
+	if err != nil {
+		return 0, fmt.Errorf("serialize provider metadata failed: %v", err)
+	}
+
+	tx, err := pdb.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var current int
+	var previousText, previousMetadata string
+	var previousTime time.Time
+	err = tx.QueryRow(`SELECT current_revision, transcription, provider_metadata, last_conversion_time FROM transcriptions WHERE id = $1 FOR UPDATE;`,
+		transcriptionID).Scan(&current, &previousText, &previousMetadata, &previousTime)
+	if err != nil {
+		return 0, fmt.Errorf("db scan failed: %w", err)
+	}
+
+	insertSQL := `INSERT INTO transcription_revisions (transcription_id, revision, transcription, provider_metadata, created_at) VALUES ($1, $2, $3, $4, $5);`
+
+	// The first result was stored before revisions existed, keep it as revision 1
+	if current == 0 {
+		if _, err = tx.Exec(insertSQL, transcriptionID, 1, previousText, previousMetadata, previousTime); err != nil {
+			return 0, err
+		}
+	}
+
+	var revision int
+	err = tx.QueryRow(`SELECT coalesce(max(revision), 0) + 1 FROM transcription_revisions WHERE transcription_id = $1;`,
+		transcriptionID).Scan(&revision)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec(insertSQL, transcriptionID, revision, transcription, metadata, createdAt); err != nil {
+		return 0, err
+	}
+
+	updateSQL := `UPDATE transcriptions SET transcription = $1, provider_metadata = $2, last_conversion_time = $3, current_revision = $4 WHERE id = $5;`
+	if _, err = tx.Exec(updateSQL, transcription, metadata, createdAt, revision, transcriptionID); err != nil {
+		return 0, err
+	}
+	return revision, tx.Commit()
+}
+
+func (pdb *PostgresDB) GetRevisions(transcriptionID int) ([]model.Revision, error) {
+	sqlStr := `
+		SELECT r.transcription_id, r.revision, r.transcription, r.provider_metadata, r.created_at, r.revision = t.current_revision
+		FROM transcription_revisions r
+		         JOIN transcriptions t ON t.id = r.transcription_id
+		WHERE r.transcription_id = $1
+		ORDER BY r.revision;`
+	rows, err := pdb.db.Query(sqlStr, transcriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]model.Revision, 0)
+	for rows.Next() {
+		var r model.Revision
+		var metadata string
+		err = rows.Scan(&r.TranscriptionID, &r.Revision, &r.Transcription, &metadata, &r.CreatedAt, &r.Current)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+
+		r.ProviderMetadata, err = model.ParseProviderMetadata(metadata)
+		if err != nil {
+			logging.L().Warn("Ignore invalid provider metadata", "id", transcriptionID, "revision", r.Revision, "error", err)
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+func (pdb *PostgresDB) SetCurrentRevision(transcriptionID int, revision int) error {
+	updateSQL := `
+		UPDATE transcriptions t
+		SET transcription     = r.transcription,
+		    provider_metadata = r.provider_metadata,
+		    current_revision  = r.revision
+		FROM transcription_revisions r
+		WHERE r.transcription_id = t.id
+		  AND t.id = $1
+		  AND r.revision = $2;`
+	result, err := pdb.db.Exec(updateSQL, transcriptionID, revision)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("transcription %d has no revision %d", transcriptionID, revision)
+	}
+	return nil
+}
+
+func (pdb *PostgresDB) SetSourceURL(transcriptionID int, sourceURL string) error {
+	result, err := pdb.db.Exec(`UPDATE transcriptions SET source_url = $1 WHERE id = $2;`, sourceURL, transcriptionID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("no transcription %d", transcriptionID)
+	}
+	return nil
+}
+
+func (pdb *PostgresDB) SaveSegments(transcriptionID int, segments []model.Segment) error {
+	tx, err := pdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The words go with their segments through ON DELETE CASCADE
+	if _, err = tx.Exec(`DELETE FROM transcription_segments WHERE transcription_id = $1;`, transcriptionID); err != nil {
+		return err
+	}
+
+	segmentSQL := `INSERT INTO transcription_segments (transcription_id, position, start_seconds, end_seconds, speaker, text) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;`
+	wordSQL := `INSERT INTO transcription_words (segment_id, position, start_seconds, end_seconds, text, probability) VALUES ($1, $2, $3, $4, $5, $6);`
+	for i, s := range segments {
+		var segmentID int
+		if err = tx.QueryRow(segmentSQL, transcriptionID, i, s.Start, s.End, s.Speaker, s.Text).Scan(&segmentID); err != nil {
+			return err
+		}
+		for j, w := range s.Words {
+			if _, err = tx.Exec(wordSQL, segmentID, j, w.Start, w.End, w.Text, w.Probability); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (pdb *PostgresDB) GetSegments(transcriptionID int) ([]model.Segment, error) {
+	rows, err := pdb.db.Query(`
+		SELECT id, start_seconds, end_seconds, speaker, text
+		FROM transcription_segments
+		WHERE transcription_id = $1
+		ORDER BY position;`, transcriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	segments := make([]model.Segment, 0)
+	index := make(map[int]int)
+	for rows.Next() {
+		var id int
+		var s model.Segment
+		if err = rows.Scan(&id, &s.Start, &s.End, &s.Speaker, &s.Text); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		index[id] = len(segments)
+		segments = append(segments, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	words, err := pdb.db.Query(`
+		SELECT w.segment_id, w.start_seconds, w.end_seconds, w.text, w.probability
+		FROM transcription_words w
+		         JOIN transcription_segments s ON s.id = w.segment_id
+		WHERE s.transcription_id = $1
+		ORDER BY s.position, w.position;`, transcriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer words.Close()
+
+	for words.Next() {
+		var segmentID int
+		var w model.Word
+		if err = words.Scan(&segmentID, &w.Start, &w.End, &w.Text, &w.Probability); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		i := index[segmentID]
+		segments[i].Words = append(segments[i].Words, w)
+	}
+	return segments, words.Err()
+}
+
+func (pdb *PostgresDB) CreateBatchJob(job model.BatchJob) error {
+	tx, err := pdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO batch_jobs (id, kind, user_nickname, output_directory, created_at) VALUES ($1, $2, $3, $4, $5);`,
+		job.ID, job.Kind, job.User, job.OutputDirectory, job.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	fileSQL := `INSERT INTO batch_job_files (job_id, position, path, status, error_message, updated_at) VALUES ($1, $2, $3, $4, $5, $6);`
+	for i, f := range job.Files {
+		if _, err = tx.Exec(fileSQL, job.ID, i, f.Path, f.Status, f.Error, f.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (pdb *PostgresDB) GetBatchJob(id string) (*model.BatchJob, error) {
+	var job model.BatchJob
+	err := pdb.db.QueryRow(`SELECT id, kind, user_nickname, output_directory, created_at FROM batch_jobs WHERE id = $1;`, id).
+		Scan(&job.ID, &job.Kind, &job.User, &job.OutputDirectory, &job.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+
+	rows, err := pdb.db.Query(`
+		SELECT path, status, error_message, updated_at
+		FROM batch_job_files
+		WHERE job_id = $1
+		ORDER BY position;`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f model.BatchFile
+		if err = rows.Scan(&f.Path, &f.Status, &f.Error, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		job.Files = append(job.Files, f)
+	}
+	return &job, rows.Err()
+}
+
+func (pdb *PostgresDB) SetBatchFileStatus(jobID string, path string, status model.BatchFileStatus, errorMessage string, updatedAt time.Time) error {
+	updateSQL := `UPDATE batch_job_files SET status = $1, error_message = $2, updated_at = $3 WHERE job_id = $4 AND path = $5;`
+	result, err := pdb.db.Exec(updateSQL, status, errorMessage, updatedAt, jobID, path)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("batch job %s has no file %s", jobID, path)
+	}
+	return nil
+}
+
+func (pdb *PostgresDB) RecordCost(e model.CostEntry) error {
+	insertSQL := `INSERT INTO transcription_costs (transcription_id, user_nickname, file_path, provider, model, audio_seconds, cost, recorded_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+	_, err := pdb.db.Exec(insertSQL, e.TranscriptionID, e.User, e.FilePath, e.Provider, e.Model, e.AudioSeconds, e.Cost, e.RecordedAt)
+	return err
+}
+
+func (pdb *PostgresDB) GetCosts(since time.Time) ([]model.CostEntry, error) {
+	rows, err := pdb.db.Query(`
+		SELECT id, transcription_id, user_nickname, file_path, provider, model, audio_seconds, cost, recorded_at
+		FROM transcription_costs
+		WHERE recorded_at >= $1
+		ORDER BY recorded_at, id;`, since)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.CostEntry, 0)
+	for rows.Next() {
+		var e model.CostEntry
+		err = rows.Scan(&e.ID, &e.TranscriptionID, &e.User, &e.FilePath, &e.Provider, &e.Model, &e.AudioSeconds, &e.Cost, &e.RecordedAt)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (pdb *PostgresDB) SaveTranslation(t model.Translation) error {
+	upsertSQL := `
+		INSERT INTO transcription_translations (transcription_id, language, backend, text, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (transcription_id, language) DO UPDATE SET backend = excluded.backend, text = excluded.text, created_at = excluded.created_at;`
+	_, err := pdb.db.Exec(upsertSQL, t.TranscriptionID, t.Language, t.Backend, t.Text, t.CreatedAt)
+	return err
+}
+
+func (pdb *PostgresDB) GetTranslation(transcriptionID int, language string) (*model.Translation, error) {
+	var t model.Translation
+	err := pdb.db.QueryRow(`
+		SELECT id, transcription_id, language, backend, text, created_at
+		FROM transcription_translations
+		WHERE transcription_id = $1 AND language = $2;`, transcriptionID, language).
+		Scan(&t.ID, &t.TranscriptionID, &t.Language, &t.Backend, &t.Text, &t.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+	return &t, nil
+}
+
+func (pdb *PostgresDB) SaveModeration(m model.Moderation) error {
+	scores, flagged, err := moderationJSON(m)
+	if err != nil {
+		return err
+	}
+	upsertSQL := `
+		INSERT INTO transcription_moderations (transcription_id, backend, scores, flagged, checked_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (transcription_id) DO UPDATE SET backend = excluded.backend, scores = excluded.scores,
+			flagged = excluded.flagged, checked_at = excluded.checked_at;`
+	_, err = pdb.db.Exec(upsertSQL, m.TranscriptionID, m.Backend, scores, flagged, m.CheckedAt)
+	return err
+}
+
+func (pdb *PostgresDB) GetModeration(transcriptionID int) (*model.Moderation, error) {
+	m := model.Moderation{TranscriptionID: transcriptionID}
+	var scores, flagged string
+	err := pdb.db.QueryRow(`
+		SELECT backend, scores, flagged, checked_at
+		FROM transcription_moderations
+		WHERE transcription_id = $1;`, transcriptionID).
+		Scan(&m.Backend, &scores, &flagged, &m.CheckedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+	if err = json.Unmarshal([]byte(scores), &m.Scores); err != nil {
+		return nil, fmt.Errorf("parse moderation scores failed: %v", err)
+	}
+	if err = json.Unmarshal([]byte(flagged), &m.Flagged); err != nil {
+		return nil, fmt.Errorf("parse flagged categories failed: %v", err)
+	}
+	return &m, nil
+}
+
+// moderationJSON serializes the scores and flagged categories of m for their columns.
+func moderationJSON(m model.Moderation) (string, string, error) {
+	if m.Scores == nil {
+		m.Scores = map[string]float64{}
+	}
+	if m.Flagged == nil {
+		m.Flagged = []string{}
+	}
+	scores, err := json.Marshal(m.Scores)
+	if err != nil {
+		return "", "", fmt.Errorf("serialize moderation scores failed: %v", err)
+	}
+	flagged, err := json.Marshal(m.Flagged)
+	if err != nil {
+		return "", "", fmt.Errorf("serialize flagged categories failed: %v", err)
+	}
+	return string(scores), string(flagged), nil
+}
+
+func (pdb *PostgresDB) SaveSegmentTranslations(translations []model.SegmentTranslation) error {
+	tx, err := pdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsertSQL := `
+		INSERT INTO segment_translations (transcription_id, position, language, backend, source, text, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (transcription_id, position, language) DO UPDATE SET backend = excluded.backend,
+			source = excluded.source, text = excluded.text, created_at = excluded.created_at;`
+	for _, t := range translations {
+		_, err = tx.Exec(upsertSQL, t.TranscriptionID, t.Position, t.Language, t.Backend, t.Source, t.Text, t.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (pdb *PostgresDB) GetSegmentTranslations(transcriptionID int, language string) (map[int]model.SegmentTranslation, error) {
+	rows, err := pdb.db.Query(`
+		SELECT position, backend, source, text, created_at
+		FROM segment_translations
+		WHERE transcription_id = $1 AND language = $2;`, transcriptionID, language)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	translations := make(map[int]model.SegmentTranslation)
+	for rows.Next() {
+		t := model.SegmentTranslation{TranscriptionID: transcriptionID, Language: language}
+		if err = rows.Scan(&t.Position, &t.Backend, &t.Source, &t.Text, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		translations[t.Position] = t
+	}
+	return translations, rows.Err()
+}
+
+func (pdb *PostgresDB) SaveReviewCard(c model.ReviewCard) error {
+	upsertSQL := `
+		INSERT INTO review_cards (transcription_id, position, language, text, translation, interval_days, ease, repetitions, due_at, marked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (transcription_id, position) DO UPDATE SET language = excluded.language, text = excluded.text,
+			translation = excluded.translation, interval_days = excluded.interval_days, ease = excluded.ease,
+			repetitions = excluded.repetitions, due_at = excluded.due_at, marked_at = excluded.marked_at;`
+	_, err := pdb.db.Exec(upsertSQL, c.TranscriptionID, c.Position, c.Language, c.Text, c.Translation,
+		c.IntervalDays, c.Ease, c.Repetitions, c.DueAt, c.MarkedAt)
+	return err
+}
+
+func (pdb *PostgresDB) DeleteReviewCard(transcriptionID int, position int) error {
+	_, err := pdb.db.Exec(`DELETE FROM review_cards WHERE transcription_id = $1 AND position = $2;`, transcriptionID, position)
+	return err
+}
+
+func (pdb *PostgresDB) GetReviewCards(userNickname string) ([]model.ReviewCard, error) {
+	rows, err := pdb.db.Query(`
+		SELECT c.transcription_id, c.position, c.language, c.text, c.translation, c.interval_days, c.ease,
+			c.repetitions, c.due_at, c.marked_at
+		FROM review_cards c
+		JOIN transcriptions t ON t.id = c.transcription_id
+		WHERE t.user_nickname = $1
+		ORDER BY c.due_at, c.transcription_id, c.position;`, userNickname)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	cards := make([]model.ReviewCard, 0)
+	for rows.Next() {
+		var c model.ReviewCard
+		err = rows.Scan(&c.TranscriptionID, &c.Position, &c.Language, &c.Text, &c.Translation, &c.IntervalDays,
+			&c.Ease, &c.Repetitions, &c.DueAt, &c.MarkedAt)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		cards = append(cards, c)
+	}
+	return cards, rows.Err()
+}
+
+func (pdb *PostgresDB) SaveSpeechStats(s model.SpeechStats) error {
+	upsertSQL := `
+		INSERT INTO speech_analytics (transcription_id, version, speech_seconds, words, filler_words, longest_monologue,
+			speakers, interruptions, computed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (transcription_id) DO UPDATE SET version = excluded.version, speech_seconds = excluded.speech_seconds,
+			words = excluded.words, filler_words = excluded.filler_words, longest_monologue = excluded.longest_monologue,
+			speakers = excluded.speakers, interruptions = excluded.interruptions, computed_at = excluded.computed_at;`
+	_, err := pdb.db.Exec(upsertSQL, s.TranscriptionID, s.Version, s.SpeechSeconds, s.Words, s.FillerWords,
+		s.LongestMonologue, s.Speakers, s.Interruptions, s.ComputedAt)
+	return err
+}
+
+func (pdb *PostgresDB) GetSpeechStats(userNickname string) ([]model.SpeechStats, error) {
+	rows, err := pdb.db.Query(`
+		SELECT a.transcription_id, a.version, a.speech_seconds, a.words, a.filler_words, a.longest_monologue,
+			a.speakers, a.interruptions, a.computed_at, t.mp3_file_name, t.last_conversion_time
+		FROM speech_analytics a
+		JOIN transcriptions t ON t.id = a.transcription_id
+		WHERE t.user_nickname = $1
+		ORDER BY t.last_conversion_time, t.id;`, userNickname)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	stats := make([]model.SpeechStats, 0)
+	for rows.Next() {
+		var s model.SpeechStats
+		err = rows.Scan(&s.TranscriptionID, &s.Version, &s.SpeechSeconds, &s.Words, &s.FillerWords, &s.LongestMonologue,
+			&s.Speakers, &s.Interruptions, &s.ComputedAt, &s.FileName, &s.Date)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (pdb *PostgresDB) ReplaceDuplicates(userNickname string, duplicates []model.Duplicate) error {
+	tx, err := pdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DELETE FROM transcription_duplicates
+		WHERE transcription_id IN (SELECT id FROM transcriptions WHERE user_nickname = $1);`, userNickname)
+	if err != nil {
+		return err
+	}
+	for _, d := range duplicates {
+		_, err = tx.Exec(`
+			INSERT INTO transcription_duplicates (transcription_id, duplicate_of, similarity, embedding_similarity, text_similarity, detected_at)
+			VALUES ($1, $2, $3, $4, $5, $6);`,
+			d.TranscriptionID, d.DuplicateOf, d.Similarity, d.EmbeddingSimilarity, d.TextSimilarity, d.DetectedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (pdb *PostgresDB) GetDuplicates(userNickname string) ([]model.Duplicate, error) {
+	rows, err := pdb.db.Query(`
+		SELECT d.transcription_id, d.duplicate_of, d.similarity, d.embedding_similarity, d.text_similarity, d.detected_at,
+			t.mp3_file_name, o.mp3_file_name
+		FROM transcription_duplicates d
+		JOIN transcriptions t ON t.id = d.transcription_id
+		JOIN transcriptions o ON o.id = d.duplicate_of
+		WHERE t.user_nickname = $1
+		ORDER BY d.similarity DESC, d.transcription_id, d.duplicate_of;`, userNickname)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	duplicates := make([]model.Duplicate, 0)
+	for rows.Next() {
+		var d model.Duplicate
+		err = rows.Scan(&d.TranscriptionID, &d.DuplicateOf, &d.Similarity, &d.EmbeddingSimilarity, &d.TextSimilarity, &d.DetectedAt,
+			&d.FileName, &d.DuplicateFileName)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		duplicates = append(duplicates, d)
+	}
+	return duplicates, rows.Err()
+}
+
+func (pdb *PostgresDB) SaveInsights(transcriptionID int, insights model.Insights) error {
+	tx, err := pdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"transcription_chapters", "transcription_entities", "transcription_sentiments"} {
+		if _, err = tx.Exec(`DELETE FROM `+table+` WHERE transcription_id = $1;`, transcriptionID); err != nil {
+			return err
+		}
+	}
+	for i, c := range insights.Chapters {
+		_, err = tx.Exec(`INSERT INTO transcription_chapters (transcription_id, position, start_seconds, end_seconds, headline, gist, summary) VALUES ($1, $2, $3, $4, $5, $6, $7);`,
+			transcriptionID, i, c.Start, c.End, c.Headline, c.Gist, c.Summary)
+		if err != nil {
+			return err
+		}
+	}
+	for i, e := range insights.Entities {
+		_, err = tx.Exec(`INSERT INTO transcription_entities (transcription_id, position, entity_type, text, start_seconds, end_seconds) VALUES ($1, $2, $3, $4, $5, $6);`,
+			transcriptionID, i, e.Type, e.Text, e.Start, e.End)
+		if err != nil {
+			return err
+		}
+	}
+	for i, s := range insights.Sentiments {
+		_, err = tx.Exec(`INSERT INTO transcription_sentiments (transcription_id, position, start_seconds, end_seconds, speaker, text, sentiment, confidence) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`,
+			transcriptionID, i, s.Start, s.End, s.Speaker, s.Text, s.Sentiment, s.Confidence)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (pdb *PostgresDB) GetInsights(transcriptionID int) (model.Insights, error) {
+	var insights model.Insights
+	rows, err := pdb.db.Query(`
+		SELECT start_seconds, end_seconds, headline, gist, summary
+		FROM transcription_chapters
+		WHERE transcription_id = $1
+		ORDER BY position;`, transcriptionID)
+	if err != nil {
+		return insights, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c model.Chapter
+		if err = rows.Scan(&c.Start, &c.End, &c.Headline, &c.Gist, &c.Summary); err != nil {
+			return insights, fmt.Errorf("db scan failed: %v", err)
+		}
+		insights.Chapters = append(insights.Chapters, c)
+	}
+	if err = rows.Err(); err != nil {
+		return insights, err
+	}
+
+	entities, err := pdb.db.Query(`
+		SELECT entity_type, text, start_seconds, end_seconds
+		FROM transcription_entities
+		WHERE transcription_id = $1
+		ORDER BY position;`, transcriptionID)
+	if err != nil {
+		return insights, fmt.Errorf("query failed: %v", err)
+	}
+	defer entities.Close()
+	for entities.Next() {
+		var e model.Entity
+		if err = entities.Scan(&e.Type, &e.Text, &e.Start, &e.End); err != nil {
+			return insights, fmt.Errorf("db scan failed: %v", err)
+		}
+		insights.Entities = append(insights.Entities, e)
+	}
+	if err = entities.Err(); err != nil {
+		return insights, err
+	}
+
+	sentiments, err := pdb.db.Query(`
+		SELECT start_seconds, end_seconds, speaker, text, sentiment, confidence
+		FROM transcription_sentiments
+		WHERE transcription_id = $1
+		ORDER BY position;`, transcriptionID)
+	if err != nil {
+		return insights, fmt.Errorf("query failed: %v", err)
+	}
+	defer sentiments.Close()
+	for sentiments.Next() {
+		var s model.Sentiment
+		if err = sentiments.Scan(&s.Start, &s.End, &s.Speaker, &s.Text, &s.Sentiment, &s.Confidence); err != nil {
+			return insights, fmt.Errorf("db scan failed: %v", err)
+		}
+		insights.Sentiments = append(insights.Sentiments, s)
+	}
+	return insights, sentiments.Err()
+}
+
+func (pdb *PostgresDB) GetTranscriptionsWithoutEmbeddings(embeddingModel string, afterID int, limit int) ([]model.Transcription, error) {
+	var max sql.NullInt64
+	if limit > 0 {
+		max = sql.NullInt64{Int64: int64(limit), Valid: true}
+	}
+	rows, err := pdb.db.Query(`
+		SELECT `+transcriptionColumns+`
+		FROM transcriptions t
+		LEFT JOIN transcription_embeddings e ON e.transcription_id = t.id AND e.model = $1
+		WHERE t.id > $2 AND t.has_error = 0 AND t.transcription <> ''
+		  AND (e.embedded_at IS NULL OR e.embedded_at < t.last_conversion_time)
+		ORDER BY t.id
+		LIMIT $3;`, embeddingModel, afterID, max)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	transcriptions := make([]model.Transcription, 0)
+	for rows.Next() {
+		t, err := scanTranscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		transcriptions = append(transcriptions, *t)
+	}
+	return transcriptions, rows.Err()
+}
+
+func (pdb *PostgresDB) SaveEmbedding(e model.Embedding) error {
+	upsertSQL := `
+		INSERT INTO transcription_embeddings (transcription_id, model, dimensions, vector, embedded_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (transcription_id, model) DO UPDATE SET dimensions = excluded.dimensions, vector = excluded.vector,
+			embedded_at = excluded.embedded_at;`
+	_, err := pdb.db.Exec(upsertSQL, e.TranscriptionID, e.Model, len(e.Vector), vectorBytes(e.Vector), e.EmbeddedAt)
+	return err
+}
+
+func (pdb *PostgresDB) GetEmbedding(transcriptionID int, embeddingModel string) (*model.Embedding, error) {
+	e := model.Embedding{TranscriptionID: transcriptionID, Model: embeddingModel}
+	var vector []byte
+	err := pdb.db.QueryRow(`
+		SELECT vector, embedded_at
+		FROM transcription_embeddings
+		WHERE transcription_id = $1 AND model = $2;`, transcriptionID, embeddingModel).
+		Scan(&vector, &e.EmbeddedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+	e.Vector = parseVector(vector)
+	return &e, nil
+}
+
+func (pdb *PostgresDB) GetEmbeddingProgress(embeddingModel string) (model.EmbeddingProgress, error) {
+	progress := model.EmbeddingProgress{Model: embeddingModel}
+	err := pdb.db.QueryRow(`
+		SELECT count(*) FILTER (WHERE e.embedded_at >= t.last_conversion_time),
+		       count(*) FILTER (WHERE e.embedded_at IS NULL OR e.embedded_at < t.last_conversion_time)
+		FROM transcriptions t
+		LEFT JOIN transcription_embeddings e ON e.transcription_id = t.id AND e.model = $1
+		WHERE t.has_error = 0 AND t.transcription <> '';`, embeddingModel).
+		Scan(&progress.Embedded, &progress.Pending)
+	if err != nil {
+		return progress, fmt.Errorf("db scan failed: %v", err)
+	}
+	return progress, nil
+}
+
+func (pdb *PostgresDB) GetIndexModel() (string, error) {
+	var embeddingModel string
+	err := pdb.db.QueryRow(`SELECT model FROM embedding_index WHERE id = 1;`).Scan(&embeddingModel)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("db scan failed: %v", err)
+	}
+	return embeddingModel, nil
+}
+
+func (pdb *PostgresDB) SetIndexModel(embeddingModel string, dropOthers bool, activatedAt time.Time) error {
+	tx, err := pdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsertSQL := `
+		INSERT INTO embedding_index (id, model, activated_at) VALUES (1, $1, $2)
+		ON CONFLICT (id) DO UPDATE SET model = excluded.model, activated_at = excluded.activated_at;`
+	if _, err = tx.Exec(upsertSQL, embeddingModel, activatedAt); err != nil {
+		return err
+	}
+	if dropOthers {
+		if _, err = tx.Exec(`DELETE FROM transcription_embeddings WHERE model <> $1;`, embeddingModel); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// vectorBytes encodes v as little-endian float32s for the vector column.
+func vectorBytes(v []float32) []byte {
+	data := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(f))
+	}
+	return data
+}
+
+// parseVector decodes the vector column written by vectorBytes.
+func parseVector(data []byte) []float32 {
+	v := make([]float32, len(data)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return v
+}
+
+func (pdb *PostgresDB) QueueRefine(job model.RefineJob) error {
+	upsertSQL := `
+		INSERT INTO refine_jobs (transcription_id, audio_path, status, error_message, queued_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (transcription_id) DO UPDATE SET audio_path = excluded.audio_path, status = excluded.status,
+			error_message = excluded.error_message, queued_at = excluded.queued_at, updated_at = excluded.updated_at;`
+	_, err := pdb.db.Exec(upsertSQL, job.TranscriptionID, job.AudioPath, job.Status, job.Error, job.QueuedAt, job.UpdatedAt)
+	return err
+}
+
+func (pdb *PostgresDB) GetRefineJobs(status model.RefineStatus) ([]model.RefineJob, error) {
+	rows, err := pdb.db.Query(`
+		SELECT transcription_id, audio_path, status, error_message, queued_at, updated_at
+		FROM refine_jobs
+		WHERE $1::VARCHAR = '' OR status = $1
+		ORDER BY queued_at, transcription_id;`, status)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]model.RefineJob, 0)
+	for rows.Next() {
+		var job model.RefineJob
+		if err = rows.Scan(&job.TranscriptionID, &job.AudioPath, &job.Status, &job.Error, &job.QueuedAt, &job.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (pdb *PostgresDB) SetRefineStatus(transcriptionID int, status model.RefineStatus, errorMessage string, updatedAt time.Time) error {
+	updateSQL := `UPDATE refine_jobs SET status = $1, error_message = $2, updated_at = $3 WHERE transcription_id = $4;`
+	result, err := pdb.db.Exec(updateSQL, status, errorMessage, updatedAt, transcriptionID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("transcription %d is not queued for refinement", transcriptionID)
+	}
+	return nil
+}
+
+func (pdb *PostgresDB) GetMetadata(transcriptionID int) (map[string]string, error) {
+	rows, err := pdb.db.Query(`SELECT key, value FROM transcription_metadata WHERE transcription_id = $1;`, transcriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err = rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		values[key] = value
+	}
+	return values, rows.Err()
+}
+
+func (pdb *PostgresDB) SetMetadata(transcriptionID int, values map[string]string) error {
+	tx, err := pdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for key, value := range values {
+		if value == "" {
+			_, err = tx.Exec(`DELETE FROM transcription_metadata WHERE transcription_id = $1 AND key = $2;`, transcriptionID, key)
+		} else {
+			_, err = tx.Exec(`
+				INSERT INTO transcription_metadata (transcription_id, key, value) VALUES ($1, $2, $3)
+				ON CONFLICT (transcription_id, key) DO UPDATE SET value = excluded.value;`, transcriptionID, key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
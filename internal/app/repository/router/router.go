@@ -0,0 +1,103 @@
+// Package router picks the database of a user from the routing table in config.yaml.
+package router
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/repository/memory"
+	"tiktok-whisper/internal/app/repository/pg"
+	"tiktok-whisper/internal/app/repository/sqlite"
+	"tiktok-whisper/internal/app/util/files"
+)
+
+// Opener connects to a database instance.
+type Opener func(instance config.DatabaseInstance) (repository.TranscriptionDAO, error)
+
+// Router hands out the DAO of the database a user is routed to. Connections are opened on
+// first use and shared by every user of the same instance, so closing a routed DAO closes it
+// for all of them, use Close of the router when more than one is in use.
+type Router struct {
+	cfg  config.DatabaseConfig
+	open Opener
+
+	mu   sync.Mutex
+	daos map[string]repository.TranscriptionDAO
+}
+
+// New creates a Router over cfg, open defaults to Open.
+func New(cfg config.DatabaseConfig, open Opener) *Router {
+	if open == nil {
+		open = Open
+	}
+	return &Router{
+		cfg:  cfg,
+		open: open,
+		daos: make(map[string]repository.TranscriptionDAO),
+	}
+}
+
+// ForUser returns the DAO of user's database, users without a route get the default database.
+func (r *Router) ForUser(user string) (repository.TranscriptionDAO, error) {
+	name, instance, err := r.cfg.InstanceFor(user)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if dao, ok := r.daos[name]; ok {
+		return dao, nil
+	}
+	dao, err := r.open(instance)
+	if err != nil {
+		return nil, fmt.Errorf("open database %s failed: %v", name, err)
+	}
+	r.daos[name] = dao
+	return dao, nil
+}
+
+// Close closes every database opened by the router.
+func (r *Router) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for name, dao := range r.daos {
+		if err := dao.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("close database %s failed: %v", name, err)
+		}
+		delete(r.daos, name)
+	}
+	return firstErr
+}
+
+// Open connects to a sqlite or postgres instance, a sqlite instance without DSN is data/transcription.db.
+// A memory instance keeps its data until the process exits.
+func Open(instance config.DatabaseInstance) (repository.TranscriptionDAO, error) {
+	switch instance.Driver {
+	case "", "sqlite":
+		path := instance.DSN
+		if path == "" {
+			projectRoot, err := files.GetProjectRoot()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(projectRoot, "data/transcription.db")
+		}
+		return sqlite.NewSQLiteDB(path), nil
+	case "postgres":
+		dao, err := pg.NewPostgresDB(instance.DSN)
+		if err != nil {
+			return nil, err
+		}
+		return dao, nil
+	case "memory":
+		return memory.NewMemoryDB(), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %s", instance.Driver)
+	}
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"path/filepath"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/repository"
+)
+
+func TestRouter_ForUser(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.DatabaseConfig{
+		Instances: map[string]config.DatabaseInstance{
+			config.DefaultDatabase: {DSN: filepath.Join(dir, "default.db")},
+			"client-a":             {Driver: "sqlite", DSN: filepath.Join(dir, "client-a.db")},
+		},
+		Users: map[string]string{
+			"alice": "client-a",
+			"bob":   "client-a",
+			"eve":   "missing",
+		},
+	}
+
+	opened := make(map[string]int)
+	r := New(cfg, func(instance config.DatabaseInstance) (repository.TranscriptionDAO, error) {
+		opened[instance.DSN]++
+		return Open(instance)
+	})
+	defer r.Close()
+
+	tests := []struct {
+		name    string
+		user    string
+		wantDSN string
+		wantErr bool
+	}{
+		{name: "routed", user: "alice", wantDSN: filepath.Join(dir, "client-a.db")},
+		{name: "same instance", user: "bob", wantDSN: filepath.Join(dir, "client-a.db")},
+		{name: "unrouted", user: "carol", wantDSN: filepath.Join(dir, "default.db")},
+		{name: "unknown instance", user: "eve", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := r.ForUser(tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ForUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && opened[tt.wantDSN] != 1 {
+				t.Errorf("%s opened %d times, want once", tt.wantDSN, opened[tt.wantDSN])
+			}
+		})
+	}
+
+	alice, _ := r.ForUser("alice")
+	bob, _ := r.ForUser("bob")
+	if alice != bob {
+		t.Errorf("ForUser() returned different connections for users of the same instance")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  string
+		wantErr bool
+	}{
+		{name: "memory", driver: "memory"},
+		{name: "unknown", driver: "oracle", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dao, err := Open(config.DatabaseInstance{Driver: tt.driver})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if dao != nil {
+				dao.Close()
+			}
+		})
+	}
+}
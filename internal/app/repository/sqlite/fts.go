@@ -0,0 +1,151 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/textdiff"
+)
+
+// The FTS5 index holds the words of the file name and text of every transcription, one row per
+// transcription with its id as rowid. The words are split like textdiff.Words, so every Han, Hiragana
+// and Katakana character is a word of its own and searching works the same for texts without spaces.
+//
+// FTS5 is compiled into go-sqlite3 by building with -tags sqlite_fts5. Builds without it leave the
+// index alone, the transcriptions they add or delete are caught up with the next time a build with
+// FTS5 opens the database.
+const createFTSTableSQL = `CREATE VIRTUAL TABLE IF NOT EXISTS transcriptions_fts USING fts5(words);`
+
+// execer runs statements on the database or in a transaction.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// ensureFTS creates the full-text index and catches it up with the transcriptions table,
+// it reports false when go-sqlite3 was built without FTS5.
+func ensureFTS(db *sql.DB) (bool, error) {
+	if _, err := db.Exec(createFTSTableSQL); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return false, nil
+		}
+		return false, fmt.Errorf("create full-text index failed: %v", err)
+	}
+
+	if _, err := db.Exec(`DELETE FROM transcriptions_fts WHERE rowid NOT IN (SELECT id FROM transcriptions);`); err != nil {
+		return false, fmt.Errorf("clean full-text index failed: %v", err)
+	}
+	rows, err := db.Query(`SELECT id, mp3_file_name, transcription FROM transcriptions WHERE id NOT IN (SELECT rowid FROM transcriptions_fts);`)
+	if err != nil {
+		return false, fmt.Errorf("query unindexed transcriptions failed: %v", err)
+	}
+	type unindexed struct {
+		id       int64
+		fileName string
+		text     string
+	}
+	var missing []unindexed
+	for rows.Next() {
+		var u unindexed
+		if err = rows.Scan(&u.id, &u.fileName, &u.text); err != nil {
+			rows.Close()
+			return false, err
+		}
+		missing = append(missing, u)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+
+	if len(missing) == 0 {
+		return true, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	for _, u := range missing {
+		if _, err = tx.Exec(`INSERT INTO transcriptions_fts (rowid, words) VALUES (?, ?);`, u.id, ftsWords(u.fileName, u.text)); err != nil {
+			return false, fmt.Errorf("index transcription %d failed: %v", u.id, err)
+		}
+	}
+	return true, tx.Commit()
+}
+
+func ftsWords(fileName string, text string) string {
+	return strings.Join(textdiff.Words(fileName+" "+text), " ")
+}
+
+// reindex updates the full-text index of the transcription with id from its stored row.
+func (sdb *SQLiteDB) reindex(e execer, id int64) error {
+	if !sdb.fts {
+		return nil
+	}
+	var fileName, text string
+	if err := e.QueryRow(`SELECT mp3_file_name, transcription FROM transcriptions WHERE id = ?;`, id).Scan(&fileName, &text); err != nil {
+		return fmt.Errorf("read transcription %d to index failed: %v", id, err)
+	}
+	if _, err := e.Exec(`DELETE FROM transcriptions_fts WHERE rowid = ?;`, id); err != nil {
+		return err
+	}
+	_, err := e.Exec(`INSERT INTO transcriptions_fts (rowid, words) VALUES (?, ?);`, id, ftsWords(fileName, text))
+	return err
+}
+
+// unindex removes the transcription with id from the full-text index.
+func (sdb *SQLiteDB) unindex(e execer, id int64) error {
+	if !sdb.fts {
+		return nil
+	}
+	_, err := e.Exec(`DELETE FROM transcriptions_fts WHERE rowid = ?;`, id)
+	return err
+}
+
+// SearchTranscriptions looks the words of query up in the full-text index, it fails with
+// repository.ErrNoFullTextIndex when go-sqlite3 was built without FTS5.
+func (sdb *SQLiteDB) SearchTranscriptions(query string, user string, limit int) ([]model.Transcription, error) {
+	if !sdb.fts {
+		return nil, repository.ErrNoFullTextIndex
+	}
+	words := textdiff.Words(query)
+	if len(words) == 0 {
+		return []model.Transcription{}, nil
+	}
+	// Every word is a quoted string, so FTS5 doesn't read operators like OR or NEAR in the query
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, `"`+strings.ReplaceAll(w, `"`, `""`)+`"`)
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+
+	sqlStr := `
+		SELECT ` + transcriptionColumns + `
+		FROM transcriptions_fts
+		         JOIN transcriptions ON transcriptions.id = transcriptions_fts.rowid
+		WHERE transcriptions_fts MATCH ?
+		  AND has_error = 0
+		  AND "user" = ?
+		ORDER BY rank
+		LIMIT ?;`
+	rows, err := sdb.db.Query(sqlStr, strings.Join(terms, " "), user, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	transcriptions := make([]model.Transcription, 0)
+	for rows.Next() {
+		t, err := scanTranscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		transcriptions = append(transcriptions, *t)
+	}
+	return transcriptions, rows.Err()
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package sqlite
+
+import "os"
+
+// tryLock is a no-op on windows, concurrent runs rely on SQLite's busy timeout alone.
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
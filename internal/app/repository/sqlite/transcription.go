@@ -2,27 +2,179 @@ package sqlite
 
 import (
 	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/logging"
 	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/repository/migrations"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type SQLiteDB struct {
-	db *sql.DB
+	db   *sql.DB
+	path string
+	lock *os.File
+	// fts is set when the full-text index is kept, see fts.go.
+	fts      bool
+	migrator *migrations.Migrator
+}
+
+// connectionParams put the database in WAL mode, so readers don't block the writer, and make
+// connections wait for a busy database instead of failing with "database is locked".
+// Transactions take the write lock up front, a deferred one can't wait its way out of a busy upgrade.
+const connectionParams = "_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+
+// addedColumns are columns introduced before the schema was versioned, databases created
+// by older versions get them before the initial migration adopts them.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{name: "provider_metadata", definition: "TEXT NOT NULL DEFAULT ''"},
+	{name: "current_revision", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{name: "source_url", definition: "TEXT NOT NULL DEFAULT ''"},
 }
 
 func NewSQLiteDB(dbFilePath string) *SQLiteDB {
-	db, err := sql.Open("sqlite3", dbFilePath)
+	db, err := sql.Open("sqlite3", dataSourceName(dbFilePath))
 	if err != nil {
 		log.Fatal(err)
 	}
-	return &SQLiteDB{db: db}
+
+	migrator, err := ensureSchema(db)
+	if err != nil {
+		log.Fatalf("Failed to prepare database schema: %v\n", err)
+	}
+	fts, err := ensureFTS(db)
+	if err != nil {
+		log.Fatalf("Failed to prepare full-text index: %v\n", err)
+	}
+	return &SQLiteDB{db: db, path: dbFilePath, fts: fts, migrator: migrator}
+}
+
+func dataSourceName(dbFilePath string) string {
+	if strings.Contains(dbFilePath, "?") {
+		return dbFilePath + "&" + connectionParams
+	}
+	return dbFilePath + "?" + connectionParams
+}
+
+// LockRun takes an advisory lock on <db>.lock, so a second conversion run on the
+// same database stops right away instead of interleaving its writes with the first.
+func (sdb *SQLiteDB) LockRun() error {
+	if sdb.lock != nil {
+		return nil
+	}
+
+	lockPath := sdb.path + ".lock"
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("open lock file failed: %v", err)
+	}
+	locked, err := tryLock(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("lock %s failed: %v", lockPath, err)
+	}
+	if !locked {
+		pid, _ := os.ReadFile(lockPath)
+		f.Close()
+		return fmt.Errorf("database %s is in use by another run (pid %s), retry when it finishes",
+			sdb.path, strings.TrimSpace(string(pid)))
+	}
+
+	// The pid is only informational, the lock itself is what excludes other runs
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	if err != nil {
+		logging.L().Warn("Error writing pid", "path", lockPath, "error", err)
+	}
+	sdb.lock = f
+	return nil
+}
+
+// ensureSchema applies the pending migrations. Databases created before the schema was versioned
+// get the columns added since first, so the initial migration finds only tables it can create.
+func ensureSchema(db *sql.DB) (*migrations.Migrator, error) {
+	migrator, err := migrations.New(db, migrations.SQLite)
+	if err != nil {
+		return nil, err
+	}
+
+	var versioned int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations';`).Scan(&versioned)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations failed: %v", err)
+	}
+	if versioned == 0 {
+		if err = addLegacyColumns(db); err != nil {
+			return nil, err
+		}
+	}
+
+	steps, err := migrator.Up()
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		logging.L().Info("Schema migration "+step.String(), "migration", step.Migration.String())
+	}
+	return migrator, nil
+}
+
+func addLegacyColumns(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('transcriptions');`)
+	if err != nil {
+		return fmt.Errorf("query table info failed: %v", err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if len(existing) == 0 {
+		// A new database, the initial migration creates the table with every column
+		return nil
+	}
+
+	for _, c := range addedColumns {
+		if existing[c.name] {
+			continue
+		}
+		if _, err = db.Exec(fmt.Sprintf(`ALTER TABLE transcriptions ADD COLUMN %s %s;`, c.name, c.definition)); err != nil {
+			return fmt.Errorf("add column %s failed: %v", c.name, err)
+		}
+	}
+	return nil
+}
+
+// Migrator returns the migrator of the database schema.
+func (sdb *SQLiteDB) Migrator() *migrations.Migrator {
+	return sdb.migrator
 }
 
 func (sdb *SQLiteDB) Close() error {
+	if sdb.lock != nil {
+		// Closing the descriptor releases the lock, the file stays so its inode is stable for other runs
+		sdb.lock.Close()
+		sdb.lock = nil
+	}
 	return sdb.db.Close()
 }
 
@@ -35,42 +187,1101 @@ func (sdb *SQLiteDB) CheckIfFileProcessed(fileName string) (int, error) {
 }
 
 func (sdb *SQLiteDB) RecordToDB(user, inputDir, fileName, mp3FileName string, audioDuration int, transcription string,
-	lastConversionTime time.Time, hasError int, errorMessage string) {
-	insertSQL := `INSERT INTO transcriptions (user, input_dir, file_name, mp3_file_name, audio_duration, transcription, last_conversion_time, has_error, error_message) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
-	_, err := sdb.db.Exec(insertSQL, user, inputDir, fileName, mp3FileName, audioDuration, transcription, lastConversionTime, hasError, errorMessage)
+	lastConversionTime time.Time, hasError int, errorMessage string, providerMetadata model.ProviderMetadata) {
+	metadata, err := providerMetadata.JSON()
+	if err != nil {
+		logging.L().Error("Failed to serialize provider metadata", "error", err)
+	}
+
+	insertSQL := `INSERT INTO transcriptions (user, input_dir, file_name, mp3_file_name, audio_duration, transcription, last_conversion_time, has_error, error_message, provider_metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	result, err := sdb.db.Exec(insertSQL, user, inputDir, fileName, mp3FileName, audioDuration, transcription, lastConversionTime, hasError, errorMessage, metadata)
 	if err != nil {
 		log.Fatalf("Failed to insert data into database: %v\n", err)
 	}
+	id, err := result.LastInsertId()
+	if err == nil {
+		err = sdb.reindex(sdb.db, id)
+	}
+	if err != nil {
+		logging.L().Error("Error indexing for full-text search", "file", mp3FileName, "error", err)
+	}
 }
 
 func (sdb *SQLiteDB) GetAllByUser(userNickname string) ([]model.Transcription, error) {
-	db, err := GetConnection()
+	sqlStr := `
+		SELECT ` + transcriptionColumns + `
+		FROM transcriptions
+		WHERE has_error = 0
+		  AND "user" = ?
+		ORDER BY last_conversion_time DESC;`
+	rows, err := sdb.db.Query(sqlStr, userNickname)
 	if err != nil {
-		return nil, fmt.Errorf("get connection failed: %v", err)
+		return nil, fmt.Errorf("query failed: %v", err)
 	}
+	defer rows.Close()
 
+	transcriptions := make([]model.Transcription, 0)
+
+	for rows.Next() {
+		t, err := scanTranscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		transcriptions = append(transcriptions, *t)
+	}
+	return transcriptions, nil
+}
+
+func (sdb *SQLiteDB) ListByUser(userNickname string, opts repository.ListOptions) ([]model.Transcription, error) {
+	orderBy, err := opts.OrderBy()
+	if err != nil {
+		return nil, err
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1
+	}
 	sqlStr := `
-		SELECT id, user, last_conversion_time, mp3_file_name, audio_duration, transcription, error_message
+		SELECT ` + transcriptionColumns + `
 		FROM transcriptions
 		WHERE has_error = 0
 		  AND "user" = ?
-		ORDER BY last_conversion_time DESC;`
-	rows, err := db.Query(sqlStr, userNickname)
+		` + orderBy + `
+		LIMIT ? OFFSET ?;`
+	rows, err := sdb.db.Query(sqlStr, userNickname, limit, opts.Offset)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %v", err)
 	}
 	defer rows.Close()
 
 	transcriptions := make([]model.Transcription, 0)
+	for rows.Next() {
+		t, err := scanTranscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		transcriptions = append(transcriptions, *t)
+	}
+	return transcriptions, rows.Err()
+}
+
+func (sdb *SQLiteDB) GetByID(id int) (*model.Transcription, error) {
+	sqlStr := `
+		SELECT ` + transcriptionColumns + `
+		FROM transcriptions
+		WHERE id = ?;`
+	return scanTranscription(sdb.db.QueryRow(sqlStr, id))
+}
+
+// transcriptionColumns are the columns read by scanTranscription, in order.
+const transcriptionColumns = `id, user, last_conversion_time, mp3_file_name, audio_duration, transcription, error_message, provider_metadata, source_url`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTranscription(row scanner) (*model.Transcription, error) {
+	var t model.Transcription
+	var errorMessage sql.NullString
+	var metadata string
+	err := row.Scan(&t.ID, &t.User, &t.LastConversionTime, &t.Mp3FileName, &t.AudioDuration, &t.Transcription, &errorMessage, &metadata, &t.SourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+	t.ErrorMessage = errorMessage.String
+
+	t.ProviderMetadata, err = model.ParseProviderMetadata(metadata)
+	if err != nil {
+		logging.L().Warn("Ignore invalid provider metadata", "id", t.ID, "error", err)
+	}
+	return &t, nil
+}
+
+func (sdb *SQLiteDB) UpdateTranscription(t model.Transcription) error {
+	metadata, err := t.ProviderMetadata.JSON()
+	if err != nil {
+		return fmt.Errorf("serialize provider metadata failed: %v", err)
+	}
+
+	updateSQL := `
+		UPDATE transcriptions
+		SET user = ?, mp3_file_name = ?, audio_duration = ?, transcription = ?, last_conversion_time = ?,
+		    error_message = ?, provider_metadata = ?, source_url = ?
+		WHERE id = ?;`
+	result, err := sdb.db.Exec(updateSQL, t.User, t.Mp3FileName, t.AudioDuration, t.Transcription, t.LastConversionTime,
+		t.ErrorMessage, metadata, t.SourceURL, t.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("no transcription %d", t.ID)
+	}
+	return sdb.reindex(sdb.db, int64(t.ID))
+}
+
+// deleteSQL removes a transcription and the rows of the other tables about it, in order.
+var deleteSQL = []string{
+	`DELETE FROM transcription_words WHERE segment_id IN (SELECT id FROM transcription_segments WHERE transcription_id = ?);`,
+	`DELETE FROM transcription_segments WHERE transcription_id = ?;`,
+	`DELETE FROM transcription_revisions WHERE transcription_id = ?;`,
+	`DELETE FROM artifacts WHERE transcription_id = ?;`,
+	`DELETE FROM transcription_translations WHERE transcription_id = ?;`,
+	`DELETE FROM refine_jobs WHERE transcription_id = ?;`,
+	`DELETE FROM transcription_metadata WHERE transcription_id = ?;`,
+	`DELETE FROM transcription_moderations WHERE transcription_id = ?;`,
+	`DELETE FROM segment_translations WHERE transcription_id = ?;`,
+	`DELETE FROM review_cards WHERE transcription_id = ?;`,
+	`DELETE FROM speech_analytics WHERE transcription_id = ?;`,
+	`DELETE FROM transcription_chapters WHERE transcription_id = ?;`,
+	`DELETE FROM transcription_entities WHERE transcription_id = ?;`,
+	`DELETE FROM transcription_sentiments WHERE transcription_id = ?;`,
+	`DELETE FROM transcription_embeddings WHERE transcription_id = ?;`,
+	`DELETE FROM transcription_duplicates WHERE transcription_id = ?1 OR duplicate_of = ?1;`,
+}
+
+func (sdb *SQLiteDB) DeleteTranscription(id int) error {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM transcriptions WHERE id = ?;`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("no transcription %d", id)
+	}
+	for _, query := range deleteSQL {
+		if _, err = tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+	if err = sdb.unindex(tx, int64(id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (sdb *SQLiteDB) GetUserStats() ([]model.UserStats, error) {
+	sqlStr := `
+		SELECT "user", count(*), coalesce(sum(audio_duration), 0)
+		FROM transcriptions
+		WHERE has_error = 0
+		GROUP BY "user"
+		ORDER BY "user";`
+	rows, err := sdb.db.Query(sqlStr)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	stats := make([]model.UserStats, 0)
+	for rows.Next() {
+		var s model.UserStats
+		err = rows.Scan(&s.User, &s.VideoCount, &s.TotalAudioDuration)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (sdb *SQLiteDB) GetArtifact(transcriptionID int, format string) (*model.Artifact, error) {
+	sqlStr := `
+		SELECT id, transcription_id, format, path, writer_version, content_hash, exported_at
+		FROM artifacts
+		WHERE transcription_id = ? AND format = ?
+		ORDER BY id DESC
+		LIMIT 1;`
+
+	var a model.Artifact
+	err := sdb.db.QueryRow(sqlStr, transcriptionID, format).Scan(&a.ID, &a.TranscriptionID, &a.Format, &a.Path,
+		&a.WriterVersion, &a.ContentHash, &a.ExportedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+	return &a, nil
+}
+
+func (sdb *SQLiteDB) SaveArtifact(a model.Artifact) error {
+	insertSQL := `INSERT INTO artifacts (transcription_id, format, path, writer_version, content_hash, exported_at) VALUES (?, ?, ?, ?, ?, ?);`
+	_, err := sdb.db.Exec(insertSQL, a.TranscriptionID, a.Format, a.Path, a.WriterVersion, a.ContentHash, a.ExportedAt)
+	return err
+}
+
+func (sdb *SQLiteDB) AddRevision(transcriptionID int, transcription string, providerMetadata model.ProviderMetadata, createdAt time.Time) (int, error) {
+	metadata, err := providerMetadata.JSON()
+	if err != nil {
+		return 0, fmt.Errorf("serialize provider metadata failed: %v", err)
+	}
+
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var current int
+	var previousText, previousMetadata string
+	var previousTime time.Time
+	err = tx.QueryRow(`SELECT current_revision, transcription, provider_metadata, last_conversion_time FROM transcriptions WHERE id = ?;`,
+		transcriptionID).Scan(&current, &previousText, &previousMetadata, &previousTime)
+	if err != nil {
+		return 0, fmt.Errorf("db scan failed: %w", err)
+	}
+
+	insertSQL := `INSERT INTO transcription_revisions (transcription_id, revision, transcription, provider_metadata, created_at) VALUES (?, ?, ?, ?, ?);`
+
+	// The first result was stored before revisions existed, keep it as revision 1
+	if current == 0 {
+		if _, err = tx.Exec(insertSQL, transcriptionID, 1, previousText, previousMetadata, previousTime); err != nil {
+			return 0, err
+		}
+	}
+
+	var revision int
+	err = tx.QueryRow(`SELECT coalesce(max(revision), 0) + 1 FROM transcription_revisions WHERE transcription_id = ?;`,
+		transcriptionID).Scan(&revision)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec(insertSQL, transcriptionID, revision, transcription, metadata, createdAt); err != nil {
+		return 0, err
+	}
+
+	updateSQL := `UPDATE transcriptions SET transcription = ?, provider_metadata = ?, last_conversion_time = ?, current_revision = ? WHERE id = ?;`
+	if _, err = tx.Exec(updateSQL, transcription, metadata, createdAt, revision, transcriptionID); err != nil {
+		return 0, err
+	}
+	if err = sdb.reindex(tx, int64(transcriptionID)); err != nil {
+		return 0, err
+	}
+	return revision, tx.Commit()
+}
+
+func (sdb *SQLiteDB) GetRevisions(transcriptionID int) ([]model.Revision, error) {
+	sqlStr := `
+		SELECT r.transcription_id, r.revision, r.transcription, r.provider_metadata, r.created_at, r.revision = t.current_revision
+		FROM transcription_revisions r
+		         JOIN transcriptions t ON t.id = r.transcription_id
+		WHERE r.transcription_id = ?
+		ORDER BY r.revision;`
+	rows, err := sdb.db.Query(sqlStr, transcriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]model.Revision, 0)
+	for rows.Next() {
+		var r model.Revision
+		var metadata string
+		err = rows.Scan(&r.TranscriptionID, &r.Revision, &r.Transcription, &metadata, &r.CreatedAt, &r.Current)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+
+		r.ProviderMetadata, err = model.ParseProviderMetadata(metadata)
+		if err != nil {
+			logging.L().Warn("Ignore invalid provider metadata", "id", transcriptionID, "revision", r.Revision, "error", err)
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+func (sdb *SQLiteDB) SetCurrentRevision(transcriptionID int, revision int) error {
+	updateSQL := `
+		UPDATE transcriptions
+		SET transcription     = r.transcription,
+		    provider_metadata = r.provider_metadata,
+		    current_revision  = r.revision
+		FROM transcription_revisions r
+		WHERE r.transcription_id = transcriptions.id
+		  AND transcriptions.id = ?
+		  AND r.revision = ?;`
+	result, err := sdb.db.Exec(updateSQL, transcriptionID, revision)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("transcription %d has no revision %d", transcriptionID, revision)
+	}
+	return sdb.reindex(sdb.db, int64(transcriptionID))
+}
+
+func (sdb *SQLiteDB) SetSourceURL(transcriptionID int, sourceURL string) error {
+	result, err := sdb.db.Exec(`UPDATE transcriptions SET source_url = ? WHERE id = ?;`, sourceURL, transcriptionID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("no transcription %d", transcriptionID)
+	}
+	return nil
+}
+
+func (sdb *SQLiteDB) SaveSegments(transcriptionID int, segments []model.Segment) error {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM transcription_words WHERE segment_id IN (SELECT id FROM transcription_segments WHERE transcription_id = ?);`, transcriptionID)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM transcription_segments WHERE transcription_id = ?;`, transcriptionID); err != nil {
+		return err
+	}
+
+	segmentSQL := `INSERT INTO transcription_segments (transcription_id, position, start_seconds, end_seconds, speaker, text) VALUES (?, ?, ?, ?, ?, ?);`
+	wordSQL := `INSERT INTO transcription_words (segment_id, position, start_seconds, end_seconds, text, probability) VALUES (?, ?, ?, ?, ?, ?);`
+	for i, s := range segments {
+		result, err := tx.Exec(segmentSQL, transcriptionID, i, s.Start, s.End, s.Speaker, s.Text)
+		if err != nil {
+			return err
+		}
+		segmentID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for j, w := range s.Words {
+			if _, err = tx.Exec(wordSQL, segmentID, j, w.Start, w.End, w.Text, w.Probability); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (sdb *SQLiteDB) GetSegments(transcriptionID int) ([]model.Segment, error) {
+	rows, err := sdb.db.Query(`
+		SELECT id, start_seconds, end_seconds, speaker, text
+		FROM transcription_segments
+		WHERE transcription_id = ?
+		ORDER BY position;`, transcriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	segments := make([]model.Segment, 0)
+	index := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		var s model.Segment
+		if err = rows.Scan(&id, &s.Start, &s.End, &s.Speaker, &s.Text); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		index[id] = len(segments)
+		segments = append(segments, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	words, err := sdb.db.Query(`
+		SELECT w.segment_id, w.start_seconds, w.end_seconds, w.text, w.probability
+		FROM transcription_words w
+		         JOIN transcription_segments s ON s.id = w.segment_id
+		WHERE s.transcription_id = ?
+		ORDER BY s.position, w.position;`, transcriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer words.Close()
+
+	for words.Next() {
+		var segmentID int64
+		var w model.Word
+		if err = words.Scan(&segmentID, &w.Start, &w.End, &w.Text, &w.Probability); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		i := index[segmentID]
+		segments[i].Words = append(segments[i].Words, w)
+	}
+	return segments, words.Err()
+}
+
+func (sdb *SQLiteDB) CreateBatchJob(job model.BatchJob) error {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO batch_jobs (id, kind, user, output_directory, created_at) VALUES (?, ?, ?, ?, ?);`,
+		job.ID, job.Kind, job.User, job.OutputDirectory, job.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	fileSQL := `INSERT INTO batch_job_files (job_id, position, path, status, error_message, updated_at) VALUES (?, ?, ?, ?, ?, ?);`
+	for i, f := range job.Files {
+		if _, err = tx.Exec(fileSQL, job.ID, i, f.Path, f.Status, f.Error, f.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (sdb *SQLiteDB) GetBatchJob(id string) (*model.BatchJob, error) {
+	var job model.BatchJob
+	err := sdb.db.QueryRow(`SELECT id, kind, user, output_directory, created_at FROM batch_jobs WHERE id = ?;`, id).
+		Scan(&job.ID, &job.Kind, &job.User, &job.OutputDirectory, &job.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+
+	rows, err := sdb.db.Query(`
+		SELECT path, status, error_message, updated_at
+		FROM batch_job_files
+		WHERE job_id = ?
+		ORDER BY position;`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var t model.Transcription
-		err = rows.Scan(&t.ID, &t.User, &t.LastConversionTime, &t.Mp3FileName, &t.AudioDuration, &t.Transcription, &t.ErrorMessage)
+		var f model.BatchFile
+		if err = rows.Scan(&f.Path, &f.Status, &f.Error, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		job.Files = append(job.Files, f)
+	}
+	return &job, rows.Err()
+}
+
+func (sdb *SQLiteDB) SetBatchFileStatus(jobID string, path string, status model.BatchFileStatus, errorMessage string, updatedAt time.Time) error {
+	updateSQL := `UPDATE batch_job_files SET status = ?, error_message = ?, updated_at = ? WHERE job_id = ? AND path = ?;`
+	result, err := sdb.db.Exec(updateSQL, status, errorMessage, updatedAt, jobID, path)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("batch job %s has no file %s", jobID, path)
+	}
+	return nil
+}
+
+func (sdb *SQLiteDB) RecordCost(e model.CostEntry) error {
+	insertSQL := `INSERT INTO transcription_costs (transcription_id, user, file_path, provider, model, audio_seconds, cost, recorded_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+	_, err := sdb.db.Exec(insertSQL, e.TranscriptionID, e.User, e.FilePath, e.Provider, e.Model, e.AudioSeconds, e.Cost, e.RecordedAt)
+	return err
+}
+
+func (sdb *SQLiteDB) GetCosts(since time.Time) ([]model.CostEntry, error) {
+	rows, err := sdb.db.Query(`
+		SELECT id, transcription_id, user, file_path, provider, model, audio_seconds, cost, recorded_at
+		FROM transcription_costs
+		WHERE recorded_at >= ?
+		ORDER BY recorded_at, id;`, since)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.CostEntry, 0)
+	for rows.Next() {
+		var e model.CostEntry
+		err = rows.Scan(&e.ID, &e.TranscriptionID, &e.User, &e.FilePath, &e.Provider, &e.Model, &e.AudioSeconds, &e.Cost, &e.RecordedAt)
 		if err != nil {
 			return nil, fmt.Errorf("db scan failed: %v", err)
 		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (sdb *SQLiteDB) SaveTranslation(t model.Translation) error {
+	upsertSQL := `
+		INSERT INTO transcription_translations (transcription_id, language, backend, text, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (transcription_id, language) DO UPDATE SET backend = excluded.backend, text = excluded.text, created_at = excluded.created_at;`
+	_, err := sdb.db.Exec(upsertSQL, t.TranscriptionID, t.Language, t.Backend, t.Text, t.CreatedAt)
+	return err
+}
 
-		transcriptions = append(transcriptions, t)
+func (sdb *SQLiteDB) GetTranslation(transcriptionID int, language string) (*model.Translation, error) {
+	var t model.Translation
+	err := sdb.db.QueryRow(`
+		SELECT id, transcription_id, language, backend, text, created_at
+		FROM transcription_translations
+		WHERE transcription_id = ? AND language = ?;`, transcriptionID, language).
+		Scan(&t.ID, &t.TranscriptionID, &t.Language, &t.Backend, &t.Text, &t.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+	return &t, nil
+}
+
+func (sdb *SQLiteDB) SaveModeration(m model.Moderation) error {
+	scores, flagged, err := moderationJSON(m)
+	if err != nil {
+		return err
+	}
+	upsertSQL := `
+		INSERT INTO transcription_moderations (transcription_id, backend, scores, flagged, checked_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (transcription_id) DO UPDATE SET backend = excluded.backend, scores = excluded.scores,
+			flagged = excluded.flagged, checked_at = excluded.checked_at;`
+	_, err = sdb.db.Exec(upsertSQL, m.TranscriptionID, m.Backend, scores, flagged, m.CheckedAt)
+	return err
+}
+
+func (sdb *SQLiteDB) GetModeration(transcriptionID int) (*model.Moderation, error) {
+	m := model.Moderation{TranscriptionID: transcriptionID}
+	var scores, flagged string
+	err := sdb.db.QueryRow(`
+		SELECT backend, scores, flagged, checked_at
+		FROM transcription_moderations
+		WHERE transcription_id = ?;`, transcriptionID).
+		Scan(&m.Backend, &scores, &flagged, &m.CheckedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+	if err = json.Unmarshal([]byte(scores), &m.Scores); err != nil {
+		return nil, fmt.Errorf("parse moderation scores failed: %v", err)
+	}
+	if err = json.Unmarshal([]byte(flagged), &m.Flagged); err != nil {
+		return nil, fmt.Errorf("parse flagged categories failed: %v", err)
+	}
+	return &m, nil
+}
+
+// moderationJSON serializes the scores and flagged categories of m for their columns.
+func moderationJSON(m model.Moderation) (string, string, error) {
+	if m.Scores == nil {
+		m.Scores = map[string]float64{}
+	}
+	if m.Flagged == nil {
+		m.Flagged = []string{}
+	}
+	scores, err := json.Marshal(m.Scores)
+	if err != nil {
+		return "", "", fmt.Errorf("serialize moderation scores failed: %v", err)
+	}
+	flagged, err := json.Marshal(m.Flagged)
+	if err != nil {
+		return "", "", fmt.Errorf("serialize flagged categories failed: %v", err)
+	}
+	return string(scores), string(flagged), nil
+}
+
+func (sdb *SQLiteDB) SaveSegmentTranslations(translations []model.SegmentTranslation) error {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsertSQL := `
+		INSERT INTO segment_translations (transcription_id, position, language, backend, source, text, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (transcription_id, position, language) DO UPDATE SET backend = excluded.backend,
+			source = excluded.source, text = excluded.text, created_at = excluded.created_at;`
+	for _, t := range translations {
+		_, err = tx.Exec(upsertSQL, t.TranscriptionID, t.Position, t.Language, t.Backend, t.Source, t.Text, t.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (sdb *SQLiteDB) GetSegmentTranslations(transcriptionID int, language string) (map[int]model.SegmentTranslation, error) {
+	rows, err := sdb.db.Query(`
+		SELECT position, backend, source, text, created_at
+		FROM segment_translations
+		WHERE transcription_id = ? AND language = ?;`, transcriptionID, language)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	translations := make(map[int]model.SegmentTranslation)
+	for rows.Next() {
+		t := model.SegmentTranslation{TranscriptionID: transcriptionID, Language: language}
+		if err = rows.Scan(&t.Position, &t.Backend, &t.Source, &t.Text, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		translations[t.Position] = t
+	}
+	return translations, rows.Err()
+}
+
+func (sdb *SQLiteDB) SaveReviewCard(c model.ReviewCard) error {
+	upsertSQL := `
+		INSERT INTO review_cards (transcription_id, position, language, text, translation, interval_days, ease, repetitions, due_at, marked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (transcription_id, position) DO UPDATE SET language = excluded.language, text = excluded.text,
+			translation = excluded.translation, interval_days = excluded.interval_days, ease = excluded.ease,
+			repetitions = excluded.repetitions, due_at = excluded.due_at, marked_at = excluded.marked_at;`
+	_, err := sdb.db.Exec(upsertSQL, c.TranscriptionID, c.Position, c.Language, c.Text, c.Translation,
+		c.IntervalDays, c.Ease, c.Repetitions, c.DueAt, c.MarkedAt)
+	return err
+}
+
+func (sdb *SQLiteDB) DeleteReviewCard(transcriptionID int, position int) error {
+	_, err := sdb.db.Exec(`DELETE FROM review_cards WHERE transcription_id = ? AND position = ?;`, transcriptionID, position)
+	return err
+}
+
+func (sdb *SQLiteDB) GetReviewCards(userNickname string) ([]model.ReviewCard, error) {
+	rows, err := sdb.db.Query(`
+		SELECT c.transcription_id, c.position, c.language, c.text, c.translation, c.interval_days, c.ease,
+			c.repetitions, c.due_at, c.marked_at
+		FROM review_cards c
+		JOIN transcriptions t ON t.id = c.transcription_id
+		WHERE t.user = ?
+		ORDER BY c.due_at, c.transcription_id, c.position;`, userNickname)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	cards := make([]model.ReviewCard, 0)
+	for rows.Next() {
+		var c model.ReviewCard
+		err = rows.Scan(&c.TranscriptionID, &c.Position, &c.Language, &c.Text, &c.Translation, &c.IntervalDays,
+			&c.Ease, &c.Repetitions, &c.DueAt, &c.MarkedAt)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		cards = append(cards, c)
+	}
+	return cards, rows.Err()
+}
+
+func (sdb *SQLiteDB) SaveSpeechStats(s model.SpeechStats) error {
+	upsertSQL := `
+		INSERT INTO speech_analytics (transcription_id, version, speech_seconds, words, filler_words, longest_monologue,
+			speakers, interruptions, computed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (transcription_id) DO UPDATE SET version = excluded.version, speech_seconds = excluded.speech_seconds,
+			words = excluded.words, filler_words = excluded.filler_words, longest_monologue = excluded.longest_monologue,
+			speakers = excluded.speakers, interruptions = excluded.interruptions, computed_at = excluded.computed_at;`
+	_, err := sdb.db.Exec(upsertSQL, s.TranscriptionID, s.Version, s.SpeechSeconds, s.Words, s.FillerWords,
+		s.LongestMonologue, s.Speakers, s.Interruptions, s.ComputedAt)
+	return err
+}
+
+func (sdb *SQLiteDB) GetSpeechStats(userNickname string) ([]model.SpeechStats, error) {
+	rows, err := sdb.db.Query(`
+		SELECT a.transcription_id, a.version, a.speech_seconds, a.words, a.filler_words, a.longest_monologue,
+			a.speakers, a.interruptions, a.computed_at, t.mp3_file_name, t.last_conversion_time
+		FROM speech_analytics a
+		JOIN transcriptions t ON t.id = a.transcription_id
+		WHERE t.user = ?
+		ORDER BY t.last_conversion_time, t.id;`, userNickname)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	stats := make([]model.SpeechStats, 0)
+	for rows.Next() {
+		var s model.SpeechStats
+		err = rows.Scan(&s.TranscriptionID, &s.Version, &s.SpeechSeconds, &s.Words, &s.FillerWords, &s.LongestMonologue,
+			&s.Speakers, &s.Interruptions, &s.ComputedAt, &s.FileName, &s.Date)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (sdb *SQLiteDB) ReplaceDuplicates(userNickname string, duplicates []model.Duplicate) error {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DELETE FROM transcription_duplicates
+		WHERE transcription_id IN (SELECT id FROM transcriptions WHERE "user" = ?);`, userNickname)
+	if err != nil {
+		return err
+	}
+	for _, d := range duplicates {
+		_, err = tx.Exec(`
+			INSERT INTO transcription_duplicates (transcription_id, duplicate_of, similarity, embedding_similarity, text_similarity, detected_at)
+			VALUES (?, ?, ?, ?, ?, ?);`,
+			d.TranscriptionID, d.DuplicateOf, d.Similarity, d.EmbeddingSimilarity, d.TextSimilarity, d.DetectedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (sdb *SQLiteDB) GetDuplicates(userNickname string) ([]model.Duplicate, error) {
+	rows, err := sdb.db.Query(`
+		SELECT d.transcription_id, d.duplicate_of, d.similarity, d.embedding_similarity, d.text_similarity, d.detected_at,
+			t.mp3_file_name, o.mp3_file_name
+		FROM transcription_duplicates d
+		JOIN transcriptions t ON t.id = d.transcription_id
+		JOIN transcriptions o ON o.id = d.duplicate_of
+		WHERE t."user" = ?
+		ORDER BY d.similarity DESC, d.transcription_id, d.duplicate_of;`, userNickname)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	duplicates := make([]model.Duplicate, 0)
+	for rows.Next() {
+		var d model.Duplicate
+		err = rows.Scan(&d.TranscriptionID, &d.DuplicateOf, &d.Similarity, &d.EmbeddingSimilarity, &d.TextSimilarity, &d.DetectedAt,
+			&d.FileName, &d.DuplicateFileName)
+		if err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		duplicates = append(duplicates, d)
+	}
+	return duplicates, rows.Err()
+}
+
+func (sdb *SQLiteDB) SaveInsights(transcriptionID int, insights model.Insights) error {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"transcription_chapters", "transcription_entities", "transcription_sentiments"} {
+		if _, err = tx.Exec(`DELETE FROM `+table+` WHERE transcription_id = ?;`, transcriptionID); err != nil {
+			return err
+		}
+	}
+	for i, c := range insights.Chapters {
+		_, err = tx.Exec(`INSERT INTO transcription_chapters (transcription_id, position, start_seconds, end_seconds, headline, gist, summary) VALUES (?, ?, ?, ?, ?, ?, ?);`,
+			transcriptionID, i, c.Start, c.End, c.Headline, c.Gist, c.Summary)
+		if err != nil {
+			return err
+		}
+	}
+	for i, e := range insights.Entities {
+		_, err = tx.Exec(`INSERT INTO transcription_entities (transcription_id, position, entity_type, text, start_seconds, end_seconds) VALUES (?, ?, ?, ?, ?, ?);`,
+			transcriptionID, i, e.Type, e.Text, e.Start, e.End)
+		if err != nil {
+			return err
+		}
+	}
+	for i, s := range insights.Sentiments {
+		_, err = tx.Exec(`INSERT INTO transcription_sentiments (transcription_id, position, start_seconds, end_seconds, speaker, text, sentiment, confidence) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+			transcriptionID, i, s.Start, s.End, s.Speaker, s.Text, s.Sentiment, s.Confidence)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (sdb *SQLiteDB) GetInsights(transcriptionID int) (model.Insights, error) {
+	var insights model.Insights
+	rows, err := sdb.db.Query(`
+		SELECT start_seconds, end_seconds, headline, gist, summary
+		FROM transcription_chapters
+		WHERE transcription_id = ?
+		ORDER BY position;`, transcriptionID)
+	if err != nil {
+		return insights, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c model.Chapter
+		if err = rows.Scan(&c.Start, &c.End, &c.Headline, &c.Gist, &c.Summary); err != nil {
+			return insights, fmt.Errorf("db scan failed: %v", err)
+		}
+		insights.Chapters = append(insights.Chapters, c)
+	}
+	if err = rows.Err(); err != nil {
+		return insights, err
+	}
+
+	entities, err := sdb.db.Query(`
+		SELECT entity_type, text, start_seconds, end_seconds
+		FROM transcription_entities
+		WHERE transcription_id = ?
+		ORDER BY position;`, transcriptionID)
+	if err != nil {
+		return insights, fmt.Errorf("query failed: %v", err)
+	}
+	defer entities.Close()
+	for entities.Next() {
+		var e model.Entity
+		if err = entities.Scan(&e.Type, &e.Text, &e.Start, &e.End); err != nil {
+			return insights, fmt.Errorf("db scan failed: %v", err)
+		}
+		insights.Entities = append(insights.Entities, e)
+	}
+	if err = entities.Err(); err != nil {
+		return insights, err
+	}
+
+	sentiments, err := sdb.db.Query(`
+		SELECT start_seconds, end_seconds, speaker, text, sentiment, confidence
+		FROM transcription_sentiments
+		WHERE transcription_id = ?
+		ORDER BY position;`, transcriptionID)
+	if err != nil {
+		return insights, fmt.Errorf("query failed: %v", err)
+	}
+	defer sentiments.Close()
+	for sentiments.Next() {
+		var s model.Sentiment
+		if err = sentiments.Scan(&s.Start, &s.End, &s.Speaker, &s.Text, &s.Sentiment, &s.Confidence); err != nil {
+			return insights, fmt.Errorf("db scan failed: %v", err)
+		}
+		insights.Sentiments = append(insights.Sentiments, s)
+	}
+	return insights, sentiments.Err()
+}
+
+func (sdb *SQLiteDB) GetTranscriptionsWithoutEmbeddings(embeddingModel string, afterID int, limit int) ([]model.Transcription, error) {
+	var ids []int
+	err := sdb.embeddingCandidates(embeddingModel, afterID, func(id int, embedded bool) bool {
+		if !embedded {
+			ids = append(ids, id)
+		}
+		return limit <= 0 || len(ids) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transcriptions := make([]model.Transcription, 0, len(ids))
+	for _, id := range ids {
+		t, err := sdb.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		transcriptions = append(transcriptions, *t)
 	}
 	return transcriptions, nil
 }
+
+// embeddingCandidates calls visit with the ID of each transcription above afterID that has text to
+// embed, lowest first, and whether its embedding of embeddingModel is current, until visit returns
+// false. Staleness is decided here rather than in SQL since SQLite compares the stored times as text.
+func (sdb *SQLiteDB) embeddingCandidates(embeddingModel string, afterID int, visit func(id int, embedded bool) bool) error {
+	rows, err := sdb.db.Query(`
+		SELECT t.id, t.last_conversion_time, e.embedded_at
+		FROM transcriptions t
+		LEFT JOIN transcription_embeddings e ON e.transcription_id = t.id AND e.model = ?
+		WHERE t.id > ? AND t.has_error = 0 AND t.transcription != ''
+		ORDER BY t.id;`, embeddingModel, afterID)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var changedAt time.Time
+		var embeddedAt sql.NullTime
+		if err = rows.Scan(&id, &changedAt, &embeddedAt); err != nil {
+			return fmt.Errorf("db scan failed: %v", err)
+		}
+		if !visit(id, embeddedAt.Valid && !embeddedAt.Time.Before(changedAt)) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+func (sdb *SQLiteDB) SaveEmbedding(e model.Embedding) error {
+	upsertSQL := `
+		INSERT INTO transcription_embeddings (transcription_id, model, dimensions, vector, embedded_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (transcription_id, model) DO UPDATE SET dimensions = excluded.dimensions, vector = excluded.vector,
+			embedded_at = excluded.embedded_at;`
+	_, err := sdb.db.Exec(upsertSQL, e.TranscriptionID, e.Model, len(e.Vector), vectorBytes(e.Vector), e.EmbeddedAt)
+	return err
+}
+
+func (sdb *SQLiteDB) GetEmbedding(transcriptionID int, embeddingModel string) (*model.Embedding, error) {
+	e := model.Embedding{TranscriptionID: transcriptionID, Model: embeddingModel}
+	var vector []byte
+	err := sdb.db.QueryRow(`
+		SELECT vector, embedded_at
+		FROM transcription_embeddings
+		WHERE transcription_id = ? AND model = ?;`, transcriptionID, embeddingModel).
+		Scan(&vector, &e.EmbeddedAt)
+	if err != nil {
+		return nil, fmt.Errorf("db scan failed: %w", err)
+	}
+	e.Vector = parseVector(vector)
+	return &e, nil
+}
+
+func (sdb *SQLiteDB) GetEmbeddingProgress(embeddingModel string) (model.EmbeddingProgress, error) {
+	progress := model.EmbeddingProgress{Model: embeddingModel}
+	err := sdb.embeddingCandidates(embeddingModel, 0, func(id int, embedded bool) bool {
+		if embedded {
+			progress.Embedded++
+		} else {
+			progress.Pending++
+		}
+		return true
+	})
+	return progress, err
+}
+
+func (sdb *SQLiteDB) GetIndexModel() (string, error) {
+	var embeddingModel string
+	err := sdb.db.QueryRow(`SELECT model FROM embedding_index WHERE id = 1;`).Scan(&embeddingModel)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("db scan failed: %v", err)
+	}
+	return embeddingModel, nil
+}
+
+func (sdb *SQLiteDB) SetIndexModel(embeddingModel string, dropOthers bool, activatedAt time.Time) error {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsertSQL := `
+		INSERT INTO embedding_index (id, model, activated_at) VALUES (1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET model = excluded.model, activated_at = excluded.activated_at;`
+	if _, err = tx.Exec(upsertSQL, embeddingModel, activatedAt); err != nil {
+		return err
+	}
+	if dropOthers {
+		if _, err = tx.Exec(`DELETE FROM transcription_embeddings WHERE model <> ?;`, embeddingModel); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// vectorBytes encodes v as little-endian float32s for the vector column.
+func vectorBytes(v []float32) []byte {
+	data := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(f))
+	}
+	return data
+}
+
+// parseVector decodes the vector column written by vectorBytes.
+func parseVector(data []byte) []float32 {
+	v := make([]float32, len(data)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return v
+}
+
+func (sdb *SQLiteDB) QueueRefine(job model.RefineJob) error {
+	upsertSQL := `
+		INSERT INTO refine_jobs (transcription_id, audio_path, status, error_message, queued_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (transcription_id) DO UPDATE SET audio_path = excluded.audio_path, status = excluded.status,
+			error_message = excluded.error_message, queued_at = excluded.queued_at, updated_at = excluded.updated_at;`
+	_, err := sdb.db.Exec(upsertSQL, job.TranscriptionID, job.AudioPath, job.Status, job.Error, job.QueuedAt, job.UpdatedAt)
+	return err
+}
+
+func (sdb *SQLiteDB) GetRefineJobs(status model.RefineStatus) ([]model.RefineJob, error) {
+	rows, err := sdb.db.Query(`
+		SELECT transcription_id, audio_path, status, error_message, queued_at, updated_at
+		FROM refine_jobs
+		WHERE ? = '' OR status = ?
+		ORDER BY queued_at, transcription_id;`, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]model.RefineJob, 0)
+	for rows.Next() {
+		var job model.RefineJob
+		if err = rows.Scan(&job.TranscriptionID, &job.AudioPath, &job.Status, &job.Error, &job.QueuedAt, &job.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (sdb *SQLiteDB) SetRefineStatus(transcriptionID int, status model.RefineStatus, errorMessage string, updatedAt time.Time) error {
+	updateSQL := `UPDATE refine_jobs SET status = ?, error_message = ?, updated_at = ? WHERE transcription_id = ?;`
+	result, err := sdb.db.Exec(updateSQL, status, errorMessage, updatedAt, transcriptionID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("transcription %d is not queued for refinement", transcriptionID)
+	}
+	return nil
+}
+
+func (sdb *SQLiteDB) GetMetadata(transcriptionID int) (map[string]string, error) {
+	rows, err := sdb.db.Query(`SELECT key, value FROM transcription_metadata WHERE transcription_id = ?;`, transcriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err = rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("db scan failed: %v", err)
+		}
+		values[key] = value
+	}
+	return values, rows.Err()
+}
+
+func (sdb *SQLiteDB) SetMetadata(transcriptionID int, values map[string]string) error {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for key, value := range values {
+		if value == "" {
+			_, err = tx.Exec(`DELETE FROM transcription_metadata WHERE transcription_id = ? AND key = ?;`, transcriptionID, key)
+		} else {
+			_, err = tx.Exec(`
+				INSERT INTO transcription_metadata (transcription_id, key, value) VALUES (?, ?, ?)
+				ON CONFLICT (transcription_id, key) DO UPDATE SET value = excluded.value;`, transcriptionID, key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,804 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+func newTestDB(t *testing.T) *SQLiteDB {
+	t.Helper()
+	sdb := NewSQLiteDB(filepath.Join(t.TempDir(), "transcription.db"))
+	t.Cleanup(func() { sdb.Close() })
+	return sdb
+}
+
+func TestSQLiteDB_RecordToDB(t *testing.T) {
+	sdb := newTestDB(t)
+
+	metadata := model.ProviderMetadata{Provider: "whisper_cpp", Model: "large-v2", SegmentCount: 2}
+	sdb.RecordToDB("alice", "/in/a.mp4", "a.mp4", "a.mp3", 30, "hello", time.Now(), 0, "", metadata)
+	sdb.RecordToDB("alice", "/in/b.mp4", "b.mp4", "b.mp3", 10, "", time.Now(), 1, "boom", model.ProviderMetadata{})
+	sdb.RecordToDB("bob", "/in/c.mp4", "c.mp4", "c.mp3", 20, "world", time.Now(), 0, "", model.ProviderMetadata{})
+
+	got, err := sdb.GetAllByUser("alice")
+	if err != nil {
+		t.Fatalf("GetAllByUser() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Transcription != "hello" {
+		t.Fatalf("GetAllByUser() = %+v", got)
+	}
+	if got[0].ProviderMetadata.Provider != "whisper_cpp" || got[0].ProviderMetadata.SegmentCount != 2 {
+		t.Errorf("GetAllByUser() metadata = %+v", got[0].ProviderMetadata)
+	}
+
+	if _, err = sdb.CheckIfFileProcessed("b.mp4"); err == nil {
+		t.Errorf("CheckIfFileProcessed() found a failed conversion")
+	}
+
+	stats, err := sdb.GetUserStats()
+	if err != nil {
+		t.Fatalf("GetUserStats() error = %v", err)
+	}
+	want := []model.UserStats{
+		{User: "alice", VideoCount: 1, TotalAudioDuration: 30},
+		{User: "bob", VideoCount: 1, TotalAudioDuration: 20},
+	}
+	if len(stats) != len(want) || stats[0] != want[0] || stats[1] != want[1] {
+		t.Errorf("GetUserStats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestNewSQLiteDB_UpgradesLegacySchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = legacy.Exec(`CREATE TABLE transcriptions (id INTEGER PRIMARY KEY AUTOINCREMENT, user TEXT NOT NULL,
+		input_dir TEXT NOT NULL, file_name TEXT NOT NULL, mp3_file_name TEXT NOT NULL, audio_duration INTEGER NOT NULL,
+		transcription TEXT NOT NULL, last_conversion_time DATETIME NOT NULL, has_error INTEGER NOT NULL, error_message TEXT);`)
+	legacy.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sdb := NewSQLiteDB(dbPath)
+	defer sdb.Close()
+	sdb.RecordToDB("alice", "/in/a.mp4", "a.mp4", "a.mp3", 30, "hello", time.Now(), 0, "", model.ProviderMetadata{Provider: "openai"})
+
+	got, err := sdb.GetAllByUser("alice")
+	if err != nil || len(got) != 1 || got[0].ProviderMetadata.Provider != "openai" {
+		t.Errorf("GetAllByUser() = %+v, %v", got, err)
+	}
+	if version, err := sdb.Migrator().Version(); err != nil || version != sdb.Migrator().Latest() {
+		t.Errorf("Version() of the adopted database = %d, %v, want the latest", version, err)
+	}
+}
+
+func TestSQLiteDB_GetByID(t *testing.T) {
+	sdb := newTestDB(t)
+	sdb.RecordToDB("alice", "/in/a.mp4", "a.mp4", "a.mp3", 30, "hello", time.Now(), 0, "", model.ProviderMetadata{})
+
+	got, err := sdb.GetByID(1)
+	if err != nil || got.Transcription != "hello" || got.User != "alice" {
+		t.Errorf("GetByID() = %+v, %v", got, err)
+	}
+
+	if _, err = sdb.GetByID(2); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID() of missing row error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLiteDB_SetSourceURL(t *testing.T) {
+	sdb := newTestDB(t)
+	sdb.RecordToDB("alice", "/in/a.m4a", "a.m4a", "a.m4a.mp3", 30, "hello", time.Now(), 0, "", model.ProviderMetadata{})
+
+	const url = "https://www.youtube.com/watch?v=abc"
+	if err := sdb.SetSourceURL(1, url); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := sdb.GetByID(1); err != nil || got.SourceURL != url {
+		t.Errorf("GetByID() = %+v, %v, want source %s", got, err, url)
+	}
+	if err := sdb.SetSourceURL(2, url); err == nil {
+		t.Errorf("SetSourceURL() of a missing transcription succeeded")
+	}
+}
+
+func TestSQLiteDB_Revisions(t *testing.T) {
+	sdb := newTestDB(t)
+	sdb.RecordToDB("alice", "/in/a.mp4", "a.mp4", "a.mp3", 30, "first", time.Now(), 0, "", model.ProviderMetadata{Provider: "openai"})
+
+	revisions, err := sdb.GetRevisions(1)
+	if err != nil || len(revisions) != 0 {
+		t.Fatalf("GetRevisions() before re-transcription = %+v, %v", revisions, err)
+	}
+
+	for i, text := range []string{"second", "third"} {
+		revision, err := sdb.AddRevision(1, text, model.ProviderMetadata{Provider: "whisper_cpp"}, time.Now())
+		if err != nil {
+			t.Fatalf("AddRevision() error = %v", err)
+		}
+		if want := i + 2; revision != want {
+			t.Errorf("AddRevision() = %d, want %d", revision, want)
+		}
+	}
+
+	revisions, err = sdb.GetRevisions(1)
+	if err != nil {
+		t.Fatalf("GetRevisions() error = %v", err)
+	}
+	if len(revisions) != 3 || revisions[0].Transcription != "first" || revisions[0].ProviderMetadata.Provider != "openai" {
+		t.Fatalf("GetRevisions() = %+v", revisions)
+	}
+	if !revisions[2].Current || revisions[0].Current {
+		t.Errorf("GetRevisions() current flags = %v %v %v", revisions[0].Current, revisions[1].Current, revisions[2].Current)
+	}
+
+	tests := []struct {
+		name     string
+		revision int
+		want     string
+		wantErr  bool
+	}{
+		{name: "back to the first", revision: 1, want: "first"},
+		{name: "unknown revision", revision: 9, want: "first", wantErr: true},
+		{name: "latest", revision: 3, want: "third"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sdb.SetCurrentRevision(1, tt.revision)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetCurrentRevision() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got, err := sdb.GetByID(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Transcription != tt.want {
+				t.Errorf("GetByID() transcription = %q, want %q", got.Transcription, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteDB_LockRun(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "transcription.db")
+	first := NewSQLiteDB(dbPath)
+	second := NewSQLiteDB(dbPath)
+	defer second.Close()
+
+	if err := first.LockRun(); err != nil {
+		t.Fatalf("LockRun() error = %v", err)
+	}
+	if err := second.LockRun(); err == nil {
+		t.Errorf("LockRun() of a second run succeeded while the first holds the lock")
+	}
+
+	first.Close()
+	if err := second.LockRun(); err != nil {
+		t.Errorf("LockRun() after the first run closed error = %v", err)
+	}
+}
+
+func TestNewSQLiteDB_WAL(t *testing.T) {
+	sdb := newTestDB(t)
+
+	var mode string
+	if err := sdb.db.QueryRow(`PRAGMA journal_mode;`).Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Errorf("journal_mode = %v, want wal", mode)
+	}
+}
+
+func TestSQLiteDB_Segments(t *testing.T) {
+	db := newTestDB(t)
+
+	first := []model.Segment{
+		{Start: 0, End: 2, Speaker: "A", Text: "Hello there.", Words: []model.Word{
+			{Start: 0, End: 0.8, Text: "Hello", Probability: 0.9},
+			{Start: 0.8, End: 2, Text: "there."},
+		}},
+		{Start: 2, End: 4, Text: "General Kenobi."},
+	}
+	second := []model.Segment{{Start: 0, End: 3, Text: "Retranscribed."}}
+
+	tests := []struct {
+		name     string
+		segments []model.Segment
+	}{
+		{name: "saved", segments: first},
+		{name: "replaced", segments: second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.SaveSegments(1, tt.segments); err != nil {
+				t.Fatalf("SaveSegments() error = %v", err)
+			}
+			got, err := db.GetSegments(1)
+			if err != nil {
+				t.Fatalf("GetSegments() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.segments) {
+				t.Errorf("GetSegments() = %+v, want %+v", got, tt.segments)
+			}
+		})
+	}
+
+	if got, err := db.GetSegments(2); err != nil || len(got) != 0 {
+		t.Errorf("GetSegments() of a transcription without segments = %+v, %v", got, err)
+	}
+}
+
+func TestSQLiteDB_BatchJobs(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	job := model.BatchJob{
+		ID:              "0123456789abcdef",
+		Kind:            model.BatchAudio,
+		OutputDirectory: "/data/transcription",
+		CreatedAt:       now,
+		Files: []model.BatchFile{
+			{Path: "/audio/b.mp3", Status: model.BatchFilePending, UpdatedAt: now},
+			{Path: "/audio/a.mp3", Status: model.BatchFilePending, UpdatedAt: now},
+		},
+	}
+	if err := db.CreateBatchJob(job); err != nil {
+		t.Fatalf("CreateBatchJob() error = %v", err)
+	}
+	if err := db.SetBatchFileStatus(job.ID, "/audio/a.mp3", model.BatchFileFailed, "decode failed", now); err != nil {
+		t.Fatalf("SetBatchFileStatus() error = %v", err)
+	}
+	if err := db.SetBatchFileStatus(job.ID, "/audio/missing.mp3", model.BatchFileDone, "", now); err == nil {
+		t.Errorf("SetBatchFileStatus() of a file not in the job succeeded")
+	}
+
+	got, err := db.GetBatchJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetBatchJob() error = %v", err)
+	}
+	if got.Kind != job.Kind || got.OutputDirectory != job.OutputDirectory || len(got.Files) != 2 {
+		t.Fatalf("GetBatchJob() = %+v", got)
+	}
+	if got.Files[0].Path != "/audio/b.mp3" || got.Files[0].Status != model.BatchFilePending {
+		t.Errorf("first file = %+v, want the pending b.mp3", got.Files[0])
+	}
+	if got.Files[1].Status != model.BatchFileFailed || got.Files[1].Error != "decode failed" {
+		t.Errorf("second file = %+v, want the failed a.mp3", got.Files[1])
+	}
+
+	if _, err = db.GetBatchJob("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetBatchJob() of an unknown job error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLiteDB_Costs(t *testing.T) {
+	db := newTestDB(t)
+	month := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	entries := []model.CostEntry{
+		{TranscriptionID: 1, User: "alice", FilePath: "/v/a.mp4", Provider: "openai", AudioSeconds: 60, Cost: 0.006, RecordedAt: month.Add(-time.Hour)},
+		{TranscriptionID: 2, User: "alice", FilePath: "/v/b.mp4", Provider: "openai", AudioSeconds: 120, Cost: 0.012, RecordedAt: month.Add(time.Hour)},
+		{User: "", FilePath: "/a/c.mp3", Provider: "whisper_cpp", AudioSeconds: 30, RecordedAt: month.Add(2 * time.Hour)},
+	}
+	for _, e := range entries {
+		if err := db.RecordCost(e); err != nil {
+			t.Fatalf("RecordCost() error = %v", err)
+		}
+	}
+
+	got, err := db.GetCosts(month)
+	if err != nil {
+		t.Fatalf("GetCosts() error = %v", err)
+	}
+	if len(got) != 2 || got[0].FilePath != "/v/b.mp4" || got[0].Cost != 0.012 || got[1].Provider != "whisper_cpp" {
+		t.Errorf("GetCosts() = %+v, want the two entries of the month", got)
+	}
+	if !got[0].RecordedAt.Equal(entries[1].RecordedAt) {
+		t.Errorf("RecordedAt = %v, want %v", got[0].RecordedAt, entries[1].RecordedAt)
+	}
+}
+
+func TestSQLiteDB_Translations(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tr := range []model.Translation{
+		{TranscriptionID: 1, Language: "en", Backend: "openai", Text: "old", CreatedAt: now},
+		{TranscriptionID: 1, Language: "en", Backend: "deepl", Text: "new", CreatedAt: now.Add(time.Hour)},
+	} {
+		if err := db.SaveTranslation(tr); err != nil {
+			t.Fatalf("SaveTranslation() error = %v", err)
+		}
+	}
+
+	got, err := db.GetTranslation(1, "en")
+	if err != nil {
+		t.Fatalf("GetTranslation() error = %v", err)
+	}
+	if got.Text != "new" || got.Backend != "deepl" || !got.CreatedAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("GetTranslation() = %+v, want the replaced translation", got)
+	}
+	if _, err = db.GetTranslation(1, "fr"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetTranslation() of an untranslated language error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLiteDB_Moderations(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, m := range []model.Moderation{
+		{TranscriptionID: 1, Backend: "openai", Scores: map[string]float64{"violence": 0.9}, Flagged: []string{"violence"}, CheckedAt: now},
+		{TranscriptionID: 1, Backend: "http", Scores: map[string]float64{"violence": 0.1, "hate": 0.02}, CheckedAt: now.Add(time.Hour)},
+	} {
+		if err := db.SaveModeration(m); err != nil {
+			t.Fatalf("SaveModeration() error = %v", err)
+		}
+	}
+
+	got, err := db.GetModeration(1)
+	if err != nil {
+		t.Fatalf("GetModeration() error = %v", err)
+	}
+	if got.Backend != "http" || got.IsFlagged() || got.Scores["hate"] != 0.02 || !got.CheckedAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("GetModeration() = %+v, want the replaced result", got)
+	}
+	if _, err = db.GetModeration(2); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetModeration() of an unscreened transcription error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLiteDB_Review(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 30, "Hallo. Wie geht's?", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "b.mp4", "b.mp3", 30, "Hola.", now, 0, "", model.ProviderMetadata{})
+
+	err := db.SaveSegmentTranslations([]model.SegmentTranslation{
+		{TranscriptionID: 1, Position: 0, Language: "en", Backend: "deepl", Source: "Hallo.", Text: "Hi.", CreatedAt: now},
+		{TranscriptionID: 1, Position: 1, Language: "en", Backend: "deepl", Source: "Wie geht's?", Text: "How is it going?", CreatedAt: now},
+	})
+	if err == nil {
+		err = db.SaveSegmentTranslations([]model.SegmentTranslation{
+			{TranscriptionID: 1, Position: 0, Language: "en", Backend: "openai", Source: "Hallo.", Text: "Hello.", CreatedAt: now},
+		})
+	}
+	if err != nil {
+		t.Fatalf("SaveSegmentTranslations() error = %v", err)
+	}
+	translations, err := db.GetSegmentTranslations(1, "en")
+	if err != nil || len(translations) != 2 || translations[0].Text != "Hello." || translations[1].Source != "Wie geht's?" {
+		t.Errorf("GetSegmentTranslations() = %+v, %v", translations, err)
+	}
+
+	for _, c := range []model.ReviewCard{
+		{TranscriptionID: 1, Position: 1, Language: "en", Text: "Wie geht's?", Ease: 2.5, DueAt: now.Add(time.Hour), MarkedAt: now},
+		{TranscriptionID: 1, Position: 0, Language: "en", Text: "Hallo.", Ease: 2.5, DueAt: now.Add(2 * time.Hour), MarkedAt: now},
+		{TranscriptionID: 1, Position: 0, Language: "en", Text: "Hallo.", IntervalDays: 1, Ease: 2.5, Repetitions: 1, DueAt: now, MarkedAt: now},
+		{TranscriptionID: 2, Position: 0, Language: "en", Text: "Hola.", Ease: 2.5, DueAt: now, MarkedAt: now},
+	} {
+		if err = db.SaveReviewCard(c); err != nil {
+			t.Fatalf("SaveReviewCard() error = %v", err)
+		}
+	}
+	if err = db.DeleteReviewCard(1, 5); err != nil {
+		t.Errorf("DeleteReviewCard() of an unmarked segment error = %v", err)
+	}
+	cards, err := db.GetReviewCards("alice")
+	if err != nil || len(cards) != 2 || cards[0].Position != 0 || cards[0].Repetitions != 1 || !cards[0].DueAt.Equal(now) {
+		t.Fatalf("GetReviewCards() = %+v, %v, want the replaced card due first", cards, err)
+	}
+	if err = db.DeleteReviewCard(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if cards, _ = db.GetReviewCards("alice"); len(cards) != 1 || cards[0].Position != 1 {
+		t.Errorf("GetReviewCards() after DeleteReviewCard() = %+v", cards)
+	}
+}
+
+func TestSQLiteDB_SpeechStats(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	db.RecordToDB("alice", "/in", "late.mp4", "late.mp3", 60, "Um, hi.", now.Add(24*time.Hour), 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "early.mp4", "early.mp3", 60, "Hi.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "b.mp4", "b.mp3", 60, "Hola.", now, 0, "", model.ProviderMetadata{})
+
+	for _, s := range []model.SpeechStats{
+		{TranscriptionID: 1, Version: 1, SpeechSeconds: 50, Words: 2, FillerWords: 1, ComputedAt: now},
+		{TranscriptionID: 2, Version: 1, SpeechSeconds: 55, Words: 1, ComputedAt: now},
+		{TranscriptionID: 1, Version: 2, SpeechSeconds: 58, Words: 2, FillerWords: 1, LongestMonologue: 30.5, Speakers: 2, Interruptions: 3, ComputedAt: now},
+		{TranscriptionID: 3, Version: 2, SpeechSeconds: 40, Words: 1, ComputedAt: now},
+	} {
+		if err := db.SaveSpeechStats(s); err != nil {
+			t.Fatalf("SaveSpeechStats() error = %v", err)
+		}
+	}
+
+	stats, err := db.GetSpeechStats("alice")
+	if err != nil || len(stats) != 2 {
+		t.Fatalf("GetSpeechStats() = %+v, %v, want the two of alice", stats, err)
+	}
+	if stats[0].FileName != "early.mp3" || !stats[0].Date.Equal(now) {
+		t.Errorf("GetSpeechStats()[0] = %+v, want the earliest transcription first", stats[0])
+	}
+	if got := stats[1]; got.Version != 2 || got.LongestMonologue != 30.5 || got.Speakers != 2 || got.Interruptions != 3 {
+		t.Errorf("GetSpeechStats()[1] = %+v, want the replaced stats", got)
+	}
+
+	if err = db.DeleteTranscription(1); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ = db.GetSpeechStats("alice"); len(stats) != 1 {
+		t.Errorf("GetSpeechStats() after DeleteTranscription() = %+v", stats)
+	}
+}
+
+func TestSQLiteDB_Insights(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "Hi Bob.", now, 0, "", model.ProviderMetadata{})
+
+	if got, err := db.GetInsights(1); err != nil || !got.Empty() {
+		t.Errorf("GetInsights() = %+v, %v, want none before they are saved", got, err)
+	}
+	insights := model.Insights{
+		Chapters: []model.Chapter{{Start: 0, End: 30, Headline: "Greetings", Gist: "hello", Summary: "Alice greets Bob."}, {Start: 30, End: 60, Headline: "Goodbye"}},
+		Entities: []model.Entity{{Type: "person_name", Text: "Bob", Start: 0.5, End: 0.9}},
+		Sentiments: []model.Sentiment{
+			{Start: 0, End: 1, Speaker: "A", Text: "Hi Bob.", Sentiment: model.SentimentPositive, Confidence: 0.8},
+		},
+	}
+	if err := db.SaveInsights(1, model.Insights{Chapters: []model.Chapter{{Headline: "replaced"}}}); err != nil {
+		t.Fatalf("SaveInsights() error = %v", err)
+	}
+	if err := db.SaveInsights(1, insights); err != nil {
+		t.Fatalf("SaveInsights() error = %v", err)
+	}
+	if got, err := db.GetInsights(1); err != nil || !reflect.DeepEqual(got, insights) {
+		t.Errorf("GetInsights() = %+v, %v, want %+v", got, err, insights)
+	}
+
+	if err := db.DeleteTranscription(1); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetInsights(1); !got.Empty() {
+		t.Errorf("GetInsights() after DeleteTranscription() = %+v", got)
+	}
+}
+
+func TestSQLiteDB_Embeddings(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "first", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 60, "", now, 1, "decoding failed", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 60, "third", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "d.mp4", "d.mp3", 60, "fourth", now, 0, "", model.ProviderMetadata{})
+
+	ids := func(transcriptions []model.Transcription) []int {
+		ids := []int{}
+		for _, t := range transcriptions {
+			ids = append(ids, t.ID)
+		}
+		return ids
+	}
+	if got, err := db.GetTranscriptionsWithoutEmbeddings("m", 0, 2); err != nil || !reflect.DeepEqual(ids(got), []int{1, 3}) {
+		t.Errorf("GetTranscriptionsWithoutEmbeddings() = %v, %v, want the first two with text", ids(got), err)
+	}
+	if got, _ := db.GetTranscriptionsWithoutEmbeddings("m", 3, 0); !reflect.DeepEqual(ids(got), []int{4}) || got[0].Transcription != "fourth" {
+		t.Errorf("GetTranscriptionsWithoutEmbeddings() after 3 = %+v, want 4", got)
+	}
+
+	embedding := model.Embedding{TranscriptionID: 1, Model: "m", Vector: []float32{0.5, -1, 3.25}, EmbeddedAt: now.Add(time.Minute)}
+	if err := db.SaveEmbedding(model.Embedding{TranscriptionID: 1, Model: "m", Vector: []float32{9}, EmbeddedAt: now}); err != nil {
+		t.Fatalf("SaveEmbedding() error = %v", err)
+	}
+	if err := db.SaveEmbedding(embedding); err != nil {
+		t.Fatalf("SaveEmbedding() error = %v", err)
+	}
+	db.SaveEmbedding(model.Embedding{TranscriptionID: 3, Model: "other", Vector: []float32{1}, EmbeddedAt: now})
+	if got, err := db.GetEmbedding(1, "m"); err != nil || !reflect.DeepEqual(got.Vector, embedding.Vector) || !got.EmbeddedAt.Equal(embedding.EmbeddedAt) {
+		t.Errorf("GetEmbedding() = %+v, %v, want %+v", got, err, embedding)
+	}
+	if _, err := db.GetEmbedding(3, "m"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetEmbedding() of another model error = %v, want sql.ErrNoRows", err)
+	}
+	if got, _ := db.GetTranscriptionsWithoutEmbeddings("m", 0, 0); !reflect.DeepEqual(ids(got), []int{3, 4}) {
+		t.Errorf("GetTranscriptionsWithoutEmbeddings() = %v, want 3 and 4", ids(got))
+	}
+	if got, err := db.GetEmbeddingProgress("m"); err != nil || got != (model.EmbeddingProgress{Model: "m", Embedded: 1, Pending: 2}) {
+		t.Errorf("GetEmbeddingProgress() = %+v, %v", got, err)
+	}
+
+	if got, err := db.GetIndexModel(); err != nil || got != "" {
+		t.Errorf("GetIndexModel() = %q, %v, want none before one is set", got, err)
+	}
+	if err := db.SetIndexModel("other", false, now); err != nil {
+		t.Fatalf("SetIndexModel() error = %v", err)
+	}
+	if err := db.SetIndexModel("m", true, now.Add(time.Minute)); err != nil {
+		t.Fatalf("SetIndexModel() error = %v", err)
+	}
+	if got, err := db.GetIndexModel(); err != nil || got != "m" {
+		t.Errorf("GetIndexModel() = %q, %v, want m", got, err)
+	}
+	if _, err := db.GetEmbedding(3, "other"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetEmbedding() of a dropped model error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := db.GetEmbedding(1, "m"); err != nil {
+		t.Errorf("GetEmbedding() of the index model error = %v", err)
+	}
+
+	if _, err := db.AddRevision(1, "first, corrected", model.ProviderMetadata{}, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetTranscriptionsWithoutEmbeddings("m", 0, 1); !reflect.DeepEqual(ids(got), []int{1}) {
+		t.Errorf("GetTranscriptionsWithoutEmbeddings() after a revision = %v, want the stale 1", ids(got))
+	}
+
+	if err := db.DeleteTranscription(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetEmbedding(1, "m"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetEmbedding() after DeleteTranscription() error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLiteDB_Duplicates(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "hello", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "a2.mp4", "a2.mp3", 60, "hello", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "a3.mp4", "a3.mp3", 60, "hello!", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "b.mp4", "b.mp3", 60, "hi", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "b2.mp4", "b2.mp3", 60, "hi", now, 0, "", model.ProviderMetadata{})
+
+	if err := db.ReplaceDuplicates("bob", []model.Duplicate{{TranscriptionID: 5, DuplicateOf: 4, Similarity: 1, TextSimilarity: 1, DetectedAt: now}}); err != nil {
+		t.Fatalf("ReplaceDuplicates() error = %v", err)
+	}
+	db.ReplaceDuplicates("alice", []model.Duplicate{{TranscriptionID: 2, DuplicateOf: 1, Similarity: 0.5, DetectedAt: now}})
+	duplicates := []model.Duplicate{
+		{TranscriptionID: 3, DuplicateOf: 1, Similarity: 0.96, EmbeddingSimilarity: 0.96, TextSimilarity: 0.9, DetectedAt: now},
+		{TranscriptionID: 2, DuplicateOf: 1, Similarity: 1, EmbeddingSimilarity: 0.99, TextSimilarity: 1, DetectedAt: now},
+	}
+	if err := db.ReplaceDuplicates("alice", duplicates); err != nil {
+		t.Fatalf("ReplaceDuplicates() error = %v", err)
+	}
+
+	got, err := db.GetDuplicates("alice")
+	if err != nil {
+		t.Fatalf("GetDuplicates() error = %v", err)
+	}
+	want := []model.Duplicate{duplicates[1], duplicates[0]}
+	want[0].FileName, want[0].DuplicateFileName = "a2.mp3", "a.mp3"
+	want[1].FileName, want[1].DuplicateFileName = "a3.mp3", "a.mp3"
+	for i := range got {
+		if !got[i].DetectedAt.Equal(want[i].DetectedAt) {
+			t.Errorf("GetDuplicates()[%d].DetectedAt = %v, want %v", i, got[i].DetectedAt, want[i].DetectedAt)
+		}
+		got[i].DetectedAt = want[i].DetectedAt
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetDuplicates() = %+v, want the most similar first %+v", got, want)
+	}
+	if got, _ := db.GetDuplicates("bob"); len(got) != 1 {
+		t.Errorf("GetDuplicates(bob) = %+v, want the duplicate of bob kept", got)
+	}
+
+	if err := db.DeleteTranscription(1); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetDuplicates("alice"); len(got) != 0 {
+		t.Errorf("GetDuplicates() after deleting the original = %+v, want none", got)
+	}
+}
+
+func TestSQLiteDB_RefineJobs(t *testing.T) {
+	db := newTestDB(t)
+	queued := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, id := range []int{2, 1} {
+		job := model.RefineJob{TranscriptionID: id, AudioPath: "/mp3/a.mp3", Status: model.RefinePending, QueuedAt: queued.Add(time.Duration(i) * time.Minute)}
+		if err := db.QueueRefine(job); err != nil {
+			t.Fatalf("QueueRefine() error = %v", err)
+		}
+	}
+	if err := db.SetRefineStatus(2, model.RefineFailed, "decoding failed", queued.Add(time.Hour)); err != nil {
+		t.Fatalf("SetRefineStatus() error = %v", err)
+	}
+	if err := db.SetRefineStatus(3, model.RefineDone, "", queued); err == nil {
+		t.Error("SetRefineStatus() of an unqueued transcription succeeded, want an error")
+	}
+
+	jobs, err := db.GetRefineJobs("")
+	if err != nil {
+		t.Fatalf("GetRefineJobs() error = %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].TranscriptionID != 2 || jobs[0].Status != model.RefineFailed || jobs[0].Error != "decoding failed" {
+		t.Errorf("GetRefineJobs() = %+v, want both jobs oldest first", jobs)
+	}
+	if pending, _ := db.GetRefineJobs(model.RefinePending); len(pending) != 1 || pending[0].TranscriptionID != 1 {
+		t.Errorf("GetRefineJobs(pending) = %+v, want job 1", pending)
+	}
+
+	// Queueing again resets the job
+	if err = db.QueueRefine(model.RefineJob{TranscriptionID: 2, AudioPath: "/mp3/b.mp3", Status: model.RefinePending, QueuedAt: queued}); err != nil {
+		t.Fatalf("QueueRefine() again error = %v", err)
+	}
+	if pending, _ := db.GetRefineJobs(model.RefinePending); len(pending) != 2 || pending[0].AudioPath != "/mp3/b.mp3" || pending[0].Error != "" {
+		t.Errorf("GetRefineJobs(pending) = %+v, want job 2 requeued", pending)
+	}
+}
+
+func TestSQLiteDB_Metadata(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.SetMetadata(1, map[string]string{"language": "en", "collection": "inbox"}); err != nil {
+		t.Fatalf("SetMetadata() error = %v", err)
+	}
+	if err := db.SetMetadata(1, map[string]string{"language": "zh", "collection": ""}); err != nil {
+		t.Fatalf("SetMetadata() error = %v", err)
+	}
+
+	got, err := db.GetMetadata(1)
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+	if want := map[string]string{"language": "zh"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMetadata() = %v, want %v", got, want)
+	}
+	if got, _ = db.GetMetadata(2); len(got) != 0 {
+		t.Errorf("GetMetadata() without metadata = %v, want none", got)
+	}
+}
+
+func TestSQLiteDB_ListByUser(t *testing.T) {
+	sdb := newTestDB(t)
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	sdb.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 30, "a", now, 0, "", model.ProviderMetadata{})
+	sdb.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 10, "b", now.Add(time.Hour), 0, "", model.ProviderMetadata{})
+	sdb.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 20, "c", now.Add(-time.Hour), 0, "", model.ProviderMetadata{})
+	sdb.RecordToDB("alice", "/in", "d.mp4", "d.mp3", 40, "", now, 1, "boom", model.ProviderMetadata{})
+	sdb.RecordToDB("bob", "/in", "e.mp4", "e.mp3", 50, "e", now, 0, "", model.ProviderMetadata{})
+
+	tests := []struct {
+		name    string
+		opts    repository.ListOptions
+		wantIDs []int
+		wantErr bool
+	}{
+		{name: "all newest first", wantIDs: []int{2, 1, 3}},
+		{name: "page", opts: repository.ListOptions{Limit: 2, Offset: 1}, wantIDs: []int{1, 3}},
+		{name: "past the end", opts: repository.ListOptions{Offset: 3}, wantIDs: []int{}},
+		{name: "shortest first", opts: repository.ListOptions{SortBy: repository.SortByDuration, Ascending: true}, wantIDs: []int{2, 3, 1}},
+		{name: "by file", opts: repository.ListOptions{SortBy: repository.SortByFile, Limit: 1}, wantIDs: []int{3}},
+		{name: "unknown field", opts: repository.ListOptions{SortBy: "user"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sdb.ListByUser("alice", tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListByUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			ids := make([]int, 0, len(got))
+			for _, tr := range got {
+				ids = append(ids, tr.ID)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ListByUser() ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestSQLiteDB_UpdateDelete(t *testing.T) {
+	sdb := newTestDB(t)
+	sdb.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 30, "hello", time.Now(), 0, "", model.ProviderMetadata{})
+	sdb.SaveSegments(1, []model.Segment{{Start: 0, End: 1, Text: "hello", Words: []model.Word{{Text: "hello"}}}})
+	sdb.SetMetadata(1, map[string]string{"collection": "inbox"})
+	sdb.RecordCost(model.CostEntry{TranscriptionID: 1, FilePath: "a.mp3", Cost: 1, RecordedAt: time.Now()})
+
+	tr, _ := sdb.GetByID(1)
+	tr.Transcription = "hello world"
+	tr.User = "bob"
+	tr.ProviderMetadata.Provider = "openai"
+	if err := sdb.UpdateTranscription(*tr); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := sdb.GetByID(1); err != nil || got.Transcription != "hello world" || got.User != "bob" || got.ProviderMetadata.Provider != "openai" {
+		t.Errorf("GetByID() after update = %+v, %v", got, err)
+	}
+	tr.ID = 2
+	if err := sdb.UpdateTranscription(*tr); err == nil {
+		t.Error("UpdateTranscription() of a missing transcription succeeded")
+	}
+
+	if err := sdb.DeleteTranscription(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sdb.GetByID(1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID() after delete error = %v, want sql.ErrNoRows", err)
+	}
+	var words int
+	sdb.db.QueryRow(`SELECT count(*) FROM transcription_words;`).Scan(&words)
+	if segments, _ := sdb.GetSegments(1); len(segments) != 0 || words != 0 {
+		t.Errorf("segments after delete = %+v with %d words, want none", segments, words)
+	}
+	if values, _ := sdb.GetMetadata(1); len(values) != 0 {
+		t.Errorf("GetMetadata() after delete = %v, want none", values)
+	}
+	if costs, _ := sdb.GetCosts(time.Time{}); len(costs) != 1 {
+		t.Errorf("GetCosts() after delete = %+v, want the cost kept", costs)
+	}
+	if err := sdb.DeleteTranscription(1); err == nil {
+		t.Error("DeleteTranscription() of a deleted transcription succeeded")
+	}
+}
+
+func TestSQLiteDB_SearchTranscriptions(t *testing.T) {
+	sdb := newTestDB(t)
+	if !sdb.fts {
+		if _, err := sdb.SearchTranscriptions("whisper", "alice", 0); !errors.Is(err, repository.ErrNoFullTextIndex) {
+			t.Errorf("SearchTranscriptions() without FTS5 error = %v, want ErrNoFullTextIndex", err)
+		}
+		t.Skip("go-sqlite3 is built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+
+	now := time.Now()
+	sdb.RecordToDB("alice", "/in", "whisper_talk.mp4", "whisper_talk.mp3", 60, "We compare models on long podcasts.", now, 0, "", model.ProviderMetadata{})
+	sdb.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 120, "Whisper models for cooking.", now, 0, "", model.ProviderMetadata{})
+	sdb.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 30, "语音识别模型", now, 0, "", model.ProviderMetadata{})
+	sdb.RecordToDB("alice", "/in", "d.mp4", "d.mp3", 0, "", now, 1, "whisper failed", model.ProviderMetadata{})
+	sdb.RecordToDB("bob", "/in", "e.mp4", "e.mp3", 30, "Whisper models for bob.", now, 0, "", model.ProviderMetadata{})
+
+	tests := []struct {
+		name      string
+		query     string
+		limit     int
+		wantIDs   []int
+		wantCount int
+	}{
+		{name: "text and file name", query: "Models WHISPER", wantIDs: []int{1, 2}},
+		{name: "limit", query: "whisper models", limit: 1, wantCount: 1},
+		{name: "one word missing", query: "whisper bob", wantIDs: []int{}},
+		{name: "han characters", query: "识别", wantIDs: []int{3}},
+		{name: "operators are words", query: "whisper OR bob", wantIDs: []int{}},
+		{name: "no words", query: "?!", wantIDs: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sdb.SearchTranscriptions(tt.query, "alice", tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int, 0, len(got))
+			for _, tr := range got {
+				ids = append(ids, tr.ID)
+			}
+			sort.Ints(ids)
+			if tt.limit > 0 && len(ids) != tt.wantCount || tt.limit == 0 && !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("SearchTranscriptions(%q) ids = %v, want %v", tt.query, ids, tt.wantIDs)
+			}
+		})
+	}
+
+	// The index follows changes of the text
+	tr, _ := sdb.GetByID(3)
+	tr.Transcription = "cooking podcasts"
+	sdb.UpdateTranscription(*tr)
+	sdb.DeleteTranscription(2)
+	if got, _ := sdb.SearchTranscriptions("cooking", "alice", 0); len(got) != 1 || got[0].ID != 3 {
+		t.Errorf("SearchTranscriptions() after update and delete = %+v, want transcription 3", got)
+	}
+}
@@ -0,0 +1,312 @@
+// Package review is the language-learning mode: the segments of a transcription are read next to
+// their translations, and the segments worth learning are marked for spaced repetition and exported
+// to Anki.
+package review
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/paragraph"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/translation"
+	"time"
+)
+
+// ErrNotSupported is returned when the database doesn't keep segment translations and review cards.
+var ErrNotSupported = errors.New("the database does not keep the language-learning review")
+
+// ErrNotMarked is returned when a segment that isn't marked is answered.
+var ErrNotMarked = errors.New("the segment is not marked for review")
+
+// Grade is how well a card was remembered.
+type Grade string
+
+const (
+	Again Grade = "again"
+	Hard  Grade = "hard"
+	Good  Grade = "good"
+	Easy  Grade = "easy"
+)
+
+// Grades are the grades ParseGrade accepts, from the worst.
+var Grades = []Grade{Again, Hard, Good, Easy}
+
+// ParseGrade returns the grade named s.
+func ParseGrade(s string) (Grade, error) {
+	for _, g := range Grades {
+		if string(g) == strings.ToLower(s) {
+			return g, nil
+		}
+	}
+	return "", fmt.Errorf("unknown grade %q, supported: again, hard, good, easy", s)
+}
+
+const (
+	// initialEase and minEase bound the factor a good answer multiplies the interval with.
+	initialEase = 2.5
+	minEase     = 1.3
+	// relearnDelay is when a forgotten card is shown again.
+	relearnDelay = 10 * time.Minute
+)
+
+// Schedule answers card with grade at now and returns it with its next due date, the intervals
+// follow SM-2: a forgotten card starts over, a good one is due after a day, six days and then the
+// interval times its ease.
+func Schedule(card model.ReviewCard, grade Grade, now time.Time) model.ReviewCard {
+	if card.Ease == 0 {
+		card.Ease = initialEase
+	}
+
+	switch grade {
+	case Again:
+		card.Repetitions = 0
+		card.IntervalDays = 0
+		card.Ease = math.Max(minEase, card.Ease-0.2)
+		card.DueAt = now.Add(relearnDelay)
+		return card
+	case Hard:
+		card.IntervalDays = int(math.Max(1, math.Round(float64(card.IntervalDays)*1.2)))
+		card.Ease = math.Max(minEase, card.Ease-0.15)
+	default:
+		switch card.Repetitions {
+		case 0:
+			card.IntervalDays = 1
+		case 1:
+			card.IntervalDays = 6
+		default:
+			card.IntervalDays = int(math.Round(float64(card.IntervalDays) * card.Ease))
+		}
+		if grade == Easy {
+			card.IntervalDays = int(math.Round(float64(card.IntervalDays) * 1.3))
+			card.Ease += 0.15
+		}
+	}
+	card.Repetitions++
+	card.DueAt = now.AddDate(0, 0, card.IntervalDays)
+	return card
+}
+
+// Item is a segment of a transcription in the review.
+type Item struct {
+	Position int
+	model.Segment
+	// Translation is empty until the segment is translated, or once its text changed.
+	Translation string
+	// Card is set when the segment is marked.
+	Card *model.ReviewCard
+}
+
+// Due reports whether the item is marked and due at now.
+func (i Item) Due(now time.Time) bool {
+	return i.Card != nil && !i.Card.DueAt.After(now)
+}
+
+func reviewDAO(db repository.TranscriptionDAO) (repository.ReviewDAO, error) {
+	dao, ok := db.(repository.ReviewDAO)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return dao, nil
+}
+
+// Items returns the segments of t with their translations into language and their cards, the
+// segments of t have to be loaded, a transcription without segments is reviewed sentence by sentence.
+func Items(db repository.TranscriptionDAO, t model.Transcription, language string) ([]Item, error) {
+	dao, err := reviewDAO(db)
+	if err != nil {
+		return nil, err
+	}
+	translations, err := dao.GetSegmentTranslations(t.ID, language)
+	if err != nil {
+		return nil, fmt.Errorf("get segment translations of %d failed: %v", t.ID, err)
+	}
+	cards, err := cardsOf(dao, t)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := paragraph.Segments(t)
+	items := make([]Item, len(segments))
+	for i, s := range segments {
+		items[i] = Item{Position: i, Segment: s}
+		if tr, ok := translations[i]; ok && tr.Source == s.Text {
+			items[i].Translation = tr.Text
+		}
+		if card, ok := cards[i]; ok {
+			card := card
+			items[i].Card = &card
+		}
+	}
+	return items, nil
+}
+
+// cardsOf returns the cards of t by position.
+func cardsOf(dao repository.ReviewDAO, t model.Transcription) (map[int]model.ReviewCard, error) {
+	cards, err := dao.GetReviewCards(t.User)
+	if err != nil {
+		return nil, fmt.Errorf("get review cards of %s failed: %v", t.User, err)
+	}
+	byPosition := make(map[int]model.ReviewCard)
+	for _, c := range cards {
+		if c.TranscriptionID == t.ID {
+			byPosition[c.Position] = c
+		}
+	}
+	return byPosition, nil
+}
+
+// Translate translates the segments of t into language one by one and stores the translations,
+// segments translated before are only translated again when their text changed. It returns how many
+// segments were translated.
+func Translate(db repository.TranscriptionDAO, tr translation.Translator, t model.Transcription, language string) (int, error) {
+	dao, err := reviewDAO(db)
+	if err != nil {
+		return 0, err
+	}
+	if language == "" {
+		return 0, errors.New("no target language")
+	}
+	stored, err := dao.GetSegmentTranslations(t.ID, language)
+	if err != nil {
+		return 0, fmt.Errorf("get segment translations of %d failed: %v", t.ID, err)
+	}
+
+	var translated []model.SegmentTranslation
+	for i, s := range paragraph.Segments(t) {
+		if strings.TrimSpace(s.Text) == "" {
+			continue
+		}
+		if previous, ok := stored[i]; ok && previous.Source == s.Text {
+			continue
+		}
+		text, err := translation.Translate(tr, s.Text, language)
+		if err != nil {
+			// Keep what was translated so far, the next run goes on from there.
+			if saveErr := dao.SaveSegmentTranslations(translated); saveErr != nil {
+				return 0, saveErr
+			}
+			return len(translated), fmt.Errorf("translate segment %d of %d failed: %w", i, t.ID, err)
+		}
+		translated = append(translated, model.SegmentTranslation{
+			TranscriptionID: t.ID,
+			Position:        i,
+			Language:        language,
+			Backend:         tr.Name(),
+			Source:          s.Text,
+			Text:            text,
+			CreatedAt:       time.Now(),
+		})
+	}
+	if err = dao.SaveSegmentTranslations(translated); err != nil {
+		return 0, fmt.Errorf("save segment translations of %d failed: %v", t.ID, err)
+	}
+	return len(translated), nil
+}
+
+// Mark marks the segment at position of t for review with its translation into language, the card
+// is due at once. A marked segment keeps its schedule and takes the current text, and the current
+// translation when there is one.
+func Mark(db repository.TranscriptionDAO, t model.Transcription, position int, language string, now time.Time) (model.ReviewCard, error) {
+	dao, err := reviewDAO(db)
+	if err != nil {
+		return model.ReviewCard{}, err
+	}
+	items, err := Items(db, t, language)
+	if err != nil {
+		return model.ReviewCard{}, err
+	}
+	if position < 0 || position >= len(items) {
+		return model.ReviewCard{}, fmt.Errorf("transcription %d has no segment %d", t.ID, position)
+	}
+
+	item := items[position]
+	card := model.ReviewCard{Ease: initialEase, DueAt: now, MarkedAt: now}
+	if item.Card != nil {
+		card = *item.Card
+	}
+	card.TranscriptionID = t.ID
+	card.Position = position
+	card.Language = language
+	card.Text = item.Text
+	if item.Translation != "" || item.Card == nil {
+		card.Translation = item.Translation
+	}
+	if err = dao.SaveReviewCard(card); err != nil {
+		return model.ReviewCard{}, fmt.Errorf("save review card failed: %v", err)
+	}
+	return card, nil
+}
+
+// Answer schedules the card of the segment at position of t with grade.
+func Answer(db repository.TranscriptionDAO, t model.Transcription, position int, grade Grade, now time.Time) (model.ReviewCard, error) {
+	dao, err := reviewDAO(db)
+	if err != nil {
+		return model.ReviewCard{}, err
+	}
+	cards, err := cardsOf(dao, t)
+	if err != nil {
+		return model.ReviewCard{}, err
+	}
+	card, ok := cards[position]
+	if !ok {
+		return model.ReviewCard{}, ErrNotMarked
+	}
+
+	card = Schedule(card, grade, now)
+	if err = dao.SaveReviewCard(card); err != nil {
+		return model.ReviewCard{}, fmt.Errorf("save review card failed: %v", err)
+	}
+	return card, nil
+}
+
+// Unmark removes the segment at position of t from the review.
+func Unmark(db repository.TranscriptionDAO, t model.Transcription, position int) error {
+	dao, err := reviewDAO(db)
+	if err != nil {
+		return err
+	}
+	return dao.DeleteReviewCard(t.ID, position)
+}
+
+// Cards returns the cards of user, only those due at now when due is set.
+func Cards(db repository.TranscriptionDAO, user string, due bool, now time.Time) ([]model.ReviewCard, error) {
+	dao, err := reviewDAO(db)
+	if err != nil {
+		return nil, err
+	}
+	cards, err := dao.GetReviewCards(user)
+	if err != nil {
+		return nil, fmt.Errorf("get review cards of %s failed: %v", user, err)
+	}
+	if !due {
+		return cards, nil
+	}
+	var dueCards []model.ReviewCard
+	for _, c := range cards {
+		if !c.DueAt.After(now) {
+			dueCards = append(dueCards, c)
+		}
+	}
+	return dueCards, nil
+}
+
+// Notes turns cards into Anki notes, the original on the front and the translation on the back,
+// tagged with the user and the file the segment comes from, names holds the file by transcription.
+func Notes(user string, cards []model.ReviewCard, names map[int]string) []export.AnkiNote {
+	notes := make([]export.AnkiNote, 0, len(cards))
+	for _, c := range cards {
+		tags := []string{"v2t", user}
+		if name, ok := names[c.TranscriptionID]; ok {
+			tags = append(tags, name)
+		}
+		if c.Language != "" {
+			tags = append(tags, "v2t::"+c.Language)
+		}
+		notes = append(notes, export.AnkiNote{Front: c.Text, Back: c.Translation, Tags: tags})
+	}
+	return notes
+}
@@ -0,0 +1,138 @@
+package review
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+// upperTranslator "translates" by upper casing, it fails on text containing fail.
+type upperTranslator struct {
+	calls int
+}
+
+func (u *upperTranslator) Name() string { return "upper" }
+
+func (u *upperTranslator) Translate(text string, target string) (string, error) {
+	u.calls++
+	if strings.Contains(text, "fail") {
+		return "", errors.New("backend down")
+	}
+	return strings.ToUpper(text), nil
+}
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		card            model.ReviewCard
+		grade           Grade
+		wantInterval    int
+		wantRepetitions int
+		wantEase        float64
+		wantDue         time.Time
+	}{
+		{name: "first good", card: model.ReviewCard{Ease: 2.5}, grade: Good,
+			wantInterval: 1, wantRepetitions: 1, wantEase: 2.5, wantDue: now.AddDate(0, 0, 1)},
+		{name: "second good", card: model.ReviewCard{Ease: 2.5, IntervalDays: 1, Repetitions: 1}, grade: Good,
+			wantInterval: 6, wantRepetitions: 2, wantEase: 2.5, wantDue: now.AddDate(0, 0, 6)},
+		{name: "good multiplies by the ease", card: model.ReviewCard{Ease: 2.5, IntervalDays: 6, Repetitions: 2}, grade: Good,
+			wantInterval: 15, wantRepetitions: 3, wantEase: 2.5, wantDue: now.AddDate(0, 0, 15)},
+		{name: "easy", card: model.ReviewCard{Ease: 2.5, IntervalDays: 6, Repetitions: 2}, grade: Easy,
+			wantInterval: 20, wantRepetitions: 3, wantEase: 2.65, wantDue: now.AddDate(0, 0, 20)},
+		{name: "hard", card: model.ReviewCard{Ease: 2.5, IntervalDays: 10, Repetitions: 3}, grade: Hard,
+			wantInterval: 12, wantRepetitions: 4, wantEase: 2.35, wantDue: now.AddDate(0, 0, 12)},
+		{name: "again starts over", card: model.ReviewCard{Ease: 2.5, IntervalDays: 10, Repetitions: 3}, grade: Again,
+			wantInterval: 0, wantRepetitions: 0, wantEase: 2.3, wantDue: now.Add(relearnDelay)},
+		{name: "ease stays above the minimum", card: model.ReviewCard{Ease: 1.35}, grade: Again,
+			wantInterval: 0, wantRepetitions: 0, wantEase: minEase, wantDue: now.Add(relearnDelay)},
+		{name: "card without ease", card: model.ReviewCard{}, grade: Good,
+			wantInterval: 1, wantRepetitions: 1, wantEase: initialEase, wantDue: now.AddDate(0, 0, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Schedule(tt.card, tt.grade, now)
+			if got.IntervalDays != tt.wantInterval || got.Repetitions != tt.wantRepetitions || !got.DueAt.Equal(tt.wantDue) {
+				t.Errorf("Schedule() = interval %d, repetitions %d, due %v, want %d, %d, %v",
+					got.IntervalDays, got.Repetitions, got.DueAt, tt.wantInterval, tt.wantRepetitions, tt.wantDue)
+			}
+			if diff := got.Ease - tt.wantEase; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Schedule() ease = %v, want %v", got.Ease, tt.wantEase)
+			}
+		})
+	}
+}
+
+func TestReview(t *testing.T) {
+	db := memory.NewMemoryDB()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "Hello there. This will fail. Bye.", now, 0, "", model.ProviderMetadata{})
+	tr := model.Transcription{ID: 1, User: "alice", Mp3FileName: "a.mp3", Segments: []model.Segment{
+		{Start: 0, End: 2, Text: "Hello there."},
+		{Start: 2, End: 4, Text: "This will fail."},
+		{Start: 4, End: 5, Text: "Bye."},
+	}}
+
+	translator := &upperTranslator{}
+	n, err := Translate(db, translator, tr, "en")
+	if err == nil || n != 1 {
+		t.Fatalf("Translate() = %d, %v, want the first segment and the error of the second", n, err)
+	}
+	tr.Segments[1].Text = "This works."
+	translator.calls = 0
+	if n, err = Translate(db, translator, tr, "en"); err != nil || n != 2 || translator.calls != 2 {
+		t.Fatalf("Translate() again = %d, %v with %d calls, want the two missing segments", n, err, translator.calls)
+	}
+
+	tr.Segments[2].Text = "Goodbye."
+	items, err := Items(db, tr, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTranslations := []string{"HELLO THERE.", "THIS WORKS.", ""}
+	for i, item := range items {
+		if item.Translation != wantTranslations[i] {
+			t.Errorf("item %d translation = %q, want %q", i, item.Translation, wantTranslations[i])
+		}
+	}
+
+	card, err := Mark(db, tr, 1, "en", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.Text != "This works." || card.Translation != "THIS WORKS." || !card.DueAt.Equal(now) {
+		t.Errorf("Mark() = %+v", card)
+	}
+	if _, err = Mark(db, tr, 3, "en", now); err == nil {
+		t.Error("Mark() of a segment that doesn't exist succeeded")
+	}
+	if _, err = Answer(db, tr, 0, Good, now); !errors.Is(err, ErrNotMarked) {
+		t.Errorf("Answer() of an unmarked segment error = %v, want ErrNotMarked", err)
+	}
+	if card, err = Answer(db, tr, 1, Good, now); err != nil || card.IntervalDays != 1 {
+		t.Fatalf("Answer() = %+v, %v", card, err)
+	}
+
+	if due, _ := Cards(db, "alice", true, now); len(due) != 0 {
+		t.Errorf("Cards(due) = %v, want none before the interval passed", due)
+	}
+	cards, err := Cards(db, "alice", true, now.AddDate(0, 0, 1))
+	if err != nil || len(cards) != 1 {
+		t.Fatalf("Cards(due) a day later = %v, %v", cards, err)
+	}
+	notes := Notes("alice", cards, map[int]string{1: "a.mp3"})
+	if len(notes) != 1 || notes[0].Front != "This works." || notes[0].Back != "THIS WORKS." ||
+		strings.Join(notes[0].Tags, " ") != "v2t alice a.mp3 v2t::en" {
+		t.Errorf("Notes() = %+v", notes)
+	}
+
+	if err = Unmark(db, tr, 1); err != nil {
+		t.Fatal(err)
+	}
+	if cards, _ = Cards(db, "alice", false, now); len(cards) != 0 {
+		t.Errorf("Cards() after Unmark() = %v", cards)
+	}
+}
@@ -0,0 +1,109 @@
+// Package schema defines the versioned JSON documents written for other programs and read back:
+// transcriptions, as exported with the json format and returned by the API, and API jobs.
+//
+// Every document carries schema_version. Readers accept documents of any version, fields are
+// only ever added to a kind, so fields a reader doesn't know are ignored, and documents written
+// before versioning, without schema_version, are version 1.
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+// Version is the schema version of the documents written by this build.
+const Version = 1
+
+// The kinds of documents, each has a JSON Schema per version.
+const (
+	KindTranscription = "transcription"
+	KindJob           = "job"
+)
+
+// ErrInvalid is returned for documents that don't match their schema.
+var ErrInvalid = errors.New("invalid document")
+
+//go:embed *.json
+var schemas embed.FS
+
+// JSONSchema returns the JSON Schema of kind at version, for consumers of the documents.
+func JSONSchema(kind string, version int) ([]byte, error) {
+	data, err := schemas.ReadFile(fmt.Sprintf("%s.v%d.json", kind, version))
+	if err != nil {
+		return nil, fmt.Errorf("no schema for %s version %d", kind, version)
+	}
+	return data, nil
+}
+
+// Transcription is a stored transcription with its segments.
+type Transcription struct {
+	SchemaVersion      int                    `json:"schema_version"`
+	ID                 int                    `json:"id"`
+	User               string                 `json:"user"`
+	FileName           string                 `json:"file_name"`
+	AudioDuration      float64                `json:"audio_duration"`
+	Language           string                 `json:"language,omitempty"`
+	LastConversionTime time.Time              `json:"last_conversion_time"`
+	Transcription      string                 `json:"transcription"`
+	ProviderMetadata   model.ProviderMetadata `json:"provider_metadata"`
+	// Segments are empty when the provider reported none.
+	Segments []model.Segment `json:"segments,omitempty"`
+}
+
+// NewTranscription returns the current version of the document of t.
+func NewTranscription(t model.Transcription) Transcription {
+	return Transcription{
+		SchemaVersion:      Version,
+		ID:                 t.ID,
+		User:               t.User,
+		FileName:           t.Mp3FileName,
+		AudioDuration:      t.AudioDuration,
+		Language:           t.ProviderMetadata.Language,
+		LastConversionTime: t.LastConversionTime,
+		Transcription:      t.Transcription,
+		ProviderMetadata:   t.ProviderMetadata,
+		Segments:           t.Segments,
+	}
+}
+
+// DecodeTranscription parses and validates a transcription document of any version.
+func DecodeTranscription(data []byte) (Transcription, error) {
+	var t Transcription
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if t.SchemaVersion == 0 {
+		t.SchemaVersion = 1
+	}
+	return t, t.Validate()
+}
+
+// Validate checks the fields the schema requires and that the segments are in order.
+func (t Transcription) Validate() error {
+	switch {
+	case t.SchemaVersion < 1:
+		return fmt.Errorf("%w: schema_version %d", ErrInvalid, t.SchemaVersion)
+	case t.User == "":
+		return fmt.Errorf("%w: user is missing", ErrInvalid)
+	case t.FileName == "":
+		return fmt.Errorf("%w: file_name is missing", ErrInvalid)
+	case t.AudioDuration < 0:
+		return fmt.Errorf("%w: negative audio_duration", ErrInvalid)
+	}
+
+	var previous float64
+	for i, s := range t.Segments {
+		if s.Start < 0 || s.End < s.Start {
+			return fmt.Errorf("%w: segment %d ends before it starts", ErrInvalid, i)
+		}
+		if s.Timed() && s.Start < previous {
+			return fmt.Errorf("%w: segment %d starts before the previous one", ErrInvalid, i)
+		}
+		previous = s.Start
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+func TestDecodeTranscription(t *testing.T) {
+	tests := []struct {
+		name        string
+		doc         string
+		wantVersion int
+		wantErr     error
+	}{
+		{
+			name:        "current",
+			doc:         `{"schema_version": 1, "id": 3, "user": "alice", "file_name": "talk.mp3", "audio_duration": 12, "transcription": "hi", "segments": [{"start": 0, "end": 1, "text": "hi"}]}`,
+			wantVersion: 1,
+		},
+		{
+			name:        "written before versioning",
+			doc:         `{"id": 3, "user": "alice", "file_name": "talk.mp3", "audio_duration": 12, "transcription": "hi"}`,
+			wantVersion: 1,
+		},
+		{
+			name:        "newer version",
+			doc:         `{"schema_version": 7, "user": "alice", "file_name": "talk.mp3", "transcription": "hi", "chapters": [{"title": "intro"}]}`,
+			wantVersion: 7,
+		},
+		{name: "missing user", doc: `{"schema_version": 1, "file_name": "talk.mp3"}`, wantErr: ErrInvalid},
+		{name: "negative version", doc: `{"schema_version": -1, "user": "alice", "file_name": "talk.mp3"}`, wantErr: ErrInvalid},
+		{
+			name:    "segment ends before it starts",
+			doc:     `{"user": "alice", "file_name": "talk.mp3", "segments": [{"start": 2, "end": 1, "text": "hi"}]}`,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "segments out of order",
+			doc:     `{"user": "alice", "file_name": "talk.mp3", "segments": [{"start": 2, "end": 3, "text": "b"}, {"start": 0, "end": 1, "text": "a"}]}`,
+			wantErr: ErrInvalid,
+		},
+		{name: "not json", doc: `<xml/>`, wantErr: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeTranscription([]byte(tt.doc))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeTranscription() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got.SchemaVersion != tt.wantVersion {
+				t.Errorf("DecodeTranscription() version = %d, want %d", got.SchemaVersion, tt.wantVersion)
+			}
+		})
+	}
+}
+
+// TestJSONSchema_Transcription checks that the written documents and the published schema agree.
+func TestJSONSchema_Transcription(t *testing.T) {
+	doc := NewTranscription(model.Transcription{
+		ID:                 3,
+		User:               "alice",
+		Mp3FileName:        "talk.mp3",
+		AudioDuration:      12,
+		LastConversionTime: time.Date(2024, 3, 9, 14, 5, 0, 0, time.UTC),
+		Transcription:      "hi",
+		ProviderMetadata:   model.ProviderMetadata{Provider: "whisper_cpp", Language: "en"},
+		Segments:           []model.Segment{{Start: 0, End: 1, Text: "hi"}},
+	})
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written map[string]interface{}
+	json.Unmarshal(data, &written)
+
+	checkSchema(t, KindTranscription, written)
+}
+
+func checkSchema(t *testing.T, kind string, written map[string]interface{}) {
+	t.Helper()
+	data, err := JSONSchema(kind, Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err = json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("schema of %s is not valid JSON: %v", kind, err)
+	}
+
+	for _, field := range s.Required {
+		if _, ok := written[field]; !ok {
+			t.Errorf("written %s lacks the required %s", kind, field)
+		}
+	}
+	for field := range written {
+		if _, ok := s.Properties[field]; !ok {
+			t.Errorf("written %s has %s, which its schema doesn't describe", kind, field)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"tiktok-whisper/internal/app/embedding"
+	"tiktok-whisper/internal/app/embedding/provider"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/textdiff"
+	"time"
+)
+
+const (
+	// rankOffset dampens the reciprocal ranks Hybrid fuses, so the first results of either search
+	// don't outweigh a transcription both searches rank well. 60 is the usual choice.
+	rankOffset = 60
+	// vectorCandidates is the number of transcriptions nearest to the query in the vector index that
+	// are ranked with the keyword matches.
+	vectorCandidates = 50
+)
+
+// Filter narrows the results to transcriptions made within a time range, whose audio lasts within
+// bounds. Zero values don't narrow.
+type Filter struct {
+	// From is the earliest time of the last conversion of a transcription.
+	From time.Time
+	// To is the time the last conversion must precede.
+	To time.Time
+	// MinDuration and MaxDuration bound the audio duration in seconds.
+	MinDuration float64
+	MaxDuration float64
+}
+
+// Match reports whether t passes the filter.
+func (f Filter) Match(t model.Transcription) bool {
+	switch {
+	case !f.From.IsZero() && t.LastConversionTime.Before(f.From):
+		return false
+	case !f.To.IsZero() && !t.LastConversionTime.Before(f.To):
+		return false
+	case f.MinDuration > 0 && t.AudioDuration < f.MinDuration:
+		return false
+	case f.MaxDuration > 0 && t.AudioDuration > f.MaxDuration:
+		return false
+	}
+	return true
+}
+
+// HybridOptions narrow a hybrid search.
+type HybridOptions struct {
+	// Limit is the most results to return, zero returns all of them.
+	Limit  int
+	Filter Filter
+}
+
+// HybridResult is a transcription found by the words of the query, by its meaning or both.
+type HybridResult struct {
+	Result
+	// Score ranks the results, the sum of the reciprocal ranks the transcription has in either search.
+	Score float64
+	// Keyword is set when the transcription contains every word of the query.
+	Keyword bool
+	// Similarity is the cosine similarity of the embeddings of the transcription and of the query,
+	// zero when the vector index didn't find it.
+	Similarity float64
+}
+
+// Hybrid searches the transcriptions of user both by the words of query, like Transcriptions, and by
+// its meaning among the transcriptions nearest to it in the vector index. The two rankings are fused
+// by their reciprocal ranks, so a transcription both find comes first. Without embedder, or when the
+// vector index can't be searched, e.g. before anything was embedded, only the words are searched.
+// Transcriptions whose embeddings are orthogonal or opposite to that of the query aren't found by it.
+// semantic reports whether the vector index was.
+func Hybrid(ctx context.Context, db repository.TranscriptionDAO, embedder provider.Embedder, user string, query string, opts HybridOptions) (results []HybridResult, semantic bool, err error) {
+	words := textdiff.Words(query)
+	if len(words) == 0 {
+		return nil, false, ErrEmptyQuery
+	}
+
+	keyword, err := Transcriptions(db, user, query, Options{})
+	if err != nil {
+		return nil, false, err
+	}
+	found := make(map[int]*HybridResult)
+	rank := 0
+	for _, r := range keyword {
+		if !opts.Filter.Match(r.Transcription) {
+			continue
+		}
+		rank++
+		found[r.ID] = &HybridResult{Result: r, Score: 1 / float64(rankOffset+rank), Keyword: true}
+	}
+
+	if embedder != nil {
+		matches, err := nearest(ctx, db, embedder, user, query)
+		if err != nil {
+			log.Printf("Searching %s by keywords only: %v\n", user, err)
+		} else {
+			semantic = true
+		}
+		rank = 0
+		for _, m := range matches {
+			if m.Similarity <= 0 || !opts.Filter.Match(m.Transcription) {
+				continue
+			}
+			if rank++; rank > vectorCandidates {
+				break
+			}
+			r, ok := found[m.Transcription.ID]
+			if !ok {
+				t := m.Transcription
+				r = &HybridResult{Result: Result{Transcription: t, Snippet: Snippet(t.Transcription, words[0], SnippetLength), Confidence: Confidence(t)}}
+				found[t.ID] = r
+			}
+			r.Score += 1 / float64(rankOffset+rank)
+			r.Similarity = m.Similarity
+		}
+	}
+
+	results = make([]HybridResult, 0, len(found))
+	for _, r := range found {
+		results = append(results, *r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID > results[j].ID
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, semantic, nil
+}
+
+// nearest returns the transcriptions of user in the vector index, the nearest to query first.
+func nearest(ctx context.Context, db repository.TranscriptionDAO, embedder provider.Embedder, user string, query string) ([]embedding.Match, error) {
+	vectors, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embed query failed: %v", err)
+	}
+	return embedding.Nearest(db, embedder.Name(), user, vectors[0], 0)
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+// meaningEmbedder embeds a text as the number of times it mentions the words of each meaning.
+type meaningEmbedder struct{}
+
+var meanings = [][]string{{"pricing", "cost", "price"}, {"weather", "rain"}}
+
+func (meaningEmbedder) Name() string    { return "fake/meanings" }
+func (meaningEmbedder) Dimensions() int { return len(meanings) }
+
+func (meaningEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, len(meanings))
+		for j, words := range meanings {
+			for _, w := range words {
+				vectors[i][j] += float32(strings.Count(strings.ToLower(text), w))
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func TestHybrid(t *testing.T) {
+	day := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		query        string
+		noEmbedder   bool
+		noIndex      bool
+		filter       Filter
+		want         []string
+		wantSemantic bool
+		wantErr      error
+	}{
+		{name: "both searches first", query: "pricing", want: []string{"plans.mp3", "costs.mp3"}, wantSemantic: true},
+		{name: "keywords only without embedder", query: "pricing", noEmbedder: true, want: []string{"plans.mp3"}},
+		{name: "keywords only without index", query: "pricing", noIndex: true, want: []string{"plans.mp3"}},
+		{name: "meaning without keywords", query: "rain", want: []string{"weather.mp3"}, wantSemantic: true},
+		{name: "date range", query: "pricing", filter: Filter{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 3)}, want: []string{"costs.mp3"}, wantSemantic: true},
+		{name: "duration", query: "pricing", filter: Filter{MinDuration: 100, MaxDuration: 150}, want: []string{"plans.mp3"}, wantSemantic: true},
+		{name: "no words", query: "?", wantErr: ErrEmptyQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemoryDB()
+			texts := []struct{ file, text string }{
+				{"plans.mp3", "Our pricing plans, pricing per month."},
+				{"costs.mp3", "What it will cost you."},
+				{"weather.mp3", "The weather today."},
+			}
+			for i, tr := range texts {
+				db.RecordToDB("alice", "/in", tr.file, tr.file, 60*(i+2), tr.text, day.AddDate(0, 0, 2*i), 0, "", model.ProviderMetadata{})
+				vectors, _ := meaningEmbedder{}.Embed(context.Background(), []string{tr.text})
+				db.SaveEmbedding(model.Embedding{TranscriptionID: i + 1, Model: "fake/meanings", Vector: vectors[0], EmbeddedAt: day})
+			}
+			db.RecordToDB("bob", "/in", "bob.mp3", "bob.mp3", 60, "pricing", day, 0, "", model.ProviderMetadata{})
+			if !tt.noIndex {
+				db.SetIndexModel("fake/meanings", false, day)
+			}
+			var embedder meaningEmbedder
+			opts := HybridOptions{Filter: tt.filter}
+
+			var results []HybridResult
+			var semantic bool
+			var err error
+			if tt.noEmbedder {
+				results, semantic, err = Hybrid(context.Background(), db, nil, "alice", tt.query, opts)
+			} else {
+				results, semantic, err = Hybrid(context.Background(), db, embedder, "alice", tt.query, opts)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Hybrid() error = %v, want %v", err, tt.wantErr)
+			}
+			got := make([]string, 0, len(results))
+			for _, r := range results {
+				got = append(got, r.Mp3FileName)
+			}
+			if tt.wantErr == nil && (!reflect.DeepEqual(got, tt.want) || semantic != tt.wantSemantic) {
+				t.Errorf("Hybrid() = %v, semantic %v, want %v, semantic %v", got, semantic, tt.want, tt.wantSemantic)
+			}
+		})
+	}
+}
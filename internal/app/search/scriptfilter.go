@@ -0,0 +1,52 @@
+package search
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ScriptFilter is the JSON an Alfred script filter prints, Raycast script commands read it as well,
+// see https://www.alfredapp.com/help/workflows/inputs/script-filter/json/.
+type ScriptFilter struct {
+	Items []ScriptFilterItem `json:"items"`
+}
+
+// ScriptFilterItem is a row of the launcher, Arg is passed on when it is selected.
+type ScriptFilterItem struct {
+	UID          string           `json:"uid"`
+	Title        string           `json:"title"`
+	Subtitle     string           `json:"subtitle"`
+	Arg          string           `json:"arg"`
+	QuickLookURL string           `json:"quicklookurl,omitempty"`
+	Text         ScriptFilterText `json:"text"`
+}
+
+// ScriptFilterText is copied with ⌘C and shown with ⌘L.
+type ScriptFilterText struct {
+	Copy      string `json:"copy"`
+	LargeType string `json:"largetype"`
+}
+
+// DetailPath is the path v2t serve returns the transcription at.
+func DetailPath(user string, id int) string {
+	return fmt.Sprintf("/api/v1/users/%s/transcriptions/%d", url.PathEscape(user), id)
+}
+
+// NewScriptFilter lists results linking to their detail at baseURL, the address of v2t serve.
+func NewScriptFilter(results []Result, baseURL string) ScriptFilter {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	items := make([]ScriptFilterItem, 0, len(results))
+	for _, r := range results {
+		link := baseURL + DetailPath(r.User, r.ID)
+		items = append(items, ScriptFilterItem{
+			UID:          fmt.Sprintf("%s/%d", r.User, r.ID),
+			Title:        r.Mp3FileName,
+			Subtitle:     strings.Join(strings.Fields(r.Snippet), " "),
+			Arg:          link,
+			QuickLookURL: link,
+			Text:         ScriptFilterText{Copy: r.Transcription.Transcription, LargeType: r.Snippet},
+		})
+	}
+	return ScriptFilter{Items: items}
+}
@@ -0,0 +1,145 @@
+// Package search finds stored transcriptions by keywords, for the MCP server, the HTTP API and the Go
+// client, and by keywords and meaning together for the search page of v2t serve.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/textdiff"
+)
+
+// SnippetLength is the number of characters of a transcription shown with a result.
+const SnippetLength = 200
+
+// ErrEmptyQuery is returned for queries without any word.
+var ErrEmptyQuery = errors.New("empty search query")
+
+// LowConfidence is the confidence below which the text of a transcription is likely misheard,
+// such results rank below the others.
+const LowConfidence = 0.6
+
+// Result is a transcription matching a query.
+type Result struct {
+	model.Transcription
+	// Snippet is the part of the text around the first word of the query.
+	Snippet string
+	// Confidence is how far the text can be trusted, see Confidence.
+	Confidence float64
+}
+
+// Options narrow a search.
+type Options struct {
+	// Limit is the most results to return, zero returns all matches.
+	Limit int
+	// ExcludeLowConfidence leaves out the results below LowConfidence instead of ranking them last.
+	ExcludeLowConfidence bool
+}
+
+// Transcriptions returns the transcriptions of user whose file name and text together contain every
+// word of query, in any order and case, as ordered by the database. A database with a full-text index
+// answers from it, best matches first. Results below LowConfidence come after the others, in the
+// same order among themselves.
+func Transcriptions(db repository.TranscriptionDAO, user string, query string, opts Options) ([]Result, error) {
+	words := textdiff.Words(query)
+	if len(words) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	found, err := matches(db, user, query, words)
+	if err != nil {
+		return nil, err
+	}
+
+	var confident, low []Result
+	for _, t := range found {
+		r := Result{Transcription: t, Snippet: Snippet(t.Transcription, words[0], SnippetLength), Confidence: Confidence(t)}
+		if r.Confidence >= LowConfidence {
+			confident = append(confident, r)
+		} else if !opts.ExcludeLowConfidence {
+			low = append(low, r)
+		}
+	}
+	results := append(append(make([]Result, 0, len(confident)+len(low)), confident...), low...)
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// matches returns all transcriptions of user matching words, from the full-text index when the database has one.
+func matches(db repository.TranscriptionDAO, user string, query string, words []string) ([]model.Transcription, error) {
+	if searcher, ok := db.(repository.FullTextSearcher); ok {
+		found, err := searcher.SearchTranscriptions(query, user, 0)
+		if err == nil {
+			return found, nil
+		}
+		if !errors.Is(err, repository.ErrNoFullTextIndex) {
+			return nil, fmt.Errorf("full-text search failed: %v", err)
+		}
+	}
+
+	stored, err := db.GetAllByUser(user)
+	if err != nil {
+		return nil, fmt.Errorf("get transcriptions failed: %v", err)
+	}
+
+	var found []model.Transcription
+	for _, t := range stored {
+		if textdiff.ContainsWords(textdiff.Words(t.Mp3FileName+" "+t.Transcription), words) {
+			found = append(found, t)
+		}
+	}
+	return found, nil
+}
+
+// Confidence estimates how far the text of t can be trusted, from 0 to 1: the mean probability of
+// its words where the provider reported them, halved when the result checks found issues. Failed
+// transcriptions have none, those nothing is known about have full confidence.
+func Confidence(t model.Transcription) float64 {
+	if t.ErrorMessage != "" {
+		return 0
+	}
+	confidence := 1.0
+	if p := t.ProviderMetadata.WordConfidence; p > 0 {
+		confidence = p
+	}
+	if v := t.ProviderMetadata.Validation; v != nil && !v.Passed {
+		confidence /= 2
+	}
+	return confidence
+}
+
+// Snippet returns about length characters of text around the first occurrence of word.
+func Snippet(text string, word string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	// lower casing can change the length of some characters, the snippet starts at the beginning then
+	lower := []rune(strings.ToLower(text))
+	start := 0
+	if len(lower) == len(runes) {
+		if i := strings.Index(string(lower), word); i >= 0 {
+			start = len([]rune(string(lower)[:i])) - length/4
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > len(runes)-length {
+		start = len(runes) - length
+	}
+
+	s := strings.TrimSpace(string(runes[start : start+length]))
+	if start > 0 {
+		s = "…" + s
+	}
+	if start+length < len(runes) {
+		s += "…"
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+)
+
+func TestTranscriptions(t *testing.T) {
+	db := memory.NewMemoryDB()
+	now := time.Now()
+	db.RecordToDB("alice", "/in", "whisper_talk.mp4", "whisper_talk.mp3", 60, "We compare models on long podcasts.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 120, "Whisper models for cooking.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "c.mp4", "c.mp3", 30, "Whisper models for bob.", now, 0, "", model.ProviderMetadata{})
+
+	tests := []struct {
+		name    string
+		query   string
+		limit   int
+		want    []string
+		wantErr error
+	}{
+		{name: "text and file name", query: "Models WHISPER", want: []string{"whisper_talk.mp3", "b.mp3"}},
+		{name: "limit", query: "whisper models", limit: 1, want: []string{"whisper_talk.mp3"}},
+		{name: "one word missing", query: "whisper bob", want: []string{}},
+		{name: "no words", query: " ?! ", wantErr: ErrEmptyQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Transcriptions(db, "alice", tt.query, Options{Limit: tt.limit})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Transcriptions() error = %v, want %v", err, tt.wantErr)
+			}
+			var files []string
+			for _, r := range got {
+				files = append(files, r.Mp3FileName)
+			}
+			if strings.Join(files, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Transcriptions() = %v, want %v", files, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscriptions_Confidence(t *testing.T) {
+	db := memory.NewMemoryDB()
+	now := time.Now()
+	db.RecordToDB("alice", "/in", "a.mp4", "misheard.mp3", 60, "Whisper models.", now, 0, "", model.ProviderMetadata{Provider: "openai", WordConfidence: 0.4})
+	db.RecordToDB("alice", "/in", "b.mp4", "sure.mp3", 60, "Whisper models.", now, 0, "", model.ProviderMetadata{Provider: "openai", WordConfidence: 0.9})
+	db.RecordToDB("alice", "/in", "c.mp4", "failed_checks.mp3", 60, "Whisper models.", now, 0, "", model.ProviderMetadata{Provider: "openai", Validation: &model.ValidationMetadata{Passed: false}})
+	db.RecordToDB("alice", "/in", "d.mp4", "unknown.mp3", 60, "Whisper models.", now, 0, "", model.ProviderMetadata{})
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{name: "low confidence last", want: []string{"sure.mp3", "unknown.mp3", "misheard.mp3", "failed_checks.mp3"}},
+		{name: "limit after ranking", opts: Options{Limit: 2}, want: []string{"sure.mp3", "unknown.mp3"}},
+		{name: "exclude low confidence", opts: Options{ExcludeLowConfidence: true}, want: []string{"sure.mp3", "unknown.mp3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Transcriptions(db, "alice", "whisper models", tt.opts)
+			if err != nil {
+				t.Fatalf("Transcriptions() error = %v", err)
+			}
+			var files []string
+			for _, r := range got {
+				files = append(files, r.Mp3FileName)
+			}
+			if strings.Join(files, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Transcriptions() = %v, want %v", files, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	tests := []struct {
+		name string
+		t    model.Transcription
+		want float64
+	}{
+		{name: "nothing known", want: 1},
+		{name: "word probabilities", t: model.Transcription{ProviderMetadata: model.ProviderMetadata{WordConfidence: 0.8}}, want: 0.8},
+		{name: "failed checks", t: model.Transcription{ProviderMetadata: model.ProviderMetadata{WordConfidence: 0.8, Validation: &model.ValidationMetadata{}}}, want: 0.4},
+		{name: "passed checks", t: model.Transcription{ProviderMetadata: model.ProviderMetadata{Validation: &model.ValidationMetadata{Passed: true}}}, want: 1},
+		{name: "failed transcription", t: model.Transcription{ErrorMessage: "timeout"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Confidence(tt.t); got != tt.want {
+				t.Errorf("Confidence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("a ", 100) + "whisper " + strings.Repeat("b ", 100)
+	got := Snippet(text, "whisper", 40)
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "whisper") {
+		t.Errorf("Snippet() = %q, want the text around whisper", got)
+	}
+	if got = Snippet("short text", "text", 40); got != "short text" {
+		t.Errorf("Snippet() = %q, want the whole text", got)
+	}
+}
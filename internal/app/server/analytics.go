@@ -0,0 +1,147 @@
+package server
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"tiktok-whisper/internal/app/analytics"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+)
+
+//go:embed templates/analytics.html
+var analyticsHTML string
+
+var analyticsTemplate = template.Must(template.New("analytics").Parse(analyticsHTML))
+
+// The size of a trend chart of the dashboard in SVG units.
+const (
+	chartWidth  = 600
+	chartHeight = 120
+)
+
+type analyticsPage struct {
+	User     string
+	Episodes []model.SpeechStats
+	Charts   []trendChart
+	First    string
+	Last     string
+	// Duplicates are those v2t analyze duplicates found last.
+	Duplicates []model.Duplicate
+}
+
+// trendChart is the line of one metric over the weeks.
+type trendChart struct {
+	Title  string
+	Max    float64
+	Points string
+	Latest float64
+}
+
+// speechStats analyzes the episodes of user that weren't yet and returns the stats of all of them.
+func (s *Server) speechStats(w http.ResponseWriter, db repository.TranscriptionDAO, user string) ([]model.SpeechStats, bool) {
+	stats, err := analytics.UpdateSpeech(db, analytics.SpeechOptions{User: user, FillerWords: s.opts.FillerWords})
+	if errors.Is(err, analytics.ErrSpeechNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return stats, true
+}
+
+// writeSpeechAnalytics serves /api/v1/users/{user}/speech-analytics.
+func (s *Server) writeSpeechAnalytics(w http.ResponseWriter, db repository.TranscriptionDAO, user string) {
+	stats, ok := s.speechStats(w, db, user)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, analytics.NewSpeechReport(stats))
+}
+
+// writeDuplicates serves /api/v1/users/{user}/duplicates, the duplicates v2t analyze duplicates found.
+func writeDuplicates(w http.ResponseWriter, db repository.TranscriptionDAO, user string) {
+	dao, ok := db.(repository.DuplicateDAO)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, analytics.ErrDuplicatesNotSupported.Error())
+		return
+	}
+	duplicates, err := dao.GetDuplicates(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, analytics.NewDuplicateEntries(duplicates))
+}
+
+// handleAnalytics serves /analytics/{user}, the dashboard of the speech analytics of a creator.
+func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to read the dashboard")
+		return
+	}
+	user := strings.Trim(strings.TrimPrefix(r.URL.Path, "/analytics/"), "/")
+	if !validUser(user) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	db, err := s.databases.ForUser(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	stats, ok := s.speechStats(w, db, user)
+	if !ok {
+		return
+	}
+
+	page := analyticsPage{User: user, Episodes: stats}
+	if dao, ok := db.(repository.DuplicateDAO); ok {
+		if page.Duplicates, err = dao.GetDuplicates(user); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	trend := analytics.Trend(stats)
+	if len(trend) > 0 {
+		page.First = trend[0].Week.Format("2006-01-02")
+		page.Last = trend[len(trend)-1].Week.Format("2006-01-02")
+		page.Charts = []trendChart{
+			chart("Filler words per minute", trend, func(p analytics.TrendPoint) float64 { return p.FillersPerMinute }),
+			chart("Longest monologue (s)", trend, func(p analytics.TrendPoint) float64 { return p.LongestMonologue }),
+			chart("Interruptions per episode", trend, func(p analytics.TrendPoint) float64 { return p.Interruptions }),
+			chart("Words per minute", trend, func(p analytics.TrendPoint) float64 { return p.WordsPerMinute }),
+		}
+	}
+	writeHTML(w, analyticsTemplate, page)
+}
+
+// chart draws the metric of the weeks as the points of an SVG polyline, zero at the bottom.
+func chart(title string, trend []analytics.TrendPoint, metric func(analytics.TrendPoint) float64) trendChart {
+	c := trendChart{Title: title, Latest: metric(trend[len(trend)-1])}
+	for _, p := range trend {
+		if v := metric(p); v > c.Max {
+			c.Max = v
+		}
+	}
+
+	points := make([]string, len(trend))
+	for i, p := range trend {
+		x := float64(chartWidth) / 2
+		if len(trend) > 1 {
+			x = float64(i) * chartWidth / float64(len(trend)-1)
+		}
+		y := float64(chartHeight)
+		if c.Max > 0 {
+			y -= metric(p) / c.Max * chartHeight
+		}
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	c.Points = strings.Join(points, " ")
+	return c
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/analytics"
+	"tiktok-whisper/internal/app/testutil"
+)
+
+func TestServer_SpeechAnalytics(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	ts := newTestServerWith(t, Options{FillerWords: []string{"hello"}})
+
+	_, queued := submit(t, ts, "alice", "talk.mp3")
+	waitJob(t, ts, queued.ID)
+
+	var resp analytics.SpeechReport
+	if code := getJSON(t, ts.URL+"/api/v1/users/alice/speech-analytics", &resp); code != http.StatusOK {
+		t.Fatalf("speech analytics = %v", code)
+	}
+	if len(resp.Episodes) != 1 || resp.Episodes[0].FillerWords != 1 || resp.Episodes[0].SpeechSeconds != 42 || len(resp.Trend) != 1 {
+		t.Errorf("speech analytics = %+v, want the episode with hello as its filler", resp)
+	}
+
+	if code, page := get(t, ts.URL+"/analytics/alice"); code != http.StatusOK || !strings.Contains(page, "<polyline") || !strings.Contains(page, "talk.mp3") {
+		t.Errorf("dashboard = %v, %s, want the trend charts and the episode", code, page)
+	}
+	if code, page := get(t, ts.URL+"/analytics/bob"); code != http.StatusOK || !strings.Contains(page, "no transcriptions") {
+		t.Errorf("dashboard of a user without transcriptions = %v, %s", code, page)
+	}
+	var duplicates []analytics.DuplicateEntry
+	if code := getJSON(t, ts.URL+"/api/v1/users/alice/duplicates", &duplicates); code != http.StatusOK || duplicates == nil || len(duplicates) != 0 {
+		t.Errorf("duplicates = %v, %+v, want none until v2t analyze duplicates runs", code, duplicates)
+	}
+	var e map[string]string
+	if code := getJSON(t, ts.URL+"/api/v1/users/alice/speech-analytics/x", &e); code != http.StatusNotFound {
+		t.Errorf("unknown path = %v, want 404", code)
+	}
+}
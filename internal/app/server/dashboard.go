@@ -0,0 +1,294 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/model"
+	"time"
+)
+
+//go:embed templates/dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// dashboardJobs is the most jobs the dashboard keeps, the least recently updated are dropped first.
+const dashboardJobs = 20
+
+// board follows the batch jobs on the event bus for the dashboard: the jobs of the server and the
+// convert runs of the same process, with the nats backend those of every machine. Jobs it saw no
+// event of are unknown to it, so are the files of a job it is not converting yet.
+type board struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	jobs     map[string]*boardJob
+	watchers map[chan struct{}]struct{}
+	closed   chan struct{}
+}
+
+// boardJob is the progress of a run over a batch job.
+type boardJob struct {
+	ID   string `json:"id"`
+	User string `json:"user,omitempty"`
+	// Files is the number of files of the run, those not started yet included.
+	Files     int       `json:"files"`
+	Done      int       `json:"done"`
+	Failed    int       `json:"failed"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// FilesPerMinute is the throughput, the files finished per minute since the run started, until
+	// the last one finished once it is over.
+	FilesPerMinute float64      `json:"files_per_minute"`
+	Progress       []*boardFile `json:"progress"`
+}
+
+// boardFile is a started file of a job.
+type boardFile struct {
+	Path   string                `json:"path"`
+	Name   string                `json:"name"`
+	Status model.BatchFileStatus `json:"status"`
+	// Stage and Progress are the last stage the file entered and how much of it is done.
+	Stage    string  `json:"stage,omitempty"`
+	Progress float64 `json:"progress,omitempty"`
+	// Provider transcribed the file, set once it is done.
+	Provider   string     `json:"provider,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func newBoard() *board {
+	return &board{
+		now:      time.Now,
+		jobs:     make(map[string]*boardJob),
+		watchers: make(map[chan struct{}]struct{}),
+		closed:   make(chan struct{}),
+	}
+}
+
+// observe updates the board with e, the events of files converted on their own are ignored.
+func (b *board) observe(e events.Event) {
+	if e.JobID == "" {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = b.now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	job, ok := b.jobs[e.JobID]
+	switch e.Topic {
+	case events.TopicJobStarted:
+		// a resumed job starts a new run over the files it didn't finish
+		job = &boardJob{ID: e.JobID, User: e.User, Files: e.Files, StartedAt: e.Time}
+		b.jobs[e.JobID] = job
+	case events.TopicFileProgress, events.TopicFileDone, events.TopicJobFailed:
+		if !ok {
+			job = &boardJob{ID: e.JobID, User: e.User, StartedAt: e.Time}
+			b.jobs[e.JobID] = job
+		}
+		b.observeFile(job, e)
+	default:
+		return
+	}
+	job.UpdatedAt = e.Time
+	b.prune()
+	b.notify()
+}
+
+// observeFile updates the file of job e is about.
+func (b *board) observeFile(job *boardJob, e events.Event) {
+	var file *boardFile
+	for _, f := range job.Progress {
+		if f.Path == e.FilePath {
+			file = f
+			break
+		}
+	}
+	if file == nil {
+		file = &boardFile{Path: e.FilePath, Name: filepath.Base(e.FilePath), Status: model.BatchFileInFlight, StartedAt: e.Time}
+		job.Progress = append(job.Progress, file)
+		if len(job.Progress) > job.Files {
+			job.Files = len(job.Progress)
+		}
+	}
+	if file.Status != model.BatchFileInFlight {
+		// a late progress event of a finished file
+		return
+	}
+
+	switch e.Topic {
+	case events.TopicFileProgress:
+		file.Stage, file.Progress = e.Stage, e.Progress
+	case events.TopicFileDone:
+		file.Status, file.Provider = model.BatchFileDone, e.Provider
+		file.FinishedAt = &e.Time
+		job.Done++
+	case events.TopicJobFailed:
+		file.Status, file.Provider, file.Error = model.BatchFileFailed, e.Provider, e.Error
+		file.FinishedAt = &e.Time
+		job.Failed++
+	}
+}
+
+// prune drops the least recently updated jobs beyond dashboardJobs.
+func (b *board) prune() {
+	for len(b.jobs) > dashboardJobs {
+		var oldest *boardJob
+		for _, job := range b.jobs {
+			if oldest == nil || job.UpdatedAt.Before(oldest.UpdatedAt) {
+				oldest = job
+			}
+		}
+		delete(b.jobs, oldest.ID)
+	}
+}
+
+// notify wakes the watchers up, a watcher that didn't catch up yet is only woken once.
+func (b *board) notify() {
+	for w := range b.watchers {
+		select {
+		case w <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch returns a channel receiving when the board changed, and a function to stop watching.
+func (b *board) watch() (<-chan struct{}, func()) {
+	w := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.watchers[w] = struct{}{}
+	b.mu.Unlock()
+	return w, func() {
+		b.mu.Lock()
+		delete(b.watchers, w)
+		b.mu.Unlock()
+	}
+}
+
+// close ends the streams of the watchers, so the server can shut down.
+func (b *board) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.closed:
+	default:
+		close(b.closed)
+	}
+}
+
+// snapshot returns copies of the jobs, the most recently started first.
+func (b *board) snapshot() []boardJob {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	jobs := make([]boardJob, 0, len(b.jobs))
+	for _, job := range b.jobs {
+		copied := *job
+		copied.Progress = make([]*boardFile, len(job.Progress))
+		for i, f := range job.Progress {
+			file := *f
+			copied.Progress[i] = &file
+		}
+		finished := job.Done + job.Failed
+		end := now
+		if finished >= job.Files {
+			end = job.UpdatedAt
+		}
+		if minutes := end.Sub(job.StartedAt).Minutes(); finished > 0 && minutes > 0 {
+			copied.FilesPerMinute = float64(finished) / minutes
+		}
+		jobs = append(jobs, copied)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].StartedAt.Equal(jobs[j].StartedAt) {
+			return jobs[i].StartedAt.After(jobs[j].StartedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs
+}
+
+// follow feeds the board with the events of the bus, the returned function stops it.
+func (s *Server) follow() (stop func()) {
+	if s.bus == nil {
+		return func() {}
+	}
+	return s.bus.Subscribe(events.TopicAll, s.board.observe)
+}
+
+// publish reports e on the bus when the server has one.
+func (s *Server) publish(e events.Event) {
+	if s.bus != nil {
+		s.bus.Publish(e)
+	}
+}
+
+// handleDashboardJobs serves /api/v1/dashboard, the jobs of the dashboard as JSON.
+func (s *Server) handleDashboardJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to read the dashboard")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.board.snapshot())
+}
+
+// handleDashboardEvents serves /api/v1/dashboard/events, a stream of server-sent events whose data
+// are the jobs of the dashboard as JSON, sent on connect and whenever they change.
+func (s *Server) handleDashboardEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to follow the dashboard")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	changed, stop := s.board.watch()
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for {
+		data, err := json.Marshal(s.board.snapshot())
+		if err != nil {
+			return
+		}
+		if _, err = fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		case <-s.board.closed:
+			return
+		}
+	}
+}
+
+// handleDashboard serves /dashboard, the batch jobs with the progress of their files, updated live
+// from /api/v1/dashboard/events.
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to read the dashboard")
+		return
+	}
+	writeHTML(w, dashboardTemplate, nil)
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/testutil"
+	"time"
+)
+
+func TestBoard_observe(t *testing.T) {
+	start := time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	tests := []struct {
+		name         string
+		events       []events.Event
+		wantNoJob    bool
+		wantFiles    int
+		wantDone     int
+		wantFailed   int
+		wantStatuses []model.BatchFileStatus
+		wantRate     float64
+	}{
+		{
+			name: "running job",
+			events: []events.Event{
+				{Topic: events.TopicJobStarted, JobID: "j1", Files: 3, Time: at(0)},
+				{Topic: events.TopicFileProgress, JobID: "j1", FilePath: "/in/a.mp4", Stage: events.StageExtracting, Progress: 40, Time: at(0)},
+				{Topic: events.TopicFileDone, JobID: "j1", FilePath: "/in/a.mp4", Provider: "openai", Time: at(1)},
+				{Topic: events.TopicFileProgress, JobID: "j1", FilePath: "/in/b.mp4", Stage: events.StageTranscribing, Time: at(1)},
+			},
+			wantFiles: 3, wantDone: 1,
+			wantStatuses: []model.BatchFileStatus{model.BatchFileDone, model.BatchFileInFlight},
+			// one file in the four minutes since the start
+			wantRate: 0.25,
+		},
+		{
+			name: "finished job",
+			events: []events.Event{
+				{Topic: events.TopicJobStarted, JobID: "j1", Files: 2, Time: at(0)},
+				{Topic: events.TopicFileDone, JobID: "j1", FilePath: "/in/a.mp4", Time: at(1)},
+				{Topic: events.TopicJobFailed, JobID: "j1", FilePath: "/in/b.mp4", Error: "provider down", Time: at(2)},
+				// a late progress event doesn't revive the failed file
+				{Topic: events.TopicFileProgress, JobID: "j1", FilePath: "/in/b.mp4", Stage: events.StageTranscribing, Time: at(2)},
+			},
+			wantFiles: 2, wantDone: 1, wantFailed: 1,
+			wantStatuses: []model.BatchFileStatus{model.BatchFileDone, model.BatchFileFailed},
+			wantRate:     1,
+		},
+		{
+			name: "job started before the board",
+			events: []events.Event{
+				{Topic: events.TopicFileDone, JobID: "j1", FilePath: "/in/a.mp4", Time: at(0)},
+				{Topic: events.TopicFileProgress, JobID: "j1", FilePath: "/in/b.mp4", Stage: events.StageTranscribing, Time: at(1)},
+			},
+			wantFiles: 2, wantDone: 1,
+			wantStatuses: []model.BatchFileStatus{model.BatchFileDone, model.BatchFileInFlight},
+			wantRate:     0.25,
+		},
+		{
+			name: "resumed job starts over",
+			events: []events.Event{
+				{Topic: events.TopicJobStarted, JobID: "j1", Files: 2, Time: at(0)},
+				{Topic: events.TopicJobFailed, JobID: "j1", FilePath: "/in/a.mp4", Error: "provider down", Time: at(1)},
+				{Topic: events.TopicJobStarted, JobID: "j1", Files: 1, Time: at(2)},
+			},
+			wantFiles: 1,
+		},
+		{
+			name: "files converted on their own are ignored",
+			events: []events.Event{
+				{Topic: events.TopicFileDone, FilePath: "/in/a.mp4", Time: at(0)},
+				{Topic: events.TopicProviderUnhealthy, JobID: "j1", Provider: "openai", Time: at(0)},
+			},
+			wantNoJob: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBoard()
+			b.now = func() time.Time { return at(4) }
+			for _, e := range tt.events {
+				b.observe(e)
+			}
+
+			jobs := b.snapshot()
+			if tt.wantNoJob {
+				if len(jobs) != 0 {
+					t.Fatalf("snapshot() = %+v, want no job", jobs)
+				}
+				return
+			}
+			if len(jobs) != 1 {
+				t.Fatalf("snapshot() = %+v, want one job", jobs)
+			}
+			job := jobs[0]
+			if job.Files != tt.wantFiles || job.Done != tt.wantDone || job.Failed != tt.wantFailed || job.FilesPerMinute != tt.wantRate {
+				t.Errorf("job = %d files, %d done, %d failed, %v files/min, want %d, %d, %d, %v",
+					job.Files, job.Done, job.Failed, job.FilesPerMinute, tt.wantFiles, tt.wantDone, tt.wantFailed, tt.wantRate)
+			}
+			if len(job.Progress) != len(tt.wantStatuses) {
+				t.Fatalf("progress = %+v, want %d files", job.Progress, len(tt.wantStatuses))
+			}
+			for i, f := range job.Progress {
+				if f.Status != tt.wantStatuses[i] {
+					t.Errorf("file %s = %s, want %s", f.Name, f.Status, tt.wantStatuses[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBoard_prune(t *testing.T) {
+	b := newBoard()
+	start := time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < dashboardJobs+2; i++ {
+		b.observe(events.Event{Topic: events.TopicJobStarted, JobID: string(rune('a' + i)), Files: 1, Time: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	jobs := b.snapshot()
+	if len(jobs) != dashboardJobs || jobs[0].ID != string(rune('a'+dashboardJobs+1)) || jobs[len(jobs)-1].ID != "c" {
+		t.Errorf("snapshot() has %d jobs from %s to %s, want the %d latest", len(jobs), jobs[0].ID, jobs[len(jobs)-1].ID, dashboardJobs)
+	}
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	ts := newTestServer(t)
+
+	_, submitted := submit(t, ts, "alice", "talk.mp3")
+	waitJob(t, ts, submitted.ID)
+
+	// the board follows the bus asynchronously
+	var jobs []boardJob
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if getJSON(t, ts.URL+"/api/v1/dashboard", &jobs) != http.StatusOK {
+			t.Fatalf("GET /api/v1/dashboard failed")
+		}
+		if len(jobs) == 1 && jobs[0].Done == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(jobs) != 1 || jobs[0].ID != submitted.ID || jobs[0].Done != 1 || len(jobs[0].Progress) != 1 {
+		t.Fatalf("dashboard = %+v, want the finished job", jobs)
+	}
+	if f := jobs[0].Progress[0]; f.Name != "talk.mp3" || f.Provider != "fake" || f.Status != model.BatchFileDone {
+		t.Errorf("file = %+v, want talk.mp3 done by the fake provider", f)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/v1/dashboard/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("first line = %q, %v, want the data of an event", line, err)
+	}
+	var streamed []boardJob
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &streamed); err != nil || len(streamed) != 1 {
+		t.Errorf("streamed %q, %v, want the job", line, err)
+	}
+
+	if status, body := get(t, ts.URL+"/dashboard"); status != http.StatusOK || !strings.Contains(body, "/api/v1/dashboard/events") {
+		t.Errorf("GET /dashboard = %d, want the page following the events", status)
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	v2tv1 "tiktok-whisper/api/proto/v2t/v1"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/search"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCServer returns a gRPC server with the TranscriptionService of api/proto/v2t/v1, it shares the
+// jobs and databases of the HTTP API. Audio of up to the upload limit is accepted. The AgentService
+// is served too when the jobs run on agents.
+func (s *Server) GRPCServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.MaxRecvMsgSize(int(s.opts.MaxUploadBytes)))
+	v2tv1.RegisterTranscriptionServiceServer(srv, &grpcService{s: s})
+	if s.opts.Agents != nil {
+		v2tv1.RegisterAgentServiceServer(srv, s.opts.Agents)
+	}
+	return srv
+}
+
+type grpcService struct {
+	v2tv1.UnimplementedTranscriptionServiceServer
+	s *Server
+}
+
+func (g *grpcService) Transcribe(ctx context.Context, req *v2tv1.TranscribeRequest) (*v2tv1.Transcription, error) {
+	if !validUser(req.User) {
+		return nil, status.Error(codes.InvalidArgument, "a valid user is required")
+	}
+	if req.FileName == "" || len(req.Audio) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "file_name and audio are required")
+	}
+
+	job := g.s.newJob(req.User, req.FileName)
+	if err := saveUpload(bytes.NewReader(req.Audio), job.path); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err := g.s.enqueue(job); err != nil {
+		os.Remove(job.path)
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	select {
+	case <-job.done:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	finished, _ := g.s.job(job.ID)
+	if finished.Status == JobFailed {
+		return nil, status.Error(codes.Internal, finished.Error)
+	}
+	return g.transcription(req.User, int64(finished.TranscriptionID))
+}
+
+func (g *grpcService) GetTranscription(ctx context.Context, req *v2tv1.GetTranscriptionRequest) (*v2tv1.Transcription, error) {
+	return g.transcription(req.User, req.Id)
+}
+
+func (g *grpcService) transcription(user string, id int64) (*v2tv1.Transcription, error) {
+	db, err := g.s.databases.ForUser(user)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	t, err := transcriptionOf(db, user, int(id))
+	if errors.Is(err, errNoTranscription) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return newTranscription(*t), nil
+}
+
+func (g *grpcService) ListByUser(ctx context.Context, req *v2tv1.ListByUserRequest) (*v2tv1.ListByUserResponse, error) {
+	db, err := g.s.databases.ForUser(req.User)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	transcriptions, err := db.GetAllByUser(req.User)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get transcriptions failed: %v", err)
+	}
+
+	resp := &v2tv1.ListByUserResponse{Transcriptions: make([]*v2tv1.Transcription, 0, len(transcriptions))}
+	for _, t := range transcriptions {
+		resp.Transcriptions = append(resp.Transcriptions, newTranscription(t))
+	}
+	return resp, nil
+}
+
+// SearchEmbeddings answers with the keyword search until there is an embedding index.
+func (g *grpcService) SearchEmbeddings(ctx context.Context, req *v2tv1.SearchEmbeddingsRequest) (*v2tv1.SearchEmbeddingsResponse, error) {
+	db, err := g.s.databases.ForUser(req.User)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	results, err := search.Transcriptions(db, req.User, req.Query, search.Options{Limit: int(req.Limit)})
+	if errors.Is(err, search.ErrEmptyQuery) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &v2tv1.SearchEmbeddingsResponse{Results: make([]*v2tv1.SearchResult, 0, len(results))}
+	for _, r := range results {
+		resp.Results = append(resp.Results, &v2tv1.SearchResult{Transcription: newTranscription(r.Transcription), Snippet: r.Snippet})
+	}
+	return resp, nil
+}
+
+func newTranscription(t model.Transcription) *v2tv1.Transcription {
+	pb := &v2tv1.Transcription{
+		Id:                   int64(t.ID),
+		User:                 t.User,
+		FileName:             t.Mp3FileName,
+		AudioDurationSeconds: t.AudioDuration,
+		Language:             t.ProviderMetadata.Language,
+		LastConversionTime:   timestamppb.New(t.LastConversionTime),
+		Text:                 t.Transcription,
+		Provider:             t.ProviderMetadata.Provider,
+		Model:                t.ProviderMetadata.Model,
+		SourceUrl:            t.SourceURL,
+	}
+	for _, s := range t.Segments {
+		segment := &v2tv1.Segment{Start: s.Start, End: s.End, Speaker: s.Speaker, Text: s.Text}
+		for _, w := range s.Words {
+			segment.Words = append(segment.Words, &v2tv1.Word{Start: w.Start, End: w.End, Text: w.Text, Probability: w.Probability})
+		}
+		pb.Segments = append(pb.Segments, segment)
+	}
+	return pb
+}
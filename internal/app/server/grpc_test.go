@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	v2tv1 "tiktok-whisper/api/proto/v2t/v1"
+	"tiktok-whisper/internal/app/agent"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/repository/router"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestGRPCClient(t *testing.T) v2tv1.TranscriptionServiceClient {
+	t.Helper()
+	return v2tv1.NewTranscriptionServiceClient(newTestGRPCConn(t, nil))
+}
+
+func newTestGRPCConn(t *testing.T, agents *agent.Pool) *grpc.ClientConn {
+	t.Helper()
+	dir := t.TempDir()
+	databases := router.New(config.DatabaseConfig{
+		Instances: map[string]config.DatabaseInstance{
+			config.DefaultDatabase: {DSN: filepath.Join(dir, "transcription.db")},
+		},
+	}, nil)
+	t.Cleanup(func() { databases.Close() })
+
+	s := NewServer(fakeTranscriber{}, databases, nil, nil)
+	s.duration = func(filePath string) (int, error) { return 42, nil }
+	wait := s.startWorkers(Options{UploadDir: filepath.Join(dir, "uploads"), Workers: 2, Agents: agents})
+	t.Cleanup(wait)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := s.GRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPC_Transcribe(t *testing.T) {
+	client := newTestGRPCClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		req      *v2tv1.TranscribeRequest
+		wantCode codes.Code
+		wantText string
+	}{
+		{name: "done", req: &v2tv1.TranscribeRequest{User: "alice", FileName: "talk.mp3", Audio: []byte("audio")}, wantCode: codes.OK, wantText: "hello from "},
+		{name: "failed", req: &v2tv1.TranscribeRequest{User: "alice", FileName: "broken.mp3", Audio: []byte("audio")}, wantCode: codes.Internal},
+		{name: "invalid user", req: &v2tv1.TranscribeRequest{User: "../etc", FileName: "talk.mp3", Audio: []byte("audio")}, wantCode: codes.InvalidArgument},
+		{name: "no audio", req: &v2tv1.TranscribeRequest{User: "alice", FileName: "talk.mp3"}, wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.Transcribe(ctx, tt.req)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("Transcribe() error = %v, want %v", err, tt.wantCode)
+			}
+			if tt.wantText != "" && (!strings.HasPrefix(got.Text, tt.wantText) || got.AudioDurationSeconds != 42 || got.User != "alice") {
+				t.Errorf("Transcribe() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestGRPC_Read(t *testing.T) {
+	client := newTestGRPCClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done, err := client.Transcribe(ctx, &v2tv1.TranscribeRequest{User: "alice", FileName: "talk.mp3", Audio: []byte("audio")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := client.GetTranscription(ctx, &v2tv1.GetTranscriptionRequest{User: "alice", Id: done.Id}); err != nil || got.Text != done.Text {
+		t.Errorf("GetTranscription() = %+v, %v, want the transcribed text", got, err)
+	}
+	if _, err = client.GetTranscription(ctx, &v2tv1.GetTranscriptionRequest{User: "bob", Id: done.Id}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetTranscription() of another user error = %v, want NotFound", err)
+	}
+	if list, err := client.ListByUser(ctx, &v2tv1.ListByUserRequest{User: "alice"}); err != nil || len(list.Transcriptions) != 1 {
+		t.Errorf("ListByUser() = %+v, %v, want the one transcription", list, err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  codes.Code
+		wantItems int
+	}{
+		{name: "match", query: "hello talk", wantItems: 1},
+		{name: "no match", query: "goodbye"},
+		{name: "empty query", query: " ", wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.SearchEmbeddings(ctx, &v2tv1.SearchEmbeddingsRequest{User: "alice", Query: tt.query})
+			if status.Code(err) != tt.wantCode || len(got.GetResults()) != tt.wantItems {
+				t.Errorf("SearchEmbeddings(%q) = %+v, %v, want %v with %d results", tt.query, got, err, tt.wantCode, tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestGRPC_Agents(t *testing.T) {
+	pool := agent.NewPool(agent.PoolOptions{PollTime: 50 * time.Millisecond, QueueTimeout: 5 * time.Second})
+	conn := newTestGRPCConn(t, pool)
+	client := v2tv1.NewTranscriptionServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	worker := agent.NewWorker(v2tv1.NewAgentServiceClient(conn), fakeTranscriber{}, agent.WorkerOptions{Name: "gpu-1", Providers: []string{"fake"}})
+	workerCtx, stop := context.WithCancel(ctx)
+	stopped := make(chan error, 1)
+	go func() { stopped <- worker.Run(workerCtx) }()
+	defer func() {
+		stop()
+		<-stopped
+	}()
+
+	got, err := client.Transcribe(ctx, &v2tv1.TranscribeRequest{User: "alice", FileName: "talk.mp3", Audio: []byte("audio")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got.Text, "hello from ") || !strings.HasSuffix(got.Text, "talk.mp3") || got.Provider != "fake" {
+		t.Errorf("Transcribe() on an agent = %+v", got)
+	}
+	if agents := pool.Agents(); len(agents) != 1 || agents[0].Name != "gpu-1" {
+		t.Errorf("Agents() = %+v, want gpu-1", agents)
+	}
+}
@@ -0,0 +1,357 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/schema"
+	"tiktok-whisper/internal/app/search"
+)
+
+// Handler routes the API:
+//
+//	POST /api/v1/jobs                               submit a multipart form with "file" and "user"
+//	GET  /api/v1/jobs/{id}                          status of a job
+//	GET  /api/v1/jobs/{id}/result                   transcription of a finished job
+//	GET  /api/v1/users/{user}/transcriptions        transcription history of a user
+//	GET  /api/v1/users/{user}/transcriptions/{id}   a transcription with its segments
+//	GET  /api/v1/users/{user}/speech-analytics      speech analytics of the episodes of a user, with the weekly trend
+//	GET  /api/v1/users/{user}/duplicates            the duplicates v2t analyze duplicates found among the transcriptions of a user
+//	GET  /api/quick-search?user=&q=                 Alfred script filter items of the matching transcriptions
+//	GET  /api/v1/search?user=&q=                    the results of the search page, see parseSearch for the filters
+//	GET  /api/v1/slo                                how the providers fare against their SLOs
+//	GET  /api/v1/agents                             the registered agents when the jobs run on agents
+//	GET  /status                                    the same as a status page
+//	GET  /review/{user}                             the language-learning review, see handleReview
+//	GET  /analytics/{user}                          the speech analytics as a dashboard
+//	GET  /search                                    the transcriptions of a user found by words and meaning
+//	GET  /api/v1/dashboard                          the batch jobs the dashboard follows on the event bus
+//	GET  /api/v1/dashboard/events                   the same as server-sent events, sent whenever they change
+//	GET  /dashboard                                 the batch jobs with the live progress of their files
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/jobs", s.handleSubmit)
+	mux.HandleFunc("/api/v1/jobs/", s.handleJob)
+	mux.HandleFunc("/api/v1/users/", s.handleHistory)
+	mux.HandleFunc("/api/quick-search", s.handleQuickSearch)
+	mux.HandleFunc("/api/v1/search", s.handleSearch)
+	mux.HandleFunc("/api/v1/slo", s.handleSLO)
+	mux.HandleFunc("/api/v1/agents", s.handleAgents)
+	mux.HandleFunc("/status", s.handleStatusPage)
+	mux.HandleFunc("/review/", s.handleReview)
+	mux.HandleFunc("/analytics/", s.handleAnalytics)
+	mux.HandleFunc("/search", s.handleSearchPage)
+	mux.HandleFunc("/api/v1/dashboard", s.handleDashboardJobs)
+	mux.HandleFunc("/api/v1/dashboard/events", s.handleDashboardEvents)
+	mux.HandleFunc("/dashboard", s.handleDashboard)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return mux
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST to submit a job")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxUploadBytes)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("read file field failed: %v", err))
+		return
+	}
+	defer file.Close()
+
+	user := r.FormValue("user")
+	if !validUser(user) {
+		writeError(w, http.StatusBadRequest, "a valid user field is required")
+		return
+	}
+
+	options, err := parseOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job := s.newJob(user, header.Filename)
+	job.options = options
+	if err = saveUpload(file, job.path); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = s.enqueue(job); err != nil {
+		os.Remove(job.path)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	log.Printf("Queued job %s: %s of %s\n", job.ID, job.FileName, job.User)
+
+	queued, _ := s.job(job.ID)
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, queued)
+}
+
+// parseOptions reads the optional fields tuning the transcription of an upload: temperature,
+// translate, word_threshold and max_segment_length.
+func parseOptions(r *http.Request) (api.Options, error) {
+	var opts api.Options
+	if t := r.FormValue("temperature"); t != "" {
+		temperature, err := strconv.ParseFloat(t, 32)
+		if err != nil || temperature < 0 || temperature > 1 {
+			return opts, errors.New("temperature must be a number from 0 to 1")
+		}
+		opts.Temperature = float32(temperature)
+	}
+	if t := r.FormValue("translate"); t != "" {
+		translate, err := strconv.ParseBool(t)
+		if err != nil {
+			return opts, errors.New("translate must be true or false")
+		}
+		opts.Translate = translate
+	}
+	if t := r.FormValue("word_threshold"); t != "" {
+		threshold, err := strconv.ParseFloat(t, 32)
+		if err != nil || threshold < 0 || threshold > 1 {
+			return opts, errors.New("word_threshold must be a number from 0 to 1")
+		}
+		opts.WordThreshold = float32(threshold)
+	}
+	if l := r.FormValue("max_segment_length"); l != "" {
+		length, err := strconv.Atoi(l)
+		if err != nil || length < 0 {
+			return opts, errors.New("max_segment_length must be a number of characters, 0 for no limit")
+		}
+		opts.MaxSegmentLength = length
+	}
+	return opts, nil
+}
+
+func saveUpload(src io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create upload dir failed: %v", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create upload failed: %v", err)
+	}
+	if _, err = io.Copy(f, src); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("save upload failed: %v", err)
+	}
+	return f.Close()
+}
+
+// handleJob serves /api/v1/jobs/{id} and /api/v1/jobs/{id}/result.
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to read a job")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/jobs/"), "/")
+	job, ok := s.job(parts[0])
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		writeJSON(w, http.StatusOK, job)
+	case len(parts) == 2 && parts[1] == "result":
+		s.writeResult(w, job)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) writeResult(w http.ResponseWriter, job Job) {
+	switch job.Status {
+	case JobFailed:
+		writeError(w, http.StatusUnprocessableEntity, job.Error)
+		return
+	case JobQueued, JobRunning:
+		writeError(w, http.StatusConflict, fmt.Sprintf("job is %s", job.Status))
+		return
+	}
+
+	db, err := s.databases.ForUser(job.User)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	t, err := db.GetByID(job.TranscriptionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("get transcription failed: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, schema.NewTranscription(*t))
+}
+
+// handleHistory serves /api/v1/users/{user}/transcriptions, /api/v1/users/{user}/transcriptions/{id}
+// /api/v1/users/{user}/speech-analytics and /api/v1/users/{user}/duplicates.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to read the history")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/users/"), "/")
+	speech := len(parts) == 2 && parts[1] == "speech-analytics"
+	duplicates := len(parts) == 2 && parts[1] == "duplicates"
+	if !speech && !duplicates && (len(parts) < 2 || len(parts) > 3 || parts[1] != "transcriptions") || parts[0] == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	user := parts[0]
+
+	db, err := s.databases.ForUser(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if speech {
+		s.writeSpeechAnalytics(w, db, user)
+		return
+	}
+	if duplicates {
+		writeDuplicates(w, db, user)
+		return
+	}
+	if len(parts) == 3 {
+		writeTranscription(w, db, user, parts[2])
+		return
+	}
+	transcriptions, err := db.GetAllByUser(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("get transcriptions failed: %v", err))
+		return
+	}
+
+	resp := make([]schema.Transcription, 0, len(transcriptions))
+	for _, t := range transcriptions {
+		resp = append(resp, schema.NewTranscription(t))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeTranscription writes the transcription of user with id, including its segments.
+func writeTranscription(w http.ResponseWriter, db repository.TranscriptionDAO, user string, id string) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	t, err := transcriptionOf(db, user, n)
+	if errors.Is(err, errNoTranscription) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, schema.NewTranscription(*t))
+}
+
+// errNoTranscription is returned for transcriptions that don't exist or belong to another user.
+var errNoTranscription = errors.New("no such transcription")
+
+// transcriptionOf returns the transcription of user with id, including its segments.
+func transcriptionOf(db repository.TranscriptionDAO, user string, id int) (*model.Transcription, error) {
+	t, err := db.GetByID(id)
+	if err != nil || t == nil || t.User != user {
+		return nil, errNoTranscription
+	}
+	if segmentDAO, ok := db.(repository.SegmentDAO); ok && len(t.Segments) == 0 {
+		if t.Segments, err = segmentDAO.GetSegments(t.ID); err != nil {
+			return nil, fmt.Errorf("get segments failed: %v", err)
+		}
+	}
+	return t, nil
+}
+
+// validUser reports whether user can name a directory of the upload dir.
+func validUser(user string) bool {
+	return user != "" && !strings.ContainsAny(user, `/\`) && user != "." && user != ".."
+}
+
+// quickSearchLimit is the number of items a launcher lists when the request sets no limit.
+const quickSearchLimit = 20
+
+// handleQuickSearch serves /api/quick-search for launchers like Alfred and Raycast, which search as
+// the user types: a query without words lists nothing instead of failing.
+func (s *Server) handleQuickSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to search")
+		return
+	}
+
+	query := r.URL.Query()
+	user := query.Get("user")
+	if user == "" {
+		writeError(w, http.StatusBadRequest, "a user parameter is required")
+		return
+	}
+	limit := quickSearchLimit
+	if l := query.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+	opts := search.Options{Limit: limit}
+	if include := query.Get("include_low_confidence"); include != "" {
+		included, err := strconv.ParseBool(include)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "include_low_confidence must be true or false")
+			return
+		}
+		opts.ExcludeLowConfidence = !included
+	}
+
+	db, err := s.databases.ForUser(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	results, err := search.Transcriptions(db, user, query.Get("q"), opts)
+	if err != nil && !errors.Is(err, search.ErrEmptyQuery) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	writeJSON(w, http.StatusOK, search.NewScriptFilter(results, scheme+"://"+r.Host))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/schema"
+	"time"
+)
+
+// JobStatus is the state of a submitted job.
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// Job is a submitted audio file, jobs are kept in memory, their results in the database.
+// Its JSON is the job document of the schema package.
+type Job struct {
+	SchemaVersion int       `json:"schema_version"`
+	ID            string    `json:"id"`
+	User          string    `json:"user"`
+	FileName      string    `json:"file_name"`
+	Status        JobStatus `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	// TranscriptionID is the stored result, set once the job is done.
+	TranscriptionID int        `json:"transcription_id,omitempty"`
+	SubmittedAt     time.Time  `json:"submitted_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+
+	path string
+	// provider transcribed the file, it is reported on the bus.
+	provider string
+	// options tune the transcription of the file, they are set by the form fields of the upload.
+	options api.Options
+	// done is closed once the job finished.
+	done chan struct{}
+}
+
+// newJob creates a queued job of user for the file uploaded as fileName, it is saved to the upload dir.
+func (s *Server) newJob(user string, fileName string) *Job {
+	job := &Job{
+		SchemaVersion: schema.Version,
+		ID:            newJobID(),
+		User:          user,
+		FileName:      filepath.Base(fileName),
+		Status:        JobQueued,
+		SubmittedAt:   time.Now(),
+		done:          make(chan struct{}),
+	}
+	job.path = filepath.Join(s.opts.UploadDir, user, job.ID+"_"+job.FileName)
+	return job
+}
+
+// run transcribes the job's file and records the outcome in the user's database.
+func (s *Server) run(job *Job) {
+	s.update(job, func(j *Job) { j.Status = JobRunning })
+	log.Printf("Running job %s: %s of %s\n", job.ID, job.FileName, job.User)
+	s.publish(events.Event{Topic: events.TopicFileProgress, JobID: job.ID, User: job.User, FilePath: job.FileName, Stage: events.StageTranscribing})
+
+	id, err := s.transcribe(job)
+
+	s.update(job, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		if err != nil {
+			j.Status = JobFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = JobDone
+		j.TranscriptionID = id
+	})
+	close(job.done)
+	s.publishResult(job, id, err)
+	if err != nil {
+		log.Printf("Job %s failed: %v\n", job.ID, err)
+	}
+}
+
+// publishResult reports the outcome of job on the bus like the converter does.
+func (s *Server) publishResult(job *Job, transcriptionID int, err error) {
+	finished, _ := s.job(job.ID)
+	e := events.Event{
+		Topic:           events.TopicFileDone,
+		JobID:           job.ID,
+		User:            job.User,
+		FilePath:        job.FileName,
+		Provider:        finished.provider,
+		TranscriptionID: transcriptionID,
+	}
+	if err != nil {
+		e.Topic = events.TopicJobFailed
+		e.Error = err.Error()
+	}
+	s.publish(e)
+}
+
+func (s *Server) transcribe(job *Job) (int, error) {
+	db, err := s.databases.ForUser(job.User)
+	if err != nil {
+		return 0, err
+	}
+
+	// The stored name carries the job id, so the result can be looked up by it
+	fileName := filepath.Base(job.path)
+	inputDir := filepath.Dir(job.path)
+
+	duration, err := s.duration(job.path)
+	if err != nil {
+		db.RecordToDB(job.User, inputDir, fileName, fileName, 0, "", time.Now(), 1,
+			fmt.Sprintf("Failed to get audio duration: %v", err), model.ProviderMetadata{})
+		return 0, fmt.Errorf("failed to get audio duration: %v", err)
+	}
+
+	var text string
+	var metadata model.ProviderMetadata
+	start := time.Now()
+	text, metadata, err = provider.Transcribe(api.WithOptions(context.Background(), job.options), s.transcriber, job.path)
+	s.monitor.Record(metadata.Provider, time.Since(start), float64(duration), err)
+	s.update(job, func(j *Job) { j.provider = metadata.Provider })
+	if err != nil {
+		db.RecordToDB(job.User, inputDir, fileName, fileName, duration, "", time.Now(), 1,
+			fmt.Sprintf("Transcription error: %v", err), metadata)
+		return 0, fmt.Errorf("transcription error: %v", err)
+	}
+
+	db.RecordToDB(job.User, inputDir, fileName, fileName, duration, text, time.Now(), 0, "", metadata)
+	id, err := db.CheckIfFileProcessed(fileName)
+	if err != nil {
+		return 0, fmt.Errorf("look up stored transcription failed: %v", err)
+	}
+	if segments, ok := db.(repository.SegmentDAO); ok && len(metadata.Segments) > 0 {
+		if err = segments.SaveSegments(id, metadata.Segments); err != nil {
+			log.Printf("Error saving segments of job %s: %v\n", job.ID, err)
+		}
+	}
+	if insights, ok := db.(repository.InsightDAO); ok && metadata.Insights != nil && !metadata.Insights.Empty() {
+		if err = insights.SaveInsights(id, *metadata.Insights); err != nil {
+			log.Printf("Error saving insights of job %s: %v\n", job.ID, err)
+		}
+	}
+	return id, nil
+}
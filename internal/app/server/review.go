@@ -0,0 +1,346 @@
+package server
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/review"
+	"time"
+)
+
+// defaultReviewLanguage is the language of the translations when the page doesn't choose one.
+const defaultReviewLanguage = "en"
+
+//go:embed templates/review.html
+var reviewHTML string
+
+//go:embed templates/review_list.html
+var reviewListHTML string
+
+var reviewFuncs = template.FuncMap{
+	"timestamp": func(seconds float64) string {
+		d := time.Duration(seconds * float64(time.Second)).Round(time.Second)
+		return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+	},
+	"date": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+}
+
+var reviewTemplate = template.Must(template.New("review").Funcs(reviewFuncs).Parse(reviewHTML))
+
+var reviewListTemplate = template.Must(template.New("review_list").Funcs(reviewFuncs).Parse(reviewListHTML))
+
+type reviewPage struct {
+	User     string
+	Base     string
+	Language string
+	Name     string
+	HasAudio bool
+	// CanTranslate is set when the server has a translation backend.
+	CanTranslate bool
+	Untranslated int
+	Items        []reviewItem
+}
+
+type reviewItem struct {
+	review.Item
+	Timed bool
+	Due   bool
+}
+
+type reviewListPage struct {
+	User           string
+	Base           string
+	Language       string
+	Marked         int
+	Due            int
+	Transcriptions []reviewListEntry
+}
+
+type reviewListEntry struct {
+	ID     int
+	Name   string
+	Marked int
+	Due    int
+}
+
+// cardResponse is the card of a segment after it was marked or answered.
+type cardResponse struct {
+	Position     int       `json:"position"`
+	Marked       bool      `json:"marked"`
+	IntervalDays int       `json:"interval_days,omitempty"`
+	DueAt        time.Time `json:"due_at,omitempty"`
+}
+
+// handleReview serves the language-learning review under /review/{user}:
+//
+//	GET  /review/{user}                               the transcriptions with their marked and due segments
+//	GET  /review/{user}/anki.txt?lang=&due=1          the marked segments as an Anki import, only the due ones with due
+//	GET  /review/{user}/{id}?lang=                    the segments side by side with their translations
+//	GET  /review/{user}/{id}/audio                    the audio of the transcription
+//	POST /review/{user}/{id}/translate?lang=          translate the segments that have no current translation
+//	POST /review/{user}/{id}/segments/{position}      mark, answer or unmark a segment, grade=mark|again|hard|good|easy|unmark
+func (s *Server) handleReview(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/review/"), "/"), "/")
+	user := parts[0]
+	if !validUser(user) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	db, err := s.databases.ForUser(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if _, ok := db.(repository.ReviewDAO); !ok {
+		writeError(w, http.StatusNotImplemented, review.ErrNotSupported.Error())
+		return
+	}
+	language := r.URL.Query().Get("lang")
+	if language == "" {
+		language = defaultReviewLanguage
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "use GET to read the review")
+			return
+		}
+		s.writeReviewList(w, db, user, language)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "anki.txt" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "use GET to export the review")
+			return
+		}
+		writeAnki(w, db, user, r.URL.Query().Get("lang"), r.URL.Query().Get("due") != "")
+		return
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	t, err := transcriptionOf(db, user, id)
+	if errors.Is(err, errNoTranscription) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		s.writeReviewPage(w, db, *t, language)
+	case len(parts) == 3 && parts[2] == "audio" && r.Method == http.MethodGet:
+		path, ok := s.audioOf(*t)
+		if !ok {
+			writeError(w, http.StatusNotFound, "the audio of the transcription is not kept")
+			return
+		}
+		http.ServeFile(w, r, path)
+	case len(parts) == 3 && parts[2] == "translate" && r.Method == http.MethodPost:
+		s.translateSegments(w, db, *t, language)
+	case len(parts) == 4 && parts[2] == "segments" && r.Method == http.MethodPost:
+		position, err := strconv.Atoi(parts[3])
+		if err != nil {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		answerSegment(w, db, *t, position, language, r.FormValue("grade"))
+	case len(parts) <= 4:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func reviewBase(user string) string {
+	return "/review/" + url.PathEscape(user)
+}
+
+func (s *Server) writeReviewList(w http.ResponseWriter, db repository.TranscriptionDAO, user string, language string) {
+	transcriptions, err := db.GetAllByUser(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("get transcriptions failed: %v", err))
+		return
+	}
+	now := time.Now()
+	cards, err := review.Cards(db, user, false, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	marked := make(map[int]int)
+	due := make(map[int]int)
+	page := reviewListPage{User: user, Base: reviewBase(user), Language: language, Marked: len(cards)}
+	for _, c := range cards {
+		marked[c.TranscriptionID]++
+		if !c.DueAt.After(now) {
+			due[c.TranscriptionID]++
+			page.Due++
+		}
+	}
+	for _, t := range transcriptions {
+		if strings.TrimSpace(t.Transcription) == "" {
+			continue
+		}
+		page.Transcriptions = append(page.Transcriptions, reviewListEntry{
+			ID:     t.ID,
+			Name:   t.Mp3FileName,
+			Marked: marked[t.ID],
+			Due:    due[t.ID],
+		})
+	}
+	writeHTML(w, reviewListTemplate, page)
+}
+
+func (s *Server) writeReviewPage(w http.ResponseWriter, db repository.TranscriptionDAO, t model.Transcription, language string) {
+	items, err := review.Items(db, t, language)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	_, hasAudio := s.audioOf(t)
+	page := reviewPage{
+		User:         t.User,
+		Base:         reviewBase(t.User) + "/" + strconv.Itoa(t.ID),
+		Language:     language,
+		Name:         t.Mp3FileName,
+		HasAudio:     hasAudio,
+		CanTranslate: s.opts.Translator != nil,
+	}
+	now := time.Now()
+	for _, item := range items {
+		if item.Translation == "" && strings.TrimSpace(item.Text) != "" {
+			page.Untranslated++
+		}
+		page.Items = append(page.Items, reviewItem{Item: item, Timed: item.Timed(), Due: item.Due(now)})
+	}
+	writeHTML(w, reviewTemplate, page)
+}
+
+// audioOf returns the audio file of t: converted files are kept in the user's mp3 dir of MediaDir,
+// submitted ones in the upload dir.
+func (s *Server) audioOf(t model.Transcription) (string, bool) {
+	name := filepath.Base(t.Mp3FileName)
+	if name == "." || name == string(filepath.Separator) {
+		return "", false
+	}
+	for _, dir := range []string{s.opts.MediaDir, s.opts.UploadDir} {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, t.User, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func (s *Server) translateSegments(w http.ResponseWriter, db repository.TranscriptionDAO, t model.Transcription, language string) {
+	if s.opts.Translator == nil {
+		writeError(w, http.StatusServiceUnavailable, "the server has no translation backend, set translation in config.yaml")
+		return
+	}
+	n, err := review.Translate(db, s.opts.Translator, t, language)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("%d segments translated, then: %v", n, err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"translated": n})
+}
+
+func answerSegment(w http.ResponseWriter, db repository.TranscriptionDAO, t model.Transcription, position int, language string, grade string) {
+	now := time.Now()
+	var card model.ReviewCard
+	var err error
+	switch grade {
+	case "mark":
+		card, err = review.Mark(db, t, position, language, now)
+	case "unmark":
+		if err = review.Unmark(db, t, position); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, cardResponse{Position: position})
+		return
+	default:
+		g, parseErr := review.ParseGrade(grade)
+		if parseErr != nil {
+			writeError(w, http.StatusBadRequest, parseErr.Error())
+			return
+		}
+		card, err = review.Answer(db, t, position, g, now)
+	}
+	if errors.Is(err, review.ErrNotMarked) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, cardResponse{Position: position, Marked: true, IntervalDays: card.IntervalDays, DueAt: card.DueAt})
+}
+
+// writeAnki writes the marked segments of user as an Anki import, only those translated into
+// language when it is set.
+func writeAnki(w http.ResponseWriter, db repository.TranscriptionDAO, user string, language string, due bool) {
+	cards, err := review.Cards(db, user, due, time.Now())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	transcriptions, err := db.GetAllByUser(user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("get transcriptions failed: %v", err))
+		return
+	}
+	names := make(map[int]string, len(transcriptions))
+	for _, t := range transcriptions {
+		names[t.ID] = t.Mp3FileName
+	}
+	selected := make([]model.ReviewCard, 0, len(cards))
+	for _, c := range cards {
+		if language == "" || c.Language == language {
+			selected = append(selected, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = export.WriteAnki(&buf, "v2t::"+user, review.Notes(user, selected, names)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "v2t-"+export.AnkiTag(user)+".txt"))
+	w.Write(buf.Bytes())
+}
+
+func writeHTML(w http.ResponseWriter, t *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Printf("Error writing %s page: %v\n", t.Name(), err)
+		writeError(w, http.StatusInternalServerError, "render page failed")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
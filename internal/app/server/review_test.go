@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/testutil"
+)
+
+// upperTranslator "translates" by upper casing.
+type upperTranslator struct{}
+
+func (upperTranslator) Name() string { return "upper" }
+
+func (upperTranslator) Translate(text string, target string) (string, error) {
+	return strings.ToUpper(text), nil
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func post(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+	resp, err := http.Post(url, "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	json.NewDecoder(resp.Body).Decode(v)
+	return resp.StatusCode
+}
+
+func TestServer_Review(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	ts := newTestServerWith(t, Options{Translator: upperTranslator{}})
+
+	_, queued := submit(t, ts, "alice", "talk.mp3")
+	job := waitJob(t, ts, queued.ID)
+	base := fmt.Sprintf("%s/review/alice/%d", ts.URL, job.TranscriptionID)
+
+	if code, page := get(t, ts.URL+"/review/alice"); code != http.StatusOK || !strings.Contains(page, "talk.mp3") {
+		t.Errorf("review list = %v, %s, want the transcription", code, page)
+	}
+	if code, page := get(t, base+"?lang=en"); code != http.StatusOK || !strings.Contains(page, "not translated") {
+		t.Errorf("review page = %v, %s, want the untranslated segment", code, page)
+	}
+	if code, audio := get(t, base+"/audio"); code != http.StatusOK || audio != "audio" {
+		t.Errorf("audio = %v, %q, want the uploaded file", code, audio)
+	}
+
+	var translated map[string]int
+	if code := post(t, base+"/translate?lang=en", &translated); code != http.StatusOK || translated["translated"] == 0 {
+		t.Fatalf("translate = %v, %v, want the segments translated", code, translated)
+	}
+	if code, page := get(t, base+"?lang=en"); code != http.StatusOK || !strings.Contains(page, "HELLO FROM") {
+		t.Errorf("review page after translating = %v, %s", code, page)
+	}
+
+	tests := []struct {
+		name         string
+		grade        string
+		wantCode     int
+		wantMarked   bool
+		wantInterval int
+	}{
+		{name: "answer before marking", grade: "good", wantCode: http.StatusConflict},
+		{name: "mark", grade: "mark", wantCode: http.StatusOK, wantMarked: true},
+		{name: "good", grade: "good", wantCode: http.StatusOK, wantMarked: true, wantInterval: 1},
+		{name: "unknown grade", grade: "perfect", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var card cardResponse
+			code := post(t, base+"/segments/0?lang=en&grade="+tt.grade, &card)
+			if code != tt.wantCode {
+				t.Fatalf("grade %s = %v, want %v", tt.grade, code, tt.wantCode)
+			}
+			if code == http.StatusOK && (card.Marked != tt.wantMarked || card.IntervalDays != tt.wantInterval) {
+				t.Errorf("grade %s = %+v", tt.grade, card)
+			}
+		})
+	}
+
+	if code, anki := get(t, ts.URL+"/review/alice/anki.txt"); code != http.StatusOK ||
+		!strings.Contains(anki, "#deck:v2t::alice\n") || !strings.Contains(anki, "\tHELLO FROM ") {
+		t.Errorf("anki export = %v, %s, want the marked segment", code, anki)
+	}
+	if code, anki := get(t, ts.URL+"/review/alice/anki.txt?due=1"); code != http.StatusOK || strings.Contains(anki, "HELLO") {
+		t.Errorf("anki export of due cards = %v, %s, want none after the good answer", code, anki)
+	}
+
+	for _, path := range []string{"/review/bob/1", "/review/alice/x", "/review/alice/1/segments/x/y"} {
+		if code, _ := get(t, ts.URL+path); code != http.StatusNotFound {
+			t.Errorf("%s = %v, want 404", path, code)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	_ "embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"tiktok-whisper/internal/app/search"
+	"time"
+)
+
+//go:embed templates/search.html
+var searchHTML string
+
+var searchTemplate = template.Must(template.New("search").Funcs(reviewFuncs).Parse(searchHTML))
+
+// searchDate is the layout of the from and to parameters, that of the date inputs of the page.
+const searchDate = "2006-01-02"
+
+// searchQuery is a search as the page and the API take it, the fields are echoed into the form.
+type searchQuery struct {
+	User        string
+	Query       string
+	From        string
+	To          string
+	MinDuration string
+	MaxDuration string
+	filter      search.Filter
+	limit       int
+}
+
+// parseSearch reads the parameters of a search: user, q, from and to dates, the last included,
+// min_duration and max_duration in seconds, and limit.
+func parseSearch(r *http.Request) (searchQuery, error) {
+	query := r.URL.Query()
+	q := searchQuery{
+		User:        query.Get("user"),
+		Query:       query.Get("q"),
+		From:        query.Get("from"),
+		To:          query.Get("to"),
+		MinDuration: query.Get("min_duration"),
+		MaxDuration: query.Get("max_duration"),
+		limit:       quickSearchLimit,
+	}
+	if q.User != "" && !validUser(q.User) {
+		return q, errors.New("invalid user")
+	}
+	if q.From != "" {
+		from, err := time.ParseInLocation(searchDate, q.From, time.Local)
+		if err != nil {
+			return q, errors.New("from must be a date like 2023-09-01")
+		}
+		q.filter.From = from
+	}
+	if q.To != "" {
+		to, err := time.ParseInLocation(searchDate, q.To, time.Local)
+		if err != nil {
+			return q, errors.New("to must be a date like 2023-09-30")
+		}
+		q.filter.To = to.AddDate(0, 0, 1)
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		to    *float64
+	}{{"min_duration", q.MinDuration, &q.filter.MinDuration}, {"max_duration", q.MaxDuration, &q.filter.MaxDuration}} {
+		if d.value == "" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(d.value, 64)
+		if err != nil || seconds < 0 {
+			return q, errors.New(d.name + " must be a number of seconds")
+		}
+		*d.to = seconds
+	}
+	if l := query.Get("limit"); l != "" {
+		var err error
+		if q.limit, err = strconv.Atoi(l); err != nil || q.limit <= 0 {
+			return q, errors.New("limit must be a positive number")
+		}
+	}
+	return q, nil
+}
+
+// searchPage is the search page with the results of its query.
+type searchPage struct {
+	searchQuery
+	Semantic bool
+	Results  []search.HybridResult
+}
+
+// searchResponse is the JSON of /api/v1/search.
+type searchResponse struct {
+	Query string `json:"query"`
+	// Semantic is false when only the words of the query were searched.
+	Semantic bool        `json:"semantic"`
+	Results  []searchHit `json:"results"`
+}
+
+// searchHit is a transcription found by the words of the query, by its meaning or both.
+type searchHit struct {
+	ID            int       `json:"id"`
+	User          string    `json:"user"`
+	File          string    `json:"file"`
+	TranscribedAt time.Time `json:"transcribed_at"`
+	Duration      float64   `json:"duration_seconds"`
+	Snippet       string    `json:"snippet"`
+	Score         float64   `json:"score"`
+	Keyword       bool      `json:"keyword"`
+	Similarity    float64   `json:"similarity"`
+	Confidence    float64   `json:"confidence"`
+	// URL is the path of the transcription in the API.
+	URL string `json:"url"`
+}
+
+// hybridSearch runs q, writing the error response when it fails.
+func (s *Server) hybridSearch(w http.ResponseWriter, r *http.Request, q searchQuery) ([]search.HybridResult, bool, bool) {
+	db, err := s.databases.ForUser(q.User)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false, false
+	}
+	results, semantic, err := search.Hybrid(r.Context(), db, s.opts.Embedder, q.User, q.Query, search.HybridOptions{Limit: q.limit, Filter: q.filter})
+	if err != nil && !errors.Is(err, search.ErrEmptyQuery) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false, false
+	}
+	return results, semantic, true
+}
+
+// handleSearch serves /api/v1/search, the results of the search page as JSON. A query without words
+// finds nothing instead of failing.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to search")
+		return
+	}
+	q, err := parseSearch(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q.User == "" {
+		writeError(w, http.StatusBadRequest, "a user parameter is required")
+		return
+	}
+	results, semantic, ok := s.hybridSearch(w, r, q)
+	if !ok {
+		return
+	}
+
+	resp := searchResponse{Query: q.Query, Semantic: semantic, Results: make([]searchHit, 0, len(results))}
+	for _, h := range results {
+		resp.Results = append(resp.Results, searchHit{
+			ID:            h.ID,
+			User:          h.User,
+			File:          h.Mp3FileName,
+			TranscribedAt: h.LastConversionTime,
+			Duration:      h.AudioDuration,
+			Snippet:       h.Snippet,
+			Score:         h.Score,
+			Keyword:       h.Keyword,
+			Similarity:    h.Similarity,
+			Confidence:    h.Confidence,
+			URL:           search.DetailPath(h.User, h.ID),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSearchPage serves /search, a form searching the transcriptions of a user by words and
+// meaning, narrowed by date and duration. The results link to their review page.
+func (s *Server) handleSearchPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to search")
+		return
+	}
+	q, err := parseSearch(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page := searchPage{searchQuery: q}
+	if q.User != "" {
+		var ok bool
+		if page.Results, page.Semantic, ok = s.hybridSearch(w, r, q); !ok {
+			return
+		}
+	}
+	writeHTML(w, searchTemplate, page)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Search(t *testing.T) {
+	ts := newTestServer(t)
+	_, talk := submit(t, ts, "alice", "talk.mp3")
+	_, other := submit(t, ts, "bob", "talk.mp3")
+	waitJob(t, ts, talk.ID)
+	waitJob(t, ts, other.ID)
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []string
+	}{
+		{name: "words", query: "?user=alice&q=talk", wantCode: http.StatusOK, want: []string{"talk.mp3"}},
+		{name: "duration in range", query: "?user=alice&q=talk&min_duration=30&max_duration=60", wantCode: http.StatusOK, want: []string{"talk.mp3"}},
+		{name: "too short", query: "?user=alice&q=talk&min_duration=60", wantCode: http.StatusOK, want: []string{}},
+		{name: "before the date range", query: "?user=alice&q=talk&to=" + yesterday, wantCode: http.StatusOK, want: []string{}},
+		{name: "within the date range", query: "?user=alice&q=talk&from=" + yesterday, wantCode: http.StatusOK, want: []string{"talk.mp3"}},
+		{name: "no words", query: "?user=alice&q=", wantCode: http.StatusOK, want: []string{}},
+		{name: "invalid date", query: "?user=alice&q=talk&from=yesterday", wantCode: http.StatusBadRequest},
+		{name: "invalid duration", query: "?user=alice&q=talk&max_duration=-1", wantCode: http.StatusBadRequest},
+		{name: "no user", query: "?q=talk", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got searchResponse
+			code := getJSON(t, ts.URL+"/api/v1/search"+tt.query, &got)
+			if code != tt.wantCode {
+				t.Fatalf("search = %v, want %v", code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			files := make([]string, 0, len(got.Results))
+			for _, r := range got.Results {
+				// uploads are stored under the job id
+				files = append(files, r.File[strings.Index(r.File, "_")+1:])
+				if r.User != "alice" || !r.Keyword || r.URL == "" {
+					t.Errorf("result = %+v, want a keyword match of alice", r)
+				}
+			}
+			if strings.Join(files, ",") != strings.Join(tt.want, ",") || got.Semantic {
+				t.Errorf("search = %+v, want %v by words only", got, tt.want)
+			}
+		})
+	}
+
+	code, page := get(t, ts.URL+"/search?user=alice&q=talk")
+	if code != http.StatusOK || !strings.Contains(page, `href="/review/alice/`) || !strings.Contains(page, "words only") {
+		t.Errorf("search page = %v, %s, want the result linking to its review", code, page)
+	}
+	if code, page = get(t, ts.URL+"/search"); code != http.StatusOK || !strings.Contains(page, `<form method="get" action="/search">`) || strings.Contains(page, "No transcription") {
+		t.Errorf("search page without query = %v, %s, want the form alone", code, page)
+	}
+}
@@ -0,0 +1,214 @@
+// Package server exposes transcription as an HTTP API: submit audio files as jobs,
+// follow their status and read the results and per-user history from the database.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"tiktok-whisper/internal/app/agent"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/audio"
+	"tiktok-whisper/internal/app/embedding/provider"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/repository/router"
+	"tiktok-whisper/internal/app/slo"
+	"tiktok-whisper/internal/app/translation"
+	"time"
+)
+
+// Options configures a running server.
+type Options struct {
+	Addr string
+	// GRPCAddr serves the gRPC API too when set.
+	GRPCAddr string
+	// UploadDir keeps the submitted audio files, one sub directory per user.
+	UploadDir string
+	// Workers is the number of jobs transcribed at the same time.
+	Workers int
+	// MaxUploadBytes limits the size of a submitted file.
+	MaxUploadBytes int64
+	// Agents transcribes the jobs on the agents registered over gRPC instead of with the server's
+	// transcriber when set, it needs GRPCAddr for the agents to connect to.
+	Agents *agent.Pool
+	// MediaDir keeps the converted audio files, one sub directory per user, for the review page to play.
+	MediaDir string
+	// Translator translates the segments on the review page, it can't translate when nil.
+	Translator translation.Translator
+	// FillerWords are counted by the speech analytics in addition to the built-in ones.
+	FillerWords []string
+	// Embedder embeds the queries of the search page with the model of the vector index, the page
+	// searches by words only when nil.
+	Embedder provider.Embedder
+}
+
+// DefaultMaxUploadBytes is the upload limit when Options.MaxUploadBytes is zero.
+const DefaultMaxUploadBytes = 1 << 30
+
+// Server runs submitted jobs with its transcriber and stores the results in the database of the job's user.
+type Server struct {
+	transcriber api.Transcriber
+	databases   *router.Router
+	monitor     *slo.Monitor
+	bus         events.Bus
+	board       *board
+
+	duration func(filePath string) (int, error)
+
+	opts  Options
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	queue chan *Job
+}
+
+// NewServer creates a new Server instance, monitor checks the providers against their SLOs and may be nil.
+// The jobs are published on bus, whose batch jobs the dashboard follows, it may be nil too.
+func NewServer(transcriber api.Transcriber, databases *router.Router, monitor *slo.Monitor, bus events.Bus) *Server {
+	return &Server{
+		transcriber: transcriber,
+		databases:   databases,
+		monitor:     monitor,
+		bus:         bus,
+		board:       newBoard(),
+		duration:    audio.GetAudioDuration,
+		jobs:        make(map[string]*Job),
+	}
+}
+
+// Run serves the API on opts.Addr until ctx is done, running jobs still finish before it returns.
+func (s *Server) Run(ctx context.Context, opts Options) error {
+	wait := s.startWorkers(opts)
+	defer wait()
+
+	srv := &http.Server{Addr: opts.Addr, Handler: s.Handler()}
+	// the dashboard streams until it is closed
+	srv.RegisterOnShutdown(s.board.close)
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("Serving the API on %s\n", opts.Addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	grpcErrc := make(chan error, 1)
+	if opts.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", opts.GRPCAddr)
+		if err != nil {
+			srv.Close()
+			return fmt.Errorf("listen for gRPC failed: %v", err)
+		}
+		grpcSrv := s.GRPCServer()
+		defer grpcSrv.GracefulStop()
+		go func() {
+			log.Printf("Serving the gRPC API on %s\n", opts.GRPCAddr)
+			grpcErrc <- grpcSrv.Serve(lis)
+		}()
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	case err := <-grpcErrc:
+		srv.Close()
+		return fmt.Errorf("gRPC server failed: %v", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown failed: %v", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// startWorkers starts the job workers, the returned func stops taking jobs and waits for the running ones.
+func (s *Server) startWorkers(opts Options) (wait func()) {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.MaxUploadBytes <= 0 {
+		opts.MaxUploadBytes = DefaultMaxUploadBytes
+	}
+	s.opts = opts
+	if opts.Agents != nil {
+		s.transcriber = opts.Agents
+	}
+	queue := make(chan *Job, 100)
+	s.queue = queue
+	unfollow := s.follow()
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				s.run(job)
+			}
+		}()
+	}
+
+	return func() {
+		s.mu.Lock()
+		close(s.queue)
+		s.queue = nil
+		s.mu.Unlock()
+		wg.Wait()
+		unfollow()
+	}
+}
+
+// enqueue registers job and queues it, it fails when the queue is full or the server is stopping.
+func (s *Server) enqueue(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.queue == nil {
+		return errors.New("server is shutting down")
+	}
+	select {
+	case s.queue <- job:
+		s.jobs[job.ID] = job
+		// under the lock, so the worker reports the job only after it started
+		s.publish(events.Event{Topic: events.TopicJobStarted, JobID: job.ID, User: job.User, Files: 1})
+		return nil
+	default:
+		return errors.New("too many queued jobs")
+	}
+}
+
+func (s *Server) job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// update changes a job under the lock, handlers only ever see consistent copies.
+func (s *Server) update(job *Job, change func(j *Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(job)
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms, the time keeps ids unique regardless
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/router"
+	"tiktok-whisper/internal/app/schema"
+	"tiktok-whisper/internal/app/search"
+	"tiktok-whisper/internal/app/slo"
+	"tiktok-whisper/internal/app/testutil"
+	"time"
+)
+
+type fakeTranscriber struct{}
+
+func (fakeTranscriber) Transcript(inputFilePath string) (string, error) {
+	if strings.HasSuffix(inputFilePath, "broken.mp3") {
+		return "", errors.New("decode failed")
+	}
+	return "hello from " + filepath.Base(inputFilePath), nil
+}
+
+func (f fakeTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	text, err := f.Transcript(inputFilePath)
+	return text, model.ProviderMetadata{Provider: "fake"}, err
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestServerWith(t, Options{})
+}
+
+// newTestServerWith runs a test server with opts, in a temporary upload dir with two workers.
+func newTestServerWith(t *testing.T, opts Options) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	databases := router.New(config.DatabaseConfig{
+		Instances: map[string]config.DatabaseInstance{
+			config.DefaultDatabase: {DSN: filepath.Join(dir, "transcription.db")},
+		},
+	}, nil)
+	t.Cleanup(func() { databases.Close() })
+
+	monitor := slo.NewMonitor(map[string]config.SLOConfig{"fake": {MaxErrorRate: 0.02, MinSamples: 2}}, nil)
+	s := NewServer(fakeTranscriber{}, databases, monitor, events.NewInProcessBus())
+	s.duration = func(filePath string) (int, error) { return 42, nil }
+	opts.UploadDir = filepath.Join(dir, "uploads")
+	opts.Workers = 2
+	wait := s.startWorkers(opts)
+	t.Cleanup(wait)
+
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func submit(t *testing.T, ts *httptest.Server, user, fileName string) (*http.Response, Job) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("user", user)
+	fw, _ := mw.CreateFormFile("file", fileName)
+	fw.Write([]byte("audio"))
+	mw.Close()
+
+	resp, err := http.Post(ts.URL+"/api/v1/jobs", mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var job Job
+	json.NewDecoder(resp.Body).Decode(&job)
+	return resp, job
+}
+
+func getJSON(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	json.NewDecoder(resp.Body).Decode(v)
+	return resp.StatusCode
+}
+
+// waitJob polls the job until it leaves the queue.
+func waitJob(t *testing.T, ts *httptest.Server, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var job Job
+		getJSON(t, ts.URL+"/api/v1/jobs/"+id, &job)
+		if job.Status == JobDone || job.Status == JobFailed {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestServer_Jobs(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		fileName   string
+		wantStatus JobStatus
+		wantCode   int
+		wantText   string
+	}{
+		{name: "done", fileName: "talk.mp3", wantStatus: JobDone, wantCode: http.StatusOK, wantText: "hello from "},
+		{name: "failed", fileName: "broken.mp3", wantStatus: JobFailed, wantCode: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, queued := submit(t, ts, "alice", tt.fileName)
+			if resp.StatusCode != http.StatusAccepted || queued.ID == "" || queued.SchemaVersion != schema.Version {
+				t.Fatalf("submit = %v, %+v", resp.Status, queued)
+			}
+
+			job := waitJob(t, ts, queued.ID)
+			if job.Status != tt.wantStatus {
+				t.Fatalf("job status = %v (%s), want %v", job.Status, job.Error, tt.wantStatus)
+			}
+
+			var result schema.Transcription
+			code := getJSON(t, ts.URL+"/api/v1/jobs/"+job.ID+"/result", &result)
+			if code != tt.wantCode {
+				t.Errorf("result code = %v, want %v", code, tt.wantCode)
+			}
+			if tt.wantText != "" && (!strings.HasPrefix(result.Transcription, tt.wantText) || result.AudioDuration != 42) {
+				t.Errorf("result = %+v", result)
+			}
+		})
+	}
+
+	var history []schema.Transcription
+	if code := getJSON(t, ts.URL+"/api/v1/users/alice/transcriptions", &history); code != http.StatusOK || len(history) != 1 {
+		t.Errorf("history = %v, %+v, want the one successful transcription", code, history)
+	}
+
+	// One of the two jobs failed, far above the 2% error rate of the test server's objective
+	var statuses []slo.Status
+	if code := getJSON(t, ts.URL+"/api/v1/slo", &statuses); code != http.StatusOK || len(statuses) != 1 || !statuses[0].Breached || statuses[0].Samples != 2 {
+		t.Errorf("slo = %v, %+v, want fake breached after two jobs", code, statuses)
+	}
+	resp, err := http.Get(ts.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	page, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), "error rate 50.0% exceeds 2.0%") {
+		t.Errorf("status page = %v, %s, want the breached objective", resp.Status, page)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	ts := newTestServer(t)
+
+	if resp, _ := submit(t, ts, "../etc", "a.mp3"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("submit with invalid user = %v, want 400", resp.Status)
+	}
+
+	var e map[string]string
+	if code := getJSON(t, ts.URL+"/api/v1/jobs/missing", &e); code != http.StatusNotFound {
+		t.Errorf("unknown job = %v, want 404", code)
+	}
+	if code := getJSON(t, ts.URL+"/api/v1/users/alice", &e); code != http.StatusNotFound {
+		t.Errorf("unknown path = %v, want 404", code)
+	}
+}
+
+func TestServer_QuickSearch(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	ts := newTestServer(t)
+
+	_, queued := submit(t, ts, "alice", "talk.mp3")
+	waitJob(t, ts, queued.ID)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantItems int
+	}{
+		{name: "match", query: "?user=alice&q=Hello+talk", wantCode: http.StatusOK, wantItems: 1},
+		{name: "no match", query: "?user=alice&q=goodbye", wantCode: http.StatusOK},
+		{name: "still typing", query: "?user=alice&q=", wantCode: http.StatusOK},
+		{name: "other user", query: "?user=bob&q=hello", wantCode: http.StatusOK},
+		{name: "no user", query: "?q=hello", wantCode: http.StatusBadRequest},
+		{name: "invalid limit", query: "?user=alice&q=hello&limit=0", wantCode: http.StatusBadRequest},
+		{name: "confident only", query: "?user=alice&q=hello&include_low_confidence=false", wantCode: http.StatusOK, wantItems: 1},
+		{name: "invalid include_low_confidence", query: "?user=alice&q=hello&include_low_confidence=maybe", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got search.ScriptFilter
+			code := getJSON(t, ts.URL+"/api/quick-search"+tt.query, &got)
+			if code != tt.wantCode || len(got.Items) != tt.wantItems {
+				t.Fatalf("quick search = %v, %+v, want %v with %d items", code, got, tt.wantCode, tt.wantItems)
+			}
+			if tt.wantItems == 0 {
+				return
+			}
+
+			var detail schema.Transcription
+			if code = getJSON(t, got.Items[0].Arg, &detail); code != http.StatusOK || !strings.HasPrefix(detail.Transcription, "hello from ") {
+				t.Errorf("detail of %s = %v, %+v", got.Items[0].Arg, code, detail)
+			}
+		})
+	}
+
+	var e map[string]string
+	for _, path := range []string{"/api/v1/users/bob/transcriptions/1", "/api/v1/users/alice/transcriptions/x"} {
+		if code := getJSON(t, ts.URL+path, &e); code != http.StatusNotFound {
+			t.Errorf("%s = %v, want 404", path, code)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    string
+		want    api.Options
+		wantErr bool
+	}{
+		{name: "none"},
+		{
+			name: "all",
+			form: "temperature=0.2&translate=true&word_threshold=0.01&max_segment_length=42",
+			want: api.Options{Temperature: 0.2, Translate: true, WordThreshold: 0.01, MaxSegmentLength: 42},
+		},
+		{name: "temperature out of range", form: "temperature=2", wantErr: true},
+		{name: "translate not a bool", form: "translate=maybe", wantErr: true},
+		{name: "negative segment length", form: "max_segment_length=-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(tt.form))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			got, err := parseOptions(r)
+			if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+				t.Errorf("parseOptions() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
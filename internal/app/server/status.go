@@ -0,0 +1,63 @@
+package server
+
+import (
+	_ "embed"
+	"html/template"
+	"log"
+	"net/http"
+	"tiktok-whisper/internal/app/slo"
+	"time"
+)
+
+//go:embed templates/status.html
+var statusHTML string
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"percent": func(f float64) float64 { return f * 100 },
+}).Parse(statusHTML))
+
+type statusPage struct {
+	Generated string
+	Providers []slo.Status
+}
+
+// handleSLO serves /api/v1/slo, the providers without objectives in config.yaml are not listed.
+func (s *Server) handleSLO(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to read the SLOs")
+		return
+	}
+	statuses := s.monitor.Statuses()
+	if statuses == nil {
+		statuses = []slo.Status{}
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to list the agents")
+		return
+	}
+	if s.opts.Agents == nil {
+		writeError(w, http.StatusNotFound, "the jobs don't run on agents, start serve with --agents")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.Agents.Agents())
+}
+
+// handleStatusPage serves /status, a page for operators showing whether the providers meet their SLOs.
+func (s *Server) handleStatusPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET to read the status page")
+		return
+	}
+	page := statusPage{
+		Generated: time.Now().Format(time.RFC3339),
+		Providers: s.monitor.Statuses(),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, page); err != nil {
+		log.Printf("Error writing status page: %v\n", err)
+	}
+}
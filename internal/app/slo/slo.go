@@ -0,0 +1,193 @@
+// Package slo checks the transcription providers against their service level objectives in
+// config.yaml, such as p95 latency under 2x realtime or less than 2% failed transcriptions.
+package slo
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/events"
+	"time"
+)
+
+const (
+	defaultWindow     = time.Hour
+	defaultMinSamples = 10
+)
+
+// Status is how a provider fares against its objectives over the current window.
+type Status struct {
+	Provider string `json:"provider"`
+	Window   string `json:"window"`
+	Samples  int    `json:"samples"`
+	// P95RealtimeFactor is the 95th percentile of transcription time divided by audio duration.
+	P95RealtimeFactor    float64 `json:"p95_realtime_factor"`
+	MaxP95RealtimeFactor float64 `json:"max_p95_realtime_factor,omitempty"`
+	ErrorRate            float64 `json:"error_rate"`
+	MaxErrorRate         float64 `json:"max_error_rate,omitempty"`
+	// Breached is set once the window has enough samples and misses an objective, Reasons tells which.
+	Breached bool     `json:"breached"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+type sample struct {
+	at             time.Time
+	realtimeFactor float64
+	failed         bool
+}
+
+// Monitor records the transcriptions of the providers with objectives, it publishes a
+// provider.unhealthy event when a provider starts to breach them and provider.recovered
+// once it meets them again. A nil Monitor records nothing.
+type Monitor struct {
+	objectives map[string]config.SLOConfig
+	bus        events.Bus
+	now        func() time.Time
+
+	mu       sync.Mutex
+	samples  map[string][]sample
+	breached map[string]bool
+}
+
+// NewMonitor creates a Monitor of the providers in objectives, alerts are published on bus.
+func NewMonitor(objectives map[string]config.SLOConfig, bus events.Bus) *Monitor {
+	return &Monitor{
+		objectives: objectives,
+		bus:        bus,
+		now:        time.Now,
+		samples:    make(map[string][]sample),
+		breached:   make(map[string]bool),
+	}
+}
+
+// Record adds a transcription of provider that took elapsed for audioSeconds of audio and failed with err.
+// Providers without objectives are ignored.
+func (m *Monitor) Record(provider string, elapsed time.Duration, audioSeconds float64, err error) {
+	if m == nil {
+		return
+	}
+	if _, ok := m.objectives[provider]; !ok {
+		return
+	}
+
+	s := sample{at: m.now(), failed: err != nil}
+	if err == nil && audioSeconds > 0 {
+		s.realtimeFactor = elapsed.Seconds() / audioSeconds
+	}
+
+	m.mu.Lock()
+	m.samples[provider] = append(m.samples[provider], s)
+	status := m.evaluate(provider)
+	changed := status.Breached != m.breached[provider]
+	m.breached[provider] = status.Breached
+	m.mu.Unlock()
+
+	if changed {
+		m.alert(status)
+	}
+}
+
+// Statuses returns the status of every provider with objectives, sorted by name.
+func (m *Monitor) Statuses() []Status {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]Status, 0, len(m.objectives))
+	for provider := range m.objectives {
+		statuses = append(statuses, m.evaluate(provider))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Provider < statuses[j].Provider })
+	return statuses
+}
+
+// evaluate drops the samples that left the window of provider and checks the rest, m.mu must be held.
+func (m *Monitor) evaluate(provider string) Status {
+	objective := m.objectives[provider]
+	window := objective.Window
+	if window <= 0 {
+		window = defaultWindow
+	}
+	minSamples := objective.MinSamples
+	if minSamples <= 0 {
+		minSamples = defaultMinSamples
+	}
+
+	samples := m.samples[provider]
+	since := m.now().Add(-window)
+	for len(samples) > 0 && samples[0].at.Before(since) {
+		samples = samples[1:]
+	}
+	m.samples[provider] = samples
+
+	status := Status{
+		Provider:             provider,
+		Window:               window.String(),
+		Samples:              len(samples),
+		MaxP95RealtimeFactor: objective.P95RealtimeFactor,
+		MaxErrorRate:         objective.MaxErrorRate,
+	}
+	if len(samples) == 0 {
+		return status
+	}
+
+	var failed int
+	var factors []float64
+	for _, s := range samples {
+		if s.failed {
+			failed++
+		} else if s.realtimeFactor > 0 {
+			factors = append(factors, s.realtimeFactor)
+		}
+	}
+	status.ErrorRate = float64(failed) / float64(len(samples))
+	status.P95RealtimeFactor = percentile(factors, 0.95)
+
+	if len(samples) < minSamples {
+		return status
+	}
+	if objective.P95RealtimeFactor > 0 && status.P95RealtimeFactor > objective.P95RealtimeFactor {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("p95 latency %.2fx realtime exceeds %.2fx",
+			status.P95RealtimeFactor, objective.P95RealtimeFactor))
+	}
+	if objective.MaxErrorRate > 0 && status.ErrorRate > objective.MaxErrorRate {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("error rate %.1f%% exceeds %.1f%%",
+			status.ErrorRate*100, objective.MaxErrorRate*100))
+	}
+	status.Breached = len(status.Reasons) > 0
+	return status
+}
+
+func (m *Monitor) alert(status Status) {
+	e := events.Event{Topic: events.TopicProviderRecovered, Provider: status.Provider}
+	if status.Breached {
+		e.Topic = events.TopicProviderUnhealthy
+		e.Error = strings.Join(status.Reasons, ", ")
+		log.Printf("Provider %s breaches its SLO over %s: %s\n", status.Provider, status.Window, e.Error)
+	} else {
+		log.Printf("Provider %s meets its SLO again\n", status.Provider)
+	}
+	if m.bus != nil {
+		m.bus.Publish(e)
+	}
+}
+
+// percentile returns the nearest-rank p percentile of values, zero when there are none.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,105 @@
+package slo
+
+import (
+	"errors"
+	"testing"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/events"
+	"time"
+)
+
+type call struct {
+	elapsed time.Duration
+	failed  bool
+}
+
+func calls(n int, c call) []call {
+	cs := make([]call, n)
+	for i := range cs {
+		cs[i] = c
+	}
+	return cs
+}
+
+func TestMonitor_Statuses(t *testing.T) {
+	objective := config.SLOConfig{P95RealtimeFactor: 2, MaxErrorRate: 0.1, MinSamples: 10}
+	fast := call{elapsed: 10 * time.Second}
+	slow := call{elapsed: 50 * time.Second}
+	failed := call{failed: true}
+
+	tests := []struct {
+		name        string
+		calls       []call
+		wantP95     float64
+		wantBreach  bool
+		wantReasons int
+	}{
+		{name: "healthy", calls: calls(20, fast), wantP95: 0.5},
+		{name: "slow tail", calls: append(calls(18, fast), calls(2, slow)...), wantP95: 2.5, wantBreach: true, wantReasons: 1},
+		{name: "tail within p95", calls: append(calls(19, fast), slow), wantP95: 0.5},
+		{name: "failing", calls: append(calls(8, fast), calls(2, failed)...), wantP95: 0.5, wantBreach: true, wantReasons: 1},
+		{name: "too few samples", calls: calls(5, failed)},
+		{name: "both", calls: append(calls(8, slow), calls(2, failed)...), wantP95: 2.5, wantBreach: true, wantReasons: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMonitor(map[string]config.SLOConfig{"openai": objective}, nil)
+			for _, c := range tt.calls {
+				var err error
+				if c.failed {
+					err = errors.New("timeout")
+				}
+				m.Record("openai", c.elapsed, 20, err)
+			}
+			m.Record("whisper_cpp", time.Hour, 1, nil)
+
+			statuses := m.Statuses()
+			if len(statuses) != 1 {
+				t.Fatalf("Statuses() = %+v, want only openai", statuses)
+			}
+			got := statuses[0]
+			if got.P95RealtimeFactor != tt.wantP95 || got.Breached != tt.wantBreach || len(got.Reasons) != tt.wantReasons {
+				t.Errorf("Statuses() = %+v, want p95 %v, breached %v with %d reasons", got, tt.wantP95, tt.wantBreach, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func TestMonitor_Alerts(t *testing.T) {
+	bus := events.NewInProcessBus()
+	received := make(chan events.Event, 10)
+	bus.Subscribe(events.TopicAll, func(e events.Event) { received <- e })
+
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMonitor(map[string]config.SLOConfig{"openai": {MaxErrorRate: 0.02, MinSamples: 2, Window: time.Minute}}, bus)
+	m.now = func() time.Time { return now }
+
+	m.Record("openai", time.Second, 10, errors.New("timeout"))
+	m.Record("openai", time.Second, 10, errors.New("timeout"))
+	m.Record("openai", time.Second, 10, errors.New("timeout"))
+	// The failures leave the window, the next success recovers the provider
+	now = now.Add(2 * time.Minute)
+	m.Record("openai", time.Second, 10, nil)
+	m.Record("openai", time.Second, 10, nil)
+	bus.Close()
+	close(received)
+
+	var got []events.Topic
+	for e := range received {
+		if e.Provider != "openai" {
+			t.Errorf("event %+v, want provider openai", e)
+		}
+		got = append(got, e.Topic)
+	}
+	if len(got) != 2 || got[0] != events.TopicProviderUnhealthy || got[1] != events.TopicProviderRecovered {
+		t.Errorf("alerts = %v, want one unhealthy and one recovered", got)
+	}
+}
+
+func TestMonitor_Nil(t *testing.T) {
+	var m *Monitor
+	m.Record("openai", time.Second, 10, nil)
+	if got := m.Statuses(); got != nil {
+		t.Errorf("Statuses() of a nil Monitor = %v", got)
+	}
+}
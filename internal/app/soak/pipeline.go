@@ -0,0 +1,46 @@
+package soak
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/converter"
+	"tiktok-whisper/internal/app/util/files"
+)
+
+// ConvertJob returns a job that converts a fresh copy of sample with c, like convert -a does,
+// and removes the copy and its transcription afterwards so the disk doesn't fill up.
+func ConvertJob(c *converter.Converter, sample string, workDir string) func(i int) error {
+	inputDir := filepath.Join(workDir, "input")
+	outputDir := filepath.Join(workDir, "transcription")
+	ext := filepath.Ext(sample)
+
+	return func(i int) error {
+		data, err := os.ReadFile(sample)
+		if err != nil {
+			return err
+		}
+		if err = os.MkdirAll(inputDir, 0755); err != nil {
+			return err
+		}
+		// A new name each time, so nothing is skipped as transcribed before
+		name := fmt.Sprintf("soak-%06d", i)
+		input := filepath.Join(inputDir, name+ext)
+		if err = os.WriteFile(input, data, 0644); err != nil {
+			return err
+		}
+		defer os.Remove(input)
+
+		if err = c.ConvertAudios([]string{input}, outputDir, 1); err != nil {
+			return err
+		}
+
+		output := filepath.Join(outputDir, strings.TrimSuffix(name+ext, ext)+".txt")
+		defer os.Remove(output)
+		if !files.IsComplete(output) {
+			return fmt.Errorf("no transcription of %s", input)
+		}
+		return nil
+	}
+}
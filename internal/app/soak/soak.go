@@ -0,0 +1,143 @@
+// Package soak runs jobs for hours while sampling the process, to find the memory, goroutine
+// and file descriptor leaks that slow down long batch runs.
+package soak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ErrLeak is returned when a resource grew beyond its limit during the run.
+var ErrLeak = errors.New("resource leak")
+
+// Limits is how much each resource may grow between the baseline and the end of the run, zero doesn't check it.
+type Limits struct {
+	HeapGrowthBytes uint64
+	GoroutineGrowth int
+	FDGrowth        int
+}
+
+// Options configures a soak run.
+type Options struct {
+	Duration time.Duration
+	// Interval is the time between samples, they are taken between jobs.
+	Interval time.Duration
+	// Warmup runs jobs before the baseline is taken, so caches and pools are filled by then.
+	Warmup time.Duration
+	Limits Limits
+	// OnSample is called with every sample, e.g. to log or write them as they come.
+	OnSample func(Sample)
+}
+
+// Sample is the state of the process at a point of the run.
+type Sample struct {
+	Elapsed time.Duration
+	Jobs    int
+	Failed  int
+	// HeapBytes is the live heap after a garbage collection.
+	HeapBytes  uint64
+	Goroutines int
+	// FDs is the number of open file descriptors, -1 where the platform can't list them.
+	FDs int
+}
+
+// Report summarizes a soak run.
+type Report struct {
+	Jobs     int
+	Failed   int
+	Baseline Sample
+	Last     Sample
+	Samples  []Sample
+	// Leaks describes the resources that grew beyond their limits.
+	Leaks []string
+}
+
+// Run calls job with increasing numbers until opts.Duration passed or ctx is done. It fails with ErrLeak
+// when a resource grew beyond its limit, and when every job failed as nothing was soaked then.
+func Run(ctx context.Context, opts Options, job func(i int) error) (Report, error) {
+	var report Report
+	start := time.Now()
+	take := func() Sample {
+		s := TakeSample()
+		s.Elapsed = time.Since(start)
+		s.Jobs, s.Failed = report.Jobs, report.Failed
+		report.Samples = append(report.Samples, s)
+		if opts.OnSample != nil {
+			opts.OnSample(s)
+		}
+		return s
+	}
+
+	baselineTaken := false
+	nextSample := start
+	for time.Since(start) < opts.Duration && ctx.Err() == nil {
+		if err := job(report.Jobs); err != nil {
+			report.Failed++
+		}
+		report.Jobs++
+
+		if !baselineTaken && time.Since(start) >= opts.Warmup {
+			report.Baseline = take()
+			baselineTaken = true
+			nextSample = time.Now().Add(opts.Interval)
+		} else if baselineTaken && !time.Now().Before(nextSample) {
+			take()
+			nextSample = time.Now().Add(opts.Interval)
+		}
+	}
+	if !baselineTaken {
+		return report, errors.New("the run ended before the warmup, no baseline was taken")
+	}
+	report.Last = take()
+
+	if report.Jobs > 0 && report.Failed == report.Jobs {
+		return report, fmt.Errorf("all %d jobs failed", report.Jobs)
+	}
+	report.Leaks = findLeaks(report.Baseline, report.Last, opts.Limits)
+	if len(report.Leaks) > 0 {
+		return report, fmt.Errorf("%w: %s", ErrLeak, strings.Join(report.Leaks, "; "))
+	}
+	return report, nil
+}
+
+func findLeaks(baseline Sample, last Sample, limits Limits) []string {
+	var leaks []string
+	if limits.HeapGrowthBytes > 0 && last.HeapBytes > baseline.HeapBytes+limits.HeapGrowthBytes {
+		leaks = append(leaks, fmt.Sprintf("heap grew from %d to %d bytes, limit is %d", baseline.HeapBytes, last.HeapBytes, limits.HeapGrowthBytes))
+	}
+	if limits.GoroutineGrowth > 0 && last.Goroutines > baseline.Goroutines+limits.GoroutineGrowth {
+		leaks = append(leaks, fmt.Sprintf("goroutines grew from %d to %d, limit is %d", baseline.Goroutines, last.Goroutines, limits.GoroutineGrowth))
+	}
+	if limits.FDGrowth > 0 && baseline.FDs >= 0 && last.FDs > baseline.FDs+limits.FDGrowth {
+		leaks = append(leaks, fmt.Sprintf("open files grew from %d to %d, limit is %d", baseline.FDs, last.FDs, limits.FDGrowth))
+	}
+	return leaks
+}
+
+// TakeSample collects the garbage and samples the process.
+func TakeSample() Sample {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return Sample{
+		HeapBytes:  m.HeapAlloc,
+		Goroutines: runtime.NumGoroutine(),
+		FDs:        OpenFiles(),
+	}
+}
+
+// OpenFiles returns the number of open file descriptors of the process, -1 where it can't be listed.
+func OpenFiles() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			// Reading the directory opened one of them
+			return len(entries) - 1
+		}
+	}
+	return -1
+}
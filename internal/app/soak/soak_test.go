@@ -0,0 +1,106 @@
+package soak
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"tiktok-whisper/internal/app/testutil"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		job      func(t *testing.T, stop chan struct{}) func(i int) error
+		limits   Limits
+		wantLeak bool
+		wantErr  bool
+	}{
+		{
+			name: "steady",
+			job: func(t *testing.T, stop chan struct{}) func(i int) error {
+				return func(i int) error {
+					time.Sleep(time.Millisecond)
+					return nil
+				}
+			},
+			limits: Limits{HeapGrowthBytes: 10 << 20, GoroutineGrowth: 5, FDGrowth: 5},
+		},
+		{
+			name: "goroutine leak",
+			job: func(t *testing.T, stop chan struct{}) func(i int) error {
+				return func(i int) error {
+					go func() { <-stop }()
+					time.Sleep(time.Millisecond)
+					return nil
+				}
+			},
+			limits:   Limits{GoroutineGrowth: 5},
+			wantLeak: true,
+			wantErr:  true,
+		},
+		{
+			name: "file leak",
+			job: func(t *testing.T, stop chan struct{}) func(i int) error {
+				dir := t.TempDir()
+				var opened []*os.File
+				t.Cleanup(func() {
+					for _, f := range opened {
+						f.Close()
+					}
+				})
+				return func(i int) error {
+					f, err := os.Create(filepath.Join(dir, "leak"))
+					opened = append(opened, f)
+					time.Sleep(time.Millisecond)
+					return err
+				}
+			},
+			limits:   Limits{FDGrowth: 5},
+			wantLeak: OpenFiles() >= 0,
+			wantErr:  OpenFiles() >= 0,
+		},
+		{
+			name: "failing",
+			job: func(t *testing.T, stop chan struct{}) func(i int) error {
+				return func(i int) error { return errors.New("provider unavailable") }
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stop := make(chan struct{})
+			defer close(stop)
+
+			report, err := Run(context.Background(), Options{
+				Duration: 200 * time.Millisecond,
+				Interval: 20 * time.Millisecond,
+				Warmup:   20 * time.Millisecond,
+				Limits:   tt.limits,
+			}, tt.job(t, stop))
+			if (err != nil) != tt.wantErr || errors.Is(err, ErrLeak) != tt.wantLeak {
+				t.Fatalf("Run() error = %v, wantErr %v, wantLeak %v", err, tt.wantErr, tt.wantLeak)
+			}
+			if report.Jobs == 0 || len(report.Samples) < 2 || report.Last.Elapsed < report.Baseline.Elapsed {
+				t.Errorf("Run() report = %d jobs, %d samples", report.Jobs, len(report.Samples))
+			}
+		})
+	}
+}
+
+func TestRun_Canceled(t *testing.T) {
+	testutil.VerifyNoLeaks(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	report, err := Run(ctx, Options{Duration: time.Hour}, func(i int) error {
+		if i == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil || report.Jobs != 3 {
+		t.Errorf("Run() = %d jobs, %v, want it to stop after the job that canceled it", report.Jobs, err)
+	}
+}
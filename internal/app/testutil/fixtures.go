@@ -0,0 +1,152 @@
+package testutil
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"time"
+)
+
+// FixtureFile is a converted file of a generated corpus, its fields are the arguments of RecordToDB.
+type FixtureFile struct {
+	User               string
+	InputDir           string
+	FileName           string
+	Mp3FileName        string
+	AudioDuration      int
+	Transcription      string
+	LastConversionTime time.Time
+	// HasError is 1 for a failed conversion, its ErrorMessage says why and it has no transcription.
+	HasError         int
+	ErrorMessage     string
+	ProviderMetadata model.ProviderMetadata
+}
+
+// TestCorpus is a synthetic dataset, the same seed and size always generate the same corpus.
+type TestCorpus struct {
+	Seed  int64
+	Users []string
+	Files []FixtureFile
+}
+
+// corpusStart is the time of the first generated conversion, fixed so corpora are reproducible.
+var corpusStart = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Distributions of the generated corpus, roughly those of the TikTok and podcast batches v2t converts.
+const (
+	filesPerUser = 50
+	errorRate    = 0.05
+	// Durations are log-normal around three minutes, clamped to 5 seconds and 2 hours
+	medianSeconds = 180
+	durationSigma = 1.0
+)
+
+var fixtureLanguages = []struct {
+	code   string
+	weight float64
+	// perSecond is how many words (characters for zh and ja) are spoken per second
+	perSecond float64
+	separator string
+	words     []string
+}{
+	{code: "zh", weight: 0.6, perSecond: 4, words: strings.Split("我们今天来聊一下这个视频里面的内容大家好谢谢观看关注点赞分享生活工作学习", "")},
+	{code: "en", weight: 0.3, perSecond: 2.5, separator: " ", words: strings.Fields("the and to of a in that is it you for this we on with so what about today video")},
+	{code: "ja", weight: 0.1, perSecond: 5, words: strings.Split("こんにちは今日はこの動画について話しますありがとうございました", "")},
+}
+
+var fixtureProviders = []struct {
+	name   string
+	model  string
+	weight float64
+}{
+	{name: "whisper_cpp", model: "ggml-large-v2", weight: 0.6},
+	{name: "openai", model: "whisper-1", weight: 0.4},
+}
+
+var fixtureErrors = []string{
+	"FFmpeg error: exit status 1",
+	"Failed to get audio duration: invalid data found when processing input",
+	"Transcription error: 503 service unavailable",
+	"Transcription error: 400 invalid file format",
+}
+
+// GenerateTestCorpus generates n converted files: users own a Zipf distributed share of them,
+// durations are log-normal and the text length follows the duration and language, a few
+// conversions failed. The same seed and n always generate the same corpus.
+func GenerateTestCorpus(seed int64, n int) TestCorpus {
+	r := rand.New(rand.NewSource(seed))
+	corpus := TestCorpus{Seed: seed}
+
+	userCount := n/filesPerUser + 1
+	for i := 0; i < userCount; i++ {
+		corpus.Users = append(corpus.Users, fmt.Sprintf("user%03d", i))
+	}
+	// A few users own most files, like the accounts of prolific creators
+	zipf := rand.NewZipf(r, 1.2, 1, uint64(userCount-1))
+
+	conversionTime := corpusStart
+	for i := 0; i < n; i++ {
+		user := corpus.Users[zipf.Uint64()]
+		fileName := fmt.Sprintf("%s_%06d.mp4", user, i)
+		// Conversions are minutes apart, in the order of their files
+		conversionTime = conversionTime.Add(time.Duration(1+r.Intn(30)) * time.Minute)
+
+		f := FixtureFile{
+			User:               user,
+			InputDir:           "/data/mp4/" + user,
+			FileName:           fileName,
+			Mp3FileName:        strings.TrimSuffix(fileName, ".mp4") + ".mp3",
+			AudioDuration:      fixtureDuration(r),
+			LastConversionTime: conversionTime,
+		}
+
+		provider := fixtureProviders[pick(r, len(fixtureProviders), func(i int) float64 { return fixtureProviders[i].weight })]
+		lang := fixtureLanguages[pick(r, len(fixtureLanguages), func(i int) float64 { return fixtureLanguages[i].weight })]
+		f.ProviderMetadata = model.ProviderMetadata{
+			Provider:        provider.name,
+			Model:           provider.model,
+			Language:        lang.code,
+			DurationSeconds: float64(f.AudioDuration),
+		}
+
+		if r.Float64() < errorRate {
+			f.HasError = 1
+			f.ErrorMessage = fixtureErrors[r.Intn(len(fixtureErrors))]
+		} else {
+			words := make([]string, int(float64(f.AudioDuration)*lang.perSecond))
+			for j := range words {
+				words[j] = lang.words[r.Intn(len(lang.words))]
+			}
+			f.Transcription = strings.Join(words, lang.separator)
+		}
+		corpus.Files = append(corpus.Files, f)
+	}
+	return corpus
+}
+
+// Load records the files of the corpus with dao.
+func (c TestCorpus) Load(dao repository.TranscriptionDAO) {
+	for _, f := range c.Files {
+		dao.RecordToDB(f.User, f.InputDir, f.FileName, f.Mp3FileName, f.AudioDuration, f.Transcription,
+			f.LastConversionTime, f.HasError, f.ErrorMessage, f.ProviderMetadata)
+	}
+}
+
+func fixtureDuration(r *rand.Rand) int {
+	seconds := medianSeconds * math.Exp(r.NormFloat64()*durationSigma)
+	return int(math.Min(math.Max(seconds, 5), 7200))
+}
+
+// pick returns an index drawn with the weights, they add up to one.
+func pick(r *rand.Rand, n int, weight func(i int) float64) int {
+	x := r.Float64()
+	for i := 0; i < n-1; i++ {
+		if x -= weight(i); x < 0 {
+			return i
+		}
+	}
+	return n - 1
+}
@@ -0,0 +1,71 @@
+package testutil
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tiktok-whisper/internal/app/repository/sqlite"
+)
+
+func TestGenerateTestCorpus(t *testing.T) {
+	corpus := GenerateTestCorpus(42, 2000)
+	if !reflect.DeepEqual(corpus, GenerateTestCorpus(42, 2000)) {
+		t.Fatalf("GenerateTestCorpus() with the same seed generated different corpora")
+	}
+	if reflect.DeepEqual(corpus.Files[:10], GenerateTestCorpus(7, 2000).Files[:10]) {
+		t.Errorf("GenerateTestCorpus() with another seed generated the same files")
+	}
+	if len(corpus.Files) != 2000 || len(corpus.Users) != 41 {
+		t.Fatalf("GenerateTestCorpus() = %d files of %d users, want 2000 of 41", len(corpus.Files), len(corpus.Users))
+	}
+
+	failed := 0
+	languages := make(map[string]int)
+	perUser := make(map[string]int)
+	for _, f := range corpus.Files {
+		if f.HasError == 1 {
+			failed++
+			if f.Transcription != "" || f.ErrorMessage == "" {
+				t.Errorf("failed file %+v has a transcription or no error", f)
+			}
+		} else if f.Transcription == "" {
+			t.Errorf("converted file %+v has no transcription", f)
+		}
+		if f.AudioDuration < 5 || f.AudioDuration > 7200 {
+			t.Errorf("duration %d is out of range", f.AudioDuration)
+		}
+		languages[f.ProviderMetadata.Language]++
+		perUser[f.User]++
+	}
+	if failed < 60 || failed > 140 {
+		t.Errorf("%d of 2000 files failed, want about 5%%", failed)
+	}
+	if languages["zh"] < languages["en"] || languages["en"] < languages["ja"] || languages["ja"] == 0 {
+		t.Errorf("languages = %v, want mostly zh, then en and ja", languages)
+	}
+	if perUser[corpus.Users[0]] < 2000/4 {
+		t.Errorf("the first user owns %d files, want the largest share", perUser[corpus.Users[0]])
+	}
+}
+
+func TestTestCorpus_Load(t *testing.T) {
+	db := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "transcription.db"))
+	defer db.Close()
+
+	corpus := GenerateTestCorpus(1, 100)
+	corpus.Load(db)
+
+	got, err := db.GetAllByUser(corpus.Users[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 0
+	for _, f := range corpus.Files {
+		if f.User == corpus.Users[0] && f.HasError == 0 {
+			want++
+		}
+	}
+	if len(got) != want {
+		t.Errorf("GetAllByUser() = %d transcriptions, want %d", len(got), want)
+	}
+}
@@ -0,0 +1,164 @@
+// Package testutil holds helpers shared by the tests of several packages.
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TempPrefix starts the names of the temp files and directories v2t creates in os.TempDir,
+// VerifyNoLeaks reports the ones a test leaves behind.
+const TempPrefix = "v2t-"
+
+// settleTimeout is how long VerifyNoLeaks waits for goroutines and files that are still being closed.
+var settleTimeout = 2 * time.Second
+
+// VerifyNoLeaks fails the test when it leaves goroutines running, files open, v2t temp files in
+// os.TempDir or new entries in dirs behind. Call it first, so its check runs after the cleanups
+// the test registers later, e.g. the ones closing its servers and databases. Tests using t.Parallel
+// can't use it, the goroutines and files of the other tests would count as leaked.
+func VerifyNoLeaks(tb testing.TB, dirs ...string) {
+	tb.Helper()
+	goroutines := goroutineStacks()
+	files := openFiles()
+	watched := append([]string{os.TempDir()}, dirs...)
+	entries := make([]map[string]bool, len(watched))
+	for i, dir := range watched {
+		entries[i] = dirEntries(dir)
+	}
+
+	tb.Cleanup(func() {
+		var leaked []string
+		deadline := time.Now().Add(settleTimeout)
+		for {
+			leaked = leakedGoroutines(goroutines, goroutineStacks())
+			leaked = append(leaked, leakedFiles(files, openFiles())...)
+			for i, dir := range watched {
+				leaked = append(leaked, leakedEntries(dir, i == 0, entries[i], dirEntries(dir))...)
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		for _, l := range leaked {
+			tb.Errorf("leaked %s", l)
+		}
+	})
+}
+
+// goroutineStacks returns the stacks of all goroutines by their id.
+func goroutineStacks() map[string]string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	stacks := make(map[string]string)
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		// goroutine 7 [chan receive]:
+		fields := strings.Fields(stack)
+		if len(fields) < 2 || fields[0] != "goroutine" {
+			continue
+		}
+		stacks[fields[1]] = stack
+	}
+	return stacks
+}
+
+// ignoredGoroutines run for the test binary itself, they are started by the first test that needs them.
+var ignoredGoroutines = []string{
+	"os/signal.signal_recv(",
+	"runtime.ensureSigM(",
+	"created by testing.",
+}
+
+func leakedGoroutines(before map[string]string, after map[string]string) []string {
+	var leaked []string
+	for id, stack := range after {
+		if _, ok := before[id]; ok || ignoredGoroutine(stack) {
+			continue
+		}
+		leaked = append(leaked, stack)
+	}
+	sort.Strings(leaked)
+	return leaked
+}
+
+func ignoredGoroutine(stack string) bool {
+	for _, ignored := range ignoredGoroutines {
+		if strings.Contains(stack, ignored) {
+			return true
+		}
+	}
+	return false
+}
+
+// openFiles returns the targets of the open file descriptors by descriptor, nil where they can't be listed.
+// The network poller's descriptors are left out, the runtime opens them on first use and keeps them.
+func openFiles() map[string]string {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return nil
+	}
+	files := make(map[string]string, len(entries))
+	for _, e := range entries {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", e.Name()))
+		if err != nil {
+			// The descriptor that read the directory is closed by now
+			continue
+		}
+		if strings.HasPrefix(target, "anon_inode:") {
+			continue
+		}
+		files[e.Name()] = target
+	}
+	return files
+}
+
+func leakedFiles(before map[string]string, after map[string]string) []string {
+	if before == nil {
+		return nil
+	}
+	var leaked []string
+	for fd, target := range after {
+		if before[fd] == target {
+			continue
+		}
+		leaked = append(leaked, "open file "+target)
+	}
+	sort.Strings(leaked)
+	return leaked
+}
+
+func dirEntries(dir string) map[string]bool {
+	entries, _ := os.ReadDir(dir)
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	return names
+}
+
+// leakedEntries returns the new entries of dir, only the v2t ones when it is shared with other processes.
+func leakedEntries(dir string, shared bool, before map[string]bool, after map[string]bool) []string {
+	var leaked []string
+	for name := range after {
+		if before[name] || (shared && !strings.HasPrefix(name, TempPrefix)) {
+			continue
+		}
+		leaked = append(leaked, "temp file "+filepath.Join(dir, name))
+	}
+	sort.Strings(leaked)
+	return leaked
+}
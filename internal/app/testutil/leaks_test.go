@@ -0,0 +1,119 @@
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder collects what VerifyNoLeaks reports instead of failing the test.
+type recorder struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) runCleanups() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestVerifyNoLeaks(t *testing.T) {
+	original := settleTimeout
+	settleTimeout = 50 * time.Millisecond
+	defer func() { settleTimeout = original }()
+
+	watched := t.TempDir()
+	tests := []struct {
+		name string
+		// leak runs as the test, the returned func undoes the leak after the check
+		leak func(t *testing.T) func()
+		want string
+	}{
+		{
+			name: "clean",
+			leak: func(t *testing.T) func() {
+				done := make(chan struct{})
+				go func() { close(done) }()
+				<-done
+				f, _ := os.Create(filepath.Join(watched, "closed"))
+				f.Close()
+				os.Remove(f.Name())
+				return func() {}
+			},
+		},
+		{
+			name: "goroutine",
+			leak: func(t *testing.T) func() {
+				stop := make(chan struct{})
+				go func() { <-stop }()
+				return func() { close(stop) }
+			},
+			want: "goroutine ",
+		},
+		{
+			name: "open file",
+			leak: func(t *testing.T) func() {
+				if openFiles() == nil {
+					t.Skip("open files can't be listed on this platform")
+				}
+				f, err := os.Create(filepath.Join(t.TempDir(), "open"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return func() { f.Close() }
+			},
+			want: "open file ",
+		},
+		{
+			name: "temp file",
+			leak: func(t *testing.T) func() {
+				dir, err := os.MkdirTemp("", TempPrefix+"leak-")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return func() { os.RemoveAll(dir) }
+			},
+			want: "temp file " + filepath.Join(os.TempDir(), TempPrefix+"leak-"),
+		},
+		{
+			name: "watched dir",
+			leak: func(t *testing.T) func() {
+				path := filepath.Join(watched, "talk.txt.partial")
+				os.WriteFile(path, nil, 0644)
+				return func() { os.Remove(path) }
+			},
+			want: "temp file " + filepath.Join(watched, "talk.txt.partial"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			VerifyNoLeaks(r, watched)
+			undo := tt.leak(t)
+			r.runCleanups()
+			undo()
+
+			if tt.want == "" && len(r.errors) > 0 {
+				t.Errorf("VerifyNoLeaks() reported %q, want nothing", r.errors)
+			}
+			if tt.want != "" && (len(r.errors) != 1 || !strings.Contains(r.errors[0], tt.want)) {
+				t.Errorf("VerifyNoLeaks() reported %q, want one leak of %q", r.errors, tt.want)
+			}
+		})
+	}
+}
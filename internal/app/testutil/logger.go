@@ -0,0 +1,79 @@
+package testutil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"tiktok-whisper/internal/app/logging"
+)
+
+// LogEntry is a message a MockLogger recorded, Fields hold its keys and values and those of With.
+type LogEntry struct {
+	Level  string
+	Msg    string
+	Fields map[string]interface{}
+}
+
+// MockLogger is a logging.Logger recording its messages, so tests can assert on what was logged.
+type MockLogger struct {
+	mu      *sync.Mutex
+	entries *[]LogEntry
+	fields  []interface{}
+}
+
+// NewMockLogger returns an empty MockLogger.
+func NewMockLogger() *MockLogger {
+	return &MockLogger{mu: &sync.Mutex{}, entries: &[]LogEntry{}}
+}
+
+// UseMockLogger makes a new MockLogger the logger of logging.L until the test ends.
+func UseMockLogger(tb testing.TB) *MockLogger {
+	m := NewMockLogger()
+	tb.Cleanup(logging.SetDefault(m))
+	return m
+}
+
+func (m *MockLogger) Debug(msg string, kv ...interface{}) { m.record("debug", msg, kv) }
+func (m *MockLogger) Info(msg string, kv ...interface{})  { m.record("info", msg, kv) }
+func (m *MockLogger) Warn(msg string, kv ...interface{})  { m.record("warn", msg, kv) }
+func (m *MockLogger) Error(msg string, kv ...interface{}) { m.record("error", msg, kv) }
+
+// With returns a logger recording into the same entries with kv added.
+func (m *MockLogger) With(kv ...interface{}) logging.Logger {
+	return &MockLogger{mu: m.mu, entries: m.entries, fields: append(append([]interface{}{}, m.fields...), kv...)}
+}
+
+func (m *MockLogger) record(level string, msg string, kv []interface{}) {
+	fields := map[string]interface{}{}
+	all := append(append([]interface{}{}, m.fields...), kv...)
+	for i := 0; i < len(all); i += 2 {
+		key := fmt.Sprint(all[i])
+		if i+1 < len(all) {
+			fields[key] = all[i+1]
+		} else {
+			fields[key] = nil
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*m.entries = append(*m.entries, LogEntry{Level: level, Msg: msg, Fields: fields})
+}
+
+// Entries returns the recorded messages in order.
+func (m *MockLogger) Entries() []LogEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]LogEntry{}, *m.entries...)
+}
+
+// Find returns the first message at level containing msg, false when there is none.
+func (m *MockLogger) Find(level string, msg string) (LogEntry, bool) {
+	for _, e := range m.Entries() {
+		if e.Level == level && strings.Contains(e.Msg, msg) {
+			return e, true
+		}
+	}
+	return LogEntry{}, false
+}
@@ -0,0 +1,378 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultServiceName = "v2t"
+	scopeName          = "tiktok-whisper"
+	defaultTimeout     = 10 * time.Second
+
+	// The batch of spans is sent once it holds maxBatch spans or batchDelay passed,
+	// a span ended while queueSize spans wait is dropped.
+	maxBatch   = 512
+	batchDelay = 5 * time.Second
+	queueSize  = 2048
+)
+
+// Config is where and how the spans are exported, see configFromEnv.
+type Config struct {
+	// Endpoint is the URL spans are POSTed to, e.g. http://localhost:4318/v1/traces.
+	Endpoint    string
+	Headers     map[string]string
+	Timeout     time.Duration
+	ServiceName string
+	// Resource are further attributes of the process, e.g. deployment.environment.
+	Resource []Attribute
+}
+
+// Init configures tracing from the environment and returns the function flushing the spans that
+// weren't exported yet, to be called before the process exits. Tracing stays disabled unless
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set:
+//
+//   - OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is used as it is, OTEL_EXPORTER_OTLP_ENDPOINT gets /v1/traces appended
+//   - OTEL_EXPORTER_OTLP_HEADERS, e.g. "authorization=Bearer%20token,x-team=a", values are URL-decoded
+//   - OTEL_EXPORTER_OTLP_TIMEOUT in milliseconds, 10000 by default
+//   - OTEL_EXPORTER_OTLP_PROTOCOL must be http/json, the only protocol supported
+//   - OTEL_SERVICE_NAME, v2t by default, and OTEL_RESOURCE_ATTRIBUTES, e.g. "deployment.environment=prod"
+//   - OTEL_SDK_DISABLED=true or OTEL_TRACES_EXPORTER=none disable tracing
+//
+// The TRACES_ variants of the OTLP variables take precedence over the general ones.
+func Init() (func(context.Context) error, error) {
+	cfg, ok, err := configFromEnv(os.Getenv)
+	if err != nil || !ok {
+		return func(context.Context) error { return nil }, err
+	}
+	return Setup(cfg), nil
+}
+
+// Setup enables tracing with the spans exported as cfg says and returns the function flushing them.
+func Setup(cfg Config) func(context.Context) error {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = defaultServiceName
+	}
+	e := newOTLPExporter(cfg)
+	setGlobal(&Tracer{exporter: e})
+	return func(ctx context.Context) error {
+		setGlobal(nil)
+		return e.shutdown(ctx)
+	}
+}
+
+// configFromEnv reads the Config of the OTEL_* variables of getenv, ok is false when tracing is off.
+func configFromEnv(getenv func(string) string) (cfg Config, ok bool, err error) {
+	if strings.EqualFold(getenv("OTEL_SDK_DISABLED"), "true") {
+		return Config{}, false, nil
+	}
+	switch exporter := getenv("OTEL_TRACES_EXPORTER"); exporter {
+	case "", "otlp":
+	case "none":
+		return Config{}, false, nil
+	default:
+		return Config{}, false, fmt.Errorf("OTEL_TRACES_EXPORTER %q is not supported, use otlp or none", exporter)
+	}
+
+	lookup := func(name string) string {
+		if v := getenv("OTEL_EXPORTER_OTLP_TRACES_" + name); v != "" {
+			return v
+		}
+		return getenv("OTEL_EXPORTER_OTLP_" + name)
+	}
+
+	cfg.Endpoint = getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if cfg.Endpoint == "" {
+		base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return Config{}, false, nil
+		}
+		cfg.Endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if _, err = url.ParseRequestURI(cfg.Endpoint); err != nil {
+		return Config{}, false, fmt.Errorf("invalid OTLP endpoint %q: %v", cfg.Endpoint, err)
+	}
+
+	if protocol := lookup("PROTOCOL"); protocol != "" && protocol != "http/json" {
+		return Config{}, false, fmt.Errorf("OTLP protocol %q is not supported, use http/json", protocol)
+	}
+	if cfg.Headers, err = parsePairs(lookup("HEADERS")); err != nil {
+		return Config{}, false, fmt.Errorf("invalid OTLP headers: %v", err)
+	}
+	if timeout := lookup("TIMEOUT"); timeout != "" {
+		ms, err := strconv.Atoi(timeout)
+		if err != nil || ms < 0 {
+			return Config{}, false, fmt.Errorf("invalid OTLP timeout %q, want milliseconds", timeout)
+		}
+		cfg.Timeout = time.Duration(ms) * time.Millisecond
+	}
+
+	resource, err := parsePairs(getenv("OTEL_RESOURCE_ATTRIBUTES"))
+	if err != nil {
+		return Config{}, false, fmt.Errorf("invalid OTEL_RESOURCE_ATTRIBUTES: %v", err)
+	}
+	cfg.ServiceName = resource["service.name"]
+	delete(resource, "service.name")
+	if name := getenv("OTEL_SERVICE_NAME"); name != "" {
+		cfg.ServiceName = name
+	}
+	for _, k := range sortedKeys(resource) {
+		cfg.Resource = append(cfg.Resource, String(k, resource[k]))
+	}
+	return cfg, true, nil
+}
+
+// parsePairs parses the "key1=value1,key2=value2" lists of the OTEL_* variables.
+func parsePairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%q is not key=value", pair)
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("value of %s: %v", k, err)
+		}
+		pairs[k] = value
+	}
+	return pairs, nil
+}
+
+// otlpExporter batches the ended spans and POSTs them as OTLP/HTTP JSON.
+type otlpExporter struct {
+	cfg    Config
+	client *http.Client
+
+	queue chan *Span
+	done  chan struct{}
+
+	mu      sync.Mutex
+	stopped bool
+	dropped int
+}
+
+func newOTLPExporter(cfg Config) *otlpExporter {
+	e := &otlpExporter{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan *Span, queueSize),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *otlpExporter) export(s *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return
+	}
+	select {
+	case e.queue <- s:
+	default:
+		e.dropped++
+	}
+}
+
+// shutdown sends the queued spans and stops the exporter, waiting at most until ctx is done.
+func (e *otlpExporter) shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return nil
+	}
+	e.stopped = true
+	dropped := e.dropped
+	close(e.queue)
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		log.Printf("Dropped %d spans, the export queue was full\n", dropped)
+	}
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flush spans failed: %w", ctx.Err())
+	}
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(batchDelay)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, maxBatch)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			log.Printf("Error exporting %d spans: %v\n", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s, ok := <-e.queue:
+			if !ok {
+				send()
+				return
+			}
+			if batch = append(batch, s); len(batch) >= maxBatch {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		}
+	}
+}
+
+func (e *otlpExporter) send(spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// The OTLP/HTTP JSON encoding of ExportTraceServiceRequest, IDs are hex and 64-bit integers strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              Kind           `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+// statusError is the OTLP status code of a failed span.
+const statusError = 2
+
+func (e *otlpExporter) request(spans []*Span) otlpRequest {
+	resource := append([]Attribute{String("service.name", e.cfg.ServiceName)}, e.cfg.Resource...)
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.sc.TraceID.String(),
+			SpanID:            s.sc.SpanID.String(),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        keyValues(s.attrs),
+		}
+		if s.parent != (SpanID{}) {
+			span.ParentSpanID = s.parent.String()
+		}
+		if s.failed {
+			span.Status = &otlpStatus{Code: statusError, Message: s.err}
+		}
+		s.mu.Unlock()
+		out = append(out, span)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: keyValues(resource)},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: out}},
+	}}}
+}
+
+func keyValues(attrs []Attribute) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]interface{}
+		switch v := a.Value.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, otlpKeyValue{Key: a.Key, Value: value})
+	}
+	return kvs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
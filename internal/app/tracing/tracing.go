@@ -0,0 +1,287 @@
+// Package tracing records OpenTelemetry spans of the conversion pipeline, from the download to the
+// database write, and exports them over OTLP/HTTP. It is configured with the standard OTEL_*
+// environment variables, see Init; without an endpoint every span is a no-op.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a trace, all spans of one conversion share it.
+type TraceID [16]byte
+
+// SpanID identifies a span within its trace.
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// SpanContext is the part of a span that crosses process boundaries in the traceparent header.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// IsValid reports whether both IDs are set, the W3C format forbids all-zero IDs.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Kind tells the backend the role of a span, the values are those of OTLP.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Attribute is a key-value pair describing a span, Value is a string, int64, float64 or bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute        { return Attribute{Key: key, Value: value} }
+func Int(key string, value int) Attribute       { return Attribute{Key: key, Value: int64(value)} }
+func Float(key string, value float64) Attribute { return Attribute{Key: key, Value: value} }
+func Bool(key string, value bool) Attribute     { return Attribute{Key: key, Value: value} }
+
+// Span is an operation of a trace. A nil span is the no-op span of disabled tracing, all its methods
+// are safe to call.
+type Span struct {
+	tracer *Tracer
+	name   string
+	kind   Kind
+	sc     SpanContext
+	parent SpanID
+	start  time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  []Attribute
+	err    string
+	failed bool
+	ended  bool
+}
+
+// SpanContext returns the IDs of the span, zero for the no-op span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes adds attrs to the span, an attribute set before with the same key is replaced.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		replaced := false
+		for i := range s.attrs {
+			if s.attrs[i].Key == a.Key {
+				s.attrs[i], replaced = a, true
+				break
+			}
+		}
+		if !replaced {
+			s.attrs = append(s.attrs, a)
+		}
+	}
+}
+
+// RecordError marks the span failed with err, a nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.err = err.Error()
+}
+
+// End finishes the span and queues it for export, later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.exporter.export(s)
+}
+
+// exporter takes the ended spans of a tracer.
+type exporter interface {
+	export(s *Span)
+	shutdown(ctx context.Context) error
+}
+
+// Tracer starts the spans of the process and hands the ended ones to its exporter.
+type Tracer struct {
+	exporter exporter
+}
+
+var global atomic.Pointer[Tracer]
+
+// setGlobal makes t the tracer of Start, nil disables tracing. It returns the previous one.
+func setGlobal(t *Tracer) *Tracer {
+	return global.Swap(t)
+}
+
+// Enabled reports whether spans are recorded.
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start starts a span named name as a child of the span of ctx and returns ctx carrying it.
+// The span is nil when tracing is disabled, End it like any other.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return StartKind(ctx, name, KindInternal, attrs...)
+}
+
+// StartKind works like Start for a span of kind, e.g. KindClient for a call to a provider.
+func StartKind(ctx context.Context, name string, kind Kind, attrs ...Attribute) (context.Context, *Span) {
+	t := global.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	parent := SpanFromContext(ctx).SpanContext()
+	if !parent.IsValid() {
+		parent, _ = ctx.Value(remoteKey{}).(SpanContext)
+	}
+	if parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.parent = parent.SpanID
+	} else {
+		randomID(s.sc.TraceID[:])
+	}
+	randomID(s.sc.SpanID[:])
+	s.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SpanFromContext returns the span ctx carries, nil when it carries none.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+func randomID(b []byte) {
+	for {
+		if _, err := rand.Read(b); err != nil {
+			panic(fmt.Sprintf("tracing: read random ID failed: %v", err))
+		}
+		for _, c := range b {
+			if c != 0 {
+				return
+			}
+		}
+	}
+}
+
+// traceparentHeader is the W3C Trace Context header, e.g.
+// "traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+const traceparentHeader = "traceparent"
+
+// Inject sets the traceparent header of the span of ctx, so the receiver continues the trace.
+// It does nothing when ctx carries no span.
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(traceparentHeader, "00-"+sc.TraceID.String()+"-"+sc.SpanID.String()+"-01")
+}
+
+// Extract returns ctx continuing the trace of the traceparent header, spans started from it are
+// children of the remote span. A missing or malformed header leaves ctx as it is.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := parseTraceparent(header.Get(traceparentHeader))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func parseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four fields, later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.DecodeString(parts[3]); err != nil {
+		return SpanContext{}, false
+	}
+	return sc, sc.IsValid()
+}
+
+// Transport is an http.RoundTripper recording a client span for every request and sending its
+// traceparent, so a provider that traces joins the trace of the conversion.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !Enabled() {
+		return base.RoundTrip(req)
+	}
+
+	ctx, span := StartKind(req.Context(), "HTTP "+req.Method, KindClient,
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Hostname()),
+		String("url.full", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path))
+	defer span.End()
+
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.RecordError(fmt.Errorf("%s", resp.Status))
+	}
+	return resp, nil
+}
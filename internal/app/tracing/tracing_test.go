@@ -0,0 +1,221 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		wantOK bool
+	}{
+		{name: "valid", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantOK: true},
+		{name: "not sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", wantOK: true},
+		{name: "later version with more fields", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xyz", wantOK: true},
+		{name: "empty", value: ""},
+		{name: "invalid version", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "version 00 with more fields", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xyz"},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "zero span id", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "not hex", value: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"},
+		{name: "short span id", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := parseTraceparent(tt.value)
+			if ok != tt.wantOK {
+				t.Fatalf("parseTraceparent(%q) ok = %v, want %v", tt.value, ok, tt.wantOK)
+			}
+			if ok && (sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7") {
+				t.Errorf("parseTraceparent(%q) = %v, %v", tt.value, sc.TraceID, sc.SpanID)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantOK  bool
+		wantErr bool
+	}{
+		{name: "no endpoint", env: map[string]string{"OTEL_SERVICE_NAME": "x"}},
+		{
+			name:   "base endpoint",
+			env:    map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318/"},
+			want:   Config{Endpoint: "http://collector:4318/v1/traces", Headers: map[string]string{}},
+			wantOK: true,
+		},
+		{
+			name: "traces variables take precedence",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://collector:4318",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://traces:4318/custom",
+				"OTEL_EXPORTER_OTLP_HEADERS":         "x-team=a",
+				"OTEL_EXPORTER_OTLP_TRACES_HEADERS":  "authorization=Bearer%20t, x-team = b",
+				"OTEL_EXPORTER_OTLP_TIMEOUT":         "2500",
+			},
+			want: Config{
+				Endpoint: "http://traces:4318/custom",
+				Headers:  map[string]string{"authorization": "Bearer t", "x-team": "b"},
+				Timeout:  2500 * time.Millisecond,
+			},
+			wantOK: true,
+		},
+		{
+			name: "resource attributes",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+				"OTEL_RESOURCE_ATTRIBUTES":    "service.name=from-resource,host.name=box,deployment.environment=prod",
+			},
+			want: Config{
+				Endpoint:    "http://collector:4318/v1/traces",
+				Headers:     map[string]string{},
+				ServiceName: "from-resource",
+				Resource:    []Attribute{String("deployment.environment", "prod"), String("host.name", "box")},
+			},
+			wantOK: true,
+		},
+		{
+			name: "service name overrides resource",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+				"OTEL_RESOURCE_ATTRIBUTES":    "service.name=from-resource",
+				"OTEL_SERVICE_NAME":           "worker",
+			},
+			want:   Config{Endpoint: "http://collector:4318/v1/traces", Headers: map[string]string{}, ServiceName: "worker"},
+			wantOK: true,
+		},
+		{name: "sdk disabled", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_SDK_DISABLED": "TRUE"}},
+		{name: "exporter none", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_TRACES_EXPORTER": "none"}},
+		{name: "unsupported exporter", env: map[string]string{"OTEL_TRACES_EXPORTER": "zipkin"}, wantErr: true},
+		{name: "grpc protocol", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"}, wantErr: true},
+		{name: "invalid endpoint", env: map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "collector"}, wantErr: true},
+		{name: "invalid headers", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_EXPORTER_OTLP_HEADERS": "token"}, wantErr: true},
+		{name: "invalid timeout", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_EXPORTER_OTLP_TIMEOUT": "5s"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, ok, err := configFromEnv(func(name string) string { return tt.env[name] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("configFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("configFromEnv() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(cfg, tt.want) {
+				t.Errorf("configFromEnv() = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestStart_Disabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "convert")
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatalf("Start() without tracing = %v, want the no-op span", span)
+	}
+	span.SetAttributes(String("k", "v"))
+	span.RecordError(errors.New("failed"))
+	span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	if len(header) != 0 {
+		t.Errorf("Inject() without a span set %v", header)
+	}
+}
+
+func TestSetup_ExportsTrace(t *testing.T) {
+	var mu sync.Mutex
+	var got otlpRequest
+	var gotHeader http.Header
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		gotHeader = r.Header.Clone()
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode export: %v", err)
+		}
+		got.ResourceSpans = append(got.ResourceSpans, req.ResourceSpans...)
+	}))
+	defer collector.Close()
+
+	var traceparent string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(traceparentHeader)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer provider.Close()
+
+	shutdown := Setup(Config{Endpoint: collector.URL + "/v1/traces", Headers: map[string]string{"X-Team": "a"}})
+
+	// The conversion continues the trace of a remote caller
+	remote := http.Header{}
+	remote.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := Start(Extract(context.Background(), remote), "convert", String("v2t.user", "alice"))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, provider.URL+"/v1/audio/transcriptions?key=secret", nil)
+	resp, err := (&http.Client{Transport: &Transport{}}).Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+	root.SetAttributes(Int("v2t.segments", 3))
+	root.End()
+
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+	if Enabled() {
+		t.Fatal("tracing still enabled after shutdown")
+	}
+
+	if gotHeader.Get("X-Team") != "a" || gotHeader.Get("Content-Type") != "application/json" {
+		t.Errorf("export headers = %v", gotHeader)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("export = %+v, want one batch", got)
+	}
+	resource := got.ResourceSpans[0].Resource.Attributes
+	if len(resource) != 1 || resource[0].Key != "service.name" || resource[0].Value["stringValue"] != defaultServiceName {
+		t.Errorf("resource = %+v, want service.name v2t", resource)
+	}
+
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want the client span and the root", len(spans))
+	}
+	client, convert := spans[0], spans[1]
+	if convert.Name != "convert" || convert.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || convert.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("root span = %+v, want it to continue the remote trace", convert)
+	}
+	if len(convert.Attributes) != 2 || convert.Attributes[1].Value["intValue"] != "3" || convert.Status != nil {
+		t.Errorf("root span attributes = %+v, status %+v", convert.Attributes, convert.Status)
+	}
+	if client.Name != "HTTP POST" || client.Kind != KindClient || client.TraceID != convert.TraceID || client.ParentSpanID != convert.SpanID {
+		t.Errorf("client span = %+v, want a child of %s", client, convert.SpanID)
+	}
+	if client.Status == nil || client.Status.Code != statusError {
+		t.Errorf("client span status = %+v, want an error for 429", client.Status)
+	}
+	for _, a := range client.Attributes {
+		if a.Key == "url.full" && a.Value["stringValue"] != provider.URL+"/v1/audio/transcriptions" {
+			t.Errorf("url.full = %v, want it without the query", a.Value)
+		}
+	}
+	if want := "00-" + client.TraceID + "-" + client.SpanID + "-01"; traceparent != want {
+		t.Errorf("provider got traceparent %q, want %q", traceparent, want)
+	}
+}
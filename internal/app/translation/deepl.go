@@ -0,0 +1,66 @@
+package translation
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"tiktok-whisper/internal/app/config/secrets"
+	"time"
+)
+
+const (
+	deepLFreeURL = "https://api-free.deepl.com/v2/translate"
+	deepLProURL  = "https://api.deepl.com/v2/translate"
+)
+
+// deepLTargets are the target codes DeepL wants in place of plain language codes it deprecated.
+var deepLTargets = map[string]string{
+	"en": "EN-US",
+	"pt": "PT-BR",
+}
+
+// DeepLTranslator translates with the DeepL API.
+type DeepLTranslator struct {
+	url    string
+	key    string
+	client *http.Client
+}
+
+// NewDeepLTranslator creates a new DeepLTranslator instance, the key is resolved by secrets.Key.
+// Free keys, ending in :fx, use the free API and the others the Pro API unless url is set.
+func NewDeepLTranslator(url string, timeout time.Duration) (*DeepLTranslator, error) {
+	key, err := secrets.Key("deepl")
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		url = deepLProURL
+		if strings.HasSuffix(key, ":fx") {
+			url = deepLFreeURL
+		}
+	}
+	return &DeepLTranslator{url: url, key: key, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (d *DeepLTranslator) Name() string { return "deepl" }
+
+func (d *DeepLTranslator) Translate(text string, target string) (string, error) {
+	code, ok := deepLTargets[strings.ToLower(target)]
+	if !ok {
+		code = strings.ToUpper(target)
+	}
+
+	request := map[string]interface{}{"text": []string{text}, "target_lang": code}
+	var response struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	if err := postJSON(d.client, d.url, map[string]string{"Authorization": "DeepL-Auth-Key " + d.key}, request, &response); err != nil {
+		return "", err
+	}
+	if len(response.Translations) == 0 {
+		return "", errors.New("empty deepl response")
+	}
+	return response.Translations[0].Text, nil
+}
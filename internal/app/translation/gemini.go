@@ -0,0 +1,73 @@
+package translation
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"tiktok-whisper/internal/app/config/secrets"
+	"time"
+)
+
+const (
+	defaultGeminiURL   = "https://generativelanguage.googleapis.com/v1beta"
+	defaultGeminiModel = "gemini-1.5-flash"
+)
+
+// GeminiTranslator translates with a Gemini model of the Google generative language API.
+type GeminiTranslator struct {
+	url    string
+	model  string
+	key    string
+	client *http.Client
+}
+
+// NewGeminiTranslator creates a new GeminiTranslator instance, the key is resolved by secrets.Key.
+// model defaults to gemini-1.5-flash, url replaces the API endpoint when it is set.
+func NewGeminiTranslator(model string, url string, timeout time.Duration) (*GeminiTranslator, error) {
+	key, err := secrets.Key("gemini")
+	if err != nil {
+		return nil, err
+	}
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	if url == "" {
+		url = defaultGeminiURL
+	}
+	return &GeminiTranslator{url: strings.TrimSuffix(url, "/"), model: model, key: key, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (g *GeminiTranslator) Name() string { return "gemini" }
+
+func (g *GeminiTranslator) Translate(text string, target string) (string, error) {
+	system, err := instruction(target)
+	if err != nil {
+		return "", err
+	}
+	request := map[string]interface{}{
+		"systemInstruction": map[string]interface{}{"parts": []map[string]string{{"text": system}}},
+		"contents":          []map[string]interface{}{{"role": "user", "parts": []map[string]string{{"text": text}}}},
+	}
+	var response struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	url := g.url + "/models/" + g.model + ":generateContent"
+	if err := postJSON(g.client, url, map[string]string{"x-goog-api-key": g.key}, request, &response); err != nil {
+		return "", err
+	}
+	if len(response.Candidates) == 0 {
+		return "", errors.New("empty gemini response")
+	}
+
+	var sb strings.Builder
+	for _, part := range response.Candidates[0].Content.Parts {
+		sb.WriteString(part.Text)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,36 @@
+package translation
+
+import (
+	"context"
+	"tiktok-whisper/internal/app/llm"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// LLMTranslator translates with the language model configured as llm in config.yaml, e.g. a local
+// Ollama model so transcripts stay on the machine.
+type LLMTranslator struct {
+	model  llm.Model
+	budget int
+}
+
+// NewLLMTranslator creates a new LLMTranslator instance sending at most budget tokens of text per
+// request, zero doesn't bound them.
+func NewLLMTranslator(model llm.Model, budget int) *LLMTranslator {
+	return &LLMTranslator{model: model, budget: budget}
+}
+
+func (l *LLMTranslator) Name() string { return l.model.Name() }
+
+func (l *LLMTranslator) Budget() int { return l.budget }
+
+func (l *LLMTranslator) Translate(text string, target string) (string, error) {
+	system, err := instruction(target)
+	if err != nil {
+		return "", err
+	}
+	return l.model.Complete(context.Background(), []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: system},
+		{Role: openai.ChatMessageRoleUser, Content: text},
+	})
+}
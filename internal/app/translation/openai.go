@@ -0,0 +1,67 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"tiktok-whisper/internal/app/config/secrets"
+	"tiktok-whisper/internal/app/prompts"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAITranslator translates with a chat model of the OpenAI API.
+type OpenAITranslator struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAITranslator creates a new OpenAITranslator instance, the key is resolved by secrets.Key.
+// model defaults to gpt-3.5-turbo, url replaces the API endpoint when it is set.
+func NewOpenAITranslator(model string, url string, timeout time.Duration) (*OpenAITranslator, error) {
+	token, err := secrets.Key("openai")
+	if err != nil {
+		return nil, err
+	}
+	if model == "" {
+		model = openai.GPT3Dot5Turbo
+	}
+
+	cfg := openai.DefaultConfig(token)
+	if url != "" {
+		cfg.BaseURL = url
+	}
+	cfg.HTTPClient = &http.Client{Timeout: timeout}
+	return &OpenAITranslator{client: openai.NewClientWithConfig(cfg), model: model}, nil
+}
+
+func (o *OpenAITranslator) Name() string { return "openai" }
+
+func (o *OpenAITranslator) Translate(text string, target string) (string, error) {
+	system, err := instruction(target)
+	if err != nil {
+		return "", err
+	}
+	resp, err := o.client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Model: o.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: system},
+			{Role: openai.ChatMessageRoleUser, Content: text},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("chat completion failed: %v", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("empty chat completion response")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// instruction tells a language model to translate into target and nothing else, it is the
+// translation prompt template.
+func instruction(target string) (string, error) {
+	return prompts.Render(prompts.Translation, prompts.Data{Target: target})
+}
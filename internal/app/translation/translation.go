@@ -0,0 +1,202 @@
+// Package translation translates stored transcriptions into other languages with OpenAI, Gemini,
+// DeepL or the language model of llm in config.yaml. The translations are stored next to the
+// transcriptions, one per target language.
+package translation
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"tiktok-whisper/internal/app/capability"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/llm"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/paragraph"
+	"tiktok-whisper/internal/app/prompts"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/util/textdiff"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+)
+
+// Translator translates text with one backend.
+type Translator interface {
+	// Name of the backend, stored with the translations.
+	Name() string
+	// Translate returns text in the language target, a code such as en or zh.
+	Translate(text string, target string) (string, error)
+}
+
+// DefaultTimeout bounds a translation request when none is configured.
+const DefaultTimeout = 2 * time.Minute
+
+// maxChunkLength in characters is the most text sent per request, longer transcriptions are
+// split at sentence ends so each request stays well within the limits of every backend.
+const maxChunkLength = 4000
+
+// Backends are the backends New creates.
+var Backends = []string{"openai", "gemini", "deepl", "llm"}
+
+// New creates the translator configured in config.yaml.
+func New(cfg config.TranslationConfig) (Translator, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	switch cfg.Backend {
+	case "openai", "":
+		return NewOpenAITranslator(cfg.Model, cfg.URL, timeout)
+	case "gemini":
+		return NewGeminiTranslator(cfg.Model, cfg.URL, timeout)
+	case "deepl":
+		return NewDeepLTranslator(cfg.URL, timeout)
+	case "llm":
+		// the model, URL and timeout are those of llm
+		if err := capability.Require(capability.LLM); err != nil {
+			return nil, err
+		}
+		model, err := llm.Default()
+		if err != nil {
+			return nil, err
+		}
+		return NewLLMTranslator(model, llm.Budget(config.Get().LLM, string(prompts.Translation))), nil
+	default:
+		return nil, fmt.Errorf("unknown translation backend %q, supported: %s", cfg.Backend, strings.Join(Backends, ", "))
+	}
+}
+
+// Budgeted is implemented by translators bounding the tokens of a request, like the language model
+// of llm whose context window may be small.
+type Budgeted interface {
+	// Budget returns the most tokens of text a request takes, see llm.Budget.
+	Budget() int
+}
+
+// Translate translates text with t, chunk by chunk when it is longer than one request takes.
+func Translate(t Translator, text string, target string) (string, error) {
+	maxTokens := 0
+	if b, ok := t.(Budgeted); ok {
+		maxTokens = b.Budget()
+	}
+
+	var translated string
+	for i, chunk := range chunks(text, maxChunkLength, maxTokens) {
+		result, err := t.Translate(chunk, target)
+		if err != nil {
+			return "", fmt.Errorf("translate chunk %d failed: %w", i+1, err)
+		}
+		translated = join(translated, strings.TrimSpace(result))
+	}
+	return translated, nil
+}
+
+// chunks splits text at sentence ends into pieces of at most maxLength characters and, unless it is
+// zero, maxTokens tokens. A single sentence longer than maxLength is a piece of its own, one over
+// maxTokens is split further.
+func chunks(text string, maxLength int, maxTokens int) []string {
+	fits := func(s string) bool {
+		return utf8.RuneCountInString(s) <= maxLength && (maxTokens <= 0 || llm.EstimateTokens(s) <= maxTokens)
+	}
+	sentences := textdiff.Sentences(text)
+	if maxTokens > 0 {
+		sentences = lo.FlatMap(sentences, func(s string, _ int) []string {
+			return lo.Ternary(llm.EstimateTokens(s) > maxTokens, llm.Split(s, maxTokens), []string{s})
+		})
+	}
+
+	var pieces []string
+	var current string
+	for _, s := range sentences {
+		if current != "" && !fits(join(current, s)) {
+			pieces = append(pieces, current)
+			current = ""
+		}
+		current = join(current, s)
+	}
+	if current != "" {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+func join(before, after string) string {
+	if before == "" || after == "" {
+		return before + after
+	}
+	if paragraph.NeedsSpace(before, after) {
+		return before + " " + after
+	}
+	return before + after
+}
+
+// Options select the transcriptions Transcriptions translates.
+type Options struct {
+	User string
+	// Language is the target language code, e.g. en.
+	Language string
+	// Force translates transcriptions that already have a translation into Language again.
+	Force bool
+}
+
+// Result counts what Transcriptions did.
+type Result struct {
+	Translated int
+	// Skipped transcriptions already had a translation, or no text.
+	Skipped int
+	Failed  int
+}
+
+// Transcriptions translates every transcription of the user into the target language and stores
+// the translations in translations. A failed transcription is logged and counted, the others go on.
+func Transcriptions(db repository.TranscriptionDAO, translations repository.TranslationDAO, t Translator, opts Options) (Result, error) {
+	var result Result
+	if opts.Language == "" {
+		return result, errors.New("no target language")
+	}
+
+	transcriptions, err := db.GetAllByUser(opts.User)
+	if err != nil {
+		return result, fmt.Errorf("get transcriptions of %s failed: %v", opts.User, err)
+	}
+
+	for _, tr := range transcriptions {
+		if strings.TrimSpace(tr.Transcription) == "" {
+			result.Skipped++
+			continue
+		}
+		if !opts.Force {
+			_, err = translations.GetTranslation(tr.ID, opts.Language)
+			if err == nil {
+				result.Skipped++
+				continue
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return result, fmt.Errorf("get translation of %d failed: %v", tr.ID, err)
+			}
+		}
+
+		text, err := Translate(t, tr.Transcription, opts.Language)
+		if err != nil {
+			log.Printf("Translating transcription %d (%s) failed: %v\n", tr.ID, tr.Mp3FileName, err)
+			result.Failed++
+			continue
+		}
+		err = translations.SaveTranslation(model.Translation{
+			TranscriptionID: tr.ID,
+			Language:        opts.Language,
+			Backend:         t.Name(),
+			Text:            text,
+			CreatedAt:       time.Now(),
+		})
+		if err != nil {
+			return result, fmt.Errorf("save translation of %d failed: %v", tr.ID, err)
+		}
+		result.Translated++
+	}
+	return result, nil
+}
@@ -0,0 +1,174 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// upperTranslator "translates" by upper casing, it fails on text containing fail.
+type upperTranslator struct {
+	calls int
+}
+
+func (u *upperTranslator) Name() string { return "upper" }
+
+func (u *upperTranslator) Translate(text string, target string) (string, error) {
+	u.calls++
+	if strings.Contains(text, "fail") {
+		return "", errors.New("backend down")
+	}
+	return strings.ToUpper(text), nil
+}
+
+func TestChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		maxTokens int
+		want      []string
+	}{
+		{name: "short", text: "One. Two.", maxLength: 100, want: []string{"One. Two."}},
+		{name: "split at sentences", text: "One one. Two two. Three.", maxLength: 17, want: []string{"One one. Two two.", "Three."}},
+		{name: "long sentence", text: "A very long sentence. B.", maxLength: 5, want: []string{"A very long sentence.", "B."}},
+		{name: "cjk", text: "第一句。第二句。第三句。", maxLength: 8, want: []string{"第一句。第二句。", "第三句。"}},
+		{name: "token budget", text: "One one. Two two. Three.", maxLength: 100, maxTokens: 5, want: []string{"One one. Two two.", "Three."}},
+		{name: "sentence over token budget", text: "一二三四五六。七。", maxLength: 100, maxTokens: 4, want: []string{"一二三四", "五六。", "七。"}},
+		{name: "empty", text: " "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunks(tt.text, tt.maxLength, tt.maxTokens); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscriptions(t *testing.T) {
+	db := memory.NewMemoryDB()
+	now := time.Now()
+	db.RecordToDB("alice", "/in", "a.mp4", "a.mp3", 60, "Hello there.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "b.mp4", "b.mp3", 60, "This will fail.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("alice", "/in", "c.mp4", "c.mp3", 60, "Already done.", now, 0, "", model.ProviderMetadata{})
+	db.RecordToDB("bob", "/in", "d.mp4", "d.mp3", 60, "Not alice.", now, 0, "", model.ProviderMetadata{})
+	db.SaveTranslation(model.Translation{TranscriptionID: 3, Language: "en", Backend: "deepl", Text: "done before"})
+
+	translator := &upperTranslator{}
+	result, err := Transcriptions(db, db, translator, Options{User: "alice", Language: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Result{Translated: 1, Skipped: 1, Failed: 1}); result != want {
+		t.Errorf("Transcriptions() = %+v, want %+v", result, want)
+	}
+	if tr, err := db.GetTranslation(1, "en"); err != nil || tr.Text != "HELLO THERE." || tr.Backend != "upper" {
+		t.Errorf("translation of 1 = %+v, %v", tr, err)
+	}
+	if tr, _ := db.GetTranslation(3, "en"); tr.Text != "done before" {
+		t.Errorf("translation of 3 = %+v, want it kept", tr)
+	}
+
+	result, err = Transcriptions(db, db, translator, Options{User: "alice", Language: "en", Force: true})
+	if err != nil || result.Translated != 2 {
+		t.Errorf("Transcriptions() with force = %+v, %v, want 2 translated", result, err)
+	}
+	if tr, _ := db.GetTranslation(3, "en"); tr.Text != "ALREADY DONE." {
+		t.Errorf("translation of 3 = %+v, want it replaced", tr)
+	}
+}
+
+func TestGeminiTranslator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Contents []struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"contents"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/models/gemini-test:generateContent" || r.Header.Get("x-goog-api-key") != "key" || body.Contents[0].Parts[0].Text != "你好" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"candidates": [{"content": {"parts": [{"text": "Hello"}, {"text": " there"}]}}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("GEMINI_API_KEY", "key")
+
+	g, err := NewGeminiTranslator("gemini-test", server.URL, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := g.Translate("你好", "en"); err != nil || got != "Hello there" {
+		t.Errorf("Translate() = %q, %v", got, err)
+	}
+	if _, err = g.Translate("other", "en"); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Translate() error = %v, want the status", err)
+	}
+}
+
+func TestDeepLTranslator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text       []string `json:"text"`
+			TargetLang string   `json:"target_lang"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key key:fx" || len(body.Text) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"translations": []map[string]string{{"detected_source_language": "ZH", "text": body.TargetLang + ": " + body.Text[0]}},
+		})
+	}))
+	defer server.Close()
+	t.Setenv("DEEPL_AUTH_KEY", "key:fx")
+
+	d, err := NewDeepLTranslator(server.URL, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for target, want := range map[string]string{"en": "EN-US: 你好", "de": "DE: 你好"} {
+		if got, err := d.Translate("你好", target); err != nil || got != want {
+			t.Errorf("Translate() to %s = %q, %v, want %q", target, got, err, want)
+		}
+	}
+
+	if d, _ = NewDeepLTranslator("", time.Minute); d.url != deepLFreeURL {
+		t.Errorf("url of a free key = %s, want %s", d.url, deepLFreeURL)
+	}
+}
+
+// echoModel replies with the system prompt and the text it was sent.
+type echoModel struct{}
+
+func (echoModel) Name() string { return "ollama/echo" }
+
+func (echoModel) Complete(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	return messages[0].Content + "|" + messages[1].Content, nil
+}
+
+func TestLLMTranslator(t *testing.T) {
+	l := NewLLMTranslator(echoModel{}, 0)
+	got, err := l.Translate("你好", "en")
+	if err != nil || !strings.HasSuffix(got, "|你好") || !strings.Contains(got, "en") {
+		t.Errorf("Translate() = %q, %v, want the translation prompt into en and the text", got, err)
+	}
+	if l.Name() != "ollama/echo" {
+		t.Errorf("Name() = %q, want the name of the model", l.Name())
+	}
+}
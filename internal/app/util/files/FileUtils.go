@@ -40,6 +40,15 @@ func GetUserMp3Dir(userNickname string) string {
 	return filepath.Join(root, "data/mp3", userNickname)
 }
 
+// GetUserDownloadDir is where the media downloaded for a user is kept.
+func GetUserDownloadDir(userNickname string) string {
+	root, err := GetProjectRoot()
+	if err != nil {
+		log.Fatalf("GetUserDownloadDir failed: %v\n", err)
+	}
+	return filepath.Join(root, "data/download", userNickname)
+}
+
 func CheckAndCreateMP3Directory(mp3Dir string) {
 	if _, err := os.Stat(mp3Dir); os.IsNotExist(err) {
 		log.Printf("Creating MP3 directory: %s\n", mp3Dir)
@@ -88,28 +97,9 @@ func ReadOutputFile(filePath string) (string, error) {
 	return strings.TrimSpace(string(content)), nil
 }
 
+// WriteToFile writes content to filePath atomically, creating the parent directory if needed.
 func WriteToFile(content, filePath string) error {
-	// Ensure the parent directory exists
-	dir := filepath.Dir(filePath)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		err = os.MkdirAll(dir, 0755) // use 0755 permissions for the created directories
-		if err != nil {
-			return fmt.Errorf("create dir err: %v", err)
-		}
-	}
-
-	file, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("create file err: %v", err)
-	}
-	defer file.Close()
-
-	_, err = file.WriteString(content)
-	if err != nil {
-		return fmt.Errorf("write string err: %v", err)
-	}
-
-	return nil
+	return WriteFileAtomic(filePath, []byte(content), 0644)
 }
 
 func findGoModRoot(path string) (string, error) {
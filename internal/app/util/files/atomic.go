@@ -0,0 +1,126 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// partialSuffix marks an output whose write has started but not finished, streamed
+// outputs keep their text so far in it.
+const partialSuffix = ".partial"
+
+// PartialMarker returns the path of the marker that exists while filePath is being written.
+func PartialMarker(filePath string) string {
+	return filePath + partialSuffix
+}
+
+// IsComplete reports whether filePath exists and its last write finished,
+// an output left behind by an interrupted run still has its partial marker.
+func IsComplete(filePath string) bool {
+	if _, err := os.Stat(filePath); err != nil {
+		return false
+	}
+	_, err := os.Stat(PartialMarker(filePath))
+	return os.IsNotExist(err)
+}
+
+// WriteFileAtomic writes data to a temp file next to filePath, syncs it and renames it over filePath,
+// so readers see either the old content or the complete new one. The partial marker is created
+// before and removed after the rename, a crash in between leaves it for resume logic to find.
+func WriteFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create dir err: %v", err)
+	}
+
+	// An existing marker may hold streamed partial text, it is kept until the output is complete
+	marker := PartialMarker(filePath)
+	f, err := os.OpenFile(marker, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("create partial marker err: %v", err)
+	}
+	f.Close()
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file err: %v", err)
+	}
+	tmpPath := tmp.Name()
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp file err: %v", err)
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("chmod temp file err: %v", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temp file err: %v", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file err: %v", err)
+	}
+
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("rename temp file err: %v", err)
+	}
+	renamed = true
+	if err = syncDir(dir); err != nil {
+		return fmt.Errorf("sync dir err: %v", err)
+	}
+
+	if err = os.Remove(marker); err != nil {
+		return fmt.Errorf("remove partial marker err: %v", err)
+	}
+	return syncDir(dir)
+}
+
+// PartialWriter appends the text of an output while it is produced to its partial marker,
+// so it can be followed during a long run and survives a crash.
+type PartialWriter struct {
+	f *os.File
+}
+
+// CreatePartial starts the partial output of filePath, discarding what an earlier run left.
+func CreatePartial(filePath string) (*PartialWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("create dir err: %v", err)
+	}
+	f, err := os.OpenFile(PartialMarker(filePath), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("create partial output err: %v", err)
+	}
+	return &PartialWriter{f: f}, nil
+}
+
+// WriteLine appends line and syncs it, so a crash loses at most the line being written.
+func (w *PartialWriter) WriteLine(line string) error {
+	if _, err := w.f.WriteString(line + "\n"); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// Close closes the partial output, it stays on disk until the complete output replaces it.
+func (w *PartialWriter) Close() error {
+	return w.f.Close()
+}
+
+// syncDir flushes the directory entry changes of a rename to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
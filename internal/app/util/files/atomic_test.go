@@ -0,0 +1,72 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out", "a.txt")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFileAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFileAtomic() error = %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("WriteFileAtomic() wrote %q, want %q", data, content)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("WriteFileAtomic() left %d files behind, want only a.txt", len(entries))
+	}
+}
+
+func TestIsComplete(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		prepare func(path string)
+		want    bool
+	}{
+		{
+			name:    "missing",
+			prepare: func(path string) {},
+			want:    false,
+		},
+		{
+			name: "written",
+			prepare: func(path string) {
+				WriteFileAtomic(path, []byte("text"), 0644)
+			},
+			want: true,
+		},
+		{
+			name: "interrupted",
+			prepare: func(path string) {
+				os.WriteFile(path, []byte("trunc"), 0644)
+				os.WriteFile(PartialMarker(path), nil, 0644)
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".txt")
+			tt.prepare(path)
+			if got := IsComplete(path); got != tt.want {
+				t.Errorf("IsComplete() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Op is the kind of change of an Edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is a sentence kept, removed from the old text or added by the new text.
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// sentenceEnds terminate a sentence, transcripts are often a single line so
+// diffing by line would report the whole text as changed.
+const sentenceEnds = "。！？!?.；;\n"
+
+// Sentences splits text after every sentence ending punctuation, surrounding space is trimmed.
+func Sentences(text string) []string {
+	sentences := make([]string, 0)
+	start := 0
+	for i, r := range text {
+		if !strings.ContainsRune(sentenceEnds, r) {
+			continue
+		}
+		end := i + len(string(r))
+		if s := strings.TrimFunc(text[start:end], unicode.IsSpace); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = end
+	}
+	if s := strings.TrimFunc(text[start:], unicode.IsSpace); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// Diff compares the sentences of a and b by their longest common subsequence.
+func Diff(a, b string) []Edit {
+	x, y := Sentences(a), Sentences(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	edits := make([]Edit, 0, len(x)+len(y))
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			edits = append(edits, Edit{Op: Equal, Text: x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, Edit{Op: Delete, Text: x[i]})
+			i++
+		default:
+			edits = append(edits, Edit{Op: Insert, Text: y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		edits = append(edits, Edit{Op: Delete, Text: x[i]})
+	}
+	for ; j < len(y); j++ {
+		edits = append(edits, Edit{Op: Insert, Text: y[j]})
+	}
+	return edits
+}
+
+// Unified renders edits one sentence per line prefixed with "  ", "- " or "+ ".
+func Unified(edits []Edit) string {
+	var sb strings.Builder
+	for _, e := range edits {
+		switch e.Op {
+		case Delete:
+			sb.WriteString("- ")
+		case Insert:
+			sb.WriteString("+ ")
+		default:
+			sb.WriteString("  ")
+		}
+		sb.WriteString(e.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,52 @@
+package textdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "empty", text: "", want: []string{}},
+		{name: "chinese", text: "你好。今天天气不错！", want: []string{"你好。", "今天天气不错！"}},
+		{name: "english without final period", text: "Hello there. How are you", want: []string{"Hello there.", "How are you"}},
+		{name: "lines", text: "first line\n\nsecond line\n", want: []string{"first line", "second line"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sentences(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sentences() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []Edit
+	}{
+		{name: "identical", a: "一。二。", b: "一。二。", want: []Edit{{Equal, "一。"}, {Equal, "二。"}}},
+		{
+			name: "changed sentence",
+			a:    "一。二。三。",
+			b:    "一。贰。三。",
+			want: []Edit{{Equal, "一。"}, {Delete, "二。"}, {Insert, "贰。"}, {Equal, "三。"}},
+		},
+		{name: "appended", a: "一。", b: "一。二。", want: []Edit{{Equal, "一。"}, {Insert, "二。"}}},
+		{name: "removed", a: "一。二。", b: "二。", want: []Edit{{Delete, "一。"}, {Equal, "二。"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
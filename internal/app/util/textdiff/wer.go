@@ -0,0 +1,82 @@
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Words splits text into the tokens WER counts: lower cased words without punctuation,
+// every Han, Hiragana and Katakana character is a token of its own as those scripts don't use spaces.
+func Words(text string) []string {
+	words := make([]string, 0)
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			flush()
+			words = append(words, string(r))
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '\'':
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+// ContainsWords reports whether text contains every one of words, in any order.
+func ContainsWords(text []string, words []string) bool {
+	seen := make(map[string]bool, len(text))
+	for _, w := range text {
+		seen[w] = true
+	}
+	for _, w := range words {
+		if !seen[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// WER is the word error rate of hypothesis against reference: the substituted, deleted and inserted
+// words over the words of the reference. It is 0 for a perfect transcript and may exceed 1.
+func WER(reference, hypothesis string) float64 {
+	ref, hyp := Words(reference), Words(hypothesis)
+	if len(ref) == 0 {
+		if len(hyp) == 0 {
+			return 0
+		}
+		return 1
+	}
+
+	// prev[j] is the edit distance of the reference so far and hyp[:j]
+	prev := make([]int, len(hyp)+1)
+	cur := make([]int, len(hyp)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ref); i++ {
+		cur[0] = i
+		for j := 1; j <= len(hyp); j++ {
+			cur[j] = prev[j-1]
+			if ref[i-1] != hyp[j-1] {
+				cur[j]++
+			}
+			if deletion := prev[j] + 1; deletion < cur[j] {
+				cur[j] = deletion
+			}
+			if insertion := cur[j-1] + 1; insertion < cur[j] {
+				cur[j] = insertion
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return float64(prev[len(hyp)]) / float64(len(ref))
+}
@@ -0,0 +1,71 @@
+package textdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "punctuation and case", text: "And so, my fellow Americans: ask not!", want: []string{"and", "so", "my", "fellow", "americans", "ask", "not"}},
+		{name: "apostrophe", text: "don't stop", want: []string{"don't", "stop"}},
+		{name: "chinese", text: "大家好，谢谢", want: []string{"大", "家", "好", "谢", "谢"}},
+		{name: "mixed", text: "用GPT4转录", want: []string{"用", "gpt4", "转", "录"}},
+		{name: "empty", text: " ,. ", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Words(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsWords(t *testing.T) {
+	text := Words("Ask not what your country can do for you")
+	tests := []struct {
+		name  string
+		words []string
+		want  bool
+	}{
+		{name: "all in another order", words: Words("country ask"), want: true},
+		{name: "one missing", words: Words("ask the country"), want: false},
+		{name: "no words", words: []string{}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContainsWords(text, tt.words); got != tt.want {
+				t.Errorf("ContainsWords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWER(t *testing.T) {
+	tests := []struct {
+		name       string
+		reference  string
+		hypothesis string
+		want       float64
+	}{
+		{name: "perfect", reference: "ask not what your country can do", hypothesis: "Ask not, what your country can do.", want: 0},
+		{name: "substitution", reference: "ask not what your country can do", hypothesis: "ask not what our country can do", want: 1.0 / 7},
+		{name: "deletion and insertion", reference: "a b c d", hypothesis: "a c d e", want: 0.5},
+		{name: "nothing recognized", reference: "a b", hypothesis: "", want: 1},
+		{name: "hallucination", reference: "a", hypothesis: "a b c", want: 2},
+		{name: "chinese", reference: "谢谢观看", hypothesis: "谢谢收看", want: 0.25},
+		{name: "empty reference", reference: "", hypothesis: "", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WER(tt.reference, tt.hypothesis); got < tt.want-1e-9 || got > tt.want+1e-9 {
+				t.Errorf("WER() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+// Package vocab manages vocabulary packs: lists of domain terms with their preferred spelling, the
+// variants whisper writes instead and an optional expansion. Installed packs bias the initial prompt
+// of the providers towards their terms and correct the variants left in the transcriptions.
+package vocab
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/logging"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed packs/*.yaml
+var builtinPacks embed.FS
+
+// MaxPromptRunes caps the terms Prompt returns, whisper only reads the last 224 tokens of a prompt
+// and the rest of it is the language prompt and the previous text.
+const MaxPromptRunes = 200
+
+// ErrNotInstalled is returned for packs that aren't installed.
+var ErrNotInstalled = errors.New("vocabulary pack not installed")
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Term is a term of a pack.
+type Term struct {
+	// Term is the preferred spelling.
+	Term string `yaml:"term"`
+	// Variants are the spellings corrected to Term, matched ignoring case.
+	Variants []string `yaml:"variants,omitempty"`
+	// Expansion spells out an abbreviation, e.g. electrocardiogram for ECG.
+	Expansion string `yaml:"expansion,omitempty"`
+}
+
+// Pack is a vocabulary pack, stored as a yaml file named after it.
+type Pack struct {
+	Name string `yaml:"name"`
+	// Language is the language the pack applies to, e.g. zh, all languages when empty.
+	Language    string `yaml:"language,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	// Expand writes the expansion of a term after its first occurrence in a transcription.
+	Expand bool   `yaml:"expand,omitempty"`
+	Terms  []Term `yaml:"terms"`
+}
+
+// Parse reads a pack and validates it.
+func Parse(data []byte) (Pack, error) {
+	var p Pack
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return Pack{}, fmt.Errorf("parse vocabulary pack failed: %v", err)
+	}
+	if !nameRegexp.MatchString(p.Name) {
+		return Pack{}, fmt.Errorf("invalid pack name %q, use lowercase letters, digits and dashes", p.Name)
+	}
+	if len(p.Terms) == 0 {
+		return Pack{}, fmt.Errorf("pack %s has no terms", p.Name)
+	}
+	for i, t := range p.Terms {
+		if strings.TrimSpace(t.Term) == "" {
+			return Pack{}, fmt.Errorf("pack %s: term %d is empty", p.Name, i+1)
+		}
+		for _, v := range t.Variants {
+			if strings.TrimSpace(v) == "" {
+				return Pack{}, fmt.Errorf("pack %s: term %s has an empty variant", p.Name, t.Term)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Builtin returns the packs v2t ships with, sorted by name.
+func Builtin() []Pack {
+	entries, _ := builtinPacks.ReadDir("packs")
+	var packs []Pack
+	for _, e := range entries {
+		data, err := builtinPacks.ReadFile("packs/" + e.Name())
+		if err != nil {
+			continue
+		}
+		p, err := Parse(data)
+		if err != nil {
+			panic(fmt.Sprintf("built-in %s: %v", e.Name(), err))
+		}
+		packs = append(packs, p)
+	}
+	sort.Slice(packs, func(i, j int) bool { return packs[i].Name < packs[j].Name })
+	return packs
+}
+
+// Dir returns the directory the packs are installed in, vocab in the config directory.
+func Dir() string {
+	return filepath.Join(config.Dir(), "vocab")
+}
+
+// Load returns the packs installed in dir sorted by name, none when dir doesn't exist.
+func Load(dir string) (Packs, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var packs Packs
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		p, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		packs = append(packs, p)
+	}
+	return packs, nil
+}
+
+// Install installs source in dir, either the name of a built-in pack or the path of a pack file,
+// replacing the installed pack of the same name.
+func Install(dir string, source string) (Pack, error) {
+	var data []byte
+	for _, p := range Builtin() {
+		if p.Name == source {
+			data, _ = builtinPacks.ReadFile("packs/" + source + ".yaml")
+		}
+	}
+	if data == nil {
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			if os.IsNotExist(err) {
+				return Pack{}, fmt.Errorf("no built-in pack or file named %s", source)
+			}
+			return Pack{}, err
+		}
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		return Pack{}, err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return Pack{}, err
+	}
+	return p, os.WriteFile(filepath.Join(dir, p.Name+".yaml"), data, 0644)
+}
+
+// Remove removes the pack name installed in dir.
+func Remove(dir string, name string) error {
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("%w: %s", ErrNotInstalled, name)
+	}
+	err := os.Remove(filepath.Join(dir, name+".yaml"))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrNotInstalled, name)
+	}
+	return err
+}
+
+var (
+	installed     Packs
+	installedOnce sync.Once
+)
+
+// Installed returns the packs installed in Dir, loaded once. Packs that fail to load are logged and skipped.
+func Installed() Packs {
+	installedOnce.Do(func() {
+		var err error
+		if installed, err = Load(Dir()); err != nil {
+			logging.L().Error("Failed to load vocabulary packs", "dir", Dir(), "error", err)
+		}
+	})
+	return installed
+}
+
+// Packs are the packs used together.
+type Packs []Pack
+
+// For returns the packs applying to language, those without a language apply to every language.
+// language may have a region, e.g. zh-CN.
+func (ps Packs) For(language string) Packs {
+	base := strings.ToLower(language)
+	if i := strings.IndexAny(base, "-_"); i > 0 {
+		base = base[:i]
+	}
+
+	var packs Packs
+	for _, p := range ps {
+		if p.Language == "" || base == "" || strings.EqualFold(p.Language, base) {
+			packs = append(packs, p)
+		}
+	}
+	return packs
+}
+
+// Prompt returns the terms of the packs for language as a prompt, the expansion after each abbreviation,
+// cut to MaxPromptRunes at a whole term. It is empty when no pack applies.
+func (ps Packs) Prompt(language string) string {
+	separator := ", "
+	if strings.HasPrefix(strings.ToLower(language), "zh") {
+		separator = "、"
+	}
+
+	var sb strings.Builder
+terms:
+	for _, p := range ps.For(language) {
+		for _, t := range p.Terms {
+			term := t.Term
+			if t.Expansion != "" {
+				term += " (" + t.Expansion + ")"
+			}
+			if sb.Len() > 0 {
+				term = separator + term
+			}
+			if utf8.RuneCountInString(sb.String()+term) > MaxPromptRunes {
+				break terms
+			}
+			sb.WriteString(term)
+		}
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	if separator == "、" {
+		return sb.String() + "。"
+	}
+	return sb.String() + ". "
+}
+
+// Correct replaces the variants of the terms of the packs for language in text with their
+// preferred spelling and returns the text and the number of replacements. In packs that expand
+// their terms, the first occurrence of an abbreviation is followed by its expansion.
+func (ps Packs) Correct(text string, language string) (string, int) {
+	re, terms := ps.For(language).matcher()
+	if re == nil || text == "" {
+		return text, 0
+	}
+
+	count := 0
+	expanded := map[string]bool{}
+	text = re.ReplaceAllStringFunc(text, func(match string) string {
+		t := terms[strings.ToLower(match)]
+		replacement := match
+		if match != t.term.Term {
+			replacement = t.term.Term
+			count++
+		}
+		if t.expand && t.term.Expansion != "" && !expanded[t.term.Term] {
+			expanded[t.term.Term] = true
+			replacement += " (" + t.term.Expansion + ")"
+		}
+		return replacement
+	})
+	return text, count
+}
+
+// Unexpanded returns copies of the packs that don't expand their terms, to correct text
+// that is already shown with its expansions elsewhere, e.g. the segments of a transcription.
+func (ps Packs) Unexpanded() Packs {
+	packs := make(Packs, len(ps))
+	for i, p := range ps {
+		p.Expand = false
+		packs[i] = p
+	}
+	return packs
+}
+
+// packTerm is a term with the option of its pack.
+type packTerm struct {
+	term   Term
+	expand bool
+}
+
+// matcher returns a regexp matching the variants of the terms of ps, and the terms expanded, by
+// their lowercase match. Longer spellings are matched first, and those starting or ending with a
+// letter or digit only as whole words so "CT" doesn't match inside "ACTION". It returns nil
+// when there is nothing to match.
+func (ps Packs) matcher() (*regexp.Regexp, map[string]packTerm) {
+	terms := map[string]packTerm{}
+	for _, p := range ps {
+		for _, t := range p.Terms {
+			if p.Expand && t.Expansion != "" {
+				terms[strings.ToLower(t.Term)] = packTerm{term: t, expand: true}
+			}
+			for _, v := range t.Variants {
+				terms[strings.ToLower(v)] = packTerm{term: t, expand: p.Expand}
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	spellings := make([]string, 0, len(terms))
+	for s := range terms {
+		spellings = append(spellings, s)
+	}
+	sort.Slice(spellings, func(i, j int) bool {
+		if len(spellings[i]) != len(spellings[j]) {
+			return len(spellings[i]) > len(spellings[j])
+		}
+		return spellings[i] < spellings[j]
+	})
+
+	alternatives := make([]string, len(spellings))
+	for i, s := range spellings {
+		pattern := regexp.QuoteMeta(s)
+		if first, _ := utf8.DecodeRuneInString(s); isWordRune(first) {
+			pattern = `\b` + pattern
+		}
+		if last, _ := utf8.DecodeLastRuneInString(s); isWordRune(last) {
+			pattern += `\b`
+		}
+		alternatives[i] = pattern
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|")), terms
+}
+
+// isWordRune reports whether r is a word character of \b, an ASCII letter, digit or underscore.
+func isWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
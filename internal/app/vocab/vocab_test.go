@@ -0,0 +1,151 @@
+package vocab
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testPacks = Packs{
+	{Name: "medical-en", Language: "en", Expand: true, Terms: []Term{
+		{Term: "ECG", Variants: []string{"EKG"}, Expansion: "electrocardiogram"},
+		{Term: "CT", Expansion: "computed tomography"},
+		{Term: "acetaminophen", Variants: []string{"acetaminophin", "acetaminophin tablets"}},
+	}},
+	{Name: "tech", Terms: []Term{
+		{Term: "Kubernetes", Variants: []string{"kubernetes", "Cooper Netties"}},
+	}},
+	{Name: "medical-zh", Language: "zh", Terms: []Term{
+		{Term: "心肌梗死", Variants: []string{"心肌梗塞"}},
+	}},
+}
+
+func TestPacks_Correct(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		language  string
+		want      string
+		wantCount int
+	}{
+		{
+			name:      "variants and first expansion",
+			text:      "The EKG was normal, the ekg again and the CT too.",
+			language:  "en",
+			want:      "The ECG (electrocardiogram) was normal, the ECG again and the CT (computed tomography) too.",
+			wantCount: 2,
+		},
+		{
+			name:      "whole words only",
+			text:      "No ACTION on cooper netties.",
+			language:  "en",
+			want:      "No ACTION on Kubernetes.",
+			wantCount: 1,
+		},
+		{
+			name:      "longest variant first",
+			text:      "Take acetaminophin tablets.",
+			language:  "en-US",
+			want:      "Take acetaminophen.",
+			wantCount: 1,
+		},
+		{
+			name:      "Chinese without word boundaries",
+			text:      "急性心肌梗塞患者用kubernetes",
+			language:  "zh",
+			want:      "急性心肌梗死患者用Kubernetes",
+			wantCount: 2,
+		},
+		{
+			name:     "packs of other languages are skipped",
+			text:     "心肌梗塞 EKG",
+			language: "ja",
+			want:     "心肌梗塞 EKG",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := testPacks.Correct(tt.text, tt.language)
+			if got != tt.want || count != tt.wantCount {
+				t.Errorf("Correct() = %q, %d, want %q, %d", got, count, tt.want, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestPacks_Prompt(t *testing.T) {
+	if got, want := testPacks.Prompt("zh"), "Kubernetes、心肌梗死。"; got != want {
+		t.Errorf("Prompt(zh) = %q, want %q", got, want)
+	}
+	if got, want := testPacks.Prompt("en"), "ECG (electrocardiogram), CT (computed tomography), acetaminophen, Kubernetes. "; got != want {
+		t.Errorf("Prompt(en) = %q, want %q", got, want)
+	}
+	if got := (Packs{}).Prompt("en"); got != "" {
+		t.Errorf("Prompt() without packs = %q", got)
+	}
+
+	long := Packs{{Name: "long"}}
+	for i := 0; i < 100; i++ {
+		long[0].Terms = append(long[0].Terms, Term{Term: "term"})
+	}
+	if got := long.Prompt("en"); len([]rune(got)) > MaxPromptRunes+2 || !strings.HasSuffix(got, "term. ") {
+		t.Errorf("Prompt() of many terms = %q, want at most %d runes of whole terms", got, MaxPromptRunes)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "valid", data: "name: law\nterms:\n  - term: plaintiff\n    variants: [plaintive]\n"},
+		{name: "invalid name", data: "name: Law Terms\nterms:\n  - term: plaintiff\n", wantErr: true},
+		{name: "no terms", data: "name: law\n", wantErr: true},
+		{name: "empty variant", data: "name: law\nterms:\n  - term: plaintiff\n    variants: [\"\"]\n", wantErr: true},
+		{name: "not yaml", data: "name: [", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstall(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "vocab")
+	if len(Builtin()) == 0 {
+		t.Fatal("Builtin() returned no packs")
+	}
+
+	if _, err := Install(dir, "medical-zh"); err != nil {
+		t.Fatal(err)
+	}
+	custom := filepath.Join(t.TempDir(), "pack.yaml")
+	os.WriteFile(custom, []byte("name: law\nterms:\n  - term: plaintiff\n"), 0644)
+	if p, err := Install(dir, custom); err != nil || p.Name != "law" {
+		t.Fatalf("Install() of a file = %v, %v", p.Name, err)
+	}
+	if _, err := Install(dir, "astronomy"); err == nil {
+		t.Error("Install() of an unknown pack succeeded")
+	}
+
+	packs, err := Load(dir)
+	if err != nil || len(packs) != 2 || packs[0].Name != "law" || packs[1].Name != "medical-zh" {
+		t.Fatalf("Load() = %v, %v, want law and medical-zh", packs, err)
+	}
+
+	if err = Remove(dir, "law"); err != nil {
+		t.Fatal(err)
+	}
+	if err = Remove(dir, "law"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Remove() of a removed pack = %v, want ErrNotInstalled", err)
+	}
+	if err = Remove(dir, "../config"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Remove() of a path = %v, want ErrNotInstalled", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package watch hands the files dropped into a directory to a handler, once they are completely
+// written, e.g. to transcribe the episodes copied into a podcast ingest folder.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"tiktok-whisper/internal/app/logging"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/samber/lo"
+)
+
+// DefaultSettle is how long a file has to stay unchanged before it is handled.
+const DefaultSettle = 2 * time.Second
+
+// Handler processes a file that was dropped into the directory, an error is logged and the watch
+// goes on.
+type Handler func(path string) error
+
+// Options of Watch.
+type Options struct {
+	// Extensions are the extensions of the files to handle without the dot, e.g. mp3, any case.
+	Extensions []string
+	// Settle is how long a file has to stay unchanged before it is handled, so files still being
+	// copied or downloaded are left alone. DefaultSettle when zero.
+	Settle time.Duration
+	// Existing also hands the files already in the directory to the handler when the watch starts.
+	Existing bool
+}
+
+// pending is a file that changed and waits to settle.
+type pending struct {
+	changed time.Time
+	size    int64
+}
+
+// Watch calls handle for the files with one of the extensions that are created in or moved into dir,
+// after they stopped changing. The files are handled one at a time in the order they settled. It runs
+// until ctx is done and returns once the file being handled is done.
+func Watch(ctx context.Context, dir string, opts Options, handle Handler) error {
+	if len(opts.Extensions) == 0 {
+		return errors.New("no extensions to watch")
+	}
+	if opts.Settle <= 0 {
+		opts.Settle = DefaultSettle
+	}
+	extensions := lo.Map(opts.Extensions, func(ext string, i int) string {
+		return "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+	})
+	matches := func(path string) bool {
+		return lo.Contains(extensions, strings.ToLower(filepath.Ext(path)))
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err = watcher.Add(dir); err != nil {
+		return fmt.Errorf("watch %s failed: %v", dir, err)
+	}
+	logging.L().Info("Watching for new files", "dir", dir, "extensions", opts.Extensions)
+
+	// the files are handled by a single goroutine, so events keep being read during long conversions
+	settled := make(chan string, 64)
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		for path := range settled {
+			if err := handle(path); err != nil {
+				logging.L().Error("Error handling watched file", "file", path, "error", err)
+			}
+		}
+	}()
+	defer func() {
+		close(settled)
+		<-handled
+	}()
+
+	files := make(map[string]*pending)
+	if opts.Existing {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if path := filepath.Join(dir, entry.Name()); entry.Type().IsRegular() && matches(path) {
+				files[path] = &pending{changed: time.Now(), size: -1}
+			}
+		}
+	}
+
+	ticker := time.NewTicker(opts.Settle / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logging.L().Error("Error watching directory", "dir", dir, "error", err)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !matches(event.Name) {
+				continue
+			}
+			switch {
+			case event.Has(fsnotify.Create), event.Has(fsnotify.Write):
+				if p, ok := files[event.Name]; ok {
+					p.changed = time.Now()
+				} else {
+					files[event.Name] = &pending{changed: time.Now(), size: -1}
+				}
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				delete(files, event.Name)
+			}
+		case now := <-ticker.C:
+			for path, p := range files {
+				if now.Sub(p.changed) < opts.Settle {
+					continue
+				}
+				info, err := os.Stat(path)
+				if err != nil || !info.Mode().IsRegular() {
+					delete(files, path)
+					continue
+				}
+				// writers that don't cause events, e.g. on network shares, still change the size
+				if info.Size() != p.size {
+					p.changed, p.size = now, info.Size()
+					continue
+				}
+				delete(files, path)
+				logging.L().Info("New file settled", "file", path, "bytes", info.Size())
+				select {
+				case settled <- path:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
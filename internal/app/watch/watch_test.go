@@ -0,0 +1,107 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"tiktok-whisper/internal/app/testutil"
+	"time"
+)
+
+// recorder records the handled files.
+type recorder struct {
+	mu    sync.Mutex
+	paths []string
+	sizes []int64
+}
+
+func (r *recorder) handle(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(r.paths, filepath.Base(path))
+	r.sizes = append(r.sizes, info.Size())
+	return nil
+}
+
+func (r *recorder) handled() ([]string, []int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.paths...), append([]int64{}, r.sizes...)
+}
+
+func TestWatch(t *testing.T) {
+	testutil.UseMockLogger(t)
+	tests := []struct {
+		name     string
+		existing bool
+		want     []string
+	}{
+		{name: "new files", want: []string{"episode.MP3"}},
+		{name: "existing files too", existing: true, want: []string{"old.mp3", "episode.MP3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "old.mp3"), []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			r := &recorder{}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- Watch(ctx, dir, Options{Extensions: []string{"mp3"}, Settle: 100 * time.Millisecond, Existing: tt.existing}, r.handle)
+			}()
+			time.Sleep(50 * time.Millisecond)
+
+			// written in parts like a slow copy, the file is handled once when complete
+			f, err := os.Create(filepath.Join(dir, "episode.MP3"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 3; i++ {
+				f.Write([]byte("part"))
+				time.Sleep(40 * time.Millisecond)
+			}
+			f.Close()
+			os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("skip"), 0644)
+
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				if paths, _ := r.handled(); len(paths) >= len(tt.want) || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			time.Sleep(300 * time.Millisecond)
+			cancel()
+			if err := <-done; err != nil {
+				t.Fatalf("Watch() error = %v", err)
+			}
+
+			paths, sizes := r.handled()
+			if len(paths) != len(tt.want) {
+				t.Fatalf("handled %v, want %v", paths, tt.want)
+			}
+			for i := range tt.want {
+				if paths[i] != tt.want[i] {
+					t.Errorf("handled %v, want %v", paths, tt.want)
+				}
+			}
+			if sizes[len(sizes)-1] != 12 {
+				t.Errorf("episode handled at %d bytes, want the complete 12", sizes[len(sizes)-1])
+			}
+		})
+	}
+}
+
+func TestWatch_NoExtensions(t *testing.T) {
+	if err := Watch(context.Background(), t.TempDir(), Options{}, func(string) error { return nil }); err == nil {
+		t.Errorf("Watch() without extensions succeeded, want an error")
+	}
+}
@@ -4,42 +4,425 @@
 package app
 
 import (
+	"fmt"
 	"github.com/google/wire"
 	"log"
 	"path/filepath"
+	"strings"
+	"sync"
+	"tiktok-whisper/internal/app/analytics"
 	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/assemblyai"
+	"tiktok-whisper/internal/app/api/benchmark"
+	"tiktok-whisper/internal/app/api/chunked"
+	"tiktok-whisper/internal/app/api/deepgram"
+	"tiktok-whisper/internal/app/api/faster_whisper"
+	"tiktok-whisper/internal/app/api/google_stt"
 	"tiktok-whisper/internal/app/api/openai"
 	"tiktok-whisper/internal/app/api/openai/whisper"
+	"tiktok-whisper/internal/app/api/openai_compatible"
+	"tiktok-whisper/internal/app/api/provider"
+	"tiktok-whisper/internal/app/api/ssh_whisper"
+	"tiktok-whisper/internal/app/api/transcode"
+	"tiktok-whisper/internal/app/api/validation"
 	"tiktok-whisper/internal/app/api/whisper_cpp"
+	"tiktok-whisper/internal/app/api/whisper_native"
+	"tiktok-whisper/internal/app/api/whisper_server"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/config/secrets"
 	"tiktok-whisper/internal/app/converter"
+	"tiktok-whisper/internal/app/events"
+	"tiktok-whisper/internal/app/logging"
+	"tiktok-whisper/internal/app/mcp"
+	"tiktok-whisper/internal/app/refine"
 	"tiktok-whisper/internal/app/repository"
-	"tiktok-whisper/internal/app/repository/sqlite"
+	"tiktok-whisper/internal/app/repository/router"
+	"tiktok-whisper/internal/app/server"
+	"tiktok-whisper/internal/app/slo"
 	"tiktok-whisper/internal/app/util/files"
 )
 
-// provideRemoteTranscriber with openai's remote service conversion, must set environment variable OPENAI_API_KEY
+// provideRemoteTranscriber with openai's remote service conversion, needs the openai key of v2t config set-key or OPENAI_API_KEY.
+// Long audio is sent in chunks to stay below the upload limit, results are validated as configured in config.yaml.
 func provideRemoteTranscriber() api.Transcriber {
-	return whisper.NewRemoteTranscriber(openai.GetClient())
+	p := whisper.NewRemoteTranscriber(openai.GetClient())
+	t := transcode.Wrap(chunk(retry(p, "openai"), chunked.DefaultChunkSeconds), p)
+	return validation.Wrap(t, config.Get().Validation)
 }
 
 // provideLocalTranscriber with native whisper.cpp conversion, you need to compile whisper.cpp/main executable by yourself
+// Long audio is only chunked when config.yaml sets a chunk length, languages routed in providers.yaml
+// are sent to their own providers. The whisper.cpp servers of whisper_server in providers.yaml, when
+// there are some, transcribe instead of the executable.
 func provideLocalTranscriber() api.Transcriber {
-	binaryPath := "/Volumes/SSD2T/workspace/cpp/whisper.cpp/main"
-	modelPath := "/Volumes/SSD2T/workspace/cpp/whisper.cpp/models/ggml-large-v2.bin"
-	return whisper_cpp.NewLocalTranscriber(binaryPath, modelPath)
+	if len(config.GetProviders().For("whisper_server").Servers) > 0 {
+		return routeLanguages(provideServerTranscriber())
+	}
+	return routeLanguages(validation.Wrap(local(newLocalProvider()), config.Get().Validation))
+}
+
+// provideServerTranscriber spreads the files over the whisper.cpp servers of whisper_server in providers.yaml.
+func provideServerTranscriber() api.Transcriber {
+	b, err := whisper_server.New()
+	if err != nil {
+		log.Fatalf("Failed to create the whisper.cpp servers: %v\n", err)
+	}
+	return validation.Wrap(servers(b), config.Get().Validation)
+}
+
+// provideFasterWhisperTranscriber transcribes with the faster-whisper server of faster_whisper in providers.yaml.
+func provideFasterWhisperTranscriber() api.Transcriber {
+	t, err := faster_whisper.New()
+	if err != nil {
+		log.Fatalf("Failed to create the faster_whisper provider: %v\n", err)
+	}
+	return validation.Wrap(transcode.Wrap(chunk(retry(t, "faster_whisper"), 0), t), config.Get().Validation)
+}
+
+// provideGoogleTranscriber transcribes with the Speech-to-Text recognizer of google_stt in providers.yaml.
+func provideGoogleTranscriber(t *google_stt.GoogleTranscriber) api.Transcriber {
+	return validation.Wrap(transcode.Wrap(chunk(retry(t, "google_stt"), 0), t), config.Get().Validation)
+}
+
+// provideDeepgramTranscriber transcribes with the Deepgram model of deepgram in providers.yaml.
+func provideDeepgramTranscriber(t *deepgram.DeepgramTranscriber) api.Transcriber {
+	return validation.Wrap(transcode.Wrap(chunk(retry(t, "deepgram"), 0), t), config.Get().Validation)
+}
+
+// provideAssemblyAITranscriber transcribes with the AssemblyAI model of assemblyai in providers.yaml.
+func provideAssemblyAITranscriber(t *assemblyai.AssemblyAITranscriber) api.Transcriber {
+	return validation.Wrap(transcode.Wrap(chunk(retry(t, "assemblyai"), 0), t), config.Get().Validation)
+}
+
+// provideCompatibleTranscriber transcribes with the OpenAI-compatible service of openai_compatible in providers.yaml.
+func provideCompatibleTranscriber() api.Transcriber {
+	t, err := openai_compatible.New()
+	if err != nil {
+		log.Fatalf("Failed to create the openai_compatible provider: %v\n", err)
+	}
+	return validation.Wrap(transcode.Wrap(chunk(retry(t, "openai_compatible"), 0), t), config.Get().Validation)
+}
+
+// provideSSHTranscriber transcribes with whisper.cpp on the host of ssh_whisper in providers.yaml.
+func provideSSHTranscriber() api.Transcriber {
+	t, err := ssh_whisper.New()
+	if err != nil {
+		log.Fatalf("Failed to create the ssh_whisper provider: %v\n", err)
+	}
+	return validation.Wrap(transcode.Wrap(chunk(retry(t, "ssh_whisper"), 0), t), config.Get().Validation)
+}
+
+// provideDraftTranscriber transcribes the drafts of the two-pass mode with the fast draft model of config.yaml.
+func provideDraftTranscriber() api.Transcriber {
+	return validation.Wrap(local(newLocalModel(config.Get().Refine.DraftModel)), config.Get().Validation)
 }
 
+// provideRefineTranscriber transcribes the drafts again with the high-quality refine model of config.yaml.
+func provideRefineTranscriber() api.Transcriber {
+	return validation.Wrap(local(newLocalModel(config.Get().Refine.Model)), config.Get().Validation)
+}
+
+// routeLanguages detects the language of each file and sends it to the provider routed to the language
+// in providers.yaml, t transcribes the other languages. t is returned as is when nothing is routed.
+func routeLanguages(t api.Transcriber) api.Transcriber {
+	cfg := config.GetProviders().Languages
+	if len(cfg.Routes) == 0 {
+		return t
+	}
+
+	routes := make(map[string]api.Transcriber, len(cfg.Routes))
+	for language, route := range cfg.Routes {
+		routed, err := newLanguageProvider(language, route)
+		if err != nil {
+			log.Fatalf("Failed to create the provider of language %s: %v\n", language, err)
+		}
+		routes[language] = routed
+	}
+	return provider.NewLanguageRouter(newLocalModel(cfg.DetectModel), routes, t)
+}
+
+// newLanguageProvider creates the provider of a language route, retried, chunked and validated like the default providers.
+func newLanguageProvider(language string, route config.LanguageRoute) (api.Transcriber, error) {
+	switch route.Provider {
+	case "openai":
+		t := whisper.NewRemoteTranscriber(openai.GetClient())
+		t.SetLanguage(language)
+		return validation.Wrap(transcode.Wrap(chunk(retry(t, route.Provider), chunked.DefaultChunkSeconds), t), config.Get().Validation), nil
+	case "whisper_cpp":
+		t := newLocalModel(route.Model)
+		t.SetLanguage(language)
+		return validation.Wrap(local(t), config.Get().Validation), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q, available: %s", route.Provider, strings.Join(ProviderNames, ", "))
+	}
+}
+
+// chunk splits the audio of t as configured in config.yaml, defaultSeconds applies when no chunk
+// length is configured and zero leaves t alone.
+func chunk(t api.PromptTranscriber, defaultSeconds int) api.Transcriber {
+	cfg := config.Get().Chunking
+	seconds := cfg.Seconds
+	if seconds == 0 {
+		seconds = defaultSeconds
+	}
+	if seconds == 0 {
+		return t
+	}
+
+	c := chunked.NewTranscriber(t, seconds)
+	c.SetOverlap(cfg.Overlap)
+	c.SetParallel(cfg.Parallel)
+	return c
+}
+
+// local sets up a whisper.cpp provider: retried, chunked as configured and fed the 16kHz WAV it reads.
+func local(t *whisper_cpp.LocalTranscriber) api.Transcriber {
+	return transcode.Wrap(chunk(retry(t, "whisper_cpp"), 0), t)
+}
+
+// native sets up the in-process whisper.cpp provider like local.
+func native(t *whisper_native.NativeTranscriber) api.Transcriber {
+	return transcode.Wrap(chunk(retry(t, "whisper_native"), 0), t)
+}
+
+// servers sets up whisper.cpp servers like local, a failure is retried once every server failed it.
+func servers(b *provider.Balancer) api.Transcriber {
+	return transcode.Wrap(chunk(retry(b, "whisper_server"), 0), b)
+}
+
+// retry retries the retryable failures of t as configured for the provider name in providers.yaml.
+// It wraps the bare provider, so a long audio only transcribes the failed chunk again.
+func retry(t api.OptionsTranscriber, name string) *provider.RetryTranscriber {
+	return provider.NewRetryTranscriber(t, config.GetProviders().For(name).Retry)
+}
+
+const (
+	localBinaryPath = "/Volumes/SSD2T/workspace/cpp/whisper.cpp/main"
+	localModelPath  = "/Volumes/SSD2T/workspace/cpp/whisper.cpp/models/ggml-large-v2.bin"
+)
+
+func newLocalProvider() *whisper_cpp.LocalTranscriber {
+	return newLocalModel("")
+}
+
+// newLocalModel creates the whisper.cpp provider with the model at modelPath, the default model when it is empty.
+func newLocalModel(modelPath string) *whisper_cpp.LocalTranscriber {
+	if modelPath == "" {
+		modelPath = localModelPath
+	}
+	return whisper_cpp.NewLocalTranscriber(localBinaryPath, modelPath)
+}
+
+// ProviderNames are the providers NewProvider creates.
+var ProviderNames = []string{"openai", "google_stt", "deepgram", "assemblyai", "openai_compatible", "whisper_server", "faster_whisper", "ssh_whisper", "whisper_native", "whisper_cpp"}
+
+// AutoProvider is the provider name NewProvider resolves to the provider recommended by the
+// measurements of v2t providers benchmark.
+const AutoProvider = "auto"
+
+// NewProvider creates the bare transcriber of a provider, without chunking, validation or middlewares.
+func NewProvider(name string) (api.Transcriber, error) {
+	switch name {
+	case AutoProvider:
+		path, err := benchmark.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		recommended, err := benchmark.Recommended(path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using %s, the recommended provider of the benchmarks\n", recommended)
+		return NewProvider(recommended)
+	case "openai":
+		if _, err := secrets.Key("openai"); err != nil {
+			return nil, err
+		}
+		return whisper.NewRemoteTranscriber(openai.GetClient()), nil
+	case "google_stt":
+		return google_stt.New()
+	case "deepgram":
+		return deepgram.New()
+	case "assemblyai":
+		return assemblyai.New()
+	case "openai_compatible":
+		return openai_compatible.New()
+	case "whisper_server":
+		return whisper_server.New()
+	case "faster_whisper":
+		return faster_whisper.New()
+	case "ssh_whisper":
+		return ssh_whisper.New()
+	case "whisper_native":
+		return whisper_native.New()
+	case "whisper_cpp":
+		return newLocalProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q, available: %s", name, strings.Join(ProviderNames, ", "))
+	}
+}
+
+// provideFallbackTranscriber uses the OpenAI API and falls back to the local whisper.cpp when the API is unavailable,
+// skipping the providers whose circuit is open. Swap it for provideLocalTranscriber in an injector to enable it.
+func provideFallbackTranscriber() api.Transcriber {
+	return DefaultProviderRegistry().Transcriber()
+}
+
+var (
+	registryOnce     sync.Once
+	providerRegistry *provider.Registry
+)
+
+// DefaultProviderRegistry returns the providers of ProviderNames in that order, set up like provideRemoteTranscriber
+// and provideLocalTranscriber, each behind the circuit breaker configured in providers.yaml. Providers that can't be
+// created, e.g. openai without a key, aren't registered. The circuits are kept in data/provider_breakers.json.
+func DefaultProviderRegistry() *provider.Registry {
+	registryOnce.Do(func() {
+		path := ""
+		if projectRoot, err := files.GetProjectRoot(); err == nil {
+			path = filepath.Join(projectRoot, "data", provider.BreakersFile)
+		}
+		r, err := provider.NewRegistry(path)
+		if err != nil {
+			logging.L().Error("Failed to read the provider circuits, starting with closed ones", "path", path, "error", err)
+			r, _ = provider.NewRegistry("")
+		}
+
+		if _, err = secrets.Key("openai"); err != nil {
+			logging.L().Warn("Provider unavailable, not registered", "provider", "openai", "error", err)
+		} else {
+			r.Register("openai", provideRemoteTranscriber(), config.GetProviders().For("openai").CircuitBreaker)
+		}
+		if google := config.GetProviders().For("google_stt").Google; google.CredentialsFile != "" || google.Project != "" {
+			if t, err := google_stt.New(); err != nil {
+				logging.L().Warn("Provider unavailable, not registered", "provider", "google_stt", "error", err)
+			} else {
+				r.Register("google_stt", routeLanguages(provideGoogleTranscriber(t)), config.GetProviders().For("google_stt").CircuitBreaker)
+			}
+		}
+		// deepgram is only registered when it has a key, unlike openai its absence is not worth a warning
+		if t, err := deepgram.New(); err == nil {
+			r.Register("deepgram", routeLanguages(provideDeepgramTranscriber(t)), config.GetProviders().For("deepgram").CircuitBreaker)
+		}
+		if t, err := assemblyai.New(); err == nil {
+			r.Register("assemblyai", routeLanguages(provideAssemblyAITranscriber(t)), config.GetProviders().For("assemblyai").CircuitBreaker)
+		}
+		if config.GetProviders().For("openai_compatible").Endpoint.BaseURL != "" {
+			r.Register("openai_compatible", routeLanguages(provideCompatibleTranscriber()), config.GetProviders().For("openai_compatible").CircuitBreaker)
+		}
+		if len(config.GetProviders().For("whisper_server").Servers) > 0 {
+			r.Register("whisper_server", routeLanguages(provideServerTranscriber()), config.GetProviders().For("whisper_server").CircuitBreaker)
+		}
+		if config.GetProviders().For("faster_whisper").FasterWhisper.URL != "" {
+			r.Register("faster_whisper", routeLanguages(provideFasterWhisperTranscriber()), config.GetProviders().For("faster_whisper").CircuitBreaker)
+		}
+		if config.GetProviders().For("ssh_whisper").SSH.Host != "" {
+			r.Register("ssh_whisper", routeLanguages(provideSSHTranscriber()), config.GetProviders().For("ssh_whisper").CircuitBreaker)
+		}
+		if config.GetProviders().For("whisper_native").Native.Model != "" {
+			if t, err := whisper_native.New(); err != nil {
+				logging.L().Warn("Provider unavailable, not registered", "provider", "whisper_native", "error", err)
+			} else {
+				r.Register("whisper_native", routeLanguages(validation.Wrap(native(t), config.Get().Validation)), config.GetProviders().For("whisper_native").CircuitBreaker)
+			}
+		}
+		binary := routeLanguages(validation.Wrap(local(newLocalProvider()), config.Get().Validation))
+		r.Register("whisper_cpp", binary, config.GetProviders().For("whisper_cpp").CircuitBreaker)
+		providerRegistry = r
+	})
+	return providerRegistry
+}
+
+// databaseRouter is shared by every injector, so each database is connected to once per process.
+var (
+	routerOnce     sync.Once
+	databaseRouter *router.Router
+)
+
+func provideDatabaseRouter() *router.Router {
+	routerOnce.Do(func() {
+		databaseRouter = router.New(config.Get().Databases, nil)
+	})
+	return databaseRouter
+}
+
+// provideUserTranscriptionDAO returns the database user is routed to in config.yaml.
+func provideUserTranscriptionDAO(user string) repository.TranscriptionDAO {
+	dao, err := provideDatabaseRouter().ForUser(user)
+	if err != nil {
+		log.Fatalf("Failed to open database of user %s: %v\n", user, err)
+	}
+	return dao
+}
+
+// provideTranscriptionDAO returns the default database.
 func provideTranscriptionDAO() repository.TranscriptionDAO {
-	projectRoot, err := files.GetProjectRoot()
+	return provideUserTranscriptionDAO("")
+}
+
+func provideAnalyticsConfig() config.AnalyticsConfig {
+	return config.Get().Analytics
+}
+
+// provideSLOs returns the objectives of the providers, checked by v2t serve.
+func provideSLOs() map[string]config.SLOConfig {
+	return config.Get().SLOs
+}
+
+func provideEventBus() events.Bus {
+	bus, err := events.New(config.Get().Events)
 	if err != nil {
-		log.Fatalf("Failed to get project root: %v\n", err)
+		log.Fatalf("Failed to create event bus: %v\n", err)
 	}
+	return bus
+}
 
-	dbPath := filepath.Join(projectRoot, "data/transcription.db")
-	return sqlite.NewSQLiteDB(dbPath)
+// InitializeConverter stores the results in the database user is routed to.
+func InitializeConverter(user string) *converter.Converter {
+	wire.Build(converter.NewConverter, provideLocalTranscriber, provideUserTranscriptionDAO, provideEventBus)
+	return &converter.Converter{}
 }
 
-func InitializeConverter() *converter.Converter {
-	wire.Build(converter.NewConverter, provideLocalTranscriber, provideTranscriptionDAO)
+// InitializeDraftConverter transcribes drafts with the fast draft model, for convert --draft.
+func InitializeDraftConverter(user string) *converter.Converter {
+	wire.Build(converter.NewConverter, provideDraftTranscriber, provideUserTranscriptionDAO, provideEventBus)
 	return &converter.Converter{}
 }
+
+// InitializeRefiner refines the drafts stored in the database user is routed to.
+func InitializeRefiner(user string) *refine.Refiner {
+	wire.Build(refine.NewRefiner, provideRefineTranscriber, provideUserTranscriptionDAO, provideEventBus)
+	return &refine.Refiner{}
+}
+
+func InitializeAnalyzer() *analytics.Analyzer {
+	wire.Build(analytics.NewAnalyzer, provideTranscriptionDAO, provideAnalyticsConfig)
+	return &analytics.Analyzer{}
+}
+
+func InitializeTranscriptionDAO() repository.TranscriptionDAO {
+	wire.Build(provideTranscriptionDAO)
+	return nil
+}
+
+func InitializeTranscriptionDAOForUser(user string) repository.TranscriptionDAO {
+	wire.Build(provideUserTranscriptionDAO)
+	return nil
+}
+
+func InitializeServer() *server.Server {
+	wire.Build(server.NewServer, provideLocalTranscriber, provideDatabaseRouter, slo.NewMonitor, provideSLOs, provideEventBus)
+	return &server.Server{}
+}
+
+// InitializeAgentTranscriber transcribes the tasks v2t-agent pulls with the local whisper.cpp, like convert.
+func InitializeAgentTranscriber() api.Transcriber {
+	wire.Build(provideLocalTranscriber)
+	return nil
+}
+
+// InitializeMCPServer serves the databases of every user to MCP clients.
+func InitializeMCPServer(version string) *mcp.Server {
+	wire.Build(mcp.NewServer, provideDatabaseRouter, wire.Bind(new(mcp.Databases), new(*router.Router)))
+	return &mcp.Server{}
+}
@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// YtDlp downloads the audio of video pages with the yt-dlp binary, which supports TikTok,
+// YouTube, Bilibili and many other sites, see https://github.com/yt-dlp/yt-dlp.
+type YtDlp struct {
+	// Binary is the path of yt-dlp, it is looked up in PATH when empty.
+	Binary string
+}
+
+// DownloadAudio downloads the best audio of url into dir and returns the path of the file.
+// The file is named after the site and the video id, downloading a video again reuses it.
+func (y YtDlp) DownloadAudio(url string, dir string) (string, error) {
+	binary := y.Binary
+	if binary == "" {
+		binary = "yt-dlp"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create download dir failed: %v", err)
+	}
+
+	// after_move prints the final path once the file is complete, also when it was downloaded before
+	cmd := exec.Command(binary, "--no-playlist", "--format", "bestaudio/best",
+		"--output", filepath.Join(dir, "%(extractor)s_%(id)s.%(ext)s"),
+		"--print", "after_move:filepath", "--", url)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("yt-dlp not found, install it from https://github.com/yt-dlp/yt-dlp: %v", err)
+		}
+		return "", fmt.Errorf("yt-dlp error: %v, stderr: %s", err, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	path := strings.TrimSpace(lines[len(lines)-1])
+	if path == "" {
+		return "", fmt.Errorf("yt-dlp downloaded nothing from %s", url)
+	}
+	return path, nil
+}
@@ -0,0 +1,53 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeYtDlp writes a script standing in for yt-dlp, it runs body with the arguments of yt-dlp.
+func fakeYtDlp(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "yt-dlp")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestYtDlp_DownloadAudio(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		binary   string
+		wantFile string
+		wantErr  bool
+	}{
+		{
+			name:     "downloaded",
+			script:   "echo '[youtube] abc: Downloading webpage'\necho \"$5\" | sed 's/%(extractor)s_%(id)s.%(ext)s/youtube_abc.m4a/'\n",
+			wantFile: "youtube_abc.m4a",
+		},
+		{name: "failed", script: "echo 'ERROR: Unsupported URL' >&2\nexit 1\n", wantErr: true},
+		{name: "nothing printed", script: "exit 0\n", wantErr: true},
+		{name: "not installed", binary: filepath.Join(t.TempDir(), "missing"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binary := tt.binary
+			if binary == "" {
+				binary = fakeYtDlp(t, tt.script)
+			}
+			dir := filepath.Join(t.TempDir(), "download")
+
+			got, err := YtDlp{Binary: binary}.DownloadAudio("https://www.youtube.com/watch?v=abc", dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DownloadAudio() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != filepath.Join(dir, tt.wantFile) {
+				t.Errorf("DownloadAudio() = %s, want %s", got, filepath.Join(dir, tt.wantFile))
+			}
+		})
+	}
+}
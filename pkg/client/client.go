@@ -0,0 +1,230 @@
+// Package client embeds tiktok-whisper in other Go programs: transcribe audio files into a database,
+// search the stored transcriptions and export them, without copying the wiring of cmd/v2t.
+//
+//	c, err := client.New(client.Config{Provider: "openai", Driver: "sqlite", DSN: "transcriptions.db"})
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	t, err := c.Transcribe("talk.mp3")
+package client
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"tiktok-whisper/internal/app"
+	"tiktok-whisper/internal/app/api"
+	"tiktok-whisper/internal/app/api/middleware"
+	"tiktok-whisper/internal/app/audio"
+	"tiktok-whisper/internal/app/config"
+	"tiktok-whisper/internal/app/converter/export"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository"
+	"tiktok-whisper/internal/app/repository/router"
+	"tiktok-whisper/internal/app/search"
+	"time"
+)
+
+// DefaultProvider transcribes when Config.Provider is empty.
+const DefaultProvider = "whisper_cpp"
+
+// DefaultUser owns the transcriptions when Config.User is empty.
+const DefaultUser = "default"
+
+// Config configures a Client, the zero value transcribes with whisper.cpp into an in-memory database.
+type Config struct {
+	// Provider is openai, whisper_cpp or auto, the provider recommended by v2t providers benchmark.
+	Provider string
+	// Driver is the database, "memory" (default), "sqlite" or "postgres".
+	Driver string
+	// DSN is the sqlite file path or the postgres connection string.
+	DSN string
+	// User owns the transcriptions of the client.
+	User string
+	// Retries is how often a failing provider call is tried again, with a growing backoff.
+	Retries int
+}
+
+// Segment is a timed piece of a transcript, Start and End are zero when the provider reports no timings.
+type Segment struct {
+	Start   float64
+	End     float64
+	Speaker string
+	Text    string
+}
+
+// Transcription is a stored transcription.
+type Transcription struct {
+	ID   int
+	User string
+	// File is the name of the transcribed file.
+	File          string
+	Text          string
+	AudioDuration float64
+	Provider      string
+	Model         string
+	TranscribedAt time.Time
+	// Segments are empty when the provider reported none or the database doesn't keep them.
+	Segments []Segment
+}
+
+// Client transcribes for one user and keeps the results in its database.
+type Client struct {
+	user        string
+	transcriber api.Transcriber
+	db          repository.TranscriptionDAO
+
+	duration func(filePath string) (int, error)
+}
+
+// New creates a client with the provider and database of cfg, Close releases the database.
+func New(cfg Config) (*Client, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = DefaultProvider
+	}
+	t, err := app.NewProvider(cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Retries > 0 {
+		t = middleware.New(t, middleware.Retry(cfg.Retries+1, time.Second))
+	}
+
+	if cfg.Driver == "" {
+		cfg.Driver = "memory"
+	}
+	db, err := router.Open(config.DatabaseInstance{Driver: cfg.Driver, DSN: cfg.DSN})
+	if err != nil {
+		return nil, err
+	}
+	return newClient(cfg, t, db), nil
+}
+
+func newClient(cfg Config, t api.Transcriber, db repository.TranscriptionDAO) *Client {
+	if cfg.User == "" {
+		cfg.User = DefaultUser
+	}
+	return &Client{user: cfg.User, transcriber: t, db: db, duration: audio.GetAudioDuration}
+}
+
+// Close closes the database.
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+// Transcribe transcribes the audio file and stores the result, a file transcribed before is stored
+// as a new revision when the database keeps revisions. Failures are stored too.
+func (c *Client) Transcribe(audioFilePath string) (Transcription, error) {
+	fileName := filepath.Base(audioFilePath)
+	inputDir := filepath.Dir(audioFilePath)
+
+	duration, err := c.duration(audioFilePath)
+	if err != nil {
+		c.db.RecordToDB(c.user, inputDir, fileName, fileName, 0, "", time.Now(), 1,
+			fmt.Sprintf("Failed to get audio duration: %v", err), model.ProviderMetadata{})
+		return Transcription{}, fmt.Errorf("failed to get audio duration: %v", err)
+	}
+
+	var text string
+	var metadata model.ProviderMetadata
+	if mt, ok := c.transcriber.(api.MetadataTranscriber); ok {
+		text, metadata, err = mt.TranscriptWithMetadata(audioFilePath)
+	} else {
+		text, err = c.transcriber.Transcript(audioFilePath)
+	}
+	if err != nil {
+		c.db.RecordToDB(c.user, inputDir, fileName, fileName, duration, "", time.Now(), 1,
+			fmt.Sprintf("Transcription error: %v", err), metadata)
+		return Transcription{}, fmt.Errorf("transcription error: %v", err)
+	}
+
+	id, err := c.save(inputDir, fileName, duration, text, metadata)
+	if err != nil {
+		return Transcription{}, err
+	}
+	if segments, ok := c.db.(repository.SegmentDAO); ok && len(metadata.Segments) > 0 {
+		if err = segments.SaveSegments(id, metadata.Segments); err != nil {
+			return Transcription{}, fmt.Errorf("save segments failed: %v", err)
+		}
+	}
+
+	t, err := c.db.GetByID(id)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("look up stored transcription failed: %v", err)
+	}
+	t.Segments = metadata.Segments
+	return newTranscription(*t), nil
+}
+
+// save stores the result and returns the id of its transcription.
+func (c *Client) save(inputDir, fileName string, duration int, text string, metadata model.ProviderMetadata) (int, error) {
+	if revisions, ok := c.db.(repository.RevisionDAO); ok {
+		if id, err := c.db.CheckIfFileProcessed(fileName); err == nil {
+			if _, err = revisions.AddRevision(id, text, metadata, time.Now()); err != nil {
+				return 0, fmt.Errorf("add revision failed: %v", err)
+			}
+			return id, nil
+		}
+	}
+
+	c.db.RecordToDB(c.user, inputDir, fileName, fileName, duration, text, time.Now(), 0, "", metadata)
+	id, err := c.db.CheckIfFileProcessed(fileName)
+	if err != nil {
+		return 0, fmt.Errorf("look up stored transcription failed: %v", err)
+	}
+	return id, nil
+}
+
+// Search returns the transcriptions of the user whose file name and text contain every word of query,
+// in any order and case, newest first with the likely misheard transcriptions last.
+func (c *Client) Search(query string) ([]Transcription, error) {
+	results, err := search.Transcriptions(c.db, c.user, query, search.Options{})
+	if err != nil {
+		return nil, err
+	}
+	var found []Transcription
+	for _, r := range results {
+		found = append(found, newTranscription(r.Transcription))
+	}
+	return found, nil
+}
+
+// Formats are the formats Export writes.
+func Formats() []string {
+	return export.Formats()
+}
+
+// Export writes every transcription of the user to outputDir in format, one file per transcription,
+// and returns how many files were written. Subtitle formats skip transcriptions without timestamps.
+func (c *Client) Export(format string, outputDir string) (int, error) {
+	artifacts, ok := c.db.(repository.ArtifactDAO)
+	if !ok {
+		return 0, errors.New("the database doesn't keep export artifacts")
+	}
+	result, err := export.ReExport(c.db, artifacts, export.ReExportOptions{
+		User:      c.user,
+		Format:    format,
+		OutputDir: outputDir,
+		Force:     true,
+		Write:     export.DefaultOptions(),
+	})
+	return result.Written, err
+}
+
+func newTranscription(t model.Transcription) Transcription {
+	result := Transcription{
+		ID:            t.ID,
+		User:          t.User,
+		File:          t.Mp3FileName,
+		Text:          t.Transcription,
+		AudioDuration: t.AudioDuration,
+		Provider:      t.ProviderMetadata.Provider,
+		Model:         t.ProviderMetadata.Model,
+		TranscribedAt: t.LastConversionTime,
+	}
+	for _, s := range t.Segments {
+		result.Segments = append(result.Segments, Segment{Start: s.Start, End: s.End, Speaker: s.Speaker, Text: s.Text})
+	}
+	return result
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tiktok-whisper/internal/app/model"
+	"tiktok-whisper/internal/app/repository/memory"
+)
+
+type fakeTranscriber struct{}
+
+func (fakeTranscriber) Transcript(inputFilePath string) (string, error) {
+	text, _, err := fakeTranscriber{}.TranscriptWithMetadata(inputFilePath)
+	return text, err
+}
+
+func (fakeTranscriber) TranscriptWithMetadata(inputFilePath string) (string, model.ProviderMetadata, error) {
+	if strings.HasSuffix(inputFilePath, "broken.mp3") {
+		return "", model.ProviderMetadata{}, errors.New("decode failed")
+	}
+	text := "Hello from " + strings.TrimSuffix(filepath.Base(inputFilePath), ".mp3")
+	return text, model.ProviderMetadata{
+		Provider: "fake",
+		Segments: []model.Segment{{Start: 0, End: 1.5, Text: text}},
+	}, nil
+}
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	c := newClient(Config{User: "alice"}, fakeTranscriber{}, memory.NewMemoryDB())
+	c.duration = func(filePath string) (int, error) { return 42, nil }
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient_Transcribe(t *testing.T) {
+	c := newTestClient(t)
+
+	tests := []struct {
+		name     string
+		file     string
+		wantText string
+		wantErr  bool
+	}{
+		{name: "stored", file: "talk.mp3", wantText: "Hello from talk"},
+		{name: "transcribed again", file: "talk.mp3", wantText: "Hello from talk"},
+		{name: "failed", file: "broken.mp3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Transcribe(filepath.Join("audio", tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transcribe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.ID == 0 || got.User != "alice" || got.File != tt.file || got.Text != tt.wantText ||
+				got.AudioDuration != 42 || got.Provider != "fake" || len(got.Segments) != 1 {
+				t.Errorf("Transcribe() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestClient_Search(t *testing.T) {
+	c := newTestClient(t)
+	for _, f := range []string{"paris.mp3", "berlin.mp3"} {
+		if _, err := c.Transcribe(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{query: "hello", want: 2},
+		{query: "PARIS hello", want: 1},
+		{query: "paris berlin", want: 0},
+		{query: " ,", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := c.Search(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Search() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("Search() = %+v, want %d transcriptions", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Export(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.Transcribe("talk.mp3"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	written, err := c.Export("srt", dir)
+	if err != nil || written != 1 {
+		t.Fatalf("Export() = %d, %v, want 1 file", written, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || filepath.Ext(entries[0].Name()) != ".srt" {
+		t.Errorf("exported %v, want one srt file", entries)
+	}
+}
@@ -9,5 +9,30 @@ CREATE TABLE transcriptions
     last_conversion_time TIMESTAMP NOT NULL,
     has_error            INTEGER   NOT NULL,
     error_message        VARCHAR,
-    user_nickname        VARCHAR
+    user_nickname        VARCHAR,
+    provider_metadata    TEXT      NOT NULL DEFAULT '',
+    current_revision     INTEGER   NOT NULL DEFAULT 0
+);
+
+CREATE TABLE artifacts
+(
+    id               SERIAL PRIMARY KEY,
+    transcription_id INTEGER   NOT NULL,
+    format           VARCHAR   NOT NULL,
+    path             VARCHAR   NOT NULL,
+    writer_version   INTEGER   NOT NULL,
+    content_hash     VARCHAR   NOT NULL,
+    exported_at      TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_artifacts_transcription ON artifacts (transcription_id, format);
+
+CREATE TABLE transcription_revisions
+(
+    id                SERIAL PRIMARY KEY,
+    transcription_id  INTEGER   NOT NULL,
+    revision          INTEGER   NOT NULL,
+    transcription     VARCHAR   NOT NULL,
+    provider_metadata TEXT      NOT NULL DEFAULT '',
+    created_at        TIMESTAMP NOT NULL,
+    UNIQUE (transcription_id, revision)
 );
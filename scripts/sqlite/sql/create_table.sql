@@ -9,5 +9,30 @@ CREATE TABLE IF NOT EXISTS transcriptions
     transcription        TEXT     NOT NULL,
     last_conversion_time DATETIME NOT NULL,
     has_error            INTEGER  NOT NULL,
-    error_message        TEXT
-);
\ No newline at end of file
+    error_message        TEXT,
+    provider_metadata    TEXT     NOT NULL DEFAULT '',
+    current_revision     INTEGER  NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS artifacts
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    transcription_id INTEGER  NOT NULL,
+    format           TEXT     NOT NULL,
+    path             TEXT     NOT NULL,
+    writer_version   INTEGER  NOT NULL,
+    content_hash     TEXT     NOT NULL,
+    exported_at      DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_artifacts_transcription ON artifacts (transcription_id, format);
+
+CREATE TABLE IF NOT EXISTS transcription_revisions
+(
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    transcription_id  INTEGER  NOT NULL,
+    revision          INTEGER  NOT NULL,
+    transcription     TEXT     NOT NULL,
+    provider_metadata TEXT     NOT NULL DEFAULT '',
+    created_at        DATETIME NOT NULL,
+    UNIQUE (transcription_id, revision)
+);